@@ -0,0 +1,199 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/pkg/audit"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/user_switch"
+	log "github.com/sirupsen/logrus"
+	"github.com/swaggo/swag"
+)
+
+// AccessLevel is the kind of authentication a route requires.
+type AccessLevel string
+
+const (
+	// AccessAnonymous routes can be called without any authentication.
+	AccessAnonymous AccessLevel = "anonymous"
+	// AccessUser routes require the X-User-Id header of an existing user, or a user switch session.
+	AccessUser AccessLevel = "user"
+	// AccessAdmin routes require the X-User-Id header of an admin user, switch sessions are not enough.
+	AccessAdmin AccessLevel = "admin"
+	// AccessToken routes are authenticated by the {token} path variable instead of a user,
+	// the handler checks the token against the store of the route's scope.
+	AccessToken AccessLevel = "token"
+)
+
+// Access is the auth requirement of a route.
+type Access struct {
+	Level AccessLevel
+	// Scope names the kind of token accepted by AccessToken routes, e.g. "webhook".
+	Scope string
+}
+
+var (
+	anonymous = Access{Level: AccessAnonymous}
+	authUser  = Access{Level: AccessUser}
+	admin     = Access{Level: AccessAdmin}
+)
+
+func token(scope string) Access {
+	return Access{Level: AccessToken, Scope: scope}
+}
+
+func (a Access) String() string {
+	if a.Scope != "" {
+		return string(a.Level) + ":" + a.Scope
+	}
+	return string(a.Level)
+}
+
+// authenticatesUser reports whether requests of the route are let through only for an authenticated user.
+func (a Access) authenticatesUser() bool {
+	return a.Level == AccessUser || a.Level == AccessAdmin
+}
+
+// accessRouter registers routes together with their auth requirements.
+type accessRouter struct {
+	router *mux.Router
+	access map[*mux.Route]Access
+	// audited routes record their action in the audit log
+	audited map[*mux.Route]audit.Action
+}
+
+func newAccessRouter(r *mux.Router) *accessRouter {
+	return &accessRouter{router: r, access: make(map[*mux.Route]Access), audited: make(map[*mux.Route]audit.Action)}
+}
+
+func (ar *accessRouter) handle(access Access, path string, handler http.HandlerFunc) *mux.Route {
+	route := ar.router.HandleFunc(path, handler)
+	ar.access[route] = access
+	return route
+}
+
+// routeAccess returns the auth requirement of the matched route. Routes registered without one
+// (e.g. the frontend) are anonymous.
+func (ar *accessRouter) routeAccess(route *mux.Route) Access {
+	if access, ok := ar.access[route]; ok {
+		return access
+	}
+	return anonymous
+}
+
+// isAdmin reports whether the user may call admin routes. Without configured admins nobody is an admin.
+func isAdmin(cfg config.Admin, u user.User) bool {
+	return slices.Contains(cfg.UserUids, u.Uid)
+}
+
+// accessMiddleware rejects requests that do not meet the auth requirement of the matched route.
+// It must run after the user has been propagated into the request context.
+func (ar *accessRouter) accessMiddleware(cfg config.Admin) mux.MiddlewareFunc {
+	if len(cfg.UserUids) == 0 {
+		log.Warn("No admin users configured (KLOKKU_ADMIN_USERUIDS), admin endpoints are disabled")
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			access := ar.routeAccess(mux.CurrentRoute(req))
+			switch access.Level {
+			case AccessUser, AccessAdmin:
+				u, err := user.CurrentUser(req.Context())
+				if err != nil {
+					http.Error(w, "user not found", http.StatusForbidden)
+					return
+				}
+				if _, switched := user_switch.CurrentSession(req.Context()); switched && access.Level == AccessAdmin {
+					log.Debugf("admin access of user %s rejected for a switch session", u.Uid)
+					http.Error(w, "admin access requires the user header", http.StatusForbidden)
+					return
+				}
+				if access.Level == AccessAdmin && !isAdmin(cfg, u) {
+					log.Debugf("user %s is not an admin", u.Uid)
+					http.Error(w, "admin access required", http.StatusForbidden)
+					return
+				}
+			case AccessToken:
+				if mux.Vars(req)["token"] == "" {
+					http.Error(w, "missing "+access.Scope+" token", http.StatusBadRequest)
+					return
+				}
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// accessDoc serves the generated OpenAPI spec with the security of each operation taken from the route
+// registrations, so the documented and enforced requirements always match.
+type accessDoc struct {
+	doc string
+}
+
+func (d accessDoc) ReadDoc() string {
+	return d.doc
+}
+
+const accessDocInstance = "klokku"
+
+// registerAccessDoc registers the OpenAPI spec of the given swag instance, updated with the route requirements,
+// under accessDocInstance.
+func (ar *accessRouter) registerAccessDoc(instanceName string) error {
+	doc, err := swag.ReadDoc(instanceName)
+	if err != nil {
+		return err
+	}
+	patched, err := ar.applyAccess(doc)
+	if err != nil {
+		return err
+	}
+	swag.Register(accessDocInstance, accessDoc{doc: patched})
+	return nil
+}
+
+// applyAccess sets the security and the x-access extension of every documented operation of a registered route.
+func (ar *accessRouter) applyAccess(doc string) (string, error) {
+	var spec map[string]any
+	if err := json.Unmarshal([]byte(doc), &spec); err != nil {
+		return "", err
+	}
+	paths, _ := spec["paths"].(map[string]any)
+	for route, access := range ar.access {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			continue
+		}
+		pathItem, ok := paths[path].(map[string]any)
+		if !ok {
+			continue
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			continue
+		}
+		for _, method := range methods {
+			operation, ok := pathItem[strings.ToLower(method)].(map[string]any)
+			if !ok {
+				continue
+			}
+			operation["x-access"] = access.String()
+			switch access.Level {
+			case AccessUser:
+				operation["security"] = []map[string][]string{{"XUserId": {}}, {"XSessionToken": {}}}
+			case AccessAdmin:
+				operation["security"] = []map[string][]string{{"XUserId": {}}}
+			default:
+				operation["security"] = []map[string][]string{}
+			}
+		}
+	}
+	patched, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	return string(patched), nil
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/user_switch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAccessRouter(adminCfg config.Admin) *accessRouter {
+	ar := newAccessRouter(mux.NewRouter())
+	// Stand-in for the user propagation middleware
+	ar.router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if uid := req.Header.Get("X-User-Id"); uid != "" {
+				req = req.WithContext(user.WithUser(req.Context(), user.User{Id: 1, Uid: uid}))
+			}
+			if token := req.Header.Get(user_switch.SessionHeader); token != "" {
+				ctx := user.WithUser(req.Context(), user.User{Id: 1, Uid: "uid-1"})
+				req = req.WithContext(user_switch.WithSession(ctx, user_switch.Session{Token: token, UserId: 1}))
+			}
+			next.ServeHTTP(w, req)
+		})
+	})
+	ar.router.Use(ar.accessMiddleware(adminCfg))
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	ar.handle(anonymous, "/api/public", ok).Methods("GET")
+	ar.handle(authUser, "/api/private", ok).Methods("GET")
+	ar.handle(admin, "/api/admin", ok).Methods("DELETE")
+	ar.handle(token("webhook"), "/api/hook/{token}", ok).Methods("POST")
+	return ar
+}
+
+func TestAccessMiddleware(t *testing.T) {
+	tests := []struct {
+		name         string
+		admins       []string
+		method       string
+		path         string
+		userUid      string
+		sessionToken string
+		expected     int
+	}{
+		{"anonymous without user", nil, "GET", "/api/public", "", "", http.StatusOK},
+		{"user route without user", nil, "GET", "/api/private", "", "", http.StatusForbidden},
+		{"user route with user", nil, "GET", "/api/private", "uid-1", "", http.StatusOK},
+		{"admin route without user", nil, "DELETE", "/api/admin", "", "", http.StatusForbidden},
+		{"admin route without configured admins", nil, "DELETE", "/api/admin", "uid-1", "", http.StatusForbidden},
+		{"admin route with admin", []string{"uid-1"}, "DELETE", "/api/admin", "uid-1", "", http.StatusOK},
+		{"admin route with other user", []string{"uid-2"}, "DELETE", "/api/admin", "uid-1", "", http.StatusForbidden},
+		{"token route without user", nil, "POST", "/api/hook/abc", "", "", http.StatusOK},
+		{"user route with switch session", nil, "GET", "/api/private", "", "session-1", http.StatusOK},
+		{"admin route with switch session", nil, "DELETE", "/api/admin", "", "session-1", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ar := newTestAccessRouter(config.Admin{UserUids: tt.admins})
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.userUid != "" {
+				req.Header.Set("X-User-Id", tt.userUid)
+			}
+			if tt.sessionToken != "" {
+				req.Header.Set(user_switch.SessionHeader, tt.sessionToken)
+			}
+			rec := httptest.NewRecorder()
+
+			ar.router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expected, rec.Code)
+		})
+	}
+}
+
+func TestAccessRouter_applyAccess(t *testing.T) {
+	ar := newTestAccessRouter(config.Admin{})
+	doc := `{"paths": {
+		"/api/public": {"get": {"security": [{"XUserId": []}]}},
+		"/api/private": {"get": {}},
+		"/api/admin": {"delete": {}},
+		"/api/hook/{token}": {"post": {}}
+	}}`
+
+	patched, err := ar.applyAccess(doc)
+	require.NoError(t, err)
+
+	var spec struct {
+		Paths map[string]map[string]struct {
+			Security []map[string][]string `json:"security"`
+			Access   string                `json:"x-access"`
+		} `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(patched), &spec))
+	userSecurity := []map[string][]string{{"XUserId": {}}, {"XSessionToken": {}}}
+	adminSecurity := []map[string][]string{{"XUserId": {}}}
+	assert.Empty(t, spec.Paths["/api/public"]["get"].Security)
+	assert.Equal(t, "anonymous", spec.Paths["/api/public"]["get"].Access)
+	assert.Equal(t, userSecurity, spec.Paths["/api/private"]["get"].Security)
+	assert.Equal(t, "user", spec.Paths["/api/private"]["get"].Access)
+	assert.Equal(t, adminSecurity, spec.Paths["/api/admin"]["delete"].Security)
+	assert.Equal(t, "admin", spec.Paths["/api/admin"]["delete"].Access)
+	assert.Empty(t, spec.Paths["/api/hook/{token}"]["post"].Security)
+	assert.Equal(t, "token:webhook", spec.Paths["/api/hook/{token}"]["post"].Access)
+}
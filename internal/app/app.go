@@ -1,13 +1,25 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/klokku/klokku/docs"
+	"github.com/klokku/klokku/internal/backup"
 	"github.com/klokku/klokku/internal/config"
 	"github.com/klokku/klokku/internal/database"
+	"github.com/klokku/klokku/internal/outbound"
 	"github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/internal/tracing"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -16,11 +28,88 @@ type Application struct {
 	cfg    config.Application
 	router *mux.Router
 	srv    *http.Server
+	db     *pgxpool.Pool
+	deps   *Dependencies
+	// tracing is nil when tracing is disabled
+	tracing *tracing.Provider
+	// jobs tracks the running background jobs
+	jobs sync.WaitGroup
+}
+
+const configPath = "./config/application.yaml"
+
+// Migrate applies the pending database migrations without starting the application.
+func Migrate() error {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	if err := database.Migrate(cfg.Database); err != nil {
+		return err
+	}
+	log.Info("Database migrations applied")
+	return nil
+}
+
+// Restore replaces the data in the database with the stored backup of the given name. Klokku must not be running
+// meanwhile, the restore does not stop other instances from writing.
+func Restore(name string) error {
+	return withBackupService(func(ctx context.Context, service *backup.ServiceImpl) error {
+		restored, err := service.Restore(ctx, name)
+		if err != nil {
+			return err
+		}
+		if restored.UserId == 0 {
+			log.Infof("Restored the whole instance from backup %s", name)
+		} else {
+			log.Infof("Restored user %d from backup %s", restored.UserId, name)
+		}
+		return nil
+	})
+}
+
+// VerifyBackup restores the stored backup of the given name into a temporary schema, which is dropped afterwards,
+// to prove the backup can be restored. The data of the instance is not touched, Klokku may keep running.
+func VerifyBackup(name string) error {
+	return withBackupService(func(ctx context.Context, service *backup.ServiceImpl) error {
+		verified, err := service.Verify(ctx, name)
+		if err != nil {
+			return err
+		}
+		log.Infof("Backup %s can be restored (created at %s)", name, verified.CreatedAt.Format(time.RFC3339))
+		return nil
+	})
+}
+
+func withBackupService(fn func(ctx context.Context, service *backup.ServiceImpl) error) error {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	// Restoring a larger instance takes longer than the statement timeout of requests
+	cfg.Database.StatementTimeoutMs = 0
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	service, err := newBackupService(db, outbound.NewRegistry(), cfg.Backup)
+	if err != nil {
+		return err
+	}
+	return fn(context.Background(), service)
 }
 
 // NewApplication constructs the full HTTP application, ready to Run().
 func NewApplication() (*Application, error) {
-	cfg, err := config.Load("./config/application.yaml")
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tracing is set up first, so the instrumented database and clients use its provider
+	tracingProvider, err := tracing.Setup(cfg.Tracing)
 	if err != nil {
 		return nil, err
 	}
@@ -30,21 +119,32 @@ func NewApplication() (*Application, error) {
 	if err != nil {
 		return nil, err
 	}
-	// db will be closed when server shuts down; defer not possible here, leave to process exit.
-	if err := database.Migrate(cfg.Database); err != nil {
-		return nil, err
+	// db is closed by Run once the server has shut down
+	if cfg.Database.AutoMigrate {
+		if err := database.Migrate(cfg.Database); err != nil {
+			return nil, err
+		}
 	}
 
 	r := mux.NewRouter()
 
 	// Build dependencies (services, handlers...)
-	deps := BuildDependencies(db, cfg)
+	deps, err := BuildDependencies(db, cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	// Middleware chain
-	SetupMiddleware(r, deps, cfg)
+	ar := newAccessRouter(r)
+	if err := SetupMiddleware(ar, deps, cfg); err != nil {
+		return nil, err
+	}
 
 	// Routes
-	RegisterRoutes(r, deps, cfg)
+	RegisterRoutes(ar, deps, cfg)
+	if err := ar.registerAccessDoc(docs.SwaggerInfo.InstanceName()); err != nil {
+		return nil, err
+	}
 
 	// Frontend
 	if cfg.Frontend.Enabled {
@@ -60,11 +160,92 @@ func NewApplication() (*Application, error) {
 		IdleTimeout:  60 * time.Second,
 	}
 
-	return &Application{cfg: cfg, router: r, srv: srv}, nil
+	return &Application{cfg: cfg, router: r, srv: srv, db: db, deps: deps, tracing: tracingProvider}, nil
 }
 
-// Run starts the HTTP server and blocks.
+// Run starts the background jobs and the HTTP server and blocks until SIGTERM or SIGINT, then shuts down gracefully.
 func (a *Application) Run() error {
-	log.Infof("Starting server on %s", a.srv.Addr)
-	return a.srv.ListenAndServe()
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stopSignals()
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	a.startJob(jobsCtx, a.deps.CredentialsService.EncryptPlaintextTokens)
+	a.startJob(jobsCtx, a.deps.Scheduler.Start)
+	a.startJob(jobsCtx, a.deps.Outbox.StartWorker)
+	if a.deps.MqttClient != nil {
+		a.startJob(jobsCtx, a.deps.MqttClient.Run)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Infof("Starting server on %s", a.srv.Addr)
+		serveErr <- a.srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		// The server did not start, e.g. the port is taken
+		return errors.Join(err, a.shutdown(stopJobs))
+	case <-signalCtx.Done():
+	}
+	// A second signal terminates right away
+	stopSignals()
+	log.Info("Shutting down")
+	if err := a.shutdown(stopJobs); err != nil {
+		return err
+	}
+	log.Info("Shut down")
+	return nil
+}
+
+func (a *Application) startJob(ctx context.Context, job func(ctx context.Context)) {
+	a.jobs.Add(1)
+	go func() {
+		defer a.jobs.Done()
+		job(ctx)
+	}()
+}
+
+// shutdown stops taking new requests, drains the requests in flight, stops the background jobs, waits for the
+// event subscribers to finish and closes the database, so no writes are lost. Spans are exported last, as the
+// previous steps record them.
+func (a *Application) shutdown(stopJobs context.CancelFunc) error {
+	a.deps.HealthHandler.SetDraining()
+	if delay := time.Duration(a.cfg.Shutdown.DrainDelaySeconds) * time.Second; delay > 0 {
+		log.Infof("Waiting %s for load balancers to stop routing requests", delay)
+		time.Sleep(delay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(a.cfg.Shutdown.TimeoutSeconds)*time.Second)
+	defer cancel()
+	var errs []error
+	if err := a.srv.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to drain requests: %w", err))
+	}
+
+	stopJobs()
+	jobsDone := make(chan struct{})
+	go func() {
+		a.jobs.Wait()
+		close(jobsDone)
+	}()
+	select {
+	case <-jobsDone:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("background jobs still running: %w", ctx.Err()))
+	}
+
+	if err := a.deps.EventBus.Close(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to flush event subscribers: %w", err))
+	}
+	a.db.Close()
+
+	// Spans get their own timeout, the previous steps may have used up the shared one
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelTracing()
+	if err := a.tracing.Shutdown(tracingCtx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to export remaining spans: %w", err))
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"maps"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/pkg/audit"
+)
+
+// handleAudited registers a route like handle, recording the action in the audit log whenever the route succeeds.
+func (ar *accessRouter) handleAudited(action audit.Action, access Access, path string, handler http.HandlerFunc) *mux.Route {
+	route := ar.handle(access, path, handler)
+	ar.audited[route] = action
+	return route
+}
+
+// clientIpMiddleware stores the address of the client in the request context for the audit log.
+func clientIpMiddleware(proxies audit.TrustedProxies) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctx := audit.WithClientIp(req.Context(), audit.RequestIp(req, proxies))
+			next.ServeHTTP(w, req.WithContext(ctx))
+		})
+	}
+}
+
+// auditMiddleware records the action of audited routes responding with a success, with the route template as the
+// resource and the path variables as details. Tokens in the path are never recorded.
+func (ar *accessRouter) auditMiddleware(recorder audit.Recorder) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			route := mux.CurrentRoute(req)
+			action, ok := ar.audited[route]
+			if !ok {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			recorded := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorded, req)
+			if recorded.status >= http.StatusBadRequest {
+				return
+			}
+			details := maps.Clone(mux.Vars(req))
+			delete(details, "token")
+			recorder.Record(req.Context(), action, routeSpanName("", req), details)
+		})
+	}
+}
+
+// statusRecorder remembers the status code written by the handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
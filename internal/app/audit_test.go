@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/pkg/audit"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedAction struct {
+	action   audit.Action
+	actorUid string
+	ip       string
+	resource string
+	details  map[string]string
+}
+
+type recorderStub struct {
+	actions []recordedAction
+}
+
+func (r *recorderStub) Record(ctx context.Context, action audit.Action, resource string, details map[string]string) {
+	u, _ := user.CurrentUser(ctx)
+	r.actions = append(r.actions, recordedAction{action, u.Uid, audit.ClientIp(ctx), resource, details})
+}
+
+func newTestAuditRouter(recorder audit.Recorder, proxies audit.TrustedProxies) *accessRouter {
+	ar := newAccessRouter(mux.NewRouter())
+	ar.router.Use(clientIpMiddleware(proxies))
+	ar.router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(w, req.WithContext(user.WithUser(req.Context(), user.User{Id: 1, Uid: "uid-1"})))
+		})
+	})
+	ar.router.Use(ar.auditMiddleware(recorder))
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	notFound := func(w http.ResponseWriter, r *http.Request) { http.Error(w, "not found", http.StatusNotFound) }
+	ar.handleAudited(audit.ActionDeleted, admin, "/api/user/{userUid}", ok).Methods("DELETE")
+	ar.handleAudited(audit.ActionDeleted, authUser, "/api/missing/{id}", notFound).Methods("DELETE")
+	ar.handleAudited(audit.ActionIntegrationDisabled, token("webhook"), "/api/hook/{token}", ok).Methods("DELETE")
+	ar.handle(authUser, "/api/private", ok).Methods("GET")
+	return ar
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	t.Run("should record successful audited requests", func(t *testing.T) {
+		// given
+		recorder := &recorderStub{}
+		ar := newTestAuditRouter(recorder, nil)
+		req := httptest.NewRequest("DELETE", "/api/user/uid-2", nil)
+		req.RemoteAddr = "192.0.2.1:51234"
+		req.Header.Set("X-Forwarded-For", "198.51.100.7")
+
+		// when
+		ar.router.ServeHTTP(httptest.NewRecorder(), req)
+
+		// then
+		require.Len(t, recorder.actions, 1)
+		assert.Equal(t, recordedAction{
+			action:   audit.ActionDeleted,
+			actorUid: "uid-1",
+			ip:       "192.0.2.1",
+			resource: "DELETE /api/user/{userUid}",
+			details:  map[string]string{"userUid": "uid-2"},
+		}, recorder.actions[0])
+	})
+
+	t.Run("should take the client address from the proxy when trusted", func(t *testing.T) {
+		// given
+		recorder := &recorderStub{}
+		proxies, err := audit.ParseTrustedProxies([]string{"192.0.2.0/24"})
+		require.NoError(t, err)
+		ar := newTestAuditRouter(recorder, proxies)
+		req := httptest.NewRequest("DELETE", "/api/user/uid-2", nil)
+		req.RemoteAddr = "192.0.2.1:51234"
+		req.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.7")
+
+		// when
+		ar.router.ServeHTTP(httptest.NewRecorder(), req)
+
+		// then
+		require.Len(t, recorder.actions, 1)
+		assert.Equal(t, "198.51.100.7", recorder.actions[0].ip)
+	})
+
+	t.Run("should not record tokens, failed or not audited requests", func(t *testing.T) {
+		// given
+		recorder := &recorderStub{}
+		ar := newTestAuditRouter(recorder, nil)
+
+		// when
+		ar.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/api/missing/1", nil))
+		ar.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/private", nil))
+		ar.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/api/hook/secret", nil))
+
+		// then
+		require.Len(t, recorder.actions, 1)
+		assert.Equal(t, "DELETE /api/hook/{token}", recorder.actions[0].resource)
+		assert.Empty(t, recorder.actions[0].details)
+	})
+}
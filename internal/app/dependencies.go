@@ -1,20 +1,61 @@
 package app
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/klokku/klokku/internal/backup"
 	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/internal/database"
 	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/health"
+	"github.com/klokku/klokku/internal/mqtt"
+	"github.com/klokku/klokku/internal/outbound"
+	"github.com/klokku/klokku/internal/scheduler"
 	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/absence"
+	"github.com/klokku/klokku/pkg/announcement"
+	"github.com/klokku/klokku/pkg/audit"
+	"github.com/klokku/klokku/pkg/budget_alert"
+	"github.com/klokku/klokku/pkg/budget_item_backfill"
 	"github.com/klokku/klokku/pkg/budget_plan"
 	"github.com/klokku/klokku/pkg/budget_plan_report"
+	"github.com/klokku/klokku/pkg/budget_plan_transfer"
+	"github.com/klokku/klokku/pkg/budget_plan_validation"
+	"github.com/klokku/klokku/pkg/budget_rollover"
 	"github.com/klokku/klokku/pkg/calendar"
 	"github.com/klokku/klokku/pkg/calendar_provider"
+	"github.com/klokku/klokku/pkg/chat_notification"
 	"github.com/klokku/klokku/pkg/clickup"
+	"github.com/klokku/klokku/pkg/credentials"
 	"github.com/klokku/klokku/pkg/current_event"
+	"github.com/klokku/klokku/pkg/db_activity"
+	"github.com/klokku/klokku/pkg/event_classifier"
+	"github.com/klokku/klokku/pkg/event_schedule"
+	"github.com/klokku/klokku/pkg/export_stream"
+	"github.com/klokku/klokku/pkg/goal"
+	"github.com/klokku/klokku/pkg/google_calendar"
+	"github.com/klokku/klokku/pkg/monthly_statement"
+	"github.com/klokku/klokku/pkg/mqtt_bridge"
+	"github.com/klokku/klokku/pkg/oidc"
+	"github.com/klokku/klokku/pkg/onboarding"
+	"github.com/klokku/klokku/pkg/outlook_calendar"
+	"github.com/klokku/klokku/pkg/quick_action"
+	"github.com/klokku/klokku/pkg/sandbox"
+	"github.com/klokku/klokku/pkg/share_link"
 	"github.com/klokku/klokku/pkg/stats"
+	"github.com/klokku/klokku/pkg/time_export"
+	"github.com/klokku/klokku/pkg/time_import"
+	"github.com/klokku/klokku/pkg/toggl"
 	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/user_switch"
 	"github.com/klokku/klokku/pkg/webhook"
+	"github.com/klokku/klokku/pkg/webhook_subscription"
+	"github.com/klokku/klokku/pkg/week_review"
+	"github.com/klokku/klokku/pkg/weekly_digest"
 	"github.com/klokku/klokku/pkg/weekly_plan"
+	"github.com/klokku/klokku/pkg/workspace"
 )
 
 // Dependencies holds all services and handlers for the application.
@@ -22,7 +63,33 @@ type Dependencies struct {
 	UserService user.Service
 	UserHandler *user.Handler
 
-	EventBus *event_bus.EventBus
+	UserSwitchService user_switch.Service
+	UserSwitchHandler *user_switch.Handler
+
+	OidcService oidc.Service
+	OidcHandler *oidc.Handler
+
+	EventBus      *event_bus.EventBus
+	Outbox        *event_bus.Outbox
+	EventsHandler *event_bus.Handler
+
+	AuditService audit.Service
+	AuditHandler *audit.Handler
+
+	Outbound        *outbound.Registry
+	OutboundHandler *outbound.Handler
+
+	HealthHandler     *health.Handler
+	MigrationsHandler *database.MigrationsHandler
+
+	Scheduler   *scheduler.Scheduler
+	JobsHandler *scheduler.Handler
+
+	BackupService *backup.ServiceImpl
+	BackupHandler *backup.Handler
+
+	CredentialsService *credentials.ServiceImpl
+	CredentialsHandler *credentials.Handler
 
 	BudgetRepo        budget_plan.Repository
 	BudgetPlanService budget_plan.Service
@@ -32,69 +99,281 @@ type Dependencies struct {
 	WebhookService webhook.Service
 	WebhookHandler *webhook.Handler
 
-	WeeklyPlanRepo    weekly_plan.Repository
-	WeeklyPlanService weekly_plan.Service
-	WeeklyPlanHandler *weekly_plan.Handler
+	AbsenceService absence.Service
+	AbsenceHandler *absence.Handler
+
+	WeeklyPlanRepo      weekly_plan.Repository
+	WeeklyPlanService   weekly_plan.Service
+	WeeklyPlanHandler   *weekly_plan.Handler
+	WeeklyPlanGenerator *weekly_plan.Generator
 
 	KlokkuCalendarRepository calendar.Repository
 	KlokkuCalendarService    *calendar.Service
 	KlokkuCalendarHandler    *calendar.Handler
+	CalendarArchiver         *calendar.Archiver
 
-	CalendarProvider *calendar_provider.CalendarProvider
+	CalendarProviderRegistry *calendar_provider.Registry
+	CalendarProvider         *calendar_provider.CalendarProvider
+	CalendarProviderHandler  *calendar_provider.Handler
+
+	WorkspaceService workspace.Service
+	WorkspaceHandler *workspace.Handler
 
 	CurrentEventRepo    current_event.Repository
 	CurrentEventService current_event.Service
 	CurrentEventHandler *current_event.EventHandler
 
+	EventScheduleRepo    event_schedule.Repository
+	EventScheduleService *event_schedule.ServiceImpl
+	EventScheduleHandler *event_schedule.Handler
+
+	ExportStreamRepo    export_stream.Repository
+	ExportStreamService export_stream.Service
+	ExportStreamHandler *export_stream.Handler
+
+	OnboardingRepo    onboarding.Repository
+	OnboardingService onboarding.Service
+	OnboardingHandler *onboarding.Handler
+
+	SandboxService sandbox.Service
+	SandboxHandler *sandbox.Handler
+
 	StatsService stats.StatsService
 	StatsHandler *stats.StatsHandler
 
+	WeekReviewService week_review.Service
+	WeekReviewHandler *week_review.Handler
+
+	ShareLinkService share_link.Service
+	ShareLinkHandler *share_link.Handler
+
+	QuickActionService quick_action.Service
+	QuickActionHandler *quick_action.Handler
+
+	EventClassifierService event_classifier.Service
+	EventClassifierHandler *event_classifier.Handler
+
 	BudgetPlanReportService budget_plan_report.Service
 	BudgetPlanReportHandler *budget_plan_report.Handler
 
+	BudgetPlanValidationService budget_plan_validation.Service
+	BudgetPlanValidationHandler *budget_plan_validation.Handler
+
+	BudgetPlanTransferService  budget_plan_transfer.Service
+	BudgetPlanTransferHandler  *budget_plan_transfer.Handler
+	BudgetItemBackfillService  budget_item_backfill.Service
+	BudgetItemBackfillHandler  *budget_item_backfill.Handler
+	TimeExportService          time_export.Service
+	TimeExportHandler          *time_export.Handler
+	MonthlyStatementService    monthly_statement.Service
+	MonthlyStatementHandler    *monthly_statement.Handler
+	TimeImportService          time_import.Service
+	TimeImportHandler          *time_import.Handler
+	DbActivityService          db_activity.Service
+	DbActivityHandler          *db_activity.Handler
+	AnnouncementService        announcement.Service
+	AnnouncementHandler        *announcement.Handler
+	GoalService                goal.Service
+	GoalHandler                *goal.Handler
+	WeeklyDigestService        weekly_digest.Service
+	WebhookSubscriptionService webhook_subscription.Service
+	WebhookSubscriptionHandler *webhook_subscription.Handler
+	ChatNotificationService    chat_notification.Service
+	ChatNotificationHandler    *chat_notification.Handler
+	BudgetAlertService         budget_alert.Service
+	BudgetAlertHandler         *budget_alert.Handler
+	// MqttClient is nil when no MQTT broker is configured
+	MqttClient        *mqtt.Client
+	MqttBridgeService mqtt_bridge.Service
+	MqttBridgeHandler *mqtt_bridge.Handler
+
+	BudgetRolloverService budget_rollover.Service
+
 	ClickUpAuth    *clickup.ClickUpAuth
 	ClickUpClient  clickup.Client
 	ClickUpRepo    clickup.Repository
 	ClickUpService *clickup.ServiceImpl
 	ClickUpHandler *clickup.Handler
 
+	ClickUpTimeTrackingService *clickup.TimeTrackingServiceImpl
+	ClickUpTimeTrackingHandler *clickup.TimeTrackingHandler
+
+	OutlookAuth     *outlook_calendar.OutlookAuth
+	OutlookClient   outlook_calendar.Client
+	OutlookCalendar *outlook_calendar.OutlookCalendar
+	OutlookHandler  *outlook_calendar.Handler
+
+	GoogleAuth     *google_calendar.GoogleAuth
+	GoogleClient   google_calendar.Client
+	GoogleCalendar *google_calendar.GoogleCalendar
+	GoogleSyncer   *google_calendar.Syncer
+	GoogleHandler  *google_calendar.Handler
+
+	TogglService *toggl.ServiceImpl
+	TogglHandler *toggl.Handler
+
 	Clock utils.Clock
 }
 
 // BuildDependencies initializes and wires all application services and handlers.
-func BuildDependencies(db *pgxpool.Pool, cfg config.Application) *Dependencies {
+func BuildDependencies(db *pgxpool.Pool, cfg config.Application) (*Dependencies, error) {
 	deps := &Dependencies{}
 
 	deps.EventBus = event_bus.NewEventBus()
 
-	deps.UserService = user.NewUserService(user.NewUserRepo(db))
+	deps.Outbound = outbound.NewRegistry()
+	deps.OutboundHandler = outbound.NewHandler(deps.Outbound)
+
+	credentialsCipher, err := credentials.NewCipher(cfg.Credentials.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid credentials encryption key: %w", err)
+	}
+	deps.CredentialsService = credentials.NewService(credentials.NewRepository(db), credentialsCipher, deps.EventBus)
+	deps.CredentialsHandler = credentials.NewHandler(deps.CredentialsService)
+
+	// A database outage is not fixed by restarting the application, so it only fails the readiness
+	deps.HealthHandler = health.NewHandler(
+		health.NewChecker(),
+		health.NewChecker(health.DatabaseCheck(db), health.MigrationsCheck(db), health.TokenStoreCheck(deps.CredentialsService)),
+	)
+
+	deps.MigrationsHandler = database.NewMigrationsHandler(db)
+
+	userService := user.NewUserService(user.NewUserRepo(db), deps.EventBus)
+	userService.OnDelete(deps.CredentialsService.RevokeUser)
+	deps.UserService = userService
+	deps.Outbox = event_bus.NewOutbox(event_bus.NewOutboxRepository(db), user.NewEventUserContext(deps.UserService))
+	deps.EventBus.UseOutbox(deps.Outbox)
+	deps.EventsHandler = event_bus.NewHandler(deps.Outbox)
 	deps.UserHandler = user.NewHandler(deps.UserService)
 
+	deps.AuditService = audit.NewService(audit.NewRepository(db), deps.UserService, deps.EventBus)
+	deps.AuditHandler = audit.NewHandler(deps.AuditService)
+
+	deps.UserSwitchService = user_switch.NewService(user_switch.NewRepository(db), deps.UserService, cfg.UserSwitch)
+	deps.UserSwitchHandler = user_switch.NewHandler(deps.UserSwitchService, deps.AuditService)
+
+	var oidcClient oidc.Client
+	if cfg.Oidc.Issuer != "" {
+		oidcClient = oidc.NewClient(cfg.Oidc.Issuer, cfg.Oidc.ClientId, cfg.Oidc.ClientSecret,
+			cfg.Host+"/api/auth/oidc/callback", deps.Outbound.Client("oidc", outbound.DefaultPolicy))
+	}
+	deps.OidcService = oidc.NewService(oidc.NewRepository(db), oidcClient, deps.UserService, deps.UserSwitchService, cfg)
+	deps.OidcHandler = oidc.NewHandler(deps.OidcService, deps.AuditService)
+
 	deps.BudgetRepo = budget_plan.NewBudgetPlanRepo(db)
 	deps.BudgetPlanService = budget_plan.NewBudgetPlanService(deps.BudgetRepo, deps.EventBus)
+	userService.OnUpdate(deps.BudgetPlanService.ValidateUserSettings)
 	deps.BudgetPlanHandler = budget_plan.NewBudgetPlanHandler(deps.BudgetPlanService)
 
+	deps.AbsenceService = absence.NewService(absence.NewRepository(db))
+	deps.AbsenceHandler = absence.NewHandler(deps.AbsenceService)
+
 	deps.WeeklyPlanRepo = weekly_plan.NewRepo(db)
-	deps.WeeklyPlanService = weekly_plan.NewService(deps.WeeklyPlanRepo, deps.BudgetPlanService, deps.EventBus)
+	deps.WeeklyPlanService = weekly_plan.NewService(deps.WeeklyPlanRepo, deps.BudgetPlanService, deps.AbsenceService, deps.EventBus)
 	deps.WeeklyPlanHandler = weekly_plan.NewHandler(deps.WeeklyPlanService)
+	deps.WeeklyPlanGenerator = weekly_plan.NewGenerator(deps.WeeklyPlanService, cfg.WeeklyPlan)
+	deps.WeeklyPlanGenerator.SubscribeToBudgetPlanChanges(deps.EventBus)
 
 	deps.KlokkuCalendarRepository = calendar.NewRepository(db)
-	deps.KlokkuCalendarService = calendar.NewService(deps.KlokkuCalendarRepository, deps.EventBus, deps.WeeklyPlanService.GetItemsForWeek)
+	deps.KlokkuCalendarService = calendar.NewService(deps.KlokkuCalendarRepository, deps.EventBus, deps.WeeklyPlanService.GetItemsForWeek,
+		deps.WeeklyPlanService.IsWeekLocked)
+	deps.KlokkuCalendarService.SubscribeToBudgetItemChanges()
+	deps.KlokkuCalendarService.SubscribeToPlanChanges()
+	deps.BudgetPlanService.OnItemDeleted(deps.KlokkuCalendarService.UnlinkBudgetItem)
+	deps.CalendarArchiver = calendar.NewArchiver(deps.KlokkuCalendarRepository, cfg.Archive)
 	deps.KlokkuCalendarHandler = calendar.NewHandler(deps.KlokkuCalendarService)
 
-	deps.CalendarProvider = calendar_provider.NewCalendarProvider(deps.UserService, deps.KlokkuCalendarService)
+	deps.OutlookAuth = outlook_calendar.NewOutlookAuth(deps.UserService, cfg,
+		deps.Outbound.Client("microsoft", outbound.DefaultPolicy), deps.CredentialsService)
+	deps.OutlookClient = outlook_calendar.NewClient(deps.OutlookAuth)
+	deps.OutlookCalendar = outlook_calendar.NewOutlookCalendar(deps.OutlookClient, deps.EventBus,
+		deps.WeeklyPlanService.IsWeekLocked)
+	deps.OutlookHandler = outlook_calendar.NewHandler(deps.OutlookClient)
+
+	googleRepo := google_calendar.NewRepository(db)
+	deps.GoogleAuth = google_calendar.NewGoogleAuth(deps.UserService, cfg,
+		deps.Outbound.Client("google", outbound.DefaultPolicy), deps.CredentialsService)
+	deps.GoogleClient = google_calendar.NewClient(deps.GoogleAuth)
+	deps.GoogleCalendar = google_calendar.NewGoogleCalendar(deps.GoogleClient, googleRepo, deps.EventBus,
+		deps.WeeklyPlanService.IsWeekLocked)
+	deps.GoogleSyncer = google_calendar.NewSyncer(googleRepo, deps.GoogleClient, deps.BudgetPlanService, deps.UserService,
+		deps.EventBus, deps.WeeklyPlanService.IsWeekLocked, cfg.Host)
+	deps.GoogleAuth.OnDisconnect(deps.GoogleSyncer.StopWatching)
+	deps.CredentialsService.RegisterRevoker(credentials.Google, deps.GoogleAuth.RevokeToken)
+	deps.GoogleHandler = google_calendar.NewHandler(deps.GoogleClient, deps.GoogleSyncer)
+
+	deps.CalendarProviderRegistry = calendar_provider.NewRegistry()
+	for _, provider := range []calendar_provider.Provider{
+		{
+			Type:         user.KlokkuCalendar,
+			Name:         "Klokku",
+			Calendar:     deps.KlokkuCalendarService,
+			Capabilities: calendar_provider.Capabilities{SupportsStickyEdit: true, SupportsMetadata: true},
+		},
+		{
+			Type:         user.OutlookCalendar,
+			Name:         "Outlook",
+			Calendar:     deps.OutlookCalendar,
+			Capabilities: calendar_provider.Capabilities{SupportsMetadata: true},
+		},
+		{
+			Type:         user.GoogleCalendar,
+			Name:         "Google",
+			Calendar:     deps.GoogleCalendar,
+			Capabilities: calendar_provider.Capabilities{SupportsMetadata: true},
+		},
+	} {
+		if err := deps.CalendarProviderRegistry.Register(provider); err != nil {
+			return nil, err
+		}
+	}
+	deps.CalendarProvider = calendar_provider.NewCalendarProvider(deps.UserService, deps.CalendarProviderRegistry)
+	deps.CalendarProviderHandler = calendar_provider.NewHandler(deps.CalendarProviderRegistry)
+
+	deps.WorkspaceService = workspace.NewService(workspace.NewRepository(db), deps.UserService, deps.BudgetPlanService,
+		deps.CalendarProvider)
+	deps.WorkspaceHandler = workspace.NewHandler(deps.WorkspaceService)
 
 	deps.CurrentEventRepo = current_event.NewEventRepo(db)
-	deps.CurrentEventService = current_event.NewEventService(deps.CurrentEventRepo, deps.CalendarProvider)
+	deps.CurrentEventService = current_event.NewEventService(deps.CurrentEventRepo, deps.CalendarProvider, deps.WeeklyPlanService, deps.EventBus)
 	deps.CurrentEventHandler = current_event.NewEventHandler(deps.CurrentEventService)
 
+	deps.EventScheduleRepo = event_schedule.NewRepository(db)
+	deps.EventScheduleService = event_schedule.NewService(deps.EventScheduleRepo, deps.CurrentEventService, deps.BudgetPlanService, deps.UserService)
+	deps.EventScheduleHandler = event_schedule.NewHandler(deps.EventScheduleService)
+
+	deps.EventClassifierService = event_classifier.NewService(deps.CalendarProvider, deps.WeeklyPlanService)
+	deps.EventClassifierHandler = event_classifier.NewHandler(deps.EventClassifierService)
+
 	deps.WebhookRepo = webhook.NewRepository(db)
-	deps.WebhookService = webhook.NewService(deps.WebhookRepo, deps.CurrentEventService, deps.BudgetPlanService, deps.UserService)
+	deps.WebhookService = webhook.NewService(deps.WebhookRepo, deps.CurrentEventService, deps.BudgetPlanService, deps.UserService,
+		cfg.Webhook)
 	deps.WebhookHandler = webhook.NewHandler(cfg.Host, deps.WebhookService)
 
+	deps.ExportStreamRepo = export_stream.NewRepository(db)
+	deps.ExportStreamService = export_stream.NewService(deps.ExportStreamRepo, deps.EventBus)
+	deps.ExportStreamHandler = export_stream.NewHandler(cfg.Host, deps.ExportStreamService)
+
+	deps.OnboardingRepo = onboarding.NewRepository(db)
+	deps.OnboardingService = onboarding.NewService(deps.OnboardingRepo, deps.BudgetPlanService, deps.CalendarProvider, deps.CurrentEventService)
+	deps.OnboardingHandler = onboarding.NewHandler(deps.OnboardingService)
+
+	deps.SandboxService = sandbox.NewService(deps.BudgetPlanService, deps.KlokkuCalendarService)
+	deps.SandboxHandler = sandbox.NewHandler(deps.SandboxService)
+
 	deps.Clock = &utils.SystemClock{}
-	deps.StatsService = stats.NewService(deps.CurrentEventService, deps.WeeklyPlanService, deps.BudgetPlanService, deps.CalendarProvider)
-	deps.StatsHandler = stats.NewStatsHandler(deps.StatsService)
+	deps.StatsService = stats.NewService(stats.NewRepository(db), deps.CurrentEventService, deps.WeeklyPlanService, deps.BudgetPlanService, deps.CalendarProvider,
+		deps.AbsenceService)
+	deps.WeekReviewService = week_review.NewService(week_review.NewRepository(db), deps.StatsService)
+	deps.WeekReviewHandler = week_review.NewHandler(deps.WeekReviewService)
+	deps.StatsHandler = stats.NewStatsHandler(deps.StatsService, deps.WeekReviewService)
+
+	deps.ShareLinkService = share_link.NewService(share_link.NewRepository(db), deps.UserService, deps.StatsService)
+	deps.ShareLinkHandler = share_link.NewHandler(cfg.Host, deps.ShareLinkService)
+	deps.QuickActionService = quick_action.NewService(quick_action.NewRepository(db), deps.CurrentEventService,
+		deps.WeeklyPlanService, deps.UserService, deps.StatsService)
+	deps.QuickActionHandler = quick_action.NewHandler(cfg.Host, deps.QuickActionService)
 
 	deps.BudgetPlanReportService = budget_plan_report.NewService(
 		deps.BudgetPlanService,
@@ -105,11 +384,144 @@ func BuildDependencies(db *pgxpool.Pool, cfg config.Application) *Dependencies {
 	)
 	deps.BudgetPlanReportHandler = budget_plan_report.NewHandler(deps.BudgetPlanReportService)
 
-	deps.ClickUpAuth = clickup.NewClickUpAuth(db, deps.UserService, cfg)
+	deps.BudgetRolloverService = budget_rollover.NewService(
+		deps.BudgetPlanService,
+		deps.WeeklyPlanService,
+		deps.CalendarProvider,
+		deps.UserService,
+	)
+
+	deps.BudgetPlanTransferService = budget_plan_transfer.NewService(deps.BudgetPlanService)
+	deps.BudgetPlanTransferHandler = budget_plan_transfer.NewHandler(deps.BudgetPlanTransferService)
+
+	deps.BudgetItemBackfillService = budget_item_backfill.NewService(deps.BudgetPlanService, deps.CalendarProvider, deps.Clock)
+	deps.BudgetItemBackfillHandler = budget_item_backfill.NewHandler(deps.BudgetItemBackfillService)
+
+	deps.TimeExportService = time_export.NewService(deps.CalendarProvider, deps.WeeklyPlanService)
+	deps.TimeExportHandler = time_export.NewHandler(deps.TimeExportService)
+	deps.MonthlyStatementService = monthly_statement.NewService(deps.CalendarProvider, deps.WeeklyPlanService, deps.BudgetPlanService)
+	deps.MonthlyStatementHandler = monthly_statement.NewHandler(deps.MonthlyStatementService)
+	deps.TimeImportService = time_import.NewService(deps.CalendarProvider, deps.BudgetPlanService, deps.EventClassifierService)
+	deps.TimeImportHandler = time_import.NewHandler(deps.TimeImportService)
+	deps.DbActivityService = db_activity.NewService(db_activity.NewRepository(db))
+	deps.DbActivityHandler = db_activity.NewHandler(deps.DbActivityService)
+	deps.AnnouncementService = announcement.NewService(announcement.NewRepository(db), deps.Clock)
+	deps.AnnouncementHandler = announcement.NewHandler(deps.AnnouncementService)
+	deps.GoalService = goal.NewService(goal.NewRepository(db), deps.BudgetPlanService, deps.StatsService, deps.Clock)
+	deps.GoalHandler = goal.NewHandler(deps.GoalService)
+	deps.WeeklyDigestService = weekly_digest.NewService(
+		weekly_digest.NewRepository(db),
+		deps.UserService,
+		deps.StatsService,
+		deps.WeeklyPlanService,
+		weekly_digest.NewSmtpNotifier(cfg.Smtp),
+		cfg.Digest,
+	)
+	webhookPolicy := outbound.DefaultPolicy
+	webhookPolicy.AttemptTimeout = 10 * time.Second
+	// The delivery log retries failed deliveries
+	webhookPolicy.MaxRetries = 0
+	deps.WebhookSubscriptionService, err = webhook_subscription.NewService(webhook_subscription.NewRepository(db),
+		deps.Outbound.PublicClient("webhook", webhookPolicy), deps.EventBus)
+	if err != nil {
+		return nil, err
+	}
+	deps.WebhookSubscriptionHandler = webhook_subscription.NewHandler(deps.WebhookSubscriptionService)
+	chatPolicy := outbound.DefaultPolicy
+	chatPolicy.AttemptTimeout = 10 * time.Second
+	deps.ChatNotificationService = chat_notification.NewService(chat_notification.NewRepository(db), deps.UserService,
+		deps.StatsService, deps.Outbound.PublicClient("chat", chatPolicy), deps.EventBus)
+	deps.ChatNotificationHandler = chat_notification.NewHandler(deps.ChatNotificationService)
+	deps.BudgetAlertService, err = budget_alert.NewService(budget_alert.NewRepository(db), deps.UserService,
+		deps.CurrentEventService, deps.StatsService, deps.EventBus)
+	if err != nil {
+		return nil, err
+	}
+	deps.BudgetAlertHandler = budget_alert.NewHandler(deps.BudgetAlertService)
+	var mqttPublisher mqtt_bridge.Publisher
+	if cfg.Mqtt.Broker != "" {
+		deps.MqttClient, err = mqtt.NewClient(mqtt.Options{
+			Broker:      cfg.Mqtt.Broker,
+			ClientId:    cfg.Mqtt.ClientId,
+			Username:    cfg.Mqtt.Username,
+			Password:    cfg.Mqtt.Password,
+			WillTopic:   mqtt_bridge.StatusTopic(cfg.Mqtt.TopicPrefix),
+			WillMessage: mqtt_bridge.StatusOffline,
+		})
+		if err != nil {
+			return nil, err
+		}
+		mqttPublisher = deps.MqttClient
+	}
+	deps.MqttBridgeService = mqtt_bridge.NewService(mqtt_bridge.NewRepository(db), mqttPublisher, deps.CurrentEventService,
+		deps.BudgetPlanService, deps.UserService, deps.StatsService, deps.EventBus, cfg.Mqtt)
+	deps.MqttBridgeHandler = mqtt_bridge.NewHandler(deps.MqttBridgeService)
+
+	deps.ClickUpAuth = clickup.NewClickUpAuth(db, deps.UserService, cfg,
+		deps.Outbound.Client("clickup", outbound.DefaultPolicy), deps.CredentialsService)
 	deps.ClickUpClient = clickup.NewClient(deps.ClickUpAuth)
-	deps.ClickUpRepo = clickup.NewRepository(db)
-	deps.ClickUpService = clickup.NewServiceImpl(deps.ClickUpRepo, deps.ClickUpClient)
+	clickUpRepo := clickup.NewRepository(db)
+	deps.ClickUpRepo = clickUpRepo
+	deps.ClickUpService = clickup.NewServiceImpl(deps.ClickUpRepo, deps.ClickUpClient, cfg.ClickUp, deps.EventBus)
+	deps.ClickUpService.SubscribeToBudgetPlanChanges(deps.EventBus)
 	deps.ClickUpHandler = clickup.NewHandler(deps.ClickUpService, deps.ClickUpClient)
+	deps.ClickUpTimeTrackingService, err = clickup.NewTimeTrackingService(clickUpRepo, clickUpRepo, deps.ClickUpClient,
+		deps.UserService, deps.EventBus)
+	if err != nil {
+		return nil, err
+	}
+	deps.ClickUpTimeTrackingHandler = clickup.NewTimeTrackingHandler(deps.ClickUpTimeTrackingService)
+
+	// Toggl allows about one request per second
+	togglPolicy := outbound.DefaultPolicy
+	togglPolicy.RequestsPerSecond = 1
+	togglPolicy.Burst = 1
+	togglClient := toggl.NewClient(deps.Outbound.Client("toggl", togglPolicy))
+	deps.TogglService = toggl.NewService(toggl.NewRepository(db), togglClient, deps.CalendarProvider, deps.UserService,
+		deps.BudgetPlanService, deps.CredentialsService, deps.EventBus)
+	deps.TogglHandler = toggl.NewHandler(deps.TogglService)
+
+	deps.BudgetPlanValidationService = budget_plan_validation.NewService(deps.BudgetPlanService, deps.ClickUpService)
+	deps.BudgetPlanValidationHandler = budget_plan_validation.NewHandler(deps.BudgetPlanValidationService)
+
+	deps.BackupService, err = newBackupService(db, deps.Outbound, cfg.Backup)
+	if err != nil {
+		return nil, err
+	}
+	deps.BackupHandler = backup.NewHandler(deps.BackupService, deps.UserService)
+
+	deps.Scheduler = scheduler.NewScheduler(scheduler.NewRepository(db), deps.UserService, cfg.Scheduler)
+	if err := registerJobs(deps.Scheduler, deps, cfg); err != nil {
+		return nil, err
+	}
+	deps.JobsHandler = scheduler.NewHandler(deps.Scheduler)
+
+	return deps, nil
+}
+
+func newBackupService(db *pgxpool.Pool, registry *outbound.Registry, cfg config.Backup) (*backup.ServiceImpl, error) {
+	cipher, err := backup.NewCipher(cfg.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup encryption key: %w", err)
+	}
+	if cfg.Schedule != "" && !cipher.Enabled() {
+		return nil, fmt.Errorf("scheduled backups need an encryption key: %w", backup.ErrEncryptionRequired)
+	}
+	storage, err := newBackupStorage(registry, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return backup.NewService(backup.NewRepository(db), storage, cipher, cfg), nil
+}
 
-	return deps
+// newBackupStorage stores the backups in the S3 bucket when one is configured, in the backup directory otherwise.
+func newBackupStorage(registry *outbound.Registry, cfg config.Backup) (backup.Storage, error) {
+	if cfg.S3.Bucket == "" {
+		return backup.NewLocalStorage(cfg.Dir), nil
+	}
+	s3Policy := outbound.DefaultPolicy
+	// A part of a backup takes longer to upload than an API call, and the S3 client retries on its own
+	s3Policy.AttemptTimeout = 10 * time.Minute
+	s3Policy.MaxRetries = 0
+	return backup.NewS3Storage(registry.Client("s3", s3Policy), cfg.S3)
 }
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/internal/scheduler"
+)
+
+// jobRegistry registers jobs with the scheduler and collects the errors of invalid schedules and duplicate names.
+type jobRegistry struct {
+	scheduler *scheduler.Scheduler
+	errs      []error
+}
+
+func (r *jobRegistry) add(spec string, job scheduler.Job) {
+	schedule, err := scheduler.ParseSchedule(spec)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("job %s: %w", job.Name, err))
+		return
+	}
+	job.Schedule = schedule
+	if err := r.scheduler.Register(job); err != nil {
+		r.errs = append(r.errs, err)
+	}
+}
+
+// registerJobs registers the periodic background work with the scheduler, so only one instance runs it.
+func registerJobs(s *scheduler.Scheduler, deps *Dependencies, cfg config.Application) error {
+	jobs := &jobRegistry{scheduler: s}
+	jobs.add("@every 1m", scheduler.Job{
+		Name: "event_schedules",
+		Run:  deps.EventScheduleService.RunDueSchedules,
+	})
+	jobs.add("@every 1m", scheduler.Job{
+		Name: "budget_plan_activation",
+		Run:  deps.BudgetPlanService.ActivateDuePlans,
+	})
+	jobs.add("@hourly", scheduler.Job{
+		Name: "budget_rollover",
+		Run:  deps.BudgetRolloverService.RunRollover,
+	})
+	jobs.add("@every 15s", scheduler.Job{
+		Name: "webhook_deliveries",
+		Run: func(ctx context.Context, now time.Time) error {
+			_, err := deps.WebhookSubscriptionService.DeliverDue(ctx, now)
+			return err
+		},
+	})
+	jobs.add("@every 30s", scheduler.Job{
+		Name: "clickup_time_entries",
+		Run: func(ctx context.Context, now time.Time) error {
+			_, err := deps.ClickUpTimeTrackingService.PushDue(ctx, now)
+			return err
+		},
+	})
+	if cfg.CurrentEvent.AutoStopAfterHours > 0 {
+		jobs.add("@every 5m", scheduler.Job{
+			Name:    "stale_event_auto_stop",
+			PerUser: true,
+			Run: func(ctx context.Context, now time.Time) error {
+				maxDuration := time.Duration(cfg.CurrentEvent.AutoStopAfterHours) * time.Hour
+				_, err := deps.CurrentEventService.StopStaleEvent(ctx, now, maxDuration)
+				return err
+			},
+		})
+	}
+	jobs.add("@every 1m", scheduler.Job{
+		Name: "budget_alerts",
+		Run: func(ctx context.Context, now time.Time) error {
+			return deps.BudgetAlertService.CheckRunningEvents(ctx)
+		},
+	})
+	if deps.WeeklyPlanGenerator.Enabled() {
+		jobs.add("@daily", scheduler.Job{
+			Name:    "weekly_plan_generation",
+			PerUser: true,
+			Run:     deps.WeeklyPlanGenerator.GenerateUpcomingWeeks,
+		})
+	}
+	if cfg.Archive.EventsAfterDays > 0 {
+		jobs.add("0 3 * * *", scheduler.Job{
+			Name: "calendar_archive",
+			Run: func(ctx context.Context, now time.Time) error {
+				_, err := deps.CalendarArchiver.ArchiveOldEvents(ctx, now)
+				return err
+			},
+		})
+	}
+	if cfg.Archive.HistoryDays > 0 {
+		jobs.add("30 3 * * *", scheduler.Job{
+			Name: "calendar_history_retention",
+			Run: func(ctx context.Context, now time.Time) error {
+				_, err := deps.CalendarArchiver.DeleteOldHistory(ctx, now)
+				return err
+			},
+		})
+	}
+	jobs.add("0 4 * * *", scheduler.Job{
+		Name: "webhook_delivery_retention",
+		Run: func(ctx context.Context, now time.Time) error {
+			_, err := deps.WebhookSubscriptionService.DeleteOldDeliveries(ctx, now)
+			return err
+		},
+	})
+	if cfg.Audit.RetentionDays > 0 {
+		jobs.add("15 4 * * *", scheduler.Job{
+			Name: "audit_log_retention",
+			Run: func(ctx context.Context, now time.Time) error {
+				_, err := deps.AuditService.DeleteEntriesBefore(ctx, now.AddDate(0, 0, -cfg.Audit.RetentionDays))
+				return err
+			},
+		})
+	}
+	if cfg.Smtp.Host != "" {
+		jobs.add("@every 1m", scheduler.Job{
+			Name: "weekly_digests",
+			Run:  deps.WeeklyDigestService.SendDueDigests,
+		})
+	}
+	jobs.add("@every 1m", scheduler.Job{
+		Name: "chat_week_summaries",
+		Run:  deps.ChatNotificationService.SendWeekSummaries,
+	})
+	jobs.add("@every 15m", scheduler.Job{
+		Name:    "google_calendar_sync",
+		PerUser: true,
+		Run:     deps.GoogleSyncer.RenewAndSync,
+	})
+	jobs.add("@hourly", scheduler.Job{
+		Name: "clickup_stale_integrations",
+		Run:  deps.ClickUpService.CleanupStaleIntegrations,
+	})
+	jobs.add("@every 15m", scheduler.Job{
+		Name: "toggl_sync",
+		Run:  deps.TogglService.SyncAll,
+	})
+	if cfg.Mqtt.Broker != "" {
+		jobs.add("@every 1m", scheduler.Job{
+			Name: "mqtt_state",
+			Run: func(ctx context.Context, now time.Time) error {
+				return deps.MqttBridgeService.PublishStates(ctx)
+			},
+		})
+	}
+	if cfg.Backup.Schedule != "" {
+		jobs.add(cfg.Backup.Schedule, scheduler.Job{
+			Name: "backup",
+			Run:  deps.BackupService.RunScheduled,
+		})
+	}
+	return errors.Join(jobs.errs...)
+}
@@ -3,17 +3,46 @@ package app
 import (
 	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/pkg/audit"
 	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/user_switch"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // SetupMiddleware wires all HTTP middlewares for the application.
-func SetupMiddleware(r *mux.Router, deps *Dependencies, cfg config.Application) {
+func SetupMiddleware(ar *accessRouter, deps *Dependencies, cfg config.Application) error {
+	r := ar.router
 
-	// Propagate X-User-Id header into context for downstream services
+	// Trace every request but the frequent health probes, continuing the trace of the caller when the request
+	// carries one
+	r.Use(otelhttp.NewMiddleware("klokku",
+		otelhttp.WithSpanNameFormatter(routeSpanName),
+		otelhttp.WithFilter(func(req *http.Request) bool {
+			return req.URL.Path != "/healthz" && req.URL.Path != "/readyz"
+		})))
+
+	// Keep the address of the client for the audit log
+	proxies, err := audit.ParseTrustedProxies(cfg.Audit.TrustedProxies)
+	if err != nil {
+		return err
+	}
+	r.Use(clientIpMiddleware(proxies))
+
+	// Limit the clients by their address before authenticating, so guessing users is limited too
+	ipLimiter := rest.NewRateLimiter(cfg.RateLimit.IpRequestsPerSecond, cfg.RateLimit.IpBurst)
+	r.Use(ipLimiter.Middleware(func(req *http.Request) string {
+		return audit.ClientIp(req.Context())
+	}))
+
+	// Propagate X-User-Id header (or the user of a switch session) into context for downstream services
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 			log.Debug("Propagating user ID header")
@@ -21,7 +50,27 @@ func SetupMiddleware(r *mux.Router, deps *Dependencies, cfg config.Application)
 			userIdHeader := req.Header.Get("X-User-Id")
 			ctx := req.Context()
 
-			if userIdHeader != "" {
+			// A switch session from a shared device takes precedence over the user header
+			if sessionToken := req.Header.Get(user_switch.SessionHeader); sessionToken != "" {
+				session, u, err := deps.UserSwitchService.Authenticate(ctx, sessionToken)
+				if err != nil {
+					if errors.Is(err, user_switch.ErrSessionNotFound) || errors.Is(err, user.ErrUserNotFound) {
+						log.Debug("user switch session not found or expired")
+						http.Error(w, "session not found or expired", http.StatusUnauthorized)
+						return
+					}
+					if errors.Is(err, user.ErrUserDisabled) {
+						log.Debug("user of switch session is disabled")
+						http.Error(w, "user disabled", http.StatusForbidden)
+						return
+					}
+					log.Errorf("failed to authenticate user switch session: %v", err)
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				log.Debugf("user found by switch session: %s", u.Uid)
+				ctx = user_switch.WithSession(user.WithUser(ctx, u), session)
+			} else if userIdHeader != "" {
 				u, err := deps.UserService.GetUserByUid(ctx, userIdHeader)
 				if err != nil {
 					if errors.Is(err, user.ErrUserNotFound) {
@@ -33,13 +82,51 @@ func SetupMiddleware(r *mux.Router, deps *Dependencies, cfg config.Application)
 						http.Error(w, err.Error(), http.StatusBadRequest)
 						return
 					}
+				} else if u.Disabled {
+					log.Debugf("user disabled: %s", u.Uid)
+					http.Error(w, "user disabled", http.StatusForbidden)
+					return
 				} else {
 					log.Debugf("user found: %s", u.Uid)
 					ctx = user.WithUser(ctx, u)
 				}
 			}
+			if u, err := user.CurrentUser(ctx); err == nil {
+				trace.SpanFromContext(ctx).SetAttributes(attribute.Int("enduser.id", u.Id))
+			}
 			log.Debug("Propagated user ID header")
 			next.ServeHTTP(w, req.WithContext(ctx))
 		})
 	})
+
+	// Reject requests not meeting the auth requirement of the route
+	r.Use(ar.accessMiddleware(cfg.Admin))
+
+	// Limit every user on their own, wherever their requests come from. Only the requests of users the access
+	// check accepted count, requests of others are limited by their address alone.
+	userLimiter := rest.NewRateLimiter(cfg.RateLimit.UserRequestsPerSecond, cfg.RateLimit.UserBurst)
+	r.Use(userLimiter.Middleware(func(req *http.Request) string {
+		if !ar.routeAccess(mux.CurrentRoute(req)).authenticatesUser() {
+			return ""
+		}
+		if u, err := user.CurrentUser(req.Context()); err == nil {
+			return strconv.Itoa(u.Id)
+		}
+		return ""
+	}))
+
+	// Record security-relevant actions of the authorized requests
+	r.Use(ar.auditMiddleware(deps.AuditService))
+	return nil
+}
+
+// routeSpanName names the span of a request after its route, e.g. "GET /api/event/current", so requests with
+// different ids are grouped together.
+func routeSpanName(_ string, req *http.Request) string {
+	if route := mux.CurrentRoute(req); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return req.Method + " " + template
+		}
+	}
+	return req.Method
 }
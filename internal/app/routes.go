@@ -1,92 +1,308 @@
 package app
 
 import (
-	"github.com/gorilla/mux"
 	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/pkg/audit"
 	httpSwagger "github.com/swaggo/http-swagger"
-
-	_ "github.com/klokku/klokku/docs" // Import generated docs
 )
 
-// RegisterRoutes registers all API endpoints.
-func RegisterRoutes(r *mux.Router, deps *Dependencies, cfg config.Application) {
+// RegisterRoutes registers all API endpoints together with their auth requirements.
+func RegisterRoutes(ar *accessRouter, deps *Dependencies, cfg config.Application) {
 
 	// Swagger UI
-	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
+	ar.router.PathPrefix("/swagger/").Handler(httpSwagger.Handler(httpSwagger.InstanceName(accessDocInstance)))
+
+	// Health probes
+	ar.handle(anonymous, "/healthz", deps.HealthHandler.Liveness).Methods("GET")
+	ar.handle(anonymous, "/readyz", deps.HealthHandler.Readiness).Methods("GET")
 
 	// Budget Plan
-	r.HandleFunc("/api/budgetplan", deps.BudgetPlanHandler.ListPlans).Methods("GET")
-	r.HandleFunc("/api/budgetplan", deps.BudgetPlanHandler.CreatePlan).Methods("POST")
-	r.HandleFunc("/api/budgetplan/{planId}", deps.BudgetPlanHandler.GetPlan).Methods("GET")
-	r.HandleFunc("/api/budgetplan/{planId}", deps.BudgetPlanHandler.UpdatePlan).Methods("PUT")
-	r.HandleFunc("/api/budgetplan/{planId}", deps.BudgetPlanHandler.DeletePlan).Methods("DELETE")
+	ar.handle(authUser, "/api/budgetplan", deps.BudgetPlanHandler.ListPlans).Methods("GET")
+	ar.handle(authUser, "/api/budgetplan", deps.BudgetPlanHandler.CreatePlan).Methods("POST")
+	ar.handle(authUser, "/api/budgetplan/activation", deps.BudgetPlanHandler.ListPlanActivations).Methods("GET")
+	ar.handle(authUser, "/api/budgetplan/activation/{activationId}", deps.BudgetPlanHandler.CancelPlanActivation).Methods("DELETE")
+	ar.handle(authUser, "/api/budgetplan/{planId}", deps.BudgetPlanHandler.GetPlan).Methods("GET")
+	ar.handle(authUser, "/api/budgetplan/{planId}", deps.BudgetPlanHandler.UpdatePlan).Methods("PUT")
+	ar.handleAudited(audit.ActionDeleted, authUser, "/api/budgetplan/{planId}", deps.BudgetPlanHandler.DeletePlan).Methods("DELETE")
+	ar.handle(authUser, "/api/budgetplan/{planId}/activation", deps.BudgetPlanHandler.SchedulePlanActivation).Methods("POST")
+	ar.handle(authUser, "/api/budgetplan/{planId}/changelog", deps.BudgetPlanHandler.GetChangelog).Methods("GET")
+
+	// Budget Category
+	ar.handle(authUser, "/api/budgetplan/{planId}/category", deps.BudgetPlanHandler.ListCategories).Methods("GET")
+	ar.handle(authUser, "/api/budgetplan/{planId}/category", deps.BudgetPlanHandler.CreateCategory).Methods("POST")
+	ar.handle(authUser, "/api/budgetplan/{planId}/category/{categoryId}", deps.BudgetPlanHandler.UpdateCategory).Methods("PUT")
+	ar.handle(authUser, "/api/budgetplan/{planId}/category/{categoryId}", deps.BudgetPlanHandler.DeleteCategory).Methods("DELETE")
 
 	// Budget Item
-	r.HandleFunc("/api/budgetplan/{planId}/item", deps.BudgetPlanHandler.RegisterItem).Methods("POST")
-	r.HandleFunc("/api/budgetplan/{planId}/item/{itemId}", deps.BudgetPlanHandler.UpdateItem).Methods("PUT")
-	r.HandleFunc("/api/budgetplan/{planId}/item/{itemId}/position", deps.BudgetPlanHandler.SetItemPosition).Methods("PUT")
-	r.HandleFunc("/api/budgetplan/{planId}/item/{itemId}", deps.BudgetPlanHandler.DeleteItem).Methods("DELETE")
+	ar.handle(authUser, "/api/budgetplan/{planId}/item", deps.BudgetPlanHandler.RegisterItem).Methods("POST")
+	ar.handleAudited(audit.ActionDataExported, authUser, "/api/budgetplan/{planId}/item/export", deps.BudgetPlanTransferHandler.ExportItems).Methods("GET")
+	ar.handle(authUser, "/api/budgetplan/{planId}/item/import", deps.BudgetPlanTransferHandler.ImportItems).Methods("POST")
+	ar.handle(authUser, "/api/budgetplan/{planId}/item/style-suggestion", deps.BudgetPlanHandler.SuggestItemStyle).Methods("GET")
+	ar.handle(authUser, "/api/budgetplan/{planId}/item/{itemId}", deps.BudgetPlanHandler.UpdateItem).Methods("PUT")
+	ar.handle(authUser, "/api/budgetplan/{planId}/item/{itemId}/position", deps.BudgetPlanHandler.SetItemPosition).Methods("PUT")
+	ar.handle(authUser, "/api/budgetplan/{planId}/item/{itemId}", deps.BudgetPlanHandler.DeleteItem).Methods("DELETE")
+	ar.handle(authUser, "/api/budgetplan/{planId}/item/{itemId}/backfill", deps.BudgetItemBackfillHandler.GetProposals).Methods("GET")
+	ar.handle(authUser, "/api/budgetplan/{planId}/item/{itemId}/backfill", deps.BudgetItemBackfillHandler.ApplyReassignments).Methods("POST")
 
 	// Budget Plan Report
-	r.HandleFunc("/api/budgetplan/{planId}/report", deps.BudgetPlanReportHandler.GetReport).Methods("GET")
-	r.HandleFunc("/api/budgetplan/{planId}/report/item/{itemId}", deps.BudgetPlanReportHandler.GetItemReport).Methods("GET")
+	ar.handle(authUser, "/api/budgetplan/{planId}/report", deps.BudgetPlanReportHandler.GetReport).Methods("GET")
+	ar.handle(authUser, "/api/budgetplan/{planId}/report/item/{itemId}", deps.BudgetPlanReportHandler.GetItemReport).Methods("GET")
+	ar.handle(authUser, "/api/budgetplan/{planId}/validation", deps.BudgetPlanValidationHandler.ValidatePlan).Methods("GET")
+
+	// Budget alerts
+	ar.handle(authUser, "/api/budget-alerts", deps.BudgetAlertHandler.ListAlerts).Methods("GET")
+	ar.handle(authUser, "/api/budget-alerts/settings", deps.BudgetAlertHandler.GetSettings).Methods("GET")
+	ar.handleAudited(audit.ActionSettingsChanged, authUser, "/api/budget-alerts/settings", deps.BudgetAlertHandler.UpdateSettings).Methods("PUT")
 
 	// Webhook management (authenticated)
-	r.HandleFunc("/api/webhook", deps.WebhookHandler.CreateWebhook).Methods("POST")
-	r.HandleFunc("/api/webhook", deps.WebhookHandler.ListWebhooks).Methods("GET")
-	r.HandleFunc("/api/webhook/{id}", deps.WebhookHandler.DeleteWebhook).Methods("DELETE")
+	ar.handleAudited(audit.ActionIntegrationEnabled, authUser, "/api/webhook", deps.WebhookHandler.CreateWebhook).Methods("POST")
+	ar.handle(authUser, "/api/webhook", deps.WebhookHandler.ListWebhooks).Methods("GET")
+	ar.handleAudited(audit.ActionIntegrationDisabled, authUser, "/api/webhook/{id}", deps.WebhookHandler.DeleteWebhook).Methods("DELETE")
+	ar.handleAudited(audit.ActionSettingsChanged, authUser, "/api/webhook/{id}/rotate", deps.WebhookHandler.RotateWebhookToken).Methods("POST")
 
 	// Webhook execution (no authentication required)
-	r.HandleFunc("/api/webhook/{token}", deps.WebhookHandler.HandleWebhook).Methods("POST")
+	ar.handle(token("webhook"), "/api/webhook/{token}", deps.WebhookHandler.HandleWebhook).Methods("POST")
+
+	// Outgoing webhook subscriptions (authenticated)
+	ar.handle(authUser, "/api/webhook-subscriptions", deps.WebhookSubscriptionHandler.ListSubscriptions).Methods("GET")
+	ar.handleAudited(audit.ActionIntegrationEnabled, authUser, "/api/webhook-subscriptions", deps.WebhookSubscriptionHandler.CreateSubscription).Methods("POST")
+	ar.handleAudited(audit.ActionSettingsChanged, authUser, "/api/webhook-subscriptions/{id}", deps.WebhookSubscriptionHandler.UpdateSubscription).Methods("PUT")
+	ar.handleAudited(audit.ActionIntegrationDisabled, authUser, "/api/webhook-subscriptions/{id}", deps.WebhookSubscriptionHandler.DeleteSubscription).Methods("DELETE")
+	ar.handle(authUser, "/api/webhook-subscriptions/{id}/deliveries", deps.WebhookSubscriptionHandler.ListDeliveries).Methods("GET")
+
+	// Slack / Discord notifications (authenticated)
+	ar.handle(authUser, "/api/chat-notifications", deps.ChatNotificationHandler.ListIntegrations).Methods("GET")
+	ar.handleAudited(audit.ActionIntegrationEnabled, authUser, "/api/chat-notifications", deps.ChatNotificationHandler.CreateIntegration).Methods("POST")
+	ar.handleAudited(audit.ActionSettingsChanged, authUser, "/api/chat-notifications/{id}", deps.ChatNotificationHandler.UpdateIntegration).Methods("PUT")
+	ar.handleAudited(audit.ActionIntegrationDisabled, authUser, "/api/chat-notifications/{id}", deps.ChatNotificationHandler.DeleteIntegration).Methods("DELETE")
+	ar.handle(authUser, "/api/chat-notifications/{id}/test", deps.ChatNotificationHandler.SendTest).Methods("POST")
+
+	// Home Assistant / MQTT bridge (authenticated)
+	ar.handle(authUser, "/api/mqtt/settings", deps.MqttBridgeHandler.GetSettings).Methods("GET")
+	ar.handleAudited(audit.ActionSettingsChanged, authUser, "/api/mqtt/settings", deps.MqttBridgeHandler.UpdateSettings).Methods("PUT")
+	ar.handleAudited(audit.ActionSettingsChanged, authUser, "/api/mqtt/settings/secret", deps.MqttBridgeHandler.RotateSecret).Methods("POST")
+
+	// Export stream management (authenticated)
+	ar.handleAudited(audit.ActionIntegrationEnabled, authUser, "/api/export/stream", deps.ExportStreamHandler.EnableStream).Methods("POST")
+	ar.handle(authUser, "/api/export/stream", deps.ExportStreamHandler.GetStream).Methods("GET")
+	ar.handleAudited(audit.ActionIntegrationDisabled, authUser, "/api/export/stream", deps.ExportStreamHandler.DisableStream).Methods("DELETE")
+	ar.handleAudited(audit.ActionSettingsChanged, authUser, "/api/export/stream/filter", deps.ExportStreamHandler.SetFilter).Methods("PUT")
+	ar.handleAudited(audit.ActionDataExported, authUser, "/api/export/time", deps.TimeExportHandler.ExportTime).Queries("from", "{from}", "to", "{to}").Methods("GET")
+	ar.handleAudited(audit.ActionDataExported, authUser, "/api/export/statement", deps.MonthlyStatementHandler.ExportStatement).Queries("month", "{month}").Methods("GET")
+	ar.handle(authUser, "/api/import/time/preview", deps.TimeImportHandler.PreviewImport).Methods("POST")
+	ar.handle(authUser, "/api/import/time", deps.TimeImportHandler.Import).Methods("POST")
+
+	// Share links of weeks (read-only, no authentication required to view)
+	ar.handle(authUser, "/api/share-links", deps.ShareLinkHandler.ListLinks).Methods("GET")
+	ar.handleAudited(audit.ActionDataExported, authUser, "/api/share-links", deps.ShareLinkHandler.CreateLink).Methods("POST")
+	ar.handleAudited(audit.ActionDeleted, authUser, "/api/share-links/{id}", deps.ShareLinkHandler.RevokeLink).Methods("DELETE")
+	ar.handle(token("share_link"), "/api/share/{token}", deps.ShareLinkHandler.GetSharedWeek).Methods("GET")
+
+	// Quick action devices, e.g. hardware buttons (token authenticated)
+	ar.handle(authUser, "/api/quick-devices", deps.QuickActionHandler.ListDevices).Methods("GET")
+	ar.handleAudited(audit.ActionIntegrationEnabled, authUser, "/api/quick-devices", deps.QuickActionHandler.CreateDevice).Methods("POST")
+	ar.handleAudited(audit.ActionIntegrationDisabled, authUser, "/api/quick-devices/{id}", deps.QuickActionHandler.DeleteDevice).Methods("DELETE")
+	ar.handle(token("quick_action"), "/api/quick/{token}/next", deps.QuickActionHandler.Next).Methods("POST")
+	ar.handle(token("quick_action"), "/api/quick/{token}/stop", deps.QuickActionHandler.Stop).Methods("POST")
+	ar.handle(token("quick_action"), "/api/quick/{token}/status", deps.QuickActionHandler.Status).Methods("GET")
+
+	// Export stream reading (token authenticated)
+	ar.handle(token("export_stream"), "/api/export/stream/{token}/changes", deps.ExportStreamHandler.ReadChanges).Methods("GET")
 
 	// Weekly Plan item
-	r.HandleFunc("/api/weeklyplan", deps.WeeklyPlanHandler.GetPlan).Queries("date", "{date}").Methods("GET")
-	r.HandleFunc("/api/weeklyplan", deps.WeeklyPlanHandler.ResetWeek).Queries("date", "{date}").Methods("DELETE")
-	r.HandleFunc("/api/weeklyplan/item", deps.WeeklyPlanHandler.UpdateItem).Queries("date", "{date}").Methods("PUT")
-	r.HandleFunc("/api/weeklyplan/item/{itemId}", deps.WeeklyPlanHandler.ResetItem).Methods("DELETE")
-	r.HandleFunc("/api/weeklyplan/off-week", deps.WeeklyPlanHandler.SetOffWeek).Queries("date", "{date}").Methods("PUT")
+	ar.handle(authUser, "/api/weeklyplan", deps.WeeklyPlanHandler.GetPlan).Queries("date", "{date}").Methods("GET")
+	ar.handle(authUser, "/api/weeklyplan/range", deps.WeeklyPlanHandler.GetPlanRange).Queries("from", "{from}", "to", "{to}").Methods("GET")
+	ar.handle(authUser, "/api/weeklyplan", deps.WeeklyPlanHandler.ResetWeek).Queries("date", "{date}").Methods("DELETE")
+	ar.handle(authUser, "/api/weeklyplan/item", deps.WeeklyPlanHandler.UpdateItem).Queries("date", "{date}").Methods("PUT")
+	ar.handle(authUser, "/api/weeklyplan/item/position", deps.WeeklyPlanHandler.MoveItem).Queries("date", "{date}").Methods("PUT")
+	ar.handle(authUser, "/api/weeklyplan/item/daily-durations", deps.WeeklyPlanHandler.SetItemDailyDurations).Queries("date", "{date}").Methods("PUT")
+	ar.handle(authUser, "/api/weeklyplan/item/{itemId}", deps.WeeklyPlanHandler.ResetItem).Methods("DELETE")
+	ar.handle(authUser, "/api/weeklyplan/ad-hoc-item", deps.WeeklyPlanHandler.AddAdHocItem).Queries("date", "{date}").Methods("POST")
+	ar.handle(authUser, "/api/weeklyplan/ad-hoc-item/{itemId}", deps.WeeklyPlanHandler.DeleteAdHocItem).Methods("DELETE")
+	ar.handle(authUser, "/api/weeklyplan/off-week", deps.WeeklyPlanHandler.SetOffWeek).Queries("date", "{date}").Methods("PUT")
+	ar.handle(authUser, "/api/weeklyplan/copy", deps.WeeklyPlanHandler.CopyWeek).Methods("POST")
+	ar.handle(authUser, "/api/weeklyplan/lock", deps.WeeklyPlanHandler.LockWeek).Queries("date", "{date}").Methods("PUT")
+	ar.handle(authUser, "/api/weeklyplan/lock", deps.WeeklyPlanHandler.UnlockWeek).Queries("date", "{date}").Methods("DELETE")
+	ar.handle(authUser, "/api/weeklyplan/{weekDate}/preview", deps.WeeklyPlanHandler.PreviewWeek).Methods("GET")
+
+	// Absences, e.g. vacations
+	ar.handle(authUser, "/api/absences", deps.AbsenceHandler.ListAbsences).Methods("GET")
+	ar.handleAudited(audit.ActionDataChanged, authUser, "/api/absences", deps.AbsenceHandler.CreateAbsence).Methods("POST")
+	ar.handleAudited(audit.ActionDataChanged, authUser, "/api/absences/{id}", deps.AbsenceHandler.UpdateAbsence).Methods("PUT")
+	ar.handleAudited(audit.ActionDeleted, authUser, "/api/absences/{id}", deps.AbsenceHandler.DeleteAbsence).Methods("DELETE")
 
 	// Events
-	r.HandleFunc("/api/event", deps.CurrentEventHandler.StartEvent).Methods("POST")
-	r.HandleFunc("/api/event/current/start", deps.CurrentEventHandler.ModifyCurrentEventStartTime).Methods("PATCH")
-	r.HandleFunc("/api/event/current", deps.CurrentEventHandler.GetCurrentEvent).Methods("GET")
+	ar.handle(authUser, "/api/event", deps.CurrentEventHandler.StartEvent).Methods("POST")
+	ar.handle(authUser, "/api/event/current/start", deps.CurrentEventHandler.ModifyCurrentEventStartTime).Methods("PATCH")
+	ar.handle(authUser, "/api/event/current", deps.CurrentEventHandler.GetCurrentEvent).Methods("GET")
+	ar.handle(authUser, "/api/event/current", deps.CurrentEventHandler.UpdateCurrentEvent).Methods("PATCH")
+	ar.handle(authUser, "/api/event/current", deps.CurrentEventHandler.StopEvent).Methods("DELETE")
+	ar.handle(authUser, "/api/event/current/idle", deps.CurrentEventHandler.ReportIdle).Methods("POST")
+	ar.handle(authUser, "/api/event/current/switch-back", deps.CurrentEventHandler.SwitchBack).Methods("POST")
+	ar.handle(authUser, "/api/event/current/start-default", deps.CurrentEventHandler.StartDefaultEvent).Methods("POST")
+	ar.handle(authUser, "/api/event/recent", deps.CurrentEventHandler.GetRecentItems).Methods("GET")
+	ar.handle(authUser, "/api/event/classify", deps.EventClassifierHandler.Classify).Methods("POST")
+
+	// Event schedules
+	ar.handle(authUser, "/api/event/schedule", deps.EventScheduleHandler.ListSchedules).Methods("GET")
+	ar.handle(authUser, "/api/event/schedule", deps.EventScheduleHandler.CreateSchedule).Methods("POST")
+	ar.handle(authUser, "/api/event/schedule/{scheduleId}", deps.EventScheduleHandler.UpdateSchedule).Methods("PUT")
+	ar.handle(authUser, "/api/event/schedule/{scheduleId}", deps.EventScheduleHandler.DeleteSchedule).Methods("DELETE")
 
 	// Stats
-	r.HandleFunc("/api/stats/weekly", deps.StatsHandler.GetWeeklyStats).Queries("date", "{date}").Methods("GET")
-	r.HandleFunc("/api/stats/item-history", deps.StatsHandler.GetPlanItemByWeekHistoryStats).
+	ar.handle(authUser, "/api/stats/weekly", deps.StatsHandler.GetWeeklyStats).Queries("date", "{date}").Methods("GET")
+	ar.handle(authUser, "/api/stats/day", deps.StatsHandler.GetDailyStats).Queries("date", "{date}").Methods("GET")
+	ar.handle(authUser, "/api/stats/item-history", deps.StatsHandler.GetPlanItemByWeekHistoryStats).
 		Methods("GET").
 		Queries("from", "{from}", "to", "{to}", "budgetItemId", "{budgetItemId}")
+	ar.handle(authUser, "/api/stats/capacity", deps.StatsHandler.GetWeeklyCapacity).Queries("weekDate", "{weekDate}").Methods("GET")
+	ar.handle(authUser, "/api/stats/trends", deps.StatsHandler.GetTrend).Queries("budgetItemId", "{budgetItemId}").Methods("GET")
+	ar.handle(authUser, "/api/stats/focus", deps.StatsHandler.GetWeeklyFocus).Queries("date", "{date}").Methods("GET")
+	ar.handle(authUser, "/api/integrations/clickup/time-tracking", deps.ClickUpTimeTrackingHandler.GetTimeTracking).Methods("GET")
+	ar.handleAudited(audit.ActionSettingsChanged, authUser, "/api/integrations/clickup/time-tracking", deps.ClickUpTimeTrackingHandler.StoreTimeTracking).Methods("PUT")
+	ar.handle(authUser, "/api/stats/goals", deps.GoalHandler.GetProgress).Methods("GET")
+
+	// Week reviews
+	ar.handleAudited(audit.ActionDataChanged, authUser, "/api/week-reviews", deps.WeekReviewHandler.StartReview).Methods("POST")
+	ar.handle(authUser, "/api/week-reviews/{week}", deps.WeekReviewHandler.GetReview).Methods("GET")
+	ar.handleAudited(audit.ActionDataChanged, authUser, "/api/week-reviews/{week}/items/{budgetItemId}", deps.WeekReviewHandler.SetItemNote).Methods("PUT")
+	ar.handleAudited(audit.ActionDataChanged, authUser, "/api/week-reviews/{week}/complete", deps.WeekReviewHandler.CompleteReview).Methods("POST")
+
+	// Goals
+	ar.handle(authUser, "/api/goals", deps.GoalHandler.ListGoals).Methods("GET")
+	ar.handle(authUser, "/api/goals", deps.GoalHandler.CreateGoal).Methods("POST")
+	ar.handle(authUser, "/api/goals/{id}", deps.GoalHandler.UpdateGoal).Methods("PUT")
+	ar.handle(authUser, "/api/goals/{id}", deps.GoalHandler.DeleteGoal).Methods("DELETE")
 
 	// User management
-	r.HandleFunc("/api/user/current", deps.UserHandler.CurrentUser).Methods("GET")
-	r.HandleFunc("/api/user/current", deps.UserHandler.UpdateUser).Methods("PUT")
-	r.HandleFunc("/api/user/current/photo", deps.UserHandler.UploadPhoto).Methods("PUT")
-	r.HandleFunc("/api/user/current/photo", deps.UserHandler.GetPhoto).Methods("GET")
-	r.HandleFunc("/api/user/current/photo", deps.UserHandler.DeletePhoto).Methods("DELETE")
-	r.HandleFunc("/api/user", deps.UserHandler.CreateUser).Methods("POST")
-	r.HandleFunc("/api/user/name-availability", deps.UserHandler.IsUsernameAvailable).Methods("GET").Queries("username", "{username}")
-	r.HandleFunc("/api/user", deps.UserHandler.GetAvailableUsers).Methods("GET")
-	r.HandleFunc("/api/user/{userUid}", deps.UserHandler.DeleteUser).Methods("DELETE")
-	r.HandleFunc("/api/user/{userUid}/photo", deps.UserHandler.GetPhoto).Methods("GET")
+	ar.handle(authUser, "/api/user/current", deps.UserHandler.CurrentUser).Methods("GET")
+	ar.handleAudited(audit.ActionSettingsChanged, authUser, "/api/user/current", deps.UserHandler.UpdateUser).Methods("PUT")
+	ar.handle(authUser, "/api/user/current/photo", deps.UserHandler.UploadPhoto).Methods("PUT")
+	ar.handle(authUser, "/api/user/current/photo", deps.UserHandler.GetPhoto).Methods("GET")
+	ar.handle(authUser, "/api/user/current/photo", deps.UserHandler.DeletePhoto).Methods("DELETE")
+	ar.handle(authUser, "/api/user/current/onboarding", deps.OnboardingHandler.GetOnboarding).Methods("GET")
+	ar.handle(authUser, "/api/user/current/onboarding", deps.OnboardingHandler.UpdateOnboarding).Methods("PATCH")
+	ar.handleAudited(audit.ActionSettingsChanged, authUser, "/api/user/current/pin", deps.UserSwitchHandler.SetPin).Methods("PUT")
+	ar.handle(authUser, "/api/user/current/pin", deps.UserSwitchHandler.GetPin).Methods("GET")
+	ar.handleAudited(audit.ActionSettingsChanged, authUser, "/api/user/current/pin", deps.UserSwitchHandler.DeletePin).Methods("DELETE")
+	ar.handle(anonymous, "/api/user/switch", deps.UserSwitchHandler.Switch).Methods("POST")
+	ar.handle(authUser, "/api/user/switch", deps.UserSwitchHandler.EndSession).Methods("DELETE")
+	ar.handle(authUser, "/api/user/current/identities", deps.OidcHandler.ListIdentities).Methods("GET")
+	ar.handleAudited(audit.ActionDeleted, authUser, "/api/user/current/identities/{id}", deps.OidcHandler.DeleteIdentity).Methods("DELETE")
+	ar.handle(anonymous, "/api/user", deps.UserHandler.CreateUser).Methods("POST")
+	ar.handle(anonymous, "/api/user/name-availability", deps.UserHandler.IsUsernameAvailable).Methods("GET").Queries("username", "{username}")
+	ar.handle(anonymous, "/api/user", deps.UserHandler.GetAvailableUsers).Methods("GET")
+	ar.handleAudited(audit.ActionDeleted, admin, "/api/user/{userUid}", deps.UserHandler.DeleteUser).Methods("DELETE")
+	ar.handleAudited(audit.ActionUserStatusChanged, admin, "/api/user/{userUid}/status", deps.UserHandler.SetUserStatus).Methods("PUT")
+	ar.handle(anonymous, "/api/user/{userUid}/photo", deps.UserHandler.GetPhoto).Methods("GET")
+
+	// Single sign-on
+	ar.handle(anonymous, "/api/auth/oidc/login", deps.OidcHandler.Login).Methods("GET")
+	ar.handle(anonymous, "/api/auth/oidc/callback", deps.OidcHandler.Callback).Methods("GET")
+	ar.handle(authUser, "/api/auth/oidc/link", deps.OidcHandler.Link).Methods("GET")
+
+	// Workspaces
+	ar.handle(authUser, "/api/workspaces/invitations", deps.WorkspaceHandler.ListInvitations).Methods("GET")
+	ar.handleAudited(audit.ActionSettingsChanged, authUser, "/api/workspaces/invitations/{invitationId}/accept", deps.WorkspaceHandler.AcceptInvitation).Methods("POST")
+	ar.handleAudited(audit.ActionDeleted, authUser, "/api/workspaces/invitations/{invitationId}", deps.WorkspaceHandler.DeclineInvitation).Methods("DELETE")
+	ar.handle(authUser, "/api/workspaces", deps.WorkspaceHandler.ListWorkspaces).Methods("GET")
+	ar.handle(authUser, "/api/workspaces", deps.WorkspaceHandler.CreateWorkspace).Methods("POST")
+	ar.handle(authUser, "/api/workspaces/{workspaceId}", deps.WorkspaceHandler.GetWorkspace).Methods("GET")
+	ar.handle(authUser, "/api/workspaces/{workspaceId}", deps.WorkspaceHandler.RenameWorkspace).Methods("PUT")
+	ar.handleAudited(audit.ActionDeleted, authUser, "/api/workspaces/{workspaceId}", deps.WorkspaceHandler.DeleteWorkspace).Methods("DELETE")
+	ar.handle(authUser, "/api/workspaces/{workspaceId}/members", deps.WorkspaceHandler.ListMembers).Methods("GET")
+	ar.handleAudited(audit.ActionSettingsChanged, authUser, "/api/workspaces/{workspaceId}/invitations", deps.WorkspaceHandler.InviteMember).Methods("POST")
+	ar.handleAudited(audit.ActionSettingsChanged, authUser, "/api/workspaces/{workspaceId}/members/{userUid}", deps.WorkspaceHandler.UpdateMemberRole).Methods("PUT")
+	ar.handleAudited(audit.ActionDeleted, authUser, "/api/workspaces/{workspaceId}/members/{userUid}", deps.WorkspaceHandler.RemoveMember).Methods("DELETE")
+	ar.handle(authUser, "/api/workspaces/{workspaceId}/budgetplans", deps.WorkspaceHandler.ListSharedPlans).Methods("GET")
+	ar.handle(authUser, "/api/workspaces/{workspaceId}/budgetplans", deps.WorkspaceHandler.SharePlan).Methods("POST")
+	ar.handle(authUser, "/api/workspaces/{workspaceId}/budgetplans/{planId}", deps.WorkspaceHandler.GetSharedPlan).Methods("GET")
+	ar.handle(authUser, "/api/workspaces/{workspaceId}/budgetplans/{planId}", deps.WorkspaceHandler.UnsharePlan).Methods("DELETE")
+	ar.handle(authUser, "/api/workspaces/{workspaceId}/budgetplans/{planId}/item", deps.WorkspaceHandler.CreateSharedItem).Methods("POST")
+	ar.handle(authUser, "/api/workspaces/{workspaceId}/budgetplans/{planId}/item/{itemId}", deps.WorkspaceHandler.UpdateSharedItem).Methods("PUT")
+	ar.handle(authUser, "/api/workspaces/{workspaceId}/budgetplans/{planId}/item/{itemId}", deps.WorkspaceHandler.DeleteSharedItem).Methods("DELETE")
+	ar.handle(authUser, "/api/workspaces/{workspaceId}/calendar", deps.WorkspaceHandler.GetCalendar).Methods("GET")
+
+	// Announcements
+	ar.handle(authUser, "/api/announcements", deps.AnnouncementHandler.GetAnnouncements).Methods("GET")
+	ar.handle(authUser, "/api/announcements/read", deps.AnnouncementHandler.MarkAllRead).Methods("POST")
+	ar.handle(authUser, "/api/announcements/{id}/read", deps.AnnouncementHandler.MarkRead).Methods("POST")
+	ar.handle(admin, "/api/announcements", deps.AnnouncementHandler.CreateAnnouncement).Methods("POST")
+	ar.handleAudited(audit.ActionDeleted, admin, "/api/announcements/{id}", deps.AnnouncementHandler.DeleteAnnouncement).Methods("DELETE")
+
+	// Admin
+	ar.handle(admin, "/api/admin/db/queries", deps.DbActivityHandler.ListQueries).Methods("GET")
+	ar.handleAudited(audit.ActionDeleted, admin, "/api/admin/db/queries/{pid}", deps.DbActivityHandler.CancelQuery).Methods("DELETE")
+	ar.handle(admin, "/api/admin/integrations/outbound", deps.OutboundHandler.ListProviderStats).Methods("GET")
+	ar.handle(admin, "/api/admin/audit", deps.AuditHandler.ListEntries).Methods("GET")
+	ar.handle(admin, "/api/admin/events/dead-letters", deps.EventsHandler.ListDeadLetters).Methods("GET")
+	ar.handleAudited(audit.ActionEventsReplayed, admin, "/api/admin/events/dead-letters/replay", deps.EventsHandler.ReplayDeadLetters).Methods("POST")
+	ar.handle(admin, "/api/admin/events/dead-letters/{id}", deps.EventsHandler.GetDeadLetter).Methods("GET")
+	ar.handleAudited(audit.ActionEventsReplayed, admin, "/api/admin/events/dead-letters/{id}/replay", deps.EventsHandler.ReplayDeadLetter).Methods("POST")
+	ar.handle(admin, "/api/admin/migrations", deps.MigrationsHandler.GetStatus).Methods("GET")
+	ar.handle(admin, "/api/admin/jobs", deps.JobsHandler.ListJobs).Methods("GET")
+	ar.handle(admin, "/api/admin/jobs/{name}/run", deps.JobsHandler.RunJob).Methods("POST")
+	ar.handle(admin, "/api/admin/backups", deps.BackupHandler.ListBackups).Methods("GET")
+	ar.handleAudited(audit.ActionDataExported, admin, "/api/admin/backups", deps.BackupHandler.CreateBackup).Methods("POST")
+	ar.handleAudited(audit.ActionDataExported, admin, "/api/admin/backups/{name}", deps.BackupHandler.DownloadBackup).Methods("GET")
+
+	// Sandbox
+	ar.handle(authUser, "/api/sandbox/week", deps.SandboxHandler.GenerateWeek).Methods("POST")
+	ar.handleAudited(audit.ActionDeleted, authUser, "/api/sandbox", deps.SandboxHandler.Cleanup).Methods("DELETE")
 
 	// Klokku Calendar
-	r.HandleFunc("/api/calendar/event", deps.KlokkuCalendarHandler.GetEvents).Queries("from", "{from}", "to", "{to}").Methods("GET")
-	r.HandleFunc("/api/calendar/event", deps.KlokkuCalendarHandler.CreateEvent).Methods("POST")
-	r.HandleFunc("/api/calendar/event/recent", deps.KlokkuCalendarHandler.GetLastEvents).Methods("GET").Queries("last", "{last}")
-	r.HandleFunc("/api/calendar/event/{eventUid}", deps.KlokkuCalendarHandler.UpdateEvent).Methods("PUT")
-	r.HandleFunc("/api/calendar/event/{eventUid}", deps.KlokkuCalendarHandler.DeleteEvent).Methods("DELETE")
+	ar.handle(authUser, "/api/calendar/event", deps.KlokkuCalendarHandler.GetEvents).Queries("from", "{from}", "to", "{to}").Methods("GET")
+	ar.handle(authUser, "/api/calendar/event", deps.KlokkuCalendarHandler.CreateEvent).Methods("POST")
+	ar.handle(authUser, "/api/calendar/event/overlaps", deps.KlokkuCalendarHandler.GetOverlaps).Queries("from", "{from}", "to", "{to}").Methods("GET")
+	ar.handle(authUser, "/api/calendar/event/recent", deps.KlokkuCalendarHandler.GetLastEvents).Methods("GET").Queries("last", "{last}")
+	ar.handle(authUser, "/api/calendar/event/{eventUid}/lineage", deps.KlokkuCalendarHandler.GetEventLineage).Methods("GET")
+	ar.handle(authUser, "/api/calendar/event/{eventUid}", deps.KlokkuCalendarHandler.UpdateEvent).Methods("PUT")
+	ar.handle(authUser, "/api/calendar/event/{eventUid}", deps.KlokkuCalendarHandler.DeleteEvent).Methods("DELETE")
+	ar.handle(authUser, "/api/calendar/diff", deps.KlokkuCalendarHandler.GetDiff).Queries("date", "{date}", "fromVersion", "{fromVersion}", "toVersion", "{toVersion}").Methods("GET")
+	ar.handle(authUser, "/api/calendar/providers", deps.CalendarProviderHandler.ListProviders).Methods("GET")
+
+	// Outlook calendar integration
+	ar.handle(authUser, "/api/integrations/outlook/auth/login", deps.OutlookAuth.OAuthLogin).Methods("GET")
+	ar.handle(anonymous, "/api/integrations/outlook/auth/callback", deps.OutlookAuth.OAuthCallback).Methods("GET")
+	ar.handle(authUser, "/api/integrations/outlook/auth", deps.OutlookAuth.IsAuthenticated).Methods("GET")
+	ar.handleAudited(audit.ActionIntegrationDisabled, authUser, "/api/integrations/outlook/auth", deps.OutlookAuth.Disconnect).Methods("DELETE")
+	ar.handle(authUser, "/api/integrations/outlook/calendars", deps.OutlookHandler.ListCalendars).Methods("GET")
+
+	// Google calendar integration
+	ar.handle(authUser, "/api/integrations/google/auth/login", deps.GoogleAuth.OAuthLogin).Methods("GET")
+	ar.handle(anonymous, "/api/integrations/google/auth/callback", deps.GoogleAuth.OAuthCallback).Methods("GET")
+	ar.handle(authUser, "/api/integrations/google/auth", deps.GoogleAuth.IsAuthenticated).Methods("GET")
+	ar.handleAudited(audit.ActionIntegrationDisabled, authUser, "/api/integrations/google/auth", deps.GoogleAuth.Disconnect).Methods("DELETE")
+	ar.handle(authUser, "/api/integrations/google/calendars", deps.GoogleHandler.ListCalendars).Methods("GET")
+	ar.handle(authUser, "/api/integrations/google/import-rules", deps.GoogleHandler.GetImportRules).Methods("GET")
+	ar.handleAudited(audit.ActionSettingsChanged, authUser, "/api/integrations/google/import-rules", deps.GoogleHandler.StoreImportRules).Methods("PUT")
+	ar.handle(anonymous, "/api/integrations/google/notifications", deps.GoogleHandler.Notify).Methods("POST")
+
+	// Toggl integration
+	ar.handle(authUser, "/api/integrations/toggl", deps.TogglHandler.GetConfiguration).Methods("GET")
+	ar.handleAudited(audit.ActionIntegrationEnabled, authUser, "/api/integrations/toggl", deps.TogglHandler.StoreConfiguration).Methods("PUT")
+	ar.handleAudited(audit.ActionIntegrationDisabled, authUser, "/api/integrations/toggl", deps.TogglHandler.DeleteConfiguration).Methods("DELETE")
+	ar.handle(authUser, "/api/integrations/toggl/projects", deps.TogglHandler.ListProjects).Methods("GET")
+	ar.handle(authUser, "/api/integrations/toggl/mappings", deps.TogglHandler.GetMappings).Methods("GET")
+	ar.handle(authUser, "/api/integrations/toggl/mappings", deps.TogglHandler.StoreMappings).Methods("PUT")
+	ar.handle(authUser, "/api/integrations/toggl/import", deps.TogglHandler.Import).Methods("POST")
+	ar.handle(authUser, "/api/integrations/toggl/sync", deps.TogglHandler.Sync).Methods("POST")
+
+	// Third-party credentials
+	ar.handleAudited(audit.ActionIntegrationDisabled, authUser, "/api/integrations/credentials", deps.CredentialsHandler.RevokeAll).Methods("DELETE")
 
 	// ClickUp integration
-	r.HandleFunc("/api/integrations/clickup/auth/login", deps.ClickUpAuth.OAuthLogin).Methods("GET")
-	r.HandleFunc("/api/integrations/clickup/auth/callback", deps.ClickUpAuth.OAuthCallback).Methods("GET")
-	r.HandleFunc("/api/integrations/clickup/auth", deps.ClickUpAuth.IsAuthenticated).Methods("GET")
-	r.HandleFunc("/api/integrations/clickup/auth", deps.ClickUpHandler.DisableIntegration).Methods("DELETE")
-	r.HandleFunc("/api/integrations/clickup/workspace", deps.ClickUpHandler.ListWorkspaces).Methods("GET")
-	r.HandleFunc("/api/integrations/clickup/space", deps.ClickUpHandler.ListSpaces).Queries("workspaceId", "{workspaceId}").Methods("GET")
-	r.HandleFunc("/api/integrations/clickup/tag", deps.ClickUpHandler.ListTags).Queries("spaceId", "{spaceId}").Methods("GET")
-	r.HandleFunc("/api/integrations/clickup/folder", deps.ClickUpHandler.ListFolders).Queries("spaceId", "{spaceId}").Methods("GET")
-	r.HandleFunc("/api/integrations/clickup/configuration/{budgetPlanId}", deps.ClickUpHandler.GetConfiguration).Methods("GET")
-	r.HandleFunc("/api/integrations/clickup/configuration/{budgetPlanId}", deps.ClickUpHandler.StoreConfiguration).Methods("PUT")
-	r.HandleFunc("/api/integrations/clickup/configuration/{budgetPlanId}", deps.ClickUpHandler.DeleteBudgetPlanConfiguration).Methods("DELETE")
-	r.HandleFunc("/api/integrations/clickup/tasks", deps.ClickUpHandler.GetTasks).Queries("budgetItemId", "{budgetItemId}").Methods("GET")
+	ar.handle(authUser, "/api/integrations/clickup/auth/login", deps.ClickUpAuth.OAuthLogin).Methods("GET")
+	ar.handle(anonymous, "/api/integrations/clickup/auth/callback", deps.ClickUpAuth.OAuthCallback).Methods("GET")
+	ar.handle(authUser, "/api/integrations/clickup/auth", deps.ClickUpAuth.IsAuthenticated).Methods("GET")
+	ar.handleAudited(audit.ActionIntegrationDisabled, authUser, "/api/integrations/clickup/auth", deps.ClickUpHandler.DisableIntegration).Methods("DELETE")
+	ar.handle(authUser, "/api/integrations/clickup/status", deps.ClickUpHandler.GetStatus).Methods("GET")
+	ar.handle(authUser, "/api/integrations/clickup/workspace", deps.ClickUpHandler.ListWorkspaces).Methods("GET")
+	ar.handle(authUser, "/api/integrations/clickup/space", deps.ClickUpHandler.ListSpaces).Queries("workspaceId", "{workspaceId}").Methods("GET")
+	ar.handle(authUser, "/api/integrations/clickup/tag", deps.ClickUpHandler.ListTags).Queries("spaceId", "{spaceId}").Methods("GET")
+	ar.handle(authUser, "/api/integrations/clickup/folder", deps.ClickUpHandler.ListFolders).Queries("spaceId", "{spaceId}").Methods("GET")
+	ar.handle(authUser, "/api/integrations/clickup/configuration/{budgetPlanId}", deps.ClickUpHandler.GetConfiguration).Methods("GET")
+	ar.handleAudited(audit.ActionSettingsChanged, authUser, "/api/integrations/clickup/configuration/{budgetPlanId}", deps.ClickUpHandler.StoreConfiguration).Methods("PUT")
+	ar.handleAudited(audit.ActionIntegrationDisabled, authUser, "/api/integrations/clickup/configuration/{budgetPlanId}", deps.ClickUpHandler.DeleteBudgetPlanConfiguration).Methods("DELETE")
+	ar.handle(authUser, "/api/integrations/clickup/tasks", deps.ClickUpHandler.GetTasks).Queries("budgetItemId", "{budgetItemId}").Methods("GET")
 }
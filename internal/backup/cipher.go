@@ -0,0 +1,197 @@
+package backup
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// encryptedMagic opens an encrypted backup, it is authenticated with every segment.
+const encryptedMagic = "KLOKKUE1"
+
+// segmentSize is the size of the plaintext sealed at once, so a backup is encrypted and decrypted as a stream.
+const segmentSize = 64 * 1024
+
+// noncePrefixSize is the random part of the nonces of a backup, the rest is the segment counter and the last flag.
+const noncePrefixSize = 7
+
+var ErrMissingKey = errors.New("backup is encrypted but no encryption key is configured")
+
+// Cipher encrypts backups with AES-256-GCM. Without a key no backup is created, only the unencrypted ones made
+// before can be restored.
+//
+// An encrypted backup is encryptedMagic, a random nonce prefix and the segments, each its sealed length followed by
+// the sealed segment. The nonce of a segment is the prefix, its counter and whether it is the last one, so segments
+// cannot be reordered, and a backup cut after any segment does not decrypt.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a cipher from a base64 encoded 32 byte key, an empty key disables it.
+func NewCipher(key string) (*Cipher, error) {
+	if key == "" {
+		return &Cipher{}, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key is not valid base64: %w", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(raw))
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+func (c *Cipher) Enabled() bool {
+	return c.aead != nil
+}
+
+// Encrypt returns a writer encrypting what is written to w. Closing it writes the last segment, it does not close w.
+func (c *Cipher) Encrypt(w io.Writer) (io.WriteCloser, error) {
+	if c.aead == nil {
+		return nil, ErrMissingKey
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce[:noncePrefixSize]); err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, encryptedMagic); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(nonce[:noncePrefixSize]); err != nil {
+		return nil, err
+	}
+	return &encryptingWriter{aead: c.aead, w: w, nonce: nonce, buf: make([]byte, 0, segmentSize)}, nil
+}
+
+// Decrypt returns a reader of the plaintext of the encrypted backup read from r.
+func (c *Cipher) Decrypt(r io.Reader) (io.Reader, error) {
+	if c.aead == nil {
+		return nil, ErrMissingKey
+	}
+	br := bufio.NewReader(r)
+	header := make([]byte, len(encryptedMagic)+noncePrefixSize)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[:len(encryptedMagic)]) != encryptedMagic {
+		return nil, fmt.Errorf("%w: not an encrypted backup", ErrIncompatibleBackup)
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	copy(nonce, header[len(encryptedMagic):])
+	return &decryptingReader{aead: c.aead, r: br, nonce: nonce}, nil
+}
+
+// setSegmentNonce sets the counter and the last flag of the segment after the random prefix.
+func setSegmentNonce(nonce []byte, counter uint32, last bool) {
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], counter)
+	nonce[len(nonce)-1] = 0
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+}
+
+type encryptingWriter struct {
+	aead    cipher.AEAD
+	w       io.Writer
+	nonce   []byte
+	counter uint32
+	buf     []byte
+}
+
+func (ew *encryptingWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// A full segment is sealed only once more data comes, the last one is sealed by Close
+		if len(ew.buf) == segmentSize {
+			if err := ew.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(ew.buf[len(ew.buf):segmentSize], p)
+		ew.buf = ew.buf[:len(ew.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (ew *encryptingWriter) Close() error {
+	return ew.seal(true)
+}
+
+func (ew *encryptingWriter) seal(last bool) error {
+	setSegmentNonce(ew.nonce, ew.counter, last)
+	sealed := ew.aead.Seal(nil, ew.nonce, ew.buf, []byte(encryptedMagic))
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(sealed)))
+	if _, err := ew.w.Write(length[:]); err != nil {
+		return err
+	}
+	if _, err := ew.w.Write(sealed); err != nil {
+		return err
+	}
+	ew.counter++
+	ew.buf = ew.buf[:0]
+	return nil
+}
+
+type decryptingReader struct {
+	aead    cipher.AEAD
+	r       *bufio.Reader
+	nonce   []byte
+	counter uint32
+	plain   []byte
+	done    bool
+}
+
+func (dr *decryptingReader) Read(p []byte) (int, error) {
+	for len(dr.plain) == 0 {
+		if dr.done {
+			return 0, io.EOF
+		}
+		if err := dr.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, dr.plain)
+	dr.plain = dr.plain[n:]
+	return n, nil
+}
+
+func (dr *decryptingReader) open() error {
+	var length [4]byte
+	if _, err := io.ReadFull(dr.r, length[:]); err != nil {
+		return fmt.Errorf("%w: encrypted backup is truncated", ErrIncompatibleBackup)
+	}
+	size := binary.BigEndian.Uint32(length[:])
+	if size > segmentSize+uint32(dr.aead.Overhead()) {
+		return fmt.Errorf("%w: encrypted backup is malformed", ErrIncompatibleBackup)
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(dr.r, sealed); err != nil {
+		return fmt.Errorf("%w: encrypted backup is truncated", ErrIncompatibleBackup)
+	}
+	_, err := dr.r.Peek(1)
+	last := errors.Is(err, io.EOF)
+	setSegmentNonce(dr.nonce, dr.counter, last)
+	plain, err := dr.aead.Open(nil, dr.nonce, sealed, []byte(encryptedMagic))
+	if err != nil {
+		return fmt.Errorf("unable to decrypt backup, was the encryption key changed or the backup cut? %w", err)
+	}
+	dr.counter++
+	dr.plain = plain
+	dr.done = last
+	return nil
+}
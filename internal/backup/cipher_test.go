@@ -0,0 +1,79 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encrypt(t *testing.T, cipher *Cipher, plaintext []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := cipher.Encrypt(&buf)
+	require.NoError(t, err)
+	_, err = w.Write(plaintext)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestCipher_RoundTrip(t *testing.T) {
+	cipher, err := NewCipher(testEncryptionKey)
+	require.NoError(t, err)
+
+	for _, size := range []int{0, 10, segmentSize, 3*segmentSize + 17} {
+		plaintext := make([]byte, size)
+		_, err := rand.Read(plaintext)
+		require.NoError(t, err)
+
+		r, err := cipher.Decrypt(bytes.NewReader(encrypt(t, cipher, plaintext)))
+		require.NoError(t, err)
+		decrypted, err := io.ReadAll(r)
+
+		require.NoError(t, err, "size %d", size)
+		assert.Equal(t, plaintext, decrypted, "size %d", size)
+	}
+}
+
+func TestCipher_DetectsTampering(t *testing.T) {
+	cipher, err := NewCipher(testEncryptionKey)
+	require.NoError(t, err)
+	encrypted := encrypt(t, cipher, bytes.Repeat([]byte("klokku"), segmentSize))
+
+	t.Run("cut after a segment", func(t *testing.T) {
+		firstSegment := len(encryptedMagic) + noncePrefixSize + 4 + segmentSize + 16
+		r, err := cipher.Decrypt(bytes.NewReader(encrypted[:firstSegment]))
+		require.NoError(t, err)
+		_, err = io.ReadAll(r)
+		assert.Error(t, err)
+	})
+
+	t.Run("changed byte", func(t *testing.T) {
+		changed := bytes.Clone(encrypted)
+		changed[len(changed)-1] ^= 1
+		r, err := cipher.Decrypt(bytes.NewReader(changed))
+		require.NoError(t, err)
+		_, err = io.ReadAll(r)
+		assert.Error(t, err)
+	})
+
+	t.Run("another key", func(t *testing.T) {
+		other, err := NewCipher("ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=")
+		require.NoError(t, err)
+		r, err := other.Decrypt(bytes.NewReader(encrypted))
+		require.NoError(t, err)
+		_, err = io.ReadAll(r)
+		assert.Error(t, err)
+	})
+}
+
+func TestNewCipher_InvalidKey(t *testing.T) {
+	_, err := NewCipher("c2hvcnQ=")
+	assert.Error(t, err)
+	_, err = NewCipher("not base64!")
+	assert.Error(t, err)
+}
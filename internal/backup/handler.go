@@ -0,0 +1,185 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+)
+
+// transferTimeout bounds creating and downloading a backup, which take longer than the server write timeout
+// for large instances
+const transferTimeout = 30 * time.Minute
+
+type BackupDTO struct {
+	Name string `json:"name"`
+	// UserUid is the user of a per-user backup, empty for a backup of the whole instance or when the user has been
+	// deleted since
+	UserUid   string    `json:"userUid"`
+	Instance  bool      `json:"instance"`
+	CreatedAt time.Time `json:"createdAt"`
+	SizeBytes int64     `json:"sizeBytes"`
+	// Encrypted backups are restored only with the encryption key they were made with
+	Encrypted bool `json:"encrypted"`
+}
+
+type Handler struct {
+	service     Service
+	userService user.Service
+}
+
+func NewHandler(service Service, userService user.Service) *Handler {
+	return &Handler{service: service, userService: userService}
+}
+
+// ListBackups godoc
+// @Summary List backups
+// @Description List the stored backups, the newest first. Requires an admin user.
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} BackupDTO
+// @Failure 403 {string} string "User not found or not an admin"
+// @Router /api/admin/backups [get]
+// @Security XUserId
+func (h *Handler) ListBackups(w http.ResponseWriter, r *http.Request) {
+	backups, err := h.service.ListBackups(r.Context())
+	if err != nil {
+		log.Errorf("Failed to list backups: %v", err)
+		http.Error(w, "Failed to list backups", http.StatusInternalServerError)
+		return
+	}
+	users, err := h.userService.GetAllUsers(r.Context())
+	if err != nil {
+		log.Errorf("Failed to get users: %v", err)
+		http.Error(w, "Failed to list backups", http.StatusInternalServerError)
+		return
+	}
+	uids := make(map[int]string, len(users))
+	for _, u := range users {
+		uids[u.Id] = u.Uid
+	}
+
+	dtos := make([]BackupDTO, 0, len(backups))
+	for _, backup := range backups {
+		dtos = append(dtos, backupToDTO(backup, uids[backup.UserId]))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		log.Errorf("Failed to encode backups: %v", err)
+		http.Error(w, "Failed to encode backups", http.StatusInternalServerError)
+	}
+}
+
+// CreateBackup godoc
+// @Summary Create a backup
+// @Description Store a snapshot of the data of the user, or of the whole instance when no user is given. It is
+// @Description restored with the --restore flag while Klokku is stopped. Requires an admin user.
+// @Tags Admin
+// @Produce json
+// @Param userUid query string false "Back up only the data of this user"
+// @Success 201 {object} BackupDTO
+// @Failure 403 {string} string "User not found or not an admin"
+// @Failure 404 {string} string "User to back up not found"
+// @Failure 409 {string} string "No encryption key is configured"
+// @Router /api/admin/backups [post]
+// @Security XUserId
+func (h *Handler) CreateBackup(w http.ResponseWriter, r *http.Request) {
+	userId := 0
+	userUid := r.URL.Query().Get("userUid")
+	if userUid != "" {
+		u, err := h.userService.GetUserByUid(r.Context(), userUid)
+		if err != nil {
+			if errors.Is(err, user.ErrUserNotFound) {
+				http.Error(w, "User to back up not found", http.StatusNotFound)
+				return
+			}
+			log.Errorf("Failed to get user: %v", err)
+			http.Error(w, "Failed to create backup", http.StatusInternalServerError)
+			return
+		}
+		userId = u.Id
+	}
+
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(transferTimeout)); err != nil {
+		log.Debugf("Failed to extend write deadline of backup creation: %v", err)
+	}
+	backup, err := h.service.CreateBackup(r.Context(), userId)
+	if err != nil {
+		if errors.Is(err, ErrEncryptionRequired) {
+			http.Error(w, "Backups need an encryption key to be configured", http.StatusConflict)
+			return
+		}
+		log.Errorf("Failed to create backup: %v", err)
+		http.Error(w, "Failed to create backup", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(backupToDTO(backup, userUid)); err != nil {
+		log.Errorf("Failed to encode backup: %v", err)
+	}
+}
+
+// DownloadBackup godoc
+// @Summary Download a backup
+// @Description Download the backup as gzip compressed JSON lines, e.g. to keep it off the Klokku host. Encrypted
+// @Description backups are downloaded as stored, encrypted. Requires an admin user.
+// @Tags Admin
+// @Produce application/gzip
+// @Produce application/octet-stream
+// @Param name path string true "Backup name"
+// @Success 200 {file} file
+// @Failure 400 {string} string "Invalid backup name"
+// @Failure 403 {string} string "User not found or not an admin"
+// @Failure 404 {string} string "Backup not found"
+// @Router /api/admin/backups/{name} [get]
+// @Security XUserId
+func (h *Handler) DownloadBackup(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	content, err := h.service.OpenBackup(r.Context(), name)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidBackupName):
+			http.Error(w, "Invalid backup name", http.StatusBadRequest)
+		case errors.Is(err, ErrBackupNotFound):
+			http.Error(w, "Backup not found", http.StatusNotFound)
+		default:
+			log.Errorf("Failed to open backup: %v", err)
+			http.Error(w, "Failed to open backup", http.StatusInternalServerError)
+		}
+		return
+	}
+	defer content.Close()
+
+	contentType := "application/gzip"
+	if strings.HasSuffix(name, encryptedSuffix) {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(name))
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(transferTimeout)); err != nil {
+		log.Debugf("Failed to extend write deadline of backup download: %v", err)
+	}
+	if _, err := io.Copy(w, content); err != nil {
+		log.Errorf("Failed to send backup %s: %v", name, err)
+	}
+}
+
+func backupToDTO(backup Backup, userUid string) BackupDTO {
+	return BackupDTO{
+		Name:      backup.Name,
+		UserUid:   userUid,
+		Instance:  backup.UserId == 0,
+		CreatedAt: backup.CreatedAt,
+		SizeBytes: backup.Size,
+		Encrypted: backup.Encrypted,
+	}
+}
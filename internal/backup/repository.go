@@ -0,0 +1,459 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/klokku/klokku/internal/database"
+)
+
+// excludedTables hold the state of the running instances rather than data, schema_migrations is recorded as the
+// schema version of the snapshot.
+var excludedTables = []string{"schema_migrations", "scheduler_leader", "scheduled_job"}
+
+type Repository interface {
+	// Dump writes a consistent snapshot of the data of the user, or of the whole instance when userId is 0.
+	Dump(ctx context.Context, w io.Writer, userId int, now time.Time) error
+	// Restore replaces the data of the snapshot's user, or of the whole instance, with the snapshot.
+	Restore(ctx context.Context, r io.Reader) (Header, error)
+	// Verify restores the snapshot into a temporary copy of the schema, which is dropped afterwards.
+	Verify(ctx context.Context, r io.Reader) (Header, error)
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+func (r *RepositoryImpl) Dump(ctx context.Context, w io.Writer, userId int, now time.Time) error {
+	status, err := database.GetMigrationStatus(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	if status.Dirty {
+		return fmt.Errorf("migration %d failed, the schema has to be fixed before a backup", status.Version)
+	}
+
+	// All tables are read from one snapshot of the database, so they are consistent with each other
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("failed to begin backup transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	s, err := loadSchema(ctx, tx)
+	if err != nil {
+		return err
+	}
+	sw, err := newSnapshotWriter(w, Header{CreatedAt: now, SchemaVersion: status.Version, UserId: userId})
+	if err != nil {
+		return err
+	}
+	for _, table := range s.ordered {
+		filter, args, ok := s.userFilter(table, userId)
+		if !ok {
+			continue
+		}
+		if err := dumpTable(ctx, tx, sw, table, filter, args); err != nil {
+			return fmt.Errorf("failed to back up table %s: %w", table, err)
+		}
+	}
+	return sw.flush()
+}
+
+func dumpTable(ctx context.Context, tx pgx.Tx, sw *snapshotWriter, table string, filter string, args []any) error {
+	from := fmt.Sprintf("FROM %s t WHERE %s", pgx.Identifier{table}.Sanitize(), filter)
+	var count int
+	if err := tx.QueryRow(ctx, "SELECT count(*) "+from, args...).Scan(&count); err != nil {
+		return err
+	}
+	if err := sw.startTable(table, count); err != nil {
+		return err
+	}
+	rows, err := tx.Query(ctx, "SELECT row_to_json(t)::text "+from, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	written := 0
+	for rows.Next() {
+		var row []byte
+		if err := rows.Scan(&row); err != nil {
+			return err
+		}
+		if err := sw.writeRow(row); err != nil {
+			return err
+		}
+		written++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if written != count {
+		return fmt.Errorf("counted %d rows but read %d", count, written)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) Restore(ctx context.Context, reader io.Reader) (Header, error) {
+	sr, header, err := r.openSnapshot(ctx, reader)
+	if err != nil {
+		return Header{}, err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return Header{}, fmt.Errorf("failed to begin restore transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	s, err := loadSchema(ctx, tx)
+	if err != nil {
+		return Header{}, err
+	}
+	if err := s.clear(ctx, tx, header.UserId); err != nil {
+		return Header{}, err
+	}
+	if err := s.restoreTables(ctx, tx, sr); err != nil {
+		return Header{}, err
+	}
+	// Ids restored for one user were allocated before, only a whole instance starts over
+	if header.UserId == 0 {
+		if err := s.resetIdentities(ctx, tx); err != nil {
+			return Header{}, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return Header{}, fmt.Errorf("failed to commit restore: %w", err)
+	}
+	return header, nil
+}
+
+func (r *RepositoryImpl) Verify(ctx context.Context, reader io.Reader) (Header, error) {
+	sr, header, err := r.openSnapshot(ctx, reader)
+	if err != nil {
+		return Header{}, err
+	}
+
+	// The schema is created in a transaction that is never committed, so nothing is left behind
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return Header{}, fmt.Errorf("failed to begin verification transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	s, err := loadSchema(ctx, tx)
+	if err != nil {
+		return Header{}, err
+	}
+	var source string
+	if err := tx.QueryRow(ctx, "SELECT current_schema()").Scan(&source); err != nil {
+		return Header{}, fmt.Errorf("failed to get current schema: %w", err)
+	}
+	target := fmt.Sprintf("%s_verify_%d", source, time.Now().UnixNano())
+	if _, err := tx.Exec(ctx, "CREATE SCHEMA "+pgx.Identifier{target}.Sanitize()); err != nil {
+		return Header{}, fmt.Errorf("failed to create verification schema: %w", err)
+	}
+	// Copies keep the columns, defaults, checks and unique indexes, but not the foreign keys
+	for _, table := range s.ordered {
+		query := fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING ALL)", pgx.Identifier{target, table}.Sanitize(),
+			pgx.Identifier{source, table}.Sanitize())
+		if _, err := tx.Exec(ctx, query); err != nil {
+			return Header{}, fmt.Errorf("failed to copy table %s: %w", table, err)
+		}
+	}
+	if _, err := tx.Exec(ctx, "SELECT set_config('search_path', $1, true)", target); err != nil {
+		return Header{}, fmt.Errorf("failed to switch to verification schema: %w", err)
+	}
+	if err := s.restoreTables(ctx, tx, sr); err != nil {
+		return Header{}, err
+	}
+	return header, nil
+}
+
+// openSnapshot reads the header of the snapshot and checks it can be restored to the database.
+func (r *RepositoryImpl) openSnapshot(ctx context.Context, reader io.Reader) (*snapshotReader, Header, error) {
+	sr, header, err := newSnapshotReader(reader)
+	if err != nil {
+		return nil, Header{}, err
+	}
+	status, err := database.GetMigrationStatus(ctx, r.db)
+	if err != nil {
+		return nil, Header{}, err
+	}
+	if status.Dirty || status.Version != header.SchemaVersion {
+		return nil, Header{}, fmt.Errorf("%w: the backup is of schema version %d, the database is at %d",
+			ErrIncompatibleBackup, header.SchemaVersion, status.Version)
+	}
+	return sr, header, nil
+}
+
+// restoreBatchSize is the number of rows inserted by one statement.
+const restoreBatchSize = 1000
+
+// restoreTables inserts the rows of the snapshot into the tables of the current schema.
+func (s *schema) restoreTables(ctx context.Context, tx pgx.Tx, sr *snapshotReader) error {
+	for {
+		tr, err := sr.nextTable()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := s.tables[tr.table]; !ok {
+			return fmt.Errorf("%w: unknown table %s", ErrIncompatibleBackup, tr.table)
+		}
+		if err := s.restoreTable(ctx, tx, tr); err != nil {
+			return fmt.Errorf("failed to restore table %s: %w", tr.table, err)
+		}
+	}
+}
+
+// restoreTable inserts the rows of a table in batches. Foreign keys are checked at the end of each statement, so
+// the rows of a table referencing itself are staged first and then copied at once.
+func (s *schema) restoreTable(ctx context.Context, tx pgx.Tx, tr *tableReader) error {
+	target := pgx.Identifier{tr.table}.Sanitize()
+	into := target
+	if s.referencesItself(tr.table) {
+		into = pgx.Identifier{"restore_" + tr.table}.Sanitize()
+		if _, err := tx.Exec(ctx, fmt.Sprintf("CREATE TEMP TABLE %s (LIKE %s) ON COMMIT DROP", into, target)); err != nil {
+			return err
+		}
+	}
+	query := fmt.Sprintf(`INSERT INTO %s OVERRIDING SYSTEM VALUE
+						  SELECT * FROM json_populate_recordset(NULL::%s, $1::json)`, into, target)
+	for {
+		rows, err := tr.nextBatch(restoreBatchSize)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, query, string(rows)); err != nil {
+			return err
+		}
+	}
+	if into == target {
+		return nil
+	}
+	if _, err := tx.Exec(ctx, fmt.Sprintf("INSERT INTO %s OVERRIDING SYSTEM VALUE SELECT * FROM %s", target,
+		into)); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, "DROP TABLE "+into)
+	return err
+}
+
+func (s *schema) referencesItself(table string) bool {
+	return slices.ContainsFunc(s.tables[table], func(fk foreignKey) bool { return fk.parent == table })
+}
+
+type foreignKey struct {
+	column       string
+	parent       string
+	parentColumn string
+}
+
+// schema is the structure of the tables relevant to backups.
+type schema struct {
+	tables map[string][]foreignKey
+	// ordered lists the tables with the referenced ones before those referencing them
+	ordered    []string
+	userTables map[string]bool
+	// identities holds the columns with generated ids, identity as well as serial ones
+	identities map[string][]string
+}
+
+func loadSchema(ctx context.Context, tx pgx.Tx) (*schema, error) {
+	s := &schema{
+		tables:     make(map[string][]foreignKey),
+		userTables: make(map[string]bool),
+		identities: make(map[string][]string),
+	}
+	rows, err := tx.Query(ctx, `SELECT c.relname,
+									   EXISTS (SELECT 1 FROM pg_attribute a
+											   WHERE a.attrelid = c.oid AND a.attname = 'user_id' AND NOT a.attisdropped)
+								FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+								WHERE n.nspname = current_schema() AND c.relkind = 'r'
+								ORDER BY c.relname`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		var hasUserId bool
+		if err := rows.Scan(&name, &hasUserId); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if slices.Contains(excludedTables, name) {
+			continue
+		}
+		names = append(names, name)
+		s.tables[name] = nil
+		s.userTables[name] = hasUserId
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(ctx, `SELECT cl.relname, att.attname, rcl.relname, ratt.attname
+							   FROM pg_constraint con
+							   JOIN pg_class cl ON cl.oid = con.conrelid
+							   JOIN pg_namespace n ON n.oid = cl.relnamespace
+							   JOIN pg_class rcl ON rcl.oid = con.confrelid
+							   JOIN pg_attribute att ON att.attrelid = con.conrelid AND att.attnum = con.conkey[1]
+							   JOIN pg_attribute ratt ON ratt.attrelid = con.confrelid AND ratt.attnum = con.confkey[1]
+							   WHERE con.contype = 'f' AND n.nspname = current_schema()
+							   ORDER BY cl.relname, att.attname`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list foreign keys: %w", err)
+	}
+	for rows.Next() {
+		var table string
+		var fk foreignKey
+		if err := rows.Scan(&table, &fk.column, &fk.parent, &fk.parentColumn); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if _, ok := s.tables[table]; ok {
+			s.tables[table] = append(s.tables[table], fk)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.Query(ctx, `SELECT table_name, column_name FROM information_schema.columns
+							   WHERE table_schema = current_schema()
+							     AND (is_identity = 'YES' OR column_default LIKE 'nextval(%')`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list generated id columns: %w", err)
+	}
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s.identities[table] = append(s.identities[table], column)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	visited := make(map[string]bool)
+	var visit func(table string)
+	visit = func(table string) {
+		if visited[table] {
+			return
+		}
+		visited[table] = true
+		for _, fk := range s.tables[table] {
+			if _, ok := s.tables[fk.parent]; ok {
+				visit(fk.parent)
+			}
+		}
+		s.ordered = append(s.ordered, table)
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	return s, nil
+}
+
+// userFilter returns the condition selecting the rows of the user from the table aliased t, following the foreign
+// keys of tables without a user_id column to a table with one. It returns false when the table holds no data of
+// users. When userId is 0 it selects all rows.
+func (s *schema) userFilter(table string, userId int) (string, []any, bool) {
+	if userId == 0 {
+		return "true", nil, true
+	}
+	filter, ok := s.userCondition(table, "t", map[string]bool{})
+	if !ok {
+		return "", nil, false
+	}
+	return filter, []any{userId}, true
+}
+
+func (s *schema) userCondition(table string, alias string, visiting map[string]bool) (string, bool) {
+	switch {
+	case table == "users":
+		return alias + ".id = $1", true
+	case s.userTables[table]:
+		return alias + ".user_id = $1", true
+	case visiting[table]:
+		return "", false
+	}
+	visiting[table] = true
+	defer delete(visiting, table)
+	for _, fk := range s.tables[table] {
+		parentAlias := alias + "p"
+		parentFilter, ok := s.userCondition(fk.parent, parentAlias, visiting)
+		if !ok {
+			continue
+		}
+		return fmt.Sprintf("%s.%s IN (SELECT %s.%s FROM %s %s WHERE %s)", alias, pgx.Identifier{fk.column}.Sanitize(),
+			parentAlias, pgx.Identifier{fk.parentColumn}.Sanitize(), pgx.Identifier{fk.parent}.Sanitize(), parentAlias,
+			parentFilter), true
+	}
+	return "", false
+}
+
+// clear deletes the data the snapshot replaces, of the user or of the whole instance.
+func (s *schema) clear(ctx context.Context, tx pgx.Tx, userId int) error {
+	if userId == 0 {
+		tables := make([]string, 0, len(s.ordered))
+		for _, table := range s.ordered {
+			tables = append(tables, pgx.Identifier{table}.Sanitize())
+		}
+		query := "TRUNCATE " + strings.Join(tables, ", ") + " CASCADE"
+		if _, err := tx.Exec(ctx, query); err != nil {
+			return fmt.Errorf("failed to clear the database: %w", err)
+		}
+		return nil
+	}
+	// The referencing rows go first
+	for _, table := range slices.Backward(s.ordered) {
+		filter, args, ok := s.userFilter(table, userId)
+		if !ok {
+			continue
+		}
+		query := fmt.Sprintf("DELETE FROM %s t WHERE %s", pgx.Identifier{table}.Sanitize(), filter)
+		if _, err := tx.Exec(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to clear table %s of user %d: %w", table, userId, err)
+		}
+	}
+	return nil
+}
+
+// resetIdentities continues the generated ids after the restored ones.
+func (s *schema) resetIdentities(ctx context.Context, tx pgx.Tx) error {
+	for _, table := range s.ordered {
+		for _, column := range s.identities[table] {
+			query := fmt.Sprintf(`SELECT setval(pg_get_serial_sequence($1, $2), COALESCE(MAX(%s), 0) + 1, false) FROM %s`,
+				pgx.Identifier{column}.Sanitize(), pgx.Identifier{table}.Sanitize())
+			if _, err := tx.Exec(ctx, query, table, column); err != nil {
+				return fmt.Errorf("failed to reset ids of table %s: %w", table, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+type stubRow struct {
+	Value string `json:"value"`
+}
+
+// RepositoryStub dumps Data as the rows of a single table, a restore replaces Data with the rows of the snapshot.
+type RepositoryStub struct {
+	Data []string
+	// Restored holds the headers of the restored snapshots
+	Restored []Header
+	// DumpErr fails a dump after the rows were written
+	DumpErr error
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{}
+}
+
+func (r *RepositoryStub) Dump(ctx context.Context, w io.Writer, userId int, now time.Time) error {
+	sw, err := newSnapshotWriter(w, Header{CreatedAt: now, SchemaVersion: 1, UserId: userId})
+	if err != nil {
+		return err
+	}
+	if err := sw.startTable("data", len(r.Data)); err != nil {
+		return err
+	}
+	for _, value := range r.Data {
+		if err := sw.writeJson(stubRow{Value: value}); err != nil {
+			return err
+		}
+	}
+	if err := sw.flush(); err != nil {
+		return err
+	}
+	return r.DumpErr
+}
+
+func (r *RepositoryStub) Restore(ctx context.Context, reader io.Reader) (Header, error) {
+	data, header, err := r.read(reader)
+	if err != nil {
+		return Header{}, err
+	}
+	r.Data = data
+	r.Restored = append(r.Restored, header)
+	return header, nil
+}
+
+// Verify reads the whole snapshot without changing Data.
+func (r *RepositoryStub) Verify(ctx context.Context, reader io.Reader) (Header, error) {
+	_, header, err := r.read(reader)
+	return header, err
+}
+
+func (r *RepositoryStub) read(reader io.Reader) ([]string, Header, error) {
+	sr, header, err := newSnapshotReader(reader)
+	if err != nil {
+		return nil, Header{}, err
+	}
+	data := make([]string, 0)
+	for {
+		tr, err := sr.nextTable()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, Header{}, err
+		}
+		for {
+			rows, err := tr.nextBatch(restoreBatchSize)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, Header{}, err
+			}
+			var parsed []stubRow
+			if err := json.Unmarshal(rows, &parsed); err != nil {
+				return nil, Header{}, err
+			}
+			for _, row := range parsed {
+				data = append(data, row.Value)
+			}
+		}
+	}
+	return data, header, nil
+}
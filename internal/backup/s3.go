@@ -0,0 +1,97 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/klokku/klokku/internal/config"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3PartSize is the size of the parts a backup is uploaded in, it bounds the memory an upload of unknown size takes.
+const s3PartSize = 16 * 1024 * 1024
+
+// S3Storage keeps the backups in a bucket of an S3-compatible service, e.g. AWS S3, MinIO or Backblaze B2. It
+// addresses the bucket in the path of the URL, which all of them support.
+type S3Storage struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func NewS3Storage(client *http.Client, cfg config.S3) (*S3Storage, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	s3, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure:       endpoint.Scheme == "https",
+		Region:       region,
+		BucketLookup: minio.BucketLookupPath,
+		Transport:    client.Transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+	return &S3Storage{client: s3, bucket: cfg.Bucket, prefix: cfg.Prefix}, nil
+}
+
+func (s *S3Storage) Put(ctx context.Context, name string, r io.Reader) error {
+	_, err := s.client.PutObject(ctx, s.bucket, s.prefix+name, r, -1, minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+		PartSize:    s3PartSize,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload backup: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Storage) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, s.prefix+name, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download backup: %w", err)
+	}
+	// The object is requested lazily, stat tells a missing one apart before the content is read
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+			return nil, ErrBackupNotFound
+		}
+		return nil, fmt.Errorf("failed to download backup: %w", err)
+	}
+	return object, nil
+}
+
+func (s *S3Storage) List(ctx context.Context) ([]Object, error) {
+	objects := make([]Object, 0)
+	for info := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.prefix}) {
+		if info.Err != nil {
+			return nil, fmt.Errorf("failed to list backups: %w", info.Err)
+		}
+		name := strings.TrimPrefix(info.Key, s.prefix)
+		// Objects in "subdirectories" of the prefix are not ours
+		if strings.Contains(name, "/") || !isBackupFile(name) {
+			continue
+		}
+		objects = append(objects, Object{Name: name, Size: info.Size, ModifiedAt: info.LastModified})
+	}
+	return objects, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, name string) error {
+	if err := s.client.RemoveObject(ctx, s.bucket, s.prefix+name, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete backup: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/klokku/klokku/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 keeps the objects of a single bucket in memory, uploads are always multipart as their size is unknown.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	parts   map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=access/") || !strings.Contains(auth, "/eu-west-1/s3/aws4_request") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/backups")
+	key = strings.TrimPrefix(key, "/")
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		fmt.Fprintf(w, `<InitiateMultipartUploadResult><Bucket>backups</Bucket><Key>%s</Key><UploadId>1</UploadId></InitiateMultipartUploadResult>`, key)
+	case r.Method == http.MethodPut && query.Has("partNumber"):
+		body, _ := io.ReadAll(r.Body)
+		f.parts[key] = append(f.parts[key], body...)
+		w.Header().Set("ETag", `"part"`)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		f.objects[key] = f.parts[key]
+		delete(f.parts, key)
+		fmt.Fprintf(w, `<CompleteMultipartUploadResult><Bucket>backups</Bucket><Key>%s</Key><ETag>"object"</ETag></CompleteMultipartUploadResult>`, key)
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.parts, key)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && key == "":
+		prefix := query.Get("prefix")
+		fmt.Fprint(w, `<ListBucketResult><IsTruncated>false</IsTruncated>`)
+		for name, body := range f.objects {
+			if strings.HasPrefix(name, prefix) {
+				fmt.Fprintf(w, `<Contents><Key>%s</Key><Size>%d</Size><LastModified>2026-10-15T03:00:00.000Z</LastModified></Contents>`,
+					name, len(body))
+			}
+		}
+		fmt.Fprint(w, `</ListBucketResult>`)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		body, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Header().Set("Last-Modified", "Thu, 15 Oct 2026 03:00:00 GMT")
+		w.Header().Set("ETag", `"object"`)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(body)
+		}
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Storage(t *testing.T) {
+	// given
+	ctx := context.Background()
+	fake := &fakeS3{
+		objects: map[string][]byte{"other/instance-20261001T030000Z.jsonl.gz": []byte("x")},
+		parts:   map[string][]byte{},
+	}
+	server := httptest.NewTLSServer(fake)
+	defer server.Close()
+	storage, err := NewS3Storage(server.Client(), config.S3{
+		Endpoint:  server.URL,
+		Region:    "eu-west-1",
+		Bucket:    "backups",
+		Prefix:    "klokku/",
+		AccessKey: "access",
+		SecretKey: "secret",
+	})
+	require.NoError(t, err)
+	name := "instance-20261015T030000Z.jsonl.gz"
+
+	// when
+	require.NoError(t, storage.Put(ctx, name, strings.NewReader("snapshot")))
+	objects, listErr := storage.List(ctx)
+	content, getErr := storage.Get(ctx, name)
+
+	// then
+	require.NoError(t, listErr)
+	require.NoError(t, getErr)
+	defer content.Close()
+	data, err := io.ReadAll(content)
+	require.NoError(t, err)
+	assert.Equal(t, "snapshot", string(data))
+	require.Len(t, objects, 1)
+	assert.Equal(t, name, objects[0].Name)
+	assert.Equal(t, int64(8), objects[0].Size)
+
+	// when deleted
+	require.NoError(t, storage.Delete(ctx, name))
+	_, err = storage.Get(ctx, name)
+
+	// then
+	assert.ErrorIs(t, err, ErrBackupNotFound)
+}
@@ -0,0 +1,307 @@
+package backup
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrInvalidBackupName = errors.New("invalid backup name")
+var ErrEncryptionRequired = errors.New("backups are created only with an encryption key configured")
+
+const nameTimeLayout = "20060102T150405Z"
+
+// namePattern matches the names of the backups, e.g. instance-20261015T030000Z.jsonl.gz.enc, or
+// user-12-20261015T030000Z.jsonl.gz for one made before backups had to be encrypted. Only such names reach the storage, so a name can never point
+// outside of it.
+var namePattern = regexp.MustCompile(`^(instance|user-(\d+))-(\d{8}T\d{6}Z)\.jsonl\.gz(\.enc)?$`)
+
+// Backup is a stored snapshot.
+type Backup struct {
+	Name string
+	// UserId is the user of a per-user backup, 0 for a backup of the whole instance
+	UserId    int
+	CreatedAt time.Time
+	Size      int64
+	// Encrypted backups need the encryption key they were made with to be restored
+	Encrypted bool
+}
+
+type Service interface {
+	// CreateBackup stores a snapshot of the user, or of the whole instance when userId is 0.
+	CreateBackup(ctx context.Context, userId int) (Backup, error)
+	// ListBackups returns the stored backups, the newest first.
+	ListBackups(ctx context.Context) ([]Backup, error)
+	// OpenBackup returns the compressed content of the backup.
+	OpenBackup(ctx context.Context, name string) (io.ReadCloser, error)
+	// Restore replaces the data of the backup's user, or of the whole instance, with the backup. Klokku must not
+	// be running meanwhile.
+	Restore(ctx context.Context, name string) (Backup, error)
+	// Verify restores the backup into a temporary schema, which is dropped afterwards, to prove it can be restored.
+	Verify(ctx context.Context, name string) (Backup, error)
+	// RunScheduled stores a snapshot of the whole instance and removes the instance backups beyond the ones to keep.
+	RunScheduled(ctx context.Context, now time.Time) error
+}
+
+type ServiceImpl struct {
+	repo    Repository
+	storage Storage
+	cipher  *Cipher
+	clock   utils.Clock
+	// keep is how many of the newest instance backups the scheduled backup keeps
+	keep int
+	// keepDaily and keepWeekly are how many days and weeks keep their newest instance backup
+	keepDaily  int
+	keepWeekly int
+}
+
+// NewService creates the backup service. Backups hold password hashes and integration tokens, so they are created
+// only when the cipher has a key.
+func NewService(repo Repository, storage Storage, cipher *Cipher, cfg config.Backup) *ServiceImpl {
+	return &ServiceImpl{
+		repo:       repo,
+		storage:    storage,
+		cipher:     cipher,
+		clock:      &utils.SystemClock{},
+		keep:       cfg.Keep,
+		keepDaily:  cfg.KeepDaily,
+		keepWeekly: cfg.KeepWeekly,
+	}
+}
+
+func (s *ServiceImpl) CreateBackup(ctx context.Context, userId int) (Backup, error) {
+	return s.create(ctx, userId, s.clock.Now())
+}
+
+func (s *ServiceImpl) create(ctx context.Context, userId int, now time.Time) (Backup, error) {
+	if !s.cipher.Enabled() {
+		return Backup{}, ErrEncryptionRequired
+	}
+	now = now.UTC().Truncate(time.Second)
+	backup := Backup{Name: backupName(userId, now), UserId: userId, CreatedAt: now, Encrypted: true}
+
+	// Streamed to the storage as it is dumped, which discards the backup when the dump fails
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(s.write(ctx, pw, userId, now))
+	}()
+	counter := &countingReader{r: pr}
+	err := s.storage.Put(ctx, backup.Name, counter)
+	// Unblocks the dump when the storage gave up before reading all of it
+	pr.CloseWithError(err)
+	if err != nil {
+		return Backup{}, err
+	}
+	backup.Size = counter.n
+	log.Infof("Stored backup %s (%d bytes)", backup.Name, backup.Size)
+	return backup, nil
+}
+
+// write dumps the snapshot to w, compressed and encrypted.
+func (s *ServiceImpl) write(ctx context.Context, w io.Writer, userId int, now time.Time) error {
+	encrypted, err := s.cipher.Encrypt(w)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt backup: %w", err)
+	}
+	gz := gzip.NewWriter(encrypted)
+	if err := s.repo.Dump(ctx, gz, userId, now); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress backup: %w", err)
+	}
+	if err := encrypted.Close(); err != nil {
+		return fmt.Errorf("failed to encrypt backup: %w", err)
+	}
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (s *ServiceImpl) ListBackups(ctx context.Context) ([]Backup, error) {
+	objects, err := s.storage.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	backups := make([]Backup, 0, len(objects))
+	for _, object := range objects {
+		backup, ok := parseName(object.Name)
+		if !ok {
+			continue
+		}
+		backup.Size = object.Size
+		backups = append(backups, backup)
+	}
+	slices.SortFunc(backups, func(a, b Backup) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return backups, nil
+}
+
+func (s *ServiceImpl) OpenBackup(ctx context.Context, name string) (io.ReadCloser, error) {
+	if _, ok := parseName(name); !ok {
+		return nil, ErrInvalidBackupName
+	}
+	return s.storage.Get(ctx, name)
+}
+
+func (s *ServiceImpl) Restore(ctx context.Context, name string) (Backup, error) {
+	backup, ok := parseName(name)
+	if !ok {
+		return Backup{}, ErrInvalidBackupName
+	}
+	header, err := s.withSnapshot(ctx, backup, s.repo.Restore)
+	if err != nil {
+		return Backup{}, err
+	}
+	if header.UserId != backup.UserId {
+		// The restore is committed already, the name was just misleading
+		log.Warnf("Backup %s holds the snapshot of user %d", name, header.UserId)
+	}
+	backup.UserId = header.UserId
+	return backup, nil
+}
+
+func (s *ServiceImpl) Verify(ctx context.Context, name string) (Backup, error) {
+	backup, ok := parseName(name)
+	if !ok {
+		return Backup{}, ErrInvalidBackupName
+	}
+	header, err := s.withSnapshot(ctx, backup, s.repo.Verify)
+	if err != nil {
+		return Backup{}, err
+	}
+	backup.UserId = header.UserId
+	return backup, nil
+}
+
+// withSnapshot passes the decrypted and decompressed snapshot of the backup to fn.
+func (s *ServiceImpl) withSnapshot(ctx context.Context, backup Backup, fn func(ctx context.Context, r io.Reader) (Header, error)) (Header, error) {
+	content, err := s.storage.Get(ctx, backup.Name)
+	if err != nil {
+		return Header{}, err
+	}
+	defer content.Close()
+	var compressed io.Reader = content
+	if backup.Encrypted {
+		compressed, err = s.cipher.Decrypt(content)
+		if err != nil {
+			return Header{}, err
+		}
+	}
+	gz, err := gzip.NewReader(compressed)
+	if err != nil {
+		return Header{}, fmt.Errorf("%w: not a compressed snapshot: %v", ErrIncompatibleBackup, err)
+	}
+	defer gz.Close()
+	return fn(ctx, gz)
+}
+
+func (s *ServiceImpl) RunScheduled(ctx context.Context, now time.Time) error {
+	if _, err := s.create(ctx, 0, now); err != nil {
+		return err
+	}
+	if s.keep <= 0 && s.keepDaily <= 0 && s.keepWeekly <= 0 {
+		return nil
+	}
+
+	backups, err := s.ListBackups(ctx)
+	if err != nil {
+		return err
+	}
+	var instanceBackups []Backup
+	for _, backup := range backups {
+		// Per-user backups are made on request, the one requesting decides when they go
+		if backup.UserId == 0 {
+			instanceBackups = append(instanceBackups, backup)
+		}
+	}
+	kept := s.retained(instanceBackups)
+	var errs []error
+	for _, backup := range instanceBackups {
+		if kept[backup.Name] {
+			continue
+		}
+		if err := s.storage.Delete(ctx, backup.Name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		log.Infof("Removed old backup %s", backup.Name)
+	}
+	return errors.Join(errs...)
+}
+
+// retained returns the names of the backups to keep out of the backups sorted from the newest: the newest keep
+// backups, and the newest backup of each of the keepDaily latest days and of each of the keepWeekly latest weeks.
+func (s *ServiceImpl) retained(backups []Backup) map[string]bool {
+	kept := make(map[string]bool)
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	for i, backup := range backups {
+		if i < s.keep {
+			kept[backup.Name] = true
+		}
+		day := backup.CreatedAt.Format(time.DateOnly)
+		if !days[day] && len(days) < s.keepDaily {
+			days[day] = true
+			kept[backup.Name] = true
+		}
+		year, week := backup.CreatedAt.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%d", year, week)
+		if !weeks[weekKey] && len(weeks) < s.keepWeekly {
+			weeks[weekKey] = true
+			kept[backup.Name] = true
+		}
+	}
+	return kept
+}
+
+func backupName(userId int, createdAt time.Time) string {
+	scope := "instance"
+	if userId != 0 {
+		scope = fmt.Sprintf("user-%d", userId)
+	}
+	return scope + "-" + createdAt.UTC().Format(nameTimeLayout) + fileSuffix + encryptedSuffix
+}
+
+func parseName(name string) (Backup, bool) {
+	match := namePattern.FindStringSubmatch(name)
+	if match == nil {
+		return Backup{}, false
+	}
+	createdAt, err := time.Parse(nameTimeLayout, match[3])
+	if err != nil {
+		return Backup{}, false
+	}
+	backup := Backup{Name: name, CreatedAt: createdAt, Encrypted: match[4] != ""}
+	if match[2] != "" {
+		backup.UserId, err = strconv.Atoi(match[2])
+		if err != nil || backup.UserId <= 0 {
+			return Backup{}, false
+		}
+	}
+	return backup, true
+}
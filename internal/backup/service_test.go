@@ -0,0 +1,276 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testEncryptionKey is a base64 encoded 32 byte key
+const testEncryptionKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+func setupServiceTest(t *testing.T, cfg config.Backup) (*ServiceImpl, *RepositoryStub, *LocalStorage) {
+	cfg.EncryptionKey = testEncryptionKey
+	repo := NewRepositoryStub()
+	storage := NewLocalStorage(t.TempDir())
+	cipher, err := NewCipher(cfg.EncryptionKey)
+	require.NoError(t, err)
+	service := NewService(repo, storage, cipher, cfg)
+	service.clock = &utils.MockClock{FixedNow: time.Date(2026, 10, 15, 3, 0, 0, 0, time.UTC)}
+	return service, repo, storage
+}
+
+func TestService_CreateAndRestoreBackup(t *testing.T) {
+	// given
+	ctx := context.Background()
+	service, repo, _ := setupServiceTest(t, config.Backup{Keep: 7})
+	repo.Data = []string{"first", "second"}
+
+	// when
+	backup, err := service.CreateBackup(ctx, 0)
+	require.NoError(t, err)
+	repo.Data = []string{"changed"}
+	restored, err := service.Restore(ctx, backup.Name)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "instance-20261015T030000Z.jsonl.gz.enc", backup.Name)
+	assert.Equal(t, 0, restored.UserId)
+	assert.Equal(t, []string{"first", "second"}, repo.Data)
+}
+
+func TestService_CreateUserBackup(t *testing.T) {
+	// given
+	ctx := context.Background()
+	service, repo, _ := setupServiceTest(t, config.Backup{Keep: 7})
+
+	// when
+	backup, err := service.CreateBackup(ctx, 12)
+	require.NoError(t, err)
+	restored, err := service.Restore(ctx, backup.Name)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "user-12-20261015T030000Z.jsonl.gz.enc", backup.Name)
+	assert.Equal(t, 12, restored.UserId)
+	require.Len(t, repo.Restored, 1)
+	assert.Equal(t, 12, repo.Restored[0].UserId)
+}
+
+func TestService_ListBackups(t *testing.T) {
+	// given
+	ctx := context.Background()
+	service, _, storage := setupServiceTest(t, config.Backup{Keep: 7})
+	require.NoError(t, storage.Put(ctx, "instance-20261013T030000Z.jsonl.gz", strings.NewReader("a")))
+	require.NoError(t, storage.Put(ctx, "user-3-20261014T120000Z.jsonl.gz", strings.NewReader("bb")))
+	require.NoError(t, storage.Put(ctx, "notes.jsonl.gz", strings.NewReader("ignored")))
+
+	// when
+	backups, err := service.ListBackups(ctx)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, []Backup{
+		{Name: "user-3-20261014T120000Z.jsonl.gz", UserId: 3, CreatedAt: time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC), Size: 2},
+		{Name: "instance-20261013T030000Z.jsonl.gz", CreatedAt: time.Date(2026, 10, 13, 3, 0, 0, 0, time.UTC), Size: 1},
+	}, backups)
+}
+
+func TestService_RunScheduledKeepsNewestInstanceBackups(t *testing.T) {
+	// given
+	ctx := context.Background()
+	service, _, storage := setupServiceTest(t, config.Backup{Keep: 2})
+	require.NoError(t, storage.Put(ctx, "instance-20261013T030000Z.jsonl.gz", strings.NewReader("a")))
+	require.NoError(t, storage.Put(ctx, "instance-20261014T030000Z.jsonl.gz", strings.NewReader("a")))
+	require.NoError(t, storage.Put(ctx, "user-3-20261001T120000Z.jsonl.gz", strings.NewReader("a")))
+
+	// when
+	err := service.RunScheduled(ctx, time.Date(2026, 10, 15, 3, 0, 0, 0, time.UTC))
+
+	// then
+	require.NoError(t, err)
+	backups, err := service.ListBackups(ctx)
+	require.NoError(t, err)
+	var names []string
+	for _, backup := range backups {
+		names = append(names, backup.Name)
+	}
+	assert.Equal(t, []string{
+		"instance-20261015T030000Z.jsonl.gz.enc",
+		"instance-20261014T030000Z.jsonl.gz",
+		"user-3-20261001T120000Z.jsonl.gz",
+	}, names)
+}
+
+func TestService_RunScheduledKeepsDailyAndWeeklyBackups(t *testing.T) {
+	// given
+	ctx := context.Background()
+	service, _, storage := setupServiceTest(t, config.Backup{Keep: 1, KeepDaily: 2, KeepWeekly: 2})
+	for _, name := range []string{
+		"instance-20261001T030000Z.jsonl.gz", // Thursday of the week before last
+		"instance-20261007T030000Z.jsonl.gz", // Wednesday of the last week
+		"instance-20261008T030000Z.jsonl.gz", // Thursday of the last week
+		"instance-20261014T030000Z.jsonl.gz",
+		"instance-20261014T150000Z.jsonl.gz",
+	} {
+		require.NoError(t, storage.Put(ctx, name, strings.NewReader("a")))
+	}
+
+	// when
+	err := service.RunScheduled(ctx, time.Date(2026, 10, 15, 3, 0, 0, 0, time.UTC))
+
+	// then
+	require.NoError(t, err)
+	backups, err := service.ListBackups(ctx)
+	require.NoError(t, err)
+	var names []string
+	for _, backup := range backups {
+		names = append(names, backup.Name)
+	}
+	assert.Equal(t, []string{
+		"instance-20261015T030000Z.jsonl.gz.enc",
+		"instance-20261014T150000Z.jsonl.gz",
+		"instance-20261008T030000Z.jsonl.gz",
+	}, names)
+}
+
+func TestService_EncryptedBackup(t *testing.T) {
+	// given
+	ctx := context.Background()
+	service, repo, storage := setupServiceTest(t, config.Backup{Keep: 7})
+	repo.Data = []string{"secret token"}
+
+	// when
+	backup, err := service.CreateBackup(ctx, 0)
+	require.NoError(t, err)
+	content, err := storage.Get(ctx, backup.Name)
+	require.NoError(t, err)
+	stored, err := io.ReadAll(content)
+	require.NoError(t, content.Close())
+	require.NoError(t, err)
+	repo.Data = []string{"changed"}
+	_, err = service.Restore(ctx, backup.Name)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "instance-20261015T030000Z.jsonl.gz.enc", backup.Name)
+	assert.True(t, backup.Encrypted)
+	assert.True(t, strings.HasPrefix(string(stored), encryptedMagic))
+	assert.Equal(t, []string{"secret token"}, repo.Data)
+
+	t.Run("cannot be restored without the key", func(t *testing.T) {
+		withoutKey := NewService(repo, storage, &Cipher{}, config.Backup{})
+		_, err := withoutKey.Restore(ctx, backup.Name)
+		assert.ErrorIs(t, err, ErrMissingKey)
+	})
+}
+
+func TestService_CreateBackupRequiresEncryptionKey(t *testing.T) {
+	// given
+	ctx := context.Background()
+	storage := NewLocalStorage(t.TempDir())
+	service := NewService(NewRepositoryStub(), storage, &Cipher{}, config.Backup{})
+
+	// when
+	_, err := service.CreateBackup(ctx, 0)
+
+	// then
+	assert.ErrorIs(t, err, ErrEncryptionRequired)
+	objects, err := storage.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+}
+
+func TestService_CreateBackupStoresNothingWhenDumpFails(t *testing.T) {
+	// given
+	ctx := context.Background()
+	service, repo, storage := setupServiceTest(t, config.Backup{Keep: 7})
+	repo.Data = []string{"first"}
+	repo.DumpErr = errors.New("connection lost")
+
+	// when
+	_, err := service.CreateBackup(ctx, 0)
+
+	// then
+	assert.ErrorIs(t, err, repo.DumpErr)
+	objects, err := storage.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+}
+
+func TestService_Verify(t *testing.T) {
+	// given
+	ctx := context.Background()
+	service, repo, storage := setupServiceTest(t, config.Backup{Keep: 7})
+	repo.Data = []string{"first"}
+	backup, err := service.CreateBackup(ctx, 3)
+	require.NoError(t, err)
+	repo.Data = []string{"changed"}
+
+	// when
+	verified, err := service.Verify(ctx, backup.Name)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, 3, verified.UserId)
+	assert.Equal(t, []string{"changed"}, repo.Data)
+	assert.Empty(t, repo.Restored)
+
+	t.Run("fails for a corrupted backup", func(t *testing.T) {
+		require.NoError(t, storage.Put(ctx, "instance-20261001T030000Z.jsonl.gz", strings.NewReader("not gzip")))
+		_, err := service.Verify(ctx, "instance-20261001T030000Z.jsonl.gz")
+		assert.ErrorIs(t, err, ErrIncompatibleBackup)
+	})
+}
+
+func TestService_OpenBackup(t *testing.T) {
+	// given
+	ctx := context.Background()
+	service, _, _ := setupServiceTest(t, config.Backup{Keep: 7})
+	backup, err := service.CreateBackup(ctx, 0)
+	require.NoError(t, err)
+
+	// when
+	content, err := service.OpenBackup(ctx, backup.Name)
+	require.NoError(t, err)
+	defer content.Close()
+	data, err := io.ReadAll(content)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, backup.Size, int64(len(data)))
+}
+
+func TestService_RejectsNamesOutsideOfStorage(t *testing.T) {
+	// given
+	ctx := context.Background()
+	service, _, _ := setupServiceTest(t, config.Backup{Keep: 7})
+
+	// when
+	_, openErr := service.OpenBackup(ctx, "../config/application.yaml")
+	_, restoreErr := service.Restore(ctx, "../instance-20261015T030000Z.jsonl.gz")
+
+	// then
+	assert.ErrorIs(t, openErr, ErrInvalidBackupName)
+	assert.ErrorIs(t, restoreErr, ErrInvalidBackupName)
+}
+
+func TestService_RestoreMissingBackup(t *testing.T) {
+	// given
+	ctx := context.Background()
+	service, _, _ := setupServiceTest(t, config.Backup{Keep: 7})
+
+	// when
+	_, err := service.Restore(ctx, "instance-20261015T030000Z.jsonl.gz")
+
+	// then
+	assert.ErrorIs(t, err, ErrBackupNotFound)
+}
@@ -0,0 +1,141 @@
+package backup
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// formatVersion is the version of the layout of the snapshot files, not of the database schema.
+const formatVersion = 1
+
+var ErrIncompatibleBackup = errors.New("incompatible backup")
+
+// Header opens a snapshot. The snapshot is JSON lines: the header, then for every table a tableHeader followed by
+// its rows, each row a JSON object of its columns.
+type Header struct {
+	Format    int       `json:"format"`
+	CreatedAt time.Time `json:"createdAt"`
+	// SchemaVersion is the migration the database was at, a snapshot is only restored to the same version
+	SchemaVersion uint `json:"schemaVersion"`
+	// UserId is the user of a per-user snapshot, 0 for a snapshot of the whole instance
+	UserId int `json:"userId,omitempty"`
+}
+
+type tableHeader struct {
+	Table string `json:"table"`
+	Rows  int    `json:"rows"`
+}
+
+type snapshotWriter struct {
+	w *bufio.Writer
+}
+
+func newSnapshotWriter(w io.Writer, header Header) (*snapshotWriter, error) {
+	sw := &snapshotWriter{w: bufio.NewWriter(w)}
+	header.Format = formatVersion
+	if err := sw.writeJson(header); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+func (sw *snapshotWriter) startTable(table string, rows int) error {
+	return sw.writeJson(tableHeader{Table: table, Rows: rows})
+}
+
+func (sw *snapshotWriter) writeRow(row []byte) error {
+	if _, err := sw.w.Write(row); err != nil {
+		return err
+	}
+	return sw.w.WriteByte('\n')
+}
+
+func (sw *snapshotWriter) flush() error {
+	return sw.w.Flush()
+}
+
+func (sw *snapshotWriter) writeJson(value any) error {
+	line, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return sw.writeRow(line)
+}
+
+type snapshotReader struct {
+	r *bufio.Reader
+}
+
+func newSnapshotReader(r io.Reader) (*snapshotReader, Header, error) {
+	sr := &snapshotReader{r: bufio.NewReader(r)}
+	var header Header
+	if err := sr.readJson(&header); err != nil {
+		return nil, Header{}, fmt.Errorf("%w: failed to read header: %v", ErrIncompatibleBackup, err)
+	}
+	if header.Format != formatVersion {
+		return nil, Header{}, fmt.Errorf("%w: unknown format %d", ErrIncompatibleBackup, header.Format)
+	}
+	return sr, header, nil
+}
+
+// nextTable starts reading the rows of the next table, it returns io.EOF after the last table.
+func (sr *snapshotReader) nextTable() (*tableReader, error) {
+	var th tableHeader
+	if err := sr.readJson(&th); err != nil {
+		return nil, err
+	}
+	return &tableReader{sr: sr, table: th.Table, rows: th.Rows}, nil
+}
+
+// tableReader reads the rows of a table in batches, so a large table is never held in memory at once.
+type tableReader struct {
+	sr    *snapshotReader
+	table string
+	rows  int
+	read  int
+}
+
+// nextBatch returns up to size of the remaining rows as a JSON array, it returns io.EOF once all rows are read.
+func (tr *tableReader) nextBatch(size int) ([]byte, error) {
+	if tr.read == tr.rows {
+		return nil, io.EOF
+	}
+	batch := make([]byte, 0, 1024)
+	batch = append(batch, '[')
+	for i := 0; i < size && tr.read < tr.rows; i++ {
+		row, err := tr.sr.readLine()
+		if err != nil {
+			return nil, fmt.Errorf("%w: table %s ends after %d of %d rows", ErrIncompatibleBackup, tr.table, tr.read,
+				tr.rows)
+		}
+		if i > 0 {
+			batch = append(batch, ',')
+		}
+		batch = append(batch, row...)
+		tr.read++
+	}
+	return append(batch, ']'), nil
+}
+
+func (sr *snapshotReader) readJson(value any) error {
+	line, err := sr.readLine()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(line, value)
+}
+
+func (sr *snapshotReader) readLine() ([]byte, error) {
+	line, err := sr.r.ReadBytes('\n')
+	if errors.Is(err, io.EOF) && len(line) > 0 {
+		return line, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return line[:len(line)-1], nil
+}
@@ -0,0 +1,88 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	// given
+	var buf bytes.Buffer
+	createdAt := time.Date(2026, 10, 15, 3, 0, 0, 0, time.UTC)
+	sw, err := newSnapshotWriter(&buf, Header{CreatedAt: createdAt, SchemaVersion: 52, UserId: 7})
+	require.NoError(t, err)
+	require.NoError(t, sw.startTable("users", 1))
+	require.NoError(t, sw.writeRow([]byte(`{"id":7,"username":"jane"}`)))
+	require.NoError(t, sw.startTable("empty", 0))
+	require.NoError(t, sw.startTable("tag", 2))
+	require.NoError(t, sw.writeRow([]byte(`{"id":1}`)))
+	require.NoError(t, sw.writeRow([]byte(`{"id":2}`)))
+	require.NoError(t, sw.flush())
+
+	// when
+	sr, header, err := newSnapshotReader(&buf)
+	require.NoError(t, err)
+	var tables []string
+	var batches [][]string
+	for {
+		tr, err := sr.nextTable()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		tables = append(tables, tr.table)
+		var tableBatches []string
+		for {
+			batch, err := tr.nextBatch(1)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			tableBatches = append(tableBatches, string(batch))
+		}
+		batches = append(batches, tableBatches)
+	}
+
+	// then
+	assert.Equal(t, Header{Format: formatVersion, CreatedAt: createdAt, SchemaVersion: 52, UserId: 7}, header)
+	assert.Equal(t, []string{"users", "empty", "tag"}, tables)
+	assert.Equal(t, [][]string{{`[{"id":7,"username":"jane"}]`}, nil, {`[{"id":1}]`, `[{"id":2}]`}}, batches)
+}
+
+func TestSnapshot_UnknownFormat(t *testing.T) {
+	// given
+	content := bytes.NewBufferString(`{"format":2,"createdAt":"2026-10-15T03:00:00Z","schemaVersion":52}` + "\n")
+
+	// when
+	_, _, err := newSnapshotReader(content)
+
+	// then
+	assert.ErrorIs(t, err, ErrIncompatibleBackup)
+}
+
+func TestSnapshot_TruncatedTable(t *testing.T) {
+	// given
+	var buf bytes.Buffer
+	sw, err := newSnapshotWriter(&buf, Header{SchemaVersion: 52})
+	require.NoError(t, err)
+	require.NoError(t, sw.startTable("tag", 2))
+	require.NoError(t, sw.writeRow([]byte(`{"id":1}`)))
+	require.NoError(t, sw.flush())
+	sr, _, err := newSnapshotReader(&buf)
+	require.NoError(t, err)
+
+	tr, err := sr.nextTable()
+	require.NoError(t, err)
+
+	// when
+	_, err = tr.nextBatch(10)
+
+	// then
+	assert.ErrorIs(t, err, ErrIncompatibleBackup)
+}
@@ -0,0 +1,113 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrBackupNotFound = errors.New("backup not found")
+
+const fileSuffix = ".jsonl.gz"
+
+// encryptedSuffix follows fileSuffix in the names of encrypted backups.
+const encryptedSuffix = ".enc"
+
+func isBackupFile(name string) bool {
+	return strings.HasSuffix(name, fileSuffix) || strings.HasSuffix(name, fileSuffix+encryptedSuffix)
+}
+
+// Object is a stored backup file.
+type Object struct {
+	Name       string
+	Size       int64
+	ModifiedAt time.Time
+}
+
+// Storage keeps the backup files, names are validated by the service before they reach the storage.
+type Storage interface {
+	// Put stores what is read from r, a backup is stored only when r ends without an error.
+	Put(ctx context.Context, name string, r io.Reader) error
+	// Get returns ErrBackupNotFound when there is no backup of the name.
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	List(ctx context.Context) ([]Object, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// LocalStorage keeps the backups in a directory, e.g. on a volume of the container.
+type LocalStorage struct {
+	dir string
+}
+
+func NewLocalStorage(dir string) *LocalStorage {
+	return &LocalStorage{dir: dir}
+}
+
+func (s *LocalStorage) Put(ctx context.Context, name string, r io.Reader) error {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	// Written aside and renamed, so a backup interrupted half way never looks complete
+	tmp, err := os.CreateTemp(s.dir, "."+name+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, name)); err != nil {
+		return fmt.Errorf("failed to store backup file: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalStorage) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	file, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrBackupNotFound
+		}
+		return nil, fmt.Errorf("failed to open backup file: %w", err)
+	}
+	return file, nil
+}
+
+func (s *LocalStorage) List(ctx context.Context) ([]Object, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Object{}, nil
+		}
+		return nil, fmt.Errorf("failed to list backup directory: %w", err)
+	}
+	objects := make([]Object, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || !isBackupFile(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// Deleted meanwhile
+			continue
+		}
+		objects = append(objects, Object{Name: entry.Name(), Size: info.Size(), ModifiedAt: info.ModTime()})
+	}
+	return objects, nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, name string) error {
+	if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete backup file: %w", err)
+	}
+	return nil
+}
@@ -154,6 +154,44 @@ func TestClient_StartEvent(t *testing.T) {
 	assert.Equal(t, "Work", event.PlanItem.Name)
 }
 
+func TestClient_StopEvent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/event/current", r.URL.Path)
+		assert.Equal(t, http.MethodDelete, r.Method)
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(CurrentEventDTO{
+			PlanItem:  PlanItemDTO{BudgetItemID: 5, Name: "Work"},
+			StartTime: "2026-04-05T09:00:00Z",
+		})
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, "tok", "")
+	event, err := client.StopEvent()
+	require.NoError(t, err)
+	assert.Equal(t, 5, event.PlanItem.BudgetItemID)
+}
+
+func TestClient_SwitchBack(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/event/current/switch-back", r.URL.Path)
+		assert.Equal(t, http.MethodPost, r.Method)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(CurrentEventDTO{
+			PlanItem:  PlanItemDTO{BudgetItemID: 3, Name: "Reading"},
+			StartTime: "2026-04-05T10:00:00Z",
+		})
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, "tok", "")
+	event, err := client.SwitchBack()
+	require.NoError(t, err)
+	assert.Equal(t, "Reading", event.PlanItem.Name)
+}
+
 func TestNewClientNoAuth(t *testing.T) {
 	var gotAuth, gotUserID string
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
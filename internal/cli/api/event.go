@@ -2,6 +2,7 @@ package api
 
 import (
 	"fmt"
+	"net/http"
 	"net/url"
 )
 
@@ -27,6 +28,28 @@ func (c *Client) GetCurrentEvent() (*CurrentEventDTO, error) {
 	return &event, nil
 }
 
+// StopEvent stops the current event without starting another one and returns the stopped event.
+func (c *Client) StopEvent() (*CurrentEventDTO, error) {
+	req, err := c.newRequest(http.MethodDelete, "/api/event/current", nil)
+	if err != nil {
+		return nil, err
+	}
+	var event CurrentEventDTO
+	if err := c.do(req, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// SwitchBack stops the current event and starts tracking the previously tracked budget item again.
+func (c *Client) SwitchBack() (*CurrentEventDTO, error) {
+	var event CurrentEventDTO
+	if err := c.Post("/api/event/current/switch-back", nil, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
 func (c *Client) AdjustCurrentEventStart(startTime string) (*CurrentEventDTO, error) {
 	body, err := jsonBody(AdjustStartRequest{StartTime: startTime})
 	if err != nil {
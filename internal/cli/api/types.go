@@ -24,6 +24,19 @@ type SettingsDTO struct {
 	EventCalendarType string                    `json:"eventCalendarType"`
 	GoogleCalendar    GoogleCalendarSettingsDTO `json:"googleCalendar"`
 	IgnoreShortEvents bool                      `json:"ignoreShortEvents"`
+	// ShortEventThreshold in seconds
+	ShortEventThreshold     int                  `json:"shortEventThreshold"`
+	ShortEventHandling      string               `json:"shortEventHandling"`
+	StrictCalendar          bool                 `json:"strictCalendar"`
+	DiscardIdleTime         bool                 `json:"discardIdleTime"`
+	KeepCrossMidnightEvents bool                 `json:"keepCrossMidnightEvents"`
+	EventSummaryTemplate    string               `json:"eventSummaryTemplate"`
+	RenamePropagation       RenamePropagationDTO `json:"renamePropagation"`
+}
+
+type RenamePropagationDTO struct {
+	RewritePastWeeklyPlans bool `json:"rewritePastWeeklyPlans"`
+	RewritePastEvents      bool `json:"rewritePastEvents"`
 }
 
 type GoogleCalendarSettingsDTO struct {
@@ -46,6 +59,9 @@ type BudgetItemDTO struct {
 	WeeklyOccurrences int    `json:"weeklyOccurrences,omitempty"`
 	Icon              string `json:"icon,omitempty"`
 	Color             string `json:"color,omitempty"`
+	Rollover          bool   `json:"rollover,omitempty"`
+	Unit              string `json:"unit,omitempty"`
+	HourlyRate        int    `json:"hourlyRate,omitempty"`
 }
 
 type SetItemPositionRequest struct {
@@ -58,9 +74,15 @@ type SetItemPositionRequest struct {
 type WeeklyPlanDTO struct {
 	BudgetPlanID int                 `json:"budgetPlanId"`
 	IsOffWeek    bool                `json:"isOffWeek"`
+	LockedAt     string              `json:"lockedAt,omitempty"`
 	Items        []WeeklyPlanItemDTO `json:"items"`
 }
 
+type WeeklyPlanWeekDTO struct {
+	WeekNumber string `json:"weekNumber"`
+	WeeklyPlanDTO
+}
+
 type WeeklyPlanItemDTO struct {
 	ID                int    `json:"id"`
 	BudgetItemID      int    `json:"budgetItemId"`
@@ -71,6 +93,25 @@ type WeeklyPlanItemDTO struct {
 	Color             string `json:"color"`
 	Notes             string `json:"notes"`
 	Position          int    `json:"position"`
+	CarriedOver       *int   `json:"carriedOver,omitempty"`
+	AdHoc             bool   `json:"adHoc,omitempty"`
+	// DailyDurations maps lowercase weekday names to seconds
+	DailyDurations           map[string]int `json:"dailyDurations,omitempty"`
+	DailyDurationsCustomized bool           `json:"dailyDurationsCustomized,omitempty"`
+}
+
+type SetDailyDurationsRequest struct {
+	BudgetItemID   int            `json:"budgetItemId"`
+	DailyDurations map[string]int `json:"dailyDurations"`
+}
+
+type AddAdHocItemRequest struct {
+	Name              string `json:"name"`
+	WeeklyDuration    int    `json:"weeklyDuration"`
+	WeeklyOccurrences int    `json:"weeklyOccurrences"`
+	Icon              string `json:"icon,omitempty"`
+	Color             string `json:"color,omitempty"`
+	Notes             string `json:"notes"`
 }
 
 type UpdateWeeklyItemRequest struct {
@@ -80,10 +121,21 @@ type UpdateWeeklyItemRequest struct {
 	Notes          string `json:"notes"`
 }
 
+type MoveWeeklyItemRequest struct {
+	BudgetItemID          int `json:"budgetItemId"`
+	PrecedingBudgetItemID int `json:"precedingBudgetItemId"`
+}
+
 type SetOffWeekRequest struct {
 	IsOffWeek bool `json:"isOffWeek"`
 }
 
+type CopyWeekRequest struct {
+	SourceDate string `json:"sourceDate"`
+	TargetDate string `json:"targetDate"`
+	Overwrite  bool   `json:"overwrite"`
+}
+
 // --- Current Event ---
 
 type CurrentEventDTO struct {
@@ -139,6 +191,7 @@ type PlanItemStatsDTO struct {
 	PlanItem  StatsPlanItemDTO `json:"planItem"`
 	Duration  int              `json:"duration"`
 	Remaining int              `json:"remaining"`
+	Sessions  int              `json:"sessions"`
 	StartDate string           `json:"startDate"`
 	EndDate   string           `json:"endDate"`
 }
@@ -154,6 +207,7 @@ type StatsPlanItemDTO struct {
 	WeeklyItemDuration int    `json:"weeklyItemDuration"`
 	BudgetItemDuration int    `json:"budgetItemDuration"`
 	WeeklyOccurrences  int    `json:"weeklyOccurrences"`
+	Unit               string `json:"unit,omitempty"`
 	Notes              string `json:"notes"`
 }
 
@@ -280,6 +334,8 @@ type WebhookDTO struct {
 	Token      string          `json:"token"`
 	WebhookURL string          `json:"webhookUrl"`
 	Data       json.RawMessage `json:"data"`
+	// PreviousTokenExpiresAt is set while the token replaced by the last rotation still works.
+	PreviousTokenExpiresAt string `json:"previousTokenExpiresAt,omitempty"`
 }
 
 type CreateWebhookRequest struct {
@@ -291,4 +347,5 @@ type WebhookTriggerResponse struct {
 	Success   bool   `json:"success"`
 	Message   string `json:"message"`
 	Timestamp string `json:"timestamp"`
+	Warning   string `json:"warning,omitempty"`
 }
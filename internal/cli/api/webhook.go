@@ -3,6 +3,7 @@ package api
 import (
 	"fmt"
 	"net/url"
+	"strconv"
 )
 
 func (c *Client) ListWebhooks(webhookType string) ([]WebhookDTO, error) {
@@ -33,6 +34,20 @@ func (c *Client) DeleteWebhook(id int) error {
 	return c.Delete(fmt.Sprintf("/api/webhook/%d", id))
 }
 
+// RotateWebhook issues a new webhook token. The old token keeps working for overlapHours,
+// or for the server default when overlapHours is negative.
+func (c *Client) RotateWebhook(id int, overlapHours int) (*WebhookDTO, error) {
+	path := fmt.Sprintf("/api/webhook/%d/rotate", id)
+	if overlapHours >= 0 {
+		path += "?overlapHours=" + strconv.Itoa(overlapHours)
+	}
+	var webhook WebhookDTO
+	if err := c.Post(path, nil, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
 // TriggerWebhook calls the webhook endpoint (no auth required).
 func (c *Client) TriggerWebhook(token string) (*WebhookTriggerResponse, error) {
 	var resp WebhookTriggerResponse
@@ -13,6 +13,14 @@ func (c *Client) GetWeeklyPlan(date string) (*WeeklyPlanDTO, error) {
 	return &plan, nil
 }
 
+func (c *Client) GetWeeklyPlanRange(from, to string) ([]WeeklyPlanWeekDTO, error) {
+	var weeks []WeeklyPlanWeekDTO
+	if err := c.Get("/api/weeklyplan/range?from="+url.QueryEscape(from)+"&to="+url.QueryEscape(to), &weeks); err != nil {
+		return nil, err
+	}
+	return weeks, nil
+}
+
 func (c *Client) ResetWeeklyPlan(date string) (*WeeklyPlanDTO, error) {
 	var plan WeeklyPlanDTO
 	req, err := c.newRequest("DELETE", "/api/weeklyplan?date="+url.QueryEscape(date), nil)
@@ -37,6 +45,38 @@ func (c *Client) SetOffWeek(date string, isOff bool) (*WeeklyPlanDTO, error) {
 	return &plan, nil
 }
 
+func (c *Client) CopyWeeklyPlan(r CopyWeekRequest) (*WeeklyPlanDTO, error) {
+	body, err := jsonBody(r)
+	if err != nil {
+		return nil, err
+	}
+	var plan WeeklyPlanDTO
+	if err := c.Post("/api/weeklyplan/copy", body, &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (c *Client) LockWeek(date string) (*WeeklyPlanDTO, error) {
+	var plan WeeklyPlanDTO
+	if err := c.Put("/api/weeklyplan/lock?date="+url.QueryEscape(date), nil, &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (c *Client) UnlockWeek(date string) (*WeeklyPlanDTO, error) {
+	var plan WeeklyPlanDTO
+	req, err := c.newRequest("DELETE", "/api/weeklyplan/lock?date="+url.QueryEscape(date), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.do(req, &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
 func (c *Client) UpdateWeeklyItem(date string, r UpdateWeeklyItemRequest) (*WeeklyPlanItemDTO, error) {
 	body, err := jsonBody(r)
 	if err != nil {
@@ -49,6 +89,46 @@ func (c *Client) UpdateWeeklyItem(date string, r UpdateWeeklyItemRequest) (*Week
 	return &item, nil
 }
 
+func (c *Client) ReorderWeeklyItem(date string, r MoveWeeklyItemRequest) (*WeeklyPlanDTO, error) {
+	body, err := jsonBody(r)
+	if err != nil {
+		return nil, err
+	}
+	var plan WeeklyPlanDTO
+	if err := c.Put("/api/weeklyplan/item/position?date="+url.QueryEscape(date), body, &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (c *Client) SetWeeklyItemDailyDurations(date string, r SetDailyDurationsRequest) (*WeeklyPlanItemDTO, error) {
+	body, err := jsonBody(r)
+	if err != nil {
+		return nil, err
+	}
+	var item WeeklyPlanItemDTO
+	if err := c.Put("/api/weeklyplan/item/daily-durations?date="+url.QueryEscape(date), body, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (c *Client) AddAdHocItem(date string, r AddAdHocItemRequest) (*WeeklyPlanItemDTO, error) {
+	body, err := jsonBody(r)
+	if err != nil {
+		return nil, err
+	}
+	var item WeeklyPlanItemDTO
+	if err := c.Post("/api/weeklyplan/ad-hoc-item?date="+url.QueryEscape(date), body, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (c *Client) DeleteAdHocItem(itemID int) error {
+	return c.Delete(fmt.Sprintf("/api/weeklyplan/ad-hoc-item/%d", itemID))
+}
+
 func (c *Client) ResetWeeklyItem(itemID int) (*WeeklyPlanItemDTO, error) {
 	var item WeeklyPlanItemDTO
 	req, err := c.newRequest("DELETE", fmt.Sprintf("/api/weeklyplan/item/%d", itemID), nil)
@@ -208,6 +208,8 @@ func newBudgetItemCreateCmd() *cobra.Command {
 		occurrences int
 		icon        string
 		color       string
+		rollover    bool
+		unit        string
 	)
 	cmd := &cobra.Command{
 		Use:   "create <planId>",
@@ -221,12 +223,15 @@ func newBudgetItemCreateCmd() *cobra.Command {
 			if name == "" {
 				return fmt.Errorf("--name is required")
 			}
-			if duration == "" {
+			if duration == "" && unit != "sessions" {
 				return fmt.Errorf("--duration is required")
 			}
-			dur, err := parseDuration(duration)
-			if err != nil {
-				return err
+			dur := 0
+			if duration != "" {
+				dur, err = parseDuration(duration)
+				if err != nil {
+					return err
+				}
 			}
 			client, err := newAPIClient()
 			if err != nil {
@@ -238,6 +243,8 @@ func newBudgetItemCreateCmd() *cobra.Command {
 				WeeklyOccurrences: occurrences,
 				Icon:              icon,
 				Color:             color,
+				Rollover:          rollover,
+				Unit:              unit,
 			})
 			if err != nil {
 				return err
@@ -248,10 +255,12 @@ func newBudgetItemCreateCmd() *cobra.Command {
 		},
 	}
 	cmd.Flags().StringVar(&name, "name", "", "Item name (required)")
-	cmd.Flags().StringVar(&duration, "duration", "", "Weekly duration, e.g. 8h or 28800 (required)")
-	cmd.Flags().IntVar(&occurrences, "occurrences", 0, "Days per week")
-	cmd.Flags().StringVar(&icon, "icon", "", "Icon")
+	cmd.Flags().StringVar(&duration, "duration", "", "Weekly duration, e.g. 8h or 28800 (required unless planned in sessions)")
+	cmd.Flags().IntVar(&occurrences, "occurrences", 0, "Days per week, or sessions per week for --unit sessions")
+	cmd.Flags().StringVar(&icon, "icon", "", "Icon name, e.g. work, book or run")
 	cmd.Flags().StringVar(&color, "color", "", "Color hex code")
+	cmd.Flags().BoolVar(&rollover, "rollover", false, "Carry unused (or overspent) time over to the next week")
+	cmd.Flags().StringVar(&unit, "unit", "", "Plan the item in duration (default) or sessions")
 	return cmd
 }
 
@@ -262,6 +271,8 @@ func newBudgetItemUpdateCmd() *cobra.Command {
 		occurrences int
 		icon        string
 		color       string
+		rollover    bool
+		unit        string
 	)
 	cmd := &cobra.Command{
 		Use:   "update <planId> <itemId>",
@@ -314,6 +325,12 @@ func newBudgetItemUpdateCmd() *cobra.Command {
 			if cmd.Flags().Changed("color") {
 				current.Color = color
 			}
+			if cmd.Flags().Changed("rollover") {
+				current.Rollover = rollover
+			}
+			if cmd.Flags().Changed("unit") {
+				current.Unit = unit
+			}
 			updated, err := client.UpdateBudgetItem(planID, *current)
 			if err != nil {
 				return err
@@ -325,9 +342,11 @@ func newBudgetItemUpdateCmd() *cobra.Command {
 	}
 	cmd.Flags().StringVar(&name, "name", "", "Item name")
 	cmd.Flags().StringVar(&duration, "duration", "", "Weekly duration, e.g. 8h or 28800")
-	cmd.Flags().IntVar(&occurrences, "occurrences", 0, "Days per week")
-	cmd.Flags().StringVar(&icon, "icon", "", "Icon")
+	cmd.Flags().IntVar(&occurrences, "occurrences", 0, "Days per week, or sessions per week for --unit sessions")
+	cmd.Flags().StringVar(&icon, "icon", "", "Icon name, e.g. work, book or run")
 	cmd.Flags().StringVar(&color, "color", "", "Color hex code")
+	cmd.Flags().BoolVar(&rollover, "rollover", false, "Carry unused (or overspent) time over to the next week")
+	cmd.Flags().StringVar(&unit, "unit", "", "Plan the item in duration (default) or sessions")
 	return cmd
 }
 
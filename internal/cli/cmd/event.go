@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/klokku/klokku/internal/cli/api"
 	"github.com/klokku/klokku/internal/cli/output"
@@ -16,6 +17,8 @@ func newEventCmd() *cobra.Command {
 	}
 
 	eventCmd.AddCommand(newEventStartCmd())
+	eventCmd.AddCommand(newEventStopCmd())
+	eventCmd.AddCommand(newEventSwitchCmd())
 	eventCmd.AddCommand(newEventCurrentCmd())
 	eventCmd.AddCommand(newEventListCmd())
 	eventCmd.AddCommand(newEventRecentCmd())
@@ -28,59 +31,118 @@ func newEventCmd() *cobra.Command {
 
 func newEventStartCmd() *cobra.Command {
 	return &cobra.Command{
-		Use:   "start <budgetItemId>",
+		Use:   "start <budgetItem>",
 		Short: "Start tracking time for a budget item",
-		Long: `Start tracking time for a budget item. The budget item's name and weekly duration
-are automatically looked up from the current budget plan.`,
+		Long: `Start tracking time for a budget item, given by its ID or name. The budget item's name and
+weekly duration are automatically looked up from the current budget plan. The event tracked
+so far is stopped and saved to the calendar.`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			budgetItemID, err := strconv.Atoi(args[0])
+			return startTracking(args[0])
+		},
+	}
+}
+
+func newEventStopCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "stop",
+		Short: "Stop tracking time",
+		Long:  "Stop the current event without starting another one. It is saved to the calendar.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := newAPIClient()
 			if err != nil {
-				return fmt.Errorf("invalid budget item ID: %s", args[0])
+				return err
 			}
-			client, err := newAPIClient()
+			event, err := client.StopEvent()
 			if err != nil {
 				return err
 			}
+			return output.Print(outputFormat, event, func() {
+				fmt.Printf("Stopped: %s (budget item %d) started at %s\n",
+					event.PlanItem.Name, event.PlanItem.BudgetItemID, event.StartTime)
+			})
+		},
+	}
+}
 
-			// Look up the budget item to get name and weeklyDuration
-			// (the server stores these on the current event)
-			plans, err := client.ListBudgetPlans()
-			if err != nil {
-				return fmt.Errorf("failed to look up budget plans: %w", err)
+func newEventSwitchCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "switch [budgetItem]",
+		Short: "Switch tracking to another budget item",
+		Long: `Switch tracking to another budget item, given by its ID or name, like start does.
+Without a budget item it switches back to the previously tracked one.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				return startTracking(args[0])
 			}
-			var itemName string
-			var weeklyDuration int
-			for _, plan := range plans {
-				if !plan.IsCurrent {
-					continue
-				}
-				fullPlan, err := client.GetBudgetPlan(plan.ID)
-				if err != nil {
-					return fmt.Errorf("failed to look up budget plan: %w", err)
-				}
-				for _, item := range fullPlan.Items {
-					if item.ID == budgetItemID {
-						itemName = item.Name
-						weeklyDuration = item.WeeklyDuration
-						break
-					}
-				}
-				break
+			client, err := newAPIClient()
+			if err != nil {
+				return err
 			}
-
-			event, err := client.StartEvent(budgetItemID, itemName, weeklyDuration)
+			event, err := client.SwitchBack()
 			if err != nil {
 				return err
 			}
 			return output.Print(outputFormat, event, func() {
-				fmt.Printf("Started: %s (budget item %d) at %s\n",
+				fmt.Printf("Switched back to: %s (budget item %d) at %s\n",
 					event.PlanItem.Name, event.PlanItem.BudgetItemID, event.StartTime)
 			})
 		},
 	}
 }
 
+func startTracking(budgetItem string) error {
+	client, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+	// The server stores the name and weeklyDuration on the current event
+	item, err := findCurrentBudgetItem(client, budgetItem)
+	if err != nil {
+		return err
+	}
+
+	event, err := client.StartEvent(item.ID, item.Name, item.WeeklyDuration)
+	if err != nil {
+		return err
+	}
+	return output.Print(outputFormat, event, func() {
+		fmt.Printf("Started: %s (budget item %d) at %s\n",
+			event.PlanItem.Name, event.PlanItem.BudgetItemID, event.StartTime)
+	})
+}
+
+// findCurrentBudgetItem looks up the item of the current budget plan by its ID or, case-insensitively, by its name.
+// An ID that is not in the current plan is returned without name and weekly duration.
+func findCurrentBudgetItem(client *api.Client, budgetItem string) (api.BudgetItemDTO, error) {
+	budgetItemID, idErr := strconv.Atoi(budgetItem)
+	plans, err := client.ListBudgetPlans()
+	if err != nil {
+		return api.BudgetItemDTO{}, fmt.Errorf("failed to look up budget plans: %w", err)
+	}
+	for _, plan := range plans {
+		if !plan.IsCurrent {
+			continue
+		}
+		fullPlan, err := client.GetBudgetPlan(plan.ID)
+		if err != nil {
+			return api.BudgetItemDTO{}, fmt.Errorf("failed to look up budget plan: %w", err)
+		}
+		for _, item := range fullPlan.Items {
+			if (idErr == nil && item.ID == budgetItemID) || (idErr != nil && strings.EqualFold(item.Name, budgetItem)) {
+				return item, nil
+			}
+		}
+		break
+	}
+	if idErr != nil {
+		return api.BudgetItemDTO{}, fmt.Errorf("no budget item named %q in the current budget plan", budgetItem)
+	}
+	return api.BudgetItemDTO{ID: budgetItemID}, nil
+}
+
 func newEventCurrentCmd() *cobra.Command {
 	currentCmd := &cobra.Command{
 		Use:   "current",
@@ -47,6 +47,14 @@ func newStatsWeeklyCmd() *cobra.Command {
 					headers := []string{"NAME", "TRACKED", "REMAINING"}
 					rows := make([][]string, 0, len(stats.PerPlanItem))
 					for _, item := range stats.PerPlanItem {
+						if item.PlanItem.Unit == "sessions" {
+							rows = append(rows, []string{
+								item.PlanItem.Name,
+								fmt.Sprintf("%d sessions", item.Sessions),
+								fmt.Sprintf("%d sessions", item.PlanItem.WeeklyOccurrences-item.Sessions),
+							})
+							continue
+						}
 						rows = append(rows, []string{
 							item.PlanItem.Name,
 							formatDuration(item.Duration),
@@ -20,6 +20,7 @@ func newWebhookCmd() *cobra.Command {
 	webhookCmd.AddCommand(newWebhookListCmd())
 	webhookCmd.AddCommand(newWebhookCreateCmd())
 	webhookCmd.AddCommand(newWebhookDeleteCmd())
+	webhookCmd.AddCommand(newWebhookRotateCmd())
 	webhookCmd.AddCommand(newWebhookTriggerCmd())
 
 	return webhookCmd
@@ -120,6 +121,38 @@ func newWebhookDeleteCmd() *cobra.Command {
 	}
 }
 
+func newWebhookRotateCmd() *cobra.Command {
+	var overlapHours int
+	cmd := &cobra.Command{
+		Use:   "rotate <id>",
+		Short: "Issue a new webhook token, keeping the old one valid for a while",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid webhook ID: %s", args[0])
+			}
+			client, err := newAPIClient()
+			if err != nil {
+				return err
+			}
+			webhook, err := client.RotateWebhook(id, overlapHours)
+			if err != nil {
+				return err
+			}
+			return output.Print(outputFormat, webhook, func() {
+				fmt.Printf("Rotated webhook %d (new token: %s)\n", webhook.ID, webhook.Token)
+				fmt.Printf("URL: %s\n", webhook.WebhookURL)
+				if webhook.PreviousTokenExpiresAt != "" {
+					fmt.Printf("The old token works until %s\n", webhook.PreviousTokenExpiresAt)
+				}
+			})
+		},
+	}
+	cmd.Flags().IntVar(&overlapHours, "overlap-hours", -1, "Hours the old token keeps working (default: server setting)")
+	return cmd
+}
+
 func newWebhookTriggerCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "trigger <token>",
@@ -146,6 +179,9 @@ func newWebhookTriggerCmd() *cobra.Command {
 				} else {
 					fmt.Printf("Webhook trigger failed: %s\n", resp.Message)
 				}
+				if resp.Warning != "" {
+					fmt.Printf("Warning: %s\n", resp.Warning)
+				}
 			})
 		},
 	}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/klokku/klokku/internal/cli/api"
@@ -17,8 +18,12 @@ func newWeekCmd() *cobra.Command {
 	}
 
 	weekCmd.AddCommand(newWeekGetCmd())
+	weekCmd.AddCommand(newWeekRangeCmd())
 	weekCmd.AddCommand(newWeekResetCmd())
 	weekCmd.AddCommand(newWeekOffCmd())
+	weekCmd.AddCommand(newWeekCopyCmd())
+	weekCmd.AddCommand(newWeekLockCmd())
+	weekCmd.AddCommand(newWeekUnlockCmd())
 	weekCmd.AddCommand(newWeekItemCmd())
 
 	return weekCmd
@@ -54,6 +59,39 @@ func newWeekGetCmd() *cobra.Command {
 	return cmd
 }
 
+func newWeekRangeCmd() *cobra.Command {
+	var from, to string
+	cmd := &cobra.Command{
+		Use:   "range",
+		Short: "Get weekly plans of all weeks between two dates",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if from == "" || to == "" {
+				return fmt.Errorf("--from and --to are required")
+			}
+			client, err := newAPIClient()
+			if err != nil {
+				return err
+			}
+			weeks, err := client.GetWeeklyPlanRange(from, to)
+			if err != nil {
+				return err
+			}
+			return output.Print(outputFormat, weeks, func() {
+				for i, week := range weeks {
+					if i > 0 {
+						fmt.Println()
+					}
+					fmt.Printf("Week %s\n", week.WeekNumber)
+					printWeeklyPlanText(&week.WeeklyPlanDTO)
+				}
+			})
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "First date, in RFC3339 or YYYY-MM-DD format (required)")
+	cmd.Flags().StringVar(&to, "to", "", "Last date, in RFC3339 or YYYY-MM-DD format (required)")
+	return cmd
+}
+
 func newWeekResetCmd() *cobra.Command {
 	var date string
 	cmd := &cobra.Command{
@@ -118,6 +156,94 @@ func newWeekOffCmd() *cobra.Command {
 	return cmd
 }
 
+func newWeekCopyCmd() *cobra.Command {
+	var (
+		from      string
+		to        string
+		overwrite bool
+	)
+	cmd := &cobra.Command{
+		Use:   "copy",
+		Short: "Copy durations and notes from another week",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if from == "" {
+				return fmt.Errorf("--from is required")
+			}
+			if to == "" {
+				to = defaultDateRFC3339()
+			}
+			client, err := newAPIClient()
+			if err != nil {
+				return err
+			}
+			plan, err := client.CopyWeeklyPlan(api.CopyWeekRequest{SourceDate: from, TargetDate: to, Overwrite: overwrite})
+			if err != nil {
+				return err
+			}
+			return output.Print(outputFormat, plan, func() {
+				fmt.Println("Weekly plan copied.")
+				printWeeklyPlanText(plan)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "Any day of the source week, RFC3339 or YYYY-MM-DD (required)")
+	cmd.Flags().StringVar(&to, "to", "", "Any day of the target week, RFC3339 or YYYY-MM-DD (default: today)")
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "Overwrite items already customized in the target week")
+	return cmd
+}
+
+func newWeekLockCmd() *cobra.Command {
+	var date string
+	cmd := &cobra.Command{
+		Use:   "lock",
+		Short: "Lock a past week's plan and events against changes",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if date == "" {
+				return fmt.Errorf("--date is required")
+			}
+			client, err := newAPIClient()
+			if err != nil {
+				return err
+			}
+			plan, err := client.LockWeek(date)
+			if err != nil {
+				return err
+			}
+			return output.Print(outputFormat, plan, func() {
+				fmt.Println("Week locked.")
+			})
+		},
+	}
+	cmd.Flags().StringVar(&date, "date", "", "Any day of the week, RFC3339 or YYYY-MM-DD (required)")
+	return cmd
+}
+
+func newWeekUnlockCmd() *cobra.Command {
+	var date string
+	cmd := &cobra.Command{
+		Use:   "unlock",
+		Short: "Unlock a locked week",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if date == "" {
+				return fmt.Errorf("--date is required")
+			}
+			client, err := newAPIClient()
+			if err != nil {
+				return err
+			}
+			plan, err := client.UnlockWeek(date)
+			if err != nil {
+				return err
+			}
+			return output.Print(outputFormat, plan, func() {
+				fmt.Println("Week unlocked.")
+			})
+		},
+	}
+	cmd.Flags().StringVar(&date, "date", "", "Any day of the week, RFC3339 or YYYY-MM-DD (required)")
+	return cmd
+}
+
 func newWeekItemCmd() *cobra.Command {
 	itemCmd := &cobra.Command{
 		Use:   "item",
@@ -125,6 +251,10 @@ func newWeekItemCmd() *cobra.Command {
 	}
 	itemCmd.AddCommand(newWeekItemUpdateCmd())
 	itemCmd.AddCommand(newWeekItemResetCmd())
+	itemCmd.AddCommand(newWeekItemReorderCmd())
+	itemCmd.AddCommand(newWeekItemDaysCmd())
+	itemCmd.AddCommand(newWeekItemAddCmd())
+	itemCmd.AddCommand(newWeekItemDeleteCmd())
 	return itemCmd
 }
 
@@ -216,10 +346,187 @@ func newWeekItemResetCmd() *cobra.Command {
 	}
 }
 
+func newWeekItemReorderCmd() *cobra.Command {
+	var (
+		date    string
+		afterID int
+	)
+	cmd := &cobra.Command{
+		Use:   "reorder <budgetItemId>",
+		Short: "Move a weekly plan item after another item, only in the given week",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if date == "" {
+				return fmt.Errorf("--date is required")
+			}
+			budgetItemID, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid budget item ID: %s", args[0])
+			}
+			client, err := newAPIClient()
+			if err != nil {
+				return err
+			}
+			plan, err := client.ReorderWeeklyItem(date, api.MoveWeeklyItemRequest{
+				BudgetItemID:          budgetItemID,
+				PrecedingBudgetItemID: afterID,
+			})
+			if err != nil {
+				return err
+			}
+			return output.Print(outputFormat, plan, func() {
+				printWeeklyPlanText(plan)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&date, "date", "", "Date in RFC3339 format, e.g. 2026-04-05T00:00:00Z (required)")
+	cmd.Flags().IntVar(&afterID, "after", 0, "Budget item ID of the item to place after (0 for first position)")
+	return cmd
+}
+
+func newWeekItemDaysCmd() *cobra.Command {
+	var (
+		date string
+		days []string
+	)
+	cmd := &cobra.Command{
+		Use:   "days <budgetItemId>",
+		Short: "Distribute a weekly plan item across weekdays, only in the given week",
+		Long: "Distribute a weekly plan item across weekdays, only in the given week. The days must sum up to the " +
+			"weekly duration of the item. Without --day the distribution of the budget item is restored.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if date == "" {
+				return fmt.Errorf("--date is required")
+			}
+			budgetItemID, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid budget item ID: %s", args[0])
+			}
+			dailyDurations := make(map[string]int, len(days))
+			for _, day := range days {
+				weekday, duration, ok := strings.Cut(day, "=")
+				if !ok {
+					return fmt.Errorf("invalid --day %q, expected weekday=duration, e.g. monday=2h", day)
+				}
+				secs, err := parseDuration(duration)
+				if err != nil {
+					return err
+				}
+				dailyDurations[strings.ToLower(weekday)] = secs
+			}
+			client, err := newAPIClient()
+			if err != nil {
+				return err
+			}
+			item, err := client.SetWeeklyItemDailyDurations(date, api.SetDailyDurationsRequest{
+				BudgetItemID:   budgetItemID,
+				DailyDurations: dailyDurations,
+			})
+			if err != nil {
+				return err
+			}
+			return output.Print(outputFormat, item, func() {
+				fmt.Printf("Updated daily durations of %s (duration: %s)\n", item.Name, formatDuration(item.WeeklyDuration))
+				for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+					if secs, ok := item.DailyDurations[strings.ToLower(weekday.String())]; ok {
+						fmt.Printf("  %-9s %s\n", weekday, formatDuration(secs))
+					}
+				}
+			})
+		},
+	}
+	cmd.Flags().StringVar(&date, "date", "", "Date in RFC3339 format, e.g. 2026-04-05T00:00:00Z (required)")
+	cmd.Flags().StringArrayVar(&days, "day", nil, "Duration of a weekday, e.g. monday=2h (repeatable)")
+	return cmd
+}
+
+func newWeekItemAddCmd() *cobra.Command {
+	var (
+		date        string
+		name        string
+		duration    string
+		occurrences int
+		icon        string
+		color       string
+		notes       string
+	)
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "Add an ad-hoc item to a week only, without adding it to the budget plan",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if date == "" {
+				return fmt.Errorf("--date is required")
+			}
+			if name == "" {
+				return fmt.Errorf("--name is required")
+			}
+			dur, err := parseDuration(duration)
+			if err != nil {
+				return err
+			}
+			client, err := newAPIClient()
+			if err != nil {
+				return err
+			}
+			item, err := client.AddAdHocItem(date, api.AddAdHocItemRequest{
+				Name:              name,
+				WeeklyDuration:    dur,
+				WeeklyOccurrences: occurrences,
+				Icon:              icon,
+				Color:             color,
+				Notes:             notes,
+			})
+			if err != nil {
+				return err
+			}
+			return output.Print(outputFormat, item, func() {
+				fmt.Printf("Added ad-hoc item: %s (ID: %d, budget item ID: %d, duration: %s)\n",
+					item.Name, item.ID, item.BudgetItemID, formatDuration(item.WeeklyDuration))
+			})
+		},
+	}
+	cmd.Flags().StringVar(&date, "date", "", "Date in RFC3339 format, e.g. 2026-04-05T00:00:00Z (required)")
+	cmd.Flags().StringVar(&name, "name", "", "Item name (required)")
+	cmd.Flags().StringVar(&duration, "duration", "0", "Weekly duration, e.g. 3h or 10800")
+	cmd.Flags().IntVar(&occurrences, "occurrences", 0, "Number of days in the week")
+	cmd.Flags().StringVar(&icon, "icon", "", "Icon")
+	cmd.Flags().StringVar(&color, "color", "", "Color")
+	cmd.Flags().StringVar(&notes, "notes", "", "Notes for this week")
+	return cmd
+}
+
+func newWeekItemDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <itemId>",
+		Short: "Delete an ad-hoc item from its week",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			itemID, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid item ID: %s", args[0])
+			}
+			client, err := newAPIClient()
+			if err != nil {
+				return err
+			}
+			if err := client.DeleteAdHocItem(itemID); err != nil {
+				return err
+			}
+			return output.Print(outputFormat, map[string]string{"status": "deleted"}, func() {
+				fmt.Printf("Deleted ad-hoc item %d\n", itemID)
+			})
+		},
+	}
+}
+
 func printWeeklyPlanText(plan *api.WeeklyPlanDTO) {
 	if plan.IsOffWeek {
 		fmt.Println("(Off week)")
 	}
+	if plan.LockedAt != "" {
+		fmt.Printf("(Locked since %s)\n", plan.LockedAt)
+	}
 	fmt.Printf("Budget Plan ID: %d\n", plan.BudgetPlanID)
 	if len(plan.Items) > 0 {
 		fmt.Println()
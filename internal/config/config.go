@@ -13,11 +13,29 @@ import (
 )
 
 type Application struct {
-	Host     string   `koanf:"host"`
-	Frontend Frontend `koanf:"frontend"`
-	ClickUp  ClickUp  `koanf:"clickup"`
-	Google   Google   `koanf:"google"`
-	Database Database `koanf:"db"`
+	Host         string       `koanf:"host"`
+	Frontend     Frontend     `koanf:"frontend"`
+	ClickUp      ClickUp      `koanf:"clickup"`
+	Google       Google       `koanf:"google"`
+	Microsoft    Microsoft    `koanf:"microsoft"`
+	Database     Database     `koanf:"db"`
+	Webhook      Webhook      `koanf:"webhook"`
+	Archive      Archive      `koanf:"archive"`
+	Admin        Admin        `koanf:"admin"`
+	UserSwitch   UserSwitch   `koanf:"userswitch"`
+	WeeklyPlan   WeeklyPlan   `koanf:"weeklyplan"`
+	Smtp         Smtp         `koanf:"smtp"`
+	Digest       Digest       `koanf:"digest"`
+	Credentials  Credentials  `koanf:"credentials"`
+	Oidc         Oidc         `koanf:"oidc"`
+	Tracing      Tracing      `koanf:"tracing"`
+	Audit        Audit        `koanf:"audit"`
+	Shutdown     Shutdown     `koanf:"shutdown"`
+	Scheduler    Scheduler    `koanf:"scheduler"`
+	Backup       Backup       `koanf:"backup"`
+	RateLimit    RateLimit    `koanf:"ratelimit"`
+	Mqtt         Mqtt         `koanf:"mqtt"`
+	CurrentEvent CurrentEvent `koanf:"currentevent"`
 }
 
 type Frontend struct {
@@ -27,6 +45,186 @@ type Frontend struct {
 type ClickUp struct {
 	ClientId     string `koanf:"clientid"`
 	ClientSecret string `koanf:"clientsecret"`
+	// StaleAfterDays is the number of days a token has to be invalid before the user is notified.
+	StaleAfterDays int `koanf:"staleafterdays"`
+	// DisableAfterDays is the grace period after the notification, after which sync is disabled.
+	DisableAfterDays int `koanf:"disableafterdays"`
+}
+
+type Webhook struct {
+	// RotationOverlapHours is how long the previous token stays valid after rotation, unless the request sets it.
+	RotationOverlapHours int `koanf:"rotationoverlaphours"`
+}
+
+type Archive struct {
+	// EventsAfterDays is how long after their end events are moved to the archive. 0 disables archiving.
+	EventsAfterDays int `koanf:"eventsafterdays"`
+	// HistoryDays is how long the calendar history is kept, older changes are deleted. 0 keeps the history forever.
+	HistoryDays int `koanf:"historydays"`
+}
+
+type Admin struct {
+	// UserUids lists the users allowed to call admin endpoints, comma separated in the environment.
+	// When empty, nobody is an admin.
+	UserUids []string `koanf:"useruids"`
+}
+
+type UserSwitch struct {
+	// PinTtlHours is how long a PIN set by a user can be used to switch to them before it has to be set again.
+	PinTtlHours int `koanf:"pinttlhours"`
+	// SessionTtlMinutes is how long a session issued by switching users lasts.
+	SessionTtlMinutes int `koanf:"sessionttlminutes"`
+}
+
+type WeeklyPlan struct {
+	// GenerateWeeksAhead is how many weeks after the current one get their plan generated in advance.
+	// 0 disables the generation, weeks are then created on first change or tracked event.
+	GenerateWeeksAhead int `koanf:"generateweeksahead"`
+}
+
+type Smtp struct {
+	// Host of the SMTP server, emails are not sent when empty.
+	Host string `koanf:"host"`
+	Port int    `koanf:"port"`
+	// User and Pass authenticate with PLAIN auth, no authentication is used when User is empty.
+	User string `koanf:"user"`
+	Pass string `koanf:"pass"`
+	// From is the sender address of the emails.
+	From string `koanf:"from"`
+}
+
+type Digest struct {
+	// Weekday the weekly digest is sent on, e.g. "monday". It is evaluated in each user's timezone.
+	Weekday string `koanf:"weekday"`
+	// Hour of the day (0-23) from which the weekly digest is sent, in each user's timezone.
+	Hour int `koanf:"hour"`
+}
+
+type Credentials struct {
+	// EncryptionKey encrypts the tokens of third-party providers in the database, base64 encoded 32 bytes
+	// (e.g. openssl rand -base64 32). Integrations cannot be connected without it.
+	EncryptionKey string `koanf:"encryptionkey"`
+}
+
+type Oidc struct {
+	// Issuer of the OpenID Connect provider, e.g. https://accounts.google.com. Single sign-on is disabled when empty.
+	Issuer       string `koanf:"issuer"`
+	ClientId     string `koanf:"clientid"`
+	ClientSecret string `koanf:"clientsecret"`
+	// AutoProvision creates a Klokku user on the first login of an identity not linked to any user. The identity
+	// needs a verified email and, when any of the allow lists is set, has to match one of them.
+	AutoProvision bool `koanf:"autoprovision"`
+	// AllowedEmails restricts auto provisioning to the listed emails.
+	AllowedEmails []string `koanf:"allowedemails"`
+	// AllowedDomains restricts auto provisioning to emails of the listed domains, e.g. example.com.
+	AllowedDomains []string `koanf:"alloweddomains"`
+	// AllowedGroups restricts auto provisioning to members of the listed groups of the groups claim.
+	AllowedGroups []string `koanf:"allowedgroups"`
+	// SessionTtlHours is how long a session issued by single sign-on lasts.
+	SessionTtlHours int `koanf:"sessionttlhours"`
+}
+
+type Tracing struct {
+	// Endpoint of an OpenTelemetry collector accepting OTLP over HTTP, e.g. http://localhost:4318.
+	// Tracing is disabled when empty.
+	Endpoint string `koanf:"endpoint"`
+	// Headers sent with every export, e.g. an API key of a hosted collector, as comma separated key=value pairs.
+	Headers string `koanf:"headers"`
+	// ServiceName identifies the Klokku instance in the tracing backend.
+	ServiceName string `koanf:"servicename"`
+	// SampleRatio is the fraction (0-1) of traces started by Klokku that are recorded. Requests coming with
+	// a trace context follow the sampling decision of the caller.
+	SampleRatio float64 `koanf:"sampleratio"`
+}
+
+type Audit struct {
+	// TrustedProxies lists the addresses or CIDR ranges of the reverse proxies in front of Klokku, comma separated
+	// in the environment. For connections from them the client address is taken from the X-Forwarded-For header,
+	// for the audit log and the rate limits per address.
+	TrustedProxies []string `koanf:"trustedproxies"`
+	// RetentionDays is how long audit log entries are kept, older entries are deleted. 0 keeps them forever.
+	RetentionDays int `koanf:"retentiondays"`
+}
+
+type Shutdown struct {
+	// DrainDelaySeconds is how long the readiness probe fails after SIGTERM before the server stops accepting
+	// connections, so load balancers stop routing to the instance first.
+	DrainDelaySeconds int `koanf:"draindelayseconds"`
+	// TimeoutSeconds bounds draining the requests in flight and finishing the background work after SIGTERM.
+	TimeoutSeconds int `koanf:"timeoutseconds"`
+}
+
+type CurrentEvent struct {
+	// AutoStopAfterHours stops events running for longer, they are stored as lasting that long. 0 keeps events
+	// running until they are stopped.
+	AutoStopAfterHours int `koanf:"autostopafterhours"`
+}
+
+type Scheduler struct {
+	// InstanceId identifies the instance in the election of the one running the scheduled jobs, it defaults to
+	// the host name and process id.
+	InstanceId string `koanf:"instanceid"`
+}
+
+type RateLimit struct {
+	// UserRequestsPerSecond is how many API requests a user can make per second on average, more are rejected with
+	// 429 Too Many Requests. 0 disables the limit.
+	UserRequestsPerSecond float64 `koanf:"userrequestspersecond"`
+	// UserBurst is how many API requests a user can make at once, e.g. when the frontend loads.
+	UserBurst int `koanf:"userburst"`
+	// IpRequestsPerSecond limits the API requests from one client address, also those of unknown users. Clients
+	// behind the same NAT share the limit. 0 disables the limit.
+	IpRequestsPerSecond float64 `koanf:"iprequestspersecond"`
+	IpBurst             int     `koanf:"ipburst"`
+}
+
+type Backup struct {
+	// Schedule of the backups of the whole instance as a cron expression in UTC, e.g. "0 2 * * *".
+	// Scheduled backups are disabled when empty.
+	Schedule string `koanf:"schedule"`
+	// Keep is how many of the newest scheduled backups are kept, older ones are removed after each backup, unless
+	// KeepDaily or KeepWeekly keep them. All are kept when the three are 0.
+	Keep int `koanf:"keep"`
+	// KeepDaily is how many of the latest days keep their newest scheduled backup.
+	KeepDaily int `koanf:"keepdaily"`
+	// KeepWeekly is how many of the latest weeks keep their newest scheduled backup.
+	KeepWeekly int `koanf:"keepweekly"`
+	// EncryptionKey encrypts the backups with AES-256-GCM, base64 encoded 32 bytes (e.g. openssl rand -base64 32).
+	// Backups hold password hashes and integration tokens, so none is created when it is empty. Encrypted backups
+	// cannot be restored without it.
+	EncryptionKey string `koanf:"encryptionkey"`
+	// Dir is where the backups are stored, unless they go to S3.
+	Dir string `koanf:"dir"`
+	S3  S3     `koanf:"s3"`
+}
+
+type S3 struct {
+	// Bucket of an S3-compatible storage the backups are stored in, they are stored in Dir when empty.
+	Bucket string `koanf:"bucket"`
+	// Endpoint of the storage, e.g. https://s3.eu-central-1.amazonaws.com or http://minio:9000.
+	Endpoint string `koanf:"endpoint"`
+	Region   string `koanf:"region"`
+	// Prefix of the object keys, e.g. "klokku/".
+	Prefix    string `koanf:"prefix"`
+	AccessKey string `koanf:"accesskey"`
+	SecretKey string `koanf:"secretkey"`
+}
+
+type Mqtt struct {
+	// Broker is the address of an MQTT broker, e.g. tcp://mosquitto:1883 or tls://broker:8883, the tracking state
+	// is published to. The MQTT integration is disabled when empty.
+	Broker   string `koanf:"broker"`
+	Username string `koanf:"username"`
+	Password string `koanf:"password"`
+	// ClientId identifies the connection to the broker, each instance needs its own. A random one is used when empty.
+	ClientId string `koanf:"clientid"`
+	// SharedGroup is the shared subscription group of the command topics, so a command published once is run by one
+	// instance only. The broker must support shared subscriptions, e.g. Mosquitto 2 or EMQX, when it is not empty.
+	SharedGroup string `koanf:"sharedgroup"`
+	// TopicPrefix is the first level of the state and command topics, e.g. klokku/<topic>/state.
+	TopicPrefix string `koanf:"topicprefix"`
+	// DiscoveryPrefix is where Home Assistant looks for MQTT discovery configs. Discovery is not published when empty.
+	DiscoveryPrefix string `koanf:"discoveryprefix"`
 }
 
 type Google struct {
@@ -34,6 +232,13 @@ type Google struct {
 	ClientSecret string `koanf:"clientsecret"`
 }
 
+type Microsoft struct {
+	ClientId     string `koanf:"clientid"`
+	ClientSecret string `koanf:"clientsecret"`
+	// Tenant restricts sign-in to one Entra ID tenant, "common" allows any work, school or personal account.
+	Tenant string `koanf:"tenant"`
+}
+
 type Database struct {
 	Host   string `koanf:"host"`
 	Port   int    `koanf:"port"`
@@ -41,6 +246,19 @@ type Database struct {
 	Pass   string `koanf:"pass"`
 	Name   string `koanf:"name"`
 	Schema string `koanf:"schema"`
+	// SlowQueryThresholdMs is how long a query has to run to be logged as slow. 0 disables the slow query log.
+	SlowQueryThresholdMs int `koanf:"slowquerythresholdms"`
+	// AutoMigrate applies the pending migrations on startup. Disable it to upgrade the schema in a controlled step
+	// with the --migrate-only flag instead, the instances then report not ready until it is done.
+	AutoMigrate bool `koanf:"automigrate"`
+	// MaxConns is the size of the connection pool shared by all requests and background jobs.
+	MaxConns int `koanf:"maxconns"`
+	// StatementTimeoutMs makes Postgres cancel statements running longer, also those of background jobs whose
+	// context has no deadline. 0 disables the timeout.
+	StatementTimeoutMs int `koanf:"statementtimeoutms"`
+	// IdleInTransactionTimeoutMs makes Postgres close connections of transactions left idle for longer, so a
+	// stuck transaction does not hold its connection and locks forever. 0 disables the timeout.
+	IdleInTransactionTimeoutMs int `koanf:"idleintransactiontimeoutms"`
 }
 
 func Load(path string) (Application, error) {
@@ -51,6 +269,65 @@ func Load(path string) (Application, error) {
 		Frontend: Frontend{
 			Enabled: true,
 		},
+		ClickUp: ClickUp{
+			StaleAfterDays:   7,
+			DisableAfterDays: 7,
+		},
+		Microsoft: Microsoft{
+			Tenant: "common",
+		},
+		Webhook: Webhook{
+			RotationOverlapHours: 24,
+		},
+		Archive: Archive{
+			EventsAfterDays: 730,
+			HistoryDays:     180,
+		},
+		UserSwitch: UserSwitch{
+			PinTtlHours:       7 * 24,
+			SessionTtlMinutes: 60,
+		},
+		WeeklyPlan: WeeklyPlan{
+			GenerateWeeksAhead: 4,
+		},
+		Smtp: Smtp{
+			Port: 587,
+		},
+		Digest: Digest{
+			Weekday: "monday",
+			Hour:    8,
+		},
+		Oidc: Oidc{
+			SessionTtlHours: 30 * 24,
+		},
+		Tracing: Tracing{
+			ServiceName: "klokku",
+			SampleRatio: 1,
+		},
+		Shutdown: Shutdown{
+			TimeoutSeconds: 25,
+		},
+		RateLimit: RateLimit{
+			UserRequestsPerSecond: 10,
+			UserBurst:             60,
+			IpRequestsPerSecond:   20,
+			IpBurst:               120,
+		},
+		Audit: Audit{
+			RetentionDays: 365,
+		},
+		Backup: Backup{
+			Keep: 7,
+			Dir:  "./storage/backups",
+			S3: S3{
+				Region: "us-east-1",
+			},
+		},
+		Mqtt: Mqtt{
+			SharedGroup:     "klokku",
+			TopicPrefix:     "klokku",
+			DiscoveryPrefix: "homeassistant",
+		},
 		Database: Database{
 			Host:   "localhost",
 			Port:   5432,
@@ -58,6 +335,12 @@ func Load(path string) (Application, error) {
 			Pass:   "",
 			Name:   "klokku",
 			Schema: "klokku",
+
+			SlowQueryThresholdMs:       1000,
+			AutoMigrate:                true,
+			MaxConns:                   25,
+			StatementTimeoutMs:         30000,
+			IdleInTransactionTimeoutMs: 60000,
 		},
 	}, "koanf"), nil)
 	if err != nil {
@@ -81,6 +364,10 @@ func Load(path string) (Application, error) {
 		TransformFunc: func(k, v string) (string, any) {
 			// Transform the key.
 			k = strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(k, "KLOKKU_")), "_", ".")
+			switch k {
+			case "admin.useruids", "oidc.allowedemails", "oidc.alloweddomains", "oidc.allowedgroups":
+				return k, strings.Split(v, ",")
+			}
 			return k, v
 		},
 	}), nil)
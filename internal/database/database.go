@@ -1,20 +1,26 @@
 package database
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"net/url"
-	"os"
-	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-	_ "github.com/jackc/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/multitracer"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/migrations"
+	log "github.com/sirupsen/logrus"
 )
 
 // Open opens a Postgres database
@@ -31,9 +37,24 @@ func Open(cfg config.Database) (*pgxpool.Pool, error) {
 		return nil, fmt.Errorf("failed to parse database config: %w", err)
 	}
 
-	// Optional: Configure pool settings for better performance
 	poolConfig.MaxConns = 25
-	poolConfig.MinConns = 5
+	if cfg.MaxConns > 0 {
+		poolConfig.MaxConns = int32(cfg.MaxConns)
+	}
+	poolConfig.MinConns = min(5, poolConfig.MaxConns)
+	if cfg.StatementTimeoutMs > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.Itoa(cfg.StatementTimeoutMs)
+	}
+	if cfg.IdleInTransactionTimeoutMs > 0 {
+		poolConfig.ConnConfig.RuntimeParams["idle_in_transaction_session_timeout"] = strconv.Itoa(cfg.IdleInTransactionTimeoutMs)
+	}
+
+	// Queries are traced only when tracing is set up, otherwise the spans are discarded right away
+	tracers := []pgx.QueryTracer{newQueryTracer()}
+	if cfg.SlowQueryThresholdMs > 0 {
+		tracers = append(tracers, newSlowQueryTracer(time.Duration(cfg.SlowQueryThresholdMs)*time.Millisecond))
+	}
+	poolConfig.ConnConfig.Tracer = multitracer.New(tracers...)
 
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
@@ -47,22 +68,41 @@ func Open(cfg config.Database) (*pgxpool.Pool, error) {
 	return pool, nil
 }
 
-// Migrate runs database migrations using golang-migrate against the configured DB.
+// Migration is a migration shipped with the application.
+type Migration struct {
+	Version uint
+	// Name is the description of the migration, e.g. "event_outbox"
+	Name string
+}
+
+// migrationLogger reports the progress of golang-migrate, so the applied migrations show in the log.
+type migrationLogger struct{}
+
+func (migrationLogger) Printf(format string, v ...any) {
+	log.Infof("migrations: "+strings.TrimSuffix(format, "\n"), v...)
+}
+
+func (migrationLogger) Verbose() bool {
+	return false
+}
+
+// Migrate applies the pending migrations embedded in the binary to the configured DB. Instances starting at the
+// same time wait for each other, golang-migrate holds an advisory lock while migrating.
 func Migrate(cfg config.Database) error {
 	escapedPassword := url.QueryEscape(cfg.Pass)
 
 	dbUrl := fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable&search_path=%s", cfg.User, escapedPassword, cfg.Host, cfg.Port, cfg.Name, cfg.Schema)
 
-	migrationsPath, err := findMigrationsPath()
+	source, err := iofs.New(migrations.FS, ".")
 	if err != nil {
-		return fmt.Errorf("failed to locate migrations directory: %w", err)
+		return fmt.Errorf("failed to read embedded migrations: %w", err)
 	}
-
-	m, err := migrate.New("file://"+migrationsPath, dbUrl)
+	m, err := migrate.NewWithSourceInstance("iofs", source, dbUrl)
 	if err != nil {
 		return fmt.Errorf("failed to create migrate instance: %w", err)
 	}
 	defer m.Close()
+	m.Log = migrationLogger{}
 	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
 		return fmt.Errorf("migration up failed: %w", err)
 	}
@@ -70,32 +110,58 @@ func Migrate(cfg config.Database) error {
 	return nil
 }
 
-// findMigrationsPath searches upward from the current working directory for a "migrations" directory
-// and returns its absolute path. This makes migrations resolution robust in tests where the working
-// directory can be different from the project root.
-func findMigrationsPath() (string, error) {
-	dir, err := os.Getwd()
+// MigrationStatus compares the version of the database schema with the migrations shipped with the application.
+type MigrationStatus struct {
+	// Version is the last applied migration, 0 when none has been applied yet
+	Version uint
+	// Dirty is set when the last migration failed and the schema has to be fixed manually
+	Dirty bool
+	// Latest is the last migration shipped with the application
+	Latest uint
+}
+
+// Pending is the number of shipped migrations not applied to the database yet.
+func (s MigrationStatus) Pending() uint {
+	if s.Latest <= s.Version {
+		return 0
+	}
+	return s.Latest - s.Version
+}
+
+// GetMigrationStatus reads the version recorded by golang-migrate in the schema of the pool.
+func GetMigrationStatus(ctx context.Context, db *pgxpool.Pool) (MigrationStatus, error) {
+	shipped, err := Migrations()
 	if err != nil {
-		return "", err
-	}
-
-	for {
-		candidate := filepath.Join(dir, "migrations")
-		info, err := os.Stat(candidate)
-		if err == nil && info.IsDir() {
-			abs, err := filepath.Abs(candidate)
-			if err != nil {
-				return "", err
-			}
-			return abs, nil
-		}
+		return MigrationStatus{}, err
+	}
+	status := MigrationStatus{}
+	if len(shipped) > 0 {
+		status.Latest = shipped[len(shipped)-1].Version
+	}
+	var version int64
+	err = db.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &status.Dirty)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return MigrationStatus{}, fmt.Errorf("failed to read migration version: %w", err)
+	}
+	status.Version = uint(version)
+	return status, nil
+}
 
-		parent := filepath.Dir(dir)
-		if parent == dir {
-			break
+// Migrations returns the up migrations embedded in the binary, named like 0042_description.up.sql, in order.
+func Migrations() ([]Migration, error) {
+	files, err := fs.Glob(migrations.FS, "*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+	var shipped []Migration
+	for _, file := range files {
+		prefix, name, _ := strings.Cut(strings.TrimSuffix(file, ".up.sql"), "_")
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			continue
 		}
-		dir = parent
+		shipped = append(shipped, Migration{Version: uint(version), Name: name})
 	}
-
-	return "", fmt.Errorf("migrations directory not found")
+	slices.SortFunc(shipped, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	return shipped, nil
 }
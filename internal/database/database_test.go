@@ -0,0 +1,22 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+	// when
+	shipped, err := Migrations()
+
+	// then
+	require.NoError(t, err)
+	require.NotEmpty(t, shipped)
+	// Pending counts the versions between the applied and the latest one, so they must not leave gaps
+	for i, migration := range shipped {
+		assert.Equal(t, uint(i+1), migration.Version, "migration %s", migration.Name)
+		assert.NotEmpty(t, migration.Name)
+	}
+}
@@ -0,0 +1,141 @@
+// Package dbtx carries a database transaction in a context, so code called within it can join the transaction
+// and defer work until it commits.
+package dbtx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	log "github.com/sirupsen/logrus"
+)
+
+// Querier runs queries, it is implemented by both *pgxpool.Pool and pgx.Tx.
+type Querier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// beginTimeout bounds waiting for a connection of the pool. When all of them are taken for longer, failing the request
+// is better than queueing ever more of them.
+const beginTimeout = 5 * time.Second
+
+type txKey struct{}
+
+// txState is the transaction of a context together with the work waiting for its commit.
+type txState struct {
+	tx pgx.Tx
+
+	mu          sync.Mutex
+	done        bool
+	afterCommit []func()
+}
+
+// WithTx returns ctx carrying the transaction, so code called within it, e.g. the event bus, can write within
+// the same transaction. The owner of the transaction has to call Committed once it commits.
+func WithTx(ctx context.Context, tx pgx.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, &txState{tx: tx})
+}
+
+// Tx returns the open transaction of ctx.
+func Tx(ctx context.Context) (pgx.Tx, bool) {
+	state, ok := ctx.Value(txKey{}).(*txState)
+	if !ok {
+		return nil, false
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.done {
+		return nil, false
+	}
+	return state.tx, true
+}
+
+// AfterCommit registers fn to run once the transaction of ctx commits, it never runs when the transaction is
+// rolled back. It returns false when ctx has no open transaction, the caller should then run fn right away.
+func AfterCommit(ctx context.Context, fn func()) bool {
+	state, ok := ctx.Value(txKey{}).(*txState)
+	if !ok {
+		return false
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.done {
+		return false
+	}
+	state.afterCommit = append(state.afterCommit, fn)
+	return true
+}
+
+// Committed closes the transaction of ctx and runs the functions registered with AfterCommit, in order.
+func Committed(ctx context.Context) {
+	state, ok := ctx.Value(txKey{}).(*txState)
+	if !ok {
+		return
+	}
+	state.mu.Lock()
+	state.done = true
+	afterCommit := state.afterCommit
+	state.afterCommit = nil
+	state.mu.Unlock()
+	for _, fn := range afterCommit {
+		fn()
+	}
+}
+
+// From returns the open transaction of ctx, or db when there is none. Repositories query through it, so code called
+// within a transaction joins it instead of waiting for a second connection of the pool, which blocks once all
+// connections are held by transactions doing the same.
+func From(ctx context.Context, db Querier) Querier {
+	if tx, ok := Tx(ctx); ok {
+		return tx
+	}
+	return db
+}
+
+// InTx runs fn in a transaction on db and commits it when fn succeeds. Within an open transaction of ctx, fn runs in
+// a savepoint of it instead, and the work deferred with AfterCommit waits for the outer transaction to commit.
+func InTx(ctx context.Context, db Querier, fn func(ctx context.Context) error) error {
+	outer, nested := Tx(ctx)
+	beginCtx, cancel := context.WithTimeout(ctx, beginTimeout)
+	var tx pgx.Tx
+	var err error
+	if nested {
+		tx, err = outer.Begin(beginCtx)
+	} else {
+		tx, err = db.Begin(beginCtx)
+	}
+	cancel()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		// The Rollback will be a no-op if the transaction was already committed
+		if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
+			log.Errorf("rollback error: %v", rbErr)
+		}
+	}()
+
+	// The savepoint is on the connection of the outer transaction, so queries through it are part of the savepoint
+	txCtx := ctx
+	if !nested {
+		txCtx = WithTx(ctx, tx)
+	}
+	if err := fn(txCtx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	if !nested {
+		Committed(txCtx)
+	}
+	return nil
+}
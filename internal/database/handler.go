@@ -0,0 +1,82 @@
+package database
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	log "github.com/sirupsen/logrus"
+)
+
+type MigrationDTO struct {
+	Version uint   `json:"version"`
+	Name    string `json:"name"`
+	Applied bool   `json:"applied"`
+}
+
+type MigrationStatusDTO struct {
+	// Version is the last applied migration, 0 when none has been applied yet
+	Version uint `json:"version"`
+	// Dirty is set when the last migration failed and the schema has to be fixed manually
+	Dirty bool `json:"dirty"`
+	// Latest is the last migration shipped with the running version of Klokku
+	Latest     uint           `json:"latest"`
+	Pending    uint           `json:"pending"`
+	Migrations []MigrationDTO `json:"migrations"`
+}
+
+type MigrationsHandler struct {
+	db *pgxpool.Pool
+}
+
+func NewMigrationsHandler(db *pgxpool.Pool) *MigrationsHandler {
+	return &MigrationsHandler{db: db}
+}
+
+// GetStatus godoc
+// @Summary Get the database migration status
+// @Description Get the version of the database schema and the migrations shipped with the running version of
+// @Description Klokku, each marked whether it has been applied. Requires an admin user.
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} MigrationStatusDTO
+// @Failure 403 {string} string "User not found or not an admin"
+// @Router /api/admin/migrations [get]
+// @Security XUserId
+func (h *MigrationsHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := GetMigrationStatus(r.Context(), h.db)
+	if err != nil {
+		log.Errorf("Failed to get migration status: %v", err)
+		http.Error(w, "Failed to get migration status", http.StatusInternalServerError)
+		return
+	}
+	shipped, err := Migrations()
+	if err != nil {
+		log.Errorf("Failed to list migrations: %v", err)
+		http.Error(w, "Failed to list migrations", http.StatusInternalServerError)
+		return
+	}
+
+	dto := MigrationStatusDTO{
+		Version:    status.Version,
+		Dirty:      status.Dirty,
+		Latest:     status.Latest,
+		Pending:    status.Pending(),
+		Migrations: make([]MigrationDTO, 0, len(shipped)),
+	}
+	for _, migration := range shipped {
+		// A dirty version failed part way, it does not count as applied
+		applied := migration.Version < status.Version || (migration.Version == status.Version && !status.Dirty)
+		dto.Migrations = append(dto.Migrations, MigrationDTO{
+			Version: migration.Version,
+			Name:    migration.Name,
+			Applied: applied,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(dto); err != nil {
+		log.Errorf("Failed to encode migration status: %v", err)
+		http.Error(w, "Failed to encode migration status", http.StatusInternalServerError)
+	}
+}
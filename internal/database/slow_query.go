@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxLoggedSqlLength keeps the log readable for long generated statements
+const maxLoggedSqlLength = 2000
+
+type slowQueryStartKey struct{}
+
+type slowQueryStart struct {
+	sql     string
+	argsLen int
+	start   time.Time
+}
+
+// slowQueryTracer logs the queries running longer than the threshold, together with the user they were run for.
+// Query arguments are not logged, they may contain user data.
+type slowQueryTracer struct {
+	threshold time.Duration
+}
+
+func newSlowQueryTracer(threshold time.Duration) *slowQueryTracer {
+	return &slowQueryTracer{threshold: threshold}
+}
+
+func (t *slowQueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, slowQueryStartKey{}, slowQueryStart{
+		sql:     data.SQL,
+		argsLen: len(data.Args),
+		start:   time.Now(),
+	})
+}
+
+func (t *slowQueryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	query, ok := ctx.Value(slowQueryStartKey{}).(slowQueryStart)
+	if !ok {
+		return
+	}
+	duration := time.Since(query.start)
+	if duration < t.threshold {
+		return
+	}
+
+	sql := query.sql
+	if len(sql) > maxLoggedSqlLength {
+		sql = sql[:maxLoggedSqlLength] + "..."
+	}
+	fields := log.Fields{
+		"durationMs": duration.Milliseconds(),
+		"sql":        sql,
+		"args":       query.argsLen,
+		"rows":       data.CommandTag.RowsAffected(),
+	}
+	if conn != nil && conn.PgConn() != nil {
+		fields["pid"] = conn.PgConn().PID()
+	}
+	if userId, err := user.CurrentId(ctx); err == nil {
+		fields["userId"] = userId
+	}
+	if data.Err != nil {
+		fields["error"] = data.Err.Error()
+	}
+	log.WithFields(fields).Warn("slow database query")
+}
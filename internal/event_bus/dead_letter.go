@@ -0,0 +1,103 @@
+package event_bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultDeadLetterLimit = 100
+	maxDeadLetterLimit     = 500
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+var ErrInvalidFilter = errors.New("invalid dead letter filter")
+
+// DeadLetter is an event a durable subscriber failed to handle in all attempts.
+type DeadLetter struct {
+	Id    int64
+	Event OutboxEvent
+	// UserUid identifies the user who published the event, empty when there was none
+	UserUid    string
+	Subscriber string
+	Attempts   int
+	LastError  string
+	FailedAt   time.Time
+}
+
+type DeadLetterFilter struct {
+	EventType  EventType
+	Subscriber string
+	// BeforeId limits the dead letters to ones with a lower id, for paging
+	BeforeId int64
+	Limit    int
+}
+
+// ListDeadLetters returns the dead letters matching the filter, the newest first.
+func (o *Outbox) ListDeadLetters(ctx context.Context, filter DeadLetterFilter) ([]DeadLetter, error) {
+	if filter.Limit == 0 {
+		filter.Limit = defaultDeadLetterLimit
+	}
+	if filter.Limit < 0 || filter.Limit > maxDeadLetterLimit {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidFilter, maxDeadLetterLimit)
+	}
+	return o.repo.ListDeadLetters(ctx, filter)
+}
+
+func (o *Outbox) GetDeadLetter(ctx context.Context, id int64) (DeadLetter, error) {
+	return o.repo.GetDeadLetter(ctx, id)
+}
+
+// Replay moves the dead letter back to the outbox, so it is delivered to its subscriber again right away.
+func (o *Outbox) Replay(ctx context.Context, id int64) error {
+	replayed, err := o.repo.Replay(ctx, []int64{id}, o.clock.Now())
+	if err != nil {
+		return err
+	}
+	if replayed == 0 {
+		return ErrDeadLetterNotFound
+	}
+	log.Infof("replaying dead letter %d", id)
+	o.notify()
+	return nil
+}
+
+// ReplayAll replays all dead letters of the event type and subscriber of the filter, each when set, and returns
+// how many were replayed. Limit and BeforeId of the filter are ignored.
+func (o *Outbox) ReplayAll(ctx context.Context, filter DeadLetterFilter) (int, error) {
+	filter.BeforeId = 0
+	filter.Limit = maxDeadLetterLimit
+	replayed := 0
+	for {
+		deadLetters, err := o.repo.ListDeadLetters(ctx, filter)
+		if err != nil {
+			return replayed, err
+		}
+		if len(deadLetters) == 0 {
+			break
+		}
+		ids := make([]int64, 0, len(deadLetters))
+		for _, d := range deadLetters {
+			ids = append(ids, d.Id)
+		}
+		count, err := o.repo.Replay(ctx, ids, o.clock.Now())
+		if err != nil {
+			return replayed, err
+		}
+		replayed += count
+		if len(deadLetters) < maxDeadLetterLimit {
+			break
+		}
+		// Continue below the last one, skipping deliveries that failed again meanwhile
+		filter.BeforeId = ids[len(ids)-1]
+	}
+	if replayed > 0 {
+		log.Infof("replaying %d dead letters", replayed)
+		o.notify()
+	}
+	return replayed, nil
+}
@@ -2,10 +2,12 @@ package event_bus
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/klokku/klokku/internal/database/dbtx"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -61,18 +63,29 @@ func (e EventT[T]) Context() context.Context {
 // handler is the internal shape for subscribers: a function that accepts the generic Event.
 type handler func(Event) error
 
+// ErrClosed is returned by Publish once the bus has been closed.
+var ErrClosed = errors.New("event bus closed")
+
 // EventBus is a concurrency-safe synchronous event dispatcher.
 // All handlers are executed sequentially and synchronously during Publish.
 type EventBus struct {
 	mu          sync.RWMutex
 	subscribers map[EventType]map[uint64]handler
 	nextID      uint64
+	durable     map[EventType]map[string]*durableSubscriber
+	outbox      *Outbox
+
+	// publishing tracks the running Publish calls, closed buses accept no new ones
+	publishing sync.WaitGroup
+	closed     bool
+	flushers   []func(ctx context.Context) error
 }
 
 // NewEventBus creates an empty EventBus.
 func NewEventBus() *EventBus {
 	return &EventBus{
 		subscribers: make(map[EventType]map[uint64]handler),
+		durable:     make(map[EventType]map[string]*durableSubscriber),
 	}
 }
 
@@ -155,6 +168,10 @@ func SubscribeTyped[T any](eb *EventBus, eventType EventType, h func(EventT[T])
 //
 // If the event's context is cancelled before or during handler execution, remaining
 // handlers are skipped and a context error is returned.
+//
+// When the bus uses an outbox, the event is stored for its durable subscribers first, failing the publish when
+// it cannot be. When the event's context carries an open transaction (see dbtx.WithTx), the event is stored
+// within it and the other handlers run only once it commits, their errors are then logged.
 func (eb *EventBus) Publish(e Event) error {
 	// Check if context is already cancelled
 	if err := e.Context().Err(); err != nil {
@@ -162,24 +179,60 @@ func (eb *EventBus) Publish(e Event) error {
 	}
 
 	eb.mu.RLock()
+	if eb.closed {
+		eb.mu.RUnlock()
+		return fmt.Errorf("event %s: %w", e.Type, ErrClosed)
+	}
+	eb.publishing.Add(1)
+	defer eb.publishing.Done()
 	// Copy handler map to avoid holding lock during invocation
-	handlers := make([]struct {
-		id uint64
-		h  handler
-	}, 0, len(eb.subscribers[e.Type]))
+	handlers := make([]subscription, 0, len(eb.subscribers[e.Type]))
 	for id, h := range eb.subscribers[e.Type] {
-		handlers = append(handlers, struct {
-			id uint64
-			h  handler
-		}{id, h})
+		handlers = append(handlers, subscription{id, h})
 	}
+	var durable []*durableSubscriber
+	for _, subscriber := range eb.durable[e.Type] {
+		if e.Data != nil && subscriber.accepts(e.Data) {
+			durable = append(durable, subscriber)
+		}
+	}
+	outbox := eb.outbox
 	eb.mu.RUnlock()
 
-	var errors []error
+	if outbox != nil && len(durable) > 0 {
+		if err := outbox.enqueue(e, durable); err != nil {
+			return fmt.Errorf("event %s: %w", e.Type, err)
+		}
+	} else {
+		for _, subscriber := range durable {
+			handlers = append(handlers, subscription{h: subscriber.handle})
+		}
+	}
+
+	deferred := dbtx.AfterCommit(e.Context(), func() {
+		if err := eb.dispatch(e, handlers); err != nil {
+			log.Errorf("EventBus: %v", err)
+		}
+	})
+	if deferred {
+		return nil
+	}
+	return eb.dispatch(e, handlers)
+}
+
+// subscription is a registered handler with the ID it was registered under.
+type subscription struct {
+	id uint64
+	h  handler
+}
+
+// dispatch runs the handlers of the event, returning the errors of all failed handlers.
+func (eb *EventBus) dispatch(e Event, handlers []subscription) error {
+	var errs []error
 	for _, handler := range handlers {
 		// Check context cancellation before each handler
 		if err := e.Context().Err(); err != nil {
-			errors = append(errors, fmt.Errorf("context cancelled during event processing: %w", err))
+			errs = append(errs, fmt.Errorf("context cancelled during event processing: %w", err))
 			break
 		}
 
@@ -198,13 +251,49 @@ func (eb *EventBus) Publish(e Event) error {
 		if err != nil {
 			log.Errorf("EventBus: handler error (ID %d) for event %s: %v",
 				handler.id, e.Type, err)
-			errors = append(errors, err)
+			errs = append(errs, err)
 		}
 	}
 
-	if len(errors) > 0 {
-		return fmt.Errorf("event %s: %d handler(s) failed: %v", e.Type, len(errors), errors)
+	if len(errs) > 0 {
+		return fmt.Errorf("event %s: %d handler(s) failed: %v", e.Type, len(errs), errs)
 	}
 
 	return nil
 }
+
+// OnClose registers a function completing the work a subscriber started in the background of its handlers,
+// e.g. notifications still being sent. It is called by Close after the last event has been handled.
+func (eb *EventBus) OnClose(flush func(ctx context.Context) error) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.flushers = append(eb.flushers, flush)
+}
+
+// Close stops accepting new events, waits for the events being handled and flushes the subscribers registered
+// with OnClose. It returns early with the error of ctx when that takes longer than ctx allows.
+func (eb *EventBus) Close(ctx context.Context) error {
+	eb.mu.Lock()
+	eb.closed = true
+	flushers := eb.flushers
+	eb.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		eb.publishing.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return fmt.Errorf("events still being handled: %w", ctx.Err())
+	}
+
+	var errs []error
+	for _, flush := range flushers {
+		if err := flush(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,64 @@
+package event_bus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBus_Close(t *testing.T) {
+	t.Run("should wait for events being handled and flush subscribers", func(t *testing.T) {
+		// given
+		bus := NewEventBus()
+		handling := make(chan struct{})
+		release := make(chan struct{})
+		var steps []string
+		bus.Subscribe("test", func(e Event) error {
+			close(handling)
+			<-release
+			steps = append(steps, "handled")
+			return nil
+		})
+		bus.OnClose(func(ctx context.Context) error {
+			steps = append(steps, "flushed")
+			return nil
+		})
+		go func() { _ = bus.Publish(NewEvent(context.Background(), "test", nil)) }()
+		<-handling
+
+		// when
+		closed := make(chan error)
+		go func() { closed <- bus.Close(context.Background()) }()
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+
+		// then
+		require.NoError(t, <-closed)
+		assert.Equal(t, []string{"handled", "flushed"}, steps)
+		assert.ErrorIs(t, bus.Publish(NewEvent(context.Background(), "test", nil)), ErrClosed)
+	})
+
+	t.Run("should give up when the context ends", func(t *testing.T) {
+		// given
+		bus := NewEventBus()
+		handling := make(chan struct{})
+		bus.Subscribe("test", func(e Event) error {
+			close(handling)
+			<-make(chan struct{})
+			return nil
+		})
+		go func() { _ = bus.Publish(NewEvent(context.Background(), "test", nil)) }()
+		<-handling
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		// when
+		err := bus.Close(ctx)
+
+		// then
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
@@ -2,6 +2,33 @@ package event_bus
 
 import "time"
 
+type BudgetPlanCreated struct {
+	Id   int
+	Name string
+}
+
+type BudgetPlanUpdated struct {
+	Id        int
+	Name      string
+	IsCurrent bool
+}
+
+type BudgetPlanDeleted struct {
+	Id int
+}
+
+type BudgetPlanItemCreated struct {
+	Id                int
+	PlanId            int
+	Name              string
+	WeeklyDuration    time.Duration
+	WeeklyOccurrences int
+	DailyDurations    map[time.Weekday]time.Duration
+	Icon              string
+	Color             string
+	Position          int
+}
+
 type BudgetPlanItemUpdated struct {
 	Id     int
 	PlanId int
@@ -10,9 +37,16 @@ type BudgetPlanItemUpdated struct {
 	WeeklyDuration time.Duration
 	// WeeklyOccurrences represents the number of days in a week that a budget is expected to be used.
 	WeeklyOccurrences int
-	Icon              string
-	Color             string
-	Position          int
+	// DailyDurations optionally describes how the weekly duration should be distributed across weekdays.
+	DailyDurations map[time.Weekday]time.Duration
+	Icon           string
+	Color          string
+	Position       int
+}
+
+type BudgetPlanItemDeleted struct {
+	Id     int
+	PlanId int
 }
 
 type CalendarEventCreated struct {
@@ -21,4 +55,88 @@ type CalendarEventCreated struct {
 	StartTime    time.Time
 	EndTime      time.Time
 	BudgetItemId int
+	// TaskId is the external task (e.g. ClickUp task id) the time was spent on, if any.
+	TaskId string
+	// Sandbox is set for generated demo events that should not be exported.
+	Sandbox bool
+}
+
+// CalendarEventUpdated is published when a stored calendar event is modified. Parts split off the event
+// are published as created.
+type CalendarEventUpdated struct {
+	UID          string
+	Summary      string
+	StartTime    time.Time
+	EndTime      time.Time
+	BudgetItemId int
+	TaskId       string
+	Sandbox      bool
+}
+
+type CalendarEventDeleted struct {
+	UID string
+}
+
+type CurrentEventStarted struct {
+	BudgetItemId int
+	Name         string
+	StartTime    time.Time
+}
+
+// CurrentEventStopped is published when the running event ends because another one is started or it is stopped.
+type CurrentEventStopped struct {
+	BudgetItemId int
+	Name         string
+	StartTime    time.Time
+	EndTime      time.Time
+	// RoundedDuration is the duration rounded according to the user's rounding settings, 0 when rounding is disabled
+	RoundedDuration time.Duration
+}
+
+type WeeklyPlanWeekGenerated struct {
+	BudgetPlanId int
+	// Week is the ISO 8601 week, e.g. "2025-W03"
+	Week      string
+	StartDate time.Time
+	ItemCount int
+}
+
+// WeeklyPlanWeekChanged is published when items are added to or removed from a week's plan.
+type WeeklyPlanWeekChanged struct {
+	// Week is the ISO 8601 week, e.g. "2025-W03"
+	Week string
+}
+
+// BudgetAlertTriggered is published when the time tracked for a weekly plan item crosses an alert threshold.
+type BudgetAlertTriggered struct {
+	BudgetItemId int
+	Name         string
+	// Week is the ISO 8601 week, e.g. "2025-W03"
+	Week string
+	// Threshold is the crossed percentage of the planned time, e.g. 100
+	Threshold  int
+	Percentage float64
+	Tracked    time.Duration
+	Planned    time.Duration
+}
+
+// UserDeleted is published after the user and all the data they own have been deleted.
+type UserDeleted struct {
+	Id  int
+	Uid string
+}
+
+// IntegrationStale is published once, when the user's token of an integration has been invalid for too long.
+// The integration's sync is disabled at DisableAt unless the user reconnects it.
+type IntegrationStale struct {
+	UserId       int
+	Provider     string
+	InvalidSince time.Time
+	DisableAt    time.Time
+}
+
+// IntegrationConnected is published when the user has authorized Klokku with a third-party provider.
+type IntegrationConnected struct {
+	UserId   int
+	Provider string
 }
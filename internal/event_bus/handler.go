@@ -0,0 +1,225 @@
+package event_bus
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/internal/rest"
+	log "github.com/sirupsen/logrus"
+)
+
+type DeadLetterDTO struct {
+	Id         int64  `json:"id"`
+	EventType  string `json:"eventType"`
+	Subscriber string `json:"subscriber"`
+	// UserUid identifies the user who published the event, empty when there was none
+	UserUid   string          `json:"userUid"`
+	Payload   json.RawMessage `json:"payload" swaggertype:"object"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"lastError"`
+	// PublishedAt is when the event was published
+	PublishedAt time.Time `json:"publishedAt"`
+	FailedAt    time.Time `json:"failedAt"`
+}
+
+type ReplayResultDTO struct {
+	Replayed int `json:"replayed"`
+}
+
+type Handler struct {
+	outbox *Outbox
+}
+
+func NewHandler(outbox *Outbox) *Handler {
+	return &Handler{outbox: outbox}
+}
+
+// ListDeadLetters godoc
+// @Summary List dead-lettered events
+// @Description List the events durable subscribers failed to handle in all attempts, the newest first. To get the
+// @Description next page pass the id of the last returned dead letter as 'before'. Requires an admin user.
+// @Tags Admin
+// @Produce json
+// @Param eventType query string false "Only events of this type, e.g. calendar.event.created"
+// @Param subscriber query string false "Only events of this subscriber, e.g. webhook_subscription"
+// @Param before query int false "Only dead letters with a lower id"
+// @Param limit query int false "Maximum number of dead letters" default(100) maximum(500)
+// @Success 200 {array} DeadLetterDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid filter"
+// @Failure 403 {string} string "User not found or not an admin"
+// @Router /api/admin/events/dead-letters [get]
+// @Security XUserId
+func (h *Handler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	filter := DeadLetterFilter{
+		EventType:  EventType(query.Get("eventType")),
+		Subscriber: query.Get("subscriber"),
+	}
+	if value := query.Get("before"); value != "" {
+		before, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || before <= 0 {
+			writeBadRequest(w, "Invalid before", "'before' must be a positive integer")
+			return
+		}
+		filter.BeforeId = before
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			writeBadRequest(w, "Invalid limit", "'limit' must be a positive integer")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	deadLetters, err := h.outbox.ListDeadLetters(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, ErrInvalidFilter) {
+			writeBadRequest(w, "Invalid filter", err.Error())
+			return
+		}
+		log.Errorf("Failed to list dead letters: %v", err)
+		http.Error(w, "Failed to list dead letters", http.StatusInternalServerError)
+		return
+	}
+
+	dtos := make([]DeadLetterDTO, 0, len(deadLetters))
+	for _, d := range deadLetters {
+		dtos = append(dtos, deadLetterToDTO(d))
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		log.Errorf("Failed to encode dead letters: %v", err)
+		http.Error(w, "Failed to encode dead letters", http.StatusInternalServerError)
+	}
+}
+
+// GetDeadLetter godoc
+// @Summary Get a dead-lettered event
+// @Description Get a dead-lettered event with its payload and the error of its last delivery attempt. Requires an
+// @Description admin user.
+// @Tags Admin
+// @Produce json
+// @Param id path int true "Dead letter ID"
+// @Success 200 {object} DeadLetterDTO
+// @Failure 400 {string} string "Invalid id"
+// @Failure 403 {string} string "User not found or not an admin"
+// @Failure 404 {string} string "Dead letter not found"
+// @Router /api/admin/events/dead-letters/{id} [get]
+// @Security XUserId
+func (h *Handler) GetDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid id", http.StatusBadRequest)
+		return
+	}
+
+	deadLetter, err := h.outbox.GetDeadLetter(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrDeadLetterNotFound) {
+			http.Error(w, "Dead letter not found", http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to get dead letter: %v", err)
+		http.Error(w, "Failed to get dead letter", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(deadLetterToDTO(deadLetter)); err != nil {
+		log.Errorf("Failed to encode dead letter: %v", err)
+		http.Error(w, "Failed to encode dead letter", http.StatusInternalServerError)
+	}
+}
+
+// ReplayDeadLetter godoc
+// @Summary Replay a dead-lettered event
+// @Description Deliver the dead-lettered event to its subscriber again, with a fresh set of attempts. It is removed
+// @Description from the dead letters, and returns there when it fails again. Requires an admin user.
+// @Tags Admin
+// @Param id path int true "Dead letter ID"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Invalid id"
+// @Failure 403 {string} string "User not found or not an admin"
+// @Failure 404 {string} string "Dead letter not found"
+// @Router /api/admin/events/dead-letters/{id}/replay [post]
+// @Security XUserId
+func (h *Handler) ReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.outbox.Replay(r.Context(), id); err != nil {
+		if errors.Is(err, ErrDeadLetterNotFound) {
+			http.Error(w, "Dead letter not found", http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to replay dead letter: %v", err)
+		http.Error(w, "Failed to replay dead letter", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReplayDeadLetters godoc
+// @Summary Replay dead-lettered events
+// @Description Deliver all dead-lettered events matching the filter to their subscribers again, e.g. once the
+// @Description third party that was down is back. Without a filter all dead letters are replayed. Requires an
+// @Description admin user.
+// @Tags Admin
+// @Produce json
+// @Param eventType query string false "Only events of this type"
+// @Param subscriber query string false "Only events of this subscriber"
+// @Success 200 {object} ReplayResultDTO
+// @Failure 403 {string} string "User not found or not an admin"
+// @Router /api/admin/events/dead-letters/replay [post]
+// @Security XUserId
+func (h *Handler) ReplayDeadLetters(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := DeadLetterFilter{
+		EventType:  EventType(query.Get("eventType")),
+		Subscriber: query.Get("subscriber"),
+	}
+
+	replayed, err := h.outbox.ReplayAll(r.Context(), filter)
+	if err != nil {
+		log.Errorf("Failed to replay dead letters after replaying %d: %v", replayed, err)
+		http.Error(w, "Failed to replay dead letters", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ReplayResultDTO{Replayed: replayed}); err != nil {
+		log.Errorf("Failed to encode replay result: %v", err)
+		http.Error(w, "Failed to encode replay result", http.StatusInternalServerError)
+	}
+}
+
+func deadLetterToDTO(d DeadLetter) DeadLetterDTO {
+	return DeadLetterDTO{
+		Id:          d.Id,
+		EventType:   string(d.Event.Type),
+		Subscriber:  d.Subscriber,
+		UserUid:     d.UserUid,
+		Payload:     d.Event.Payload,
+		Attempts:    d.Attempts,
+		LastError:   d.LastError,
+		PublishedAt: d.Event.CreatedAt,
+		FailedAt:    d.FailedAt,
+	}
+}
+
+func writeBadRequest(w http.ResponseWriter, message string, details string) {
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+		Error:   message,
+		Details: details,
+	})
+}
@@ -0,0 +1,246 @@
+package event_bus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/klokku/klokku/internal/database/dbtx"
+	"github.com/klokku/klokku/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	deliveryInterval = 5 * time.Second
+	deliveriesPerRun = 100
+	// deliveryLease is how long a claimed delivery is not handed out again, it has to outlast a delivery attempt
+	deliveryLease = 5 * time.Minute
+	// deliveredRetention is how long delivered events are kept for troubleshooting
+	deliveredRetention = 7 * 24 * time.Hour
+	cleanupInterval    = time.Hour
+	maxErrorLength     = 1000
+)
+
+// retryDelays are the waits after each failed attempt. A delivery is moved to the dead letters after
+// len(retryDelays)+1 attempts.
+var retryDelays = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 6 * time.Hour}
+
+var ErrDuplicateSubscriber = errors.New("durable subscriber registered twice")
+
+// UserContext carries the user who published an event to its durable subscribers, which handle it in the
+// background. It is implemented by the user package, which the event bus cannot depend on.
+type UserContext interface {
+	// UserId returns the user of ctx, false when there is none.
+	UserId(ctx context.Context) (int, bool)
+	// WithUser returns ctx with the given user, as it was when the event was published.
+	WithUser(ctx context.Context, userId int) (context.Context, error)
+}
+
+// durableSubscriber is a subscriber whose events are stored in the outbox and delivered until it handles them.
+type durableSubscriber struct {
+	name string
+	// accepts reports whether the data of an event is of the type the subscriber expects
+	accepts func(data any) bool
+	// deliver decodes a stored event and handles it
+	deliver func(ctx context.Context, event OutboxEvent) error
+	// handle handles a published event right away, used when the bus has no outbox
+	handle handler
+}
+
+// SubscribeDurable registers a handler of events of the given type, that must not be lost, e.g. because it
+// notifies a third party. Once the bus uses an outbox, the events are stored in the transaction of the change that
+// published them and handled in the background, retrying while the handler returns an error. The handler then
+// gets the event decoded from JSON, so T must survive a JSON round trip. Without an outbox the handler runs
+// synchronously and its errors are only logged.
+//
+// The subscriber name identifies the handler in the stored deliveries, so it must not change between releases.
+// Registering the same name twice for an event type fails with ErrDuplicateSubscriber.
+func SubscribeDurable[T any](eb *EventBus, subscriber string, eventType EventType, h func(EventT[T]) error) error {
+	accepts := func(data any) bool {
+		_, ok := data.(T)
+		return ok
+	}
+	deliver := func(ctx context.Context, event OutboxEvent) error {
+		var data T
+		if err := json.Unmarshal(event.Payload, &data); err != nil {
+			return fmt.Errorf("failed to decode %s event %d: %w", event.Type, event.Id, err)
+		}
+		return h(EventT[T]{ctx: ctx, Type: event.Type, Timestamp: event.CreatedAt, Data: data})
+	}
+	handle := func(e Event) error {
+		payload, ok := e.Data.(T)
+		if !ok {
+			return nil
+		}
+		err := h(EventT[T]{ctx: e.ctx, Type: e.Type, Timestamp: e.Timestamp, Data: payload})
+		if err != nil {
+			log.Errorf("EventBus: subscriber %s failed to handle event %s: %v", subscriber, e.Type, err)
+		}
+		return nil
+	}
+	return eb.subscribeDurable(eventType, &durableSubscriber{name: subscriber, accepts: accepts, deliver: deliver, handle: handle})
+}
+
+func (eb *EventBus) subscribeDurable(eventType EventType, subscriber *durableSubscriber) error {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	if eb.durable[eventType] == nil {
+		eb.durable[eventType] = make(map[string]*durableSubscriber)
+	}
+	if _, ok := eb.durable[eventType][subscriber.name]; ok {
+		return fmt.Errorf("%w: %s of event %s", ErrDuplicateSubscriber, subscriber.name, eventType)
+	}
+	eb.durable[eventType][subscriber.name] = subscriber
+	return nil
+}
+
+func (eb *EventBus) durableSubscriber(eventType EventType, name string) (*durableSubscriber, bool) {
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+	subscriber, ok := eb.durable[eventType][name]
+	return subscriber, ok
+}
+
+// UseOutbox makes the bus store the events of durable subscribers in the outbox, which delivers them.
+func (eb *EventBus) UseOutbox(outbox *Outbox) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.outbox = outbox
+	outbox.bus = eb
+}
+
+// Outbox stores the events of durable subscribers and delivers them in the background.
+type Outbox struct {
+	repo  OutboxRepository
+	users UserContext
+	clock utils.Clock
+	bus   *EventBus
+	// wake triggers the worker right after new events are stored
+	wake chan struct{}
+}
+
+func NewOutbox(repo OutboxRepository, users UserContext) *Outbox {
+	return &Outbox{
+		repo:  repo,
+		users: users,
+		clock: &utils.SystemClock{},
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// enqueue stores the event for the given subscribers, within the transaction of the event context if there is one.
+func (o *Outbox) enqueue(e Event, subscribers []*durableSubscriber) error {
+	ctx := e.Context()
+	payload, err := json.Marshal(e.Data)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	names := make([]string, 0, len(subscribers))
+	for _, subscriber := range subscribers {
+		names = append(names, subscriber.name)
+	}
+	userId, _ := o.users.UserId(ctx)
+	event := OutboxEvent{Type: e.Type, Payload: payload, UserId: userId, CreatedAt: e.Timestamp}
+	if err := o.repo.Enqueue(ctx, event, names); err != nil {
+		return err
+	}
+	if !dbtx.AfterCommit(ctx, o.notify) {
+		o.notify()
+	}
+	return nil
+}
+
+func (o *Outbox) notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// StartWorker delivers the stored events until ctx is done, and removes the ones delivered long ago.
+func (o *Outbox) StartWorker(ctx context.Context) {
+	ticker := time.NewTicker(deliveryInterval)
+	defer ticker.Stop()
+	log.Info("Event outbox worker started")
+	var lastCleanup time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Event outbox worker stopped")
+			return
+		case <-ticker.C:
+		case <-o.wake:
+		}
+		now := o.clock.Now()
+		delivered, err := o.DeliverDue(ctx, now)
+		if err != nil {
+			log.Errorf("failed to deliver events: %v", err)
+		} else if delivered == deliveriesPerRun {
+			// There may be more due, do not wait for the next tick
+			o.notify()
+		}
+		if now.Sub(lastCleanup) >= cleanupInterval {
+			lastCleanup = now
+			if deleted, err := o.repo.DeleteDelivered(ctx, now.Add(-deliveredRetention)); err != nil {
+				log.Errorf("failed to delete delivered events: %v", err)
+			} else if deleted > 0 {
+				log.Debugf("deleted %d delivered events", deleted)
+			}
+		}
+	}
+}
+
+// DeliverDue hands the deliveries due at now to their subscribers and returns how many were attempted.
+func (o *Outbox) DeliverDue(ctx context.Context, now time.Time) (int, error) {
+	due, err := o.repo.ClaimDue(ctx, now, deliveryLease, deliveriesPerRun)
+	if err != nil {
+		return 0, err
+	}
+	for _, d := range due {
+		if err := o.attempt(ctx, d); err != nil {
+			return 0, err
+		}
+	}
+	return len(due), nil
+}
+
+// attempt delivers the event once and records the outcome, scheduling the next attempt when it failed.
+func (o *Outbox) attempt(ctx context.Context, d Delivery) error {
+	deliveryErr := o.deliver(ctx, d)
+	now := o.clock.Now()
+	if deliveryErr == nil {
+		return o.repo.MarkDelivered(ctx, d.Event.Id, d.Subscriber, now)
+	}
+
+	attempts := d.Attempts + 1
+	if attempts > len(retryDelays) {
+		log.Errorf("delivery of %s event %d to %s failed after %d attempts, moving it to the dead letters: %v",
+			d.Event.Type, d.Event.Id, d.Subscriber, attempts, deliveryErr)
+		return o.repo.DeadLetter(ctx, d.Event.Id, d.Subscriber, attempts,
+			utils.Truncate(deliveryErr.Error(), maxErrorLength), now)
+	}
+	log.Warnf("delivery of %s event %d to %s failed, retrying: %v", d.Event.Type, d.Event.Id, d.Subscriber, deliveryErr)
+	return o.repo.MarkFailed(ctx, d.Event.Id, d.Subscriber, attempts, now.Add(retryDelays[attempts-1]),
+		utils.Truncate(deliveryErr.Error(), maxErrorLength))
+}
+
+func (o *Outbox) deliver(ctx context.Context, d Delivery) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("subscriber panic: %v", r)
+		}
+	}()
+	subscriber, ok := o.bus.durableSubscriber(d.Event.Type, d.Subscriber)
+	if !ok {
+		return fmt.Errorf("no subscriber %s of event %s", d.Subscriber, d.Event.Type)
+	}
+	if d.Event.UserId != 0 {
+		ctx, err = o.users.WithUser(ctx, d.Event.UserId)
+		if err != nil {
+			return fmt.Errorf("failed to restore user %d: %w", d.Event.UserId, err)
+		}
+	}
+	return subscriber.deliver(ctx, d.Event)
+}
@@ -0,0 +1,256 @@
+package event_bus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/klokku/klokku/internal/database/dbtx"
+)
+
+// OutboxEvent is an event stored for its durable subscribers.
+type OutboxEvent struct {
+	Id      int64
+	Type    EventType
+	Payload json.RawMessage
+	// UserId is the user who published the event, 0 when there was none
+	UserId    int
+	CreatedAt time.Time
+}
+
+// Delivery is a pending delivery of an event to one durable subscriber.
+type Delivery struct {
+	Event      OutboxEvent
+	Subscriber string
+	// Attempts made before this one
+	Attempts int
+}
+
+type OutboxRepository interface {
+	// Enqueue stores the event with a pending delivery for each subscriber. It writes within the transaction of ctx
+	// when there is one, so the event is only stored together with the change it describes.
+	Enqueue(ctx context.Context, event OutboxEvent, subscribers []string) error
+	// ClaimDue returns up to limit deliveries due at now, the oldest events first. Claimed deliveries are not due
+	// again until the lease passes, so other instances do not deliver them at the same time.
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error)
+	MarkDelivered(ctx context.Context, eventId int64, subscriber string, at time.Time) error
+	// MarkFailed records a failed attempt, the delivery is retried at nextAttemptAt.
+	MarkFailed(ctx context.Context, eventId int64, subscriber string, attempts int, nextAttemptAt time.Time, lastError string) error
+	// DeadLetter gives up the delivery, moving the event to the dead letters of the subscriber.
+	DeadLetter(ctx context.Context, eventId int64, subscriber string, attempts int, lastError string, at time.Time) error
+	// ListDeadLetters returns the dead letters matching the filter, the newest first.
+	ListDeadLetters(ctx context.Context, filter DeadLetterFilter) ([]DeadLetter, error)
+	// GetDeadLetter returns ErrDeadLetterNotFound when there is no dead letter with the given id.
+	GetDeadLetter(ctx context.Context, id int64) (DeadLetter, error)
+	// Replay moves the dead letters with the given ids back to the outbox, to be delivered at the given time. It
+	// returns how many were moved, ids of dead letters that do not exist are skipped.
+	Replay(ctx context.Context, ids []int64, at time.Time) (int, error)
+	// DeleteDelivered removes the events created before the given time that have been delivered to all subscribers.
+	DeleteDelivered(ctx context.Context, before time.Time) (int, error)
+}
+
+type OutboxRepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewOutboxRepository(db *pgxpool.Pool) *OutboxRepositoryImpl {
+	return &OutboxRepositoryImpl{db: db}
+}
+
+// queryer returns the transaction of ctx, or the pool when there is none.
+func (r *OutboxRepositoryImpl) queryer(ctx context.Context) interface {
+	Exec(ctx context.Context, query string, args ...any) (pgconn.CommandTag, error)
+} {
+	if tx, ok := dbtx.Tx(ctx); ok {
+		return tx
+	}
+	return r.db
+}
+
+func (r *OutboxRepositoryImpl) Enqueue(ctx context.Context, event OutboxEvent, subscribers []string) error {
+	query := `WITH event AS (
+				INSERT INTO event_outbox (event_type, payload, user_id, created_at)
+				VALUES ($1, $2, NULLIF($3, 0), $4)
+				RETURNING id
+			  )
+			  INSERT INTO event_delivery (event_id, subscriber, next_attempt_at)
+			  SELECT event.id, subscriber, $4 FROM event, unnest($5::text[]) AS subscriber`
+	_, err := r.queryer(ctx).Exec(ctx, query, string(event.Type), event.Payload, event.UserId, event.CreatedAt, subscribers)
+	if err != nil {
+		return fmt.Errorf("failed to store event %s in the outbox: %w", event.Type, err)
+	}
+	return nil
+}
+
+func (r *OutboxRepositoryImpl) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error) {
+	query := `WITH due AS (
+				SELECT event_id, subscriber FROM event_delivery
+				WHERE status = 'pending' AND next_attempt_at <= $1
+				ORDER BY event_id
+				LIMIT $3
+				FOR UPDATE SKIP LOCKED
+			  ), claimed AS (
+				UPDATE event_delivery d SET next_attempt_at = $2
+				FROM due
+				WHERE d.event_id = due.event_id AND d.subscriber = due.subscriber
+				RETURNING d.event_id, d.subscriber, d.attempts
+			  )
+			  SELECT e.id, e.event_type, e.payload, COALESCE(e.user_id, 0), e.created_at, c.subscriber, c.attempts
+			  FROM claimed c JOIN event_outbox e ON e.id = c.event_id
+			  ORDER BY e.id, c.subscriber`
+	rows, err := r.db.Query(ctx, query, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim due event deliveries: %w", err)
+	}
+	deliveries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Delivery, error) {
+		var d Delivery
+		var eventType string
+		err := row.Scan(&d.Event.Id, &eventType, &d.Event.Payload, &d.Event.UserId, &d.Event.CreatedAt, &d.Subscriber, &d.Attempts)
+		d.Event.Type = EventType(eventType)
+		return d, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read due event deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (r *OutboxRepositoryImpl) MarkDelivered(ctx context.Context, eventId int64, subscriber string, at time.Time) error {
+	query := `UPDATE event_delivery
+			  SET status = 'delivered', attempts = attempts + 1, next_attempt_at = NULL, last_error = NULL, delivered_at = $3
+			  WHERE event_id = $1 AND subscriber = $2`
+	if _, err := r.db.Exec(ctx, query, eventId, subscriber, at); err != nil {
+		return fmt.Errorf("failed to mark event %d delivered to %s: %w", eventId, subscriber, err)
+	}
+	return nil
+}
+
+func (r *OutboxRepositoryImpl) MarkFailed(ctx context.Context, eventId int64, subscriber string, attempts int,
+	nextAttemptAt time.Time, lastError string) error {
+	query := `UPDATE event_delivery
+			  SET attempts = $3, next_attempt_at = $4, last_error = $5
+			  WHERE event_id = $1 AND subscriber = $2`
+	if _, err := r.db.Exec(ctx, query, eventId, subscriber, attempts, nextAttemptAt, lastError); err != nil {
+		return fmt.Errorf("failed to record failed delivery of event %d to %s: %w", eventId, subscriber, err)
+	}
+	return nil
+}
+
+func (r *OutboxRepositoryImpl) DeleteDelivered(ctx context.Context, before time.Time) (int, error) {
+	query := `DELETE FROM event_outbox e
+			  WHERE e.created_at < $1
+			    AND NOT EXISTS (SELECT 1 FROM event_delivery d WHERE d.event_id = e.id AND d.status <> 'delivered')`
+	tag, err := r.db.Exec(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete delivered events: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func (r *OutboxRepositoryImpl) DeadLetter(ctx context.Context, eventId int64, subscriber string, attempts int,
+	lastError string, at time.Time) error {
+	query := `WITH given_up AS (
+				DELETE FROM event_delivery WHERE event_id = $1 AND subscriber = $2
+				RETURNING event_id, subscriber
+			  )
+			  INSERT INTO event_dead_letter (event_type, payload, user_id, created_at, subscriber, attempts, last_error, failed_at)
+			  SELECT e.event_type, e.payload, e.user_id, e.created_at, given_up.subscriber, $3, $4, $5
+			  FROM given_up JOIN event_outbox e ON e.id = given_up.event_id`
+	if _, err := r.db.Exec(ctx, query, eventId, subscriber, attempts, lastError, at); err != nil {
+		return fmt.Errorf("failed to dead-letter event %d of %s: %w", eventId, subscriber, err)
+	}
+	return nil
+}
+
+const deadLetterColumns = `d.id, d.event_type, d.payload, COALESCE(d.user_id, 0), COALESCE(u.uid, ''), d.created_at,
+	d.subscriber, d.attempts, d.last_error, d.failed_at`
+
+func scanDeadLetter(row pgx.Row) (DeadLetter, error) {
+	var d DeadLetter
+	var eventType string
+	err := row.Scan(&d.Id, &eventType, &d.Event.Payload, &d.Event.UserId, &d.UserUid, &d.Event.CreatedAt,
+		&d.Subscriber, &d.Attempts, &d.LastError, &d.FailedAt)
+	d.Event.Type = EventType(eventType)
+	return d, err
+}
+
+func (r *OutboxRepositoryImpl) ListDeadLetters(ctx context.Context, filter DeadLetterFilter) ([]DeadLetter, error) {
+	conditions := make([]string, 0)
+	args := make([]any, 0)
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", "$"+strconv.Itoa(len(args))))
+	}
+	if filter.EventType != "" {
+		addCondition("d.event_type = ?", string(filter.EventType))
+	}
+	if filter.Subscriber != "" {
+		addCondition("d.subscriber = ?", filter.Subscriber)
+	}
+	if filter.BeforeId > 0 {
+		addCondition("d.id < ?", filter.BeforeId)
+	}
+
+	query := `SELECT ` + deadLetterColumns + ` FROM event_dead_letter d LEFT JOIN users u ON u.id = d.user_id`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += ` ORDER BY d.id DESC LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	deadLetters := make([]DeadLetter, 0)
+	for rows.Next() {
+		d, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dead letter: %w", err)
+		}
+		deadLetters = append(deadLetters, d)
+	}
+	return deadLetters, rows.Err()
+}
+
+func (r *OutboxRepositoryImpl) GetDeadLetter(ctx context.Context, id int64) (DeadLetter, error) {
+	query := `SELECT ` + deadLetterColumns + ` FROM event_dead_letter d LEFT JOIN users u ON u.id = d.user_id
+			  WHERE d.id = $1`
+	d, err := scanDeadLetter(r.db.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return DeadLetter{}, ErrDeadLetterNotFound
+		}
+		return DeadLetter{}, fmt.Errorf("failed to get dead letter %d: %w", id, err)
+	}
+	return d, nil
+}
+
+func (r *OutboxRepositoryImpl) Replay(ctx context.Context, ids []int64, at time.Time) (int, error) {
+	// Each dead letter becomes a new event, so its delivery to other subscribers is not repeated
+	query := `WITH dead AS (
+				DELETE FROM event_dead_letter WHERE id = ANY($1)
+				RETURNING event_type, payload, user_id, created_at, subscriber
+			  ), replayed AS (
+				SELECT nextval(pg_get_serial_sequence('event_outbox', 'id')) AS event_id, dead.* FROM dead
+			  ), event AS (
+				INSERT INTO event_outbox (id, event_type, payload, user_id, created_at)
+				SELECT event_id, event_type, payload, user_id, created_at FROM replayed
+			  )
+			  INSERT INTO event_delivery (event_id, subscriber, next_attempt_at)
+			  SELECT event_id, subscriber, $2 FROM replayed`
+	tag, err := r.db.Exec(ctx, query, ids, at)
+	if err != nil {
+		return 0, fmt.Errorf("failed to replay dead letters: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
@@ -0,0 +1,210 @@
+package event_bus
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// StoredDelivery is the state of a delivery kept by OutboxRepositoryStub.
+type StoredDelivery struct {
+	Delivery
+	Status        string
+	NextAttemptAt *time.Time
+	LastError     string
+	DeliveredAt   *time.Time
+}
+
+type OutboxRepositoryStub struct {
+	mu         sync.Mutex
+	nextId     int64
+	events     map[int64]OutboxEvent
+	Deliveries []*StoredDelivery
+
+	nextDeadLetterId int64
+	DeadLetters      []DeadLetter
+}
+
+func NewOutboxRepositoryStub() *OutboxRepositoryStub {
+	return &OutboxRepositoryStub{nextId: 1, events: make(map[int64]OutboxEvent)}
+}
+
+func (r *OutboxRepositoryStub) Enqueue(ctx context.Context, event OutboxEvent, subscribers []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event.Id = r.nextId
+	r.nextId++
+	r.events[event.Id] = event
+	for _, subscriber := range subscribers {
+		nextAttemptAt := event.CreatedAt
+		r.Deliveries = append(r.Deliveries, &StoredDelivery{
+			Delivery:      Delivery{Event: event, Subscriber: subscriber},
+			Status:        "pending",
+			NextAttemptAt: &nextAttemptAt,
+		})
+	}
+	return nil
+}
+
+func (r *OutboxRepositoryStub) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var due []Delivery
+	for _, d := range r.Deliveries {
+		if len(due) == limit {
+			break
+		}
+		if d.Status != "pending" || d.NextAttemptAt == nil || d.NextAttemptAt.After(now) {
+			continue
+		}
+		leaseEnd := now.Add(lease)
+		d.NextAttemptAt = &leaseEnd
+		due = append(due, d.Delivery)
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].Event.Id < due[j].Event.Id })
+	return due, nil
+}
+
+func (r *OutboxRepositoryStub) MarkDelivered(ctx context.Context, eventId int64, subscriber string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if d := r.find(eventId, subscriber); d != nil {
+		d.Status = "delivered"
+		d.Attempts++
+		d.NextAttemptAt = nil
+		d.LastError = ""
+		d.DeliveredAt = &at
+	}
+	return nil
+}
+
+func (r *OutboxRepositoryStub) MarkFailed(ctx context.Context, eventId int64, subscriber string, attempts int,
+	nextAttemptAt time.Time, lastError string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if d := r.find(eventId, subscriber); d != nil {
+		d.Attempts = attempts
+		d.NextAttemptAt = &nextAttemptAt
+		d.LastError = lastError
+	}
+	return nil
+}
+
+func (r *OutboxRepositoryStub) DeadLetter(ctx context.Context, eventId int64, subscriber string, attempts int,
+	lastError string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := r.find(eventId, subscriber)
+	if d == nil {
+		return nil
+	}
+	r.nextDeadLetterId++
+	r.DeadLetters = append(r.DeadLetters, DeadLetter{
+		Id:         r.nextDeadLetterId,
+		Event:      d.Event,
+		Subscriber: subscriber,
+		Attempts:   attempts,
+		LastError:  lastError,
+		FailedAt:   at,
+	})
+	r.remove(func(d *StoredDelivery) bool { return d.Event.Id == eventId && d.Subscriber == subscriber })
+	return nil
+}
+
+func (r *OutboxRepositoryStub) ListDeadLetters(ctx context.Context, filter DeadLetterFilter) ([]DeadLetter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := make([]DeadLetter, 0)
+	for i := len(r.DeadLetters) - 1; i >= 0 && len(result) < filter.Limit; i-- {
+		d := r.DeadLetters[i]
+		if (filter.EventType != "" && d.Event.Type != filter.EventType) ||
+			(filter.Subscriber != "" && d.Subscriber != filter.Subscriber) ||
+			(filter.BeforeId > 0 && d.Id >= filter.BeforeId) {
+			continue
+		}
+		result = append(result, d)
+	}
+	return result, nil
+}
+
+func (r *OutboxRepositoryStub) GetDeadLetter(ctx context.Context, id int64) (DeadLetter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range r.DeadLetters {
+		if d.Id == id {
+			return d, nil
+		}
+	}
+	return DeadLetter{}, ErrDeadLetterNotFound
+}
+
+func (r *OutboxRepositoryStub) Replay(ctx context.Context, ids []int64, at time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	replayed := 0
+	kept := r.DeadLetters[:0]
+	for _, d := range r.DeadLetters {
+		if !slices.Contains(ids, d.Id) {
+			kept = append(kept, d)
+			continue
+		}
+		event := d.Event
+		event.Id = r.nextId
+		r.nextId++
+		r.events[event.Id] = event
+		nextAttemptAt := at
+		r.Deliveries = append(r.Deliveries, &StoredDelivery{
+			Delivery:      Delivery{Event: event, Subscriber: d.Subscriber},
+			Status:        "pending",
+			NextAttemptAt: &nextAttemptAt,
+		})
+		replayed++
+	}
+	r.DeadLetters = kept
+	return replayed, nil
+}
+
+func (r *OutboxRepositoryStub) DeleteDelivered(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	deleted := 0
+	for id, event := range r.events {
+		if !event.CreatedAt.Before(before) || !r.allDelivered(id) {
+			continue
+		}
+		delete(r.events, id)
+		r.remove(func(d *StoredDelivery) bool { return d.Event.Id == id })
+		deleted++
+	}
+	return deleted, nil
+}
+
+func (r *OutboxRepositoryStub) find(eventId int64, subscriber string) *StoredDelivery {
+	for _, d := range r.Deliveries {
+		if d.Event.Id == eventId && d.Subscriber == subscriber {
+			return d
+		}
+	}
+	return nil
+}
+
+func (r *OutboxRepositoryStub) remove(matches func(d *StoredDelivery) bool) {
+	kept := r.Deliveries[:0]
+	for _, d := range r.Deliveries {
+		if !matches(d) {
+			kept = append(kept, d)
+		}
+	}
+	r.Deliveries = kept
+}
+
+func (r *OutboxRepositoryStub) allDelivered(eventId int64) bool {
+	for _, d := range r.Deliveries {
+		if d.Event.Id == eventId && d.Status != "delivered" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,272 @@
+package event_bus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/klokku/klokku/internal/database/dbtx"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type userKey struct{}
+
+type userContextStub struct{}
+
+func (userContextStub) UserId(ctx context.Context) (int, bool) {
+	id, ok := ctx.Value(userKey{}).(int)
+	return id, ok
+}
+
+func (userContextStub) WithUser(ctx context.Context, userId int) (context.Context, error) {
+	return context.WithValue(ctx, userKey{}, userId), nil
+}
+
+type testPayload struct {
+	Name  string
+	Start time.Time
+}
+
+func setupOutbox(now time.Time) (*EventBus, *Outbox, *OutboxRepositoryStub) {
+	repo := NewOutboxRepositoryStub()
+	outbox := NewOutbox(repo, userContextStub{})
+	outbox.clock = &utils.MockClock{FixedNow: now}
+	bus := NewEventBus()
+	bus.UseOutbox(outbox)
+	return bus, outbox, repo
+}
+
+func TestOutbox_DeliverDue(t *testing.T) {
+	// Published events are due right away, at their timestamp
+	now := time.Now()
+
+	t.Run("should deliver stored events in the background with the publishing user", func(t *testing.T) {
+		// given
+		bus, outbox, repo := setupOutbox(now)
+		var received []testPayload
+		var receivedBy []int
+		SubscribeDurable[testPayload](bus, "test", "test.created", func(e EventT[testPayload]) error {
+			userId, _ := userContextStub{}.UserId(e.Context())
+			received = append(received, e.Data)
+			receivedBy = append(receivedBy, userId)
+			return nil
+		})
+		ctx := context.WithValue(context.Background(), userKey{}, 7)
+		payload := testPayload{Name: "Reading", Start: time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)}
+
+		// when
+		err := bus.Publish(NewEvent(ctx, "test.created", payload))
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, received)
+		require.Len(t, repo.Deliveries, 1)
+		assert.Equal(t, 7, repo.Deliveries[0].Event.UserId)
+
+		// when
+		delivered, err := outbox.DeliverDue(context.Background(), now.Add(time.Second))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+		assert.Equal(t, []testPayload{payload}, received)
+		assert.Equal(t, []int{7}, receivedBy)
+		assert.Equal(t, "delivered", repo.Deliveries[0].Status)
+	})
+
+	t.Run("should retry failed deliveries and dead-letter them after the last retry", func(t *testing.T) {
+		// given
+		bus, outbox, repo := setupOutbox(now)
+		SubscribeDurable[testPayload](bus, "test", "test.created", func(e EventT[testPayload]) error {
+			return errors.New("service unavailable")
+		})
+		require.NoError(t, bus.Publish(NewEvent(context.Background(), "test.created", testPayload{Name: "Reading"})))
+
+		// when
+		_, err := outbox.DeliverDue(context.Background(), now.Add(time.Second))
+
+		// then
+		require.NoError(t, err)
+		delivery := repo.Deliveries[0]
+		assert.Equal(t, "pending", delivery.Status)
+		assert.Equal(t, 1, delivery.Attempts)
+		assert.Equal(t, "service unavailable", delivery.LastError)
+		assert.Equal(t, now.Add(retryDelays[0]), *delivery.NextAttemptAt)
+
+		// when
+		for range len(retryDelays) {
+			_, err := outbox.DeliverDue(context.Background(), now.Add(24*time.Hour))
+			require.NoError(t, err)
+		}
+
+		// then
+		assert.Empty(t, repo.Deliveries)
+		require.Len(t, repo.DeadLetters, 1)
+		deadLetter := repo.DeadLetters[0]
+		assert.Equal(t, "test", deadLetter.Subscriber)
+		assert.Equal(t, EventType("test.created"), deadLetter.Event.Type)
+		assert.Equal(t, len(retryDelays)+1, deadLetter.Attempts)
+		assert.Equal(t, "service unavailable", deadLetter.LastError)
+	})
+
+	t.Run("should cut long error messages without splitting a character", func(t *testing.T) {
+		// given
+		bus, outbox, repo := setupOutbox(now)
+		// 'ł' takes two bytes, after the leading space the limit falls in the middle of one
+		message := " " + strings.Repeat("ł", maxErrorLength)
+		SubscribeDurable[testPayload](bus, "test", "test.created", func(e EventT[testPayload]) error {
+			return errors.New(message)
+		})
+		require.NoError(t, bus.Publish(NewEvent(context.Background(), "test.created", testPayload{})))
+
+		// when
+		_, err := outbox.DeliverDue(context.Background(), now.Add(time.Second))
+
+		// then
+		require.NoError(t, err)
+		lastError := repo.Deliveries[0].LastError
+		assert.True(t, utf8.ValidString(lastError))
+		assert.Equal(t, message[:maxErrorLength-1], lastError)
+	})
+
+	t.Run("should fail deliveries of handlers that panic", func(t *testing.T) {
+		// given
+		bus, outbox, repo := setupOutbox(now)
+		SubscribeDurable[testPayload](bus, "test", "test.created", func(e EventT[testPayload]) error {
+			panic("boom")
+		})
+		require.NoError(t, bus.Publish(NewEvent(context.Background(), "test.created", testPayload{})))
+
+		// when
+		_, err := outbox.DeliverDue(context.Background(), now.Add(time.Second))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "subscriber panic: boom", repo.Deliveries[0].LastError)
+	})
+}
+
+func TestEventBus_PublishDurable(t *testing.T) {
+	t.Run("should handle durable events synchronously without an outbox", func(t *testing.T) {
+		// given
+		bus := NewEventBus()
+		handled := 0
+		SubscribeDurable[testPayload](bus, "test", "test.created", func(e EventT[testPayload]) error {
+			handled++
+			return errors.New("service unavailable")
+		})
+
+		// when
+		err := bus.Publish(NewEvent(context.Background(), "test.created", testPayload{}))
+
+		// then
+		assert.NoError(t, err)
+		assert.Equal(t, 1, handled)
+	})
+
+	t.Run("should run the other handlers once the transaction commits", func(t *testing.T) {
+		// given
+		bus, _, repo := setupOutbox(time.Now())
+		SubscribeDurable[testPayload](bus, "test", "test.created", func(e EventT[testPayload]) error { return nil })
+		handled := 0
+		bus.Subscribe("test.created", func(e Event) error {
+			handled++
+			return nil
+		})
+		txCtx := dbtx.WithTx(context.Background(), nil)
+
+		// when
+		err := bus.Publish(NewEvent(txCtx, "test.created", testPayload{}))
+
+		// then
+		require.NoError(t, err)
+		assert.Len(t, repo.Deliveries, 1)
+		assert.Equal(t, 0, handled)
+
+		// when
+		dbtx.Committed(txCtx)
+
+		// then
+		assert.Equal(t, 1, handled)
+	})
+
+	t.Run("should not register a durable subscriber twice", func(t *testing.T) {
+		// given
+		bus := NewEventBus()
+		require.NoError(t, SubscribeDurable[testPayload](bus, "test", "test.created", func(e EventT[testPayload]) error { return nil }))
+
+		// when
+		err := SubscribeDurable[testPayload](bus, "test", "test.created", func(e EventT[testPayload]) error { return nil })
+
+		// then
+		assert.ErrorIs(t, err, ErrDuplicateSubscriber)
+		assert.NoError(t, SubscribeDurable[testPayload](bus, "other", "test.created", func(e EventT[testPayload]) error { return nil }))
+	})
+}
+
+func TestOutbox_Replay(t *testing.T) {
+	now := time.Now()
+
+	deadLetter := func(t *testing.T, bus *EventBus, outbox *Outbox, subscriber string, name string) {
+		SubscribeDurable[testPayload](bus, subscriber, "test.created", func(e EventT[testPayload]) error {
+			return errors.New("service unavailable")
+		})
+		require.NoError(t, bus.Publish(NewEvent(context.Background(), "test.created", testPayload{Name: name})))
+		for range len(retryDelays) + 1 {
+			_, err := outbox.DeliverDue(context.Background(), now.Add(24*time.Hour))
+			require.NoError(t, err)
+		}
+	}
+
+	t.Run("should deliver a replayed dead letter again", func(t *testing.T) {
+		// given
+		bus, outbox, repo := setupOutbox(now)
+		deadLetter(t, bus, outbox, "test", "Reading")
+		require.Len(t, repo.DeadLetters, 1)
+		id := repo.DeadLetters[0].Id
+
+		// when
+		err := outbox.Replay(context.Background(), id)
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, repo.DeadLetters)
+		require.Len(t, repo.Deliveries, 1)
+		assert.Equal(t, "test", repo.Deliveries[0].Subscriber)
+		assert.Equal(t, 0, repo.Deliveries[0].Attempts)
+		assert.Equal(t, "Reading", decodeName(t, repo.Deliveries[0].Event))
+		assert.ErrorIs(t, outbox.Replay(context.Background(), id), ErrDeadLetterNotFound)
+	})
+
+	t.Run("should replay the dead letters of a subscriber", func(t *testing.T) {
+		// given
+		bus, outbox, repo := setupOutbox(now)
+		deadLetter(t, bus, outbox, "first", "Reading")
+		deadLetter(t, bus, outbox, "second", "Writing")
+
+		// when
+		replayed, err := outbox.ReplayAll(context.Background(), DeadLetterFilter{Subscriber: "second"})
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, replayed)
+		require.Len(t, repo.DeadLetters, 2)
+		for _, d := range repo.DeadLetters {
+			assert.Equal(t, "first", d.Subscriber)
+		}
+		require.Len(t, repo.Deliveries, 1)
+		assert.Equal(t, "second", repo.Deliveries[0].Subscriber)
+	})
+}
+
+func decodeName(t *testing.T, event OutboxEvent) string {
+	var payload testPayload
+	require.NoError(t, json.Unmarshal(event.Payload, &payload))
+	return payload.Name
+}
@@ -0,0 +1,97 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type ComponentDTO struct {
+	Name   string `json:"name"`
+	Status Status `json:"status" enums:"up,down"`
+	// Error describes why the component is down
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+type ReportDTO struct {
+	Status     Status         `json:"status" enums:"up,down"`
+	Components []ComponentDTO `json:"components"`
+}
+
+type Handler struct {
+	liveness  *Checker
+	readiness *Checker
+	// draining is set when the application is shutting down
+	draining atomic.Bool
+}
+
+// NewHandler returns the handler of the probes. Liveness checks should only fail when restarting the application
+// helps, readiness checks whenever it cannot serve requests.
+func NewHandler(liveness *Checker, readiness *Checker) *Handler {
+	return &Handler{liveness: liveness, readiness: readiness}
+}
+
+// Liveness godoc
+// @Summary Liveness probe
+// @Description Check that the application is running and can serve the probe. Dependencies such as the database
+// @Description are only checked by the readiness probe, as restarting the application does not fix them.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} ReportDTO
+// @Failure 503 {object} ReportDTO "A component is down"
+// @Router /healthz [get]
+func (h *Handler) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, h.liveness.Run(r.Context()))
+}
+
+// Readiness godoc
+// @Summary Readiness probe
+// @Description Check that the application is ready to serve requests: the database is reachable, all migrations
+// @Description have been applied and the tokens of third-party integrations can be read. Responds with 503 when
+// @Description any component is down or the application is shutting down, it should then receive no traffic.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} ReportDTO
+// @Failure 503 {object} ReportDTO "A component is down"
+// @Router /readyz [get]
+func (h *Handler) Readiness(w http.ResponseWriter, r *http.Request) {
+	if h.draining.Load() {
+		writeReport(w, Report{Status: StatusDown, Components: []ComponentStatus{
+			{Name: "server", Status: StatusDown, Error: "shutting down"},
+		}})
+		return
+	}
+	writeReport(w, h.readiness.Run(r.Context()))
+}
+
+// SetDraining makes the readiness probe fail from now on, as the application is shutting down.
+func (h *Handler) SetDraining() {
+	h.draining.Store(true)
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	components := make([]ComponentDTO, 0, len(report.Components))
+	for _, component := range report.Components {
+		if component.Status != StatusUp {
+			log.Warnf("health check of %s failed: %s", component.Name, component.Error)
+		}
+		components = append(components, ComponentDTO{
+			Name:       component.Name,
+			Status:     component.Status,
+			Error:      component.Error,
+			DurationMs: component.Duration.Milliseconds(),
+		})
+	}
+	if report.Status != StatusUp {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(ReportDTO{Status: report.Status, Components: components}); err != nil {
+		log.Errorf("Failed to encode health report: %v", err)
+	}
+}
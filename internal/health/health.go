@@ -0,0 +1,120 @@
+// Package health reports whether the application and the components it depends on are working, for the liveness
+// and readiness probes of container orchestrators.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/klokku/klokku/internal/database"
+)
+
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// checkTimeout bounds each check, so a hanging dependency fails the probe instead of timing it out
+const checkTimeout = 2 * time.Second
+
+// Check verifies one component, returning an error when it is not available.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// ComponentStatus is the result of a Check.
+type ComponentStatus struct {
+	Name   string
+	Status Status
+	Error  string
+	// Duration is how long the check took
+	Duration time.Duration
+}
+
+// Report is the result of all checks, it is up only when every component is.
+type Report struct {
+	Status     Status
+	Components []ComponentStatus
+}
+
+// Checker runs a set of checks concurrently.
+type Checker struct {
+	checks []Check
+}
+
+func NewChecker(checks ...Check) *Checker {
+	return &Checker{checks: checks}
+}
+
+// Run runs all checks and returns their statuses in the order the checks were given.
+func (c *Checker) Run(ctx context.Context) Report {
+	components := make([]ComponentStatus, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			components[i] = runCheck(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Components: components}
+	for _, component := range components {
+		if component.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+func runCheck(ctx context.Context, check Check) ComponentStatus {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	start := time.Now()
+	err := check.Run(ctx)
+	status := ComponentStatus{Name: check.Name, Status: StatusUp, Duration: time.Since(start)}
+	if err != nil {
+		status.Status = StatusDown
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// DatabaseCheck verifies that a connection to the database can be acquired and used.
+func DatabaseCheck(db *pgxpool.Pool) Check {
+	return Check{Name: "database", Run: db.Ping}
+}
+
+// MigrationsCheck verifies that all migrations shipped with the application have been applied successfully,
+// e.g. after another instance running an older version started first.
+func MigrationsCheck(db *pgxpool.Pool) Check {
+	return Check{Name: "migrations", Run: func(ctx context.Context) error {
+		status, err := database.GetMigrationStatus(ctx, db)
+		if err != nil {
+			return err
+		}
+		if status.Dirty {
+			return fmt.Errorf("migration %d failed and has to be fixed manually", status.Version)
+		}
+		if pending := status.Pending(); pending > 0 {
+			return fmt.Errorf("%d pending migrations, the database is at version %d of %d", pending, status.Version, status.Latest)
+		}
+		return nil
+	}}
+}
+
+type TokenStore interface {
+	Ping(ctx context.Context) error
+}
+
+// TokenStoreCheck verifies that the tokens of the third-party integrations can be read.
+func TokenStoreCheck(store TokenStore) Check {
+	return Check{Name: "tokenStore", Run: store.Ping}
+}
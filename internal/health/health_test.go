@@ -0,0 +1,87 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checkReturning(name string, err error) Check {
+	return Check{Name: name, Run: func(ctx context.Context) error { return err }}
+}
+
+func TestChecker_Run(t *testing.T) {
+	t.Run("should be up when all components are up", func(t *testing.T) {
+		// given
+		checker := NewChecker(checkReturning("database", nil), checkReturning("tokenStore", nil))
+
+		// when
+		report := checker.Run(context.Background())
+
+		// then
+		assert.Equal(t, StatusUp, report.Status)
+		require.Len(t, report.Components, 2)
+		assert.Equal(t, "database", report.Components[0].Name)
+		assert.Equal(t, "tokenStore", report.Components[1].Name)
+	})
+
+	t.Run("should be down when any component is down", func(t *testing.T) {
+		// given
+		checker := NewChecker(checkReturning("database", nil), checkReturning("migrations", errors.New("2 pending migrations")))
+
+		// when
+		report := checker.Run(context.Background())
+
+		// then
+		assert.Equal(t, StatusDown, report.Status)
+		assert.Equal(t, StatusUp, report.Components[0].Status)
+		assert.Equal(t, StatusDown, report.Components[1].Status)
+		assert.Equal(t, "2 pending migrations", report.Components[1].Error)
+	})
+
+	t.Run("should fail checks not finishing in time", func(t *testing.T) {
+		// given
+		checker := NewChecker(Check{Name: "database", Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// when
+		report := checker.Run(ctx)
+
+		// then
+		assert.Equal(t, StatusDown, report.Status)
+		assert.Equal(t, context.Canceled.Error(), report.Components[0].Error)
+	})
+}
+
+func TestHandler(t *testing.T) {
+	// given
+	handler := NewHandler(
+		NewChecker(checkReturning("database", nil)),
+		NewChecker(checkReturning("database", nil), checkReturning("tokenStore", errors.New("relation does not exist"))),
+	)
+
+	// when
+	liveness := httptest.NewRecorder()
+	handler.Liveness(liveness, httptest.NewRequest("GET", "/healthz", nil))
+	readiness := httptest.NewRecorder()
+	handler.Readiness(readiness, httptest.NewRequest("GET", "/readyz", nil))
+
+	// then
+	assert.Equal(t, http.StatusOK, liveness.Code)
+	assert.Equal(t, http.StatusServiceUnavailable, readiness.Code)
+	var report ReportDTO
+	require.NoError(t, json.NewDecoder(readiness.Body).Decode(&report))
+	assert.Equal(t, StatusDown, report.Status)
+	require.Len(t, report.Components, 2)
+	assert.Equal(t, ComponentDTO{Name: "tokenStore", Status: StatusDown, Error: "relation does not exist"}, report.Components[1])
+}
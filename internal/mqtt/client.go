@@ -0,0 +1,194 @@
+package mqtt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrNotConnected = errors.New("not connected to the MQTT broker")
+
+const (
+	connectTimeout    = 10 * time.Second
+	defaultKeepAlive  = 60 * time.Second
+	maxReconnectDelay = time.Minute
+	// requestTimeout bounds waiting for the broker to take a publish or a subscription
+	requestTimeout = 10 * time.Second
+	// disconnectQuiesce is how long, in milliseconds, pending work may finish when disconnecting
+	disconnectQuiesce = 250
+)
+
+type Options struct {
+	// Broker is the address of the broker, e.g. tcp://mosquitto:1883, or tls://broker:8883 for a TLS connection
+	Broker string
+	// ClientId must be unique among the clients of the broker, which disconnects a client when another connects with
+	// its id. A random one is generated when empty, so every instance of klokku gets its own.
+	ClientId string
+	Username string
+	Password string
+	// WillTopic gets WillMessage, retained, when the client loses its connection without disconnecting. It is not
+	// set when empty.
+	WillTopic   string
+	WillMessage string
+	KeepAlive   time.Duration
+}
+
+// Handler handles a message published to a subscribed topic.
+type Handler func(topic string, payload []byte)
+
+// Client is the connection to the MQTT broker. It publishes and subscribes with QoS 0, which is all state updates and
+// commands need, and reconnects when the connection is lost.
+type Client struct {
+	opts   Options
+	client paho.Client
+
+	mu        sync.Mutex
+	handlers  map[string]Handler
+	onConnect []func()
+}
+
+func NewClient(opts Options) (*Client, error) {
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = defaultKeepAlive
+	}
+	broker, err := brokerUrl(opts.Broker)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ClientId == "" {
+		opts.ClientId = randomClientId()
+	}
+	c := &Client{opts: opts, handlers: make(map[string]Handler)}
+	pahoOpts := paho.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(opts.ClientId).
+		SetUsername(opts.Username).
+		SetPassword(opts.Password).
+		SetKeepAlive(opts.KeepAlive).
+		SetCleanSession(true).
+		SetConnectTimeout(connectTimeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(time.Second).
+		SetMaxReconnectInterval(maxReconnectDelay).
+		// Handlers start work in the background, so they never hold up the other messages
+		SetOrderMatters(false).
+		SetOnConnectHandler(func(paho.Client) { c.connected() }).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Errorf("MQTT connection to %s lost: %v, reconnecting", opts.Broker, err)
+		})
+	if opts.WillTopic != "" {
+		pahoOpts.SetWill(opts.WillTopic, opts.WillMessage, 0, true)
+	}
+	c.client = paho.NewClient(pahoOpts)
+	return c, nil
+}
+
+// brokerUrl validates the address of the broker, the scheme defaults to tcp and the port to the one of the scheme.
+func brokerUrl(broker string) (string, error) {
+	if !strings.Contains(broker, "://") {
+		broker = "tcp://" + broker
+	}
+	parsed, err := url.Parse(broker)
+	if err != nil || parsed.Hostname() == "" {
+		return "", fmt.Errorf("invalid MQTT broker address %q", broker)
+	}
+	scheme, port := "tcp", "1883"
+	switch parsed.Scheme {
+	case "tcp", "mqtt":
+	case "tls", "ssl", "mqtts":
+		scheme, port = "ssl", "8883"
+	default:
+		return "", fmt.Errorf("unsupported MQTT broker scheme %q", parsed.Scheme)
+	}
+	if parsed.Port() != "" {
+		port = parsed.Port()
+	}
+	return scheme + "://" + net.JoinHostPort(parsed.Hostname(), port), nil
+}
+
+func randomClientId() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "klokku-" + hex.EncodeToString(b)
+}
+
+// Subscribe calls the handler for the messages published to topics matching the filter, which may contain the
+// wildcards + and #, or be a shared subscription, e.g. $share/klokku/klokku/+/command. The subscription is renewed
+// whenever the client reconnects.
+func (c *Client) Subscribe(filter string, handler Handler) error {
+	c.mu.Lock()
+	c.handlers[filter] = handler
+	c.mu.Unlock()
+	if !c.client.IsConnectionOpen() {
+		return nil
+	}
+	return c.subscribe(filter, handler)
+}
+
+// OnConnect calls f after every connection to the broker, e.g. to publish the current state again.
+func (c *Client) OnConnect(f func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onConnect = append(c.onConnect, f)
+}
+
+// Publish sends the message with QoS 0. A retained message is kept by the broker for clients subscribing later.
+func (c *Client) Publish(topic string, payload []byte, retain bool) error {
+	if !c.client.IsConnectionOpen() {
+		return ErrNotConnected
+	}
+	return wait(c.client.Publish(topic, 0, retain, payload))
+}
+
+// Run keeps the client connected until ctx is done, then disconnects.
+func (c *Client) Run(ctx context.Context) {
+	// Connect keeps retrying in the background until the broker is reached
+	c.client.Connect()
+	<-ctx.Done()
+	c.client.Disconnect(disconnectQuiesce)
+}
+
+// connected renews the subscriptions, the session is clean, and calls the OnConnect functions.
+func (c *Client) connected() {
+	log.Infof("Connected to MQTT broker %s", c.opts.Broker)
+	c.mu.Lock()
+	handlers := make(map[string]Handler, len(c.handlers))
+	for filter, handler := range c.handlers {
+		handlers[filter] = handler
+	}
+	onConnect := append([]func(){}, c.onConnect...)
+	c.mu.Unlock()
+
+	for filter, handler := range handlers {
+		if err := c.subscribe(filter, handler); err != nil {
+			log.Errorf("failed to subscribe to MQTT topic %s: %v", filter, err)
+		}
+	}
+	for _, f := range onConnect {
+		f()
+	}
+}
+
+func (c *Client) subscribe(filter string, handler Handler) error {
+	return wait(c.client.Subscribe(filter, 0, func(_ paho.Client, message paho.Message) {
+		handler(message.Topic(), message.Payload())
+	}))
+}
+
+func wait(token paho.Token) error {
+	if !token.WaitTimeout(requestTimeout) {
+		return fmt.Errorf("MQTT broker did not respond within %s", requestTimeout)
+	}
+	return token.Error()
+}
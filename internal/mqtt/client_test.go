@@ -0,0 +1,156 @@
+package mqtt
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrokerUrl(t *testing.T) {
+	tests := []struct {
+		broker string
+		url    string
+	}{
+		{broker: "mosquitto", url: "tcp://mosquitto:1883"},
+		{broker: "mqtt://mosquitto", url: "tcp://mosquitto:1883"},
+		{broker: "tcp://mosquitto:1884", url: "tcp://mosquitto:1884"},
+		{broker: "tls://broker", url: "ssl://broker:8883"},
+		{broker: "mqtts://broker:9883", url: "ssl://broker:9883"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.broker, func(t *testing.T) {
+			url, err := brokerUrl(tt.broker)
+			require.NoError(t, err)
+			assert.Equal(t, tt.url, url)
+		})
+	}
+}
+
+func TestClient_ConnectsSubscribesAndPublishes(t *testing.T) {
+	// given
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	client, err := NewClient(Options{
+		Broker:      "tcp://" + listener.Addr().String(),
+		ClientId:    "klokku-test",
+		Username:    "user",
+		Password:    "secret",
+		WillTopic:   "klokku/status",
+		WillMessage: "offline",
+	})
+	require.NoError(t, err)
+	received := make(chan string, 1)
+	require.NoError(t, client.Subscribe("$share/klokku/klokku/+/command", func(topic string, payload []byte) {
+		received <- topic + " " + string(payload)
+	}))
+	connected := make(chan struct{}, 1)
+	client.OnConnect(func() {
+		connected <- struct{}{}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.Run(ctx)
+
+	// when
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	connect := readPacket[*packets.ConnectPacket](t, conn)
+	require.NoError(t, packets.NewControlPacket(packets.Connack).Write(conn))
+	subscribe := readPacket[*packets.SubscribePacket](t, conn)
+	suback := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+	suback.MessageID = subscribe.MessageID
+	suback.ReturnCodes = []byte{0}
+	require.NoError(t, suback.Write(conn))
+	<-connected
+
+	// then
+	assert.Equal(t, "klokku-test", connect.ClientIdentifier)
+	assert.True(t, connect.CleanSession)
+	assert.Equal(t, "user", connect.Username)
+	assert.Equal(t, "secret", string(connect.Password))
+	assert.Equal(t, "klokku/status", connect.WillTopic)
+	assert.Equal(t, "offline", string(connect.WillMessage))
+	assert.True(t, connect.WillRetain)
+	assert.Equal(t, []string{"$share/klokku/klokku/+/command"}, subscribe.Topics)
+
+	// when
+	command := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	command.TopicName = "klokku/alice/command"
+	command.Payload = []byte(`{"action":"stop"}`)
+	require.NoError(t, command.Write(conn))
+
+	// then
+	select {
+	case message := <-received:
+		assert.Equal(t, `klokku/alice/command {"action":"stop"}`, message)
+	case <-time.After(5 * time.Second):
+		t.Fatal("command not received")
+	}
+
+	// when
+	require.NoError(t, client.Publish("klokku/alice/state", []byte(`{"tracking":false}`), true))
+
+	// then
+	state := readPacket[*packets.PublishPacket](t, conn)
+	assert.Equal(t, "klokku/alice/state", state.TopicName)
+	assert.True(t, state.Retain)
+	assert.Equal(t, `{"tracking":false}`, string(state.Payload))
+
+	// when
+	cancel()
+
+	// then
+	readPacket[*packets.DisconnectPacket](t, conn)
+}
+
+// readPacket reads the next packet of type P, skipping pings
+func readPacket[P packets.ControlPacket](t *testing.T, conn net.Conn) P {
+	t.Helper()
+	for {
+		packet, err := packets.ReadPacket(conn)
+		require.NoError(t, err)
+		if _, ping := packet.(*packets.PingreqPacket); ping {
+			continue
+		}
+		typed, ok := packet.(P)
+		require.True(t, ok, "unexpected packet %s", packet)
+		return typed
+	}
+}
+
+func TestClient_PublishWhileDisconnected(t *testing.T) {
+	// given
+	client, err := NewClient(Options{Broker: "mosquitto"})
+	require.NoError(t, err)
+
+	// when
+	err = client.Publish("klokku/status", []byte("online"), true)
+
+	// then
+	assert.ErrorIs(t, err, ErrNotConnected)
+}
+
+func TestNewClient_GeneratesClientId(t *testing.T) {
+	first, err := NewClient(Options{Broker: "mosquitto"})
+	require.NoError(t, err)
+	second, err := NewClient(Options{Broker: "mosquitto"})
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(first.opts.ClientId, "klokku-"))
+	assert.NotEqual(t, first.opts.ClientId, second.opts.ClientId)
+}
+
+func TestNewClient_InvalidBroker(t *testing.T) {
+	_, err := NewClient(Options{Broker: "http://mosquitto:1883"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+package outbound
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit open, provider is failing")
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// breaker opens after threshold consecutive failures. Once openDuration passed a single probe request is let
+// through: its success closes the circuit, its failure opens it again.
+type breaker struct {
+	mu           sync.Mutex
+	threshold    int
+	openDuration time.Duration
+	state        CircuitState
+	failures     int
+	openedAt     time.Time
+	probing      bool
+}
+
+func newBreaker(threshold int, openDuration time.Duration) *breaker {
+	return &breaker{threshold: threshold, openDuration: openDuration, state: CircuitClosed}
+}
+
+// allow reports whether a request can be sent. A non-positive threshold disables the breaker.
+func (b *breaker) allow(now time.Time) bool {
+	if b.threshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if now.Sub(b.openedAt) < b.openDuration {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return true
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = CircuitClosed
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *breaker) failure(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == CircuitHalfOpen || (b.threshold > 0 && b.failures >= b.threshold) {
+		b.state = CircuitOpen
+		b.openedAt = now
+	}
+	b.probing = false
+}
+
+// release ends a request without an outcome, e.g. one cancelled by the caller, so another probe can be sent.
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *breaker) currentState() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,68 @@
+package outbound
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type ProviderStatsDTO struct {
+	Provider      string     `json:"provider"`
+	Requests      int64      `json:"requests"`
+	Attempts      int64      `json:"attempts"`
+	NetworkErrors int64      `json:"networkErrors"`
+	RateLimited   int64      `json:"rateLimited"`
+	ServerErrors  int64      `json:"serverErrors"`
+	Rejected      int64      `json:"rejected"`
+	CircuitState  string     `json:"circuitState"`
+	OpenCircuits  int        `json:"openCircuits"`
+	LastFailure   string     `json:"lastFailure,omitempty"`
+	LastFailureAt *time.Time `json:"lastFailureAt,omitempty"`
+}
+
+type Handler struct {
+	registry *Registry
+}
+
+func NewHandler(registry *Registry) *Handler {
+	return &Handler{registry: registry}
+}
+
+// ListProviderStats godoc
+// @Summary List outbound integration metrics
+// @Description List the requests sent to every third-party provider since the application started, the requests
+// @Description that failed after all retries by cause, and the state of the provider's circuit breakers.
+// @Description Requires an admin user.
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} ProviderStatsDTO
+// @Failure 403 {string} string "User not found or not an admin"
+// @Router /api/admin/integrations/outbound [get]
+// @Security XUserId
+func (h *Handler) ListProviderStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	stats := h.registry.Stats()
+	dtos := make([]ProviderStatsDTO, 0, len(stats))
+	for _, s := range stats {
+		dtos = append(dtos, ProviderStatsDTO{
+			Provider:      s.Provider,
+			Requests:      s.Requests,
+			Attempts:      s.Attempts,
+			NetworkErrors: s.NetworkErrors,
+			RateLimited:   s.RateLimited,
+			ServerErrors:  s.ServerErrors,
+			Rejected:      s.Rejected,
+			CircuitState:  string(s.CircuitState),
+			OpenCircuits:  s.OpenCircuits,
+			LastFailure:   s.LastFailure,
+			LastFailureAt: s.LastFailureAt,
+		})
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		log.Errorf("Failed to encode outbound integration metrics: %v", err)
+		http.Error(w, "Failed to encode outbound integration metrics", http.StatusInternalServerError)
+	}
+}
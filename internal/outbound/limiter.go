@@ -0,0 +1,43 @@
+package outbound
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter is a token bucket. Requests reserve a token and wait until it is available, so they are sent in the
+// order they arrived.
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(requestsPerSecond float64, burst int, now time.Time) *limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &limiter{rate: requestsPerSecond, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+// reserve takes a token and returns how long to wait before it can be used. A non-positive rate disables
+// the limit.
+func (l *limiter) reserve(now time.Time) time.Duration {
+	if l.rate <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.After(l.last) {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		l.last = now
+	}
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
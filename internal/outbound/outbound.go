@@ -0,0 +1,132 @@
+// Package outbound is the HTTP layer for requests Klokku sends to third-party providers. Every provider gets a
+// client that limits the request rate, retries throttled and failed requests with exponential backoff and
+// jitter, stops calling a provider endpoint or credential that keeps failing, and counts the failures.
+package outbound
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Policy configures how requests to one provider are sent.
+type Policy struct {
+	// RequestsPerSecond is the sustained request rate, Burst how many requests can be sent at once above it.
+	RequestsPerSecond float64
+	Burst             int
+	// AttemptTimeout limits every attempt, so a retry gets the full timeout again. Zero disables it.
+	AttemptTimeout time.Duration
+	// MaxRetries is how many times a request is retried after a 429 or 5xx response or a network error.
+	// Requests that are not idempotent are only retried after a 429, which means they were not processed.
+	MaxRetries int
+	// BaseDelay is the backoff before the first retry, doubled for every next one up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// FailureThreshold is the number of consecutive failed requests that opens the circuit. Every host and
+	// credential (Authorization header) of the provider has its own circuit. Requests fail immediately with
+	// ErrCircuitOpen while it is open, one request is let through after OpenDuration.
+	FailureThreshold int
+	OpenDuration     time.Duration
+}
+
+var DefaultPolicy = Policy{
+	RequestsPerSecond: 10,
+	Burst:             20,
+	AttemptTimeout:    30 * time.Second,
+	MaxRetries:        3,
+	BaseDelay:         500 * time.Millisecond,
+	MaxDelay:          30 * time.Second,
+	FailureThreshold:  5,
+	OpenDuration:      time.Minute,
+}
+
+// Registry holds the clients of all providers, so they share the limits and the circuits of their provider.
+type Registry struct {
+	mu         sync.Mutex
+	transports map[string]*transport
+	base       http.RoundTripper
+	// public connects only to public addresses, for the providers reached at URLs given by users
+	public http.RoundTripper
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		transports: make(map[string]*transport),
+		base:       http.DefaultTransport,
+		public:     publicTransport(),
+	}
+}
+
+// Client returns the HTTP client for the provider. The policy is used when the client is created, later calls
+// return the same client regardless of the policy passed.
+func (r *Registry) Client(provider string, policy Policy) *http.Client {
+	return &http.Client{Transport: r.transport(provider, policy, r.base)}
+}
+
+// PublicClient returns the HTTP client for a provider reached at URLs given by users, e.g. webhook and chat URLs.
+// On top of the policy it connects only to public addresses and does not follow redirects, a redirect is returned
+// as the response.
+func (r *Registry) PublicClient(provider string, policy Policy) *http.Client {
+	return &http.Client{
+		Transport: r.transport(provider, policy, r.public),
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func (r *Registry) transport(provider string, policy Policy, base http.RoundTripper) *transport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.transports[provider]; ok {
+		return t
+	}
+	// Every attempt, including the retries, gets its own span with the trace context sent to the provider
+	traced := otelhttp.NewTransport(base, otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+		return provider + " " + req.Method
+	}))
+	t := newTransport(provider, policy, traced)
+	r.transports[provider] = t
+	return t
+}
+
+// Stats returns the metrics of all providers ordered by name.
+func (r *Registry) Stats() []ProviderStats {
+	r.mu.Lock()
+	transports := make([]*transport, 0, len(r.transports))
+	for _, t := range r.transports {
+		transports = append(transports, t)
+	}
+	r.mu.Unlock()
+
+	stats := make([]ProviderStats, 0, len(transports))
+	for _, t := range transports {
+		stats = append(stats, t.stats())
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Provider < stats[j].Provider
+	})
+	return stats
+}
+
+// ProviderStats are the metrics of one provider since the application started.
+type ProviderStats struct {
+	Provider string
+	// Requests counts the requests sent by the application, Attempts also counts every retry.
+	Requests int64
+	Attempts int64
+	// Failures counts requests that failed after all retries, by cause.
+	NetworkErrors int64
+	RateLimited   int64
+	ServerErrors  int64
+	// Rejected counts requests not sent because the circuit was open.
+	Rejected int64
+	// CircuitState is the most severe state of the provider's circuits, OpenCircuits how many are open.
+	CircuitState  CircuitState
+	OpenCircuits  int
+	LastFailure   string
+	LastFailureAt *time.Time
+}
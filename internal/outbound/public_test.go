@@ -0,0 +1,68 @@
+package outbound
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isPublic(netip.MustParseAddr(tt.addr)))
+		})
+	}
+}
+
+func TestRegistry_PublicClient(t *testing.T) {
+	t.Run("rejects local addresses when connecting", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		_, err := NewRegistry().PublicClient("test", Policy{}).Get(server.URL)
+
+		assert.ErrorIs(t, err, ErrNonPublicAddress)
+	})
+
+	t.Run("does not follow redirects", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+		}))
+		defer server.Close()
+		registry := NewRegistry()
+		// Lets the client reach the local test server, the redirect is still not followed
+		registry.public = http.DefaultTransport
+		client := registry.PublicClient("test", Policy{})
+
+		resp, err := client.Get(server.URL)
+
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusFound, resp.StatusCode)
+	})
+}
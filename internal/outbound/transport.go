@@ -0,0 +1,316 @@
+package outbound
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type failureKind int
+
+const (
+	noFailure failureKind = iota
+	networkError
+	rateLimited
+	serverError
+)
+
+// staleBreakerAge is how long the breaker of a credential is kept without requests, e.g. after a token was
+// refreshed or revoked.
+const staleBreakerAge = time.Hour
+
+// transport applies the policy of one provider to the requests sent through it.
+type transport struct {
+	provider string
+	policy   Policy
+	base     http.RoundTripper
+	limiter  *limiter
+	now      func() time.Time
+	sleep    func(ctx context.Context, d time.Duration) error
+
+	mu      sync.Mutex
+	metrics ProviderStats
+	// breakers are keyed by the host and the credential of the requests, so a revoked token or a broken
+	// endpoint of one user does not stop the requests of the others
+	breakers   map[string]*trackedBreaker
+	lastPruned time.Time
+}
+
+type trackedBreaker struct {
+	*breaker
+	lastUsed time.Time
+}
+
+func newTransport(provider string, policy Policy, base http.RoundTripper) *transport {
+	return &transport{
+		provider: provider,
+		policy:   policy,
+		base:     base,
+		limiter:  newLimiter(policy.RequestsPerSecond, policy.Burst, time.Now()),
+		now:      time.Now,
+		sleep:    sleepContext,
+		metrics:  ProviderStats{Provider: provider},
+		breakers: make(map[string]*trackedBreaker),
+	}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	t.record(func(s *ProviderStats) { s.Requests++ })
+	breaker := t.breakerFor(req)
+	if !breaker.allow(t.now()) {
+		t.record(func(s *ProviderStats) { s.Rejected++ })
+		return nil, fmt.Errorf("%s: %w", t.provider, ErrCircuitOpen)
+	}
+
+	attemptReq := req
+	for attempt := 0; ; attempt++ {
+		if err := t.sleep(ctx, t.limiter.reserve(t.now())); err != nil {
+			breaker.release()
+			return nil, err
+		}
+		t.record(func(s *ProviderStats) { s.Attempts++ })
+		resp, err := t.roundTripAttempt(attemptReq)
+
+		kind := classify(resp, err)
+		if kind == noFailure {
+			breaker.success()
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			breaker.release()
+			return resp, err
+		}
+		if attempt >= t.policy.MaxRetries || !retryable(req, kind) {
+			breaker.failure(t.now())
+			t.recordFailure(kind, resp, err)
+			return resp, err
+		}
+
+		delay := t.backoff(attempt, resp)
+		log.Debugf("%s request %s %s failed (%s), retrying in %s", t.provider, req.Method, req.URL.Path,
+			describe(resp, err), delay)
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+		}
+		if err := t.sleep(ctx, delay); err != nil {
+			breaker.release()
+			return nil, err
+		}
+		if attemptReq, err = rewind(req); err != nil {
+			breaker.release()
+			return nil, err
+		}
+	}
+}
+
+// roundTripAttempt sends the request once, limited by the attempt timeout of the policy. The timeout covers
+// reading the response body, it ends when the body is closed.
+func (t *transport) roundTripAttempt(req *http.Request) (*http.Response, error) {
+	if t.policy.AttemptTimeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.policy.AttemptTimeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// breakerFor returns the breaker of the request's host and credential. The credential is only kept hashed.
+func (t *transport) breakerFor(req *http.Request) *breaker {
+	key := req.URL.Host
+	if authorization := req.Header.Get("Authorization"); authorization != "" {
+		hash := sha256.Sum256([]byte(authorization))
+		key += " " + hex.EncodeToString(hash[:])
+	}
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Sub(t.lastPruned) >= staleBreakerAge {
+		for k, b := range t.breakers {
+			if now.Sub(b.lastUsed) >= staleBreakerAge {
+				delete(t.breakers, k)
+			}
+		}
+		t.lastPruned = now
+	}
+	b, ok := t.breakers[key]
+	if !ok {
+		b = &trackedBreaker{breaker: newBreaker(t.policy.FailureThreshold, t.policy.OpenDuration)}
+		t.breakers[key] = b
+	}
+	b.lastUsed = now
+	return b.breaker
+}
+
+func classify(resp *http.Response, err error) failureKind {
+	switch {
+	case err != nil:
+		return networkError
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return rateLimited
+	case resp.StatusCode >= 500:
+		return serverError
+	default:
+		return noFailure
+	}
+}
+
+// retryable reports whether the request can be sent again. A 429 means the request was not processed, other
+// failures are only retried for idempotent methods so nothing gets created twice.
+func retryable(req *http.Request, kind failureKind) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if kind == rateLimited {
+		return true
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// rewind returns a copy of the request with a fresh body to send it again.
+func rewind(req *http.Request) (*http.Request, error) {
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	return retry, nil
+}
+
+// backoff returns the delay before the retry following the given attempt: the Retry-After of the response when
+// there is one, otherwise an exponential delay of which a random half is waited to spread the retries.
+func (t *transport) backoff(attempt int, resp *http.Response) time.Duration {
+	if retryAfter, ok := parseRetryAfter(resp, t.now()); ok {
+		return min(retryAfter, t.policy.MaxDelay)
+	}
+	delay := t.policy.BaseDelay << attempt
+	if delay <= 0 || delay > t.policy.MaxDelay {
+		delay = t.policy.MaxDelay
+	}
+	if delay < 2 {
+		return delay
+	}
+	return delay/2 + rand.N(delay/2)
+}
+
+func parseRetryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+func (t *transport) recordFailure(kind failureKind, resp *http.Response, err error) {
+	at := t.now()
+	description := describe(resp, err)
+	log.Warnf("%s request failed: %s", t.provider, description)
+	t.record(func(s *ProviderStats) {
+		switch kind {
+		case networkError:
+			s.NetworkErrors++
+		case rateLimited:
+			s.RateLimited++
+		case serverError:
+			s.ServerErrors++
+		}
+		s.LastFailure = description
+		s.LastFailureAt = &at
+	})
+}
+
+func describe(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("status %d", resp.StatusCode)
+}
+
+func (t *transport) record(update func(s *ProviderStats)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	update(&t.metrics)
+}
+
+// stats reports the most severe circuit state of the provider's breakers, with the number of open circuits.
+func (t *transport) stats() ProviderStats {
+	t.mu.Lock()
+	stats := t.metrics
+	breakers := make([]*breaker, 0, len(t.breakers))
+	for _, b := range t.breakers {
+		breakers = append(breakers, b.breaker)
+	}
+	t.mu.Unlock()
+
+	stats.CircuitState = CircuitClosed
+	for _, b := range breakers {
+		switch b.currentState() {
+		case CircuitOpen:
+			stats.CircuitState = CircuitOpen
+			stats.OpenCircuits++
+		case CircuitHalfOpen:
+			if stats.CircuitState == CircuitClosed {
+				stats.CircuitState = CircuitHalfOpen
+			}
+		}
+	}
+	return stats
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,317 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPolicy = Policy{
+	MaxRetries:       2,
+	BaseDelay:        time.Second,
+	MaxDelay:         10 * time.Second,
+	FailureThreshold: 3,
+	OpenDuration:     time.Minute,
+}
+
+type testTransport struct {
+	*transport
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func setupTransport(t *testing.T, policy Policy, handler http.HandlerFunc) (*testTransport, *http.Client, string) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	tt := &testTransport{now: time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)}
+	tt.transport = newTransport("test", policy, http.DefaultTransport)
+	tt.transport.now = func() time.Time { return tt.now }
+	tt.transport.sleep = func(ctx context.Context, d time.Duration) error {
+		if d > 0 {
+			tt.sleeps = append(tt.sleeps, d)
+			tt.now = tt.now.Add(d)
+		}
+		return ctx.Err()
+	}
+	return tt, &http.Client{Transport: tt.transport}, server.URL
+}
+
+// respondWith answers with the given statuses in order, the last one for all remaining requests
+func respondWith(calls *atomic.Int32, statuses ...int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		call := int(calls.Add(1))
+		status := statuses[min(call, len(statuses))-1]
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "7")
+		}
+		w.WriteHeader(status)
+	}
+}
+
+func TestTransport_Retry(t *testing.T) {
+	t.Run("should retry rate limited request after Retry-After", func(t *testing.T) {
+		// given
+		var calls atomic.Int32
+		tt, client, url := setupTransport(t, testPolicy, respondWith(&calls, http.StatusTooManyRequests, http.StatusOK))
+
+		// when
+		resp, err := client.Post(url, "application/json", strings.NewReader(`{"id":1}`))
+
+		// then
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Equal(t, []time.Duration{7 * time.Second}, tt.sleeps)
+	})
+
+	t.Run("should send the body again when retrying", func(t *testing.T) {
+		// given
+		var bodies []string
+		var calls atomic.Int32
+		_, client, url := setupTransport(t, testPolicy, func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			respondWith(&calls, http.StatusServiceUnavailable, http.StatusOK)(w, r)
+		})
+		req, err := http.NewRequest(http.MethodPut, url, strings.NewReader(`{"id":1}`))
+		require.NoError(t, err)
+
+		// when
+		resp, err := client.Do(req)
+
+		// then
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, []string{`{"id":1}`, `{"id":1}`}, bodies)
+	})
+
+	t.Run("should retry server errors of idempotent requests with growing backoff", func(t *testing.T) {
+		// given
+		var calls atomic.Int32
+		tt, client, url := setupTransport(t, testPolicy, respondWith(&calls, http.StatusBadGateway))
+
+		// when
+		resp, err := client.Get(url)
+
+		// then
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+		assert.Equal(t, int32(3), calls.Load())
+		require.Len(t, tt.sleeps, 2)
+		assert.GreaterOrEqual(t, tt.sleeps[0], 500*time.Millisecond)
+		assert.Less(t, tt.sleeps[0], time.Second)
+		assert.GreaterOrEqual(t, tt.sleeps[1], time.Second)
+		assert.Less(t, tt.sleeps[1], 2*time.Second)
+
+		stats := tt.stats()
+		assert.Equal(t, int64(1), stats.Requests)
+		assert.Equal(t, int64(3), stats.Attempts)
+		assert.Equal(t, int64(1), stats.ServerErrors)
+		assert.Equal(t, "status 502", stats.LastFailure)
+	})
+
+	t.Run("should not retry server errors of requests that are not idempotent", func(t *testing.T) {
+		// given
+		var calls atomic.Int32
+		tt, client, url := setupTransport(t, testPolicy, respondWith(&calls, http.StatusInternalServerError))
+
+		// when
+		resp, err := client.Post(url, "application/json", strings.NewReader(`{}`))
+
+		// then
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		assert.Equal(t, int32(1), calls.Load())
+		assert.Empty(t, tt.sleeps)
+	})
+
+	t.Run("should not retry client errors", func(t *testing.T) {
+		// given
+		var calls atomic.Int32
+		tt, client, url := setupTransport(t, testPolicy, respondWith(&calls, http.StatusNotFound))
+
+		// when
+		resp, err := client.Get(url)
+
+		// then
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, int32(1), calls.Load())
+		assert.Equal(t, int64(0), tt.stats().ServerErrors)
+		assert.Nil(t, tt.stats().LastFailureAt)
+	})
+}
+
+func TestTransport_CircuitBreaker(t *testing.T) {
+	policy := testPolicy
+	policy.MaxRetries = 0
+
+	t.Run("should reject requests while the circuit is open", func(t *testing.T) {
+		// given
+		var calls atomic.Int32
+		tt, client, url := setupTransport(t, policy, respondWith(&calls, http.StatusServiceUnavailable))
+		for range policy.FailureThreshold {
+			resp, err := client.Get(url)
+			require.NoError(t, err)
+			resp.Body.Close()
+		}
+
+		// when
+		_, err := client.Get(url)
+
+		// then
+		assert.True(t, errors.Is(err, ErrCircuitOpen))
+		assert.Equal(t, int32(policy.FailureThreshold), calls.Load())
+		stats := tt.stats()
+		assert.Equal(t, CircuitOpen, stats.CircuitState)
+		assert.Equal(t, int64(1), stats.Rejected)
+	})
+
+	t.Run("should close the circuit when the probe succeeds", func(t *testing.T) {
+		// given
+		var calls atomic.Int32
+		tt, client, url := setupTransport(t, policy, respondWith(&calls,
+			http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK))
+		for range policy.FailureThreshold {
+			resp, err := client.Get(url)
+			require.NoError(t, err)
+			resp.Body.Close()
+		}
+		tt.now = tt.now.Add(policy.OpenDuration)
+
+		// when
+		resp, err := client.Get(url)
+
+		// then
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, CircuitClosed, tt.stats().CircuitState)
+	})
+
+	t.Run("should open the circuit again when the probe fails", func(t *testing.T) {
+		// given
+		var calls atomic.Int32
+		tt, client, url := setupTransport(t, policy, respondWith(&calls, http.StatusServiceUnavailable))
+		for range policy.FailureThreshold {
+			resp, err := client.Get(url)
+			require.NoError(t, err)
+			resp.Body.Close()
+		}
+		tt.now = tt.now.Add(policy.OpenDuration)
+
+		// when
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		resp.Body.Close()
+		_, err = client.Get(url)
+
+		// then
+		assert.True(t, errors.Is(err, ErrCircuitOpen))
+		assert.Equal(t, int32(policy.FailureThreshold+1), calls.Load())
+	})
+}
+
+func TestTransport_CircuitPerCredential(t *testing.T) {
+	// given
+	policy := testPolicy
+	policy.MaxRetries = 0
+	tt, client, url := setupTransport(t, policy, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer revoked" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	get := func(token string) (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return client.Do(req)
+	}
+	for range policy.FailureThreshold {
+		resp, err := get("revoked")
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	// when
+	_, revokedErr := get("revoked")
+	resp, validErr := get("valid")
+
+	// then
+	assert.ErrorIs(t, revokedErr, ErrCircuitOpen)
+	require.NoError(t, validErr)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	stats := tt.stats()
+	assert.Equal(t, CircuitOpen, stats.CircuitState)
+	assert.Equal(t, 1, stats.OpenCircuits)
+
+	t.Run("should forget the circuits of unused credentials", func(t *testing.T) {
+		tt.now = tt.now.Add(staleBreakerAge)
+
+		resp, err := get("valid")
+
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Len(t, tt.breakers, 1)
+		assert.Equal(t, CircuitClosed, tt.stats().CircuitState)
+	})
+}
+
+func TestTransport_AttemptTimeout(t *testing.T) {
+	// given
+	var calls atomic.Int32
+	release := make(chan struct{})
+	defer close(release)
+	policy := testPolicy
+	policy.AttemptTimeout = 50 * time.Millisecond
+	tt, client, url := setupTransport(t, policy, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	// when
+	resp, err := client.Get(url)
+
+	// then
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(body))
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Len(t, tt.sleeps, 1)
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	l := newLimiter(2, 2, now)
+
+	assert.Equal(t, time.Duration(0), l.reserve(now))
+	assert.Equal(t, time.Duration(0), l.reserve(now))
+	assert.Equal(t, 500*time.Millisecond, l.reserve(now))
+	assert.Equal(t, time.Second, l.reserve(now))
+	// the tokens reserved ahead are paid back before new ones are available
+	assert.Equal(t, 500*time.Millisecond, l.reserve(now.Add(time.Second)))
+}
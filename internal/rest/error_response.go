@@ -3,4 +3,13 @@ package rest
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Details string `json:"details,omitempty"`
+	// Fields lists every invalid field of the request, Error and Details describe the first of them
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError is the problem of one field of a request.
+type FieldError struct {
+	// Field is the JSON path of the field in the body, e.g. "settings.locale", or the name of the query parameter
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
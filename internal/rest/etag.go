@@ -0,0 +1,50 @@
+package rest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// WriteJSONWithETag responds 200 OK with the JSON of v and its hash as the ETag. A request whose If-None-Match header
+// matches the ETag gets 304 Not Modified without a body, so clients polling for changes only download them when
+// there are any. The response is still built and serialized to compare it, so it saves bandwidth, not server work.
+// Only If-None-Match is handled: there is no Last-Modified time to compare If-Modified-Since with, so it is ignored.
+func WriteJSONWithETag(w http.ResponseWriter, r *http.Request, v any) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(v); err != nil {
+		log.Errorf("Failed to encode response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%x"`, sha256.Sum256(body.Bytes()))
+	w.Header().Set("ETag", etag)
+	// The data can change at any time, so clients keep it but revalidate with the ETag
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body.Bytes()); err != nil {
+		log.Errorf("Failed to write response: %v", err)
+	}
+}
+
+// etagMatches compares the If-None-Match header, a list of ETags or "*", with the ETag weakly as RFC 9110 asks for.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
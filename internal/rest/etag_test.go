@@ -0,0 +1,74 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteJSONWithETag_WritesBodyAndETag(t *testing.T) {
+	// given
+	r := httptest.NewRequest(http.MethodGet, "/api/calendar/event", nil)
+	w := httptest.NewRecorder()
+
+	// when
+	WriteJSONWithETag(w, r, map[string]int{"id": 1})
+
+	// then
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+	assert.JSONEq(t, `{"id": 1}`, w.Body.String())
+}
+
+func TestWriteJSONWithETag_NotModified(t *testing.T) {
+	// given
+	first := httptest.NewRecorder()
+	WriteJSONWithETag(first, httptest.NewRequest(http.MethodGet, "/api/weeklyplan", nil), []int{1, 2})
+	etag := first.Header().Get("ETag")
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		status      int
+	}{
+		{name: "same ETag", ifNoneMatch: etag, status: http.StatusNotModified},
+		{name: "weak ETag in a list", ifNoneMatch: `"other", W/` + etag, status: http.StatusNotModified},
+		{name: "any", ifNoneMatch: "*", status: http.StatusNotModified},
+		{name: "other ETag", ifNoneMatch: `"other"`, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/weeklyplan", nil)
+			r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			w := httptest.NewRecorder()
+
+			// when
+			WriteJSONWithETag(w, r, []int{1, 2})
+
+			// then
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, etag, w.Header().Get("ETag"))
+			if tt.status == http.StatusNotModified {
+				assert.Empty(t, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestWriteJSONWithETag_ChangesWithData(t *testing.T) {
+	// given
+	r := httptest.NewRequest(http.MethodGet, "/api/budgetplan/1", nil)
+	before := httptest.NewRecorder()
+	after := httptest.NewRecorder()
+
+	// when
+	WriteJSONWithETag(before, r, map[string]int{"weeklyDuration": 3600})
+	WriteJSONWithETag(after, r, map[string]int{"weeklyDuration": 7200})
+
+	// then
+	assert.NotEqual(t, before.Header().Get("ETag"), after.Header().Get("ETag"))
+}
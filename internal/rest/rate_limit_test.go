@@ -0,0 +1,114 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLimiter(requestsPerSecond float64, burst int, now *time.Time) *RateLimiter {
+	l := NewRateLimiter(requestsPerSecond, burst)
+	l.now = func() time.Time { return *now }
+	return l
+}
+
+func TestRateLimiter_AllowsBurstThenRefills(t *testing.T) {
+	// given
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(2, 3, &now)
+
+	// when
+	var allowed []bool
+	for i := 0; i < 4; i++ {
+		ok, _ := l.Allow("client")
+		allowed = append(allowed, ok)
+	}
+	_, retryAfter := l.Allow("client")
+	now = now.Add(500 * time.Millisecond)
+	refilled, _ := l.Allow("client")
+
+	// then
+	assert.Equal(t, []bool{true, true, true, false}, allowed)
+	assert.Equal(t, 500*time.Millisecond, retryAfter)
+	assert.True(t, refilled)
+}
+
+func TestRateLimiter_ClientsHaveOwnBuckets(t *testing.T) {
+	// given
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(1, 1, &now)
+	l.Allow("first")
+
+	// when
+	first, _ := l.Allow("first")
+	second, _ := l.Allow("second")
+
+	// then
+	assert.False(t, first)
+	assert.True(t, second)
+}
+
+func TestRateLimiter_DropsRefilledBuckets(t *testing.T) {
+	// given
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(1, 5, &now)
+	l.Allow("idle")
+	now = now.Add(2 * cleanupInterval)
+
+	// when
+	l.Allow("active")
+
+	// then
+	assert.NotContains(t, l.buckets, "idle")
+	assert.Contains(t, l.buckets, "active")
+}
+
+func TestRateLimiter_Middleware(t *testing.T) {
+	// given
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(0.5, 1, &now)
+	handler := l.Middleware(func(r *http.Request) string {
+		return r.Header.Get("X-Client")
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func(path string, client string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Client", client)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// when
+	first := serve("/api/event/current", "a")
+	limited := serve("/api/event/current", "a")
+	frontend := serve("/index.html", "a")
+	anonymous := serve("/api/event/current", "")
+
+	// then
+	assert.Equal(t, http.StatusNoContent, first.Code)
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code)
+	assert.Equal(t, "2", limited.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusNoContent, frontend.Code)
+	assert.Equal(t, http.StatusNoContent, anonymous.Code)
+}
+
+func TestRateLimiter_Disabled(t *testing.T) {
+	// given
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(0, 1, &now)
+
+	// when
+	var allowed []bool
+	for i := 0; i < 3; i++ {
+		ok, _ := l.Allow("client")
+		allowed = append(allowed, ok)
+	}
+
+	// then
+	assert.Equal(t, []bool{true, true, true}, allowed)
+}
@@ -0,0 +1,156 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// RequestError is a request that is malformed or has invalid fields, WriteBadRequest responds to it.
+type RequestError struct {
+	// Summary is the short description of the first problem, e.g. "Invalid locale"
+	Summary string
+	// Details explains the first problem, e.g. what the valid values are
+	Details string
+	Fields  []FieldError
+}
+
+func (e *RequestError) Error() string {
+	if e.Details == "" {
+		return e.Summary
+	}
+	return e.Summary + ": " + e.Details
+}
+
+// Validator collects the invalid fields of a request, so a client learns about all of them at once.
+type Validator struct {
+	err *RequestError
+}
+
+// Check records the field as invalid unless ok. summary names the problem, e.g. "Invalid day start hour", and
+// message explains it, e.g. "Day start hour must be between 0 and 23".
+func (v *Validator) Check(ok bool, field string, summary string, message string) {
+	if ok {
+		return
+	}
+	if v.err == nil {
+		v.err = &RequestError{Summary: summary, Details: message}
+	}
+	v.err.Fields = append(v.err.Fields, FieldError{Field: field, Message: message})
+}
+
+// Required records the field as invalid when value is empty or only white space.
+func (v *Validator) Required(value string, field string, summary string) {
+	v.Check(strings.TrimSpace(value) != "", field, summary, fmt.Sprintf("'%s' must not be empty", field))
+}
+
+// Err returns the *RequestError of the invalid fields, nil when all were valid.
+func (v *Validator) Err() error {
+	if v.err == nil {
+		return nil
+	}
+	return v.err
+}
+
+// DecodeJSON decodes the JSON body of the request into dst. It returns a *RequestError naming the field when a value
+// has the wrong type.
+func DecodeJSON(r *http.Request, dst any) error {
+	err := json.NewDecoder(r.Body).Decode(dst)
+	if err == nil {
+		return nil
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return &RequestError{Summary: "Invalid request body format", Details: "The request body must not be empty"}
+	case errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF):
+		return &RequestError{Summary: "Invalid request body format", Details: "The request body must be valid JSON"}
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		message := fmt.Sprintf("'%s' must be %s", typeErr.Field, jsonTypeName(typeErr.Type))
+		return &RequestError{
+			Summary: "Invalid request body format",
+			Details: message,
+			Fields:  []FieldError{{Field: typeErr.Field, Message: message}},
+		}
+	}
+	return &RequestError{Summary: "Invalid request body format", Details: err.Error()}
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Pointer:
+		return jsonTypeName(t.Elem())
+	}
+	return "an object"
+}
+
+// WriteBadRequest responds 400 Bad Request with the ErrorResponse of err, a *RequestError lists its fields.
+func WriteBadRequest(w http.ResponseWriter, err error) {
+	response := ErrorResponse{Error: "Invalid request", Details: err.Error()}
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		response = ErrorResponse{Error: requestErr.Summary, Details: requestErr.Details, Fields: requestErr.Fields}
+	}
+	WriteError(w, http.StatusBadRequest, response)
+}
+
+// WriteNotFound responds 404 Not Found with err as the details.
+func WriteNotFound(w http.ResponseWriter, err error) {
+	WriteError(w, http.StatusNotFound, ErrorResponse{Error: "Not found", Details: err.Error()})
+}
+
+// WriteConflict responds 409 Conflict with err as the details.
+func WriteConflict(w http.ResponseWriter, err error) {
+	WriteError(w, http.StatusConflict, ErrorResponse{Error: "Conflict", Details: err.Error()})
+}
+
+// WriteInternalError logs err and responds 500 Internal Server Error without it, as it may reveal internals.
+func WriteInternalError(w http.ResponseWriter, err error) {
+	log.Errorf("Request failed: %v", err)
+	WriteError(w, http.StatusInternalServerError, ErrorResponse{Error: "Internal server error"})
+}
+
+// WriteError responds with the status and the JSON of the ErrorResponse.
+func WriteError(w http.ResponseWriter, status int, response ErrorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Errorf("Failed to encode error response: %v", err)
+	}
+}
+
+// InvalidField returns the *RequestError of a single invalid field, e.g. for a problem only the service detects.
+func InvalidField(field string, summary string, message string) error {
+	var v Validator
+	v.Check(false, field, summary, message)
+	return v.Err()
+}
+
+// PathInt parses the integer path variable of the route, e.g. the id in /api/budgetplan/{planId}.
+func PathInt(r *http.Request, name string) (int, error) {
+	value, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil {
+		return 0, InvalidField(name, "Invalid "+name, fmt.Sprintf("'%s' must be an integer", name))
+	}
+	return value, nil
+}
@@ -0,0 +1,150 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidator_CollectsAllInvalidFields(t *testing.T) {
+	// given
+	var v Validator
+
+	// when
+	v.Required("", "firstName", "Invalid first name")
+	v.Check(true, "lastName", "Invalid last name", "never reported")
+	v.Check(false, "settings.locale", "Invalid locale", "'locale' is not supported")
+	err := v.Err()
+
+	// then
+	var requestErr *RequestError
+	require.True(t, errors.As(err, &requestErr))
+	assert.Equal(t, "Invalid first name", requestErr.Summary)
+	assert.Equal(t, "'firstName' must not be empty", requestErr.Details)
+	assert.Equal(t, []FieldError{
+		{Field: "firstName", Message: "'firstName' must not be empty"},
+		{Field: "settings.locale", Message: "'locale' is not supported"},
+	}, requestErr.Fields)
+}
+
+func TestValidator_NoErrorWhenValid(t *testing.T) {
+	// given
+	var v Validator
+
+	// when
+	v.Required("Jane", "firstName", "Invalid first name")
+
+	// then
+	assert.NoError(t, v.Err())
+}
+
+func TestDecodeJSON(t *testing.T) {
+	type body struct {
+		Name     string `json:"name"`
+		Duration int    `json:"duration"`
+	}
+	tests := []struct {
+		name    string
+		body    string
+		details string
+		fields  []FieldError
+	}{
+		{name: "empty body", body: "", details: "The request body must not be empty"},
+		{name: "malformed JSON", body: `{"name": `, details: "The request body must be valid JSON"},
+		{
+			name:    "wrong type",
+			body:    `{"name": "Work", "duration": "1h"}`,
+			details: "'duration' must be an integer",
+			fields:  []FieldError{{Field: "duration", Message: "'duration' must be an integer"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			r := httptest.NewRequest(http.MethodPost, "/api/test", strings.NewReader(tt.body))
+
+			// when
+			var dst body
+			err := DecodeJSON(r, &dst)
+
+			// then
+			var requestErr *RequestError
+			require.True(t, errors.As(err, &requestErr))
+			assert.Equal(t, "Invalid request body format", requestErr.Summary)
+			assert.Equal(t, tt.details, requestErr.Details)
+			assert.Equal(t, tt.fields, requestErr.Fields)
+		})
+	}
+}
+
+func TestWriteBadRequest_WritesFields(t *testing.T) {
+	// given
+	w := httptest.NewRecorder()
+
+	// when
+	WriteBadRequest(w, InvalidField("endDate", "Invalid dates", "'endDate' must be after 'startDate'"))
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var response ErrorResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, ErrorResponse{
+		Error:   "Invalid dates",
+		Details: "'endDate' must be after 'startDate'",
+		Fields:  []FieldError{{Field: "endDate", Message: "'endDate' must be after 'startDate'"}},
+	}, response)
+}
+
+func TestWriteBadRequest_OtherError(t *testing.T) {
+	// given
+	w := httptest.NewRecorder()
+
+	// when
+	WriteBadRequest(w, errors.New("invalid category"))
+
+	// then
+	var response ErrorResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, ErrorResponse{Error: "Invalid request", Details: "invalid category"}, response)
+}
+
+func TestWriteInternalError_HidesError(t *testing.T) {
+	// given
+	w := httptest.NewRecorder()
+
+	// when
+	WriteInternalError(w, errors.New("pq: connection refused to 10.0.0.5"))
+
+	// then
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var response ErrorResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, ErrorResponse{Error: "Internal server error"}, response)
+}
+
+func TestPathInt(t *testing.T) {
+	// given
+	valid := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/budgetplan/12", nil), map[string]string{"planId": "12"})
+	invalid := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/budgetplan/x", nil), map[string]string{"planId": "x"})
+
+	// when
+	planId, validErr := PathInt(valid, "planId")
+	_, invalidErr := PathInt(invalid, "planId")
+
+	// then
+	require.NoError(t, validErr)
+	assert.Equal(t, 12, planId)
+	var requestErr *RequestError
+	require.True(t, errors.As(invalidErr, &requestErr))
+	assert.Equal(t, "Invalid planId", requestErr.Summary)
+	assert.Equal(t, []FieldError{{Field: "planId", Message: "'planId' must be an integer"}}, requestErr.Fields)
+}
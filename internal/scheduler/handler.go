@@ -0,0 +1,123 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+type LeaderDTO struct {
+	InstanceId string    `json:"instanceId"`
+	Since      time.Time `json:"since"`
+	LeaseUntil time.Time `json:"leaseUntil"`
+}
+
+type JobDTO struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"`
+	PerUser  bool   `json:"perUser"`
+	// NextRunAt is null until the job first ran
+	NextRunAt      *time.Time `json:"nextRunAt"`
+	LastStartedAt  *time.Time `json:"lastStartedAt"`
+	LastFinishedAt *time.Time `json:"lastFinishedAt"`
+	// LastStatus is running, succeeded or failed, empty until the job first ran
+	LastStatus      string `json:"lastStatus"`
+	LastError       string `json:"lastError"`
+	LastDurationMs  int64  `json:"lastDurationMs"`
+	LastUsers       int    `json:"lastUsers"`
+	LastFailedUsers int    `json:"lastFailedUsers"`
+}
+
+type OverviewDTO struct {
+	// InstanceId identifies the instance that answered
+	InstanceId string `json:"instanceId"`
+	// Leader is the instance running the jobs, null when there has been none yet
+	Leader *LeaderDTO `json:"leader"`
+	Jobs   []JobDTO   `json:"jobs"`
+}
+
+type Handler struct {
+	scheduler *Scheduler
+}
+
+func NewHandler(scheduler *Scheduler) *Handler {
+	return &Handler{scheduler: scheduler}
+}
+
+// ListJobs godoc
+// @Summary List scheduled jobs
+// @Description List the background jobs with their schedules and the outcome of their last runs, and the instance
+// @Description running them. Requires an admin user.
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} OverviewDTO
+// @Failure 403 {string} string "User not found or not an admin"
+// @Router /api/admin/jobs [get]
+// @Security XUserId
+func (h *Handler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	overview, err := h.scheduler.Overview(r.Context())
+	if err != nil {
+		log.Errorf("Failed to get jobs: %v", err)
+		http.Error(w, "Failed to get jobs", http.StatusInternalServerError)
+		return
+	}
+
+	dto := OverviewDTO{InstanceId: overview.InstanceId, Jobs: make([]JobDTO, 0, len(overview.Jobs))}
+	if overview.Leader != nil {
+		dto.Leader = &LeaderDTO{
+			InstanceId: overview.Leader.InstanceId,
+			Since:      overview.Leader.Since,
+			LeaseUntil: overview.Leader.LeaseUntil,
+		}
+	}
+	for _, job := range overview.Jobs {
+		dto.Jobs = append(dto.Jobs, JobDTO{
+			Name:            job.Name,
+			Schedule:        job.Schedule,
+			PerUser:         job.PerUser,
+			NextRunAt:       job.NextRunAt,
+			LastStartedAt:   job.LastStartedAt,
+			LastFinishedAt:  job.LastFinishedAt,
+			LastStatus:      string(job.LastStatus),
+			LastError:       job.LastError,
+			LastDurationMs:  job.LastDuration.Milliseconds(),
+			LastUsers:       job.Users,
+			LastFailedUsers: job.FailedUsers,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(dto); err != nil {
+		log.Errorf("Failed to encode jobs: %v", err)
+		http.Error(w, "Failed to encode jobs", http.StatusInternalServerError)
+	}
+}
+
+// RunJob godoc
+// @Summary Run a scheduled job now
+// @Description Make the job due now, so the instance running the jobs starts it within seconds, unless it is
+// @Description running already. Its regular schedule continues after the run. Requires an admin user.
+// @Tags Admin
+// @Param name path string true "Job name, e.g. budget_rollover"
+// @Success 202 "Accepted"
+// @Failure 403 {string} string "User not found or not an admin"
+// @Failure 404 {string} string "Job not found"
+// @Router /api/admin/jobs/{name}/run [post]
+// @Security XUserId
+func (h *Handler) RunJob(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if err := h.scheduler.Trigger(r.Context(), name); err != nil {
+		if errors.Is(err, ErrJobNotFound) {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to run job %s: %v", name, err)
+		http.Error(w, "Failed to run job", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
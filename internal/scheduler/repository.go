@@ -0,0 +1,130 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository interface {
+	// AcquireLeadership makes the instance the leader until leaseUntil when it is the leader already or the lease
+	// of the previous leader ended before now, and returns whether the instance is the leader.
+	AcquireLeadership(ctx context.Context, instanceId string, now time.Time, leaseUntil time.Time) (bool, error)
+	// ReleaseLeadership ends the lease of the instance, if it is the leader, so another one can take over right away.
+	ReleaseLeadership(ctx context.Context, instanceId string) error
+	// GetLeader returns false when no instance has been the leader yet.
+	GetLeader(ctx context.Context) (Leader, bool, error)
+	ListStates(ctx context.Context) ([]State, error)
+	// RecordStart marks the job running and schedules its next run.
+	RecordStart(ctx context.Context, name string, startedAt time.Time, nextRunAt time.Time) error
+	RecordFinish(ctx context.Context, name string, result Result) error
+	// RequestRun makes the job due at the given time.
+	RequestRun(ctx context.Context, name string, at time.Time) error
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+func (r *RepositoryImpl) AcquireLeadership(ctx context.Context, instanceId string, now time.Time, leaseUntil time.Time) (bool, error) {
+	query := `INSERT INTO scheduler_leader (id, instance_id, since, lease_until)
+			  VALUES (1, $1, $2, $3)
+			  ON CONFLICT (id) DO UPDATE
+			  SET instance_id = EXCLUDED.instance_id,
+				  since = CASE WHEN scheduler_leader.instance_id = EXCLUDED.instance_id
+							   THEN scheduler_leader.since ELSE EXCLUDED.since END,
+				  lease_until = EXCLUDED.lease_until
+			  WHERE scheduler_leader.instance_id = EXCLUDED.instance_id OR scheduler_leader.lease_until < $2`
+	tag, err := r.db.Exec(ctx, query, instanceId, now, leaseUntil)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire scheduler leadership: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *RepositoryImpl) ReleaseLeadership(ctx context.Context, instanceId string) error {
+	query := `UPDATE scheduler_leader SET lease_until = since WHERE instance_id = $1`
+	if _, err := r.db.Exec(ctx, query, instanceId); err != nil {
+		return fmt.Errorf("failed to release scheduler leadership: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) GetLeader(ctx context.Context) (Leader, bool, error) {
+	var leader Leader
+	err := r.db.QueryRow(ctx, `SELECT instance_id, since, lease_until FROM scheduler_leader WHERE id = 1`).
+		Scan(&leader.InstanceId, &leader.Since, &leader.LeaseUntil)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Leader{}, false, nil
+		}
+		return Leader{}, false, fmt.Errorf("failed to get scheduler leader: %w", err)
+	}
+	return leader, true, nil
+}
+
+func (r *RepositoryImpl) ListStates(ctx context.Context) ([]State, error) {
+	query := `SELECT name, next_run_at, last_started_at, last_finished_at, COALESCE(last_status, ''),
+					 COALESCE(last_error, ''), last_duration_ms, users, failed_users
+			  FROM scheduled_job ORDER BY name`
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job states: %w", err)
+	}
+	defer rows.Close()
+
+	states := make([]State, 0)
+	for rows.Next() {
+		var state State
+		var durationMs int64
+		err := rows.Scan(&state.Name, &state.NextRunAt, &state.LastStartedAt, &state.LastFinishedAt, &state.LastStatus,
+			&state.LastError, &durationMs, &state.Users, &state.FailedUsers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job state: %w", err)
+		}
+		state.LastDuration = time.Duration(durationMs) * time.Millisecond
+		states = append(states, state)
+	}
+	return states, rows.Err()
+}
+
+func (r *RepositoryImpl) RecordStart(ctx context.Context, name string, startedAt time.Time, nextRunAt time.Time) error {
+	query := `INSERT INTO scheduled_job (name, next_run_at, last_started_at, last_status)
+			  VALUES ($1, $2, $3, 'running')
+			  ON CONFLICT (name) DO UPDATE
+			  SET next_run_at = EXCLUDED.next_run_at, last_started_at = EXCLUDED.last_started_at, last_status = 'running'`
+	if _, err := r.db.Exec(ctx, query, name, nextRunAt, startedAt); err != nil {
+		return fmt.Errorf("failed to record start of job %s: %w", name, err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) RecordFinish(ctx context.Context, name string, result Result) error {
+	query := `UPDATE scheduled_job
+			  SET last_finished_at = $2, last_status = $3, last_error = NULLIF($4, ''), last_duration_ms = $5,
+				  users = $6, failed_users = $7
+			  WHERE name = $1`
+	_, err := r.db.Exec(ctx, query, name, result.FinishedAt, result.Status, result.Error,
+		result.Duration.Milliseconds(), result.Users, result.FailedUsers)
+	if err != nil {
+		return fmt.Errorf("failed to record finish of job %s: %w", name, err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) RequestRun(ctx context.Context, name string, at time.Time) error {
+	query := `INSERT INTO scheduled_job (name, next_run_at) VALUES ($1, $2)
+			  ON CONFLICT (name) DO UPDATE SET next_run_at = EXCLUDED.next_run_at`
+	if _, err := r.db.Exec(ctx, query, name, at); err != nil {
+		return fmt.Errorf("failed to request run of job %s: %w", name, err)
+	}
+	return nil
+}
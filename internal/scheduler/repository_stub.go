@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type RepositoryStub struct {
+	mu     sync.Mutex
+	Leader *Leader
+	States map[string]*State
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{States: make(map[string]*State)}
+}
+
+func (r *RepositoryStub) AcquireLeadership(ctx context.Context, instanceId string, now time.Time, leaseUntil time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Leader == nil || r.Leader.LeaseUntil.Before(now) {
+		r.Leader = &Leader{InstanceId: instanceId, Since: now, LeaseUntil: leaseUntil}
+		return true, nil
+	}
+	if r.Leader.InstanceId == instanceId {
+		r.Leader.LeaseUntil = leaseUntil
+		return true, nil
+	}
+	return false, nil
+}
+
+func (r *RepositoryStub) ReleaseLeadership(ctx context.Context, instanceId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Leader != nil && r.Leader.InstanceId == instanceId {
+		r.Leader.LeaseUntil = r.Leader.Since
+	}
+	return nil
+}
+
+func (r *RepositoryStub) GetLeader(ctx context.Context) (Leader, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Leader == nil {
+		return Leader{}, false, nil
+	}
+	return *r.Leader, true, nil
+}
+
+func (r *RepositoryStub) ListStates(ctx context.Context) ([]State, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	states := make([]State, 0, len(r.States))
+	for _, state := range r.States {
+		states = append(states, *state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states, nil
+}
+
+func (r *RepositoryStub) RecordStart(ctx context.Context, name string, startedAt time.Time, nextRunAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state := r.state(name)
+	state.NextRunAt = &nextRunAt
+	state.LastStartedAt = &startedAt
+	state.LastStatus = StatusRunning
+	return nil
+}
+
+func (r *RepositoryStub) RecordFinish(ctx context.Context, name string, result Result) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state := r.state(name)
+	state.LastFinishedAt = &result.FinishedAt
+	state.LastStatus = result.Status
+	state.LastError = result.Error
+	state.LastDuration = result.Duration
+	state.Users = result.Users
+	state.FailedUsers = result.FailedUsers
+	return nil
+}
+
+func (r *RepositoryStub) RequestRun(ctx context.Context, name string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state(name).NextRunAt = &at
+	return nil
+}
+
+// GetState returns a copy of the state of the job, safe to read while jobs are running.
+func (r *RepositoryStub) GetState(name string) (State, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state, ok := r.States[name]
+	if !ok {
+		return State{}, false
+	}
+	return *state, true
+}
+
+func (r *RepositoryStub) state(name string) *State {
+	state, ok := r.States[name]
+	if !ok {
+		state = &State{Name: name}
+		r.States[name] = state
+	}
+	return state
+}
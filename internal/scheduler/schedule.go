@@ -0,0 +1,174 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// Schedule decides when a job runs.
+type Schedule interface {
+	// Next returns the first time the job runs after the given time.
+	Next(after time.Time) time.Time
+	String() string
+}
+
+// ParseSchedule parses a cron expression with the fields minute, hour, day of month, month and day of week, e.g.
+// "30 3 * * 1-5", evaluated in UTC. Fields take numbers, ranges, lists and steps. Besides it accepts "@hourly",
+// "@daily", "@weekly" and "@every <duration>", e.g. "@every 15m".
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	switch {
+	case strings.HasPrefix(spec, "@every "):
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil || interval < time.Second {
+			return nil, fmt.Errorf("%w: %q needs a duration of at least a second", ErrInvalidSchedule, spec)
+		}
+		return every{interval: interval}, nil
+	case spec == "@hourly":
+		return ParseSchedule("0 * * * *")
+	case spec == "@daily":
+		return ParseSchedule("0 0 * * *")
+	case spec == "@weekly":
+		return ParseSchedule("0 0 * * 0")
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: %q must have 5 fields", ErrInvalidSchedule, spec)
+	}
+	c := cron{spec: spec}
+	var err error
+	if c.minutes, err = parseField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("%w: minute of %q: %v", ErrInvalidSchedule, spec, err)
+	}
+	if c.hours, err = parseField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("%w: hour of %q: %v", ErrInvalidSchedule, spec, err)
+	}
+	if c.days, err = parseField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("%w: day of month of %q: %v", ErrInvalidSchedule, spec, err)
+	}
+	if c.months, err = parseField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("%w: month of %q: %v", ErrInvalidSchedule, spec, err)
+	}
+	// 7 is Sunday too
+	if c.weekdays, err = parseField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("%w: day of week of %q: %v", ErrInvalidSchedule, spec, err)
+	}
+	if c.weekdays&(1<<7) != 0 {
+		c.weekdays |= 1
+	}
+	c.anyDay = fields[2] == "*"
+	c.anyWeekday = fields[4] == "*"
+	return c, nil
+}
+
+type every struct {
+	interval time.Duration
+}
+
+func (e every) Next(after time.Time) time.Time {
+	return after.Add(e.interval)
+}
+
+func (e every) String() string {
+	return "@every " + e.interval.String()
+}
+
+// cron holds the allowed values of each field as bits.
+type cron struct {
+	spec                                   string
+	minutes, hours, days, months, weekdays uint64
+	anyDay, anyWeekday                     bool
+}
+
+func (c cron) String() string {
+	return c.spec
+}
+
+func (c cron) Next(after time.Time) time.Time {
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	// Every combination repeats within a few years, a limit keeps impossible dates like Feb 30 from looping forever
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !has(c.months, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !has(c.hours, t.Hour()) {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if !has(c.minutes, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches follows cron: when both the day of month and the day of week are restricted, either has to match.
+func (c cron) dayMatches(t time.Time) bool {
+	day := has(c.days, t.Day())
+	weekday := has(c.weekdays, int(t.Weekday()))
+	switch {
+	case c.anyDay && c.anyWeekday:
+		return true
+	case c.anyDay:
+		return weekday
+	case c.anyWeekday:
+		return day
+	}
+	return day || weekday
+}
+
+func has(bits uint64, value int) bool {
+	return bits&(1<<uint(value)) != 0
+}
+
+func parseField(field string, min int, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+		from, to := min, max
+		if rangePart != "*" {
+			fromPart, toPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if from, err = strconv.Atoi(fromPart); err != nil {
+				return 0, fmt.Errorf("invalid value %q", fromPart)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(toPart); err != nil {
+					return 0, fmt.Errorf("invalid value %q", toPart)
+				}
+			} else if hasStep {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for value := from; value <= to; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
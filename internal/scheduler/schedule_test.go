@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	// Monday
+	after := time.Date(2025, 6, 2, 9, 17, 30, 0, time.UTC)
+
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"@every 15m", after.Add(15 * time.Minute)},
+		{"@hourly", time.Date(2025, 6, 2, 10, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2025, 6, 8, 0, 0, 0, 0, time.UTC)},
+		{"* * * * *", time.Date(2025, 6, 2, 9, 18, 0, 0, time.UTC)},
+		{"*/20 * * * *", time.Date(2025, 6, 2, 9, 20, 0, 0, time.UTC)},
+		{"30 3 * * *", time.Date(2025, 6, 3, 3, 30, 0, 0, time.UTC)},
+		{"0 8-10 * * *", time.Date(2025, 6, 2, 10, 0, 0, 0, time.UTC)},
+		{"0 9 * * 6,7", time.Date(2025, 6, 7, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Either the day of month or the day of week
+		{"0 0 15 * 3", time.Date(2025, 6, 4, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			// when
+			schedule, err := ParseSchedule(test.spec)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, schedule.Next(after))
+		})
+	}
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "@every", "@every 10ms", "@yearly", "* * * *", "60 * * * *", "* 24 * * *",
+		"* * 0 * *", "* * * 13 *", "* * * * 8", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		t.Run(spec, func(t *testing.T) {
+			// when
+			_, err := ParseSchedule(spec)
+
+			// then
+			assert.ErrorIs(t, err, ErrInvalidSchedule)
+		})
+	}
+}
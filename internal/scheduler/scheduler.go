@@ -0,0 +1,345 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	checkInterval = 10 * time.Second
+	// leaseDuration is how long a leader that stopped renewing blocks the other instances from taking over
+	leaseDuration  = 30 * time.Second
+	maxErrorLength = 1000
+)
+
+var ErrJobNotFound = errors.New("job not found")
+var ErrDuplicateJob = errors.New("job registered twice")
+
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// Job is work run on a schedule by the leader instance.
+type Job struct {
+	// Name identifies the job in its stored state, so it must not change between releases
+	Name     string
+	Schedule Schedule
+	// PerUser runs the job once for every user who is not disabled, with the user in the context. A failure for
+	// one user does not stop the run for the others.
+	PerUser bool
+	Run     func(ctx context.Context, now time.Time) error
+}
+
+// State is the stored state of a job.
+type State struct {
+	Name string
+	// NextRunAt is nil until the job first ran, it is due right away then
+	NextRunAt      *time.Time
+	LastStartedAt  *time.Time
+	LastFinishedAt *time.Time
+	// LastStatus is empty until the job first ran
+	LastStatus   Status
+	LastError    string
+	LastDuration time.Duration
+	// Users and FailedUsers count the users of the last run of a per-user job
+	Users       int
+	FailedUsers int
+}
+
+// Result is the outcome of a run.
+type Result struct {
+	FinishedAt  time.Time
+	Status      Status
+	Error       string
+	Duration    time.Duration
+	Users       int
+	FailedUsers int
+}
+
+type Leader struct {
+	InstanceId string
+	Since      time.Time
+	LeaseUntil time.Time
+}
+
+// JobStatus is a registered job with its stored state.
+type JobStatus struct {
+	State
+	Schedule string
+	PerUser  bool
+}
+
+// Overview is the state of the scheduler across all instances.
+type Overview struct {
+	// InstanceId identifies the instance answering
+	InstanceId string
+	// Leader is nil when no instance has been the leader yet
+	Leader *Leader
+	Jobs   []JobStatus
+}
+
+type UserProvider interface {
+	GetAllUsers(ctx context.Context) ([]user.User, error)
+}
+
+// Scheduler runs the registered jobs on their schedules. Of all instances sharing the database only the leader
+// runs them, so jobs never run twice at the same time. The leader holds a lease it renews on every check, when it
+// stops another instance takes over once the lease ends.
+type Scheduler struct {
+	repo       Repository
+	users      UserProvider
+	clock      utils.Clock
+	instanceId string
+
+	mu   sync.Mutex
+	jobs []Job
+	// running holds the jobs this instance is running
+	running map[string]bool
+	// runsCtx is the context of the runs while this instance is the leader, stopRuns cancels it
+	runsCtx  context.Context
+	stopRuns context.CancelFunc
+	runs     sync.WaitGroup
+}
+
+func NewScheduler(repo Repository, users UserProvider, cfg config.Scheduler) *Scheduler {
+	instanceId := cfg.InstanceId
+	if instanceId == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "klokku"
+		}
+		instanceId = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	return &Scheduler{
+		repo:       repo,
+		users:      users,
+		clock:      &utils.SystemClock{},
+		instanceId: instanceId,
+		running:    make(map[string]bool),
+	}
+}
+
+// Register adds the job, it fails with ErrDuplicateJob when a job of the same name is registered already.
+func (s *Scheduler) Register(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, registered := range s.jobs {
+		if registered.Name == job.Name {
+			return fmt.Errorf("%w: %s", ErrDuplicateJob, job.Name)
+		}
+	}
+	s.jobs = append(s.jobs, job)
+	return nil
+}
+
+// Start runs the due jobs while this instance is the leader, until ctx is done. It then waits for the running jobs,
+// cancelled with ctx, and hands the leadership over.
+func (s *Scheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	log.Infof("Job scheduler started as instance %s", s.instanceId)
+	for {
+		s.check(ctx, s.clock.Now())
+		select {
+		case <-ctx.Done():
+			s.resign()
+			s.runs.Wait()
+			releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := s.repo.ReleaseLeadership(releaseCtx, s.instanceId); err != nil {
+				log.Errorf("failed to release scheduler leadership: %v", err)
+			}
+			cancel()
+			log.Info("Job scheduler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check renews the leadership and starts the due jobs when this instance is the leader.
+func (s *Scheduler) check(ctx context.Context, now time.Time) {
+	leader, err := s.repo.AcquireLeadership(ctx, s.instanceId, now, now.Add(leaseDuration))
+	if err != nil {
+		log.Errorf("failed to renew scheduler leadership: %v", err)
+	}
+	if err != nil || !leader {
+		s.resign()
+		return
+	}
+
+	s.mu.Lock()
+	if s.runsCtx == nil {
+		log.Infof("Instance %s became the job scheduler leader", s.instanceId)
+		s.runsCtx, s.stopRuns = context.WithCancel(ctx)
+	}
+	runsCtx := s.runsCtx
+	s.mu.Unlock()
+	s.startDue(ctx, runsCtx, now)
+}
+
+// resign cancels the runs of this instance when it is no longer the leader.
+func (s *Scheduler) resign() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.runsCtx != nil {
+		log.Infof("Instance %s is no longer the job scheduler leader", s.instanceId)
+		s.stopRuns()
+		s.runsCtx, s.stopRuns = nil, nil
+	}
+}
+
+// startDue starts the jobs due at now, which run with runsCtx.
+func (s *Scheduler) startDue(ctx context.Context, runsCtx context.Context, now time.Time) {
+	states, err := s.repo.ListStates(ctx)
+	if err != nil {
+		log.Errorf("failed to get job states: %v", err)
+		return
+	}
+	nextRuns := make(map[string]*time.Time, len(states))
+	for _, state := range states {
+		nextRuns[state.Name] = state.NextRunAt
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		next := nextRuns[job.Name]
+		if s.running[job.Name] || (next != nil && next.After(now)) {
+			continue
+		}
+		if err := s.repo.RecordStart(ctx, job.Name, now, job.Schedule.Next(now)); err != nil {
+			log.Errorf("failed to start job %s: %v", job.Name, err)
+			continue
+		}
+		s.running[job.Name] = true
+		s.runs.Add(1)
+		go s.run(runsCtx, job, now)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, job Job, now time.Time) {
+	defer s.runs.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, job.Name)
+		s.mu.Unlock()
+	}()
+
+	log.Debugf("running job %s", job.Name)
+	startedAt := s.clock.Now()
+	result := Result{Status: StatusSucceeded}
+	var err error
+	if job.PerUser {
+		result.Users, result.FailedUsers, err = s.runPerUser(ctx, job, now)
+	} else {
+		err = runSafely(ctx, job, now)
+	}
+	result.FinishedAt = s.clock.Now()
+	result.Duration = result.FinishedAt.Sub(startedAt)
+	if err != nil {
+		log.Errorf("job %s failed: %v", job.Name, err)
+		result.Status = StatusFailed
+		result.Error = utils.Truncate(err.Error(), maxErrorLength)
+	}
+
+	// The run is recorded even when it was cancelled
+	if err := s.repo.RecordFinish(context.WithoutCancel(ctx), job.Name, result); err != nil {
+		log.Errorf("failed to record finish of job %s: %v", job.Name, err)
+	}
+}
+
+func (s *Scheduler) runPerUser(ctx context.Context, job Job, now time.Time) (int, int, error) {
+	users, err := s.users.GetAllUsers(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get users: %w", err)
+	}
+	count, failed := 0, 0
+	var errs []error
+	for _, u := range users {
+		if u.Disabled {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		count++
+		if err := runSafely(user.WithUser(ctx, u), job, now); err != nil {
+			failed++
+			errs = append(errs, fmt.Errorf("user %d: %w", u.Id, err))
+		}
+	}
+	return count, failed, errors.Join(errs...)
+}
+
+func runSafely(ctx context.Context, job Job, now time.Time) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panic: %v", r)
+		}
+	}()
+	return job.Run(ctx, now)
+}
+
+// Overview returns the registered jobs with their state and the current leader.
+func (s *Scheduler) Overview(ctx context.Context) (Overview, error) {
+	states, err := s.repo.ListStates(ctx)
+	if err != nil {
+		return Overview{}, err
+	}
+	byName := make(map[string]State, len(states))
+	for _, state := range states {
+		byName[state.Name] = state
+	}
+	overview := Overview{InstanceId: s.instanceId, Jobs: make([]JobStatus, 0)}
+	leader, ok, err := s.repo.GetLeader(ctx)
+	if err != nil {
+		return Overview{}, err
+	}
+	if ok {
+		overview.Leader = &leader
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		state, ok := byName[job.Name]
+		if !ok {
+			state = State{Name: job.Name}
+		}
+		overview.Jobs = append(overview.Jobs, JobStatus{State: state, Schedule: job.Schedule.String(), PerUser: job.PerUser})
+	}
+	return overview, nil
+}
+
+// Trigger makes the job due now, the leader runs it on its next check unless it is running already.
+func (s *Scheduler) Trigger(ctx context.Context, name string) error {
+	if !s.isRegistered(name) {
+		return ErrJobNotFound
+	}
+	return s.repo.RequestRun(ctx, name, s.clock.Now())
+}
+
+func (s *Scheduler) isRegistered(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,244 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+
+type usersStub []user.User
+
+func (u usersStub) GetAllUsers(ctx context.Context) ([]user.User, error) {
+	return u, nil
+}
+
+// runRecorder is a job recording its runs.
+type runRecorder struct {
+	mu    sync.Mutex
+	runs  []time.Time
+	users []int
+	err   error
+}
+
+func (r *runRecorder) run(ctx context.Context, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs = append(r.runs, at)
+	if userId, err := user.CurrentId(ctx); err == nil {
+		r.users = append(r.users, userId)
+		if userId == 2 {
+			return r.err
+		}
+		return nil
+	}
+	return r.err
+}
+
+func setupScheduler(repo *RepositoryStub, instanceId string, users usersStub) *Scheduler {
+	s := NewScheduler(repo, users, config.Scheduler{InstanceId: instanceId})
+	s.clock = &utils.MockClock{FixedNow: now}
+	return s
+}
+
+func parseSchedule(t *testing.T, spec string) Schedule {
+	schedule, err := ParseSchedule(spec)
+	require.NoError(t, err)
+	return schedule
+}
+
+// checkAndWait runs a check and waits for the started jobs to finish.
+func checkAndWait(s *Scheduler, at time.Time) {
+	s.check(context.Background(), at)
+	s.runs.Wait()
+}
+
+func TestScheduler_Check(t *testing.T) {
+	t.Run("should run a new job right away and again when it is due", func(t *testing.T) {
+		// given
+		repo := NewRepositoryStub()
+		s := setupScheduler(repo, "a", nil)
+		job := &runRecorder{}
+		require.NoError(t, s.Register(Job{Name: "sweep", Schedule: parseSchedule(t, "@every 1m"), Run: job.run}))
+
+		// when
+		checkAndWait(s, now)
+		checkAndWait(s, now.Add(30*time.Second))
+		checkAndWait(s, now.Add(time.Minute))
+
+		// then
+		assert.Equal(t, []time.Time{now, now.Add(time.Minute)}, job.runs)
+		state, ok := repo.GetState("sweep")
+		require.True(t, ok)
+		assert.Equal(t, StatusSucceeded, state.LastStatus)
+		assert.Equal(t, now.Add(2*time.Minute), *state.NextRunAt)
+	})
+
+	t.Run("should run jobs only on the leader", func(t *testing.T) {
+		// given
+		repo := NewRepositoryStub()
+		leader := setupScheduler(repo, "a", nil)
+		follower := setupScheduler(repo, "b", nil)
+		leaderJob, followerJob := &runRecorder{}, &runRecorder{}
+		require.NoError(t, leader.Register(Job{Name: "sweep", Schedule: parseSchedule(t, "@every 1m"), Run: leaderJob.run}))
+		require.NoError(t, follower.Register(Job{Name: "cleanup", Schedule: parseSchedule(t, "@every 1m"), Run: followerJob.run}))
+
+		// when
+		checkAndWait(leader, now)
+		checkAndWait(follower, now.Add(10*time.Second))
+
+		// then
+		assert.Len(t, leaderJob.runs, 1)
+		assert.Empty(t, followerJob.runs)
+		assert.Equal(t, "a", repo.Leader.InstanceId)
+	})
+
+	t.Run("should take over when the lease of the leader ended", func(t *testing.T) {
+		// given
+		repo := NewRepositoryStub()
+		leader := setupScheduler(repo, "a", nil)
+		follower := setupScheduler(repo, "b", nil)
+		job := &runRecorder{}
+		require.NoError(t, leader.Register(Job{Name: "sweep", Schedule: parseSchedule(t, "@every 1m"), Run: job.run}))
+		require.NoError(t, follower.Register(Job{Name: "sweep", Schedule: parseSchedule(t, "@every 1m"), Run: job.run}))
+		checkAndWait(leader, now)
+
+		// when, the leader stopped renewing
+		checkAndWait(follower, now.Add(time.Minute))
+
+		// then
+		assert.Equal(t, []time.Time{now, now.Add(time.Minute)}, job.runs)
+		assert.Equal(t, "b", repo.Leader.InstanceId)
+	})
+
+	t.Run("should record a failed run and keep the schedule", func(t *testing.T) {
+		// given
+		repo := NewRepositoryStub()
+		s := setupScheduler(repo, "a", nil)
+		job := &runRecorder{err: errors.New("service down")}
+		require.NoError(t, s.Register(Job{Name: "sweep", Schedule: parseSchedule(t, "@hourly"), Run: job.run}))
+
+		// when
+		checkAndWait(s, now)
+
+		// then
+		state, _ := repo.GetState("sweep")
+		assert.Equal(t, StatusFailed, state.LastStatus)
+		assert.Equal(t, "service down", state.LastError)
+		assert.Equal(t, now.Add(time.Hour), *state.NextRunAt)
+	})
+
+	t.Run("should record a panicking job as failed", func(t *testing.T) {
+		// given
+		repo := NewRepositoryStub()
+		s := setupScheduler(repo, "a", nil)
+		require.NoError(t, s.Register(Job{Name: "sweep", Schedule: parseSchedule(t, "@hourly"), Run: func(ctx context.Context, now time.Time) error {
+			panic("boom")
+		}}))
+
+		// when
+		checkAndWait(s, now)
+
+		// then
+		state, _ := repo.GetState("sweep")
+		assert.Equal(t, StatusFailed, state.LastStatus)
+		assert.Contains(t, state.LastError, "boom")
+	})
+
+	t.Run("should run a per-user job for every enabled user", func(t *testing.T) {
+		// given
+		repo := NewRepositoryStub()
+		users := usersStub{{Id: 1}, {Id: 2}, {Id: 3, Disabled: true}, {Id: 4}}
+		s := setupScheduler(repo, "a", users)
+		job := &runRecorder{err: errors.New("no plan")}
+		require.NoError(t, s.Register(Job{Name: "generate", Schedule: parseSchedule(t, "@daily"), PerUser: true, Run: job.run}))
+
+		// when
+		checkAndWait(s, now)
+
+		// then
+		assert.Equal(t, []int{1, 2, 4}, job.users)
+		state, _ := repo.GetState("generate")
+		assert.Equal(t, StatusFailed, state.LastStatus)
+		assert.Equal(t, 3, state.Users)
+		assert.Equal(t, 1, state.FailedUsers)
+		assert.Contains(t, state.LastError, "user 2: no plan")
+	})
+}
+
+func TestScheduler_Register(t *testing.T) {
+	// given
+	s := setupScheduler(NewRepositoryStub(), "a", nil)
+	require.NoError(t, s.Register(Job{Name: "sweep", Schedule: parseSchedule(t, "@hourly"), Run: (&runRecorder{}).run}))
+
+	// when
+	err := s.Register(Job{Name: "sweep", Schedule: parseSchedule(t, "@daily"), Run: (&runRecorder{}).run})
+
+	// then
+	assert.ErrorIs(t, err, ErrDuplicateJob)
+}
+
+func TestScheduler_Trigger(t *testing.T) {
+	t.Run("should make the job due now", func(t *testing.T) {
+		// given
+		repo := NewRepositoryStub()
+		s := setupScheduler(repo, "a", nil)
+		job := &runRecorder{}
+		require.NoError(t, s.Register(Job{Name: "rollover", Schedule: parseSchedule(t, "@hourly"), Run: job.run}))
+		checkAndWait(s, now)
+		later := now.Add(10 * time.Minute)
+		s.clock = &utils.MockClock{FixedNow: later}
+
+		// when
+		err := s.Trigger(context.Background(), "rollover")
+		checkAndWait(s, later)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []time.Time{now, later}, job.runs)
+	})
+
+	t.Run("should fail for an unknown job", func(t *testing.T) {
+		// given
+		s := setupScheduler(NewRepositoryStub(), "a", nil)
+
+		// when
+		err := s.Trigger(context.Background(), "unknown")
+
+		// then
+		assert.ErrorIs(t, err, ErrJobNotFound)
+	})
+}
+
+func TestScheduler_Overview(t *testing.T) {
+	// given
+	repo := NewRepositoryStub()
+	s := setupScheduler(repo, "a", nil)
+	require.NoError(t, s.Register(Job{Name: "sweep", Schedule: parseSchedule(t, "@every 1m"), Run: (&runRecorder{}).run}))
+	require.NoError(t, s.Register(Job{Name: "generate", Schedule: parseSchedule(t, "@daily"), PerUser: true, Run: (&runRecorder{}).run}))
+	require.NoError(t, repo.RequestRun(context.Background(), "sweep", now))
+
+	// when
+	overview, err := s.Overview(context.Background())
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "a", overview.InstanceId)
+	assert.Nil(t, overview.Leader)
+	require.Len(t, overview.Jobs, 2)
+	assert.Equal(t, "sweep", overview.Jobs[0].Name)
+	assert.Equal(t, "@every 1m0s", overview.Jobs[0].Schedule)
+	assert.Equal(t, now, *overview.Jobs[0].NextRunAt)
+	assert.Equal(t, "generate", overview.Jobs[1].Name)
+	assert.True(t, overview.Jobs[1].PerUser)
+	assert.Nil(t, overview.Jobs[1].NextRunAt)
+}
@@ -0,0 +1,97 @@
+// Package tracing records OpenTelemetry traces of HTTP requests, database queries and calls to third-party
+// providers, and exports them to a collector with OTLP over HTTP. Instrumented code uses the global tracer
+// provider of the otel package, which does nothing until Setup installs the provider of the OpenTelemetry SDK.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/klokku/klokku/internal/config"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Provider exports the spans of all tracers in batches.
+type Provider struct {
+	provider *sdktrace.TracerProvider
+}
+
+// Setup installs the global tracer provider exporting to the configured collector, and the W3C trace context
+// propagator, so traces continue across the calls to and from other services. It returns nil when tracing is
+// disabled.
+func Setup(cfg config.Tracing) (*Provider, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if cfg.Endpoint == "" {
+		return nil, nil
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid tracing endpoint %q", cfg.Endpoint)
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("tracing sample ratio must be between 0 and 1, got %v", cfg.SampleRatio)
+	}
+	headers, err := parseHeaders(cfg.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(strings.TrimSuffix(cfg.Endpoint, "/")+"/v1/traces"),
+		otlptracehttp.WithHeaders(headers),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+	provider := newProvider(exporter, cfg.ServiceName, cfg.SampleRatio)
+	otel.SetTracerProvider(provider.provider)
+	log.Infof("Exporting traces of %s to %s", cfg.ServiceName, endpoint.Host)
+	return provider, nil
+}
+
+// Shutdown exports the spans still waiting in the queue. It is safe to call on a nil provider.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	return p.provider.Shutdown(ctx)
+}
+
+// newProvider samples the traces started by Klokku with the ratio, requests coming with a trace context follow
+// the sampling decision of the caller.
+func newProvider(exporter sdktrace.SpanExporter, serviceName string, sampleRatio float64) *Provider {
+	return &Provider{provider: sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+	)}
+}
+
+// Tracer returns the tracer of the instrumented package, e.g. "klokku/calendar".
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(name)
+}
+
+func parseHeaders(raw string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(raw, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid tracing header %q, expected key=value", pair)
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
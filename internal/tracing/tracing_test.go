@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// flush exports the ended spans, the in-memory exporter forgets them on shutdown
+func flush(t *testing.T, provider *Provider) {
+	require.NoError(t, provider.provider.ForceFlush(context.Background()))
+}
+
+func shutdown(t *testing.T, provider *Provider) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, provider.Shutdown(ctx))
+}
+
+func TestProvider(t *testing.T) {
+	t.Run("should record child spans in the trace of the parent", func(t *testing.T) {
+		// given
+		exporter := tracetest.NewInMemoryExporter()
+		provider := newProvider(exporter, "klokku-test", 1)
+		tracer := provider.provider.Tracer("test")
+
+		// when
+		ctx, parent := tracer.Start(context.Background(), "parent")
+		_, child := tracer.Start(ctx, "child", trace.WithAttributes(attribute.String("key", "value")))
+		child.End()
+		parent.End()
+		flush(t, provider)
+
+		// then
+		spans := exporter.GetSpans()
+		require.Len(t, spans, 2)
+		childData, parentData := spans[0], spans[1]
+		assert.Equal(t, "child", childData.Name)
+		assert.Equal(t, parentData.SpanContext.TraceID(), childData.SpanContext.TraceID())
+		assert.Equal(t, parentData.SpanContext.SpanID(), childData.Parent.SpanID())
+		assert.Equal(t, []attribute.KeyValue{attribute.String("key", "value")}, childData.Attributes)
+		assert.Contains(t, parentData.Resource.Attributes(), attribute.String("service.name", "klokku-test"))
+	})
+
+	t.Run("should not record traces which are not sampled but propagate them", func(t *testing.T) {
+		// given
+		exporter := tracetest.NewInMemoryExporter()
+		provider := newProvider(exporter, "klokku-test", 0)
+		tracer := provider.provider.Tracer("test")
+
+		// when
+		ctx, parent := tracer.Start(context.Background(), "parent")
+		_, child := tracer.Start(ctx, "child")
+		child.End()
+		parent.End()
+		flush(t, provider)
+
+		// then
+		assert.Empty(t, exporter.GetSpans())
+		assert.True(t, parent.SpanContext().IsValid())
+		assert.False(t, parent.SpanContext().IsSampled())
+		assert.Equal(t, parent.SpanContext().TraceID(), child.SpanContext().TraceID())
+	})
+
+	t.Run("should follow the sampling decision of the caller", func(t *testing.T) {
+		// given
+		exporter := tracetest.NewInMemoryExporter()
+		provider := newProvider(exporter, "klokku-test", 0)
+		remote := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{1, 2, 3},
+			SpanID:     trace.SpanID{4, 5, 6},
+			TraceFlags: trace.FlagsSampled,
+			Remote:     true,
+		})
+
+		// when
+		_, span := provider.provider.Tracer("test").
+			Start(trace.ContextWithRemoteSpanContext(context.Background(), remote), "request")
+		span.End()
+		flush(t, provider)
+
+		// then
+		spans := exporter.GetSpans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, remote.TraceID(), spans[0].SpanContext.TraceID())
+		assert.Equal(t, remote.SpanID(), spans[0].Parent.SpanID())
+	})
+}
+
+func TestSetup(t *testing.T) {
+	t.Run("should send spans to the collector on shutdown", func(t *testing.T) {
+		// given
+		var mu sync.Mutex
+		var path, apiKey string
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			path = r.URL.Path
+			apiKey = r.Header.Get("X-Api-Key")
+		}))
+		defer collector.Close()
+		provider, err := Setup(config.Tracing{
+			Endpoint:    collector.URL,
+			Headers:     "X-Api-Key=secret",
+			ServiceName: "klokku-test",
+			SampleRatio: 1,
+		})
+		require.NoError(t, err)
+
+		// when
+		_, span := Tracer("klokku/test").Start(context.Background(), "GET /api/event/current")
+		span.End()
+		shutdown(t, provider)
+
+		// then
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, "/v1/traces", path)
+		assert.Equal(t, "secret", apiKey)
+	})
+
+	t.Run("should be disabled without an endpoint", func(t *testing.T) {
+		provider, err := Setup(config.Tracing{})
+
+		require.NoError(t, err)
+		assert.Nil(t, provider)
+		assert.NoError(t, provider.Shutdown(context.Background()))
+	})
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := parseHeaders("x-api-key=secret, x-dataset = klokku")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"x-api-key": "secret", "x-dataset": "klokku"}, headers)
+
+	_, err = parseHeaders("no-value")
+	assert.Error(t, err)
+}
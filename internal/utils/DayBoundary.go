@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// DayBoundary calculates day boundaries in the given location. Calculations are done on wall clock dates,
+// so days that are shorter or longer because of DST transitions are handled correctly.
+// Days start at midnight unless another start hour is set with WithStartHour.
+type DayBoundary struct {
+	location  *time.Location
+	startHour int
+}
+
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+func NewDayBoundary(location *time.Location) DayBoundary {
+	return DayBoundary{location: location}
+}
+
+func NewDayBoundaryForTimezone(timezone string) (DayBoundary, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return DayBoundary{}, fmt.Errorf("could not load location for timezone %s: %w", timezone, err)
+	}
+	return NewDayBoundary(location), nil
+}
+
+// WithStartHour returns the boundary of days starting at the given hour (0-23), e.g. 4 for people working
+// past midnight, whose night counts into the previous day.
+func (b DayBoundary) WithStartHour(hour int) DayBoundary {
+	b.startHour = hour
+	return b
+}
+
+func (b DayBoundary) Location() *time.Location {
+	return b.location
+}
+
+// StartOfDay returns the start of the day containing t, midnight unless another start hour is set.
+func (b DayBoundary) StartOfDay(t time.Time) time.Time {
+	year, month, day := b.Date(t)
+	return time.Date(year, month, day, b.startHour, 0, 0, 0, b.location)
+}
+
+// StartOfNextDay returns the start of the day following the day containing t.
+func (b DayBoundary) StartOfNextDay(t time.Time) time.Time {
+	year, month, day := b.Date(t)
+	return time.Date(year, month, day+1, b.startHour, 0, 0, 0, b.location)
+}
+
+// Date returns the calendar date of the day containing t. Before the start hour, it is the previous date.
+func (b DayBoundary) Date(t time.Time) (int, time.Month, int) {
+	local := t.In(b.location)
+	if local.Hour() < b.startHour {
+		local = time.Date(local.Year(), local.Month(), local.Day()-1, 12, 0, 0, 0, b.location)
+	}
+	return local.Date()
+}
+
+// EndOfDay returns the last instant of the day containing t. Time ranges are queried inclusively,
+// so a day ends one nanosecond before the next one starts.
+func (b DayBoundary) EndOfDay(t time.Time) time.Time {
+	return b.StartOfNextDay(t).Add(-time.Nanosecond)
+}
+
+func (b DayBoundary) SameDay(t1, t2 time.Time) bool {
+	year1, month1, day1 := b.Date(t1)
+	year2, month2, day2 := b.Date(t2)
+	return year1 == year2 && month1 == month2 && day1 == day2
+}
+
+// Split divides the range into consecutive ranges, each within a single day. Every range except the last
+// one ends exactly where the next one starts, at the start of the following day, so no time is lost between them.
+// A range ending exactly at the start of a day is kept within its day instead of producing an empty range for the next day.
+func (b DayBoundary) Split(start, end time.Time) []TimeRange {
+	var ranges []TimeRange
+	for {
+		nextDay := b.StartOfNextDay(start)
+		if !end.After(nextDay) {
+			return append(ranges, TimeRange{Start: start, End: end})
+		}
+		ranges = append(ranges, TimeRange{Start: start, End: nextDay})
+		start = nextDay
+	}
+}
@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDayBoundary_Split(t *testing.T) {
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	require.NoError(t, err)
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		location *time.Location
+		start    time.Time
+		end      time.Time
+		want     []TimeRange
+	}{
+		{
+			name:     "range within a single day",
+			location: warsaw,
+			start:    time.Date(2025, 6, 10, 10, 0, 0, 0, warsaw),
+			end:      time.Date(2025, 6, 10, 12, 0, 0, 0, warsaw),
+			want: []TimeRange{
+				{time.Date(2025, 6, 10, 10, 0, 0, 0, warsaw), time.Date(2025, 6, 10, 12, 0, 0, 0, warsaw)},
+			},
+		},
+		{
+			name:     "range ending exactly at midnight stays in its day",
+			location: warsaw,
+			start:    time.Date(2025, 6, 10, 22, 0, 0, 0, warsaw),
+			end:      time.Date(2025, 6, 11, 0, 0, 0, 0, warsaw),
+			want: []TimeRange{
+				{time.Date(2025, 6, 10, 22, 0, 0, 0, warsaw), time.Date(2025, 6, 11, 0, 0, 0, 0, warsaw)},
+			},
+		},
+		{
+			name:     "range spanning three days",
+			location: warsaw,
+			start:    time.Date(2025, 6, 10, 22, 0, 0, 0, warsaw),
+			end:      time.Date(2025, 6, 12, 2, 0, 0, 0, warsaw),
+			want: []TimeRange{
+				{time.Date(2025, 6, 10, 22, 0, 0, 0, warsaw), time.Date(2025, 6, 11, 0, 0, 0, 0, warsaw)},
+				{time.Date(2025, 6, 11, 0, 0, 0, 0, warsaw), time.Date(2025, 6, 12, 0, 0, 0, 0, warsaw)},
+				{time.Date(2025, 6, 12, 0, 0, 0, 0, warsaw), time.Date(2025, 6, 12, 2, 0, 0, 0, warsaw)},
+			},
+		},
+		{
+			name:     "split uses the boundary location, not the location of the range",
+			location: newYork,
+			start:    time.Date(2025, 6, 11, 3, 0, 0, 0, time.UTC), // 23:00 in New York
+			end:      time.Date(2025, 6, 11, 6, 0, 0, 0, time.UTC), // 02:00 in New York
+			want: []TimeRange{
+				{time.Date(2025, 6, 11, 3, 0, 0, 0, time.UTC), time.Date(2025, 6, 11, 0, 0, 0, 0, newYork)},
+				{time.Date(2025, 6, 11, 0, 0, 0, 0, newYork), time.Date(2025, 6, 11, 6, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name:     "across the year boundary",
+			location: warsaw,
+			start:    time.Date(2025, 12, 31, 23, 0, 0, 0, warsaw),
+			end:      time.Date(2026, 1, 1, 1, 0, 0, 0, warsaw),
+			want: []TimeRange{
+				{time.Date(2025, 12, 31, 23, 0, 0, 0, warsaw), time.Date(2026, 1, 1, 0, 0, 0, 0, warsaw)},
+				{time.Date(2026, 1, 1, 0, 0, 0, 0, warsaw), time.Date(2026, 1, 1, 1, 0, 0, 0, warsaw)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewDayBoundary(tt.location).Split(tt.start, tt.end)
+
+			require.Len(t, got, len(tt.want))
+			for i := range tt.want {
+				assert.True(t, tt.want[i].Start.Equal(got[i].Start), "range %d start: want %v, got %v", i, tt.want[i].Start, got[i].Start)
+				assert.True(t, tt.want[i].End.Equal(got[i].End), "range %d end: want %v, got %v", i, tt.want[i].End, got[i].End)
+			}
+		})
+	}
+}
+
+func TestDayBoundary_DST(t *testing.T) {
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	require.NoError(t, err)
+	dayBoundary := NewDayBoundary(warsaw)
+
+	t.Run("day when clocks move forward is 23 hours long", func(t *testing.T) {
+		noon := time.Date(2025, 3, 30, 12, 0, 0, 0, warsaw)
+
+		dayLength := dayBoundary.StartOfNextDay(noon).Sub(dayBoundary.StartOfDay(noon))
+
+		assert.Equal(t, 23*time.Hour, dayLength)
+	})
+
+	t.Run("day when clocks move back is 25 hours long", func(t *testing.T) {
+		noon := time.Date(2025, 10, 26, 12, 0, 0, 0, warsaw)
+
+		dayLength := dayBoundary.StartOfNextDay(noon).Sub(dayBoundary.StartOfDay(noon))
+
+		assert.Equal(t, 25*time.Hour, dayLength)
+	})
+
+	t.Run("range over the DST change is split at local midnight", func(t *testing.T) {
+		start := time.Date(2025, 10, 25, 20, 0, 0, 0, warsaw)
+		end := time.Date(2025, 10, 27, 1, 0, 0, 0, warsaw)
+
+		ranges := dayBoundary.Split(start, end)
+
+		require.Len(t, ranges, 3)
+		assert.Equal(t, 25*time.Hour, ranges[1].End.Sub(ranges[1].Start))
+		assert.True(t, dayBoundary.SameDay(ranges[2].Start, end))
+	})
+}
+
+func TestDayBoundary_WithStartHour(t *testing.T) {
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	require.NoError(t, err)
+	dayBoundary := NewDayBoundary(warsaw).WithStartHour(4)
+
+	t.Run("night before the start hour belongs to the previous day", func(t *testing.T) {
+		night := time.Date(2025, 6, 11, 2, 30, 0, 0, warsaw)
+
+		assert.Equal(t, time.Date(2025, 6, 10, 4, 0, 0, 0, warsaw), dayBoundary.StartOfDay(night))
+		assert.Equal(t, time.Date(2025, 6, 11, 4, 0, 0, 0, warsaw), dayBoundary.StartOfNextDay(night))
+		assert.True(t, dayBoundary.SameDay(night, time.Date(2025, 6, 10, 22, 0, 0, 0, warsaw)))
+	})
+
+	t.Run("range is split at the start hour", func(t *testing.T) {
+		start := time.Date(2025, 6, 10, 23, 0, 0, 0, warsaw)
+		end := time.Date(2025, 6, 11, 5, 0, 0, 0, warsaw)
+
+		ranges := dayBoundary.Split(start, end)
+
+		require.Len(t, ranges, 2)
+		assert.Equal(t, time.Date(2025, 6, 11, 4, 0, 0, 0, warsaw), ranges[1].Start)
+	})
+}
@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Truncate shortens the text to at most maxBytes bytes without cutting a character in half, e.g. for error messages
+// stored in TEXT columns. Postgres rejects invalid UTF-8, so invalid sequences of the text are replaced too.
+func Truncate(text string, maxBytes int) string {
+	text = strings.ToValidUTF8(text, string(utf8.RuneError))
+	if len(text) <= maxBytes {
+		return text
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut]
+}
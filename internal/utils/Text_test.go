@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		maxBytes int
+		expect   string
+	}{
+		{"short text", "timeout", 10, "timeout"},
+		{"ascii text", "connection refused", 10, "connection"},
+		{"cut inside a multi-byte character", "zażółć", 3, "za"},
+		{"cut after a multi-byte character", "zażółć", 4, "zaż"},
+		{"invalid UTF-8", "bad \xff byte", 20, "bad � byte"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Truncate(tt.text, tt.maxBytes)
+			assert.Equal(t, tt.expect, result)
+			assert.True(t, utf8.ValidString(result))
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package utils
+
+import "time"
+
+// WeekStart returns midnight of the first day of the week containing date, in date's location. Weeks start on
+// Monday when weekFirstDay is not a valid weekday.
+func WeekStart(date time.Time, weekFirstDay time.Weekday) time.Time {
+	if weekFirstDay < time.Sunday || weekFirstDay > time.Saturday {
+		weekFirstDay = time.Monday
+	}
+	delta := (int(date.Weekday()) - int(weekFirstDay) + 7) % 7
+	return time.Date(date.Year(), date.Month(), date.Day()-delta, 0, 0, 0, 0, date.Location())
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeekStart(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Warsaw")
+	tests := []struct {
+		name         string
+		date         time.Time
+		weekFirstDay time.Weekday
+		expect       time.Time
+	}{
+		{"monday week", time.Date(2025, 3, 13, 15, 30, 0, 0, location), time.Monday, time.Date(2025, 3, 10, 0, 0, 0, 0, location)},
+		{"first day of the week", time.Date(2025, 3, 10, 0, 0, 0, 0, location), time.Monday, time.Date(2025, 3, 10, 0, 0, 0, 0, location)},
+		{"sunday week", time.Date(2025, 3, 13, 15, 30, 0, 0, location), time.Sunday, time.Date(2025, 3, 9, 0, 0, 0, 0, location)},
+		{"across DST change", time.Date(2025, 3, 31, 12, 0, 0, 0, location), time.Sunday, time.Date(2025, 3, 30, 0, 0, 0, 0, location)},
+		{"invalid weekday", time.Date(2025, 3, 13, 15, 30, 0, 0, location), time.Weekday(9), time.Date(2025, 3, 10, 0, 0, 0, 0, location)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, WeekStart(tt.date, tt.weekFirstDay))
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"os"
 
 	"github.com/klokku/klokku/internal/app"
@@ -29,7 +30,34 @@ func init() {
 // @in header
 // @name X-User-Id
 // @description User ID header required for authentication
+// @securityDefinitions.apikey XSessionToken
+// @in header
+// @name X-Session-Token
+// @description Token of a user switch session, accepted instead of X-User-Id on user endpoints
 func main() {
+	migrateOnly := flag.Bool("migrate-only", false, "apply the pending database migrations and exit")
+	restore := flag.String("restore", "", "restore the backup of the given name and exit, Klokku must be stopped meanwhile")
+	verifyBackup := flag.String("verify-backup", "", "restore the backup of the given name into a temporary schema and exit")
+	flag.Parse()
+	if *migrateOnly {
+		if err := app.Migrate(); err != nil {
+			log.Fatalf("failed to migrate database: %v", err)
+		}
+		return
+	}
+	if *restore != "" {
+		if err := app.Restore(*restore); err != nil {
+			log.Fatalf("failed to restore backup: %v", err)
+		}
+		return
+	}
+	if *verifyBackup != "" {
+		if err := app.VerifyBackup(*verifyBackup); err != nil {
+			log.Fatalf("backup verification failed: %v", err)
+		}
+		return
+	}
+
 	application, err := app.NewApplication()
 	if err != nil {
 		log.Fatalf("failed to initialize application: %v", err)
@@ -0,0 +1,13 @@
+SET search_path TO klokku, public;
+
+CREATE TABLE event_schedule
+(
+    id             INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id        INTEGER     NOT NULL,
+    budget_item_id INTEGER     NOT NULL REFERENCES budget_item (id) ON DELETE CASCADE,
+    weekdays       INTEGER[]   NOT NULL, -- 0 = Sunday ... 6 = Saturday
+    start_minute   INTEGER     NOT NULL, -- minutes after midnight in the user's timezone
+    enabled        BOOLEAN     NOT NULL DEFAULT TRUE,
+    last_run_at    TIMESTAMPTZ
+);
+CREATE INDEX event_schedule_user_id_idx ON event_schedule (user_id);
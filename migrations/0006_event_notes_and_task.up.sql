@@ -0,0 +1,7 @@
+SET search_path TO klokku, public;
+
+ALTER TABLE current_event ADD COLUMN notes TEXT NOT NULL DEFAULT '';
+ALTER TABLE current_event ADD COLUMN task_id TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE calendar_event ADD COLUMN notes TEXT NOT NULL DEFAULT '';
+ALTER TABLE calendar_event ADD COLUMN task_id TEXT NOT NULL DEFAULT '';
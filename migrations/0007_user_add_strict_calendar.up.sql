@@ -0,0 +1,3 @@
+SET search_path TO klokku, public;
+
+ALTER TABLE users ADD COLUMN strict_calendar BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,16 @@
+SET search_path TO klokku, public;
+
+CREATE TABLE calendar_event_lineage
+(
+    id                  INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id             INTEGER     NOT NULL,
+    source_uid          TEXT        NOT NULL,
+    derived_uid         TEXT        NOT NULL DEFAULT '',
+    operation           TEXT        NOT NULL,
+    caused_by_uid       TEXT        NOT NULL,
+    previous_start_time TIMESTAMPTZ NOT NULL,
+    previous_end_time   TIMESTAMPTZ NOT NULL,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX calendar_event_lineage_source_idx ON calendar_event_lineage (user_id, source_uid);
+CREATE INDEX calendar_event_lineage_derived_idx ON calendar_event_lineage (user_id, derived_uid);
@@ -0,0 +1,3 @@
+SET search_path TO klokku, public;
+
+ALTER TABLE users ADD COLUMN discard_idle_time BOOLEAN NOT NULL DEFAULT FALSE;
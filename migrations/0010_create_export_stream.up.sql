@@ -0,0 +1,19 @@
+SET search_path TO klokku, public;
+
+CREATE TABLE export_stream
+(
+    user_id    INTEGER PRIMARY KEY,
+    token      TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX export_stream_token_idx ON export_stream (token);
+
+CREATE TABLE export_stream_change
+(
+    id         BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id    INTEGER     NOT NULL,
+    type       TEXT        NOT NULL,
+    payload    JSONB       NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX export_stream_change_user_id_idx ON export_stream_change (user_id, id);
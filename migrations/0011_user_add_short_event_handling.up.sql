@@ -0,0 +1,4 @@
+SET search_path TO klokku, public;
+
+ALTER TABLE users ADD COLUMN short_event_threshold INTEGER NOT NULL DEFAULT 60;
+ALTER TABLE users ADD COLUMN short_event_handling TEXT NOT NULL DEFAULT 'merge_next';
@@ -0,0 +1,5 @@
+SET search_path TO klokku, public;
+
+-- Optional per-weekday duration targets, indexed by weekday (0 = Sunday ... 6 = Saturday), in seconds.
+ALTER TABLE budget_item ADD COLUMN daily_durations_sec INTEGER[];
+ALTER TABLE weekly_plan_item ADD COLUMN daily_durations_sec INTEGER[];
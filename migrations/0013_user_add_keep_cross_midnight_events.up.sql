@@ -0,0 +1,3 @@
+SET search_path TO klokku, public;
+
+ALTER TABLE users ADD COLUMN keep_cross_midnight_events BOOLEAN NOT NULL DEFAULT FALSE;
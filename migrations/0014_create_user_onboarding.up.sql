@@ -0,0 +1,9 @@
+SET search_path TO klokku, public;
+
+CREATE TABLE user_onboarding
+(
+    user_id       INTEGER PRIMARY KEY,
+    skipped_steps TEXT[]      NOT NULL DEFAULT '{}',
+    dismissed     BOOLEAN     NOT NULL DEFAULT FALSE,
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,3 @@
+SET search_path TO klokku, public;
+
+ALTER TABLE calendar_event ADD COLUMN sandbox BOOLEAN NOT NULL DEFAULT FALSE;
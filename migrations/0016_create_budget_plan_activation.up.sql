@@ -0,0 +1,13 @@
+SET search_path TO klokku, public;
+
+CREATE TABLE budget_plan_activation
+(
+    id             INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    budget_plan_id INTEGER     NOT NULL REFERENCES budget_plan (id) ON DELETE CASCADE,
+    user_id        INTEGER     NOT NULL,
+    effective_from TIMESTAMPTZ NOT NULL,
+    activated_at   TIMESTAMPTZ,
+    created        TIMESTAMPTZ DEFAULT NOW()
+);
+CREATE UNIQUE INDEX budget_plan_activation_user_id_effective_from_idx ON budget_plan_activation (user_id, effective_from);
+CREATE INDEX budget_plan_activation_pending_idx ON budget_plan_activation (effective_from) WHERE activated_at IS NULL;
@@ -0,0 +1,14 @@
+SET search_path TO klokku, public;
+
+CREATE TABLE budget_category
+(
+    id             INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    budget_plan_id INTEGER NOT NULL REFERENCES budget_plan (id) ON DELETE CASCADE,
+    user_id        INTEGER NOT NULL,
+    name           TEXT    NOT NULL,
+    color          TEXT    NOT NULL DEFAULT '',
+    position       INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX budget_category_budget_plan_id_idx ON budget_category (budget_plan_id);
+
+ALTER TABLE budget_item ADD COLUMN category_id INTEGER REFERENCES budget_category (id) ON DELETE SET NULL;
@@ -0,0 +1,15 @@
+SET search_path TO klokku, public;
+
+CREATE TABLE budget_plan_revision
+(
+    id                           INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    budget_plan_id               INTEGER     NOT NULL REFERENCES budget_plan (id) ON DELETE CASCADE,
+    user_id                      INTEGER     NOT NULL,
+    budget_item_id               INTEGER     NOT NULL,
+    item_name                    TEXT        NOT NULL,
+    change_type                  TEXT        NOT NULL,
+    previous_weekly_duration_sec INTEGER     NOT NULL DEFAULT 0,
+    weekly_duration_sec          INTEGER     NOT NULL DEFAULT 0,
+    changed_at                   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX budget_plan_revision_budget_plan_id_idx ON budget_plan_revision (budget_plan_id, changed_at);
@@ -0,0 +1,9 @@
+SET search_path TO klokku, public;
+
+ALTER TABLE clickup_auth
+    ADD COLUMN invalid_since     TIMESTAMPTZ,
+    ADD COLUMN stale_notified_at TIMESTAMPTZ,
+    ADD COLUMN disabled_at       TIMESTAMPTZ;
+
+ALTER TABLE clickup_tag_mapping
+    ADD COLUMN active BOOLEAN NOT NULL DEFAULT TRUE;
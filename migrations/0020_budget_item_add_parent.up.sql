@@ -0,0 +1,5 @@
+SET search_path TO klokku, public;
+
+ALTER TABLE budget_item
+    ADD COLUMN parent_id INTEGER REFERENCES budget_item (id) ON DELETE SET NULL;
+CREATE INDEX budget_item_parent_id_idx ON budget_item (parent_id);
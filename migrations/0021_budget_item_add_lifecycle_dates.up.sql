@@ -0,0 +1,5 @@
+SET search_path TO klokku, public;
+
+ALTER TABLE budget_item
+    ADD COLUMN start_date DATE,
+    ADD COLUMN end_date   DATE;
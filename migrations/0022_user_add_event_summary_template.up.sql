@@ -0,0 +1,3 @@
+SET search_path TO klokku, public;
+
+ALTER TABLE users ADD COLUMN event_summary_template TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+SET search_path TO klokku, public;
+
+ALTER TABLE users
+    ADD COLUMN rename_rewrites_past_weekly_plans BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN rename_rewrites_past_events       BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,8 @@
+SET search_path TO klokku, public;
+
+-- The previous token stays valid for an overlap window after the token is rotated
+ALTER TABLE webhooks
+    ADD COLUMN previous_token            TEXT,
+    ADD COLUMN previous_token_expires_at TIMESTAMPTZ;
+
+CREATE UNIQUE INDEX webhooks_previous_token_idx ON webhooks (previous_token);
@@ -0,0 +1,21 @@
+SET search_path TO klokku, public;
+
+-- Cold storage for old events. Rows are moved here by the archiver and are not read by the day-to-day
+-- queries on calendar_event, only by exports and long-range reports.
+CREATE TABLE calendar_event_archive
+(
+    id             INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    uid            TEXT        NOT NULL,
+    summary        TEXT        NOT NULL,
+    start_time     TIMESTAMPTZ NOT NULL,
+    end_time       TIMESTAMPTZ NOT NULL,
+    budget_item_id INTEGER     NOT NULL,
+    user_id        INTEGER     NOT NULL,
+    notes          TEXT        NOT NULL DEFAULT '',
+    task_id        TEXT        NOT NULL DEFAULT '',
+    sandbox        BOOLEAN     NOT NULL DEFAULT FALSE,
+    archived_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX calendar_event_archive_user_id_start_end_idx ON calendar_event_archive (user_id, start_time, end_time);
+-- Archived rows are never updated, pack them densely.
+ALTER TABLE calendar_event_archive SET (fillfactor = 100);
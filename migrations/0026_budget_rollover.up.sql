@@ -0,0 +1,6 @@
+SET search_path TO klokku, public;
+
+ALTER TABLE budget_item ADD COLUMN rollover BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Time carried over from the previous week (negative when overspent), NULL when no rollover was applied
+ALTER TABLE weekly_plan_item ADD COLUMN carried_over_sec INTEGER;
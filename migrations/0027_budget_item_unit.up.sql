@@ -0,0 +1,4 @@
+SET search_path TO klokku, public;
+
+-- Unit the item is planned in, NULL for the default duration-based planning
+ALTER TABLE budget_item ADD COLUMN unit TEXT CHECK (unit IN ('sessions'));
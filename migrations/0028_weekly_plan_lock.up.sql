@@ -0,0 +1,4 @@
+SET search_path TO klokku, public;
+
+-- Set when the user locks a past week, its plan and events cannot be changed until it is unlocked
+ALTER TABLE weekly_plan ADD COLUMN locked_at TIMESTAMPTZ;
@@ -0,0 +1,5 @@
+SET search_path TO klokku, public;
+
+-- Ad-hoc weekly plan items have no budget item, they get negative budget item ids from this sequence
+-- so they never collide with budget items and calendar events can still reference them
+CREATE SEQUENCE weekly_plan_ad_hoc_item_seq INCREMENT BY -1 MAXVALUE -1 START WITH -1;
@@ -0,0 +1,21 @@
+SET search_path TO klokku, public;
+
+-- PIN a user sets to allow switching to them on a shared device, only a salted hash is stored
+CREATE TABLE user_switch_pin
+(
+    user_id         INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    pin_hash        BYTEA       NOT NULL,
+    pin_salt        BYTEA       NOT NULL,
+    failed_attempts INTEGER     NOT NULL DEFAULT 0,
+    expires_at      TIMESTAMPTZ NOT NULL
+);
+
+-- Sessions issued by switching to a user, used instead of the X-User-Id header
+CREATE TABLE user_switch_session
+(
+    token      TEXT PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX user_switch_session_user_id_idx ON user_switch_session (user_id);
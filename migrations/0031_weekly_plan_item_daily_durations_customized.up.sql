@@ -0,0 +1,4 @@
+SET search_path TO klokku, public;
+
+-- Daily durations edited for a single week are no longer overwritten by budget item updates
+ALTER TABLE weekly_plan_item ADD COLUMN daily_durations_customized BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,5 @@
+SET search_path TO klokku, public;
+
+-- Empty filters accept all budget items and change types
+ALTER TABLE export_stream ADD COLUMN filter_budget_item_ids INTEGER[] NOT NULL DEFAULT '{}';
+ALTER TABLE export_stream ADD COLUMN filter_change_types TEXT[] NOT NULL DEFAULT '{}';
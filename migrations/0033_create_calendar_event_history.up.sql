@@ -0,0 +1,14 @@
+SET search_path TO klokku, public;
+
+-- Event snapshots are stored as JSON, previous is NULL for created and current for deleted events
+CREATE TABLE calendar_event_history
+(
+    id          BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id     INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    event_uid   TEXT        NOT NULL,
+    change_type TEXT        NOT NULL,
+    previous    JSONB,
+    current     JSONB,
+    changed_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX calendar_event_history_user_id_idx ON calendar_event_history (user_id, changed_at);
@@ -0,0 +1,21 @@
+SET search_path TO klokku, public;
+
+-- Announcements are shared by all users, published_at may be in the future to schedule them
+CREATE TABLE announcement
+(
+    id           INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    version      TEXT        NOT NULL,
+    title        TEXT        NOT NULL,
+    body         TEXT        NOT NULL DEFAULT '',
+    published_at TIMESTAMPTZ NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX announcement_published_at_idx ON announcement (published_at);
+
+CREATE TABLE announcement_read
+(
+    user_id         INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    announcement_id INTEGER     NOT NULL REFERENCES announcement (id) ON DELETE CASCADE,
+    read_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, announcement_id)
+);
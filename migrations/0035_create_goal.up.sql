@@ -0,0 +1,12 @@
+SET search_path TO klokku, public;
+
+CREATE TABLE goal
+(
+    id             INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id        INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    budget_item_id INTEGER     NOT NULL REFERENCES budget_item (id) ON DELETE CASCADE,
+    comparison     TEXT        NOT NULL, -- at_least or at_most
+    target_sec     INTEGER     NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX goal_user_id_idx ON goal (user_id);
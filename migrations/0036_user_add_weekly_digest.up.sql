@@ -0,0 +1,14 @@
+SET search_path TO klokku, public;
+
+ALTER TABLE users
+    ADD COLUMN weekly_digest_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN weekly_digest_email   TEXT    NOT NULL DEFAULT '';
+
+-- Weeks the digest was already sent for, so that a restart does not send it twice
+CREATE TABLE weekly_digest_sent
+(
+    user_id     INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    week_number TEXT        NOT NULL,
+    sent_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, week_number)
+);
@@ -0,0 +1,31 @@
+SET search_path TO klokku, public;
+
+-- Outgoing webhooks, an empty event_types list subscribes to all supported event types
+CREATE TABLE webhook_subscription
+(
+    id          INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id     INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    url         TEXT        NOT NULL,
+    secret      TEXT        NOT NULL,
+    event_types TEXT[]      NOT NULL DEFAULT '{}',
+    enabled     BOOLEAN     NOT NULL DEFAULT TRUE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX webhook_subscription_user_id_idx ON webhook_subscription (user_id);
+
+CREATE TABLE webhook_delivery
+(
+    id              BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    subscription_id INTEGER     NOT NULL REFERENCES webhook_subscription (id) ON DELETE CASCADE,
+    event_type      TEXT        NOT NULL,
+    payload         JSONB       NOT NULL,
+    status          TEXT        NOT NULL, -- pending, succeeded or failed
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ,
+    response_status INTEGER,
+    last_error      TEXT        NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX webhook_delivery_subscription_id_idx ON webhook_delivery (subscription_id, id);
+CREATE INDEX webhook_delivery_pending_idx ON webhook_delivery (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,23 @@
+SET search_path TO klokku, public;
+
+-- Slack or Discord incoming webhooks the user's notifications are posted to
+CREATE TABLE chat_notification
+(
+    id          INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id     INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider    TEXT        NOT NULL, -- slack or discord
+    webhook_url TEXT        NOT NULL,
+    triggers    TEXT[]      NOT NULL DEFAULT '{}',
+    enabled     BOOLEAN     NOT NULL DEFAULT TRUE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX chat_notification_user_id_idx ON chat_notification (user_id);
+
+-- Notifications that must be posted only once, e.g. the summary of a week
+CREATE TABLE chat_notification_sent
+(
+    integration_id INTEGER     NOT NULL REFERENCES chat_notification (id) ON DELETE CASCADE,
+    key            TEXT        NOT NULL,
+    sent_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (integration_id, key)
+);
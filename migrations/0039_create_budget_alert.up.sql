@@ -0,0 +1,26 @@
+SET search_path TO klokku, public;
+
+-- Users without a row get the default thresholds
+CREATE TABLE budget_alert_settings
+(
+    user_id    INTEGER   NOT NULL PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    enabled    BOOLEAN   NOT NULL DEFAULT TRUE,
+    thresholds INTEGER[] NOT NULL
+);
+
+-- Each threshold of an item is alerted at most once a week
+CREATE TABLE budget_alert
+(
+    id             BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id        INTEGER          NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    week_number    TEXT             NOT NULL,
+    budget_item_id INTEGER          NOT NULL,
+    item_name      TEXT             NOT NULL,
+    threshold      INTEGER          NOT NULL,
+    percentage     DOUBLE PRECISION NOT NULL,
+    tracked_sec    INTEGER          NOT NULL,
+    planned_sec    INTEGER          NOT NULL,
+    created_at     TIMESTAMPTZ      NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, week_number, budget_item_id, threshold)
+);
+CREATE INDEX budget_alert_user_id_idx ON budget_alert (user_id, id);
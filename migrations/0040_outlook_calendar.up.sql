@@ -0,0 +1,13 @@
+SET search_path TO klokku, public;
+
+ALTER TABLE users
+    ADD COLUMN event_calendar_outlook_calendar_id TEXT;
+
+CREATE TABLE outlook_auth
+(
+    user_id       INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    access_token  TEXT,
+    refresh_token TEXT,
+    expiry        TIMESTAMPTZ,
+    nonce         TEXT
+);
@@ -0,0 +1,31 @@
+SET search_path TO klokku, public;
+
+CREATE TABLE toggl_config
+(
+    user_id      INTEGER     NOT NULL PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    api_token    TEXT        NOT NULL,
+    workspace_id BIGINT      NOT NULL,
+    enabled      BOOLEAN     NOT NULL DEFAULT TRUE,
+    synced_until TIMESTAMPTZ,
+    last_error   TEXT        NOT NULL DEFAULT ''
+);
+
+CREATE TABLE toggl_mapping
+(
+    user_id          INTEGER NOT NULL REFERENCES toggl_config (user_id) ON DELETE CASCADE,
+    toggl_project_id BIGINT  NOT NULL DEFAULT 0,
+    toggl_tag        TEXT    NOT NULL DEFAULT '',
+    budget_item_id   INTEGER NOT NULL,
+    position         INTEGER NOT NULL,
+    PRIMARY KEY (user_id, position)
+);
+
+-- Events created from each imported time entry, so changes in Toggl update them instead of duplicating
+CREATE TABLE toggl_imported_entry
+(
+    user_id          INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    toggl_entry_id   BIGINT      NOT NULL,
+    event_uids       TEXT[]      NOT NULL,
+    toggl_updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_id, toggl_entry_id)
+);
@@ -0,0 +1,43 @@
+SET search_path TO klokku, public;
+
+-- Users without a row do not push time entries to ClickUp
+CREATE TABLE clickup_time_tracking
+(
+    user_id INTEGER NOT NULL PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+-- Outbox of calendar event changes to push to ClickUp time tracking. There is at most one pending push
+-- per event, a newer change replaces it and bumps the version.
+CREATE TABLE clickup_time_entry_push
+(
+    id              BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id         INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    event_uid       TEXT        NOT NULL,
+    operation       TEXT        NOT NULL, -- upsert or delete
+    workspace_id    TEXT        NOT NULL DEFAULT '',
+    task_id         TEXT        NOT NULL DEFAULT '',
+    description     TEXT        NOT NULL DEFAULT '',
+    start_time      TIMESTAMPTZ,
+    end_time        TIMESTAMPTZ,
+    status          TEXT        NOT NULL, -- pending, succeeded or failed
+    version         INTEGER     NOT NULL DEFAULT 1,
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ,
+    last_error      TEXT        NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE UNIQUE INDEX clickup_time_entry_push_pending_event_idx ON clickup_time_entry_push (user_id, event_uid)
+    WHERE status = 'pending';
+CREATE INDEX clickup_time_entry_push_due_idx ON clickup_time_entry_push (next_attempt_at) WHERE status = 'pending';
+
+-- ClickUp time entries created for calendar events
+CREATE TABLE clickup_time_entry
+(
+    user_id       INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    event_uid     TEXT    NOT NULL,
+    workspace_id  TEXT    NOT NULL,
+    time_entry_id TEXT    NOT NULL,
+    PRIMARY KEY (user_id, event_uid)
+);
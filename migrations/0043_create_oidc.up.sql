@@ -0,0 +1,26 @@
+SET search_path TO klokku, public;
+
+-- Logins started with the OpenID Connect provider, consumed by the callback
+CREATE TABLE oidc_login_state
+(
+    state         TEXT PRIMARY KEY,
+    nonce         TEXT        NOT NULL,
+    code_verifier TEXT        NOT NULL,
+    final_url     TEXT        NOT NULL,
+    -- set when a signed in user links the identity to their account instead of signing in
+    link_user_id  INTEGER REFERENCES users (id) ON DELETE CASCADE,
+    expires_at    TIMESTAMPTZ NOT NULL
+);
+
+-- Identities of the OpenID Connect provider linked to Klokku users
+CREATE TABLE user_identity
+(
+    id         INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    issuer     TEXT        NOT NULL,
+    subject    TEXT        NOT NULL,
+    email      TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (issuer, subject)
+);
+CREATE INDEX user_identity_user_id_idx ON user_identity (user_id);
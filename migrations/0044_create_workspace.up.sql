@@ -0,0 +1,30 @@
+SET search_path TO klokku, public;
+
+-- Groups of users (e.g. a household) sharing budget plans and seeing each other's calendars
+CREATE TABLE workspace
+(
+    id         INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    name       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE workspace_member
+(
+    workspace_id INTEGER     NOT NULL REFERENCES workspace (id) ON DELETE CASCADE,
+    user_id      INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    -- owner, editor or viewer
+    role         TEXT        NOT NULL,
+    joined_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (workspace_id, user_id)
+);
+CREATE INDEX workspace_member_user_id_idx ON workspace_member (user_id);
+
+-- Budget plans members shared with the workspace, the plan stays owned by the member who shared it
+CREATE TABLE workspace_budget_plan
+(
+    workspace_id   INTEGER     NOT NULL REFERENCES workspace (id) ON DELETE CASCADE,
+    budget_plan_id INTEGER     NOT NULL REFERENCES budget_plan (id) ON DELETE CASCADE,
+    user_id        INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    shared_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (workspace_id, budget_plan_id)
+);
@@ -0,0 +1,15 @@
+SET search_path TO klokku, public;
+
+-- Read-only public links to the plan and stats of a week, e.g. for a coach
+CREATE TABLE share_link
+(
+    id         INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token      TEXT        NOT NULL UNIQUE,
+    -- any time within the shared week
+    week_date  TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- NULL for links valid until revoked
+    expires_at TIMESTAMPTZ
+);
+CREATE INDEX share_link_user_id_idx ON share_link (user_id);
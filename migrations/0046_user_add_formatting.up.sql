@@ -0,0 +1,6 @@
+SET search_path TO klokku, public;
+
+ALTER TABLE users
+    ADD COLUMN locale          TEXT    NOT NULL DEFAULT 'en-US',
+    ADD COLUMN duration_format TEXT    NOT NULL DEFAULT 'hh_mm',
+    ADD COLUMN day_start_hour  INTEGER NOT NULL DEFAULT 0 CHECK (day_start_hour BETWEEN 0 AND 23);
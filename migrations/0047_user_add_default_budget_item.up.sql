@@ -0,0 +1,4 @@
+SET search_path TO klokku, public;
+
+ALTER TABLE users
+    ADD COLUMN default_budget_item_id INTEGER REFERENCES budget_item (id) ON DELETE SET NULL;
@@ -0,0 +1,5 @@
+SET search_path TO klokku, public;
+
+-- Disabled users keep their data but can neither sign in nor be synchronized in the background
+ALTER TABLE users
+    ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,18 @@
+SET search_path TO klokku, public;
+
+-- Security-relevant actions. Entries outlive their actor, the uid is kept after the user is deleted.
+CREATE TABLE audit_log
+(
+    id            BIGSERIAL PRIMARY KEY,
+    created_at    TIMESTAMPTZ NOT NULL,
+    action        TEXT        NOT NULL,
+    actor_user_id INTEGER     REFERENCES users (id) ON DELETE SET NULL,
+    actor_uid     TEXT,
+    ip            TEXT,
+    resource      TEXT        NOT NULL DEFAULT '',
+    details       JSONB       NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at DESC, id DESC);
+CREATE INDEX audit_log_actor_uid_idx ON audit_log (actor_uid, created_at DESC);
+CREATE INDEX audit_log_action_idx ON audit_log (action, created_at DESC);
@@ -0,0 +1,28 @@
+SET search_path TO klokku, public;
+
+-- Events of the event bus for their durable subscribers, stored in the transaction of the change they describe
+-- where possible. Events are removed once delivered to all subscribers.
+CREATE TABLE event_outbox
+(
+    id         BIGSERIAL PRIMARY KEY,
+    event_type TEXT        NOT NULL,
+    payload    JSONB       NOT NULL,
+    -- user who published the event, subscribers act on their behalf
+    user_id    INTEGER REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+-- Delivery of an event to one durable subscriber. Failed attempts are retried with increasing delays.
+CREATE TABLE event_delivery
+(
+    event_id        BIGINT      NOT NULL REFERENCES event_outbox (id) ON DELETE CASCADE,
+    subscriber      TEXT        NOT NULL,
+    status          TEXT        NOT NULL DEFAULT 'pending',
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ,
+    last_error      TEXT,
+    delivered_at    TIMESTAMPTZ,
+    PRIMARY KEY (event_id, subscriber)
+);
+
+CREATE INDEX event_delivery_due_idx ON event_delivery (next_attempt_at) WHERE status = 'pending';
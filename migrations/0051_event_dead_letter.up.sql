@@ -0,0 +1,18 @@
+SET search_path TO klokku, public;
+
+-- Events a durable subscriber failed to handle after all retries, kept until an admin replays them
+CREATE TABLE event_dead_letter
+(
+    id           BIGSERIAL PRIMARY KEY,
+    event_type   TEXT        NOT NULL,
+    payload      JSONB       NOT NULL,
+    user_id      INTEGER REFERENCES users (id) ON DELETE CASCADE,
+    -- when the event was published
+    created_at   TIMESTAMPTZ NOT NULL,
+    subscriber   TEXT        NOT NULL,
+    attempts     INTEGER     NOT NULL,
+    last_error   TEXT        NOT NULL,
+    failed_at    TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX event_dead_letter_subscriber_idx ON event_dead_letter (subscriber, event_type);
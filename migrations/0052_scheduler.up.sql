@@ -0,0 +1,26 @@
+SET search_path TO klokku, public;
+
+-- The instance running the scheduled jobs. It renews its lease while running, another instance takes over once
+-- the lease ends.
+CREATE TABLE scheduler_leader
+(
+    id          INTEGER PRIMARY KEY CHECK (id = 1),
+    instance_id TEXT        NOT NULL,
+    since       TIMESTAMPTZ NOT NULL,
+    lease_until TIMESTAMPTZ NOT NULL
+);
+
+-- State of the scheduled jobs, so a new leader continues where the previous one stopped
+CREATE TABLE scheduled_job
+(
+    name             TEXT PRIMARY KEY,
+    next_run_at      TIMESTAMPTZ,
+    last_started_at  TIMESTAMPTZ,
+    last_finished_at TIMESTAMPTZ,
+    last_status      TEXT,
+    last_error       TEXT,
+    last_duration_ms BIGINT  NOT NULL DEFAULT 0,
+    -- users the last run of a per-user job ran for, and failed for
+    users            INTEGER NOT NULL DEFAULT 0,
+    failed_users     INTEGER NOT NULL DEFAULT 0
+);
@@ -0,0 +1,10 @@
+SET search_path TO klokku, public;
+
+-- Users publishing their tracking state to the MQTT broker, e.g. for Home Assistant. The topic is the user's level
+-- of the state and command topics, so it is unique across users.
+CREATE TABLE mqtt_bridge_settings
+(
+    user_id INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    topic   TEXT    NOT NULL UNIQUE
+);
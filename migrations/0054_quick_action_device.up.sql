@@ -0,0 +1,15 @@
+SET search_path TO klokku, public;
+
+-- Devices, e.g. a single hardware button, controlling the tracking of their user with a token instead of the user
+-- header
+CREATE TABLE quick_action_device
+(
+    id           INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id      INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name         TEXT        NOT NULL,
+    token        TEXT        NOT NULL UNIQUE,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- NULL until the device first called Klokku
+    last_used_at TIMESTAMPTZ
+);
+CREATE INDEX quick_action_device_user_id_idx ON quick_action_device (user_id);
@@ -0,0 +1,24 @@
+SET search_path TO klokku, public;
+
+-- Reviews of past weeks, the user walks through the deviations from the plan and marks the week as reviewed
+CREATE TABLE week_review
+(
+    id           INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id      INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    week_number  TEXT        NOT NULL, -- ISO 8601 week number, e.g. "2025-W03"
+    status       TEXT        NOT NULL DEFAULT 'in_progress',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- NULL until the week is marked as reviewed
+    completed_at TIMESTAMPTZ,
+    UNIQUE (user_id, week_number)
+);
+CREATE INDEX week_review_user_id_idx ON week_review (user_id);
+
+-- Retrospective notes about the items of the reviewed week
+CREATE TABLE week_review_item_note
+(
+    review_id      INTEGER NOT NULL REFERENCES week_review (id) ON DELETE CASCADE,
+    budget_item_id INTEGER NOT NULL, -- negative for ad hoc items of the week
+    note           TEXT    NOT NULL,
+    PRIMARY KEY (review_id, budget_item_id)
+);
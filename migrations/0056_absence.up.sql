@@ -0,0 +1,14 @@
+SET search_path TO klokku, public;
+
+-- Planned absences, e.g. vacations, reducing the weekly plans of the weeks they fall into
+CREATE TABLE absence
+(
+    id         INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    start_date DATE        NOT NULL,
+    end_date   DATE        NOT NULL, -- inclusive
+    note       TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (end_date >= start_date)
+);
+CREATE INDEX absence_user_id_idx ON absence (user_id);
@@ -0,0 +1,5 @@
+SET search_path TO klokku, public;
+
+-- Free-form key-value data attached to events by integrations, e.g. a Jira issue key.
+ALTER TABLE calendar_event ADD COLUMN attributes JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE calendar_event_archive ADD COLUMN attributes JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,6 @@
+SET search_path TO klokku, public;
+
+-- Where the time was spent, free text (e.g. "office") or coordinates (e.g. "52.2297,21.0122").
+ALTER TABLE current_event ADD COLUMN location TEXT NOT NULL DEFAULT '';
+ALTER TABLE calendar_event ADD COLUMN location TEXT NOT NULL DEFAULT '';
+ALTER TABLE calendar_event_archive ADD COLUMN location TEXT NOT NULL DEFAULT '';
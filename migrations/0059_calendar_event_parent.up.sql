@@ -0,0 +1,7 @@
+SET search_path TO klokku, public;
+
+-- The uid of the first part of an event split at day boundaries, empty for first parts and events that were not split.
+ALTER TABLE calendar_event ADD COLUMN parent_uid TEXT NOT NULL DEFAULT '';
+ALTER TABLE calendar_event_archive ADD COLUMN parent_uid TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX calendar_event_parent_idx ON calendar_event (user_id, parent_uid) WHERE parent_uid <> '';
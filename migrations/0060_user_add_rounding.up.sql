@@ -0,0 +1,6 @@
+SET search_path TO klokku, public;
+
+ALTER TABLE users
+    ADD COLUMN rounding_increment     INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN rounding_mode          TEXT    NOT NULL DEFAULT 'nearest',
+    ADD COLUMN rounding_minimum_block INTEGER NOT NULL DEFAULT 0;
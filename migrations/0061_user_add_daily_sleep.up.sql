@@ -0,0 +1,4 @@
+SET search_path TO klokku, public;
+
+ALTER TABLE users
+    ADD COLUMN daily_sleep INTEGER NOT NULL DEFAULT 28800 CHECK (daily_sleep BETWEEN 0 AND 57600);
@@ -0,0 +1,7 @@
+SET search_path TO klokku, public;
+
+ALTER TABLE calendar_event
+    ALTER COLUMN budget_item_id DROP NOT NULL;
+
+ALTER TABLE calendar_event_archive
+    ALTER COLUMN budget_item_id DROP NOT NULL;
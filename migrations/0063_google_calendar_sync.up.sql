@@ -0,0 +1,31 @@
+SET search_path TO klokku, public;
+
+-- Push notification channel watching the user's Google calendar, with the cursor of the incremental sync
+CREATE TABLE google_calendar_channel
+(
+    user_id     INTEGER     NOT NULL PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    calendar_id TEXT        NOT NULL,
+    channel_id  TEXT        NOT NULL UNIQUE,
+    resource_id TEXT        NOT NULL,
+    token       TEXT        NOT NULL,
+    expires_at  TIMESTAMPTZ NOT NULL,
+    sync_token  TEXT        NOT NULL DEFAULT ''
+);
+
+-- Last known version of each Google event, so changes made by Klokku itself are not imported again
+CREATE TABLE google_calendar_event
+(
+    user_id  INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    event_id TEXT    NOT NULL,
+    etag     TEXT    NOT NULL,
+    PRIMARY KEY (user_id, event_id)
+);
+
+CREATE TABLE google_calendar_import_rule
+(
+    user_id        INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    pattern        TEXT    NOT NULL,
+    budget_item_id INTEGER NOT NULL REFERENCES budget_item (id) ON DELETE CASCADE,
+    position       INTEGER NOT NULL,
+    PRIMARY KEY (user_id, position)
+);
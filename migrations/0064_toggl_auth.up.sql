@@ -0,0 +1,19 @@
+SET search_path TO klokku, public;
+
+-- The Toggl API token is kept with the other third-party tokens, so it is encrypted and revoked with them
+CREATE TABLE toggl_auth
+(
+    user_id       INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    access_token  TEXT,
+    refresh_token TEXT,
+    expiry        TIMESTAMPTZ,
+    nonce         TEXT
+);
+
+-- Copied tokens are encrypted on startup like the other plaintext tokens
+INSERT INTO toggl_auth (user_id, access_token)
+SELECT user_id, api_token
+FROM toggl_config;
+
+ALTER TABLE toggl_config
+    DROP COLUMN api_token;
@@ -0,0 +1,15 @@
+SET search_path TO klokku, public;
+
+-- Users join a workspace by accepting an invitation of its owner
+CREATE TABLE workspace_invitation
+(
+    id           INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    workspace_id INTEGER     NOT NULL REFERENCES workspace (id) ON DELETE CASCADE,
+    user_id      INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    -- owner, editor or viewer
+    role         TEXT        NOT NULL,
+    invited_by   INTEGER     REFERENCES users (id) ON DELETE SET NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (workspace_id, user_id)
+);
+CREATE INDEX workspace_invitation_user_id_idx ON workspace_invitation (user_id);
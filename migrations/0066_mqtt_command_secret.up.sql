@@ -0,0 +1,9 @@
+SET search_path TO klokku, public;
+
+-- Commands must carry the user's secret, the topic alone can be guessed or read by any client of the broker. Existing
+-- settings get a random one, the default is evaluated per row.
+ALTER TABLE mqtt_bridge_settings
+    ADD COLUMN command_secret TEXT NOT NULL DEFAULT replace(gen_random_uuid()::text || gen_random_uuid()::text, '-', '');
+
+ALTER TABLE mqtt_bridge_settings
+    ALTER COLUMN command_secret DROP DEFAULT;
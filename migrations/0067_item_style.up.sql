@@ -0,0 +1,36 @@
+SET search_path TO klokku, public;
+
+-- Items get only hex colors, e.g. #3B82F6, and curated icons. Colors of earlier items are normalized where their
+-- meaning is clear, e.g. 3b82f6 or #38f, and cleared otherwise; icons outside the curated set are cleared. The
+-- weekly plan items copied from budget items are cleaned the same way, ad-hoc items keep their style.
+UPDATE budget_item
+SET color = CASE
+                WHEN ltrim(btrim(color), '#') ~ '^[0-9A-Fa-f]{6}$' THEN '#' || ltrim(btrim(color), '#')
+                WHEN ltrim(btrim(color), '#') ~ '^[0-9A-Fa-f]{3}$'
+                    THEN regexp_replace(ltrim(btrim(color), '#'), '(.)(.)(.)', '#\1\1\2\2\3\3')
+    END
+WHERE color <> ''
+  AND color !~ '^#[0-9A-Fa-f]{6}$';
+
+UPDATE weekly_plan_item
+SET color = CASE
+                WHEN ltrim(btrim(color), '#') ~ '^[0-9A-Fa-f]{6}$' THEN '#' || ltrim(btrim(color), '#')
+                WHEN ltrim(btrim(color), '#') ~ '^[0-9A-Fa-f]{3}$'
+                    THEN regexp_replace(ltrim(btrim(color), '#'), '(.)(.)(.)', '#\1\1\2\2\3\3')
+    END
+WHERE budget_item_id > 0
+  AND color <> ''
+  AND color !~ '^#[0-9A-Fa-f]{6}$';
+
+UPDATE budget_item
+SET icon = NULL
+WHERE icon <> ''
+  AND icon NOT IN ('work', 'sleep', 'run', 'book', 'family', 'food', 'music', 'code',
+                   'heart', 'home', 'school', 'shopping', 'travel', 'game', 'brush', 'meditation');
+
+UPDATE weekly_plan_item
+SET icon = NULL
+WHERE budget_item_id > 0
+  AND icon <> ''
+  AND icon NOT IN ('work', 'sleep', 'run', 'book', 'family', 'food', 'music', 'code',
+                   'heart', 'home', 'school', 'shopping', 'travel', 'game', 'brush', 'meditation');
@@ -0,0 +1,4 @@
+SET search_path TO klokku, public;
+
+-- Rate the time tracked on the item is billed at, in cents of the user's currency per hour, 0 for unbilled items
+ALTER TABLE budget_item ADD COLUMN hourly_rate INT NOT NULL DEFAULT 0 CHECK (hourly_rate >= 0);
@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migrations of the database schema, so the binary applies them without the
+// migrations directory next to it.
+package migrations
+
+import "embed"
+
+// FS holds the migrations named like 0042_description.up.sql.
+//
+//go:embed *.sql
+var FS embed.FS
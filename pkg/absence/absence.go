@@ -0,0 +1,32 @@
+package absence
+
+import (
+	"time"
+)
+
+const (
+	// MaxAbsenceDays is the longest absence, longer breaks are better handled by changing the budget plan
+	MaxAbsenceDays = 366
+	MaxNoteLen     = 200
+)
+
+// Absence is a planned break of the user, e.g. a vacation. The weekly plans of the weeks it falls into are reduced
+// by the absent days.
+type Absence struct {
+	Id     int
+	UserId int
+	// StartDate and EndDate are the first and the last absent day, at midnight UTC
+	StartDate time.Time
+	EndDate   time.Time
+	Note      string
+}
+
+// Days returns the number of absent days.
+func (a Absence) Days() int {
+	return int(a.EndDate.Sub(a.StartDate).Hours()/24) + 1
+}
+
+// dateOnly returns the day of t at midnight UTC, keeping its year, month and day.
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
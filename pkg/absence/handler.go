@@ -0,0 +1,195 @@
+package absence
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/klokku/klokku/internal/rest"
+	log "github.com/sirupsen/logrus"
+)
+
+type AbsenceDTO struct {
+	Id int `json:"id"`
+	// StartDate and EndDate are the first and the last absent day in YYYY-MM-DD format
+	StartDate string `json:"startDate" example:"2025-07-14"`
+	EndDate   string `json:"endDate" example:"2025-07-25"`
+	Note      string `json:"note"`
+	// Days is the number of absent days
+	Days int `json:"days"`
+}
+
+type AbsenceRequestDTO struct {
+	StartDate string `json:"startDate" example:"2025-07-14"`
+	EndDate   string `json:"endDate" example:"2025-07-25"`
+	Note      string `json:"note"`
+}
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// ListAbsences godoc
+// @Summary List absences
+// @Description All planned absences of the user, e.g. vacations, by start date
+// @Tags Absence
+// @Produce json
+// @Success 200 {array} AbsenceDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/absences [get]
+// @Security XUserId
+func (h *Handler) ListAbsences(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	absences, err := h.service.ListAbsences(r.Context())
+	if err != nil {
+		log.Errorf("Failed to list absences: %v", err)
+		http.Error(w, "Failed to list absences", http.StatusInternalServerError)
+		return
+	}
+	dtos := make([]AbsenceDTO, 0, len(absences))
+	for _, absence := range absences {
+		dtos = append(dtos, absenceToDTO(absence))
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// CreateAbsence godoc
+// @Summary Plan an absence
+// @Description Plan an absence, e.g. a vacation. The weekly plans of the weeks it falls into are reduced by the
+// @Description absent days and the stats of those days have no targets.
+// @Tags Absence
+// @Accept json
+// @Produce json
+// @Param absence body AbsenceRequestDTO true "Absence"
+// @Success 201 {object} AbsenceDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid absence"
+// @Failure 403 {string} string "User not found"
+// @Failure 409 {string} string "The absence overlaps another absence"
+// @Router /api/absences [post]
+// @Security XUserId
+func (h *Handler) CreateAbsence(w http.ResponseWriter, r *http.Request) {
+	absence, err := decodeAbsence(r)
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	created, err := h.service.CreateAbsence(r.Context(), absence)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(absenceToDTO(created)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// UpdateAbsence godoc
+// @Summary Change an absence
+// @Tags Absence
+// @Accept json
+// @Produce json
+// @Param id path int true "Absence ID"
+// @Param absence body AbsenceRequestDTO true "Absence"
+// @Success 200 {object} AbsenceDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid absence"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Absence not found"
+// @Failure 409 {string} string "The absence overlaps another absence"
+// @Router /api/absences/{id} [put]
+// @Security XUserId
+func (h *Handler) UpdateAbsence(w http.ResponseWriter, r *http.Request) {
+	id, err := rest.PathInt(r, "id")
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	absence, err := decodeAbsence(r)
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	absence.Id = id
+	updated, err := h.service.UpdateAbsence(r.Context(), absence)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(absenceToDTO(updated)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// DeleteAbsence godoc
+// @Summary Cancel an absence
+// @Description Delete the absence, the weekly plans of its weeks are no longer reduced
+// @Tags Absence
+// @Param id path int true "Absence ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} rest.ErrorResponse "Invalid absence ID"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Absence not found"
+// @Router /api/absences/{id} [delete]
+// @Security XUserId
+func (h *Handler) DeleteAbsence(w http.ResponseWriter, r *http.Request) {
+	id, err := rest.PathInt(r, "id")
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	if err := h.service.DeleteAbsence(r.Context(), id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeAbsence(r *http.Request) (Absence, error) {
+	var body AbsenceRequestDTO
+	if err := rest.DecodeJSON(r, &body); err != nil {
+		return Absence{}, err
+	}
+	var v rest.Validator
+	startDate, startErr := time.Parse(time.DateOnly, body.StartDate)
+	v.Check(startErr == nil, "startDate", "Invalid start date", "'startDate' must be in YYYY-MM-DD format")
+	endDate, endErr := time.Parse(time.DateOnly, body.EndDate)
+	v.Check(endErr == nil, "endDate", "Invalid end date", "'endDate' must be in YYYY-MM-DD format")
+	if err := v.Err(); err != nil {
+		return Absence{}, err
+	}
+	return Absence{StartDate: startDate, EndDate: endDate, Note: body.Note}, nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidAbsence):
+		rest.WriteError(w, http.StatusBadRequest, rest.ErrorResponse{Error: "Invalid absence", Details: err.Error()})
+	case errors.Is(err, ErrAbsenceNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrOverlappingAbsence):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Errorf("Absence request failed: %v", err)
+		http.Error(w, "Absence request failed", http.StatusInternalServerError)
+	}
+}
+
+func absenceToDTO(absence Absence) AbsenceDTO {
+	return AbsenceDTO{
+		Id:        absence.Id,
+		StartDate: absence.StartDate.Format(time.DateOnly),
+		EndDate:   absence.EndDate.Format(time.DateOnly),
+		Note:      absence.Note,
+		Days:      absence.Days(),
+	}
+}
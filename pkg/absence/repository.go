@@ -0,0 +1,94 @@
+package absence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrAbsenceNotFound = errors.New("absence not found")
+
+type Repository interface {
+	CreateAbsence(ctx context.Context, absence Absence) (Absence, error)
+	UpdateAbsence(ctx context.Context, absence Absence) (Absence, error)
+	DeleteAbsence(ctx context.Context, userId, id int) error
+	// GetAbsences returns the absences of the user overlapping the days from to to (inclusive), by start date.
+	GetAbsences(ctx context.Context, userId int, from time.Time, to time.Time) ([]Absence, error)
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+const absenceColumns = "id, user_id, start_date, end_date, note"
+
+func scanAbsence(row pgx.Row) (Absence, error) {
+	var absence Absence
+	err := row.Scan(&absence.Id, &absence.UserId, &absence.StartDate, &absence.EndDate, &absence.Note)
+	return absence, err
+}
+
+func (r *RepositoryImpl) CreateAbsence(ctx context.Context, absence Absence) (Absence, error) {
+	query := `INSERT INTO absence (user_id, start_date, end_date, note) VALUES ($1, $2, $3, $4)
+			  RETURNING ` + absenceColumns
+	created, err := scanAbsence(r.db.QueryRow(ctx, query, absence.UserId, absence.StartDate, absence.EndDate,
+		absence.Note))
+	if err != nil {
+		return Absence{}, fmt.Errorf("failed to create absence: %w", err)
+	}
+	return created, nil
+}
+
+func (r *RepositoryImpl) UpdateAbsence(ctx context.Context, absence Absence) (Absence, error) {
+	query := `UPDATE absence SET start_date = $1, end_date = $2, note = $3 WHERE user_id = $4 AND id = $5
+			  RETURNING ` + absenceColumns
+	updated, err := scanAbsence(r.db.QueryRow(ctx, query, absence.StartDate, absence.EndDate, absence.Note,
+		absence.UserId, absence.Id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Absence{}, ErrAbsenceNotFound
+		}
+		return Absence{}, fmt.Errorf("failed to update absence: %w", err)
+	}
+	return updated, nil
+}
+
+func (r *RepositoryImpl) DeleteAbsence(ctx context.Context, userId, id int) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM absence WHERE user_id = $1 AND id = $2", userId, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete absence: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAbsenceNotFound
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) GetAbsences(ctx context.Context, userId int, from time.Time, to time.Time) ([]Absence, error) {
+	query := `SELECT ` + absenceColumns + ` FROM absence
+			  WHERE user_id = $1 AND start_date <= $3 AND end_date >= $2
+			  ORDER BY start_date`
+	rows, err := r.db.Query(ctx, query, userId, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absences: %w", err)
+	}
+	defer rows.Close()
+
+	absences := make([]Absence, 0)
+	for rows.Next() {
+		absence, err := scanAbsence(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan absence: %w", err)
+		}
+		absences = append(absences, absence)
+	}
+	return absences, rows.Err()
+}
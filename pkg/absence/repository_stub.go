@@ -0,0 +1,64 @@
+package absence
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+type RepositoryStub struct {
+	mu       sync.Mutex
+	nextId   int
+	absences []Absence
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{}
+}
+
+func (r *RepositoryStub) CreateAbsence(ctx context.Context, absence Absence) (Absence, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextId++
+	absence.Id = r.nextId
+	r.absences = append(r.absences, absence)
+	return absence, nil
+}
+
+func (r *RepositoryStub) UpdateAbsence(ctx context.Context, absence Absence) (Absence, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, existing := range r.absences {
+		if existing.UserId == absence.UserId && existing.Id == absence.Id {
+			r.absences[i] = absence
+			return absence, nil
+		}
+	}
+	return Absence{}, ErrAbsenceNotFound
+}
+
+func (r *RepositoryStub) DeleteAbsence(ctx context.Context, userId, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, absence := range r.absences {
+		if absence.UserId == userId && absence.Id == id {
+			r.absences = append(r.absences[:i], r.absences[i+1:]...)
+			return nil
+		}
+	}
+	return ErrAbsenceNotFound
+}
+
+func (r *RepositoryStub) GetAbsences(ctx context.Context, userId int, from time.Time, to time.Time) ([]Absence, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	absences := make([]Absence, 0)
+	for _, absence := range r.absences {
+		if absence.UserId == userId && !absence.StartDate.After(to) && !absence.EndDate.Before(from) {
+			absences = append(absences, absence)
+		}
+	}
+	slices.SortFunc(absences, func(a, b Absence) int { return a.StartDate.Compare(b.StartDate) })
+	return absences, nil
+}
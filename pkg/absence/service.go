@@ -0,0 +1,129 @@
+package absence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/klokku/klokku/pkg/user"
+)
+
+var (
+	ErrInvalidAbsence     = errors.New("invalid absence")
+	ErrOverlappingAbsence = errors.New("the absence overlaps another absence")
+)
+
+var (
+	firstDate = time.Date(1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	lastDate  = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+)
+
+type Service interface {
+	// ListAbsences returns all absences of the user, by start date.
+	ListAbsences(ctx context.Context) ([]Absence, error)
+	CreateAbsence(ctx context.Context, absence Absence) (Absence, error)
+	UpdateAbsence(ctx context.Context, absence Absence) (Absence, error)
+	DeleteAbsence(ctx context.Context, id int) error
+	// AbsentDays returns the days from from to to (inclusive) the user is absent on, at midnight UTC. Only the
+	// year, month and day of from and to are taken into account.
+	AbsentDays(ctx context.Context, from time.Time, to time.Time) ([]time.Time, error)
+}
+
+type ServiceImpl struct {
+	repo Repository
+}
+
+func NewService(repo Repository) *ServiceImpl {
+	return &ServiceImpl{repo: repo}
+}
+
+func (s *ServiceImpl) ListAbsences(ctx context.Context) ([]Absence, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.GetAbsences(ctx, userId, firstDate, lastDate)
+}
+
+func (s *ServiceImpl) CreateAbsence(ctx context.Context, absence Absence) (Absence, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Absence{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	absence.UserId = userId
+	absence, err = s.validate(ctx, absence)
+	if err != nil {
+		return Absence{}, err
+	}
+	return s.repo.CreateAbsence(ctx, absence)
+}
+
+func (s *ServiceImpl) UpdateAbsence(ctx context.Context, absence Absence) (Absence, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Absence{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	absence.UserId = userId
+	absence, err = s.validate(ctx, absence)
+	if err != nil {
+		return Absence{}, err
+	}
+	return s.repo.UpdateAbsence(ctx, absence)
+}
+
+func (s *ServiceImpl) DeleteAbsence(ctx context.Context, id int) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.DeleteAbsence(ctx, userId, id)
+}
+
+func (s *ServiceImpl) AbsentDays(ctx context.Context, from time.Time, to time.Time) ([]time.Time, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	from, to = dateOnly(from), dateOnly(to)
+	absences, err := s.repo.GetAbsences(ctx, userId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	var days []time.Time
+	for _, absence := range absences {
+		for day := absence.StartDate; !day.After(absence.EndDate); day = day.AddDate(0, 0, 1) {
+			if !day.Before(from) && !day.After(to) {
+				days = append(days, day)
+			}
+		}
+	}
+	return days, nil
+}
+
+// validate normalizes the absence and checks it does not overlap other absences of the user.
+func (s *ServiceImpl) validate(ctx context.Context, absence Absence) (Absence, error) {
+	absence.StartDate = dateOnly(absence.StartDate)
+	absence.EndDate = dateOnly(absence.EndDate)
+	absence.Note = strings.TrimSpace(absence.Note)
+	if absence.EndDate.Before(absence.StartDate) {
+		return Absence{}, fmt.Errorf("%w: the end date is before the start date", ErrInvalidAbsence)
+	}
+	if absence.Days() > MaxAbsenceDays {
+		return Absence{}, fmt.Errorf("%w: an absence can last at most %d days", ErrInvalidAbsence, MaxAbsenceDays)
+	}
+	if len(absence.Note) > MaxNoteLen {
+		return Absence{}, fmt.Errorf("%w: the note must have at most %d characters", ErrInvalidAbsence, MaxNoteLen)
+	}
+	overlapping, err := s.repo.GetAbsences(ctx, absence.UserId, absence.StartDate, absence.EndDate)
+	if err != nil {
+		return Absence{}, err
+	}
+	for _, other := range overlapping {
+		if other.Id != absence.Id {
+			return Absence{}, ErrOverlappingAbsence
+		}
+	}
+	return absence, nil
+}
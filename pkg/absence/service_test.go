@@ -0,0 +1,121 @@
+package absence
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testUser = user.User{
+	Id:       1,
+	Uid:      "user-1",
+	Username: "test-user-1",
+	Settings: user.Settings{Timezone: "UTC", WeekFirstDay: time.Monday},
+}
+
+func date(month time.Month, day int) time.Time {
+	return time.Date(2025, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func setupServiceTest(t *testing.T) (*ServiceImpl, context.Context) {
+	t.Helper()
+	return NewService(NewRepositoryStub()), user.WithUser(context.Background(), testUser)
+}
+
+func TestCreateAbsence(t *testing.T) {
+	// given
+	service, ctx := setupServiceTest(t)
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	require.NoError(t, err)
+
+	// when
+	created, err := service.CreateAbsence(ctx, Absence{
+		StartDate: time.Date(2025, time.July, 14, 0, 0, 0, 0, warsaw),
+		EndDate:   date(time.July, 25),
+		Note:      " Summer vacation ",
+	})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, Absence{Id: 1, UserId: testUser.Id, StartDate: date(time.July, 14), EndDate: date(time.July, 25),
+		Note: "Summer vacation"}, created)
+	assert.Equal(t, 12, created.Days())
+}
+
+func TestCreateAbsence_Validation(t *testing.T) {
+	// given
+	service, ctx := setupServiceTest(t)
+	_, err := service.CreateAbsence(ctx, Absence{StartDate: date(time.July, 14), EndDate: date(time.July, 25)})
+	require.NoError(t, err)
+
+	for name, tt := range map[string]struct {
+		absence Absence
+		err     error
+	}{
+		"end before start": {absence: Absence{StartDate: date(time.March, 2), EndDate: date(time.March, 1)}, err: ErrInvalidAbsence},
+		"too long":         {absence: Absence{StartDate: date(time.January, 1), EndDate: date(time.January, 1).AddDate(1, 0, 1)}, err: ErrInvalidAbsence},
+		"long note":        {absence: Absence{StartDate: date(time.March, 1), EndDate: date(time.March, 1), Note: strings.Repeat("a", MaxNoteLen+1)}, err: ErrInvalidAbsence},
+		"overlapping":      {absence: Absence{StartDate: date(time.July, 25), EndDate: date(time.July, 28)}, err: ErrOverlappingAbsence},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// when
+			_, err := service.CreateAbsence(ctx, tt.absence)
+
+			// then
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestUpdateAbsence_MayOverlapItself(t *testing.T) {
+	// given
+	service, ctx := setupServiceTest(t)
+	created, err := service.CreateAbsence(ctx, Absence{StartDate: date(time.July, 14), EndDate: date(time.July, 25)})
+	require.NoError(t, err)
+
+	// when
+	created.EndDate = date(time.July, 27)
+	updated, err := service.UpdateAbsence(ctx, created)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, date(time.July, 27), updated.EndDate)
+}
+
+func TestAbsentDays(t *testing.T) {
+	// given
+	service, ctx := setupServiceTest(t)
+	_, err := service.CreateAbsence(ctx, Absence{StartDate: date(time.July, 4), EndDate: date(time.July, 8)})
+	require.NoError(t, err)
+	_, err = service.CreateAbsence(ctx, Absence{StartDate: date(time.July, 12), EndDate: date(time.July, 12)})
+	require.NoError(t, err)
+	_, err = service.CreateAbsence(ctx, Absence{StartDate: date(time.August, 1), EndDate: date(time.August, 3)})
+	require.NoError(t, err)
+
+	// when
+	days, err := service.AbsentDays(ctx, date(time.July, 7), time.Date(2025, time.July, 13, 23, 59, 0, 0, time.UTC))
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{date(time.July, 7), date(time.July, 8), date(time.July, 12)}, days)
+}
+
+func TestDeleteAbsence_OfOtherUser(t *testing.T) {
+	// given
+	service, ctx := setupServiceTest(t)
+	created, err := service.CreateAbsence(ctx, Absence{StartDate: date(time.July, 14), EndDate: date(time.July, 25)})
+	require.NoError(t, err)
+	otherUser := testUser
+	otherUser.Id = 2
+
+	// when
+	err = service.DeleteAbsence(user.WithUser(context.Background(), otherUser), created.Id)
+
+	// then
+	assert.ErrorIs(t, err, ErrAbsenceNotFound)
+}
@@ -0,0 +1,19 @@
+package announcement
+
+import "time"
+
+// Announcement informs users about a change of the application, e.g. a new integration.
+type Announcement struct {
+	Id int
+	// Version of the application introducing the change, e.g. "1.6.0"
+	Version     string
+	Title       string
+	Body        string
+	PublishedAt time.Time
+	// ReadAt is when the current user marked the announcement as read, nil when unread
+	ReadAt *time.Time
+}
+
+func (a Announcement) IsRead() bool {
+	return a.ReadAt != nil
+}
@@ -0,0 +1,230 @@
+package announcement
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/internal/rest"
+	log "github.com/sirupsen/logrus"
+)
+
+type AnnouncementDTO struct {
+	Id          int        `json:"id"`
+	Version     string     `json:"version"`
+	Title       string     `json:"title"`
+	Body        string     `json:"body"`
+	PublishedAt time.Time  `json:"publishedAt"`
+	Read        bool       `json:"read"`
+	ReadAt      *time.Time `json:"readAt,omitempty"`
+}
+
+type AnnouncementsDTO struct {
+	Announcements []AnnouncementDTO `json:"announcements"`
+	UnreadCount   int               `json:"unreadCount"`
+}
+
+type CreateAnnouncementDTO struct {
+	Version string `json:"version"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	// PublishedAt schedules the announcement, defaults to now
+	PublishedAt *time.Time `json:"publishedAt,omitempty"`
+}
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// GetAnnouncements godoc
+// @Summary List announcements
+// @Description List the published announcements about new capabilities, the newest first, with the read state of
+// @Description the current user. Clients can poll with since set to the last publishedAt they have seen.
+// @Tags Announcement
+// @Produce json
+// @Param since query string false "Only announcements published after this time, in RFC3339 format"
+// @Success 200 {object} AnnouncementsDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Router /api/announcements [get]
+// @Security XUserId
+func (h *Handler) GetAnnouncements(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var since *time.Time
+	if sinceString := r.URL.Query().Get("since"); sinceString != "" {
+		parsed, err := time.Parse(time.RFC3339, sinceString)
+		if err != nil {
+			writeBadRequest(w, "Invalid since parameter", "since must be in RFC3339 format")
+			return
+		}
+		since = &parsed
+	}
+
+	announcements, err := h.service.GetAnnouncements(r.Context(), since)
+	if err != nil {
+		log.Errorf("Failed to get announcements: %v", err)
+		http.Error(w, "Failed to get announcements", http.StatusInternalServerError)
+		return
+	}
+
+	result := AnnouncementsDTO{Announcements: make([]AnnouncementDTO, 0, len(announcements))}
+	for _, a := range announcements {
+		result.Announcements = append(result.Announcements, announcementToDTO(a))
+		if !a.IsRead() {
+			result.UnreadCount++
+		}
+	}
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Errorf("Failed to encode announcements: %v", err)
+		http.Error(w, "Failed to encode announcements", http.StatusInternalServerError)
+	}
+}
+
+// MarkRead godoc
+// @Summary Mark an announcement as read
+// @Description Mark the announcement as read by the current user
+// @Tags Announcement
+// @Param id path int true "Announcement ID"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Announcement not found"
+// @Router /api/announcements/{id}/read [post]
+// @Security XUserId
+func (h *Handler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid announcement ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.MarkRead(r.Context(), id); err != nil {
+		if errors.Is(err, ErrAnnouncementNotFound) {
+			http.Error(w, "Announcement not found", http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to mark announcement as read: %v", err)
+		http.Error(w, "Failed to mark announcement as read", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkAllRead godoc
+// @Summary Mark all announcements as read
+// @Description Mark all published announcements as read by the current user
+// @Tags Announcement
+// @Success 204 "No Content"
+// @Failure 403 {string} string "User not found"
+// @Router /api/announcements/read [post]
+// @Security XUserId
+func (h *Handler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.MarkAllRead(r.Context()); err != nil {
+		log.Errorf("Failed to mark announcements as read: %v", err)
+		http.Error(w, "Failed to mark announcements as read", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateAnnouncement godoc
+// @Summary Create an announcement
+// @Description Announce a new capability to all users. Requires an admin user.
+// @Tags Announcement
+// @Accept json
+// @Produce json
+// @Param announcement body CreateAnnouncementDTO true "Announcement"
+// @Success 201 {object} AnnouncementDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found or not an admin"
+// @Router /api/announcements [post]
+// @Security XUserId
+func (h *Handler) CreateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request CreateAnnouncementDTO
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeBadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	announcement := Announcement{
+		Version: request.Version,
+		Title:   request.Title,
+		Body:    request.Body,
+	}
+	if request.PublishedAt != nil {
+		announcement.PublishedAt = *request.PublishedAt
+	}
+
+	created, err := h.service.CreateAnnouncement(r.Context(), announcement)
+	if err != nil {
+		if errors.Is(err, ErrInvalidAnnouncement) {
+			writeBadRequest(w, "Invalid announcement", err.Error())
+			return
+		}
+		log.Errorf("Failed to create announcement: %v", err)
+		http.Error(w, "Failed to create announcement", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(announcementToDTO(created)); err != nil {
+		log.Errorf("Failed to encode announcement: %v", err)
+	}
+}
+
+// DeleteAnnouncement godoc
+// @Summary Delete an announcement
+// @Description Delete the announcement together with its read state. Requires an admin user.
+// @Tags Announcement
+// @Param id path int true "Announcement ID"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "User not found or not an admin"
+// @Failure 404 {string} string "Announcement not found"
+// @Router /api/announcements/{id} [delete]
+// @Security XUserId
+func (h *Handler) DeleteAnnouncement(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid announcement ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.DeleteAnnouncement(r.Context(), id); err != nil {
+		if errors.Is(err, ErrAnnouncementNotFound) {
+			http.Error(w, "Announcement not found", http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to delete announcement: %v", err)
+		http.Error(w, "Failed to delete announcement", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func announcementToDTO(a Announcement) AnnouncementDTO {
+	return AnnouncementDTO{
+		Id:          a.Id,
+		Version:     a.Version,
+		Title:       a.Title,
+		Body:        a.Body,
+		PublishedAt: a.PublishedAt,
+		Read:        a.IsRead(),
+		ReadAt:      a.ReadAt,
+	}
+}
+
+func writeBadRequest(w http.ResponseWriter, errMsg, details string) {
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+		Error:   errMsg,
+		Details: details,
+	})
+}
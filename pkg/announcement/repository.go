@@ -0,0 +1,100 @@
+package announcement
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository interface {
+	Create(ctx context.Context, announcement Announcement) (Announcement, error)
+	// Delete returns false when the announcement does not exist.
+	Delete(ctx context.Context, id int) (bool, error)
+	// GetPublished returns the announcements published after since and not after until, the newest first,
+	// with the read state of the user.
+	GetPublished(ctx context.Context, userId int, since time.Time, until time.Time) ([]Announcement, error)
+	// MarkRead marks the announcements published not after until as read by the user.
+	// Announcements already read keep their read time. It returns the number of announcements found.
+	MarkRead(ctx context.Context, userId int, ids []int, until time.Time) (int, error)
+	// MarkAllRead marks all announcements published not after until as read by the user.
+	MarkAllRead(ctx context.Context, userId int, until time.Time) error
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+func (r *RepositoryImpl) Create(ctx context.Context, announcement Announcement) (Announcement, error) {
+	query := `INSERT INTO announcement (version, title, body, published_at) VALUES ($1, $2, $3, $4) RETURNING id`
+	err := r.db.QueryRow(ctx, query, announcement.Version, announcement.Title, announcement.Body,
+		announcement.PublishedAt).Scan(&announcement.Id)
+	if err != nil {
+		return Announcement{}, fmt.Errorf("failed to create announcement: %w", err)
+	}
+	announcement.ReadAt = nil
+	return announcement, nil
+}
+
+func (r *RepositoryImpl) Delete(ctx context.Context, id int) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM announcement WHERE id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete announcement: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *RepositoryImpl) GetPublished(ctx context.Context, userId int, since time.Time, until time.Time) ([]Announcement, error) {
+	query := `SELECT a.id, a.version, a.title, a.body, a.published_at, ar.read_at
+			  FROM announcement a
+			  LEFT JOIN announcement_read ar ON ar.announcement_id = a.id AND ar.user_id = $1
+			  WHERE a.published_at > $2 AND a.published_at <= $3
+			  ORDER BY a.published_at DESC, a.id DESC`
+	rows, err := r.db.Query(ctx, query, userId, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get announcements: %w", err)
+	}
+	defer rows.Close()
+
+	announcements := make([]Announcement, 0)
+	for rows.Next() {
+		var a Announcement
+		if err := rows.Scan(&a.Id, &a.Version, &a.Title, &a.Body, &a.PublishedAt, &a.ReadAt); err != nil {
+			return nil, fmt.Errorf("failed to scan announcement: %w", err)
+		}
+		announcements = append(announcements, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get announcements: %w", err)
+	}
+	return announcements, nil
+}
+
+func (r *RepositoryImpl) MarkRead(ctx context.Context, userId int, ids []int, until time.Time) (int, error) {
+	query := `WITH found AS (SELECT id FROM announcement WHERE id = ANY($2) AND published_at <= $3),
+				   inserted AS (
+					   INSERT INTO announcement_read (user_id, announcement_id)
+						   SELECT $1, id FROM found
+					   ON CONFLICT (user_id, announcement_id) DO NOTHING)
+			  SELECT COUNT(*) FROM found`
+	var found int
+	if err := r.db.QueryRow(ctx, query, userId, ids, until).Scan(&found); err != nil {
+		return 0, fmt.Errorf("failed to mark announcements as read: %w", err)
+	}
+	return found, nil
+}
+
+func (r *RepositoryImpl) MarkAllRead(ctx context.Context, userId int, until time.Time) error {
+	query := `INSERT INTO announcement_read (user_id, announcement_id)
+			  SELECT $1, id FROM announcement WHERE published_at <= $2
+			  ON CONFLICT (user_id, announcement_id) DO NOTHING`
+	if _, err := r.db.Exec(ctx, query, userId, until); err != nil {
+		return fmt.Errorf("failed to mark announcements as read: %w", err)
+	}
+	return nil
+}
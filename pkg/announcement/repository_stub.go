@@ -0,0 +1,102 @@
+package announcement
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+type RepositoryStub struct {
+	mu            sync.RWMutex
+	announcements map[int]Announcement
+	reads         map[int]map[int]time.Time // userId -> announcementId -> read at
+	nextId        int
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{
+		announcements: make(map[int]Announcement),
+		reads:         make(map[int]map[int]time.Time),
+		nextId:        1,
+	}
+}
+
+func (r *RepositoryStub) Create(ctx context.Context, announcement Announcement) (Announcement, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	announcement.Id = r.nextId
+	announcement.ReadAt = nil
+	r.nextId++
+	r.announcements[announcement.Id] = announcement
+	return announcement, nil
+}
+
+func (r *RepositoryStub) Delete(ctx context.Context, id int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.announcements[id]; !ok {
+		return false, nil
+	}
+	delete(r.announcements, id)
+	for _, reads := range r.reads {
+		delete(reads, id)
+	}
+	return true, nil
+}
+
+func (r *RepositoryStub) GetPublished(ctx context.Context, userId int, since time.Time, until time.Time) ([]Announcement, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	announcements := make([]Announcement, 0)
+	for _, a := range r.announcements {
+		if !a.PublishedAt.After(since) || a.PublishedAt.After(until) {
+			continue
+		}
+		if readAt, ok := r.reads[userId][a.Id]; ok {
+			a.ReadAt = &readAt
+		}
+		announcements = append(announcements, a)
+	}
+	slices.SortFunc(announcements, func(a, b Announcement) int {
+		if c := b.PublishedAt.Compare(a.PublishedAt); c != 0 {
+			return c
+		}
+		return b.Id - a.Id
+	})
+	return announcements, nil
+}
+
+func (r *RepositoryStub) MarkRead(ctx context.Context, userId int, ids []int, until time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	found := 0
+	for _, a := range r.announcements {
+		if !slices.Contains(ids, a.Id) || a.PublishedAt.After(until) {
+			continue
+		}
+		found++
+		r.markRead(userId, a.Id)
+	}
+	return found, nil
+}
+
+func (r *RepositoryStub) MarkAllRead(ctx context.Context, userId int, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, a := range r.announcements {
+		if !a.PublishedAt.After(until) {
+			r.markRead(userId, a.Id)
+		}
+	}
+	return nil
+}
+
+func (r *RepositoryStub) markRead(userId int, announcementId int) {
+	if r.reads[userId] == nil {
+		r.reads[userId] = make(map[int]time.Time)
+	}
+	if _, ok := r.reads[userId][announcementId]; !ok {
+		r.reads[userId][announcementId] = time.Now()
+	}
+}
@@ -0,0 +1,98 @@
+package announcement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/user"
+)
+
+var ErrAnnouncementNotFound = errors.New("announcement not found")
+var ErrInvalidAnnouncement = errors.New("invalid announcement")
+
+type Service interface {
+	// GetAnnouncements returns the announcements published after since (all when nil) with the read state of
+	// the current user, the newest first. Announcements scheduled for later are not returned yet.
+	GetAnnouncements(ctx context.Context, since *time.Time) ([]Announcement, error)
+	// MarkRead marks the published announcement as read by the current user.
+	MarkRead(ctx context.Context, id int) error
+	// MarkAllRead marks all published announcements as read by the current user.
+	MarkAllRead(ctx context.Context) error
+	// CreateAnnouncement stores a new announcement, published now unless PublishedAt is set.
+	CreateAnnouncement(ctx context.Context, announcement Announcement) (Announcement, error)
+	DeleteAnnouncement(ctx context.Context, id int) error
+}
+
+type ServiceImpl struct {
+	repo  Repository
+	clock utils.Clock
+}
+
+func NewService(repo Repository, clock utils.Clock) *ServiceImpl {
+	return &ServiceImpl{repo: repo, clock: clock}
+}
+
+func (s *ServiceImpl) GetAnnouncements(ctx context.Context, since *time.Time) ([]Announcement, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	from := time.Time{}
+	if since != nil {
+		from = *since
+	}
+	return s.repo.GetPublished(ctx, userId, from, s.clock.Now())
+}
+
+func (s *ServiceImpl) MarkRead(ctx context.Context, id int) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	found, err := s.repo.MarkRead(ctx, userId, []int{id}, s.clock.Now())
+	if err != nil {
+		return err
+	}
+	if found == 0 {
+		return fmt.Errorf("%w: %d", ErrAnnouncementNotFound, id)
+	}
+	return nil
+}
+
+func (s *ServiceImpl) MarkAllRead(ctx context.Context) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.MarkAllRead(ctx, userId, s.clock.Now())
+}
+
+func (s *ServiceImpl) CreateAnnouncement(ctx context.Context, announcement Announcement) (Announcement, error) {
+	announcement.Version = strings.TrimSpace(announcement.Version)
+	announcement.Title = strings.TrimSpace(announcement.Title)
+	if announcement.Version == "" {
+		return Announcement{}, fmt.Errorf("%w: version is required", ErrInvalidAnnouncement)
+	}
+	if announcement.Title == "" {
+		return Announcement{}, fmt.Errorf("%w: title is required", ErrInvalidAnnouncement)
+	}
+	if announcement.PublishedAt.IsZero() {
+		announcement.PublishedAt = s.clock.Now()
+	}
+	return s.repo.Create(ctx, announcement)
+}
+
+func (s *ServiceImpl) DeleteAnnouncement(ctx context.Context, id int) error {
+	deleted, err := s.repo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("%w: %d", ErrAnnouncementNotFound, id)
+	}
+	return nil
+}
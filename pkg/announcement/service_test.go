@@ -0,0 +1,121 @@
+package announcement
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+
+func setupServiceTest(t *testing.T) (*ServiceImpl, *utils.MockClock, context.Context, context.Context) {
+	t.Helper()
+	clock := &utils.MockClock{FixedNow: now}
+	service := NewService(NewRepositoryStub(), clock)
+	ctx1 := user.WithUser(context.Background(), user.User{Id: 1, Uid: "user-1", Username: "test-user-1"})
+	ctx2 := user.WithUser(context.Background(), user.User{Id: 2, Uid: "user-2", Username: "test-user-2"})
+	return service, clock, ctx1, ctx2
+}
+
+func TestService_CreateAnnouncement(t *testing.T) {
+	service, _, ctx, _ := setupServiceTest(t)
+
+	t.Run("publishes now by default", func(t *testing.T) {
+		created, err := service.CreateAnnouncement(ctx, Announcement{Version: " 1.6.0 ", Title: "ClickUp sync"})
+		require.NoError(t, err)
+
+		assert.NotZero(t, created.Id)
+		assert.Equal(t, "1.6.0", created.Version)
+		assert.Equal(t, now, created.PublishedAt)
+	})
+
+	t.Run("requires version and title", func(t *testing.T) {
+		_, err := service.CreateAnnouncement(ctx, Announcement{Title: "No version"})
+		assert.ErrorIs(t, err, ErrInvalidAnnouncement)
+
+		_, err = service.CreateAnnouncement(ctx, Announcement{Version: "1.6.0", Title: "  "})
+		assert.ErrorIs(t, err, ErrInvalidAnnouncement)
+	})
+}
+
+func TestService_GetAnnouncements(t *testing.T) {
+	service, clock, ctx1, ctx2 := setupServiceTest(t)
+	older, err := service.CreateAnnouncement(ctx1, Announcement{Version: "1.5.0", Title: "Older", PublishedAt: now.Add(-48 * time.Hour)})
+	require.NoError(t, err)
+	newer, err := service.CreateAnnouncement(ctx1, Announcement{Version: "1.6.0", Title: "Newer", PublishedAt: now.Add(-time.Hour)})
+	require.NoError(t, err)
+	scheduled, err := service.CreateAnnouncement(ctx1, Announcement{Version: "1.7.0", Title: "Scheduled", PublishedAt: now.Add(24 * time.Hour)})
+	require.NoError(t, err)
+
+	t.Run("returns published announcements newest first", func(t *testing.T) {
+		announcements, err := service.GetAnnouncements(ctx1, nil)
+		require.NoError(t, err)
+
+		require.Len(t, announcements, 2)
+		assert.Equal(t, newer.Id, announcements[0].Id)
+		assert.Equal(t, older.Id, announcements[1].Id)
+		assert.False(t, announcements[0].IsRead())
+	})
+
+	t.Run("returns announcements published after since", func(t *testing.T) {
+		since := now.Add(-24 * time.Hour)
+		announcements, err := service.GetAnnouncements(ctx1, &since)
+		require.NoError(t, err)
+
+		require.Len(t, announcements, 1)
+		assert.Equal(t, newer.Id, announcements[0].Id)
+	})
+
+	t.Run("tracks read state per user", func(t *testing.T) {
+		require.NoError(t, service.MarkRead(ctx1, newer.Id))
+
+		announcements, err := service.GetAnnouncements(ctx1, nil)
+		require.NoError(t, err)
+		assert.True(t, announcements[0].IsRead())
+		assert.False(t, announcements[1].IsRead())
+
+		announcements, err = service.GetAnnouncements(ctx2, nil)
+		require.NoError(t, err)
+		assert.False(t, announcements[0].IsRead())
+	})
+
+	t.Run("cannot mark a scheduled announcement as read", func(t *testing.T) {
+		err := service.MarkRead(ctx1, scheduled.Id)
+		assert.ErrorIs(t, err, ErrAnnouncementNotFound)
+	})
+
+	t.Run("marks all published announcements as read", func(t *testing.T) {
+		require.NoError(t, service.MarkAllRead(ctx2))
+
+		announcements, err := service.GetAnnouncements(ctx2, nil)
+		require.NoError(t, err)
+		require.Len(t, announcements, 2)
+		assert.True(t, announcements[0].IsRead())
+		assert.True(t, announcements[1].IsRead())
+
+		clock.FixedNow = now.Add(48 * time.Hour)
+		announcements, err = service.GetAnnouncements(ctx2, nil)
+		require.NoError(t, err)
+		require.Len(t, announcements, 3)
+		assert.Equal(t, scheduled.Id, announcements[0].Id)
+		assert.False(t, announcements[0].IsRead())
+	})
+}
+
+func TestService_DeleteAnnouncement(t *testing.T) {
+	service, _, ctx, _ := setupServiceTest(t)
+	created, err := service.CreateAnnouncement(ctx, Announcement{Version: "1.6.0", Title: "To delete"})
+	require.NoError(t, err)
+
+	require.NoError(t, service.DeleteAnnouncement(ctx, created.Id))
+
+	announcements, err := service.GetAnnouncements(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, announcements)
+	assert.ErrorIs(t, service.DeleteAnnouncement(ctx, created.Id), ErrAnnouncementNotFound)
+}
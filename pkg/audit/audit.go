@@ -0,0 +1,147 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Action is the kind of security-relevant action recorded in the audit log.
+type Action string
+
+const (
+	ActionLogin               Action = "login"
+	ActionLoginFailed         Action = "login_failed"
+	ActionSettingsChanged     Action = "settings_changed"
+	ActionIntegrationEnabled  Action = "integration_enabled"
+	ActionIntegrationDisabled Action = "integration_disabled"
+	ActionDataExported        Action = "data_exported"
+	ActionDeleted             Action = "deleted"
+	ActionUserStatusChanged   Action = "user_status_changed"
+	ActionEventsReplayed      Action = "events_replayed"
+	// ActionDataChanged is recorded for changes of the user's records outside the settings, e.g. week reviews.
+	ActionDataChanged Action = "data_changed"
+)
+
+func (a Action) IsValid() bool {
+	switch a {
+	case ActionLogin, ActionLoginFailed, ActionSettingsChanged, ActionIntegrationEnabled, ActionIntegrationDisabled,
+		ActionDataExported, ActionDeleted, ActionUserStatusChanged, ActionEventsReplayed, ActionDataChanged:
+		return true
+	}
+	return false
+}
+
+// Entry is one recorded action.
+type Entry struct {
+	Id     int64
+	Action Action
+	// ActorId is 0 when nobody was authenticated, e.g. for failed logins, or the actor has been deleted since
+	ActorId  int
+	ActorUid string
+	Ip       string
+	// Resource is what the action was performed on, e.g. the route of the request "DELETE /api/user/{userUid}"
+	Resource  string
+	Details   map[string]string
+	CreatedAt time.Time
+}
+
+// Filter narrows down the listed entries, zero values match everything.
+type Filter struct {
+	ActorUid string
+	Action   Action
+	From     *time.Time
+	To       *time.Time
+	// BeforeId continues the listing after the last entry of the previous page
+	BeforeId int64
+	Limit    int
+}
+
+type clientIpKey struct{}
+
+// WithClientIp stores the address of the client the request came from in ctx.
+func WithClientIp(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIpKey{}, ip)
+}
+
+// ClientIp returns the address of the client stored in ctx, empty outside of requests.
+func ClientIp(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIpKey{}).(string)
+	return ip
+}
+
+// TrustedProxies are the reverse proxies Klokku is reached through, only they can tell the address of the client.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies parses the addresses or CIDR ranges of the trusted proxies, e.g. 10.0.0.0/8 or 172.17.0.1.
+func ParseTrustedProxies(values []string) (TrustedProxies, error) {
+	proxies := make(TrustedProxies, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+			}
+			proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+func (p TrustedProxies) trusts(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestIp returns the address of the client of the request. Behind a reverse proxy the connection comes from
+// the proxy, which appends the address it got the request from to X-Forwarded-For. So when the connection comes from
+// a trusted proxy, the header is read from the right, and the first address not of a trusted proxy is the client.
+// Addresses further left were sent by the client itself and could be anything.
+func RequestIp(r *http.Request, proxies TrustedProxies) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !proxies.trusts(ip) {
+		return ip
+	}
+	var forwarded []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			// Not appended by a proxy, the last trusted address is all that is known
+			return ip
+		}
+		ip = hop
+		if !proxies.trusts(ip) {
+			return ip
+		}
+	}
+	return ip
+}
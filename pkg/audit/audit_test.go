@@ -0,0 +1,50 @@
+package audit
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestIp(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", " 172.17.0.1 "})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		expected   string
+	}{
+		{"should use the connection without a proxy", "198.51.100.7:4000", nil, "198.51.100.7"},
+		{"should ignore the header of an untrusted connection", "198.51.100.7:4000", []string{"203.0.113.9"}, "198.51.100.7"},
+		{"should use the address appended by the proxy", "172.17.0.1:4000", []string{"203.0.113.9"}, "203.0.113.9"},
+		{"should ignore addresses sent by the client", "172.17.0.1:4000", []string{"1.2.3.4, 203.0.113.9"}, "203.0.113.9"},
+		{"should skip chained proxies", "10.0.0.2:4000", []string{"1.2.3.4, 203.0.113.9", "10.0.0.3"}, "203.0.113.9"},
+		{"should stop at an invalid address", "172.17.0.1:4000", []string{"203.0.113.9, unknown"}, "172.17.0.1"},
+		{"should use the proxy without the header", "172.17.0.1:4000", nil, "172.17.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			req := httptest.NewRequest("GET", "/api/user", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+
+			// when
+			ip := RequestIp(req, proxies)
+
+			// then
+			assert.Equal(t, tt.expected, ip)
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	_, err := ParseTrustedProxies([]string{"proxy.local"})
+	assert.Error(t, err)
+}
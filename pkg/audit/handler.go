@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/klokku/klokku/internal/rest"
+	log "github.com/sirupsen/logrus"
+)
+
+type EntryDTO struct {
+	Id     int64  `json:"id"`
+	Action Action `json:"action" enums:"login,login_failed,settings_changed,integration_enabled,integration_disabled,data_exported,deleted,user_status_changed,events_replayed,data_changed"`
+	// ActorUid is empty when nobody was authenticated, e.g. for failed logins
+	ActorUid  string            `json:"actorUid"`
+	Ip        string            `json:"ip"`
+	Resource  string            `json:"resource"`
+	Details   map[string]string `json:"details"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// ListEntries godoc
+// @Summary List audit log entries
+// @Description List the recorded security-relevant actions, the newest first. To get the next page pass the id
+// @Description of the last returned entry as 'before'. Requires an admin user.
+// @Tags Admin
+// @Produce json
+// @Param userUid query string false "Only actions of this user"
+// @Param action query string false "Only actions of this kind" Enums(login,login_failed,settings_changed,integration_enabled,integration_disabled,data_exported,deleted,user_status_changed,events_replayed,data_changed)
+// @Param from query string false "Only actions at or after this time (RFC 3339)"
+// @Param to query string false "Only actions before this time (RFC 3339)"
+// @Param before query int false "Only entries with a lower id"
+// @Param limit query int false "Maximum number of entries" default(100) maximum(500)
+// @Success 200 {array} EntryDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid filter"
+// @Failure 403 {string} string "User not found or not an admin"
+// @Router /api/admin/audit [get]
+// @Security XUserId
+func (h *Handler) ListEntries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	filter := Filter{
+		ActorUid: query.Get("userUid"),
+		Action:   Action(query.Get("action")),
+	}
+	for name, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				writeBadRequest(w, "Invalid "+name, "'"+name+"' must be an RFC 3339 timestamp")
+				return
+			}
+			*target = &t
+		}
+	}
+	if value := query.Get("before"); value != "" {
+		before, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || before <= 0 {
+			writeBadRequest(w, "Invalid before", "'before' must be a positive integer")
+			return
+		}
+		filter.BeforeId = before
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			writeBadRequest(w, "Invalid limit", "'limit' must be a positive integer")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := h.service.List(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, ErrInvalidFilter) {
+			writeBadRequest(w, "Invalid filter", err.Error())
+			return
+		}
+		log.Errorf("Failed to list audit log entries: %v", err)
+		http.Error(w, "Failed to list audit log entries", http.StatusInternalServerError)
+		return
+	}
+
+	dtos := make([]EntryDTO, 0, len(entries))
+	for _, entry := range entries {
+		details := entry.Details
+		if details == nil {
+			details = map[string]string{}
+		}
+		dtos = append(dtos, EntryDTO{
+			Id:        entry.Id,
+			Action:    entry.Action,
+			ActorUid:  entry.ActorUid,
+			Ip:        entry.Ip,
+			Resource:  entry.Resource,
+			Details:   details,
+			CreatedAt: entry.CreatedAt,
+		})
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		log.Errorf("Failed to encode audit log entries: %v", err)
+		http.Error(w, "Failed to encode audit log entries", http.StatusInternalServerError)
+	}
+}
+
+func writeBadRequest(w http.ResponseWriter, message string, details string) {
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+		Error:   message,
+		Details: details,
+	})
+}
@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository interface {
+	Create(ctx context.Context, entry Entry) (Entry, error)
+	// List returns the entries matching the filter, the newest first.
+	List(ctx context.Context, filter Filter) ([]Entry, error)
+	// DeleteBefore deletes the entries created before the given time and returns how many were deleted.
+	DeleteBefore(ctx context.Context, before time.Time) (int, error)
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+const entryColumns = "id, action, COALESCE(actor_user_id, 0), COALESCE(actor_uid, ''), COALESCE(ip, ''), resource, details, created_at"
+
+func scanEntry(row pgx.Row) (Entry, error) {
+	var entry Entry
+	err := row.Scan(&entry.Id, &entry.Action, &entry.ActorId, &entry.ActorUid, &entry.Ip, &entry.Resource,
+		&entry.Details, &entry.CreatedAt)
+	return entry, err
+}
+
+func (r *RepositoryImpl) Create(ctx context.Context, entry Entry) (Entry, error) {
+	var actorId *int
+	if entry.ActorId != 0 {
+		actorId = &entry.ActorId
+	}
+	details := entry.Details
+	if details == nil {
+		details = map[string]string{}
+	}
+	query := `INSERT INTO audit_log (created_at, action, actor_user_id, actor_uid, ip, resource, details)
+			  VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, $7)
+			  RETURNING ` + entryColumns
+	created, err := scanEntry(r.db.QueryRow(ctx, query, entry.CreatedAt, entry.Action, actorId, entry.ActorUid,
+		entry.Ip, entry.Resource, details))
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to create audit log entry: %w", err)
+	}
+	return created, nil
+}
+
+func (r *RepositoryImpl) List(ctx context.Context, filter Filter) ([]Entry, error) {
+	conditions := make([]string, 0)
+	args := make([]any, 0)
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", "$"+strconv.Itoa(len(args))))
+	}
+	if filter.ActorUid != "" {
+		addCondition("actor_uid = ?", filter.ActorUid)
+	}
+	if filter.Action != "" {
+		addCondition("action = ?", filter.Action)
+	}
+	if filter.From != nil {
+		addCondition("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at < ?", *filter.To)
+	}
+	if filter.BeforeId > 0 {
+		addCondition("id < ?", filter.BeforeId)
+	}
+
+	query := `SELECT ` + entryColumns + ` FROM audit_log`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += ` ORDER BY id DESC LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]Entry, 0)
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit log entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func (r *RepositoryImpl) DeleteBefore(ctx context.Context, before time.Time) (int, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM audit_log WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old audit log entries: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
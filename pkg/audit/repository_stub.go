@@ -0,0 +1,58 @@
+package audit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type RepositoryStub struct {
+	mu      sync.Mutex
+	nextId  int64
+	entries []Entry
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{}
+}
+
+func (r *RepositoryStub) Create(ctx context.Context, entry Entry) (Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextId++
+	entry.Id = r.nextId
+	r.entries = append(r.entries, entry)
+	return entry, nil
+}
+
+func (r *RepositoryStub) List(ctx context.Context, filter Filter) ([]Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := make([]Entry, 0)
+	for i := len(r.entries) - 1; i >= 0 && len(entries) < filter.Limit; i-- {
+		entry := r.entries[i]
+		if (filter.ActorUid != "" && entry.ActorUid != filter.ActorUid) ||
+			(filter.Action != "" && entry.Action != filter.Action) ||
+			(filter.From != nil && entry.CreatedAt.Before(*filter.From)) ||
+			(filter.To != nil && !entry.CreatedAt.Before(*filter.To)) ||
+			(filter.BeforeId > 0 && entry.Id >= filter.BeforeId) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (r *RepositoryStub) DeleteBefore(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := make([]Entry, 0, len(r.entries))
+	for _, entry := range r.entries {
+		if !entry.CreatedAt.Before(before) {
+			kept = append(kept, entry)
+		}
+	}
+	deleted := len(r.entries) - len(kept)
+	r.entries = kept
+	return deleted, nil
+}
@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultListLimit = 100
+	MaxListLimit     = 500
+)
+
+var ErrInvalidFilter = errors.New("invalid audit log filter")
+
+// Recorder records actions in the audit log.
+type Recorder interface {
+	// Record stores the action performed by the user in ctx on the resource, together with the client address
+	// stored in ctx. Failures are logged, recording never fails the action itself.
+	Record(ctx context.Context, action Action, resource string, details map[string]string)
+}
+
+type Service interface {
+	Recorder
+	// List returns the entries matching the filter, the newest first.
+	List(ctx context.Context, filter Filter) ([]Entry, error)
+	// DeleteEntriesBefore deletes the entries of all users created before the given time and returns how many
+	// were deleted.
+	DeleteEntriesBefore(ctx context.Context, before time.Time) (int, error)
+}
+
+type UserProvider interface {
+	GetUser(ctx context.Context, id int) (user.User, error)
+}
+
+type ServiceImpl struct {
+	repo  Repository
+	users UserProvider
+	clock utils.Clock
+}
+
+func NewService(repo Repository, users UserProvider, eventBus *event_bus.EventBus) *ServiceImpl {
+	service := &ServiceImpl{repo: repo, users: users, clock: utils.SystemClock{}}
+	// Integrations are authorized in an OAuth callback, the user is only known once the token is stored
+	event_bus.SubscribeTyped[event_bus.IntegrationConnected](
+		eventBus,
+		"integration.connected",
+		func(e event_bus.EventT[event_bus.IntegrationConnected]) error {
+			actor, err := users.GetUser(e.Context(), e.Data.UserId)
+			if err != nil {
+				log.Errorf("failed to get user %d connecting %s: %v", e.Data.UserId, e.Data.Provider, err)
+				actor = user.User{Id: e.Data.UserId}
+			}
+			service.Record(user.WithUser(e.Context(), actor), ActionIntegrationEnabled, e.Data.Provider, nil)
+			return nil
+		},
+	)
+	return service
+}
+
+func (s *ServiceImpl) Record(ctx context.Context, action Action, resource string, details map[string]string) {
+	entry := Entry{
+		Action:    action,
+		Ip:        ClientIp(ctx),
+		Resource:  resource,
+		Details:   maps.Clone(details),
+		CreatedAt: s.clock.Now(),
+	}
+	if actor, err := user.CurrentUser(ctx); err == nil {
+		entry.ActorId = actor.Id
+		entry.ActorUid = actor.Uid
+	}
+
+	// The log line keeps the trail even when the database is not available
+	log.WithFields(log.Fields{
+		"action":   entry.Action,
+		"actorUid": entry.ActorUid,
+		"ip":       entry.Ip,
+		"resource": entry.Resource,
+	}).Info("audit")
+	if _, err := s.repo.Create(ctx, entry); err != nil {
+		log.Errorf("failed to record %s of %q in the audit log: %v", action, entry.ActorUid, err)
+	}
+}
+
+func (s *ServiceImpl) List(ctx context.Context, filter Filter) ([]Entry, error) {
+	if filter.Action != "" && !filter.Action.IsValid() {
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidFilter, filter.Action)
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidFilter)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultListLimit
+	}
+	filter.Limit = min(filter.Limit, MaxListLimit)
+	return s.repo.List(ctx, filter)
+}
+
+func (s *ServiceImpl) DeleteEntriesBefore(ctx context.Context, before time.Time) (int, error) {
+	deleted, err := s.repo.DeleteBefore(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+	if deleted > 0 {
+		log.Infof("Deleted %d audit log entries", deleted)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,140 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2026, 3, 9, 10, 0, 0, 0, time.UTC)
+
+func setupServiceTest(t *testing.T) (*ServiceImpl, *event_bus.EventBus, user.User) {
+	t.Helper()
+	eventBus := event_bus.NewEventBus()
+	users := user.NewUserService(user.NewStubUserRepository(), eventBus)
+	u, err := users.CreateUser(context.Background(), user.User{Uid: "uid-1", Username: "alice", DisplayName: "Alice"})
+	require.NoError(t, err)
+	service := NewService(NewRepositoryStub(), users, eventBus)
+	service.clock = &utils.MockClock{FixedNow: now}
+	return service, eventBus, u
+}
+
+func TestServiceImpl_Record(t *testing.T) {
+	t.Run("should record the actor and the client address", func(t *testing.T) {
+		// given
+		service, _, u := setupServiceTest(t)
+		ctx := WithClientIp(user.WithUser(context.Background(), u), "192.0.2.1")
+
+		// when
+		service.Record(ctx, ActionDeleted, "DELETE /api/budgetplan/{planId}", map[string]string{"planId": "5"})
+
+		// then
+		entries, err := service.List(context.Background(), Filter{})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, ActionDeleted, entries[0].Action)
+		assert.Equal(t, u.Id, entries[0].ActorId)
+		assert.Equal(t, "uid-1", entries[0].ActorUid)
+		assert.Equal(t, "192.0.2.1", entries[0].Ip)
+		assert.Equal(t, "DELETE /api/budgetplan/{planId}", entries[0].Resource)
+		assert.Equal(t, map[string]string{"planId": "5"}, entries[0].Details)
+		assert.Equal(t, now, entries[0].CreatedAt)
+	})
+
+	t.Run("should record actions without an authenticated user", func(t *testing.T) {
+		// given
+		service, _, _ := setupServiceTest(t)
+
+		// when
+		service.Record(context.Background(), ActionLoginFailed, "user switch", map[string]string{"userUid": "uid-1"})
+
+		// then
+		entries, err := service.List(context.Background(), Filter{})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, 0, entries[0].ActorId)
+		assert.Empty(t, entries[0].ActorUid)
+	})
+
+	t.Run("should record integrations connected by OAuth", func(t *testing.T) {
+		// given
+		service, eventBus, u := setupServiceTest(t)
+
+		// when
+		err := eventBus.Publish(event_bus.NewEvent(context.Background(), "integration.connected",
+			event_bus.IntegrationConnected{UserId: u.Id, Provider: "clickup"}))
+
+		// then
+		require.NoError(t, err)
+		entries, err := service.List(context.Background(), Filter{Action: ActionIntegrationEnabled})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "uid-1", entries[0].ActorUid)
+		assert.Equal(t, "clickup", entries[0].Resource)
+	})
+}
+
+func TestServiceImpl_List(t *testing.T) {
+	t.Run("should filter and page the newest entries first", func(t *testing.T) {
+		// given
+		service, _, u := setupServiceTest(t)
+		ctx := user.WithUser(context.Background(), u)
+		for i := 0; i < 3; i++ {
+			service.Record(ctx, ActionSettingsChanged, "PUT /api/user/current", nil)
+		}
+		service.Record(ctx, ActionDataExported, "GET /api/export/time", nil)
+
+		// when
+		firstPage, err := service.List(context.Background(), Filter{ActorUid: "uid-1", Action: ActionSettingsChanged, Limit: 2})
+		require.NoError(t, err)
+		secondPage, err := service.List(context.Background(), Filter{ActorUid: "uid-1", Action: ActionSettingsChanged, BeforeId: firstPage[1].Id})
+		require.NoError(t, err)
+
+		// then
+		require.Len(t, firstPage, 2)
+		assert.Equal(t, int64(3), firstPage[0].Id)
+		assert.Equal(t, int64(2), firstPage[1].Id)
+		require.Len(t, secondPage, 1)
+		assert.Equal(t, int64(1), secondPage[0].Id)
+	})
+
+	t.Run("should reject invalid filters", func(t *testing.T) {
+		// given
+		service, _, _ := setupServiceTest(t)
+		later := now.Add(time.Hour)
+
+		// when
+		_, unknownActionErr := service.List(context.Background(), Filter{Action: "unknown"})
+		_, rangeErr := service.List(context.Background(), Filter{From: &later, To: &now})
+
+		// then
+		assert.ErrorIs(t, unknownActionErr, ErrInvalidFilter)
+		assert.ErrorIs(t, rangeErr, ErrInvalidFilter)
+	})
+}
+
+func TestServiceImpl_DeleteEntriesBefore(t *testing.T) {
+	// given
+	service, _, u := setupServiceTest(t)
+	ctx := user.WithUser(context.Background(), u)
+	service.Record(ctx, ActionLogin, "login", nil)
+	service.clock = &utils.MockClock{FixedNow: now.AddDate(0, 0, 10)}
+	service.Record(ctx, ActionDeleted, "DELETE /api/admin/db/queries/{pid}", nil)
+
+	// when
+	deleted, err := service.DeleteEntriesBefore(context.Background(), now.AddDate(0, 0, 1))
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	entries, err := service.List(context.Background(), Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, ActionDeleted, entries[0].Action)
+}
@@ -0,0 +1,41 @@
+package budget_alert
+
+import (
+	"time"
+
+	"github.com/klokku/klokku/pkg/weekly_plan"
+)
+
+// DefaultThresholds are used until the user configures their own.
+var DefaultThresholds = []int{80, 100, 120}
+
+const (
+	// MaxThreshold is the highest threshold accepted, in percent of the planned time.
+	MaxThreshold  = 1000
+	MaxThresholds = 10
+)
+
+type Settings struct {
+	Enabled bool
+	// Thresholds are percentages of the planned time, sorted ascending.
+	Thresholds []int
+}
+
+func DefaultSettings() Settings {
+	return Settings{Enabled: true, Thresholds: append([]int(nil), DefaultThresholds...)}
+}
+
+// Alert records that the time tracked for a weekly plan item crossed a threshold.
+type Alert struct {
+	Id           int64
+	UserId       int
+	Week         weekly_plan.WeekNumber
+	BudgetItemId int
+	ItemName     string
+	Threshold    int
+	// Percentage is the tracked share of the planned time when the alert was raised.
+	Percentage float64
+	Tracked    time.Duration
+	Planned    time.Duration
+	CreatedAt  time.Time
+}
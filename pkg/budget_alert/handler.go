@@ -0,0 +1,169 @@
+package budget_alert
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	log "github.com/sirupsen/logrus"
+)
+
+type SettingsDTO struct {
+	Enabled bool `json:"enabled"`
+	// Thresholds are percentages of the planned time, e.g. [80, 100, 120]
+	Thresholds []int `json:"thresholds"`
+}
+
+type AlertDTO struct {
+	Id           int64  `json:"id"`
+	Week         string `json:"week"`
+	BudgetItemId int    `json:"budgetItemId"`
+	ItemName     string `json:"itemName"`
+	Threshold    int    `json:"threshold"`
+	// Percentage is the tracked share of the planned time when the alert was raised, rounded to one decimal place
+	Percentage float64   `json:"percentage"`
+	Tracked    int       `json:"tracked"`
+	Planned    int       `json:"planned"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// ListAlerts godoc
+// @Summary List budget alerts
+// @Description The latest 100 alerts raised when the time tracked for a weekly plan item crossed a threshold,
+// @Description newest first
+// @Tags BudgetAlert
+// @Produce json
+// @Param week query string false "ISO 8601 week, e.g. 2025-W03, all weeks when omitted"
+// @Success 200 {array} AlertDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid week"
+// @Failure 403 {string} string "User not found"
+// @Router /api/budget-alerts [get]
+// @Security XUserId
+func (h *Handler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var week weekly_plan.WeekNumber
+	if weekString := r.URL.Query().Get("week"); weekString != "" {
+		var err error
+		week, err = weekly_plan.WeekNumberFromString(weekString)
+		if err != nil {
+			writeBadRequest(w, "Invalid week", err.Error())
+			return
+		}
+	}
+	alerts, err := h.service.ListAlerts(r.Context(), week)
+	if err != nil {
+		log.Errorf("Failed to list budget alerts: %v", err)
+		http.Error(w, "Failed to list budget alerts", http.StatusInternalServerError)
+		return
+	}
+	dtos := make([]AlertDTO, 0, len(alerts))
+	for _, alert := range alerts {
+		dtos = append(dtos, AlertDTO{
+			Id:           alert.Id,
+			Week:         alert.Week.String(),
+			BudgetItemId: alert.BudgetItemId,
+			ItemName:     alert.ItemName,
+			Threshold:    alert.Threshold,
+			Percentage:   math.Round(alert.Percentage*10) / 10,
+			Tracked:      int(alert.Tracked.Seconds()),
+			Planned:      int(alert.Planned.Seconds()),
+			CreatedAt:    alert.CreatedAt,
+		})
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		log.Errorf("Failed to encode budget alerts: %v", err)
+		http.Error(w, "Failed to encode budget alerts", http.StatusInternalServerError)
+	}
+}
+
+// GetSettings godoc
+// @Summary Get budget alert settings
+// @Tags BudgetAlert
+// @Produce json
+// @Success 200 {object} SettingsDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/budget-alerts/settings [get]
+// @Security XUserId
+func (h *Handler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	settings, err := h.service.GetSettings(r.Context())
+	if err != nil {
+		log.Errorf("Failed to get budget alert settings: %v", err)
+		http.Error(w, "Failed to get budget alert settings", http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(settingsToDTO(settings)); err != nil {
+		log.Errorf("Failed to encode budget alert settings: %v", err)
+		http.Error(w, "Failed to encode budget alert settings", http.StatusInternalServerError)
+	}
+}
+
+// UpdateSettings godoc
+// @Summary Update budget alert settings
+// @Description Set the percentages of the planned time at which an item raises an alert, 1 to 10 thresholds
+// @Description between 1 and 1000
+// @Tags BudgetAlert
+// @Accept json
+// @Produce json
+// @Param settings body SettingsDTO true "Settings"
+// @Success 200 {object} SettingsDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid settings"
+// @Failure 403 {string} string "User not found"
+// @Router /api/budget-alerts/settings [put]
+// @Security XUserId
+func (h *Handler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var settingsDTO SettingsDTO
+	if err := json.NewDecoder(r.Body).Decode(&settingsDTO); err != nil {
+		writeBadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	updated, err := h.service.UpdateSettings(r.Context(), Settings{
+		Enabled:    settingsDTO.Enabled,
+		Thresholds: settingsDTO.Thresholds,
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvalidSettings) {
+			writeBadRequest(w, "Invalid budget alert settings", err.Error())
+			return
+		}
+		log.Errorf("Failed to update budget alert settings: %v", err)
+		http.Error(w, "Failed to update budget alert settings", http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(settingsToDTO(updated)); err != nil {
+		log.Errorf("Failed to encode budget alert settings: %v", err)
+		http.Error(w, "Failed to encode budget alert settings", http.StatusInternalServerError)
+	}
+}
+
+func settingsToDTO(settings Settings) SettingsDTO {
+	thresholds := settings.Thresholds
+	if thresholds == nil {
+		thresholds = []int{}
+	}
+	return SettingsDTO{Enabled: settings.Enabled, Thresholds: thresholds}
+}
+
+func writeBadRequest(w http.ResponseWriter, errMsg, details string) {
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+		Error:   errMsg,
+		Details: details,
+	})
+}
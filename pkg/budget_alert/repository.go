@@ -0,0 +1,129 @@
+package budget_alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/klokku/klokku/internal/database/dbtx"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+)
+
+type Repository interface {
+	// WithTransaction runs fn in a transaction, committed when fn returns no error. The context passed to fn carries
+	// the transaction, so events published with it are stored together with the changes.
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	// GetSettings returns the default settings when the user has not configured any.
+	GetSettings(ctx context.Context, userId int) (Settings, error)
+	SaveSettings(ctx context.Context, userId int, settings Settings) (Settings, error)
+	// CreateAlert stores the alert, it returns false when the threshold of the item was already alerted that week.
+	CreateAlert(ctx context.Context, alert Alert) (Alert, bool, error)
+	// ListAlerts returns the latest alerts of the user, the newest first. A zero week lists alerts of all weeks.
+	ListAlerts(ctx context.Context, userId int, week weekly_plan.WeekNumber, limit int) ([]Alert, error)
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+func (r *RepositoryImpl) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return dbtx.InTx(ctx, r.db, fn)
+}
+
+func (r *RepositoryImpl) GetSettings(ctx context.Context, userId int) (Settings, error) {
+	query := `SELECT enabled, thresholds FROM budget_alert_settings WHERE user_id = $1`
+	var settings Settings
+	if err := dbtx.From(ctx, r.db).QueryRow(ctx, query, userId).Scan(&settings.Enabled, &settings.Thresholds); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return DefaultSettings(), nil
+		}
+		return Settings{}, fmt.Errorf("failed to get budget alert settings: %w", err)
+	}
+	return settings, nil
+}
+
+func (r *RepositoryImpl) SaveSettings(ctx context.Context, userId int, settings Settings) (Settings, error) {
+	query := `INSERT INTO budget_alert_settings (user_id, enabled, thresholds) VALUES ($1, $2, $3)
+			  ON CONFLICT (user_id) DO UPDATE SET enabled = EXCLUDED.enabled, thresholds = EXCLUDED.thresholds
+			  RETURNING enabled, thresholds`
+	var saved Settings
+	if err := dbtx.From(ctx, r.db).QueryRow(ctx, query, userId, settings.Enabled, settings.Thresholds).
+		Scan(&saved.Enabled, &saved.Thresholds); err != nil {
+		return Settings{}, fmt.Errorf("failed to save budget alert settings: %w", err)
+	}
+	return saved, nil
+}
+
+const alertColumns = `id, user_id, week_number, budget_item_id, item_name, threshold, percentage, tracked_sec,
+	planned_sec, created_at`
+
+func scanAlert(row pgx.Row) (Alert, error) {
+	var alert Alert
+	var week string
+	var trackedSec, plannedSec int
+	if err := row.Scan(&alert.Id, &alert.UserId, &week, &alert.BudgetItemId, &alert.ItemName, &alert.Threshold,
+		&alert.Percentage, &trackedSec, &plannedSec, &alert.CreatedAt); err != nil {
+		return Alert{}, err
+	}
+	weekNumber, err := weekly_plan.WeekNumberFromString(week)
+	if err != nil {
+		return Alert{}, err
+	}
+	alert.Week = weekNumber
+	alert.Tracked = time.Duration(trackedSec) * time.Second
+	alert.Planned = time.Duration(plannedSec) * time.Second
+	return alert, nil
+}
+
+func (r *RepositoryImpl) CreateAlert(ctx context.Context, alert Alert) (Alert, bool, error) {
+	query := `INSERT INTO budget_alert (user_id, week_number, budget_item_id, item_name, threshold, percentage,
+			  	tracked_sec, planned_sec)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			  ON CONFLICT (user_id, week_number, budget_item_id, threshold) DO NOTHING
+			  RETURNING ` + alertColumns
+	created, err := scanAlert(dbtx.From(ctx, r.db).QueryRow(ctx, query, alert.UserId, alert.Week.String(), alert.BudgetItemId,
+		alert.ItemName, alert.Threshold, alert.Percentage, int(alert.Tracked.Seconds()), int(alert.Planned.Seconds())))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Alert{}, false, nil
+		}
+		return Alert{}, false, fmt.Errorf("failed to create budget alert: %w", err)
+	}
+	return created, true, nil
+}
+
+func (r *RepositoryImpl) ListAlerts(ctx context.Context, userId int, week weekly_plan.WeekNumber, limit int) ([]Alert, error) {
+	query := `SELECT ` + alertColumns + ` FROM budget_alert
+			  WHERE user_id = $1 AND ($2 = '' OR week_number = $2)
+			  ORDER BY id DESC
+			  LIMIT $3`
+	weekFilter := ""
+	if week != (weekly_plan.WeekNumber{}) {
+		weekFilter = week.String()
+	}
+	rows, err := dbtx.From(ctx, r.db).Query(ctx, query, userId, weekFilter, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list budget alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := make([]Alert, 0)
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan budget alert: %w", err)
+		}
+		alerts = append(alerts, alert)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list budget alerts: %w", err)
+	}
+	return alerts, nil
+}
@@ -0,0 +1,96 @@
+package budget_alert
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/klokku/klokku/pkg/weekly_plan"
+)
+
+type alertKey struct {
+	userId       int
+	week         weekly_plan.WeekNumber
+	budgetItemId int
+	threshold    int
+}
+
+type RepositoryStub struct {
+	mu       sync.RWMutex
+	settings map[int]Settings
+	alerts   []Alert
+	alerted  map[alertKey]bool
+	nextId   int64
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{
+		settings: make(map[int]Settings),
+		alerted:  make(map[alertKey]bool),
+		nextId:   1,
+	}
+}
+
+// WithTransaction restores the alerts when fn fails.
+func (r *RepositoryStub) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	r.mu.RLock()
+	alerts, alerted, nextId := slices.Clone(r.alerts), maps.Clone(r.alerted), r.nextId
+	r.mu.RUnlock()
+	if err := fn(ctx); err != nil {
+		r.mu.Lock()
+		r.alerts, r.alerted, r.nextId = alerts, alerted, nextId
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (r *RepositoryStub) GetSettings(ctx context.Context, userId int) (Settings, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	settings, ok := r.settings[userId]
+	if !ok {
+		return DefaultSettings(), nil
+	}
+	return settings, nil
+}
+
+func (r *RepositoryStub) SaveSettings(ctx context.Context, userId int, settings Settings) (Settings, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.settings[userId] = settings
+	return settings, nil
+}
+
+func (r *RepositoryStub) CreateAlert(ctx context.Context, alert Alert) (Alert, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := alertKey{alert.UserId, alert.Week, alert.BudgetItemId, alert.Threshold}
+	if r.alerted[key] {
+		return Alert{}, false, nil
+	}
+	r.alerted[key] = true
+	alert.Id = r.nextId
+	alert.CreatedAt = time.Now()
+	r.nextId++
+	r.alerts = append(r.alerts, alert)
+	return alert, true, nil
+}
+
+func (r *RepositoryStub) ListAlerts(ctx context.Context, userId int, week weekly_plan.WeekNumber, limit int) ([]Alert, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	alerts := make([]Alert, 0)
+	for _, alert := range slices.Backward(r.alerts) {
+		if alert.UserId != userId || (week != (weekly_plan.WeekNumber{}) && alert.Week != week) {
+			continue
+		}
+		alerts = append(alerts, alert)
+		if len(alerts) == limit {
+			break
+		}
+	}
+	return alerts, nil
+}
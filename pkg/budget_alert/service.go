@@ -0,0 +1,267 @@
+package budget_alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/current_event"
+	"github.com/klokku/klokku/pkg/stats"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrInvalidSettings = errors.New("invalid budget alert settings")
+
+const (
+	// MaxAlertsListed limits the alerts returned at once.
+	MaxAlertsListed = 100
+)
+
+type Service interface {
+	GetSettings(ctx context.Context) (Settings, error)
+	UpdateSettings(ctx context.Context, settings Settings) (Settings, error)
+	// ListAlerts returns the latest alerts of the current user, the newest first. A zero week lists all weeks.
+	ListAlerts(ctx context.Context, week weekly_plan.WeekNumber) ([]Alert, error)
+	// CheckWeek raises the alerts of the current user for the week containing weekTime and returns the new ones.
+	CheckWeek(ctx context.Context, weekTime time.Time) ([]Alert, error)
+	// CheckRunningEvents checks the current week of every user tracking an event at the moment.
+	CheckRunningEvents(ctx context.Context) error
+}
+
+type usersProvider interface {
+	GetAllUsers(ctx context.Context) ([]user.User, error)
+}
+
+type currentEventProvider interface {
+	FindCurrentEvent(ctx context.Context) (current_event.CurrentEvent, error)
+}
+
+type weeklyStatsProvider interface {
+	GetWeeklyStats(ctx context.Context, weekTime time.Time, includeSandbox bool) (stats.WeeklyStatsSummary, error)
+}
+
+type ServiceImpl struct {
+	repo          Repository
+	users         usersProvider
+	currentEvents currentEventProvider
+	stats         weeklyStatsProvider
+	eventBus      *event_bus.EventBus
+	clock         utils.Clock
+}
+
+func NewService(
+	repo Repository,
+	users usersProvider,
+	currentEvents currentEventProvider,
+	stats weeklyStatsProvider,
+	eventBus *event_bus.EventBus,
+) (*ServiceImpl, error) {
+	service := &ServiceImpl{
+		repo:          repo,
+		users:         users,
+		currentEvents: currentEvents,
+		stats:         stats,
+		eventBus:      eventBus,
+		clock:         &utils.SystemClock{},
+	}
+	if err := service.subscribe(); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+func (s *ServiceImpl) subscribe() error {
+	if s.eventBus == nil {
+		return nil
+	}
+	return event_bus.SubscribeDurable[event_bus.CalendarEventCreated](
+		s.eventBus,
+		"budget_alert",
+		"calendar.event.created",
+		func(e event_bus.EventT[event_bus.CalendarEventCreated]) error {
+			if e.Data.Sandbox {
+				return nil
+			}
+			if _, err := s.CheckWeek(e.Context(), e.Data.StartTime); err != nil {
+				return fmt.Errorf("failed to check budget alerts: %w", err)
+			}
+			return nil
+		},
+	)
+}
+
+func (s *ServiceImpl) GetSettings(ctx context.Context) (Settings, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Settings{}, fmt.Errorf("failed to get current user id: %w", err)
+	}
+	return s.repo.GetSettings(ctx, userId)
+}
+
+func (s *ServiceImpl) UpdateSettings(ctx context.Context, settings Settings) (Settings, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Settings{}, fmt.Errorf("failed to get current user id: %w", err)
+	}
+	if len(settings.Thresholds) == 0 {
+		return Settings{}, fmt.Errorf("%w: at least one threshold is required", ErrInvalidSettings)
+	}
+	if len(settings.Thresholds) > MaxThresholds {
+		return Settings{}, fmt.Errorf("%w: at most %d thresholds are allowed", ErrInvalidSettings, MaxThresholds)
+	}
+	for _, threshold := range settings.Thresholds {
+		if threshold <= 0 || threshold > MaxThreshold {
+			return Settings{}, fmt.Errorf("%w: threshold must be between 1 and %d", ErrInvalidSettings, MaxThreshold)
+		}
+	}
+	thresholds := slices.Clone(settings.Thresholds)
+	slices.Sort(thresholds)
+	settings.Thresholds = slices.Compact(thresholds)
+	return s.repo.SaveSettings(ctx, userId, settings)
+}
+
+func (s *ServiceImpl) ListAlerts(ctx context.Context, week weekly_plan.WeekNumber) ([]Alert, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user id: %w", err)
+	}
+	return s.repo.ListAlerts(ctx, userId, week, MaxAlertsListed)
+}
+
+func (s *ServiceImpl) CheckWeek(ctx context.Context, weekTime time.Time) ([]Alert, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	settings, err := s.repo.GetSettings(ctx, currentUser.Id)
+	if err != nil {
+		return nil, err
+	}
+	if !settings.Enabled || len(settings.Thresholds) == 0 {
+		return nil, nil
+	}
+	location, err := time.LoadLocation(currentUser.Settings.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	weekTime = weekTime.In(location)
+	week := weekly_plan.WeekNumberFromDate(weekTime, currentUser.Settings.WeekFirstDay)
+
+	// Stats of the current week include the running event
+	summary, err := s.stats.GetWeeklyStats(ctx, weekTime, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get weekly stats: %w", err)
+	}
+	raised := make([]Alert, 0)
+	for _, item := range summary.PerPlanItem {
+		// Ad hoc items have no budget item to tell their alerts apart
+		if item.PlanItem.BudgetItemId == 0 {
+			continue
+		}
+		alert, ok, err := s.raise(ctx, currentUser.Id, week, item, settings.Thresholds)
+		if err != nil {
+			return raised, err
+		}
+		if ok {
+			raised = append(raised, alert)
+		}
+	}
+	return raised, nil
+}
+
+// raise records every threshold the item crossed and publishes the highest newly crossed one only, so a single
+// long event does not notify about 80% and 100% at once. The alerts are recorded in the transaction the event is
+// published in, so an alert is never recorded without its notification.
+func (s *ServiceImpl) raise(
+	ctx context.Context,
+	userId int,
+	week weekly_plan.WeekNumber,
+	item stats.PlanItemStats,
+	thresholds []int,
+) (Alert, bool, error) {
+	percentage := item.Percentage()
+	var highest Alert
+	raised := false
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		for _, threshold := range thresholds {
+			if percentage < float64(threshold) {
+				break
+			}
+			alert, created, err := s.repo.CreateAlert(ctx, Alert{
+				UserId:       userId,
+				Week:         week,
+				BudgetItemId: item.PlanItem.BudgetItemId,
+				ItemName:     item.PlanItem.Name,
+				Threshold:    threshold,
+				Percentage:   percentage,
+				Tracked:      item.Duration,
+				Planned:      item.PlanItem.WeeklyItemDuration,
+			})
+			if err != nil {
+				return err
+			}
+			if created {
+				highest, raised = alert, true
+			}
+		}
+		if !raised {
+			return nil
+		}
+		return s.publish(ctx, highest)
+	})
+	if err != nil {
+		return Alert{}, false, err
+	}
+	return highest, raised, nil
+}
+
+func (s *ServiceImpl) publish(ctx context.Context, alert Alert) error {
+	if s.eventBus == nil {
+		return nil
+	}
+	err := s.eventBus.Publish(event_bus.NewEvent(ctx, "budget_alert.triggered", event_bus.BudgetAlertTriggered{
+		BudgetItemId: alert.BudgetItemId,
+		Name:         alert.ItemName,
+		Week:         alert.Week.String(),
+		Threshold:    alert.Threshold,
+		Percentage:   alert.Percentage,
+		Tracked:      alert.Tracked,
+		Planned:      alert.Planned,
+	}))
+	if err != nil {
+		return fmt.Errorf("failed to publish budget_alert.triggered event: %w", err)
+	}
+	return nil
+}
+
+func (s *ServiceImpl) CheckRunningEvents(ctx context.Context) error {
+	users, err := s.users.GetAllUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get users: %w", err)
+	}
+	now := s.clock.Now()
+	for _, u := range users {
+		if u.Disabled {
+			continue
+		}
+		userCtx := user.WithUser(ctx, u)
+		currentEvent, err := s.currentEvents.FindCurrentEvent(userCtx)
+		if err != nil {
+			log.Errorf("failed to find current event of user %d: %v", u.Id, err)
+			continue
+		}
+		if currentEvent.Id == 0 {
+			continue
+		}
+		if _, err := s.CheckWeek(userCtx, now); err != nil {
+			log.Errorf("failed to check budget alerts of user %d: %v", u.Id, err)
+		}
+	}
+	return nil
+}
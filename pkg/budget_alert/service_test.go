@@ -0,0 +1,231 @@
+package budget_alert
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/current_event"
+	"github.com/klokku/klokku/pkg/stats"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2025, 6, 11, 12, 0, 0, 0, time.UTC)
+
+var week = weekly_plan.WeekNumber{Year: 2025, Week: 24}
+
+var testUser = user.User{
+	Id:       1,
+	Uid:      "user-1",
+	Username: "test-user-1",
+	Settings: user.Settings{Timezone: "UTC", WeekFirstDay: time.Monday},
+}
+
+type usersStub []user.User
+
+func (u usersStub) GetAllUsers(ctx context.Context) ([]user.User, error) {
+	return u, nil
+}
+
+// currentEventsStub returns the running event of users present in the map.
+type currentEventsStub map[int]current_event.CurrentEvent
+
+func (c currentEventsStub) FindCurrentEvent(ctx context.Context) (current_event.CurrentEvent, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return current_event.CurrentEvent{}, err
+	}
+	return c[userId], nil
+}
+
+type statsStub struct {
+	tracked map[int]time.Duration
+	calls   int
+}
+
+func (s *statsStub) GetWeeklyStats(ctx context.Context, weekTime time.Time, includeSandbox bool) (stats.WeeklyStatsSummary, error) {
+	s.calls++
+	return stats.WeeklyStatsSummary{
+		PerPlanItem: []stats.PlanItemStats{
+			{
+				PlanItem: stats.PlanItem{BudgetItemId: 10, Name: "Reading", WeeklyItemDuration: 10 * time.Hour},
+				Duration: s.tracked[10],
+			},
+			{
+				PlanItem: stats.PlanItem{BudgetItemId: 11, Name: "Work", WeeklyItemDuration: 40 * time.Hour},
+				Duration: s.tracked[11],
+			},
+		},
+	}, nil
+}
+
+type testEnv struct {
+	service       *ServiceImpl
+	repo          *RepositoryStub
+	stats         *statsStub
+	currentEvents currentEventsStub
+	eventBus      *event_bus.EventBus
+	published     *[]event_bus.BudgetAlertTriggered
+	ctx           context.Context
+}
+
+func setupServiceTest(t *testing.T) testEnv {
+	t.Helper()
+	repo := NewRepositoryStub()
+	statsProvider := &statsStub{tracked: make(map[int]time.Duration)}
+	currentEvents := currentEventsStub{}
+	eventBus := event_bus.NewEventBus()
+	published := &[]event_bus.BudgetAlertTriggered{}
+	event_bus.SubscribeTyped[event_bus.BudgetAlertTriggered](eventBus, "budget_alert.triggered",
+		func(e event_bus.EventT[event_bus.BudgetAlertTriggered]) error {
+			*published = append(*published, e.Data)
+			return nil
+		})
+	service, err := NewService(repo, usersStub{testUser}, currentEvents, statsProvider, eventBus)
+	require.NoError(t, err)
+	service.clock = &utils.MockClock{FixedNow: now}
+	return testEnv{
+		service:       service,
+		repo:          repo,
+		stats:         statsProvider,
+		currentEvents: currentEvents,
+		eventBus:      eventBus,
+		published:     published,
+		ctx:           user.WithUser(context.Background(), testUser),
+	}
+}
+
+func TestCheckWeek_RaisesEachThresholdOnce(t *testing.T) {
+	env := setupServiceTest(t)
+
+	env.stats.tracked[10] = 8 * time.Hour
+	raised, err := env.service.CheckWeek(env.ctx, now)
+	require.NoError(t, err)
+	require.Len(t, raised, 1)
+	assert.Equal(t, 80, raised[0].Threshold)
+	assert.Equal(t, week, raised[0].Week)
+
+	// Nothing new crossed
+	env.stats.tracked[10] = 9 * time.Hour
+	raised, err = env.service.CheckWeek(env.ctx, now)
+	require.NoError(t, err)
+	assert.Empty(t, raised)
+
+	env.stats.tracked[10] = 10 * time.Hour
+	raised, err = env.service.CheckWeek(env.ctx, now)
+	require.NoError(t, err)
+	require.Len(t, raised, 1)
+	assert.Equal(t, 100, raised[0].Threshold)
+
+	assert.Equal(t, []event_bus.BudgetAlertTriggered{
+		{BudgetItemId: 10, Name: "Reading", Week: "2025-W24", Threshold: 80, Percentage: 80,
+			Tracked: 8 * time.Hour, Planned: 10 * time.Hour},
+		{BudgetItemId: 10, Name: "Reading", Week: "2025-W24", Threshold: 100, Percentage: 100,
+			Tracked: 10 * time.Hour, Planned: 10 * time.Hour},
+	}, *env.published)
+}
+
+func TestCheckWeek_PublishesOnlyHighestCrossedThreshold(t *testing.T) {
+	env := setupServiceTest(t)
+	env.stats.tracked[11] = 50 * time.Hour
+
+	raised, err := env.service.CheckWeek(env.ctx, now)
+	require.NoError(t, err)
+
+	require.Len(t, raised, 1)
+	assert.Equal(t, 120, raised[0].Threshold)
+	require.Len(t, *env.published, 1)
+	// Lower thresholds are recorded too, so they are not raised later
+	alerts, err := env.service.ListAlerts(env.ctx, week)
+	require.NoError(t, err)
+	assert.Len(t, alerts, 3)
+}
+
+func TestCheckWeek_UsesUserThresholds(t *testing.T) {
+	env := setupServiceTest(t)
+	_, err := env.service.UpdateSettings(env.ctx, Settings{Enabled: true, Thresholds: []int{150, 50, 50}})
+	require.NoError(t, err)
+	settings, err := env.service.GetSettings(env.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{50, 150}, settings.Thresholds)
+
+	env.stats.tracked[10] = 6 * time.Hour
+	raised, err := env.service.CheckWeek(env.ctx, now)
+	require.NoError(t, err)
+
+	require.Len(t, raised, 1)
+	assert.Equal(t, 50, raised[0].Threshold)
+}
+
+func TestCheckWeek_Disabled(t *testing.T) {
+	env := setupServiceTest(t)
+	_, err := env.service.UpdateSettings(env.ctx, Settings{Enabled: false, Thresholds: DefaultThresholds})
+	require.NoError(t, err)
+	env.stats.tracked[10] = 20 * time.Hour
+
+	raised, err := env.service.CheckWeek(env.ctx, now)
+	require.NoError(t, err)
+
+	assert.Empty(t, raised)
+	assert.Zero(t, env.stats.calls)
+}
+
+func TestUpdateSettings_Validation(t *testing.T) {
+	env := setupServiceTest(t)
+	for name, thresholds := range map[string][]int{
+		"no thresholds":  {},
+		"zero":           {0, 100},
+		"too high":       {MaxThreshold + 1},
+		"too many":       {10, 20, 30, 40, 50, 60, 70, 80, 90, 100, 110},
+		"negative value": {-10},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := env.service.UpdateSettings(env.ctx, Settings{Enabled: true, Thresholds: thresholds})
+			assert.ErrorIs(t, err, ErrInvalidSettings)
+		})
+	}
+}
+
+func TestCalendarEventCreated_ChecksTheEventWeek(t *testing.T) {
+	env := setupServiceTest(t)
+	env.stats.tracked[10] = 12 * time.Hour
+
+	err := env.eventBus.Publish(event_bus.NewEvent(env.ctx, "calendar.event.created", event_bus.CalendarEventCreated{
+		StartTime:    now,
+		EndTime:      now.Add(time.Hour),
+		BudgetItemId: 10,
+	}))
+	require.NoError(t, err)
+	err = env.eventBus.Publish(event_bus.NewEvent(env.ctx, "calendar.event.created", event_bus.CalendarEventCreated{
+		StartTime: now,
+		Sandbox:   true,
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, env.stats.calls)
+	require.Len(t, *env.published, 1)
+	assert.Equal(t, 120, (*env.published)[0].Threshold)
+}
+
+func TestCheckRunningEvents_OnlyUsersTrackingAnEvent(t *testing.T) {
+	env := setupServiceTest(t)
+	env.stats.tracked[10] = 10 * time.Hour
+
+	require.NoError(t, env.service.CheckRunningEvents(context.Background()))
+	assert.Zero(t, env.stats.calls)
+
+	env.currentEvents[testUser.Id] = current_event.CurrentEvent{Id: 1, StartTime: now.Add(-time.Hour)}
+	require.NoError(t, env.service.CheckRunningEvents(context.Background()))
+
+	assert.Equal(t, 1, env.stats.calls)
+	alerts, err := env.service.ListAlerts(env.ctx, weekly_plan.WeekNumber{})
+	require.NoError(t, err)
+	require.Len(t, alerts, 2)
+	assert.Equal(t, 100, alerts[0].Threshold)
+	assert.Equal(t, 80, alerts[1].Threshold)
+}
@@ -0,0 +1,36 @@
+package budget_item_backfill
+
+import (
+	"time"
+
+	"github.com/klokku/klokku/pkg/calendar"
+)
+
+// DefaultLookback is how far back events are scanned when no start is given.
+const DefaultLookback = 28 * 24 * time.Hour
+
+// Criteria selects the events proposed for reassignment to a new budget item.
+type Criteria struct {
+	// Keywords are matched case-insensitively against the event summary and notes.
+	// The item name is used when no keywords are given.
+	Keywords []string
+	// Since is the start of the scanned period, DefaultLookback before now when zero.
+	Since time.Time
+}
+
+// Proposal is an event that matches the criteria and is not assigned to the budget item yet.
+type Proposal struct {
+	Event calendar.Event
+	// CurrentItemName is the name of the item the event is assigned to, empty for unclassified events.
+	CurrentItemName string
+	// Unclassified is set for events assigned to an item that is not part of the plan.
+	Unclassified   bool
+	MatchedKeyword string
+}
+
+// BackfillResult reports the outcome of applying the reassignments.
+type BackfillResult struct {
+	Reassigned []calendar.Event
+	// Skipped lists the requested event UIDs that are no longer proposed, e.g. because they were changed meanwhile.
+	Skipped []string
+}
@@ -0,0 +1,190 @@
+package budget_item_backfill
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+)
+
+type ProposalDTO struct {
+	EventUID     string    `json:"eventUid"`
+	Summary      string    `json:"summary"`
+	StartTime    time.Time `json:"start"`
+	EndTime      time.Time `json:"end"`
+	BudgetItemId int       `json:"budgetItemId"`
+	// CurrentItemName is empty for unclassified events
+	CurrentItemName string `json:"currentItemName,omitempty"`
+	Unclassified    bool   `json:"unclassified"`
+	MatchedKeyword  string `json:"matchedKeyword"`
+}
+
+type ApplyRequestDTO struct {
+	EventUIDs []string `json:"eventUids"`
+	// Keywords and Since must be the same as when the proposals were requested
+	Keywords []string   `json:"keywords,omitempty"`
+	Since    *time.Time `json:"since,omitempty"`
+}
+
+type BackfillResultDTO struct {
+	Reassigned []string `json:"reassigned"`
+	Skipped    []string `json:"skipped"`
+}
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// GetProposals godoc
+// @Summary Propose events to reassign to a budget item
+// @Description Scan recent events matching the keywords (the item name by default) that are assigned to other
+// @Description items or to no item of the plan, to backfill the history of a newly added item.
+// @Tags BudgetPlan
+// @Produce json
+// @Param planId path int true "Budget Plan ID"
+// @Param itemId path int true "Budget Item ID"
+// @Param keywords query string false "Comma separated keywords matched against event summary and notes"
+// @Param since query string false "Start of the scanned period in RFC3339 format, 28 days ago by default"
+// @Success 200 {array} ProposalDTO
+// @Failure 400 {object} rest.ErrorResponse
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Plan or item not found"
+// @Router /api/budgetplan/{planId}/item/{itemId}/backfill [get]
+// @Security XUserId
+func (h *Handler) GetProposals(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	planId, itemId, err := parseIds(r)
+	if err != nil {
+		writeBadRequest(w, "Invalid plan or item ID", "Plan and item ID must be numbers")
+		return
+	}
+	criteria := Criteria{}
+	if keywords := r.URL.Query().Get("keywords"); keywords != "" {
+		criteria.Keywords = strings.Split(keywords, ",")
+	}
+	if since := r.URL.Query().Get("since"); since != "" {
+		criteria.Since, err = time.Parse(time.RFC3339, since)
+		if err != nil {
+			writeBadRequest(w, "Invalid since format", "'since' must be in RFC3339 format")
+			return
+		}
+	}
+
+	proposals, err := h.service.ProposeReassignments(r.Context(), planId, itemId, criteria)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	dtos := make([]ProposalDTO, 0, len(proposals))
+	for _, proposal := range proposals {
+		dtos = append(dtos, ProposalDTO{
+			EventUID:        proposal.Event.UID,
+			Summary:         proposal.Event.Summary,
+			StartTime:       proposal.Event.StartTime,
+			EndTime:         proposal.Event.EndTime,
+			BudgetItemId:    proposal.Event.Metadata.BudgetItemId,
+			CurrentItemName: proposal.CurrentItemName,
+			Unclassified:    proposal.Unclassified,
+			MatchedKeyword:  proposal.MatchedKeyword,
+		})
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// ApplyReassignments godoc
+// @Summary Reassign events to a budget item
+// @Description Assign the selected proposed events to the item in bulk. Events no longer proposed for the
+// @Description same keywords and period are skipped.
+// @Tags BudgetPlan
+// @Accept json
+// @Produce json
+// @Param planId path int true "Budget Plan ID"
+// @Param itemId path int true "Budget Item ID"
+// @Param request body ApplyRequestDTO true "Selected events"
+// @Success 200 {object} BackfillResultDTO
+// @Failure 400 {object} rest.ErrorResponse
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Plan or item not found"
+// @Failure 409 {string} string "An event falls into a locked week"
+// @Router /api/budgetplan/{planId}/item/{itemId}/backfill [post]
+// @Security XUserId
+func (h *Handler) ApplyReassignments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	planId, itemId, err := parseIds(r)
+	if err != nil {
+		writeBadRequest(w, "Invalid plan or item ID", "Plan and item ID must be numbers")
+		return
+	}
+	var request ApplyRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeBadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	criteria := Criteria{Keywords: request.Keywords}
+	if request.Since != nil {
+		criteria.Since = *request.Since
+	}
+
+	result, err := h.service.ApplyReassignments(r.Context(), planId, itemId, criteria, request.EventUIDs)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	dto := BackfillResultDTO{Reassigned: make([]string, 0, len(result.Reassigned)), Skipped: result.Skipped}
+	for _, event := range result.Reassigned {
+		dto.Reassigned = append(dto.Reassigned, event.UID)
+	}
+	if err := json.NewEncoder(w).Encode(dto); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func parseIds(r *http.Request) (int, int, error) {
+	vars := mux.Vars(r)
+	planId, err := strconv.Atoi(vars["planId"])
+	if err != nil {
+		return 0, 0, err
+	}
+	itemId, err := strconv.Atoi(vars["itemId"])
+	if err != nil {
+		return 0, 0, err
+	}
+	return planId, itemId, nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNoEventsSelected):
+		writeBadRequest(w, "No events selected", err.Error())
+	case errors.Is(err, budget_plan.ErrPlanNotFound), errors.Is(err, budget_plan.ErrBudgetPlanItemNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, weekly_plan.ErrWeekLocked), errors.Is(err, calendar.ErrEventOverlap):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeBadRequest(w http.ResponseWriter, errMsg, details string) {
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+		Error:   errMsg,
+		Details: details,
+	})
+}
@@ -0,0 +1,155 @@
+package budget_item_backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+)
+
+var ErrNoEventsSelected = errors.New("no events selected for reassignment")
+
+// Service proposes and applies bulk reassignments of past events to a budget item, so that the history of
+// a newly added item reflects the new categorization.
+type Service interface {
+	// ProposeReassignments returns the events matching the criteria that are assigned to other items or to
+	// no item of the plan.
+	ProposeReassignments(ctx context.Context, planId int, itemId int, criteria Criteria) ([]Proposal, error)
+	// ApplyReassignments assigns the selected events to the item in one transaction. Only events still proposed
+	// for the same criteria are reassigned, the others are reported as skipped.
+	ApplyReassignments(ctx context.Context, planId int, itemId int, criteria Criteria, eventUids []string) (BackfillResult, error)
+}
+
+type budgetPlanService interface {
+	GetPlan(ctx context.Context, planId int) (budget_plan.BudgetPlan, error)
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type calendarService interface {
+	GetEvents(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error)
+	ModifyEvent(ctx context.Context, event calendar.Event) ([]calendar.Event, error)
+}
+
+type ServiceImpl struct {
+	budgetPlanService budgetPlanService
+	calendar          calendarService
+	clock             utils.Clock
+}
+
+func NewService(budgetPlanService budgetPlanService, calendar calendarService, clock utils.Clock) Service {
+	return &ServiceImpl{
+		budgetPlanService: budgetPlanService,
+		calendar:          calendar,
+		clock:             clock,
+	}
+}
+
+func (s *ServiceImpl) ProposeReassignments(ctx context.Context, planId int, itemId int, criteria Criteria) ([]Proposal, error) {
+	plan, err := s.budgetPlanService.GetPlan(ctx, planId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get budget plan: %w", err)
+	}
+	item, found := plan.FindItem(itemId)
+	if !found {
+		return nil, budget_plan.ErrBudgetPlanItemNotFound
+	}
+
+	keywords := normalizeKeywords(criteria.Keywords)
+	if len(keywords) == 0 {
+		keywords = normalizeKeywords([]string{item.Name})
+	}
+	now := s.clock.Now()
+	since := criteria.Since
+	if since.IsZero() {
+		since = now.Add(-DefaultLookback)
+	}
+
+	events, err := s.calendar.GetEvents(ctx, since, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events: %w", err)
+	}
+	proposals := make([]Proposal, 0)
+	for _, event := range events {
+		if event.Metadata.BudgetItemId == itemId || event.Metadata.Sandbox {
+			continue
+		}
+		keyword, matched := matchKeyword(event, keywords)
+		if !matched {
+			continue
+		}
+		proposal := Proposal{Event: event, MatchedKeyword: keyword}
+		if current, found := plan.FindItem(event.Metadata.BudgetItemId); found {
+			proposal.CurrentItemName = current.Name
+		} else {
+			proposal.Unclassified = true
+		}
+		proposals = append(proposals, proposal)
+	}
+	return proposals, nil
+}
+
+func (s *ServiceImpl) ApplyReassignments(ctx context.Context, planId int, itemId int, criteria Criteria, eventUids []string) (BackfillResult, error) {
+	if len(eventUids) == 0 {
+		return BackfillResult{}, ErrNoEventsSelected
+	}
+	proposals, err := s.ProposeReassignments(ctx, planId, itemId, criteria)
+	if err != nil {
+		return BackfillResult{}, err
+	}
+	proposedByUid := make(map[string]calendar.Event, len(proposals))
+	for _, proposal := range proposals {
+		proposedByUid[proposal.Event.UID] = proposal.Event
+	}
+
+	result := BackfillResult{Reassigned: make([]calendar.Event, 0, len(eventUids)), Skipped: make([]string, 0)}
+	// Either all selected events are reassigned or none of them
+	err = s.budgetPlanService.WithTransaction(ctx, func(ctx context.Context) error {
+		for _, uid := range eventUids {
+			event, proposed := proposedByUid[uid]
+			if !proposed {
+				result.Skipped = append(result.Skipped, uid)
+				continue
+			}
+			event.Metadata.BudgetItemId = itemId
+			updated, err := s.calendar.ModifyEvent(ctx, event)
+			if err != nil {
+				return fmt.Errorf("failed to reassign event %s: %w", uid, err)
+			}
+			result.Reassigned = append(result.Reassigned, updated...)
+			delete(proposedByUid, uid)
+		}
+		return nil
+	})
+	if err != nil {
+		return BackfillResult{}, err
+	}
+	return result, nil
+}
+
+func normalizeKeywords(keywords []string) []string {
+	result := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword != "" {
+			result = append(result, keyword)
+		}
+	}
+	return result
+}
+
+// matchKeyword returns the first keyword contained in the event summary or notes.
+func matchKeyword(event calendar.Event, keywords []string) (string, bool) {
+	summary := strings.ToLower(event.Summary)
+	notes := strings.ToLower(event.Metadata.Notes)
+	for _, keyword := range keywords {
+		if strings.Contains(summary, keyword) || strings.Contains(notes, keyword) {
+			return keyword, true
+		}
+	}
+	return "", false
+}
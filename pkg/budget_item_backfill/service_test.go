@@ -0,0 +1,112 @@
+package budget_item_backfill
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC)
+
+type budgetPlanServiceStub struct {
+	plan budget_plan.BudgetPlan
+}
+
+func (s *budgetPlanServiceStub) GetPlan(_ context.Context, planId int) (budget_plan.BudgetPlan, error) {
+	if planId != s.plan.Id {
+		return budget_plan.BudgetPlan{}, budget_plan.ErrPlanNotFound
+	}
+	return s.plan, nil
+}
+
+func (s *budgetPlanServiceStub) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func setup(t *testing.T) (Service, *calendar.StubCalendar, context.Context) {
+	t.Helper()
+	plan := budget_plan.BudgetPlan{
+		Id: 1,
+		Items: []budget_plan.BudgetItem{
+			{Id: 10, PlanId: 1, Name: "Work"},
+			{Id: 20, PlanId: 1, Name: "Reading"},
+		},
+	}
+	cal := calendar.NewStubCalendar()
+	service := NewService(&budgetPlanServiceStub{plan: plan}, cal, &utils.MockClock{FixedNow: now})
+	ctx := user.WithUser(context.Background(), user.User{Id: 1})
+	return service, cal, ctx
+}
+
+func addEvent(t *testing.T, ctx context.Context, cal *calendar.StubCalendar, summary string, budgetItemId int, start time.Time) calendar.Event {
+	t.Helper()
+	events, err := cal.AddEvent(ctx, calendar.Event{
+		Summary:   summary,
+		StartTime: start,
+		EndTime:   start.Add(time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: budgetItemId},
+	})
+	require.NoError(t, err)
+	return events[0]
+}
+
+func TestServiceImpl_ProposeReassignments(t *testing.T) {
+	service, cal, ctx := setup(t)
+	otherItem := addEvent(t, ctx, cal, "Work: reading papers", 10, now.Add(-48*time.Hour))
+	unclassified := addEvent(t, ctx, cal, "Reading", 99, now.Add(-24*time.Hour))
+	addEvent(t, ctx, cal, "Reading", 20, now.Add(-6*time.Hour))                  // already assigned
+	addEvent(t, ctx, cal, "Work", 10, now.Add(-5*time.Hour))                     // no match
+	addEvent(t, ctx, cal, "Reading", 10, now.Add(-DefaultLookback-24*time.Hour)) // too old
+
+	proposals, err := service.ProposeReassignments(ctx, 1, 20, Criteria{})
+
+	require.NoError(t, err)
+	require.Len(t, proposals, 2)
+	assert.Equal(t, otherItem.UID, proposals[0].Event.UID)
+	assert.Equal(t, "Work", proposals[0].CurrentItemName)
+	assert.False(t, proposals[0].Unclassified)
+	assert.Equal(t, "reading", proposals[0].MatchedKeyword)
+	assert.Equal(t, unclassified.UID, proposals[1].Event.UID)
+	assert.True(t, proposals[1].Unclassified)
+
+	proposals, err = service.ProposeReassignments(ctx, 1, 20, Criteria{Keywords: []string{" PAPERS "}})
+	require.NoError(t, err)
+	require.Len(t, proposals, 1)
+	assert.Equal(t, otherItem.UID, proposals[0].Event.UID)
+
+	_, err = service.ProposeReassignments(ctx, 1, 30, Criteria{})
+	assert.ErrorIs(t, err, budget_plan.ErrBudgetPlanItemNotFound)
+}
+
+func TestServiceImpl_ApplyReassignments(t *testing.T) {
+	service, cal, ctx := setup(t)
+	proposed := addEvent(t, ctx, cal, "Reading", 99, now.Add(-24*time.Hour))
+	notProposed := addEvent(t, ctx, cal, "Work", 10, now.Add(-5*time.Hour))
+
+	result, err := service.ApplyReassignments(ctx, 1, 20, Criteria{}, []string{proposed.UID, notProposed.UID})
+
+	require.NoError(t, err)
+	require.Len(t, result.Reassigned, 1)
+	assert.Equal(t, proposed.UID, result.Reassigned[0].UID)
+	assert.Equal(t, []string{notProposed.UID}, result.Skipped)
+	events, err := cal.GetEvents(ctx, now.Add(-DefaultLookback), now)
+	require.NoError(t, err)
+	for _, event := range events {
+		if event.UID == proposed.UID {
+			assert.Equal(t, 20, event.Metadata.BudgetItemId)
+		}
+		if event.UID == notProposed.UID {
+			assert.Equal(t, 10, event.Metadata.BudgetItemId)
+		}
+	}
+
+	_, err = service.ApplyReassignments(ctx, 1, 20, Criteria{}, nil)
+	assert.ErrorIs(t, err, ErrNoEventsSelected)
+}
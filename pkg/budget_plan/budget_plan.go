@@ -1,12 +1,77 @@
 package budget_plan
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type BudgetPlan struct {
-	Id        int
-	Name      string
-	IsCurrent bool
-	Items     []BudgetItem
+	Id         int
+	Name       string
+	IsCurrent  bool
+	Items      []BudgetItem
+	Categories []Category
+}
+
+// Category groups budget items of a plan, e.g. "Health" or "Work".
+type Category struct {
+	Id       int
+	PlanId   int
+	Name     string
+	Color    string
+	Position int
+}
+
+// FindCategory returns the category with the given id, if it belongs to the plan.
+func (p BudgetPlan) FindCategory(categoryId int) (Category, bool) {
+	for _, category := range p.Categories {
+		if category.Id == categoryId {
+			return category, true
+		}
+	}
+	return Category{}, false
+}
+
+// ItemCategoryId returns the category id of the plan item with the given budget item id, 0 when the item
+// has no category or is not part of the plan.
+func (p BudgetPlan) ItemCategoryId(budgetItemId int) int {
+	for _, item := range p.Items {
+		if item.Id == budgetItemId {
+			return item.CategoryId
+		}
+	}
+	return 0
+}
+
+// FindItem returns the item with the given id, if it belongs to the plan.
+func (p BudgetPlan) FindItem(itemId int) (BudgetItem, bool) {
+	for _, item := range p.Items {
+		if item.Id == itemId {
+			return item, true
+		}
+	}
+	return BudgetItem{}, false
+}
+
+// Children returns sub-items of the item with the given id, in plan order.
+func (p BudgetPlan) Children(itemId int) []BudgetItem {
+	var children []BudgetItem
+	for _, item := range p.Items {
+		if item.ParentId == itemId {
+			children = append(children, item)
+		}
+	}
+	return children
+}
+
+// HasChildren reports whether any item of the plan is a sub-item of the item with the given id.
+func (p BudgetPlan) HasChildren(itemId int) bool {
+	for _, item := range p.Items {
+		if item.ParentId == itemId {
+			return true
+		}
+	}
+	return false
 }
 
 type BudgetItem struct {
@@ -17,7 +82,153 @@ type BudgetItem struct {
 	WeeklyDuration time.Duration
 	// WeeklyOccurrences represents the number of days in a week that a budget is expected to be used.
 	WeeklyOccurrences int
-	Icon              string
-	Color             string
-	Position          int
+	// DailyDurations optionally describes how the weekly duration should be distributed across weekdays
+	// (e.g. 8h from Monday to Friday). Weekdays without an entry have no daily target.
+	DailyDurations map[time.Weekday]time.Duration
+	Icon           string
+	Color          string
+	Position       int
+	// CategoryId is the id of the plan category the item belongs to, 0 when uncategorized.
+	CategoryId int
+	// ParentId is the id of the item this item is a sub-item of, 0 for top-level items.
+	// Only one level of nesting is allowed. The weekly duration of an item with sub-items is the sum
+	// of its sub-items' durations.
+	ParentId int
+	// StartDate and EndDate optionally limit the dates (inclusive) the item is active on, e.g. for a course
+	// running a few weeks. Only the date part is meaningful, nil means unbounded.
+	StartDate *time.Time
+	EndDate   *time.Time
+	// Rollover opts the item into carrying unused (or overspent) time of a week over to the next week's plan.
+	Rollover bool
+	// Unit is how the item is planned and tracked, by time spent (default) or by the number of sessions.
+	Unit ItemUnit
+	// HourlyRate is the rate the item's tracked time is billed at, in cents of the user's currency per hour,
+	// 0 for items that are not billed.
+	HourlyRate int
+}
+
+// ItemUnit describes how progress of a budget item is measured.
+type ItemUnit string
+
+const (
+	// UnitDuration items are planned in hours, WeeklyDuration is their target. It is the default unit.
+	UnitDuration ItemUnit = "duration"
+	// UnitSessions items are planned in sessions (e.g. habits where doing it matters more than how long),
+	// WeeklyOccurrences is the number of sessions planned for a week. Each tracked event is one session.
+	UnitSessions ItemUnit = "sessions"
+)
+
+// IsSessionBased reports whether the item is planned in sessions instead of time.
+func (i BudgetItem) IsSessionBased() bool {
+	return i.Unit == UnitSessions
+}
+
+// IsActiveBetween reports whether any day from the from-to range (inclusive) falls within the item's
+// StartDate-EndDate window.
+func (i BudgetItem) IsActiveBetween(from, to time.Time) bool {
+	if i.StartDate != nil && dateOf(*i.StartDate).After(dateOf(to)) {
+		return false
+	}
+	if i.EndDate != nil && dateOf(*i.EndDate).Before(dateOf(from)) {
+		return false
+	}
+	return true
+}
+
+// dateOf returns the calendar date of t (in t's location) as midnight UTC, so dates can be compared
+// regardless of the location they were created in.
+func dateOf(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// PlanActivation schedules a plan to become the current plan starting from a given week.
+type PlanActivation struct {
+	Id     int
+	UserId int
+	PlanId int
+	// EffectiveFrom is the start of the week (in the user's timezone) from which the plan is current.
+	EffectiveFrom time.Time
+	// ActivatedAt is set once the plan switcher made the plan current.
+	ActivatedAt *time.Time
+}
+
+type RevisionType string
+
+const (
+	RevisionItemAdded       RevisionType = "item_added"
+	RevisionItemRemoved     RevisionType = "item_removed"
+	RevisionDurationChanged RevisionType = "duration_changed"
+)
+
+// Revision records a single change of a plan's items. Revisions are kept after the item is removed,
+// so ItemName holds the name the item had at the time of the change.
+type Revision struct {
+	Id           int
+	PlanId       int
+	BudgetItemId int
+	ItemName     string
+	Type         RevisionType
+	// PreviousWeeklyDuration is set only for RevisionDurationChanged.
+	PreviousWeeklyDuration time.Duration
+	WeeklyDuration         time.Duration
+	ChangedAt              time.Time
+}
+
+// Description renders the revision as a human-readable changelog line.
+func (r Revision) Description() string {
+	switch r.Type {
+	case RevisionItemAdded:
+		return fmt.Sprintf("Added %s (%s per week)", r.ItemName, formatDuration(r.WeeklyDuration))
+	case RevisionItemRemoved:
+		return fmt.Sprintf("Removed %s (%s per week)", r.ItemName, formatDuration(r.WeeklyDuration))
+	case RevisionDurationChanged:
+		return fmt.Sprintf("Changed %s from %s to %s per week",
+			r.ItemName, formatDuration(r.PreviousWeeklyDuration), formatDuration(r.WeeklyDuration))
+	}
+	return fmt.Sprintf("Changed %s", r.ItemName)
+}
+
+// formatDuration formats the duration as hours and minutes, e.g. "1h30m" or "45m".
+func formatDuration(d time.Duration) string {
+	hours := int(d / time.Hour)
+	minutes := int((d % time.Hour) / time.Minute)
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dh%dm", hours, minutes)
+}
+
+// DailyDurationsToSeconds converts daily durations to a slice of seconds indexed by time.Weekday,
+// as stored in the database. It returns nil when there are no daily durations.
+func DailyDurationsToSeconds(dailyDurations map[time.Weekday]time.Duration) []int32 {
+	if len(dailyDurations) == 0 {
+		return nil
+	}
+	seconds := make([]int32, 7)
+	for weekday, duration := range dailyDurations {
+		if weekday < time.Sunday || weekday > time.Saturday {
+			continue
+		}
+		seconds[weekday] = int32(duration.Seconds())
+	}
+	return seconds
+}
+
+// DailyDurationsFromSeconds is the inverse of DailyDurationsToSeconds. Weekdays with no time are omitted.
+func DailyDurationsFromSeconds(seconds []int32) map[time.Weekday]time.Duration {
+	var dailyDurations map[time.Weekday]time.Duration
+	for idx, sec := range seconds {
+		if idx > int(time.Saturday) || sec <= 0 {
+			continue
+		}
+		if dailyDurations == nil {
+			dailyDurations = make(map[time.Weekday]time.Duration)
+		}
+		dailyDurations[time.Weekday(idx)] = time.Duration(sec) * time.Second
+	}
+	return dailyDurations
 }
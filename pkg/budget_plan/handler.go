@@ -3,19 +3,40 @@ package budget_plan
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"maps"
 	"net/http"
-	"strconv"
+	"slices"
+	"strings"
 	"time"
 
-	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/internal/rest"
 	log "github.com/sirupsen/logrus"
 )
 
 type BudgetPlanDTO struct {
-	Id        int       `json:"id"`
-	Name      string    `json:"name"`
-	IsCurrent bool      `json:"isCurrent"`
-	Items     []ItemDTO `json:"items,omitempty"`
+	Id         int           `json:"id"`
+	Name       string        `json:"name"`
+	IsCurrent  bool          `json:"isCurrent"`
+	Items      []ItemDTO     `json:"items,omitempty"`
+	Categories []CategoryDTO `json:"categories,omitempty"`
+}
+
+type CategoryDTO struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	Color    string `json:"color,omitempty"`
+	Position int    `json:"position"`
+}
+
+type ChangelogEntryDTO struct {
+	Date                      time.Time `json:"date"`
+	Type                      string    `json:"type"`
+	Description               string    `json:"description"`
+	BudgetItemId              int       `json:"budgetItemId"`
+	ItemName                  string    `json:"itemName"`
+	PreviousWeeklyDurationSec int       `json:"previousWeeklyDurationSec,omitempty"`
+	WeeklyDurationSec         int       `json:"weeklyDurationSec"`
 }
 
 type ItemDTO struct {
@@ -23,8 +44,48 @@ type ItemDTO struct {
 	Name              string `json:"name"`
 	WeeklyDuration    int    `json:"weeklyDuration"`
 	WeeklyOccurrences int    `json:"weeklyOccurrences,omitempty"`
-	Icon              string `json:"icon,omitempty"`
-	Color             string `json:"color,omitempty"`
+	// DailyDurations maps lowercase weekday names (e.g. "monday") to the daily target in seconds.
+	DailyDurations map[string]int `json:"dailyDurations,omitempty"`
+	Icon           string         `json:"icon,omitempty"`
+	Color          string         `json:"color,omitempty"`
+	// CategoryId is the id of one of the plan's categories, omitted for uncategorized items.
+	CategoryId int `json:"categoryId,omitempty"`
+	// ParentId is the id of the item this item is a sub-item of, omitted for top-level items.
+	// The weeklyDuration of an item with sub-items is the sum of their durations and cannot be set directly.
+	ParentId int `json:"parentId,omitempty"`
+	// StartDate and EndDate optionally limit the dates (inclusive) the item is active on.
+	// Outside of this window the item is not added to weekly plans.
+	StartDate *time.Time `json:"startDate,omitempty"`
+	EndDate   *time.Time `json:"endDate,omitempty"`
+	// Rollover carries unused (or overspent) time of a week over to the next week's plan.
+	Rollover bool `json:"rollover,omitempty"`
+	// Unit is "duration" (default) or "sessions". Items planned in sessions use weeklyOccurrences as
+	// the number of sessions per week.
+	Unit string `json:"unit,omitempty" enums:"duration,sessions"`
+	// HourlyRate is the rate the item's tracked time is billed at in monthly statements, in cents per hour,
+	// omitted for items that are not billed.
+	HourlyRate int `json:"hourlyRate,omitempty"`
+}
+
+type ItemStyleSuggestionDTO struct {
+	// Color and Icon are the first palette values not used by the plan's items yet
+	Color string `json:"color"`
+	Icon  string `json:"icon"`
+	// Colors and Icons are the curated palettes to choose from
+	Colors []string `json:"colors"`
+	Icons  []string `json:"icons"`
+}
+
+type PlanActivationDTO struct {
+	Id     int `json:"id"`
+	PlanId int `json:"planId"`
+	// EffectiveFrom is the start of the week from which the plan becomes current
+	EffectiveFrom time.Time `json:"effectiveFrom"`
+}
+
+type SchedulePlanActivationDTO struct {
+	// WeekDate is any date within the week from which the plan should become current
+	WeekDate time.Time `json:"weekDate"`
 }
 
 type Handler struct {
@@ -49,7 +110,7 @@ func (handler *Handler) ListPlans(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	plans, err := handler.service.ListPlans(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 
@@ -59,7 +120,7 @@ func (handler *Handler) ListPlans(w http.ResponseWriter, r *http.Request) {
 	}
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(plansDTO); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 }
@@ -72,7 +133,7 @@ func (handler *Handler) ListPlans(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param plan body BudgetPlanDTO true "Budget Plan"
 // @Success 201 {object} BudgetPlanDTO
-// @Failure 400 {string} string "Bad Request"
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
 // @Failure 403 {string} string "User not found"
 // @Router /api/budgetplan [post]
 // @Security XUserId
@@ -80,21 +141,21 @@ func (handler *Handler) CreatePlan(w http.ResponseWriter, r *http.Request) {
 	log.Debug("Creating new budget plan")
 	w.Header().Set("Content-Type", "application/json")
 	var planDTO BudgetPlanDTO
-	if err := json.NewDecoder(r.Body).Decode(&planDTO); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := rest.DecodeJSON(r, &planDTO); err != nil {
+		rest.WriteBadRequest(w, err)
 		return
 	}
 
 	plan, err := handler.service.CreatePlan(r.Context(), DTOToPlan(planDTO))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 
 	planDTO = PlanToDTO(plan)
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(planDTO); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 }
@@ -108,44 +169,42 @@ func (handler *Handler) CreatePlan(w http.ResponseWriter, r *http.Request) {
 // @Param planId path int true "Budget Plan ID"
 // @Param plan body BudgetPlanDTO true "Budget Plan"
 // @Success 200 {object} BudgetPlanDTO
-// @Failure 400 {string} string "Bad Request"
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
 // @Failure 403 {string} string "User not found"
-// @Failure 404 {string} string "Plan Not Found"
+// @Failure 404 {object} rest.ErrorResponse "Plan Not Found"
 // @Router /api/budgetplan/{planId} [put]
 // @Security XUserId
 func (handler *Handler) UpdatePlan(w http.ResponseWriter, r *http.Request) {
 	log.Debug("Updating budget plan")
 	w.Header().Set("Content-Type", "application/json")
-	vars := mux.Vars(r)
-	planIdString := vars["planId"]
-	planId, err := strconv.Atoi(planIdString)
+	planId, err := rest.PathInt(r, "planId")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		rest.WriteBadRequest(w, err)
 		return
 	}
 	var planDTO BudgetPlanDTO
-	if err := json.NewDecoder(r.Body).Decode(&planDTO); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := rest.DecodeJSON(r, &planDTO); err != nil {
+		rest.WriteBadRequest(w, err)
 		return
 	}
-	if planDTO.Id == 0 || planDTO.Id != planId {
-		http.Error(w, "Invalid plan id in request body", http.StatusBadRequest)
+	if err := validatePlanDTO(planDTO, planId); err != nil {
+		rest.WriteBadRequest(w, err)
 		return
 	}
 	plan := DTOToPlan(planDTO)
 	updatedPlan, err := handler.service.UpdatePlan(r.Context(), plan)
 	if err != nil {
 		if errors.Is(err, ErrPlanNotFound) {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			rest.WriteNotFound(w, err)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 	updatedPlanDTO := PlanToDTO(updatedPlan)
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(updatedPlanDTO); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 }
@@ -156,28 +215,316 @@ func (handler *Handler) UpdatePlan(w http.ResponseWriter, r *http.Request) {
 // @Tags BudgetPlan
 // @Param planId path int true "Budget Plan ID"
 // @Success 204 "No Content"
-// @Failure 400 {string} string "Bad Request"
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
 // @Failure 403 {string} string "User not found"
-// @Failure 404 {string} string "Plan Not Found"
+// @Failure 404 {object} rest.ErrorResponse "Plan Not Found"
 // @Router /api/budgetplan/{planId} [delete]
 // @Security XUserId
 func (handler *Handler) DeletePlan(w http.ResponseWriter, r *http.Request) {
 	log.Debug("Deleting budget plan")
 	w.Header().Set("Content-Type", "application/json")
-	vars := mux.Vars(r)
-	planIdString := vars["planId"]
-	planId, err := strconv.Atoi(planIdString)
+	planId, err := rest.PathInt(r, "planId")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		rest.WriteBadRequest(w, err)
 		return
 	}
 	deleted, err := handler.service.DeletePlan(r.Context(), planId)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 	if !deleted {
-		http.Error(w, "plan not found", http.StatusNotFound)
+		rest.WriteError(w, http.StatusNotFound, rest.ErrorResponse{Error: "Plan not found"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListPlanActivations godoc
+// @Summary List scheduled plan activations
+// @Description Get plan activations scheduled for future weeks that were not applied yet
+// @Tags BudgetPlan
+// @Produce json
+// @Success 200 {array} PlanActivationDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/budgetplan/activation [get]
+// @Security XUserId
+func (handler *Handler) ListPlanActivations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	activations, err := handler.service.GetPendingPlanActivations(r.Context())
+	if err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+
+	activationsDTO := make([]PlanActivationDTO, 0, len(activations))
+	for _, activation := range activations {
+		activationsDTO = append(activationsDTO, planActivationToDTO(activation))
+	}
+	if err := json.NewEncoder(w).Encode(activationsDTO); err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+}
+
+// SchedulePlanActivation godoc
+// @Summary Schedule a plan activation
+// @Description Make the plan current starting from the first day of the given week. The plan is switched
+// @Description in the background when the week starts. Scheduling another plan for the same week replaces it.
+// @Tags BudgetPlan
+// @Accept json
+// @Produce json
+// @Param planId path int true "Budget Plan ID"
+// @Param activation body SchedulePlanActivationDTO true "Week to activate the plan from"
+// @Success 201 {object} PlanActivationDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {object} rest.ErrorResponse "Plan Not Found"
+// @Router /api/budgetplan/{planId}/activation [post]
+// @Security XUserId
+func (handler *Handler) SchedulePlanActivation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	planId, err := rest.PathInt(r, "planId")
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	var activationDTO SchedulePlanActivationDTO
+	if err := rest.DecodeJSON(r, &activationDTO); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	if activationDTO.WeekDate.IsZero() {
+		rest.WriteBadRequest(w, rest.InvalidField("weekDate", "Invalid week date", "'weekDate' is required"))
+		return
+	}
+
+	activation, err := handler.service.SchedulePlanActivation(r.Context(), planId, activationDTO.WeekDate)
+	if err != nil {
+		if errors.Is(err, ErrActivationNotInFuture) {
+			rest.WriteBadRequest(w, rest.InvalidField("weekDate", "Invalid week date", err.Error()))
+			return
+		}
+		if errors.Is(err, ErrPlanNotFound) {
+			rest.WriteNotFound(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(planActivationToDTO(activation)); err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+}
+
+// CancelPlanActivation godoc
+// @Summary Cancel a scheduled plan activation
+// @Description Remove a plan activation that was not applied yet
+// @Tags BudgetPlan
+// @Param activationId path int true "Plan Activation ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {object} rest.ErrorResponse "Activation Not Found"
+// @Router /api/budgetplan/activation/{activationId} [delete]
+// @Security XUserId
+func (handler *Handler) CancelPlanActivation(w http.ResponseWriter, r *http.Request) {
+	activationId, err := rest.PathInt(r, "activationId")
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	if err := handler.service.CancelPlanActivation(r.Context(), activationId); err != nil {
+		if errors.Is(err, ErrPlanActivationNotFound) {
+			rest.WriteNotFound(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func planActivationToDTO(activation PlanActivation) PlanActivationDTO {
+	return PlanActivationDTO{
+		Id:            activation.Id,
+		PlanId:        activation.PlanId,
+		EffectiveFrom: activation.EffectiveFrom,
+	}
+}
+
+// ListCategories godoc
+// @Summary List plan categories
+// @Description Get categories of a budget plan ordered by position
+// @Tags BudgetCategory
+// @Produce json
+// @Param planId path int true "Budget Plan ID"
+// @Success 200 {array} CategoryDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Router /api/budgetplan/{planId}/category [get]
+// @Security XUserId
+func (handler *Handler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	planId, err := rest.PathInt(r, "planId")
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	categories, err := handler.service.GetCategories(r.Context(), planId)
+	if err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+
+	categoriesDTO := make([]CategoryDTO, 0, len(categories))
+	for _, category := range categories {
+		categoriesDTO = append(categoriesDTO, CategoryToDTO(category))
+	}
+	if err := json.NewEncoder(w).Encode(categoriesDTO); err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+}
+
+// CreateCategory godoc
+// @Summary Create a plan category
+// @Description Create a category grouping budget items of the plan. New categories are added at the end.
+// @Tags BudgetCategory
+// @Accept json
+// @Produce json
+// @Param planId path int true "Budget Plan ID"
+// @Param category body CategoryDTO true "Category"
+// @Success 201 {object} CategoryDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {object} rest.ErrorResponse "Plan Not Found"
+// @Router /api/budgetplan/{planId}/category [post]
+// @Security XUserId
+func (handler *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	planId, err := rest.PathInt(r, "planId")
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	var categoryDTO CategoryDTO
+	if err := rest.DecodeJSON(r, &categoryDTO); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+
+	category, err := handler.service.CreateCategory(r.Context(), Category{
+		PlanId: planId,
+		Name:   categoryDTO.Name,
+		Color:  categoryDTO.Color,
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvalidCategory) {
+			rest.WriteBadRequest(w, rest.InvalidField("name", "Invalid category name", err.Error()))
+			return
+		}
+		if errors.Is(err, ErrPlanNotFound) {
+			rest.WriteNotFound(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(CategoryToDTO(category)); err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+}
+
+// UpdateCategory godoc
+// @Summary Update a plan category
+// @Description Change the name and color of a category
+// @Tags BudgetCategory
+// @Accept json
+// @Produce json
+// @Param planId path int true "Budget Plan ID"
+// @Param categoryId path int true "Category ID"
+// @Param category body CategoryDTO true "Category"
+// @Success 200 {object} CategoryDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {object} rest.ErrorResponse "Category Not Found"
+// @Router /api/budgetplan/{planId}/category/{categoryId} [put]
+// @Security XUserId
+func (handler *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	planId, err := rest.PathInt(r, "planId")
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	categoryId, err := rest.PathInt(r, "categoryId")
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	var categoryDTO CategoryDTO
+	if err := rest.DecodeJSON(r, &categoryDTO); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+
+	category, err := handler.service.UpdateCategory(r.Context(), Category{
+		Id:     categoryId,
+		PlanId: planId,
+		Name:   categoryDTO.Name,
+		Color:  categoryDTO.Color,
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvalidCategory) {
+			rest.WriteBadRequest(w, rest.InvalidField("name", "Invalid category name", err.Error()))
+			return
+		}
+		if errors.Is(err, ErrCategoryNotFound) {
+			rest.WriteNotFound(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(CategoryToDTO(category)); err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+}
+
+// DeleteCategory godoc
+// @Summary Delete a plan category
+// @Description Remove a category. Items assigned to it become uncategorized.
+// @Tags BudgetCategory
+// @Param planId path int true "Budget Plan ID"
+// @Param categoryId path int true "Category ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {object} rest.ErrorResponse "Category Not Found"
+// @Router /api/budgetplan/{planId}/category/{categoryId} [delete]
+// @Security XUserId
+func (handler *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	categoryId, err := rest.PathInt(r, "categoryId")
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	deleted, err := handler.service.DeleteCategory(r.Context(), categoryId)
+	if err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+	if !deleted {
+		rest.WriteError(w, http.StatusNotFound, rest.ErrorResponse{Error: "Category not found"})
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -192,7 +539,7 @@ func (handler *Handler) DeletePlan(w http.ResponseWriter, r *http.Request) {
 // @Param planId path int true "Budget Plan ID"
 // @Param item body ItemDTO true "Budget Item"
 // @Success 201 {object} ItemDTO
-// @Failure 400 {string} string "Bad Request"
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
 // @Failure 403 {string} string "User not found"
 // @Router /api/budgetplan/{planId}/item [post]
 // @Security XUserId
@@ -200,76 +547,168 @@ func (handler *Handler) RegisterItem(w http.ResponseWriter, r *http.Request) {
 	log.Debug("Registering new budget item")
 	w.Header().Set("Content-Type", "application/json")
 
-	vars := mux.Vars(r)
-	planIdString := vars["planId"]
-	planId, err := strconv.Atoi(planIdString)
+	planId, err := rest.PathInt(r, "planId")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		rest.WriteBadRequest(w, err)
 		return
 	}
 
 	var itemDTO ItemDTO
-	if err := json.NewDecoder(r.Body).Decode(&itemDTO); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := rest.DecodeJSON(r, &itemDTO); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	if err := validateItemDTO(itemDTO, 0); err != nil {
+		rest.WriteBadRequest(w, err)
 		return
 	}
 	item := DTOToItem(planId, itemDTO)
 
 	createdItem, err := handler.service.CreateItem(r.Context(), item)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if fieldErr := itemFieldError(err); fieldErr != nil {
+			rest.WriteBadRequest(w, fieldErr)
+			return
+		}
+		rest.WriteInternalError(w, err)
 		return
 	}
 	createdItemDto := ItemToDTO(createdItem)
 
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(createdItemDto); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 }
 
 // GetPlan godoc
 // @Summary Get a budget plan by ID
-// @Description Retrieve a specific budget plan with all its items
+// @Description Retrieve a specific budget plan with all its items.
+// @Description The response has an ETag, a request with a matching If-None-Match header gets 304 Not Modified.
 // @Tags BudgetPlan
 // @Produce json
 // @Param planId path int true "Budget Plan ID"
 // @Success 200 {object} BudgetPlanDTO
-// @Failure 400 {string} string "Bad Request"
+// @Success 304 "Not Modified"
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
 // @Failure 403 {string} string "User not found"
-// @Failure 404 {string} string "Plan Not Found"
+// @Failure 404 {object} rest.ErrorResponse "Plan Not Found"
 // @Router /api/budgetplan/{planId} [get]
 // @Security XUserId
 func (handler *Handler) GetPlan(w http.ResponseWriter, r *http.Request) {
+	planId, err := rest.PathInt(r, "planId")
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+
+	plan, err := handler.service.GetPlan(r.Context(), planId)
+	if err != nil {
+		if errors.Is(err, ErrPlanNotFound) {
+			rest.WriteNotFound(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
+		return
+	}
+
+	rest.WriteJSONWithETag(w, r, PlanToDTO(plan))
+}
+
+// SuggestItemStyle godoc
+// @Summary Suggest a color and icon for a new budget item
+// @Description Suggest the first palette color and icon not used by the items of the plan yet, the least used ones
+// @Description when the whole palette is used. The curated palettes are returned as well.
+// @Tags BudgetItem
+// @Produce json
+// @Param planId path int true "Budget Plan ID"
+// @Success 200 {object} ItemStyleSuggestionDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {object} rest.ErrorResponse "Plan Not Found"
+// @Router /api/budgetplan/{planId}/item/style-suggestion [get]
+// @Security XUserId
+func (handler *Handler) SuggestItemStyle(w http.ResponseWriter, r *http.Request) {
+	planId, err := rest.PathInt(r, "planId")
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+
+	style, err := handler.service.SuggestItemStyle(r.Context(), planId)
+	if err != nil {
+		if errors.Is(err, ErrPlanNotFound) {
+			rest.WriteNotFound(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ItemStyleSuggestionDTO{
+		Color:  style.Color,
+		Icon:   style.Icon,
+		Colors: Colors,
+		Icons:  Icons,
+	}); err != nil {
+		rest.WriteInternalError(w, err)
+	}
+}
 
-	vars := mux.Vars(r)
-	planIdString := vars["planId"]
-	planId, err := strconv.Atoi(planIdString)
+// GetChangelog godoc
+// @Summary Get the changelog of a budget plan
+// @Description Get the history of item changes (added, removed, weekly duration changed) of a budget plan, oldest first
+// @Tags BudgetPlan
+// @Produce json
+// @Param planId path int true "Budget Plan ID"
+// @Success 200 {array} ChangelogEntryDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {object} rest.ErrorResponse "Plan Not Found"
+// @Router /api/budgetplan/{planId}/changelog [get]
+// @Security XUserId
+func (handler *Handler) GetChangelog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	planId, err := rest.PathInt(r, "planId")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		rest.WriteBadRequest(w, err)
 		return
 	}
 
-	plan, err := handler.service.GetPlan(r.Context(), planId)
+	revisions, err := handler.service.GetChangelog(r.Context(), planId)
 	if err != nil {
 		if errors.Is(err, ErrPlanNotFound) {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			rest.WriteNotFound(w, err)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 
-	planDto := PlanToDTO(plan)
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(planDto); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	changelog := make([]ChangelogEntryDTO, 0, len(revisions))
+	for _, revision := range revisions {
+		changelog = append(changelog, RevisionToDTO(revision))
+	}
+	if err := json.NewEncoder(w).Encode(changelog); err != nil {
+		rest.WriteInternalError(w, err)
 		return
 	}
 }
 
+func RevisionToDTO(revision Revision) ChangelogEntryDTO {
+	return ChangelogEntryDTO{
+		Date:                      revision.ChangedAt,
+		Type:                      string(revision.Type),
+		Description:               revision.Description(),
+		BudgetItemId:              revision.BudgetItemId,
+		ItemName:                  revision.ItemName,
+		PreviousWeeklyDurationSec: int(revision.PreviousWeeklyDuration.Seconds()),
+		WeeklyDurationSec:         int(revision.WeeklyDuration.Seconds()),
+	}
+}
+
 // UpdateItem godoc
 // @Summary Update a budget item
 // @Description Update an existing budget item within a plan
@@ -280,46 +719,50 @@ func (handler *Handler) GetPlan(w http.ResponseWriter, r *http.Request) {
 // @Param itemId path int true "Budget Item ID"
 // @Param item body ItemDTO true "Budget Item"
 // @Success 200 {object} ItemDTO
-// @Failure 400 {string} string "Bad Request"
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
 // @Failure 403 {string} string "User not found"
 // @Router /api/budgetplan/{planId}/item/{itemId} [put]
 // @Security XUserId
 func (handler *Handler) UpdateItem(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	vars := mux.Vars(r)
-	planIdString := vars["planId"]
-	planId, err := strconv.Atoi(planIdString)
+	planId, err := rest.PathInt(r, "planId")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		rest.WriteBadRequest(w, err)
 		return
 	}
-	itemIdString := vars["itemId"]
-	itemId, err := strconv.Atoi(itemIdString)
+	itemId, err := rest.PathInt(r, "itemId")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		rest.WriteBadRequest(w, err)
 		return
 	}
 	var itemDTO ItemDTO
-	if err := json.NewDecoder(r.Body).Decode(&itemDTO); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := rest.DecodeJSON(r, &itemDTO); err != nil {
+		rest.WriteBadRequest(w, err)
 		return
 	}
-	if itemDTO.ID == 0 || itemDTO.ID != itemId {
-		http.Error(w, "Invalid item id in request body", http.StatusBadRequest)
+	if err := validateItemDTO(itemDTO, itemId); err != nil {
+		rest.WriteBadRequest(w, err)
 		return
 	}
-
 	item := DTOToItem(planId, itemDTO)
 	updatedItem, err := handler.service.UpdateItem(r.Context(), item)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if fieldErr := itemFieldError(err); fieldErr != nil {
+			rest.WriteBadRequest(w, fieldErr)
+			return
+		}
+		if errors.Is(err, ErrBudgetPlanItemNotFound) {
+			rest.WriteNotFound(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
 		return
 	}
 	updatedItemDTO := ItemToDTO(updatedItem)
 
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(updatedItemDTO); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 }
@@ -331,28 +774,31 @@ func (handler *Handler) UpdateItem(w http.ResponseWriter, r *http.Request) {
 // @Param planId path int true "Budget Plan ID"
 // @Param itemId path int true "Budget Item ID"
 // @Success 204 "No Content"
-// @Failure 400 {string} string "Bad Request"
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
 // @Failure 403 {string} string "User not found"
-// @Failure 404 {string} string "Item Not Found"
+// @Failure 404 {object} rest.ErrorResponse "Item Not Found"
+// @Failure 409 {object} rest.ErrorResponse "Item is tracked by the current event"
 // @Router /api/budgetplan/{planId}/item/{itemId} [delete]
 // @Security XUserId
 func (handler *Handler) DeleteItem(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	vars := mux.Vars(r)
-	itemIdString := vars["itemId"]
-	itemId, err := strconv.Atoi(itemIdString)
+	itemId, err := rest.PathInt(r, "itemId")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		rest.WriteBadRequest(w, err)
 		return
 	}
 
 	ok, err := handler.service.DeleteItem(r.Context(), itemId)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, ErrItemTracked) {
+			rest.WriteConflict(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
 		return
 	}
 	if !ok {
-		http.Error(w, "Item not found", http.StatusNotFound)
+		rest.WriteError(w, http.StatusNotFound, rest.ErrorResponse{Error: "Item not found"})
 		return
 	}
 
@@ -369,23 +815,20 @@ func (handler *Handler) DeleteItem(w http.ResponseWriter, r *http.Request) {
 // @Param itemId path int true "Budget Item ID"
 // @Param position body object{id=int,precedingId=int} true "Position details"
 // @Success 200 "OK"
-// @Failure 400 {string} string "Bad Request"
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
 // @Failure 403 {string} string "User not found"
-// @Failure 404 {string} string "Item Not Found"
+// @Failure 404 {object} rest.ErrorResponse "Item Not Found"
 // @Router /api/budgetplan/{planId}/item/{itemId}/position [put]
 // @Security XUserId
 func (handler *Handler) SetItemPosition(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	planIdString := vars["planId"]
-	planId, err := strconv.Atoi(planIdString)
+	planId, err := rest.PathInt(r, "planId")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		rest.WriteBadRequest(w, err)
 		return
 	}
-	itemIdString := vars["itemId"]
-	itemId, err := strconv.Atoi(itemIdString)
+	itemId, err := rest.PathInt(r, "itemId")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		rest.WriteBadRequest(w, err)
 		return
 	}
 
@@ -393,18 +836,18 @@ func (handler *Handler) SetItemPosition(w http.ResponseWriter, r *http.Request)
 		ID          int `json:"id"`
 		PrecedingId int `json:"precedingId"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&setPositionDTO); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := rest.DecodeJSON(r, &setPositionDTO); err != nil {
+		rest.WriteBadRequest(w, err)
 		return
 	}
 
 	ok, err := handler.service.MoveItemAfter(r.Context(), planId, itemId, setPositionDTO.PrecedingId)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 	if !ok {
-		http.Error(w, "BudgetItem not found", http.StatusNotFound)
+		rest.WriteError(w, http.StatusNotFound, rest.ErrorResponse{Error: "Budget item not found"})
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -415,11 +858,16 @@ func PlanToDTO(plan BudgetPlan) BudgetPlanDTO {
 	for _, item := range plan.Items {
 		itemsDto = append(itemsDto, ItemToDTO(item))
 	}
+	categoriesDto := make([]CategoryDTO, 0, len(plan.Categories))
+	for _, category := range plan.Categories {
+		categoriesDto = append(categoriesDto, CategoryToDTO(category))
+	}
 	return BudgetPlanDTO{
-		Id:        plan.Id,
-		Name:      plan.Name,
-		Items:     itemsDto,
-		IsCurrent: plan.IsCurrent,
+		Id:         plan.Id,
+		Name:       plan.Name,
+		Items:      itemsDto,
+		Categories: categoriesDto,
+		IsCurrent:  plan.IsCurrent,
 	}
 }
 
@@ -442,8 +890,16 @@ func ItemToDTO(item BudgetItem) ItemDTO {
 		Name:              item.Name,
 		WeeklyDuration:    int(item.WeeklyDuration.Seconds()),
 		WeeklyOccurrences: item.WeeklyOccurrences,
+		DailyDurations:    DailyDurationsToDTO(item.DailyDurations),
 		Icon:              item.Icon,
 		Color:             item.Color,
+		CategoryId:        item.CategoryId,
+		ParentId:          item.ParentId,
+		StartDate:         item.StartDate,
+		EndDate:           item.EndDate,
+		Rollover:          item.Rollover,
+		Unit:              string(item.Unit),
+		HourlyRate:        item.HourlyRate,
 	}
 }
 
@@ -455,7 +911,117 @@ func DTOToItem(planId int, itemDTO ItemDTO) BudgetItem {
 		Name:              itemDTO.Name,
 		WeeklyDuration:    time.Duration(itemDTO.WeeklyDuration) * time.Second,
 		WeeklyOccurrences: itemDTO.WeeklyOccurrences,
+		DailyDurations:    DTOToDailyDurations(itemDTO.DailyDurations),
 		Icon:              itemDTO.Icon,
 		Color:             itemDTO.Color,
+		CategoryId:        itemDTO.CategoryId,
+		ParentId:          itemDTO.ParentId,
+		StartDate:         itemDTO.StartDate,
+		EndDate:           itemDTO.EndDate,
+		Rollover:          itemDTO.Rollover,
+		Unit:              ItemUnit(itemDTO.Unit),
+		HourlyRate:        itemDTO.HourlyRate,
+	}
+}
+
+func CategoryToDTO(category Category) CategoryDTO {
+	return CategoryDTO{
+		Id:       category.Id,
+		Name:     category.Name,
+		Color:    category.Color,
+		Position: category.Position,
+	}
+}
+
+// DailyDurationsToDTO converts daily durations to a map of lowercase weekday names to seconds.
+func DailyDurationsToDTO(dailyDurations map[time.Weekday]time.Duration) map[string]int {
+	if len(dailyDurations) == 0 {
+		return nil
+	}
+	result := make(map[string]int, len(dailyDurations))
+	for weekday, duration := range dailyDurations {
+		result[strings.ToLower(weekday.String())] = int(duration.Seconds())
+	}
+	return result
+}
+
+// DTOToDailyDurations converts a map of lowercase weekday names to seconds into daily durations.
+// Unknown weekday names are ignored, use ValidateDailyDurationsDTO to reject them.
+func DTOToDailyDurations(dailyDurations map[string]int) map[time.Weekday]time.Duration {
+	if len(dailyDurations) == 0 {
+		return nil
+	}
+	result := make(map[time.Weekday]time.Duration, len(dailyDurations))
+	for name, seconds := range dailyDurations {
+		weekday, ok := parseWeekday(name)
+		if !ok {
+			continue
+		}
+		result[weekday] = time.Duration(seconds) * time.Second
+	}
+	return result
+}
+
+// ValidateDailyDurationsDTO rejects daily durations with unknown weekday names.
+func ValidateDailyDurationsDTO(dailyDurations map[string]int) error {
+	var v rest.Validator
+	checkDailyDurations(&v, dailyDurations)
+	return v.Err()
+}
+
+func checkDailyDurations(v *rest.Validator, dailyDurations map[string]int) {
+	names := slices.Sorted(maps.Keys(dailyDurations))
+	for _, name := range names {
+		_, ok := parseWeekday(name)
+		v.Check(ok, "dailyDurations."+name, "Invalid daily durations",
+			fmt.Sprintf("invalid weekday in daily durations: %s", name))
+	}
+}
+
+// validatePlanDTO checks the plan of an update request against the id of the plan in the path.
+func validatePlanDTO(planDTO BudgetPlanDTO, pathId int) error {
+	var v rest.Validator
+	v.Check(planDTO.Id == pathId, "id", "Invalid plan id", "'id' must be the id of the plan in the path")
+	return v.Err()
+}
+
+// validateItemDTO checks the item of the request, pathId is the id of the item to update, 0 when creating one.
+func validateItemDTO(itemDTO ItemDTO, pathId int) error {
+	var v rest.Validator
+	if pathId != 0 {
+		v.Check(itemDTO.ID == pathId, "id", "Invalid item id", "'id' must be the id of the item in the path")
+	}
+	checkDailyDurations(&v, itemDTO.DailyDurations)
+	v.Check(itemDTO.HourlyRate >= 0, "hourlyRate", "Invalid hourly rate", "'hourlyRate' must not be negative")
+	return v.Err()
+}
+
+// itemFieldError returns the field error of an item the service rejected, nil for other errors.
+func itemFieldError(err error) error {
+	switch {
+	case errors.Is(err, ErrInvalidDailyDuration):
+		return rest.InvalidField("dailyDurations", "Invalid daily durations", err.Error())
+	case errors.Is(err, ErrCategoryNotFound):
+		return rest.InvalidField("categoryId", "Invalid category", err.Error())
+	case errors.Is(err, ErrInvalidParentItem):
+		return rest.InvalidField("parentId", "Invalid parent item", err.Error())
+	case errors.Is(err, ErrInvalidItemDates):
+		return rest.InvalidField("endDate", "Invalid end date", err.Error())
+	case errors.Is(err, ErrInvalidItemUnit):
+		return rest.InvalidField("unit", "Invalid unit", err.Error())
+	case errors.Is(err, ErrInvalidItemColor):
+		return rest.InvalidField("color", "Invalid color", err.Error())
+	case errors.Is(err, ErrInvalidItemIcon):
+		return rest.InvalidField("icon", "Invalid icon", err.Error())
+	}
+	return nil
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.ToLower(weekday.String()) == name {
+			return weekday, true
+		}
 	}
+	return 0, false
 }
@@ -0,0 +1,62 @@
+package budget_plan
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Colors is the curated palette of item colors, in the order they are suggested.
+var Colors = []string{
+	"#3B82F6", "#EF4444", "#10B981", "#F59E0B", "#8B5CF6", "#EC4899", "#14B8A6", "#F97316",
+	"#6366F1", "#84CC16", "#06B6D4", "#F43F5E", "#A855F7", "#EAB308", "#22C55E", "#64748B",
+}
+
+// Icons is the curated set of item icons, in the order they are suggested.
+var Icons = []string{
+	"work", "sleep", "run", "book", "family", "food", "music", "code",
+	"heart", "home", "school", "shopping", "travel", "game", "brush", "meditation",
+}
+
+var colorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// ItemStyle is the color and icon of a budget item.
+type ItemStyle struct {
+	Color string
+	Icon  string
+}
+
+// IsValidColor reports whether the color is a hex RGB color, e.g. "#3B82F6".
+func IsValidColor(color string) bool {
+	return colorPattern.MatchString(color)
+}
+
+// IsValidIcon reports whether the icon is one of the curated Icons.
+func IsValidIcon(icon string) bool {
+	return slices.Contains(Icons, icon)
+}
+
+// SuggestStyle returns the first palette color and icon no item of the plan uses yet. When all of them are
+// used, the least used one is suggested.
+func (p BudgetPlan) SuggestStyle() ItemStyle {
+	colorUses := make(map[string]int)
+	iconUses := make(map[string]int)
+	for _, item := range p.Items {
+		colorUses[strings.ToUpper(item.Color)]++
+		iconUses[item.Icon]++
+	}
+	return ItemStyle{
+		Color: leastUsed(Colors, colorUses),
+		Icon:  leastUsed(Icons, iconUses),
+	}
+}
+
+func leastUsed(palette []string, uses map[string]int) string {
+	suggested := palette[0]
+	for _, value := range palette[1:] {
+		if uses[value] < uses[suggested] {
+			suggested = value
+		}
+	}
+	return suggested
+}
@@ -9,12 +9,16 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/klokku/klokku/internal/database/dbtx"
 	log "github.com/sirupsen/logrus"
 )
 
 var ErrPlanNotFound = errors.New("plan not found")
 var ErrDeletingCurrentPlan = errors.New("cannot delete current plan")
 var ErrBudgetPlanItemNotFound = errors.New("budget plan item not found")
+var ErrPlanActivationNotFound = errors.New("plan activation not found")
+var ErrCategoryNotFound = errors.New("category not found")
+var ErrItemTracked = errors.New("budget item is tracked by the current event")
 
 type Repository interface {
 	StoreItem(ctx context.Context, userId int, budget BudgetItem) (int, int, error)
@@ -28,6 +32,26 @@ type Repository interface {
 	UpdateItem(ctx context.Context, userId int, item BudgetItem) (BudgetItem, error)
 	UpdateItemPosition(ctx context.Context, userId int, item BudgetItem) (bool, error)
 	DeleteItem(ctx context.Context, userId int, itemId int) (bool, error)
+	StoreCategory(ctx context.Context, userId int, category Category) (Category, error)
+	GetCategories(ctx context.Context, userId int, planId int) ([]Category, error)
+	UpdateCategory(ctx context.Context, userId int, category Category) (Category, error)
+	// DeleteCategory removes the category, items assigned to it become uncategorized.
+	DeleteCategory(ctx context.Context, userId int, categoryId int) (bool, error)
+	// StorePlanActivation schedules the activation, replacing a pending one scheduled for the same week.
+	StorePlanActivation(ctx context.Context, userId int, activation PlanActivation) (PlanActivation, error)
+	GetPendingPlanActivations(ctx context.Context, userId int) ([]PlanActivation, error)
+	DeletePlanActivation(ctx context.Context, userId int, activationId int) (bool, error)
+	// GetDuePlanActivations returns pending activations of all users effective at the given time, oldest first.
+	GetDuePlanActivations(ctx context.Context, now time.Time) ([]PlanActivation, error)
+	// ActivatePlan makes the activation's plan current and marks the activation as done.
+	ActivatePlan(ctx context.Context, activation PlanActivation, activatedAt time.Time) error
+	// GetUserIdsWithRolloverItems returns ids of all users whose current plan has items with rollover enabled.
+	GetUserIdsWithRolloverItems(ctx context.Context) ([]int, error)
+	StoreRevision(ctx context.Context, userId int, revision Revision) (Revision, error)
+	// GetRevisions returns revisions of the plan, oldest first.
+	GetRevisions(ctx context.Context, userId int, planId int) ([]Revision, error)
+	// WithTransaction runs fn in a transaction, the repository calls made with its ctx are committed together.
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
 type RepositoryImpl struct {
@@ -37,6 +61,16 @@ type RepositoryImpl struct {
 func NewBudgetPlanRepo(db *pgxpool.Pool) *RepositoryImpl {
 	return &RepositoryImpl{db: db}
 }
+
+// conn returns the transaction of ctx, if any, or the pool, so reads made within another package's transaction
+// do not wait for a second connection.
+func (r *RepositoryImpl) conn(ctx context.Context) dbtx.Querier {
+	return dbtx.From(ctx, r.db)
+}
+
+func (r *RepositoryImpl) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return dbtx.InTx(ctx, r.db, fn)
+}
 func (r *RepositoryImpl) StoreItem(ctx context.Context, userId int, budget BudgetItem) (int, int, error) {
 
 	query := `INSERT INTO budget_item (
@@ -46,21 +80,37 @@ func (r *RepositoryImpl) StoreItem(ctx context.Context, userId int, budget Budge
                     weekly_occurrences, 
                     icon,
                     color,
+                    daily_durations_sec,
+                    category_id,
+                    parent_id,
+                    start_date,
+                    end_date,
+                    rollover,
+                    unit,
+                    hourly_rate,
                     position, 
                     user_id
-				) VALUES ($1, $2, $3, $4, $5, $6, 
-				          (SELECT COALESCE(MAX(position), 0) + 100 FROM budget_item WHERE budget_plan_id = $1 AND user_id = $7), 
-				          $7) RETURNING id, position`
+				) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14,
+				          (SELECT COALESCE(MAX(position), 0) + 100 FROM budget_item WHERE budget_plan_id = $1 AND user_id = $15), 
+				          $15) RETURNING id, position`
 
 	var lastInsertID int
 	var assignedPosition int
-	err := r.db.QueryRow(ctx, query,
+	err := r.conn(ctx).QueryRow(ctx, query,
 		budget.PlanId,
 		budget.Name,
 		budget.WeeklyDuration.Milliseconds()/1000,
 		budget.WeeklyOccurrences,
 		budget.Icon,
 		budget.Color,
+		DailyDurationsToSeconds(budget.DailyDurations),
+		idParam(budget.CategoryId),
+		idParam(budget.ParentId),
+		dateParam(budget.StartDate),
+		dateParam(budget.EndDate),
+		budget.Rollover,
+		unitParam(budget.Unit),
+		budget.HourlyRate,
 		userId,
 	).Scan(&lastInsertID, &assignedPosition)
 	if err != nil {
@@ -74,7 +124,7 @@ func (r *RepositoryImpl) StoreItem(ctx context.Context, userId int, budget Budge
 
 func (r *RepositoryImpl) GetPlan(ctx context.Context, userId int, planId int) (BudgetPlan, error) {
 	// Get a Tx for making transaction requests.
-	tx, err := r.db.Begin(ctx)
+	tx, err := r.conn(ctx).Begin(ctx)
 	if err != nil {
 		return BudgetPlan{}, err
 	}
@@ -89,6 +139,14 @@ func (r *RepositoryImpl) GetPlan(ctx context.Context, userId int, planId int) (B
     			item.weekly_occurrences,
     			item.icon,
     			item.color,
+    			item.daily_durations_sec,
+    			item.category_id,
+    			item.parent_id,
+    			item.start_date,
+    			item.end_date,
+    			item.rollover,
+    			item.unit,
+    			item.hourly_rate,
     			item.position
                FROM budget_plan plan 
 			   LEFT JOIN budget_item item on plan.id = item.budget_plan_id
@@ -115,6 +173,14 @@ func (r *RepositoryImpl) GetPlan(ctx context.Context, userId int, planId int) (B
 			itemOccurrences   sql.NullInt64
 			itemIcon          sql.NullString
 			itemColor         sql.NullString
+			dailyDurationsSec []int32
+			itemCategoryId    sql.NullInt64
+			itemParentId      sql.NullInt64
+			itemStartDate     *time.Time
+			itemEndDate       *time.Time
+			itemRollover      sql.NullBool
+			itemUnit          sql.NullString
+			itemHourlyRate    sql.NullInt64
 			itemPosition      sql.NullInt64
 		)
 
@@ -127,6 +193,14 @@ func (r *RepositoryImpl) GetPlan(ctx context.Context, userId int, planId int) (B
 			&itemOccurrences,
 			&itemIcon,
 			&itemColor,
+			&dailyDurationsSec,
+			&itemCategoryId,
+			&itemParentId,
+			&itemStartDate,
+			&itemEndDate,
+			&itemRollover,
+			&itemUnit,
+			&itemHourlyRate,
 			&itemPosition,
 		); err != nil {
 			err := fmt.Errorf("error scanning row: %w", err)
@@ -153,6 +227,14 @@ func (r *RepositoryImpl) GetPlan(ctx context.Context, userId int, planId int) (B
 		if itemColor.Valid {
 			item.Color = itemColor.String
 		}
+		item.DailyDurations = DailyDurationsFromSeconds(dailyDurationsSec)
+		item.CategoryId = int(itemCategoryId.Int64)
+		item.ParentId = int(itemParentId.Int64)
+		item.StartDate = itemStartDate
+		item.EndDate = itemEndDate
+		item.Rollover = itemRollover.Bool
+		item.Unit = ItemUnit(itemUnit.String)
+		item.HourlyRate = int(itemHourlyRate.Int64)
 		item.Position = int(itemPosition.Int64)
 
 		items = append(items, item)
@@ -174,7 +256,12 @@ func (r *RepositoryImpl) GetPlan(ctx context.Context, userId int, planId int) (B
 	}
 	isCurrentPlan := currentPlanId == planId
 
-	plan := BudgetPlan{Id: planId, Name: planName, IsCurrent: isCurrentPlan, Items: items}
+	categories, err := r.queryCategories(ctx, tx, userId, planId)
+	if err != nil {
+		return BudgetPlan{}, err
+	}
+
+	plan := BudgetPlan{Id: planId, Name: planName, IsCurrent: isCurrentPlan, Items: items, Categories: categories}
 
 	if err := tx.Commit(ctx); err != nil {
 		return BudgetPlan{}, fmt.Errorf("could not commit transaction: %w", err)
@@ -183,14 +270,7 @@ func (r *RepositoryImpl) GetPlan(ctx context.Context, userId int, planId int) (B
 }
 
 func (r *RepositoryImpl) GetCurrentPlan(ctx context.Context, userId int) (BudgetPlan, error) {
-	// Get a Tx for making transaction requests.
-	tx, err := r.db.Begin(ctx)
-	if err != nil {
-		return BudgetPlan{}, err
-	}
-	defer func() { _ = tx.Rollback(ctx) }()
-
-	currentPlanId, err := r.getCurrentPlanId(ctx, tx, userId)
+	currentPlanId, err := r.getCurrentPlanId(ctx, r.conn(ctx), userId)
 	if err != nil {
 		return BudgetPlan{}, err
 	}
@@ -202,7 +282,7 @@ func (r *RepositoryImpl) GetCurrentPlan(ctx context.Context, userId int) (Budget
 
 func (r *RepositoryImpl) ListPlans(ctx context.Context, userId int) ([]BudgetPlan, error) {
 	// Get a Tx for making transaction requests.
-	tx, err := r.db.Begin(ctx)
+	tx, err := r.conn(ctx).Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -242,7 +322,7 @@ func (r *RepositoryImpl) ListPlans(ctx context.Context, userId int) ([]BudgetPla
 
 func (r *RepositoryImpl) CreatePlan(ctx context.Context, userId int, plan BudgetPlan) (BudgetPlan, error) {
 	// Get a Tx for making transaction requests.
-	tx, err := r.db.Begin(ctx)
+	tx, err := r.conn(ctx).Begin(ctx)
 	if err != nil {
 		return BudgetPlan{}, err
 	}
@@ -284,7 +364,7 @@ func (r *RepositoryImpl) CreatePlan(ctx context.Context, userId int, plan Budget
 
 func (r *RepositoryImpl) UpdatePlan(ctx context.Context, userId int, plan BudgetPlan) (BudgetPlan, error) {
 	// Get a Tx for making transaction requests.
-	tx, err := r.db.Begin(ctx)
+	tx, err := r.conn(ctx).Begin(ctx)
 	if err != nil {
 		return BudgetPlan{}, err
 	}
@@ -335,7 +415,7 @@ func (r *RepositoryImpl) setCurrentPlan(ctx context.Context, tx pgx.Tx, userId i
 
 func (r *RepositoryImpl) DeletePlan(ctx context.Context, userId int, planId int) (bool, error) {
 	// Get a Tx for making transaction requests.
-	tx, err := r.db.Begin(ctx)
+	tx, err := r.conn(ctx).Begin(ctx)
 	if err != nil {
 		return false, err
 	}
@@ -371,6 +451,14 @@ func (r *RepositoryImpl) GetItem(ctx context.Context, userId int, itemId int) (B
     			item.weekly_occurrences,
     			item.icon,
     			item.color,
+    			item.daily_durations_sec,
+    			item.category_id,
+    			item.parent_id,
+    			item.start_date,
+    			item.end_date,
+    			item.rollover,
+    			item.unit,
+    			item.hourly_rate,
     			item.position
                FROM budget_item item
                WHERE item.id = $1 AND item.user_id = $2`
@@ -382,10 +470,18 @@ func (r *RepositoryImpl) GetItem(ctx context.Context, userId int, itemId int) (B
 		weeklyOccurrences sql.NullInt64
 		itemIcon          sql.NullString
 		itemColor         sql.NullString
+		dailyDurationsSec []int32
+		itemCategoryId    sql.NullInt64
+		itemParentId      sql.NullInt64
+		itemStartDate     *time.Time
+		itemEndDate       *time.Time
+		itemRollover      bool
+		itemUnit          sql.NullString
+		itemHourlyRate    int
 		itemPosition      int
 	)
 
-	err := r.db.QueryRow(ctx, query, itemId, userId).
+	err := r.conn(ctx).QueryRow(ctx, query, itemId, userId).
 		Scan(
 			&itemPlanId,
 			&itemName,
@@ -393,6 +489,14 @@ func (r *RepositoryImpl) GetItem(ctx context.Context, userId int, itemId int) (B
 			&weeklyOccurrences,
 			&itemIcon,
 			&itemColor,
+			&dailyDurationsSec,
+			&itemCategoryId,
+			&itemParentId,
+			&itemStartDate,
+			&itemEndDate,
+			&itemRollover,
+			&itemUnit,
+			&itemHourlyRate,
 			&itemPosition,
 		)
 	if err != nil {
@@ -418,6 +522,14 @@ func (r *RepositoryImpl) GetItem(ctx context.Context, userId int, itemId int) (B
 	if itemColor.Valid {
 		item.Color = itemColor.String
 	}
+	item.DailyDurations = DailyDurationsFromSeconds(dailyDurationsSec)
+	item.CategoryId = int(itemCategoryId.Int64)
+	item.ParentId = int(itemParentId.Int64)
+	item.StartDate = itemStartDate
+	item.EndDate = itemEndDate
+	item.Rollover = itemRollover
+	item.Unit = ItemUnit(itemUnit.String)
+	item.HourlyRate = itemHourlyRate
 	item.Position = itemPosition
 
 	return item, nil
@@ -425,7 +537,7 @@ func (r *RepositoryImpl) GetItem(ctx context.Context, userId int, itemId int) (B
 
 func (r *RepositoryImpl) UpdateItemPosition(ctx context.Context, userId int, budget BudgetItem) (bool, error) {
 	query := "UPDATE budget_item SET position = $1 WHERE id = $2 and user_id = $3"
-	result, err := r.db.Exec(ctx, query, budget.Position, budget.Id, userId)
+	result, err := r.conn(ctx).Exec(ctx, query, budget.Position, budget.Id, userId)
 	if err != nil {
 		err := fmt.Errorf("could not execute query: %v", err)
 		log.Error(err)
@@ -441,8 +553,16 @@ func (r *RepositoryImpl) UpdateItem(ctx context.Context, userId int, item Budget
                   weekly_duration_sec = $2, 
                   weekly_occurrences = $3, 
                   icon = $4,
-                  color = $5
-              WHERE id = $6 and user_id = $7 RETURNING budget_plan_id, id, name, weekly_duration_sec, weekly_occurrences, icon, color, position`
+                  color = $5,
+                  daily_durations_sec = $6,
+                  category_id = $7,
+                  parent_id = $8,
+                  start_date = $9,
+                  end_date = $10,
+                  rollover = $11,
+                  unit = $12,
+                  hourly_rate = $13
+              WHERE id = $14 and user_id = $15 RETURNING budget_plan_id, id, name, weekly_duration_sec, weekly_occurrences, icon, color, daily_durations_sec, category_id, parent_id, start_date, end_date, rollover, unit, hourly_rate, position`
 
 	var (
 		itemPlanId        int
@@ -452,18 +572,34 @@ func (r *RepositoryImpl) UpdateItem(ctx context.Context, userId int, item Budget
 		weeklyOccurrences sql.NullInt64
 		itemIcon          sql.NullString
 		itemColor         sql.NullString
+		dailyDurationsSec []int32
+		itemCategoryId    sql.NullInt64
+		itemParentId      sql.NullInt64
+		itemStartDate     *time.Time
+		itemEndDate       *time.Time
+		itemRollover      bool
+		itemUnit          sql.NullString
+		itemHourlyRate    int
 		itemPosition      int
 	)
 
-	err := r.db.QueryRow(ctx, query,
+	err := r.conn(ctx).QueryRow(ctx, query,
 		item.Name,
 		item.WeeklyDuration.Milliseconds()/1000,
 		item.WeeklyOccurrences,
 		item.Icon,
 		item.Color,
+		DailyDurationsToSeconds(item.DailyDurations),
+		idParam(item.CategoryId),
+		idParam(item.ParentId),
+		dateParam(item.StartDate),
+		dateParam(item.EndDate),
+		item.Rollover,
+		unitParam(item.Unit),
+		item.HourlyRate,
 		item.Id,
 		userId,
-	).Scan(&itemPlanId, &itemId, &itemName, &weeklyDurationSec, &weeklyOccurrences, &itemIcon, &itemColor, &itemPosition)
+	).Scan(&itemPlanId, &itemId, &itemName, &weeklyDurationSec, &weeklyOccurrences, &itemIcon, &itemColor, &dailyDurationsSec, &itemCategoryId, &itemParentId, &itemStartDate, &itemEndDate, &itemRollover, &itemUnit, &itemHourlyRate, &itemPosition)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return BudgetItem{}, ErrBudgetPlanItemNotFound
@@ -486,24 +622,48 @@ func (r *RepositoryImpl) UpdateItem(ctx context.Context, userId int, item Budget
 	if itemColor.Valid {
 		updatedItem.Color = itemColor.String
 	}
+	updatedItem.DailyDurations = DailyDurationsFromSeconds(dailyDurationsSec)
+	updatedItem.CategoryId = int(itemCategoryId.Int64)
+	updatedItem.ParentId = int(itemParentId.Int64)
+	updatedItem.StartDate = itemStartDate
+	updatedItem.EndDate = itemEndDate
+	updatedItem.Rollover = itemRollover
+	updatedItem.Unit = ItemUnit(itemUnit.String)
+	updatedItem.HourlyRate = itemHourlyRate
 	updatedItem.Position = itemPosition
 
 	return updatedItem, nil
 }
 
 func (r *RepositoryImpl) DeleteItem(ctx context.Context, userId int, itemId int) (bool, error) {
-	query := "DELETE FROM budget_item WHERE id = $1 and user_id = $2"
-	result, err := r.db.Exec(ctx, query, itemId, userId)
-	if err != nil {
-		err := fmt.Errorf("could not execute query: %v", err)
-		log.Error(err)
-		return false, err
-	}
-	rowsAffected := result.RowsAffected()
-	return rowsAffected == 1, nil
+	deleted := false
+	err := dbtx.InTx(ctx, r.db, func(ctx context.Context) error {
+		tx := r.conn(ctx)
+		// The current event cannot be re-linked to another item, the user has to stop or change it first
+		var tracked bool
+		err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM current_event WHERE budget_item_id = $1 AND user_id = $2)",
+			itemId, userId).Scan(&tracked)
+		if err != nil {
+			return fmt.Errorf("could not check current event: %w", err)
+		}
+		if tracked {
+			return ErrItemTracked
+		}
+
+		query := "DELETE FROM budget_item WHERE id = $1 and user_id = $2"
+		result, err := tx.Exec(ctx, query, itemId, userId)
+		if err != nil {
+			err := fmt.Errorf("could not execute query: %v", err)
+			log.Error(err)
+			return err
+		}
+		deleted = result.RowsAffected() == 1
+		return nil
+	})
+	return deleted, err
 }
 
-func (r *RepositoryImpl) getCurrentPlanId(ctx context.Context, tx pgx.Tx, userId int) (int, error) {
+func (r *RepositoryImpl) getCurrentPlanId(ctx context.Context, tx dbtx.Querier, userId int) (int, error) {
 	query := "SELECT budget_plan_current.budget_plan_id FROM budget_plan_current WHERE budget_plan_current.user_id = $1"
 	var planId sql.NullInt64
 	err := tx.QueryRow(ctx, query, userId).Scan(&planId)
@@ -530,3 +690,323 @@ func (r *RepositoryImpl) countPlans(ctx context.Context, tx pgx.Tx, userId int)
 	}
 	return count, nil
 }
+
+// idParam maps an unset reference id (e.g. category or parent item) to NULL.
+func idParam(id int) *int {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// dateParam maps an optional date to its calendar date, NULL when unset.
+func dateParam(date *time.Time) *time.Time {
+	if date == nil {
+		return nil
+	}
+	d := dateOf(*date)
+	return &d
+}
+
+// unitParam maps the item unit to NULL for the default, duration-based unit.
+func unitParam(unit ItemUnit) *string {
+	if unit == "" || unit == UnitDuration {
+		return nil
+	}
+	u := string(unit)
+	return &u
+}
+
+const categoryColumns = `id, budget_plan_id, name, color, position`
+
+func scanCategory(row pgx.Row) (Category, error) {
+	var category Category
+	err := row.Scan(&category.Id, &category.PlanId, &category.Name, &category.Color, &category.Position)
+	return category, err
+}
+
+func (r *RepositoryImpl) StoreCategory(ctx context.Context, userId int, category Category) (Category, error) {
+	query := `INSERT INTO budget_category (budget_plan_id, user_id, name, color, position)
+				SELECT plan.id, plan.user_id, $3, $4,
+				       (SELECT COALESCE(MAX(position), 0) + 100 FROM budget_category WHERE budget_plan_id = $1)
+				FROM budget_plan plan WHERE plan.id = $1 AND plan.user_id = $2
+				RETURNING ` + categoryColumns
+	stored, err := scanCategory(r.conn(ctx).QueryRow(ctx, query, category.PlanId, userId, category.Name, category.Color))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Category{}, ErrPlanNotFound
+		}
+		err := fmt.Errorf("could not store category: %w", err)
+		log.Error(err)
+		return Category{}, err
+	}
+	return stored, nil
+}
+
+func (r *RepositoryImpl) GetCategories(ctx context.Context, userId int, planId int) ([]Category, error) {
+	tx, err := r.conn(ctx).Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	categories, err := r.queryCategories(ctx, tx, userId, planId)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return categories, nil
+}
+
+func (r *RepositoryImpl) queryCategories(ctx context.Context, tx pgx.Tx, userId int, planId int) ([]Category, error) {
+	query := `SELECT ` + categoryColumns + ` FROM budget_category
+				WHERE budget_plan_id = $1 AND user_id = $2
+				ORDER BY position, id`
+	rows, err := tx.Query(ctx, query, planId, userId)
+	if err != nil {
+		err := fmt.Errorf("could not query categories: %w", err)
+		log.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make([]Category, 0)
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			err := fmt.Errorf("error scanning row: %w", err)
+			log.Error(err)
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+func (r *RepositoryImpl) UpdateCategory(ctx context.Context, userId int, category Category) (Category, error) {
+	query := `UPDATE budget_category SET name = $1, color = $2
+				WHERE id = $3 AND budget_plan_id = $4 AND user_id = $5
+				RETURNING ` + categoryColumns
+	updated, err := scanCategory(r.conn(ctx).QueryRow(ctx, query, category.Name, category.Color, category.Id, category.PlanId, userId))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Category{}, ErrCategoryNotFound
+		}
+		err := fmt.Errorf("could not update category: %w", err)
+		log.Error(err)
+		return Category{}, err
+	}
+	return updated, nil
+}
+
+func (r *RepositoryImpl) DeleteCategory(ctx context.Context, userId int, categoryId int) (bool, error) {
+	query := `DELETE FROM budget_category WHERE id = $1 AND user_id = $2`
+	result, err := r.conn(ctx).Exec(ctx, query, categoryId, userId)
+	if err != nil {
+		err := fmt.Errorf("could not execute query: %v", err)
+		log.Error(err)
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+const planActivationColumns = `id, user_id, budget_plan_id, effective_from, activated_at`
+
+func scanPlanActivation(row pgx.Row) (PlanActivation, error) {
+	var activation PlanActivation
+	err := row.Scan(
+		&activation.Id,
+		&activation.UserId,
+		&activation.PlanId,
+		&activation.EffectiveFrom,
+		&activation.ActivatedAt,
+	)
+	return activation, err
+}
+
+func (r *RepositoryImpl) StorePlanActivation(ctx context.Context, userId int, activation PlanActivation) (PlanActivation, error) {
+	query := `INSERT INTO budget_plan_activation (budget_plan_id, user_id, effective_from)
+				SELECT plan.id, plan.user_id, $3 FROM budget_plan plan WHERE plan.id = $1 AND plan.user_id = $2
+				ON CONFLICT (user_id, effective_from) DO UPDATE
+					SET budget_plan_id = EXCLUDED.budget_plan_id, activated_at = NULL
+				RETURNING ` + planActivationColumns
+	stored, err := scanPlanActivation(r.conn(ctx).QueryRow(ctx, query, activation.PlanId, userId, activation.EffectiveFrom))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return PlanActivation{}, ErrPlanNotFound
+		}
+		err := fmt.Errorf("could not store plan activation: %w", err)
+		log.Error(err)
+		return PlanActivation{}, err
+	}
+	return stored, nil
+}
+
+func (r *RepositoryImpl) GetPendingPlanActivations(ctx context.Context, userId int) ([]PlanActivation, error) {
+	query := `SELECT ` + planActivationColumns + ` FROM budget_plan_activation
+				WHERE user_id = $1 AND activated_at IS NULL
+				ORDER BY effective_from`
+	return r.queryPlanActivations(ctx, query, userId)
+}
+
+func (r *RepositoryImpl) GetDuePlanActivations(ctx context.Context, now time.Time) ([]PlanActivation, error) {
+	query := `SELECT ` + planActivationColumns + ` FROM budget_plan_activation
+				WHERE activated_at IS NULL AND effective_from <= $1
+				  AND user_id IN (SELECT id FROM users WHERE NOT disabled)
+				ORDER BY effective_from`
+	return r.queryPlanActivations(ctx, query, now)
+}
+
+func (r *RepositoryImpl) queryPlanActivations(ctx context.Context, query string, args ...any) ([]PlanActivation, error) {
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		err := fmt.Errorf("could not query plan activations: %w", err)
+		log.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	activations := make([]PlanActivation, 0)
+	for rows.Next() {
+		activation, err := scanPlanActivation(rows)
+		if err != nil {
+			err := fmt.Errorf("error scanning row: %w", err)
+			log.Error(err)
+			return nil, err
+		}
+		activations = append(activations, activation)
+	}
+	return activations, nil
+}
+
+func (r *RepositoryImpl) DeletePlanActivation(ctx context.Context, userId int, activationId int) (bool, error) {
+	query := `DELETE FROM budget_plan_activation WHERE id = $1 AND user_id = $2 AND activated_at IS NULL`
+	result, err := r.conn(ctx).Exec(ctx, query, activationId, userId)
+	if err != nil {
+		err := fmt.Errorf("could not execute query: %v", err)
+		log.Error(err)
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+func (r *RepositoryImpl) ActivatePlan(ctx context.Context, activation PlanActivation, activatedAt time.Time) error {
+	tx, err := r.conn(ctx).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := r.setCurrentPlan(ctx, tx, activation.UserId, activation.PlanId); err != nil {
+		return err
+	}
+	query := `UPDATE budget_plan_activation SET activated_at = $1 WHERE id = $2`
+	if _, err := tx.Exec(ctx, query, activatedAt, activation.Id); err != nil {
+		err := fmt.Errorf("could not execute query: %v", err)
+		log.Error(err)
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
+
+const revisionColumns = `id, budget_plan_id, budget_item_id, item_name, change_type,
+	previous_weekly_duration_sec, weekly_duration_sec, changed_at`
+
+func scanRevision(row pgx.Row) (Revision, error) {
+	var revision Revision
+	var changeType string
+	var previousDurationSec, durationSec int
+	err := row.Scan(
+		&revision.Id,
+		&revision.PlanId,
+		&revision.BudgetItemId,
+		&revision.ItemName,
+		&changeType,
+		&previousDurationSec,
+		&durationSec,
+		&revision.ChangedAt,
+	)
+	revision.Type = RevisionType(changeType)
+	revision.PreviousWeeklyDuration = time.Duration(previousDurationSec) * time.Second
+	revision.WeeklyDuration = time.Duration(durationSec) * time.Second
+	return revision, err
+}
+
+func (r *RepositoryImpl) GetUserIdsWithRolloverItems(ctx context.Context) ([]int, error) {
+	query := `SELECT DISTINCT item.user_id
+				FROM budget_item item
+				JOIN budget_plan_current current ON current.budget_plan_id = item.budget_plan_id AND current.user_id = item.user_id
+				WHERE item.rollover = TRUE
+				ORDER BY item.user_id`
+	rows, err := r.conn(ctx).Query(ctx, query)
+	if err != nil {
+		err := fmt.Errorf("could not query users with rollover items: %w", err)
+		log.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIds := make([]int, 0)
+	for rows.Next() {
+		var userId int
+		if err := rows.Scan(&userId); err != nil {
+			return nil, fmt.Errorf("could not scan user id: %w", err)
+		}
+		userIds = append(userIds, userId)
+	}
+	return userIds, rows.Err()
+}
+
+func (r *RepositoryImpl) StoreRevision(ctx context.Context, userId int, revision Revision) (Revision, error) {
+	query := `INSERT INTO budget_plan_revision (budget_plan_id, user_id, budget_item_id, item_name, change_type,
+				previous_weekly_duration_sec, weekly_duration_sec, changed_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+				RETURNING ` + revisionColumns
+	stored, err := scanRevision(r.conn(ctx).QueryRow(ctx, query,
+		revision.PlanId,
+		userId,
+		revision.BudgetItemId,
+		revision.ItemName,
+		string(revision.Type),
+		int(revision.PreviousWeeklyDuration.Seconds()),
+		int(revision.WeeklyDuration.Seconds()),
+		revision.ChangedAt,
+	))
+	if err != nil {
+		err := fmt.Errorf("could not store plan revision: %w", err)
+		log.Error(err)
+		return Revision{}, err
+	}
+	return stored, nil
+}
+
+func (r *RepositoryImpl) GetRevisions(ctx context.Context, userId int, planId int) ([]Revision, error) {
+	query := `SELECT ` + revisionColumns + ` FROM budget_plan_revision
+				WHERE budget_plan_id = $1 AND user_id = $2
+				ORDER BY changed_at, id`
+	rows, err := r.conn(ctx).Query(ctx, query, planId, userId)
+	if err != nil {
+		err := fmt.Errorf("could not query plan revisions: %w", err)
+		log.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]Revision, 0)
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			err := fmt.Errorf("error scanning row: %w", err)
+			log.Error(err)
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
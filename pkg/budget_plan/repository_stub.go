@@ -3,12 +3,22 @@ package budget_plan
 import (
 	"context"
 	"fmt"
+	"sort"
+	"time"
 )
 
 type RepositoryStub struct {
 	nextId        int
 	plans         map[int]BudgetPlan
 	currentPlanId int
+	activations   []PlanActivation
+	categories    []Category
+	revisions     []Revision
+	trackedItemId int
+}
+
+func (s *RepositoryStub) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
 }
 
 func (s *RepositoryStub) CreatePlan(ctx context.Context, userId int, plan BudgetPlan) (BudgetPlan, error) {
@@ -76,7 +86,7 @@ func (s *RepositoryStub) ListPlans(ctx context.Context, userId int) ([]BudgetPla
 func NewStubBudgetRepo() *RepositoryStub {
 	nextId := 2
 	plans := map[int]BudgetPlan{}
-	return &RepositoryStub{nextId, plans, 0}
+	return &RepositoryStub{nextId, plans, 0, nil, nil, nil, 0}
 }
 
 func (s *RepositoryStub) StoreItem(ctx context.Context, userId int, item BudgetItem) (int, int, error) {
@@ -104,6 +114,7 @@ func (s *RepositoryStub) GetPlan(ctx context.Context, userId int, planId int) (B
 		if s.currentPlanId == planId {
 			plan.IsCurrent = true
 		}
+		plan.Categories, _ = s.GetCategories(ctx, userId, planId)
 		return plan, nil
 	}
 	return BudgetPlan{}, ErrPlanNotFound
@@ -124,10 +135,18 @@ func (s *RepositoryStub) UpdateItem(ctx context.Context, userId int, item Budget
 }
 
 func (s *RepositoryStub) DeleteItem(ctx context.Context, userId int, itemId int) (bool, error) {
+	if itemId == s.trackedItemId {
+		return false, ErrItemTracked
+	}
 	for _, plan := range s.plans {
 		for i, item := range plan.Items {
 			if item.Id == itemId {
 				plan.Items = append(plan.Items[:i], plan.Items[i+1:]...)
+				for j := range plan.Items {
+					if plan.Items[j].ParentId == itemId {
+						plan.Items[j].ParentId = 0
+					}
+				}
 				s.plans[plan.Id] = plan
 				return true, nil
 			}
@@ -144,6 +163,166 @@ func (s *RepositoryStub) UpdateItemPosition(ctx context.Context, userId int, ite
 	return updateItem.Position == item.Position, err
 }
 
+// TrackItem makes the item the one tracked by the current event, so it cannot be deleted.
+func (s *RepositoryStub) TrackItem(itemId int) {
+	s.trackedItemId = itemId
+}
+
 func (s *RepositoryStub) Cleanup() {
 	s.plans = map[int]BudgetPlan{}
+	s.trackedItemId = 0
+	s.activations = nil
+	s.categories = nil
+	s.revisions = nil
+}
+
+func (s *RepositoryStub) StoreCategory(ctx context.Context, userId int, category Category) (Category, error) {
+	if _, exists := s.plans[category.PlanId]; !exists {
+		return Category{}, ErrPlanNotFound
+	}
+	s.nextId++
+	category.Id = s.nextId
+	category.Position = 100
+	for _, c := range s.categories {
+		if c.PlanId == category.PlanId && c.Position >= category.Position {
+			category.Position = c.Position + 100
+		}
+	}
+	s.categories = append(s.categories, category)
+	return category, nil
+}
+
+func (s *RepositoryStub) GetCategories(ctx context.Context, userId int, planId int) ([]Category, error) {
+	result := make([]Category, 0)
+	for _, c := range s.categories {
+		if c.PlanId == planId {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+func (s *RepositoryStub) UpdateCategory(ctx context.Context, userId int, category Category) (Category, error) {
+	for i, c := range s.categories {
+		if c.Id == category.Id && c.PlanId == category.PlanId {
+			category.Position = c.Position
+			s.categories[i] = category
+			return category, nil
+		}
+	}
+	return Category{}, ErrCategoryNotFound
+}
+
+func (s *RepositoryStub) DeleteCategory(ctx context.Context, userId int, categoryId int) (bool, error) {
+	for i, c := range s.categories {
+		if c.Id == categoryId {
+			s.categories = append(s.categories[:i], s.categories[i+1:]...)
+			for planId, plan := range s.plans {
+				for j := range plan.Items {
+					if plan.Items[j].CategoryId == categoryId {
+						plan.Items[j].CategoryId = 0
+					}
+				}
+				s.plans[planId] = plan
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *RepositoryStub) StorePlanActivation(ctx context.Context, userId int, activation PlanActivation) (PlanActivation, error) {
+	if _, exists := s.plans[activation.PlanId]; !exists {
+		return PlanActivation{}, ErrPlanNotFound
+	}
+	activation.UserId = userId
+	activation.ActivatedAt = nil
+	for i, a := range s.activations {
+		if a.UserId == userId && a.EffectiveFrom.Equal(activation.EffectiveFrom) {
+			activation.Id = a.Id
+			s.activations[i] = activation
+			return activation, nil
+		}
+	}
+	s.nextId++
+	activation.Id = s.nextId
+	s.activations = append(s.activations, activation)
+	return activation, nil
+}
+
+func (s *RepositoryStub) GetPendingPlanActivations(ctx context.Context, userId int) ([]PlanActivation, error) {
+	result := make([]PlanActivation, 0)
+	for _, a := range s.activations {
+		if a.UserId == userId && a.ActivatedAt == nil {
+			result = append(result, a)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].EffectiveFrom.Before(result[j].EffectiveFrom)
+	})
+	return result, nil
+}
+
+func (s *RepositoryStub) DeletePlanActivation(ctx context.Context, userId int, activationId int) (bool, error) {
+	for i, a := range s.activations {
+		if a.Id == activationId && a.UserId == userId && a.ActivatedAt == nil {
+			s.activations = append(s.activations[:i], s.activations[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *RepositoryStub) GetDuePlanActivations(ctx context.Context, now time.Time) ([]PlanActivation, error) {
+	result := make([]PlanActivation, 0)
+	for _, a := range s.activations {
+		if a.ActivatedAt == nil && !a.EffectiveFrom.After(now) {
+			result = append(result, a)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].EffectiveFrom.Before(result[j].EffectiveFrom)
+	})
+	return result, nil
+}
+
+// GetUserIdsWithRolloverItems returns the stub's single user (id 1) when its current plan has rollover items.
+func (s *RepositoryStub) GetUserIdsWithRolloverItems(ctx context.Context) ([]int, error) {
+	for _, item := range s.plans[s.currentPlanId].Items {
+		if item.Rollover {
+			return []int{1}, nil
+		}
+	}
+	return []int{}, nil
+}
+
+func (s *RepositoryStub) ActivatePlan(ctx context.Context, activation PlanActivation, activatedAt time.Time) error {
+	for i, a := range s.activations {
+		if a.Id == activation.Id {
+			s.currentPlanId = activation.PlanId
+			s.activations[i].ActivatedAt = &activatedAt
+			return nil
+		}
+	}
+	return ErrPlanActivationNotFound
+}
+
+func (s *RepositoryStub) StoreRevision(ctx context.Context, userId int, revision Revision) (Revision, error) {
+	s.nextId++
+	revision.Id = s.nextId
+	s.revisions = append(s.revisions, revision)
+	return revision, nil
+}
+
+func (s *RepositoryStub) GetRevisions(ctx context.Context, userId int, planId int) ([]Revision, error) {
+	result := make([]Revision, 0)
+	for _, revision := range s.revisions {
+		if revision.PlanId == planId {
+			result = append(result, revision)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ChangedAt.Before(result[j].ChangedAt)
+	})
+	return result, nil
 }
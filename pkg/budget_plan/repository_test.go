@@ -438,3 +438,225 @@ func TestRepositoryImpl_GetItem(t *testing.T) {
 	assert.Equal(t, "#DDDDFF", item.Color)
 	assert.Equal(t, "some-icon", item.Icon)
 }
+
+func TestRepositoryImpl_PlanActivation(t *testing.T) {
+	// given
+	ctx, repo, userId := setupTestRepository(t)
+	regularPlan, _ := repo.CreatePlan(ctx, userId, BudgetPlan{Name: "Regular"})
+	holidayPlan, _ := repo.CreatePlan(ctx, userId, BudgetPlan{Name: "Holiday"})
+	effectiveFrom := time.Date(2025, time.July, 14, 0, 0, 0, 0, time.UTC)
+
+	// when
+	_, err := repo.StorePlanActivation(ctx, userId, PlanActivation{PlanId: regularPlan.Id, EffectiveFrom: effectiveFrom})
+	require.NoError(t, err)
+	activation, err := repo.StorePlanActivation(ctx, userId, PlanActivation{PlanId: holidayPlan.Id, EffectiveFrom: effectiveFrom})
+	require.NoError(t, err)
+
+	// then
+	pending, err := repo.GetPendingPlanActivations(ctx, userId)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, holidayPlan.Id, pending[0].PlanId)
+	assert.True(t, effectiveFrom.Equal(pending[0].EffectiveFrom))
+
+	_, err = repo.StorePlanActivation(ctx, userId+1, PlanActivation{PlanId: holidayPlan.Id, EffectiveFrom: effectiveFrom})
+	assert.ErrorIs(t, err, ErrPlanNotFound)
+
+	due, err := repo.GetDuePlanActivations(ctx, effectiveFrom.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, due)
+	due, err = repo.GetDuePlanActivations(ctx, effectiveFrom)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+
+	// when
+	err = repo.ActivatePlan(ctx, due[0], effectiveFrom)
+
+	// then
+	require.NoError(t, err)
+	current, err := repo.GetCurrentPlan(ctx, userId)
+	require.NoError(t, err)
+	assert.Equal(t, holidayPlan.Id, current.Id)
+	pending, err = repo.GetPendingPlanActivations(ctx, userId)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	deleted, err := repo.DeletePlanActivation(ctx, userId, activation.Id)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+}
+
+func TestRepositoryImpl_GetDuePlanActivations_SkipsDisabledUsers(t *testing.T) {
+	// given
+	ctx, repo, userId := setupTestRepository(t)
+	plan, _ := repo.CreatePlan(ctx, userId, BudgetPlan{Name: "Holiday"})
+	effectiveFrom := time.Date(2025, time.July, 14, 0, 0, 0, 0, time.UTC)
+	_, err := repo.StorePlanActivation(ctx, userId, PlanActivation{PlanId: plan.Id, EffectiveFrom: effectiveFrom})
+	require.NoError(t, err)
+	db := openDb()
+	defer db.Close()
+	_, err = db.Exec(ctx, "UPDATE users SET disabled = true WHERE id = $1", userId)
+	require.NoError(t, err)
+
+	// when
+	due, err := repo.GetDuePlanActivations(ctx, effectiveFrom)
+
+	// then
+	require.NoError(t, err)
+	assert.Empty(t, due)
+}
+
+func TestRepositoryImpl_Revisions(t *testing.T) {
+	// given
+	ctx, repo, userId := setupTestRepository(t)
+	plan, _ := repo.CreatePlan(ctx, userId, BudgetPlan{Name: "Plan"})
+	added := Revision{
+		PlanId:         plan.Id,
+		BudgetItemId:   7,
+		ItemName:       "Reading",
+		Type:           RevisionItemAdded,
+		WeeklyDuration: 5 * time.Hour,
+		ChangedAt:      time.Date(2025, time.March, 3, 9, 0, 0, 0, time.UTC),
+	}
+	changed := Revision{
+		PlanId:                 plan.Id,
+		BudgetItemId:           7,
+		ItemName:               "Reading",
+		Type:                   RevisionDurationChanged,
+		PreviousWeeklyDuration: 5 * time.Hour,
+		WeeklyDuration:         3 * time.Hour,
+		ChangedAt:              time.Date(2025, time.March, 10, 9, 0, 0, 0, time.UTC),
+	}
+
+	// when
+	_, err := repo.StoreRevision(ctx, userId, changed)
+	require.NoError(t, err)
+	_, err = repo.StoreRevision(ctx, userId, added)
+	require.NoError(t, err)
+
+	// then
+	revisions, err := repo.GetRevisions(ctx, userId, plan.Id)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, RevisionItemAdded, revisions[0].Type)
+	assert.Equal(t, 5*time.Hour, revisions[0].WeeklyDuration)
+	assert.Equal(t, RevisionDurationChanged, revisions[1].Type)
+	assert.Equal(t, 5*time.Hour, revisions[1].PreviousWeeklyDuration)
+	assert.Equal(t, 3*time.Hour, revisions[1].WeeklyDuration)
+	assert.True(t, changed.ChangedAt.Equal(revisions[1].ChangedAt))
+
+	otherUserRevisions, err := repo.GetRevisions(ctx, userId+1, plan.Id)
+	require.NoError(t, err)
+	assert.Empty(t, otherUserRevisions)
+}
+
+func TestRepositoryImpl_SubItems(t *testing.T) {
+	// given
+	ctx, repo, userId := setupTestRepository(t)
+	plan, _ := repo.CreatePlan(ctx, userId, BudgetPlan{Name: "Plan"})
+	parentId, _, err := repo.StoreItem(ctx, userId, BudgetItem{PlanId: plan.Id, Name: "Work", WeeklyDuration: time.Hour})
+	require.NoError(t, err)
+
+	// when
+	childId, _, err := repo.StoreItem(ctx, userId, BudgetItem{PlanId: plan.Id, Name: "Coding", WeeklyDuration: time.Hour, ParentId: parentId})
+	require.NoError(t, err)
+
+	// then
+	child, err := repo.GetItem(ctx, userId, childId)
+	require.NoError(t, err)
+	assert.Equal(t, parentId, child.ParentId)
+	storedPlan, err := repo.GetPlan(ctx, userId, plan.Id)
+	require.NoError(t, err)
+	assert.True(t, storedPlan.HasChildren(parentId))
+
+	// when
+	deleted, err := repo.DeleteItem(ctx, userId, parentId)
+
+	// then
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	child, err = repo.GetItem(ctx, userId, childId)
+	require.NoError(t, err)
+	assert.Zero(t, child.ParentId)
+}
+
+func TestRepositoryImpl_ItemDates(t *testing.T) {
+	// given
+	ctx, repo, userId := setupTestRepository(t)
+	plan, _ := repo.CreatePlan(ctx, userId, BudgetPlan{Name: "Plan"})
+	start := time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, time.April, 13, 0, 0, 0, 0, time.UTC)
+
+	// when
+	itemId, _, err := repo.StoreItem(ctx, userId, BudgetItem{PlanId: plan.Id, Name: "Course", WeeklyDuration: time.Hour, StartDate: &start})
+	require.NoError(t, err)
+
+	// then
+	item, err := repo.GetItem(ctx, userId, itemId)
+	require.NoError(t, err)
+	require.NotNil(t, item.StartDate)
+	assert.True(t, start.Equal(*item.StartDate))
+	assert.Nil(t, item.EndDate)
+
+	// when
+	item.EndDate = &end
+	updated, err := repo.UpdateItem(ctx, userId, item)
+	require.NoError(t, err)
+
+	// then
+	require.NotNil(t, updated.EndDate)
+	assert.True(t, end.Equal(*updated.EndDate))
+	storedPlan, err := repo.GetPlan(ctx, userId, plan.Id)
+	require.NoError(t, err)
+	require.NotNil(t, storedPlan.Items[0].EndDate)
+	assert.True(t, end.Equal(*storedPlan.Items[0].EndDate))
+}
+
+func TestRepositoryImpl_ItemHourlyRate(t *testing.T) {
+	// given
+	ctx, repo, userId := setupTestRepository(t)
+	plan, _ := repo.CreatePlan(ctx, userId, BudgetPlan{Name: "Plan"})
+
+	// when
+	itemId, _, err := repo.StoreItem(ctx, userId, BudgetItem{PlanId: plan.Id, Name: "Client work", WeeklyDuration: time.Hour, HourlyRate: 8000})
+	require.NoError(t, err)
+
+	// then
+	item, err := repo.GetItem(ctx, userId, itemId)
+	require.NoError(t, err)
+	assert.Equal(t, 8000, item.HourlyRate)
+
+	// when
+	item.HourlyRate = 9500
+	updated, err := repo.UpdateItem(ctx, userId, item)
+	require.NoError(t, err)
+
+	// then
+	assert.Equal(t, 9500, updated.HourlyRate)
+	storedPlan, err := repo.GetPlan(ctx, userId, plan.Id)
+	require.NoError(t, err)
+	assert.Equal(t, 9500, storedPlan.Items[0].HourlyRate)
+}
+
+func TestRepositoryImpl_GetUserIdsWithRolloverItems(t *testing.T) {
+	// given
+	ctx, repo, userId := setupTestRepository(t)
+	otherUserId := userId + 1
+	plan, err := repo.CreatePlan(ctx, userId, BudgetPlan{Name: "Current"})
+	require.NoError(t, err)
+	_, _, err = repo.StoreItem(ctx, userId, BudgetItem{PlanId: plan.Id, Name: "Reading", WeeklyDuration: time.Hour, Rollover: true})
+	require.NoError(t, err)
+	otherPlan, err := repo.CreatePlan(ctx, otherUserId, BudgetPlan{Name: "Other"})
+	require.NoError(t, err)
+	_, _, err = repo.StoreItem(ctx, otherUserId, BudgetItem{PlanId: otherPlan.Id, Name: "Work", WeeklyDuration: time.Hour})
+	require.NoError(t, err)
+
+	// when
+	userIds, err := repo.GetUserIdsWithRolloverItems(ctx)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, []int{userId}, userIds)
+	storedPlan, err := repo.GetPlan(ctx, userId, plan.Id)
+	require.NoError(t, err)
+	assert.True(t, storedPlan.Items[0].Rollover)
+}
@@ -2,13 +2,26 @@ package budget_plan
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
 	"github.com/klokku/klokku/pkg/user"
 	log "github.com/sirupsen/logrus"
 )
 
+var ErrInvalidDailyDuration = errors.New("daily duration must be between 0 and 24 hours")
+var ErrActivationNotInFuture = errors.New("plan activation must start in a future week")
+var ErrInvalidCategory = errors.New("category name cannot be empty")
+var ErrInvalidParentItem = errors.New("parent must be a top-level item of the same plan")
+var ErrInvalidItemDates = errors.New("item end date cannot be before its start date")
+var ErrInvalidItemUnit = errors.New("invalid item unit, sessions require weekly occurrences")
+var ErrInvalidItemColor = errors.New("item color must be a hex color, e.g. #3B82F6")
+var ErrInvalidItemIcon = errors.New("item icon must be one of the curated icons")
+
 type Service interface {
 	GetPlan(ctx context.Context, planId int) (BudgetPlan, error)
 	GetCurrentPlan(ctx context.Context) (BudgetPlan, error)
@@ -21,15 +34,45 @@ type Service interface {
 	MoveItemAfter(ctx context.Context, planId, itemId, precedingId int) (bool, error)
 	UpdateItem(ctx context.Context, budget BudgetItem) (BudgetItem, error)
 	DeleteItem(ctx context.Context, id int) (bool, error)
+	// GetChangelog returns the history of item changes of the plan, oldest first.
+	GetChangelog(ctx context.Context, planId int) ([]Revision, error)
+	GetCategories(ctx context.Context, planId int) ([]Category, error)
+	CreateCategory(ctx context.Context, category Category) (Category, error)
+	UpdateCategory(ctx context.Context, category Category) (Category, error)
+	DeleteCategory(ctx context.Context, categoryId int) (bool, error)
+	// SchedulePlanActivation makes the plan current from the start of the week containing weekDate.
+	SchedulePlanActivation(ctx context.Context, planId int, weekDate time.Time) (PlanActivation, error)
+	GetPendingPlanActivations(ctx context.Context) ([]PlanActivation, error)
+	CancelPlanActivation(ctx context.Context, activationId int) error
+	// ActivateDuePlans switches the current plan of every user with an activation effective at the given time.
+	ActivateDuePlans(ctx context.Context, now time.Time) error
+	// GetUserIdsWithRolloverItems returns ids of all users whose current plan has items with rollover enabled.
+	GetUserIdsWithRolloverItems(ctx context.Context) ([]int, error)
+	// ValidateUserSettings checks that the budget items referenced by the user's settings belong to the user,
+	// user.ErrUserDataInvalid otherwise.
+	ValidateUserSettings(ctx context.Context, u user.User) error
+	// SuggestItemStyle suggests a palette color and icon not used by the items of the plan yet.
+	SuggestItemStyle(ctx context.Context, planId int) (ItemStyle, error)
+	// WithTransaction runs fn in a transaction, the changes made through the service with its ctx are committed
+	// together, or none of them when fn fails.
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	// OnItemDeleted registers a handler run in the transaction deleting a budget item, so the references to the
+	// item are removed together with it. A failing handler rolls the deletion back.
+	OnItemDeleted(handler ItemDeletedHandlerFunc)
 }
 
+// ItemDeletedHandlerFunc removes the references to a deleted budget item. ctx carries the deleting transaction.
+type ItemDeletedHandlerFunc func(ctx context.Context, itemId int) error
+
 type ServiceImpl struct {
-	repo     Repository
-	eventBus *event_bus.EventBus
+	repo                Repository
+	eventBus            *event_bus.EventBus
+	clock               utils.Clock
+	itemDeletedHandlers []ItemDeletedHandlerFunc
 }
 
 func NewBudgetPlanService(repo Repository, eventBus *event_bus.EventBus) Service {
-	return &ServiceImpl{repo: repo, eventBus: eventBus}
+	return &ServiceImpl{repo: repo, eventBus: eventBus, clock: &utils.SystemClock{}}
 }
 
 func (s *ServiceImpl) GetPlan(ctx context.Context, planId int) (BudgetPlan, error) {
@@ -61,7 +104,18 @@ func (s *ServiceImpl) CreatePlan(ctx context.Context, plan BudgetPlan) (BudgetPl
 	if err != nil {
 		return BudgetPlan{}, fmt.Errorf("failed to get current user: %w", err)
 	}
-	return s.repo.CreatePlan(ctx, userId, plan)
+	createdPlan, err := s.repo.CreatePlan(ctx, userId, plan)
+	if err != nil {
+		return BudgetPlan{}, err
+	}
+	err = s.publish(ctx, "budget_plan.plan.created", event_bus.BudgetPlanCreated{
+		Id:   createdPlan.Id,
+		Name: createdPlan.Name,
+	})
+	if err != nil {
+		return BudgetPlan{}, err
+	}
+	return createdPlan, nil
 }
 
 func (s *ServiceImpl) UpdatePlan(ctx context.Context, plan BudgetPlan) (BudgetPlan, error) {
@@ -73,6 +127,14 @@ func (s *ServiceImpl) UpdatePlan(ctx context.Context, plan BudgetPlan) (BudgetPl
 	if err != nil {
 		return BudgetPlan{}, err
 	}
+	err = s.publish(ctx, "budget_plan.plan.updated", event_bus.BudgetPlanUpdated{
+		Id:        updatedPlan.Id,
+		Name:      updatedPlan.Name,
+		IsCurrent: updatedPlan.IsCurrent,
+	})
+	if err != nil {
+		return BudgetPlan{}, err
+	}
 	return updatedPlan, nil
 }
 
@@ -81,7 +143,14 @@ func (s *ServiceImpl) DeletePlan(ctx context.Context, planId int) (bool, error)
 	if err != nil {
 		return false, fmt.Errorf("failed to get current user: %w", err)
 	}
-	return s.repo.DeletePlan(ctx, userId, planId)
+	deleted, err := s.repo.DeletePlan(ctx, userId, planId)
+	if err != nil || !deleted {
+		return deleted, err
+	}
+	if err := s.publish(ctx, "budget_plan.plan.deleted", event_bus.BudgetPlanDeleted{Id: planId}); err != nil {
+		return true, err
+	}
+	return true, nil
 }
 
 func (s *ServiceImpl) GetItem(ctx context.Context, id int) (BudgetItem, error) {
@@ -92,11 +161,42 @@ func (s *ServiceImpl) GetItem(ctx context.Context, id int) (BudgetItem, error) {
 	return s.repo.GetItem(ctx, userId, id)
 }
 
+func (s *ServiceImpl) ValidateUserSettings(ctx context.Context, u user.User) error {
+	budgetItemId := u.Settings.DefaultBudgetItemId
+	if budgetItemId == 0 {
+		return nil
+	}
+	_, err := s.GetItem(ctx, budgetItemId)
+	if errors.Is(err, ErrBudgetPlanItemNotFound) {
+		return fmt.Errorf("%w: default budget item %d not found", user.ErrUserDataInvalid, budgetItemId)
+	}
+	return err
+}
+
 func (s *ServiceImpl) CreateItem(ctx context.Context, item BudgetItem) (BudgetItem, error) {
 	userId, err := user.CurrentId(ctx)
 	if err != nil {
 		return BudgetItem{}, fmt.Errorf("failed to get current user: %w", err)
 	}
+	if err := validateDailyDurations(item.DailyDurations); err != nil {
+		return BudgetItem{}, err
+	}
+	if err := validateItemDates(item); err != nil {
+		return BudgetItem{}, err
+	}
+	if err := validateItemUnit(item); err != nil {
+		return BudgetItem{}, err
+	}
+	if err := validateItemStyle(item); err != nil {
+		return BudgetItem{}, err
+	}
+	if err := s.validateItemCategory(ctx, userId, item); err != nil {
+		return BudgetItem{}, err
+	}
+	item, err = s.applyItemHierarchy(ctx, userId, item)
+	if err != nil {
+		return BudgetItem{}, err
+	}
 
 	id, position, err := s.repo.StoreItem(ctx, userId, item)
 	if err != nil {
@@ -104,6 +204,30 @@ func (s *ServiceImpl) CreateItem(ctx context.Context, item BudgetItem) (BudgetIt
 	}
 	item.Id = id
 	item.Position = position
+	s.recordRevision(ctx, userId, Revision{
+		PlanId:         item.PlanId,
+		BudgetItemId:   item.Id,
+		ItemName:       item.Name,
+		Type:           RevisionItemAdded,
+		WeeklyDuration: item.WeeklyDuration,
+	})
+	err = s.publish(ctx, "budget_plan.item.created", event_bus.BudgetPlanItemCreated{
+		Id:                item.Id,
+		PlanId:            item.PlanId,
+		Name:              item.Name,
+		WeeklyDuration:    item.WeeklyDuration,
+		WeeklyOccurrences: item.WeeklyOccurrences,
+		DailyDurations:    item.DailyDurations,
+		Icon:              item.Icon,
+		Color:             item.Color,
+		Position:          item.Position,
+	})
+	if err != nil {
+		return BudgetItem{}, err
+	}
+	if err := s.rollUpParentDuration(ctx, userId, item.PlanId, item.ParentId); err != nil {
+		return BudgetItem{}, err
+	}
 	return item, nil
 }
 
@@ -112,37 +236,68 @@ func (s *ServiceImpl) UpdateItem(ctx context.Context, budget BudgetItem) (Budget
 	if err != nil {
 		return BudgetItem{}, fmt.Errorf("failed to get current user: %w", err)
 	}
+	if err := validateDailyDurations(budget.DailyDurations); err != nil {
+		return BudgetItem{}, err
+	}
+	if err := validateItemDates(budget); err != nil {
+		return BudgetItem{}, err
+	}
+	if err := validateItemUnit(budget); err != nil {
+		return BudgetItem{}, err
+	}
+	if err := validateItemStyle(budget); err != nil {
+		return BudgetItem{}, err
+	}
+	if err := s.validateItemCategory(ctx, userId, budget); err != nil {
+		return BudgetItem{}, err
+	}
+	budget, err = s.applyItemHierarchy(ctx, userId, budget)
+	if err != nil {
+		return BudgetItem{}, err
+	}
 
+	previousItem, err := s.repo.GetItem(ctx, userId, budget.Id)
+	if err != nil {
+		return BudgetItem{}, err
+	}
 	updatedItem, err := s.repo.UpdateItem(ctx, userId, budget)
 	if err != nil {
 		return BudgetItem{}, err
 	}
+	if previousItem.WeeklyDuration != updatedItem.WeeklyDuration {
+		s.recordRevision(ctx, userId, Revision{
+			PlanId:                 updatedItem.PlanId,
+			BudgetItemId:           updatedItem.Id,
+			ItemName:               updatedItem.Name,
+			Type:                   RevisionDurationChanged,
+			PreviousWeeklyDuration: previousItem.WeeklyDuration,
+			WeeklyDuration:         updatedItem.WeeklyDuration,
+		})
+	}
 
-	// This may fail, and because the transaction is already closed, the budget item is changed and this
-	// event may not be properly processed by the subscribers.
-	// This is a conscious decision done because of the current architecture of the application.
-	// A proper solution would be to implement inbox and outbox patterns, but this is out of scope for now.
-	// Application is running as a single process, with a single database, so the risk of the event not being processed is low.
-	// Additionally, there is an easy workaround in case data are stale. It is enough to update the budget item again.
-	err = s.eventBus.Publish(event_bus.NewEvent(
-		ctx,
-		"budget_plan.item.updated",
-		event_bus.BudgetPlanItemUpdated{
-			Id:                updatedItem.Id,
-			PlanId:            updatedItem.PlanId,
-			Name:              updatedItem.Name,
-			WeeklyDuration:    updatedItem.WeeklyDuration,
-			WeeklyOccurrences: updatedItem.WeeklyOccurrences,
-			Icon:              updatedItem.Icon,
-			Color:             updatedItem.Color,
-			Position:          updatedItem.Position,
-		},
-	))
+	err = s.publish(ctx, "budget_plan.item.updated", event_bus.BudgetPlanItemUpdated{
+		Id:                updatedItem.Id,
+		PlanId:            updatedItem.PlanId,
+		Name:              updatedItem.Name,
+		WeeklyDuration:    updatedItem.WeeklyDuration,
+		WeeklyOccurrences: updatedItem.WeeklyOccurrences,
+		DailyDurations:    updatedItem.DailyDurations,
+		Icon:              updatedItem.Icon,
+		Color:             updatedItem.Color,
+		Position:          updatedItem.Position,
+	})
 	if err != nil {
-		log.Errorf("failed to publish budget item update event: %v", err)
 		return BudgetItem{}, err
 	}
 
+	if err := s.rollUpParentDuration(ctx, userId, updatedItem.PlanId, updatedItem.ParentId); err != nil {
+		return BudgetItem{}, err
+	}
+	if previousItem.ParentId != updatedItem.ParentId {
+		if err := s.rollUpParentDuration(ctx, userId, updatedItem.PlanId, previousItem.ParentId); err != nil {
+			return BudgetItem{}, err
+		}
+	}
 	return updatedItem, nil
 }
 
@@ -152,17 +307,139 @@ func (s *ServiceImpl) DeleteItem(ctx context.Context, id int) (bool, error) {
 		return false, fmt.Errorf("failed to get current user: %w", err)
 	}
 
-	deleted, err := s.repo.DeleteItem(ctx, userId, id)
+	item, itemErr := s.repo.GetItem(ctx, userId, id)
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		deleted, err := s.repo.DeleteItem(ctx, userId, id)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			log.Warnf("item not deleted, probably because it does not exist (%d) or the user (%d) is not the owner", id, userId)
+			return fmt.Errorf("item not deleted")
+		}
+		for _, handler := range s.itemDeletedHandlers {
+			if err := handler(ctx, id); err != nil {
+				return fmt.Errorf("failed to remove references to item %d: %w", id, err)
+			}
+		}
+		return s.publish(ctx, "budget_plan.item.deleted", event_bus.BudgetPlanItemDeleted{Id: id, PlanId: item.PlanId})
+	})
 	if err != nil {
 		return false, err
 	}
-	if !deleted {
-		log.Warnf("item not deleted, probably because it does not exist (%d) or the user (%d) is not the owner", id, userId)
-		return false, fmt.Errorf("item not deleted")
+	if itemErr == nil {
+		s.recordRevision(ctx, userId, Revision{
+			PlanId:         item.PlanId,
+			BudgetItemId:   item.Id,
+			ItemName:       item.Name,
+			Type:           RevisionItemRemoved,
+			WeeklyDuration: item.WeeklyDuration,
+		})
+		if err := s.rollUpParentDuration(ctx, userId, item.PlanId, item.ParentId); err != nil {
+			return true, err
+		}
 	}
 	return true, nil
 }
 
+// publish notifies subscribers about a change that is already persisted.
+// This may fail, and because the transaction is already closed, the change is stored and this
+// event may not be properly processed by the subscribers.
+// This is a conscious decision done because of the current architecture of the application.
+// A proper solution would be to implement inbox and outbox patterns, but this is out of scope for now.
+// Application is running as a single process, with a single database, so the risk of the event not being processed is low.
+// Additionally, there is an easy workaround in case data are stale. It is enough to repeat the change.
+func (s *ServiceImpl) publish(ctx context.Context, eventType event_bus.EventType, data any) error {
+	if err := s.eventBus.Publish(event_bus.NewEvent(ctx, eventType, data)); err != nil {
+		log.Errorf("failed to publish %s event: %v", eventType, err)
+		return err
+	}
+	return nil
+}
+
+// applyItemHierarchy validates the item's parent and, for an item with sub-items, replaces its weekly
+// duration with the sum of the sub-items' durations.
+func (s *ServiceImpl) applyItemHierarchy(ctx context.Context, userId int, item BudgetItem) (BudgetItem, error) {
+	if item.ParentId == 0 && item.Id == 0 {
+		return item, nil
+	}
+	plan, err := s.repo.GetPlan(ctx, userId, item.PlanId)
+	if err != nil {
+		return BudgetItem{}, err
+	}
+	if item.ParentId != 0 {
+		parent, found := plan.FindItem(item.ParentId)
+		if !found || parent.ParentId != 0 || parent.Id == item.Id {
+			return BudgetItem{}, fmt.Errorf("%w: %d", ErrInvalidParentItem, item.ParentId)
+		}
+		if item.Id != 0 && plan.HasChildren(item.Id) {
+			return BudgetItem{}, fmt.Errorf("%w: item %d has sub-items", ErrInvalidParentItem, item.Id)
+		}
+	}
+	if children := plan.Children(item.Id); item.Id != 0 && len(children) > 0 {
+		item.WeeklyDuration = 0
+		for _, child := range children {
+			item.WeeklyDuration += child.WeeklyDuration
+		}
+	}
+	return item, nil
+}
+
+// rollUpParentDuration updates the weekly duration of the parent item to the sum of its sub-items' durations.
+func (s *ServiceImpl) rollUpParentDuration(ctx context.Context, userId int, planId int, parentId int) error {
+	if parentId == 0 {
+		return nil
+	}
+	plan, err := s.repo.GetPlan(ctx, userId, planId)
+	if err != nil {
+		return err
+	}
+	parent, found := plan.FindItem(parentId)
+	children := plan.Children(parentId)
+	// An item left without sub-items keeps its last rolled up duration
+	if !found || len(children) == 0 {
+		return nil
+	}
+	var total time.Duration
+	for _, child := range children {
+		total += child.WeeklyDuration
+	}
+	if parent.WeeklyDuration == total {
+		return nil
+	}
+	// UpdateItem sums up the sub-items again, records the revision and notifies weekly plans
+	_, err = s.UpdateItem(ctx, parent)
+	return err
+}
+
+func (s *ServiceImpl) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.repo.WithTransaction(ctx, fn)
+}
+
+func (s *ServiceImpl) OnItemDeleted(handler ItemDeletedHandlerFunc) {
+	s.itemDeletedHandlers = append(s.itemDeletedHandlers, handler)
+}
+
+func (s *ServiceImpl) GetChangelog(ctx context.Context, planId int) ([]Revision, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	if _, err := s.repo.GetPlan(ctx, userId, planId); err != nil {
+		return nil, err
+	}
+	return s.repo.GetRevisions(ctx, userId, planId)
+}
+
+// recordRevision stores the change in the plan's changelog. The item change is already persisted at this point,
+// so a failure only leaves a gap in the changelog and is logged instead of being returned.
+func (s *ServiceImpl) recordRevision(ctx context.Context, userId int, revision Revision) {
+	revision.ChangedAt = s.clock.Now()
+	if _, err := s.repo.StoreRevision(ctx, userId, revision); err != nil {
+		log.Errorf("failed to record plan revision: %v", err)
+	}
+}
+
 func (s *ServiceImpl) MoveItemAfter(ctx context.Context, planId int, itemId int, precedingId int) (bool, error) {
 	userId, err := user.CurrentId(ctx)
 	if err != nil {
@@ -219,6 +496,179 @@ func (s *ServiceImpl) reorderItems(ctx context.Context, userId int, items []Budg
 	return nil
 }
 
+func (s *ServiceImpl) GetCategories(ctx context.Context, planId int) ([]Category, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.GetCategories(ctx, userId, planId)
+}
+
+func (s *ServiceImpl) CreateCategory(ctx context.Context, category Category) (Category, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Category{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	if strings.TrimSpace(category.Name) == "" {
+		return Category{}, ErrInvalidCategory
+	}
+	return s.repo.StoreCategory(ctx, userId, category)
+}
+
+func (s *ServiceImpl) UpdateCategory(ctx context.Context, category Category) (Category, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Category{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	if strings.TrimSpace(category.Name) == "" {
+		return Category{}, ErrInvalidCategory
+	}
+	return s.repo.UpdateCategory(ctx, userId, category)
+}
+
+func (s *ServiceImpl) DeleteCategory(ctx context.Context, categoryId int) (bool, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.DeleteCategory(ctx, userId, categoryId)
+}
+
+// validateItemCategory checks that the item's category, if set, belongs to the item's plan.
+func (s *ServiceImpl) validateItemCategory(ctx context.Context, userId int, item BudgetItem) error {
+	if item.CategoryId == 0 {
+		return nil
+	}
+	categories, err := s.repo.GetCategories(ctx, userId, item.PlanId)
+	if err != nil {
+		return err
+	}
+	for _, category := range categories {
+		if category.Id == item.CategoryId {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %d", ErrCategoryNotFound, item.CategoryId)
+}
+
+func (s *ServiceImpl) SchedulePlanActivation(ctx context.Context, planId int, weekDate time.Time) (PlanActivation, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return PlanActivation{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	location, err := time.LoadLocation(currentUser.Settings.Timezone)
+	if err != nil {
+		return PlanActivation{}, fmt.Errorf("failed to load user timezone: %w", err)
+	}
+
+	effectiveFrom := utils.WeekStart(weekDate.In(location), currentUser.Settings.WeekFirstDay)
+	if !effectiveFrom.After(s.clock.Now()) {
+		return PlanActivation{}, ErrActivationNotInFuture
+	}
+	return s.repo.StorePlanActivation(ctx, currentUser.Id, PlanActivation{
+		PlanId:        planId,
+		EffectiveFrom: effectiveFrom,
+	})
+}
+
+func (s *ServiceImpl) GetPendingPlanActivations(ctx context.Context) ([]PlanActivation, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.GetPendingPlanActivations(ctx, userId)
+}
+
+func (s *ServiceImpl) CancelPlanActivation(ctx context.Context, activationId int) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	deleted, err := s.repo.DeletePlanActivation(ctx, userId, activationId)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrPlanActivationNotFound
+	}
+	return nil
+}
+
+func (s *ServiceImpl) ActivateDuePlans(ctx context.Context, now time.Time) error {
+	activations, err := s.repo.GetDuePlanActivations(ctx, now)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, activation := range activations {
+		if err := s.repo.ActivatePlan(ctx, activation, now); err != nil {
+			errs = append(errs, fmt.Errorf("plan activation %d: %w", activation.Id, err))
+			continue
+		}
+		log.Infof("Plan %d activated for user %d (effective from %s)", activation.PlanId, activation.UserId,
+			activation.EffectiveFrom.Format(time.RFC3339))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *ServiceImpl) GetUserIdsWithRolloverItems(ctx context.Context) ([]int, error) {
+	return s.repo.GetUserIdsWithRolloverItems(ctx)
+}
+
+func (s *ServiceImpl) SuggestItemStyle(ctx context.Context, planId int) (ItemStyle, error) {
+	plan, err := s.GetPlan(ctx, planId)
+	if err != nil {
+		return ItemStyle{}, err
+	}
+	return plan.SuggestStyle(), nil
+}
+
+func validateDailyDurations(dailyDurations map[time.Weekday]time.Duration) error {
+	for weekday, duration := range dailyDurations {
+		if weekday < time.Sunday || weekday > time.Saturday {
+			return fmt.Errorf("%w: invalid weekday %d", ErrInvalidDailyDuration, weekday)
+		}
+		if duration < 0 || duration > 24*time.Hour {
+			return fmt.Errorf("%w: %s", ErrInvalidDailyDuration, weekday)
+		}
+	}
+	return nil
+}
+
+func validateItemDates(item BudgetItem) error {
+	if item.StartDate != nil && item.EndDate != nil && dateOf(*item.EndDate).Before(dateOf(*item.StartDate)) {
+		return fmt.Errorf("%w: %s - %s", ErrInvalidItemDates,
+			item.StartDate.Format(time.DateOnly), item.EndDate.Format(time.DateOnly))
+	}
+	return nil
+}
+
+// validateItemUnit checks that the unit is known and that a session-based item has a number of sessions planned.
+func validateItemUnit(item BudgetItem) error {
+	switch item.Unit {
+	case "", UnitDuration:
+		return nil
+	case UnitSessions:
+		if item.WeeklyOccurrences <= 0 {
+			return fmt.Errorf("%w: no weekly occurrences", ErrInvalidItemUnit)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidItemUnit, item.Unit)
+	}
+}
+
+// validateItemStyle checks that the item has no color or a hex one, and no icon or one of the curated Icons.
+func validateItemStyle(item BudgetItem) error {
+	if item.Color != "" && !IsValidColor(item.Color) {
+		return fmt.Errorf("%w: %s", ErrInvalidItemColor, item.Color)
+	}
+	if item.Icon != "" && !IsValidIcon(item.Icon) {
+		return fmt.Errorf("%w: %s", ErrInvalidItemIcon, item.Icon)
+	}
+	return nil
+}
+
 func findPreviousAndNextPositions(previousId int, items []BudgetItem) (int, int) {
 	previousItemIdx := findItem(previousId, items)
 	if previousItemIdx == -1 {
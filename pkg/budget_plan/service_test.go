@@ -2,11 +2,13 @@ package budget_plan
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
 	"github.com/klokku/klokku/pkg/user"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -243,6 +245,82 @@ func TestServiceImpl_DeletePlan(t *testing.T) {
 	})
 }
 
+func TestServiceImpl_PublishesChangeEvents(t *testing.T) {
+	subscribe := func(bus *event_bus.EventBus, eventType event_bus.EventType, published *[]any) {
+		bus.Subscribe(eventType, func(e event_bus.Event) error {
+			*published = append(*published, e.Data)
+			return nil
+		})
+	}
+
+	t.Run("should publish plan events", func(t *testing.T) {
+		bus := event_bus.NewEventBus()
+		s := NewBudgetPlanService(NewStubBudgetRepo(), bus)
+		var published []any
+		for _, eventType := range []event_bus.EventType{"budget_plan.plan.created", "budget_plan.plan.updated", "budget_plan.plan.deleted"} {
+			subscribe(bus, eventType, &published)
+		}
+
+		// when
+		_, err := s.CreatePlan(ctx, BudgetPlan{Name: "Current"})
+		require.NoError(t, err)
+		plan, err := s.CreatePlan(ctx, BudgetPlan{Name: "Draft"})
+		require.NoError(t, err)
+		plan.Name = "Holidays"
+		_, err = s.UpdatePlan(ctx, plan)
+		require.NoError(t, err)
+		_, err = s.DeletePlan(ctx, plan.Id)
+		require.NoError(t, err)
+
+		// then
+		require.Len(t, published, 4)
+		assert.Equal(t, event_bus.BudgetPlanCreated{Id: plan.Id, Name: "Draft"}, published[1])
+		assert.Equal(t, event_bus.BudgetPlanUpdated{Id: plan.Id, Name: "Holidays"}, published[2])
+		assert.Equal(t, event_bus.BudgetPlanDeleted{Id: plan.Id}, published[3])
+	})
+
+	t.Run("should publish item events", func(t *testing.T) {
+		bus := event_bus.NewEventBus()
+		s := NewBudgetPlanService(NewStubBudgetRepo(), bus)
+		plan, err := s.CreatePlan(ctx, BudgetPlan{Name: "Plan"})
+		require.NoError(t, err)
+		var published []any
+		for _, eventType := range []event_bus.EventType{"budget_plan.item.created", "budget_plan.item.deleted"} {
+			subscribe(bus, eventType, &published)
+		}
+
+		// when
+		item, err := s.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Work", WeeklyDuration: 40 * time.Hour, Icon: "work"})
+		require.NoError(t, err)
+		_, err = s.DeleteItem(ctx, item.Id)
+		require.NoError(t, err)
+
+		// then
+		require.Len(t, published, 2)
+		created := published[0].(event_bus.BudgetPlanItemCreated)
+		assert.Equal(t, item.Id, created.Id)
+		assert.Equal(t, plan.Id, created.PlanId)
+		assert.Equal(t, "Work", created.Name)
+		assert.Equal(t, 40*time.Hour, created.WeeklyDuration)
+		assert.Equal(t, "work", created.Icon)
+		assert.Equal(t, event_bus.BudgetPlanItemDeleted{Id: item.Id, PlanId: plan.Id}, published[1])
+	})
+
+	t.Run("should not publish when nothing is deleted", func(t *testing.T) {
+		bus := event_bus.NewEventBus()
+		s := NewBudgetPlanService(NewStubBudgetRepo(), bus)
+		var published []any
+		subscribe(bus, "budget_plan.item.deleted", &published)
+
+		// when
+		_, err := s.DeleteItem(ctx, 999)
+
+		// then
+		assert.Error(t, err)
+		assert.Empty(t, published)
+	})
+}
+
 func TestServiceImpl_CreateItem(t *testing.T) {
 	t.Run("should create a budget item with correct position", func(t *testing.T) {
 		teardown := setup(t)
@@ -257,7 +335,7 @@ func TestServiceImpl_CreateItem(t *testing.T) {
 			Name:              "Test Item",
 			WeeklyDuration:    time.Duration(2) * time.Hour,
 			WeeklyOccurrences: 3,
-			Icon:              "SomeIcon",
+			Icon:              "book",
 			Color:             "#FF0000",
 		})
 
@@ -267,7 +345,7 @@ func TestServiceImpl_CreateItem(t *testing.T) {
 		assert.Equal(t, "Test Item", item.Name)
 		assert.Equal(t, time.Duration(2)*time.Hour, item.WeeklyDuration)
 		assert.Equal(t, 3, item.WeeklyOccurrences)
-		assert.Equal(t, "SomeIcon", item.Icon)
+		assert.Equal(t, "book", item.Icon)
 		assert.Equal(t, "#FF0000", item.Color)
 		assert.Equal(t, 100, item.Position) // First item should have position 100
 	})
@@ -288,6 +366,50 @@ func TestServiceImpl_CreateItem(t *testing.T) {
 		assert.Greater(t, item2.Position, item1.Position)
 	})
 
+	t.Run("should create an item with daily durations", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+		dailyDurations := map[time.Weekday]time.Duration{
+			time.Monday:  8 * time.Hour,
+			time.Tuesday: 8 * time.Hour,
+		}
+
+		// when
+		item, err := service.CreateItem(ctx, BudgetItem{
+			PlanId:         plan.Id,
+			Name:           "Work",
+			WeeklyDuration: 16 * time.Hour,
+			DailyDurations: dailyDurations,
+		})
+
+		// then
+		require.NoError(t, err)
+		stored, err := service.GetItem(ctx, item.Id)
+		require.NoError(t, err)
+		assert.Equal(t, dailyDurations, stored.DailyDurations)
+	})
+
+	t.Run("should reject daily duration longer than a day", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+
+		// when
+		_, err := service.CreateItem(ctx, BudgetItem{
+			PlanId:         plan.Id,
+			Name:           "Work",
+			DailyDurations: map[time.Weekday]time.Duration{time.Monday: 25 * time.Hour},
+		})
+
+		// then
+		assert.ErrorIs(t, err, ErrInvalidDailyDuration)
+	})
+
 	t.Run("should return error when context has no user", func(t *testing.T) {
 		teardown := setup(t)
 		defer teardown()
@@ -313,7 +435,7 @@ func TestServiceImpl_UpdateItem(t *testing.T) {
 			Name:              "Original",
 			WeeklyDuration:    time.Duration(2) * time.Hour,
 			WeeklyOccurrences: 3,
-			Icon:              "SomeIcon",
+			Icon:              "book",
 			Color:             "#FF0000"})
 		item.Name = "Updated"
 		item.WeeklyDuration = time.Duration(4) * time.Hour
@@ -350,7 +472,7 @@ func TestServiceImpl_UpdateItem(t *testing.T) {
 			Name:              "Original",
 			WeeklyDuration:    time.Duration(2) * time.Hour,
 			WeeklyOccurrences: 3,
-			Icon:              "SomeIcon",
+			Icon:              "book",
 			Color:             "#FF0000"})
 		item.Name = "Updated"
 		item.WeeklyDuration = time.Duration(4) * time.Hour
@@ -379,6 +501,34 @@ func TestServiceImpl_UpdateItem(t *testing.T) {
 		assert.Equal(t, item.Color, publishedEvent.Data.Color)
 
 	})
+
+	t.Run("should publish daily durations when item is updated", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+		item, _ := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Work", WeeklyDuration: 40 * time.Hour})
+		item.DailyDurations = map[time.Weekday]time.Duration{time.Friday: 4 * time.Hour}
+
+		var publishedEvent event_bus.EventT[event_bus.BudgetPlanItemUpdated]
+		event_bus.SubscribeTyped[event_bus.BudgetPlanItemUpdated](
+			eventBus,
+			"budget_plan.item.updated",
+			func(e event_bus.EventT[event_bus.BudgetPlanItemUpdated]) error {
+				publishedEvent = e
+				return nil
+			},
+		)
+
+		// when
+		updatedItem, err := service.UpdateItem(ctx, item)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, item.DailyDurations, updatedItem.DailyDurations)
+		assert.Equal(t, item.DailyDurations, publishedEvent.Data.DailyDurations)
+	})
 }
 
 func TestServiceImpl_DeleteItem(t *testing.T) {
@@ -398,6 +548,67 @@ func TestServiceImpl_DeleteItem(t *testing.T) {
 		assert.True(t, deleted)
 	})
 
+	t.Run("should remove the references to the deleted item", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+		item, _ := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "To Delete"})
+		var unlinkedItemId int
+		service.OnItemDeleted(func(ctx context.Context, itemId int) error {
+			unlinkedItemId = itemId
+			return nil
+		})
+
+		// when
+		deleted, err := service.DeleteItem(ctx, item.Id)
+
+		// then
+		assert.NoError(t, err)
+		assert.True(t, deleted)
+		assert.Equal(t, item.Id, unlinkedItemId)
+	})
+
+	t.Run("should fail when the references cannot be removed", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+		item, _ := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "To Delete"})
+		unlinkErr := errors.New("unlink failed")
+		service.OnItemDeleted(func(ctx context.Context, itemId int) error {
+			return unlinkErr
+		})
+
+		// when
+		deleted, err := service.DeleteItem(ctx, item.Id)
+
+		// then
+		assert.ErrorIs(t, err, unlinkErr)
+		assert.False(t, deleted)
+	})
+
+	t.Run("should not delete an item tracked by the current event", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+		item, _ := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Tracked"})
+		budgetRepoStub.TrackItem(item.Id)
+
+		// when
+		deleted, err := service.DeleteItem(ctx, item.Id)
+
+		// then
+		assert.ErrorIs(t, err, ErrItemTracked)
+		assert.False(t, deleted)
+		_, err = service.GetItem(ctx, item.Id)
+		assert.NoError(t, err)
+	})
+
 	t.Run("should return error when context has no user", func(t *testing.T) {
 		teardown := setup(t)
 		defer teardown()
@@ -523,7 +734,7 @@ func TestServiceImpl_GetItem(t *testing.T) {
 			Name:              "Original",
 			WeeklyDuration:    time.Duration(2) * time.Hour,
 			WeeklyOccurrences: 3,
-			Icon:              "SomeIcon",
+			Icon:              "book",
 			Color:             "#FF0000",
 		})
 
@@ -540,3 +751,550 @@ func TestServiceImpl_GetItem(t *testing.T) {
 		assert.Equal(t, item.Color, readItem.Color)
 	})
 }
+
+func TestServiceImpl_ValidateUserSettings(t *testing.T) {
+	t.Run("should accept an own default budget item", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+		item, _ := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Reading", WeeklyDuration: time.Hour})
+		currentUser, _ := user.CurrentUser(ctx)
+		currentUser.Settings.DefaultBudgetItemId = item.Id
+
+		// when
+		err := service.ValidateUserSettings(ctx, currentUser)
+
+		// then
+		assert.NoError(t, err)
+	})
+
+	t.Run("should reject an unknown default budget item", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		currentUser, _ := user.CurrentUser(ctx)
+		currentUser.Settings.DefaultBudgetItemId = 999
+
+		// when
+		err := service.ValidateUserSettings(ctx, currentUser)
+
+		// then
+		assert.ErrorIs(t, err, user.ErrUserDataInvalid)
+	})
+}
+
+func TestServiceImpl_PlanActivation(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Warsaw")
+	now := time.Date(2025, time.July, 2, 12, 0, 0, 0, location) // Wednesday
+	setupWithClock := func(t *testing.T) func() {
+		teardown := setup(t)
+		service.(*ServiceImpl).clock = &utils.MockClock{FixedNow: now}
+		return teardown
+	}
+
+	t.Run("should schedule activation from the start of the week", func(t *testing.T) {
+		teardown := setupWithClock(t)
+		defer teardown()
+
+		// given
+		_, _ = service.CreatePlan(ctx, BudgetPlan{Name: "Regular"})
+		holidayPlan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Holiday"})
+
+		// when
+		activation, err := service.SchedulePlanActivation(ctx, holidayPlan.Id, time.Date(2025, time.July, 17, 15, 0, 0, 0, location))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, holidayPlan.Id, activation.PlanId)
+		assert.Equal(t, time.Date(2025, time.July, 14, 0, 0, 0, 0, location), activation.EffectiveFrom)
+		pending, err := service.GetPendingPlanActivations(ctx)
+		require.NoError(t, err)
+		assert.Len(t, pending, 1)
+	})
+
+	t.Run("should replace activation scheduled for the same week", func(t *testing.T) {
+		teardown := setupWithClock(t)
+		defer teardown()
+
+		// given
+		plan1, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Plan 1"})
+		plan2, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Plan 2"})
+		_, err := service.SchedulePlanActivation(ctx, plan1.Id, time.Date(2025, time.July, 14, 0, 0, 0, 0, location))
+		require.NoError(t, err)
+
+		// when
+		_, err = service.SchedulePlanActivation(ctx, plan2.Id, time.Date(2025, time.July, 20, 0, 0, 0, 0, location))
+
+		// then
+		require.NoError(t, err)
+		pending, _ := service.GetPendingPlanActivations(ctx)
+		require.Len(t, pending, 1)
+		assert.Equal(t, plan2.Id, pending[0].PlanId)
+	})
+
+	t.Run("should reject activation of the current week", func(t *testing.T) {
+		teardown := setupWithClock(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Plan"})
+
+		// when
+		_, err := service.SchedulePlanActivation(ctx, plan.Id, now.Add(24*time.Hour))
+
+		// then
+		assert.ErrorIs(t, err, ErrActivationNotInFuture)
+	})
+
+	t.Run("should reject activation of a non-existent plan", func(t *testing.T) {
+		teardown := setupWithClock(t)
+		defer teardown()
+
+		// when
+		_, err := service.SchedulePlanActivation(ctx, 999, now.AddDate(0, 0, 7))
+
+		// then
+		assert.ErrorIs(t, err, ErrPlanNotFound)
+	})
+
+	t.Run("should cancel pending activation", func(t *testing.T) {
+		teardown := setupWithClock(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Plan"})
+		activation, _ := service.SchedulePlanActivation(ctx, plan.Id, now.AddDate(0, 0, 7))
+
+		// when
+		err := service.CancelPlanActivation(ctx, activation.Id)
+
+		// then
+		require.NoError(t, err)
+		pending, _ := service.GetPendingPlanActivations(ctx)
+		assert.Empty(t, pending)
+		assert.ErrorIs(t, service.CancelPlanActivation(ctx, activation.Id), ErrPlanActivationNotFound)
+	})
+
+	t.Run("should switch current plan when activation is due", func(t *testing.T) {
+		teardown := setupWithClock(t)
+		defer teardown()
+
+		// given
+		regularPlan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Regular", IsCurrent: true})
+		_, _ = service.UpdatePlan(ctx, BudgetPlan{Id: regularPlan.Id, Name: "Regular", IsCurrent: true})
+		holidayPlan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Holiday"})
+		activation, _ := service.SchedulePlanActivation(ctx, holidayPlan.Id, now.AddDate(0, 0, 7))
+
+		// when - a tick before the week starts
+		err := service.ActivateDuePlans(ctx, activation.EffectiveFrom.Add(-time.Minute))
+
+		// then
+		require.NoError(t, err)
+		current, _ := service.GetCurrentPlan(ctx)
+		assert.Equal(t, regularPlan.Id, current.Id)
+
+		// when - the week started
+		err = service.ActivateDuePlans(ctx, activation.EffectiveFrom.Add(time.Minute))
+
+		// then
+		require.NoError(t, err)
+		current, _ = service.GetCurrentPlan(ctx)
+		assert.Equal(t, holidayPlan.Id, current.Id)
+		pending, _ := service.GetPendingPlanActivations(ctx)
+		assert.Empty(t, pending)
+	})
+}
+
+func TestServiceImpl_Categories(t *testing.T) {
+	t.Run("should create categories with incrementing positions", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+
+		// when
+		work, err1 := service.CreateCategory(ctx, Category{PlanId: plan.Id, Name: "Work", Color: "#0000FF"})
+		health, err2 := service.CreateCategory(ctx, Category{PlanId: plan.Id, Name: "Health"})
+
+		// then
+		require.NoError(t, err1)
+		require.NoError(t, err2)
+		assert.NotZero(t, work.Id)
+		assert.Equal(t, "#0000FF", work.Color)
+		assert.Less(t, work.Position, health.Position)
+		categories, err := service.GetCategories(ctx, plan.Id)
+		require.NoError(t, err)
+		assert.Len(t, categories, 2)
+	})
+
+	t.Run("should reject category without name", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+
+		// when
+		_, err := service.CreateCategory(ctx, Category{PlanId: plan.Id, Name: "  "})
+
+		// then
+		assert.ErrorIs(t, err, ErrInvalidCategory)
+	})
+
+	t.Run("should reject item with category from another plan", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+		otherPlan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Other Plan"})
+		category, _ := service.CreateCategory(ctx, Category{PlanId: otherPlan.Id, Name: "Work"})
+
+		// when
+		_, err := service.CreateItem(ctx, BudgetItem{
+			PlanId:         plan.Id,
+			Name:           "Coding",
+			WeeklyDuration: time.Hour,
+			CategoryId:     category.Id,
+		})
+
+		// then
+		assert.ErrorIs(t, err, ErrCategoryNotFound)
+	})
+
+	t.Run("should uncategorize items when category is deleted", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+		category, _ := service.CreateCategory(ctx, Category{PlanId: plan.Id, Name: "Work"})
+		item, err := service.CreateItem(ctx, BudgetItem{
+			PlanId:         plan.Id,
+			Name:           "Coding",
+			WeeklyDuration: time.Hour,
+			CategoryId:     category.Id,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, category.Id, item.CategoryId)
+
+		// when
+		deleted, err := service.DeleteCategory(ctx, category.Id)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, deleted)
+		result, err := service.GetPlan(ctx, plan.Id)
+		require.NoError(t, err)
+		assert.Empty(t, result.Categories)
+		require.Len(t, result.Items, 1)
+		assert.Zero(t, result.Items[0].CategoryId)
+	})
+}
+
+func TestServiceImpl_GetChangelog(t *testing.T) {
+	t.Run("should record item additions, duration changes and removals", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		clock := &utils.MockClock{FixedNow: time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC)}
+		service.(*ServiceImpl).clock = clock
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+		item, err := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Reading", WeeklyDuration: 5 * time.Hour})
+		require.NoError(t, err)
+
+		clock.SetNow(time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC))
+		item.Icon = "book"
+		_, err = service.UpdateItem(ctx, item) // no duration change, not recorded
+		require.NoError(t, err)
+		item.WeeklyDuration = 90 * time.Minute
+		_, err = service.UpdateItem(ctx, item)
+		require.NoError(t, err)
+
+		clock.SetNow(time.Date(2025, 3, 17, 9, 0, 0, 0, time.UTC))
+		_, err = service.DeleteItem(ctx, item.Id)
+		require.NoError(t, err)
+
+		// when
+		changelog, err := service.GetChangelog(ctx, plan.Id)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, changelog, 3)
+		assert.Equal(t, RevisionItemAdded, changelog[0].Type)
+		assert.Equal(t, "Added Reading (5h per week)", changelog[0].Description())
+		assert.Equal(t, time.Date(2025, 3, 3, 9, 0, 0, 0, time.UTC), changelog[0].ChangedAt)
+		assert.Equal(t, RevisionDurationChanged, changelog[1].Type)
+		assert.Equal(t, "Changed Reading from 5h to 1h30m per week", changelog[1].Description())
+		assert.Equal(t, RevisionItemRemoved, changelog[2].Type)
+		assert.Equal(t, "Removed Reading (1h30m per week)", changelog[2].Description())
+		assert.Equal(t, item.Id, changelog[2].BudgetItemId)
+	})
+
+	t.Run("should return error when plan does not exist", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// when
+		_, err := service.GetChangelog(ctx, 999)
+
+		// then
+		assert.ErrorIs(t, err, ErrPlanNotFound)
+	})
+}
+
+func TestServiceImpl_ItemDates(t *testing.T) {
+	t.Run("should store the item's active window", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+		start := time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC)
+		end := start.AddDate(0, 0, 6*7-1)
+
+		// when
+		item, err := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Course", WeeklyDuration: 3 * time.Hour, StartDate: &start, EndDate: &end})
+		require.NoError(t, err)
+
+		// then
+		stored, err := service.GetItem(ctx, item.Id)
+		require.NoError(t, err)
+		assert.Equal(t, start, *stored.StartDate)
+		assert.Equal(t, end, *stored.EndDate)
+	})
+
+	t.Run("should reject end date before start date", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+		start := time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC)
+		end := start.AddDate(0, 0, -1)
+		item, _ := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Course", WeeklyDuration: time.Hour})
+
+		// when
+		_, createErr := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Course 2", WeeklyDuration: time.Hour, StartDate: &start, EndDate: &end})
+		item.StartDate = &start
+		item.EndDate = &end
+		_, updateErr := service.UpdateItem(ctx, item)
+
+		// then
+		assert.ErrorIs(t, createErr, ErrInvalidItemDates)
+		assert.ErrorIs(t, updateErr, ErrInvalidItemDates)
+	})
+}
+
+func TestServiceImpl_ItemUnit(t *testing.T) {
+	t.Run("should store a session-based item", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+
+		// when
+		item, err := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Gym", WeeklyOccurrences: 3, Unit: UnitSessions})
+		require.NoError(t, err)
+
+		// then
+		stored, err := service.GetItem(ctx, item.Id)
+		require.NoError(t, err)
+		assert.True(t, stored.IsSessionBased())
+		assert.Equal(t, 3, stored.WeeklyOccurrences)
+	})
+
+	t.Run("should reject unknown units and sessions without occurrences", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+		item, _ := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Gym", WeeklyDuration: time.Hour})
+
+		// when
+		_, unknownErr := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Reading", Unit: "pages"})
+		item.Unit = UnitSessions
+		_, updateErr := service.UpdateItem(ctx, item)
+
+		// then
+		assert.ErrorIs(t, unknownErr, ErrInvalidItemUnit)
+		assert.ErrorIs(t, updateErr, ErrInvalidItemUnit)
+	})
+}
+
+func TestServiceImpl_ItemStyle(t *testing.T) {
+	t.Run("should reject colors that are not hex colors", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+		item, err := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Gym", Color: "#3b82f6"})
+		require.NoError(t, err)
+
+		// when
+		_, createErr := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Reading", Color: "blue"})
+		item.Color = "#3B82F"
+		_, updateErr := service.UpdateItem(ctx, item)
+
+		// then
+		assert.ErrorIs(t, createErr, ErrInvalidItemColor)
+		assert.ErrorIs(t, updateErr, ErrInvalidItemColor)
+	})
+
+	t.Run("should reject icons that are not curated", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+		item, err := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Gym", Icon: "run"})
+		require.NoError(t, err)
+
+		// when
+		_, createErr := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Reading", Icon: "📚"})
+		item.Icon = "Run"
+		_, updateErr := service.UpdateItem(ctx, item)
+
+		// then
+		assert.ErrorIs(t, createErr, ErrInvalidItemIcon)
+		assert.ErrorIs(t, updateErr, ErrInvalidItemIcon)
+	})
+
+	t.Run("should suggest the first unused palette color and icon", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+		_, err := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Work", Color: "#3b82f6", Icon: "work"})
+		require.NoError(t, err)
+		_, err = service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Gym", Color: Colors[2], Icon: "run"})
+		require.NoError(t, err)
+
+		// when
+		style, err := service.SuggestItemStyle(ctx, plan.Id)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, ItemStyle{Color: Colors[1], Icon: "sleep"}, style)
+	})
+}
+
+func TestBudgetPlan_SuggestStyle(t *testing.T) {
+	// given
+	plan := BudgetPlan{}
+	for _, color := range Colors {
+		plan.Items = append(plan.Items, BudgetItem{Color: color})
+	}
+	plan.Items = append(plan.Items, BudgetItem{Color: Colors[0]})
+
+	// when
+	style := plan.SuggestStyle()
+
+	// then
+	assert.Equal(t, Colors[1], style.Color)
+	assert.Equal(t, Icons[0], style.Icon)
+}
+
+func TestBudgetItem_IsActiveBetween(t *testing.T) {
+	date := func(month time.Month, day int) *time.Time {
+		d := time.Date(2025, month, day, 0, 0, 0, 0, time.UTC)
+		return &d
+	}
+	weekStart := time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC)
+	weekEnd := time.Date(2025, time.March, 16, 23, 59, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		item   BudgetItem
+		expect bool
+	}{
+		{"no window", BudgetItem{}, true},
+		{"starts on last day", BudgetItem{StartDate: date(time.March, 16)}, true},
+		{"starts after range", BudgetItem{StartDate: date(time.March, 17)}, false},
+		{"ends on first day", BudgetItem{EndDate: date(time.March, 10)}, true},
+		{"ended before range", BudgetItem{EndDate: date(time.March, 9)}, false},
+		{"window inside range", BudgetItem{StartDate: date(time.March, 11), EndDate: date(time.March, 12)}, true},
+		{"window around range", BudgetItem{StartDate: date(time.February, 1), EndDate: date(time.April, 1)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, tt.item.IsActiveBetween(weekStart, weekEnd))
+		})
+	}
+}
+
+func TestServiceImpl_SubItems(t *testing.T) {
+	t.Run("should roll up sub-item durations into the parent", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+		work, err := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Work", WeeklyDuration: 40 * time.Hour})
+		require.NoError(t, err)
+
+		// when
+		meetings, err := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Meetings", WeeklyDuration: 5 * time.Hour, ParentId: work.Id})
+		require.NoError(t, err)
+		coding, err := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Coding", WeeklyDuration: 20 * time.Hour, ParentId: work.Id})
+		require.NoError(t, err)
+
+		// then
+		parent, err := service.GetItem(ctx, work.Id)
+		require.NoError(t, err)
+		assert.Equal(t, 25*time.Hour, parent.WeeklyDuration)
+
+		// when
+		coding.WeeklyDuration = 30 * time.Hour
+		_, err = service.UpdateItem(ctx, coding)
+		require.NoError(t, err)
+		parent.WeeklyDuration = time.Hour // ignored, the parent holds the sum of sub-items
+		parent, err = service.UpdateItem(ctx, parent)
+		require.NoError(t, err)
+
+		// then
+		assert.Equal(t, 35*time.Hour, parent.WeeklyDuration)
+
+		// when
+		_, err = service.DeleteItem(ctx, meetings.Id)
+		require.NoError(t, err)
+
+		// then
+		parent, err = service.GetItem(ctx, work.Id)
+		require.NoError(t, err)
+		assert.Equal(t, 30*time.Hour, parent.WeeklyDuration)
+	})
+
+	t.Run("should allow only one level of sub-items", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		plan, _ := service.CreatePlan(ctx, BudgetPlan{Name: "Test Plan"})
+		work, _ := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Work", WeeklyDuration: time.Hour})
+		coding, err := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Coding", WeeklyDuration: time.Hour, ParentId: work.Id})
+		require.NoError(t, err)
+		reading, _ := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Reading", WeeklyDuration: time.Hour})
+
+		// when
+		_, nestedErr := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Go", WeeklyDuration: time.Hour, ParentId: coding.Id})
+		work.ParentId = reading.Id
+		_, parentWithChildrenErr := service.UpdateItem(ctx, work)
+		_, unknownParentErr := service.CreateItem(ctx, BudgetItem{PlanId: plan.Id, Name: "Go", WeeklyDuration: time.Hour, ParentId: 999})
+
+		// then
+		assert.ErrorIs(t, nestedErr, ErrInvalidParentItem)
+		assert.ErrorIs(t, parentWithChildrenErr, ErrInvalidParentItem)
+		assert.ErrorIs(t, unknownParentErr, ErrInvalidParentItem)
+	})
+}
@@ -2,6 +2,8 @@ package budget_plan_report
 
 import (
 	"time"
+
+	"github.com/klokku/klokku/pkg/budget_plan"
 )
 
 // ReportItem holds the three metrics for a single budget item within a period.
@@ -42,6 +44,8 @@ type Report struct {
 	TotalBudgetPlanTime time.Duration
 	TotalWeeklyPlanTime time.Duration
 	TotalActualTime     time.Duration
+	// Changelog holds the changes of the plan's items made within the period, oldest first
+	Changelog []budget_plan.Revision
 }
 
 // ItemDetailReport holds detailed statistics for a single budget item over a period.
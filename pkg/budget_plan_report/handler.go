@@ -9,6 +9,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/pkg/budget_plan"
 )
 
 type ReportItemDTO struct {
@@ -50,6 +51,8 @@ type ReportDTO struct {
 	ExcludedWeekCount int                    `json:"excludedWeekCount"`
 	Weeks             []WeeklyReportEntryDTO `json:"weeks"`
 	Totals            ReportTotalsDTO        `json:"totals"`
+	// Changelog lists the changes of the plan's items made within the period, oldest first
+	Changelog []budget_plan.ChangelogEntryDTO `json:"changelog"`
 }
 
 // --- Item Detail Report DTOs ---
@@ -249,6 +252,10 @@ func reportToDTO(report Report) ReportDTO {
 	for _, w := range report.Weeks {
 		weeks = append(weeks, weeklyEntryToDTO(w))
 	}
+	changelog := make([]budget_plan.ChangelogEntryDTO, 0, len(report.Changelog))
+	for _, revision := range report.Changelog {
+		changelog = append(changelog, budget_plan.RevisionToDTO(revision))
+	}
 
 	return ReportDTO{
 		PlanId:            report.PlanId,
@@ -264,6 +271,7 @@ func reportToDTO(report Report) ReportDTO {
 			TotalWeeklyPlanTime: int(report.TotalWeeklyPlanTime.Seconds()),
 			TotalActualTime:     int(report.TotalActualTime.Seconds()),
 		},
+		Changelog: changelog,
 	}
 }
 
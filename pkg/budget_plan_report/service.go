@@ -20,10 +20,11 @@ type Service interface {
 
 type budgetPlanReader interface {
 	GetPlan(ctx context.Context, planId int) (budget_plan.BudgetPlan, error)
+	GetChangelog(ctx context.Context, planId int) ([]budget_plan.Revision, error)
 }
 
 type calendarEventsReader interface {
-	GetEvents(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error)
+	GetEventsIncludingArchive(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error)
 }
 
 type earliestEventFinder interface {
@@ -99,18 +100,19 @@ func (s *ServiceImpl) GetReport(ctx context.Context, planId int, from *time.Time
 	// Calculate range boundaries
 	var rangeStart, rangeEnd time.Time
 	if from != nil && to != nil {
-		rangeStart = weekStart(from.In(userTimezone), weekFirstDay)
+		rangeStart = utils.WeekStart(from.In(userTimezone), weekFirstDay)
 		_, rangeEnd = weekTimeRange(to.In(userTimezone), weekFirstDay)
 	} else {
-		rangeStart = weekStart(earliest.In(userTimezone), weekFirstDay)
+		rangeStart = utils.WeekStart(earliest.In(userTimezone), weekFirstDay)
 		_, rangeEnd = weekTimeRange(s.clock.Now().In(userTimezone), weekFirstDay)
 	}
 
 	// Fetch all calendar events in the range
-	allEvents, err := s.calendarReader.GetEvents(ctx, rangeStart, rangeEnd)
+	allEvents, err := s.calendarReader.GetEventsIncludingArchive(ctx, rangeStart, rangeEnd)
 	if err != nil {
 		return Report{}, fmt.Errorf("failed to get calendar events: %w", err)
 	}
+	allEvents = calendar.WithoutSandbox(allEvents)
 
 	// Filter events: only those whose budget_item_id is in this plan's current items
 	budgetItemIdSet := make(map[int]bool, len(budgetItemIds))
@@ -124,7 +126,7 @@ func (s *ServiceImpl) GetReport(ctx context.Context, planId int, from *time.Time
 		if !budgetItemIdSet[e.Metadata.BudgetItemId] {
 			continue
 		}
-		ws := weekStart(e.StartTime.In(userTimezone), weekFirstDay)
+		ws := utils.WeekStart(e.StartTime.In(userTimezone), weekFirstDay)
 		eventsByWeek[ws] = append(eventsByWeek[ws], e)
 	}
 
@@ -246,6 +248,11 @@ func (s *ServiceImpl) GetReport(ctx context.Context, planId int, from *time.Time
 		endDate = weeks[len(weeks)-1].EndDate
 	}
 
+	changelog, err := s.changelog(ctx, bp.Id, rangeStart, rangeEnd)
+	if err != nil {
+		return Report{}, err
+	}
+
 	return Report{
 		PlanId:              bp.Id,
 		PlanName:            bp.Name,
@@ -258,9 +265,25 @@ func (s *ServiceImpl) GetReport(ctx context.Context, planId int, from *time.Time
 		TotalBudgetPlanTime: totalBudget,
 		TotalWeeklyPlanTime: totalWeekly,
 		TotalActualTime:     totalActual,
+		Changelog:           changelog,
 	}, nil
 }
 
+// changelog returns the changes of the plan's items made within the range, oldest first.
+func (s *ServiceImpl) changelog(ctx context.Context, planId int, from time.Time, to time.Time) ([]budget_plan.Revision, error) {
+	revisions, err := s.budgetPlanReader.GetChangelog(ctx, planId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get budget plan changelog: %w", err)
+	}
+	changelog := make([]budget_plan.Revision, 0, len(revisions))
+	for _, revision := range revisions {
+		if !revision.ChangedAt.Before(from) && !revision.ChangedAt.After(to) {
+			changelog = append(changelog, revision)
+		}
+	}
+	return changelog, nil
+}
+
 func (s *ServiceImpl) GetItemReport(ctx context.Context, planId int, itemId int, from *time.Time, to *time.Time) (ItemDetailReport, error) {
 	currentUser, err := user.CurrentUser(ctx)
 	if err != nil {
@@ -309,18 +332,19 @@ func (s *ServiceImpl) GetItemReport(ctx context.Context, planId int, itemId int,
 	// Calculate range
 	var rangeStart, rangeEnd time.Time
 	if from != nil && to != nil {
-		rangeStart = weekStart(from.In(userTimezone), weekFirstDay)
+		rangeStart = utils.WeekStart(from.In(userTimezone), weekFirstDay)
 		_, rangeEnd = weekTimeRange(to.In(userTimezone), weekFirstDay)
 	} else {
-		rangeStart = weekStart(earliest.In(userTimezone), weekFirstDay)
+		rangeStart = utils.WeekStart(earliest.In(userTimezone), weekFirstDay)
 		_, rangeEnd = weekTimeRange(s.clock.Now().In(userTimezone), weekFirstDay)
 	}
 
 	// Fetch and filter events to this item only
-	allEvents, err := s.calendarReader.GetEvents(ctx, rangeStart, rangeEnd)
+	allEvents, err := s.calendarReader.GetEventsIncludingArchive(ctx, rangeStart, rangeEnd)
 	if err != nil {
 		return ItemDetailReport{}, fmt.Errorf("failed to get calendar events: %w", err)
 	}
+	allEvents = calendar.WithoutSandbox(allEvents)
 
 	var itemEvents []calendar.Event
 	for _, e := range allEvents {
@@ -329,15 +353,18 @@ func (s *ServiceImpl) GetItemReport(ctx context.Context, planId int, itemId int,
 		}
 	}
 
-	// Group events by week and by day
+	// Group events by week and by day. Days are keyed by their date at midnight, an event before the user's day start
+	// hour counts into the previous date.
+	dayBoundary := utils.NewDayBoundary(userTimezone).WithStartHour(currentUser.Settings.DayStartHour)
 	eventsByWeek := make(map[time.Time][]calendar.Event)
 	dailyDurations := make(map[time.Time]time.Duration)
 	for _, e := range itemEvents {
 		eventInTz := e.StartTime.In(userTimezone)
-		ws := weekStart(eventInTz, weekFirstDay)
+		ws := utils.WeekStart(eventInTz, weekFirstDay)
 		eventsByWeek[ws] = append(eventsByWeek[ws], e)
 
-		dayKey := time.Date(eventInTz.Year(), eventInTz.Month(), eventInTz.Day(), 0, 0, 0, 0, userTimezone)
+		year, month, day := dayBoundary.Date(e.StartTime)
+		dayKey := time.Date(year, month, day, 0, 0, 0, 0, userTimezone)
 		dailyDurations[dayKey] += e.EndTime.Sub(e.StartTime)
 	}
 
@@ -408,7 +435,7 @@ func (s *ServiceImpl) GetItemReport(ctx context.Context, planId int, itemId int,
 	}
 
 	for dayKey, dur := range dailyDurations {
-		ws := weekStart(dayKey, weekFirstDay)
+		ws := utils.WeekStart(dayKey, weekFirstDay)
 		if offWeekStarts[ws] {
 			continue // skip days in off-weeks
 		}
@@ -463,7 +490,7 @@ func (s *ServiceImpl) GetItemReport(ctx context.Context, planId int, itemId int,
 				continue
 			}
 			dur := dailyDurations[d]
-			if offWeekStarts[weekStart(d, weekFirstDay)] {
+			if offWeekStarts[utils.WeekStart(d, weekFirstDay)] {
 				continue
 			}
 			allDailyDurations = append(allDailyDurations, dur)
@@ -553,7 +580,7 @@ func computeHourlyHeatmap(itemEvents []calendar.Event, offWeekStarts map[time.Ti
 		endTz := e.EndTime.In(userTimezone)
 
 		// Skip events in off-weeks (based on start time)
-		ws := weekStart(startTz, weekFirstDay)
+		ws := utils.WeekStart(startTz, weekFirstDay)
 		if offWeekStarts[ws] {
 			continue
 		}
@@ -642,14 +669,8 @@ func medianDuration(sorted []time.Duration) time.Duration {
 	return (sorted[n/2-1] + sorted[n/2]) / 2
 }
 
-func weekStart(date time.Time, weekStartDay time.Weekday) time.Time {
-	date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
-	delta := (int(date.Weekday()) - int(weekStartDay) + 7) % 7
-	return date.AddDate(0, 0, -delta)
-}
-
 func weekTimeRange(date time.Time, weekStartDay time.Weekday) (time.Time, time.Time) {
-	ws := weekStart(date, weekStartDay)
+	ws := utils.WeekStart(date, weekStartDay)
 	we := ws.AddDate(0, 0, 7).Add(-time.Nanosecond)
 	return ws, we
 }
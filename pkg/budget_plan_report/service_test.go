@@ -18,7 +18,8 @@ import (
 // --- test stubs ---
 
 type budgetPlanReaderStub struct {
-	plans map[int]budget_plan.BudgetPlan
+	plans     map[int]budget_plan.BudgetPlan
+	revisions []budget_plan.Revision
 }
 
 func (s *budgetPlanReaderStub) GetPlan(_ context.Context, planId int) (budget_plan.BudgetPlan, error) {
@@ -28,11 +29,18 @@ func (s *budgetPlanReaderStub) GetPlan(_ context.Context, planId int) (budget_pl
 	return budget_plan.BudgetPlan{}, budget_plan.ErrPlanNotFound
 }
 
+func (s *budgetPlanReaderStub) GetChangelog(_ context.Context, planId int) ([]budget_plan.Revision, error) {
+	if _, ok := s.plans[planId]; !ok {
+		return nil, budget_plan.ErrPlanNotFound
+	}
+	return s.revisions, nil
+}
+
 type calendarEventsReaderStub struct {
 	events []calendar.Event
 }
 
-func (s *calendarEventsReaderStub) GetEvents(_ context.Context, from, to time.Time) ([]calendar.Event, error) {
+func (s *calendarEventsReaderStub) GetEventsIncludingArchive(_ context.Context, from, to time.Time) ([]calendar.Event, error) {
 	var result []calendar.Event
 	for _, e := range s.events {
 		if !e.StartTime.After(to) && !e.EndTime.Before(from) {
@@ -206,6 +214,31 @@ func TestGetReport_SingleWeek(t *testing.T) {
 	assert.Equal(t, 4*time.Hour, report.TotalActualTime)
 }
 
+func TestGetReport_Changelog(t *testing.T) {
+	ctx := testContext()
+	bp := testBudgetPlan()
+
+	weekMonday := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	addedBefore := budget_plan.Revision{PlanId: 1, BudgetItemId: 10, ItemName: "Exercise", Type: budget_plan.RevisionItemAdded,
+		WeeklyDuration: 4 * time.Hour, ChangedAt: weekMonday.AddDate(0, 0, -14)}
+	changedWithin := budget_plan.Revision{PlanId: 1, BudgetItemId: 10, ItemName: "Exercise",
+		Type: budget_plan.RevisionDurationChanged, PreviousWeeklyDuration: 4 * time.Hour, WeeklyDuration: 5 * time.Hour,
+		ChangedAt: weekMonday.Add(50 * time.Hour)}
+
+	svc := NewService(
+		&budgetPlanReaderStub{plans: map[int]budget_plan.BudgetPlan{1: bp}, revisions: []budget_plan.Revision{addedBefore, changedWithin}},
+		&calendarEventsReaderStub{events: []calendar.Event{makeEvent(10, weekMonday.Add(8*time.Hour), weekMonday.Add(10*time.Hour))}},
+		&earliestEventFinderStub{earliest: weekMonday.Add(8 * time.Hour), found: true},
+		&weeklyPlanItemsReaderStub{},
+		mockClock(time.Date(2025, 3, 7, 12, 0, 0, 0, time.UTC)),
+	)
+
+	report, err := svc.GetReport(ctx, 1, nil, nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, []budget_plan.Revision{changedWithin}, report.Changelog)
+}
+
 func TestGetReport_MultipleWeeks_WithGap(t *testing.T) {
 	ctx := testContext()
 	bp := testBudgetPlan()
@@ -638,6 +671,34 @@ func TestGetItemReport_DailyBreakdown(t *testing.T) {
 	assert.Equal(t, 1*time.Hour, report.Days[1].ActualTime)
 }
 
+func TestGetItemReport_DailyBreakdown_DayStartHour(t *testing.T) {
+	currentUser, _ := user.CurrentUser(testContext())
+	currentUser.Settings.DayStartHour = 4
+	ctx := user.WithUser(context.Background(), currentUser)
+	bp := testBudgetPlan()
+
+	weekMonday := time.Date(2025, 3, 3, 0, 0, 0, 0, warsawTz)
+	events := []calendar.Event{
+		makeEvent(10, weekMonday.Add(22*time.Hour), weekMonday.Add(23*time.Hour)),                         // Mon 1h
+		makeEvent(10, weekMonday.Add(24*time.Hour+1*time.Hour), weekMonday.Add(24*time.Hour+2*time.Hour)), // Tue 1:00, still Monday
+	}
+
+	svc := NewService(
+		&budgetPlanReaderStub{plans: map[int]budget_plan.BudgetPlan{1: bp}},
+		&calendarEventsReaderStub{events: events},
+		&earliestEventFinderStub{earliest: weekMonday.Add(22 * time.Hour), found: true},
+		&weeklyPlanItemsReaderStub{},
+		mockClock(time.Date(2025, 3, 7, 12, 0, 0, 0, time.UTC)),
+	)
+
+	report, err := svc.GetItemReport(ctx, 1, 10, nil, nil)
+	require.NoError(t, err)
+
+	require.Len(t, report.Days, 1)
+	assert.Equal(t, time.Monday, report.Days[0].DayOfWeek)
+	assert.Equal(t, 2*time.Hour, report.Days[0].ActualTime)
+}
+
 func TestGetItemReport_DayOfWeekAverages(t *testing.T) {
 	ctx := testContext()
 	bp := testBudgetPlan()
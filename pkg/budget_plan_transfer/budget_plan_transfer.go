@@ -0,0 +1,69 @@
+package budget_plan_transfer
+
+import (
+	"time"
+)
+
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+)
+
+func (f Format) IsValid() bool {
+	return f == FormatCSV || f == FormatJSON
+}
+
+// ItemRow is a budget item in the import/export file. Categories and parents are referenced by name,
+// so a file can be prepared in a spreadsheet without knowing ids.
+type ItemRow struct {
+	// Id of an existing item to update. Without it, the item is matched by name, or created.
+	Id                int
+	Name              string
+	WeeklyDuration    time.Duration
+	WeeklyOccurrences int
+	Icon              string
+	Color             string
+	// Category is the name of one of the plan's categories.
+	Category string
+	// Parent is the name of a top-level item of the plan or of the file.
+	Parent    string
+	StartDate *time.Time
+	EndDate   *time.Time
+}
+
+type Action string
+
+const (
+	ActionCreate    Action = "create"
+	ActionUpdate    Action = "update"
+	ActionUnchanged Action = "unchanged"
+)
+
+// ItemChange is the result of importing a single row.
+type ItemChange struct {
+	// Row is the 1-based row number in the file (not counting the CSV header).
+	Row    int
+	Action Action
+	// ItemId is the id of the created or updated item, 0 for items not created yet in a dry run.
+	ItemId int
+	Name   string
+}
+
+// RowError describes why a row cannot be imported. Row 0 refers to the whole file.
+type RowError struct {
+	Row     int
+	Message string
+}
+
+// ImportResult lists changes of an import. When there are errors, no change is applied.
+type ImportResult struct {
+	DryRun  bool
+	Changes []ItemChange
+	Errors  []RowError
+}
+
+func (r ImportResult) HasErrors() bool {
+	return len(r.Errors) > 0
+}
@@ -0,0 +1,247 @@
+package budget_plan_transfer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidFile = errors.New("invalid import file")
+
+var csvHeader = []string{"id", "name", "weekly_duration", "weekly_occurrences", "icon", "color", "category", "parent",
+	"start_date", "end_date"}
+
+// itemRowJSON is the JSON representation of ItemRow. Durations are in seconds, like in the budget plan API.
+type itemRowJSON struct {
+	Id                int    `json:"id,omitempty"`
+	Name              string `json:"name"`
+	WeeklyDuration    int    `json:"weeklyDuration"`
+	WeeklyOccurrences int    `json:"weeklyOccurrences,omitempty"`
+	Icon              string `json:"icon,omitempty"`
+	Color             string `json:"color,omitempty"`
+	Category          string `json:"category,omitempty"`
+	Parent            string `json:"parent,omitempty"`
+	// StartDate and EndDate are dates in the 2006-01-02 format.
+	StartDate string `json:"startDate,omitempty"`
+	EndDate   string `json:"endDate,omitempty"`
+}
+
+func encodeItems(w io.Writer, format Format, rows []ItemRow) error {
+	switch format {
+	case FormatCSV:
+		return encodeCSV(w, rows)
+	case FormatJSON:
+		return encodeJSON(w, rows)
+	}
+	return fmt.Errorf("unsupported format: %s", format)
+}
+
+// decodeItems reads rows of the file. Errors of single rows are returned as RowErrors, the error is returned
+// only when the file cannot be read at all.
+func decodeItems(r io.Reader, format Format) ([]ItemRow, []RowError, error) {
+	switch format {
+	case FormatCSV:
+		return decodeCSV(r)
+	case FormatJSON:
+		return decodeJSON(r)
+	}
+	return nil, nil, fmt.Errorf("unsupported format: %s", format)
+}
+
+func encodeCSV(w io.Writer, rows []ItemRow) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := []string{
+			strconv.Itoa(row.Id),
+			row.Name,
+			formatDuration(row.WeeklyDuration),
+			strconv.Itoa(row.WeeklyOccurrences),
+			row.Icon,
+			row.Color,
+			row.Category,
+			row.Parent,
+			formatDate(row.StartDate),
+			formatDate(row.EndDate),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func decodeCSV(r io.Reader) ([]ItemRow, []RowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("%w: missing header", ErrInvalidFile)
+	}
+
+	columns := make(map[string]int)
+	for idx, column := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(column))] = idx
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, nil, fmt.Errorf("%w: missing name column", ErrInvalidFile)
+	}
+	if _, ok := columns["weekly_duration"]; !ok {
+		return nil, nil, fmt.Errorf("%w: missing weekly_duration column", ErrInvalidFile)
+	}
+
+	var rows []ItemRow
+	var rowErrors []RowError
+	for idx, record := range records[1:] {
+		rowNumber := idx + 1
+		value := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row, err := parseRow(value)
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Row: rowNumber, Message: err.Error()})
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrors, nil
+}
+
+func parseRow(value func(column string) string) (ItemRow, error) {
+	row := ItemRow{
+		Name:     value("name"),
+		Icon:     value("icon"),
+		Color:    value("color"),
+		Category: value("category"),
+		Parent:   value("parent"),
+	}
+	var err error
+	if id := value("id"); id != "" {
+		if row.Id, err = strconv.Atoi(id); err != nil {
+			return row, fmt.Errorf("invalid id: %s", id)
+		}
+	}
+	if row.WeeklyDuration, err = parseDuration(value("weekly_duration")); err != nil {
+		return row, err
+	}
+	if occurrences := value("weekly_occurrences"); occurrences != "" {
+		if row.WeeklyOccurrences, err = strconv.Atoi(occurrences); err != nil {
+			return row, fmt.Errorf("invalid weekly occurrences: %s", occurrences)
+		}
+	}
+	if row.StartDate, err = parseDate(value("start_date")); err != nil {
+		return row, err
+	}
+	if row.EndDate, err = parseDate(value("end_date")); err != nil {
+		return row, err
+	}
+	return row, nil
+}
+
+func encodeJSON(w io.Writer, rows []ItemRow) error {
+	items := make([]itemRowJSON, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, itemRowJSON{
+			Id:                row.Id,
+			Name:              row.Name,
+			WeeklyDuration:    int(row.WeeklyDuration.Seconds()),
+			WeeklyOccurrences: row.WeeklyOccurrences,
+			Icon:              row.Icon,
+			Color:             row.Color,
+			Category:          row.Category,
+			Parent:            row.Parent,
+			StartDate:         formatDate(row.StartDate),
+			EndDate:           formatDate(row.EndDate),
+		})
+	}
+	return json.NewEncoder(w).Encode(items)
+}
+
+func decodeJSON(r io.Reader) ([]ItemRow, []RowError, error) {
+	var items []itemRowJSON
+	if err := json.NewDecoder(r).Decode(&items); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	rows := make([]ItemRow, 0, len(items))
+	var rowErrors []RowError
+	for idx, item := range items {
+		row := ItemRow{
+			Id:                item.Id,
+			Name:              strings.TrimSpace(item.Name),
+			WeeklyDuration:    time.Duration(item.WeeklyDuration) * time.Second,
+			WeeklyOccurrences: item.WeeklyOccurrences,
+			Icon:              item.Icon,
+			Color:             item.Color,
+			Category:          strings.TrimSpace(item.Category),
+			Parent:            strings.TrimSpace(item.Parent),
+		}
+		var err error
+		if row.StartDate, err = parseDate(item.StartDate); err == nil {
+			row.EndDate, err = parseDate(item.EndDate)
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Row: idx + 1, Message: err.Error()})
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrors, nil
+}
+
+// formatDuration formats the duration as hours and minutes, e.g. "5:30", which spreadsheets understand.
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%d:%02d", int(d/time.Hour), int((d%time.Hour)/time.Minute))
+}
+
+// parseDuration accepts hours and minutes ("5:30"), decimal hours ("5.5") or a Go duration ("5h30m").
+func parseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, errors.New("weekly duration is required")
+	}
+	if hours, minutes, found := strings.Cut(value, ":"); found {
+		h, hErr := strconv.Atoi(hours)
+		m, mErr := strconv.Atoi(minutes)
+		if hErr != nil || mErr != nil || h < 0 || m < 0 || m >= 60 {
+			return 0, fmt.Errorf("invalid weekly duration: %s", value)
+		}
+		return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+	}
+	if hours, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(hours * float64(time.Hour)).Round(time.Minute), nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d, nil
+	}
+	return 0, fmt.Errorf("invalid weekly duration: %s", value)
+}
+
+func formatDate(date *time.Time) string {
+	if date == nil {
+		return ""
+	}
+	return date.Format(time.DateOnly)
+}
+
+func parseDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date: %s", value)
+	}
+	return &date, nil
+}
@@ -0,0 +1,204 @@
+package budget_plan_transfer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxImportFileSize limits the size of an uploaded import file.
+const maxImportFileSize = 1 << 20
+
+type ItemChangeDTO struct {
+	Row    int    `json:"row"`
+	Action string `json:"action" enums:"create,update,unchanged"`
+	ItemId int    `json:"itemId,omitempty"`
+	Name   string `json:"name"`
+}
+
+type RowErrorDTO struct {
+	// Row is the 1-based row number in the file (not counting the CSV header), 0 for errors of the whole file.
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+type ImportResultDTO struct {
+	DryRun  bool            `json:"dryRun"`
+	Changes []ItemChangeDTO `json:"changes"`
+	Errors  []RowErrorDTO   `json:"errors"`
+}
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// ExportItems godoc
+// @Summary Export budget plan items
+// @Description Export the plan's items as CSV or JSON. Categories and parent items are referenced by name.
+// @Description CSV durations are in the H:MM format, JSON durations are in seconds.
+// @Tags BudgetPlan
+// @Produce json
+// @Produce text/csv
+// @Param planId path int true "Budget Plan ID"
+// @Param format query string false "File format" Enums(csv, json) default(json)
+// @Success 200 {string} string "Exported items"
+// @Failure 400 {object} rest.ErrorResponse
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Plan Not Found"
+// @Router /api/budgetplan/{planId}/item/export [get]
+// @Security XUserId
+func (h *Handler) ExportItems(w http.ResponseWriter, r *http.Request) {
+	planId, err := parsePlanId(r)
+	if err != nil {
+		writeBadRequest(w, "Invalid plan ID", "Plan ID must be a number")
+		return
+	}
+	format, err := parseFormat(r)
+	if err != nil {
+		writeBadRequest(w, "Invalid format", err.Error())
+		return
+	}
+
+	rows, err := h.service.ExportItems(r.Context(), planId)
+	if err != nil {
+		if errors.Is(err, budget_plan.ErrPlanNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	contentType := "application/json"
+	if format == FormatCSV {
+		contentType = "text/csv"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"budget-plan-%d.%s\"", planId, format))
+	if err := encodeItems(w, format, rows); err != nil {
+		log.Errorf("failed to export budget plan items: %v", err)
+	}
+}
+
+// ImportItems godoc
+// @Summary Import budget plan items
+// @Description Create or update the plan's items from a CSV or JSON file, in the format of the export.
+// @Description Rows are matched to existing items by id, or by name. The whole file is validated first and
+// @Description nothing is changed when any row is invalid. With dryRun=true changes are only reported.
+// @Tags BudgetPlan
+// @Accept json
+// @Accept text/csv
+// @Produce json
+// @Param planId path int true "Budget Plan ID"
+// @Param format query string false "File format, detected from Content-Type when omitted" Enums(csv, json)
+// @Param dryRun query bool false "Only validate the file and report the changes"
+// @Success 200 {object} ImportResultDTO
+// @Failure 400 {object} ImportResultDTO "Invalid rows, nothing was imported"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Plan Not Found"
+// @Router /api/budgetplan/{planId}/item/import [post]
+// @Security XUserId
+func (h *Handler) ImportItems(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	planId, err := parsePlanId(r)
+	if err != nil {
+		writeBadRequest(w, "Invalid plan ID", "Plan ID must be a number")
+		return
+	}
+	format, err := parseFormat(r)
+	if err != nil {
+		writeBadRequest(w, "Invalid format", err.Error())
+		return
+	}
+	dryRun := false
+	if value := r.URL.Query().Get("dryRun"); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			writeBadRequest(w, "Invalid dryRun", "dryRun must be true or false")
+			return
+		}
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportFileSize)
+	result, err := h.service.ImportItems(r.Context(), planId, body, format, dryRun)
+	if err != nil {
+		if errors.Is(err, budget_plan.ErrPlanNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, ErrInvalidFile) {
+			writeBadRequest(w, "Invalid file", err.Error())
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if result.HasErrors() {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if err := json.NewEncoder(w).Encode(importResultToDTO(result)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func parsePlanId(r *http.Request) (int, error) {
+	vars := mux.Vars(r)
+	return strconv.Atoi(vars["planId"])
+}
+
+// parseFormat reads the format from the query, falling back to the request's Content-Type and then to JSON.
+func parseFormat(r *http.Request) (Format, error) {
+	if value := r.URL.Query().Get("format"); value != "" {
+		format := Format(value)
+		if !format.IsValid() {
+			return "", fmt.Errorf("format must be one of: csv, json")
+		}
+		return format, nil
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mediaType == "text/csv" {
+		return FormatCSV, nil
+	}
+	return FormatJSON, nil
+}
+
+func writeBadRequest(w http.ResponseWriter, errMsg, details string) {
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+		Error:   errMsg,
+		Details: details,
+	})
+}
+
+func importResultToDTO(result ImportResult) ImportResultDTO {
+	changes := make([]ItemChangeDTO, 0, len(result.Changes))
+	for _, change := range result.Changes {
+		changes = append(changes, ItemChangeDTO{
+			Row:    change.Row,
+			Action: string(change.Action),
+			ItemId: change.ItemId,
+			Name:   change.Name,
+		})
+	}
+	rowErrors := make([]RowErrorDTO, 0, len(result.Errors))
+	for _, rowErr := range result.Errors {
+		rowErrors = append(rowErrors, RowErrorDTO{Row: rowErr.Row, Message: rowErr.Message})
+	}
+	return ImportResultDTO{
+		DryRun:  result.DryRun,
+		Changes: changes,
+		Errors:  rowErrors,
+	}
+}
@@ -0,0 +1,338 @@
+package budget_plan_transfer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/klokku/klokku/pkg/budget_plan"
+)
+
+const maxWeeklyDuration = 7 * 24 * time.Hour
+
+type Service interface {
+	// ExportItems returns the plan's items in plan order, parents before their sub-items.
+	ExportItems(ctx context.Context, planId int) ([]ItemRow, error)
+	// ImportItems creates or updates the plan's items from the file. The whole file is validated first and
+	// nothing is changed when any row is invalid. In a dry run, the changes are only reported.
+	ImportItems(ctx context.Context, planId int, file io.Reader, format Format, dryRun bool) (ImportResult, error)
+}
+
+type budgetPlanService interface {
+	GetPlan(ctx context.Context, planId int) (budget_plan.BudgetPlan, error)
+	CreateItem(ctx context.Context, item budget_plan.BudgetItem) (budget_plan.BudgetItem, error)
+	UpdateItem(ctx context.Context, item budget_plan.BudgetItem) (budget_plan.BudgetItem, error)
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type ServiceImpl struct {
+	budgetPlanService budgetPlanService
+}
+
+func NewService(budgetPlanService budgetPlanService) Service {
+	return &ServiceImpl{budgetPlanService: budgetPlanService}
+}
+
+func (s *ServiceImpl) ExportItems(ctx context.Context, planId int) ([]ItemRow, error) {
+	plan, err := s.budgetPlanService.GetPlan(ctx, planId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get budget plan: %w", err)
+	}
+	rows := make([]ItemRow, 0, len(plan.Items))
+	for _, item := range plan.Items {
+		if item.ParentId != 0 {
+			continue
+		}
+		rows = append(rows, itemToRow(plan, item))
+		for _, child := range plan.Children(item.Id) {
+			rows = append(rows, itemToRow(plan, child))
+		}
+	}
+	return rows, nil
+}
+
+func itemToRow(plan budget_plan.BudgetPlan, item budget_plan.BudgetItem) ItemRow {
+	row := ItemRow{
+		Id:                item.Id,
+		Name:              item.Name,
+		WeeklyDuration:    item.WeeklyDuration,
+		WeeklyOccurrences: item.WeeklyOccurrences,
+		Icon:              item.Icon,
+		Color:             item.Color,
+		StartDate:         item.StartDate,
+		EndDate:           item.EndDate,
+	}
+	if category, found := plan.FindCategory(item.CategoryId); found {
+		row.Category = category.Name
+	}
+	if parent, found := plan.FindItem(item.ParentId); found {
+		row.Parent = parent.Name
+	}
+	return row
+}
+
+// plannedChange is a validated row with the item it will be stored as. ParentId is resolved when applying,
+// because the parent may be created by the same import.
+type plannedChange struct {
+	row    int
+	action Action
+	item   budget_plan.BudgetItem
+	parent string
+}
+
+func (s *ServiceImpl) ImportItems(ctx context.Context, planId int, file io.Reader, format Format, dryRun bool) (ImportResult, error) {
+	rows, rowErrors, err := decodeItems(file, format)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	plan, err := s.budgetPlanService.GetPlan(ctx, planId)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to get budget plan: %w", err)
+	}
+
+	result := ImportResult{DryRun: dryRun, Changes: make([]ItemChange, 0, len(rows)), Errors: rowErrors}
+	changes, validationErrors := planChanges(plan, rows, rowErrors)
+	result.Errors = append(result.Errors, validationErrors...)
+	if result.HasErrors() {
+		return result, nil
+	}
+
+	if !dryRun {
+		// A failing row must not leave the plan half imported
+		err := s.budgetPlanService.WithTransaction(ctx, func(ctx context.Context) error {
+			return s.applyChanges(ctx, plan, changes)
+		})
+		if err != nil {
+			return ImportResult{}, err
+		}
+	}
+	for _, change := range changes {
+		result.Changes = append(result.Changes, ItemChange{
+			Row:    change.row,
+			Action: change.action,
+			ItemId: change.item.Id,
+			Name:   change.item.Name,
+		})
+	}
+	return result, nil
+}
+
+func planChanges(plan budget_plan.BudgetPlan, rows []ItemRow, decodeErrors []RowError) ([]*plannedChange, []RowError) {
+	invalidRows := make(map[int]bool)
+	for _, rowErr := range decodeErrors {
+		invalidRows[rowErr.Row] = true
+	}
+	if len(rows) == 0 && len(decodeErrors) == 0 {
+		return nil, []RowError{{Row: 0, Message: "file has no items"}}
+	}
+
+	var errs []RowError
+	addError := func(row int, format string, args ...any) {
+		errs = append(errs, RowError{Row: row, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var changes []*plannedChange
+	changesByName := make(map[string]*plannedChange)
+	targetedItems := make(map[int]int)
+	for idx, row := range rows {
+		rowNumber := idx + 1
+		if invalidRows[rowNumber] {
+			continue
+		}
+		if row.Name == "" {
+			addError(rowNumber, "name is required")
+			continue
+		}
+		if row.WeeklyDuration < 0 || row.WeeklyDuration > maxWeeklyDuration {
+			addError(rowNumber, "weekly duration must be between 0 and 168 hours")
+			continue
+		}
+		if row.WeeklyOccurrences < 0 || row.WeeklyOccurrences > 7 {
+			addError(rowNumber, "weekly occurrences must be between 0 and 7")
+			continue
+		}
+		if row.StartDate != nil && row.EndDate != nil && row.EndDate.Before(*row.StartDate) {
+			addError(rowNumber, "end date cannot be before start date")
+			continue
+		}
+		if row.Color != "" && !budget_plan.IsValidColor(row.Color) {
+			addError(rowNumber, "invalid color %q, expected a hex color, e.g. #3B82F6", row.Color)
+			continue
+		}
+		if row.Icon != "" && !budget_plan.IsValidIcon(row.Icon) {
+			addError(rowNumber, "invalid icon %q, expected one of the curated icons", row.Icon)
+			continue
+		}
+
+		change := &plannedChange{row: rowNumber, action: ActionCreate, parent: row.Parent}
+		existing, found := findExisting(plan, row)
+		if row.Id != 0 && !found {
+			addError(rowNumber, "item %d is not part of the plan", row.Id)
+			continue
+		}
+		if found {
+			if previousRow, targeted := targetedItems[existing.Id]; targeted {
+				addError(rowNumber, "item %q is already imported in row %d", existing.Name, previousRow)
+				continue
+			}
+			targetedItems[existing.Id] = rowNumber
+			change.action = ActionUpdate
+			change.item = existing
+		} else {
+			change.item = budget_plan.BudgetItem{PlanId: plan.Id}
+		}
+		nameKey := normalizeName(row.Name)
+		if previous, duplicate := changesByName[nameKey]; duplicate {
+			addError(rowNumber, "item %q is already imported in row %d", row.Name, previous.row)
+			continue
+		}
+		changesByName[nameKey] = change
+
+		change.item.Name = row.Name
+		change.item.WeeklyDuration = row.WeeklyDuration
+		change.item.WeeklyOccurrences = row.WeeklyOccurrences
+		change.item.Icon = row.Icon
+		change.item.Color = row.Color
+		change.item.StartDate = row.StartDate
+		change.item.EndDate = row.EndDate
+		change.item.CategoryId = 0
+		if row.Category != "" {
+			category, found := findCategory(plan, row.Category)
+			if !found {
+				addError(rowNumber, "unknown category %q", row.Category)
+				continue
+			}
+			change.item.CategoryId = category.Id
+		}
+		if found && sameItem(existing, change.item) && parentName(plan, existing) == normalizeName(row.Parent) {
+			change.action = ActionUnchanged
+		}
+		changes = append(changes, change)
+	}
+
+	for _, change := range changes {
+		if change.parent == "" {
+			continue
+		}
+		if normalizeName(change.parent) == normalizeName(change.item.Name) {
+			addError(change.row, "item cannot be its own parent")
+			continue
+		}
+		if parentChange, inFile := changesByName[normalizeName(change.parent)]; inFile {
+			if parentChange.parent != "" {
+				addError(change.row, "parent %q is a sub-item itself", change.parent)
+			}
+			continue
+		}
+		parent, found := findItemByName(plan, change.parent)
+		if !found {
+			addError(change.row, "unknown parent %q", change.parent)
+		} else if parent.ParentId != 0 {
+			addError(change.row, "parent %q is a sub-item itself", change.parent)
+		}
+	}
+	return changes, errs
+}
+
+// applyChanges stores the changes, top-level items first so that sub-items can reference parents created
+// by the same import.
+func (s *ServiceImpl) applyChanges(ctx context.Context, plan budget_plan.BudgetPlan, changes []*plannedChange) error {
+	itemIdsByName := make(map[string]int)
+	for _, item := range plan.Items {
+		itemIdsByName[normalizeName(item.Name)] = item.Id
+	}
+	apply := func(change *plannedChange) error {
+		change.item.ParentId = 0
+		if change.parent != "" {
+			change.item.ParentId = itemIdsByName[normalizeName(change.parent)]
+		}
+		var stored budget_plan.BudgetItem
+		var err error
+		switch change.action {
+		case ActionCreate:
+			stored, err = s.budgetPlanService.CreateItem(ctx, change.item)
+		case ActionUpdate:
+			stored, err = s.budgetPlanService.UpdateItem(ctx, change.item)
+		default:
+			stored = change.item
+		}
+		if err != nil {
+			return fmt.Errorf("failed to import row %d: %w", change.row, err)
+		}
+		change.item = stored
+		itemIdsByName[normalizeName(stored.Name)] = stored.Id
+		return nil
+	}
+	for _, change := range changes {
+		if change.parent == "" {
+			if err := apply(change); err != nil {
+				return err
+			}
+		}
+	}
+	for _, change := range changes {
+		if change.parent != "" {
+			if err := apply(change); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func findExisting(plan budget_plan.BudgetPlan, row ItemRow) (budget_plan.BudgetItem, bool) {
+	if row.Id != 0 {
+		return plan.FindItem(row.Id)
+	}
+	return findItemByName(plan, row.Name)
+}
+
+func findItemByName(plan budget_plan.BudgetPlan, name string) (budget_plan.BudgetItem, bool) {
+	for _, item := range plan.Items {
+		if normalizeName(item.Name) == normalizeName(name) {
+			return item, true
+		}
+	}
+	return budget_plan.BudgetItem{}, false
+}
+
+func findCategory(plan budget_plan.BudgetPlan, name string) (budget_plan.Category, bool) {
+	for _, category := range plan.Categories {
+		if normalizeName(category.Name) == normalizeName(name) {
+			return category, true
+		}
+	}
+	return budget_plan.Category{}, false
+}
+
+func parentName(plan budget_plan.BudgetPlan, item budget_plan.BudgetItem) string {
+	if parent, found := plan.FindItem(item.ParentId); found {
+		return normalizeName(parent.Name)
+	}
+	return ""
+}
+
+// sameItem compares the fields that are part of the import file, except for the parent.
+func sameItem(a, b budget_plan.BudgetItem) bool {
+	return a.Name == b.Name &&
+		a.WeeklyDuration == b.WeeklyDuration &&
+		a.WeeklyOccurrences == b.WeeklyOccurrences &&
+		a.Icon == b.Icon &&
+		a.Color == b.Color &&
+		a.CategoryId == b.CategoryId &&
+		sameDate(a.StartDate, b.StartDate) &&
+		sameDate(a.EndDate, b.EndDate)
+}
+
+func sameDate(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Format(time.DateOnly) == b.Format(time.DateOnly)
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,235 @@
+package budget_plan_transfer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = user.WithUser(context.Background(), user.User{
+	Id:       10,
+	Settings: user.Settings{Timezone: "Europe/Warsaw", WeekFirstDay: time.Monday},
+})
+
+func setup(t *testing.T) (Service, budget_plan.Service, budget_plan.BudgetPlan) {
+	t.Helper()
+	bpService := budget_plan.NewBudgetPlanService(budget_plan.NewStubBudgetRepo(), event_bus.NewEventBus())
+	plan, err := bpService.CreatePlan(ctx, budget_plan.BudgetPlan{Name: "Plan"})
+	require.NoError(t, err)
+	_, err = bpService.CreateCategory(ctx, budget_plan.Category{PlanId: plan.Id, Name: "Health"})
+	require.NoError(t, err)
+	return NewService(bpService), bpService, plan
+}
+
+func TestServiceImpl_ExportItems(t *testing.T) {
+	service, bpService, plan := setup(t)
+	plan, _ = bpService.GetPlan(ctx, plan.Id)
+	start := time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC)
+	work, err := bpService.CreateItem(ctx, budget_plan.BudgetItem{PlanId: plan.Id, Name: "Work", WeeklyDuration: 40 * time.Hour})
+	require.NoError(t, err)
+	_, err = bpService.CreateItem(ctx, budget_plan.BudgetItem{PlanId: plan.Id, Name: "Sport", WeeklyDuration: 90 * time.Minute,
+		WeeklyOccurrences: 3, Icon: "run", CategoryId: plan.Categories[0].Id, StartDate: &start})
+	require.NoError(t, err)
+	_, err = bpService.CreateItem(ctx, budget_plan.BudgetItem{PlanId: plan.Id, Name: "Meetings", WeeklyDuration: 5 * time.Hour, ParentId: work.Id})
+	require.NoError(t, err)
+
+	rows, err := service.ExportItems(ctx, plan.Id)
+	require.NoError(t, err)
+
+	t.Run("lists sub-items after their parent", func(t *testing.T) {
+		require.Len(t, rows, 3)
+		assert.Equal(t, "Work", rows[0].Name)
+		assert.Equal(t, "Meetings", rows[1].Name)
+		assert.Equal(t, "Work", rows[1].Parent)
+		assert.Equal(t, "Sport", rows[2].Name)
+		assert.Equal(t, "Health", rows[2].Category)
+	})
+
+	t.Run("encodes CSV", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, encodeItems(&buf, FormatCSV, rows))
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 4)
+		assert.Equal(t, "id,name,weekly_duration,weekly_occurrences,icon,color,category,parent,start_date,end_date", lines[0])
+		assert.Contains(t, lines[3], ",Sport,1:30,3,run,,Health,,2025-03-03,")
+	})
+
+	t.Run("re-importing the export changes nothing", func(t *testing.T) {
+		for _, format := range []Format{FormatCSV, FormatJSON} {
+			var buf bytes.Buffer
+			require.NoError(t, encodeItems(&buf, format, rows))
+
+			result, err := service.ImportItems(ctx, plan.Id, &buf, format, false)
+
+			require.NoError(t, err)
+			require.Empty(t, result.Errors)
+			require.Len(t, result.Changes, 3)
+			for _, change := range result.Changes {
+				assert.Equal(t, ActionUnchanged, change.Action, "format %s, row %d", format, change.Row)
+			}
+		}
+	})
+}
+
+func TestServiceImpl_ImportItems(t *testing.T) {
+	t.Run("creates and updates items", func(t *testing.T) {
+		service, bpService, plan := setup(t)
+		reading, err := bpService.CreateItem(ctx, budget_plan.BudgetItem{PlanId: plan.Id, Name: "Reading", WeeklyDuration: time.Hour})
+		require.NoError(t, err)
+		file := "name,weekly_duration,category,parent\n" +
+			"reading,3:00,health,\n" +
+			"Coding,20:00,,Work\n" +
+			"Work,1.5,,\n"
+
+		result, err := service.ImportItems(ctx, plan.Id, strings.NewReader(file), FormatCSV, false)
+
+		require.NoError(t, err)
+		require.Empty(t, result.Errors)
+		require.Len(t, result.Changes, 3)
+		assert.Equal(t, ActionUpdate, result.Changes[0].Action)
+		assert.Equal(t, reading.Id, result.Changes[0].ItemId)
+		assert.Equal(t, ActionCreate, result.Changes[1].Action)
+		assert.Equal(t, ActionCreate, result.Changes[2].Action)
+
+		stored, err := bpService.GetPlan(ctx, plan.Id)
+		require.NoError(t, err)
+		require.Len(t, stored.Items, 3)
+		updatedReading, _ := stored.FindItem(reading.Id)
+		assert.Equal(t, 3*time.Hour, updatedReading.WeeklyDuration)
+		assert.Equal(t, stored.Categories[0].Id, updatedReading.CategoryId)
+		coding, _ := stored.FindItem(result.Changes[1].ItemId)
+		assert.Equal(t, result.Changes[2].ItemId, coding.ParentId)
+		work, _ := stored.FindItem(result.Changes[2].ItemId)
+		assert.Equal(t, 20*time.Hour, work.WeeklyDuration, "parent holds the sum of its sub-items")
+	})
+
+	t.Run("dry run does not change the plan", func(t *testing.T) {
+		service, bpService, plan := setup(t)
+		file := `[{"name": "Reading", "weeklyDuration": 3600}]`
+
+		result, err := service.ImportItems(ctx, plan.Id, strings.NewReader(file), FormatJSON, true)
+
+		require.NoError(t, err)
+		assert.True(t, result.DryRun)
+		require.Len(t, result.Changes, 1)
+		assert.Equal(t, ActionCreate, result.Changes[0].Action)
+		stored, err := bpService.GetPlan(ctx, plan.Id)
+		require.NoError(t, err)
+		assert.Empty(t, stored.Items)
+	})
+
+	t.Run("reports invalid rows and imports nothing", func(t *testing.T) {
+		service, bpService, plan := setup(t)
+		file := "name,weekly_duration,category,parent,start_date,end_date\n" +
+			"Reading,3:00,,,,\n" +
+			",1:00,,,,\n" +
+			"Sport,abc,,,,\n" +
+			"Yoga,1:00,Unknown,,,\n" +
+			"Coding,1:00,,Missing,,\n" +
+			"reading,1:00,,,,\n" +
+			"Course,1:00,,,2025-03-10,2025-03-01\n" +
+			"Sleep,200:00,,,,\n"
+
+		result, err := service.ImportItems(ctx, plan.Id, strings.NewReader(file), FormatCSV, false)
+
+		require.NoError(t, err)
+		assert.Equal(t, []RowError{
+			{Row: 3, Message: "invalid weekly duration: abc"},
+			{Row: 2, Message: "name is required"},
+			{Row: 4, Message: `unknown category "Unknown"`},
+			{Row: 6, Message: `item "reading" is already imported in row 1`},
+			{Row: 7, Message: "end date cannot be before start date"},
+			{Row: 8, Message: "weekly duration must be between 0 and 168 hours"},
+			{Row: 5, Message: `unknown parent "Missing"`},
+		}, result.Errors)
+		stored, err := bpService.GetPlan(ctx, plan.Id)
+		require.NoError(t, err)
+		assert.Empty(t, stored.Items)
+	})
+
+	t.Run("rejects unknown item id", func(t *testing.T) {
+		service, _, plan := setup(t)
+		file := `[{"id": 999, "name": "Reading", "weeklyDuration": 3600}]`
+
+		result, err := service.ImportItems(ctx, plan.Id, strings.NewReader(file), FormatJSON, true)
+
+		require.NoError(t, err)
+		assert.Equal(t, []RowError{{Row: 1, Message: "item 999 is not part of the plan"}}, result.Errors)
+	})
+
+	t.Run("rejects colors that are not hex colors", func(t *testing.T) {
+		service, _, plan := setup(t)
+		file := `[{"name": "Reading", "weeklyDuration": 3600, "color": "blue"}]`
+
+		result, err := service.ImportItems(ctx, plan.Id, strings.NewReader(file), FormatJSON, true)
+
+		require.NoError(t, err)
+		assert.Equal(t, []RowError{{Row: 1, Message: `invalid color "blue", expected a hex color, e.g. #3B82F6`}},
+			result.Errors)
+	})
+
+	t.Run("rejects icons that are not curated", func(t *testing.T) {
+		service, _, plan := setup(t)
+		file := `[{"name": "Reading", "weeklyDuration": 3600, "icon": "📚"}]`
+
+		result, err := service.ImportItems(ctx, plan.Id, strings.NewReader(file), FormatJSON, true)
+
+		require.NoError(t, err)
+		assert.Equal(t, []RowError{{Row: 1, Message: `invalid icon "📚", expected one of the curated icons`}},
+			result.Errors)
+	})
+
+	t.Run("returns error for unreadable file", func(t *testing.T) {
+		service, _, plan := setup(t)
+
+		_, jsonErr := service.ImportItems(ctx, plan.Id, strings.NewReader("not json"), FormatJSON, true)
+		_, csvErr := service.ImportItems(ctx, plan.Id, strings.NewReader("id,icon\n1,x\n"), FormatCSV, true)
+
+		assert.ErrorIs(t, jsonErr, ErrInvalidFile)
+		assert.ErrorIs(t, csvErr, ErrInvalidFile)
+	})
+
+	t.Run("returns error for unknown plan", func(t *testing.T) {
+		service, _, _ := setup(t)
+
+		_, err := service.ImportItems(ctx, 999, strings.NewReader(`[]`), FormatJSON, true)
+
+		assert.ErrorIs(t, err, budget_plan.ErrPlanNotFound)
+	})
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		expect  time.Duration
+		wantErr bool
+	}{
+		{"5:30", 5*time.Hour + 30*time.Minute, false},
+		{"0:45", 45 * time.Minute, false},
+		{"1.5", 90 * time.Minute, false},
+		{"2h15m", 2*time.Hour + 15*time.Minute, false},
+		{"5:75", 0, true},
+		{"", 0, true},
+		{"five", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			d, err := parseDuration(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expect, d)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package budget_plan_validation
+
+import "time"
+
+// WeekDuration is the total time available in a week.
+const WeekDuration = 7 * 24 * time.Hour
+
+type WarningCode string
+
+const (
+	// WarningWeekExceeded means the plan's total weekly duration is more than a week has.
+	WarningWeekExceeded WarningCode = "week_exceeded"
+	// WarningZeroDuration means an item has no weekly duration.
+	WarningZeroDuration WarningCode = "zero_duration"
+	// WarningDuplicateName means more than one item has the same name.
+	WarningDuplicateName WarningCode = "duplicate_name"
+	// WarningOrphanedClickUpMapping means a ClickUp tag is mapped to an item that is not part of the plan anymore.
+	WarningOrphanedClickUpMapping WarningCode = "orphaned_clickup_mapping"
+)
+
+type Warning struct {
+	Code    WarningCode
+	Message string
+	// BudgetItemIds are the items the warning refers to, if any.
+	BudgetItemIds []int
+}
+
+// Report lists problems of a budget plan. A plan without warnings is valid.
+type Report struct {
+	PlanId              int
+	PlanName            string
+	TotalWeeklyDuration time.Duration
+	Warnings            []Warning
+}
+
+func (r Report) Valid() bool {
+	return len(r.Warnings) == 0
+}
@@ -0,0 +1,89 @@
+package budget_plan_validation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/pkg/budget_plan"
+)
+
+type WarningDTO struct {
+	Code          string `json:"code"`
+	Message       string `json:"message"`
+	BudgetItemIds []int  `json:"budgetItemIds,omitempty"`
+}
+
+type ReportDTO struct {
+	PlanId   int    `json:"planId"`
+	PlanName string `json:"planName"`
+	Valid    bool   `json:"valid"`
+	// TotalWeeklyDuration in seconds
+	TotalWeeklyDuration int          `json:"totalWeeklyDuration"`
+	Warnings            []WarningDTO `json:"warnings"`
+}
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// ValidatePlan godoc
+// @Summary Validate a budget plan
+// @Description Check a budget plan for problems such as a total weekly duration above 168h, items without duration,
+// @Description duplicate item names or ClickUp mappings of items that are not part of the plan.
+// @Tags BudgetPlan
+// @Produce json
+// @Param planId path int true "Budget Plan ID"
+// @Success 200 {object} ReportDTO
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Plan Not Found"
+// @Router /api/budgetplan/{planId}/validation [get]
+// @Security XUserId
+func (h *Handler) ValidatePlan(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	planId, err := strconv.Atoi(mux.Vars(r)["planId"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.service.ValidatePlan(r.Context(), planId)
+	if err != nil {
+		if errors.Is(err, budget_plan.ErrPlanNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(reportToDTO(report)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func reportToDTO(report Report) ReportDTO {
+	warnings := make([]WarningDTO, 0, len(report.Warnings))
+	for _, warning := range report.Warnings {
+		warnings = append(warnings, WarningDTO{
+			Code:          string(warning.Code),
+			Message:       warning.Message,
+			BudgetItemIds: warning.BudgetItemIds,
+		})
+	}
+	return ReportDTO{
+		PlanId:              report.PlanId,
+		PlanName:            report.PlanName,
+		Valid:               report.Valid(),
+		TotalWeeklyDuration: int(report.TotalWeeklyDuration.Seconds()),
+		Warnings:            warnings,
+	}
+}
@@ -0,0 +1,127 @@
+package budget_plan_validation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/clickup"
+)
+
+type Service interface {
+	// ValidatePlan checks the plan for problems that should be fixed before it is activated.
+	ValidatePlan(ctx context.Context, planId int) (Report, error)
+}
+
+type budgetPlanReader interface {
+	GetPlan(ctx context.Context, planId int) (budget_plan.BudgetPlan, error)
+}
+
+type clickUpConfigurationReader interface {
+	GetConfiguration(ctx context.Context, budgetPlanId int) (clickup.Configuration, error)
+}
+
+type ServiceImpl struct {
+	budgetPlanReader    budgetPlanReader
+	clickUpConfigReader clickUpConfigurationReader
+}
+
+func NewService(budgetPlanReader budgetPlanReader, clickUpConfigReader clickUpConfigurationReader) Service {
+	return &ServiceImpl{
+		budgetPlanReader:    budgetPlanReader,
+		clickUpConfigReader: clickUpConfigReader,
+	}
+}
+
+func (s *ServiceImpl) ValidatePlan(ctx context.Context, planId int) (Report, error) {
+	plan, err := s.budgetPlanReader.GetPlan(ctx, planId)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to get budget plan: %w", err)
+	}
+	clickUpConfig, err := s.clickUpConfigReader.GetConfiguration(ctx, planId)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to get ClickUp configuration: %w", err)
+	}
+
+	report := Report{
+		PlanId:   plan.Id,
+		PlanName: plan.Name,
+		Warnings: make([]Warning, 0),
+	}
+	for _, item := range plan.Items {
+		// Items with sub-items hold the sum of their sub-items' durations
+		if item.ParentId == 0 {
+			report.TotalWeeklyDuration += item.WeeklyDuration
+		}
+	}
+	if report.TotalWeeklyDuration > WeekDuration {
+		report.Warnings = append(report.Warnings, Warning{
+			Code: WarningWeekExceeded,
+			Message: fmt.Sprintf("Plan requires %s per week, but a week has only %s",
+				report.TotalWeeklyDuration, WeekDuration),
+		})
+	}
+	report.Warnings = append(report.Warnings, zeroDurationWarnings(plan)...)
+	report.Warnings = append(report.Warnings, duplicateNameWarnings(plan)...)
+	report.Warnings = append(report.Warnings, orphanedMappingWarnings(plan, clickUpConfig)...)
+	return report, nil
+}
+
+func zeroDurationWarnings(plan budget_plan.BudgetPlan) []Warning {
+	var warnings []Warning
+	for _, item := range plan.Items {
+		if item.WeeklyDuration <= 0 {
+			warnings = append(warnings, Warning{
+				Code:          WarningZeroDuration,
+				Message:       fmt.Sprintf("Item %q has no weekly duration", item.Name),
+				BudgetItemIds: []int{item.Id},
+			})
+		}
+	}
+	return warnings
+}
+
+// duplicateNameWarnings reports items with the same name, ignoring case and surrounding whitespace.
+func duplicateNameWarnings(plan budget_plan.BudgetPlan) []Warning {
+	var names []string
+	idsByName := make(map[string][]int)
+	for _, item := range plan.Items {
+		name := strings.ToLower(strings.TrimSpace(item.Name))
+		if _, seen := idsByName[name]; !seen {
+			names = append(names, name)
+		}
+		idsByName[name] = append(idsByName[name], item.Id)
+	}
+
+	var warnings []Warning
+	for _, name := range names {
+		ids := idsByName[name]
+		if len(ids) < 2 {
+			continue
+		}
+		item, _ := plan.FindItem(ids[0])
+		warnings = append(warnings, Warning{
+			Code:          WarningDuplicateName,
+			Message:       fmt.Sprintf("%d items are named %q", len(ids), item.Name),
+			BudgetItemIds: ids,
+		})
+	}
+	return warnings
+}
+
+func orphanedMappingWarnings(plan budget_plan.BudgetPlan, config clickup.Configuration) []Warning {
+	var warnings []Warning
+	for _, mapping := range config.Mappings {
+		if _, found := plan.FindItem(mapping.BudgetItemId); found {
+			continue
+		}
+		warnings = append(warnings, Warning{
+			Code: WarningOrphanedClickUpMapping,
+			Message: fmt.Sprintf("ClickUp tag %q is mapped to item %d which is not part of the plan",
+				mapping.ClickupTagName, mapping.BudgetItemId),
+			BudgetItemIds: []int{mapping.BudgetItemId},
+		})
+	}
+	return warnings
+}
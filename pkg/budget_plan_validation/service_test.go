@@ -0,0 +1,162 @@
+package budget_plan_validation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/clickup"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// --- test stubs ---
+
+type budgetPlanReaderStub struct {
+	plans map[int]budget_plan.BudgetPlan
+}
+
+func (s *budgetPlanReaderStub) GetPlan(_ context.Context, planId int) (budget_plan.BudgetPlan, error) {
+	if p, ok := s.plans[planId]; ok {
+		return p, nil
+	}
+	return budget_plan.BudgetPlan{}, budget_plan.ErrPlanNotFound
+}
+
+type clickUpConfigurationReaderStub struct {
+	configs map[int]clickup.Configuration
+}
+
+func (s *clickUpConfigurationReaderStub) GetConfiguration(_ context.Context, budgetPlanId int) (clickup.Configuration, error) {
+	return s.configs[budgetPlanId], nil
+}
+
+func setup(plan budget_plan.BudgetPlan, config clickup.Configuration) Service {
+	return NewService(
+		&budgetPlanReaderStub{plans: map[int]budget_plan.BudgetPlan{plan.Id: plan}},
+		&clickUpConfigurationReaderStub{configs: map[int]clickup.Configuration{plan.Id: config}},
+	)
+}
+
+func TestServiceImpl_ValidatePlan(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("valid plan has no warnings", func(t *testing.T) {
+		service := setup(budget_plan.BudgetPlan{
+			Id:   1,
+			Name: "Plan",
+			Items: []budget_plan.BudgetItem{
+				{Id: 1, Name: "Work", WeeklyDuration: 40 * time.Hour},
+				{Id: 2, Name: "Sleep", WeeklyDuration: 56 * time.Hour},
+			},
+		}, clickup.Configuration{
+			Mappings: []clickup.BudgetItemMapping{{ClickupTagName: "work", BudgetItemId: 1}},
+		})
+
+		report, err := service.ValidatePlan(ctx, 1)
+
+		require.NoError(t, err)
+		assert.True(t, report.Valid())
+		assert.Empty(t, report.Warnings)
+		assert.Equal(t, 96*time.Hour, report.TotalWeeklyDuration)
+	})
+
+	t.Run("warns when plan exceeds a week", func(t *testing.T) {
+		service := setup(budget_plan.BudgetPlan{
+			Id: 1,
+			Items: []budget_plan.BudgetItem{
+				{Id: 1, Name: "Work", WeeklyDuration: 100 * time.Hour},
+				{Id: 2, Name: "Sleep", WeeklyDuration: 70 * time.Hour},
+			},
+		}, clickup.Configuration{})
+
+		report, err := service.ValidatePlan(ctx, 1)
+
+		require.NoError(t, err)
+		require.Len(t, report.Warnings, 1)
+		assert.Equal(t, WarningWeekExceeded, report.Warnings[0].Code)
+		assert.Equal(t, 170*time.Hour, report.TotalWeeklyDuration)
+	})
+
+	t.Run("sub-items are not counted twice", func(t *testing.T) {
+		service := setup(budget_plan.BudgetPlan{
+			Id: 1,
+			Items: []budget_plan.BudgetItem{
+				{Id: 1, Name: "Work", WeeklyDuration: 100 * time.Hour},
+				{Id: 2, Name: "Meetings", WeeklyDuration: 40 * time.Hour, ParentId: 1},
+				{Id: 3, Name: "Coding", WeeklyDuration: 60 * time.Hour, ParentId: 1},
+			},
+		}, clickup.Configuration{})
+
+		report, err := service.ValidatePlan(ctx, 1)
+
+		require.NoError(t, err)
+		assert.True(t, report.Valid())
+		assert.Equal(t, 100*time.Hour, report.TotalWeeklyDuration)
+	})
+
+	t.Run("warns about items with zero duration", func(t *testing.T) {
+		service := setup(budget_plan.BudgetPlan{
+			Id: 1,
+			Items: []budget_plan.BudgetItem{
+				{Id: 1, Name: "Work", WeeklyDuration: 40 * time.Hour},
+				{Id: 2, Name: "Reading", WeeklyDuration: 0},
+			},
+		}, clickup.Configuration{})
+
+		report, err := service.ValidatePlan(ctx, 1)
+
+		require.NoError(t, err)
+		require.Len(t, report.Warnings, 1)
+		assert.Equal(t, WarningZeroDuration, report.Warnings[0].Code)
+		assert.Equal(t, []int{2}, report.Warnings[0].BudgetItemIds)
+	})
+
+	t.Run("warns about duplicate names ignoring case and whitespace", func(t *testing.T) {
+		service := setup(budget_plan.BudgetPlan{
+			Id: 1,
+			Items: []budget_plan.BudgetItem{
+				{Id: 1, Name: "Work", WeeklyDuration: 20 * time.Hour},
+				{Id: 2, Name: "Sleep", WeeklyDuration: 56 * time.Hour},
+				{Id: 3, Name: " work ", WeeklyDuration: 20 * time.Hour},
+			},
+		}, clickup.Configuration{})
+
+		report, err := service.ValidatePlan(ctx, 1)
+
+		require.NoError(t, err)
+		require.Len(t, report.Warnings, 1)
+		assert.Equal(t, WarningDuplicateName, report.Warnings[0].Code)
+		assert.Equal(t, []int{1, 3}, report.Warnings[0].BudgetItemIds)
+	})
+
+	t.Run("warns about ClickUp mappings of items outside the plan", func(t *testing.T) {
+		service := setup(budget_plan.BudgetPlan{
+			Id: 1,
+			Items: []budget_plan.BudgetItem{
+				{Id: 1, Name: "Work", WeeklyDuration: 40 * time.Hour},
+			},
+		}, clickup.Configuration{
+			Mappings: []clickup.BudgetItemMapping{
+				{ClickupTagName: "work", BudgetItemId: 1},
+				{ClickupTagName: "reading", BudgetItemId: 7},
+			},
+		})
+
+		report, err := service.ValidatePlan(ctx, 1)
+
+		require.NoError(t, err)
+		require.Len(t, report.Warnings, 1)
+		assert.Equal(t, WarningOrphanedClickUpMapping, report.Warnings[0].Code)
+		assert.Equal(t, []int{7}, report.Warnings[0].BudgetItemIds)
+	})
+
+	t.Run("returns error when plan does not exist", func(t *testing.T) {
+		service := setup(budget_plan.BudgetPlan{Id: 1}, clickup.Configuration{})
+
+		_, err := service.ValidatePlan(ctx, 2)
+
+		assert.ErrorIs(t, err, budget_plan.ErrPlanNotFound)
+	})
+}
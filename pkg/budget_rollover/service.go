@@ -0,0 +1,159 @@
+package budget_rollover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	log "github.com/sirupsen/logrus"
+)
+
+// Service carries unused (or overspent) time of budget items with rollover enabled over to the next week.
+// When a week closes, the difference between the planned and the tracked time of such an item is added
+// to the item's duration in the new week.
+type Service interface {
+	// RunRollover applies the rollover to the current week of every user with rollover items.
+	RunRollover(ctx context.Context, now time.Time) error
+}
+
+type budgetPlanService interface {
+	GetUserIdsWithRolloverItems(ctx context.Context) ([]int, error)
+	GetCurrentPlan(ctx context.Context) (budget_plan.BudgetPlan, error)
+}
+
+type weeklyPlanService interface {
+	GetPlanForWeek(ctx context.Context, date time.Time) (weekly_plan.WeeklyPlan, error)
+	ApplyCarryOver(ctx context.Context, weekDate time.Time, carried map[int]time.Duration) ([]weekly_plan.WeeklyPlanItem, error)
+}
+
+type calendarEventsReader interface {
+	GetEvents(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error)
+}
+
+type UserProvider interface {
+	GetUser(ctx context.Context, id int) (user.User, error)
+}
+
+type ServiceImpl struct {
+	budgetPlanService budgetPlanService
+	weeklyPlanService weeklyPlanService
+	calendar          calendarEventsReader
+	userService       UserProvider
+}
+
+func NewService(
+	budgetPlanService budgetPlanService,
+	weeklyPlanService weeklyPlanService,
+	calendar calendarEventsReader,
+	userService UserProvider,
+) *ServiceImpl {
+	return &ServiceImpl{
+		budgetPlanService: budgetPlanService,
+		weeklyPlanService: weeklyPlanService,
+		calendar:          calendar,
+		userService:       userService,
+	}
+}
+
+func (s *ServiceImpl) RunRollover(ctx context.Context, now time.Time) error {
+	userIds, err := s.budgetPlanService.GetUserIdsWithRolloverItems(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, userId := range userIds {
+		u, err := s.userService.GetUser(ctx, userId)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to get user %d: %w", userId, err))
+			continue
+		}
+		if u.Disabled {
+			continue
+		}
+		if err := s.rolloverForUser(user.WithUser(ctx, u), u, now); err != nil {
+			errs = append(errs, fmt.Errorf("user %d: %w", userId, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *ServiceImpl) rolloverForUser(ctx context.Context, u user.User, now time.Time) error {
+	location, err := time.LoadLocation(u.Settings.Timezone)
+	if err != nil {
+		return fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	currentWeekStart := utils.WeekStart(now.In(location), u.Settings.WeekFirstDay)
+	previousWeekStart := currentWeekStart.AddDate(0, 0, -7)
+
+	currentPlan, err := s.budgetPlanService.GetCurrentPlan(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current plan: %w", err)
+	}
+	// Items with sub-items hold the sum of their sub-items, the rollover applies to the sub-items
+	rolloverItemIds := make(map[int]bool)
+	for _, item := range currentPlan.Items {
+		if item.Rollover && !currentPlan.HasChildren(item.Id) {
+			rolloverItemIds[item.Id] = true
+		}
+	}
+
+	currentWeek, err := s.weeklyPlanService.GetPlanForWeek(ctx, currentWeekStart)
+	if err != nil {
+		return fmt.Errorf("failed to get current week plan: %w", err)
+	}
+	pending := false
+	for _, item := range currentWeek.Items {
+		if rolloverItemIds[item.BudgetItemId] && item.CarriedOver == nil {
+			pending = true
+			break
+		}
+	}
+	if !pending {
+		return nil
+	}
+
+	previousWeek, err := s.weeklyPlanService.GetPlanForWeek(ctx, previousWeekStart)
+	if err != nil {
+		return fmt.Errorf("failed to get previous week plan: %w", err)
+	}
+	events, err := s.calendar.GetEvents(ctx, previousWeekStart, currentWeekStart.Add(-time.Nanosecond))
+	if err != nil {
+		return fmt.Errorf("failed to get previous week events: %w", err)
+	}
+	tracked := make(map[int]time.Duration)
+	for _, event := range calendar.WithoutSandbox(events) {
+		tracked[event.Metadata.BudgetItemId] += event.EndTime.Sub(event.StartTime)
+	}
+
+	carried := make(map[int]time.Duration)
+	for _, item := range previousWeek.Items {
+		if !rolloverItemIds[item.BudgetItemId] {
+			continue
+		}
+		// Nothing is carried out of an off-week, the plan was not meant to be followed
+		if previousWeek.IsOffWeek {
+			carried[item.BudgetItemId] = 0
+			continue
+		}
+		carried[item.BudgetItemId] = item.WeeklyDuration - tracked[item.BudgetItemId]
+	}
+	if len(carried) == 0 {
+		return nil
+	}
+
+	applied, err := s.weeklyPlanService.ApplyCarryOver(ctx, currentWeekStart, carried)
+	if err != nil {
+		return err
+	}
+	for _, item := range applied {
+		log.Debugf("Carried %s over to %s for budget item %d of user %d", *item.CarriedOver, item.WeekNumber,
+			item.BudgetItemId, u.Id)
+	}
+	return nil
+}
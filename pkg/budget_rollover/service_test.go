@@ -0,0 +1,151 @@
+package budget_rollover
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var location, _ = time.LoadLocation("Europe/Warsaw")
+
+var testUser = user.User{
+	Id:       1,
+	Username: "test-user",
+	Settings: user.Settings{
+		Timezone:          "Europe/Warsaw",
+		WeekFirstDay:      time.Monday,
+		EventCalendarType: user.KlokkuCalendar,
+		RenamePropagation: user.DefaultRenamePropagation,
+	},
+}
+
+type budgetPlanServiceStub struct {
+	plan budget_plan.BudgetPlan
+}
+
+func (s *budgetPlanServiceStub) GetUserIdsWithRolloverItems(_ context.Context) ([]int, error) {
+	return []int{testUser.Id}, nil
+}
+
+func (s *budgetPlanServiceStub) GetCurrentPlan(_ context.Context) (budget_plan.BudgetPlan, error) {
+	return s.plan, nil
+}
+
+type userProviderStub struct{}
+
+func (userProviderStub) GetUser(_ context.Context, _ int) (user.User, error) {
+	return testUser, nil
+}
+
+// Wednesday of 2025-W04, the previous week is 2025-W03 (13-19 January)
+var now = time.Date(2025, 1, 22, 10, 0, 0, 0, location)
+var previousWeekDay = time.Date(2025, 1, 14, 0, 0, 0, 0, location)
+
+func setup(t *testing.T) (*ServiceImpl, weekly_plan.Service, *calendar.StubCalendar, context.Context) {
+	t.Helper()
+	plan := budget_plan.BudgetPlan{
+		Id:        1,
+		Name:      "Plan",
+		IsCurrent: true,
+		Items: []budget_plan.BudgetItem{
+			{Id: 101, PlanId: 1, Name: "Reading", WeeklyDuration: 5 * time.Hour, Rollover: true, Position: 0},
+			{Id: 102, PlanId: 1, Name: "Work", WeeklyDuration: 40 * time.Hour, Position: 1},
+		},
+	}
+	bpReader := weekly_plan.NewBudgetPlanReaderStub()
+	bpReader.SetCurrentPlan(plan)
+	bpReader.SetPlan(plan)
+	weeklyPlanService := weekly_plan.NewService(weekly_plan.NewRepositoryStub(), bpReader, weekly_plan.NewAbsenceReaderStub(),
+		event_bus.NewEventBus())
+	calendarStub := calendar.NewStubCalendar()
+	service := NewService(&budgetPlanServiceStub{plan: plan}, weeklyPlanService, calendarStub, userProviderStub{})
+	return service, weeklyPlanService, calendarStub, user.WithUser(context.Background(), testUser)
+}
+
+func track(t *testing.T, ctx context.Context, cal *calendar.StubCalendar, budgetItemId int, start time.Time, d time.Duration) {
+	t.Helper()
+	_, err := cal.AddEvent(ctx, calendar.Event{
+		Summary:   "event",
+		StartTime: start,
+		EndTime:   start.Add(d),
+		Metadata:  calendar.EventMetadata{BudgetItemId: budgetItemId},
+	})
+	require.NoError(t, err)
+}
+
+func currentWeekItem(t *testing.T, ctx context.Context, weeklyPlanService weekly_plan.Service, budgetItemId int) weekly_plan.WeeklyPlanItem {
+	t.Helper()
+	items, err := weeklyPlanService.GetItemsForWeek(ctx, now)
+	require.NoError(t, err)
+	for _, item := range items {
+		if item.BudgetItemId == budgetItemId {
+			return item
+		}
+	}
+	t.Fatalf("no item for budget item %d", budgetItemId)
+	return weekly_plan.WeeklyPlanItem{}
+}
+
+func TestServiceImpl_RunRollover(t *testing.T) {
+	t.Run("carries unused time over to the next week once", func(t *testing.T) {
+		service, weeklyPlanService, cal, ctx := setup(t)
+		_, err := weeklyPlanService.UpdateItem(ctx, previousWeekDay, 0, 101, 6*time.Hour, "")
+		require.NoError(t, err)
+		track(t, ctx, cal, 101, previousWeekDay.Add(18*time.Hour), 2*time.Hour)
+		track(t, ctx, cal, 102, previousWeekDay.Add(8*time.Hour), 8*time.Hour)
+
+		require.NoError(t, service.RunRollover(context.Background(), now))
+		require.NoError(t, service.RunRollover(context.Background(), now.Add(time.Hour)))
+
+		reading := currentWeekItem(t, ctx, weeklyPlanService, 101)
+		assert.Equal(t, 9*time.Hour, reading.WeeklyDuration)
+		require.NotNil(t, reading.CarriedOver)
+		assert.Equal(t, 4*time.Hour, *reading.CarriedOver)
+
+		work := currentWeekItem(t, ctx, weeklyPlanService, 102)
+		assert.Equal(t, 40*time.Hour, work.WeeklyDuration)
+		assert.Nil(t, work.CarriedOver)
+	})
+
+	t.Run("reduces the next week when the previous one was overspent", func(t *testing.T) {
+		service, weeklyPlanService, cal, ctx := setup(t)
+		track(t, ctx, cal, 101, previousWeekDay.Add(8*time.Hour), 8*time.Hour)
+
+		require.NoError(t, service.RunRollover(context.Background(), now))
+
+		reading := currentWeekItem(t, ctx, weeklyPlanService, 101)
+		assert.Equal(t, 2*time.Hour, reading.WeeklyDuration)
+		assert.Equal(t, -3*time.Hour, *reading.CarriedOver)
+	})
+
+	t.Run("does not go below zero", func(t *testing.T) {
+		service, weeklyPlanService, cal, ctx := setup(t)
+		track(t, ctx, cal, 101, previousWeekDay.Add(8*time.Hour), 12*time.Hour)
+
+		require.NoError(t, service.RunRollover(context.Background(), now))
+
+		reading := currentWeekItem(t, ctx, weeklyPlanService, 101)
+		assert.Equal(t, time.Duration(0), reading.WeeklyDuration)
+		assert.Equal(t, -7*time.Hour, *reading.CarriedOver)
+	})
+
+	t.Run("carries nothing out of an off-week", func(t *testing.T) {
+		service, weeklyPlanService, _, ctx := setup(t)
+		_, err := weeklyPlanService.SetOffWeek(ctx, previousWeekDay, true)
+		require.NoError(t, err)
+
+		require.NoError(t, service.RunRollover(context.Background(), now))
+
+		reading := currentWeekItem(t, ctx, weeklyPlanService, 101)
+		assert.Equal(t, 5*time.Hour, reading.WeeklyDuration)
+		assert.Equal(t, time.Duration(0), *reading.CarriedOver)
+	})
+}
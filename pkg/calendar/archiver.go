@@ -0,0 +1,59 @@
+package calendar
+
+import (
+	"context"
+	"time"
+
+	"github.com/klokku/klokku/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// Archiver moves old events to the archive, so that the day-to-day queries stay fast for active users.
+// Archived events are only read by exports and long-range reports (see Service.GetEventsIncludingArchive).
+// It also deletes the old entries of the calendar history.
+type Archiver struct {
+	repo Repository
+	// archiveAfter is how long after their end events are archived, 0 disables archiving.
+	archiveAfter time.Duration
+	// historyRetention is how long the calendar history is kept, 0 keeps it forever.
+	historyRetention time.Duration
+}
+
+func NewArchiver(repo Repository, cfg config.Archive) *Archiver {
+	return &Archiver{
+		repo:             repo,
+		archiveAfter:     time.Duration(cfg.EventsAfterDays) * 24 * time.Hour,
+		historyRetention: time.Duration(cfg.HistoryDays) * 24 * time.Hour,
+	}
+}
+
+// ArchiveOldEvents archives events of all users that ended more than the configured age before now.
+func (a *Archiver) ArchiveOldEvents(ctx context.Context, now time.Time) (int, error) {
+	if a.archiveAfter <= 0 {
+		return 0, nil
+	}
+	archived, err := a.repo.ArchiveEventsEndedBefore(ctx, now.Add(-a.archiveAfter))
+	if err != nil {
+		return 0, err
+	}
+	if archived > 0 {
+		log.Infof("Archived %d calendar events", archived)
+	}
+	return archived, nil
+}
+
+// DeleteOldHistory deletes the calendar history entries of all users recorded more than the configured retention
+// before now.
+func (a *Archiver) DeleteOldHistory(ctx context.Context, now time.Time) (int, error) {
+	if a.historyRetention <= 0 {
+		return 0, nil
+	}
+	deleted, err := a.repo.DeleteEventChangesBefore(ctx, now.Add(-a.historyRetention))
+	if err != nil {
+		return 0, err
+	}
+	if deleted > 0 {
+		log.Infof("Deleted %d calendar history entries", deleted)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,106 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiver_ArchiveOldEvents(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, location)
+	oldStart := now.AddDate(0, 0, -40)
+	recentStart := now.AddDate(0, 0, -5)
+
+	t.Run("moves events older than the configured age to the archive", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		repo := service.repo.(*RepositoryStub)
+		archiver := NewArchiver(repo, config.Archive{EventsAfterDays: 30})
+
+		old, err := repo.StoreEvent(ctx, 1, Event{Summary: "old", StartTime: oldStart, EndTime: oldStart.Add(time.Hour), Metadata: EventMetadata{BudgetItemId: 101}})
+		require.NoError(t, err)
+		_, err = repo.StoreEvent(ctx, 1, Event{Summary: "old sandbox", StartTime: oldStart.Add(2 * time.Hour), EndTime: oldStart.Add(3 * time.Hour), Metadata: EventMetadata{BudgetItemId: 101, Sandbox: true}})
+		require.NoError(t, err)
+		recent, err := repo.StoreEvent(ctx, 1, Event{Summary: "recent", StartTime: recentStart, EndTime: recentStart.Add(time.Hour), Metadata: EventMetadata{BudgetItemId: 102}})
+		require.NoError(t, err)
+
+		archived, err := archiver.ArchiveOldEvents(ctx, now)
+		require.NoError(t, err)
+		assert.Equal(t, 1, archived)
+
+		hot, err := service.GetEvents(ctx, oldStart.Add(-time.Hour), now)
+		require.NoError(t, err)
+		require.Len(t, hot, 2)
+		assert.Equal(t, "old sandbox", hot[0].Summary)
+		assert.Equal(t, recent.UID, hot[1].UID)
+
+		all, err := service.GetEventsIncludingArchive(ctx, oldStart.Add(-time.Hour), now)
+		require.NoError(t, err)
+		require.Len(t, all, 3)
+		assert.Equal(t, old.UID, all[0].UID)
+		assert.Equal(t, recent.UID, all[2].UID)
+
+		earliest, found, err := repo.GetEarliestEventTimeForBudgetItems(ctx, 1, []int{101})
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, oldStart, earliest)
+	})
+
+	t.Run("does nothing when archiving is disabled", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		repo := service.repo.(*RepositoryStub)
+		archiver := NewArchiver(repo, config.Archive{EventsAfterDays: 0})
+
+		_, err := repo.StoreEvent(ctx, 1, Event{Summary: "old", StartTime: oldStart, EndTime: oldStart.Add(time.Hour), Metadata: EventMetadata{BudgetItemId: 101}})
+		require.NoError(t, err)
+
+		archived, err := archiver.ArchiveOldEvents(ctx, now)
+		require.NoError(t, err)
+		assert.Equal(t, 0, archived)
+
+		hot, err := service.GetEvents(ctx, oldStart, now)
+		require.NoError(t, err)
+		assert.Len(t, hot, 1)
+	})
+}
+
+func TestArchiver_DeleteOldHistory(t *testing.T) {
+	t.Run("deletes the history recorded before the retention", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		repo := service.repo.(*RepositoryStub)
+		archiver := NewArchiver(repo, config.Archive{HistoryDays: 30})
+		start := time.Date(2026, 6, 1, 12, 0, 0, 0, location)
+		_, err := repo.StoreEvent(ctx, 1, Event{Summary: "event", StartTime: start, EndTime: start.Add(time.Hour), Metadata: EventMetadata{BudgetItemId: 101}})
+		require.NoError(t, err)
+
+		deleted, err := archiver.DeleteOldHistory(ctx, time.Now().AddDate(0, 0, 29))
+		require.NoError(t, err)
+		assert.Equal(t, 0, deleted)
+
+		deleted, err = archiver.DeleteOldHistory(ctx, time.Now().AddDate(0, 0, 31))
+		require.NoError(t, err)
+		assert.Equal(t, 1, deleted)
+		changes, err := repo.GetEventChanges(ctx, 1, time.Time{}, time.Now())
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("keeps the history when the retention is disabled", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		repo := service.repo.(*RepositoryStub)
+		archiver := NewArchiver(repo, config.Archive{HistoryDays: 0})
+		start := time.Date(2026, 6, 1, 12, 0, 0, 0, location)
+		_, err := repo.StoreEvent(ctx, 1, Event{Summary: "event", StartTime: start, EndTime: start.Add(time.Hour), Metadata: EventMetadata{BudgetItemId: 101}})
+		require.NoError(t, err)
+
+		deleted, err := archiver.DeleteOldHistory(ctx, time.Now().AddDate(1, 0, 0))
+		require.NoError(t, err)
+		assert.Equal(t, 0, deleted)
+	})
+}
@@ -1,17 +1,68 @@
 package calendar
 
 import (
+	"maps"
 	"time"
 )
 
 type Event struct {
-	UID       string
+	UID string
+	// ParentUID links the parts of an event split at day boundaries to its first part. It is empty for the first
+	// part and for events that were not split.
+	ParentUID string
 	Summary   string
 	StartTime time.Time
 	EndTime   time.Time
 	Metadata  EventMetadata
 }
 
+// LogicalUID returns the UID shared by all parts of the event split at day boundaries, the UID of its first part.
+func (e Event) LogicalUID() string {
+	if e.ParentUID != "" {
+		return e.ParentUID
+	}
+	return e.UID
+}
+
 type EventMetadata struct {
-	BudgetItemId int `json:"budgetItemId"`
+	BudgetItemId int    `json:"budgetItemId"`
+	Notes        string `json:"notes,omitempty"`
+	// TaskId is an optional reference to an external task (e.g. ClickUp task id) the time was spent on.
+	TaskId string `json:"taskId,omitempty"`
+	// Sandbox marks generated demo events. They are excluded from stats, reports and exports by default.
+	Sandbox bool `json:"sandbox,omitempty"`
+	// Location is where the time was spent, free text (e.g. "office") or coordinates (e.g. "52.2297,21.0122").
+	Location string `json:"location,omitempty"`
+	// Attributes hold data integrations attach to the event (e.g. a Jira issue key), keyed by a name of their choice.
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+const (
+	MaxEventAttributes   = 20
+	MaxAttributeKeyLen   = 64
+	MaxAttributeValueLen = 1000
+	MaxLocationLen       = 200
+)
+
+// Equal reports whether both metadata are the same, attributes are compared by their content.
+func (m EventMetadata) Equal(other EventMetadata) bool {
+	return m.BudgetItemId == other.BudgetItemId && m.Notes == other.Notes && m.TaskId == other.TaskId &&
+		m.Sandbox == other.Sandbox && m.Location == other.Location && maps.Equal(m.Attributes, other.Attributes)
+}
+
+// Overlap is a pair of stored events whose time ranges intersect.
+type Overlap struct {
+	First  Event
+	Second Event
+}
+
+// WithoutSandbox returns the events that are not sandbox events.
+func WithoutSandbox(events []Event) []Event {
+	result := make([]Event, 0, len(events))
+	for _, e := range events {
+		if !e.Metadata.Sandbox {
+			result = append(result, e)
+		}
+	}
+	return result
 }
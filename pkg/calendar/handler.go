@@ -1,26 +1,77 @@
 package calendar
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
 	"net/http"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/pkg/weekly_plan"
 	log "github.com/sirupsen/logrus"
 )
 
+type OverlapDTO struct {
+	First  EventDTO `json:"first"`
+	Second EventDTO `json:"second"`
+}
+
+type LineageLinkDTO struct {
+	SourceUID         string           `json:"sourceUid"`
+	DerivedUID        string           `json:"derivedUid,omitempty"`
+	Operation         LineageOperation `json:"operation"`
+	CausedByUID       string           `json:"causedByUid"`
+	PreviousStartTime time.Time        `json:"previousStart"`
+	PreviousEndTime   time.Time        `json:"previousEnd"`
+	CreatedAt         time.Time        `json:"createdAt"`
+}
+
+type EventDiffDTO struct {
+	EventUID string        `json:"eventUid"`
+	Type     EventDiffType `json:"type"`
+	Before   *EventDTO     `json:"before,omitempty"`
+	After    *EventDTO     `json:"after,omitempty"`
+}
+
+type DayDiffDTO struct {
+	Date        string         `json:"date"`
+	FromVersion time.Time      `json:"fromVersion"`
+	ToVersion   time.Time      `json:"toVersion"`
+	Events      []EventDiffDTO `json:"events"`
+}
+
 type Handler struct {
 	calendar *Service
 }
 
 type EventDTO struct {
-	UID          string    `json:"uid"`
+	UID string `json:"uid"`
+	// ParentUid is read-only, the uid of the first part of an event split at day boundaries. It is omitted for the
+	// first part and for events that were not split.
+	ParentUid    string    `json:"parentUid,omitempty"`
 	Summary      string    `json:"summary"`
 	StartTime    time.Time `json:"start"`
 	EndTime      time.Time `json:"end"`
 	BudgetItemId int       `json:"budgetItemId"`
+	Notes        string    `json:"notes,omitempty"`
+	TaskId       string    `json:"taskId,omitempty"`
+	// Sandbox is read-only, sandbox events are created only by the sandbox week generator
+	Sandbox bool `json:"sandbox,omitempty"`
+	// Location is free text (e.g. "office") or coordinates (e.g. "52.2297,21.0122"). An update without it keeps the
+	// stored location, an empty one clears it.
+	Location *string `json:"location,omitempty"`
+	// Attributes are key-value data attached by integrations, e.g. {"jiraIssue": "KLO-42"}. Keys start with a
+	// letter and contain only letters, digits, '_', '-' and '.'. An update without them keeps the stored attributes,
+	// an empty object clears them.
+	Attributes *map[string]string `json:"attributes,omitempty"`
 }
 
 func NewHandler(s *Service) *Handler {
@@ -29,49 +80,33 @@ func NewHandler(s *Service) *Handler {
 
 // GetEvents godoc
 // @Summary Get calendar events
-// @Description Retrieve calendar events within a date range
+// @Description Retrieve calendar events within a date range.
+// @Description The response has an ETag, a request with a matching If-None-Match header gets 304 Not Modified.
 // @Tags Calendar
 // @Produce json
 // @Param from query string true "Start date in RFC3339 format"
 // @Param to query string true "End date in RFC3339 format"
+// @Param includeArchived query bool false "Also return archived (old) events, e.g. for an export"
 // @Success 200 {array} EventDTO
+// @Success 304 "Not Modified"
 // @Failure 400 {object} rest.ErrorResponse "Invalid date format"
 // @Failure 403 {string} string "User not found"
 // @Router /api/calendar/event [get]
 // @Security XUserId
 func (h *Handler) GetEvents(w http.ResponseWriter, r *http.Request) {
-	fromString := r.URL.Query().Get("from")
-	toString := r.URL.Query().Get("to")
-	from, err := time.Parse(time.RFC3339, fromString)
+	from, to, err := parseRange(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
-			Error:   "Invalid from (date) format",
-			Details: "'from' must be in RFC3339 format",
-		})
-		if encodeErr != nil {
-			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
-			return
-		}
-		return
-	}
-	to, err := time.Parse(time.RFC3339, toString)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
-			Error:   "Invalid to (date) format",
-			Details: "'to' must be in RFC3339 format",
-		})
-		if encodeErr != nil {
-			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
-			return
-		}
+		rest.WriteBadRequest(w, err)
 		return
 	}
 
-	events, err := h.calendar.GetEvents(r.Context(), from, to)
+	getEvents := h.calendar.GetEvents
+	if r.URL.Query().Get("includeArchived") == "true" {
+		getEvents = h.calendar.GetEventsIncludingArchive
+	}
+	events, err := getEvents(r.Context(), from, to)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 
@@ -80,12 +115,7 @@ func (h *Handler) GetEvents(w http.ResponseWriter, r *http.Request) {
 		dtos = append(dtos, eventToDTO(e))
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(dtos); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	rest.WriteJSONWithETag(w, r, dtos)
 }
 
 // CreateEvent godoc
@@ -96,20 +126,29 @@ func (h *Handler) GetEvents(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param event body EventDTO true "Calendar Event"
 // @Success 201 {array} EventDTO "Array of created events (may include recurring instances)"
-// @Failure 400 {string} string "Bad Request"
+// @Failure 400 {object} rest.ErrorResponse "Invalid event"
 // @Failure 403 {string} string "User not found"
+// @Failure 409 {object} rest.ErrorResponse "Event overlaps existing events (strict calendar mode) or falls into a locked week"
 // @Router /api/calendar/event [post]
 // @Security XUserId
 func (h *Handler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 	var eventDTO EventDTO
-	if err := json.NewDecoder(r.Body).Decode(&eventDTO); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := rest.DecodeJSON(r, &eventDTO); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	if err := validateEventDTO(eventDTO); err != nil {
+		rest.WriteBadRequest(w, err)
 		return
 	}
 
 	addedEvents, err := h.calendar.AddStickyEvent(r.Context(), dtoToEvent(eventDTO))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, ErrEventOverlap) || errors.Is(err, weekly_plan.ErrWeekLocked) {
+			rest.WriteConflict(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
 		return
 	}
 
@@ -121,7 +160,7 @@ func (h *Handler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(eventDTOs); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 }
@@ -133,22 +172,54 @@ func (h *Handler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 // @Accept json
 // @Produce json
 // @Param eventUid path string true "Event UID"
+// @Param propagate query bool false "Apply the budget item, notes, task, location and attributes also to the other parts of an event split at day boundaries"
 // @Param event body EventDTO true "Updated Calendar Event"
 // @Success 200 {array} EventDTO "Array of modified events"
-// @Failure 400 {string} string "Bad Request"
+// @Failure 400 {object} rest.ErrorResponse "Invalid event"
+// @Failure 404 {object} rest.ErrorResponse "Event not found"
 // @Failure 403 {string} string "User not found"
+// @Failure 409 {object} rest.ErrorResponse "Event overlaps existing events (strict calendar mode) or falls into a locked week"
 // @Router /api/calendar/event/{eventUid} [put]
 // @Security XUserId
 func (h *Handler) UpdateEvent(w http.ResponseWriter, r *http.Request) {
 	var eventDTO EventDTO
-	if err := json.NewDecoder(r.Body).Decode(&eventDTO); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := rest.DecodeJSON(r, &eventDTO); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	if err := validateEventDTO(eventDTO); err != nil {
+		rest.WriteBadRequest(w, err)
 		return
 	}
 
-	modifiedEvents, err := h.calendar.ModifyStickyEvent(r.Context(), dtoToEvent(eventDTO))
+	modify := h.calendar.ModifyStickyEvent
+	if value := r.URL.Query().Get("propagate"); value != "" {
+		propagate, err := strconv.ParseBool(value)
+		if err != nil {
+			rest.WriteBadRequest(w, rest.InvalidField("propagate", "Invalid propagate", "'propagate' must be true or false"))
+			return
+		}
+		if propagate {
+			modify = h.calendar.ModifyStickyEventAndParts
+		}
+	}
+
+	event := dtoToEvent(eventDTO)
+	if err := h.keepOmittedMetadata(r.Context(), eventDTO, &event); err != nil {
+		if errors.Is(err, ErrEventNotFound) {
+			rest.WriteNotFound(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
+		return
+	}
+	modifiedEvents, err := modify(r.Context(), event)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, ErrEventOverlap) || errors.Is(err, weekly_plan.ErrWeekLocked) {
+			rest.WriteConflict(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
 		return
 	}
 	var eventDTOs []EventDTO
@@ -159,7 +230,7 @@ func (h *Handler) UpdateEvent(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(eventDTOs); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 }
@@ -171,6 +242,7 @@ func (h *Handler) UpdateEvent(w http.ResponseWriter, r *http.Request) {
 // @Param eventUid path string true "Event UID"
 // @Success 204 "No Content"
 // @Failure 403 {string} string "User not found"
+// @Failure 409 {object} rest.ErrorResponse "Event falls into a locked week"
 // @Router /api/calendar/event/{eventUid} [delete]
 // @Security XUserId
 func (h *Handler) DeleteEvent(w http.ResponseWriter, r *http.Request) {
@@ -180,30 +252,261 @@ func (h *Handler) DeleteEvent(w http.ResponseWriter, r *http.Request) {
 	eventUidString := vars["eventUid"]
 	err := h.calendar.DeleteEvent(r.Context(), eventUidString)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, weekly_plan.ErrWeekLocked) {
+			rest.WriteConflict(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// GetOverlaps godoc
+// @Summary Get overlapping calendar events
+// @Description Report pairs of existing calendar events that overlap each other within a date range.
+// @Description Useful to clean up the calendar before enabling strict calendar mode.
+// @Tags Calendar
+// @Produce json
+// @Param from query string true "Start date in RFC3339 format"
+// @Param to query string true "End date in RFC3339 format"
+// @Success 200 {array} OverlapDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid date format"
+// @Failure 403 {string} string "User not found"
+// @Router /api/calendar/event/overlaps [get]
+// @Security XUserId
+func (h *Handler) GetOverlaps(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	from, to, err := parseRange(r)
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+
+	overlaps, err := h.calendar.FindOverlaps(r.Context(), from, to)
+	if err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+
+	dtos := make([]OverlapDTO, 0, len(overlaps))
+	for _, o := range overlaps {
+		dtos = append(dtos, OverlapDTO{First: eventToDTO(o.First), Second: eventToDTO(o.Second)})
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+}
+
+// GetEventLineage godoc
+// @Summary Get calendar event lineage
+// @Description Explain how sticky operations changed an event: the events it was derived from and the events derived from it.
+// @Description Operation is one of: shortened, shifted, split, removed.
+// @Tags Calendar
+// @Produce json
+// @Param eventUid path string true "Event UID"
+// @Success 200 {array} LineageLinkDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/calendar/event/{eventUid}/lineage [get]
+// @Security XUserId
+func (h *Handler) GetEventLineage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	eventUid := mux.Vars(r)["eventUid"]
+
+	links, err := h.calendar.GetLineage(r.Context(), eventUid)
+	if err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+
+	dtos := make([]LineageLinkDTO, 0, len(links))
+	for _, link := range links {
+		dtos = append(dtos, LineageLinkDTO{
+			SourceUID:         link.SourceUID,
+			DerivedUID:        link.DerivedUID,
+			Operation:         link.Operation,
+			CausedByUID:       link.CausedByUID,
+			PreviousStartTime: link.PreviousStartTime,
+			PreviousEndTime:   link.PreviousEndTime,
+			CreatedAt:         link.CreatedAt,
+		})
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+}
+
+// GetDiff godoc
+// @Summary Get changes of a calendar day between two versions
+// @Description Show how the events of a day changed between two points in time, based on the calendar history.
+// @Description Type is one of: added, removed, modified. Sandbox events are not tracked.
+// @Tags Calendar
+// @Produce json
+// @Param date query string true "Day in YYYY-MM-DD or RFC3339 format"
+// @Param fromVersion query string true "Point in time of the older version in RFC3339 format"
+// @Param toVersion query string true "Point in time of the newer version in RFC3339 format"
+// @Success 200 {object} DayDiffDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid date or version"
+// @Failure 403 {string} string "User not found"
+// @Router /api/calendar/diff [get]
+// @Security XUserId
+func (h *Handler) GetDiff(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	query := r.URL.Query()
+	var v rest.Validator
+	date, err := time.Parse(time.DateOnly, query.Get("date"))
+	if err != nil {
+		date, err = time.Parse(time.RFC3339, query.Get("date"))
+	}
+	v.Check(err == nil, "date", "Invalid date format", "'date' must be in YYYY-MM-DD or RFC3339 format")
+	fromVersion, err := time.Parse(time.RFC3339, query.Get("fromVersion"))
+	v.Check(err == nil, "fromVersion", "Invalid fromVersion format", "'fromVersion' must be in RFC3339 format")
+	toVersion, err := time.Parse(time.RFC3339, query.Get("toVersion"))
+	v.Check(err == nil, "toVersion", "Invalid toVersion format", "'toVersion' must be in RFC3339 format")
+	if err := v.Err(); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+
+	diff, err := h.calendar.GetDayDiff(r.Context(), date, fromVersion, toVersion)
+	if err != nil {
+		if errors.Is(err, ErrInvalidVersionRange) {
+			rest.WriteError(w, http.StatusBadRequest, rest.ErrorResponse{Error: "Invalid version range", Details: err.Error()})
+			return
+		}
+		rest.WriteInternalError(w, err)
+		return
+	}
+
+	dto := DayDiffDTO{
+		Date:        diff.Date.Format(time.DateOnly),
+		FromVersion: diff.FromVersion,
+		ToVersion:   diff.ToVersion,
+		Events:      make([]EventDiffDTO, 0, len(diff.Events)),
+	}
+	for _, e := range diff.Events {
+		eventDiff := EventDiffDTO{EventUID: e.EventUID, Type: e.Type}
+		if e.Before != nil {
+			before := eventToDTO(*e.Before)
+			eventDiff.Before = &before
+		}
+		if e.After != nil {
+			after := eventToDTO(*e.After)
+			eventDiff.After = &after
+		}
+		dto.Events = append(dto.Events, eventDiff)
+	}
+	if err := json.NewEncoder(w).Encode(dto); err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+}
+
+// parseRange parses the from and to query parameters.
+func parseRange(r *http.Request) (time.Time, time.Time, error) {
+	var v rest.Validator
+	from, err := time.Parse(time.RFC3339, r.URL.Query().Get("from"))
+	v.Check(err == nil, "from", "Invalid from (date) format", "'from' must be in RFC3339 format")
+	to, err := time.Parse(time.RFC3339, r.URL.Query().Get("to"))
+	v.Check(err == nil, "to", "Invalid to (date) format", "'to' must be in RFC3339 format")
+	return from, to, v.Err()
+}
+
+// validateEventDTO reports all problems the service would reject the event for, one at a time.
+func validateEventDTO(e EventDTO) error {
+	var v rest.Validator
+	v.Check(!e.StartTime.IsZero(), "start", "Invalid start", "'start' is required")
+	v.Check(!e.EndTime.IsZero(), "end", "Invalid end", "'end' is required")
+	if !e.StartTime.IsZero() && !e.EndTime.IsZero() {
+		v.Check(e.EndTime.After(e.StartTime), "end", "Invalid end", "'end' must be after 'start'")
+	}
+	v.Check(e.BudgetItemId != 0, "budgetItemId", "Invalid budget item", "'budgetItemId' is required")
+	if e.Location != nil {
+		v.Check(len(*e.Location) <= MaxLocationLen, "location", "Invalid location",
+			fmt.Sprintf("'location' can have at most %d characters", MaxLocationLen))
+	}
+	if e.Attributes != nil {
+		checkAttributes(&v, *e.Attributes)
+	}
+	return v.Err()
+}
+
+var attributeKeyPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]*$`)
+
+func checkAttributes(v *rest.Validator, attributes map[string]string) {
+	v.Check(len(attributes) <= MaxEventAttributes, "attributes", "Invalid attributes",
+		fmt.Sprintf("an event can have at most %d attributes", MaxEventAttributes))
+	for _, key := range slices.Sorted(maps.Keys(attributes)) {
+		v.Check(len(key) <= MaxAttributeKeyLen && attributeKeyPattern.MatchString(key), "attributes."+key,
+			"Invalid attribute", fmt.Sprintf("attribute keys must start with a letter, contain only letters, "+
+				"digits, '_', '-' and '.', and have at most %d characters", MaxAttributeKeyLen))
+		v.Check(len(attributes[key]) <= MaxAttributeValueLen, "attributes."+key, "Invalid attribute",
+			fmt.Sprintf("attribute values can have at most %d characters", MaxAttributeValueLen))
+	}
+}
+
 func eventToDTO(e Event) EventDTO {
-	return EventDTO{
+	dto := EventDTO{
 		UID:          e.UID,
+		ParentUid:    e.ParentUID,
 		Summary:      e.Summary,
 		StartTime:    e.StartTime,
 		EndTime:      e.EndTime,
 		BudgetItemId: e.Metadata.BudgetItemId,
+		Notes:        e.Metadata.Notes,
+		TaskId:       e.Metadata.TaskId,
+		Sandbox:      e.Metadata.Sandbox,
+	}
+	if e.Metadata.Location != "" {
+		dto.Location = &e.Metadata.Location
 	}
+	if len(e.Metadata.Attributes) > 0 {
+		dto.Attributes = &e.Metadata.Attributes
+	}
+	return dto
 }
 
+// dtoToEvent converts the DTO, a missing location or attributes are empty.
 func dtoToEvent(e EventDTO) Event {
-	return Event{
+	event := Event{
 		UID:       e.UID,
 		Summary:   e.Summary,
 		StartTime: e.StartTime,
 		EndTime:   e.EndTime,
-		Metadata:  EventMetadata{BudgetItemId: e.BudgetItemId},
+		Metadata: EventMetadata{
+			BudgetItemId: e.BudgetItemId,
+			Notes:        e.Notes,
+			TaskId:       e.TaskId,
+		},
+	}
+	if e.Location != nil {
+		event.Metadata.Location = strings.TrimSpace(*e.Location)
+	}
+	if e.Attributes != nil {
+		event.Metadata.Attributes = *e.Attributes
+	}
+	return event
+}
+
+// keepOmittedMetadata sets the location and the attributes the update omits to the stored ones of the event, so a
+// client that doesn't know them doesn't wipe them.
+func (h *Handler) keepOmittedMetadata(ctx context.Context, eventDTO EventDTO, event *Event) error {
+	if eventDTO.Location != nil && eventDTO.Attributes != nil {
+		return nil
+	}
+	stored, err := h.calendar.GetEvent(ctx, event.UID)
+	if err != nil {
+		return err
+	}
+	if eventDTO.Location == nil {
+		event.Metadata.Location = stored.Metadata.Location
+	}
+	if eventDTO.Attributes == nil {
+		event.Metadata.Attributes = stored.Metadata.Attributes
 	}
+	return nil
 }
 
 // GetLastEvents godoc
@@ -229,7 +532,7 @@ func (h *Handler) GetLastEvents(w http.ResponseWriter, r *http.Request) {
 
 	events, err := h.calendar.GetLastEvents(r.Context(), last)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 
@@ -240,7 +543,7 @@ func (h *Handler) GetLastEvents(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(eventsDTO); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 	log.Tracef("Events returned: %d", len(eventsDTO))
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -45,7 +46,7 @@ func withUser(userId int, next http.Handler) http.Handler {
 func setupHandlerTest(t *testing.T) (*Handler, func()) {
 	repoStub := NewRepositoryStub()
 	eventBus := event_bus.NewEventBus()
-	service := NewService(repoStub, eventBus, weeklyItemsProvider)
+	service := NewService(repoStub, eventBus, weeklyItemsProvider, nil)
 	handler := NewHandler(service)
 	return handler, func() {
 		t.Log("Teardown after test")
@@ -269,6 +270,43 @@ func TestEventToDTO(t *testing.T) {
 	assert.Equal(t, 123, dto.BudgetItemId)
 }
 
+func TestValidateEventDTO_Attributes(t *testing.T) {
+	valid := EventDTO{
+		StartTime:    time.Date(2025, 1, 1, 10, 0, 0, 0, location),
+		EndTime:      time.Date(2025, 1, 1, 11, 0, 0, 0, location),
+		BudgetItemId: 1,
+	}
+	tooMany := make(map[string]string)
+	for i := range MaxEventAttributes + 1 {
+		tooMany[fmt.Sprintf("key%d", i)] = "value"
+	}
+	tests := map[string]struct {
+		attributes map[string]string
+		valid      bool
+	}{
+		"no attributes":    {attributes: nil, valid: true},
+		"valid attributes": {attributes: map[string]string{"jiraIssue": "KLO-42", "clickup.task_id": "abc"}, valid: true},
+		"key with space":   {attributes: map[string]string{"jira issue": "KLO-42"}},
+		"key with digit":   {attributes: map[string]string{"1key": "value"}},
+		"too long value":   {attributes: map[string]string{"key": strings.Repeat("a", MaxAttributeValueLen+1)}},
+		"too many":         {attributes: tooMany},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := valid
+			e.Attributes = &tt.attributes
+
+			err := validateEventDTO(e)
+
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
 func TestUpdateEvent(t *testing.T) {
 	// Setup
 	handler, teardown := setupHandlerTest(t)
@@ -389,6 +427,71 @@ func TestUpdateEvent(t *testing.T) {
 	assert.True(t, found, "Updated event should be returned when querying events")
 }
 
+func TestUpdateEvent_KeepsOmittedLocationAndAttributes(t *testing.T) {
+	handler, teardown := setupHandlerTest(t)
+	defer teardown()
+	userId := 123
+	officeLocation := "office"
+	attributes := map[string]string{"jiraIssue": "KLO-42"}
+	var created []EventDTO
+	createReq := httptest.NewRequest(http.MethodPost, "/event", bytes.NewBuffer(mustMarshal(t, EventDTO{
+		StartTime:    time.Date(2025, 1, 1, 10, 0, 0, 0, location),
+		EndTime:      time.Date(2025, 1, 1, 11, 0, 0, 0, location),
+		BudgetItemId: 101,
+		Location:     &officeLocation,
+		Attributes:   &attributes,
+	})))
+	createW := httptest.NewRecorder()
+	handler.CreateEvent(createW, createReq.WithContext(contextWithUser(context.Background(), userId)))
+	assert.Equal(t, http.StatusCreated, createW.Code)
+	assert.NoError(t, json.NewDecoder(createW.Body).Decode(&created))
+
+	update := func(body string) EventDTO {
+		req := httptest.NewRequest(http.MethodPut, "/event", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.UpdateEvent(w, req.WithContext(contextWithUser(context.Background(), userId)))
+		assert.Equal(t, http.StatusOK, w.Code)
+		var updated []EventDTO
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&updated))
+		return updated[0]
+	}
+	event := fmt.Sprintf(`"uid": %q, "start": "2025-01-01T10:00:00Z", "end": "2025-01-01T12:00:00Z", "budgetItemId": 102`,
+		created[0].UID)
+
+	// when the update omits them
+	updated := update(`{` + event + `}`)
+
+	// then the stored ones are kept
+	assert.Equal(t, 102, updated.BudgetItemId)
+	assert.Equal(t, &officeLocation, updated.Location)
+	assert.Equal(t, &attributes, updated.Attributes)
+
+	// when the update has them empty
+	updated = update(`{` + event + `, "location": "", "attributes": {}}`)
+
+	// then they are cleared
+	assert.Nil(t, updated.Location)
+	assert.Nil(t, updated.Attributes)
+}
+
+func TestUpdateEvent_NotFound(t *testing.T) {
+	handler, teardown := setupHandlerTest(t)
+	defer teardown()
+	body := `{"uid": "missing", "start": "2025-01-01T10:00:00Z", "end": "2025-01-01T12:00:00Z", "budgetItemId": 101}`
+	req := httptest.NewRequest(http.MethodPut, "/event", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.UpdateEvent(w, req.WithContext(contextWithUser(context.Background(), 123)))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	body, err := json.Marshal(v)
+	assert.NoError(t, err)
+	return body
+}
+
 func TestDeleteEvent(t *testing.T) {
 	// Setup
 	handler, teardown := setupHandlerTest(t)
@@ -0,0 +1,49 @@
+package calendar
+
+import "time"
+
+type EventChangeType string
+
+const (
+	EventCreated EventChangeType = "created"
+	EventUpdated EventChangeType = "updated"
+	EventDeleted EventChangeType = "deleted"
+)
+
+// EventChange is an entry of the calendar history, recorded for every stored, updated and deleted event.
+// Sandbox events and archiving are not recorded.
+type EventChange struct {
+	EventUID string
+	Type     EventChangeType
+	// Previous is the event before the change, nil for created events.
+	Previous *Event
+	// Current is the event after the change, nil for deleted events.
+	Current   *Event
+	ChangedAt time.Time
+}
+
+type EventDiffType string
+
+const (
+	DiffAdded    EventDiffType = "added"
+	DiffRemoved  EventDiffType = "removed"
+	DiffModified EventDiffType = "modified"
+)
+
+// EventDiff describes how an event differs between two versions of the calendar.
+type EventDiff struct {
+	EventUID string
+	Type     EventDiffType
+	// Before is the event in the from version, nil for added events.
+	Before *Event
+	// After is the event in the to version, nil for removed events.
+	After *Event
+}
+
+// DayDiff lists the events of a day that changed between two versions (points in time) of the calendar.
+type DayDiff struct {
+	Date        time.Time
+	FromVersion time.Time
+	ToVersion   time.Time
+	Events      []EventDiff
+}
@@ -0,0 +1,29 @@
+package calendar
+
+import "time"
+
+type LineageOperation string
+
+const (
+	// LineageShortened - the end of the source event was moved back to make room for a sticky event.
+	LineageShortened LineageOperation = "shortened"
+	// LineageShifted - the start of the source event was moved forward to make room for a sticky event.
+	LineageShifted LineageOperation = "shifted"
+	// LineageSplit - the remainder of the source event after a sticky event was stored as the derived event.
+	LineageSplit LineageOperation = "split"
+	// LineageRemoved - the source event was fully covered by a sticky event and deleted.
+	LineageRemoved LineageOperation = "removed"
+)
+
+// LineageLink records how a sticky operation changed an existing event.
+type LineageLink struct {
+	SourceUID string
+	// DerivedUID is the event resulting from the change. It equals SourceUID for shortened and shifted
+	// events and is empty for removed ones.
+	DerivedUID        string
+	Operation         LineageOperation
+	CausedByUID       string
+	PreviousStartTime time.Time
+	PreviousEndTime   time.Time
+	CreatedAt         time.Time
+}
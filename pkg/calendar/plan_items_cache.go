@@ -0,0 +1,159 @@
+package calendar
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	log "github.com/sirupsen/logrus"
+)
+
+// planItemsCacheTTL keeps the cache short-lived, changes not announced by an event (e.g. a plan activation)
+// are picked up quickly.
+const planItemsCacheTTL = 30 * time.Second
+
+// planItemsCacheMaxEntries bounds the memory taken by the cache, the oldest entries are dropped first.
+const planItemsCacheMaxEntries = 10000
+
+type planItemsCacheKey struct {
+	userId int
+	week   weekly_plan.WeekNumber
+}
+
+type planItemsCacheEntry struct {
+	items     []weekly_plan.WeeklyPlanItem
+	expiresAt time.Time
+}
+
+// planItemsCache memoizes the weekly plan items looked up by the calendar per user and week. Entries live for
+// planItemsCacheTTL, or until the weekly or budget plan changes, and at most planItemsCacheMaxEntries are kept. Within an operation started with
+// withPlanItemsMemo, the items of a week are looked up at most once, however long the operation takes.
+type planItemsCache struct {
+	provider PlanItemsProviderFunc
+	clock    utils.Clock
+	mu       sync.Mutex
+	entries  map[planItemsCacheKey]planItemsCacheEntry
+}
+
+func newPlanItemsCache(provider PlanItemsProviderFunc) *planItemsCache {
+	return &planItemsCache{
+		provider: provider,
+		clock:    &utils.SystemClock{},
+		entries:  make(map[planItemsCacheKey]planItemsCacheEntry),
+	}
+}
+
+type planItemsMemoKey struct{}
+
+type planItemsMemo struct {
+	mu    sync.Mutex
+	items map[planItemsCacheKey][]weekly_plan.WeeklyPlanItem
+}
+
+// withPlanItemsMemo returns a context memoizing plan items lookups until the operation ends.
+// A context that already has a memo is returned unchanged.
+func withPlanItemsMemo(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(planItemsMemoKey{}).(*planItemsMemo); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, planItemsMemoKey{}, &planItemsMemo{items: make(map[planItemsCacheKey][]weekly_plan.WeeklyPlanItem)})
+}
+
+func (c *planItemsCache) get(ctx context.Context, date time.Time) ([]weekly_plan.WeeklyPlanItem, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return c.provider(ctx, date)
+	}
+	// The same week as the weekly plan service resolves for the date
+	key := planItemsCacheKey{currentUser.Id, weekly_plan.WeekNumberFromDate(date, currentUser.Settings.WeekFirstDay)}
+
+	memo, _ := ctx.Value(planItemsMemoKey{}).(*planItemsMemo)
+	if memo != nil {
+		memo.mu.Lock()
+		defer memo.mu.Unlock()
+		if items, ok := memo.items[key]; ok {
+			return items, nil
+		}
+	}
+
+	now := c.clock.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok || !now.Before(entry.expiresAt) {
+		items, err := c.provider(ctx, date)
+		if err != nil {
+			return nil, err
+		}
+		entry = planItemsCacheEntry{items: items, expiresAt: now.Add(planItemsCacheTTL)}
+		c.mu.Lock()
+		c.makeRoom(now)
+		c.entries[key] = entry
+		c.mu.Unlock()
+	}
+	if memo != nil {
+		memo.items[key] = entry.items
+	}
+	return entry.items, nil
+}
+
+// makeRoom drops the expired entries once the cache is full, and the oldest entry when none has expired yet.
+// The caller holds the lock.
+func (c *planItemsCache) makeRoom(now time.Time) {
+	if len(c.entries) < planItemsCacheMaxEntries {
+		return
+	}
+	var oldestKey planItemsCacheKey
+	var oldest time.Time
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldest.IsZero() || entry.expiresAt.Before(oldest) {
+			oldestKey, oldest = key, entry.expiresAt
+		}
+	}
+	if len(c.entries) >= planItemsCacheMaxEntries {
+		delete(c.entries, oldestKey)
+	}
+}
+
+// invalidate drops the cached items of the user, or of all users when the user is not known.
+func (c *planItemsCache) invalidate(ctx context.Context) {
+	userId, err := user.CurrentId(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if err != nil || key.userId == userId {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// subscribe invalidates the cache on changes of the weekly and budget plans.
+func (c *planItemsCache) subscribe(eventBus *event_bus.EventBus) {
+	if eventBus == nil {
+		return
+	}
+	eventTypes := []event_bus.EventType{
+		"weekly_plan.week.changed",
+		"weekly_plan.week.generated",
+		"budget_plan.plan.updated",
+		"budget_plan.plan.deleted",
+		"budget_plan.item.created",
+		"budget_plan.item.updated",
+		"budget_plan.item.deleted",
+	}
+	for _, eventType := range eventTypes {
+		eventBus.Subscribe(eventType, func(e event_bus.Event) error {
+			log.Tracef("invalidating plan items cache on %s", e.Type)
+			c.invalidate(e.Context())
+			return nil
+		})
+	}
+}
@@ -2,115 +2,191 @@ package calendar
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/klokku/klokku/internal/database/dbtx"
 	log "github.com/sirupsen/logrus"
 )
 
+var ErrEventNotFound = errors.New("event not found")
+
 type Repository interface {
-	WithTransaction(ctx context.Context, fn func(repo Repository) error) error
+	// WithTransaction runs fn in a transaction, committed when fn returns no error. The context passed to fn carries
+	// the transaction, so events published with it are stored together with the changes and handled once it commits.
+	WithTransaction(ctx context.Context, fn func(ctx context.Context, repo Repository) error) error
 	StoreEvent(ctx context.Context, userId int, event Event) (Event, error)
+	// GetEvent returns the event with the given uid, ErrEventNotFound when the user has no such event.
+	GetEvent(ctx context.Context, userId int, eventUid string) (Event, error)
 	GetEvents(ctx context.Context, userId int, from, to time.Time) ([]Event, error)
 	GetLastEvents(ctx context.Context, userId int, limit int) ([]Event, error)
+	GetEventsByBudgetItemId(ctx context.Context, userId int, budgetItemId int) ([]Event, error)
+	// GetEventParts returns the parts of the logical event with the given UID (see Event.LogicalUID), by start time.
+	GetEventParts(ctx context.Context, userId int, logicalUid string) ([]Event, error)
 	UpdateEvent(ctx context.Context, userId int, event Event) (Event, error)
 	DeleteEvent(ctx context.Context, userId int, eventId string) error
+	// DeleteSandboxEvents removes all sandbox events of the user and returns the number of removed events.
+	DeleteSandboxEvents(ctx context.Context, userId int) (int, error)
 	GetEarliestEventTimeForBudgetItems(ctx context.Context, userId int, budgetItemIds []int) (time.Time, bool, error)
+	StoreLineageLink(ctx context.Context, userId int, link LineageLink) error
+	// GetLineageLinks returns links where the given event is either the source or the derived one.
+	GetLineageLinks(ctx context.Context, userId int, eventUid string) ([]LineageLink, error)
+	// GetEventChanges returns the history entries recorded after the after time up to and including the until time,
+	// the oldest first. Entries are recorded by StoreEvent, UpdateEvent and DeleteEvent.
+	GetEventChanges(ctx context.Context, userId int, after time.Time, until time.Time) ([]EventChange, error)
+	// DeleteEventChangesBefore deletes the history entries of all users recorded before the given time and returns
+	// the number of deleted entries.
+	DeleteEventChangesBefore(ctx context.Context, before time.Time) (int, error)
+	// ArchiveEventsEndedBefore moves non-sandbox events of all users that ended before the given time
+	// to the archive and returns the number of moved events.
+	ArchiveEventsEndedBefore(ctx context.Context, before time.Time) (int, error)
+	// GetArchivedEvents is GetEvents for archived events.
+	GetArchivedEvents(ctx context.Context, userId int, from, to time.Time) ([]Event, error)
+	// UnlinkArchivedEvents clears the budget item of the user's archived events of a deleted budget item.
+	UnlinkArchivedEvents(ctx context.Context, userId int, budgetItemId int) (int, error)
 }
 type repositoryImpl struct {
 	db *pgxpool.Pool
-	tx pgx.Tx
 }
 
 func NewRepository(db *pgxpool.Pool) Repository {
-	return &repositoryImpl{db: db, tx: nil}
+	return &repositoryImpl{db: db}
 }
 
-// getQueryer returns the appropriate database interface for queries (either tx or db)
-func (r *repositoryImpl) getQueryer() interface {
-	Exec(ctx context.Context, query string, args ...interface{}) (pgconn.CommandTag, error)
-	Query(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error)
-	QueryRow(ctx context.Context, query string, args ...interface{}) pgx.Row
-} {
-	if r.tx != nil {
-		return r.tx
-	}
-	return r.db
-}
+const eventColumns = `uid, parent_uid, summary, start_time, end_time, budget_item_id, notes, task_id, sandbox, attributes,
+	location`
 
-func (r *repositoryImpl) WithTransaction(ctx context.Context, fn func(repo Repository) error) error {
-	tx, err := r.db.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
+// scanEvent scans a row selected with eventColumns
+func scanEvent(row pgx.Row) (Event, error) {
+	var event Event
+	var budgetItemId sql.NullInt32
+	err := row.Scan(
+		&event.UID,
+		&event.ParentUID,
+		&event.Summary,
+		&event.StartTime,
+		&event.EndTime,
+		&budgetItemId,
+		&event.Metadata.Notes,
+		&event.Metadata.TaskId,
+		&event.Metadata.Sandbox,
+		&event.Metadata.Attributes,
+		&event.Metadata.Location,
+	)
+	event.Metadata.BudgetItemId = int(budgetItemId.Int32)
+	if len(event.Metadata.Attributes) == 0 {
+		event.Metadata.Attributes = nil
 	}
-	defer func() {
-		// The Rollback will be a no-op if the transaction was already committed
-		if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
-			// Just log rollback errors
-			log.Errorf("rollback error: %v", rbErr)
-		}
-	}()
+	return event, err
+}
 
-	// Create a repository that uses the transaction
-	txRepo := &repositoryImpl{db: r.db, tx: tx}
+// budgetItemIdParam stores events unlinked from a deleted budget item with a NULL budget item
+func budgetItemIdParam(budgetItemId int) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(budgetItemId), Valid: budgetItemId != 0}
+}
 
-	if err := fn(txRepo); err != nil {
-		return err
+// attributesOrEmpty returns the attributes to store, an empty map instead of nil as the column is not nullable
+func attributesOrEmpty(attributes map[string]string) map[string]string {
+	if attributes == nil {
+		return map[string]string{}
 	}
+	return attributes
+}
 
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("commit transaction: %w", err)
-	}
+// conn returns the transaction of ctx, if any, or the pool
+func (r *repositoryImpl) conn(ctx context.Context) dbtx.Querier {
+	return dbtx.From(ctx, r.db)
+}
 
-	return nil
+func (r *repositoryImpl) WithTransaction(ctx context.Context, fn func(ctx context.Context, repo Repository) error) error {
+	return dbtx.InTx(ctx, r.db, func(ctx context.Context) error {
+		return fn(ctx, r)
+	})
 }
 
 func (r *repositoryImpl) StoreEvent(ctx context.Context, userId int, event Event) (Event, error) {
 	query := `INSERT INTO calendar_event (
                             uid,
+                            parent_uid,
                             summary,
                             start_time,
                             end_time,
                             budget_item_id,
+                            notes,
+                            task_id,
+                            sandbox,
+                            attributes,
+                            location,
                             user_id
-						) VALUES ($1, $2, $3, $4, $5, $6) RETURNING uid, summary, start_time, end_time, budget_item_id`
+						) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING ` + eventColumns
 
 	uid := uuid.NewString()
-	var createdEvent Event
-	err := r.getQueryer().QueryRow(ctx, query,
+	createdEvent, err := scanEvent(r.conn(ctx).QueryRow(ctx, query,
 		uid,
+		event.ParentUID,
 		event.Summary,
 		event.StartTime,
 		event.EndTime,
-		event.Metadata.BudgetItemId,
+		budgetItemIdParam(event.Metadata.BudgetItemId),
+		event.Metadata.Notes,
+		event.Metadata.TaskId,
+		event.Metadata.Sandbox,
+		attributesOrEmpty(event.Metadata.Attributes),
+		event.Metadata.Location,
 		userId,
-	).Scan(&createdEvent.UID, &createdEvent.Summary, &createdEvent.StartTime, &createdEvent.EndTime, &createdEvent.Metadata.BudgetItemId)
+	))
 	if err != nil {
 		err := fmt.Errorf("could not execute query: %v", err)
 		log.Error(err)
 		return Event{}, err
 	}
+	if err := r.recordChange(ctx, userId, EventCreated, nil, &createdEvent); err != nil {
+		return Event{}, err
+	}
 
 	return createdEvent, nil
 }
 
+func (r *repositoryImpl) GetEvent(ctx context.Context, userId int, eventUid string) (Event, error) {
+	query := `SELECT ` + eventColumns + ` FROM calendar_event WHERE uid = $1 AND user_id = $2`
+	event, err := scanEvent(r.conn(ctx).QueryRow(ctx, query, eventUid, userId))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Event{}, ErrEventNotFound
+		}
+		err := fmt.Errorf("could not execute query: %v", err)
+		log.Error(err)
+		return Event{}, err
+	}
+	return event, nil
+}
+
 func (r *repositoryImpl) GetEvents(ctx context.Context, userId int, from, to time.Time) ([]Event, error) {
+	return r.getEventsFrom(ctx, "calendar_event", userId, from, to)
+}
+
+func (r *repositoryImpl) GetArchivedEvents(ctx context.Context, userId int, from, to time.Time) ([]Event, error) {
+	return r.getEventsFrom(ctx, "calendar_event_archive", userId, from, to)
+}
+
+func (r *repositoryImpl) getEventsFrom(ctx context.Context, table string, userId int, from, to time.Time) ([]Event, error) {
 	// Return all events that overlap with the given period:
 	// 1. Events that start before the end of the period (start_time <= to)
-	// 2. AND end after the start of the period (end_time >= from)
-	query := `SELECT uid, summary, start_time, end_time, budget_item_id 
-              FROM calendar_event 
+	// 2. AND end after the start of the period (end_time > from), so parts of an event split at midnight
+	//    are not returned for the day after their end
+	query := `SELECT ` + eventColumns + `
+              FROM ` + table + ` 
               WHERE user_id = $1 
                 AND start_time <= $2 
-                AND end_time >= $3
+                AND end_time > $3
 			  ORDER BY start_time`
 
-	rows, err := r.getQueryer().Query(ctx, query, userId, to, from)
+	rows, err := r.conn(ctx).Query(ctx, query, userId, to, from)
 	if err != nil {
 		err := fmt.Errorf("could not query calendar events: %w", err)
 		log.Error(err)
@@ -120,8 +196,7 @@ func (r *repositoryImpl) GetEvents(ctx context.Context, userId int, from, to tim
 
 	events := make([]Event, 0, 10)
 	for rows.Next() {
-		var event Event
-		err := rows.Scan(&event.UID, &event.Summary, &event.StartTime, &event.EndTime, &event.Metadata.BudgetItemId)
+		event, err := scanEvent(rows)
 		if err != nil {
 			err := fmt.Errorf("could not scan row: %w", err)
 			log.Error(err)
@@ -134,14 +209,14 @@ func (r *repositoryImpl) GetEvents(ctx context.Context, userId int, from, to tim
 
 // GetLastEvents retrieves the most recent calendar events for a specific user, limited by the specified number of records.
 func (r *repositoryImpl) GetLastEvents(ctx context.Context, userId int, limit int) ([]Event, error) {
-	query := `SELECT uid, summary, start_time, end_time, budget_item_id
+	query := `SELECT ` + eventColumns + `
 				FROM calendar_event 
 				WHERE user_id = $1 AND
 				      end_time <= $2
 				ORDER BY end_time DESC
 				LIMIT $3`
 
-	rows, err := r.getQueryer().Query(ctx, query, userId, time.Now(), limit)
+	rows, err := r.conn(ctx).Query(ctx, query, userId, time.Now(), limit)
 	if err != nil {
 		err := fmt.Errorf("could not query calendar events: %w", err)
 		log.Error(err)
@@ -151,8 +226,34 @@ func (r *repositoryImpl) GetLastEvents(ctx context.Context, userId int, limit in
 
 	events := make([]Event, 0, limit)
 	for rows.Next() {
-		var event Event
-		err := rows.Scan(&event.UID, &event.Summary, &event.StartTime, &event.EndTime, &event.Metadata.BudgetItemId)
+		event, err := scanEvent(rows)
+		if err != nil {
+			err := fmt.Errorf("could not scan row: %w", err)
+			log.Error(err)
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (r *repositoryImpl) GetEventsByBudgetItemId(ctx context.Context, userId int, budgetItemId int) ([]Event, error) {
+	query := `SELECT ` + eventColumns + `
+				FROM calendar_event
+				WHERE user_id = $1 AND budget_item_id = $2
+				ORDER BY start_time`
+
+	rows, err := r.conn(ctx).Query(ctx, query, userId, budgetItemId)
+	if err != nil {
+		err := fmt.Errorf("could not query calendar events: %w", err)
+		log.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]Event, 0, 10)
+	for rows.Next() {
+		event, err := scanEvent(rows)
 		if err != nil {
 			err := fmt.Errorf("could not scan row: %w", err)
 			log.Error(err)
@@ -163,13 +264,44 @@ func (r *repositoryImpl) GetLastEvents(ctx context.Context, userId int, limit in
 	return events, nil
 }
 
+func (r *repositoryImpl) GetEventParts(ctx context.Context, userId int, logicalUid string) ([]Event, error) {
+	query := `SELECT ` + eventColumns + `
+				FROM calendar_event
+				WHERE user_id = $1 AND (uid = $2 OR parent_uid = $2)
+				ORDER BY start_time`
+
+	rows, err := r.conn(ctx).Query(ctx, query, userId, logicalUid)
+	if err != nil {
+		err := fmt.Errorf("could not query calendar events: %w", err)
+		log.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			err := fmt.Errorf("could not scan row: %w", err)
+			log.Error(err)
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
 func (r *repositoryImpl) GetEarliestEventTimeForBudgetItems(ctx context.Context, userId int, budgetItemIds []int) (time.Time, bool, error) {
 	if len(budgetItemIds) == 0 {
 		return time.Time{}, false, nil
 	}
-	query := `SELECT MIN(start_time) FROM calendar_event WHERE user_id = $1 AND budget_item_id = ANY($2)`
+	query := `SELECT MIN(start_time) FROM (
+				SELECT start_time FROM calendar_event WHERE user_id = $1 AND budget_item_id = ANY($2)
+				UNION ALL
+				SELECT start_time FROM calendar_event_archive WHERE user_id = $1 AND budget_item_id = ANY($2)
+			  ) AS all_events`
 	var earliest *time.Time
-	err := r.getQueryer().QueryRow(ctx, query, userId, budgetItemIds).Scan(&earliest)
+	err := r.conn(ctx).QueryRow(ctx, query, userId, budgetItemIds).Scan(&earliest)
 	if err != nil {
 		return time.Time{}, false, fmt.Errorf("could not query earliest event time: %w", err)
 	}
@@ -180,29 +312,47 @@ func (r *repositoryImpl) GetEarliestEventTimeForBudgetItems(ctx context.Context,
 }
 
 func (r *repositoryImpl) UpdateEvent(ctx context.Context, userId int, event Event) (Event, error) {
+	previous, err := r.GetEvent(ctx, userId, event.UID)
+	if err != nil {
+		return Event{}, err
+	}
 	query := `UPDATE calendar_event 
-				SET summary = $1, start_time = $2, end_time = $3, budget_item_id = $4 
-				WHERE uid = $5 AND user_id = $6
-				RETURNING uid, summary, start_time, end_time, budget_item_id`
-	var updatedEvent Event
-	err := r.getQueryer().QueryRow(ctx, query,
+				SET summary = $1, start_time = $2, end_time = $3, budget_item_id = $4, notes = $5, task_id = $6,
+				    attributes = $7, location = $8
+				WHERE uid = $9 AND user_id = $10
+				RETURNING ` + eventColumns
+	updatedEvent, err := scanEvent(r.conn(ctx).QueryRow(ctx, query,
 		event.Summary,
 		event.StartTime,
 		event.EndTime,
-		event.Metadata.BudgetItemId,
+		budgetItemIdParam(event.Metadata.BudgetItemId),
+		event.Metadata.Notes,
+		event.Metadata.TaskId,
+		attributesOrEmpty(event.Metadata.Attributes),
+		event.Metadata.Location,
 		event.UID,
-		userId).Scan(&updatedEvent.UID, &updatedEvent.Summary, &updatedEvent.StartTime, &updatedEvent.EndTime, &updatedEvent.Metadata.BudgetItemId)
+		userId))
 	if err != nil {
 		err := fmt.Errorf("could not execute query: %v", err)
 		log.Error(err)
 		return Event{}, err
 	}
+	if err := r.recordChange(ctx, userId, EventUpdated, &previous, &updatedEvent); err != nil {
+		return Event{}, err
+	}
 	return updatedEvent, nil
 }
 
 func (r *repositoryImpl) DeleteEvent(ctx context.Context, userId int, eventUid string) error {
+	previous, err := r.GetEvent(ctx, userId, eventUid)
+	if err != nil {
+		if errors.Is(err, ErrEventNotFound) {
+			return fmt.Errorf("no event found with uid %s for user %d", eventUid, userId)
+		}
+		return err
+	}
 	query := `DELETE FROM calendar_event WHERE uid = $1 AND user_id = $2`
-	result, err := r.getQueryer().Exec(ctx, query, eventUid, userId)
+	result, err := r.conn(ctx).Exec(ctx, query, eventUid, userId)
 	if err != nil {
 		err := fmt.Errorf("could not execute query: %v", err)
 		log.Error(err)
@@ -212,5 +362,176 @@ func (r *repositoryImpl) DeleteEvent(ctx context.Context, userId int, eventUid s
 	if rowsAffected == 0 {
 		return fmt.Errorf("no event found with uid %s for user %d", eventUid, userId)
 	}
+	return r.recordChange(ctx, userId, EventDeleted, &previous, nil)
+}
+
+func (r *repositoryImpl) DeleteSandboxEvents(ctx context.Context, userId int) (int, error) {
+	query := `DELETE FROM calendar_event WHERE user_id = $1 AND sandbox = TRUE`
+	result, err := r.conn(ctx).Exec(ctx, query, userId)
+	if err != nil {
+		err := fmt.Errorf("could not execute query: %v", err)
+		log.Error(err)
+		return 0, err
+	}
+	return int(result.RowsAffected()), nil
+}
+
+func (r *repositoryImpl) UnlinkArchivedEvents(ctx context.Context, userId int, budgetItemId int) (int, error) {
+	query := `UPDATE calendar_event_archive SET budget_item_id = NULL WHERE user_id = $1 AND budget_item_id = $2`
+	result, err := r.conn(ctx).Exec(ctx, query, userId, budgetItemId)
+	if err != nil {
+		return 0, fmt.Errorf("could not unlink archived events: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+func (r *repositoryImpl) ArchiveEventsEndedBefore(ctx context.Context, before time.Time) (int, error) {
+	query := `WITH moved AS (
+				DELETE FROM calendar_event
+				WHERE end_time < $1 AND sandbox = FALSE
+				RETURNING user_id, ` + eventColumns + `
+			  )
+			  INSERT INTO calendar_event_archive (user_id, ` + eventColumns + `)
+			  SELECT user_id, ` + eventColumns + ` FROM moved`
+	result, err := r.conn(ctx).Exec(ctx, query, before)
+	if err != nil {
+		err := fmt.Errorf("could not archive events: %w", err)
+		log.Error(err)
+		return 0, err
+	}
+	return int(result.RowsAffected()), nil
+}
+
+func (r *repositoryImpl) StoreLineageLink(ctx context.Context, userId int, link LineageLink) error {
+	query := `INSERT INTO calendar_event_lineage (user_id, source_uid, derived_uid, operation, caused_by_uid,
+                                    previous_start_time, previous_end_time)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := r.conn(ctx).Exec(ctx, query,
+		userId,
+		link.SourceUID,
+		link.DerivedUID,
+		link.Operation,
+		link.CausedByUID,
+		link.PreviousStartTime,
+		link.PreviousEndTime,
+	)
+	if err != nil {
+		err := fmt.Errorf("could not store lineage link: %w", err)
+		log.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (r *repositoryImpl) GetLineageLinks(ctx context.Context, userId int, eventUid string) ([]LineageLink, error) {
+	query := `SELECT source_uid, derived_uid, operation, caused_by_uid, previous_start_time, previous_end_time, created_at
+			  FROM calendar_event_lineage
+			  WHERE user_id = $1 AND (source_uid = $2 OR derived_uid = $2)
+			  ORDER BY created_at, id`
+	rows, err := r.conn(ctx).Query(ctx, query, userId, eventUid)
+	if err != nil {
+		err := fmt.Errorf("could not query lineage links: %w", err)
+		log.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := make([]LineageLink, 0)
+	for rows.Next() {
+		var link LineageLink
+		err := rows.Scan(&link.SourceUID, &link.DerivedUID, &link.Operation, &link.CausedByUID,
+			&link.PreviousStartTime, &link.PreviousEndTime, &link.CreatedAt)
+		if err != nil {
+			err := fmt.Errorf("could not scan lineage link: %w", err)
+			log.Error(err)
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// eventSnapshot is the JSON form of an event stored in the calendar history.
+type eventSnapshot struct {
+	UID       string        `json:"uid"`
+	ParentUID string        `json:"parentUid,omitempty"`
+	Summary   string        `json:"summary"`
+	StartTime time.Time     `json:"start"`
+	EndTime   time.Time     `json:"end"`
+	Metadata  EventMetadata `json:"metadata"`
+}
+
+func snapshotOf(event *Event) *eventSnapshot {
+	if event == nil {
+		return nil
+	}
+	return &eventSnapshot{event.UID, event.ParentUID, event.Summary, event.StartTime, event.EndTime, event.Metadata}
+}
+
+func (e *eventSnapshot) event() *Event {
+	if e == nil {
+		return nil
+	}
+	return &Event{UID: e.UID, ParentUID: e.ParentUID, Summary: e.Summary, StartTime: e.StartTime, EndTime: e.EndTime,
+		Metadata: e.Metadata}
+}
+
+// recordChange stores the change in the calendar history. Sandbox events are not recorded.
+func (r *repositoryImpl) recordChange(ctx context.Context, userId int, changeType EventChangeType, previous *Event, current *Event) error {
+	event := current
+	if event == nil {
+		event = previous
+	}
+	if event.Metadata.Sandbox {
+		return nil
+	}
+	query := `INSERT INTO calendar_event_history (user_id, event_uid, change_type, previous, current)
+			  VALUES ($1, $2, $3, $4, $5)`
+	_, err := r.conn(ctx).Exec(ctx, query, userId, event.UID, changeType, snapshotOf(previous), snapshotOf(current))
+	if err != nil {
+		err := fmt.Errorf("could not record event change: %w", err)
+		log.Error(err)
+		return err
+	}
 	return nil
 }
+
+func (r *repositoryImpl) DeleteEventChangesBefore(ctx context.Context, before time.Time) (int, error) {
+	query := `DELETE FROM calendar_event_history WHERE changed_at < $1`
+	result, err := r.conn(ctx).Exec(ctx, query, before)
+	if err != nil {
+		err := fmt.Errorf("could not delete event changes: %w", err)
+		log.Error(err)
+		return 0, err
+	}
+	return int(result.RowsAffected()), nil
+}
+
+func (r *repositoryImpl) GetEventChanges(ctx context.Context, userId int, after time.Time, until time.Time) ([]EventChange, error) {
+	query := `SELECT event_uid, change_type, previous, current, changed_at
+			  FROM calendar_event_history
+			  WHERE user_id = $1 AND changed_at > $2 AND changed_at <= $3
+			  ORDER BY changed_at, id`
+	rows, err := r.conn(ctx).Query(ctx, query, userId, after, until)
+	if err != nil {
+		err := fmt.Errorf("could not query event changes: %w", err)
+		log.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]EventChange, 0)
+	for rows.Next() {
+		var change EventChange
+		var previous, current *eventSnapshot
+		if err := rows.Scan(&change.EventUID, &change.Type, &previous, &current, &change.ChangedAt); err != nil {
+			err := fmt.Errorf("could not scan event change: %w", err)
+			log.Error(err)
+			return nil, err
+		}
+		change.Previous = previous.event()
+		change.Current = current.event()
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
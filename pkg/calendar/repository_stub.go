@@ -3,14 +3,18 @@ package calendar
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
 
 type RepositoryStub struct {
 	mu             sync.RWMutex
-	items          map[string]Event // uid -> item
-	userIds        map[string]int   // uid -> userId
+	items          map[string]Event      // uid -> item
+	userIds        map[string]int        // uid -> userId
+	lineage        map[int][]LineageLink // userId -> links
+	archived       map[int][]Event       // userId -> archived events
+	history        map[int][]EventChange // userId -> changes
 	nextId         int
 	inTransaction  bool
 	transactionErr error
@@ -18,13 +22,16 @@ type RepositoryStub struct {
 
 func NewRepositoryStub() *RepositoryStub {
 	return &RepositoryStub{
-		items:   make(map[string]Event),
-		userIds: make(map[string]int),
-		nextId:  1,
+		items:    make(map[string]Event),
+		userIds:  make(map[string]int),
+		lineage:  make(map[int][]LineageLink),
+		archived: make(map[int][]Event),
+		history:  make(map[int][]EventChange),
+		nextId:   1,
 	}
 }
 
-func (r *RepositoryStub) WithTransaction(ctx context.Context, fn func(repo Repository) error) error {
+func (r *RepositoryStub) WithTransaction(ctx context.Context, fn func(ctx context.Context, repo Repository) error) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -37,6 +44,14 @@ func (r *RepositoryStub) WithTransaction(ctx context.Context, fn func(repo Repos
 	for k, v := range r.userIds {
 		originalUserIds[k] = v
 	}
+	originalLineage := make(map[int][]LineageLink, len(r.lineage))
+	for k, v := range r.lineage {
+		originalLineage[k] = append([]LineageLink(nil), v...)
+	}
+	originalHistory := make(map[int][]EventChange, len(r.history))
+	for k, v := range r.history {
+		originalHistory[k] = append([]EventChange(nil), v...)
+	}
 	originalNextId := r.nextId
 
 	// Mark as in transaction
@@ -45,7 +60,7 @@ func (r *RepositoryStub) WithTransaction(ctx context.Context, fn func(repo Repos
 	r.mu.Unlock()
 
 	// Execute the function
-	err := fn(r)
+	err := fn(ctx, r)
 
 	r.mu.Lock()
 	r.inTransaction = false
@@ -54,6 +69,8 @@ func (r *RepositoryStub) WithTransaction(ctx context.Context, fn func(repo Repos
 	if err != nil || r.transactionErr != nil {
 		r.items = originalItems
 		r.userIds = originalUserIds
+		r.lineage = originalLineage
+		r.history = originalHistory
 		r.nextId = originalNextId
 		if err != nil {
 			return err
@@ -75,10 +92,22 @@ func (r *RepositoryStub) StoreEvent(ctx context.Context, userId int, event Event
 	r.items[event.UID] = event
 	r.userIds[event.UID] = userId
 	r.nextId++
+	r.recordChange(userId, EventCreated, nil, &event)
 
 	return event, nil
 }
 
+func (r *RepositoryStub) GetEvent(ctx context.Context, userId int, eventUid string) (Event, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	event, exists := r.items[eventUid]
+	if !exists || r.userIds[eventUid] != userId {
+		return Event{}, ErrEventNotFound
+	}
+	return event, nil
+}
+
 func (r *RepositoryStub) GetEvents(ctx context.Context, userId int, from, to time.Time) ([]Event, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -131,6 +160,38 @@ func (r *RepositoryStub) GetLastEvents(ctx context.Context, userId int, limit in
 	return result, nil
 }
 
+func (r *RepositoryStub) GetEventsByBudgetItemId(ctx context.Context, userId int, budgetItemId int) ([]Event, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []Event
+	for uid, event := range r.items {
+		if r.userIds[uid] == userId && event.Metadata.BudgetItemId == budgetItemId {
+			result = append(result, event)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartTime.Before(result[j].StartTime)
+	})
+	return result, nil
+}
+
+func (r *RepositoryStub) GetEventParts(ctx context.Context, userId int, logicalUid string) ([]Event, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []Event
+	for uid, event := range r.items {
+		if r.userIds[uid] == userId && event.LogicalUID() == logicalUid {
+			result = append(result, event)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartTime.Before(result[j].StartTime)
+	})
+	return result, nil
+}
+
 func (r *RepositoryStub) GetEarliestEventTimeForBudgetItems(ctx context.Context, userId int, budgetItemIds []int) (time.Time, bool, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -142,14 +203,20 @@ func (r *RepositoryStub) GetEarliestEventTimeForBudgetItems(ctx context.Context,
 
 	var earliest time.Time
 	found := false
+	consider := func(event Event) {
+		if idSet[event.Metadata.BudgetItemId] && (!found || event.StartTime.Before(earliest)) {
+			earliest = event.StartTime
+			found = true
+		}
+	}
 	for uid, event := range r.items {
-		if r.userIds[uid] == userId && idSet[event.Metadata.BudgetItemId] {
-			if !found || event.StartTime.Before(earliest) {
-				earliest = event.StartTime
-				found = true
-			}
+		if r.userIds[uid] == userId {
+			consider(event)
 		}
 	}
+	for _, event := range r.archived[userId] {
+		consider(event)
+	}
 	return earliest, found, nil
 }
 
@@ -157,12 +224,16 @@ func (r *RepositoryStub) UpdateEvent(ctx context.Context, userId int, event Even
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	_, exists := r.items[event.UID]
+	previous, exists := r.items[event.UID]
 	if !exists || r.userIds[event.UID] != userId {
 		return Event{}, fmt.Errorf("event not found")
 	}
 
+	// Like the database, the parent and the sandbox flag are kept from the stored event
+	event.ParentUID = previous.ParentUID
+	event.Metadata.Sandbox = previous.Metadata.Sandbox
 	r.items[event.UID] = event
+	r.recordChange(userId, EventUpdated, &previous, &event)
 
 	return event, nil
 }
@@ -171,17 +242,152 @@ func (r *RepositoryStub) DeleteEvent(ctx context.Context, userId int, eventId st
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	_, exists := r.items[eventId]
+	previous, exists := r.items[eventId]
 	if !exists || r.userIds[eventId] != userId {
 		return fmt.Errorf("no event found with uid %s for user %d", eventId, userId)
 	}
 
 	delete(r.items, eventId)
 	delete(r.userIds, eventId)
+	r.recordChange(userId, EventDeleted, &previous, nil)
 
 	return nil
 }
 
+func (r *RepositoryStub) DeleteSandboxEvents(ctx context.Context, userId int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+	for uid, event := range r.items {
+		if r.userIds[uid] == userId && event.Metadata.Sandbox {
+			delete(r.items, uid)
+			delete(r.userIds, uid)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *RepositoryStub) StoreLineageLink(ctx context.Context, userId int, link LineageLink) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	link.CreatedAt = time.Now()
+	r.lineage[userId] = append(r.lineage[userId], link)
+	return nil
+}
+
+func (r *RepositoryStub) GetLineageLinks(ctx context.Context, userId int, eventUid string) ([]LineageLink, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]LineageLink, 0)
+	for _, link := range r.lineage[userId] {
+		if link.SourceUID == eventUid || link.DerivedUID == eventUid {
+			result = append(result, link)
+		}
+	}
+	return result, nil
+}
+
+func (r *RepositoryStub) ArchiveEventsEndedBefore(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	archived := 0
+	for uid, event := range r.items {
+		if event.EndTime.Before(before) && !event.Metadata.Sandbox {
+			userId := r.userIds[uid]
+			r.archived[userId] = append(r.archived[userId], event)
+			delete(r.items, uid)
+			delete(r.userIds, uid)
+			archived++
+		}
+	}
+	return archived, nil
+}
+
+func (r *RepositoryStub) UnlinkArchivedEvents(ctx context.Context, userId int, budgetItemId int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	unlinked := 0
+	for i, event := range r.archived[userId] {
+		if event.Metadata.BudgetItemId == budgetItemId {
+			r.archived[userId][i].Metadata.BudgetItemId = 0
+			unlinked++
+		}
+	}
+	return unlinked, nil
+}
+
+func (r *RepositoryStub) GetArchivedEvents(ctx context.Context, userId int, from, to time.Time) ([]Event, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var result []Event
+	for _, event := range r.archived[userId] {
+		if !event.StartTime.After(to) && !event.EndTime.Before(from) {
+			result = append(result, event)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartTime.Before(result[j].StartTime)
+	})
+	return result, nil
+}
+
+// recordChange mirrors the history recorded by the database repository, the caller holds the lock.
+func (r *RepositoryStub) recordChange(userId int, changeType EventChangeType, previous *Event, current *Event) {
+	event := current
+	if event == nil {
+		event = previous
+	}
+	if event.Metadata.Sandbox {
+		return
+	}
+	r.history[userId] = append(r.history[userId], EventChange{
+		EventUID:  event.UID,
+		Type:      changeType,
+		Previous:  previous,
+		Current:   current,
+		ChangedAt: time.Now(),
+	})
+}
+
+func (r *RepositoryStub) GetEventChanges(ctx context.Context, userId int, after time.Time, until time.Time) ([]EventChange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]EventChange, 0)
+	for _, change := range r.history[userId] {
+		if change.ChangedAt.After(after) && !change.ChangedAt.After(until) {
+			result = append(result, change)
+		}
+	}
+	return result, nil
+}
+
+func (r *RepositoryStub) DeleteEventChangesBefore(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+	for userId, changes := range r.history {
+		kept := changes[:0]
+		for _, change := range changes {
+			if change.ChangedAt.Before(before) {
+				deleted++
+				continue
+			}
+			kept = append(kept, change)
+		}
+		r.history[userId] = kept
+	}
+	return deleted, nil
+}
+
 // Helper method to set transaction error (for testing transaction rollback)
 func (r *RepositoryStub) SetTransactionError(err error) {
 	r.mu.Lock()
@@ -208,6 +414,9 @@ func (r *RepositoryStub) Reset() {
 
 	r.items = make(map[string]Event)
 	r.userIds = make(map[string]int)
+	r.lineage = make(map[int][]LineageLink)
+	r.archived = make(map[int][]Event)
+	r.history = make(map[int][]EventChange)
 	r.nextId = 1
 	r.inTransaction = false
 	r.transactionErr = nil
@@ -156,7 +156,7 @@ func TestRepositoryImpl_GetEvents(t *testing.T) {
 			eventEnd:      0,
 			queryStart:    0,
 			queryEnd:      time.Hour,
-			shouldBeFound: true, // Should be found if inclusive of boundary
+			shouldBeFound: false, // Events end exclusively, like the parts of an event split at midnight
 		},
 		{
 			name:          "Event starts exactly at query end (edge case)",
@@ -425,6 +425,57 @@ func TestRepositoryImpl_UpdateEvent(t *testing.T) {
 	assertEventEqual(t, updatedEvent, updatedEvents[0], false)
 }
 
+func TestRepositoryImpl_EventAttributes(t *testing.T) {
+	// Setup
+	ctx, repository, userId := setupTestRepository(t)
+
+	// Given
+	baseTime := time.Now().Truncate(time.Millisecond)
+	event := createTestEvent("Test Event", baseTime, baseTime.Add(time.Hour), 123)
+	event.Metadata.Attributes = map[string]string{"jiraIssue": "KLO-42"}
+	stored, err := repository.StoreEvent(ctx, userId, event)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"jiraIssue": "KLO-42"}, stored.Metadata.Attributes)
+
+	// When
+	stored.Metadata.Attributes = nil
+	updated, err := repository.UpdateEvent(ctx, userId, stored)
+
+	// Then
+	require.NoError(t, err)
+	assert.Nil(t, updated.Metadata.Attributes)
+	fetched, err := repository.GetEvent(ctx, userId, stored.UID)
+	require.NoError(t, err)
+	assert.Nil(t, fetched.Metadata.Attributes)
+}
+
+func TestRepositoryImpl_GetEventParts(t *testing.T) {
+	// Setup
+	ctx, repository, userId := setupTestRepository(t)
+
+	// Given
+	baseTime := time.Now().Truncate(time.Millisecond)
+	first, err := repository.StoreEvent(ctx, userId, createTestEvent("Night", baseTime, baseTime.Add(time.Hour), 123))
+	require.NoError(t, err)
+	second := createTestEvent("Night", baseTime.Add(time.Hour), baseTime.Add(2*time.Hour), 123)
+	second.ParentUID = first.UID
+	second, err = repository.StoreEvent(ctx, userId, second)
+	require.NoError(t, err)
+	_, err = repository.StoreEvent(ctx, userId, createTestEvent("Other", baseTime, baseTime.Add(time.Hour), 123))
+	require.NoError(t, err)
+
+	// When
+	parts, err := repository.GetEventParts(ctx, userId, second.LogicalUID())
+
+	// Then
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+	assert.Equal(t, first.UID, parts[0].UID)
+	assert.Empty(t, parts[0].ParentUID)
+	assert.Equal(t, second.UID, parts[1].UID)
+	assert.Equal(t, first.UID, parts[1].ParentUID)
+}
+
 func TestRepositoryImpl_DeleteEvent(t *testing.T) {
 	// Setup
 	ctx, repository, userId := setupTestRepository(t)
@@ -466,3 +517,49 @@ func TestRepositoryImpl_DeleteEvent(t *testing.T) {
 	assert.Len(t, finalEvents, 1)
 	assert.Equal(t, allEvents[0].UID, finalEvents[0].UID)
 }
+
+func TestRepositoryImpl_ArchiveEventsEndedBefore(t *testing.T) {
+	ctx, repository, userId := setupTestRepository(t)
+	otherUserId := 2
+
+	baseTime := time.Now().Add(-90 * 24 * time.Hour).Truncate(time.Millisecond)
+	old, err := repository.StoreEvent(ctx, userId, createTestEvent("Old", baseTime, baseTime.Add(time.Hour), 123))
+	require.NoError(t, err)
+	otherOld, err := repository.StoreEvent(ctx, otherUserId, createTestEvent("Other old", baseTime, baseTime.Add(time.Hour), 456))
+	require.NoError(t, err)
+	sandboxEvent := createTestEvent("Sandbox", baseTime.Add(2*time.Hour), baseTime.Add(3*time.Hour), 123)
+	sandboxEvent.Metadata.Sandbox = true
+	_, err = repository.StoreEvent(ctx, userId, sandboxEvent)
+	require.NoError(t, err)
+	recentTime := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	recent, err := repository.StoreEvent(ctx, userId, createTestEvent("Recent", recentTime, recentTime.Add(30*time.Minute), 123))
+	require.NoError(t, err)
+
+	// When
+	archived, err := repository.ArchiveEventsEndedBefore(ctx, time.Now().Add(-30*24*time.Hour))
+
+	// Then
+	require.NoError(t, err)
+	assert.Equal(t, 2, archived)
+
+	hot, err := repository.GetEvents(ctx, userId, baseTime, time.Now())
+	require.NoError(t, err)
+	require.Len(t, hot, 2)
+	assert.Equal(t, "Sandbox", hot[0].Summary)
+	assert.Equal(t, recent.UID, hot[1].UID)
+
+	archivedEvents, err := repository.GetArchivedEvents(ctx, userId, baseTime, time.Now())
+	require.NoError(t, err)
+	require.Len(t, archivedEvents, 1)
+	assertEventEqual(t, old, archivedEvents[0], false)
+
+	otherArchived, err := repository.GetArchivedEvents(ctx, otherUserId, baseTime, time.Now())
+	require.NoError(t, err)
+	require.Len(t, otherArchived, 1)
+	assert.Equal(t, otherOld.UID, otherArchived[0].UID)
+
+	earliest, found, err := repository.GetEarliestEventTimeForBudgetItems(ctx, userId, []int{123})
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.True(t, baseTime.Equal(earliest))
+}
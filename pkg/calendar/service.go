@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
 	"github.com/klokku/klokku/pkg/user"
 	"github.com/klokku/klokku/pkg/weekly_plan"
 	log "github.com/sirupsen/logrus"
@@ -14,52 +16,111 @@ import (
 
 var errPlanItemNotFound = errors.New("plan item not found")
 
+// ErrEventOverlap is returned when a user with strict calendar mode enabled writes an event
+// that would overlap already stored events.
+var ErrEventOverlap = errors.New("event overlaps existing events")
+
+// ErrInvalidVersionRange is returned when the from version of a diff is after its to version.
+var ErrInvalidVersionRange = errors.New("fromVersion must not be after toVersion")
+
 type PlanItemsProviderFunc func(ctx context.Context, date time.Time) ([]weekly_plan.WeeklyPlanItem, error)
 
+// WeekLockCheckerFunc reports whether the week containing the date is locked by the user.
+type WeekLockCheckerFunc func(ctx context.Context, date time.Time) (bool, error)
+
+// CheckEvent returns weekly_plan.ErrWeekLocked when the event falls into a locked week. A nil checker locks nothing.
+func (f WeekLockCheckerFunc) CheckEvent(ctx context.Context, event Event) error {
+	if f == nil {
+		return nil
+	}
+	for _, date := range []time.Time{event.StartTime, event.EndTime.Add(-time.Nanosecond)} {
+		locked, err := f(ctx, date)
+		if err != nil {
+			return fmt.Errorf("failed to check week lock: %w", err)
+		}
+		if locked {
+			return fmt.Errorf("%w: event %s on %s", weekly_plan.ErrWeekLocked, event.UID, date.Format(time.DateOnly))
+		}
+	}
+	return nil
+}
+
 type Service struct {
-	repo              Repository
-	eventBus          *event_bus.EventBus
-	planItemsProvider PlanItemsProviderFunc
+	repo            Repository
+	eventBus        *event_bus.EventBus
+	planItems       *planItemsCache
+	weekLockChecker WeekLockCheckerFunc
 }
 
-func NewService(repo Repository, eventBus *event_bus.EventBus, planItemsProvider PlanItemsProviderFunc) *Service {
+// NewService creates the calendar service. A nil weekLockChecker disables week locks.
+func NewService(repo Repository, eventBus *event_bus.EventBus, planItemsProvider PlanItemsProviderFunc, weekLockChecker WeekLockCheckerFunc) *Service {
 	return &Service{
-		repo:              repo,
-		eventBus:          eventBus,
-		planItemsProvider: planItemsProvider,
+		repo:            repo,
+		eventBus:        eventBus,
+		planItems:       newPlanItemsCache(planItemsProvider),
+		weekLockChecker: weekLockChecker,
 	}
 }
 
+// withRepo returns a copy of the service using the repo, e.g. the repository of a transaction.
+func (s *Service) withRepo(repo Repository) *Service {
+	txService := *s
+	txService.repo = repo
+	return &txService
+}
+
+// SubscribeToPlanChanges drops the cached weekly plan items when the weekly or budget plan changes.
+func (s *Service) SubscribeToPlanChanges() {
+	s.planItems.subscribe(s.eventBus)
+}
+
 func (s *Service) AddEvent(ctx context.Context, event Event) ([]Event, error) {
-	err := validateEvent(event)
+	if err := validateEvent(event); err != nil {
+		return nil, err
+	}
+	return s.addEvent(ctx, event)
+}
+
+// addEvent stores the event without requiring a budget item, so the parts of an event of a deleted budget item
+// can be stored when it is split around a sticky event.
+func (s *Service) addEvent(ctx context.Context, event Event) ([]Event, error) {
+	ctx = withPlanItemsMemo(ctx)
+	err := validateEventTimes(event)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.checkNotLocked(ctx, event); err != nil {
+		return nil, err
+	}
 	userId, err := user.CurrentId(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get current user: %w", err)
 	}
 
 	var storedEvents []Event
-	err = s.repo.WithTransaction(ctx, func(repo Repository) error {
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
 		currentUser, err := user.CurrentUser(ctx)
 		if err != nil {
 			return fmt.Errorf("failed to get current user: %w", err)
 		}
-		events, err := splitEventIfNeeded(&event, currentUser.Settings.Timezone)
+		if currentUser.Settings.StrictCalendar {
+			if err := checkNoOverlaps(ctx, repo, userId, event); err != nil {
+				return err
+			}
+		}
+		events, err := splitEventIfNeeded(&event, currentUser.Settings)
 		if err != nil {
 			return err
 		}
-		for _, e := range events {
-			planItemName, err := s.getEventName(ctx, e.StartTime, e.Metadata.BudgetItemId)
+		for i, e := range events {
+			if i > 0 {
+				e.ParentUID = storedEvents[0].LogicalUID()
+			}
+			summary, err := s.getEventSummary(ctx, currentUser.Settings, e)
 			if err != nil {
-				if errors.Is(err, errPlanItemNotFound) {
-					planItemName = event.Summary
-				} else {
-					return err
-				}
+				return err
 			}
-			e.Summary = planItemName
+			e.Summary = summary
 
 			storedEvent, err := repo.StoreEvent(ctx, userId, e)
 			if err != nil {
@@ -67,60 +128,104 @@ func (s *Service) AddEvent(ctx context.Context, event Event) ([]Event, error) {
 			}
 			storedEvents = append(storedEvents, storedEvent)
 		}
+		for _, e := range storedEvents {
+			if err := s.publishCreated(ctx, e); err != nil {
+				return err
+			}
+		}
 		return nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to perform transaction: %w", err)
 	}
 
-	for _, e := range storedEvents {
-		err = s.eventBus.Publish(event_bus.NewEvent(ctx, "calendar.event.created", event_bus.CalendarEventCreated{
-			UID:          e.UID,
-			Summary:      e.Summary,
-			StartTime:    e.StartTime,
-			EndTime:      e.EndTime,
-			BudgetItemId: e.Metadata.BudgetItemId,
-		}))
-		if err != nil {
-			return nil, fmt.Errorf("failed to publish event creation: %w", err)
+	return storedEvents, nil
+}
+
+// AddEvents is AddEvent for many events, e.g. of an import, in a single transaction. An event the calendar refuses,
+// e.g. in a locked week, doesn't stop the others, its error is at its index of the returned errors. The error is
+// returned when the transaction fails, then none of the events is added.
+func (s *Service) AddEvents(ctx context.Context, events []Event) ([]error, error) {
+	ctx = withPlanItemsMemo(ctx)
+	eventErrs := make([]error, len(events))
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
+		s := s.withRepo(repo)
+		for i, e := range events {
+			// Each event is added in a nested transaction, so a refused one is rolled back alone
+			_, eventErrs[i] = s.AddEvent(ctx, e)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform transaction: %w", err)
 	}
+	return eventErrs, nil
+}
 
-	return storedEvents, nil
+func (s *Service) publishCreated(ctx context.Context, e Event) error {
+	err := s.eventBus.Publish(event_bus.NewEvent(ctx, "calendar.event.created", event_bus.CalendarEventCreated{
+		UID:          e.UID,
+		Summary:      e.Summary,
+		StartTime:    e.StartTime,
+		EndTime:      e.EndTime,
+		BudgetItemId: e.Metadata.BudgetItemId,
+		TaskId:       e.Metadata.TaskId,
+		Sandbox:      e.Metadata.Sandbox,
+	}))
+	if err != nil {
+		return fmt.Errorf("failed to publish event creation: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) publishUpdated(ctx context.Context, e Event) error {
+	err := s.eventBus.Publish(event_bus.NewEvent(ctx, "calendar.event.updated", event_bus.CalendarEventUpdated{
+		UID:          e.UID,
+		Summary:      e.Summary,
+		StartTime:    e.StartTime,
+		EndTime:      e.EndTime,
+		BudgetItemId: e.Metadata.BudgetItemId,
+		TaskId:       e.Metadata.TaskId,
+		Sandbox:      e.Metadata.Sandbox,
+	}))
+	if err != nil {
+		return fmt.Errorf("failed to publish event update: %w", err)
+	}
+	return nil
 }
 
-func splitEventIfNeeded(event *Event, userTimezone string) ([]Event, error) {
-	location, err := time.LoadLocation(userTimezone)
+func checkNoOverlaps(ctx context.Context, repo Repository, userId int, event Event) error {
+	existingEvents, err := repo.GetEvents(ctx, userId, event.StartTime, event.EndTime)
+	if err != nil {
+		return fmt.Errorf("failed to get events: %w", err)
+	}
+	for _, e := range existingEvents {
+		if e.UID != event.UID && overlaps(e, event) {
+			return fmt.Errorf("%w: %s (%s - %s)", ErrEventOverlap, e.Summary,
+				e.StartTime.Format(time.RFC3339), e.EndTime.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// overlaps reports whether two events share any time. Events that only touch are not overlapping.
+func overlaps(a, b Event) bool {
+	return a.StartTime.Before(b.EndTime) && b.StartTime.Before(a.EndTime)
+}
+
+// splitEventIfNeeded splits the event into per-day events in the user's timezone, unless the user
+// prefers to keep cross-midnight events whole. The first event keeps the original UID.
+func splitEventIfNeeded(event *Event, settings user.Settings) ([]Event, error) {
+	dayBoundary, err := utils.NewDayBoundaryForTimezone(settings.Timezone)
 	if err != nil {
-		err := fmt.Errorf("could not load location for timezone %s", userTimezone)
 		log.Error(err)
 		return nil, err
 	}
-	if crossesDateBoundary(event.StartTime, event.EndTime, location) {
-		log.Debug("Event crosses date boundary, splitting it into two events")
-		eventA := Event{
-			UID:       event.UID,
-			Summary:   event.Summary,
-			StartTime: event.StartTime,
-			EndTime:   endOfDay(event.StartTime, location),
-			Metadata:  event.Metadata,
-		}
-		eventB := Event{
-			Summary:   event.Summary,
-			StartTime: startOfNextDay(event.StartTime, location),
-			EndTime:   event.EndTime,
-			Metadata:  event.Metadata,
-		}
-		resultEvents := []Event{eventA}
-		splitEventB, err := splitEventIfNeeded(&eventB, userTimezone)
-		if err != nil {
-			return nil, err
-		}
-		return append(resultEvents, splitEventB...), nil
-	} else {
+	if settings.KeepCrossMidnightEvents {
 		return []Event{
 			{
 				UID:       event.UID,
+				ParentUID: event.ParentUID,
 				Summary:   event.Summary,
 				StartTime: event.StartTime,
 				EndTime:   event.EndTime,
@@ -128,25 +233,32 @@ func splitEventIfNeeded(event *Event, userTimezone string) ([]Event, error) {
 			},
 		}, nil
 	}
-}
-
-func crossesDateBoundary(start, end time.Time, location *time.Location) bool {
-	startDate := start.In(location).YearDay()
-	endDate := end.In(location).YearDay()
-
-	return startDate != endDate
-}
 
-func endOfDay(t time.Time, location *time.Location) time.Time {
-	day := t.In(location)
-	return time.Date(day.Year(), day.Month(), day.Day(), 23, 59, 59, 999999999, location)
-}
-func startOfNextDay(t time.Time, location *time.Location) time.Time {
-	day := t.In(location)
-	return time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, location)
+	ranges := dayBoundary.Split(event.StartTime, event.EndTime)
+	if len(ranges) > 1 {
+		log.Debugf("Event crosses date boundary, splitting it into %d events", len(ranges))
+	}
+	events := make([]Event, 0, len(ranges))
+	for i, r := range ranges {
+		e := Event{
+			Summary:   event.Summary,
+			StartTime: r.Start,
+			EndTime:   r.End,
+			Metadata:  event.Metadata,
+		}
+		if i == 0 {
+			e.UID = event.UID
+			e.ParentUID = event.ParentUID
+		} else {
+			e.ParentUID = event.LogicalUID()
+		}
+		events = append(events, e)
+	}
+	return events, nil
 }
 
 func (s *Service) AddStickyEvent(ctx context.Context, event Event) ([]Event, error) {
+	ctx = withPlanItemsMemo(ctx)
 	err := validateEvent(event)
 	if err != nil {
 		return nil, err
@@ -155,33 +267,18 @@ func (s *Service) AddStickyEvent(ctx context.Context, event Event) ([]Event, err
 	if err != nil {
 		return nil, fmt.Errorf("failed to get events: %w", err)
 	}
-	eventsToModify, eventsToDelete, eventsToCreate := calculateStickyEventsChanges(overlappingEvents, event)
 	var newEvents []Event
-	err = s.repo.WithTransaction(ctx, func(repo Repository) error {
-		s := NewService(repo, s.eventBus, s.planItemsProvider)
-		for _, e := range eventsToModify {
-			_, err := s.ModifyEvent(ctx, e)
-			if err != nil {
-				return fmt.Errorf("failed to update event: %w", err)
-			}
-		}
-		for _, e := range eventsToDelete {
-			err := s.DeleteEvent(ctx, e.UID)
-			if err != nil {
-				return fmt.Errorf("failed to delete event: %w", err)
-			}
-		}
-		for _, e := range eventsToCreate {
-			_, err := s.AddEvent(ctx, e)
-			if err != nil {
-				return fmt.Errorf("failed to add event: %w", err)
-			}
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
+		s := s.withRepo(repo)
+		links, err := s.applyStickyChanges(ctx, overlappingEvents, event)
+		if err != nil {
+			return err
 		}
 		newEvents, err = s.AddEvent(ctx, event)
 		if err != nil {
 			return fmt.Errorf("failed to add event: %w", err)
 		}
-		return nil
+		return s.storeLineage(ctx, links, newEvents[0].UID)
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to perform transaction: %w", err)
@@ -190,6 +287,15 @@ func (s *Service) AddStickyEvent(ctx context.Context, event Event) ([]Event, err
 	return newEvents, nil
 }
 
+// GetEvent returns the event of the current user with the given uid, ErrEventNotFound when there is no such event.
+func (s *Service) GetEvent(ctx context.Context, eventUid string) (Event, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.GetEvent(ctx, userId, eventUid)
+}
+
 func (s *Service) GetEvents(ctx context.Context, from time.Time, to time.Time) ([]Event, error) {
 	userId, err := user.CurrentId(ctx)
 	if err != nil {
@@ -199,8 +305,41 @@ func (s *Service) GetEvents(ctx context.Context, from time.Time, to time.Time) (
 	return s.repo.GetEvents(ctx, userId, from, to)
 }
 
+// GetEventsIncludingArchive is GetEvents that also reads archived events. It is meant for exports and
+// long-range reports, the day-to-day views should use GetEvents.
+func (s *Service) GetEventsIncludingArchive(ctx context.Context, from time.Time, to time.Time) ([]Event, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	archived, err := s.repo.GetArchivedEvents(ctx, userId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.repo.GetEvents(ctx, userId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	events = append(archived, events...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].StartTime.Before(events[j].StartTime)
+	})
+	return events, nil
+}
+
 func (s *Service) ModifyEvent(ctx context.Context, event Event) ([]Event, error) {
-	err := validateEvent(event)
+	if err := validateEvent(event); err != nil {
+		return nil, err
+	}
+	return s.modifyEvent(ctx, event)
+}
+
+// modifyEvent updates the event without requiring a budget item, so an event of a deleted budget item can be
+// shortened or shifted by a sticky event.
+func (s *Service) modifyEvent(ctx context.Context, event Event) ([]Event, error) {
+	ctx = withPlanItemsMemo(ctx)
+	err := validateEventTimes(event)
 	if err != nil {
 		return nil, err
 	}
@@ -208,24 +347,35 @@ func (s *Service) ModifyEvent(ctx context.Context, event Event) ([]Event, error)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get current user: %w", err)
 	}
+	if err := s.checkNotLocked(ctx, event); err != nil {
+		return nil, err
+	}
 	var updatedEvents []Event
-	err = s.repo.WithTransaction(ctx, func(repo Repository) error {
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
 		currentUser, err := user.CurrentUser(ctx)
 		if err != nil {
 			return fmt.Errorf("failed to get current user: %w", err)
 		}
-		events, err := splitEventIfNeeded(&event, currentUser.Settings.Timezone)
+		if err := s.checkStoredEventNotLocked(ctx, repo, userId, event.UID); err != nil {
+			return err
+		}
+		if currentUser.Settings.StrictCalendar {
+			if err := checkNoOverlaps(ctx, repo, userId, event); err != nil {
+				return err
+			}
+		}
+		events, err := splitEventIfNeeded(&event, currentUser.Settings)
 		if err != nil {
 			return err
 		}
 		eventToUpdate := events[0]
 		eventsToAdd := events[1:]
 
-		planItemName, err := s.getEventName(ctx, eventToUpdate.StartTime, eventToUpdate.Metadata.BudgetItemId)
+		summary, err := s.getEventSummary(ctx, currentUser.Settings, eventToUpdate)
 		if err != nil {
 			return err
 		}
-		eventToUpdate.Summary = planItemName
+		eventToUpdate.Summary = summary
 
 		updatedEvent, err := repo.UpdateEvent(ctx, userId, eventToUpdate)
 		if err != nil {
@@ -234,11 +384,14 @@ func (s *Service) ModifyEvent(ctx context.Context, event Event) ([]Event, error)
 		}
 		updatedEvents = append(updatedEvents, updatedEvent)
 		for _, e := range eventsToAdd {
-			planItemName, err := s.getEventName(ctx, e.StartTime, e.Metadata.BudgetItemId)
+			// Parts split off an event are linked to it and, when it is a sandbox event, stay in the sandbox
+			e.ParentUID = updatedEvent.LogicalUID()
+			e.Metadata.Sandbox = updatedEvent.Metadata.Sandbox
+			summary, err := s.getEventSummary(ctx, currentUser.Settings, e)
 			if err != nil {
 				return err
 			}
-			e.Summary = planItemName
+			e.Summary = summary
 			newEvent, err := repo.StoreEvent(ctx, userId, e)
 			if err != nil {
 				log.Errorf("failed to store event: %v", err)
@@ -246,6 +399,15 @@ func (s *Service) ModifyEvent(ctx context.Context, event Event) ([]Event, error)
 			}
 			updatedEvents = append(updatedEvents, newEvent)
 		}
+
+		if err := s.publishUpdated(ctx, updatedEvents[0]); err != nil {
+			return err
+		}
+		for _, e := range updatedEvents[1:] {
+			if err := s.publishCreated(ctx, e); err != nil {
+				return err
+			}
+		}
 		return nil
 	})
 	if err != nil {
@@ -255,26 +417,135 @@ func (s *Service) ModifyEvent(ctx context.Context, event Event) ([]Event, error)
 	return updatedEvents, nil
 }
 
-func (s *Service) getEventName(ctx context.Context, startTime time.Time, budgetItemId int) (string, error) {
-	planItems, err := s.planItemsProvider(ctx, startTime.Add(2*time.Hour))
+// getEventSummary renders the summary of the event from the user's template and the event's weekly plan item.
+// Events without a plan item, e.g. of a deleted budget item, keep their summary.
+func (s *Service) getEventSummary(ctx context.Context, settings user.Settings, event Event) (string, error) {
+	planItem, err := s.getPlanItem(ctx, event.StartTime, event.Metadata.BudgetItemId)
+	if errors.Is(err, errPlanItemNotFound) {
+		return event.Summary, nil
+	}
 	if err != nil {
-		log.Errorf("failed to get plan items: %v", err)
 		return "", err
 	}
-	var planItemInfo *weekly_plan.WeeklyPlanItem
+	return renderSummary(settings.EventSummaryTemplate, planItem.Name, planItem.Icon, event.Metadata.Notes), nil
+}
+
+func (s *Service) getPlanItem(ctx context.Context, startTime time.Time, budgetItemId int) (weekly_plan.WeeklyPlanItem, error) {
+	planItems, err := s.planItems.get(ctx, startTime.Add(2*time.Hour))
+	if err != nil {
+		log.Errorf("failed to get plan items: %v", err)
+		return weekly_plan.WeeklyPlanItem{}, err
+	}
 	for _, planItem := range planItems {
 		if planItem.BudgetItemId == budgetItemId {
-			planItemInfo = &planItem
-			break
+			return planItem, nil
 		}
 	}
-	if planItemInfo == nil {
-		return "", errPlanItemNotFound
+	return weekly_plan.WeeklyPlanItem{}, errPlanItemNotFound
+}
+
+// SubscribeToBudgetItemChanges re-renders summaries of the budget item's events when the item is updated,
+// so renaming the item or changing its icon is reflected in the calendar. Past events are re-rendered only
+// when the user's rename propagation settings allow it. Deleted items are handled by UnlinkBudgetItem.
+func (s *Service) SubscribeToBudgetItemChanges() {
+	event_bus.SubscribeTyped[event_bus.BudgetPlanItemUpdated](
+		s.eventBus,
+		"budget_plan.item.updated",
+		func(e event_bus.EventT[event_bus.BudgetPlanItemUpdated]) error {
+			updated, err := s.renderBudgetItemSummaries(e.Context(), e.Data)
+			if err != nil {
+				log.Errorf("failed to update summaries of budget item %d events: %v", e.Data.Id, err)
+				return err
+			}
+			log.Debugf("updated summaries of %d events of budget item %d", updated, e.Data.Id)
+			return nil
+		},
+	)
+}
+
+// UnlinkBudgetItem removes the references to a deleted budget item. Upcoming events of the item are deleted, past
+// ones, including the archived ones, are kept with the summary they had but without the budget item. It runs in the
+// transaction of ctx, if any, so the events change together with the deletion of the item.
+func (s *Service) UnlinkBudgetItem(ctx context.Context, budgetItemId int) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
+		events, err := repo.GetEventsByBudgetItemId(ctx, userId, budgetItemId)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, e := range events {
+			if e.StartTime.After(now) {
+				if err := repo.DeleteEvent(ctx, userId, e.UID); err != nil {
+					return err
+				}
+				err := s.eventBus.Publish(event_bus.NewEvent(ctx, "calendar.event.deleted", event_bus.CalendarEventDeleted{UID: e.UID}))
+				if err != nil {
+					return fmt.Errorf("failed to publish event deletion: %w", err)
+				}
+				continue
+			}
+			e.Metadata.BudgetItemId = 0
+			updated, err := repo.UpdateEvent(ctx, userId, e)
+			if err != nil {
+				return err
+			}
+			if err := s.publishUpdated(ctx, updated); err != nil {
+				return err
+			}
+		}
+		archived, err := repo.UnlinkArchivedEvents(ctx, userId, budgetItemId)
+		if err != nil {
+			return err
+		}
+		log.Debugf("unlinked %d events and %d archived events of deleted budget item %d", len(events), archived, budgetItemId)
+		return nil
+	})
+}
+
+// renderBudgetItemSummaries re-renders summaries of the budget item's events, events of locked weeks are kept.
+func (s *Service) renderBudgetItemSummaries(ctx context.Context, budgetItem event_bus.BudgetPlanItemUpdated) (int, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get current user: %w", err)
 	}
-	return planItemInfo.Name, nil
+	updated := 0
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
+		events, err := repo.GetEventsByBudgetItemId(ctx, currentUser.Id, budgetItem.Id)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, e := range events {
+			if !currentUser.Settings.RenamePropagation.RewritePastEvents && e.StartTime.Before(now) {
+				continue
+			}
+			if err := s.checkNotLocked(ctx, e); err != nil {
+				if errors.Is(err, weekly_plan.ErrWeekLocked) {
+					continue
+				}
+				return err
+			}
+			summary := renderSummary(currentUser.Settings.EventSummaryTemplate, budgetItem.Name, budgetItem.Icon, e.Metadata.Notes)
+			if summary == e.Summary {
+				continue
+			}
+			e.Summary = summary
+			if _, err := repo.UpdateEvent(ctx, currentUser.Id, e); err != nil {
+				return err
+			}
+			updated++
+		}
+		return nil
+	})
+	return updated, err
 }
 
 func (s *Service) ModifyStickyEvent(ctx context.Context, event Event) ([]Event, error) {
+	ctx = withPlanItemsMemo(ctx)
 	err := validateEvent(event)
 	if err != nil {
 		return nil, err
@@ -283,34 +554,18 @@ func (s *Service) ModifyStickyEvent(ctx context.Context, event Event) ([]Event,
 	if err != nil {
 		return nil, fmt.Errorf("failed to get events: %w", err)
 	}
-	eventsToModify, eventsToDelete, eventsToCreate := calculateStickyEventsChanges(overlappingEvents, event)
 	var modifiedEvents []Event
-	err = s.repo.WithTransaction(ctx, func(repo Repository) error {
-		s := NewService(repo, s.eventBus, s.planItemsProvider)
-		for _, e := range eventsToModify {
-			_, err := s.ModifyEvent(ctx, e)
-			if err != nil {
-				return fmt.Errorf("failed to update event: %w", err)
-			}
-		}
-		for _, e := range eventsToDelete {
-			err := s.DeleteEvent(ctx, e.UID)
-			if err != nil {
-				return fmt.Errorf("failed to delete event: %w", err)
-			}
-		}
-		for _, e := range eventsToCreate {
-			_, err := s.AddEvent(ctx, e)
-			if err != nil {
-				return fmt.Errorf("failed to add event: %w", err)
-			}
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
+		s := s.withRepo(repo)
+		links, err := s.applyStickyChanges(ctx, overlappingEvents, event)
+		if err != nil {
+			return err
 		}
-
 		modifiedEvents, err = s.ModifyEvent(ctx, event)
 		if err != nil {
 			return fmt.Errorf("failed to modify event: %w", err)
 		}
-		return nil
+		return s.storeLineage(ctx, links, event.UID)
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to perform transaction: %w", err)
@@ -319,10 +574,240 @@ func (s *Service) ModifyStickyEvent(ctx context.Context, event Event) ([]Event,
 	return modifiedEvents, nil
 }
 
-func calculateStickyEventsChanges(overlappingEvents []Event, event Event) ([]Event, []Event, []Event) {
+// ModifyStickyEventAndParts is ModifyStickyEvent that also applies the metadata of the event (budget item, notes,
+// task, location and attributes) to the other parts of the logical event it belongs to, e.g. the part of a night
+// spanning event on the next day. The times of the other parts are kept.
+func (s *Service) ModifyStickyEventAndParts(ctx context.Context, event Event) ([]Event, error) {
+	var modifiedEvents []Event
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
+		s := s.withRepo(repo)
+		var err error
+		modifiedEvents, err = s.ModifyStickyEvent(ctx, event)
+		if err != nil {
+			return err
+		}
+		modifiedParts, err := s.propagateToParts(ctx, modifiedEvents[0])
+		if err != nil {
+			return err
+		}
+		modifiedEvents = append(modifiedEvents, modifiedParts...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform transaction: %w", err)
+	}
+	return modifiedEvents, nil
+}
+
+// propagateToParts copies the metadata of the event to the other parts of its logical event and returns the parts
+// that changed.
+func (s *Service) propagateToParts(ctx context.Context, event Event) ([]Event, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	parts, err := s.repo.GetEventParts(ctx, userId, event.LogicalUID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event parts: %w", err)
+	}
+	var modifiedParts []Event
+	for _, part := range parts {
+		metadata := event.Metadata
+		metadata.Sandbox = part.Metadata.Sandbox
+		if part.UID == event.UID || part.Metadata.Equal(metadata) {
+			continue
+		}
+		part.Metadata = metadata
+		modified, err := s.ModifyEvent(ctx, part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to modify event part: %w", err)
+		}
+		modifiedParts = append(modifiedParts, modified...)
+	}
+	return modifiedParts, nil
+}
+
+// applyStickyChanges shortens, shifts, splits or removes events overlapping the sticky event and returns
+// lineage links describing each change. The links are not stored yet, as the sticky event UID may not be known.
+func (s *Service) applyStickyChanges(ctx context.Context, overlappingEvents []Event, event Event) ([]LineageLink, error) {
+	originals := make(map[string]Event, len(overlappingEvents))
+	for _, e := range overlappingEvents {
+		originals[e.UID] = e
+	}
+	eventsToModify, eventsToDelete, eventsToCreate := calculateStickyEventsChanges(overlappingEvents, event)
+
+	links := make([]LineageLink, 0, len(eventsToModify)+len(eventsToDelete)+len(eventsToCreate))
+	// Events of deleted budget items have no budget item, they are adjusted like the others
+	for _, e := range eventsToModify {
+		_, err := s.modifyEvent(ctx, e)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update event: %w", err)
+		}
+		original := originals[e.UID]
+		operation := LineageShortened
+		if !e.StartTime.Equal(original.StartTime) {
+			operation = LineageShifted
+		}
+		links = append(links, newLineageLink(original, e.UID, operation))
+	}
+	for _, e := range eventsToDelete {
+		err := s.DeleteEvent(ctx, e.UID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete event: %w", err)
+		}
+		links = append(links, newLineageLink(e, "", LineageRemoved))
+	}
+	for _, e := range eventsToCreate {
+		createdEvents, err := s.addEvent(ctx, e.Event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add event: %w", err)
+		}
+		for _, created := range createdEvents {
+			links = append(links, newLineageLink(originals[e.SourceUID], created.UID, LineageSplit))
+		}
+	}
+	return links, nil
+}
+
+func newLineageLink(source Event, derivedUID string, operation LineageOperation) LineageLink {
+	return LineageLink{
+		SourceUID:         source.UID,
+		DerivedUID:        derivedUID,
+		Operation:         operation,
+		PreviousStartTime: source.StartTime,
+		PreviousEndTime:   source.EndTime,
+	}
+}
+
+func (s *Service) storeLineage(ctx context.Context, links []LineageLink, causedByUID string) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	for _, link := range links {
+		link.CausedByUID = causedByUID
+		if err := s.repo.StoreLineageLink(ctx, userId, link); err != nil {
+			return fmt.Errorf("failed to store event lineage: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetLineage returns all lineage links connected to the event with the given UID, both the events it
+// was derived from and the events derived from it, ordered by the time the change happened.
+func (s *Service) GetLineage(ctx context.Context, eventUid string) ([]LineageLink, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	visited := map[string]bool{eventUid: true}
+	queue := []string{eventUid}
+	seenLinks := make(map[LineageLink]bool)
+	result := make([]LineageLink, 0)
+	for len(queue) > 0 {
+		uid := queue[0]
+		queue = queue[1:]
+		links, err := s.repo.GetLineageLinks(ctx, userId, uid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get event lineage: %w", err)
+		}
+		for _, link := range links {
+			if seenLinks[link] {
+				continue
+			}
+			seenLinks[link] = true
+			result = append(result, link)
+			for _, next := range []string{link.SourceUID, link.DerivedUID} {
+				if next != "" && !visited[next] {
+					visited[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+// GetDayDiff returns the events of the date's day (in the user's timezone) that were added, removed or modified between
+// the two versions of the calendar, based on the calendar history. Events changed back to their original state
+// are not reported. The history is kept for archive.historydays, changes older than that are missing from the diff.
+func (s *Service) GetDayDiff(ctx context.Context, date time.Time, fromVersion time.Time, toVersion time.Time) (DayDiff, error) {
+	if fromVersion.After(toVersion) {
+		return DayDiff{}, ErrInvalidVersionRange
+	}
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return DayDiff{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	location, err := time.LoadLocation(currentUser.Settings.Timezone)
+	if err != nil {
+		return DayDiff{}, fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	dayStart := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, location)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	changes, err := s.repo.GetEventChanges(ctx, currentUser.Id, fromVersion, toVersion)
+	if err != nil {
+		return DayDiff{}, fmt.Errorf("failed to get event changes: %w", err)
+	}
+
+	diffs := make(map[string]*EventDiff)
+	order := make([]string, 0)
+	for _, change := range changes {
+		diff, ok := diffs[change.EventUID]
+		if !ok {
+			diff = &EventDiff{EventUID: change.EventUID, Before: change.Previous}
+			diffs[change.EventUID] = diff
+			order = append(order, change.EventUID)
+		}
+		diff.After = change.Current
+	}
+
+	result := DayDiff{Date: dayStart, FromVersion: fromVersion, ToVersion: toVersion, Events: make([]EventDiff, 0)}
+	for _, uid := range order {
+		diff := diffs[uid]
+		if !withinDay(diff.Before, dayStart, dayEnd) && !withinDay(diff.After, dayStart, dayEnd) {
+			continue
+		}
+		switch {
+		case diff.Before == nil && diff.After == nil:
+			continue
+		case diff.Before == nil:
+			diff.Type = DiffAdded
+		case diff.After == nil:
+			diff.Type = DiffRemoved
+		case sameEvent(*diff.Before, *diff.After):
+			continue
+		default:
+			diff.Type = DiffModified
+		}
+		result.Events = append(result.Events, *diff)
+	}
+	return result, nil
+}
+
+func withinDay(event *Event, dayStart time.Time, dayEnd time.Time) bool {
+	return event != nil && event.StartTime.Before(dayEnd) && event.EndTime.After(dayStart)
+}
+
+func sameEvent(a, b Event) bool {
+	return a.Summary == b.Summary && a.StartTime.Equal(b.StartTime) && a.EndTime.Equal(b.EndTime) && a.Metadata.Equal(b.Metadata)
+}
+
+// derivedEvent is a new event cut out of an existing (source) event.
+type derivedEvent struct {
+	Event
+	SourceUID string
+}
+
+func calculateStickyEventsChanges(overlappingEvents []Event, event Event) ([]Event, []Event, []derivedEvent) {
 	eventsToModify := make([]Event, 0, len(overlappingEvents))
 	eventsToDelete := make([]Event, 0, len(overlappingEvents))
-	eventsToCreate := make([]Event, 0, len(overlappingEvents))
+	eventsToCreate := make([]derivedEvent, 0, len(overlappingEvents))
 	if len(overlappingEvents) != 0 {
 		for _, overlappingEvent := range overlappingEvents {
 			if overlappingEvent.UID == event.UID {
@@ -344,7 +829,7 @@ func calculateStickyEventsChanges(overlappingEvents []Event, event Event) ([]Eve
 					EndTime:   overlappingEvent.EndTime,
 					Metadata:  overlappingEvent.Metadata,
 				}
-				eventsToCreate = append(eventsToCreate, newEvent)
+				eventsToCreate = append(eventsToCreate, derivedEvent{Event: newEvent, SourceUID: overlappingEvent.UID})
 				overlappingEvent.EndTime = event.StartTime
 				eventsToModify = append(eventsToModify, overlappingEvent)
 			}
@@ -370,15 +855,120 @@ func (s *Service) GetEarliestEventTimeForBudgetItems(ctx context.Context, budget
 	return s.repo.GetEarliestEventTimeForBudgetItems(ctx, userId, budgetItemIds)
 }
 
+// FindOverlaps returns all pairs of stored events overlapping each other within the given period.
+// It is meant to report existing overlaps before switching a user to strict calendar mode.
+func (s *Service) FindOverlaps(ctx context.Context, from time.Time, to time.Time) ([]Overlap, error) {
+	events, err := s.GetEvents(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events: %w", err)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].StartTime.Before(events[j].StartTime)
+	})
+
+	result := make([]Overlap, 0)
+	for i := range events {
+		for j := i + 1; j < len(events) && events[j].StartTime.Before(events[i].EndTime); j++ {
+			if overlaps(events[i], events[j]) {
+				result = append(result, Overlap{First: events[i], Second: events[j]})
+			}
+		}
+	}
+	return result, nil
+}
+
 func (s *Service) DeleteEvent(ctx context.Context, eventUid string) error {
 	userId, err := user.CurrentId(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get current user: %w", err)
 	}
-	return s.repo.DeleteEvent(ctx, userId, eventUid)
+	if err := s.checkStoredEventNotLocked(ctx, s.repo, userId, eventUid); err != nil {
+		return err
+	}
+	return s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
+		if err := repo.DeleteEvent(ctx, userId, eventUid); err != nil {
+			return err
+		}
+		err := s.eventBus.Publish(event_bus.NewEvent(ctx, "calendar.event.deleted", event_bus.CalendarEventDeleted{UID: eventUid}))
+		if err != nil {
+			return fmt.Errorf("failed to publish event deletion: %w", err)
+		}
+		return nil
+	})
+}
+
+// checkNotLocked returns weekly_plan.ErrWeekLocked when the event falls into a locked week.
+func (s *Service) checkNotLocked(ctx context.Context, event Event) error {
+	return s.weekLockChecker.CheckEvent(ctx, event)
+}
+
+// checkStoredEventNotLocked is checkNotLocked for the event as currently stored. Unknown events are left
+// to the caller to report.
+func (s *Service) checkStoredEventNotLocked(ctx context.Context, repo Repository, userId int, eventUid string) error {
+	if s.weekLockChecker == nil {
+		return nil
+	}
+	stored, err := repo.GetEvent(ctx, userId, eventUid)
+	if err != nil {
+		if errors.Is(err, ErrEventNotFound) {
+			return nil
+		}
+		return err
+	}
+	return s.checkNotLocked(ctx, stored)
+}
+
+// AddSandboxEvents stores generated demo events flagged as sandbox. Events are stored as they are,
+// without splitting or overlap checks, and no creation events are published, so they never reach exports.
+func (s *Service) AddSandboxEvents(ctx context.Context, events []Event) ([]Event, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	for _, e := range events {
+		if err := validateEvent(e); err != nil {
+			return nil, err
+		}
+	}
+
+	storedEvents := make([]Event, 0, len(events))
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
+		for _, e := range events {
+			e.Metadata.Sandbox = true
+			storedEvent, err := repo.StoreEvent(ctx, userId, e)
+			if err != nil {
+				return err
+			}
+			storedEvents = append(storedEvents, storedEvent)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform transaction: %w", err)
+	}
+	return storedEvents, nil
+}
+
+// DeleteSandboxEvents removes all sandbox events of the current user and returns how many were removed.
+func (s *Service) DeleteSandboxEvents(ctx context.Context) (int, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.DeleteSandboxEvents(ctx, userId)
 }
 
 func validateEvent(event Event) error {
+	if err := validateEventTimes(event); err != nil {
+		return err
+	}
+	if event.Metadata.BudgetItemId == 0 {
+		return fmt.Errorf("budget item id cannot be zero")
+	}
+	return nil
+}
+
+func validateEventTimes(event Event) error {
 	if event.StartTime.IsZero() {
 		return fmt.Errorf("start time cannot be zero")
 	}
@@ -388,8 +978,5 @@ func validateEvent(event Event) error {
 	if !event.EndTime.After(event.StartTime) {
 		return fmt.Errorf("end time must be after start time")
 	}
-	if event.Metadata.BudgetItemId == 0 {
-		return fmt.Errorf("budget item id cannot be zero")
-	}
 	return nil
 }
@@ -8,6 +8,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
 	"github.com/klokku/klokku/pkg/user"
 	"github.com/klokku/klokku/pkg/weekly_plan"
 	"github.com/stretchr/testify/assert"
@@ -40,7 +41,7 @@ var weeklyItemsProvider = func(ctx context.Context, date time.Time) ([]weekly_pl
 // Test setup helper
 func setupServiceTest(t *testing.T) (*Service, context.Context, func()) {
 	repoStub := NewRepositoryStub()
-	service := NewService(repoStub, eventBus, weeklyItemsProvider)
+	service := NewService(repoStub, eventBus, weeklyItemsProvider, nil)
 	ctx := user.WithUser(context.Background(), user.User{
 		Id:          1,
 		Uid:         uuid.NewString(),
@@ -52,6 +53,7 @@ func setupServiceTest(t *testing.T) (*Service, context.Context, func()) {
 			WeekFirstDay:      time.Monday,
 			EventCalendarType: user.KlokkuCalendar,
 			GoogleCalendar:    user.GoogleCalendarSettings{},
+			RenamePropagation: user.DefaultRenamePropagation,
 		},
 	})
 
@@ -242,8 +244,8 @@ func TestService_AddStickyEvent(t *testing.T) {
 			want: []compareEvent{
 				{
 					Summary:   "Test BudgetItem 3",
-					StartTime: start,                                                                                        // 10:00
-					EndTime:   time.Date(start.Year(), start.Month(), start.Day(), 23, 59, 59, 999999999, start.Location()), // 23:59
+					StartTime: start,                     // 10:00
+					EndTime:   start.Add(14 * time.Hour), // 00:00
 				},
 				{
 					Summary:   "Test BudgetItem 3",
@@ -594,9 +596,70 @@ func TestService_ModifyStickyEvent_MultiDay(t *testing.T) {
 	require.NoError(t, err)
 	assert.Len(t, modifiedEvents, 2)
 	assert.Equal(t, start, modifiedEvents[0].StartTime)
-	assert.Equal(t, time.Date(start.Year(), start.Month(), start.Day(), 23, 59, 59, 999999999, location), modifiedEvents[0].EndTime)
+	assert.Equal(t, time.Date(start.Year(), start.Month(), start.Day()+1, 0, 0, 0, 0, location), modifiedEvents[0].EndTime)
 	assert.Equal(t, start.Add(2*time.Hour), modifiedEvents[1].StartTime)
 	assert.Equal(t, start.Add(4*time.Hour), modifiedEvents[1].EndTime)
+	assert.Equal(t, modifiedEvents[0].UID, modifiedEvents[1].ParentUID)
+}
+
+func TestService_ModifyStickyEventAndParts(t *testing.T) {
+	start := time.Date(2026, 1, 1, 22, 0, 0, 0, location)
+
+	t.Run("applies the metadata to the other parts", func(t *testing.T) {
+		s, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		// given
+		parts, err := s.AddStickyEvent(ctx, Event{
+			StartTime: start,
+			EndTime:   start.Add(4 * time.Hour), // 02:00 the next day
+			Metadata:  EventMetadata{BudgetItemId: 101},
+		})
+		require.NoError(t, err)
+		require.Len(t, parts, 2)
+
+		// when
+		secondPart := parts[1]
+		secondPart.Metadata = EventMetadata{BudgetItemId: 102, Notes: "Night shift"}
+		modifiedEvents, err := s.ModifyStickyEventAndParts(ctx, secondPart)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, modifiedEvents, 2)
+		assert.Equal(t, parts[1].UID, modifiedEvents[0].UID)
+		assert.Equal(t, parts[0].UID, modifiedEvents[1].UID)
+		stored, err := s.GetEvents(ctx, start, start.Add(4*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, stored, 2)
+		for _, e := range stored {
+			assert.Equal(t, EventMetadata{BudgetItemId: 102, Notes: "Night shift"}, e.Metadata)
+			assert.Equal(t, "Test BudgetItem 2", e.Summary)
+		}
+		assert.Equal(t, start, stored[0].StartTime)
+		assert.Equal(t, start.Add(4*time.Hour), stored[1].EndTime)
+	})
+
+	t.Run("modifies only the event when it was not split", func(t *testing.T) {
+		s, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		// given
+		added, err := s.AddStickyEvent(ctx, Event{
+			StartTime: start,
+			EndTime:   start.Add(time.Hour),
+			Metadata:  EventMetadata{BudgetItemId: 101},
+		})
+		require.NoError(t, err)
+
+		// when
+		added[0].Metadata.BudgetItemId = 103
+		modifiedEvents, err := s.ModifyStickyEventAndParts(ctx, added[0])
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, modifiedEvents, 1)
+		assert.Equal(t, 103, modifiedEvents[0].Metadata.BudgetItemId)
+	})
 }
 
 func TestService_AddEvent(t *testing.T) {
@@ -633,6 +696,37 @@ func TestService_AddEvent(t *testing.T) {
 	})
 }
 
+func TestService_AddEvents(t *testing.T) {
+	s, ctx, teardown := setupServiceTest(t)
+	defer teardown()
+
+	// given
+	valid := Event{
+		Summary:   "Test BudgetItem 1",
+		StartTime: time.Date(2023, 1, 1, 10, 0, 0, 0, location),
+		EndTime:   time.Date(2023, 1, 1, 11, 0, 0, 0, location),
+		Metadata:  EventMetadata{BudgetItemId: 101},
+	}
+	invalid := valid
+	invalid.Metadata.BudgetItemId = 0
+	another := valid
+	another.StartTime = valid.EndTime
+	another.EndTime = valid.EndTime.Add(time.Hour)
+
+	// when
+	eventErrs, err := s.AddEvents(ctx, []Event{valid, invalid, another})
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, eventErrs, 3)
+	assert.NoError(t, eventErrs[0])
+	assert.Error(t, eventErrs[1])
+	assert.NoError(t, eventErrs[2])
+	stored, err := s.GetEvents(ctx, valid.StartTime, another.EndTime)
+	require.NoError(t, err)
+	assert.Len(t, stored, 2)
+}
+
 func TestService_AddEvent_Validation(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -871,3 +965,726 @@ func TestService_ModifyStickyEvent_Validation(t *testing.T) {
 		})
 	}
 }
+
+func withStrictCalendar(t *testing.T, ctx context.Context) context.Context {
+	currentUser, err := user.CurrentUser(ctx)
+	require.NoError(t, err)
+	currentUser.Settings.StrictCalendar = true
+	return user.WithUser(ctx, currentUser)
+}
+
+func TestService_AddEvent_CrossMidnight(t *testing.T) {
+	start := time.Date(2026, 3, 28, 22, 0, 0, 0, location) // the night before DST starts in Europe/Warsaw
+
+	t.Run("splits event spanning midnight into per-day events", func(t *testing.T) {
+		s, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		events, err := s.AddEvent(ctx, Event{
+			StartTime: start,
+			EndTime:   start.Add(5 * time.Hour), // 04:00 CEST on the next day
+			Metadata:  EventMetadata{BudgetItemId: 101},
+		})
+
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		assert.Equal(t, time.Date(2026, 3, 29, 0, 0, 0, 0, location), events[0].EndTime)
+		assert.Equal(t, time.Date(2026, 3, 29, 0, 0, 0, 0, location), events[1].StartTime)
+		assert.Equal(t, time.Date(2026, 3, 29, 4, 0, 0, 0, location), events[1].EndTime)
+		assert.Empty(t, events[0].ParentUID)
+		assert.Equal(t, events[0].UID, events[1].ParentUID)
+	})
+
+	t.Run("does not create an empty event for an event ending at midnight", func(t *testing.T) {
+		s, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		events, err := s.AddEvent(ctx, Event{
+			StartTime: start,
+			EndTime:   time.Date(2026, 3, 29, 0, 0, 0, 0, location),
+			Metadata:  EventMetadata{BudgetItemId: 101},
+		})
+
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, time.Date(2026, 3, 29, 0, 0, 0, 0, location), events[0].EndTime)
+	})
+
+	t.Run("keeps event whole when user prefers it", func(t *testing.T) {
+		s, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		currentUser, err := user.CurrentUser(ctx)
+		require.NoError(t, err)
+		currentUser.Settings.KeepCrossMidnightEvents = true
+		ctx = user.WithUser(ctx, currentUser)
+
+		events, err := s.AddEvent(ctx, Event{
+			StartTime: start,
+			EndTime:   start.Add(5 * time.Hour),
+			Metadata:  EventMetadata{BudgetItemId: 101},
+		})
+
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, start, events[0].StartTime)
+		assert.Equal(t, start.Add(5*time.Hour), events[0].EndTime)
+	})
+}
+
+func TestService_SandboxEvents(t *testing.T) {
+	start := time.Date(2026, 4, 6, 22, 0, 0, 0, location)
+
+	t.Run("parts split off a modified sandbox event stay in the sandbox", func(t *testing.T) {
+		s, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		stored, err := s.AddSandboxEvents(ctx, []Event{{
+			StartTime: start,
+			EndTime:   start.Add(time.Hour),
+			Metadata:  EventMetadata{BudgetItemId: 101},
+		}})
+		require.NoError(t, err)
+
+		modified := stored[0]
+		modified.EndTime = start.Add(4 * time.Hour)
+		modified.Metadata = EventMetadata{BudgetItemId: 101} // clients do not send the sandbox flag
+		events, err := s.ModifyEvent(ctx, modified)
+
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		assert.True(t, events[0].Metadata.Sandbox)
+		assert.True(t, events[1].Metadata.Sandbox)
+	})
+
+	t.Run("deletes only sandbox events", func(t *testing.T) {
+		s, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		_, err := s.AddSandboxEvents(ctx, []Event{{
+			StartTime: start,
+			EndTime:   start.Add(time.Hour),
+			Metadata:  EventMetadata{BudgetItemId: 101},
+		}})
+		require.NoError(t, err)
+		_, err = s.AddEvent(ctx, Event{
+			StartTime: start.Add(-2 * time.Hour),
+			EndTime:   start.Add(-time.Hour),
+			Metadata:  EventMetadata{BudgetItemId: 102},
+		})
+		require.NoError(t, err)
+
+		deleted, err := s.DeleteSandboxEvents(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, deleted)
+		remaining, err := s.GetEvents(ctx, start.Add(-3*time.Hour), start.Add(2*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, remaining, 1)
+		assert.Equal(t, 102, remaining[0].Metadata.BudgetItemId)
+	})
+}
+
+func TestService_StrictCalendar(t *testing.T) {
+	existing := Event{
+		Summary:   "Test BudgetItem 1",
+		StartTime: time.Date(2026, 1, 1, 10, 0, 0, 0, location),
+		EndTime:   time.Date(2026, 1, 1, 11, 0, 0, 0, location),
+		Metadata:  EventMetadata{BudgetItemId: 101},
+	}
+
+	t.Run("rejects overlapping event when adding", func(t *testing.T) {
+		s, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		ctx = withStrictCalendar(t, ctx)
+		_, err := s.AddEvent(ctx, existing)
+		require.NoError(t, err)
+
+		_, err = s.AddEvent(ctx, Event{
+			StartTime: time.Date(2026, 1, 1, 10, 30, 0, 0, location),
+			EndTime:   time.Date(2026, 1, 1, 11, 30, 0, 0, location),
+			Metadata:  EventMetadata{BudgetItemId: 102},
+		})
+
+		assert.ErrorIs(t, err, ErrEventOverlap)
+		events, err := s.GetEvents(ctx, existing.StartTime, existing.EndTime.Add(time.Hour))
+		require.NoError(t, err)
+		assert.Len(t, events, 1)
+	})
+
+	t.Run("accepts adjacent event when adding", func(t *testing.T) {
+		s, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		ctx = withStrictCalendar(t, ctx)
+		_, err := s.AddEvent(ctx, existing)
+		require.NoError(t, err)
+
+		_, err = s.AddEvent(ctx, Event{
+			StartTime: existing.EndTime,
+			EndTime:   existing.EndTime.Add(time.Hour),
+			Metadata:  EventMetadata{BudgetItemId: 102},
+		})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("rejects modification that would overlap another event", func(t *testing.T) {
+		s, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		ctx = withStrictCalendar(t, ctx)
+		_, err := s.AddEvent(ctx, existing)
+		require.NoError(t, err)
+		added, err := s.AddEvent(ctx, Event{
+			StartTime: existing.EndTime,
+			EndTime:   existing.EndTime.Add(time.Hour),
+			Metadata:  EventMetadata{BudgetItemId: 102},
+		})
+		require.NoError(t, err)
+
+		toModify := added[0]
+		toModify.StartTime = existing.StartTime.Add(30 * time.Minute)
+		_, err = s.ModifyEvent(ctx, toModify)
+
+		assert.ErrorIs(t, err, ErrEventOverlap)
+	})
+
+	t.Run("allows overlaps when strict calendar is disabled", func(t *testing.T) {
+		s, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		_, err := s.AddEvent(ctx, existing)
+		require.NoError(t, err)
+
+		_, err = s.AddEvent(ctx, Event{
+			StartTime: time.Date(2026, 1, 1, 10, 30, 0, 0, location),
+			EndTime:   time.Date(2026, 1, 1, 11, 30, 0, 0, location),
+			Metadata:  EventMetadata{BudgetItemId: 102},
+		})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("sticky add resolves overlaps in strict mode", func(t *testing.T) {
+		s, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		ctx = withStrictCalendar(t, ctx)
+		_, err := s.AddEvent(ctx, existing)
+		require.NoError(t, err)
+
+		_, err = s.AddStickyEvent(ctx, Event{
+			StartTime: time.Date(2026, 1, 1, 10, 30, 0, 0, location),
+			EndTime:   time.Date(2026, 1, 1, 11, 30, 0, 0, location),
+			Metadata:  EventMetadata{BudgetItemId: 102},
+		})
+
+		assert.NoError(t, err)
+	})
+}
+
+func TestService_FindOverlaps(t *testing.T) {
+	s, ctx, teardown := setupServiceTest(t)
+	defer teardown()
+
+	// given
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, location)
+	for _, e := range []Event{
+		{StartTime: day.Add(9 * time.Hour), EndTime: day.Add(10 * time.Hour), Metadata: EventMetadata{BudgetItemId: 101}},
+		{StartTime: day.Add(10 * time.Hour), EndTime: day.Add(12 * time.Hour), Metadata: EventMetadata{BudgetItemId: 102}},
+		{StartTime: day.Add(11 * time.Hour), EndTime: day.Add(13 * time.Hour), Metadata: EventMetadata{BudgetItemId: 103}},
+	} {
+		_, err := s.AddEvent(ctx, e)
+		require.NoError(t, err)
+	}
+
+	// when
+	overlaps, err := s.FindOverlaps(ctx, day, day.Add(24*time.Hour))
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, overlaps, 1)
+	assert.Equal(t, "Test BudgetItem 2", overlaps[0].First.Summary)
+	assert.Equal(t, "Test BudgetItem 3", overlaps[0].Second.Summary)
+}
+
+func TestService_GetLineage(t *testing.T) {
+	t.Run("records split, shortened and removed events caused by sticky event", func(t *testing.T) {
+		s, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		// given
+		day := time.Date(2026, 1, 1, 0, 0, 0, 0, location)
+		long, err := s.AddEvent(ctx, Event{StartTime: day.Add(8 * time.Hour), EndTime: day.Add(12 * time.Hour), Metadata: EventMetadata{BudgetItemId: 101}})
+		require.NoError(t, err)
+
+		// when
+		sticky, err := s.AddStickyEvent(ctx, Event{StartTime: day.Add(9 * time.Hour), EndTime: day.Add(10 * time.Hour), Metadata: EventMetadata{BudgetItemId: 102}})
+		require.NoError(t, err)
+
+		// then
+		lineage, err := s.GetLineage(ctx, long[0].UID)
+		require.NoError(t, err)
+		require.Len(t, lineage, 2)
+		operations := map[LineageOperation]LineageLink{}
+		for _, link := range lineage {
+			assert.Equal(t, long[0].UID, link.SourceUID)
+			assert.Equal(t, sticky[0].UID, link.CausedByUID)
+			assert.Equal(t, day.Add(8*time.Hour), link.PreviousStartTime)
+			assert.Equal(t, day.Add(12*time.Hour), link.PreviousEndTime)
+			operations[link.Operation] = link
+		}
+		assert.Equal(t, long[0].UID, operations[LineageShortened].DerivedUID)
+		splitUID := operations[LineageSplit].DerivedUID
+		require.NotEmpty(t, splitUID)
+
+		// the derived event leads back to its source
+		derivedLineage, err := s.GetLineage(ctx, splitUID)
+		require.NoError(t, err)
+		assert.Len(t, derivedLineage, 2)
+	})
+
+	t.Run("records removed event", func(t *testing.T) {
+		s, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		day := time.Date(2026, 1, 1, 0, 0, 0, 0, location)
+		short, err := s.AddEvent(ctx, Event{StartTime: day.Add(9*time.Hour + 15*time.Minute), EndTime: day.Add(9*time.Hour + 30*time.Minute), Metadata: EventMetadata{BudgetItemId: 101}})
+		require.NoError(t, err)
+
+		sticky, err := s.AddStickyEvent(ctx, Event{StartTime: day.Add(9 * time.Hour), EndTime: day.Add(10 * time.Hour), Metadata: EventMetadata{BudgetItemId: 102}})
+		require.NoError(t, err)
+
+		lineage, err := s.GetLineage(ctx, short[0].UID)
+		require.NoError(t, err)
+		require.Len(t, lineage, 1)
+		assert.Equal(t, LineageRemoved, lineage[0].Operation)
+		assert.Empty(t, lineage[0].DerivedUID)
+		assert.Equal(t, sticky[0].UID, lineage[0].CausedByUID)
+	})
+
+	t.Run("returns empty lineage for untouched event", func(t *testing.T) {
+		s, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		lineage, err := s.GetLineage(ctx, "unknown")
+
+		require.NoError(t, err)
+		assert.Empty(t, lineage)
+	})
+}
+
+func TestService_EventSummaryTemplate(t *testing.T) {
+	start := time.Date(2026, 2, 2, 10, 0, 0, 0, location)
+	itemsProvider := func(ctx context.Context, date time.Time) ([]weekly_plan.WeeklyPlanItem, error) {
+		return []weekly_plan.WeeklyPlanItem{{Id: 1, BudgetItemId: 101, Name: "Reading", Icon: "📚"}}, nil
+	}
+	setup := func(t *testing.T, template string) (*Service, *event_bus.EventBus, context.Context) {
+		bus := event_bus.NewEventBus()
+		s := NewService(NewRepositoryStub(), bus, itemsProvider, nil)
+		s.SubscribeToBudgetItemChanges()
+		ctx := user.WithUser(context.Background(), user.User{
+			Id: 1,
+			Settings: user.Settings{
+				Timezone:             "Europe/Warsaw",
+				EventSummaryTemplate: template,
+				RenamePropagation:    user.DefaultRenamePropagation,
+			},
+		})
+		return s, bus, ctx
+	}
+
+	t.Run("renders summary from the user's template", func(t *testing.T) {
+		s, _, ctx := setup(t, "{icon} {name} – {note}")
+
+		events, err := s.AddEvent(ctx, Event{
+			StartTime: start,
+			EndTime:   start.Add(time.Hour),
+			Metadata:  EventMetadata{BudgetItemId: 101, Notes: "Dune"},
+		})
+		require.NoError(t, err)
+		withoutNote, err := s.AddEvent(ctx, Event{
+			StartTime: start.Add(2 * time.Hour),
+			EndTime:   start.Add(3 * time.Hour),
+			Metadata:  EventMetadata{BudgetItemId: 101},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "📚 Reading – Dune", events[0].Summary)
+		assert.Equal(t, "📚 Reading", withoutNote[0].Summary)
+	})
+
+	t.Run("uses the item name without a template", func(t *testing.T) {
+		s, _, ctx := setup(t, "")
+
+		events, err := s.AddEvent(ctx, Event{
+			StartTime: start,
+			EndTime:   start.Add(time.Hour),
+			Metadata:  EventMetadata{BudgetItemId: 101, Notes: "Dune"},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, "Reading", events[0].Summary)
+	})
+
+	t.Run("re-renders summaries when the budget item is renamed", func(t *testing.T) {
+		s, bus, ctx := setup(t, "{icon} {name}")
+		_, err := s.AddEvent(ctx, Event{
+			StartTime: start,
+			EndTime:   start.Add(time.Hour),
+			Metadata:  EventMetadata{BudgetItemId: 101},
+		})
+		require.NoError(t, err)
+
+		err = bus.Publish(event_bus.NewEvent(ctx, "budget_plan.item.updated", event_bus.BudgetPlanItemUpdated{
+			Id:   101,
+			Name: "Books",
+			Icon: "📖",
+		}))
+		require.NoError(t, err)
+
+		events, err := s.GetEvents(ctx, start, start.Add(time.Hour))
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, "📖 Books", events[0].Summary)
+	})
+
+	t.Run("preserves past summaries when the user keeps the past", func(t *testing.T) {
+		s, bus, ctx := setup(t, "{name}")
+		currentUser, _ := user.CurrentUser(ctx)
+		currentUser.Settings.RenamePropagation.RewritePastEvents = false
+		ctx = user.WithUser(ctx, currentUser)
+		future := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+		for _, startTime := range []time.Time{start, future} {
+			_, err := s.AddEvent(ctx, Event{
+				StartTime: startTime,
+				EndTime:   startTime.Add(time.Hour),
+				Metadata:  EventMetadata{BudgetItemId: 101},
+			})
+			require.NoError(t, err)
+		}
+
+		err := bus.Publish(event_bus.NewEvent(ctx, "budget_plan.item.updated", event_bus.BudgetPlanItemUpdated{
+			Id:   101,
+			Name: "Books",
+		}))
+		require.NoError(t, err)
+
+		past, err := s.GetEvents(ctx, start, start.Add(time.Hour))
+		require.NoError(t, err)
+		require.Len(t, past, 1)
+		assert.Equal(t, "Reading", past[0].Summary)
+		upcoming, err := s.GetEvents(ctx, future, future.Add(time.Hour))
+		require.NoError(t, err)
+		require.Len(t, upcoming, 1)
+		assert.Equal(t, "Books", upcoming[0].Summary)
+	})
+}
+
+func TestService_UnlinkBudgetItem(t *testing.T) {
+	// given
+	itemsProvider := func(ctx context.Context, date time.Time) ([]weekly_plan.WeeklyPlanItem, error) {
+		return []weekly_plan.WeeklyPlanItem{{Id: 1, BudgetItemId: 101, Name: "Reading"}}, nil
+	}
+	bus := event_bus.NewEventBus()
+	s := NewService(NewRepositoryStub(), bus, itemsProvider, nil)
+	ctx := user.WithUser(context.Background(), user.User{Id: 1, Settings: user.Settings{Timezone: "Europe/Warsaw"}})
+	past := time.Date(2026, 2, 2, 10, 0, 0, 0, location)
+	future := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	for _, startTime := range []time.Time{past, future} {
+		_, err := s.AddEvent(ctx, Event{
+			StartTime: startTime,
+			EndTime:   startTime.Add(time.Hour),
+			Metadata:  EventMetadata{BudgetItemId: 101},
+		})
+		require.NoError(t, err)
+	}
+	var updated []event_bus.CalendarEventUpdated
+	var deleted []event_bus.CalendarEventDeleted
+	event_bus.SubscribeTyped[event_bus.CalendarEventUpdated](bus, "calendar.event.updated",
+		func(e event_bus.EventT[event_bus.CalendarEventUpdated]) error {
+			updated = append(updated, e.Data)
+			return nil
+		})
+	event_bus.SubscribeTyped[event_bus.CalendarEventDeleted](bus, "calendar.event.deleted",
+		func(e event_bus.EventT[event_bus.CalendarEventDeleted]) error {
+			deleted = append(deleted, e.Data)
+			return nil
+		})
+
+	// when
+	err := s.UnlinkBudgetItem(ctx, 101)
+
+	// then
+	require.NoError(t, err)
+	pastEvents, err := s.GetEvents(ctx, past, past.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, pastEvents, 1)
+	assert.Equal(t, "Reading", pastEvents[0].Summary)
+	assert.Equal(t, 0, pastEvents[0].Metadata.BudgetItemId)
+	upcomingEvents, err := s.GetEvents(ctx, future, future.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, upcomingEvents)
+	require.Len(t, updated, 1)
+	assert.Equal(t, pastEvents[0].UID, updated[0].UID)
+	assert.Equal(t, 0, updated[0].BudgetItemId)
+	assert.Len(t, deleted, 1)
+}
+
+func TestService_AddStickyEvent_OverUnlinkedEvent(t *testing.T) {
+	// given
+	itemsProvider := func(ctx context.Context, date time.Time) ([]weekly_plan.WeeklyPlanItem, error) {
+		return []weekly_plan.WeeklyPlanItem{
+			{Id: 1, BudgetItemId: 101, Name: "Reading"},
+			{Id: 2, BudgetItemId: 102, Name: "Call"},
+		}, nil
+	}
+	s := NewService(NewRepositoryStub(), event_bus.NewEventBus(), itemsProvider, nil)
+	ctx := user.WithUser(context.Background(), user.User{Id: 1, Settings: user.Settings{Timezone: "Europe/Warsaw"}})
+	start := time.Date(2026, 2, 2, 10, 0, 0, 0, location)
+	_, err := s.AddEvent(ctx, Event{
+		StartTime: start,
+		EndTime:   start.Add(2 * time.Hour),
+		Metadata:  EventMetadata{BudgetItemId: 101},
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.UnlinkBudgetItem(ctx, 101))
+
+	// when
+	_, err = s.AddStickyEvent(ctx, Event{
+		StartTime: start.Add(time.Hour),
+		EndTime:   start.Add(90 * time.Minute),
+		Metadata:  EventMetadata{BudgetItemId: 102},
+	})
+
+	// then
+	require.NoError(t, err)
+	events, err := s.GetEvents(ctx, start, start.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	// the unlinked event is split around the sticky one and keeps its summary
+	assert.Equal(t, "Reading", events[0].Summary)
+	assert.Equal(t, 0, events[0].Metadata.BudgetItemId)
+	assert.Equal(t, start.Add(time.Hour), events[0].EndTime)
+	assert.Equal(t, "Call", events[1].Summary)
+	assert.Equal(t, "Reading", events[2].Summary)
+	assert.Equal(t, 0, events[2].Metadata.BudgetItemId)
+	assert.Equal(t, start.Add(90*time.Minute), events[2].StartTime)
+}
+
+func TestRenderSummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		note     string
+		expect   string
+	}{
+		{"default template", "", "note", "Work"},
+		{"icon and name", "{icon} {name}", "", "💼 Work"},
+		{"name and note", "{name} – {note}", "Meeting", "Work – Meeting"},
+		{"empty note is trimmed", "{name} – {note}", "", "Work"},
+		{"note first", "{note}: {name}", "", "Work"},
+		{"separators of the note are kept", "{name} – {note}", "- draft -", "Work – - draft -"},
+		{"text without placeholders", "Focus", "", "Focus"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, renderSummary(tt.template, "Work", "💼", tt.note))
+		})
+	}
+
+	t.Run("separators of the name are kept", func(t *testing.T) {
+		assert.Equal(t, "Q&A:", renderSummary("{name} – {note}", "Q&A:", "", ""))
+		assert.Equal(t, "-Reading-", renderSummary("", "-Reading-", "", ""))
+	})
+}
+
+func TestService_LockedWeek(t *testing.T) {
+	lockedWeekStart := time.Date(2026, 2, 2, 0, 0, 0, 0, location) // Monday
+	weekLockChecker := func(ctx context.Context, date time.Time) (bool, error) {
+		return !date.Before(lockedWeekStart) && date.Before(lockedWeekStart.AddDate(0, 0, 7)), nil
+	}
+	ctx := user.WithUser(context.Background(), user.User{
+		Id:       1,
+		Settings: user.Settings{Timezone: "Europe/Warsaw", WeekFirstDay: time.Monday},
+	})
+	repoStub := NewRepositoryStub()
+	s := NewService(repoStub, event_bus.NewEventBus(), weeklyItemsProvider, weekLockChecker)
+	// An event stored before the week was locked
+	stored, err := repoStub.StoreEvent(ctx, 1, Event{
+		StartTime: lockedWeekStart.Add(10 * time.Hour),
+		EndTime:   lockedWeekStart.Add(11 * time.Hour),
+		Metadata:  EventMetadata{BudgetItemId: 101},
+	})
+	require.NoError(t, err)
+
+	_, addErr := s.AddEvent(ctx, Event{
+		StartTime: lockedWeekStart.Add(12 * time.Hour),
+		EndTime:   lockedWeekStart.Add(13 * time.Hour),
+		Metadata:  EventMetadata{BudgetItemId: 101},
+	})
+	moved := stored
+	moved.StartTime = lockedWeekStart.AddDate(0, 0, 7).Add(10 * time.Hour)
+	moved.EndTime = lockedWeekStart.AddDate(0, 0, 7).Add(11 * time.Hour)
+	_, modifyErr := s.ModifyEvent(ctx, moved)
+	deleteErr := s.DeleteEvent(ctx, stored.UID)
+
+	assert.ErrorIs(t, addErr, weekly_plan.ErrWeekLocked)
+	assert.ErrorIs(t, modifyErr, weekly_plan.ErrWeekLocked)
+	assert.ErrorIs(t, deleteErr, weekly_plan.ErrWeekLocked)
+	assert.Len(t, repoStub.GetAllEvents(), 1)
+
+	updated, err := s.renderBudgetItemSummaries(ctx, event_bus.BudgetPlanItemUpdated{Id: 101, Name: "Renamed"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, updated)
+	assert.Equal(t, stored.Summary, repoStub.GetAllEvents()[0].Summary)
+
+	events, err := s.AddEvent(ctx, Event{
+		StartTime: lockedWeekStart.AddDate(0, 0, 7).Add(10 * time.Hour),
+		EndTime:   lockedWeekStart.AddDate(0, 0, 7).Add(11 * time.Hour),
+		Metadata:  EventMetadata{BudgetItemId: 101},
+	})
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+}
+
+func TestService_GetDayDiff(t *testing.T) {
+	service, ctx, teardown := setupServiceTest(t)
+	defer teardown()
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, location)
+	addEvent := func(hour int, budgetItemId int) Event {
+		events, err := service.AddEvent(ctx, Event{
+			StartTime: day.Add(time.Duration(hour) * time.Hour),
+			EndTime:   day.Add(time.Duration(hour+1) * time.Hour),
+			Metadata:  EventMetadata{BudgetItemId: budgetItemId},
+		})
+		require.NoError(t, err)
+		return events[0]
+	}
+	version := func() time.Time {
+		time.Sleep(time.Millisecond)
+		defer time.Sleep(time.Millisecond)
+		return time.Now()
+	}
+
+	kept := addEvent(8, 101)
+	removed := addEvent(10, 102)
+	modified := addEvent(12, 103)
+	restored := addEvent(14, 101)
+	fromVersion := version()
+
+	added := addEvent(16, 102)
+	require.NoError(t, service.DeleteEvent(ctx, removed.UID))
+	changed := modified
+	changed.EndTime = changed.EndTime.Add(30 * time.Minute)
+	_, err := service.ModifyEvent(ctx, changed)
+	require.NoError(t, err)
+	moved := restored
+	moved.StartTime = moved.StartTime.Add(time.Hour)
+	moved.EndTime = moved.EndTime.Add(time.Hour)
+	_, err = service.ModifyEvent(ctx, moved)
+	require.NoError(t, err)
+	_, err = service.ModifyEvent(ctx, restored)
+	require.NoError(t, err)
+	toVersion := version()
+
+	// changes after the to version are not included
+	addEvent(18, 101)
+
+	diff, err := service.GetDayDiff(ctx, day, fromVersion, toVersion)
+	require.NoError(t, err)
+
+	require.Len(t, diff.Events, 3)
+	assert.Equal(t, added.UID, diff.Events[0].EventUID)
+	assert.Equal(t, DiffAdded, diff.Events[0].Type)
+	assert.Nil(t, diff.Events[0].Before)
+	assert.Equal(t, removed.UID, diff.Events[1].EventUID)
+	assert.Equal(t, DiffRemoved, diff.Events[1].Type)
+	assert.Nil(t, diff.Events[1].After)
+	assert.Equal(t, modified.UID, diff.Events[2].EventUID)
+	assert.Equal(t, DiffModified, diff.Events[2].Type)
+	assert.True(t, modified.EndTime.Equal(diff.Events[2].Before.EndTime))
+	assert.True(t, changed.EndTime.Equal(diff.Events[2].After.EndTime))
+	for _, e := range diff.Events {
+		assert.NotEqual(t, kept.UID, e.EventUID)
+	}
+
+	otherDay, err := service.GetDayDiff(ctx, day.AddDate(0, 0, 1), fromVersion, toVersion)
+	require.NoError(t, err)
+	assert.Empty(t, otherDay.Events)
+
+	_, err = service.GetDayDiff(ctx, day, toVersion, fromVersion)
+	assert.ErrorIs(t, err, ErrInvalidVersionRange)
+}
+
+func TestService_PlanItemsCache(t *testing.T) {
+	lookups := 0
+	countingProvider := func(ctx context.Context, date time.Time) ([]weekly_plan.WeeklyPlanItem, error) {
+		lookups++
+		return weeklyItemsProvider(ctx, date)
+	}
+	bus := event_bus.NewEventBus()
+	s := NewService(NewRepositoryStub(), bus, countingProvider, nil)
+	s.SubscribeToPlanChanges()
+	clock := &utils.MockClock{FixedNow: time.Now()}
+	s.planItems.clock = clock
+	ctx := user.WithUser(context.Background(), user.User{
+		Id:       1,
+		Settings: user.Settings{Timezone: "Europe/Warsaw", WeekFirstDay: time.Monday},
+	})
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, location)
+	for hour := 8; hour < 12; hour++ {
+		_, err := s.AddEvent(ctx, Event{
+			StartTime: day.Add(time.Duration(hour) * time.Hour),
+			EndTime:   day.Add(time.Duration(hour+1) * time.Hour),
+			Metadata:  EventMetadata{BudgetItemId: 101},
+		})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, lookups, "the week is cached across operations")
+
+	t.Run("sticky cascade looks up the week once", func(t *testing.T) {
+		s.planItems.invalidate(ctx)
+		lookups = 0
+		_, err := s.AddStickyEvent(ctx, Event{
+			StartTime: day.Add(8*time.Hour + 30*time.Minute),
+			EndTime:   day.Add(11*time.Hour + 30*time.Minute),
+			Metadata:  EventMetadata{BudgetItemId: 102},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, lookups)
+	})
+
+	t.Run("weekly plan change invalidates the cache", func(t *testing.T) {
+		lookups = 0
+		err := bus.Publish(event_bus.NewEvent(ctx, "weekly_plan.week.changed", event_bus.WeeklyPlanWeekChanged{Week: "2025-W11"}))
+		require.NoError(t, err)
+		_, err = s.AddEvent(ctx, Event{
+			StartTime: day.Add(14 * time.Hour),
+			EndTime:   day.Add(15 * time.Hour),
+			Metadata:  EventMetadata{BudgetItemId: 101},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, lookups)
+	})
+
+	t.Run("entries expire", func(t *testing.T) {
+		lookups = 0
+		clock.SetNow(clock.Now().Add(planItemsCacheTTL))
+		_, err := s.AddEvent(ctx, Event{
+			StartTime: day.Add(16 * time.Hour),
+			EndTime:   day.Add(17 * time.Hour),
+			Metadata:  EventMetadata{BudgetItemId: 101},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, lookups)
+	})
+
+	t.Run("the number of entries is bounded", func(t *testing.T) {
+		cache := newPlanItemsCache(weeklyItemsProvider)
+		cache.clock = clock
+		for userId := 1; userId <= planItemsCacheMaxEntries+10; userId++ {
+			userCtx := user.WithUser(context.Background(), user.User{Id: userId, Settings: user.Settings{WeekFirstDay: time.Monday}})
+			_, err := cache.get(userCtx, day)
+			require.NoError(t, err)
+			clock.SetNow(clock.Now().Add(time.Millisecond))
+		}
+		assert.Len(t, cache.entries, planItemsCacheMaxEntries)
+		_, oldestKept := cache.entries[planItemsCacheKey{1, weekly_plan.WeekNumberFromDate(day, time.Monday)}]
+		assert.False(t, oldestKept)
+	})
+}
@@ -29,17 +29,29 @@ func (c *StubCalendar) AddEvent(ctx context.Context, event Event) ([]Event, erro
 		return nil, fmt.Errorf("failed to get current user: %w", err)
 	}
 
-	events, err := splitEventIfNeeded(&event, currentUser.Settings.Timezone)
+	events, err := splitEventIfNeeded(&event, currentUser.Settings)
 	if err != nil {
 		return nil, err
 	}
-	for _, e := range events {
-		c.data[e.UID] = e
+	for i := range events {
+		// Parts of a split event get their own UIDs, like when they are stored
+		if events[i].UID == "" {
+			events[i].UID = uuid.NewString()
+		}
+		c.data[events[i].UID] = events[i]
 	}
 	return events, nil
 
 }
 
+func (c *StubCalendar) AddEvents(ctx context.Context, events []Event) ([]error, error) {
+	eventErrs := make([]error, len(events))
+	for i, event := range events {
+		_, eventErrs[i] = c.AddEvent(ctx, event)
+	}
+	return eventErrs, nil
+}
+
 func (c *StubCalendar) GetEvents(ctx context.Context, from time.Time, to time.Time) ([]Event, error) {
 	var events []Event
 	for _, event := range c.data {
@@ -55,6 +67,11 @@ func (c *StubCalendar) GetEvents(ctx context.Context, from time.Time, to time.Ti
 	return events, nil
 }
 
+// GetEventsIncludingArchive is GetEvents, the stub has no archive.
+func (c *StubCalendar) GetEventsIncludingArchive(ctx context.Context, from time.Time, to time.Time) ([]Event, error) {
+	return c.GetEvents(ctx, from, to)
+}
+
 func (c *StubCalendar) ModifyEvent(ctx context.Context, event Event) ([]Event, error) {
 	if event.UID == "" {
 		return nil, errors.New("event.UID is required")
@@ -64,7 +81,7 @@ func (c *StubCalendar) ModifyEvent(ctx context.Context, event Event) ([]Event, e
 	if err != nil {
 		return nil, fmt.Errorf("failed to get current user: %w", err)
 	}
-	events, err := splitEventIfNeeded(&event, currentUser.Settings.Timezone)
+	events, err := splitEventIfNeeded(&event, currentUser.Settings)
 	if err != nil {
 		return nil, err
 	}
@@ -82,6 +99,7 @@ func (c *StubCalendar) ModifyEvent(ctx context.Context, event Event) ([]Event, e
 	c.data[foundEvent.UID] = foundEvent
 
 	for _, e := range eventsToAdd {
+		e.UID = uuid.NewString()
 		c.data[e.UID] = e
 	}
 
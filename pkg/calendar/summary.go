@@ -0,0 +1,63 @@
+package calendar
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DefaultSummaryTemplate is used when the user has not configured an event summary template.
+const DefaultSummaryTemplate = "{name}"
+
+// summarySeparators are trimmed from the template text at the start and the end of rendered summaries, so
+// e.g. "{name} – {note}" renders as just the name for events without notes. The values are never trimmed.
+const summarySeparators = " \t-–—|:,·/"
+
+var summaryPlaceholder = regexp.MustCompile(`\{(name|icon|note)\}`)
+
+// summaryPart is either text of the template or the value of a placeholder.
+type summaryPart struct {
+	text  string
+	value bool
+}
+
+// renderSummary renders the event summary from the template. Supported placeholders are {name} and {icon}
+// of the budget item, and {note} - the event's notes.
+func renderSummary(template string, name string, icon string, note string) string {
+	if template == "" {
+		template = DefaultSummaryTemplate
+	}
+	values := map[string]string{"{name}": name, "{icon}": icon, "{note}": note}
+	var parts []summaryPart
+	end := 0
+	for _, match := range summaryPlaceholder.FindAllStringIndex(template, -1) {
+		parts = append(parts, summaryPart{text: template[end:match[0]]})
+		if value := values[template[match[0]:match[1]]]; value != "" {
+			parts = append(parts, summaryPart{text: value, value: true})
+		}
+		end = match[1]
+	}
+	parts = append(parts, summaryPart{text: template[end:]})
+
+	firstValue, lastValue := len(parts), -1
+	for i, part := range parts {
+		if part.value {
+			firstValue = min(firstValue, i)
+			lastValue = i
+		}
+	}
+	var summary strings.Builder
+	for i, part := range parts {
+		text := part.text
+		if !part.value && i < firstValue {
+			text = strings.TrimLeft(text, summarySeparators)
+		}
+		if !part.value && i > lastValue {
+			text = strings.TrimRight(text, summarySeparators)
+		}
+		summary.WriteString(text)
+	}
+	if rendered := strings.TrimSpace(summary.String()); rendered != "" {
+		return rendered
+	}
+	return name
+}
@@ -10,30 +10,56 @@ import (
 	"github.com/klokku/klokku/pkg/user"
 )
 
+var (
+	ErrProviderNotAvailable = errors.New("calendar provider not available")
+	ErrNotSupported         = errors.New("operation not supported by the calendar provider")
+	ErrInvalidProvider      = errors.New("invalid calendar provider")
+)
+
+// CalendarProvider is the calendar of the current user, it delegates to the provider selected in the user's settings.
 type CalendarProvider struct {
-	userService    user.Service
-	klokkuCalendar calendar.Calendar
+	userService user.Service
+	registry    *Registry
 }
 
-func NewCalendarProvider(userService user.Service, klokkuCalendar calendar.Calendar) *CalendarProvider {
+func NewCalendarProvider(userService user.Service, registry *Registry) *CalendarProvider {
 	return &CalendarProvider{
-		userService:    userService,
-		klokkuCalendar: klokkuCalendar,
+		userService: userService,
+		registry:    registry,
 	}
 }
 
-func (c *CalendarProvider) getCalendar(ctx context.Context) (calendar.Calendar, error) {
+// CurrentProvider returns the provider selected by the current user.
+func (c *CalendarProvider) CurrentProvider(ctx context.Context) (Provider, error) {
 	currentUser, err := c.userService.GetCurrentUser(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get current user when getting calendar: %w", err)
+		return Provider{}, fmt.Errorf("failed to get current user when getting calendar: %w", err)
 	}
-	switch u := currentUser; u.Settings.EventCalendarType {
-	case user.GoogleCalendar:
-		return nil, errors.New("google calendar implementation disabled")
-	case user.KlokkuCalendar:
-		return c.klokkuCalendar, nil
+	calendarType := currentUser.Settings.EventCalendarType
+	provider, ok := c.registry.Get(calendarType)
+	if !ok {
+		return Provider{}, fmt.Errorf("%w: %q", ErrProviderNotAvailable, calendarType)
 	}
-	return nil, errors.New("unknown calendar type")
+	return provider, nil
+}
+
+func (c *CalendarProvider) getCalendar(ctx context.Context) (calendar.Calendar, error) {
+	provider, err := c.CurrentProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return provider.Calendar, nil
+}
+
+func (c *CalendarProvider) getStickyCalendar(ctx context.Context) (StickyCalendar, error) {
+	provider, err := c.CurrentProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !provider.Capabilities.SupportsStickyEdit {
+		return nil, fmt.Errorf("%w: %s does not support sticky edits", ErrNotSupported, provider.Name)
+	}
+	return provider.Calendar.(StickyCalendar), nil
 }
 
 func (c *CalendarProvider) AddEvent(ctx context.Context, event calendar.Event) ([]calendar.Event, error) {
@@ -44,6 +70,33 @@ func (c *CalendarProvider) AddEvent(ctx context.Context, event calendar.Event) (
 	return cal.AddEvent(ctx, event)
 }
 
+// AddEvents adds the events in a single transaction when the provider of the current user supports it, see
+// BatchCalendar, or one by one otherwise. The error of an event the calendar refuses is at its index of the returned
+// errors.
+func (c *CalendarProvider) AddEvents(ctx context.Context, events []calendar.Event) ([]error, error) {
+	cal, err := c.getCalendar(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get calendar when adding events: %w", err)
+	}
+	if batchCalendar, ok := cal.(BatchCalendar); ok {
+		return batchCalendar.AddEvents(ctx, events)
+	}
+	eventErrs := make([]error, len(events))
+	for i, event := range events {
+		_, eventErrs[i] = cal.AddEvent(ctx, event)
+	}
+	return eventErrs, nil
+}
+
+// AddStickyEvent returns ErrNotSupported when the provider of the current user does not support sticky edits.
+func (c *CalendarProvider) AddStickyEvent(ctx context.Context, event calendar.Event) ([]calendar.Event, error) {
+	cal, err := c.getStickyCalendar(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get calendar when adding sticky event: %w", err)
+	}
+	return cal.AddStickyEvent(ctx, event)
+}
+
 func (c *CalendarProvider) GetEvents(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error) {
 	cal, err := c.getCalendar(ctx)
 	if err != nil {
@@ -52,6 +105,14 @@ func (c *CalendarProvider) GetEvents(ctx context.Context, from time.Time, to tim
 	return cal.GetEvents(ctx, from, to)
 }
 
+func (c *CalendarProvider) GetEventsIncludingArchive(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error) {
+	cal, err := c.getCalendar(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get calendar when getting events: %w", err)
+	}
+	return cal.GetEventsIncludingArchive(ctx, from, to)
+}
+
 func (c *CalendarProvider) ModifyEvent(ctx context.Context, event calendar.Event) ([]calendar.Event, error) {
 	cal, err := c.getCalendar(ctx)
 	if err != nil {
@@ -60,6 +121,15 @@ func (c *CalendarProvider) ModifyEvent(ctx context.Context, event calendar.Event
 	return cal.ModifyEvent(ctx, event)
 }
 
+// ModifyStickyEvent returns ErrNotSupported when the provider of the current user does not support sticky edits.
+func (c *CalendarProvider) ModifyStickyEvent(ctx context.Context, event calendar.Event) ([]calendar.Event, error) {
+	cal, err := c.getStickyCalendar(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get calendar when modifying sticky event: %w", err)
+	}
+	return cal.ModifyStickyEvent(ctx, event)
+}
+
 func (c *CalendarProvider) GetLastEvents(ctx context.Context, limit int) ([]calendar.Event, error) {
 	cal, err := c.getCalendar(ctx)
 	if err != nil {
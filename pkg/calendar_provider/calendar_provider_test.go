@@ -0,0 +1,133 @@
+package calendar_provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type calendarStub struct {
+	added []calendar.Event
+}
+
+func (c *calendarStub) AddEvent(ctx context.Context, event calendar.Event) ([]calendar.Event, error) {
+	c.added = append(c.added, event)
+	return []calendar.Event{event}, nil
+}
+
+func (c *calendarStub) GetEvents(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error) {
+	return c.added, nil
+}
+
+func (c *calendarStub) GetEventsIncludingArchive(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error) {
+	return c.added, nil
+}
+
+func (c *calendarStub) ModifyEvent(ctx context.Context, event calendar.Event) ([]calendar.Event, error) {
+	return []calendar.Event{event}, nil
+}
+
+func (c *calendarStub) GetLastEvents(ctx context.Context, limit int) ([]calendar.Event, error) {
+	return c.added, nil
+}
+
+func (c *calendarStub) DeleteEvent(ctx context.Context, eventUid string) error {
+	return nil
+}
+
+type stickyCalendarStub struct {
+	calendarStub
+	stickyAdded []calendar.Event
+}
+
+func (c *stickyCalendarStub) AddStickyEvent(ctx context.Context, event calendar.Event) ([]calendar.Event, error) {
+	c.stickyAdded = append(c.stickyAdded, event)
+	return []calendar.Event{event}, nil
+}
+
+func (c *stickyCalendarStub) ModifyStickyEvent(ctx context.Context, event calendar.Event) ([]calendar.Event, error) {
+	return []calendar.Event{event}, nil
+}
+
+func contextWithCalendarType(calendarType user.EventCalendarType) context.Context {
+	return context.WithValue(context.Background(), user.UserKey, user.User{
+		Id:       1,
+		Username: "test-user",
+		Settings: user.Settings{Timezone: "Europe/Warsaw", EventCalendarType: calendarType},
+	})
+}
+
+func setup(t *testing.T) (*CalendarProvider, *stickyCalendarStub, *calendarStub) {
+	t.Helper()
+	klokku := &stickyCalendarStub{}
+	outlook := &calendarStub{}
+	registry := NewRegistry()
+	require.NoError(t, registry.Register(Provider{
+		Type:         user.KlokkuCalendar,
+		Name:         "Klokku",
+		Calendar:     klokku,
+		Capabilities: Capabilities{SupportsStickyEdit: true, SupportsMetadata: true},
+	}))
+	require.NoError(t, registry.Register(Provider{
+		Type:         user.OutlookCalendar,
+		Name:         "Outlook",
+		Calendar:     outlook,
+		Capabilities: Capabilities{SupportsMetadata: true},
+	}))
+	return NewCalendarProvider(user.NewUserService(user.NewStubUserRepository(), event_bus.NewEventBus()), registry), klokku, outlook
+}
+
+func TestCalendarProvider_DelegatesToProviderOfCurrentUser(t *testing.T) {
+	provider, klokku, outlook := setup(t)
+	event := calendar.Event{StartTime: time.Now(), EndTime: time.Now().Add(time.Hour)}
+
+	_, err := provider.AddEvent(contextWithCalendarType(user.OutlookCalendar), event)
+	require.NoError(t, err)
+
+	assert.Len(t, outlook.added, 1)
+	assert.Empty(t, klokku.added)
+}
+
+func TestCalendarProvider_StickyEdit(t *testing.T) {
+	provider, klokku, _ := setup(t)
+	event := calendar.Event{StartTime: time.Now(), EndTime: time.Now().Add(time.Hour)}
+
+	_, err := provider.AddStickyEvent(contextWithCalendarType(user.KlokkuCalendar), event)
+	require.NoError(t, err)
+	assert.Len(t, klokku.stickyAdded, 1)
+
+	_, err = provider.AddStickyEvent(contextWithCalendarType(user.OutlookCalendar), event)
+	assert.ErrorIs(t, err, ErrNotSupported)
+}
+
+func TestCalendarProvider_UnknownProvider(t *testing.T) {
+	provider, _, _ := setup(t)
+
+	_, err := provider.GetLastEvents(contextWithCalendarType(user.GoogleCalendar), 5)
+
+	assert.ErrorIs(t, err, ErrProviderNotAvailable)
+}
+
+func TestRegistry_Register(t *testing.T) {
+	registry := NewRegistry()
+	require.NoError(t, registry.Register(Provider{Type: user.OutlookCalendar, Calendar: &calendarStub{}}))
+
+	duplicateErr := registry.Register(Provider{Type: user.OutlookCalendar, Calendar: &calendarStub{}})
+	stickyErr := registry.Register(Provider{
+		Type:         user.KlokkuCalendar,
+		Calendar:     &calendarStub{},
+		Capabilities: Capabilities{SupportsStickyEdit: true},
+	})
+	missingErr := registry.Register(Provider{Type: user.GoogleCalendar})
+
+	assert.ErrorIs(t, duplicateErr, ErrInvalidProvider)
+	assert.ErrorIs(t, stickyErr, ErrInvalidProvider)
+	assert.ErrorIs(t, missingErr, ErrInvalidProvider)
+	assert.Len(t, registry.Providers(), 1)
+}
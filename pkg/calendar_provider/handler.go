@@ -0,0 +1,58 @@
+package calendar_provider
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+)
+
+type CapabilitiesDTO struct {
+	SupportsStickyEdit bool `json:"supportsStickyEdit"`
+	SupportsMetadata   bool `json:"supportsMetadata"`
+}
+
+type ProviderDTO struct {
+	Type         user.EventCalendarType `json:"type"`
+	Name         string                 `json:"name"`
+	Capabilities CapabilitiesDTO        `json:"capabilities"`
+}
+
+type Handler struct {
+	registry *Registry
+}
+
+func NewHandler(registry *Registry) *Handler {
+	return &Handler{registry: registry}
+}
+
+// ListProviders godoc
+// @Summary List calendar providers
+// @Description List the calendar providers available on this instance with their capabilities.
+// @Description The type of the chosen one goes to settings.eventCalendarType of the user.
+// @Tags Calendar
+// @Produce json
+// @Success 200 {array} ProviderDTO
+// @Router /api/calendar/providers [get]
+// @Security XUserId
+func (h *Handler) ListProviders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	providers := h.registry.Providers()
+	dtos := make([]ProviderDTO, 0, len(providers))
+	for _, p := range providers {
+		dtos = append(dtos, ProviderDTO{
+			Type: p.Type,
+			Name: p.Name,
+			Capabilities: CapabilitiesDTO{
+				SupportsStickyEdit: p.Capabilities.SupportsStickyEdit,
+				SupportsMetadata:   p.Capabilities.SupportsMetadata,
+			},
+		})
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		log.Errorf("Failed to encode calendar providers: %v", err)
+		http.Error(w, "Failed to encode calendar providers", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,79 @@
+package calendar_provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+)
+
+// Capabilities tell consumers which optional features a calendar backend supports.
+type Capabilities struct {
+	// SupportsStickyEdit - events can be added or modified as sticky, shortening, shifting or splitting the events
+	// they overlap. The calendar then has to implement StickyCalendar.
+	SupportsStickyEdit bool
+	// SupportsMetadata - the budget item, notes and task reference of events are stored with them.
+	SupportsMetadata bool
+}
+
+// StickyCalendar is implemented by calendars supporting sticky edits.
+type StickyCalendar interface {
+	AddStickyEvent(ctx context.Context, event calendar.Event) ([]calendar.Event, error)
+	ModifyStickyEvent(ctx context.Context, event calendar.Event) ([]calendar.Event, error)
+}
+
+// BatchCalendar is implemented by calendars adding many events in a single transaction, see calendar.Service.AddEvents.
+type BatchCalendar interface {
+	AddEvents(ctx context.Context, events []calendar.Event) ([]error, error)
+}
+
+// Provider is a calendar backend users can keep their events in, selected by user.Settings.EventCalendarType.
+// Backend specific settings, e.g. the chosen external calendar, are part of the user settings as well.
+type Provider struct {
+	Type         user.EventCalendarType
+	Name         string
+	Calendar     calendar.Calendar
+	Capabilities Capabilities
+}
+
+// Registry holds the available calendar providers. Providers are registered when the application starts,
+// consumers only depend on CalendarProvider, so adding a provider does not touch them.
+type Registry struct {
+	providers []Provider
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds the provider. It fails when the provider is invalid or its type is already registered.
+func (r *Registry) Register(provider Provider) error {
+	if provider.Type == "" || provider.Calendar == nil {
+		return fmt.Errorf("%w: a type and a calendar are required", ErrInvalidProvider)
+	}
+	if _, ok := r.Get(provider.Type); ok {
+		return fmt.Errorf("%w: %q registered twice", ErrInvalidProvider, provider.Type)
+	}
+	if provider.Capabilities.SupportsStickyEdit {
+		if _, ok := provider.Calendar.(StickyCalendar); !ok {
+			return fmt.Errorf("%w: %q supports sticky edit but does not implement it", ErrInvalidProvider, provider.Type)
+		}
+	}
+	r.providers = append(r.providers, provider)
+	return nil
+}
+
+func (r *Registry) Get(calendarType user.EventCalendarType) (Provider, bool) {
+	for _, provider := range r.providers {
+		if provider.Type == calendarType {
+			return provider, true
+		}
+	}
+	return Provider{}, false
+}
+
+// Providers returns the registered providers in the order of registration.
+func (r *Registry) Providers() []Provider {
+	return append([]Provider(nil), r.providers...)
+}
@@ -0,0 +1,49 @@
+package chat_notification
+
+import (
+	"slices"
+	"time"
+)
+
+type Provider string
+
+const (
+	ProviderSlack   Provider = "slack"
+	ProviderDiscord Provider = "discord"
+)
+
+var Providers = []Provider{ProviderSlack, ProviderDiscord}
+
+type Trigger string
+
+const (
+	// TriggerEventStarted fires when the user starts tracking a budget item.
+	TriggerEventStarted Trigger = "event_started"
+	// TriggerBudgetExceeded fires once a week per item when the tracked time exceeds the item's weekly budget.
+	TriggerBudgetExceeded Trigger = "budget_exceeded"
+	// TriggerWeekSummary fires on the first day of a week with the summary of the previous one.
+	TriggerWeekSummary Trigger = "week_summary"
+	// TriggerIntegrationStale fires when the token of a connected integration has been invalid for too long
+	// and its sync is about to be disabled.
+	TriggerIntegrationStale Trigger = "integration_stale"
+	// TriggerBudgetAlert fires when the tracked time of an item crosses one of the user's budget alert thresholds.
+	TriggerBudgetAlert Trigger = "budget_alert"
+)
+
+var Triggers = []Trigger{TriggerEventStarted, TriggerBudgetExceeded, TriggerWeekSummary, TriggerIntegrationStale,
+	TriggerBudgetAlert}
+
+// Integration posts the user's notifications for the selected triggers to a Slack or Discord incoming webhook.
+type Integration struct {
+	Id         int
+	UserId     int
+	Provider   Provider
+	WebhookUrl string
+	Triggers   []Trigger
+	Enabled    bool
+	CreatedAt  time.Time
+}
+
+func (i Integration) Fires(trigger Trigger) bool {
+	return i.Enabled && slices.Contains(i.Triggers, trigger)
+}
@@ -0,0 +1,251 @@
+package chat_notification
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/internal/rest"
+	log "github.com/sirupsen/logrus"
+)
+
+type IntegrationDTO struct {
+	Id         int      `json:"id"`
+	Provider   string   `json:"provider" enums:"slack,discord"`
+	WebhookUrl string   `json:"webhookUrl"`
+	Triggers   []string `json:"triggers" enums:"event_started,budget_exceeded,week_summary,integration_stale,budget_alert"`
+	// Enabled defaults to true
+	Enabled   *bool     `json:"enabled,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// ListIntegrations godoc
+// @Summary List chat notification integrations
+// @Description List the Slack and Discord webhooks the current user's notifications are posted to
+// @Tags ChatNotification
+// @Produce json
+// @Success 200 {array} IntegrationDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/chat-notifications [get]
+// @Security XUserId
+func (h *Handler) ListIntegrations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	integrations, err := h.service.ListIntegrations(r.Context())
+	if err != nil {
+		log.Errorf("Failed to list chat notification integrations: %v", err)
+		http.Error(w, "Failed to list chat notification integrations", http.StatusInternalServerError)
+		return
+	}
+	dtos := make([]IntegrationDTO, 0, len(integrations))
+	for _, integration := range integrations {
+		dtos = append(dtos, integrationToDTO(integration))
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		log.Errorf("Failed to encode chat notification integrations: %v", err)
+		http.Error(w, "Failed to encode chat notification integrations", http.StatusInternalServerError)
+	}
+}
+
+// CreateIntegration godoc
+// @Summary Create a chat notification integration
+// @Description Post notifications to a Slack or Discord incoming webhook (an https URL) when any of the triggers
+// @Description fires: event_started when an event is started, budget_exceeded once a week per item when its
+// @Description weekly budget is exceeded, week_summary on the first day of a week with the previous week's summary.
+// @Tags ChatNotification
+// @Accept json
+// @Produce json
+// @Param integration body IntegrationDTO true "Integration"
+// @Success 201 {object} IntegrationDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid integration"
+// @Failure 403 {string} string "User not found"
+// @Router /api/chat-notifications [post]
+// @Security XUserId
+func (h *Handler) CreateIntegration(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var integrationDTO IntegrationDTO
+	if err := json.NewDecoder(r.Body).Decode(&integrationDTO); err != nil {
+		writeBadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	created, err := h.service.CreateIntegration(r.Context(), dtoToIntegration(integrationDTO))
+	if err != nil {
+		if errors.Is(err, ErrInvalidIntegration) {
+			writeBadRequest(w, "Invalid chat notification integration", err.Error())
+			return
+		}
+		log.Errorf("Failed to create chat notification integration: %v", err)
+		http.Error(w, "Failed to create chat notification integration", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(integrationToDTO(created)); err != nil {
+		log.Errorf("Failed to encode chat notification integration: %v", err)
+	}
+}
+
+// UpdateIntegration godoc
+// @Summary Update a chat notification integration
+// @Tags ChatNotification
+// @Accept json
+// @Produce json
+// @Param id path int true "Integration ID"
+// @Param integration body IntegrationDTO true "Integration"
+// @Success 200 {object} IntegrationDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid integration"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Integration not found"
+// @Router /api/chat-notifications/{id} [put]
+// @Security XUserId
+func (h *Handler) UpdateIntegration(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid integration ID", http.StatusBadRequest)
+		return
+	}
+	var integrationDTO IntegrationDTO
+	if err := json.NewDecoder(r.Body).Decode(&integrationDTO); err != nil {
+		writeBadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	integration := dtoToIntegration(integrationDTO)
+	integration.Id = id
+
+	updated, err := h.service.UpdateIntegration(r.Context(), integration)
+	if err != nil {
+		if errors.Is(err, ErrInvalidIntegration) {
+			writeBadRequest(w, "Invalid chat notification integration", err.Error())
+			return
+		}
+		if errors.Is(err, ErrIntegrationNotFound) {
+			http.Error(w, "Integration not found", http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to update chat notification integration: %v", err)
+		http.Error(w, "Failed to update chat notification integration", http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(integrationToDTO(updated)); err != nil {
+		log.Errorf("Failed to encode chat notification integration: %v", err)
+		http.Error(w, "Failed to encode chat notification integration", http.StatusInternalServerError)
+	}
+}
+
+// DeleteIntegration godoc
+// @Summary Delete a chat notification integration
+// @Tags ChatNotification
+// @Param id path int true "Integration ID"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Integration not found"
+// @Router /api/chat-notifications/{id} [delete]
+// @Security XUserId
+func (h *Handler) DeleteIntegration(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid integration ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.DeleteIntegration(r.Context(), id); err != nil {
+		if errors.Is(err, ErrIntegrationNotFound) {
+			http.Error(w, "Integration not found", http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to delete chat notification integration: %v", err)
+		http.Error(w, "Failed to delete chat notification integration", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SendTest godoc
+// @Summary Send a test chat notification
+// @Description Post a test message to the integration's webhook to check it is set up correctly
+// @Tags ChatNotification
+// @Produce json
+// @Param id path int true "Integration ID"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Integration not found"
+// @Failure 502 {object} rest.ErrorResponse "The webhook rejected the message"
+// @Router /api/chat-notifications/{id}/test [post]
+// @Security XUserId
+func (h *Handler) SendTest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid integration ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.SendTest(r.Context(), id); err != nil {
+		if errors.Is(err, ErrIntegrationNotFound) {
+			http.Error(w, "Integration not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, ErrPostFailed) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadGateway)
+			_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+				Error:   "The webhook rejected the message",
+				Details: err.Error(),
+			})
+			return
+		}
+		log.Errorf("Failed to send test chat notification: %v", err)
+		http.Error(w, "Failed to send test chat notification", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func integrationToDTO(integration Integration) IntegrationDTO {
+	dto := IntegrationDTO{
+		Id:         integration.Id,
+		Provider:   string(integration.Provider),
+		WebhookUrl: integration.WebhookUrl,
+		Triggers:   make([]string, 0, len(integration.Triggers)),
+		Enabled:    &integration.Enabled,
+		CreatedAt:  integration.CreatedAt,
+	}
+	for _, trigger := range integration.Triggers {
+		dto.Triggers = append(dto.Triggers, string(trigger))
+	}
+	return dto
+}
+
+func dtoToIntegration(dto IntegrationDTO) Integration {
+	integration := Integration{
+		Id:         dto.Id,
+		Provider:   Provider(dto.Provider),
+		WebhookUrl: dto.WebhookUrl,
+		Enabled:    dto.Enabled == nil || *dto.Enabled,
+	}
+	for _, trigger := range dto.Triggers {
+		integration.Triggers = append(integration.Triggers, Trigger(trigger))
+	}
+	return integration
+}
+
+func writeBadRequest(w http.ResponseWriter, errMsg, details string) {
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+		Error:   errMsg,
+		Details: details,
+	})
+}
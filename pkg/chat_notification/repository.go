@@ -0,0 +1,147 @@
+package chat_notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrIntegrationNotFound = errors.New("chat notification integration not found")
+
+type Repository interface {
+	ListIntegrations(ctx context.Context, userId int) ([]Integration, error)
+	GetIntegration(ctx context.Context, userId int, id int) (Integration, error)
+	CreateIntegration(ctx context.Context, integration Integration) (Integration, error)
+	UpdateIntegration(ctx context.Context, integration Integration) (Integration, error)
+	// DeleteIntegration returns false when the integration does not exist.
+	DeleteIntegration(ctx context.Context, userId int, id int) (bool, error)
+	// ListIntegrationsWithTrigger returns the enabled integrations of all users that fire for the trigger.
+	ListIntegrationsWithTrigger(ctx context.Context, trigger Trigger) ([]Integration, error)
+	// MarkSent records that the notification identified by key was posted to the integration. It returns false
+	// when it was recorded before.
+	MarkSent(ctx context.Context, integrationId int, key string) (bool, error)
+}
+
+const integrationColumns = `id, user_id, provider, webhook_url, triggers, enabled, created_at`
+
+func scanIntegration(row pgx.Row) (Integration, error) {
+	var integration Integration
+	var triggers []string
+	if err := row.Scan(&integration.Id, &integration.UserId, &integration.Provider, &integration.WebhookUrl,
+		&triggers, &integration.Enabled, &integration.CreatedAt); err != nil {
+		return Integration{}, err
+	}
+	for _, trigger := range triggers {
+		integration.Triggers = append(integration.Triggers, Trigger(trigger))
+	}
+	return integration, nil
+}
+
+func triggersToStrings(triggers []Trigger) []string {
+	result := make([]string, 0, len(triggers))
+	for _, trigger := range triggers {
+		result = append(result, string(trigger))
+	}
+	return result
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+func (r *RepositoryImpl) ListIntegrations(ctx context.Context, userId int) ([]Integration, error) {
+	query := `SELECT ` + integrationColumns + ` FROM chat_notification WHERE user_id = $1 ORDER BY id`
+	return r.queryIntegrations(ctx, query, userId)
+}
+
+func (r *RepositoryImpl) ListIntegrationsWithTrigger(ctx context.Context, trigger Trigger) ([]Integration, error) {
+	query := `SELECT ` + integrationColumns + ` FROM chat_notification
+			  WHERE enabled AND $1 = ANY (triggers)
+			  ORDER BY user_id, id`
+	return r.queryIntegrations(ctx, query, string(trigger))
+}
+
+func (r *RepositoryImpl) queryIntegrations(ctx context.Context, query string, args ...any) ([]Integration, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chat notification integrations: %w", err)
+	}
+	defer rows.Close()
+
+	integrations := make([]Integration, 0)
+	for rows.Next() {
+		integration, err := scanIntegration(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan chat notification integration: %w", err)
+		}
+		integrations = append(integrations, integration)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list chat notification integrations: %w", err)
+	}
+	return integrations, nil
+}
+
+func (r *RepositoryImpl) GetIntegration(ctx context.Context, userId int, id int) (Integration, error) {
+	query := `SELECT ` + integrationColumns + ` FROM chat_notification WHERE id = $1 AND user_id = $2`
+	integration, err := scanIntegration(r.db.QueryRow(ctx, query, id, userId))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Integration{}, ErrIntegrationNotFound
+		}
+		return Integration{}, fmt.Errorf("failed to get chat notification integration: %w", err)
+	}
+	return integration, nil
+}
+
+func (r *RepositoryImpl) CreateIntegration(ctx context.Context, integration Integration) (Integration, error) {
+	query := `INSERT INTO chat_notification (user_id, provider, webhook_url, triggers, enabled)
+			  VALUES ($1, $2, $3, $4, $5)
+			  RETURNING ` + integrationColumns
+	created, err := scanIntegration(r.db.QueryRow(ctx, query, integration.UserId, integration.Provider,
+		integration.WebhookUrl, triggersToStrings(integration.Triggers), integration.Enabled))
+	if err != nil {
+		return Integration{}, fmt.Errorf("failed to create chat notification integration: %w", err)
+	}
+	return created, nil
+}
+
+func (r *RepositoryImpl) UpdateIntegration(ctx context.Context, integration Integration) (Integration, error) {
+	query := `UPDATE chat_notification SET provider = $1, webhook_url = $2, triggers = $3, enabled = $4
+			  WHERE id = $5 AND user_id = $6
+			  RETURNING ` + integrationColumns
+	updated, err := scanIntegration(r.db.QueryRow(ctx, query, integration.Provider, integration.WebhookUrl,
+		triggersToStrings(integration.Triggers), integration.Enabled, integration.Id, integration.UserId))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Integration{}, ErrIntegrationNotFound
+		}
+		return Integration{}, fmt.Errorf("failed to update chat notification integration: %w", err)
+	}
+	return updated, nil
+}
+
+func (r *RepositoryImpl) DeleteIntegration(ctx context.Context, userId int, id int) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM chat_notification WHERE id = $1 AND user_id = $2`, id, userId)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete chat notification integration: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *RepositoryImpl) MarkSent(ctx context.Context, integrationId int, key string) (bool, error) {
+	query := `INSERT INTO chat_notification_sent (integration_id, key) VALUES ($1, $2)
+			  ON CONFLICT (integration_id, key) DO NOTHING`
+	tag, err := r.db.Exec(ctx, query, integrationId, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark chat notification as sent: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,101 @@
+package chat_notification
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+type RepositoryStub struct {
+	mu           sync.RWMutex
+	integrations map[int]Integration
+	sent         map[int]map[string]bool
+	nextId       int
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{
+		integrations: make(map[int]Integration),
+		sent:         make(map[int]map[string]bool),
+		nextId:       1,
+	}
+}
+
+func (r *RepositoryStub) ListIntegrations(ctx context.Context, userId int) ([]Integration, error) {
+	return r.filter(func(integration Integration) bool { return integration.UserId == userId }), nil
+}
+
+func (r *RepositoryStub) ListIntegrationsWithTrigger(ctx context.Context, trigger Trigger) ([]Integration, error) {
+	return r.filter(func(integration Integration) bool { return integration.Fires(trigger) }), nil
+}
+
+func (r *RepositoryStub) filter(keep func(Integration) bool) []Integration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	integrations := make([]Integration, 0)
+	for _, integration := range r.integrations {
+		if keep(integration) {
+			integrations = append(integrations, integration)
+		}
+	}
+	slices.SortFunc(integrations, func(a, b Integration) int { return a.Id - b.Id })
+	return integrations
+}
+
+func (r *RepositoryStub) GetIntegration(ctx context.Context, userId int, id int) (Integration, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	integration, ok := r.integrations[id]
+	if !ok || integration.UserId != userId {
+		return Integration{}, ErrIntegrationNotFound
+	}
+	return integration, nil
+}
+
+func (r *RepositoryStub) CreateIntegration(ctx context.Context, integration Integration) (Integration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	integration.Id = r.nextId
+	integration.CreatedAt = time.Now()
+	r.nextId++
+	r.integrations[integration.Id] = integration
+	return integration, nil
+}
+
+func (r *RepositoryStub) UpdateIntegration(ctx context.Context, integration Integration) (Integration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing, ok := r.integrations[integration.Id]
+	if !ok || existing.UserId != integration.UserId {
+		return Integration{}, ErrIntegrationNotFound
+	}
+	integration.CreatedAt = existing.CreatedAt
+	r.integrations[integration.Id] = integration
+	return integration, nil
+}
+
+func (r *RepositoryStub) DeleteIntegration(ctx context.Context, userId int, id int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	integration, ok := r.integrations[id]
+	if !ok || integration.UserId != userId {
+		return false, nil
+	}
+	delete(r.integrations, id)
+	delete(r.sent, id)
+	return true, nil
+}
+
+func (r *RepositoryStub) MarkSent(ctx context.Context, integrationId int, key string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sent[integrationId] == nil {
+		r.sent[integrationId] = make(map[string]bool)
+	}
+	if r.sent[integrationId][key] {
+		return false, nil
+	}
+	r.sent[integrationId][key] = true
+	return true, nil
+}
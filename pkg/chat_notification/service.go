@@ -0,0 +1,416 @@
+package chat_notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/stats"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrInvalidIntegration = errors.New("invalid chat notification integration")
+	ErrPostFailed         = errors.New("failed to post chat notification")
+)
+
+type Service interface {
+	ListIntegrations(ctx context.Context) ([]Integration, error)
+	CreateIntegration(ctx context.Context, integration Integration) (Integration, error)
+	UpdateIntegration(ctx context.Context, integration Integration) (Integration, error)
+	DeleteIntegration(ctx context.Context, id int) error
+	// SendTest posts a test message to the integration, regardless of its triggers and enabled state.
+	SendTest(ctx context.Context, id int) error
+	// SendWeekSummaries posts the summary of the previous week to the integrations of users whose week starts
+	// on the day of now, once per week.
+	SendWeekSummaries(ctx context.Context, now time.Time) error
+}
+
+type usersProvider interface {
+	GetUser(ctx context.Context, id int) (user.User, error)
+}
+
+type weeklyStatsProvider interface {
+	GetWeeklyStats(ctx context.Context, weekTime time.Time, includeSandbox bool) (stats.WeeklyStatsSummary, error)
+}
+
+type ServiceImpl struct {
+	repo       Repository
+	users      usersProvider
+	stats      weeklyStatsProvider
+	httpClient *http.Client
+	// pending tracks notifications posted in the background of event handlers
+	pending sync.WaitGroup
+}
+
+// NewService creates the service posting with the httpClient, which has to refuse non-public addresses, see
+// outbound.Registry.PublicClient.
+func NewService(repo Repository, users usersProvider, stats weeklyStatsProvider, httpClient *http.Client,
+	eventBus *event_bus.EventBus) *ServiceImpl {
+	service := &ServiceImpl{
+		repo:       repo,
+		users:      users,
+		stats:      stats,
+		httpClient: httpClient,
+	}
+	service.subscribe(eventBus)
+	eventBus.OnClose(service.flush)
+	return service
+}
+
+func (s *ServiceImpl) subscribe(eventBus *event_bus.EventBus) {
+	if eventBus == nil {
+		return
+	}
+	event_bus.SubscribeTyped[event_bus.CurrentEventStarted](
+		eventBus,
+		"current_event.started",
+		func(e event_bus.EventT[event_bus.CurrentEventStarted]) error {
+			s.inBackground(e.Context(), func(ctx context.Context) error {
+				return s.notifyUser(ctx, TriggerEventStarted, "", fmt.Sprintf("Started tracking %s", e.Data.Name))
+			})
+			return nil
+		},
+	)
+	event_bus.SubscribeTyped[event_bus.CalendarEventCreated](
+		eventBus,
+		"calendar.event.created",
+		func(e event_bus.EventT[event_bus.CalendarEventCreated]) error {
+			if e.Data.Sandbox {
+				return nil
+			}
+			s.inBackground(e.Context(), func(ctx context.Context) error {
+				return s.checkBudgets(ctx, e.Data.StartTime)
+			})
+			return nil
+		},
+	)
+	// The alert service records each crossed threshold once, so no key is needed
+	event_bus.SubscribeTyped[event_bus.BudgetAlertTriggered](
+		eventBus,
+		"budget_alert.triggered",
+		func(e event_bus.EventT[event_bus.BudgetAlertTriggered]) error {
+			s.inBackground(e.Context(), func(ctx context.Context) error {
+				currentUser, err := user.CurrentUser(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to get current user: %w", err)
+				}
+				formatter := user.NewFormatter(currentUser.Settings)
+				text := fmt.Sprintf("%s reached %d%% of its budget in %s: %s tracked of %s planned", e.Data.Name,
+					e.Data.Threshold, e.Data.Week, formatter.Duration(e.Data.Tracked), formatter.Duration(e.Data.Planned))
+				return s.notifyUser(ctx, TriggerBudgetAlert, "", text)
+			})
+			return nil
+		},
+	)
+	// Stale integrations are detected by a background job, the user is only known from the event
+	event_bus.SubscribeTyped[event_bus.IntegrationStale](
+		eventBus,
+		"integration.stale",
+		func(e event_bus.EventT[event_bus.IntegrationStale]) error {
+			text := fmt.Sprintf("The %s integration has not been authorized since %s, its sync will be disabled on %s "+
+				"unless you connect it again", e.Data.Provider, e.Data.InvalidSince.Format(time.DateOnly),
+				e.Data.DisableAt.Format(time.DateOnly))
+			key := fmt.Sprintf("%s:%s:%d", TriggerIntegrationStale, e.Data.Provider, e.Data.InvalidSince.Unix())
+			s.inBackground(e.Context(), func(ctx context.Context) error {
+				return s.notify(ctx, e.Data.UserId, TriggerIntegrationStale, key, text)
+			})
+			return nil
+		},
+	)
+}
+
+// inBackground runs the notification without holding up the event publisher, e.g. the request starting an event.
+func (s *ServiceImpl) inBackground(ctx context.Context, notify func(ctx context.Context) error) {
+	ctx = context.WithoutCancel(ctx)
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		if err := notify(ctx); err != nil {
+			log.Errorf("failed to send chat notification: %v", err)
+		}
+	}()
+}
+
+// flush waits for the notifications posted in the background.
+func (s *ServiceImpl) flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("chat notifications still being sent: %w", ctx.Err())
+	}
+}
+
+// notifyUser posts the text to the current user's integrations firing for the trigger. When key is set, the text
+// is posted to each integration only once.
+func (s *ServiceImpl) notifyUser(ctx context.Context, trigger Trigger, key string, text string) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user id: %w", err)
+	}
+	return s.notify(ctx, userId, trigger, key, text)
+}
+
+// notify posts the text to the user's integrations firing for the trigger, see notifyUser.
+func (s *ServiceImpl) notify(ctx context.Context, userId int, trigger Trigger, key string, text string) error {
+	integrations, err := s.repo.ListIntegrations(ctx, userId)
+	if err != nil {
+		return err
+	}
+	for _, integration := range integrations {
+		if !integration.Fires(trigger) {
+			continue
+		}
+		s.postOnce(ctx, integration, key, text)
+	}
+	return nil
+}
+
+func (s *ServiceImpl) postOnce(ctx context.Context, integration Integration, key string, text string) {
+	if key != "" {
+		first, err := s.repo.MarkSent(ctx, integration.Id, key)
+		if err != nil {
+			log.Errorf("failed to record chat notification %s of integration %d: %v", key, integration.Id, err)
+			return
+		}
+		if !first {
+			return
+		}
+	}
+	if err := s.post(ctx, integration, text); err != nil {
+		log.Warnf("chat notification to integration %d of user %d not sent: %v", integration.Id, integration.UserId, err)
+	}
+}
+
+// checkBudgets notifies about the items of the week containing weekTime whose tracked time exceeds the budget.
+func (s *ServiceImpl) checkBudgets(ctx context.Context, weekTime time.Time) error {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	location, err := time.LoadLocation(currentUser.Settings.Timezone)
+	if err != nil {
+		return fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	weekTime = weekTime.In(location)
+	summary, err := s.stats.GetWeeklyStats(ctx, weekTime, false)
+	if err != nil {
+		return fmt.Errorf("failed to get weekly stats: %w", err)
+	}
+	week := weekly_plan.WeekNumberFromDate(weekTime, currentUser.Settings.WeekFirstDay)
+	formatter := user.NewFormatter(currentUser.Settings)
+	for _, item := range summary.PerPlanItem {
+		// Only time budgets can be exceeded, ad hoc items have no budget item to tell them apart
+		if item.PlanItem.Unit == budget_plan.UnitSessions || item.PlanItem.BudgetItemId == 0 ||
+			item.PlanItem.WeeklyItemDuration <= 0 || item.Duration <= item.PlanItem.WeeklyItemDuration {
+			continue
+		}
+		text := fmt.Sprintf("Weekly budget of %s exceeded: %s tracked of %s planned in %s", item.PlanItem.Name,
+			formatter.Duration(item.Duration), formatter.Duration(item.PlanItem.WeeklyItemDuration), week)
+		key := fmt.Sprintf("%s:%s:%d", TriggerBudgetExceeded, week, item.PlanItem.BudgetItemId)
+		if err := s.notifyUser(ctx, TriggerBudgetExceeded, key, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ServiceImpl) SendWeekSummaries(ctx context.Context, now time.Time) error {
+	integrations, err := s.repo.ListIntegrationsWithTrigger(ctx, TriggerWeekSummary)
+	if err != nil {
+		return err
+	}
+	perUser := make(map[int][]Integration)
+	for _, integration := range integrations {
+		perUser[integration.UserId] = append(perUser[integration.UserId], integration)
+	}
+	for userId, userIntegrations := range perUser {
+		if err := s.sendWeekSummary(ctx, userId, userIntegrations, now); err != nil {
+			log.Errorf("failed to send week summary of user %d: %v", userId, err)
+		}
+	}
+	return nil
+}
+
+func (s *ServiceImpl) sendWeekSummary(ctx context.Context, userId int, integrations []Integration, now time.Time) error {
+	u, err := s.users.GetUser(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if u.Disabled {
+		return nil
+	}
+	location, err := time.LoadLocation(u.Settings.Timezone)
+	if err != nil {
+		return fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	localNow := now.In(location)
+	if localNow.Weekday() != u.Settings.WeekFirstDay {
+		return nil
+	}
+	weekDate := localNow.AddDate(0, 0, -7)
+	week := weekly_plan.WeekNumberFromDate(weekDate, u.Settings.WeekFirstDay)
+	key := fmt.Sprintf("%s:%s", TriggerWeekSummary, week)
+
+	var text string
+	for _, integration := range integrations {
+		first, err := s.repo.MarkSent(ctx, integration.Id, key)
+		if err != nil {
+			return err
+		}
+		if !first {
+			continue
+		}
+		if text == "" {
+			summary, err := s.stats.GetWeeklyStats(user.WithUser(ctx, u), weekDate, false)
+			if err != nil {
+				return fmt.Errorf("failed to get weekly stats: %w", err)
+			}
+			text = weekSummaryText(week, summary, user.NewFormatter(u.Settings))
+		}
+		if err := s.post(ctx, integration, text); err != nil {
+			log.Warnf("week summary to integration %d of user %d not sent: %v", integration.Id, userId, err)
+		}
+	}
+	return nil
+}
+
+func weekSummaryText(week weekly_plan.WeekNumber, summary stats.WeeklyStatsSummary, formatter user.Formatter) string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "Summary of week %s: %s tracked of %s planned (%.0f%%)", week,
+		formatter.Duration(summary.TotalTime), formatter.Duration(summary.TotalPlanned), summary.TotalPercentage())
+	for _, item := range summary.PerPlanItem {
+		// Time of sub-items is already included in their parents
+		if item.PlanItem.ParentBudgetItemId != 0 {
+			continue
+		}
+		fmt.Fprintf(&text, "\n- %s: %s of %s (%.0f%%)", item.PlanItem.Name, formatter.Duration(item.Duration),
+			formatter.Duration(item.PlanItem.WeeklyItemDuration), item.Percentage())
+	}
+	return text.String()
+}
+
+// post sends the text to the incoming webhook, Slack expects it in "text" and Discord in "content".
+func (s *ServiceImpl) post(ctx context.Context, integration Integration, text string) error {
+	field := "text"
+	if integration.Provider == ProviderDiscord {
+		field = "content"
+	}
+	body, err := json.Marshal(map[string]string{field: text})
+	if err != nil {
+		return fmt.Errorf("failed to marshal chat notification: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, integration.WebhookUrl, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPostFailed, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPostFailed, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	// Only the status is reported, the body of whatever answered must not be shown to the user
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%w: unexpected response status %d", ErrPostFailed, resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *ServiceImpl) ListIntegrations(ctx context.Context) ([]Integration, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user id: %w", err)
+	}
+	return s.repo.ListIntegrations(ctx, userId)
+}
+
+func (s *ServiceImpl) CreateIntegration(ctx context.Context, integration Integration) (Integration, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Integration{}, fmt.Errorf("failed to get current user id: %w", err)
+	}
+	if err := validate(integration); err != nil {
+		return Integration{}, err
+	}
+	integration.UserId = userId
+	return s.repo.CreateIntegration(ctx, integration)
+}
+
+func (s *ServiceImpl) UpdateIntegration(ctx context.Context, integration Integration) (Integration, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Integration{}, fmt.Errorf("failed to get current user id: %w", err)
+	}
+	if err := validate(integration); err != nil {
+		return Integration{}, err
+	}
+	integration.UserId = userId
+	return s.repo.UpdateIntegration(ctx, integration)
+}
+
+func (s *ServiceImpl) DeleteIntegration(ctx context.Context, id int) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user id: %w", err)
+	}
+	deleted, err := s.repo.DeleteIntegration(ctx, userId, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrIntegrationNotFound
+	}
+	return nil
+}
+
+func (s *ServiceImpl) SendTest(ctx context.Context, id int) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user id: %w", err)
+	}
+	integration, err := s.repo.GetIntegration(ctx, userId, id)
+	if err != nil {
+		return err
+	}
+	return s.post(ctx, integration, "Klokku notifications are set up")
+}
+
+func validate(integration Integration) error {
+	if !slices.Contains(Providers, integration.Provider) {
+		return fmt.Errorf("%w: unknown provider %q", ErrInvalidIntegration, integration.Provider)
+	}
+	webhookUrl, err := url.Parse(integration.WebhookUrl)
+	if err != nil || webhookUrl.Scheme != "https" || webhookUrl.Host == "" {
+		return fmt.Errorf("%w: webhook URL must be an absolute https URL", ErrInvalidIntegration)
+	}
+	if len(integration.Triggers) == 0 {
+		return fmt.Errorf("%w: at least one trigger is required", ErrInvalidIntegration)
+	}
+	for _, trigger := range integration.Triggers {
+		if !slices.Contains(Triggers, trigger) {
+			return fmt.Errorf("%w: unknown trigger %q", ErrInvalidIntegration, trigger)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package chat_notification
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/outbound"
+	"github.com/klokku/klokku/pkg/stats"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Monday
+var now = time.Date(2025, 6, 9, 9, 0, 0, 0, time.UTC)
+
+var testUser = user.User{
+	Id:       1,
+	Uid:      "user-1",
+	Username: "test-user-1",
+	Settings: user.Settings{Timezone: "UTC", WeekFirstDay: time.Monday},
+}
+
+// receiver records the posted bodies and responds with status.
+type receiver struct {
+	mu     sync.Mutex
+	bodies []map[string]string
+	status int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var message map[string]string
+	_ = json.Unmarshal(body, &message)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.bodies = append(rc.bodies, message)
+	if rc.status != 0 {
+		w.WriteHeader(rc.status)
+		_, _ = w.Write([]byte("internal response"))
+	}
+}
+
+func (rc *receiver) received() []map[string]string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]map[string]string(nil), rc.bodies...)
+}
+
+type usersStub map[int]user.User
+
+func (u usersStub) GetUser(ctx context.Context, id int) (user.User, error) {
+	return u[id], nil
+}
+
+type statsStub struct {
+	summary   stats.WeeklyStatsSummary
+	weekTimes []time.Time
+}
+
+func (s *statsStub) GetWeeklyStats(ctx context.Context, weekTime time.Time, includeSandbox bool) (stats.WeeklyStatsSummary, error) {
+	s.weekTimes = append(s.weekTimes, weekTime)
+	return s.summary, nil
+}
+
+type testEnv struct {
+	service  *ServiceImpl
+	repo     *RepositoryStub
+	stats    *statsStub
+	eventBus *event_bus.EventBus
+	receiver *receiver
+	server   *httptest.Server
+	ctx      context.Context
+}
+
+func setupServiceTest(t *testing.T) testEnv {
+	t.Helper()
+	repo := NewRepositoryStub()
+	statsProvider := &statsStub{}
+	eventBus := event_bus.NewEventBus()
+	rc := &receiver{}
+	server := httptest.NewTLSServer(rc)
+	t.Cleanup(server.Close)
+	service := NewService(repo, usersStub{testUser.Id: testUser}, statsProvider, server.Client(), eventBus)
+	return testEnv{
+		service:  service,
+		repo:     repo,
+		stats:    statsProvider,
+		eventBus: eventBus,
+		receiver: rc,
+		server:   server,
+		ctx:      user.WithUser(context.Background(), testUser),
+	}
+}
+
+func (env testEnv) createIntegration(t *testing.T, provider Provider, triggers ...Trigger) Integration {
+	t.Helper()
+	integration, err := env.service.CreateIntegration(env.ctx, Integration{
+		Provider:   provider,
+		WebhookUrl: env.server.URL,
+		Triggers:   triggers,
+		Enabled:    true,
+	})
+	require.NoError(t, err)
+	return integration
+}
+
+func exceededSummary() stats.WeeklyStatsSummary {
+	return stats.WeeklyStatsSummary{
+		PerPlanItem: []stats.PlanItemStats{
+			{
+				PlanItem: stats.PlanItem{BudgetItemId: 10, Name: "Reading", WeeklyItemDuration: 2 * time.Hour},
+				Duration: 2*time.Hour + 30*time.Minute,
+			},
+			{
+				PlanItem: stats.PlanItem{BudgetItemId: 11, Name: "Work", WeeklyItemDuration: 40 * time.Hour},
+				Duration: 10 * time.Hour,
+			},
+		},
+		TotalPlanned: 42 * time.Hour,
+		TotalTime:    12*time.Hour + 30*time.Minute,
+	}
+}
+
+func TestCreateIntegration_Validation(t *testing.T) {
+	env := setupServiceTest(t)
+	for name, integration := range map[string]Integration{
+		"unknown provider": {Provider: "teams", WebhookUrl: env.server.URL, Triggers: []Trigger{TriggerWeekSummary}},
+		"plain http":       {Provider: ProviderSlack, WebhookUrl: "http://hooks.example.com/1", Triggers: []Trigger{TriggerWeekSummary}},
+		"relative url":     {Provider: ProviderSlack, WebhookUrl: "/hooks/1", Triggers: []Trigger{TriggerWeekSummary}},
+		"no triggers":      {Provider: ProviderSlack, WebhookUrl: env.server.URL},
+		"unknown trigger":  {Provider: ProviderSlack, WebhookUrl: env.server.URL, Triggers: []Trigger{"event_deleted"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := env.service.CreateIntegration(env.ctx, integration)
+			assert.ErrorIs(t, err, ErrInvalidIntegration)
+		})
+	}
+}
+
+func TestEventStarted_PostsToFiringIntegrations(t *testing.T) {
+	env := setupServiceTest(t)
+	env.createIntegration(t, ProviderSlack, TriggerEventStarted)
+	env.createIntegration(t, ProviderDiscord, TriggerEventStarted, TriggerWeekSummary)
+	env.createIntegration(t, ProviderSlack, TriggerWeekSummary)
+
+	err := env.eventBus.Publish(event_bus.NewEvent(env.ctx, "current_event.started", event_bus.CurrentEventStarted{
+		BudgetItemId: 10,
+		Name:         "Reading",
+		StartTime:    now,
+	}))
+	require.NoError(t, err)
+	env.service.pending.Wait()
+
+	assert.ElementsMatch(t, []map[string]string{
+		{"text": "Started tracking Reading"},
+		{"content": "Started tracking Reading"},
+	}, env.receiver.received())
+}
+
+func TestEventStarted_SkipsDisabledIntegrations(t *testing.T) {
+	env := setupServiceTest(t)
+	integration := env.createIntegration(t, ProviderSlack, TriggerEventStarted)
+	integration.Enabled = false
+	_, err := env.service.UpdateIntegration(env.ctx, integration)
+	require.NoError(t, err)
+
+	err = env.eventBus.Publish(event_bus.NewEvent(env.ctx, "current_event.started", event_bus.CurrentEventStarted{
+		Name: "Reading",
+	}))
+	require.NoError(t, err)
+	env.service.pending.Wait()
+
+	assert.Empty(t, env.receiver.received())
+}
+
+func TestBudgetExceeded_PostsOncePerItemAndWeek(t *testing.T) {
+	env := setupServiceTest(t)
+	env.createIntegration(t, ProviderSlack, TriggerBudgetExceeded)
+	env.stats.summary = exceededSummary()
+
+	for range 2 {
+		err := env.eventBus.Publish(event_bus.NewEvent(env.ctx, "calendar.event.created", event_bus.CalendarEventCreated{
+			StartTime:    now,
+			EndTime:      now.Add(time.Hour),
+			BudgetItemId: 10,
+		}))
+		require.NoError(t, err)
+		env.service.pending.Wait()
+	}
+
+	assert.Equal(t, []map[string]string{
+		{"text": "Weekly budget of Reading exceeded: 2:30 tracked of 2:00 planned in 2025-W24"},
+	}, env.receiver.received())
+}
+
+func TestBudgetExceeded_IgnoresSandboxEvents(t *testing.T) {
+	env := setupServiceTest(t)
+	env.createIntegration(t, ProviderSlack, TriggerBudgetExceeded)
+	env.stats.summary = exceededSummary()
+
+	err := env.eventBus.Publish(event_bus.NewEvent(env.ctx, "calendar.event.created", event_bus.CalendarEventCreated{
+		StartTime: now,
+		Sandbox:   true,
+	}))
+	require.NoError(t, err)
+	env.service.pending.Wait()
+
+	assert.Empty(t, env.receiver.received())
+	assert.Empty(t, env.stats.weekTimes)
+}
+
+func TestBudgetAlert_PostsTriggeredAlerts(t *testing.T) {
+	env := setupServiceTest(t)
+	env.createIntegration(t, ProviderSlack, TriggerBudgetAlert)
+	env.createIntegration(t, ProviderSlack, TriggerBudgetExceeded)
+
+	err := env.eventBus.Publish(event_bus.NewEvent(env.ctx, "budget_alert.triggered", event_bus.BudgetAlertTriggered{
+		BudgetItemId: 10,
+		Name:         "Reading",
+		Week:         "2025-W24",
+		Threshold:    80,
+		Percentage:   85,
+		Tracked:      102 * time.Minute,
+		Planned:      2 * time.Hour,
+	}))
+	require.NoError(t, err)
+	env.service.pending.Wait()
+
+	assert.Equal(t, []map[string]string{
+		{"text": "Reading reached 80% of its budget in 2025-W24: 1:42 tracked of 2:00 planned"},
+	}, env.receiver.received())
+}
+
+func TestIntegrationStale_NotifiesTheIntegrationOwner(t *testing.T) {
+	env := setupServiceTest(t)
+	env.createIntegration(t, ProviderSlack, TriggerIntegrationStale)
+
+	// published by a background job, without a user in the context
+	err := env.eventBus.Publish(event_bus.NewEvent(context.Background(), "integration.stale", event_bus.IntegrationStale{
+		UserId:       testUser.Id,
+		Provider:     "clickup",
+		InvalidSince: now.AddDate(0, 0, -7),
+		DisableAt:    now.AddDate(0, 0, 3),
+	}))
+	require.NoError(t, err)
+	env.service.pending.Wait()
+
+	assert.Equal(t, []map[string]string{
+		{"text": "The clickup integration has not been authorized since 2025-06-02, its sync will be disabled on " +
+			"2025-06-12 unless you connect it again"},
+	}, env.receiver.received())
+}
+
+func TestSendWeekSummaries(t *testing.T) {
+	env := setupServiceTest(t)
+	env.createIntegration(t, ProviderDiscord, TriggerWeekSummary)
+	env.createIntegration(t, ProviderSlack, TriggerEventStarted)
+	env.stats.summary = exceededSummary()
+
+	require.NoError(t, env.service.SendWeekSummaries(context.Background(), now))
+	// Sent only once a week
+	require.NoError(t, env.service.SendWeekSummaries(context.Background(), now.Add(time.Hour)))
+
+	assert.Equal(t, []map[string]string{
+		{"content": "Summary of week 2025-W23: 12:30 tracked of 42:00 planned (30%)\n" +
+			"- Reading: 2:30 of 2:00 (125%)\n" +
+			"- Work: 10:00 of 40:00 (25%)"},
+	}, env.receiver.received())
+	require.Len(t, env.stats.weekTimes, 1)
+	assert.Equal(t, now.AddDate(0, 0, -7), env.stats.weekTimes[0])
+}
+
+func TestSendWeekSummaries_UsesTheUsersDurationFormat(t *testing.T) {
+	env := setupServiceTest(t)
+	decimalUser := testUser
+	decimalUser.Settings.Locale = "pl-PL"
+	decimalUser.Settings.DurationFormat = user.DurationDecimalHours
+	env.service.users = usersStub{testUser.Id: decimalUser}
+	env.createIntegration(t, ProviderDiscord, TriggerWeekSummary)
+	env.stats.summary = exceededSummary()
+
+	require.NoError(t, env.service.SendWeekSummaries(context.Background(), now))
+
+	assert.Equal(t, []map[string]string{
+		{"content": "Summary of week 2025-W23: 12,50 tracked of 42,00 planned (30%)\n" +
+			"- Reading: 2,50 of 2,00 (125%)\n" +
+			"- Work: 10,00 of 40,00 (25%)"},
+	}, env.receiver.received())
+}
+
+func TestSendWeekSummaries_OnlyOnFirstDayOfWeek(t *testing.T) {
+	env := setupServiceTest(t)
+	env.createIntegration(t, ProviderSlack, TriggerWeekSummary)
+
+	require.NoError(t, env.service.SendWeekSummaries(context.Background(), now.AddDate(0, 0, 1)))
+
+	assert.Empty(t, env.receiver.received())
+}
+
+func TestSendTest_ReportsRejectedMessage(t *testing.T) {
+	env := setupServiceTest(t)
+	integration := env.createIntegration(t, ProviderSlack, TriggerWeekSummary)
+	env.receiver.status = http.StatusNotFound
+
+	err := env.service.SendTest(env.ctx, integration.Id)
+
+	assert.ErrorIs(t, err, ErrPostFailed)
+	assert.ErrorContains(t, err, "404")
+	assert.NotContains(t, err.Error(), "internal response")
+	assert.ErrorIs(t, env.service.SendTest(env.ctx, integration.Id+1), ErrIntegrationNotFound)
+}
+
+func TestSendTest_RefusesLocalAddresses(t *testing.T) {
+	env := setupServiceTest(t)
+	integration := env.createIntegration(t, ProviderSlack, TriggerWeekSummary)
+	env.service.httpClient = outbound.NewRegistry().PublicClient("chat", outbound.DefaultPolicy)
+
+	err := env.service.SendTest(env.ctx, integration.Id)
+
+	assert.ErrorIs(t, err, ErrPostFailed)
+	assert.Empty(t, env.receiver.received())
+}
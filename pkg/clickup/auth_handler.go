@@ -2,19 +2,18 @@ package clickup
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/klokku/klokku/internal/config"
 	"github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/pkg/credentials"
 	"github.com/klokku/klokku/pkg/user"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/oauth2"
@@ -35,9 +34,13 @@ type ClickUpAuth struct {
 	db          *pgxpool.Pool
 	userService user.Service
 	oauthConfig *oauth2.Config
+	// httpClient sends the requests to ClickUp, the OAuth client authorizes them on top of it
+	httpClient  *http.Client
+	credentials credentials.Service
 }
 
-func NewClickUpAuth(db *pgxpool.Pool, userService user.Service, cfg config.Application) *ClickUpAuth {
+func NewClickUpAuth(db *pgxpool.Pool, userService user.Service, cfg config.Application, httpClient *http.Client,
+	credentialsService credentials.Service) *ClickUpAuth {
 	oauthConfig := &oauth2.Config{
 		ClientID:     cfg.ClickUp.ClientId,
 		ClientSecret: cfg.ClickUp.ClientSecret,
@@ -45,7 +48,13 @@ func NewClickUpAuth(db *pgxpool.Pool, userService user.Service, cfg config.Appli
 		RedirectURL:  cfg.Host + "/api/integrations/clickup/auth/callback",
 	}
 
-	return &ClickUpAuth{db: db, userService: userService, oauthConfig: oauthConfig}
+	return &ClickUpAuth{
+		db:          db,
+		userService: userService,
+		oauthConfig: oauthConfig,
+		httpClient:  httpClient,
+		credentials: credentialsService,
+	}
 }
 
 // OAuthLogin godoc
@@ -69,9 +78,10 @@ func (g *ClickUpAuth) OAuthLogin(w http.ResponseWriter, r *http.Request) {
 	}
 	userId := currentUser.Id
 
-	_, err = g.db.Exec(r.Context(), "DELETE FROM clickup_auth WHERE user_id = $1", userId)
+	// The old row is removed with its invalid and disabled state, so the new token starts fresh
+	err = g.credentials.Revoke(r.Context(), credentials.ClickUp, userId)
 	if err != nil {
-		log.Errorf("failed to delete old ClickUp auth row for user %d: %v", userId, err)
+		log.Errorf("failed to revoke old ClickUp token for user %d: %v", userId, err)
 		w.WriteHeader(http.StatusInternalServerError)
 		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
 			Error: "Failed to handle ClickUp authentication",
@@ -85,8 +95,7 @@ func (g *ClickUpAuth) OAuthLogin(w http.ResponseWriter, r *http.Request) {
 	stateNonce := uuid.New().String()
 	finalUrl := r.URL.Query().Get("finalUrl")
 
-	// store nonce for the use in the DB
-	_, err = g.db.Exec(r.Context(), "INSERT INTO clickup_auth (user_id, nonce) VALUES ($1, $2)", userId, stateNonce)
+	err = g.credentials.StartAuthentication(r.Context(), credentials.ClickUp, userId, stateNonce)
 	if err != nil {
 		log.Errorf("failed to store ClickUp auth nonce for user %d: %v", userId, err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -128,7 +137,7 @@ func (g *ClickUpAuth) OAuthCallback(w http.ResponseWriter, r *http.Request) {
 	finalUrl := parts[0]
 	nonce := parts[1]
 
-	token, err := g.oauthConfig.Exchange(context.Background(), code)
+	token, err := g.oauthConfig.Exchange(context.WithValue(context.Background(), oauth2.HTTPClient, g.httpClient), code)
 	if err != nil {
 		err := fmt.Errorf("unable to exchange code for token: %v", err)
 		log.Error(err)
@@ -136,20 +145,18 @@ func (g *ClickUpAuth) OAuthCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Handle zero expiry time properly
-	var expiryTimestamp *int64
-	if !token.Expiry.IsZero() {
-		expiryTimestamp = new(token.Expiry.Unix())
-	}
-
-	_, err = g.db.Exec(r.Context(), "UPDATE clickup_auth SET access_token = $1, refresh_token = $2, expiry = $3 WHERE nonce = $4",
-		token.AccessToken, token.RefreshToken, expiryTimestamp, nonce)
+	userId, err := g.credentials.StoreTokenByNonce(r.Context(), credentials.ClickUp, nonce, token)
 	if err != nil {
 		err := fmt.Errorf("unable to store ClickUp auth token for nonce: %v", err)
 		log.Error(err)
 		http.Redirect(w, r, finalUrl+"?success=false", http.StatusFound)
 		return
 	}
+	// Mappings deactivated for a stale integration are used again once the user authenticates
+	_, err = g.db.Exec(r.Context(), "UPDATE clickup_tag_mapping SET active = TRUE WHERE user_id = $1", userId)
+	if err != nil {
+		log.Errorf("unable to reactivate ClickUp mappings of user %d: %v", userId, err)
+	}
 	log.Debug("Successfully stored ClickUp auth token for nonce: ", nonce)
 	http.Redirect(w, r, finalUrl+"?success=true", http.StatusFound)
 }
@@ -172,7 +179,7 @@ func (g *ClickUpAuth) IsAuthenticated(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "unable to retrieve current user", http.StatusInternalServerError)
 		return
 	}
-	row := g.db.QueryRow(r.Context(), "SELECT 1 FROM clickup_auth WHERE user_id = $1", userId)
+	row := g.db.QueryRow(r.Context(), "SELECT 1 FROM clickup_auth WHERE user_id = $1 AND disabled_at IS NULL", userId)
 	var isAuthenticated int
 	err = row.Scan(&isAuthenticated)
 	if err != nil && errors.Is(err, pgx.ErrNoRows) {
@@ -188,26 +195,8 @@ func (g *ClickUpAuth) IsAuthenticated(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write([]byte("true"))
 }
 
-func (g *ClickUpAuth) getToken(ctx context.Context, userId int) (*oauth2.Token, error) {
-	var token oauth2.Token
-	var expiryTimestamp sql.NullInt64
-	err := g.db.QueryRow(ctx, "SELECT access_token, refresh_token, expiry FROM clickup_auth WHERE user_id = $1", userId).
-		Scan(&token.AccessToken, &token.RefreshToken, &expiryTimestamp)
-	if err != nil && errors.Is(err, pgx.ErrNoRows) {
-		return nil, nil
-	} else if err != nil {
-		return nil, fmt.Errorf("unable to retrieve ClickUp auth token: %v", err)
-	}
-
-	if expiryTimestamp.Valid {
-		token.Expiry = time.Unix(expiryTimestamp.Int64, 0)
-	}
-	// If expiryTimestamp is not valid, token.Expiry remains zero time which is fine
-	return &token, nil
-}
-
 func (g *ClickUpAuth) getClient(ctx context.Context, userId int) (*http.Client, error) {
-	token, err := g.getToken(ctx, userId)
+	token, err := g.credentials.GetToken(ctx, credentials.ClickUp, userId)
 	if err != nil {
 		log.Error(err)
 		return nil, err
@@ -215,5 +204,22 @@ func (g *ClickUpAuth) getClient(ctx context.Context, userId int) (*http.Client,
 	if token == nil {
 		return nil, nil
 	}
-	return g.oauthConfig.Client(ctx, token), nil
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, g.httpClient)
+	return oauth2.NewClient(ctx, g.credentials.TokenSource(ctx, credentials.ClickUp, userId, g.oauthConfig, token)), nil
+}
+
+// markTokenInvalid records when the user's token was first rejected by ClickUp.
+func (g *ClickUpAuth) markTokenInvalid(ctx context.Context, userId int) {
+	_, err := g.db.Exec(ctx, "UPDATE clickup_auth SET invalid_since = NOW() WHERE user_id = $1 AND invalid_since IS NULL", userId)
+	if err != nil {
+		log.Errorf("unable to mark ClickUp token of user %d as invalid: %v", userId, err)
+	}
+}
+
+func (g *ClickUpAuth) markTokenValid(ctx context.Context, userId int) {
+	_, err := g.db.Exec(ctx,
+		"UPDATE clickup_auth SET invalid_since = NULL, stale_notified_at = NULL WHERE user_id = $1 AND invalid_since IS NOT NULL", userId)
+	if err != nil {
+		log.Errorf("unable to mark ClickUp token of user %d as valid: %v", userId, err)
+	}
 }
@@ -1,21 +1,28 @@
 package clickup
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/klokku/klokku/pkg/user"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
 )
 
 const (
 	baseURL = "https://api.clickup.com/api/v2"
 )
 
-var ErrUnathenticated = fmt.Errorf("user is not authenticated with ClickUp")
+var (
+	ErrUnathenticated    = fmt.Errorf("user is not authenticated with ClickUp")
+	ErrTimeEntryNotFound = errors.New("ClickUp time entry not found")
+)
 
 type Workspace struct {
 	Id   string `json:"id"`
@@ -51,6 +58,14 @@ type Tag struct {
 	BgColor string `json:"tag_bg"`
 }
 
+// TimeEntry is time tracked on a ClickUp task.
+type TimeEntry struct {
+	TaskId      string
+	Description string
+	Start       time.Time
+	End         time.Time
+}
+
 type Client interface {
 	GetAuthorizedWorkspaces(ctx context.Context) ([]Workspace, error)   // /v2/oauth/token
 	GetSpaces(ctx context.Context, workspaceId string) ([]Space, error) // /v2/team/{team_id}/space
@@ -58,6 +73,10 @@ type Client interface {
 	GetFilteredTeamTasks(ctx context.Context, workspaceId string, spaceId string, folderId string, page int, tagName string,
 		withPrioritySetOnly bool) ([]Task, error) // /v2/team/{team_Id}/task
 	GetTags(ctx context.Context, spaceId string) ([]Tag, error) // /v2/space/{space_id}/tag
+	// CreateTimeEntry returns the id of the created time entry
+	CreateTimeEntry(ctx context.Context, workspaceId string, entry TimeEntry) (string, error)           // /v2/team/{team_id}/time_entries
+	UpdateTimeEntry(ctx context.Context, workspaceId string, timeEntryId string, entry TimeEntry) error // /v2/team/{team_id}/time_entries/{timer_id}
+	DeleteTimeEntry(ctx context.Context, workspaceId string, timeEntryId string) error                  // /v2/team/{team_id}/time_entries/{timer_id}
 }
 
 type ClientImpl struct {
@@ -91,6 +110,26 @@ func (s *ClientImpl) prepareClickUpClient(ctx context.Context) (*http.Client, er
 	return client, nil
 }
 
+// do executes the request and keeps track of the validity of the user's token, so integrations
+// with revoked tokens can be detected by the stale integration cleanup.
+func (s *ClientImpl) do(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	userId, userErr := user.CurrentId(ctx)
+	if userErr != nil {
+		return resp, err
+	}
+	var retrieveErr *oauth2.RetrieveError
+	switch {
+	case err != nil && errors.As(err, &retrieveErr):
+		s.auth.markTokenInvalid(ctx, userId)
+	case err == nil && resp.StatusCode == http.StatusUnauthorized:
+		s.auth.markTokenInvalid(ctx, userId)
+	case err == nil && resp.StatusCode == http.StatusOK:
+		s.auth.markTokenValid(ctx, userId)
+	}
+	return resp, err
+}
+
 // GetAuthorizedWorkspaces retrieves the workspaces the user has access to
 func (s *ClientImpl) GetAuthorizedWorkspaces(ctx context.Context) ([]Workspace, error) {
 	client, err := s.prepareClickUpClient(ctx)
@@ -110,7 +149,7 @@ func (s *ClientImpl) GetAuthorizedWorkspaces(ctx context.Context) ([]Workspace,
 		return nil, err
 	}
 
-	resp, err := client.Do(req)
+	resp, err := s.do(ctx, client, req)
 	if err != nil {
 		log.Errorf("Failed to execute request: %v", err)
 		return nil, err
@@ -153,7 +192,7 @@ func (s *ClientImpl) GetSpaces(ctx context.Context, workspaceId string) ([]Space
 		return nil, err
 	}
 
-	resp, err := client.Do(req)
+	resp, err := s.do(ctx, client, req)
 	if err != nil {
 		log.Errorf("Failed to execute request: %v", err)
 		return nil, err
@@ -196,7 +235,7 @@ func (s *ClientImpl) GetFolders(ctx context.Context, spaceId string) ([]Folder,
 		return nil, err
 	}
 
-	resp, err := client.Do(req)
+	resp, err := s.do(ctx, client, req)
 	if err != nil {
 		log.Errorf("Failed to execute request: %v", err)
 		return nil, err
@@ -279,7 +318,7 @@ func (s *ClientImpl) GetFilteredTeamTasks(ctx context.Context, workspaceId strin
 		return nil, err
 	}
 
-	resp, err := client.Do(req)
+	resp, err := s.do(ctx, client, req)
 	if err != nil {
 		log.Errorf("Failed to execute request: %v", err)
 		return nil, err
@@ -332,7 +371,7 @@ func (s *ClientImpl) GetTags(ctx context.Context, spaceId string) ([]Tag, error)
 		return nil, err
 	}
 
-	resp, err := client.Do(req)
+	resp, err := s.do(ctx, client, req)
 	if err != nil {
 		log.Errorf("Failed to execute request: %v", err)
 		return nil, err
@@ -357,3 +396,95 @@ func (s *ClientImpl) GetTags(ctx context.Context, spaceId string) ([]Tag, error)
 
 	return response.Tags, nil
 }
+
+type timeEntryRequest struct {
+	TaskId      string `json:"tid"`
+	Description string `json:"description"`
+	Start       int64  `json:"start"`
+	End         int64  `json:"end"`
+	Duration    int64  `json:"duration"`
+}
+
+func newTimeEntryRequest(entry TimeEntry) timeEntryRequest {
+	return timeEntryRequest{
+		TaskId:      entry.TaskId,
+		Description: entry.Description,
+		Start:       entry.Start.UnixMilli(),
+		End:         entry.End.UnixMilli(),
+		Duration:    entry.End.Sub(entry.Start).Milliseconds(),
+	}
+}
+
+// CreateTimeEntry tracks the time on the task
+func (s *ClientImpl) CreateTimeEntry(ctx context.Context, workspaceId string, entry TimeEntry) (string, error) {
+	url := fmt.Sprintf("%s/team/%s/time_entries", baseURL, workspaceId)
+	var response struct {
+		Data struct {
+			Id json.Number `json:"id"`
+		} `json:"data"`
+	}
+	if err := s.sendTimeEntryRequest(ctx, http.MethodPost, url, newTimeEntryRequest(entry), &response); err != nil {
+		return "", fmt.Errorf("failed to create ClickUp time entry: %w", err)
+	}
+	return response.Data.Id.String(), nil
+}
+
+// UpdateTimeEntry changes the task and time of the time entry
+func (s *ClientImpl) UpdateTimeEntry(ctx context.Context, workspaceId string, timeEntryId string, entry TimeEntry) error {
+	url := fmt.Sprintf("%s/team/%s/time_entries/%s", baseURL, workspaceId, timeEntryId)
+	if err := s.sendTimeEntryRequest(ctx, http.MethodPut, url, newTimeEntryRequest(entry), nil); err != nil {
+		return fmt.Errorf("failed to update ClickUp time entry: %w", err)
+	}
+	return nil
+}
+
+func (s *ClientImpl) DeleteTimeEntry(ctx context.Context, workspaceId string, timeEntryId string) error {
+	url := fmt.Sprintf("%s/team/%s/time_entries/%s", baseURL, workspaceId, timeEntryId)
+	if err := s.sendTimeEntryRequest(ctx, http.MethodDelete, url, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete ClickUp time entry: %w", err)
+	}
+	return nil
+}
+
+// sendTimeEntryRequest sends the body encoded as JSON and decodes the response into result, when not nil
+func (s *ClientImpl) sendTimeEntryRequest(ctx context.Context, method string, url string, body any, result any) error {
+	client, err := s.prepareClickUpClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.do(ctx, client, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return ErrUnathenticated
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrTimeEntryNotFound
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("ClickUp API returned non-OK status: %d", resp.StatusCode)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -3,6 +3,7 @@ package clickup
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 )
 
@@ -18,6 +19,9 @@ type ClientStub struct {
 	getFoldersErr              error
 	getTagsErr                 error
 	getFilteredTeamTasksErr    error
+	timeEntries                map[string]TimeEntry // timeEntryId -> entry
+	nextTimeEntryId            int
+	timeEntryErr               error
 }
 
 type taskKey struct {
@@ -31,10 +35,11 @@ type taskKey struct {
 
 func NewClientStub() *ClientStub {
 	return &ClientStub{
-		spaces:  make(map[string][]Space),
-		folders: make(map[string][]Folder),
-		tags:    make(map[string][]Tag),
-		tasks:   make(map[taskKey][]Task),
+		spaces:      make(map[string][]Space),
+		folders:     make(map[string][]Folder),
+		tags:        make(map[string][]Tag),
+		tasks:       make(map[taskKey][]Task),
+		timeEntries: make(map[string]TimeEntry),
 	}
 }
 
@@ -140,8 +145,63 @@ func (c *ClientStub) GetTags(ctx context.Context, spaceId string) ([]Tag, error)
 	return result, nil
 }
 
+func (c *ClientStub) CreateTimeEntry(ctx context.Context, workspaceId string, entry TimeEntry) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.timeEntryErr != nil {
+		return "", c.timeEntryErr
+	}
+
+	c.nextTimeEntryId++
+	id := fmt.Sprintf("%d", c.nextTimeEntryId)
+	c.timeEntries[id] = entry
+	return id, nil
+}
+
+func (c *ClientStub) UpdateTimeEntry(ctx context.Context, workspaceId string, timeEntryId string, entry TimeEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.timeEntryErr != nil {
+		return c.timeEntryErr
+	}
+	if _, exists := c.timeEntries[timeEntryId]; !exists {
+		return ErrTimeEntryNotFound
+	}
+
+	c.timeEntries[timeEntryId] = entry
+	return nil
+}
+
+func (c *ClientStub) DeleteTimeEntry(ctx context.Context, workspaceId string, timeEntryId string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.timeEntryErr != nil {
+		return c.timeEntryErr
+	}
+	if _, exists := c.timeEntries[timeEntryId]; !exists {
+		return ErrTimeEntryNotFound
+	}
+
+	delete(c.timeEntries, timeEntryId)
+	return nil
+}
+
 // Helper methods for test setup
 
+// TimeEntries returns the time entries stored in ClickUp by id
+func (c *ClientStub) TimeEntries() map[string]TimeEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result := make(map[string]TimeEntry, len(c.timeEntries))
+	for id, entry := range c.timeEntries {
+		result[id] = entry
+	}
+	return result
+}
+
 func (c *ClientStub) SetWorkspaces(workspaces []Workspace) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -227,6 +287,12 @@ func (c *ClientStub) SetGetFilteredTeamTasksError(err error) {
 	c.getFilteredTeamTasksErr = err
 }
 
+func (c *ClientStub) SetTimeEntryError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timeEntryErr = err
+}
+
 // Reset clears all data
 func (c *ClientStub) Reset() {
 	c.mu.Lock()
@@ -242,6 +308,9 @@ func (c *ClientStub) Reset() {
 	c.getFoldersErr = nil
 	c.getTagsErr = nil
 	c.getFilteredTeamTasksErr = nil
+	c.timeEntries = make(map[string]TimeEntry)
+	c.nextTimeEntryId = 0
+	c.timeEntryErr = nil
 }
 
 var ErrClientTestError = errors.New("client test error")
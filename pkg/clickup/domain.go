@@ -1,5 +1,7 @@
 package clickup
 
+import "time"
+
 type Configuration struct {
 	WorkspaceId           string
 	SpaceId               string
@@ -13,4 +15,74 @@ type BudgetItemMapping struct {
 	ClickupTagName string
 	BudgetItemId   int
 	Position       int
+	// Inactive is set when sync was disabled for a stale integration. Inactive mappings are not used
+	// to fetch tasks until the user authenticates again.
+	Inactive bool
+}
+
+type IntegrationState string
+
+const (
+	IntegrationNotConnected IntegrationState = "not_connected"
+	IntegrationActive       IntegrationState = "active"
+	// IntegrationInvalid means the token was rejected by ClickUp, but not for long enough to notify the user.
+	IntegrationInvalid IntegrationState = "invalid"
+	// IntegrationStale means the user was notified and sync will be disabled after the grace period.
+	IntegrationStale    IntegrationState = "stale"
+	IntegrationDisabled IntegrationState = "disabled"
+)
+
+// AuthState tracks the validity of a user's ClickUp token.
+type AuthState struct {
+	UserId          int
+	InvalidSince    *time.Time
+	StaleNotifiedAt *time.Time
+	DisabledAt      *time.Time
+}
+
+type IntegrationStatus struct {
+	State        IntegrationState
+	InvalidSince *time.Time
+	// DisableAt is set for stale integrations.
+	DisableAt *time.Time
+}
+
+type TimeEntryPushOperation string
+
+const (
+	// PushUpsert creates or updates the time entry of the event.
+	PushUpsert TimeEntryPushOperation = "upsert"
+	// PushDelete deletes the time entry of the event, if there is one.
+	PushDelete TimeEntryPushOperation = "delete"
+)
+
+type TimeEntryPushStatus string
+
+const (
+	PushPending   TimeEntryPushStatus = "pending"
+	PushSucceeded TimeEntryPushStatus = "succeeded"
+	PushFailed    TimeEntryPushStatus = "failed"
+)
+
+// TimeEntryPush is an outbox record of a calendar event change to be pushed to ClickUp time tracking.
+type TimeEntryPush struct {
+	Id          int64
+	UserId      int
+	EventUid    string
+	Operation   TimeEntryPushOperation
+	WorkspaceId string
+	Entry       TimeEntry
+	Status      TimeEntryPushStatus
+	// Version is bumped when a newer change of the event replaces the pending push.
+	Version       int
+	Attempts      int
+	NextAttemptAt *time.Time
+	LastError     string
+}
+
+// PushedTimeEntry links a calendar event to the ClickUp time entry created for it.
+type PushedTimeEntry struct {
+	EventUid    string
+	WorkspaceId string
+	TimeEntryId string
 }
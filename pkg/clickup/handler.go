@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -42,6 +43,14 @@ type BudgetMappingDTO struct {
 	ClickUpTagName string `json:"clickUpTagName"`
 	BudgetItemId   int    `json:"budgetItemId"`
 	Position       int    `json:"position"`
+	// Inactive is read-only, mappings are reactivated when the user authenticates again.
+	Inactive bool `json:"inactive,omitempty"`
+}
+
+type IntegrationStatusDTO struct {
+	State        string     `json:"state"`
+	InvalidSince *time.Time `json:"invalidSince,omitempty"`
+	DisableAt    *time.Time `json:"disableAt,omitempty"`
 }
 
 type TaskDTO struct {
@@ -312,6 +321,7 @@ func (h *Handler) GetConfiguration(w http.ResponseWriter, r *http.Request) {
 			ClickUpTagName: mapping.ClickupTagName,
 			BudgetItemId:   mapping.BudgetItemId,
 			Position:       mapping.Position,
+			Inactive:       mapping.Inactive,
 		})
 	}
 
@@ -321,6 +331,34 @@ func (h *Handler) GetConfiguration(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetStatus godoc
+// @Summary Get ClickUp integration status
+// @Description Get the state of the ClickUp integration. An integration whose token has been rejected by ClickUp
+// @Description for too long becomes stale and its sync is disabled at disableAt unless the user authenticates again.
+// @Tags ClickUp
+// @Produce json
+// @Success 200 {object} IntegrationStatusDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/integrations/clickup/status [get]
+// @Security XUserId
+func (h *Handler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	status, err := h.service.GetIntegrationStatus(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(IntegrationStatusDTO{
+		State:        string(status.State),
+		InvalidSince: status.InvalidSince,
+		DisableAt:    status.DisableAt,
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 // DisableIntegration godoc
 // @Summary Disable ClickUp integration
 // @Description Disconnect and disable the ClickUp integration
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -15,7 +16,16 @@ type Repository interface {
 	GetConfigurationWithMappingByBudgetItemId(ctx context.Context, userId, budgetItemId int) (*Configuration, error)
 	DeleteAllConfigurations(ctx context.Context, userId int) error
 	DeleteBudgetPlanConfiguration(ctx context.Context, userId, budgetPlanId int) error
+	// DeleteBudgetItemMapping removes the tag mapping of a budget item, if there is one.
+	DeleteBudgetItemMapping(ctx context.Context, userId, budgetItemId int) error
 	DeleteAuthData(ctx context.Context, userId int) error
+	GetAuthState(ctx context.Context, userId int) (*AuthState, error)
+	// GetStaleAuthStates returns auth states of all users with tokens invalid since invalidBefore or earlier,
+	// skipping integrations that are already disabled.
+	GetStaleAuthStates(ctx context.Context, invalidBefore time.Time) ([]AuthState, error)
+	MarkStaleNotified(ctx context.Context, userId int, notifiedAt time.Time) error
+	// DisableSync disables the user's integration and marks all their mappings inactive.
+	DisableSync(ctx context.Context, userId int, disabledAt time.Time) error
 }
 
 type RepositoryImpl struct {
@@ -103,7 +113,7 @@ func (r *RepositoryImpl) GetConfiguration(ctx context.Context, userId, budgetPla
 
 	// Query for the budget mappings
 	rows, err := r.db.Query(ctx,
-		`SELECT m.clickup_space_id, m.clickup_tag_name, m.budget_item_id, m.position 
+		`SELECT m.clickup_space_id, m.clickup_tag_name, m.budget_item_id, m.position, NOT m.active
 				FROM clickup_tag_mapping m
 				INNER JOIN clickup_config c ON m.clickup_config_id = c.id
 				WHERE c.user_id = $1 AND c.budget_plan_id = $2 ORDER BY m.position`,
@@ -122,6 +132,7 @@ func (r *RepositoryImpl) GetConfiguration(ctx context.Context, userId, budgetPla
 			&mapping.ClickupTagName,
 			&mapping.BudgetItemId,
 			&mapping.Position,
+			&mapping.Inactive,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan budget mapping: %w", err)
@@ -145,7 +156,7 @@ func (r *RepositoryImpl) GetConfigurationWithMappingByBudgetItemId(ctx context.C
 		`SELECT m.clickup_space_id, m.clickup_tag_name, m.position, c.workspace_id, c.space_id, c.folder_id, c.only_tasks_with_priority
 				FROM clickup_tag_mapping m
 				INNER JOIN clickup_config c ON m.clickup_config_id = c.id
-				WHERE m.user_id = $1 AND m.budget_item_id = $2`,
+				WHERE m.user_id = $1 AND m.budget_item_id = $2 AND m.active`,
 		userId, budgetItemId).Scan(
 		&mapping.ClickupSpaceId,
 		&mapping.ClickupTagName,
@@ -195,6 +206,14 @@ func (r *RepositoryImpl) DeleteBudgetPlanConfiguration(ctx context.Context, user
 	return nil
 }
 
+func (r *RepositoryImpl) DeleteBudgetItemMapping(ctx context.Context, userId, budgetItemId int) error {
+	_, err := r.db.Exec(ctx, "DELETE FROM clickup_tag_mapping WHERE user_id = $1 AND budget_item_id = $2", userId, budgetItemId)
+	if err != nil {
+		return fmt.Errorf("failed to delete budget item mapping: %w", err)
+	}
+	return nil
+}
+
 func (g *RepositoryImpl) DeleteAuthData(ctx context.Context, userId int) error {
 	_, err := g.db.Exec(ctx, "DELETE FROM clickup_auth WHERE user_id = $1", userId)
 	if err != nil {
@@ -202,3 +221,69 @@ func (g *RepositoryImpl) DeleteAuthData(ctx context.Context, userId int) error {
 	}
 	return nil
 }
+
+const authStateColumns = `user_id, invalid_since, stale_notified_at, disabled_at`
+
+func scanAuthState(row pgx.Row) (AuthState, error) {
+	var state AuthState
+	err := row.Scan(&state.UserId, &state.InvalidSince, &state.StaleNotifiedAt, &state.DisabledAt)
+	return state, err
+}
+
+func (r *RepositoryImpl) GetAuthState(ctx context.Context, userId int) (*AuthState, error) {
+	state, err := scanAuthState(r.db.QueryRow(ctx,
+		`SELECT `+authStateColumns+` FROM clickup_auth WHERE user_id = $1 AND access_token IS NOT NULL`, userId))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to retrieve auth state: %w", err)
+	}
+	return &state, nil
+}
+
+func (r *RepositoryImpl) GetStaleAuthStates(ctx context.Context, invalidBefore time.Time) ([]AuthState, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT `+authStateColumns+` FROM clickup_auth
+				WHERE invalid_since <= $1 AND disabled_at IS NULL
+				ORDER BY invalid_since`,
+		invalidBefore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve stale auth states: %w", err)
+	}
+	defer rows.Close()
+
+	states := make([]AuthState, 0)
+	for rows.Next() {
+		state, err := scanAuthState(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan auth state: %w", err)
+		}
+		states = append(states, state)
+	}
+	return states, rows.Err()
+}
+
+func (r *RepositoryImpl) MarkStaleNotified(ctx context.Context, userId int, notifiedAt time.Time) error {
+	_, err := r.db.Exec(ctx, "UPDATE clickup_auth SET stale_notified_at = $1 WHERE user_id = $2", notifiedAt, userId)
+	if err != nil {
+		return fmt.Errorf("failed to mark stale integration as notified: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) DisableSync(ctx context.Context, userId int, disabledAt time.Time) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, "UPDATE clickup_auth SET disabled_at = $1 WHERE user_id = $2", disabledAt, userId); err != nil {
+		return fmt.Errorf("failed to disable integration: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "UPDATE clickup_tag_mapping SET active = FALSE WHERE user_id = $1", userId); err != nil {
+		return fmt.Errorf("failed to deactivate budget mappings: %w", err)
+	}
+	return tx.Commit(ctx)
+}
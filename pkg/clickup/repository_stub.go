@@ -3,13 +3,16 @@ package clickup
 import (
 	"context"
 	"errors"
+	"sort"
 	"sync"
+	"time"
 )
 
 type RepositoryStub struct {
 	mu             sync.RWMutex
 	configs        map[configKey]*Configuration // (userId, budgetPlanId) -> config
 	authData       map[int]bool                 // userId -> has auth data
+	authStates     map[int]AuthState            // userId -> token validity
 	nextMappingPos int
 }
 
@@ -22,6 +25,7 @@ func NewRepositoryStub() *RepositoryStub {
 	return &RepositoryStub{
 		configs:        make(map[configKey]*Configuration),
 		authData:       make(map[int]bool),
+		authStates:     make(map[int]AuthState),
 		nextMappingPos: 1,
 	}
 }
@@ -79,7 +83,7 @@ func (r *RepositoryStub) GetConfigurationWithMappingByBudgetItemId(ctx context.C
 		}
 
 		for _, mapping := range config.Mappings {
-			if mapping.BudgetItemId == budgetItemId {
+			if mapping.BudgetItemId == budgetItemId && !mapping.Inactive {
 				// Found the mapping, return config with just this mapping
 				configCopy := &Configuration{
 					WorkspaceId:           config.WorkspaceId,
@@ -120,11 +124,90 @@ func (r *RepositoryStub) DeleteBudgetPlanConfiguration(ctx context.Context, user
 	return nil
 }
 
+func (r *RepositoryStub) DeleteBudgetItemMapping(ctx context.Context, userId, budgetItemId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, config := range r.configs {
+		if key.userId != userId {
+			continue
+		}
+		mappings := config.Mappings[:0]
+		for _, mapping := range config.Mappings {
+			if mapping.BudgetItemId != budgetItemId {
+				mappings = append(mappings, mapping)
+			}
+		}
+		config.Mappings = mappings
+	}
+
+	return nil
+}
+
 func (r *RepositoryStub) DeleteAuthData(ctx context.Context, userId int) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	delete(r.authData, userId)
+	delete(r.authStates, userId)
+	return nil
+}
+
+func (r *RepositoryStub) GetAuthState(ctx context.Context, userId int) (*AuthState, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if !r.authData[userId] {
+		return nil, nil
+	}
+	state, exists := r.authStates[userId]
+	if !exists {
+		state = AuthState{UserId: userId}
+	}
+	return &state, nil
+}
+
+func (r *RepositoryStub) GetStaleAuthStates(ctx context.Context, invalidBefore time.Time) ([]AuthState, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	states := make([]AuthState, 0)
+	for _, state := range r.authStates {
+		if state.InvalidSince != nil && !state.InvalidSince.After(invalidBefore) && state.DisabledAt == nil {
+			states = append(states, state)
+		}
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].InvalidSince.Before(*states[j].InvalidSince)
+	})
+	return states, nil
+}
+
+func (r *RepositoryStub) MarkStaleNotified(ctx context.Context, userId int, notifiedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := r.authStates[userId]
+	state.StaleNotifiedAt = &notifiedAt
+	r.authStates[userId] = state
+	return nil
+}
+
+func (r *RepositoryStub) DisableSync(ctx context.Context, userId int, disabledAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := r.authStates[userId]
+	state.DisabledAt = &disabledAt
+	r.authStates[userId] = state
+	for key, config := range r.configs {
+		if key.userId != userId {
+			continue
+		}
+		for i := range config.Mappings {
+			config.Mappings[i].Inactive = true
+		}
+	}
 	return nil
 }
 
@@ -144,6 +227,14 @@ func (r *RepositoryStub) HasAuthData(userId int) bool {
 	return r.authData[userId]
 }
 
+// SetAuthState sets the token validity of a user (useful for test setup)
+func (r *RepositoryStub) SetAuthState(state AuthState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.authData[state.UserId] = true
+	r.authStates[state.UserId] = state
+}
+
 // GetAllConfigs returns all stored configurations (useful for test assertions)
 func (r *RepositoryStub) GetAllConfigs() map[configKey]*Configuration {
 	r.mu.RLock()
@@ -171,6 +262,7 @@ func (r *RepositoryStub) Reset() {
 
 	r.configs = make(map[configKey]*Configuration)
 	r.authData = make(map[int]bool)
+	r.authStates = make(map[int]AuthState)
 	r.nextMappingPos = 1
 }
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/klokku/klokku/internal/test_utils"
@@ -623,6 +624,35 @@ func TestRepositoryImpl_DeleteBudgetPlanConfigurations(t *testing.T) {
 	})
 }
 
+func TestRepositoryImpl_DeleteBudgetItemMapping(t *testing.T) {
+	t.Run("should delete only the mapping of the budget item", func(t *testing.T) {
+		// given
+		ctx, repo, userId := setupTestRepository(t)
+		config := Configuration{
+			WorkspaceId: "10",
+			SpaceId:     "20",
+			FolderId:    "30",
+			Mappings: []BudgetItemMapping{
+				{ClickupSpaceId: "20", ClickupTagName: "tag-1", BudgetItemId: 101, Position: 1},
+				{ClickupSpaceId: "20", ClickupTagName: "tag-2", BudgetItemId: 102, Position: 2},
+			},
+		}
+		err := repo.StoreConfiguration(ctx, userId, 1, config)
+		require.NoError(t, err)
+
+		// when
+		err = repo.DeleteBudgetItemMapping(ctx, userId, 101)
+
+		// then
+		require.NoError(t, err)
+		stored, err := repo.GetConfiguration(ctx, userId, 1)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		require.Len(t, stored.Mappings, 1)
+		assert.Equal(t, 102, stored.Mappings[0].BudgetItemId)
+	})
+}
+
 func TestRepositoryImpl_DeleteAuthData(t *testing.T) {
 	t.Run("should delete auth data for user", func(t *testing.T) {
 		// given
@@ -690,3 +720,59 @@ func TestRepositoryImpl_DeleteAuthData(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+func TestRepositoryImpl_StaleIntegrations(t *testing.T) {
+	t.Run("should find stale integrations and disable their sync", func(t *testing.T) {
+		// given
+		ctx, repo, userId := setupTestRepository(t)
+		db := openDb()
+		defer db.Close()
+		invalidSince := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+		_, err := db.Exec(ctx,
+			"INSERT INTO clickup_auth (user_id, access_token, refresh_token, invalid_since) VALUES ($1, $2, $3, $4)",
+			userId, "access-token", "refresh-token", invalidSince)
+		require.NoError(t, err)
+		_, err = db.Exec(ctx,
+			"INSERT INTO clickup_auth (user_id, access_token, refresh_token) VALUES ($1, $2, $3)",
+			userId+1, "access-token-2", "refresh-token-2")
+		require.NoError(t, err)
+		err = repo.StoreConfiguration(ctx, userId, 10, Configuration{
+			WorkspaceId: "10",
+			SpaceId:     "20",
+			Mappings:    []BudgetItemMapping{{ClickupSpaceId: "20", ClickupTagName: "work", BudgetItemId: 1}},
+		})
+		require.NoError(t, err)
+
+		// when
+		stale, err := repo.GetStaleAuthStates(ctx, invalidSince.Add(7*24*time.Hour))
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, stale, 1)
+		assert.Equal(t, userId, stale[0].UserId)
+		assert.Nil(t, stale[0].StaleNotifiedAt)
+
+		// when
+		notifiedAt := invalidSince.Add(8 * 24 * time.Hour)
+		require.NoError(t, repo.MarkStaleNotified(ctx, userId, notifiedAt))
+		require.NoError(t, repo.DisableSync(ctx, userId, notifiedAt.Add(7*24*time.Hour)))
+
+		// then
+		state, err := repo.GetAuthState(ctx, userId)
+		require.NoError(t, err)
+		require.NotNil(t, state.StaleNotifiedAt)
+		assert.True(t, notifiedAt.Equal(*state.StaleNotifiedAt))
+		assert.NotNil(t, state.DisabledAt)
+		stale, err = repo.GetStaleAuthStates(ctx, notifiedAt.Add(30*24*time.Hour))
+		require.NoError(t, err)
+		assert.Empty(t, stale)
+
+		configuration, err := repo.GetConfiguration(ctx, userId, 10)
+		require.NoError(t, err)
+		require.Len(t, configuration.Mappings, 1)
+		assert.True(t, configuration.Mappings[0].Inactive)
+		withMapping, err := repo.GetConfigurationWithMappingByBudgetItemId(ctx, userId, 1)
+		require.NoError(t, err)
+		assert.Nil(t, withMapping)
+	})
+}
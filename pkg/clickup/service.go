@@ -3,7 +3,10 @@ package clickup
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/internal/event_bus"
 	"github.com/klokku/klokku/pkg/user"
 	log "github.com/sirupsen/logrus"
 )
@@ -14,15 +17,30 @@ type Service interface {
 	GetTasksByBudgetItemId(ctx context.Context, budgetItemId int) ([]Task, error)
 	DisableIntegration(ctx context.Context) error
 	DeleteBudgetPlanConfiguration(ctx context.Context, budgetPlanId int) error
+	GetIntegrationStatus(ctx context.Context) (IntegrationStatus, error)
+	// CleanupStaleIntegrations notifies users whose token has been invalid for too long and disables sync
+	// of integrations whose grace period after the notification has passed.
+	CleanupStaleIntegrations(ctx context.Context, now time.Time) error
 }
 
 type ServiceImpl struct {
-	repo   Repository
-	client Client
+	repo     Repository
+	client   Client
+	eventBus *event_bus.EventBus
+	// staleAfter is how long a token has to be invalid before the user is notified.
+	staleAfter time.Duration
+	// gracePeriod is how long after the notification sync is disabled.
+	gracePeriod time.Duration
 }
 
-func NewServiceImpl(repo Repository, clickUpClient Client) *ServiceImpl {
-	return &ServiceImpl{repo: repo, client: clickUpClient}
+func NewServiceImpl(repo Repository, clickUpClient Client, cfg config.ClickUp, eventBus *event_bus.EventBus) *ServiceImpl {
+	return &ServiceImpl{
+		repo:        repo,
+		client:      clickUpClient,
+		eventBus:    eventBus,
+		staleAfter:  time.Duration(cfg.StaleAfterDays) * 24 * time.Hour,
+		gracePeriod: time.Duration(cfg.DisableAfterDays) * 24 * time.Hour,
+	}
 }
 
 func (s *ServiceImpl) StoreConfiguration(ctx context.Context, budgetPlanId int, config Configuration) error {
@@ -130,3 +148,95 @@ func (s *ServiceImpl) DeleteBudgetPlanConfiguration(ctx context.Context, budgetP
 
 	return nil
 }
+
+// SubscribeToBudgetPlanChanges removes configurations and tag mappings of deleted budget plans and items,
+// so no mapping is left pointing at a budget item that no longer exists.
+func (s *ServiceImpl) SubscribeToBudgetPlanChanges(eventBus *event_bus.EventBus) {
+	event_bus.SubscribeTyped[event_bus.BudgetPlanDeleted](
+		eventBus,
+		"budget_plan.plan.deleted",
+		func(e event_bus.EventT[event_bus.BudgetPlanDeleted]) error {
+			return s.DeleteBudgetPlanConfiguration(e.Context(), e.Data.Id)
+		},
+	)
+	event_bus.SubscribeTyped[event_bus.BudgetPlanItemDeleted](
+		eventBus,
+		"budget_plan.item.deleted",
+		func(e event_bus.EventT[event_bus.BudgetPlanItemDeleted]) error {
+			userId, err := user.CurrentId(e.Context())
+			if err != nil {
+				return fmt.Errorf("failed to get current user: %w", err)
+			}
+			return s.repo.DeleteBudgetItemMapping(e.Context(), userId, e.Data.Id)
+		},
+	)
+}
+
+func (s *ServiceImpl) GetIntegrationStatus(ctx context.Context) (IntegrationStatus, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return IntegrationStatus{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	state, err := s.repo.GetAuthState(ctx, userId)
+	if err != nil {
+		return IntegrationStatus{}, err
+	}
+	switch {
+	case state == nil:
+		return IntegrationStatus{State: IntegrationNotConnected}, nil
+	case state.DisabledAt != nil:
+		return IntegrationStatus{State: IntegrationDisabled, InvalidSince: state.InvalidSince}, nil
+	case state.StaleNotifiedAt != nil:
+		disableAt := state.StaleNotifiedAt.Add(s.gracePeriod)
+		return IntegrationStatus{State: IntegrationStale, InvalidSince: state.InvalidSince, DisableAt: &disableAt}, nil
+	case state.InvalidSince != nil:
+		return IntegrationStatus{State: IntegrationInvalid, InvalidSince: state.InvalidSince}, nil
+	}
+	return IntegrationStatus{State: IntegrationActive}, nil
+}
+
+// notifyStale publishes the integration.stale event, which notifies the user, once per stale token.
+func (s *ServiceImpl) notifyStale(ctx context.Context, state AuthState, now time.Time) {
+	disableAt := now.Add(s.gracePeriod)
+	log.Warnf("ClickUp token of user %d has been invalid since %s, sync will be disabled after %s",
+		state.UserId, state.InvalidSince.Format(time.RFC3339), disableAt.Format(time.RFC3339))
+	if err := s.repo.MarkStaleNotified(ctx, state.UserId, now); err != nil {
+		log.Errorf("failed to notify user %d about stale ClickUp integration: %v", state.UserId, err)
+		return
+	}
+	if s.eventBus == nil {
+		return
+	}
+	err := s.eventBus.Publish(event_bus.NewEvent(ctx, "integration.stale", event_bus.IntegrationStale{
+		UserId:       state.UserId,
+		Provider:     "clickup",
+		InvalidSince: *state.InvalidSince,
+		DisableAt:    disableAt,
+	}))
+	if err != nil {
+		log.Errorf("failed to notify user %d about stale ClickUp integration: %v", state.UserId, err)
+	}
+}
+
+func (s *ServiceImpl) CleanupStaleIntegrations(ctx context.Context, now time.Time) error {
+	states, err := s.repo.GetStaleAuthStates(ctx, now.Add(-s.staleAfter))
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		if state.StaleNotifiedAt == nil {
+			s.notifyStale(ctx, state, now)
+			continue
+		}
+		if now.Before(state.StaleNotifiedAt.Add(s.gracePeriod)) {
+			continue
+		}
+		if err := s.repo.DisableSync(ctx, state.UserId, now); err != nil {
+			log.Errorf("failed to disable stale ClickUp integration of user %d: %v", state.UserId, err)
+			continue
+		}
+		log.Infof("Disabled stale ClickUp integration of user %d", state.UserId)
+	}
+	return nil
+}
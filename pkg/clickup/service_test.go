@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/internal/event_bus"
 	"github.com/klokku/klokku/pkg/user"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -13,6 +15,8 @@ import (
 
 const testUserId = 123
 
+var testConfig = config.ClickUp{StaleAfterDays: 7, DisableAfterDays: 3}
+
 func ctxWithUserId(userId int) context.Context {
 	return context.WithValue(context.Background(), user.UserKey, user.User{
 		Id:          userId,
@@ -32,7 +36,7 @@ func ctxWithUserId(userId int) context.Context {
 func setupServiceTest(t *testing.T) (*ServiceImpl, *RepositoryStub, *ClientStub, context.Context) {
 	repo := NewRepositoryStub()
 	client := NewClientStub()
-	service := NewServiceImpl(repo, client)
+	service := NewServiceImpl(repo, client, testConfig, nil)
 	ctx := ctxWithUserId(testUserId)
 	t.Cleanup(func() {
 		repo.Reset()
@@ -593,7 +597,7 @@ func TestServiceImpl_DeleteBudgetPlanConfiguration(t *testing.T) {
 		// given
 		repo := NewRepositoryStub()
 		client := NewClientStub()
-		service := NewServiceImpl(repo, client)
+		service := NewServiceImpl(repo, client, testConfig, nil)
 		user1Id := 100
 		user2Id := 200
 		ctx1 := ctxWithUserId(user1Id)
@@ -662,3 +666,154 @@ func TestServiceImpl_DeleteBudgetPlanConfiguration(t *testing.T) {
 		assert.Contains(t, err.Error(), "failed to delete configuration")
 	})
 }
+
+func TestServiceImpl_SubscribeToBudgetPlanChanges(t *testing.T) {
+	setup := func(t *testing.T) (*RepositoryStub, *event_bus.EventBus, context.Context) {
+		service, repo, _, ctx := setupServiceTest(t)
+		bus := event_bus.NewEventBus()
+		service.SubscribeToBudgetPlanChanges(bus)
+		err := repo.StoreConfiguration(ctx, testUserId, 1, Configuration{
+			WorkspaceId: "100",
+			SpaceId:     "200",
+			Mappings: []BudgetItemMapping{
+				{ClickupSpaceId: "200", ClickupTagName: "work", BudgetItemId: 101},
+				{ClickupSpaceId: "200", ClickupTagName: "fun", BudgetItemId: 102},
+			},
+		})
+		require.NoError(t, err)
+		return repo, bus, ctx
+	}
+
+	t.Run("should delete the mapping of a deleted budget item", func(t *testing.T) {
+		// given
+		repo, bus, ctx := setup(t)
+
+		// when
+		err := bus.Publish(event_bus.NewEvent(ctx, "budget_plan.item.deleted", event_bus.BudgetPlanItemDeleted{Id: 101, PlanId: 1}))
+
+		// then
+		require.NoError(t, err)
+		config, err := repo.GetConfiguration(ctx, testUserId, 1)
+		require.NoError(t, err)
+		require.Len(t, config.Mappings, 1)
+		assert.Equal(t, 102, config.Mappings[0].BudgetItemId)
+	})
+
+	t.Run("should delete the configuration of a deleted budget plan", func(t *testing.T) {
+		// given
+		repo, bus, ctx := setup(t)
+
+		// when
+		err := bus.Publish(event_bus.NewEvent(ctx, "budget_plan.plan.deleted", event_bus.BudgetPlanDeleted{Id: 1}))
+
+		// then
+		require.NoError(t, err)
+		config, err := repo.GetConfiguration(ctx, testUserId, 1)
+		require.NoError(t, err)
+		assert.Nil(t, config)
+	})
+}
+
+func TestServiceImpl_CleanupStaleIntegrations(t *testing.T) {
+	now := time.Date(2025, 6, 20, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) *time.Time {
+		t := now.Add(-time.Duration(days) * 24 * time.Hour)
+		return &t
+	}
+
+	t.Run("should notify user when token has been invalid for longer than the stale period", func(t *testing.T) {
+		// given
+		service, repo, _, ctx := setupServiceTest(t)
+		service.eventBus = event_bus.NewEventBus()
+		var notified []event_bus.IntegrationStale
+		event_bus.SubscribeTyped[event_bus.IntegrationStale](service.eventBus, "integration.stale",
+			func(e event_bus.EventT[event_bus.IntegrationStale]) error {
+				notified = append(notified, e.Data)
+				return nil
+			})
+		repo.SetAuthState(AuthState{UserId: testUserId, InvalidSince: daysAgo(8)})
+		repo.SetAuthState(AuthState{UserId: testUserId + 1, InvalidSince: daysAgo(2)})
+
+		// when
+		err := service.CleanupStaleIntegrations(ctx, now)
+		require.NoError(t, err)
+		err = service.CleanupStaleIntegrations(ctx, now.Add(time.Hour))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []event_bus.IntegrationStale{{UserId: testUserId, Provider: "clickup", InvalidSince: *daysAgo(8),
+			DisableAt: now.Add(3 * 24 * time.Hour)}}, notified)
+		status, err := service.GetIntegrationStatus(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, IntegrationStale, status.State)
+		require.NotNil(t, status.DisableAt)
+		assert.Equal(t, now.Add(3*24*time.Hour), *status.DisableAt)
+
+		otherStatus, err := service.GetIntegrationStatus(ctxWithUserId(testUserId + 1))
+		require.NoError(t, err)
+		assert.Equal(t, IntegrationInvalid, otherStatus.State)
+	})
+
+	t.Run("should disable sync and deactivate mappings after the grace period", func(t *testing.T) {
+		// given
+		service, repo, _, ctx := setupServiceTest(t)
+		require.NoError(t, repo.StoreConfiguration(ctx, testUserId, 10, Configuration{
+			WorkspaceId: "100",
+			SpaceId:     "200",
+			Mappings:    []BudgetItemMapping{{ClickupSpaceId: "200", ClickupTagName: "work", BudgetItemId: 1}},
+		}))
+		repo.SetAuthState(AuthState{UserId: testUserId, InvalidSince: daysAgo(12), StaleNotifiedAt: daysAgo(4)})
+
+		// when
+		err := service.CleanupStaleIntegrations(ctx, now)
+
+		// then
+		require.NoError(t, err)
+		status, err := service.GetIntegrationStatus(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, IntegrationDisabled, status.State)
+		configuration, err := service.GetConfiguration(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, configuration.Mappings, 1)
+		assert.True(t, configuration.Mappings[0].Inactive)
+		tasks, err := service.GetTasksByBudgetItemId(ctx, 1)
+		require.NoError(t, err)
+		assert.Empty(t, tasks)
+	})
+
+	t.Run("should keep sync enabled during the grace period", func(t *testing.T) {
+		// given
+		service, repo, _, ctx := setupServiceTest(t)
+		repo.SetAuthState(AuthState{UserId: testUserId, InvalidSince: daysAgo(9), StaleNotifiedAt: daysAgo(2)})
+
+		// when
+		err := service.CleanupStaleIntegrations(ctx, now)
+
+		// then
+		require.NoError(t, err)
+		status, err := service.GetIntegrationStatus(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, IntegrationStale, status.State)
+	})
+}
+
+func TestServiceImpl_GetIntegrationStatus(t *testing.T) {
+	t.Run("should return not connected when user has no auth data", func(t *testing.T) {
+		service, _, _, ctx := setupServiceTest(t)
+
+		status, err := service.GetIntegrationStatus(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, IntegrationNotConnected, status.State)
+	})
+
+	t.Run("should return active when token is valid", func(t *testing.T) {
+		service, repo, _, ctx := setupServiceTest(t)
+		repo.SetAuthData(testUserId)
+
+		status, err := service.GetIntegrationStatus(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, IntegrationActive, status.State)
+	})
+}
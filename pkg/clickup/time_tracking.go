@@ -0,0 +1,255 @@
+package clickup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/tracing"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	pushesPerRun   = 100
+	maxErrorLength = 500
+	// pushLease is how long claimed pushes are not handed out again, it has to outlast a whole run
+	pushLease = 20 * time.Minute
+)
+
+// pushRetryDelays are the waits after each failed attempt. A push fails for good after len(pushRetryDelays)+1 attempts.
+var pushRetryDelays = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 6 * time.Hour}
+
+// TimeTrackingService pushes calendar events linked to ClickUp tasks to ClickUp time tracking. Changes are
+// recorded in an outbox when the events are stored and pushed in the background, so a ClickUp outage
+// does not lose them.
+type TimeTrackingService interface {
+	IsTimeTrackingEnabled(ctx context.Context) (bool, error)
+	SetTimeTrackingEnabled(ctx context.Context, enabled bool) error
+	// PushDue attempts all pending pushes that are due at now and returns how many were attempted.
+	PushDue(ctx context.Context, now time.Time) (int, error)
+}
+
+type usersProvider interface {
+	GetUser(ctx context.Context, id int) (user.User, error)
+}
+
+type TimeTrackingServiceImpl struct {
+	repo             Repository
+	timeTrackingRepo TimeTrackingRepository
+	client           Client
+	users            usersProvider
+	clock            utils.Clock
+}
+
+func NewTimeTrackingService(repo Repository, timeTrackingRepo TimeTrackingRepository, client Client, users usersProvider,
+	eventBus *event_bus.EventBus) (*TimeTrackingServiceImpl, error) {
+	service := &TimeTrackingServiceImpl{
+		repo:             repo,
+		timeTrackingRepo: timeTrackingRepo,
+		client:           client,
+		users:            users,
+		clock:            &utils.SystemClock{},
+	}
+	if err := service.subscribe(eventBus); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+func (s *TimeTrackingServiceImpl) subscribe(eventBus *event_bus.EventBus) error {
+	if eventBus == nil {
+		return nil
+	}
+	if err := event_bus.SubscribeDurable[event_bus.CalendarEventCreated](
+		eventBus,
+		"clickup_time_tracking",
+		"calendar.event.created",
+		func(e event_bus.EventT[event_bus.CalendarEventCreated]) error {
+			// Nothing was pushed for a new event, so there is nothing to delete when it has no task
+			if e.Data.Sandbox || e.Data.TaskId == "" {
+				return nil
+			}
+			return s.enqueue(e.Context(), e.Data.UID, e.Data.BudgetItemId, TimeEntry{
+				TaskId:      e.Data.TaskId,
+				Description: e.Data.Summary,
+				Start:       e.Data.StartTime,
+				End:         e.Data.EndTime,
+			})
+		},
+	); err != nil {
+		return err
+	}
+	if err := event_bus.SubscribeDurable[event_bus.CalendarEventUpdated](
+		eventBus,
+		"clickup_time_tracking",
+		"calendar.event.updated",
+		func(e event_bus.EventT[event_bus.CalendarEventUpdated]) error {
+			if e.Data.Sandbox {
+				return nil
+			}
+			return s.enqueue(e.Context(), e.Data.UID, e.Data.BudgetItemId, TimeEntry{
+				TaskId:      e.Data.TaskId,
+				Description: e.Data.Summary,
+				Start:       e.Data.StartTime,
+				End:         e.Data.EndTime,
+			})
+		},
+	); err != nil {
+		return err
+	}
+	return event_bus.SubscribeDurable[event_bus.CalendarEventDeleted](
+		eventBus,
+		"clickup_time_tracking",
+		"calendar.event.deleted",
+		func(e event_bus.EventT[event_bus.CalendarEventDeleted]) error {
+			return s.enqueue(e.Context(), e.Data.UID, 0, TimeEntry{})
+		},
+	)
+}
+
+// enqueue records the desired state of the event's time entry for users with time tracking enabled. Events
+// without a task or without a ClickUp workspace configured for their budget item should have no time entry.
+func (s *TimeTrackingServiceImpl) enqueue(ctx context.Context, eventUid string, budgetItemId int, entry TimeEntry) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	enabled, err := s.timeTrackingRepo.IsTimeTrackingEnabled(ctx, userId)
+	if err != nil || !enabled {
+		return err
+	}
+
+	now := s.clock.Now()
+	push := TimeEntryPush{UserId: userId, EventUid: eventUid, Operation: PushDelete, NextAttemptAt: &now}
+	if entry.TaskId != "" {
+		config, err := s.repo.GetConfigurationWithMappingByBudgetItemId(ctx, userId, budgetItemId)
+		if err != nil {
+			return err
+		}
+		if config != nil && config.WorkspaceId != "" {
+			push.Operation = PushUpsert
+			push.WorkspaceId = config.WorkspaceId
+			push.Entry = entry
+		}
+	}
+	return s.timeTrackingRepo.EnqueueTimeEntryPush(ctx, push)
+}
+
+func (s *TimeTrackingServiceImpl) IsTimeTrackingEnabled(ctx context.Context) (bool, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.timeTrackingRepo.IsTimeTrackingEnabled(ctx, userId)
+}
+
+func (s *TimeTrackingServiceImpl) SetTimeTrackingEnabled(ctx context.Context, enabled bool) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.timeTrackingRepo.SetTimeTrackingEnabled(ctx, userId, enabled)
+}
+
+func (s *TimeTrackingServiceImpl) PushDue(ctx context.Context, now time.Time) (int, error) {
+	due, err := s.timeTrackingRepo.ClaimDueTimeEntryPushes(ctx, now, pushLease, pushesPerRun)
+	if err != nil {
+		return 0, err
+	}
+	for _, push := range due {
+		push = s.attempt(ctx, push, now)
+		if err := s.timeTrackingRepo.UpdateTimeEntryPush(ctx, push); err != nil {
+			return 0, err
+		}
+	}
+	return len(due), nil
+}
+
+// attempt pushes the change once and returns the push with the outcome and the next attempt scheduled.
+func (s *TimeTrackingServiceImpl) attempt(ctx context.Context, push TimeEntryPush, now time.Time) TimeEntryPush {
+	// Pushes run in the background, the span ties the ClickUp calls and queries of one push together
+	ctx, span := tracing.Tracer("klokku/clickup").Start(ctx, "push time entry", trace.WithAttributes(
+		attribute.Int64("clickup.push.id", push.Id),
+		attribute.String("clickup.push.operation", string(push.Operation)),
+		attribute.Int("enduser.id", push.UserId),
+	))
+	defer span.End()
+
+	push.Attempts++
+	push.LastError = ""
+	if err := s.push(ctx, push); err != nil {
+		push.LastError = utils.Truncate(err.Error(), maxErrorLength)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	switch {
+	case push.LastError == "":
+		push.Status = PushSucceeded
+		push.NextAttemptAt = nil
+	case push.Attempts > len(pushRetryDelays):
+		log.Infof("ClickUp time entry push %d of event %s failed after %d attempts: %s",
+			push.Id, push.EventUid, push.Attempts, push.LastError)
+		push.Status = PushFailed
+		push.NextAttemptAt = nil
+	default:
+		next := now.Add(pushRetryDelays[push.Attempts-1])
+		push.NextAttemptAt = &next
+	}
+	return push
+}
+
+func (s *TimeTrackingServiceImpl) push(ctx context.Context, push TimeEntryPush) error {
+	u, err := s.users.GetUser(ctx, push.UserId)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	ctx = user.WithUser(ctx, u)
+
+	pushed, err := s.timeTrackingRepo.GetPushedTimeEntry(ctx, push.UserId, push.EventUid)
+	if err != nil {
+		return err
+	}
+	if push.Operation == PushDelete {
+		if pushed == nil {
+			return nil
+		}
+		return s.deleteTimeEntry(ctx, push.UserId, *pushed)
+	}
+
+	if pushed != nil && pushed.WorkspaceId == push.WorkspaceId {
+		err := s.client.UpdateTimeEntry(ctx, pushed.WorkspaceId, pushed.TimeEntryId, push.Entry)
+		if !errors.Is(err, ErrTimeEntryNotFound) {
+			return err
+		}
+		// Deleted in ClickUp, it is created again below
+	} else if pushed != nil {
+		if err := s.deleteTimeEntry(ctx, push.UserId, *pushed); err != nil {
+			return err
+		}
+	}
+	timeEntryId, err := s.client.CreateTimeEntry(ctx, push.WorkspaceId, push.Entry)
+	if err != nil {
+		return err
+	}
+	return s.timeTrackingRepo.StorePushedTimeEntry(ctx, push.UserId, PushedTimeEntry{
+		EventUid:    push.EventUid,
+		WorkspaceId: push.WorkspaceId,
+		TimeEntryId: timeEntryId,
+	})
+}
+
+// deleteTimeEntry deletes the time entry, ignoring entries already deleted in ClickUp.
+func (s *TimeTrackingServiceImpl) deleteTimeEntry(ctx context.Context, userId int, pushed PushedTimeEntry) error {
+	err := s.client.DeleteTimeEntry(ctx, pushed.WorkspaceId, pushed.TimeEntryId)
+	if err != nil && !errors.Is(err, ErrTimeEntryNotFound) {
+		return err
+	}
+	return s.timeTrackingRepo.DeletePushedTimeEntry(ctx, userId, pushed.EventUid)
+}
@@ -0,0 +1,72 @@
+package clickup
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type TimeTrackingDTO struct {
+	Enabled bool `json:"enabled"`
+}
+
+type TimeTrackingHandler struct {
+	service TimeTrackingService
+}
+
+func NewTimeTrackingHandler(s TimeTrackingService) *TimeTrackingHandler {
+	return &TimeTrackingHandler{s}
+}
+
+// GetTimeTracking godoc
+// @Summary Get ClickUp time tracking setting
+// @Description Get whether calendar events linked to ClickUp tasks are pushed to ClickUp as time entries
+// @Tags ClickUp
+// @Produce json
+// @Success 200 {object} TimeTrackingDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/integrations/clickup/time-tracking [get]
+// @Security XUserId
+func (h *TimeTrackingHandler) GetTimeTracking(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enabled, err := h.service.IsTimeTrackingEnabled(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(TimeTrackingDTO{Enabled: enabled}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// StoreTimeTracking godoc
+// @Summary Enable or disable ClickUp time tracking
+// @Description When enabled, calendar events linked to ClickUp tasks are pushed to ClickUp as time entries, and the
+// @Description time entries are updated or deleted when the events change. Disabling it keeps the pushed entries.
+// @Tags ClickUp
+// @Accept json
+// @Produce json
+// @Param setting body TimeTrackingDTO true "Time tracking setting"
+// @Success 200 {object} TimeTrackingDTO
+// @Failure 400 {string} string "Invalid request body"
+// @Failure 403 {string} string "User not found"
+// @Router /api/integrations/clickup/time-tracking [put]
+// @Security XUserId
+func (h *TimeTrackingHandler) StoreTimeTracking(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var setting TimeTrackingDTO
+	if err := json.NewDecoder(r.Body).Decode(&setting); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.SetTimeTrackingEnabled(r.Context(), setting.Enabled); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(setting); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
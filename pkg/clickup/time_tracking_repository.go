@@ -0,0 +1,172 @@
+package clickup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type TimeTrackingRepository interface {
+	IsTimeTrackingEnabled(ctx context.Context, userId int) (bool, error)
+	SetTimeTrackingEnabled(ctx context.Context, userId int, enabled bool) error
+	// EnqueueTimeEntryPush records a pending push, replacing the pending push of the same event if there is one.
+	EnqueueTimeEntryPush(ctx context.Context, push TimeEntryPush) error
+	// ClaimDueTimeEntryPushes returns up to limit pending pushes due at now, the oldest first, and postpones them by
+	// the lease, so concurrent pushers do not attempt them too. Pushes locked by another pusher are skipped.
+	ClaimDueTimeEntryPushes(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]TimeEntryPush, error)
+	// UpdateTimeEntryPush stores the outcome of an attempt. It is ignored when the push was replaced meanwhile,
+	// so the newer change is pushed by the next attempt.
+	UpdateTimeEntryPush(ctx context.Context, push TimeEntryPush) error
+	// GetPushedTimeEntry returns nil when no time entry was created for the event.
+	GetPushedTimeEntry(ctx context.Context, userId int, eventUid string) (*PushedTimeEntry, error)
+	StorePushedTimeEntry(ctx context.Context, userId int, entry PushedTimeEntry) error
+	DeletePushedTimeEntry(ctx context.Context, userId int, eventUid string) error
+}
+
+const timeEntryPushColumns = `id, user_id, event_uid, operation, workspace_id, task_id, description, start_time, end_time,
+	status, version, attempts, next_attempt_at, last_error`
+
+func (r *RepositoryImpl) IsTimeTrackingEnabled(ctx context.Context, userId int) (bool, error) {
+	var enabled bool
+	err := r.db.QueryRow(ctx, "SELECT enabled FROM clickup_time_tracking WHERE user_id = $1", userId).Scan(&enabled)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get ClickUp time tracking setting: %w", err)
+	}
+	return enabled, nil
+}
+
+func (r *RepositoryImpl) SetTimeTrackingEnabled(ctx context.Context, userId int, enabled bool) error {
+	query := `INSERT INTO clickup_time_tracking (user_id, enabled) VALUES ($1, $2)
+			  ON CONFLICT (user_id) DO UPDATE SET enabled = EXCLUDED.enabled`
+	if _, err := r.db.Exec(ctx, query, userId, enabled); err != nil {
+		return fmt.Errorf("failed to store ClickUp time tracking setting: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) EnqueueTimeEntryPush(ctx context.Context, push TimeEntryPush) error {
+	query := `INSERT INTO clickup_time_entry_push (user_id, event_uid, operation, workspace_id, task_id, description,
+				  start_time, end_time, status, next_attempt_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 'pending', $9)
+			  ON CONFLICT (user_id, event_uid) WHERE status = 'pending' DO UPDATE SET
+				  operation = EXCLUDED.operation,
+				  workspace_id = EXCLUDED.workspace_id,
+				  task_id = EXCLUDED.task_id,
+				  description = EXCLUDED.description,
+				  start_time = EXCLUDED.start_time,
+				  end_time = EXCLUDED.end_time,
+				  version = clickup_time_entry_push.version + 1,
+				  attempts = 0,
+				  next_attempt_at = EXCLUDED.next_attempt_at,
+				  last_error = '',
+				  updated_at = NOW()`
+	_, err := r.db.Exec(ctx, query, push.UserId, push.EventUid, push.Operation, push.WorkspaceId, push.Entry.TaskId,
+		push.Entry.Description, nullableTime(push.Entry.Start), nullableTime(push.Entry.End), push.NextAttemptAt)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue ClickUp time entry push: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) ClaimDueTimeEntryPushes(ctx context.Context, now time.Time, lease time.Duration,
+	limit int) ([]TimeEntryPush, error) {
+	query := `WITH due AS (
+				SELECT id FROM clickup_time_entry_push
+				WHERE status = 'pending' AND next_attempt_at <= $1
+				  AND user_id IN (SELECT id FROM users WHERE NOT disabled)
+				ORDER BY id
+				LIMIT $3
+				FOR UPDATE SKIP LOCKED
+			  ), claimed AS (
+				UPDATE clickup_time_entry_push p SET next_attempt_at = $2
+				FROM due
+				WHERE p.id = due.id
+				RETURNING p.*
+			  )
+			  SELECT ` + timeEntryPushColumns + ` FROM claimed ORDER BY id`
+	rows, err := r.db.Query(ctx, query, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim due ClickUp time entry pushes: %w", err)
+	}
+	defer rows.Close()
+
+	pushes := make([]TimeEntryPush, 0)
+	for rows.Next() {
+		var push TimeEntryPush
+		var start, end *time.Time
+		err := rows.Scan(&push.Id, &push.UserId, &push.EventUid, &push.Operation, &push.WorkspaceId, &push.Entry.TaskId,
+			&push.Entry.Description, &start, &end, &push.Status, &push.Version, &push.Attempts, &push.NextAttemptAt,
+			&push.LastError)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan ClickUp time entry push: %w", err)
+		}
+		if start != nil {
+			push.Entry.Start = *start
+		}
+		if end != nil {
+			push.Entry.End = *end
+		}
+		pushes = append(pushes, push)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim due ClickUp time entry pushes: %w", err)
+	}
+	return pushes, nil
+}
+
+func (r *RepositoryImpl) UpdateTimeEntryPush(ctx context.Context, push TimeEntryPush) error {
+	query := `UPDATE clickup_time_entry_push
+			  SET status = $1, attempts = $2, next_attempt_at = $3, last_error = $4, updated_at = NOW()
+			  WHERE id = $5 AND version = $6`
+	_, err := r.db.Exec(ctx, query, push.Status, push.Attempts, push.NextAttemptAt, push.LastError, push.Id, push.Version)
+	if err != nil {
+		return fmt.Errorf("failed to update ClickUp time entry push: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) GetPushedTimeEntry(ctx context.Context, userId int, eventUid string) (*PushedTimeEntry, error) {
+	entry := &PushedTimeEntry{EventUid: eventUid}
+	err := r.db.QueryRow(ctx,
+		"SELECT workspace_id, time_entry_id FROM clickup_time_entry WHERE user_id = $1 AND event_uid = $2",
+		userId, eventUid).Scan(&entry.WorkspaceId, &entry.TimeEntryId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get pushed ClickUp time entry: %w", err)
+	}
+	return entry, nil
+}
+
+func (r *RepositoryImpl) StorePushedTimeEntry(ctx context.Context, userId int, entry PushedTimeEntry) error {
+	query := `INSERT INTO clickup_time_entry (user_id, event_uid, workspace_id, time_entry_id) VALUES ($1, $2, $3, $4)
+			  ON CONFLICT (user_id, event_uid) DO UPDATE SET
+				  workspace_id = EXCLUDED.workspace_id,
+				  time_entry_id = EXCLUDED.time_entry_id`
+	if _, err := r.db.Exec(ctx, query, userId, entry.EventUid, entry.WorkspaceId, entry.TimeEntryId); err != nil {
+		return fmt.Errorf("failed to store pushed ClickUp time entry: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) DeletePushedTimeEntry(ctx context.Context, userId int, eventUid string) error {
+	query := "DELETE FROM clickup_time_entry WHERE user_id = $1 AND event_uid = $2"
+	if _, err := r.db.Exec(ctx, query, userId, eventUid); err != nil {
+		return fmt.Errorf("failed to delete pushed ClickUp time entry: %w", err)
+	}
+	return nil
+}
+
+func nullableTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
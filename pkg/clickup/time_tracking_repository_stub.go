@@ -0,0 +1,118 @@
+package clickup
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+type TimeTrackingRepositoryStub struct {
+	mu      sync.RWMutex
+	enabled map[int]bool
+	pushes  []TimeEntryPush
+	pushed  map[int]map[string]PushedTimeEntry // userId -> eventUid -> entry
+	nextId  int64
+}
+
+func NewTimeTrackingRepositoryStub() *TimeTrackingRepositoryStub {
+	return &TimeTrackingRepositoryStub{
+		enabled: make(map[int]bool),
+		pushed:  make(map[int]map[string]PushedTimeEntry),
+	}
+}
+
+func (r *TimeTrackingRepositoryStub) IsTimeTrackingEnabled(ctx context.Context, userId int) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.enabled[userId], nil
+}
+
+func (r *TimeTrackingRepositoryStub) SetTimeTrackingEnabled(ctx context.Context, userId int, enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.enabled[userId] = enabled
+	return nil
+}
+
+func (r *TimeTrackingRepositoryStub) EnqueueTimeEntryPush(ctx context.Context, push TimeEntryPush) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, p := range r.pushes {
+		if p.UserId == push.UserId && p.EventUid == push.EventUid && p.Status == PushPending {
+			push.Id = p.Id
+			push.Version = p.Version + 1
+			push.Status = PushPending
+			push.Attempts = 0
+			push.LastError = ""
+			r.pushes[i] = push
+			return nil
+		}
+	}
+	r.nextId++
+	push.Id = r.nextId
+	push.Version = 1
+	push.Status = PushPending
+	r.pushes = append(r.pushes, push)
+	return nil
+}
+
+func (r *TimeTrackingRepositoryStub) ClaimDueTimeEntryPushes(ctx context.Context, now time.Time, lease time.Duration,
+	limit int) ([]TimeEntryPush, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	leaseUntil := now.Add(lease)
+	due := make([]TimeEntryPush, 0)
+	for i, p := range r.pushes {
+		if p.Status == PushPending && p.NextAttemptAt != nil && !p.NextAttemptAt.After(now) && len(due) < limit {
+			r.pushes[i].NextAttemptAt = &leaseUntil
+			due = append(due, r.pushes[i])
+		}
+	}
+	return due, nil
+}
+
+func (r *TimeTrackingRepositoryStub) UpdateTimeEntryPush(ctx context.Context, push TimeEntryPush) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, p := range r.pushes {
+		if p.Id == push.Id && p.Version == push.Version {
+			r.pushes[i] = push
+		}
+	}
+	return nil
+}
+
+func (r *TimeTrackingRepositoryStub) GetPushedTimeEntry(ctx context.Context, userId int, eventUid string) (*PushedTimeEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entry, ok := r.pushed[userId][eventUid]
+	if !ok {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func (r *TimeTrackingRepositoryStub) StorePushedTimeEntry(ctx context.Context, userId int, entry PushedTimeEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pushed[userId] == nil {
+		r.pushed[userId] = make(map[string]PushedTimeEntry)
+	}
+	r.pushed[userId][entry.EventUid] = entry
+	return nil
+}
+
+func (r *TimeTrackingRepositoryStub) DeletePushedTimeEntry(ctx context.Context, userId int, eventUid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pushed[userId], eventUid)
+	return nil
+}
+
+// Pushes returns all pushes, the oldest first
+func (r *TimeTrackingRepositoryStub) Pushes() []TimeEntryPush {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.pushes)
+}
@@ -0,0 +1,258 @@
+package clickup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const trackedBudgetItemId = 11
+
+var trackingNow = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+type usersProviderStub struct{}
+
+func (u usersProviderStub) GetUser(ctx context.Context, id int) (user.User, error) {
+	return user.User{Id: id}, nil
+}
+
+func setupTimeTrackingTest(t *testing.T) (*TimeTrackingServiceImpl, *TimeTrackingRepositoryStub, *ClientStub,
+	*event_bus.EventBus, context.Context) {
+	repo := NewRepositoryStub()
+	timeTrackingRepo := NewTimeTrackingRepositoryStub()
+	client := NewClientStub()
+	eventBus := event_bus.NewEventBus()
+	service, err := NewTimeTrackingService(repo, timeTrackingRepo, client, usersProviderStub{}, eventBus)
+	require.NoError(t, err)
+	service.clock = &utils.MockClock{FixedNow: trackingNow}
+	ctx := ctxWithUserId(testUserId)
+
+	err = repo.StoreConfiguration(ctx, testUserId, 1, Configuration{
+		WorkspaceId: "100",
+		Mappings:    []BudgetItemMapping{{ClickupSpaceId: "200", BudgetItemId: trackedBudgetItemId}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, service.SetTimeTrackingEnabled(ctx, true))
+	return service, timeTrackingRepo, client, eventBus, ctx
+}
+
+func publishCreated(t *testing.T, eventBus *event_bus.EventBus, ctx context.Context, uid, taskId string, end time.Time) {
+	err := eventBus.Publish(event_bus.NewEvent(ctx, "calendar.event.created", event_bus.CalendarEventCreated{
+		UID:          uid,
+		Summary:      "Work",
+		StartTime:    trackingNow.Add(-time.Hour),
+		EndTime:      end,
+		BudgetItemId: trackedBudgetItemId,
+		TaskId:       taskId,
+	}))
+	require.NoError(t, err)
+}
+
+func publishUpdated(t *testing.T, eventBus *event_bus.EventBus, ctx context.Context, uid, taskId string, end time.Time) {
+	err := eventBus.Publish(event_bus.NewEvent(ctx, "calendar.event.updated", event_bus.CalendarEventUpdated{
+		UID:          uid,
+		Summary:      "Work",
+		StartTime:    trackingNow.Add(-time.Hour),
+		EndTime:      end,
+		BudgetItemId: trackedBudgetItemId,
+		TaskId:       taskId,
+	}))
+	require.NoError(t, err)
+}
+
+func TestTimeTrackingService_Push(t *testing.T) {
+	t.Run("should create time entry for event linked to a task", func(t *testing.T) {
+		// given
+		service, _, client, eventBus, ctx := setupTimeTrackingTest(t)
+		publishCreated(t, eventBus, ctx, "event-1", "task-1", trackingNow)
+
+		// when
+		attempted, err := service.PushDue(ctx, trackingNow)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, attempted)
+		entries := client.TimeEntries()
+		require.Len(t, entries, 1)
+		for _, entry := range entries {
+			assert.Equal(t, "task-1", entry.TaskId)
+			assert.Equal(t, "Work", entry.Description)
+			assert.Equal(t, trackingNow.Add(-time.Hour), entry.Start)
+			assert.Equal(t, trackingNow, entry.End)
+		}
+	})
+
+	t.Run("should not attempt pushes claimed by another pusher", func(t *testing.T) {
+		// given
+		service, timeTrackingRepo, client, eventBus, ctx := setupTimeTrackingTest(t)
+		publishCreated(t, eventBus, ctx, "event-1", "task-1", trackingNow)
+		claimed, err := timeTrackingRepo.ClaimDueTimeEntryPushes(ctx, trackingNow, pushLease, pushesPerRun)
+		require.NoError(t, err)
+		require.Len(t, claimed, 1)
+
+		// when
+		attempted, err := service.PushDue(ctx, trackingNow)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 0, attempted)
+		assert.Empty(t, client.TimeEntries())
+	})
+
+	t.Run("should skip events without task, sandbox events and users with time tracking disabled", func(t *testing.T) {
+		// given
+		service, timeTrackingRepo, client, eventBus, ctx := setupTimeTrackingTest(t)
+		publishCreated(t, eventBus, ctx, "event-1", "", trackingNow)
+		err := eventBus.Publish(event_bus.NewEvent(ctx, "calendar.event.created", event_bus.CalendarEventCreated{
+			UID: "event-2", BudgetItemId: trackedBudgetItemId, TaskId: "task-1", Sandbox: true,
+		}))
+		require.NoError(t, err)
+		otherCtx := ctxWithUserId(testUserId + 1)
+		publishCreated(t, eventBus, otherCtx, "event-3", "task-1", trackingNow)
+
+		// when
+		attempted, err := service.PushDue(ctx, trackingNow)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 0, attempted)
+		assert.Empty(t, timeTrackingRepo.Pushes())
+		assert.Empty(t, client.TimeEntries())
+	})
+
+	t.Run("should update time entry when event is modified", func(t *testing.T) {
+		// given
+		service, _, client, eventBus, ctx := setupTimeTrackingTest(t)
+		publishCreated(t, eventBus, ctx, "event-1", "task-1", trackingNow)
+		_, err := service.PushDue(ctx, trackingNow)
+		require.NoError(t, err)
+
+		// when
+		publishUpdated(t, eventBus, ctx, "event-1", "task-1", trackingNow.Add(30*time.Minute))
+		_, err = service.PushDue(ctx, trackingNow)
+
+		// then
+		require.NoError(t, err)
+		entries := client.TimeEntries()
+		require.Len(t, entries, 1)
+		assert.Equal(t, trackingNow.Add(30*time.Minute), entries["1"].End)
+	})
+
+	t.Run("should delete time entry when event is deleted or unlinked from task", func(t *testing.T) {
+		// given
+		service, _, client, eventBus, ctx := setupTimeTrackingTest(t)
+		publishCreated(t, eventBus, ctx, "event-1", "task-1", trackingNow)
+		publishCreated(t, eventBus, ctx, "event-2", "task-2", trackingNow)
+		_, err := service.PushDue(ctx, trackingNow)
+		require.NoError(t, err)
+		require.Len(t, client.TimeEntries(), 2)
+
+		// when
+		err = eventBus.Publish(event_bus.NewEvent(ctx, "calendar.event.deleted", event_bus.CalendarEventDeleted{UID: "event-1"}))
+		require.NoError(t, err)
+		publishUpdated(t, eventBus, ctx, "event-2", "", trackingNow)
+		_, err = service.PushDue(ctx, trackingNow)
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, client.TimeEntries())
+	})
+
+	t.Run("should create time entry again when it was deleted in ClickUp", func(t *testing.T) {
+		// given
+		service, _, client, eventBus, ctx := setupTimeTrackingTest(t)
+		publishCreated(t, eventBus, ctx, "event-1", "task-1", trackingNow)
+		_, err := service.PushDue(ctx, trackingNow)
+		require.NoError(t, err)
+		require.NoError(t, client.DeleteTimeEntry(ctx, "100", "1"))
+
+		// when
+		publishUpdated(t, eventBus, ctx, "event-1", "task-1", trackingNow)
+		_, err = service.PushDue(ctx, trackingNow)
+
+		// then
+		require.NoError(t, err)
+		entries := client.TimeEntries()
+		require.Len(t, entries, 1)
+		assert.Contains(t, entries, "2")
+	})
+
+	t.Run("should coalesce changes of an event not pushed yet", func(t *testing.T) {
+		// given
+		service, timeTrackingRepo, client, eventBus, ctx := setupTimeTrackingTest(t)
+		publishCreated(t, eventBus, ctx, "event-1", "task-1", trackingNow)
+		publishUpdated(t, eventBus, ctx, "event-1", "task-1", trackingNow.Add(time.Hour))
+
+		// when
+		attempted, err := service.PushDue(ctx, trackingNow)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, attempted)
+		assert.Len(t, timeTrackingRepo.Pushes(), 1)
+		entries := client.TimeEntries()
+		require.Len(t, entries, 1)
+		assert.Equal(t, trackingNow.Add(time.Hour), entries["1"].End)
+	})
+}
+
+func TestTimeTrackingService_Retry(t *testing.T) {
+	t.Run("should retry failed push with backoff", func(t *testing.T) {
+		// given
+		service, timeTrackingRepo, client, eventBus, ctx := setupTimeTrackingTest(t)
+		publishCreated(t, eventBus, ctx, "event-1", "task-1", trackingNow)
+		client.SetTimeEntryError(errors.New("clickup unavailable"))
+
+		// when
+		_, err := service.PushDue(ctx, trackingNow)
+
+		// then
+		require.NoError(t, err)
+		push := timeTrackingRepo.Pushes()[0]
+		assert.Equal(t, PushPending, push.Status)
+		assert.Equal(t, 1, push.Attempts)
+		assert.Equal(t, "clickup unavailable", push.LastError)
+		require.NotNil(t, push.NextAttemptAt)
+		assert.Equal(t, trackingNow.Add(time.Minute), *push.NextAttemptAt)
+
+		// and when ClickUp recovers
+		client.SetTimeEntryError(nil)
+		attempted, err := service.PushDue(ctx, trackingNow.Add(30*time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, 0, attempted)
+		_, err = service.PushDue(ctx, trackingNow.Add(time.Minute))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, PushSucceeded, timeTrackingRepo.Pushes()[0].Status)
+		assert.Len(t, client.TimeEntries(), 1)
+	})
+
+	t.Run("should fail push after the last attempt", func(t *testing.T) {
+		// given
+		service, timeTrackingRepo, client, eventBus, ctx := setupTimeTrackingTest(t)
+		publishCreated(t, eventBus, ctx, "event-1", "task-1", trackingNow)
+		client.SetTimeEntryError(errors.New("clickup unavailable"))
+
+		// when
+		now := trackingNow
+		for range len(pushRetryDelays) + 1 {
+			_, err := service.PushDue(ctx, now)
+			require.NoError(t, err)
+			now = now.Add(24 * time.Hour)
+		}
+
+		// then
+		push := timeTrackingRepo.Pushes()[0]
+		assert.Equal(t, PushFailed, push.Status)
+		assert.Equal(t, len(pushRetryDelays)+1, push.Attempts)
+		assert.Nil(t, push.NextAttemptAt)
+	})
+}
@@ -0,0 +1,83 @@
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix marks encrypted values, values without it were stored before tokens were encrypted.
+const encryptedPrefix = "enc:v1:"
+
+var ErrMissingKey = errors.New("token is encrypted but no encryption key is configured")
+
+// Cipher encrypts tokens with AES-256-GCM. Without a key tokens are stored as they are.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a cipher from a base64 encoded 32 byte key, an empty key disables encryption.
+func NewCipher(key string) (*Cipher, error) {
+	if key == "" {
+		return &Cipher{}, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key is not valid base64: %w", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(raw))
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+func (c *Cipher) Enabled() bool {
+	return c.aead != nil
+}
+
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	if c.aead == nil || plaintext == "" {
+		return plaintext, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *Cipher) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if c.aead == nil {
+		return "", ErrMissingKey
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", errors.New("encrypted token is malformed")
+	}
+	nonceSize := c.aead.NonceSize()
+	plaintext, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to decrypt token, was the encryption key changed? %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
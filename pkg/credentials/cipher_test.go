@@ -0,0 +1,75 @@
+package credentials
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKey(t *testing.T) string {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func TestCipher(t *testing.T) {
+	t.Run("should encrypt and decrypt token", func(t *testing.T) {
+		cipher, err := NewCipher(newTestKey(t))
+		require.NoError(t, err)
+
+		encrypted, err := cipher.Encrypt("access-token")
+		require.NoError(t, err)
+		assert.True(t, IsEncrypted(encrypted))
+		assert.NotContains(t, encrypted, "access-token")
+
+		decrypted, err := cipher.Decrypt(encrypted)
+		require.NoError(t, err)
+		assert.Equal(t, "access-token", decrypted)
+	})
+
+	t.Run("should read tokens stored before encryption", func(t *testing.T) {
+		cipher, err := NewCipher(newTestKey(t))
+		require.NoError(t, err)
+
+		decrypted, err := cipher.Decrypt("plain-token")
+
+		require.NoError(t, err)
+		assert.Equal(t, "plain-token", decrypted)
+	})
+
+	t.Run("should fail to decrypt with another key", func(t *testing.T) {
+		cipher, err := NewCipher(newTestKey(t))
+		require.NoError(t, err)
+		other, err := NewCipher(newTestKey(t))
+		require.NoError(t, err)
+		encrypted, err := cipher.Encrypt("access-token")
+		require.NoError(t, err)
+
+		_, err = other.Decrypt(encrypted)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("should fail to decrypt without key", func(t *testing.T) {
+		cipher, err := NewCipher(newTestKey(t))
+		require.NoError(t, err)
+		encrypted, err := cipher.Encrypt("access-token")
+		require.NoError(t, err)
+		noKey, err := NewCipher("")
+		require.NoError(t, err)
+
+		_, err = noKey.Decrypt(encrypted)
+
+		assert.ErrorIs(t, err, ErrMissingKey)
+	})
+
+	t.Run("should reject key of wrong length", func(t *testing.T) {
+		_, err := NewCipher(base64.StdEncoding.EncodeToString([]byte("too-short")))
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,57 @@
+// Package credentials stores the tokens users grant Klokku for third-party providers. Tokens are encrypted with
+// the server key before they are written to the database, and refreshes of the same token are serialized with a
+// row lock so concurrent requests and instances do not each spend the refresh token.
+package credentials
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+type Provider string
+
+const (
+	ClickUp Provider = "clickup"
+	Outlook Provider = "outlook"
+	Google  Provider = "google"
+	Toggl   Provider = "toggl"
+)
+
+var (
+	ErrNonceNotFound = errors.New("no pending authentication for the nonce")
+	// ErrEncryptionDisabled is returned when a token is stored without an encryption key configured.
+	ErrEncryptionDisabled = errors.New("no credentials encryption key configured, tokens cannot be stored")
+)
+
+// Revoker invalidates a token at the provider, so it cannot be used even when a copy of it leaked.
+type Revoker func(ctx context.Context, token *oauth2.Token) error
+
+// providerTable is the table holding a provider's tokens, one row per user with access_token, refresh_token,
+// expiry and the nonce of the pending authentication.
+type providerTable struct {
+	name string
+	// active is an extra condition a row has to meet for its token to be used
+	active string
+}
+
+var tables = map[Provider]providerTable{
+	ClickUp: {name: "clickup_auth", active: "disabled_at IS NULL"},
+	Outlook: {name: "outlook_auth", active: "TRUE"},
+	Google:  {name: "google_calendar_auth", active: "TRUE"},
+	Toggl:   {name: "toggl_auth", active: "TRUE"},
+}
+
+// Providers returns all providers with stored tokens.
+func Providers() []Provider {
+	return []Provider{ClickUp, Outlook, Google, Toggl}
+}
+
+// StoredToken is a token as stored in the database, with the access and refresh token encrypted.
+type StoredToken struct {
+	AccessToken  string
+	RefreshToken string
+	Expiry       *time.Time
+}
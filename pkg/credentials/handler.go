@@ -0,0 +1,34 @@
+package credentials
+
+import (
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// RevokeAll godoc
+// @Summary Revoke all third-party tokens
+// @Description Revoke and forget the tokens the current user granted Klokku for all third-party providers (ClickUp,
+// @Description Outlook, Google, Toggl). Tokens are revoked at the providers that support it.
+// @Description Integrations stop working until the user authenticates with the provider again.
+// @Tags Integrations
+// @Success 204 "No Content"
+// @Failure 403 {string} string "User not found"
+// @Router /api/integrations/credentials [delete]
+// @Security XUserId
+func (h *Handler) RevokeAll(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.RevokeAll(r.Context()); err != nil {
+		log.Errorf("Failed to revoke third-party tokens: %v", err)
+		http.Error(w, "Failed to revoke third-party tokens", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
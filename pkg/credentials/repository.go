@@ -0,0 +1,159 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository interface {
+	// GetToken returns nil when the user has not finished authenticating with the provider.
+	GetToken(ctx context.Context, provider Provider, userId int) (*StoredToken, error)
+	// StoreTokenByNonce stores the token of the pending authentication and returns its user.
+	StoreTokenByNonce(ctx context.Context, provider Provider, nonce string, token StoredToken) (int, error)
+	// StoreToken stores a token the user entered, for providers without an OAuth flow.
+	StoreToken(ctx context.Context, provider Provider, userId int, token StoredToken) error
+	// StartAuthentication stores the nonce of a pending authentication, keeping the current token until the new
+	// one is stored.
+	StartAuthentication(ctx context.Context, provider Provider, userId int, nonce string) error
+	// UpdateToken passes the stored token to update while the row is locked and stores the token update
+	// returns, unless it is nil. Other updates of the same token wait until it returns.
+	UpdateToken(ctx context.Context, provider Provider, userId int, update func(current StoredToken) (*StoredToken, error)) error
+	DeleteToken(ctx context.Context, provider Provider, userId int) error
+	GetUserIdsWithToken(ctx context.Context, provider Provider) ([]int, error)
+	// Ping returns an error when the tokens of any provider cannot be read.
+	Ping(ctx context.Context) error
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+func (r *RepositoryImpl) GetToken(ctx context.Context, provider Provider, userId int) (*StoredToken, error) {
+	table := tables[provider]
+	query := `SELECT access_token, refresh_token, expiry FROM ` + table.name + `
+			  WHERE user_id = $1 AND access_token IS NOT NULL AND ` + table.active
+	token, err := scanToken(r.db.QueryRow(ctx, query, userId))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get %s token: %w", provider, err)
+	}
+	return &token, nil
+}
+
+func (r *RepositoryImpl) StoreTokenByNonce(ctx context.Context, provider Provider, nonce string, token StoredToken) (int, error) {
+	query := `UPDATE ` + tables[provider].name + `
+			  SET access_token = $1, refresh_token = $2, expiry = $3, nonce = NULL
+			  WHERE nonce = $4
+			  RETURNING user_id`
+	var userId int
+	err := r.db.QueryRow(ctx, query, token.AccessToken, token.RefreshToken, token.Expiry, nonce).Scan(&userId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrNonceNotFound
+		}
+		return 0, fmt.Errorf("failed to store %s token: %w", provider, err)
+	}
+	return userId, nil
+}
+
+func (r *RepositoryImpl) StoreToken(ctx context.Context, provider Provider, userId int, token StoredToken) error {
+	query := `INSERT INTO ` + tables[provider].name + ` (user_id, access_token, refresh_token, expiry)
+			  VALUES ($1, $2, $3, $4)
+			  ON CONFLICT (user_id) DO UPDATE SET
+				  access_token = EXCLUDED.access_token,
+				  refresh_token = EXCLUDED.refresh_token,
+				  expiry = EXCLUDED.expiry,
+				  nonce = NULL`
+	_, err := r.db.Exec(ctx, query, userId, token.AccessToken, token.RefreshToken, token.Expiry)
+	if err != nil {
+		return fmt.Errorf("failed to store %s token: %w", provider, err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) StartAuthentication(ctx context.Context, provider Provider, userId int, nonce string) error {
+	query := `INSERT INTO ` + tables[provider].name + ` (user_id, nonce) VALUES ($1, $2)
+			  ON CONFLICT (user_id) DO UPDATE SET nonce = EXCLUDED.nonce`
+	if _, err := r.db.Exec(ctx, query, userId, nonce); err != nil {
+		return fmt.Errorf("failed to store %s auth nonce: %w", provider, err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) UpdateToken(ctx context.Context, provider Provider, userId int,
+	update func(current StoredToken) (*StoredToken, error)) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	table := tables[provider].name
+	current, err := scanToken(tx.QueryRow(ctx,
+		`SELECT access_token, refresh_token, expiry FROM `+table+` WHERE user_id = $1 AND access_token IS NOT NULL FOR UPDATE`,
+		userId))
+	if err != nil {
+		return fmt.Errorf("failed to lock %s token: %w", provider, err)
+	}
+	updated, err := update(current)
+	if err != nil {
+		return err
+	}
+	if updated == nil {
+		return nil
+	}
+	_, err = tx.Exec(ctx, `UPDATE `+table+` SET access_token = $1, refresh_token = $2, expiry = $3 WHERE user_id = $4`,
+		updated.AccessToken, updated.RefreshToken, updated.Expiry, userId)
+	if err != nil {
+		return fmt.Errorf("failed to update %s token: %w", provider, err)
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *RepositoryImpl) DeleteToken(ctx context.Context, provider Provider, userId int) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM `+tables[provider].name+` WHERE user_id = $1`, userId); err != nil {
+		return fmt.Errorf("failed to delete %s token: %w", provider, err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) GetUserIdsWithToken(ctx context.Context, provider Provider) ([]int, error) {
+	rows, err := r.db.Query(ctx, `SELECT user_id FROM `+tables[provider].name+` WHERE access_token IS NOT NULL ORDER BY user_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users with %s token: %w", provider, err)
+	}
+	userIds, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users with %s token: %w", provider, err)
+	}
+	return userIds, nil
+}
+
+func scanToken(row pgx.Row) (StoredToken, error) {
+	var token StoredToken
+	var refreshToken *string
+	err := row.Scan(&token.AccessToken, &refreshToken, &token.Expiry)
+	if refreshToken != nil {
+		token.RefreshToken = *refreshToken
+	}
+	return token, err
+}
+
+func (r *RepositoryImpl) Ping(ctx context.Context) error {
+	for _, provider := range Providers() {
+		if _, err := r.db.Exec(ctx, `SELECT 1 FROM `+tables[provider].name+` LIMIT 1`); err != nil {
+			return fmt.Errorf("failed to read %s tokens: %w", provider, err)
+		}
+	}
+	return nil
+}
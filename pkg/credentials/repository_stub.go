@@ -0,0 +1,118 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+type stubKey struct {
+	provider Provider
+	userId   int
+}
+
+type RepositoryStub struct {
+	mu     sync.Mutex
+	tokens map[stubKey]StoredToken
+	nonces map[string]stubKey
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{
+		tokens: make(map[stubKey]StoredToken),
+		nonces: make(map[string]stubKey),
+	}
+}
+
+func (r *RepositoryStub) GetToken(ctx context.Context, provider Provider, userId int) (*StoredToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[stubKey{provider, userId}]
+	if !ok {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+func (r *RepositoryStub) StoreTokenByNonce(ctx context.Context, provider Provider, nonce string, token StoredToken) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, ok := r.nonces[nonce]
+	if !ok || key.provider != provider {
+		return 0, ErrNonceNotFound
+	}
+	delete(r.nonces, nonce)
+	r.tokens[key] = token
+	return key.userId, nil
+}
+
+func (r *RepositoryStub) StoreToken(ctx context.Context, provider Provider, userId int, token StoredToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[stubKey{provider, userId}] = token
+	return nil
+}
+
+func (r *RepositoryStub) StartAuthentication(ctx context.Context, provider Provider, userId int, nonce string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nonces[nonce] = stubKey{provider, userId}
+	return nil
+}
+
+// UpdateToken holds the lock during update like the row lock of the database
+func (r *RepositoryStub) UpdateToken(ctx context.Context, provider Provider, userId int,
+	update func(current StoredToken) (*StoredToken, error)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := stubKey{provider, userId}
+	current, ok := r.tokens[key]
+	if !ok {
+		return errors.New("token not found")
+	}
+	updated, err := update(current)
+	if err != nil || updated == nil {
+		return err
+	}
+	r.tokens[key] = *updated
+	return nil
+}
+
+func (r *RepositoryStub) DeleteToken(ctx context.Context, provider Provider, userId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tokens, stubKey{provider, userId})
+	return nil
+}
+
+func (r *RepositoryStub) GetUserIdsWithToken(ctx context.Context, provider Provider) ([]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	userIds := make([]int, 0)
+	for key := range r.tokens {
+		if key.provider == provider {
+			userIds = append(userIds, key.userId)
+		}
+	}
+	sort.Ints(userIds)
+	return userIds, nil
+}
+
+// SetNonce starts a pending authentication of the user
+func (r *RepositoryStub) SetNonce(provider Provider, userId int, nonce string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nonces[nonce] = stubKey{provider, userId}
+}
+
+// SetToken stores the token as it is, without encryption
+func (r *RepositoryStub) SetToken(provider Provider, userId int, token StoredToken) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[stubKey{provider, userId}] = token
+}
+
+func (r *RepositoryStub) Ping(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,280 @@
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+type Service interface {
+	// GetToken returns nil when the user has not authenticated with the provider.
+	GetToken(ctx context.Context, provider Provider, userId int) (*oauth2.Token, error)
+	// StoreTokenByNonce stores the token obtained by the pending authentication and returns its user.
+	StoreTokenByNonce(ctx context.Context, provider Provider, nonce string, token *oauth2.Token) (int, error)
+	// StoreToken stores a token the user entered, for providers without an OAuth flow like Toggl.
+	StoreToken(ctx context.Context, provider Provider, userId int, token *oauth2.Token) error
+	// StartAuthentication remembers the nonce of the user's pending authentication with the provider.
+	StartAuthentication(ctx context.Context, provider Provider, userId int, nonce string) error
+	// TokenSource returns the user's token and refreshes it with config once it expires. The refreshed
+	// token is stored. The HTTP client refreshing the token is taken from ctx like in oauth2.Config.
+	TokenSource(ctx context.Context, provider Provider, userId int, config *oauth2.Config, token *oauth2.Token) oauth2.TokenSource
+	// Revoke revokes the token at the provider, when it has a revoker registered, and forgets it.
+	Revoke(ctx context.Context, provider Provider, userId int) error
+	// RevokeAll revokes the tokens of the current user for all providers.
+	RevokeAll(ctx context.Context) error
+	// EncryptPlaintextTokens encrypts the tokens stored before encryption was enabled.
+	EncryptPlaintextTokens(ctx context.Context)
+	// Ping returns an error when the token store is not available.
+	Ping(ctx context.Context) error
+}
+
+type ServiceImpl struct {
+	repo     Repository
+	cipher   *Cipher
+	eventBus *event_bus.EventBus
+	revokers map[Provider]Revoker
+}
+
+func NewService(repo Repository, cipher *Cipher, eventBus *event_bus.EventBus) *ServiceImpl {
+	if !cipher.Enabled() {
+		log.Warn("No credentials encryption key configured, integrations cannot be connected until one is set")
+	}
+	return &ServiceImpl{repo: repo, cipher: cipher, eventBus: eventBus, revokers: make(map[Provider]Revoker)}
+}
+
+// RegisterRevoker registers the function revoking the provider's tokens, providers without one only have their
+// tokens forgotten.
+func (s *ServiceImpl) RegisterRevoker(provider Provider, revoker Revoker) {
+	s.revokers[provider] = revoker
+}
+
+func (s *ServiceImpl) GetToken(ctx context.Context, provider Provider, userId int) (*oauth2.Token, error) {
+	stored, err := s.repo.GetToken(ctx, provider, userId)
+	if err != nil || stored == nil {
+		return nil, err
+	}
+	return s.decrypt(*stored)
+}
+
+func (s *ServiceImpl) StoreTokenByNonce(ctx context.Context, provider Provider, nonce string, token *oauth2.Token) (int, error) {
+	stored, err := s.encrypt(token)
+	if err != nil {
+		return 0, err
+	}
+	userId, err := s.repo.StoreTokenByNonce(ctx, provider, nonce, stored)
+	if err != nil {
+		return 0, err
+	}
+	err = s.eventBus.Publish(event_bus.NewEvent(ctx, "integration.connected", event_bus.IntegrationConnected{
+		UserId:   userId,
+		Provider: string(provider),
+	}))
+	if err != nil {
+		log.Errorf("failed to publish integration.connected event: %v", err)
+	}
+	return userId, nil
+}
+
+func (s *ServiceImpl) StoreToken(ctx context.Context, provider Provider, userId int, token *oauth2.Token) error {
+	stored, err := s.encrypt(token)
+	if err != nil {
+		return err
+	}
+	return s.repo.StoreToken(ctx, provider, userId, stored)
+}
+
+func (s *ServiceImpl) StartAuthentication(ctx context.Context, provider Provider, userId int, nonce string) error {
+	if !s.cipher.Enabled() {
+		return ErrEncryptionDisabled
+	}
+	return s.repo.StartAuthentication(ctx, provider, userId, nonce)
+}
+
+func (s *ServiceImpl) TokenSource(ctx context.Context, provider Provider, userId int, config *oauth2.Config,
+	token *oauth2.Token) oauth2.TokenSource {
+	return &refreshingTokenSource{
+		// The token outlives the request that created it when it is cached by the client
+		ctx:      context.WithoutCancel(ctx),
+		service:  s,
+		provider: provider,
+		userId:   userId,
+		config:   config,
+		current:  token,
+	}
+}
+
+// refresh refreshes the token unless another request or instance refreshed it since stale was read, in which case
+// the stored token is returned.
+func (s *ServiceImpl) refresh(ctx context.Context, provider Provider, userId int, config *oauth2.Config,
+	stale *oauth2.Token) (*oauth2.Token, error) {
+	var refreshed *oauth2.Token
+	err := s.repo.UpdateToken(ctx, provider, userId, func(current StoredToken) (*StoredToken, error) {
+		token, err := s.decrypt(current)
+		if err != nil {
+			return nil, err
+		}
+		if token.AccessToken != stale.AccessToken && token.Valid() {
+			refreshed = token
+			return nil, nil
+		}
+		refreshed, err = config.TokenSource(ctx, &oauth2.Token{RefreshToken: token.RefreshToken}).Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to refresh %s token: %w", provider, err)
+		}
+		// Providers that do not rotate refresh tokens do not return one
+		if refreshed.RefreshToken == "" {
+			refreshed.RefreshToken = token.RefreshToken
+		}
+		stored, err := s.encrypt(refreshed)
+		if err != nil {
+			return nil, err
+		}
+		return &stored, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refreshed, nil
+}
+
+// Revoke forgets the token even when the provider fails to revoke it, the user asked for it to be gone.
+func (s *ServiceImpl) Revoke(ctx context.Context, provider Provider, userId int) error {
+	if revoker, ok := s.revokers[provider]; ok {
+		token, err := s.GetToken(ctx, provider, userId)
+		if err != nil {
+			log.Warnf("failed to read %s token of user %d to revoke it: %v", provider, userId, err)
+		} else if token != nil {
+			if err := revoker(ctx, token); err != nil {
+				log.Warnf("failed to revoke %s token of user %d at the provider: %v", provider, userId, err)
+			}
+		}
+	}
+	return s.repo.DeleteToken(ctx, provider, userId)
+}
+
+func (s *ServiceImpl) RevokeAll(ctx context.Context) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.RevokeUser(ctx, userId)
+}
+
+// RevokeUser revokes the tokens of the user for all providers. It runs before a user is deleted, while the tokens
+// can still be read.
+func (s *ServiceImpl) RevokeUser(ctx context.Context, userId int) error {
+	for _, provider := range Providers() {
+		if err := s.Revoke(ctx, provider, userId); err != nil {
+			return err
+		}
+	}
+	log.Infof("Revoked all third-party tokens of user %d", userId)
+	return nil
+}
+
+func (s *ServiceImpl) EncryptPlaintextTokens(ctx context.Context) {
+	if !s.cipher.Enabled() {
+		return
+	}
+	encrypted := 0
+	for _, provider := range Providers() {
+		userIds, err := s.repo.GetUserIdsWithToken(ctx, provider)
+		if err != nil {
+			log.Errorf("failed to encrypt %s tokens: %v", provider, err)
+			continue
+		}
+		for _, userId := range userIds {
+			err := s.repo.UpdateToken(ctx, provider, userId, func(current StoredToken) (*StoredToken, error) {
+				if IsEncrypted(current.AccessToken) {
+					return nil, nil
+				}
+				token, err := s.decrypt(current)
+				if err != nil {
+					return nil, err
+				}
+				stored, err := s.encrypt(token)
+				if err != nil {
+					return nil, err
+				}
+				encrypted++
+				return &stored, nil
+			})
+			if err != nil {
+				log.Errorf("failed to encrypt %s token of user %d: %v", provider, userId, err)
+			}
+		}
+	}
+	if encrypted > 0 {
+		log.Infof("Encrypted %d third-party tokens stored in plaintext", encrypted)
+	}
+}
+
+func (s *ServiceImpl) Ping(ctx context.Context) error {
+	return s.repo.Ping(ctx)
+}
+
+// encrypt refuses to return the token without encryption, so no token is ever written in plaintext.
+func (s *ServiceImpl) encrypt(token *oauth2.Token) (StoredToken, error) {
+	if !s.cipher.Enabled() {
+		return StoredToken{}, ErrEncryptionDisabled
+	}
+	accessToken, err := s.cipher.Encrypt(token.AccessToken)
+	if err != nil {
+		return StoredToken{}, fmt.Errorf("failed to encrypt token: %w", err)
+	}
+	refreshToken, err := s.cipher.Encrypt(token.RefreshToken)
+	if err != nil {
+		return StoredToken{}, fmt.Errorf("failed to encrypt token: %w", err)
+	}
+	stored := StoredToken{AccessToken: accessToken, RefreshToken: refreshToken}
+	if !token.Expiry.IsZero() {
+		stored.Expiry = &token.Expiry
+	}
+	return stored, nil
+}
+
+func (s *ServiceImpl) decrypt(stored StoredToken) (*oauth2.Token, error) {
+	accessToken, err := s.cipher.Decrypt(stored.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := s.cipher.Decrypt(stored.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	token := &oauth2.Token{AccessToken: accessToken, RefreshToken: refreshToken}
+	if stored.Expiry != nil {
+		token.Expiry = *stored.Expiry
+	}
+	return token, nil
+}
+
+// refreshingTokenSource keeps the token of one user and refreshes it through the service when it expires.
+type refreshingTokenSource struct {
+	mu       sync.Mutex
+	ctx      context.Context
+	service  *ServiceImpl
+	provider Provider
+	userId   int
+	config   *oauth2.Config
+	current  *oauth2.Token
+}
+
+func (t *refreshingTokenSource) Token() (*oauth2.Token, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.current.Valid() {
+		return t.current, nil
+	}
+	token, err := t.service.refresh(t.ctx, t.provider, t.userId, t.config, t.current)
+	if err != nil {
+		return nil, err
+	}
+	t.current = token
+	return token, nil
+}
@@ -0,0 +1,295 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+const testUserId = 123
+
+func setupServiceTest(t *testing.T) (*ServiceImpl, *RepositoryStub, context.Context) {
+	cipher, err := NewCipher(newTestKey(t))
+	require.NoError(t, err)
+	repo := NewRepositoryStub()
+	ctx := user.WithUser(context.Background(), user.User{Id: testUserId})
+	return NewService(repo, cipher, event_bus.NewEventBus()), repo, ctx
+}
+
+// newTokenServer issues a new access and refresh token on every refresh and counts the refreshes
+func newTokenServer(t *testing.T, refreshes *atomic.Int32) *oauth2.Config {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := refreshes.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  fmt.Sprintf("access-%d", n),
+			"refresh_token": fmt.Sprintf("refresh-%d", n),
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+		})
+	}))
+	t.Cleanup(server.Close)
+	return &oauth2.Config{
+		ClientID: "client",
+		Endpoint: oauth2.Endpoint{TokenURL: server.URL, AuthStyle: oauth2.AuthStyleInParams},
+	}
+}
+
+func TestServiceImpl_StoreTokenByNonce(t *testing.T) {
+	t.Run("should store token encrypted", func(t *testing.T) {
+		// given
+		service, repo, ctx := setupServiceTest(t)
+		repo.SetNonce(Outlook, testUserId, "nonce-1")
+
+		// when
+		userId, err := service.StoreTokenByNonce(ctx, Outlook, "nonce-1",
+			&oauth2.Token{AccessToken: "access-token", RefreshToken: "refresh-token"})
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, testUserId, userId)
+		stored, err := repo.GetToken(ctx, Outlook, testUserId)
+		require.NoError(t, err)
+		assert.True(t, IsEncrypted(stored.AccessToken))
+		assert.True(t, IsEncrypted(stored.RefreshToken))
+		token, err := service.GetToken(ctx, Outlook, testUserId)
+		require.NoError(t, err)
+		assert.Equal(t, "access-token", token.AccessToken)
+		assert.Equal(t, "refresh-token", token.RefreshToken)
+	})
+
+	t.Run("should fail for unknown nonce", func(t *testing.T) {
+		// given
+		service, _, ctx := setupServiceTest(t)
+
+		// when
+		_, err := service.StoreTokenByNonce(ctx, Outlook, "unknown", &oauth2.Token{AccessToken: "access-token"})
+
+		// then
+		assert.ErrorIs(t, err, ErrNonceNotFound)
+	})
+
+	t.Run("should refuse to store token without encryption key", func(t *testing.T) {
+		// given
+		cipher, err := NewCipher("")
+		require.NoError(t, err)
+		repo := NewRepositoryStub()
+		service := NewService(repo, cipher, event_bus.NewEventBus())
+		ctx := user.WithUser(context.Background(), user.User{Id: testUserId})
+		repo.SetNonce(Outlook, testUserId, "nonce-1")
+
+		// when
+		_, err = service.StoreTokenByNonce(ctx, Outlook, "nonce-1", &oauth2.Token{AccessToken: "access-token"})
+		startErr := service.StartAuthentication(ctx, Google, testUserId, "nonce-2")
+		storeErr := service.StoreToken(ctx, Toggl, testUserId, &oauth2.Token{AccessToken: "api-token"})
+
+		// then
+		assert.ErrorIs(t, err, ErrEncryptionDisabled)
+		assert.ErrorIs(t, startErr, ErrEncryptionDisabled)
+		assert.ErrorIs(t, storeErr, ErrEncryptionDisabled)
+		userIds, err := repo.GetUserIdsWithToken(ctx, Outlook)
+		require.NoError(t, err)
+		assert.Empty(t, userIds)
+	})
+}
+
+func TestServiceImpl_StoreToken(t *testing.T) {
+	// given
+	service, repo, ctx := setupServiceTest(t)
+
+	// when
+	err := service.StoreToken(ctx, Toggl, testUserId, &oauth2.Token{AccessToken: "api-token"})
+
+	// then
+	require.NoError(t, err)
+	stored, err := repo.GetToken(ctx, Toggl, testUserId)
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(stored.AccessToken))
+	token, err := service.GetToken(ctx, Toggl, testUserId)
+	require.NoError(t, err)
+	assert.Equal(t, "api-token", token.AccessToken)
+}
+
+func TestServiceImpl_TokenSource(t *testing.T) {
+	expired := time.Now().Add(-time.Hour)
+
+	t.Run("should refresh expired token and store it", func(t *testing.T) {
+		// given
+		service, repo, ctx := setupServiceTest(t)
+		var refreshes atomic.Int32
+		config := newTokenServer(t, &refreshes)
+		repo.SetToken(Outlook, testUserId, StoredToken{AccessToken: "access-0", RefreshToken: "refresh-0", Expiry: &expired})
+		token, err := service.GetToken(ctx, Outlook, testUserId)
+		require.NoError(t, err)
+
+		// when
+		refreshed, err := service.TokenSource(ctx, Outlook, testUserId, config, token).Token()
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "access-1", refreshed.AccessToken)
+		stored, err := service.GetToken(ctx, Outlook, testUserId)
+		require.NoError(t, err)
+		assert.Equal(t, "access-1", stored.AccessToken)
+		assert.Equal(t, "refresh-1", stored.RefreshToken)
+	})
+
+	t.Run("should not refresh valid token", func(t *testing.T) {
+		// given
+		service, repo, ctx := setupServiceTest(t)
+		var refreshes atomic.Int32
+		config := newTokenServer(t, &refreshes)
+		repo.SetToken(ClickUp, testUserId, StoredToken{AccessToken: "access-0"})
+		token, err := service.GetToken(ctx, ClickUp, testUserId)
+		require.NoError(t, err)
+
+		// when
+		result, err := service.TokenSource(ctx, ClickUp, testUserId, config, token).Token()
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "access-0", result.AccessToken)
+		assert.Equal(t, int32(0), refreshes.Load())
+	})
+
+	t.Run("should refresh token once when requests refresh it concurrently", func(t *testing.T) {
+		// given
+		service, repo, ctx := setupServiceTest(t)
+		var refreshes atomic.Int32
+		config := newTokenServer(t, &refreshes)
+		repo.SetToken(Outlook, testUserId, StoredToken{AccessToken: "access-0", RefreshToken: "refresh-0", Expiry: &expired})
+
+		// when
+		var wg sync.WaitGroup
+		results := make([]string, 5)
+		for i := range results {
+			wg.Go(func() {
+				token, err := service.GetToken(ctx, Outlook, testUserId)
+				require.NoError(t, err)
+				refreshed, err := service.TokenSource(ctx, Outlook, testUserId, config, token).Token()
+				require.NoError(t, err)
+				results[i] = refreshed.AccessToken
+			})
+		}
+		wg.Wait()
+
+		// then
+		assert.Equal(t, int32(1), refreshes.Load())
+		for _, result := range results {
+			assert.Equal(t, "access-1", result)
+		}
+	})
+}
+
+func TestServiceImpl_RevokeAll(t *testing.T) {
+	// given
+	service, repo, ctx := setupServiceTest(t)
+	repo.SetToken(ClickUp, testUserId, StoredToken{AccessToken: "clickup"})
+	repo.SetToken(Outlook, testUserId, StoredToken{AccessToken: "outlook"})
+	repo.SetToken(Outlook, testUserId+1, StoredToken{AccessToken: "other-user"})
+
+	// when
+	err := service.RevokeAll(ctx)
+
+	// then
+	require.NoError(t, err)
+	for _, provider := range Providers() {
+		token, err := service.GetToken(ctx, provider, testUserId)
+		require.NoError(t, err)
+		assert.Nil(t, token)
+	}
+	token, err := service.GetToken(ctx, Outlook, testUserId+1)
+	require.NoError(t, err)
+	assert.NotNil(t, token)
+}
+
+func TestServiceImpl_Revoke(t *testing.T) {
+	t.Run("should revoke token at the provider", func(t *testing.T) {
+		// given
+		service, _, ctx := setupServiceTest(t)
+		require.NoError(t, service.StoreToken(ctx, Google, testUserId,
+			&oauth2.Token{AccessToken: "access-token", RefreshToken: "refresh-token"}))
+		var revoked []string
+		service.RegisterRevoker(Google, func(ctx context.Context, token *oauth2.Token) error {
+			revoked = append(revoked, token.RefreshToken)
+			return nil
+		})
+
+		// when
+		err := service.RevokeAll(ctx)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"refresh-token"}, revoked)
+		token, err := service.GetToken(ctx, Google, testUserId)
+		require.NoError(t, err)
+		assert.Nil(t, token)
+	})
+
+	t.Run("should forget token when the provider fails to revoke it", func(t *testing.T) {
+		// given
+		service, _, ctx := setupServiceTest(t)
+		require.NoError(t, service.StoreToken(ctx, Google, testUserId, &oauth2.Token{AccessToken: "access-token"}))
+		service.RegisterRevoker(Google, func(ctx context.Context, token *oauth2.Token) error {
+			return fmt.Errorf("provider unavailable")
+		})
+
+		// when
+		err := service.Revoke(ctx, Google, testUserId)
+
+		// then
+		require.NoError(t, err)
+		token, err := service.GetToken(ctx, Google, testUserId)
+		require.NoError(t, err)
+		assert.Nil(t, token)
+	})
+}
+
+func TestServiceImpl_RevokeUser(t *testing.T) {
+	// given
+	service, repo, _ := setupServiceTest(t)
+	repo.SetToken(ClickUp, testUserId, StoredToken{AccessToken: "clickup"})
+	repo.SetToken(Outlook, testUserId+1, StoredToken{AccessToken: "other-user"})
+
+	// when
+	err := service.RevokeUser(context.Background(), testUserId)
+
+	// then
+	require.NoError(t, err)
+	token, err := service.GetToken(context.Background(), ClickUp, testUserId)
+	require.NoError(t, err)
+	assert.Nil(t, token)
+	token, err = service.GetToken(context.Background(), Outlook, testUserId+1)
+	require.NoError(t, err)
+	assert.NotNil(t, token)
+}
+
+func TestServiceImpl_EncryptPlaintextTokens(t *testing.T) {
+	// given
+	service, repo, ctx := setupServiceTest(t)
+	repo.SetToken(ClickUp, testUserId, StoredToken{AccessToken: "access-token", RefreshToken: "refresh-token"})
+
+	// when
+	service.EncryptPlaintextTokens(ctx)
+
+	// then
+	stored, err := repo.GetToken(ctx, ClickUp, testUserId)
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(stored.AccessToken))
+	token, err := service.GetToken(ctx, ClickUp, testUserId)
+	require.NoError(t, err)
+	assert.Equal(t, "access-token", token.AccessToken)
+	assert.Equal(t, "refresh-token", token.RefreshToken)
+}
@@ -8,6 +8,18 @@ type CurrentEvent struct {
 	Id        int
 	PlanItem  PlanItem
 	StartTime time.Time
+	Notes     string
+	// TaskId is an optional reference to an external task (e.g. ClickUp task id).
+	// It is carried into the calendar event metadata when the event is finished.
+	TaskId string
+	// Location is where the time is spent, free text (e.g. "office") or coordinates (e.g. "52.2297,21.0122").
+	// It is carried into the calendar event metadata when the event is finished.
+	Location string
+	// EndTime is set only for a finished event, zero while it is running.
+	EndTime time.Time
+	// RoundedDuration is set only for a finished event of a user with rounding enabled, it is the duration rounded
+	// according to the user's settings. The times stored in the calendar are not rounded.
+	RoundedDuration time.Duration
 }
 
 type PlanItem struct {
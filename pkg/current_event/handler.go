@@ -3,16 +3,29 @@ package current_event
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/pkg/calendar"
 	log "github.com/sirupsen/logrus"
 )
 
 type CurrentEventDTO struct {
 	PlanItem  PlanItemDTO `json:"planItem"`
 	StartTime string      `json:"startTime"`
+	Notes     string      `json:"notes,omitempty"`
+	TaskId    string      `json:"taskId,omitempty"`
+	// Location is free text (e.g. "office") or coordinates (e.g. "52.2297,21.0122")
+	Location string `json:"location,omitempty"`
+	// EndTime is set only for a stopped event
+	EndTime string `json:"endTime,omitempty"`
+	// RoundedDuration in seconds is set only for a stopped event of a user with rounding enabled, it is the duration
+	// rounded according to the user's rounding settings
+	RoundedDuration int `json:"roundedDuration,omitempty"`
 }
 
 type PlanItemDTO struct {
@@ -39,9 +52,11 @@ func NewEventHandler(eventService Service) *EventHandler {
 // @Tags CurrentEvent
 // @Accept json
 // @Produce json
-// @Param event body object{budgetItemId=int,name=string,weeklyDuration=int} true "Event start details"
+// @Param event body object{budgetItemId=int,name=string,weeklyDuration=int,notes=string,taskId=string,location=string} true "Event start details"
 // @Success 201 {object} CurrentEventDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
 // @Failure 403 {string} string "User not found"
+// @Failure 409 {string} string "Finished event overlaps existing calendar events (strict calendar mode)"
 // @Router /api/event [post]
 // @Security XUserId
 func (e *EventHandler) StartEvent(w http.ResponseWriter, r *http.Request) {
@@ -52,6 +67,9 @@ func (e *EventHandler) StartEvent(w http.ResponseWriter, r *http.Request) {
 		BudgetItemId   int    `json:"budgetItemId"`
 		Name           string `json:"name"`
 		WeeklyDuration int    `json:"weeklyDuration"`
+		Notes          string `json:"notes"`
+		TaskId         string `json:"taskId"`
+		Location       string `json:"location"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&startEventRequest); err != nil {
@@ -67,6 +85,11 @@ func (e *EventHandler) StartEvent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Debug("New current event request: ", startEventRequest)
+	location, err := parseLocation(startEventRequest.Location)
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
 
 	startTime := time.Now()
 
@@ -77,10 +100,17 @@ func (e *EventHandler) StartEvent(w http.ResponseWriter, r *http.Request) {
 			Name:           startEventRequest.Name,
 			WeeklyDuration: time.Duration(startEventRequest.WeeklyDuration) * time.Second,
 		},
+		Notes:    startEventRequest.Notes,
+		TaskId:   startEventRequest.TaskId,
+		Location: location,
 	}
 
 	storedEvent, err := e.eventService.StartNewEvent(r.Context(), *event)
 	if err != nil {
+		if errors.Is(err, calendar.ErrEventOverlap) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -123,6 +153,41 @@ func (e *EventHandler) GetCurrentEvent(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// StopEvent godoc
+// @Summary Stop the current event
+// @Description Stop the currently running event without starting another one. It is stored in the calendar. The
+// @Description stopped event has its end time, and the duration rounded according to the user's rounding settings.
+// @Tags CurrentEvent
+// @Produce json
+// @Success 200 {object} CurrentEventDTO "The stopped event"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "No current event"
+// @Failure 409 {string} string "Finished event overlaps existing calendar events (strict calendar mode)"
+// @Router /api/event/current [delete]
+// @Security XUserId
+func (e *EventHandler) StopEvent(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	stoppedEvent, err := e.eventService.StopCurrentEvent(r.Context())
+	if err != nil {
+		if errors.Is(err, ErrNoCurrentEvent) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, calendar.ErrEventOverlap) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(eventToDTO(stoppedEvent)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
 // ModifyCurrentEventStartTime godoc
 // @Summary Modify current event start time
 // @Description Update the start time of the currently running event
@@ -134,6 +199,7 @@ func (e *EventHandler) GetCurrentEvent(w http.ResponseWriter, r *http.Request) {
 // @Failure 400 {object} rest.ErrorResponse "Invalid request"
 // @Failure 403 {string} string "User not found"
 // @Failure 404 {string} string "No current event"
+// @Failure 409 {string} string "Modified event overlaps existing calendar events (strict calendar mode)"
 // @Router /api/event/current/start [patch]
 // @Security XUserId
 func (e *EventHandler) ModifyCurrentEventStartTime(w http.ResponseWriter, r *http.Request) {
@@ -172,6 +238,10 @@ func (e *EventHandler) ModifyCurrentEventStartTime(w http.ResponseWriter, r *htt
 			w.WriteHeader(http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, calendar.ErrEventOverlap) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -182,11 +252,252 @@ func (e *EventHandler) ModifyCurrentEventStartTime(w http.ResponseWriter, r *htt
 	}
 }
 
+// UpdateCurrentEvent godoc
+// @Summary Update current event details
+// @Description Replace the notes, the external task reference and the location of the currently running event.
+// @Description They are carried into the calendar event when the current event is finished.
+// @Tags CurrentEvent
+// @Accept json
+// @Produce json
+// @Param details body object{notes=string,taskId=string,location=string} true "Current event details"
+// @Success 200 {object} CurrentEventDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "No current event"
+// @Router /api/event/current [patch]
+// @Security XUserId
+func (e *EventHandler) UpdateCurrentEvent(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var updateRequest struct {
+		Notes    string `json:"notes"`
+		TaskId   string `json:"taskId"`
+		Location string `json:"location"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&updateRequest); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+			Error: "Invalid request body format",
+		})
+		return
+	}
+
+	location, err := parseLocation(updateRequest.Location)
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+
+	updatedEvent, err := e.eventService.UpdateCurrentEventDetails(r.Context(), updateRequest.Notes, updateRequest.TaskId,
+		location)
+	if err != nil {
+		if errors.Is(err, ErrNoCurrentEvent) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(eventToDTO(updatedEvent)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// SwitchBack godoc
+// @Summary Switch back to the previous event
+// @Description Stop the currently running event and start tracking the previously tracked budget item again
+// @Tags CurrentEvent
+// @Produce json
+// @Success 201 {object} CurrentEventDTO
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "No current or previous event"
+// @Failure 409 {string} string "Finished event overlaps existing calendar events (strict calendar mode)"
+// @Router /api/event/current/switch-back [post]
+// @Security XUserId
+func (e *EventHandler) SwitchBack(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	startedEvent, err := e.eventService.SwitchBack(r.Context())
+	if err != nil {
+		if errors.Is(err, ErrNoCurrentEvent) || errors.Is(err, ErrNoPreviousEvent) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, calendar.ErrEventOverlap) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(eventToDTO(startedEvent)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// StartDefaultEvent godoc
+// @Summary Start tracking the default budget item
+// @Description Start tracking the budget item chosen as default in the user settings, e.g. from a single hardware button.
+// @Description When the default item is already being tracked, the running event is returned unchanged with 200.
+// @Tags CurrentEvent
+// @Produce json
+// @Success 200 {object} CurrentEventDTO "Default item already tracked"
+// @Success 201 {object} CurrentEventDTO
+// @Failure 403 {string} string "User not found"
+// @Failure 409 {string} string "No default item configured, it is not in the current week's plan, or the finished event overlaps existing calendar events (strict calendar mode)"
+// @Router /api/event/current/start-default [post]
+// @Security XUserId
+func (e *EventHandler) StartDefaultEvent(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	event, started, err := e.eventService.StartDefaultEvent(r.Context())
+	if err != nil {
+		if errors.Is(err, ErrNoDefaultItem) || errors.Is(err, ErrDefaultItemNotPlanned) ||
+			errors.Is(err, calendar.ErrEventOverlap) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if started {
+		w.WriteHeader(http.StatusCreated)
+	}
+	if err := json.NewEncoder(w).Encode(eventToDTO(event)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetRecentItems godoc
+// @Summary Get recently tracked items
+// @Description Retrieve distinct recently tracked plan items, the latest first, excluding the currently running one.
+// @Description Specify the number using the 'limit' query parameter (e.g., limit=5), at most 20 items are returned
+// @Tags CurrentEvent
+// @Produce json
+// @Param limit query int false "Number of recent items to retrieve" default(5)
+// @Success 200 {array} PlanItemDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/event/recent [get]
+// @Security XUserId
+func (e *EventHandler) GetRecentItems(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	limitString := r.URL.Query().Get("limit")
+	limit, err := strconv.Atoi(limitString)
+	if err != nil || limit < 1 {
+		limit = 5
+	}
+
+	items, err := e.eventService.GetRecentItems(r.Context(), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	dtos := make([]PlanItemDTO, 0, len(items))
+	for _, item := range items {
+		dtos = append(dtos, planItemToDTO(item))
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// ReportIdle godoc
+// @Summary Report user idle period
+// @Description Report a period the user was away from the computer. When the user has discardIdleTime setting enabled,
+// @Description the idle period is cut out of the running event: the part before it is stored in the calendar and the
+// @Description running event continues from the idle end. Otherwise the running event is left unchanged.
+// @Tags CurrentEvent
+// @Accept json
+// @Produce json
+// @Param idle body object{start=string,end=string} true "Idle period start and end in RFC3339 format"
+// @Success 200 {object} CurrentEventDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "No current event"
+// @Failure 409 {string} string "Stored event overlaps existing calendar events (strict calendar mode)"
+// @Router /api/event/current/idle [post]
+// @Security XUserId
+func (e *EventHandler) ReportIdle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var idleRequest struct {
+		Start string `json:"start"`
+		End   string `json:"end"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&idleRequest); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+			Error: "Invalid request body format",
+		})
+		return
+	}
+	idleStart, startErr := time.Parse(time.RFC3339, idleRequest.Start)
+	idleEnd, endErr := time.Parse(time.RFC3339, idleRequest.End)
+	if startErr != nil || endErr != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+			Error:   "Invalid idle period format",
+			Details: "Start and end must be in RFC3339 format",
+		})
+		return
+	}
+
+	event, err := e.eventService.ReportIdle(r.Context(), idleStart, idleEnd)
+	if err != nil {
+		if errors.Is(err, ErrInvalidIdlePeriod) {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+				Error:   "Invalid idle period",
+				Details: err.Error(),
+			})
+			return
+		}
+		if errors.Is(err, ErrNoCurrentEvent) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, calendar.ErrEventOverlap) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(eventToDTO(event)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
 func eventToDTO(event CurrentEvent) CurrentEventDTO {
-	return CurrentEventDTO{
-		PlanItem:  planItemToDTO(event.PlanItem),
-		StartTime: event.StartTime.Format(time.RFC3339),
+	dto := CurrentEventDTO{
+		PlanItem:        planItemToDTO(event.PlanItem),
+		StartTime:       event.StartTime.Format(time.RFC3339),
+		Notes:           event.Notes,
+		TaskId:          event.TaskId,
+		Location:        event.Location,
+		RoundedDuration: int(event.RoundedDuration.Seconds()),
+	}
+	if !event.EndTime.IsZero() {
+		dto.EndTime = event.EndTime.Format(time.RFC3339)
+	}
+	return dto
+}
+
+// parseLocation trims the location of a request and checks its length.
+func parseLocation(location string) (string, error) {
+	location = strings.TrimSpace(location)
+	if len(location) > calendar.MaxLocationLen {
+		return "", rest.InvalidField("location", "Invalid location",
+			fmt.Sprintf("'location' can have at most %d characters", calendar.MaxLocationLen))
 	}
+	return location, nil
 }
 
 func planItemToDTO(planItem PlanItem) PlanItemDTO {
@@ -8,12 +8,17 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/klokku/klokku/internal/database/dbtx"
 	log "github.com/sirupsen/logrus"
 )
 
 type Repository interface {
+	// WithTransaction runs fn in a transaction, committed when fn returns no error. The context passed to fn carries
+	// the transaction, so events published with it are stored together with the changes.
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 	ReplaceCurrentEvent(ctx context.Context, userId int, event CurrentEvent) (CurrentEvent, error)
-	DeleteCurrentEvent(ctx context.Context, userId int) error
+	// DeleteCurrentEvent deletes the current event and returns it as deleted, an event without id when there was none.
+	DeleteCurrentEvent(ctx context.Context, userId int) (CurrentEvent, error)
 	FindCurrentEvent(ctx context.Context, userId int) (CurrentEvent, error)
 }
 
@@ -25,17 +30,26 @@ func NewEventRepo(db *pgxpool.Pool) Repository {
 	return &repositoryImpl{db: db}
 }
 
+func (r *repositoryImpl) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return dbtx.InTx(ctx, r.db, fn)
+}
+
 // ReplaceCurrentEvent replaces the current event with the given event
 func (r *repositoryImpl) ReplaceCurrentEvent(ctx context.Context, userId int, event CurrentEvent) (CurrentEvent, error) {
-	query := `INSERT INTO current_event (budget_item_id, budget_item_name, plan_item_weekly_duration_sec, start_time, user_id) 
-				VALUES ($1, $2, $3, $4, $5) 
+	query := `INSERT INTO current_event (budget_item_id, budget_item_name, plan_item_weekly_duration_sec, start_time,
+                           notes, task_id, location, user_id) 
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8) 
 				ON CONFLICT (user_id) DO UPDATE SET 
 					budget_item_id = EXCLUDED.budget_item_id,
 					budget_item_name = EXCLUDED.budget_item_name,
 					plan_item_weekly_duration_sec = EXCLUDED.plan_item_weekly_duration_sec,
-					start_time = EXCLUDED.start_time`
+					start_time = EXCLUDED.start_time,
+					notes = EXCLUDED.notes,
+					task_id = EXCLUDED.task_id,
+					location = EXCLUDED.location`
 
-	_, err := r.db.Exec(ctx, query, event.PlanItem.BudgetItemId, event.PlanItem.Name, event.PlanItem.WeeklyDuration.Seconds(), event.StartTime, userId)
+	_, err := dbtx.From(ctx, r.db).Exec(ctx, query, event.PlanItem.BudgetItemId, event.PlanItem.Name, event.PlanItem.WeeklyDuration.Seconds(),
+		event.StartTime, event.Notes, event.TaskId, event.Location, userId)
 	if err != nil {
 		err := fmt.Errorf("could not execute query: %v", err)
 		log.Error(err)
@@ -45,37 +59,46 @@ func (r *repositoryImpl) ReplaceCurrentEvent(ctx context.Context, userId int, ev
 	return event, nil
 }
 
-func (r *repositoryImpl) DeleteCurrentEvent(ctx context.Context, userId int) error {
-	query := "DELETE FROM current_event WHERE user_id = $1"
-	_, err := r.db.Exec(ctx, query, userId)
+func (r *repositoryImpl) DeleteCurrentEvent(ctx context.Context, userId int) (CurrentEvent, error) {
+	query := `DELETE FROM current_event WHERE user_id = $1
+			  RETURNING id, budget_item_id, budget_item_name, plan_item_weekly_duration_sec, start_time, notes, task_id,
+						location`
+	event, err := r.scanEvent(dbtx.From(ctx, r.db).QueryRow(ctx, query, userId))
 	if err != nil {
 		err := fmt.Errorf("could not execute query: %w", err)
 		log.Error(err)
-		return err
+		return CurrentEvent{}, err
 	}
-	return nil
+	return event, nil
 }
 
 func (r *repositoryImpl) FindCurrentEvent(ctx context.Context, userId int) (CurrentEvent, error) {
 	query := `
-		SELECT id, budget_item_id, budget_item_name, plan_item_weekly_duration_sec, start_time
+		SELECT id, budget_item_id, budget_item_name, plan_item_weekly_duration_sec, start_time, notes, task_id, location
 		FROM current_event e
 		WHERE e.user_id = $1 LIMIT 1`
 
+	event, err := r.scanEvent(dbtx.From(ctx, r.db).QueryRow(ctx, query, userId))
+	if err != nil {
+		err := fmt.Errorf("failed when trying to find current event: %w", err)
+		log.Error(err)
+		return CurrentEvent{}, err
+	}
+	return event, nil
+}
+
+// scanEvent scans the current event, an event without id when there is none.
+func (r *repositoryImpl) scanEvent(row pgx.Row) (CurrentEvent, error) {
 	var weeklyTime int
 	var event CurrentEvent
-	err := r.db.QueryRow(ctx, query, userId).
-		Scan(&event.Id, &event.PlanItem.BudgetItemId, &event.PlanItem.Name, &weeklyTime, &event.StartTime)
+	err := row.Scan(&event.Id, &event.PlanItem.BudgetItemId, &event.PlanItem.Name, &weeklyTime, &event.StartTime,
+		&event.Notes, &event.TaskId, &event.Location)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return CurrentEvent{}, nil
 		}
-		err := fmt.Errorf("failed when trying to find current event: %w", err)
-		log.Error(err)
 		return CurrentEvent{}, err
 	}
-
 	event.PlanItem.WeeklyDuration = time.Duration(weeklyTime) * time.Second
-
 	return event, nil
 }
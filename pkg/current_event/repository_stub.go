@@ -2,6 +2,7 @@ package current_event
 
 import (
 	"context"
+	"maps"
 )
 
 type stubEventRepository struct {
@@ -16,6 +17,16 @@ func newStubEventRepository() *stubEventRepository {
 	}
 }
 
+// WithTransaction restores the events when fn fails.
+func (s *stubEventRepository) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	original := maps.Clone(s.events)
+	if err := fn(ctx); err != nil {
+		s.events = original
+		return err
+	}
+	return nil
+}
+
 func (s *stubEventRepository) ReplaceCurrentEvent(ctx context.Context, userId int, event CurrentEvent) (CurrentEvent, error) {
 	event.Id = s.nextId
 	s.events[userId] = event
@@ -24,9 +35,10 @@ func (s *stubEventRepository) ReplaceCurrentEvent(ctx context.Context, userId in
 	return event, nil
 }
 
-func (s *stubEventRepository) DeleteCurrentEvent(ctx context.Context, userId int) error {
+func (s *stubEventRepository) DeleteCurrentEvent(ctx context.Context, userId int) (CurrentEvent, error) {
+	event := s.events[userId]
 	delete(s.events, userId)
-	return nil
+	return event, nil
 }
 
 func (s *stubEventRepository) FindCurrentEvent(ctx context.Context, userId int) (CurrentEvent, error) {
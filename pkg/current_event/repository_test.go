@@ -0,0 +1,111 @@
+package current_event
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/klokku/klokku/internal/test_utils"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+)
+
+var pgContainer *postgres.PostgresContainer
+var openDb func() *pgxpool.Pool
+
+func TestMain(m *testing.M) {
+	pgContainer, openDb = test_utils.TestWithDB()
+	defer func() {
+		if err := testcontainers.TerminateContainer(pgContainer); err != nil {
+			log.Errorf("failed to terminate container: %s", err)
+		}
+	}()
+	code := m.Run()
+	os.Exit(code)
+}
+
+func setupTestRepository(t *testing.T) (context.Context, Repository, int, []int) {
+	ctx := context.Background()
+	db := openDb()
+	t.Cleanup(func() {
+		db.Close()
+		err := pgContainer.Restore(ctx)
+		require.NoError(t, err)
+	})
+	userId := 1
+	budgetRepo := budget_plan.NewBudgetPlanRepo(db)
+	plan, err := budgetRepo.CreatePlan(ctx, userId, budget_plan.BudgetPlan{Name: "Plan"})
+	require.NoError(t, err)
+	var itemIds []int
+	for _, name := range []string{"Work", "Reading"} {
+		id, _, err := budgetRepo.StoreItem(ctx, userId, budget_plan.BudgetItem{PlanId: plan.Id, Name: name,
+			WeeklyDuration: time.Hour})
+		require.NoError(t, err)
+		itemIds = append(itemIds, id)
+	}
+	return ctx, NewEventRepo(db), userId, itemIds
+}
+
+func TestRepositoryImpl_ReplaceCurrentEvent(t *testing.T) {
+	t.Run("should replace notes and task of the previous current event", func(t *testing.T) {
+		// given
+		ctx, repo, userId, itemIds := setupTestRepository(t)
+		startTime := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+		_, err := repo.ReplaceCurrentEvent(ctx, userId, CurrentEvent{
+			PlanItem:  PlanItem{BudgetItemId: itemIds[0], Name: "Work", WeeklyDuration: time.Hour},
+			StartTime: startTime,
+			Notes:     "Sprint planning",
+			TaskId:    "86abc",
+		})
+		require.NoError(t, err)
+
+		// when
+		_, err = repo.ReplaceCurrentEvent(ctx, userId, CurrentEvent{
+			PlanItem:  PlanItem{BudgetItemId: itemIds[1], Name: "Reading", WeeklyDuration: time.Hour},
+			StartTime: startTime.Add(time.Hour),
+			Notes:     "Chapter 3",
+		})
+		require.NoError(t, err)
+
+		// then
+		current, err := repo.FindCurrentEvent(ctx, userId)
+		require.NoError(t, err)
+		assert.Equal(t, itemIds[1], current.PlanItem.BudgetItemId)
+		assert.Equal(t, "Chapter 3", current.Notes)
+		assert.Empty(t, current.TaskId)
+	})
+}
+
+func TestRepositoryImpl_DeleteCurrentEvent(t *testing.T) {
+	t.Run("should return the deleted event only to the first delete", func(t *testing.T) {
+		// given
+		ctx, repo, userId, itemIds := setupTestRepository(t)
+		startTime := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+		_, err := repo.ReplaceCurrentEvent(ctx, userId, CurrentEvent{
+			PlanItem:  PlanItem{BudgetItemId: itemIds[0], Name: "Work", WeeklyDuration: time.Hour},
+			StartTime: startTime,
+			Notes:     "Sprint planning",
+		})
+		require.NoError(t, err)
+
+		// when
+		deleted, err := repo.DeleteCurrentEvent(ctx, userId)
+		require.NoError(t, err)
+		deletedAgain, err := repo.DeleteCurrentEvent(ctx, userId)
+		require.NoError(t, err)
+
+		// then
+		assert.NotZero(t, deleted.Id)
+		assert.Equal(t, itemIds[0], deleted.PlanItem.BudgetItemId)
+		assert.Equal(t, time.Hour, deleted.PlanItem.WeeklyDuration)
+		assert.Equal(t, "Sprint planning", deleted.Notes)
+		assert.True(t, startTime.Equal(deleted.StartTime))
+		assert.Zero(t, deletedAgain.Id)
+	})
+}
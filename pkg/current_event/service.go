@@ -2,31 +2,79 @@ package current_event
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
+	"github.com/klokku/klokku/internal/event_bus"
 	"github.com/klokku/klokku/internal/utils"
 	"github.com/klokku/klokku/pkg/calendar"
 	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
 	log "github.com/sirupsen/logrus"
 )
 
 var ErrNoCurrentEvent = fmt.Errorf("no current event")
+var ErrNoPreviousEvent = fmt.Errorf("no previously tracked event")
+var ErrInvalidIdlePeriod = fmt.Errorf("invalid idle period")
+var ErrNoDefaultItem = fmt.Errorf("no default budget item configured")
+var ErrDefaultItemNotPlanned = fmt.Errorf("default budget item is not in the current week's plan")
+
+// errKeepRunning rolls back the stop of an event replaced meanwhile by one that is not to be stopped.
+var errKeepRunning = fmt.Errorf("event keeps running")
+
+// recentEventsLookback is the number of last calendar events scanned to build the recent items list.
+const recentEventsLookback = 50
+
+// MaxRecentItems is the largest number of recent items returned, larger limits are clamped to it.
+const MaxRecentItems = 20
 
 type Service interface {
 	FindCurrentEvent(ctx context.Context) (CurrentEvent, error)
 	StartNewEvent(ctx context.Context, event CurrentEvent) (CurrentEvent, error)
+	// StopCurrentEvent stores the running event in the calendar without starting another one and returns it, with its
+	// end time and rounded duration.
+	StopCurrentEvent(ctx context.Context) (CurrentEvent, error)
+	// StopStaleEvent stops the running event when it started more than maxDuration before now, e.g. a timer forgotten
+	// over the weekend. The event is stored in the calendar as lasting maxDuration. It returns false when no event
+	// was stopped.
+	StopStaleEvent(ctx context.Context, now time.Time, maxDuration time.Duration) (bool, error)
 	ModifyCurrentEventStartTime(ctx context.Context, newStartTime time.Time) (CurrentEvent, error)
+	// UpdateCurrentEventDetails replaces the notes, the external task reference and the location of the running event.
+	UpdateCurrentEventDetails(ctx context.Context, notes string, taskId string, location string) (CurrentEvent, error)
+	// GetRecentItems returns distinct plan items of the most recently tracked events, the latest first.
+	// The item of the running event is not included.
+	GetRecentItems(ctx context.Context, limit int) ([]PlanItem, error)
+	// SwitchBack stops the running event and starts tracking the previously tracked plan item again.
+	SwitchBack(ctx context.Context) (CurrentEvent, error)
+	// StartDefaultEvent starts tracking the user's default budget item. When it is already being tracked, the running
+	// event is returned unchanged with started set to false.
+	StartDefaultEvent(ctx context.Context) (event CurrentEvent, started bool, err error)
+	// ReportIdle handles a period the user was away. Depending on the user settings the idle time is either
+	// kept in the running event or cut out of it.
+	ReportIdle(ctx context.Context, idleStart time.Time, idleEnd time.Time) (CurrentEvent, error)
+}
+
+type weeklyPlanItemsReader interface {
+	GetItemsForWeek(ctx context.Context, date time.Time) ([]weekly_plan.WeeklyPlanItem, error)
 }
 
 type EventServiceImpl struct {
-	repo     Repository
-	calendar calendar.Calendar
-	clock    utils.Clock
+	repo       Repository
+	calendar   calendar.Calendar
+	weeklyPlan weeklyPlanItemsReader
+	clock      utils.Clock
+	eventBus   *event_bus.EventBus
 }
 
-func NewEventService(repo Repository, calendar calendar.Calendar) *EventServiceImpl {
-	return &EventServiceImpl{repo, calendar, &utils.SystemClock{}}
+func NewEventService(
+	repo Repository,
+	calendar calendar.Calendar,
+	weeklyPlan weeklyPlanItemsReader,
+	eventBus *event_bus.EventBus,
+) *EventServiceImpl {
+	return &EventServiceImpl{repo, calendar, weeklyPlan, &utils.SystemClock{}, eventBus}
 }
 
 func (s *EventServiceImpl) FindCurrentEvent(ctx context.Context) (CurrentEvent, error) {
@@ -42,38 +90,221 @@ func (s *EventServiceImpl) StartNewEvent(ctx context.Context, event CurrentEvent
 	if err != nil {
 		return CurrentEvent{}, fmt.Errorf("failed to get current user: %w", err)
 	}
-	currentEvent, err := s.FindCurrentEvent(ctx)
+	var started CurrentEvent
+	// The events are published in the transaction, so they are stored in the outbox only when the change commits
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		currentEvent, err := s.repo.FindCurrentEvent(ctx, currentUser.Id)
+		if err != nil {
+			return err
+		}
+		stoppedAt := s.clock.Now()
+		if currentEvent.Id != 0 {
+			mergeIntoNext, err := s.finalizeEvent(ctx, currentUser.Settings, currentEvent, stoppedAt)
+			if err != nil {
+				return err
+			}
+			if mergeIntoNext {
+				// Use the start time of the previous event for the new event
+				event.StartTime = currentEvent.StartTime
+			}
+		}
+
+		started, err = s.repo.ReplaceCurrentEvent(ctx, currentUser.Id, event)
+		if err != nil {
+			return err
+		}
+		if currentEvent.Id != 0 {
+			if err := s.publishStopped(ctx, finish(currentUser.Settings, currentEvent, stoppedAt)); err != nil {
+				return err
+			}
+		}
+		return s.publishStarted(ctx, started)
+	})
 	if err != nil {
 		return CurrentEvent{}, err
 	}
-	if currentEvent.Id != 0 {
-		eventDuration := s.clock.Now().Sub(currentEvent.StartTime)
-		isShortEvent := eventDuration < time.Minute
-
-		if currentUser.Settings.IgnoreShortEvents && isShortEvent {
-			log.Debugf("Ignoring short event (duration: %v), not storing to calendar", eventDuration)
-			// Use the start time of the previous event for the new event
-			event.StartTime = currentEvent.StartTime
-		} else {
-			log.Debug("Storing previous event to calendar before starting new one")
-			err := s.storeEventToCalendar(ctx, currentEvent)
-			if err != nil {
-				return CurrentEvent{}, err
-			}
+	return started, nil
+}
+
+func (s *EventServiceImpl) StopCurrentEvent(ctx context.Context) (CurrentEvent, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return CurrentEvent{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	stopped, _, err := s.stop(ctx, currentUser, func(CurrentEvent) (time.Time, bool) {
+		return s.clock.Now(), true
+	})
+	return stopped, err
+}
+
+func (s *EventServiceImpl) StopStaleEvent(ctx context.Context, now time.Time, maxDuration time.Duration) (bool, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get current user: %w", err)
+	}
+	stopped, ok, err := s.stop(ctx, currentUser, func(event CurrentEvent) (time.Time, bool) {
+		endTime := event.StartTime.Add(maxDuration)
+		return endTime, endTime.Before(now)
+	})
+	if errors.Is(err, ErrNoCurrentEvent) || !ok {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	log.Infof("Stopped event %q of user %d running since %s", stopped.PlanItem.Name, currentUser.Id, stopped.StartTime)
+	return true, nil
+}
+
+// stop stores the running event in the calendar, ending at the time returned by endTime, and removes it. The event is
+// kept running when endTime returns false, stop then returns false too.
+func (s *EventServiceImpl) stop(
+	ctx context.Context,
+	currentUser user.User,
+	endTime func(event CurrentEvent) (time.Time, bool),
+) (CurrentEvent, bool, error) {
+	var currentEvent CurrentEvent
+	stopped := false
+	err := s.repo.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		currentEvent, err = s.repo.FindCurrentEvent(ctx, currentUser.Id)
+		if err != nil {
+			return err
+		}
+		if currentEvent.Id == 0 {
+			return ErrNoCurrentEvent
 		}
+		if _, ok := endTime(currentEvent); !ok {
+			return nil
+		}
+		// The event is stored as deleted, so a concurrent stop, which waits for the row, finds nothing to store and
+		// an event replaced meanwhile is stored as it was replaced
+		currentEvent, err = s.repo.DeleteCurrentEvent(ctx, currentUser.Id)
+		if err != nil {
+			return err
+		}
+		if currentEvent.Id == 0 {
+			return ErrNoCurrentEvent
+		}
+		stoppedAt, ok := endTime(currentEvent)
+		if !ok {
+			return errKeepRunning
+		}
+		// With no next event a short event to be merged into it is dropped
+		if _, err := s.finalizeEvent(ctx, currentUser.Settings, currentEvent, stoppedAt); err != nil {
+			return err
+		}
+		stopped = true
+		currentEvent = finish(currentUser.Settings, currentEvent, stoppedAt)
+		return s.publishStopped(ctx, currentEvent)
+	})
+	if errors.Is(err, errKeepRunning) {
+		return currentEvent, false, nil
+	}
+	if err != nil {
+		return CurrentEvent{}, false, err
+	}
+	return currentEvent, stopped, nil
+}
+
+// finish returns the event ended at endTime, with its duration rounded according to the user's settings.
+func finish(settings user.Settings, event CurrentEvent, endTime time.Time) CurrentEvent {
+	event.EndTime = endTime
+	if settings.Rounding.IsEnabled() {
+		event.RoundedDuration = settings.Rounding.Apply(endTime.Sub(event.StartTime))
+	}
+	return event
+}
+
+// publishStopped publishes the finished event with the raw times, as stored in the calendar, and the duration
+// rounded according to the user's settings.
+func (s *EventServiceImpl) publishStopped(ctx context.Context, event CurrentEvent) error {
+	if s.eventBus == nil {
+		return nil
+	}
+	stopped := event_bus.CurrentEventStopped{
+		BudgetItemId:    event.PlanItem.BudgetItemId,
+		Name:            event.PlanItem.Name,
+		StartTime:       event.StartTime,
+		EndTime:         event.EndTime,
+		RoundedDuration: event.RoundedDuration,
+	}
+	if err := s.eventBus.Publish(event_bus.NewEvent(ctx, "current_event.stopped", stopped)); err != nil {
+		return fmt.Errorf("failed to publish current_event.stopped event: %w", err)
+	}
+	return nil
+}
+
+func (s *EventServiceImpl) publishStarted(ctx context.Context, event CurrentEvent) error {
+	if s.eventBus == nil {
+		return nil
+	}
+	err := s.eventBus.Publish(event_bus.NewEvent(ctx, "current_event.started", event_bus.CurrentEventStarted{
+		BudgetItemId: event.PlanItem.BudgetItemId,
+		Name:         event.PlanItem.Name,
+		StartTime:    event.StartTime,
+	}))
+	if err != nil {
+		return fmt.Errorf("failed to publish current_event.started event: %w", err)
+	}
+	return nil
+}
+
+// finalizeEvent stores the finished event in the calendar, applying the user's short events handling.
+// It returns true when the event was short and should be merged into the next one, which is then expected
+// to start at the finished event start time.
+func (s *EventServiceImpl) finalizeEvent(ctx context.Context, settings user.Settings, event CurrentEvent, endTime time.Time) (bool, error) {
+	threshold := settings.ShortEventThreshold
+	if threshold <= 0 {
+		threshold = user.DefaultShortEventThreshold
+	}
+	eventDuration := endTime.Sub(event.StartTime)
+	if !settings.IgnoreShortEvents || eventDuration >= threshold {
+		log.Debug("Storing finished event to calendar")
+		return false, s.storeEventToCalendar(ctx, event, endTime)
 	}
 
-	return s.repo.ReplaceCurrentEvent(ctx, currentUser.Id, event)
+	switch settings.ShortEventHandling {
+	case user.ShortEventDiscard:
+		log.Debugf("Discarding short event (duration: %v)", eventDuration)
+		return false, nil
+	case user.ShortEventMergePrevious:
+		log.Debugf("Merging short event (duration: %v) into the previous one", eventDuration)
+		return false, s.extendPreviousEvent(ctx, event.StartTime, endTime)
+	default:
+		log.Debugf("Merging short event (duration: %v) into the next one", eventDuration)
+		return true, nil
+	}
 }
 
-func (s *EventServiceImpl) storeEventToCalendar(ctx context.Context, event CurrentEvent) error {
-	endTime := s.clock.Now()
+// extendPreviousEvent moves the end of the calendar event ending at the given start to the new end.
+// When there is no such event (there is a gap before the short event), nothing is changed.
+func (s *EventServiceImpl) extendPreviousEvent(ctx context.Context, start time.Time, newEnd time.Time) error {
+	events, err := s.calendar.GetEvents(ctx, start.Add(-time.Second), start)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		if e.EndTime.Equal(start) {
+			e.EndTime = newEnd
+			_, err := s.calendar.ModifyEvent(ctx, e)
+			return err
+		}
+	}
+	log.Debug("No previous event adjacent to the short event, discarding it")
+	return nil
+}
+
+func (s *EventServiceImpl) storeEventToCalendar(ctx context.Context, event CurrentEvent, endTime time.Time) error {
 	calEvent := calendar.Event{
 		Summary:   event.PlanItem.Name,
 		StartTime: event.StartTime,
 		EndTime:   endTime,
 		Metadata: calendar.EventMetadata{
 			BudgetItemId: event.PlanItem.BudgetItemId,
+			Notes:        event.Notes,
+			TaskId:       event.TaskId,
+			Location:     event.Location,
 		},
 	}
 
@@ -85,6 +316,166 @@ func (s *EventServiceImpl) storeEventToCalendar(ctx context.Context, event Curre
 	return nil
 }
 
+func (s *EventServiceImpl) UpdateCurrentEventDetails(ctx context.Context, notes string, taskId string, location string) (CurrentEvent, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return CurrentEvent{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	currentEvent, err := s.repo.FindCurrentEvent(ctx, userId)
+	if err != nil {
+		return CurrentEvent{}, err
+	}
+	if currentEvent.Id == 0 {
+		return CurrentEvent{}, ErrNoCurrentEvent
+	}
+	currentEvent.Notes = notes
+	currentEvent.TaskId = taskId
+	currentEvent.Location = location
+	return s.repo.ReplaceCurrentEvent(ctx, userId, currentEvent)
+}
+
+func (s *EventServiceImpl) GetRecentItems(ctx context.Context, limit int) ([]PlanItem, error) {
+	limit = min(limit, MaxRecentItems)
+	currentEvent, err := s.FindCurrentEvent(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lastEvents, err := s.calendar.GetLastEvents(ctx, recentEventsLookback)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last calendar events: %w", err)
+	}
+	sort.SliceStable(lastEvents, func(i, j int) bool {
+		return lastEvents[i].EndTime.After(lastEvents[j].EndTime)
+	})
+	planItems, err := s.weeklyPlan.GetItemsForWeek(ctx, s.clock.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get weekly plan items: %w", err)
+	}
+	planItemsById := make(map[int]weekly_plan.WeeklyPlanItem, len(planItems))
+	for _, item := range planItems {
+		planItemsById[item.BudgetItemId] = item
+	}
+
+	seen := map[int]bool{currentEvent.PlanItem.BudgetItemId: currentEvent.Id != 0}
+	recentItems := make([]PlanItem, 0, limit)
+	for _, event := range lastEvents {
+		budgetItemId := event.Metadata.BudgetItemId
+		// Ad-hoc items and deleted budget items, unlinked from their events, cannot be tracked
+		if budgetItemId <= 0 || seen[budgetItemId] {
+			continue
+		}
+		seen[budgetItemId] = true
+		recentItem := PlanItem{
+			BudgetItemId: budgetItemId,
+			Name:         event.Summary,
+		}
+		if planItem, ok := planItemsById[budgetItemId]; ok {
+			recentItem.Name = planItem.Name
+			recentItem.WeeklyDuration = planItem.WeeklyDuration
+		}
+		recentItems = append(recentItems, recentItem)
+		if len(recentItems) == limit {
+			break
+		}
+	}
+	return recentItems, nil
+}
+
+func (s *EventServiceImpl) SwitchBack(ctx context.Context) (CurrentEvent, error) {
+	currentEvent, err := s.FindCurrentEvent(ctx)
+	if err != nil {
+		return CurrentEvent{}, err
+	}
+	if currentEvent.Id == 0 {
+		return CurrentEvent{}, ErrNoCurrentEvent
+	}
+	recentItems, err := s.GetRecentItems(ctx, 1)
+	if err != nil {
+		return CurrentEvent{}, err
+	}
+	if len(recentItems) == 0 {
+		return CurrentEvent{}, ErrNoPreviousEvent
+	}
+	return s.StartNewEvent(ctx, CurrentEvent{
+		PlanItem:  recentItems[0],
+		StartTime: s.clock.Now(),
+	})
+}
+
+func (s *EventServiceImpl) StartDefaultEvent(ctx context.Context) (CurrentEvent, bool, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return CurrentEvent{}, false, fmt.Errorf("failed to get current user: %w", err)
+	}
+	budgetItemId := currentUser.Settings.DefaultBudgetItemId
+	if budgetItemId == 0 {
+		return CurrentEvent{}, false, ErrNoDefaultItem
+	}
+	currentEvent, err := s.FindCurrentEvent(ctx)
+	if err != nil {
+		return CurrentEvent{}, false, err
+	}
+	if currentEvent.Id != 0 && currentEvent.PlanItem.BudgetItemId == budgetItemId {
+		return currentEvent, false, nil
+	}
+
+	now := s.clock.Now()
+	planItems, err := s.weeklyPlan.GetItemsForWeek(ctx, now)
+	if err != nil {
+		return CurrentEvent{}, false, fmt.Errorf("failed to get weekly plan items: %w", err)
+	}
+	for _, item := range planItems {
+		if item.BudgetItemId == budgetItemId {
+			started, err := s.StartNewEvent(ctx, CurrentEvent{
+				PlanItem: PlanItem{
+					BudgetItemId:   item.BudgetItemId,
+					Name:           item.Name,
+					WeeklyDuration: item.WeeklyDuration,
+				},
+				StartTime: now,
+			})
+			return started, err == nil, err
+		}
+	}
+	return CurrentEvent{}, false, ErrDefaultItemNotPlanned
+}
+
+func (s *EventServiceImpl) ReportIdle(ctx context.Context, idleStart time.Time, idleEnd time.Time) (CurrentEvent, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return CurrentEvent{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	if !idleEnd.After(idleStart) {
+		return CurrentEvent{}, fmt.Errorf("%w: end must be after start", ErrInvalidIdlePeriod)
+	}
+	if idleEnd.After(s.clock.Now()) {
+		return CurrentEvent{}, fmt.Errorf("%w: end cannot be in the future", ErrInvalidIdlePeriod)
+	}
+
+	currentEvent, err := s.FindCurrentEvent(ctx)
+	if err != nil {
+		return CurrentEvent{}, err
+	}
+	if currentEvent.Id == 0 {
+		return CurrentEvent{}, ErrNoCurrentEvent
+	}
+	if !currentUser.Settings.DiscardIdleTime || !idleEnd.After(currentEvent.StartTime) {
+		log.Debugf("Keeping idle time (%v - %v) for user %d", idleStart, idleEnd, currentUser.Id)
+		return currentEvent, nil
+	}
+
+	if idleStart.After(currentEvent.StartTime) {
+		// The event was active before the user went idle - store the active part and continue after the idle period.
+		// A short active part cannot be merged into the continuation, as the idle period is in between, so it is dropped.
+		log.Debug("Splitting current event by idle period")
+		if _, err := s.finalizeEvent(ctx, currentUser.Settings, currentEvent, idleStart); err != nil {
+			return CurrentEvent{}, err
+		}
+	}
+	currentEvent.StartTime = idleEnd
+	return s.repo.ReplaceCurrentEvent(ctx, currentUser.Id, currentEvent)
+}
+
 func (s *EventServiceImpl) ModifyCurrentEventStartTime(ctx context.Context, newStartTime time.Time) (CurrentEvent, error) {
 	userId, err := user.CurrentId(ctx)
 	if err != nil {
@@ -2,13 +2,17 @@ package current_event
 
 import (
 	"context"
+	"errors"
+	"math"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/klokku/klokku/internal/event_bus"
 	"github.com/klokku/klokku/internal/utils"
 	"github.com/klokku/klokku/pkg/calendar"
 	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
 	"github.com/stretchr/testify/require"
 
 	"github.com/stretchr/testify/assert"
@@ -17,15 +21,26 @@ import (
 var clock *utils.MockClock
 var location, _ = time.LoadLocation("Europe/Warsaw")
 var calendarStub *calendar.StubCalendar
+var weeklyPlanStub *weeklyPlanItemsReaderStub
+
+type weeklyPlanItemsReaderStub struct {
+	items []weekly_plan.WeeklyPlanItem
+}
+
+func (s *weeklyPlanItemsReaderStub) GetItemsForWeek(ctx context.Context, date time.Time) ([]weekly_plan.WeeklyPlanItem, error) {
+	return s.items, nil
+}
 
 func setupServiceTest(t *testing.T) (Service, context.Context, func()) {
 	repoStub := newStubEventRepository()
 	calendarStub = calendar.NewStubCalendar()
 	clock = &utils.MockClock{FixedNow: time.Date(2025, time.December, 20, 14, 0, 0, 0, location)}
+	weeklyPlanStub = &weeklyPlanItemsReaderStub{}
 	service := &EventServiceImpl{
-		repo:     repoStub,
-		calendar: calendarStub,
-		clock:    clock,
+		repo:       repoStub,
+		calendar:   calendarStub,
+		weeklyPlan: weeklyPlanStub,
+		clock:      clock,
 	}
 	ctx := user.WithUser(context.Background(), user.User{
 		Id:          1,
@@ -553,8 +568,7 @@ func TestModifyCurrentEventStartTime(t *testing.T) {
 		betweenDayCalEvent3 := calendarEvents[2]
 		assert.Equal(t, betweenDayEvent1.PlanItem.Name, betweenDayCalEvent3.Summary)
 		assert.Equal(t, betweenDayEvent1.StartTime, betweenDayCalEvent3.StartTime)
-		assert.Equal(t, betweenDayEvent1.StartTime.Add(time.Duration(8)*time.Hour).Add(-time.Duration(1)*time.Nanosecond),
-			betweenDayCalEvent3.EndTime)
+		assert.Equal(t, betweenDayEvent1.StartTime.Add(time.Duration(8)*time.Hour), betweenDayCalEvent3.EndTime)
 		betweenDayCalEvent4 := calendarEvents[3]
 		assert.Equal(t, betweenDayEvent1.PlanItem.Name, betweenDayCalEvent3.Summary)
 		assert.Equal(t, betweenDayEvent1.StartTime.Add(time.Duration(8)*time.Hour), betweenDayCalEvent4.StartTime)
@@ -565,3 +579,690 @@ func TestModifyCurrentEventStartTime(t *testing.T) {
 		assert.Equal(t, currentDayEvent1.StartTime.Add(time.Duration(7-1)*time.Hour), currentDayCalEvent1.EndTime)
 	})
 }
+
+func TestUpdateCurrentEventDetails(t *testing.T) {
+
+	t.Run("should update notes and task id of current event", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		// given
+		_, err := service.StartNewEvent(ctx, CurrentEvent{
+			StartTime: clock.Now(),
+			PlanItem: PlanItem{
+				BudgetItemId:   1,
+				Name:           "Writing",
+				WeeklyDuration: time.Duration(60) * time.Minute,
+			},
+		})
+		require.NoError(t, err)
+
+		// when
+		result, err := service.UpdateCurrentEventDetails(ctx, "chapter 3", "task-123", "office")
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "chapter 3", result.Notes)
+		assert.Equal(t, "task-123", result.TaskId)
+		assert.Equal(t, "office", result.Location)
+		currentEvent, err := service.FindCurrentEvent(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "chapter 3", currentEvent.Notes)
+		assert.Equal(t, "task-123", currentEvent.TaskId)
+		assert.Equal(t, "office", currentEvent.Location)
+	})
+
+	t.Run("should return error when there is no current event", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		_, err := service.UpdateCurrentEventDetails(ctx, "notes", "", "")
+
+		assert.ErrorIs(t, err, ErrNoCurrentEvent)
+	})
+
+	t.Run("should carry notes, task id and location into calendar event when event is finished", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		// given
+		clock.SetNow(clock.Now().Add(-1 * time.Hour))
+		_, err := service.StartNewEvent(ctx, CurrentEvent{
+			StartTime: clock.Now(),
+			PlanItem: PlanItem{
+				BudgetItemId:   1,
+				Name:           "Writing",
+				WeeklyDuration: time.Duration(60) * time.Minute,
+			},
+			Notes:    "chapter 3",
+			TaskId:   "task-123",
+			Location: "52.2297,21.0122",
+		})
+		require.NoError(t, err)
+		clock.SetNow(clock.Now().Add(1 * time.Hour))
+
+		// when
+		_, err = service.StartNewEvent(ctx, CurrentEvent{
+			StartTime: clock.Now(),
+			PlanItem: PlanItem{
+				BudgetItemId:   2,
+				Name:           "Reading",
+				WeeklyDuration: time.Duration(60) * time.Minute,
+			},
+		})
+		require.NoError(t, err)
+
+		// then
+		calendarEvents, err := calendarStub.GetLastEvents(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, calendarEvents, 1)
+		assert.Equal(t, "chapter 3", calendarEvents[0].Metadata.Notes)
+		assert.Equal(t, "task-123", calendarEvents[0].Metadata.TaskId)
+		assert.Equal(t, "52.2297,21.0122", calendarEvents[0].Metadata.Location)
+	})
+}
+
+// stoppedMeanwhileRepository deletes the event right after it is found, as a concurrent stop would.
+type stoppedMeanwhileRepository struct {
+	*stubEventRepository
+}
+
+func (r stoppedMeanwhileRepository) FindCurrentEvent(ctx context.Context, userId int) (CurrentEvent, error) {
+	event, err := r.stubEventRepository.FindCurrentEvent(ctx, userId)
+	delete(r.events, userId)
+	return event, err
+}
+
+func TestStopCurrentEvent(t *testing.T) {
+	t.Run("should not store an event stopped meanwhile", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		// given
+		_, err := service.StartNewEvent(ctx, CurrentEvent{StartTime: clock.Now(), PlanItem: PlanItem{BudgetItemId: 1, Name: "Writing"}})
+		require.NoError(t, err)
+		impl := service.(*EventServiceImpl)
+		impl.repo = stoppedMeanwhileRepository{impl.repo.(*stubEventRepository)}
+		clock.SetNow(clock.Now().Add(45 * time.Minute))
+
+		// when
+		_, err = service.StopCurrentEvent(ctx)
+
+		// then
+		assert.ErrorIs(t, err, ErrNoCurrentEvent)
+		calendarEvents, err := calendarStub.GetLastEvents(ctx, 10)
+		require.NoError(t, err)
+		assert.Empty(t, calendarEvents)
+	})
+
+	t.Run("should store the running event in the calendar", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		// given
+		startTime := clock.Now()
+		_, err := service.StartNewEvent(ctx, CurrentEvent{
+			StartTime: startTime,
+			PlanItem:  PlanItem{BudgetItemId: 1, Name: "Writing"},
+			Notes:     "Chapter 3",
+		})
+		require.NoError(t, err)
+		clock.SetNow(startTime.Add(45 * time.Minute))
+
+		// when
+		stopped, err := service.StopCurrentEvent(ctx)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, stopped.PlanItem.BudgetItemId)
+		calendarEvents, err := calendarStub.GetLastEvents(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, calendarEvents, 1)
+		assert.Equal(t, "Writing", calendarEvents[0].Summary)
+		assert.Equal(t, startTime, calendarEvents[0].StartTime)
+		assert.Equal(t, clock.Now(), calendarEvents[0].EndTime)
+		assert.Equal(t, "Chapter 3", calendarEvents[0].Metadata.Notes)
+		current, err := service.FindCurrentEvent(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, current.Id)
+	})
+
+	t.Run("should store raw times and publish the rounded duration", func(t *testing.T) {
+		service, _, teardown := setupServiceTest(t)
+		defer teardown()
+
+		// given
+		eventBus := event_bus.NewEventBus()
+		service.(*EventServiceImpl).eventBus = eventBus
+		var published []event_bus.CurrentEventStopped
+		event_bus.SubscribeTyped[event_bus.CurrentEventStopped](eventBus, "current_event.stopped",
+			func(e event_bus.EventT[event_bus.CurrentEventStopped]) error {
+				published = append(published, e.Data)
+				return nil
+			})
+		ctx := user.WithUser(context.Background(), user.User{
+			Id: 1,
+			Settings: user.Settings{Timezone: location.String(), WeekFirstDay: time.Monday,
+				Rounding: user.Rounding{Increment: 15 * time.Minute, Mode: user.RoundUp}},
+		})
+		startTime := clock.Now()
+		_, err := service.StartNewEvent(ctx, CurrentEvent{StartTime: startTime, PlanItem: PlanItem{BudgetItemId: 1, Name: "Writing"}})
+		require.NoError(t, err)
+		clock.SetNow(startTime.Add(37 * time.Minute))
+
+		// when
+		stopped, err := service.StopCurrentEvent(ctx)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, startTime.Add(37*time.Minute), stopped.EndTime)
+		assert.Equal(t, 45*time.Minute, stopped.RoundedDuration)
+		calendarEvents, err := calendarStub.GetLastEvents(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, calendarEvents, 1)
+		assert.Equal(t, startTime.Add(37*time.Minute), calendarEvents[0].EndTime)
+		require.Len(t, published, 1)
+		assert.Equal(t, startTime.Add(37*time.Minute), published[0].EndTime)
+		assert.Equal(t, 45*time.Minute, published[0].RoundedDuration)
+	})
+
+	t.Run("should keep the running event when the stopped event cannot be published", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		// given
+		startTime := clock.Now()
+		_, err := service.StartNewEvent(ctx, CurrentEvent{StartTime: startTime, PlanItem: PlanItem{BudgetItemId: 1, Name: "Writing"}})
+		require.NoError(t, err)
+		eventBus := event_bus.NewEventBus()
+		service.(*EventServiceImpl).eventBus = eventBus
+		event_bus.SubscribeTyped[event_bus.CurrentEventStopped](eventBus, "current_event.stopped",
+			func(e event_bus.EventT[event_bus.CurrentEventStopped]) error {
+				return errors.New("outbox unavailable")
+			})
+		clock.SetNow(startTime.Add(45 * time.Minute))
+
+		// when
+		_, err = service.StopCurrentEvent(ctx)
+
+		// then
+		require.Error(t, err)
+		current, err := service.FindCurrentEvent(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, startTime, current.StartTime)
+	})
+
+	t.Run("should return error when there is no current event", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		_, err := service.StopCurrentEvent(ctx)
+
+		assert.ErrorIs(t, err, ErrNoCurrentEvent)
+	})
+}
+
+func TestStopStaleEvent(t *testing.T) {
+	t.Run("should stop an event running for longer than the limit at the limit", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		// given
+		startTime := clock.Now()
+		_, err := service.StartNewEvent(ctx, CurrentEvent{StartTime: startTime, PlanItem: PlanItem{BudgetItemId: 1, Name: "Writing"}})
+		require.NoError(t, err)
+
+		// when
+		stopped, err := service.StopStaleEvent(ctx, startTime.Add(30*time.Hour), 24*time.Hour)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, stopped)
+		// The calendar splits the event at midnight
+		calendarEvents, err := calendarStub.GetLastEvents(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, calendarEvents, 2)
+		assert.Equal(t, startTime, calendarEvents[0].StartTime)
+		assert.Equal(t, startTime.Add(24*time.Hour), calendarEvents[1].EndTime)
+		current, err := service.FindCurrentEvent(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, current.Id)
+	})
+
+	t.Run("should keep an event running for less than the limit", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		// given
+		startTime := clock.Now()
+		_, err := service.StartNewEvent(ctx, CurrentEvent{StartTime: startTime, PlanItem: PlanItem{BudgetItemId: 1, Name: "Writing"}})
+		require.NoError(t, err)
+
+		// when
+		stopped, err := service.StopStaleEvent(ctx, startTime.Add(23*time.Hour), 24*time.Hour)
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, stopped)
+		current, err := service.FindCurrentEvent(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, startTime, current.StartTime)
+	})
+
+	t.Run("should do nothing without a running event", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		stopped, err := service.StopStaleEvent(ctx, clock.Now(), 24*time.Hour)
+
+		require.NoError(t, err)
+		assert.False(t, stopped)
+	})
+}
+
+func TestSwitchBack(t *testing.T) {
+	startEvent := func(t *testing.T, service Service, ctx context.Context, budgetItemId int, name string) {
+		_, err := service.StartNewEvent(ctx, CurrentEvent{
+			StartTime: clock.Now(),
+			PlanItem:  PlanItem{BudgetItemId: budgetItemId, Name: name},
+		})
+		require.NoError(t, err)
+		clock.SetNow(clock.Now().Add(30 * time.Minute))
+	}
+
+	t.Run("should start previously tracked item again", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		// given
+		weeklyPlanStub.items = []weekly_plan.WeeklyPlanItem{
+			{BudgetItemId: 1, Name: "Writing", WeeklyDuration: 5 * time.Hour},
+		}
+		clock.SetNow(clock.Now().Add(-2 * time.Hour))
+		startEvent(t, service, ctx, 1, "Writing")
+		startEvent(t, service, ctx, 2, "Emails")
+
+		// when
+		result, err := service.SwitchBack(ctx)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, PlanItem{BudgetItemId: 1, Name: "Writing", WeeklyDuration: 5 * time.Hour}, result.PlanItem)
+		assert.Equal(t, clock.Now(), result.StartTime)
+		calendarEvents, err := calendarStub.GetLastEvents(ctx, 10)
+		require.NoError(t, err)
+		assert.Len(t, calendarEvents, 2)
+	})
+
+	t.Run("should toggle between two items when called twice", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		clock.SetNow(clock.Now().Add(-2 * time.Hour))
+		startEvent(t, service, ctx, 1, "Writing")
+		startEvent(t, service, ctx, 2, "Emails")
+		_, err := service.SwitchBack(ctx)
+		require.NoError(t, err)
+		clock.SetNow(clock.Now().Add(30 * time.Minute))
+
+		result, err := service.SwitchBack(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.PlanItem.BudgetItemId)
+		assert.Equal(t, "Emails", result.PlanItem.Name)
+	})
+
+	t.Run("should skip events of deleted budget items", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		// given
+		clock.SetNow(clock.Now().Add(-2 * time.Hour))
+		startEvent(t, service, ctx, 1, "Writing")
+		startEvent(t, service, ctx, 2, "Emails")
+		// the previous event's budget item was deleted, the event was unlinked from it
+		_, err := calendarStub.AddEvent(ctx, calendar.Event{Summary: "Reading",
+			StartTime: clock.Now().Add(-10 * time.Minute), EndTime: clock.Now().Add(-5 * time.Minute)})
+		require.NoError(t, err)
+
+		// when
+		result, err := service.SwitchBack(ctx)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.PlanItem.BudgetItemId)
+	})
+
+	t.Run("should skip events of ad-hoc items", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		// given
+		clock.SetNow(clock.Now().Add(-2 * time.Hour))
+		startEvent(t, service, ctx, 1, "Writing")
+		startEvent(t, service, ctx, 2, "Emails")
+		// the most recent event was logged on an ad-hoc item of the week
+		_, err := calendarStub.AddEvent(ctx, calendar.Event{Summary: "Plumber visit",
+			StartTime: clock.Now().Add(-10 * time.Minute), EndTime: clock.Now().Add(-5 * time.Minute),
+			Metadata: calendar.EventMetadata{BudgetItemId: -3}})
+		require.NoError(t, err)
+
+		// when
+		items, err := service.GetRecentItems(ctx, 5)
+		require.NoError(t, err)
+		result, err := service.SwitchBack(ctx)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		assert.Equal(t, 1, items[0].BudgetItemId)
+		assert.Equal(t, 1, result.PlanItem.BudgetItemId)
+	})
+
+	t.Run("should return error when there is no current event", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		_, err := service.SwitchBack(ctx)
+
+		assert.ErrorIs(t, err, ErrNoCurrentEvent)
+	})
+
+	t.Run("should return error when there is no previous event", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		startEvent(t, service, ctx, 1, "Writing")
+
+		_, err := service.SwitchBack(ctx)
+
+		assert.ErrorIs(t, err, ErrNoPreviousEvent)
+	})
+}
+
+func TestStartDefaultEvent(t *testing.T) {
+	withDefaultItem := func(ctx context.Context, budgetItemId int) context.Context {
+		currentUser, _ := user.CurrentUser(ctx)
+		currentUser.Settings.DefaultBudgetItemId = budgetItemId
+		return user.WithUser(ctx, currentUser)
+	}
+
+	t.Run("should start tracking the default item", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		// given
+		ctx = withDefaultItem(ctx, 1)
+		weeklyPlanStub.items = []weekly_plan.WeeklyPlanItem{
+			{BudgetItemId: 1, Name: "Deep work", WeeklyDuration: 10 * time.Hour},
+		}
+
+		// when
+		result, started, err := service.StartDefaultEvent(ctx)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, started)
+		assert.Equal(t, PlanItem{BudgetItemId: 1, Name: "Deep work", WeeklyDuration: 10 * time.Hour}, result.PlanItem)
+		assert.Equal(t, clock.Now(), result.StartTime)
+	})
+
+	t.Run("should keep the running event of the default item", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		// given
+		ctx = withDefaultItem(ctx, 1)
+		weeklyPlanStub.items = []weekly_plan.WeeklyPlanItem{{BudgetItemId: 1, Name: "Deep work"}}
+		first, _, err := service.StartDefaultEvent(ctx)
+		require.NoError(t, err)
+		clock.SetNow(clock.Now().Add(30 * time.Minute))
+
+		// when
+		result, started, err := service.StartDefaultEvent(ctx)
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, started)
+		assert.Equal(t, first.StartTime, result.StartTime)
+		calendarEvents, err := calendarStub.GetLastEvents(ctx, 10)
+		require.NoError(t, err)
+		assert.Empty(t, calendarEvents)
+	})
+
+	t.Run("should return error when no default item is configured", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		_, _, err := service.StartDefaultEvent(ctx)
+
+		assert.ErrorIs(t, err, ErrNoDefaultItem)
+	})
+
+	t.Run("should return error when the default item is not planned this week", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		ctx = withDefaultItem(ctx, 5)
+		weeklyPlanStub.items = []weekly_plan.WeeklyPlanItem{{BudgetItemId: 1, Name: "Deep work"}}
+
+		_, _, err := service.StartDefaultEvent(ctx)
+
+		assert.ErrorIs(t, err, ErrDefaultItemNotPlanned)
+	})
+}
+
+func TestGetRecentItems(t *testing.T) {
+	service, ctx, teardown := setupServiceTest(t)
+	defer teardown()
+
+	// given
+	clock.SetNow(clock.Now().Add(-3 * time.Hour))
+	for _, item := range []PlanItem{
+		{BudgetItemId: 1, Name: "Writing"},
+		{BudgetItemId: 2, Name: "Emails"},
+		{BudgetItemId: 1, Name: "Writing"},
+		{BudgetItemId: 3, Name: "Reading"},
+		{BudgetItemId: 2, Name: "Emails"},
+	} {
+		_, err := service.StartNewEvent(ctx, CurrentEvent{StartTime: clock.Now(), PlanItem: item})
+		require.NoError(t, err)
+		clock.SetNow(clock.Now().Add(30 * time.Minute))
+	}
+
+	// when
+	items, err := service.GetRecentItems(ctx, 5)
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, 3, items[0].BudgetItemId)
+	assert.Equal(t, 1, items[1].BudgetItemId)
+
+	// when
+	items, err = service.GetRecentItems(ctx, math.MaxInt)
+
+	// then
+	require.NoError(t, err)
+	assert.Len(t, items, 2)
+}
+
+func TestReportIdle(t *testing.T) {
+	withDiscardIdleTime := func(t *testing.T, ctx context.Context) context.Context {
+		currentUser, err := user.CurrentUser(ctx)
+		require.NoError(t, err)
+		currentUser.Settings.DiscardIdleTime = true
+		return user.WithUser(ctx, currentUser)
+	}
+	planItem := PlanItem{BudgetItemId: 1, Name: "Writing", WeeklyDuration: time.Hour}
+
+	t.Run("should split running event when idle time is discarded", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		ctx = withDiscardIdleTime(t, ctx)
+
+		// given
+		eventStart := clock.Now().Add(-3 * time.Hour)
+		_, err := service.StartNewEvent(ctx, CurrentEvent{StartTime: eventStart, PlanItem: planItem, Notes: "draft"})
+		require.NoError(t, err)
+		idleStart := clock.Now().Add(-2 * time.Hour)
+		idleEnd := clock.Now().Add(-1 * time.Hour)
+
+		// when
+		result, err := service.ReportIdle(ctx, idleStart, idleEnd)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, idleEnd, result.StartTime)
+		assert.Equal(t, planItem, result.PlanItem)
+		assert.Equal(t, "draft", result.Notes)
+		calendarEvents, err := calendarStub.GetLastEvents(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, calendarEvents, 1)
+		assert.Equal(t, eventStart, calendarEvents[0].StartTime)
+		assert.Equal(t, idleStart, calendarEvents[0].EndTime)
+		assert.Equal(t, "draft", calendarEvents[0].Metadata.Notes)
+	})
+
+	t.Run("should move start of running event when idle period covers it", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		ctx = withDiscardIdleTime(t, ctx)
+
+		_, err := service.StartNewEvent(ctx, CurrentEvent{StartTime: clock.Now().Add(-1 * time.Hour), PlanItem: planItem})
+		require.NoError(t, err)
+		idleEnd := clock.Now().Add(-30 * time.Minute)
+
+		result, err := service.ReportIdle(ctx, clock.Now().Add(-2*time.Hour), idleEnd)
+
+		require.NoError(t, err)
+		assert.Equal(t, idleEnd, result.StartTime)
+		calendarEvents, err := calendarStub.GetLastEvents(ctx, 10)
+		require.NoError(t, err)
+		assert.Empty(t, calendarEvents)
+	})
+
+	t.Run("should keep running event when idle time is kept", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		eventStart := clock.Now().Add(-3 * time.Hour)
+		_, err := service.StartNewEvent(ctx, CurrentEvent{StartTime: eventStart, PlanItem: planItem})
+		require.NoError(t, err)
+
+		result, err := service.ReportIdle(ctx, clock.Now().Add(-2*time.Hour), clock.Now().Add(-1*time.Hour))
+
+		require.NoError(t, err)
+		assert.Equal(t, eventStart, result.StartTime)
+		current, err := service.FindCurrentEvent(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, eventStart, current.StartTime)
+	})
+
+	t.Run("should reject idle period ending in the future", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		_, err := service.ReportIdle(ctx, clock.Now().Add(-time.Hour), clock.Now().Add(time.Hour))
+
+		assert.ErrorIs(t, err, ErrInvalidIdlePeriod)
+	})
+
+	t.Run("should return error when there is no current event", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+
+		_, err := service.ReportIdle(ctx, clock.Now().Add(-2*time.Hour), clock.Now().Add(-time.Hour))
+
+		assert.ErrorIs(t, err, ErrNoCurrentEvent)
+	})
+}
+
+func TestStartNewEvent_ShortEventHandling(t *testing.T) {
+	withShortEventSettings := func(ctx context.Context, threshold time.Duration, handling user.ShortEventHandling) context.Context {
+		currentUser, _ := user.CurrentUser(ctx)
+		currentUser.Settings.IgnoreShortEvents = true
+		currentUser.Settings.ShortEventThreshold = threshold
+		currentUser.Settings.ShortEventHandling = handling
+		return user.WithUser(context.Background(), currentUser)
+	}
+	// previous (30 min) -> short (duration) -> next
+	startEvents := func(t *testing.T, service Service, ctx context.Context, shortDuration time.Duration) (time.Time, CurrentEvent) {
+		previousStart := clock.Now()
+		_, err := service.StartNewEvent(ctx, CurrentEvent{StartTime: clock.Now(), PlanItem: PlanItem{BudgetItemId: 1, Name: "Previous"}})
+		require.NoError(t, err)
+		clock.SetNow(clock.Now().Add(30 * time.Minute))
+		_, err = service.StartNewEvent(ctx, CurrentEvent{StartTime: clock.Now(), PlanItem: PlanItem{BudgetItemId: 2, Name: "Short"}})
+		require.NoError(t, err)
+		clock.SetNow(clock.Now().Add(shortDuration))
+		result, err := service.StartNewEvent(ctx, CurrentEvent{StartTime: clock.Now(), PlanItem: PlanItem{BudgetItemId: 3, Name: "Next"}})
+		require.NoError(t, err)
+		return previousStart, result
+	}
+
+	t.Run("should use configured threshold", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		ctx = withShortEventSettings(ctx, 5*time.Minute, user.ShortEventMergeNext)
+
+		_, result := startEvents(t, service, ctx, 3*time.Minute)
+
+		calendarEvents, err := calendarStub.GetLastEvents(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, calendarEvents, 1)
+		assert.Equal(t, "Previous", calendarEvents[0].Summary)
+		assert.Equal(t, calendarEvents[0].EndTime, result.StartTime)
+	})
+
+	t.Run("should store event longer than configured threshold", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		ctx = withShortEventSettings(ctx, 5*time.Minute, user.ShortEventMergeNext)
+
+		startEvents(t, service, ctx, 6*time.Minute)
+
+		calendarEvents, err := calendarStub.GetLastEvents(ctx, 10)
+		require.NoError(t, err)
+		assert.Len(t, calendarEvents, 2)
+	})
+
+	t.Run("should discard short event", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		ctx = withShortEventSettings(ctx, 5*time.Minute, user.ShortEventDiscard)
+
+		previousStart, result := startEvents(t, service, ctx, 3*time.Minute)
+
+		calendarEvents, err := calendarStub.GetLastEvents(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, calendarEvents, 1)
+		assert.Equal(t, previousStart.Add(30*time.Minute), calendarEvents[0].EndTime)
+		assert.Equal(t, clock.Now(), result.StartTime)
+	})
+
+	t.Run("should merge short event into previous one", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		ctx = withShortEventSettings(ctx, 5*time.Minute, user.ShortEventMergePrevious)
+
+		previousStart, result := startEvents(t, service, ctx, 3*time.Minute)
+
+		calendarEvents, err := calendarStub.GetLastEvents(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, calendarEvents, 1)
+		assert.Equal(t, "Previous", calendarEvents[0].Summary)
+		assert.Equal(t, previousStart, calendarEvents[0].StartTime)
+		assert.Equal(t, clock.Now(), calendarEvents[0].EndTime)
+		assert.Equal(t, clock.Now(), result.StartTime)
+	})
+
+	t.Run("should default to one minute threshold", func(t *testing.T) {
+		service, ctx, teardown := setupServiceTest(t)
+		defer teardown()
+		ctx = withShortEventSettings(ctx, 0, user.ShortEventDiscard)
+
+		startEvents(t, service, ctx, 2*time.Minute)
+
+		calendarEvents, err := calendarStub.GetLastEvents(ctx, 10)
+		require.NoError(t, err)
+		assert.Len(t, calendarEvents, 2)
+	})
+}
@@ -0,0 +1,17 @@
+package db_activity
+
+import "time"
+
+// Query is a statement currently executed by a database connection of the application role.
+type Query struct {
+	Pid             int
+	ApplicationName string
+	ClientAddr      string
+	State           string
+	WaitEventType   string
+	WaitEvent       string
+	Query           string
+	QueryStart      time.Time
+	// Duration is how long the query has been running at the time it was listed
+	Duration time.Duration
+}
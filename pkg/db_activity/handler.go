@@ -0,0 +1,104 @@
+package db_activity
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+type QueryDTO struct {
+	Pid             int       `json:"pid"`
+	ApplicationName string    `json:"applicationName"`
+	ClientAddr      string    `json:"clientAddr"`
+	State           string    `json:"state"`
+	WaitEventType   string    `json:"waitEventType"`
+	WaitEvent       string    `json:"waitEvent"`
+	Query           string    `json:"query"`
+	QueryStart      time.Time `json:"queryStart"`
+	// DurationMs is how long the query has been running, in milliseconds
+	DurationMs int64 `json:"durationMs"`
+}
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// ListQueries godoc
+// @Summary List running database queries
+// @Description List the queries currently running in the database connections of the application role,
+// @Description the longest running first. Requires an admin user.
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} QueryDTO
+// @Failure 403 {string} string "User not found or not an admin"
+// @Router /api/admin/db/queries [get]
+// @Security XUserId
+func (h *Handler) ListQueries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	queries, err := h.service.ListQueries(r.Context())
+	if err != nil {
+		log.Errorf("Failed to list database queries: %v", err)
+		http.Error(w, "Failed to list database queries", http.StatusInternalServerError)
+		return
+	}
+
+	dtos := make([]QueryDTO, 0, len(queries))
+	for _, q := range queries {
+		dtos = append(dtos, QueryDTO{
+			Pid:             q.Pid,
+			ApplicationName: q.ApplicationName,
+			ClientAddr:      q.ClientAddr,
+			State:           q.State,
+			WaitEventType:   q.WaitEventType,
+			WaitEvent:       q.WaitEvent,
+			Query:           q.Query,
+			QueryStart:      q.QueryStart,
+			DurationMs:      q.Duration.Milliseconds(),
+		})
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		log.Errorf("Failed to encode database queries: %v", err)
+		http.Error(w, "Failed to encode database queries", http.StatusInternalServerError)
+	}
+}
+
+// CancelQuery godoc
+// @Summary Cancel a running database query
+// @Description Cancel the query running in the given backend of the application role. Only the current query is
+// @Description cancelled, the connection stays open. Requires an admin user.
+// @Tags Admin
+// @Param pid path int true "Backend process ID"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "User not found or not an admin"
+// @Failure 404 {string} string "Query not found"
+// @Router /api/admin/db/queries/{pid} [delete]
+// @Security XUserId
+func (h *Handler) CancelQuery(w http.ResponseWriter, r *http.Request) {
+	pid, err := strconv.Atoi(mux.Vars(r)["pid"])
+	if err != nil {
+		http.Error(w, "Invalid pid", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.CancelQuery(r.Context(), pid); err != nil {
+		if errors.Is(err, ErrQueryNotFound) {
+			http.Error(w, "Query not found", http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to cancel database query: %v", err)
+		http.Error(w, "Failed to cancel database query", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
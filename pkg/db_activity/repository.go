@@ -0,0 +1,92 @@
+package db_activity
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository interface {
+	// GetActiveQueries returns the queries running in connections of the application role, except the calling one.
+	GetActiveQueries(ctx context.Context) ([]Query, error)
+	// CancelQuery cancels the query running in the backend of the application role with the given pid.
+	// It returns false when no such backend exists.
+	CancelQuery(ctx context.Context, pid int) (bool, error)
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+func (r *RepositoryImpl) GetActiveQueries(ctx context.Context) ([]Query, error) {
+	query := `SELECT pid,
+					 COALESCE(application_name, ''),
+					 COALESCE(HOST(client_addr), ''),
+					 COALESCE(state, ''),
+					 COALESCE(wait_event_type, ''),
+					 COALESCE(wait_event, ''),
+					 COALESCE(query, ''),
+					 query_start,
+					 EXTRACT(EPOCH FROM NOW() - query_start)::float8
+			  FROM pg_stat_activity
+			  WHERE usename = current_user
+				AND pid <> pg_backend_pid()
+				AND state IS DISTINCT FROM 'idle'
+				AND query_start IS NOT NULL
+			  ORDER BY query_start`
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active queries: %w", err)
+	}
+	defer rows.Close()
+
+	queries := make([]Query, 0)
+	for rows.Next() {
+		var q Query
+		var durationSec float64
+		err := rows.Scan(&q.Pid, &q.ApplicationName, &q.ClientAddr, &q.State, &q.WaitEventType, &q.WaitEvent,
+			&q.Query, &q.QueryStart, &durationSec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan active query: %w", err)
+		}
+		q.Duration = time.Duration(durationSec * float64(time.Second))
+		queries = append(queries, q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get active queries: %w", err)
+	}
+	return queries, nil
+}
+
+func (r *RepositoryImpl) CancelQuery(ctx context.Context, pid int) (bool, error) {
+	// Only backends of the application role can be cancelled, pg_cancel_backend is not called for other pids
+	query := `SELECT pg_cancel_backend(pid)
+			  FROM pg_stat_activity
+			  WHERE pid = $1
+				AND usename = current_user
+				AND pid <> pg_backend_pid()`
+	rows, err := r.db.Query(ctx, query, pid)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel query: %w", err)
+	}
+	defer rows.Close()
+
+	found := false
+	for rows.Next() {
+		var cancelled bool
+		if err := rows.Scan(&cancelled); err != nil {
+			return false, fmt.Errorf("failed to cancel query: %w", err)
+		}
+		found = true
+	}
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("failed to cancel query: %w", err)
+	}
+	return found, nil
+}
@@ -0,0 +1,50 @@
+package db_activity
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+type RepositoryStub struct {
+	mu        sync.RWMutex
+	queries   []Query
+	cancelled []int
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{}
+}
+
+func (r *RepositoryStub) GetActiveQueries(ctx context.Context) ([]Query, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.queries), nil
+}
+
+func (r *RepositoryStub) CancelQuery(ctx context.Context, pid int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, q := range r.queries {
+		if q.Pid == pid {
+			r.queries = slices.Delete(r.queries, i, i+1)
+			r.cancelled = append(r.cancelled, pid)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AddQuery makes the query show up as running.
+func (r *RepositoryStub) AddQuery(query Query) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries = append(r.queries, query)
+}
+
+// Cancelled returns the pids of the cancelled queries.
+func (r *RepositoryStub) Cancelled() []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.cancelled)
+}
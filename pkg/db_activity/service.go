@@ -0,0 +1,42 @@
+package db_activity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrQueryNotFound = errors.New("query not found")
+
+type Service interface {
+	// ListQueries returns the database queries currently running for the application, the longest running first.
+	ListQueries(ctx context.Context) ([]Query, error)
+	// CancelQuery cancels the query running in the backend with the given pid.
+	CancelQuery(ctx context.Context, pid int) error
+}
+
+type ServiceImpl struct {
+	repo Repository
+}
+
+func NewService(repo Repository) *ServiceImpl {
+	return &ServiceImpl{repo: repo}
+}
+
+func (s *ServiceImpl) ListQueries(ctx context.Context) ([]Query, error) {
+	return s.repo.GetActiveQueries(ctx)
+}
+
+func (s *ServiceImpl) CancelQuery(ctx context.Context, pid int) error {
+	found, err := s.repo.CancelQuery(ctx, pid)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("%w: pid %d", ErrQueryNotFound, pid)
+	}
+	log.Infof("cancelled database query of backend %d", pid)
+	return nil
+}
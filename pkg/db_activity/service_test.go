@@ -0,0 +1,52 @@
+package db_activity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_ListQueries(t *testing.T) {
+	repo := NewRepositoryStub()
+	service := NewService(repo)
+	ctx := context.Background()
+
+	queries, err := service.ListQueries(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, queries)
+
+	repo.AddQuery(Query{Pid: 101, State: "active", Query: "SELECT 1", Duration: 5 * time.Second})
+
+	queries, err = service.ListQueries(ctx)
+	require.NoError(t, err)
+	require.Len(t, queries, 1)
+	assert.Equal(t, 101, queries[0].Pid)
+	assert.Equal(t, 5*time.Second, queries[0].Duration)
+}
+
+func TestService_CancelQuery(t *testing.T) {
+	repo := NewRepositoryStub()
+	service := NewService(repo)
+	ctx := context.Background()
+	repo.AddQuery(Query{Pid: 101, State: "active", Query: "SELECT 1"})
+	repo.AddQuery(Query{Pid: 102, State: "active", Query: "SELECT 2"})
+
+	t.Run("cancels the query of the backend", func(t *testing.T) {
+		err := service.CancelQuery(ctx, 101)
+		require.NoError(t, err)
+
+		assert.Equal(t, []int{101}, repo.Cancelled())
+		queries, err := service.ListQueries(ctx)
+		require.NoError(t, err)
+		require.Len(t, queries, 1)
+		assert.Equal(t, 102, queries[0].Pid)
+	})
+
+	t.Run("fails for an unknown backend", func(t *testing.T) {
+		err := service.CancelQuery(ctx, 999)
+		assert.ErrorIs(t, err, ErrQueryNotFound)
+	})
+}
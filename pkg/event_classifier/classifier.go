@@ -0,0 +1,155 @@
+package event_classifier
+
+import (
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+)
+
+// Entry is a proposed event, e.g. the summary typed by the user or an entry of an external calendar.
+type Entry struct {
+	Summary     string
+	Description string
+	// StartTime is when the entry starts, zero when unknown. The items tracked at the same time of day weigh more.
+	StartTime time.Time
+}
+
+// Suggestion is a budget item the entry likely belongs to.
+type Suggestion struct {
+	BudgetItemId int
+	Name         string
+	Color        string
+	// Confidence is between 0 and 1, the share of the entry's words pointing to the item.
+	Confidence float64
+}
+
+// stopWords are too common to tell the budget items apart.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "at": true, "for": true, "in": true, "of": true, "on": true, "or": true,
+	"the": true, "to": true, "with": true,
+}
+
+// words returns the lowercase words of the text without stop words and single characters.
+func words(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	result := fields[:0]
+	for _, field := range fields {
+		if utf8.RuneCountInString(field) > 1 && !stopWords[field] {
+			result = append(result, field)
+		}
+	}
+	return result
+}
+
+// features returns the distinct words of the summary and description, and the whole summary when it has more than
+// one word, so the same summary tracked before weighs more than a few shared words.
+func features(summary string, description string) []string {
+	summaryWords := words(summary)
+	seen := make(map[string]bool)
+	var result []string
+	add := func(feature string) {
+		if !seen[feature] {
+			seen[feature] = true
+			result = append(result, feature)
+		}
+	}
+	if len(summaryWords) > 1 {
+		add(strings.Join(summaryWords, " "))
+	}
+	for _, word := range summaryWords {
+		add(word)
+	}
+	for _, word := range words(description) {
+		add(word)
+	}
+	return result
+}
+
+// timeOfDay returns the feature of the part of the day the time falls into, in the time zone of the time. It can't be
+// mistaken for a word, which has only letters and digits.
+func timeOfDay(t time.Time) string {
+	switch hour := t.Hour(); {
+	case hour < 6:
+		return "@night"
+	case hour < 12:
+		return "@morning"
+	case hour < 18:
+		return "@afternoon"
+	default:
+		return "@evening"
+	}
+}
+
+// model counts in how many events of each budget item a feature appeared.
+type model struct {
+	itemsByFeature map[string]map[int]int
+	eventsByItem   map[int]int
+	location       *time.Location
+}
+
+// newModel learns from the events of the plan items. The name of each item counts as one more event of the item, so
+// the items not tracked yet are suggested too. The time of day of the events is the one in the user's location.
+func newModel(events []calendar.Event, items []weekly_plan.WeeklyPlanItem, location *time.Location) *model {
+	m := &model{
+		itemsByFeature: make(map[string]map[int]int),
+		eventsByItem:   make(map[int]int),
+		location:       location,
+	}
+	planned := make(map[int]bool, len(items))
+	for _, item := range items {
+		planned[item.BudgetItemId] = true
+		m.learn(item.BudgetItemId, features(item.Name, ""))
+	}
+	for _, event := range events {
+		if event.Metadata.Sandbox || !planned[event.Metadata.BudgetItemId] {
+			continue
+		}
+		m.eventsByItem[event.Metadata.BudgetItemId]++
+		m.learn(event.Metadata.BudgetItemId, features(event.Summary, event.Metadata.Notes))
+		m.learn(event.Metadata.BudgetItemId, []string{timeOfDay(event.StartTime.In(location))})
+	}
+	return m
+}
+
+func (m *model) learn(budgetItemId int, features []string) {
+	for _, feature := range features {
+		if m.itemsByFeature[feature] == nil {
+			m.itemsByFeature[feature] = make(map[int]int)
+		}
+		m.itemsByFeature[feature][budgetItemId]++
+	}
+}
+
+// scores returns the confidence of each budget item sharing a word with the entry. Each feature splits its vote
+// between the items by the number of their events it appeared in. The time of day of the entry only adds to the
+// items sharing a word, it is not enough to suggest an item.
+func (m *model) scores(entry Entry) map[int]float64 {
+	entryFeatures := features(entry.Summary, entry.Description)
+	scores := make(map[int]float64)
+	for _, feature := range entryFeatures {
+		for budgetItemId := range m.itemsByFeature[feature] {
+			scores[budgetItemId] = 0
+		}
+	}
+	if !entry.StartTime.IsZero() {
+		entryFeatures = append(entryFeatures, timeOfDay(entry.StartTime.In(m.location)))
+	}
+	for _, feature := range entryFeatures {
+		total := 0
+		for _, count := range m.itemsByFeature[feature] {
+			total += count
+		}
+		for budgetItemId, count := range m.itemsByFeature[feature] {
+			if _, ok := scores[budgetItemId]; ok {
+				scores[budgetItemId] += float64(count) / float64(total) / float64(len(entryFeatures))
+			}
+		}
+	}
+	return scores
+}
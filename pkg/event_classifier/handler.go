@@ -0,0 +1,96 @@
+package event_classifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/klokku/klokku/internal/rest"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	MaxSummaryLen     = 500
+	MaxDescriptionLen = 5000
+)
+
+type EntryDTO struct {
+	Summary     string `json:"summary"`
+	Description string `json:"description,omitempty"`
+	// StartTime is when the event starts, the items tracked at the same time of day of the user are more likely
+	StartTime *time.Time `json:"start,omitempty"`
+}
+
+type SuggestionDTO struct {
+	BudgetItemId int    `json:"budgetItemId"`
+	Name         string `json:"name"`
+	Color        string `json:"color"`
+	// Confidence is between 0 and 1
+	Confidence float64 `json:"confidence"`
+}
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// Classify godoc
+// @Summary Suggest the budget item of an event
+// @Description Recommend the items of the current weekly plan a proposed event or an external calendar entry most
+// @Description likely belongs to, based on the words and the time of day of the events tracked before. The most
+// @Description likely item is first, the list is empty when nothing matches or there is no current plan.
+// @Tags CurrentEvent
+// @Accept json
+// @Produce json
+// @Param entry body EntryDTO true "Proposed event"
+// @Success 200 {array} SuggestionDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid entry"
+// @Failure 403 {string} string "User not found"
+// @Router /api/event/classify [post]
+// @Security XUserId
+func (h *Handler) Classify(w http.ResponseWriter, r *http.Request) {
+	var entry EntryDTO
+	if err := rest.DecodeJSON(r, &entry); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	var v rest.Validator
+	v.Required(entry.Summary, "summary", "Invalid entry")
+	v.Check(len(entry.Summary) <= MaxSummaryLen, "summary", "Invalid entry",
+		fmt.Sprintf("'summary' must have at most %d characters", MaxSummaryLen))
+	v.Check(len(entry.Description) <= MaxDescriptionLen, "description", "Invalid entry",
+		fmt.Sprintf("'description' must have at most %d characters", MaxDescriptionLen))
+	if err := v.Err(); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+
+	classified := Entry{Summary: entry.Summary, Description: entry.Description}
+	if entry.StartTime != nil {
+		classified.StartTime = *entry.StartTime
+	}
+	suggestions, err := h.service.Classify(r.Context(), classified)
+	if err != nil {
+		log.Errorf("Failed to classify event: %v", err)
+		http.Error(w, "Failed to classify event", http.StatusInternalServerError)
+		return
+	}
+	result := make([]SuggestionDTO, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		result = append(result, SuggestionDTO{
+			BudgetItemId: suggestion.BudgetItemId,
+			Name:         suggestion.Name,
+			Color:        suggestion.Color,
+			Confidence:   math.Round(suggestion.Confidence*100) / 100,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,117 @@
+package event_classifier
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+)
+
+const (
+	// historyLookback is the number of last calendar events the suggestions are learned from.
+	historyLookback = 500
+	MaxSuggestions  = 3
+)
+
+type Service interface {
+	// Classify suggests the items of the user's current weekly plan the entry most likely belongs to, the most likely
+	// first. Nothing is suggested when no item shares a word with the entry, or when the user has no current plan.
+	Classify(ctx context.Context, entry Entry) ([]Suggestion, error)
+	// ClassifyAll is Classify for many entries, e.g. the activities of an imported file. The history is read only
+	// once, the suggestions of each entry are at its index.
+	ClassifyAll(ctx context.Context, entries []Entry) ([][]Suggestion, error)
+}
+
+type eventHistory interface {
+	GetLastEvents(ctx context.Context, limit int) ([]calendar.Event, error)
+}
+
+type weeklyPlanItemsReader interface {
+	GetItemsForWeek(ctx context.Context, date time.Time) ([]weekly_plan.WeeklyPlanItem, error)
+}
+
+type ServiceImpl struct {
+	events     eventHistory
+	weeklyPlan weeklyPlanItemsReader
+	clock      utils.Clock
+}
+
+func NewService(events eventHistory, weeklyPlan weeklyPlanItemsReader) *ServiceImpl {
+	return &ServiceImpl{
+		events:     events,
+		weeklyPlan: weeklyPlan,
+		clock:      &utils.SystemClock{},
+	}
+}
+
+func (s *ServiceImpl) Classify(ctx context.Context, entry Entry) ([]Suggestion, error) {
+	suggestions, err := s.ClassifyAll(ctx, []Entry{entry})
+	if err != nil {
+		return nil, err
+	}
+	return suggestions[0], nil
+}
+
+func (s *ServiceImpl) ClassifyAll(ctx context.Context, entries []Entry) ([][]Suggestion, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	location, err := time.LoadLocation(currentUser.Settings.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	result := make([][]Suggestion, len(entries))
+	items, err := s.weeklyPlan.GetItemsForWeek(ctx, s.clock.Now())
+	if err != nil {
+		if errors.Is(err, weekly_plan.ErrNoCurrentPlan) {
+			for i := range result {
+				result[i] = []Suggestion{}
+			}
+			return result, nil
+		}
+		return nil, fmt.Errorf("failed to get weekly plan items: %w", err)
+	}
+	events, err := s.events.GetLastEvents(ctx, historyLookback)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last calendar events: %w", err)
+	}
+	m := newModel(events, items, location)
+	for i, entry := range entries {
+		result[i] = m.suggest(entry, items)
+	}
+	return result, nil
+}
+
+// suggest returns the items of the plan the entry most likely belongs to, the most likely first.
+func (m *model) suggest(entry Entry, items []weekly_plan.WeeklyPlanItem) []Suggestion {
+	scores := m.scores(entry)
+	suggestions := make([]Suggestion, 0, len(scores))
+	for _, item := range items {
+		if score, ok := scores[item.BudgetItemId]; ok {
+			suggestions = append(suggestions, Suggestion{
+				BudgetItemId: item.BudgetItemId,
+				Name:         item.Name,
+				Color:        item.Color,
+				Confidence:   score,
+			})
+		}
+	}
+	slices.SortStableFunc(suggestions, func(a, b Suggestion) int {
+		if a.Confidence != b.Confidence {
+			return cmp.Compare(b.Confidence, a.Confidence)
+		}
+		return cmp.Compare(m.eventsByItem[b.BudgetItemId], m.eventsByItem[a.BudgetItemId])
+	})
+	if len(suggestions) > MaxSuggestions {
+		suggestions = suggestions[:MaxSuggestions]
+	}
+	return suggestions
+}
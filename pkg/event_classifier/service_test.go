@@ -0,0 +1,222 @@
+package event_classifier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2025, 6, 9, 9, 0, 0, 0, time.UTC)
+
+var testUser = user.User{
+	Id:       1,
+	Uid:      "user-1",
+	Username: "test-user-1",
+	Settings: user.Settings{Timezone: "UTC", WeekFirstDay: time.Monday},
+}
+
+type eventsStub struct {
+	events []calendar.Event
+	reads  int
+}
+
+func (e *eventsStub) GetLastEvents(ctx context.Context, limit int) ([]calendar.Event, error) {
+	e.reads++
+	return e.events, nil
+}
+
+type weeklyPlanStub struct {
+	items []weekly_plan.WeeklyPlanItem
+	err   error
+}
+
+func (w *weeklyPlanStub) GetItemsForWeek(ctx context.Context, date time.Time) ([]weekly_plan.WeeklyPlanItem, error) {
+	return w.items, w.err
+}
+
+func setupServiceTest(t *testing.T, events ...calendar.Event) (*ServiceImpl, context.Context) {
+	t.Helper()
+	weeklyPlan := &weeklyPlanStub{items: []weekly_plan.WeeklyPlanItem{
+		{Id: 1, BudgetItemId: 10, Name: "Work", Color: "#0000ff"},
+		{Id: 2, BudgetItemId: 11, Name: "Reading", Color: "#ff0000"},
+		{Id: 3, BudgetItemId: 12, Name: "Exercise", Color: "#00ff00"},
+	}}
+	service := NewService(&eventsStub{events: events}, weeklyPlan)
+	service.clock = &utils.MockClock{FixedNow: now}
+	return service, user.WithUser(context.Background(), testUser)
+}
+
+func event(summary string, budgetItemId int) calendar.Event {
+	return calendar.Event{Summary: summary, Metadata: calendar.EventMetadata{BudgetItemId: budgetItemId}}
+}
+
+func TestClassify_SuggestsItemOfSameSummary(t *testing.T) {
+	// given
+	service, ctx := setupServiceTest(t,
+		event("Sprint planning", 10),
+		event("Sprint planning", 10),
+		event("Book club planning", 11),
+	)
+
+	// when
+	suggestions, err := service.Classify(ctx, Entry{Summary: "Sprint Planning"})
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, suggestions, 2)
+	assert.Equal(t, 10, suggestions[0].BudgetItemId)
+	assert.Equal(t, "Work", suggestions[0].Name)
+	assert.Equal(t, "#0000ff", suggestions[0].Color)
+	assert.InDelta(t, 2.0/3+1.0/3*2.0/3, suggestions[0].Confidence, 0.0001)
+	assert.Equal(t, 11, suggestions[1].BudgetItemId)
+	assert.InDelta(t, 1.0/3*1.0/3, suggestions[1].Confidence, 0.0001)
+}
+
+func TestClassify_UsesDescriptionAndNotes(t *testing.T) {
+	// given
+	service, ctx := setupServiceTest(t, calendar.Event{
+		Summary:  "Morning",
+		Metadata: calendar.EventMetadata{BudgetItemId: 12, Notes: "gym, legs"},
+	})
+
+	// when
+	suggestions, err := service.Classify(ctx, Entry{Summary: "Session", Description: "Gym with a trainer"})
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, 12, suggestions[0].BudgetItemId)
+}
+
+func TestClassify_MatchesItemNamesWithoutHistory(t *testing.T) {
+	// given
+	service, ctx := setupServiceTest(t)
+
+	// when
+	suggestions, err := service.Classify(ctx, Entry{Summary: "reading: the hobbit"})
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, 11, suggestions[0].BudgetItemId)
+}
+
+func TestClassify_IgnoresItemsNotInPlanAndSandboxEvents(t *testing.T) {
+	// given
+	sandboxEvent := event("Standup", 11)
+	sandboxEvent.Metadata.Sandbox = true
+	service, ctx := setupServiceTest(t,
+		event("Standup", 99),
+		sandboxEvent,
+	)
+
+	// when
+	suggestions, err := service.Classify(ctx, Entry{Summary: "Standup"})
+
+	// then
+	require.NoError(t, err)
+	assert.Empty(t, suggestions)
+}
+
+func TestClassify_TiesGoToMoreTrackedItem(t *testing.T) {
+	// given
+	service, ctx := setupServiceTest(t,
+		event("Call", 10),
+		event("Call", 11),
+		event("Novel", 11),
+	)
+
+	// when
+	suggestions, err := service.Classify(ctx, Entry{Summary: "call"})
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, suggestions, 2)
+	assert.Equal(t, 11, suggestions[0].BudgetItemId)
+	assert.Equal(t, 10, suggestions[1].BudgetItemId)
+}
+
+func TestClassify_PrefersItemTrackedAtSameTimeOfDayOfUser(t *testing.T) {
+	// given
+	morningCall := event("Call", 10)
+	morningCall.StartTime = time.Date(2025, 6, 2, 14, 0, 0, 0, time.UTC) // 10:00 in New York
+	eveningCall := event("Call", 11)
+	eveningCall.StartTime = time.Date(2025, 6, 2, 23, 0, 0, 0, time.UTC) // 19:00 in New York
+	service, _ := setupServiceTest(t, morningCall, eveningCall)
+	newYorkUser := testUser
+	newYorkUser.Settings.Timezone = "America/New_York"
+	ctx := user.WithUser(context.Background(), newYorkUser)
+
+	// when
+	suggestions, err := service.Classify(ctx, Entry{
+		Summary:   "call",
+		StartTime: time.Date(2025, 6, 10, 3, 0, 0, 0, time.UTC), // 23:00 in New York
+	})
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, suggestions, 2)
+	assert.Equal(t, 11, suggestions[0].BudgetItemId)
+	assert.Equal(t, 10, suggestions[1].BudgetItemId)
+}
+
+func TestClassify_TimeOfDayAloneSuggestsNothing(t *testing.T) {
+	// given
+	call := event("Call", 10)
+	call.StartTime = time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	service, ctx := setupServiceTest(t, call)
+
+	// when
+	suggestions, err := service.Classify(ctx, Entry{Summary: "Dentist", StartTime: call.StartTime})
+
+	// then
+	require.NoError(t, err)
+	assert.Empty(t, suggestions)
+}
+
+func TestClassify_NoCurrentPlan(t *testing.T) {
+	// given
+	service, ctx := setupServiceTest(t, event("Call", 10))
+	service.weeklyPlan = &weeklyPlanStub{err: weekly_plan.ErrNoCurrentPlan}
+
+	// when
+	suggestions, err := service.Classify(ctx, Entry{Summary: "Call"})
+
+	// then
+	require.NoError(t, err)
+	assert.NotNil(t, suggestions)
+	assert.Empty(t, suggestions)
+}
+
+func Test_features(t *testing.T) {
+	assert.Equal(t, []string{"weekly sync team", "weekly", "sync", "team", "notes"},
+		features("Weekly sync with the TEAM", "notes, a sync"))
+	assert.Empty(t, features("a -", ""))
+}
+
+func TestClassifyAll_SuggestsForEachEntry(t *testing.T) {
+	// given
+	history := &eventsStub{events: []calendar.Event{event("Standup", 10), event("The Hobbit", 11)}}
+	service, ctx := setupServiceTest(t)
+	service.events = history
+
+	// when
+	suggestions, err := service.ClassifyAll(ctx, []Entry{{Summary: "Hobbit"}, {Summary: "Dentist"}, {Summary: "standup"}})
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, suggestions, 3)
+	require.Len(t, suggestions[0], 1)
+	assert.Equal(t, 11, suggestions[0][0].BudgetItemId)
+	assert.Empty(t, suggestions[1])
+	require.Len(t, suggestions[2], 1)
+	assert.Equal(t, 10, suggestions[2][0].BudgetItemId)
+	assert.Equal(t, 1, history.reads)
+}
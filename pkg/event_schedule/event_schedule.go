@@ -0,0 +1,45 @@
+// Package event_schedule automatically starts current events at configured times of the week.
+package event_schedule
+
+import (
+	"fmt"
+	"time"
+)
+
+// Schedule defines when the current event for a budget item should be started automatically,
+// e.g. "start Work at 09:00 on weekdays".
+type Schedule struct {
+	Id           int
+	UserId       int
+	BudgetItemId int
+	Weekdays     []time.Weekday
+	// StartTime is the offset from midnight in the user's timezone.
+	StartTime time.Duration
+	Enabled   bool
+	LastRunAt *time.Time
+}
+
+// RunsOn reports whether the schedule is active on the given weekday.
+func (s Schedule) RunsOn(weekday time.Weekday) bool {
+	for _, w := range s.Weekdays {
+		if w == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseStartTime converts "HH:MM" into an offset from midnight.
+func ParseStartTime(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid start time %q, expected HH:MM: %w", value, err)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// FormatStartTime converts an offset from midnight into "HH:MM".
+func FormatStartTime(startTime time.Duration) string {
+	minutes := int(startTime.Minutes())
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
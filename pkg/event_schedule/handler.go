@@ -0,0 +1,212 @@
+package event_schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/internal/rest"
+	log "github.com/sirupsen/logrus"
+)
+
+type ScheduleDTO struct {
+	Id           int    `json:"id"`
+	BudgetItemId int    `json:"budgetItemId"`
+	Weekdays     []int  `json:"weekdays"`  // 0 = Sunday ... 6 = Saturday
+	StartTime    string `json:"startTime"` // HH:MM in the user's timezone
+	Enabled      bool   `json:"enabled"`
+	LastRunAt    string `json:"lastRunAt,omitempty"`
+}
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// ListSchedules godoc
+// @Summary List event schedules
+// @Description Get all schedules that automatically start current events for the current user
+// @Tags EventSchedule
+// @Produce json
+// @Success 200 {array} ScheduleDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/event/schedule [get]
+// @Security XUserId
+func (h *Handler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	schedules, err := h.service.GetSchedules(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	dtos := make([]ScheduleDTO, 0, len(schedules))
+	for _, schedule := range schedules {
+		dtos = append(dtos, scheduleToDTO(schedule))
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// CreateSchedule godoc
+// @Summary Create an event schedule
+// @Description Create a schedule that automatically starts the current event for a budget item, e.g. Work at 09:00 on weekdays
+// @Tags EventSchedule
+// @Accept json
+// @Produce json
+// @Param schedule body ScheduleDTO true "Event schedule"
+// @Success 201 {object} ScheduleDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Router /api/event/schedule [post]
+// @Security XUserId
+func (h *Handler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	schedule, ok := decodeSchedule(w, r)
+	if !ok {
+		return
+	}
+	created, err := h.service.CreateSchedule(r.Context(), schedule)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(scheduleToDTO(created)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// UpdateSchedule godoc
+// @Summary Update an event schedule
+// @Description Update an existing event schedule
+// @Tags EventSchedule
+// @Accept json
+// @Produce json
+// @Param scheduleId path int true "Schedule ID"
+// @Param schedule body ScheduleDTO true "Event schedule"
+// @Success 200 {object} ScheduleDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Schedule not found"
+// @Router /api/event/schedule/{scheduleId} [put]
+// @Security XUserId
+func (h *Handler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	scheduleId, err := strconv.Atoi(mux.Vars(r)["scheduleId"])
+	if err != nil {
+		writeBadRequest(w, "Invalid scheduleId format", "Parameter scheduleId must be a number")
+		return
+	}
+	schedule, ok := decodeSchedule(w, r)
+	if !ok {
+		return
+	}
+	schedule.Id = scheduleId
+	updated, err := h.service.UpdateSchedule(r.Context(), schedule)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(scheduleToDTO(updated)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteSchedule godoc
+// @Summary Delete an event schedule
+// @Description Remove an event schedule by ID
+// @Tags EventSchedule
+// @Param scheduleId path int true "Schedule ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} rest.ErrorResponse "Invalid scheduleId"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Schedule not found"
+// @Router /api/event/schedule/{scheduleId} [delete]
+// @Security XUserId
+func (h *Handler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	scheduleId, err := strconv.Atoi(mux.Vars(r)["scheduleId"])
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		writeBadRequest(w, "Invalid scheduleId format", "Parameter scheduleId must be a number")
+		return
+	}
+	if err := h.service.DeleteSchedule(r.Context(), scheduleId); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeSchedule(w http.ResponseWriter, r *http.Request) (Schedule, bool) {
+	var dto ScheduleDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		writeBadRequest(w, "Invalid request body format", "")
+		return Schedule{}, false
+	}
+	startTime, err := ParseStartTime(dto.StartTime)
+	if err != nil {
+		writeBadRequest(w, "Invalid startTime format", "Start time must be in HH:MM format")
+		return Schedule{}, false
+	}
+	weekdays := make([]time.Weekday, 0, len(dto.Weekdays))
+	for _, w := range dto.Weekdays {
+		weekdays = append(weekdays, time.Weekday(w))
+	}
+	return Schedule{
+		BudgetItemId: dto.BudgetItemId,
+		Weekdays:     weekdays,
+		StartTime:    startTime,
+		Enabled:      dto.Enabled,
+	}, true
+}
+
+func writeServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidSchedule):
+		writeBadRequest(w, "Invalid schedule", err.Error())
+	case errors.Is(err, ErrScheduleNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		log.Errorf("event schedule request failed: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeBadRequest(w http.ResponseWriter, errMsg, details string) {
+	w.WriteHeader(http.StatusBadRequest)
+	encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
+		Error:   errMsg,
+		Details: details,
+	})
+	if encodeErr != nil {
+		http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
+	}
+}
+
+func scheduleToDTO(schedule Schedule) ScheduleDTO {
+	weekdays := make([]int, 0, len(schedule.Weekdays))
+	for _, w := range schedule.Weekdays {
+		weekdays = append(weekdays, int(w))
+	}
+	dto := ScheduleDTO{
+		Id:           schedule.Id,
+		BudgetItemId: schedule.BudgetItemId,
+		Weekdays:     weekdays,
+		StartTime:    FormatStartTime(schedule.StartTime),
+		Enabled:      schedule.Enabled,
+	}
+	if schedule.LastRunAt != nil {
+		dto.LastRunAt = schedule.LastRunAt.Format(time.RFC3339)
+	}
+	return dto
+}
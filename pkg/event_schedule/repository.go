@@ -0,0 +1,158 @@
+package event_schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+type Repository interface {
+	GetSchedules(ctx context.Context, userId int) ([]Schedule, error)
+	// GetEnabledSchedules returns enabled schedules of all users. Used by the scheduler.
+	GetEnabledSchedules(ctx context.Context) ([]Schedule, error)
+	CreateSchedule(ctx context.Context, userId int, schedule Schedule) (Schedule, error)
+	UpdateSchedule(ctx context.Context, userId int, schedule Schedule) (Schedule, error)
+	DeleteSchedule(ctx context.Context, userId int, id int) error
+	// MarkRun stores the time the schedule has last started an event.
+	MarkRun(ctx context.Context, id int, runAt time.Time) error
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+const scheduleColumns = `id, user_id, budget_item_id, weekdays, start_minute, enabled, last_run_at`
+
+func (r *RepositoryImpl) GetSchedules(ctx context.Context, userId int) ([]Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM event_schedule WHERE user_id = $1 ORDER BY start_minute, id`
+	return r.querySchedules(ctx, query, userId)
+}
+
+func (r *RepositoryImpl) GetEnabledSchedules(ctx context.Context) ([]Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM event_schedule WHERE enabled = TRUE ORDER BY user_id, start_minute, id`
+	return r.querySchedules(ctx, query)
+}
+
+func (r *RepositoryImpl) querySchedules(ctx context.Context, query string, args ...any) ([]Schedule, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		err := fmt.Errorf("could not query schedules: %w", err)
+		log.Error(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := make([]Schedule, 0, 10)
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+func (r *RepositoryImpl) CreateSchedule(ctx context.Context, userId int, schedule Schedule) (Schedule, error) {
+	query := `INSERT INTO event_schedule (user_id, budget_item_id, weekdays, start_minute, enabled)
+			  VALUES ($1, $2, $3, $4, $5)
+			  RETURNING ` + scheduleColumns
+	created, err := scanSchedule(r.db.QueryRow(ctx, query,
+		userId,
+		schedule.BudgetItemId,
+		weekdaysToInts(schedule.Weekdays),
+		int(schedule.StartTime.Minutes()),
+		schedule.Enabled,
+	))
+	if err != nil {
+		return Schedule{}, err
+	}
+	return created, nil
+}
+
+func (r *RepositoryImpl) UpdateSchedule(ctx context.Context, userId int, schedule Schedule) (Schedule, error) {
+	query := `UPDATE event_schedule
+			  SET budget_item_id = $1, weekdays = $2, start_minute = $3, enabled = $4
+			  WHERE id = $5 AND user_id = $6
+			  RETURNING ` + scheduleColumns
+	updated, err := scanSchedule(r.db.QueryRow(ctx, query,
+		schedule.BudgetItemId,
+		weekdaysToInts(schedule.Weekdays),
+		int(schedule.StartTime.Minutes()),
+		schedule.Enabled,
+		schedule.Id,
+		userId,
+	))
+	if err != nil {
+		return Schedule{}, err
+	}
+	return updated, nil
+}
+
+func (r *RepositoryImpl) DeleteSchedule(ctx context.Context, userId int, id int) error {
+	result, err := r.db.Exec(ctx, `DELETE FROM event_schedule WHERE id = $1 AND user_id = $2`, id, userId)
+	if err != nil {
+		return fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) MarkRun(ctx context.Context, id int, runAt time.Time) error {
+	_, err := r.db.Exec(ctx, `UPDATE event_schedule SET last_run_at = $1 WHERE id = $2`, runAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark schedule run: %w", err)
+	}
+	return nil
+}
+
+func scanSchedule(row pgx.Row) (Schedule, error) {
+	var schedule Schedule
+	var weekdays []int
+	var startMinute int
+	err := row.Scan(
+		&schedule.Id,
+		&schedule.UserId,
+		&schedule.BudgetItemId,
+		&weekdays,
+		&startMinute,
+		&schedule.Enabled,
+		&schedule.LastRunAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Schedule{}, ErrScheduleNotFound
+		}
+		return Schedule{}, fmt.Errorf("could not scan schedule: %w", err)
+	}
+	schedule.Weekdays = make([]time.Weekday, 0, len(weekdays))
+	for _, w := range weekdays {
+		schedule.Weekdays = append(schedule.Weekdays, time.Weekday(w))
+	}
+	schedule.StartTime = time.Duration(startMinute) * time.Minute
+	return schedule, nil
+}
+
+func weekdaysToInts(weekdays []time.Weekday) []int {
+	result := make([]int, 0, len(weekdays))
+	for _, w := range weekdays {
+		result = append(result, int(w))
+	}
+	return result
+}
@@ -0,0 +1,78 @@
+package event_schedule
+
+import (
+	"context"
+	"time"
+)
+
+type RepositoryStub struct {
+	schedules map[int]Schedule
+	nextId    int
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{schedules: make(map[int]Schedule), nextId: 1}
+}
+
+func (r *RepositoryStub) GetSchedules(ctx context.Context, userId int) ([]Schedule, error) {
+	result := make([]Schedule, 0)
+	for id := 1; id < r.nextId; id++ {
+		if s, ok := r.schedules[id]; ok && s.UserId == userId {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
+func (r *RepositoryStub) GetEnabledSchedules(ctx context.Context) ([]Schedule, error) {
+	result := make([]Schedule, 0)
+	for id := 1; id < r.nextId; id++ {
+		if s, ok := r.schedules[id]; ok && s.Enabled {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
+func (r *RepositoryStub) CreateSchedule(ctx context.Context, userId int, schedule Schedule) (Schedule, error) {
+	schedule.Id = r.nextId
+	schedule.UserId = userId
+	r.schedules[schedule.Id] = schedule
+	r.nextId++
+	return schedule, nil
+}
+
+func (r *RepositoryStub) UpdateSchedule(ctx context.Context, userId int, schedule Schedule) (Schedule, error) {
+	existing, ok := r.schedules[schedule.Id]
+	if !ok || existing.UserId != userId {
+		return Schedule{}, ErrScheduleNotFound
+	}
+	schedule.UserId = userId
+	schedule.LastRunAt = existing.LastRunAt
+	r.schedules[schedule.Id] = schedule
+	return schedule, nil
+}
+
+func (r *RepositoryStub) DeleteSchedule(ctx context.Context, userId int, id int) error {
+	existing, ok := r.schedules[id]
+	if !ok || existing.UserId != userId {
+		return ErrScheduleNotFound
+	}
+	delete(r.schedules, id)
+	return nil
+}
+
+func (r *RepositoryStub) MarkRun(ctx context.Context, id int, runAt time.Time) error {
+	existing, ok := r.schedules[id]
+	if !ok {
+		return ErrScheduleNotFound
+	}
+	existing.LastRunAt = &runAt
+	r.schedules[id] = existing
+	return nil
+}
+
+func (r *RepositoryStub) Reset() {
+	r.schedules = make(map[int]Schedule)
+	r.nextId = 1
+}
@@ -0,0 +1,208 @@
+package event_schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/current_event"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// dueWindow is how long after the scheduled time an event may still be started. It covers scheduler
+// ticks that were delayed or missed, e.g. during a restart, without starting events hours too late.
+const dueWindow = 5 * time.Minute
+
+type Service interface {
+	GetSchedules(ctx context.Context) ([]Schedule, error)
+	CreateSchedule(ctx context.Context, schedule Schedule) (Schedule, error)
+	UpdateSchedule(ctx context.Context, schedule Schedule) (Schedule, error)
+	DeleteSchedule(ctx context.Context, id int) error
+	// RunDueSchedules starts current events for all schedules that are due at the given time.
+	RunDueSchedules(ctx context.Context, now time.Time) error
+}
+
+type EventStarter interface {
+	FindCurrentEvent(ctx context.Context) (current_event.CurrentEvent, error)
+	StartNewEvent(ctx context.Context, event current_event.CurrentEvent) (current_event.CurrentEvent, error)
+}
+
+type BudgetItemProvider interface {
+	GetItem(ctx context.Context, id int) (budget_plan.BudgetItem, error)
+}
+
+type UserProvider interface {
+	GetUser(ctx context.Context, id int) (user.User, error)
+}
+
+type ServiceImpl struct {
+	repo          Repository
+	eventStarter  EventStarter
+	budgetService BudgetItemProvider
+	userService   UserProvider
+}
+
+func NewService(repo Repository, eventStarter EventStarter, budgetService BudgetItemProvider, userService UserProvider) *ServiceImpl {
+	return &ServiceImpl{
+		repo:          repo,
+		eventStarter:  eventStarter,
+		budgetService: budgetService,
+		userService:   userService,
+	}
+}
+
+func (s *ServiceImpl) GetSchedules(ctx context.Context) ([]Schedule, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.GetSchedules(ctx, userId)
+}
+
+func (s *ServiceImpl) CreateSchedule(ctx context.Context, schedule Schedule) (Schedule, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	if err := s.validateSchedule(ctx, schedule); err != nil {
+		return Schedule{}, err
+	}
+	return s.repo.CreateSchedule(ctx, userId, schedule)
+}
+
+func (s *ServiceImpl) UpdateSchedule(ctx context.Context, schedule Schedule) (Schedule, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	if err := s.validateSchedule(ctx, schedule); err != nil {
+		return Schedule{}, err
+	}
+	return s.repo.UpdateSchedule(ctx, userId, schedule)
+}
+
+func (s *ServiceImpl) DeleteSchedule(ctx context.Context, id int) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.DeleteSchedule(ctx, userId, id)
+}
+
+func (s *ServiceImpl) validateSchedule(ctx context.Context, schedule Schedule) error {
+	if len(schedule.Weekdays) == 0 {
+		return fmt.Errorf("%w: at least one weekday is required", ErrInvalidSchedule)
+	}
+	for _, w := range schedule.Weekdays {
+		if w < time.Sunday || w > time.Saturday {
+			return fmt.Errorf("%w: weekday %d out of range", ErrInvalidSchedule, w)
+		}
+	}
+	if schedule.StartTime < 0 || schedule.StartTime >= 24*time.Hour {
+		return fmt.Errorf("%w: start time must be within a day", ErrInvalidSchedule)
+	}
+	if _, err := s.budgetService.GetItem(ctx, schedule.BudgetItemId); err != nil {
+		if errors.Is(err, budget_plan.ErrBudgetPlanItemNotFound) {
+			return fmt.Errorf("%w: budget item %d not found", ErrInvalidSchedule, schedule.BudgetItemId)
+		}
+		return fmt.Errorf("failed to get budget item: %w", err)
+	}
+	return nil
+}
+
+func (s *ServiceImpl) RunDueSchedules(ctx context.Context, now time.Time) error {
+	schedules, err := s.repo.GetEnabledSchedules(ctx)
+	if err != nil {
+		return err
+	}
+
+	users := make(map[int]user.User)
+	var errs []error
+	for _, schedule := range schedules {
+		u, ok := users[schedule.UserId]
+		if !ok {
+			u, err = s.userService.GetUser(ctx, schedule.UserId)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to get user %d: %w", schedule.UserId, err))
+				continue
+			}
+			users[schedule.UserId] = u
+		}
+		if u.Disabled {
+			continue
+		}
+		dueAt, isDue, err := dueTime(schedule, u.Settings.Timezone, now)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !isDue {
+			continue
+		}
+		if err := s.runSchedule(user.WithUser(ctx, u), schedule, dueAt, now); err != nil {
+			errs = append(errs, fmt.Errorf("schedule %d: %w", schedule.Id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *ServiceImpl) runSchedule(ctx context.Context, schedule Schedule, dueAt time.Time, now time.Time) error {
+	// Mark first, so a failing start does not get retried on every tick within the due window
+	if err := s.repo.MarkRun(ctx, schedule.Id, now); err != nil {
+		return err
+	}
+
+	currentEvent, err := s.eventStarter.FindCurrentEvent(ctx)
+	if err != nil {
+		return err
+	}
+	if currentEvent.Id != 0 && currentEvent.PlanItem.BudgetItemId == schedule.BudgetItemId {
+		log.Debugf("Schedule %d: budget item %d is already the current event", schedule.Id, schedule.BudgetItemId)
+		return nil
+	}
+
+	budgetItem, err := s.budgetService.GetItem(ctx, schedule.BudgetItemId)
+	if err != nil {
+		return fmt.Errorf("failed to get budget item: %w", err)
+	}
+	_, err = s.eventStarter.StartNewEvent(ctx, current_event.CurrentEvent{
+		StartTime: dueAt,
+		PlanItem: current_event.PlanItem{
+			BudgetItemId:   budgetItem.Id,
+			Name:           budgetItem.Name,
+			WeeklyDuration: budgetItem.WeeklyDuration,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start event: %w", err)
+	}
+	log.Infof("Event started by schedule %d for user %d, item %d (%s)", schedule.Id, schedule.UserId, budgetItem.Id, budgetItem.Name)
+	return nil
+}
+
+// dueTime returns today's scheduled time (in the user's timezone) and whether the schedule should run now.
+func dueTime(schedule Schedule, timezone string, now time.Time) (time.Time, bool, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("could not load location for timezone %s: %w", timezone, err)
+	}
+	localNow := now.In(location)
+	if !schedule.RunsOn(localNow.Weekday()) {
+		return time.Time{}, false, nil
+	}
+	// The start time is a wall clock time, adding it to midnight would shift it by an hour on DST change days
+	hour, minute := int(schedule.StartTime/time.Hour), int(schedule.StartTime%time.Hour/time.Minute)
+	dueAt := time.Date(localNow.Year(), localNow.Month(), localNow.Day(), hour, minute, 0, 0, location)
+	if now.Before(dueAt) || now.Sub(dueAt) >= dueWindow {
+		return dueAt, false, nil
+	}
+	if schedule.LastRunAt != nil && !schedule.LastRunAt.Before(dueAt) {
+		return dueAt, false, nil
+	}
+	return dueAt, true, nil
+}
@@ -0,0 +1,218 @@
+package event_schedule
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/current_event"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var location, _ = time.LoadLocation("Europe/Warsaw")
+
+var testUser = user.User{
+	Id:       7,
+	Uid:      "user-7",
+	Username: "test-user-7",
+	Settings: user.Settings{
+		Timezone:     location.String(),
+		WeekFirstDay: time.Monday,
+	},
+}
+
+type eventStarterStub struct {
+	current current_event.CurrentEvent
+	started []current_event.CurrentEvent
+}
+
+func (s *eventStarterStub) FindCurrentEvent(ctx context.Context) (current_event.CurrentEvent, error) {
+	return s.current, nil
+}
+
+func (s *eventStarterStub) StartNewEvent(ctx context.Context, event current_event.CurrentEvent) (current_event.CurrentEvent, error) {
+	event.Id = len(s.started) + 1
+	s.started = append(s.started, event)
+	s.current = event
+	return event, nil
+}
+
+type budgetProviderStub struct{}
+
+var errDatabase = errors.New("database is down")
+
+func (budgetProviderStub) GetItem(ctx context.Context, id int) (budget_plan.BudgetItem, error) {
+	if id == 500 {
+		return budget_plan.BudgetItem{}, errDatabase
+	}
+	if id != 100 {
+		return budget_plan.BudgetItem{}, budget_plan.ErrBudgetPlanItemNotFound
+	}
+	return budget_plan.BudgetItem{Id: 100, PlanId: 1, Name: "Work", WeeklyDuration: 40 * time.Hour}, nil
+}
+
+type userProviderStub struct{}
+
+func (userProviderStub) GetUser(ctx context.Context, id int) (user.User, error) {
+	if id != testUser.Id {
+		return user.User{}, user.ErrUserNotFound
+	}
+	return testUser, nil
+}
+
+func setupServiceTest() (*ServiceImpl, *RepositoryStub, *eventStarterStub, context.Context) {
+	repo := NewRepositoryStub()
+	starter := &eventStarterStub{}
+	service := NewService(repo, starter, budgetProviderStub{}, userProviderStub{})
+	return service, repo, starter, user.WithUser(context.Background(), testUser)
+}
+
+func weekdaysSchedule() Schedule {
+	return Schedule{
+		BudgetItemId: 100,
+		Weekdays:     []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		StartTime:    9 * time.Hour,
+		Enabled:      true,
+	}
+}
+
+func TestServiceImpl_CreateSchedule(t *testing.T) {
+	t.Run("should create valid schedule for current user", func(t *testing.T) {
+		service, _, _, ctx := setupServiceTest()
+
+		created, err := service.CreateSchedule(ctx, weekdaysSchedule())
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, created.Id)
+		assert.Equal(t, testUser.Id, created.UserId)
+	})
+
+	t.Run("should reject schedule without weekdays", func(t *testing.T) {
+		service, _, _, ctx := setupServiceTest()
+		schedule := weekdaysSchedule()
+		schedule.Weekdays = nil
+
+		_, err := service.CreateSchedule(ctx, schedule)
+
+		assert.ErrorIs(t, err, ErrInvalidSchedule)
+	})
+
+	t.Run("should reject schedule for unknown budget item", func(t *testing.T) {
+		service, _, _, ctx := setupServiceTest()
+		schedule := weekdaysSchedule()
+		schedule.BudgetItemId = 999
+
+		_, err := service.CreateSchedule(ctx, schedule)
+
+		assert.ErrorIs(t, err, ErrInvalidSchedule)
+	})
+
+	t.Run("should return error when budget item cannot be read", func(t *testing.T) {
+		service, _, _, ctx := setupServiceTest()
+		schedule := weekdaysSchedule()
+		schedule.BudgetItemId = 500
+
+		_, err := service.CreateSchedule(ctx, schedule)
+
+		assert.ErrorIs(t, err, errDatabase)
+		assert.NotErrorIs(t, err, ErrInvalidSchedule)
+	})
+}
+
+func TestServiceImpl_RunDueSchedules(t *testing.T) {
+	monday9am := time.Date(2026, time.March, 2, 9, 0, 0, 0, location)
+
+	t.Run("should start event when schedule is due", func(t *testing.T) {
+		service, _, starter, ctx := setupServiceTest()
+		_, err := service.CreateSchedule(ctx, weekdaysSchedule())
+		require.NoError(t, err)
+
+		err = service.RunDueSchedules(context.Background(), monday9am.Add(30*time.Second))
+
+		require.NoError(t, err)
+		require.Len(t, starter.started, 1)
+		assert.Equal(t, 100, starter.started[0].PlanItem.BudgetItemId)
+		assert.Equal(t, "Work", starter.started[0].PlanItem.Name)
+		assert.True(t, monday9am.Equal(starter.started[0].StartTime))
+	})
+
+	t.Run("should start event only once per day", func(t *testing.T) {
+		service, _, starter, ctx := setupServiceTest()
+		_, err := service.CreateSchedule(ctx, weekdaysSchedule())
+		require.NoError(t, err)
+		starter.current = current_event.CurrentEvent{Id: 1, PlanItem: current_event.PlanItem{BudgetItemId: 200}}
+
+		require.NoError(t, service.RunDueSchedules(context.Background(), monday9am))
+		starter.current = current_event.CurrentEvent{Id: 2, PlanItem: current_event.PlanItem{BudgetItemId: 200}}
+		require.NoError(t, service.RunDueSchedules(context.Background(), monday9am.Add(time.Minute)))
+
+		assert.Len(t, starter.started, 1)
+	})
+
+	t.Run("should not start event on a weekday outside of the schedule", func(t *testing.T) {
+		service, _, starter, ctx := setupServiceTest()
+		_, err := service.CreateSchedule(ctx, weekdaysSchedule())
+		require.NoError(t, err)
+
+		err = service.RunDueSchedules(context.Background(), monday9am.AddDate(0, 0, 5)) // Saturday
+
+		require.NoError(t, err)
+		assert.Empty(t, starter.started)
+	})
+
+	t.Run("should not start event after the due window passed", func(t *testing.T) {
+		service, _, starter, ctx := setupServiceTest()
+		_, err := service.CreateSchedule(ctx, weekdaysSchedule())
+		require.NoError(t, err)
+
+		err = service.RunDueSchedules(context.Background(), monday9am.Add(dueWindow))
+
+		require.NoError(t, err)
+		assert.Empty(t, starter.started)
+	})
+
+	t.Run("should not restart event that is already current", func(t *testing.T) {
+		service, repo, starter, ctx := setupServiceTest()
+		created, err := service.CreateSchedule(ctx, weekdaysSchedule())
+		require.NoError(t, err)
+		starter.current = current_event.CurrentEvent{Id: 1, PlanItem: current_event.PlanItem{BudgetItemId: 100}}
+
+		err = service.RunDueSchedules(context.Background(), monday9am)
+
+		require.NoError(t, err)
+		assert.Empty(t, starter.started)
+		assert.NotNil(t, repo.schedules[created.Id].LastRunAt)
+	})
+
+	t.Run("should skip disabled schedules", func(t *testing.T) {
+		service, _, starter, ctx := setupServiceTest()
+		schedule := weekdaysSchedule()
+		schedule.Enabled = false
+		_, err := service.CreateSchedule(ctx, schedule)
+		require.NoError(t, err)
+
+		err = service.RunDueSchedules(context.Background(), monday9am)
+
+		require.NoError(t, err)
+		assert.Empty(t, starter.started)
+	})
+
+	t.Run("should start event at the wall clock time on a DST change day", func(t *testing.T) {
+		service, _, starter, ctx := setupServiceTest()
+		schedule := weekdaysSchedule()
+		schedule.Weekdays = []time.Weekday{time.Sunday}
+		_, err := service.CreateSchedule(ctx, schedule)
+		require.NoError(t, err)
+		sunday9am := time.Date(2026, time.March, 29, 9, 0, 0, 0, location) // clocks moved forward at 02:00
+
+		require.NoError(t, service.RunDueSchedules(context.Background(), sunday9am.Add(-time.Hour)))
+		require.NoError(t, service.RunDueSchedules(context.Background(), sunday9am))
+
+		require.Len(t, starter.started, 1)
+		assert.True(t, sunday9am.Equal(starter.started[0].StartTime))
+	})
+}
@@ -0,0 +1,81 @@
+package export_stream
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// Stream is a per-user change data stream, read with the token instead of the user header.
+type Stream struct {
+	UserId    int
+	Token     string
+	CreatedAt time.Time
+	Filter    Filter
+}
+
+// Filter limits the changes recorded in a stream. Empty lists accept everything.
+type Filter struct {
+	BudgetItemIds []int
+	ChangeTypes   []ChangeType
+}
+
+// Accepts reports whether a change of the given type related to the given budget item passes the filter.
+func (f Filter) Accepts(changeType ChangeType, budgetItemId int) bool {
+	if len(f.ChangeTypes) > 0 && !slices.Contains(f.ChangeTypes, changeType) {
+		return false
+	}
+	return len(f.BudgetItemIds) == 0 || slices.Contains(f.BudgetItemIds, budgetItemId)
+}
+
+type ChangeType string
+
+const (
+	// ChangeCalendarEventFinalized - payload is CalendarEventPayload
+	ChangeCalendarEventFinalized ChangeType = "calendar_event.finalized"
+	// ChangeBudgetItemUpdated - payload is BudgetItemPayload
+	ChangeBudgetItemUpdated ChangeType = "budget_item.updated"
+	// ChangeCurrentEventStarted - payload is CurrentEventPayload
+	ChangeCurrentEventStarted ChangeType = "current_event.started"
+)
+
+var changeTypes = []ChangeType{ChangeCalendarEventFinalized, ChangeBudgetItemUpdated, ChangeCurrentEventStarted}
+
+// Change is a single entry of the stream. Id is increasing within the stream and is used as a cursor.
+type Change struct {
+	Id        int64
+	Type      ChangeType
+	Payload   json.RawMessage
+	CreatedAt time.Time
+}
+
+// CalendarEventPayload is the documented schema of ChangeCalendarEventFinalized payload.
+type CalendarEventPayload struct {
+	UID          string    `json:"uid"`
+	Summary      string    `json:"summary"`
+	StartTime    time.Time `json:"start"`
+	EndTime      time.Time `json:"end"`
+	BudgetItemId int       `json:"budgetItemId"`
+	// Duration in seconds
+	Duration int `json:"duration"`
+}
+
+// BudgetItemPayload is the documented schema of ChangeBudgetItemUpdated payload.
+type BudgetItemPayload struct {
+	Id     int    `json:"id"`
+	PlanId int    `json:"planId"`
+	Name   string `json:"name"`
+	// WeeklyDuration in seconds
+	WeeklyDuration    int    `json:"weeklyDuration"`
+	WeeklyOccurrences int    `json:"weeklyOccurrences"`
+	Icon              string `json:"icon"`
+	Color             string `json:"color"`
+	Position          int    `json:"position"`
+}
+
+// CurrentEventPayload is the documented schema of ChangeCurrentEventStarted payload.
+type CurrentEventPayload struct {
+	BudgetItemId int       `json:"budgetItemId"`
+	Name         string    `json:"name"`
+	StartTime    time.Time `json:"start"`
+}
@@ -0,0 +1,278 @@
+package export_stream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/internal/rest"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultWait = 30 * time.Second
+
+type StreamDTO struct {
+	Token     string    `json:"token"`
+	StreamUrl string    `json:"streamUrl"`
+	CreatedAt time.Time `json:"createdAt"`
+	Filter    FilterDTO `json:"filter"`
+}
+
+// FilterDTO limits the changes recorded in the stream. Empty lists accept everything.
+type FilterDTO struct {
+	BudgetItemIds []int        `json:"budgetItemIds"`
+	ChangeTypes   []ChangeType `json:"changeTypes" enums:"calendar_event.finalized,budget_item.updated,current_event.started"`
+}
+
+type ChangeDTO struct {
+	Id        int64           `json:"id"`
+	Type      ChangeType      `json:"type"`
+	Payload   json.RawMessage `json:"payload" swaggertype:"object"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+type ChangesDTO struct {
+	Changes []ChangeDTO `json:"changes"`
+	// Cursor to be passed as 'after' query parameter of the next read
+	Cursor int64 `json:"cursor"`
+}
+
+type Handler struct {
+	appHost string
+	service Service
+}
+
+func NewHandler(appHost string, service Service) *Handler {
+	return &Handler{
+		appHost: appHost,
+		service: service,
+	}
+}
+
+// EnableStream godoc
+// @Summary Enable export stream
+// @Description Enable the change data stream of the current user. When the stream is already enabled, its token is rotated.
+// @Description From now on finalized calendar events, started events and budget item changes are recorded in the stream.
+// @Tags ExportStream
+// @Produce json
+// @Success 201 {object} StreamDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/export/stream [post]
+// @Security XUserId
+func (h *Handler) EnableStream(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	stream, err := h.service.EnableStream(r.Context())
+	if err != nil {
+		log.Errorf("Failed to enable export stream: %v", err)
+		http.Error(w, "Failed to enable export stream", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(h.streamToDTO(stream)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetStream godoc
+// @Summary Get export stream
+// @Description Get the change data stream of the current user
+// @Tags ExportStream
+// @Produce json
+// @Success 200 {object} StreamDTO
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Export stream not enabled"
+// @Router /api/export/stream [get]
+// @Security XUserId
+func (h *Handler) GetStream(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	stream, err := h.service.GetStream(r.Context())
+	if err != nil {
+		if errors.Is(err, ErrStreamNotFound) {
+			http.Error(w, "Export stream not enabled", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(h.streamToDTO(stream)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// DisableStream godoc
+// @Summary Disable export stream
+// @Description Disable the change data stream of the current user and remove all its recorded changes
+// @Tags ExportStream
+// @Success 204 "No Content"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Export stream not enabled"
+// @Router /api/export/stream [delete]
+// @Security XUserId
+func (h *Handler) DisableStream(w http.ResponseWriter, r *http.Request) {
+	err := h.service.DisableStream(r.Context())
+	if err != nil {
+		if errors.Is(err, ErrStreamNotFound) {
+			http.Error(w, "Export stream not enabled", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetFilter godoc
+// @Summary Set export stream filter
+// @Description Record only changes of the given types and budget items in the stream of the current user.
+// @Description The filter is evaluated when a change happens, changes already in the stream are kept.
+// @Description Empty lists accept all change types or budget items.
+// @Tags ExportStream
+// @Accept json
+// @Produce json
+// @Param filter body FilterDTO true "Filter"
+// @Success 200 {object} StreamDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid filter"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Export stream not enabled"
+// @Router /api/export/stream/filter [put]
+// @Security XUserId
+func (h *Handler) SetFilter(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var body FilterDTO
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+
+	stream, err := h.service.SetFilter(r.Context(), Filter{
+		BudgetItemIds: body.BudgetItemIds,
+		ChangeTypes:   body.ChangeTypes,
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvalidFilter) {
+			writeBadRequest(w, "Invalid filter", err.Error())
+			return
+		}
+		if errors.Is(err, ErrStreamNotFound) {
+			http.Error(w, "Export stream not enabled", http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to set export stream filter: %v", err)
+		http.Error(w, "Failed to set export stream filter", http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(h.streamToDTO(stream)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// ReadChanges godoc
+// @Summary Read export stream changes
+// @Description Long-poll the change data stream using its token (no user authentication required).
+// @Description Returns changes recorded after the 'after' cursor, the oldest first. When there are none, the request
+// @Description waits up to 'wait' seconds (max 60) for new changes and returns an empty list on timeout.
+// @Description Change types and their payloads:
+// @Description - calendar_event.finalized: {uid, summary, start, end, budgetItemId, duration}
+// @Description - budget_item.updated: {id, planId, name, weeklyDuration, weeklyOccurrences, icon, color, position}
+// @Description - current_event.started: {budgetItemId, name, start}
+// @Description Durations are in seconds, times in RFC3339 format.
+// @Tags ExportStream
+// @Produce json
+// @Param token path string true "Export stream token"
+// @Param after query int false "Cursor returned by the previous read" default(0)
+// @Param wait query int false "Maximum number of seconds to wait for new changes" default(30)
+// @Success 200 {object} ChangesDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 404 {string} string "Invalid export stream token"
+// @Router /api/export/stream/{token}/changes [get]
+func (h *Handler) ReadChanges(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	token := mux.Vars(r)["token"]
+
+	var afterId int64
+	if afterString := r.URL.Query().Get("after"); afterString != "" {
+		var err error
+		afterId, err = strconv.ParseInt(afterString, 10, 64)
+		if err != nil || afterId < 0 {
+			writeBadRequest(w, "Invalid after cursor", "'after' must be a non-negative integer")
+			return
+		}
+	}
+	wait := defaultWait
+	if waitString := r.URL.Query().Get("wait"); waitString != "" {
+		waitSeconds, err := strconv.Atoi(waitString)
+		if err != nil || waitSeconds < 0 {
+			writeBadRequest(w, "Invalid wait", "'wait' must be a non-negative number of seconds")
+			return
+		}
+		wait = time.Duration(waitSeconds) * time.Second
+	}
+
+	// Long polling may take longer than the server write timeout
+	wait = min(wait, MaxWait)
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + 10*time.Second)); err != nil {
+		log.Debugf("Failed to extend write deadline of export stream read: %v", err)
+	}
+
+	changes, err := h.service.ReadChanges(r.Context(), token, afterId, wait)
+	if err != nil {
+		if errors.Is(err, ErrStreamNotFound) {
+			http.Error(w, "Invalid export stream token", http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to read export stream changes: %v", err)
+		http.Error(w, "Failed to read export stream changes", http.StatusInternalServerError)
+		return
+	}
+
+	response := ChangesDTO{
+		Changes: make([]ChangeDTO, 0, len(changes)),
+		Cursor:  afterId,
+	}
+	for _, change := range changes {
+		response.Changes = append(response.Changes, ChangeDTO{
+			Id:        change.Id,
+			Type:      change.Type,
+			Payload:   change.Payload,
+			CreatedAt: change.CreatedAt,
+		})
+		response.Cursor = change.Id
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) streamToDTO(stream Stream) StreamDTO {
+	return StreamDTO{
+		Token:     stream.Token,
+		StreamUrl: fmt.Sprintf("%s/api/export/stream/%s/changes", h.appHost, stream.Token),
+		CreatedAt: stream.CreatedAt,
+		Filter: FilterDTO{
+			BudgetItemIds: stream.Filter.BudgetItemIds,
+			ChangeTypes:   stream.Filter.ChangeTypes,
+		},
+	}
+}
+
+func writeBadRequest(w http.ResponseWriter, message string, details string) {
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+		Error:   message,
+		Details: details,
+	})
+}
@@ -0,0 +1,176 @@
+package export_stream
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrStreamNotFound = errors.New("export stream not found")
+
+type Repository interface {
+	// UpsertStream creates the stream for the user or rotates its token when it already exists.
+	UpsertStream(ctx context.Context, userId int) (Stream, error)
+	GetStream(ctx context.Context, userId int) (Stream, error)
+	GetStreamByToken(ctx context.Context, token string) (Stream, error)
+	UpdateFilter(ctx context.Context, userId int, filter Filter) (Stream, error)
+	// DeleteStream removes the stream together with all its changes.
+	DeleteStream(ctx context.Context, userId int) error
+	AppendChange(ctx context.Context, userId int, change Change) (Change, error)
+	// GetChanges returns at most limit changes with id greater than afterId, the oldest first.
+	GetChanges(ctx context.Context, userId int, afterId int64, limit int) ([]Change, error)
+}
+
+const streamColumns = "user_id, token, created_at, filter_budget_item_ids, filter_change_types"
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+func (r *RepositoryImpl) UpsertStream(ctx context.Context, userId int) (Stream, error) {
+	token, err := generateToken()
+	if err != nil {
+		return Stream{}, fmt.Errorf("failed to generate token: %w", err)
+	}
+	query := `INSERT INTO export_stream (user_id, token) VALUES ($1, $2)
+			  ON CONFLICT (user_id) DO UPDATE SET token = EXCLUDED.token
+			  RETURNING ` + streamColumns
+	stream, err := scanStream(r.db.QueryRow(ctx, query, userId, token))
+	if err != nil {
+		return Stream{}, fmt.Errorf("failed to store export stream: %w", err)
+	}
+	return stream, nil
+}
+
+func (r *RepositoryImpl) GetStream(ctx context.Context, userId int) (Stream, error) {
+	query := `SELECT ` + streamColumns + ` FROM export_stream WHERE user_id = $1`
+	return r.getStream(ctx, query, userId)
+}
+
+func (r *RepositoryImpl) GetStreamByToken(ctx context.Context, token string) (Stream, error) {
+	// Streams of disabled users are not readable, they work again once the user is enabled
+	query := `SELECT ` + streamColumns + ` FROM export_stream
+		WHERE token = $1 AND user_id IN (SELECT id FROM users WHERE NOT disabled)`
+	return r.getStream(ctx, query, token)
+}
+
+func (r *RepositoryImpl) getStream(ctx context.Context, query string, arg any) (Stream, error) {
+	stream, err := scanStream(r.db.QueryRow(ctx, query, arg))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Stream{}, ErrStreamNotFound
+		}
+		return Stream{}, fmt.Errorf("failed to get export stream: %w", err)
+	}
+	return stream, nil
+}
+
+func (r *RepositoryImpl) UpdateFilter(ctx context.Context, userId int, filter Filter) (Stream, error) {
+	changeTypes := make([]string, 0, len(filter.ChangeTypes))
+	for _, changeType := range filter.ChangeTypes {
+		changeTypes = append(changeTypes, string(changeType))
+	}
+	budgetItemIds := filter.BudgetItemIds
+	if budgetItemIds == nil {
+		budgetItemIds = []int{}
+	}
+	query := `UPDATE export_stream SET filter_budget_item_ids = $2, filter_change_types = $3
+			  WHERE user_id = $1
+			  RETURNING ` + streamColumns
+	stream, err := scanStream(r.db.QueryRow(ctx, query, userId, budgetItemIds, changeTypes))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Stream{}, ErrStreamNotFound
+		}
+		return Stream{}, fmt.Errorf("failed to update export stream filter: %w", err)
+	}
+	return stream, nil
+}
+
+func scanStream(row pgx.Row) (Stream, error) {
+	var stream Stream
+	var changeTypes []string
+	err := row.Scan(&stream.UserId, &stream.Token, &stream.CreatedAt, &stream.Filter.BudgetItemIds, &changeTypes)
+	if err != nil {
+		return Stream{}, err
+	}
+	for _, changeType := range changeTypes {
+		stream.Filter.ChangeTypes = append(stream.Filter.ChangeTypes, ChangeType(changeType))
+	}
+	if len(stream.Filter.BudgetItemIds) == 0 {
+		stream.Filter.BudgetItemIds = nil
+	}
+	return stream, nil
+}
+
+func (r *RepositoryImpl) DeleteStream(ctx context.Context, userId int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `DELETE FROM export_stream WHERE user_id = $1`, userId)
+	if err != nil {
+		return fmt.Errorf("failed to delete export stream: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrStreamNotFound
+	}
+	_, err = tx.Exec(ctx, `DELETE FROM export_stream_change WHERE user_id = $1`, userId)
+	if err != nil {
+		return fmt.Errorf("failed to delete export stream changes: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *RepositoryImpl) AppendChange(ctx context.Context, userId int, change Change) (Change, error) {
+	query := `INSERT INTO export_stream_change (user_id, type, payload) VALUES ($1, $2, $3)
+			  RETURNING id, type, payload, created_at`
+	var stored Change
+	err := r.db.QueryRow(ctx, query, userId, change.Type, change.Payload).
+		Scan(&stored.Id, &stored.Type, &stored.Payload, &stored.CreatedAt)
+	if err != nil {
+		return Change{}, fmt.Errorf("failed to append export stream change: %w", err)
+	}
+	return stored, nil
+}
+
+func (r *RepositoryImpl) GetChanges(ctx context.Context, userId int, afterId int64, limit int) ([]Change, error) {
+	query := `SELECT id, type, payload, created_at FROM export_stream_change
+			  WHERE user_id = $1 AND id > $2
+			  ORDER BY id
+			  LIMIT $3`
+	rows, err := r.db.Query(ctx, query, userId, afterId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get export stream changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := make([]Change, 0)
+	for rows.Next() {
+		var change Change
+		if err := rows.Scan(&change.Id, &change.Type, &change.Payload, &change.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan export stream change: %w", err)
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+func generateToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	_, err := rand.Read(tokenBytes)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", tokenBytes), nil
+}
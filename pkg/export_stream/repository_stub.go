@@ -0,0 +1,113 @@
+package export_stream
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type RepositoryStub struct {
+	mu           sync.RWMutex
+	streams      map[int]Stream   // userId -> stream
+	changes      map[int][]Change // userId -> changes
+	nextChangeId int64
+	nextToken    int
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{
+		streams:      make(map[int]Stream),
+		changes:      make(map[int][]Change),
+		nextChangeId: 1,
+	}
+}
+
+func (r *RepositoryStub) UpsertStream(ctx context.Context, userId int) (Stream, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextToken++
+	stream, exists := r.streams[userId]
+	if !exists {
+		stream = Stream{UserId: userId, CreatedAt: time.Now()}
+	}
+	stream.Token = fmt.Sprintf("token-%d", r.nextToken)
+	r.streams[userId] = stream
+	return stream, nil
+}
+
+func (r *RepositoryStub) GetStream(ctx context.Context, userId int) (Stream, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stream, exists := r.streams[userId]
+	if !exists {
+		return Stream{}, ErrStreamNotFound
+	}
+	return stream, nil
+}
+
+func (r *RepositoryStub) GetStreamByToken(ctx context.Context, token string) (Stream, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, stream := range r.streams {
+		if stream.Token == token {
+			return stream, nil
+		}
+	}
+	return Stream{}, ErrStreamNotFound
+}
+
+func (r *RepositoryStub) UpdateFilter(ctx context.Context, userId int, filter Filter) (Stream, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stream, exists := r.streams[userId]
+	if !exists {
+		return Stream{}, ErrStreamNotFound
+	}
+	stream.Filter = filter
+	r.streams[userId] = stream
+	return stream, nil
+}
+
+func (r *RepositoryStub) DeleteStream(ctx context.Context, userId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.streams[userId]; !exists {
+		return ErrStreamNotFound
+	}
+	delete(r.streams, userId)
+	delete(r.changes, userId)
+	return nil
+}
+
+func (r *RepositoryStub) AppendChange(ctx context.Context, userId int, change Change) (Change, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	change.Id = r.nextChangeId
+	change.CreatedAt = time.Now()
+	r.nextChangeId++
+	r.changes[userId] = append(r.changes[userId], change)
+	return change, nil
+}
+
+func (r *RepositoryStub) GetChanges(ctx context.Context, userId int, afterId int64, limit int) ([]Change, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]Change, 0)
+	for _, change := range r.changes[userId] {
+		if change.Id > afterId {
+			result = append(result, change)
+		}
+		if len(result) == limit {
+			break
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,228 @@
+package export_stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrInvalidFilter = errors.New("invalid export stream filter")
+
+const (
+	maxChangesPerRead = 500
+	MaxWait           = 60 * time.Second
+)
+
+type Service interface {
+	// EnableStream creates the export stream of the current user or rotates its token.
+	EnableStream(ctx context.Context) (Stream, error)
+	GetStream(ctx context.Context) (Stream, error)
+	DisableStream(ctx context.Context) error
+	// SetFilter limits the changes recorded in the stream of the current user from now on.
+	SetFilter(ctx context.Context, filter Filter) (Stream, error)
+	// ReadChanges returns changes of the stream identified by the token that come after the given cursor.
+	// When there are no such changes, it waits up to the given duration for new ones (long polling).
+	ReadChanges(ctx context.Context, token string, afterId int64, wait time.Duration) ([]Change, error)
+}
+
+type ServiceImpl struct {
+	repo     Repository
+	notifier *notifier
+}
+
+func NewService(repo Repository, eventBus *event_bus.EventBus) *ServiceImpl {
+	service := &ServiceImpl{
+		repo:     repo,
+		notifier: newNotifier(),
+	}
+	event_bus.SubscribeTyped[event_bus.CalendarEventCreated](
+		eventBus,
+		"calendar.event.created",
+		func(e event_bus.EventT[event_bus.CalendarEventCreated]) error {
+			if e.Data.Sandbox {
+				return nil
+			}
+			service.recordLogged(e.Context(), ChangeCalendarEventFinalized, e.Data.BudgetItemId, CalendarEventPayload{
+				UID:          e.Data.UID,
+				Summary:      e.Data.Summary,
+				StartTime:    e.Data.StartTime,
+				EndTime:      e.Data.EndTime,
+				BudgetItemId: e.Data.BudgetItemId,
+				Duration:     int(e.Data.EndTime.Sub(e.Data.StartTime).Seconds()),
+			})
+			return nil
+		},
+	)
+	event_bus.SubscribeTyped[event_bus.BudgetPlanItemUpdated](
+		eventBus,
+		"budget_plan.item.updated",
+		func(e event_bus.EventT[event_bus.BudgetPlanItemUpdated]) error {
+			service.recordLogged(e.Context(), ChangeBudgetItemUpdated, e.Data.Id, BudgetItemPayload{
+				Id:                e.Data.Id,
+				PlanId:            e.Data.PlanId,
+				Name:              e.Data.Name,
+				WeeklyDuration:    int(e.Data.WeeklyDuration.Seconds()),
+				WeeklyOccurrences: e.Data.WeeklyOccurrences,
+				Icon:              e.Data.Icon,
+				Color:             e.Data.Color,
+				Position:          e.Data.Position,
+			})
+			return nil
+		},
+	)
+	event_bus.SubscribeTyped[event_bus.CurrentEventStarted](
+		eventBus,
+		"current_event.started",
+		func(e event_bus.EventT[event_bus.CurrentEventStarted]) error {
+			service.recordLogged(e.Context(), ChangeCurrentEventStarted, e.Data.BudgetItemId, CurrentEventPayload{
+				BudgetItemId: e.Data.BudgetItemId,
+				Name:         e.Data.Name,
+				StartTime:    e.Data.StartTime,
+			})
+			return nil
+		},
+	)
+	return service
+}
+
+// recordLogged records the change and only logs a failure, so the export stream never breaks the original operation.
+func (s *ServiceImpl) recordLogged(ctx context.Context, changeType ChangeType, budgetItemId int, payload any) {
+	if err := s.record(ctx, changeType, budgetItemId, payload); err != nil {
+		log.Errorf("failed to record %s change in export stream: %v", changeType, err)
+	}
+}
+
+// record appends the change to the stream of the current user, if the user has the stream enabled
+// and the change of the given budget item passes the stream filter.
+func (s *ServiceImpl) record(ctx context.Context, changeType ChangeType, budgetItemId int, payload any) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	stream, err := s.repo.GetStream(ctx, userId)
+	if err != nil {
+		if errors.Is(err, ErrStreamNotFound) {
+			return nil
+		}
+		return err
+	}
+	if !stream.Filter.Accepts(changeType, budgetItemId) {
+		log.Tracef("%s change of budget item %d filtered out of export stream of user %d", changeType, budgetItemId, userId)
+		return nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s change: %w", changeType, err)
+	}
+	if _, err := s.repo.AppendChange(ctx, userId, Change{Type: changeType, Payload: data}); err != nil {
+		return err
+	}
+	log.Debugf("Recorded %s change in export stream of user %d", changeType, userId)
+	s.notifier.notify(userId)
+	return nil
+}
+
+func (s *ServiceImpl) EnableStream(ctx context.Context) (Stream, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Stream{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.UpsertStream(ctx, userId)
+}
+
+func (s *ServiceImpl) GetStream(ctx context.Context) (Stream, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Stream{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.GetStream(ctx, userId)
+}
+
+func (s *ServiceImpl) DisableStream(ctx context.Context) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.DeleteStream(ctx, userId)
+}
+
+func (s *ServiceImpl) SetFilter(ctx context.Context, filter Filter) (Stream, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Stream{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	for _, changeType := range filter.ChangeTypes {
+		if !slices.Contains(changeTypes, changeType) {
+			return Stream{}, fmt.Errorf("%w: unknown change type %q", ErrInvalidFilter, changeType)
+		}
+	}
+	return s.repo.UpdateFilter(ctx, userId, filter)
+}
+
+func (s *ServiceImpl) ReadChanges(ctx context.Context, token string, afterId int64, wait time.Duration) ([]Change, error) {
+	stream, err := s.repo.GetStreamByToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	wait = min(wait, MaxWait)
+
+	// Subscribe before reading, so a change recorded in between is not missed
+	changed := s.notifier.wait(stream.UserId)
+	changes, err := s.repo.GetChanges(ctx, stream.UserId, afterId, maxChangesPerRead)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) > 0 || wait <= 0 {
+		return changes, nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-changed:
+		return s.repo.GetChanges(ctx, stream.UserId, afterId, maxChangesPerRead)
+	case <-timer.C:
+		return changes, nil
+	case <-ctx.Done():
+		return changes, nil
+	}
+}
+
+// notifier wakes up readers waiting for new changes of a user.
+type notifier struct {
+	mu      sync.Mutex
+	waiters map[int]chan struct{}
+}
+
+func newNotifier() *notifier {
+	return &notifier{waiters: make(map[int]chan struct{})}
+}
+
+// wait returns a channel closed on the next change of the user.
+func (n *notifier) wait(userId int) <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	ch, ok := n.waiters[userId]
+	if !ok {
+		ch = make(chan struct{})
+		n.waiters[userId] = ch
+	}
+	return ch
+}
+
+func (n *notifier) notify(userId int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if ch, ok := n.waiters[userId]; ok {
+		close(ch)
+		delete(n.waiters, userId)
+	}
+}
@@ -0,0 +1,220 @@
+package export_stream
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupServiceTest(t *testing.T) (*ServiceImpl, *event_bus.EventBus, context.Context) {
+	t.Helper()
+	eventBus := event_bus.NewEventBus()
+	service := NewService(NewRepositoryStub(), eventBus)
+	ctx := user.WithUser(context.Background(), user.User{Id: 1, Uid: "user-1", Username: "test-user-1"})
+	return service, eventBus, ctx
+}
+
+func publishCalendarEvent(t *testing.T, eventBus *event_bus.EventBus, ctx context.Context, uid string) {
+	t.Helper()
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	err := eventBus.Publish(event_bus.NewEvent(ctx, "calendar.event.created", event_bus.CalendarEventCreated{
+		UID:          uid,
+		Summary:      "Writing",
+		StartTime:    start,
+		EndTime:      start.Add(90 * time.Minute),
+		BudgetItemId: 7,
+	}))
+	require.NoError(t, err)
+}
+
+func TestServiceImpl_ReadChanges(t *testing.T) {
+	t.Run("should record finalized calendar events for users with enabled stream", func(t *testing.T) {
+		service, eventBus, ctx := setupServiceTest(t)
+		stream, err := service.EnableStream(ctx)
+		require.NoError(t, err)
+
+		publishCalendarEvent(t, eventBus, ctx, "event-1")
+
+		changes, err := service.ReadChanges(context.Background(), stream.Token, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		assert.Equal(t, ChangeCalendarEventFinalized, changes[0].Type)
+		var payload CalendarEventPayload
+		require.NoError(t, json.Unmarshal(changes[0].Payload, &payload))
+		assert.Equal(t, "event-1", payload.UID)
+		assert.Equal(t, 7, payload.BudgetItemId)
+		assert.Equal(t, 5400, payload.Duration)
+	})
+
+	t.Run("should record budget item updates", func(t *testing.T) {
+		service, eventBus, ctx := setupServiceTest(t)
+		stream, err := service.EnableStream(ctx)
+		require.NoError(t, err)
+
+		err = eventBus.Publish(event_bus.NewEvent(ctx, "budget_plan.item.updated", event_bus.BudgetPlanItemUpdated{
+			Id:             3,
+			PlanId:         1,
+			Name:           "Reading",
+			WeeklyDuration: 2 * time.Hour,
+		}))
+		require.NoError(t, err)
+
+		changes, err := service.ReadChanges(context.Background(), stream.Token, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		assert.Equal(t, ChangeBudgetItemUpdated, changes[0].Type)
+		var payload BudgetItemPayload
+		require.NoError(t, json.Unmarshal(changes[0].Payload, &payload))
+		assert.Equal(t, "Reading", payload.Name)
+		assert.Equal(t, 7200, payload.WeeklyDuration)
+	})
+
+	t.Run("should return only changes after cursor", func(t *testing.T) {
+		service, eventBus, ctx := setupServiceTest(t)
+		stream, err := service.EnableStream(ctx)
+		require.NoError(t, err)
+		publishCalendarEvent(t, eventBus, ctx, "event-1")
+		publishCalendarEvent(t, eventBus, ctx, "event-2")
+
+		first, err := service.ReadChanges(context.Background(), stream.Token, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, first, 2)
+		next, err := service.ReadChanges(context.Background(), stream.Token, first[0].Id, 0)
+
+		require.NoError(t, err)
+		require.Len(t, next, 1)
+		assert.Equal(t, first[1].Id, next[0].Id)
+	})
+
+	t.Run("should not record changes when stream is not enabled", func(t *testing.T) {
+		service, eventBus, ctx := setupServiceTest(t)
+		publishCalendarEvent(t, eventBus, ctx, "event-1")
+
+		stream, err := service.EnableStream(ctx)
+		require.NoError(t, err)
+		changes, err := service.ReadChanges(context.Background(), stream.Token, 0, 0)
+
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("should wake up waiting reader on new change", func(t *testing.T) {
+		service, eventBus, ctx := setupServiceTest(t)
+		stream, err := service.EnableStream(ctx)
+		require.NoError(t, err)
+
+		result := make(chan []Change, 1)
+		go func() {
+			changes, err := service.ReadChanges(context.Background(), stream.Token, 0, 10*time.Second)
+			assert.NoError(t, err)
+			result <- changes
+		}()
+		time.Sleep(50 * time.Millisecond)
+		publishCalendarEvent(t, eventBus, ctx, "event-1")
+
+		select {
+		case changes := <-result:
+			assert.Len(t, changes, 1)
+		case <-time.After(5 * time.Second):
+			t.Fatal("waiting reader was not woken up")
+		}
+	})
+
+	t.Run("should return empty list when wait times out", func(t *testing.T) {
+		service, _, ctx := setupServiceTest(t)
+		stream, err := service.EnableStream(ctx)
+		require.NoError(t, err)
+
+		changes, err := service.ReadChanges(context.Background(), stream.Token, 0, 10*time.Millisecond)
+
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("should reject invalid token", func(t *testing.T) {
+		service, _, ctx := setupServiceTest(t)
+		_, err := service.EnableStream(ctx)
+		require.NoError(t, err)
+
+		_, err = service.ReadChanges(context.Background(), "invalid", 0, 0)
+
+		assert.ErrorIs(t, err, ErrStreamNotFound)
+	})
+
+	t.Run("should invalidate old token when stream is re-enabled", func(t *testing.T) {
+		service, _, ctx := setupServiceTest(t)
+		oldStream, err := service.EnableStream(ctx)
+		require.NoError(t, err)
+
+		_, err = service.EnableStream(ctx)
+		require.NoError(t, err)
+		_, err = service.ReadChanges(context.Background(), oldStream.Token, 0, 0)
+
+		assert.ErrorIs(t, err, ErrStreamNotFound)
+	})
+}
+
+func TestServiceImpl_SetFilter(t *testing.T) {
+	t.Run("should record only changes passing the filter", func(t *testing.T) {
+		service, eventBus, ctx := setupServiceTest(t)
+		stream, err := service.EnableStream(ctx)
+		require.NoError(t, err)
+		_, err = service.SetFilter(ctx, Filter{BudgetItemIds: []int{7}, ChangeTypes: []ChangeType{ChangeCurrentEventStarted}})
+		require.NoError(t, err)
+
+		publishCalendarEvent(t, eventBus, ctx, "event-1")
+		for _, budgetItemId := range []int{3, 7} {
+			err = eventBus.Publish(event_bus.NewEvent(ctx, "current_event.started", event_bus.CurrentEventStarted{
+				BudgetItemId: budgetItemId,
+				Name:         "Deep Work",
+				StartTime:    time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC),
+			}))
+			require.NoError(t, err)
+		}
+
+		changes, err := service.ReadChanges(context.Background(), stream.Token, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		assert.Equal(t, ChangeCurrentEventStarted, changes[0].Type)
+		var payload CurrentEventPayload
+		require.NoError(t, json.Unmarshal(changes[0].Payload, &payload))
+		assert.Equal(t, 7, payload.BudgetItemId)
+	})
+
+	t.Run("should keep the filter when the token is rotated", func(t *testing.T) {
+		service, _, ctx := setupServiceTest(t)
+		_, err := service.EnableStream(ctx)
+		require.NoError(t, err)
+		_, err = service.SetFilter(ctx, Filter{BudgetItemIds: []int{7}})
+		require.NoError(t, err)
+
+		stream, err := service.EnableStream(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, []int{7}, stream.Filter.BudgetItemIds)
+	})
+
+	t.Run("should reject unknown change types", func(t *testing.T) {
+		service, _, ctx := setupServiceTest(t)
+		_, err := service.EnableStream(ctx)
+		require.NoError(t, err)
+
+		_, err = service.SetFilter(ctx, Filter{ChangeTypes: []ChangeType{"calendar_event.deleted"}})
+
+		assert.ErrorIs(t, err, ErrInvalidFilter)
+	})
+
+	t.Run("should fail when stream is not enabled", func(t *testing.T) {
+		service, _, ctx := setupServiceTest(t)
+
+		_, err := service.SetFilter(ctx, Filter{BudgetItemIds: []int{7}})
+
+		assert.ErrorIs(t, err, ErrStreamNotFound)
+	})
+}
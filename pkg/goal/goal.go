@@ -0,0 +1,85 @@
+package goal
+
+import (
+	"time"
+
+	"github.com/klokku/klokku/pkg/weekly_plan"
+)
+
+type Comparison string
+
+const (
+	AtLeast Comparison = "at_least"
+	AtMost  Comparison = "at_most"
+)
+
+func (c Comparison) IsValid() bool {
+	return c == AtLeast || c == AtMost
+}
+
+// Goal is a weekly target of the time tracked for a budget item, e.g. at least 5h of Exercise per week.
+type Goal struct {
+	Id           int
+	BudgetItemId int
+	Comparison   Comparison
+	// Target is the weekly tracked time the goal compares against.
+	Target    time.Duration
+	CreatedAt time.Time
+}
+
+// isAchieved reports whether the tracked time of a whole week meets the goal.
+func (g Goal) isAchieved(tracked time.Duration) bool {
+	if g.Comparison == AtMost {
+		return tracked <= g.Target
+	}
+	return tracked >= g.Target
+}
+
+type WeekStatus string
+
+const (
+	WeekAchieved WeekStatus = "achieved"
+	WeekMissed   WeekStatus = "missed"
+	// WeekInProgress is the status of the current week until its outcome is known.
+	WeekInProgress WeekStatus = "in_progress"
+)
+
+type GoalWeek struct {
+	Week      weekly_plan.WeekNumber
+	StartDate time.Time
+	Tracked   time.Duration
+	Status    WeekStatus
+}
+
+type Badge string
+
+const (
+	BadgeFirstAchievement Badge = "first_achievement"
+	BadgeStreak4          Badge = "streak_4"
+	BadgeStreak12         Badge = "streak_12"
+	BadgeStreak26         Badge = "streak_26"
+	BadgeStreak52         Badge = "streak_52"
+)
+
+// streakBadges are awarded when the longest streak reaches the number of weeks.
+var streakBadges = []struct {
+	weeks int
+	badge Badge
+}{
+	{4, BadgeStreak4},
+	{12, BadgeStreak12},
+	{26, BadgeStreak26},
+	{52, BadgeStreak52},
+}
+
+type GoalProgress struct {
+	Goal           Goal
+	BudgetItemName string
+	// Weeks are the weeks since the goal was created, oldest first, up to MaxHistoryWeeks.
+	Weeks []GoalWeek
+	// CurrentStreak is the number of consecutive achieved weeks up to now. The current week extends the streak
+	// once achieved, but does not break it while in progress.
+	CurrentStreak int
+	LongestStreak int
+	Badges        []Badge
+}
@@ -0,0 +1,266 @@
+package goal
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/internal/rest"
+	log "github.com/sirupsen/logrus"
+)
+
+type GoalDTO struct {
+	Id           int    `json:"id"`
+	BudgetItemId int    `json:"budgetItemId"`
+	Comparison   string `json:"comparison" enums:"at_least,at_most"`
+	// TargetSec is the weekly tracked time in seconds
+	TargetSec int       `json:"targetSec"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type GoalWeekDTO struct {
+	Week       string    `json:"week"`
+	StartDate  time.Time `json:"startDate"`
+	TrackedSec int       `json:"trackedSec"`
+	Status     string    `json:"status" enums:"achieved,missed,in_progress"`
+}
+
+type GoalProgressDTO struct {
+	Goal           GoalDTO       `json:"goal"`
+	BudgetItemName string        `json:"budgetItemName"`
+	Weeks          []GoalWeekDTO `json:"weeks"`
+	CurrentStreak  int           `json:"currentStreak"`
+	LongestStreak  int           `json:"longestStreak"`
+	Badges         []string      `json:"badges"`
+}
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// ListGoals godoc
+// @Summary List goals
+// @Description List the weekly goals of the current user
+// @Tags Goal
+// @Produce json
+// @Success 200 {array} GoalDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/goals [get]
+// @Security XUserId
+func (h *Handler) ListGoals(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	goals, err := h.service.ListGoals(r.Context())
+	if err != nil {
+		log.Errorf("Failed to list goals: %v", err)
+		http.Error(w, "Failed to list goals", http.StatusInternalServerError)
+		return
+	}
+	dtos := make([]GoalDTO, 0, len(goals))
+	for _, goal := range goals {
+		dtos = append(dtos, goalToDTO(goal))
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		log.Errorf("Failed to encode goals: %v", err)
+		http.Error(w, "Failed to encode goals", http.StatusInternalServerError)
+	}
+}
+
+// CreateGoal godoc
+// @Summary Create a goal
+// @Description Create a weekly goal of the time tracked for a budget item, e.g. at least 5h of Exercise per week
+// @Tags Goal
+// @Accept json
+// @Produce json
+// @Param goal body GoalDTO true "Goal"
+// @Success 201 {object} GoalDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid goal"
+// @Failure 403 {string} string "User not found"
+// @Router /api/goals [post]
+// @Security XUserId
+func (h *Handler) CreateGoal(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var goalDTO GoalDTO
+	if err := json.NewDecoder(r.Body).Decode(&goalDTO); err != nil {
+		writeBadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	created, err := h.service.CreateGoal(r.Context(), dtoToGoal(goalDTO))
+	if err != nil {
+		if errors.Is(err, ErrInvalidGoal) {
+			writeBadRequest(w, "Invalid goal", err.Error())
+			return
+		}
+		log.Errorf("Failed to create goal: %v", err)
+		http.Error(w, "Failed to create goal", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(goalToDTO(created)); err != nil {
+		log.Errorf("Failed to encode goal: %v", err)
+	}
+}
+
+// UpdateGoal godoc
+// @Summary Update a goal
+// @Description Update the budget item, comparison or target of a goal. Streaks are re-evaluated with the new target.
+// @Tags Goal
+// @Accept json
+// @Produce json
+// @Param id path int true "Goal ID"
+// @Param goal body GoalDTO true "Goal"
+// @Success 200 {object} GoalDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid goal"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Goal not found"
+// @Router /api/goals/{id} [put]
+// @Security XUserId
+func (h *Handler) UpdateGoal(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid goal ID", http.StatusBadRequest)
+		return
+	}
+	var goalDTO GoalDTO
+	if err := json.NewDecoder(r.Body).Decode(&goalDTO); err != nil {
+		writeBadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	goal := dtoToGoal(goalDTO)
+	goal.Id = id
+
+	updated, err := h.service.UpdateGoal(r.Context(), goal)
+	if err != nil {
+		if errors.Is(err, ErrInvalidGoal) {
+			writeBadRequest(w, "Invalid goal", err.Error())
+			return
+		}
+		if errors.Is(err, ErrGoalNotFound) {
+			http.Error(w, "Goal not found", http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to update goal: %v", err)
+		http.Error(w, "Failed to update goal", http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(goalToDTO(updated)); err != nil {
+		log.Errorf("Failed to encode goal: %v", err)
+		http.Error(w, "Failed to encode goal", http.StatusInternalServerError)
+	}
+}
+
+// DeleteGoal godoc
+// @Summary Delete a goal
+// @Tags Goal
+// @Param id path int true "Goal ID"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Goal not found"
+// @Router /api/goals/{id} [delete]
+// @Security XUserId
+func (h *Handler) DeleteGoal(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid goal ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.DeleteGoal(r.Context(), id); err != nil {
+		if errors.Is(err, ErrGoalNotFound) {
+			http.Error(w, "Goal not found", http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to delete goal: %v", err)
+		http.Error(w, "Failed to delete goal", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetProgress godoc
+// @Summary Get goal streaks and badges
+// @Description Weekly achievements of each goal since it was created (up to 104 weeks), the current and longest
+// @Description streak of consecutive achieved weeks and the earned badges. The current week is in progress until
+// @Description an "at least" goal is reached or an "at most" goal is exceeded; it does not break a streak.
+// @Tags Stats
+// @Produce json
+// @Success 200 {array} GoalProgressDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/stats/goals [get]
+// @Security XUserId
+func (h *Handler) GetProgress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	progress, err := h.service.GetProgress(r.Context())
+	if err != nil {
+		log.Errorf("Failed to get goal progress: %v", err)
+		http.Error(w, "Failed to get goal progress", http.StatusInternalServerError)
+		return
+	}
+
+	dtos := make([]GoalProgressDTO, 0, len(progress))
+	for _, p := range progress {
+		dto := GoalProgressDTO{
+			Goal:           goalToDTO(p.Goal),
+			BudgetItemName: p.BudgetItemName,
+			Weeks:          make([]GoalWeekDTO, 0, len(p.Weeks)),
+			CurrentStreak:  p.CurrentStreak,
+			LongestStreak:  p.LongestStreak,
+			Badges:         make([]string, 0, len(p.Badges)),
+		}
+		for _, week := range p.Weeks {
+			dto.Weeks = append(dto.Weeks, GoalWeekDTO{
+				Week:       week.Week.String(),
+				StartDate:  week.StartDate,
+				TrackedSec: int(week.Tracked.Seconds()),
+				Status:     string(week.Status),
+			})
+		}
+		for _, badge := range p.Badges {
+			dto.Badges = append(dto.Badges, string(badge))
+		}
+		dtos = append(dtos, dto)
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		log.Errorf("Failed to encode goal progress: %v", err)
+		http.Error(w, "Failed to encode goal progress", http.StatusInternalServerError)
+	}
+}
+
+func goalToDTO(goal Goal) GoalDTO {
+	return GoalDTO{
+		Id:           goal.Id,
+		BudgetItemId: goal.BudgetItemId,
+		Comparison:   string(goal.Comparison),
+		TargetSec:    int(goal.Target.Seconds()),
+		CreatedAt:    goal.CreatedAt,
+	}
+}
+
+func dtoToGoal(dto GoalDTO) Goal {
+	return Goal{
+		Id:           dto.Id,
+		BudgetItemId: dto.BudgetItemId,
+		Comparison:   Comparison(dto.Comparison),
+		Target:       time.Duration(dto.TargetSec) * time.Second,
+	}
+}
+
+func writeBadRequest(w http.ResponseWriter, errMsg, details string) {
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+		Error:   errMsg,
+		Details: details,
+	})
+}
@@ -0,0 +1,109 @@
+package goal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrGoalNotFound = errors.New("goal not found")
+
+type Repository interface {
+	ListGoals(ctx context.Context, userId int) ([]Goal, error)
+	GetGoal(ctx context.Context, userId int, id int) (Goal, error)
+	CreateGoal(ctx context.Context, userId int, goal Goal) (Goal, error)
+	UpdateGoal(ctx context.Context, userId int, goal Goal) (Goal, error)
+	// DeleteGoal returns false when the goal does not exist.
+	DeleteGoal(ctx context.Context, userId int, id int) (bool, error)
+}
+
+const goalColumns = `id, budget_item_id, comparison, target_sec, created_at`
+
+func scanGoal(row pgx.Row) (Goal, error) {
+	var goal Goal
+	var targetSec int
+	err := row.Scan(&goal.Id, &goal.BudgetItemId, &goal.Comparison, &targetSec, &goal.CreatedAt)
+	goal.Target = time.Duration(targetSec) * time.Second
+	return goal, err
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+func (r *RepositoryImpl) ListGoals(ctx context.Context, userId int) ([]Goal, error) {
+	query := `SELECT ` + goalColumns + ` FROM goal WHERE user_id = $1 ORDER BY id`
+	rows, err := r.db.Query(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list goals: %w", err)
+	}
+	defer rows.Close()
+
+	goals := make([]Goal, 0)
+	for rows.Next() {
+		goal, err := scanGoal(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan goal: %w", err)
+		}
+		goals = append(goals, goal)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list goals: %w", err)
+	}
+	return goals, nil
+}
+
+func (r *RepositoryImpl) GetGoal(ctx context.Context, userId int, id int) (Goal, error) {
+	query := `SELECT ` + goalColumns + ` FROM goal WHERE id = $1 AND user_id = $2`
+	goal, err := scanGoal(r.db.QueryRow(ctx, query, id, userId))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Goal{}, ErrGoalNotFound
+		}
+		return Goal{}, fmt.Errorf("failed to get goal: %w", err)
+	}
+	return goal, nil
+}
+
+func (r *RepositoryImpl) CreateGoal(ctx context.Context, userId int, goal Goal) (Goal, error) {
+	query := `INSERT INTO goal (user_id, budget_item_id, comparison, target_sec)
+			  VALUES ($1, $2, $3, $4)
+			  RETURNING ` + goalColumns
+	created, err := scanGoal(r.db.QueryRow(ctx, query, userId, goal.BudgetItemId, goal.Comparison,
+		int(goal.Target.Seconds())))
+	if err != nil {
+		return Goal{}, fmt.Errorf("failed to create goal: %w", err)
+	}
+	return created, nil
+}
+
+func (r *RepositoryImpl) UpdateGoal(ctx context.Context, userId int, goal Goal) (Goal, error) {
+	query := `UPDATE goal SET budget_item_id = $1, comparison = $2, target_sec = $3
+			  WHERE id = $4 AND user_id = $5
+			  RETURNING ` + goalColumns
+	updated, err := scanGoal(r.db.QueryRow(ctx, query, goal.BudgetItemId, goal.Comparison,
+		int(goal.Target.Seconds()), goal.Id, userId))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Goal{}, ErrGoalNotFound
+		}
+		return Goal{}, fmt.Errorf("failed to update goal: %w", err)
+	}
+	return updated, nil
+}
+
+func (r *RepositoryImpl) DeleteGoal(ctx context.Context, userId int, id int) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM goal WHERE id = $1 AND user_id = $2`, id, userId)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete goal: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,79 @@
+package goal
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+type RepositoryStub struct {
+	mu     sync.RWMutex
+	goals  map[int]map[int]Goal // userId -> goalId -> goal
+	nextId int
+	// Now is the creation time of new goals, the current time when zero.
+	Now time.Time
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{goals: make(map[int]map[int]Goal), nextId: 1}
+}
+
+func (r *RepositoryStub) ListGoals(ctx context.Context, userId int) ([]Goal, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	goals := make([]Goal, 0, len(r.goals[userId]))
+	for _, goal := range r.goals[userId] {
+		goals = append(goals, goal)
+	}
+	slices.SortFunc(goals, func(a, b Goal) int { return a.Id - b.Id })
+	return goals, nil
+}
+
+func (r *RepositoryStub) GetGoal(ctx context.Context, userId int, id int) (Goal, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	goal, ok := r.goals[userId][id]
+	if !ok {
+		return Goal{}, ErrGoalNotFound
+	}
+	return goal, nil
+}
+
+func (r *RepositoryStub) CreateGoal(ctx context.Context, userId int, goal Goal) (Goal, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	goal.Id = r.nextId
+	r.nextId++
+	goal.CreatedAt = r.Now
+	if goal.CreatedAt.IsZero() {
+		goal.CreatedAt = time.Now()
+	}
+	if r.goals[userId] == nil {
+		r.goals[userId] = make(map[int]Goal)
+	}
+	r.goals[userId][goal.Id] = goal
+	return goal, nil
+}
+
+func (r *RepositoryStub) UpdateGoal(ctx context.Context, userId int, goal Goal) (Goal, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing, ok := r.goals[userId][goal.Id]
+	if !ok {
+		return Goal{}, ErrGoalNotFound
+	}
+	goal.CreatedAt = existing.CreatedAt
+	r.goals[userId][goal.Id] = goal
+	return goal, nil
+}
+
+func (r *RepositoryStub) DeleteGoal(ctx context.Context, userId int, id int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.goals[userId][id]; !ok {
+		return false, nil
+	}
+	delete(r.goals[userId], id)
+	return true, nil
+}
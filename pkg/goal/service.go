@@ -0,0 +1,201 @@
+package goal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/stats"
+	"github.com/klokku/klokku/pkg/user"
+)
+
+var ErrInvalidGoal = errors.New("invalid goal")
+
+// MaxHistoryWeeks limits how many weeks back the progress of a goal is evaluated.
+const MaxHistoryWeeks = stats.MaxTrendWeeks
+
+const maxWeeklyTarget = 7 * 24 * time.Hour
+
+type Service interface {
+	ListGoals(ctx context.Context) ([]Goal, error)
+	CreateGoal(ctx context.Context, goal Goal) (Goal, error)
+	UpdateGoal(ctx context.Context, goal Goal) (Goal, error)
+	DeleteGoal(ctx context.Context, id int) error
+	// GetProgress returns the weekly achievements, streaks and badges of all goals of the current user.
+	GetProgress(ctx context.Context) ([]GoalProgress, error)
+}
+
+type budgetItemReader interface {
+	GetItem(ctx context.Context, id int) (budget_plan.BudgetItem, error)
+}
+
+type trendProvider interface {
+	GetTrend(ctx context.Context, budgetItemId int, weeks int) (stats.Trend, error)
+}
+
+type ServiceImpl struct {
+	repo        Repository
+	budgetItems budgetItemReader
+	trends      trendProvider
+	clock       utils.Clock
+}
+
+func NewService(repo Repository, budgetItems budgetItemReader, trends trendProvider, clock utils.Clock) *ServiceImpl {
+	return &ServiceImpl{
+		repo:        repo,
+		budgetItems: budgetItems,
+		trends:      trends,
+		clock:       clock,
+	}
+}
+
+func (s *ServiceImpl) ListGoals(ctx context.Context) ([]Goal, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.ListGoals(ctx, userId)
+}
+
+func (s *ServiceImpl) CreateGoal(ctx context.Context, goal Goal) (Goal, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Goal{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	if err := s.validate(ctx, goal); err != nil {
+		return Goal{}, err
+	}
+	return s.repo.CreateGoal(ctx, userId, goal)
+}
+
+func (s *ServiceImpl) UpdateGoal(ctx context.Context, goal Goal) (Goal, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Goal{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	if err := s.validate(ctx, goal); err != nil {
+		return Goal{}, err
+	}
+	return s.repo.UpdateGoal(ctx, userId, goal)
+}
+
+func (s *ServiceImpl) DeleteGoal(ctx context.Context, id int) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	deleted, err := s.repo.DeleteGoal(ctx, userId, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrGoalNotFound
+	}
+	return nil
+}
+
+func (s *ServiceImpl) validate(ctx context.Context, goal Goal) error {
+	if !goal.Comparison.IsValid() {
+		return fmt.Errorf("%w: comparison must be %s or %s", ErrInvalidGoal, AtLeast, AtMost)
+	}
+	if goal.Target <= 0 || goal.Target > maxWeeklyTarget {
+		return fmt.Errorf("%w: target must be between 1 second and 7 days", ErrInvalidGoal)
+	}
+	if _, err := s.budgetItems.GetItem(ctx, goal.BudgetItemId); err != nil {
+		if errors.Is(err, budget_plan.ErrBudgetPlanItemNotFound) {
+			return fmt.Errorf("%w: budget item %d not found", ErrInvalidGoal, goal.BudgetItemId)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *ServiceImpl) GetProgress(ctx context.Context) ([]GoalProgress, error) {
+	goals, err := s.ListGoals(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.Now()
+
+	progress := make([]GoalProgress, 0, len(goals))
+	for _, goal := range goals {
+		item, err := s.budgetItems.GetItem(ctx, goal.BudgetItemId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get budget item of goal %d: %w", goal.Id, err)
+		}
+		// One more week than elapsed since the creation, the creation week may start before the week of now - 7d*n
+		weeks := min(int(now.Sub(goal.CreatedAt)/(7*24*time.Hour))+2, MaxHistoryWeeks)
+		trend, err := s.trends.GetTrend(ctx, goal.BudgetItemId, weeks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get trend of goal %d: %w", goal.Id, err)
+		}
+		goalProgress := evaluate(goal, trend.Weeks, now)
+		goalProgress.BudgetItemName = item.Name
+		progress = append(progress, goalProgress)
+	}
+	return progress, nil
+}
+
+// evaluate computes the achievements of the goal in the weeks, ordered oldest first. Weeks that ended before the goal
+// was created are skipped.
+func evaluate(goal Goal, weeks []stats.TrendWeek, now time.Time) GoalProgress {
+	progress := GoalProgress{
+		Goal:   goal,
+		Weeks:  make([]GoalWeek, 0, len(weeks)),
+		Badges: make([]Badge, 0),
+	}
+	streak := 0
+	achievedAny := false
+	for _, week := range weeks {
+		if !week.EndDate.After(goal.CreatedAt) {
+			continue
+		}
+		status := weekStatus(goal, week, now)
+		switch status {
+		case WeekAchieved:
+			achievedAny = true
+			streak++
+			progress.LongestStreak = max(progress.LongestStreak, streak)
+		case WeekMissed:
+			streak = 0
+		}
+		progress.Weeks = append(progress.Weeks, GoalWeek{
+			Week:      week.Week,
+			StartDate: week.StartDate,
+			Tracked:   week.Tracked,
+			Status:    status,
+		})
+	}
+	progress.CurrentStreak = streak
+
+	if achievedAny {
+		progress.Badges = append(progress.Badges, BadgeFirstAchievement)
+	}
+	for _, streakBadge := range streakBadges {
+		if progress.LongestStreak >= streakBadge.weeks {
+			progress.Badges = append(progress.Badges, streakBadge.badge)
+		}
+	}
+	return progress
+}
+
+func weekStatus(goal Goal, week stats.TrendWeek, now time.Time) WeekStatus {
+	if !week.EndDate.After(now) {
+		if goal.isAchieved(week.Tracked) {
+			return WeekAchieved
+		}
+		return WeekMissed
+	}
+	// The outcome of the current week is known early when an "at least" goal is reached,
+	// or an "at most" goal is exceeded
+	if goal.Comparison == AtLeast && goal.isAchieved(week.Tracked) {
+		return WeekAchieved
+	}
+	if goal.Comparison == AtMost && !goal.isAchieved(week.Tracked) {
+		return WeekMissed
+	}
+	return WeekInProgress
+}
@@ -0,0 +1,188 @@
+package goal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/stats"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Wednesday, the current week starts on Monday 2025-06-09
+var now = time.Date(2025, 6, 11, 12, 0, 0, 0, time.UTC)
+var currentWeekStart = time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC)
+
+type budgetItemReaderStub struct {
+	items map[int]budget_plan.BudgetItem
+}
+
+func (s *budgetItemReaderStub) GetItem(ctx context.Context, id int) (budget_plan.BudgetItem, error) {
+	item, ok := s.items[id]
+	if !ok {
+		return budget_plan.BudgetItem{}, budget_plan.ErrBudgetPlanItemNotFound
+	}
+	return item, nil
+}
+
+type trendProviderStub struct {
+	// tracked holds the tracked time of the weeks, the last one is the current week
+	tracked []time.Duration
+}
+
+func (s *trendProviderStub) GetTrend(ctx context.Context, budgetItemId int, weeks int) (stats.Trend, error) {
+	trend := stats.Trend{BudgetItemId: budgetItemId}
+	for i := weeks - 1; i >= 0; i-- {
+		startDate := currentWeekStart.AddDate(0, 0, -7*i)
+		week := stats.TrendWeek{
+			Week:      weekly_plan.WeekNumberFromDate(startDate, time.Monday),
+			StartDate: startDate,
+			EndDate:   startDate.AddDate(0, 0, 7),
+		}
+		if i < len(s.tracked) {
+			week.Tracked = s.tracked[len(s.tracked)-1-i]
+		}
+		trend.Weeks = append(trend.Weeks, week)
+	}
+	return trend, nil
+}
+
+type testEnv struct {
+	service *ServiceImpl
+	repo    *RepositoryStub
+	trends  *trendProviderStub
+	ctx     context.Context
+}
+
+func setupServiceTest(t *testing.T) testEnv {
+	t.Helper()
+	repo := NewRepositoryStub()
+	repo.Now = now
+	trends := &trendProviderStub{}
+	items := &budgetItemReaderStub{items: map[int]budget_plan.BudgetItem{
+		10: {Id: 10, Name: "Exercise"},
+	}}
+	return testEnv{
+		service: NewService(repo, items, trends, &utils.MockClock{FixedNow: now}),
+		repo:    repo,
+		trends:  trends,
+		ctx:     user.WithUser(context.Background(), user.User{Id: 1, Uid: "user-1", Username: "test-user-1"}),
+	}
+}
+
+func TestService_CreateGoal(t *testing.T) {
+	env := setupServiceTest(t)
+
+	t.Run("creates a goal", func(t *testing.T) {
+		created, err := env.service.CreateGoal(env.ctx, Goal{BudgetItemId: 10, Comparison: AtLeast, Target: 5 * time.Hour})
+		require.NoError(t, err)
+		assert.NotZero(t, created.Id)
+
+		goals, err := env.service.ListGoals(env.ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []Goal{created}, goals)
+	})
+
+	t.Run("rejects invalid goals", func(t *testing.T) {
+		invalid := []Goal{
+			{BudgetItemId: 10, Comparison: "exactly", Target: time.Hour},
+			{BudgetItemId: 10, Comparison: AtLeast, Target: 0},
+			{BudgetItemId: 10, Comparison: AtMost, Target: 8 * 24 * time.Hour},
+			{BudgetItemId: 99, Comparison: AtLeast, Target: time.Hour},
+		}
+		for _, goal := range invalid {
+			_, err := env.service.CreateGoal(env.ctx, goal)
+			assert.ErrorIs(t, err, ErrInvalidGoal)
+		}
+	})
+
+	t.Run("fails to delete an unknown goal", func(t *testing.T) {
+		assert.ErrorIs(t, env.service.DeleteGoal(env.ctx, 999), ErrGoalNotFound)
+	})
+}
+
+func TestService_GetProgress(t *testing.T) {
+	t.Run("counts streaks of an at least goal", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.repo.Now = currentWeekStart.AddDate(0, 0, -7*6)
+		_, err := env.service.CreateGoal(env.ctx, Goal{BudgetItemId: 10, Comparison: AtLeast, Target: 5 * time.Hour})
+		require.NoError(t, err)
+		// 6 finished weeks since the creation and the current one
+		env.trends.tracked = []time.Duration{
+			6 * time.Hour, 5 * time.Hour, 2 * time.Hour, 5 * time.Hour, 7 * time.Hour, 5 * time.Hour, time.Hour,
+		}
+
+		progress, err := env.service.GetProgress(env.ctx)
+		require.NoError(t, err)
+
+		require.Len(t, progress, 1)
+		assert.Equal(t, "Exercise", progress[0].BudgetItemName)
+		require.Len(t, progress[0].Weeks, 7)
+		var statuses []WeekStatus
+		for _, week := range progress[0].Weeks {
+			statuses = append(statuses, week.Status)
+		}
+		assert.Equal(t, []WeekStatus{
+			WeekAchieved, WeekAchieved, WeekMissed, WeekAchieved, WeekAchieved, WeekAchieved, WeekInProgress,
+		}, statuses)
+		assert.Equal(t, currentWeekStart, progress[0].Weeks[6].StartDate)
+		assert.Equal(t, 3, progress[0].CurrentStreak)
+		assert.Equal(t, 3, progress[0].LongestStreak)
+		assert.Equal(t, []Badge{BadgeFirstAchievement}, progress[0].Badges)
+	})
+
+	t.Run("current week extends the streak once achieved", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.repo.Now = currentWeekStart.AddDate(0, 0, -7*3)
+		_, err := env.service.CreateGoal(env.ctx, Goal{BudgetItemId: 10, Comparison: AtLeast, Target: 5 * time.Hour})
+		require.NoError(t, err)
+		env.trends.tracked = []time.Duration{5 * time.Hour, 5 * time.Hour, 5 * time.Hour, 5 * time.Hour}
+
+		progress, err := env.service.GetProgress(env.ctx)
+		require.NoError(t, err)
+
+		require.Len(t, progress, 1)
+		assert.Equal(t, 4, progress[0].CurrentStreak)
+		assert.Equal(t, []Badge{BadgeFirstAchievement, BadgeStreak4}, progress[0].Badges)
+	})
+
+	t.Run("at most goal is missed as soon as exceeded", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.repo.Now = currentWeekStart.AddDate(0, 0, -7)
+		_, err := env.service.CreateGoal(env.ctx, Goal{BudgetItemId: 10, Comparison: AtMost, Target: 3 * time.Hour})
+		require.NoError(t, err)
+		env.trends.tracked = []time.Duration{2 * time.Hour, 4 * time.Hour}
+
+		progress, err := env.service.GetProgress(env.ctx)
+		require.NoError(t, err)
+
+		require.Len(t, progress, 1)
+		require.Len(t, progress[0].Weeks, 2)
+		assert.Equal(t, WeekAchieved, progress[0].Weeks[0].Status)
+		assert.Equal(t, WeekMissed, progress[0].Weeks[1].Status)
+		assert.Equal(t, 0, progress[0].CurrentStreak)
+		assert.Equal(t, 1, progress[0].LongestStreak)
+	})
+
+	t.Run("skips weeks before the goal was created", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.repo.Now = now
+		_, err := env.service.CreateGoal(env.ctx, Goal{BudgetItemId: 10, Comparison: AtLeast, Target: time.Hour})
+		require.NoError(t, err)
+		env.trends.tracked = []time.Duration{10 * time.Hour, 0}
+
+		progress, err := env.service.GetProgress(env.ctx)
+		require.NoError(t, err)
+
+		require.Len(t, progress, 1)
+		require.Len(t, progress[0].Weeks, 1)
+		assert.Equal(t, WeekInProgress, progress[0].Weeks[0].Status)
+		assert.Equal(t, 0, progress[0].CurrentStreak)
+		assert.Empty(t, progress[0].Badges)
+	})
+}
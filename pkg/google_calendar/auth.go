@@ -0,0 +1,254 @@
+package google_calendar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/pkg/credentials"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+// scopes lets Klokku read and write the user's calendars.
+var scopes = []string{"https://www.googleapis.com/auth/calendar"}
+
+const revokeURL = "https://oauth2.googleapis.com/revoke"
+
+type googleAuthRedirect struct {
+	RedirectUrl string `json:"redirectUrl"`
+}
+
+type GoogleAuth struct {
+	userService user.Service
+	oauthConfig *oauth2.Config
+	// httpClient sends the requests to Google, the OAuth client authorizes them on top of it
+	httpClient  *http.Client
+	credentials credentials.Service
+	// onDisconnect runs before the token is forgotten, while it can still be used
+	onDisconnect []func(ctx context.Context, userId int) error
+}
+
+func NewGoogleAuth(userService user.Service, cfg config.Application, httpClient *http.Client,
+	credentialsService credentials.Service) *GoogleAuth {
+	oauthConfig := &oauth2.Config{
+		ClientID:     cfg.Google.ClientId,
+		ClientSecret: cfg.Google.ClientSecret,
+		Endpoint:     endpoints.Google,
+		RedirectURL:  cfg.Host + "/api/integrations/google/auth/callback",
+		Scopes:       scopes,
+	}
+
+	return &GoogleAuth{
+		userService: userService,
+		oauthConfig: oauthConfig,
+		httpClient:  httpClient,
+		credentials: credentialsService,
+	}
+}
+
+// OnDisconnect registers a function run when a user disconnects their Google account.
+func (a *GoogleAuth) OnDisconnect(fn func(ctx context.Context, userId int) error) {
+	a.onDisconnect = append(a.onDisconnect, fn)
+}
+
+// OAuthLogin godoc
+// @Summary Initiate Google OAuth login
+// @Description Start the OAuth flow to connect a Google calendar
+// @Tags Google
+// @Produce json
+// @Param finalUrl query string false "URL to redirect to after authentication"
+// @Success 200 {object} object{redirectUrl=string} "OAuth redirect URL"
+// @Failure 403 {string} string "User not found"
+// @Router /api/integrations/google/auth/login [get]
+// @Security XUserId
+func (a *GoogleAuth) OAuthLogin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	currentUser, err := a.userService.GetCurrentUser(r.Context())
+	if err != nil {
+		log.Error("unable to retrieve current user: ", err)
+		http.Error(w, "unable to retrieve current user", http.StatusInternalServerError)
+		return
+	}
+
+	stateNonce := uuid.New().String()
+	finalUrl := r.URL.Query().Get("finalUrl")
+
+	// The token is kept until the new one arrives, so the calendar keeps working if the user abandons the flow
+	err = a.credentials.StartAuthentication(r.Context(), credentials.Google, currentUser.Id, stateNonce)
+	if err != nil {
+		log.Errorf("failed to store Google auth nonce for user %d: %v", currentUser.Id, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
+			Error: "Failed to handle Google authentication",
+		})
+		if encodeErr != nil {
+			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Tracef("Redirecting to Google auth URL with nonce: %s", stateNonce)
+	// Google only returns a refresh token on consent
+	u := a.oauthConfig.AuthCodeURL(finalUrl+"|"+stateNonce, oauth2.AccessTypeOffline,
+		oauth2.SetAuthURLParam("prompt", "consent select_account"))
+
+	w.WriteHeader(http.StatusOK)
+	encodeErr := json.NewEncoder(w).Encode(googleAuthRedirect{
+		RedirectUrl: u,
+	})
+	if encodeErr != nil {
+		http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
+	}
+}
+
+// OAuthCallback godoc
+// @Summary Google OAuth callback
+// @Description Handle the OAuth callback from Google
+// @Tags Google
+// @Param code query string true "Authorization code"
+// @Param state query string true "State parameter"
+// @Success 302 "Redirect to finalUrl with success=true/false"
+// @Router /api/integrations/google/auth/callback [get]
+func (a *GoogleAuth) OAuthCallback(w http.ResponseWriter, r *http.Request) {
+	code := r.FormValue("code")
+	state := r.FormValue("state")
+
+	parts := strings.SplitN(state, "|", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		http.Error(w, "Invalid state", http.StatusBadRequest)
+		return
+	}
+	finalUrl := parts[0]
+	nonce := parts[1]
+
+	if code == "" {
+		log.Warnf("Google authorization was not granted: %s", r.FormValue("error"))
+		http.Redirect(w, r, finalUrl+"?success=false", http.StatusFound)
+		return
+	}
+
+	token, err := a.oauthConfig.Exchange(context.WithValue(r.Context(), oauth2.HTTPClient, a.httpClient), code)
+	if err != nil {
+		log.Errorf("unable to exchange code for token: %v", err)
+		http.Redirect(w, r, finalUrl+"?success=false", http.StatusFound)
+		return
+	}
+
+	_, err = a.credentials.StoreTokenByNonce(r.Context(), credentials.Google, nonce, token)
+	if err != nil {
+		log.Errorf("unable to store Google auth token for nonce %s: %v", nonce, err)
+		http.Redirect(w, r, finalUrl+"?success=false", http.StatusFound)
+		return
+	}
+	log.Debug("Successfully stored Google auth token for nonce: ", nonce)
+	http.Redirect(w, r, finalUrl+"?success=true", http.StatusFound)
+}
+
+// IsAuthenticated godoc
+// @Summary Check Google authentication status
+// @Description Check if the current user has connected a Google account
+// @Tags Google
+// @Produce json
+// @Success 200 {string} string "true"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 "Not authenticated"
+// @Router /api/integrations/google/auth [get]
+// @Security XUserId
+func (a *GoogleAuth) IsAuthenticated(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userId, err := user.CurrentId(r.Context())
+	if err != nil {
+		log.Error("unable to retrieve current user: ", err)
+		http.Error(w, "unable to retrieve current user", http.StatusInternalServerError)
+		return
+	}
+	token, err := a.credentials.GetToken(r.Context(), credentials.Google, userId)
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if token == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("true"))
+}
+
+// RevokeToken revokes the grant at Google. Revoking the refresh token revokes its access tokens too.
+func (a *GoogleAuth) RevokeToken(ctx context.Context, token *oauth2.Token) error {
+	value := token.RefreshToken
+	if value == "" {
+		value = token.AccessToken
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, revokeURL,
+		strings.NewReader(url.Values{"token": {value}}.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to revoke Google token: %w", err)
+	}
+	defer resp.Body.Close()
+	// An already revoked or expired token is rejected as invalid, it cannot be used either way
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusBadRequest {
+		return fmt.Errorf("failed to revoke Google token: %s", resp.Status)
+	}
+	return nil
+}
+
+// Disconnect godoc
+// @Summary Disconnect Google
+// @Description Stop watching the Google calendar and revoke the Google token of the current user. Events stay in
+// @Description the Google calendar; switch the event calendar back to klokku to keep tracking.
+// @Tags Google
+// @Success 204 "No Content"
+// @Failure 403 {string} string "User not found"
+// @Router /api/integrations/google/auth [delete]
+// @Security XUserId
+func (a *GoogleAuth) Disconnect(w http.ResponseWriter, r *http.Request) {
+	userId, err := user.CurrentId(r.Context())
+	if err != nil {
+		log.Error("unable to retrieve current user: ", err)
+		http.Error(w, "unable to retrieve current user", http.StatusInternalServerError)
+		return
+	}
+	for _, fn := range a.onDisconnect {
+		if err := fn(r.Context(), userId); err != nil {
+			log.Warnf("failed to clean up the Google integration of user %d: %v", userId, err)
+		}
+	}
+	if err := a.credentials.Revoke(r.Context(), credentials.Google, userId); err != nil {
+		log.Errorf("failed to delete Google auth of user %d: %v", userId, err)
+		http.Error(w, "Failed to disconnect Google", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Client returns an HTTP client authorized for the Google Calendar API on behalf of the user, nil when the user has
+// not connected a Google account.
+func (a *GoogleAuth) Client(ctx context.Context, userId int) (*http.Client, error) {
+	token, err := a.credentials.GetToken(ctx, credentials.Google, userId)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, nil
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, a.httpClient)
+	return oauth2.NewClient(ctx, a.credentials.TokenSource(ctx, credentials.Google, userId, a.oauthConfig, token)), nil
+}
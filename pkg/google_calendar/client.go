@@ -0,0 +1,307 @@
+package google_calendar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/klokku/klokku/pkg/user"
+)
+
+var (
+	ErrUnauthenticated = errors.New("google account not connected")
+	ErrEventNotFound   = errors.New("google event not found")
+	// ErrSyncTokenExpired - Google no longer knows the sync token, the sync has to start over.
+	ErrSyncTokenExpired = errors.New("google sync token expired")
+)
+
+const (
+	calendarBaseUrl = "https://www.googleapis.com/calendar/v3"
+	// metadataProperty is the private extended property holding the calendar.EventMetadata JSON of an event.
+	metadataProperty = "klokkuMetadata"
+	eventsPageSize   = 2500
+	maxErrorLength   = 500
+)
+
+// Client calls the Google Calendar API on behalf of the current user. An empty calendar id is the primary calendar.
+type Client interface {
+	ListCalendars(ctx context.Context) ([]Calendar, error)
+	// ListEvents returns the events of the calendar overlapping the period, ordered by start.
+	ListEvents(ctx context.Context, calendarId string, from time.Time, to time.Time) ([]ApiEvent, error)
+	GetEvent(ctx context.Context, calendarId string, eventId string) (ApiEvent, error)
+	CreateEvent(ctx context.Context, calendarId string, event ApiEvent) (ApiEvent, error)
+	UpdateEvent(ctx context.Context, calendarId string, event ApiEvent) (ApiEvent, error)
+	DeleteEvent(ctx context.Context, calendarId string, eventId string) error
+	// ListChanges returns the events changed since the sync token, deleted ones included, and the next sync token.
+	// An empty sync token starts a sync of the events ending after since. It returns ErrSyncTokenExpired when the
+	// token is no longer valid.
+	ListChanges(ctx context.Context, calendarId string, syncToken string, since time.Time) ([]ApiEvent, string, error)
+	// Watch opens a push notification channel for the events of the calendar.
+	Watch(ctx context.Context, calendarId string, channel Channel, address string) (Channel, error)
+	StopChannel(ctx context.Context, channel Channel) error
+}
+
+type httpClientProvider interface {
+	Client(ctx context.Context, userId int) (*http.Client, error)
+}
+
+// ApiDateTime is the start or end of an event, all-day events have Date instead of DateTime.
+type ApiDateTime struct {
+	DateTime string `json:"dateTime,omitempty"`
+	Date     string `json:"date,omitempty"`
+}
+
+type ApiExtendedProperties struct {
+	Private map[string]string `json:"private,omitempty"`
+}
+
+type ApiEvent struct {
+	Id                 string                 `json:"id,omitempty"`
+	Etag               string                 `json:"etag,omitempty"`
+	Status             string                 `json:"status,omitempty"`
+	Summary            string                 `json:"summary,omitempty"`
+	Description        string                 `json:"description,omitempty"`
+	Start              *ApiDateTime           `json:"start,omitempty"`
+	End                *ApiDateTime           `json:"end,omitempty"`
+	ExtendedProperties *ApiExtendedProperties `json:"extendedProperties,omitempty"`
+}
+
+func (e ApiEvent) IsAllDay() bool {
+	return e.Start != nil && e.Start.Date != ""
+}
+
+func (e ApiEvent) IsCancelled() bool {
+	return e.Status == "cancelled"
+}
+
+type apiCalendar struct {
+	Id         string `json:"id"`
+	Summary    string `json:"summary"`
+	Primary    bool   `json:"primary"`
+	AccessRole string `json:"accessRole"`
+}
+
+type apiPage[T any] struct {
+	Items         []T    `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+	NextSyncToken string `json:"nextSyncToken"`
+}
+
+type apiChannel struct {
+	Id         string `json:"id"`
+	ResourceId string `json:"resourceId,omitempty"`
+	Type       string `json:"type,omitempty"`
+	Address    string `json:"address,omitempty"`
+	Token      string `json:"token,omitempty"`
+	// Expiration in Unix milliseconds
+	Expiration string `json:"expiration,omitempty"`
+}
+
+type ClientImpl struct {
+	auth    httpClientProvider
+	baseUrl string
+}
+
+func NewClient(auth *GoogleAuth) *ClientImpl {
+	return &ClientImpl{auth: auth, baseUrl: calendarBaseUrl}
+}
+
+func (c *ClientImpl) ListCalendars(ctx context.Context) ([]Calendar, error) {
+	apiCalendars, _, err := listAll[apiCalendar](ctx, c, c.baseUrl+"/users/me/calendarList", url.Values{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Google calendars: %w", err)
+	}
+	calendars := make([]Calendar, 0, len(apiCalendars))
+	for _, ac := range apiCalendars {
+		calendars = append(calendars, Calendar{
+			Id:        ac.Id,
+			Name:      ac.Summary,
+			IsPrimary: ac.Primary,
+			CanEdit:   ac.AccessRole == "owner" || ac.AccessRole == "writer",
+		})
+	}
+	return calendars, nil
+}
+
+func (c *ClientImpl) ListEvents(ctx context.Context, calendarId string, from time.Time, to time.Time) ([]ApiEvent, error) {
+	query := url.Values{}
+	query.Set("timeMin", from.UTC().Format(time.RFC3339))
+	query.Set("timeMax", to.UTC().Format(time.RFC3339))
+	query.Set("singleEvents", "true")
+	query.Set("orderBy", "startTime")
+	query.Set("maxResults", strconv.Itoa(eventsPageSize))
+	events, _, err := listAll[ApiEvent](ctx, c, c.eventsUrl(calendarId), query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Google events: %w", err)
+	}
+	return events, nil
+}
+
+func (c *ClientImpl) GetEvent(ctx context.Context, calendarId string, eventId string) (ApiEvent, error) {
+	var event ApiEvent
+	if err := c.call(ctx, http.MethodGet, c.eventUrl(calendarId, eventId), nil, &event); err != nil {
+		return ApiEvent{}, fmt.Errorf("failed to get Google event: %w", err)
+	}
+	return event, nil
+}
+
+func (c *ClientImpl) CreateEvent(ctx context.Context, calendarId string, event ApiEvent) (ApiEvent, error) {
+	var created ApiEvent
+	if err := c.call(ctx, http.MethodPost, c.eventsUrl(calendarId), event, &created); err != nil {
+		return ApiEvent{}, fmt.Errorf("failed to create Google event: %w", err)
+	}
+	return created, nil
+}
+
+func (c *ClientImpl) UpdateEvent(ctx context.Context, calendarId string, event ApiEvent) (ApiEvent, error) {
+	var updated ApiEvent
+	if err := c.call(ctx, http.MethodPatch, c.eventUrl(calendarId, event.Id), event, &updated); err != nil {
+		return ApiEvent{}, fmt.Errorf("failed to update Google event: %w", err)
+	}
+	return updated, nil
+}
+
+func (c *ClientImpl) DeleteEvent(ctx context.Context, calendarId string, eventId string) error {
+	if err := c.call(ctx, http.MethodDelete, c.eventUrl(calendarId, eventId), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete Google event: %w", err)
+	}
+	return nil
+}
+
+func (c *ClientImpl) ListChanges(ctx context.Context, calendarId string, syncToken string,
+	since time.Time) ([]ApiEvent, string, error) {
+	query := url.Values{}
+	query.Set("singleEvents", "true")
+	query.Set("maxResults", strconv.Itoa(eventsPageSize))
+	if syncToken != "" {
+		query.Set("syncToken", syncToken)
+	} else {
+		query.Set("timeMin", since.UTC().Format(time.RFC3339))
+	}
+	events, nextSyncToken, err := listAll[ApiEvent](ctx, c, c.eventsUrl(calendarId), query)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list changed Google events: %w", err)
+	}
+	return events, nextSyncToken, nil
+}
+
+func (c *ClientImpl) Watch(ctx context.Context, calendarId string, channel Channel, address string) (Channel, error) {
+	request := apiChannel{
+		Id:         channel.ChannelId,
+		Type:       "web_hook",
+		Address:    address,
+		Token:      channel.Token,
+		Expiration: strconv.FormatInt(channel.ExpiresAt.UnixMilli(), 10),
+	}
+	var response apiChannel
+	if err := c.call(ctx, http.MethodPost, c.eventsUrl(calendarId)+"/watch", request, &response); err != nil {
+		return Channel{}, fmt.Errorf("failed to watch Google calendar: %w", err)
+	}
+	channel.CalendarId = calendarId
+	channel.ResourceId = response.ResourceId
+	if expiration, err := strconv.ParseInt(response.Expiration, 10, 64); err == nil {
+		channel.ExpiresAt = time.UnixMilli(expiration).UTC()
+	}
+	return channel, nil
+}
+
+func (c *ClientImpl) StopChannel(ctx context.Context, channel Channel) error {
+	request := apiChannel{Id: channel.ChannelId, ResourceId: channel.ResourceId}
+	if err := c.call(ctx, http.MethodPost, c.baseUrl+"/channels/stop", request, nil); err != nil {
+		return fmt.Errorf("failed to stop Google channel: %w", err)
+	}
+	return nil
+}
+
+func (c *ClientImpl) eventsUrl(calendarId string) string {
+	if calendarId == "" {
+		calendarId = "primary"
+	}
+	return c.baseUrl + "/calendars/" + url.PathEscape(calendarId) + "/events"
+}
+
+func (c *ClientImpl) eventUrl(calendarId string, eventId string) string {
+	return c.eventsUrl(calendarId) + "/" + url.PathEscape(eventId)
+}
+
+// listAll follows the nextPageToken of the pages and returns the items with the sync token of the last page.
+func listAll[T any](ctx context.Context, c *ClientImpl, listUrl string, query url.Values) ([]T, string, error) {
+	result := make([]T, 0)
+	for {
+		var page apiPage[T]
+		pageUrl := listUrl
+		if len(query) > 0 {
+			pageUrl += "?" + query.Encode()
+		}
+		if err := c.call(ctx, http.MethodGet, pageUrl, nil, &page); err != nil {
+			return nil, "", err
+		}
+		result = append(result, page.Items...)
+		if page.NextPageToken == "" {
+			return result, page.NextSyncToken, nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+// call sends the request with the body encoded as JSON and decodes the response into result, when not nil.
+func (c *ClientImpl) call(ctx context.Context, method string, requestUrl string, body any, result any) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	client, err := c.auth.Client(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		return ErrUnauthenticated
+	}
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, requestUrl, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthenticated
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrEventNotFound
+	case resp.StatusCode == http.StatusGone && method == http.MethodDelete:
+		// The event was deleted already
+		return ErrEventNotFound
+	case resp.StatusCode == http.StatusGone:
+		return ErrSyncTokenExpired
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		response, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(response))
+	}
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,350 @@
+package google_calendar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrInvalidEvent = errors.New("invalid event")
+
+// lastEventsWindow is how far back GetLastEvents looks, the Calendar API cannot list events the latest first.
+const lastEventsWindow = 30 * 24 * time.Hour
+
+// Calendar is one of the user's Google calendars events can be stored in.
+type Calendar struct {
+	Id        string
+	Name      string
+	IsPrimary bool
+	CanEdit   bool
+}
+
+// ImportRule assigns events created in Google without a budget item to the budget item, when their summary
+// contains the pattern, ignoring case. The first matching rule by position wins.
+type ImportRule struct {
+	Pattern      string
+	BudgetItemId int
+	Position     int
+}
+
+func (r ImportRule) Matches(summary string) bool {
+	pattern := strings.TrimSpace(r.Pattern)
+	return pattern != "" && strings.Contains(strings.ToLower(summary), strings.ToLower(pattern))
+}
+
+// Channel is the push notification channel watching the user's calendar. Google calls the notification URL
+// whenever an event of the calendar changes, the changes are then read with the sync token.
+type Channel struct {
+	UserId     int
+	CalendarId string
+	ChannelId  string
+	ResourceId string
+	// Token is sent back with every notification, it proves the notification comes from Google.
+	Token     string
+	ExpiresAt time.Time
+	// SyncToken is the cursor of the incremental sync, empty before the first sync.
+	SyncToken string
+}
+
+// GoogleCalendar implements calendar.Calendar on top of the user's Google calendar. Google is the only store of
+// the events, so changes made in Google show up in Klokku and the other way round. Klokku's event metadata is kept
+// in a private extended property of the Google event. Events in locked weeks cannot be changed through Klokku.
+type GoogleCalendar struct {
+	client          Client
+	repo            Repository
+	eventBus        *event_bus.EventBus
+	weekLockChecker calendar.WeekLockCheckerFunc
+	clock           utils.Clock
+}
+
+// NewGoogleCalendar creates the Google calendar. A nil weekLockChecker disables week locks.
+func NewGoogleCalendar(client Client, repo Repository, eventBus *event_bus.EventBus,
+	weekLockChecker calendar.WeekLockCheckerFunc) *GoogleCalendar {
+	return &GoogleCalendar{
+		client:          client,
+		repo:            repo,
+		eventBus:        eventBus,
+		weekLockChecker: weekLockChecker,
+		clock:           &utils.SystemClock{},
+	}
+}
+
+func calendarId(ctx context.Context) (int, string, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get current user: %w", err)
+	}
+	return currentUser.Id, currentUser.Settings.GoogleCalendar.CalendarId, nil
+}
+
+func (c *GoogleCalendar) AddEvent(ctx context.Context, event calendar.Event) ([]calendar.Event, error) {
+	if err := validateEvent(event); err != nil {
+		return nil, err
+	}
+	if err := c.weekLockChecker.CheckEvent(ctx, event); err != nil {
+		return nil, err
+	}
+	userId, calendarId, err := calendarId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	apiEvent, err := toApiEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	created, err := c.client.CreateEvent(ctx, calendarId, apiEvent)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := fromApiEvent(created)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.repo.StoreEventVersion(ctx, userId, created.Id, created.Etag); err != nil {
+		return nil, err
+	}
+	if err := publishCreated(ctx, c.eventBus, stored); err != nil {
+		return nil, err
+	}
+	return []calendar.Event{stored}, nil
+}
+
+func (c *GoogleCalendar) GetEvents(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error) {
+	_, calendarId, err := calendarId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	apiEvents, err := c.client.ListEvents(ctx, calendarId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return fromApiEvents(apiEvents), nil
+}
+
+// GetEventsIncludingArchive is GetEvents, Google events are never archived by Klokku.
+func (c *GoogleCalendar) GetEventsIncludingArchive(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error) {
+	return c.GetEvents(ctx, from, to)
+}
+
+func (c *GoogleCalendar) ModifyEvent(ctx context.Context, event calendar.Event) ([]calendar.Event, error) {
+	if err := validateEvent(event); err != nil {
+		return nil, err
+	}
+	userId, calendarId, err := calendarId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkStoredEventNotLocked(ctx, calendarId, event.UID); err != nil {
+		return nil, err
+	}
+	if err := c.weekLockChecker.CheckEvent(ctx, event); err != nil {
+		return nil, err
+	}
+	apiEvent, err := toApiEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := c.client.UpdateEvent(ctx, calendarId, apiEvent)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := fromApiEvent(updated)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.repo.StoreEventVersion(ctx, userId, updated.Id, updated.Etag); err != nil {
+		return nil, err
+	}
+	if err := publishUpdated(ctx, c.eventBus, stored); err != nil {
+		return nil, err
+	}
+	return []calendar.Event{stored}, nil
+}
+
+func (c *GoogleCalendar) GetLastEvents(ctx context.Context, limit int) ([]calendar.Event, error) {
+	_, calendarId, err := calendarId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := c.clock.Now()
+	apiEvents, err := c.client.ListEvents(ctx, calendarId, now.Add(-lastEventsWindow), now)
+	if err != nil {
+		return nil, err
+	}
+	events := fromApiEvents(apiEvents)
+	last := make([]calendar.Event, 0, limit)
+	for i := len(events) - 1; i >= 0 && len(last) < limit; i-- {
+		if events[i].StartTime.After(now) {
+			continue
+		}
+		last = append(last, events[i])
+	}
+	return last, nil
+}
+
+func (c *GoogleCalendar) DeleteEvent(ctx context.Context, eventUid string) error {
+	userId, calendarId, err := calendarId(ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.checkStoredEventNotLocked(ctx, calendarId, eventUid); err != nil {
+		return err
+	}
+	if err := c.client.DeleteEvent(ctx, calendarId, eventUid); err != nil {
+		return err
+	}
+	if err := c.repo.DeleteEventVersion(ctx, userId, eventUid); err != nil {
+		return err
+	}
+	return publishDeleted(ctx, c.eventBus, eventUid)
+}
+
+// checkStoredEventNotLocked checks the event as currently stored in Google, so it cannot be moved out of a locked
+// week. Unknown events are left to the caller to report.
+func (c *GoogleCalendar) checkStoredEventNotLocked(ctx context.Context, calendarId string, eventUid string) error {
+	if c.weekLockChecker == nil {
+		return nil
+	}
+	apiEvent, err := c.client.GetEvent(ctx, calendarId, eventUid)
+	if err != nil {
+		if errors.Is(err, ErrEventNotFound) {
+			return nil
+		}
+		return err
+	}
+	stored, err := fromApiEvent(apiEvent)
+	if err != nil {
+		return err
+	}
+	return c.weekLockChecker.CheckEvent(ctx, stored)
+}
+
+func publishCreated(ctx context.Context, eventBus *event_bus.EventBus, event calendar.Event) error {
+	if eventBus == nil {
+		return nil
+	}
+	err := eventBus.Publish(event_bus.NewEvent(ctx, "calendar.event.created", event_bus.CalendarEventCreated{
+		UID:          event.UID,
+		Summary:      event.Summary,
+		StartTime:    event.StartTime,
+		EndTime:      event.EndTime,
+		BudgetItemId: event.Metadata.BudgetItemId,
+		TaskId:       event.Metadata.TaskId,
+		Sandbox:      event.Metadata.Sandbox,
+	}))
+	if err != nil {
+		return fmt.Errorf("failed to publish event creation: %w", err)
+	}
+	return nil
+}
+
+func publishUpdated(ctx context.Context, eventBus *event_bus.EventBus, event calendar.Event) error {
+	if eventBus == nil {
+		return nil
+	}
+	err := eventBus.Publish(event_bus.NewEvent(ctx, "calendar.event.updated", event_bus.CalendarEventUpdated{
+		UID:          event.UID,
+		Summary:      event.Summary,
+		StartTime:    event.StartTime,
+		EndTime:      event.EndTime,
+		BudgetItemId: event.Metadata.BudgetItemId,
+		TaskId:       event.Metadata.TaskId,
+		Sandbox:      event.Metadata.Sandbox,
+	}))
+	if err != nil {
+		return fmt.Errorf("failed to publish event update: %w", err)
+	}
+	return nil
+}
+
+func publishDeleted(ctx context.Context, eventBus *event_bus.EventBus, eventUid string) error {
+	if eventBus == nil {
+		return nil
+	}
+	err := eventBus.Publish(event_bus.NewEvent(ctx, "calendar.event.deleted", event_bus.CalendarEventDeleted{UID: eventUid}))
+	if err != nil {
+		return fmt.Errorf("failed to publish event deletion: %w", err)
+	}
+	return nil
+}
+
+func validateEvent(event calendar.Event) error {
+	if strings.TrimSpace(event.Summary) == "" {
+		return fmt.Errorf("%w: summary is required", ErrInvalidEvent)
+	}
+	if !event.EndTime.After(event.StartTime) {
+		return fmt.Errorf("%w: end time must be after start time", ErrInvalidEvent)
+	}
+	return nil
+}
+
+func toApiEvent(event calendar.Event) (ApiEvent, error) {
+	metadata, err := json.Marshal(event.Metadata)
+	if err != nil {
+		return ApiEvent{}, fmt.Errorf("failed to marshal event metadata: %w", err)
+	}
+	return ApiEvent{
+		Id:          event.UID,
+		Summary:     event.Summary,
+		Description: event.Metadata.Notes,
+		Start:       &ApiDateTime{DateTime: event.StartTime.UTC().Format(time.RFC3339)},
+		End:         &ApiDateTime{DateTime: event.EndTime.UTC().Format(time.RFC3339)},
+		ExtendedProperties: &ApiExtendedProperties{
+			Private: map[string]string{metadataProperty: string(metadata)},
+		},
+	}, nil
+}
+
+func fromApiEvent(apiEvent ApiEvent) (calendar.Event, error) {
+	if apiEvent.Start == nil || apiEvent.End == nil {
+		return calendar.Event{}, fmt.Errorf("event %s has no start or end", apiEvent.Id)
+	}
+	start, err := time.Parse(time.RFC3339, apiEvent.Start.DateTime)
+	if err != nil {
+		return calendar.Event{}, fmt.Errorf("invalid start %q: %w", apiEvent.Start.DateTime, err)
+	}
+	end, err := time.Parse(time.RFC3339, apiEvent.End.DateTime)
+	if err != nil {
+		return calendar.Event{}, fmt.Errorf("invalid end %q: %w", apiEvent.End.DateTime, err)
+	}
+	event := calendar.Event{
+		UID:       apiEvent.Id,
+		Summary:   apiEvent.Summary,
+		StartTime: start.UTC(),
+		EndTime:   end.UTC(),
+	}
+	if apiEvent.ExtendedProperties != nil {
+		if metadata, ok := apiEvent.ExtendedProperties.Private[metadataProperty]; ok {
+			if err := json.Unmarshal([]byte(metadata), &event.Metadata); err != nil {
+				log.Warnf("ignoring invalid metadata of Google event %s: %v", apiEvent.Id, err)
+			}
+		}
+	}
+	return event, nil
+}
+
+// fromApiEvents skips all-day events, they are not time tracked in Google.
+func fromApiEvents(apiEvents []ApiEvent) []calendar.Event {
+	events := make([]calendar.Event, 0, len(apiEvents))
+	for _, apiEvent := range apiEvents {
+		if apiEvent.IsAllDay() {
+			continue
+		}
+		event, err := fromApiEvent(apiEvent)
+		if err != nil {
+			log.Warnf("ignoring Google event %s: %v", apiEvent.Id, err)
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
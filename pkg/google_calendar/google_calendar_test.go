@@ -0,0 +1,511 @@
+package google_calendar
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+
+// fakeGoogle keeps events in memory and serves the subset of the Google Calendar API the client uses. The events
+// changed since the last sync are kept in changes.
+type fakeGoogle struct {
+	mu       sync.Mutex
+	events   map[string]ApiEvent
+	changes  []ApiEvent
+	channels map[string]apiChannel
+	nextId   int
+	syncs    int
+	requests []string
+}
+
+func newFakeGoogle() *fakeGoogle {
+	return &fakeGoogle{events: make(map[string]ApiEvent), channels: make(map[string]apiChannel)}
+}
+
+// store saves the event with a new etag, as a change made in Google.
+func (g *fakeGoogle) store(event ApiEvent) ApiEvent {
+	g.nextId++
+	if event.Id == "" {
+		event.Id = "evt-" + strconv.Itoa(g.nextId)
+	}
+	event.Etag = `"` + strconv.Itoa(g.nextId) + `"`
+	g.events[event.Id] = event
+	g.changes = append(g.changes, event)
+	return event
+}
+
+func (g *fakeGoogle) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.requests = append(g.requests, r.Method+" "+r.URL.Path)
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	eventId := ""
+	if _, after, ok := strings.Cut(r.URL.Path, "/events/"); ok {
+		eventId = after
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/users/me/calendarList":
+		if r.URL.Query().Get("pageToken") == "" {
+			writeJSON(w, map[string]any{
+				"items":         []apiCalendar{{Id: "cal-1", Summary: "Calendar", Primary: true, AccessRole: "owner"}},
+				"nextPageToken": "page2",
+			})
+			return
+		}
+		writeJSON(w, map[string]any{"items": []apiCalendar{{Id: "cal-2", Summary: "Holidays", AccessRole: "reader"}}})
+	case r.Method == http.MethodPost && r.URL.Path == "/channels/stop":
+		var channel apiChannel
+		_ = json.NewDecoder(r.Body).Decode(&channel)
+		delete(g.channels, channel.Id)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/events/watch"):
+		var channel apiChannel
+		_ = json.NewDecoder(r.Body).Decode(&channel)
+		channel.ResourceId = "resource-" + channel.Id
+		g.channels[channel.Id] = channel
+		writeJSON(w, channel)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/events"):
+		var event ApiEvent
+		_ = json.NewDecoder(r.Body).Decode(&event)
+		writeJSON(w, g.store(event))
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/events"):
+		query := r.URL.Query()
+		if query.Get("orderBy") != "" {
+			events := make([]ApiEvent, 0)
+			for _, event := range g.events {
+				events = append(events, event)
+			}
+			writeJSON(w, map[string]any{"items": events})
+			return
+		}
+		if query.Get("syncToken") == "expired" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		changes := make([]ApiEvent, 0)
+		if query.Get("syncToken") != "" {
+			changes = g.changes
+		}
+		g.changes = nil
+		g.syncs++
+		writeJSON(w, map[string]any{"items": changes, "nextSyncToken": "sync-" + strconv.Itoa(g.syncs)})
+	case r.Method == http.MethodGet && eventId != "":
+		event, ok := g.events[eventId]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJSON(w, event)
+	case r.Method == http.MethodPatch && eventId != "":
+		event, ok := g.events[eventId]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var patch ApiEvent
+		_ = json.NewDecoder(r.Body).Decode(&patch)
+		if patch.Summary != "" {
+			event.Summary = patch.Summary
+		}
+		if patch.Start != nil {
+			event.Start, event.End = patch.Start, patch.End
+		}
+		if patch.ExtendedProperties != nil {
+			event.ExtendedProperties = patch.ExtendedProperties
+		}
+		writeJSON(w, g.store(event))
+	case r.Method == http.MethodDelete && eventId != "":
+		event, ok := g.events[eventId]
+		if !ok {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		delete(g.events, eventId)
+		g.changes = append(g.changes, ApiEvent{Id: event.Id, Status: "cancelled"})
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// tokenAuth authorizes the requests of connected users with a static token.
+type tokenAuth struct {
+	connected map[int]bool
+}
+
+type bearerTransport struct{}
+
+func (bearerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer test-token")
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func (a tokenAuth) Client(ctx context.Context, userId int) (*http.Client, error) {
+	if !a.connected[userId] {
+		return nil, nil
+	}
+	return &http.Client{Transport: bearerTransport{}}, nil
+}
+
+// budgetItemsStub knows the budget items of the test user.
+type budgetItemsStub map[int]bool
+
+func (s budgetItemsStub) GetItem(ctx context.Context, id int) (budget_plan.BudgetItem, error) {
+	if !s[id] {
+		return budget_plan.BudgetItem{}, budget_plan.ErrBudgetPlanItemNotFound
+	}
+	return budget_plan.BudgetItem{Id: id}, nil
+}
+
+type usersStub map[int]user.User
+
+func (s usersStub) GetUser(ctx context.Context, id int) (user.User, error) {
+	return s[id], nil
+}
+
+type testEnv struct {
+	calendar *GoogleCalendar
+	syncer   *Syncer
+	client   *ClientImpl
+	google   *fakeGoogle
+	repo     *RepositoryStub
+	eventBus *event_bus.EventBus
+	ctx      context.Context
+}
+
+func setupTest(t *testing.T) testEnv {
+	t.Helper()
+	google := newFakeGoogle()
+	server := httptest.NewServer(google)
+	t.Cleanup(server.Close)
+	client := &ClientImpl{auth: tokenAuth{connected: map[int]bool{1: true}}, baseUrl: server.URL}
+	repo := NewRepositoryStub()
+	eventBus := event_bus.NewEventBus()
+	testUser := user.User{Id: 1, Uid: "user-1", Username: "test-user-1", Settings: user.Settings{
+		EventCalendarType: user.GoogleCalendar,
+		GoogleCalendar:    user.GoogleCalendarSettings{CalendarId: "cal-1"},
+	}}
+	googleCalendar := NewGoogleCalendar(client, repo, eventBus, nil)
+	googleCalendar.clock = &utils.MockClock{FixedNow: now}
+	syncer := NewSyncer(repo, client, budgetItemsStub{10: true, 11: true}, usersStub{1: testUser}, eventBus, nil,
+		"https://klokku.example.com")
+	syncer.clock = &utils.MockClock{FixedNow: now}
+	return testEnv{
+		calendar: googleCalendar,
+		syncer:   syncer,
+		client:   client,
+		google:   google,
+		repo:     repo,
+		eventBus: eventBus,
+		ctx:      user.WithUser(context.Background(), testUser),
+	}
+}
+
+// watch opens the channel of the test user, changes made before are not imported.
+func (env testEnv) watch(t *testing.T) Channel {
+	t.Helper()
+	require.NoError(t, env.syncer.RenewChannel(env.ctx, now))
+	channel, err := env.repo.GetChannel(env.ctx, 1)
+	require.NoError(t, err)
+	require.NotNil(t, channel)
+	return *channel
+}
+
+func googleEvent(summary string, start time.Time, end time.Time) ApiEvent {
+	return ApiEvent{
+		Summary: summary,
+		Start:   &ApiDateTime{DateTime: start.Format(time.RFC3339)},
+		End:     &ApiDateTime{DateTime: end.Format(time.RFC3339)},
+	}
+}
+
+func TestAddEvent_StoresMetadataAndPublishesCreation(t *testing.T) {
+	env := setupTest(t)
+	var published []event_bus.CalendarEventCreated
+	event_bus.SubscribeTyped[event_bus.CalendarEventCreated](env.eventBus, "calendar.event.created",
+		func(e event_bus.EventT[event_bus.CalendarEventCreated]) error {
+			published = append(published, e.Data)
+			return nil
+		})
+
+	stored, err := env.calendar.AddEvent(env.ctx, calendar.Event{
+		Summary:   "Reading",
+		StartTime: now.Add(-time.Hour),
+		EndTime:   now,
+		Metadata:  calendar.EventMetadata{BudgetItemId: 10, Notes: "chapter 3"},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, stored, 1)
+	assert.Equal(t, "evt-1", stored[0].UID)
+	assert.Equal(t, calendar.EventMetadata{BudgetItemId: 10, Notes: "chapter 3"}, stored[0].Metadata)
+	assert.Equal(t, "chapter 3", env.google.events["evt-1"].Description)
+	etag, _ := env.repo.GetEventVersion(env.ctx, 1, "evt-1")
+	assert.Equal(t, env.google.events["evt-1"].Etag, etag)
+	require.Len(t, published, 1)
+	assert.Equal(t, 10, published[0].BudgetItemId)
+
+	events, err := env.calendar.GetEvents(env.ctx, now.Add(-24*time.Hour), now)
+	require.NoError(t, err)
+	assert.Equal(t, stored, events)
+}
+
+func TestLockedWeeks(t *testing.T) {
+	env := setupTest(t)
+	lockedUntil := now.Add(-7 * 24 * time.Hour)
+	env.calendar.weekLockChecker = func(ctx context.Context, date time.Time) (bool, error) {
+		return date.Before(lockedUntil), nil
+	}
+	locked := env.google.store(googleEvent("Reading", lockedUntil.Add(-2*time.Hour), lockedUntil.Add(-time.Hour)))
+
+	_, addErr := env.calendar.AddEvent(env.ctx, calendar.Event{
+		Summary:   "Reading",
+		StartTime: lockedUntil.Add(-time.Hour),
+		EndTime:   lockedUntil,
+	})
+	_, modifyErr := env.calendar.ModifyEvent(env.ctx, calendar.Event{
+		UID:       locked.Id,
+		Summary:   "Reading",
+		StartTime: now.Add(-time.Hour),
+		EndTime:   now,
+	})
+	deleteErr := env.calendar.DeleteEvent(env.ctx, locked.Id)
+
+	assert.ErrorIs(t, addErr, weekly_plan.ErrWeekLocked)
+	assert.ErrorIs(t, modifyErr, weekly_plan.ErrWeekLocked)
+	assert.ErrorIs(t, deleteErr, weekly_plan.ErrWeekLocked)
+	assert.Equal(t, map[string]ApiEvent{locked.Id: locked}, env.google.events)
+}
+
+func TestRenewChannel(t *testing.T) {
+	env := setupTest(t)
+
+	channel := env.watch(t)
+
+	assert.Equal(t, "cal-1", channel.CalendarId)
+	assert.Equal(t, "sync-1", channel.SyncToken)
+	assert.NotEmpty(t, channel.Token)
+	assert.Equal(t, "resource-"+channel.ChannelId, channel.ResourceId)
+	require.Contains(t, env.google.channels, channel.ChannelId)
+	assert.Equal(t, "https://klokku.example.com/api/integrations/google/notifications",
+		env.google.channels[channel.ChannelId].Address)
+
+	t.Run("keeps a channel far from its expiry", func(t *testing.T) {
+		require.NoError(t, env.syncer.RenewChannel(env.ctx, now.Add(time.Hour)))
+
+		assert.Equal(t, channel, env.watch(t))
+	})
+	t.Run("replaces a channel expiring soon and keeps the sync token", func(t *testing.T) {
+		require.NoError(t, env.syncer.RenewChannel(env.ctx, now.Add(channelTtl-time.Hour)))
+
+		renewed, _ := env.repo.GetChannel(env.ctx, 1)
+		assert.NotEqual(t, channel.ChannelId, renewed.ChannelId)
+		assert.Equal(t, channel.SyncToken, renewed.SyncToken)
+		assert.NotContains(t, env.google.channels, channel.ChannelId)
+	})
+	t.Run("stops the channel when events are no longer kept in Google", func(t *testing.T) {
+		ctx := user.WithUser(context.Background(), user.User{Id: 1})
+
+		require.NoError(t, env.syncer.RenewChannel(ctx, now))
+
+		stored, _ := env.repo.GetChannel(env.ctx, 1)
+		assert.Nil(t, stored)
+		assert.Empty(t, env.google.channels)
+	})
+}
+
+func TestHandleNotification(t *testing.T) {
+	env := setupTest(t)
+	channel := env.watch(t)
+	env.google.store(googleEvent("Team meeting", now.Add(-time.Hour), now))
+
+	t.Run("rejects a wrong token", func(t *testing.T) {
+		err := env.syncer.HandleNotification(env.ctx, channel.ChannelId, "wrong", "exists")
+
+		assert.ErrorIs(t, err, ErrUnknownChannel)
+	})
+	t.Run("rejects an unknown channel", func(t *testing.T) {
+		err := env.syncer.HandleNotification(env.ctx, "unknown", channel.Token, "exists")
+
+		assert.ErrorIs(t, err, ErrUnknownChannel)
+	})
+	t.Run("syncs the user of the channel", func(t *testing.T) {
+		ctx := context.Background()
+
+		require.NoError(t, env.syncer.HandleNotification(ctx, channel.ChannelId, channel.Token, "exists"))
+		require.NoError(t, env.syncer.flush(ctx))
+
+		synced, _ := env.repo.GetChannel(ctx, 1)
+		assert.Equal(t, "sync-2", synced.SyncToken)
+	})
+}
+
+func TestSync_ImportsEventsChangedInGoogle(t *testing.T) {
+	env := setupTest(t)
+	env.watch(t)
+	require.NoError(t, env.syncer.StoreImportRules(env.ctx, []ImportRule{
+		{Pattern: "meeting", BudgetItemId: 10},
+		{Pattern: "team", BudgetItemId: 11},
+	}))
+	var created []event_bus.CalendarEventCreated
+	event_bus.SubscribeTyped[event_bus.CalendarEventCreated](env.eventBus, "calendar.event.created",
+		func(e event_bus.EventT[event_bus.CalendarEventCreated]) error {
+			created = append(created, e.Data)
+			return nil
+		})
+	var deleted []event_bus.CalendarEventDeleted
+	event_bus.SubscribeTyped[event_bus.CalendarEventDeleted](env.eventBus, "calendar.event.deleted",
+		func(e event_bus.EventT[event_bus.CalendarEventDeleted]) error {
+			deleted = append(deleted, e.Data)
+			return nil
+		})
+
+	// Made through Klokku, it must not be imported again
+	own, err := env.calendar.AddEvent(env.ctx, calendar.Event{
+		Summary:   "Reading",
+		StartTime: now.Add(-3 * time.Hour),
+		EndTime:   now.Add(-2 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 11},
+	})
+	require.NoError(t, err)
+	meeting := env.google.store(googleEvent("Team Meeting", now.Add(-time.Hour), now))
+	env.google.store(googleEvent("Lunch", now.Add(-2*time.Hour), now.Add(-time.Hour)))
+	env.google.store(ApiEvent{Summary: "Holiday", Start: &ApiDateTime{Date: "2025-06-10"}, End: &ApiDateTime{Date: "2025-06-11"}})
+
+	result, err := env.syncer.Sync(env.ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, SyncResult{Created: 2, Assigned: 1}, result)
+	metadata := env.google.events[meeting.Id].ExtendedProperties.Private[metadataProperty]
+	assert.JSONEq(t, `{"budgetItemId":10}`, metadata)
+	require.Len(t, created, 3)
+	assert.Equal(t, 10, created[1].BudgetItemId)
+	assert.Equal(t, "Team Meeting", created[1].Summary)
+	assert.Equal(t, 0, created[2].BudgetItemId)
+
+	t.Run("does not import the rule assignment back", func(t *testing.T) {
+		result, err := env.syncer.Sync(env.ctx)
+		require.NoError(t, err)
+
+		assert.Equal(t, SyncResult{}, result)
+	})
+	t.Run("publishes the deletion of known events", func(t *testing.T) {
+		require.NoError(t, env.client.DeleteEvent(env.ctx, "cal-1", meeting.Id))
+
+		result, err := env.syncer.Sync(env.ctx)
+		require.NoError(t, err)
+
+		assert.Equal(t, SyncResult{Deleted: 1}, result)
+		require.Len(t, deleted, 1)
+		assert.Equal(t, meeting.Id, deleted[0].UID)
+		assert.NotEqual(t, own[0].UID, deleted[0].UID)
+	})
+}
+
+func TestSync_ExpiredSyncTokenStartsOver(t *testing.T) {
+	env := setupTest(t)
+	env.watch(t)
+	require.NoError(t, env.repo.UpdateSyncToken(env.ctx, 1, "expired"))
+
+	result, err := env.syncer.Sync(env.ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, SyncResult{}, result)
+	channel, _ := env.repo.GetChannel(env.ctx, 1)
+	assert.Equal(t, "sync-2", channel.SyncToken)
+}
+
+func TestSync_SkipsLockedWeeks(t *testing.T) {
+	env := setupTest(t)
+	env.syncer.weekLockChecker = func(ctx context.Context, date time.Time) (bool, error) {
+		return true, nil
+	}
+	env.watch(t)
+	event := env.google.store(googleEvent("Team meeting", now.Add(-time.Hour), now))
+
+	result, err := env.syncer.Sync(env.ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, SyncResult{}, result)
+	etag, _ := env.repo.GetEventVersion(env.ctx, 1, event.Id)
+	assert.Equal(t, event.Etag, etag)
+}
+
+func TestStoreImportRules_Validation(t *testing.T) {
+	env := setupTest(t)
+
+	tests := []struct {
+		name  string
+		rules []ImportRule
+	}{
+		{"empty pattern", []ImportRule{{Pattern: "  ", BudgetItemId: 10}}},
+		{"missing budget item", []ImportRule{{Pattern: "meeting"}}},
+		{"budget item of another user", []ImportRule{{Pattern: "meeting", BudgetItemId: 99}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := env.syncer.StoreImportRules(env.ctx, tt.rules)
+
+			assert.ErrorIs(t, err, ErrInvalidImportRule)
+		})
+	}
+
+	require.NoError(t, env.syncer.StoreImportRules(env.ctx, []ImportRule{
+		{Pattern: " gym ", BudgetItemId: 11},
+		{Pattern: "meeting", BudgetItemId: 10},
+	}))
+	rules, err := env.syncer.GetImportRules(env.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []ImportRule{
+		{Pattern: "gym", BudgetItemId: 11, Position: 0},
+		{Pattern: "meeting", BudgetItemId: 10, Position: 1},
+	}, rules)
+}
+
+func TestListCalendars_FollowsPageTokens(t *testing.T) {
+	env := setupTest(t)
+
+	calendars, err := env.client.ListCalendars(env.ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Calendar{
+		{Id: "cal-1", Name: "Calendar", IsPrimary: true, CanEdit: true},
+		{Id: "cal-2", Name: "Holidays"},
+	}, calendars)
+}
+
+func TestClient_NotConnected(t *testing.T) {
+	env := setupTest(t)
+	ctx := user.WithUser(context.Background(), user.User{Id: 2})
+
+	_, err := env.client.ListCalendars(ctx)
+
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	assert.Empty(t, env.google.requests)
+}
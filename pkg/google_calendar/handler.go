@@ -0,0 +1,154 @@
+package google_calendar
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/klokku/klokku/internal/rest"
+	log "github.com/sirupsen/logrus"
+)
+
+type CalendarDTO struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	IsPrimary bool   `json:"isPrimary"`
+	CanEdit   bool   `json:"canEdit"`
+}
+
+type ImportRuleDTO struct {
+	// Pattern is matched against the event summary, ignoring case
+	Pattern      string `json:"pattern"`
+	BudgetItemId int    `json:"budgetItemId"`
+}
+
+type Handler struct {
+	client Client
+	syncer *Syncer
+}
+
+func NewHandler(client Client, syncer *Syncer) *Handler {
+	return &Handler{client: client, syncer: syncer}
+}
+
+// ListCalendars godoc
+// @Summary List Google calendars
+// @Description List the calendars of the connected Google account. The id of the chosen one goes to
+// @Description settings.googleCalendar.calendarId of the user, with eventCalendarType set to google.
+// @Tags Google
+// @Produce json
+// @Success 200 {array} CalendarDTO
+// @Failure 401 {string} string "Google not connected"
+// @Failure 403 {string} string "User not found"
+// @Router /api/integrations/google/calendars [get]
+// @Security XUserId
+func (h *Handler) ListCalendars(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	calendars, err := h.client.ListCalendars(r.Context())
+	if err != nil {
+		if errors.Is(err, ErrUnauthenticated) {
+			http.Error(w, "Google not connected", http.StatusUnauthorized)
+			return
+		}
+		log.Errorf("Failed to list Google calendars: %v", err)
+		http.Error(w, "Failed to list Google calendars", http.StatusInternalServerError)
+		return
+	}
+	dtos := make([]CalendarDTO, 0, len(calendars))
+	for _, c := range calendars {
+		dtos = append(dtos, CalendarDTO{Id: c.Id, Name: c.Name, IsPrimary: c.IsPrimary, CanEdit: c.CanEdit})
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		log.Errorf("Failed to encode Google calendars: %v", err)
+		http.Error(w, "Failed to encode Google calendars", http.StatusInternalServerError)
+	}
+}
+
+// GetImportRules godoc
+// @Summary Get Google import rules
+// @Description Get the rules assigning events created in Google to budget items, in the order they are matched
+// @Tags Google
+// @Produce json
+// @Success 200 {array} ImportRuleDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/integrations/google/import-rules [get]
+// @Security XUserId
+func (h *Handler) GetImportRules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	rules, err := h.syncer.GetImportRules(r.Context())
+	if err != nil {
+		log.Errorf("Failed to get Google import rules: %v", err)
+		http.Error(w, "Failed to get Google import rules", http.StatusInternalServerError)
+		return
+	}
+	dtos := make([]ImportRuleDTO, 0, len(rules))
+	for _, rule := range rules {
+		dtos = append(dtos, ImportRuleDTO{Pattern: rule.Pattern, BudgetItemId: rule.BudgetItemId})
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		log.Errorf("Failed to encode Google import rules: %v", err)
+		http.Error(w, "Failed to encode Google import rules", http.StatusInternalServerError)
+	}
+}
+
+// StoreImportRules godoc
+// @Summary Store Google import rules
+// @Description Replace the rules assigning events created or changed in Google without a budget item. Such an
+// @Description event gets the budget item of the first rule whose pattern its summary contains.
+// @Tags Google
+// @Accept json
+// @Param rules body []ImportRuleDTO true "Import rules"
+// @Success 204 "No Content"
+// @Failure 400 {object} rest.ErrorResponse "Invalid import rules"
+// @Failure 403 {string} string "User not found"
+// @Router /api/integrations/google/import-rules [put]
+// @Security XUserId
+func (h *Handler) StoreImportRules(w http.ResponseWriter, r *http.Request) {
+	var dtos []ImportRuleDTO
+	if err := json.NewDecoder(r.Body).Decode(&dtos); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	rules := make([]ImportRule, 0, len(dtos))
+	for _, dto := range dtos {
+		rules = append(rules, ImportRule{Pattern: dto.Pattern, BudgetItemId: dto.BudgetItemId})
+	}
+	if err := h.syncer.StoreImportRules(r.Context(), rules); err != nil {
+		if errors.Is(err, ErrInvalidImportRule) {
+			rest.WriteError(w, http.StatusBadRequest, rest.ErrorResponse{Error: "Invalid import rules", Details: err.Error()})
+			return
+		}
+		log.Errorf("Failed to store Google import rules: %v", err)
+		http.Error(w, "Failed to store Google import rules", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Notify godoc
+// @Summary Google calendar change notification
+// @Description Called by Google when events of a watched calendar change. The channel token proves the
+// @Description notification comes from Google.
+// @Tags Google
+// @Param X-Goog-Channel-ID header string true "Channel id"
+// @Param X-Goog-Channel-Token header string true "Channel token"
+// @Param X-Goog-Resource-State header string true "sync or exists"
+// @Success 204 "No Content"
+// @Failure 404 "Unknown channel"
+// @Router /api/integrations/google/notifications [post]
+func (h *Handler) Notify(w http.ResponseWriter, r *http.Request) {
+	err := h.syncer.HandleNotification(r.Context(), r.Header.Get("X-Goog-Channel-ID"),
+		r.Header.Get("X-Goog-Channel-Token"), r.Header.Get("X-Goog-Resource-State"))
+	if err != nil {
+		if errors.Is(err, ErrUnknownChannel) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to handle Google notification: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
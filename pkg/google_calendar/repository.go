@@ -0,0 +1,173 @@
+package google_calendar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository interface {
+	// GetChannel returns nil when the user's calendar is not watched.
+	GetChannel(ctx context.Context, userId int) (*Channel, error)
+	// GetChannelById returns nil when no channel has the id.
+	GetChannelById(ctx context.Context, channelId string) (*Channel, error)
+	// StoreChannel creates or replaces the user's channel.
+	StoreChannel(ctx context.Context, channel Channel) error
+	UpdateSyncToken(ctx context.Context, userId int, syncToken string) error
+	DeleteChannel(ctx context.Context, userId int) error
+
+	// GetEventVersion returns the etag of the event last written or imported by Klokku, empty when unknown.
+	GetEventVersion(ctx context.Context, userId int, eventId string) (string, error)
+	StoreEventVersion(ctx context.Context, userId int, eventId string, etag string) error
+	DeleteEventVersion(ctx context.Context, userId int, eventId string) error
+
+	GetImportRules(ctx context.Context, userId int) ([]ImportRule, error)
+	// StoreImportRules replaces the user's import rules.
+	StoreImportRules(ctx context.Context, userId int, rules []ImportRule) error
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+const channelColumns = `user_id, calendar_id, channel_id, resource_id, token, expires_at, sync_token`
+
+func scanChannel(row pgx.Row) (*Channel, error) {
+	var channel Channel
+	err := row.Scan(&channel.UserId, &channel.CalendarId, &channel.ChannelId, &channel.ResourceId, &channel.Token,
+		&channel.ExpiresAt, &channel.SyncToken)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &channel, nil
+}
+
+func (r *RepositoryImpl) GetChannel(ctx context.Context, userId int) (*Channel, error) {
+	channel, err := scanChannel(r.db.QueryRow(ctx,
+		`SELECT `+channelColumns+` FROM google_calendar_channel WHERE user_id = $1`, userId))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Google channel: %w", err)
+	}
+	return channel, nil
+}
+
+func (r *RepositoryImpl) GetChannelById(ctx context.Context, channelId string) (*Channel, error) {
+	channel, err := scanChannel(r.db.QueryRow(ctx,
+		`SELECT `+channelColumns+` FROM google_calendar_channel WHERE channel_id = $1`, channelId))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Google channel: %w", err)
+	}
+	return channel, nil
+}
+
+func (r *RepositoryImpl) StoreChannel(ctx context.Context, channel Channel) error {
+	query := `INSERT INTO google_calendar_channel (` + channelColumns + `)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)
+			  ON CONFLICT (user_id) DO UPDATE SET calendar_id = EXCLUDED.calendar_id, channel_id = EXCLUDED.channel_id,
+			      resource_id = EXCLUDED.resource_id, token = EXCLUDED.token, expires_at = EXCLUDED.expires_at,
+			      sync_token = EXCLUDED.sync_token`
+	_, err := r.db.Exec(ctx, query, channel.UserId, channel.CalendarId, channel.ChannelId, channel.ResourceId,
+		channel.Token, channel.ExpiresAt, channel.SyncToken)
+	if err != nil {
+		return fmt.Errorf("failed to store Google channel: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) UpdateSyncToken(ctx context.Context, userId int, syncToken string) error {
+	_, err := r.db.Exec(ctx, `UPDATE google_calendar_channel SET sync_token = $1 WHERE user_id = $2`, syncToken, userId)
+	if err != nil {
+		return fmt.Errorf("failed to update Google sync token: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) DeleteChannel(ctx context.Context, userId int) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM google_calendar_channel WHERE user_id = $1`, userId); err != nil {
+		return fmt.Errorf("failed to delete Google channel: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) GetEventVersion(ctx context.Context, userId int, eventId string) (string, error) {
+	var etag string
+	err := r.db.QueryRow(ctx, `SELECT etag FROM google_calendar_event WHERE user_id = $1 AND event_id = $2`,
+		userId, eventId).Scan(&etag)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get Google event version: %w", err)
+	}
+	return etag, nil
+}
+
+func (r *RepositoryImpl) StoreEventVersion(ctx context.Context, userId int, eventId string, etag string) error {
+	query := `INSERT INTO google_calendar_event (user_id, event_id, etag) VALUES ($1, $2, $3)
+			  ON CONFLICT (user_id, event_id) DO UPDATE SET etag = EXCLUDED.etag`
+	if _, err := r.db.Exec(ctx, query, userId, eventId, etag); err != nil {
+		return fmt.Errorf("failed to store Google event version: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) DeleteEventVersion(ctx context.Context, userId int, eventId string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM google_calendar_event WHERE user_id = $1 AND event_id = $2`, userId, eventId)
+	if err != nil {
+		return fmt.Errorf("failed to delete Google event version: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) GetImportRules(ctx context.Context, userId int) ([]ImportRule, error) {
+	query := `SELECT pattern, budget_item_id, position
+			  FROM google_calendar_import_rule WHERE user_id = $1 ORDER BY position`
+	rows, err := r.db.Query(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Google import rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := make([]ImportRule, 0)
+	for rows.Next() {
+		var rule ImportRule
+		if err := rows.Scan(&rule.Pattern, &rule.BudgetItemId, &rule.Position); err != nil {
+			return nil, fmt.Errorf("failed to scan Google import rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get Google import rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (r *RepositoryImpl) StoreImportRules(ctx context.Context, userId int, rules []ImportRule) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, `DELETE FROM google_calendar_import_rule WHERE user_id = $1`, userId); err != nil {
+		return fmt.Errorf("failed to delete Google import rules: %w", err)
+	}
+	for _, rule := range rules {
+		query := `INSERT INTO google_calendar_import_rule (user_id, pattern, budget_item_id, position)
+				  VALUES ($1, $2, $3, $4)`
+		if _, err := tx.Exec(ctx, query, userId, rule.Pattern, rule.BudgetItemId, rule.Position); err != nil {
+			return fmt.Errorf("failed to insert Google import rule: %w", err)
+		}
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,107 @@
+package google_calendar
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+type eventKey struct {
+	userId  int
+	eventId string
+}
+
+type RepositoryStub struct {
+	mu       sync.RWMutex
+	channels map[int]Channel
+	versions map[eventKey]string
+	rules    map[int][]ImportRule
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{
+		channels: make(map[int]Channel),
+		versions: make(map[eventKey]string),
+		rules:    make(map[int][]ImportRule),
+	}
+}
+
+func (r *RepositoryStub) GetChannel(ctx context.Context, userId int) (*Channel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	channel, ok := r.channels[userId]
+	if !ok {
+		return nil, nil
+	}
+	return &channel, nil
+}
+
+func (r *RepositoryStub) GetChannelById(ctx context.Context, channelId string) (*Channel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, channel := range r.channels {
+		if channel.ChannelId == channelId {
+			return &channel, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *RepositoryStub) StoreChannel(ctx context.Context, channel Channel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.channels[channel.UserId] = channel
+	return nil
+}
+
+func (r *RepositoryStub) UpdateSyncToken(ctx context.Context, userId int, syncToken string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if channel, ok := r.channels[userId]; ok {
+		channel.SyncToken = syncToken
+		r.channels[userId] = channel
+	}
+	return nil
+}
+
+func (r *RepositoryStub) DeleteChannel(ctx context.Context, userId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.channels, userId)
+	return nil
+}
+
+func (r *RepositoryStub) GetEventVersion(ctx context.Context, userId int, eventId string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.versions[eventKey{userId, eventId}], nil
+}
+
+func (r *RepositoryStub) StoreEventVersion(ctx context.Context, userId int, eventId string, etag string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.versions[eventKey{userId, eventId}] = etag
+	return nil
+}
+
+func (r *RepositoryStub) DeleteEventVersion(ctx context.Context, userId int, eventId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.versions, eventKey{userId, eventId})
+	return nil
+}
+
+func (r *RepositoryStub) GetImportRules(ctx context.Context, userId int) ([]ImportRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append(make([]ImportRule, 0), r.rules[userId]...), nil
+}
+
+func (r *RepositoryStub) StoreImportRules(ctx context.Context, userId int, rules []ImportRule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := append([]ImportRule(nil), rules...)
+	slices.SortFunc(stored, func(a, b ImportRule) int { return a.Position - b.Position })
+	r.rules[userId] = stored
+	return nil
+}
@@ -0,0 +1,372 @@
+package google_calendar
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrInvalidImportRule = errors.New("invalid import rule")
+	ErrUnknownChannel    = errors.New("unknown notification channel")
+)
+
+const (
+	// channelTtl is the lifetime requested for notification channels, Google may shorten it
+	channelTtl = 7 * 24 * time.Hour
+	// channelRenewBefore is how long before its expiry a channel is replaced by a new one
+	channelRenewBefore = 24 * time.Hour
+)
+
+type budgetItemReader interface {
+	GetItem(ctx context.Context, id int) (budget_plan.BudgetItem, error)
+}
+
+type usersProvider interface {
+	GetUser(ctx context.Context, id int) (user.User, error)
+}
+
+// SyncResult counts the changes made in Google that were imported.
+type SyncResult struct {
+	Created int
+	Updated int
+	Deleted int
+	// Assigned events got the budget item of an import rule.
+	Assigned int
+}
+
+// Syncer imports the changes made directly in the users' Google calendars. Google notifies Klokku of changes
+// through a channel watching each calendar, the changed events are then read with the sync token of the calendar.
+// New and changed events are published like the events created in Klokku, events without a budget item get the
+// budget item of the first matching import rule.
+type Syncer struct {
+	repo            Repository
+	client          Client
+	budgetItems     budgetItemReader
+	users           usersProvider
+	eventBus        *event_bus.EventBus
+	weekLockChecker calendar.WeekLockCheckerFunc
+	notificationUrl string
+	clock           utils.Clock
+	// userLocks serializes the syncs of a user, so a change is not imported twice
+	userLocks sync.Map
+	// pending tracks the syncs started by notifications
+	pending sync.WaitGroup
+}
+
+func NewSyncer(repo Repository, client Client, budgetItems budgetItemReader, users usersProvider,
+	eventBus *event_bus.EventBus, weekLockChecker calendar.WeekLockCheckerFunc, host string) *Syncer {
+	syncer := &Syncer{
+		repo:            repo,
+		client:          client,
+		budgetItems:     budgetItems,
+		users:           users,
+		eventBus:        eventBus,
+		weekLockChecker: weekLockChecker,
+		notificationUrl: host + "/api/integrations/google/notifications",
+		clock:           &utils.SystemClock{},
+	}
+	if eventBus != nil {
+		eventBus.OnClose(syncer.flush)
+	}
+	return syncer
+}
+
+func (s *Syncer) GetImportRules(ctx context.Context) ([]ImportRule, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.GetImportRules(ctx, userId)
+}
+
+// StoreImportRules replaces the import rules of the current user, their budget items have to be the user's.
+func (s *Syncer) StoreImportRules(ctx context.Context, rules []ImportRule) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	for i, rule := range rules {
+		if strings.TrimSpace(rule.Pattern) == "" {
+			return fmt.Errorf("%w: rule %d has no pattern", ErrInvalidImportRule, i)
+		}
+		if rule.BudgetItemId <= 0 {
+			return fmt.Errorf("%w: rule %d has no budget item", ErrInvalidImportRule, i)
+		}
+		if _, err := s.budgetItems.GetItem(ctx, rule.BudgetItemId); err != nil {
+			if errors.Is(err, budget_plan.ErrBudgetPlanItemNotFound) {
+				return fmt.Errorf("%w: budget item %d of rule %d not found", ErrInvalidImportRule, rule.BudgetItemId, i)
+			}
+			return err
+		}
+		rules[i].Pattern = strings.TrimSpace(rule.Pattern)
+		rules[i].Position = i
+	}
+	return s.repo.StoreImportRules(ctx, userId, rules)
+}
+
+// RenewAndSync keeps the current user's Google calendar watched and imports the changes, in case a notification
+// got lost. It is run periodically for every user.
+func (s *Syncer) RenewAndSync(ctx context.Context, now time.Time) error {
+	if err := s.RenewChannel(ctx, now); err != nil {
+		return err
+	}
+	_, err := s.Sync(ctx)
+	return err
+}
+
+// RenewChannel opens a notification channel for the Google calendar of the current user, or replaces the channel
+// when it expires soon or watches another calendar. The channel is closed when the user no longer keeps their
+// events in Google.
+func (s *Syncer) RenewChannel(ctx context.Context, now time.Time) error {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	channel, err := s.repo.GetChannel(ctx, currentUser.Id)
+	if err != nil {
+		return err
+	}
+	if currentUser.Settings.EventCalendarType != user.GoogleCalendar {
+		if channel != nil {
+			return s.stop(ctx, *channel)
+		}
+		return nil
+	}
+	calendarId := currentUser.Settings.GoogleCalendar.CalendarId
+	if channel != nil && channel.CalendarId == calendarId && channel.ExpiresAt.After(now.Add(channelRenewBefore)) {
+		return nil
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+	watched, err := s.client.Watch(ctx, calendarId, Channel{
+		UserId:    currentUser.Id,
+		ChannelId: uuid.New().String(),
+		Token:     token,
+		ExpiresAt: now.Add(channelTtl),
+	}, s.notificationUrl)
+	if err != nil {
+		if errors.Is(err, ErrUnauthenticated) {
+			return nil
+		}
+		return err
+	}
+	if channel != nil {
+		if channel.CalendarId == calendarId {
+			watched.SyncToken = channel.SyncToken
+		}
+		if err := s.client.StopChannel(ctx, *channel); err != nil {
+			log.Warnf("failed to stop the replaced Google channel of user %d: %v", currentUser.Id, err)
+		}
+	}
+	if watched.SyncToken == "" {
+		// Changes are imported from now on, earlier events are in Google already
+		if _, watched.SyncToken, err = s.client.ListChanges(ctx, calendarId, "", now); err != nil {
+			return err
+		}
+	}
+	return s.repo.StoreChannel(ctx, watched)
+}
+
+// StopWatching closes the notification channel of the user, e.g. before they disconnect their Google account.
+func (s *Syncer) StopWatching(ctx context.Context, userId int) error {
+	channel, err := s.repo.GetChannel(ctx, userId)
+	if err != nil || channel == nil {
+		return err
+	}
+	return s.stop(ctx, *channel)
+}
+
+func (s *Syncer) stop(ctx context.Context, channel Channel) error {
+	err := s.client.StopChannel(ctx, channel)
+	if err != nil && !errors.Is(err, ErrUnauthenticated) && !errors.Is(err, ErrEventNotFound) {
+		return err
+	}
+	return s.repo.DeleteChannel(ctx, channel.UserId)
+}
+
+// HandleNotification starts the sync of the user whose channel changed. The sync runs in the background, Google
+// expects a quick response.
+func (s *Syncer) HandleNotification(ctx context.Context, channelId string, token string, resourceState string) error {
+	channel, err := s.repo.GetChannelById(ctx, channelId)
+	if err != nil {
+		return err
+	}
+	if channel == nil || subtle.ConstantTimeCompare([]byte(channel.Token), []byte(token)) != 1 {
+		return ErrUnknownChannel
+	}
+	// The first notification only confirms the channel
+	if resourceState == "sync" {
+		return nil
+	}
+	channelUser, err := s.users.GetUser(ctx, channel.UserId)
+	if err != nil {
+		return fmt.Errorf("failed to get user of Google channel: %w", err)
+	}
+	userCtx := user.WithUser(context.WithoutCancel(ctx), channelUser)
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		if _, err := s.Sync(userCtx); err != nil {
+			log.Errorf("failed to sync the Google calendar of user %d: %v", channelUser.Id, err)
+		}
+	}()
+	return nil
+}
+
+// flush waits for the syncs started by notifications.
+func (s *Syncer) flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Sync imports the changes made in the watched Google calendar of the current user since the last sync.
+func (s *Syncer) Sync(ctx context.Context) (SyncResult, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return SyncResult{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	lock, _ := s.userLocks.LoadOrStore(currentUser.Id, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	channel, err := s.repo.GetChannel(ctx, currentUser.Id)
+	if err != nil || channel == nil || currentUser.Settings.EventCalendarType != user.GoogleCalendar {
+		return SyncResult{}, err
+	}
+	changes, syncToken, err := s.client.ListChanges(ctx, channel.CalendarId, channel.SyncToken, s.clock.Now())
+	if errors.Is(err, ErrSyncTokenExpired) {
+		log.Warnf("Google sync token of user %d expired, changes since the last sync are not imported", currentUser.Id)
+		if _, syncToken, err = s.client.ListChanges(ctx, channel.CalendarId, "", s.clock.Now()); err != nil {
+			return SyncResult{}, err
+		}
+		return SyncResult{}, s.repo.UpdateSyncToken(ctx, currentUser.Id, syncToken)
+	}
+	if err != nil {
+		return SyncResult{}, err
+	}
+
+	rules, err := s.repo.GetImportRules(ctx, currentUser.Id)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	var result SyncResult
+	for _, change := range changes {
+		if err := s.importChange(ctx, currentUser.Id, channel.CalendarId, rules, change, &result); err != nil {
+			return result, err
+		}
+	}
+	if err := s.repo.UpdateSyncToken(ctx, currentUser.Id, syncToken); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func (s *Syncer) importChange(ctx context.Context, userId int, calendarId string, rules []ImportRule, change ApiEvent,
+	result *SyncResult) error {
+	known, err := s.repo.GetEventVersion(ctx, userId, change.Id)
+	if err != nil {
+		return err
+	}
+	if change.IsCancelled() {
+		// Events deleted through Klokku are forgotten right away
+		if known == "" {
+			return nil
+		}
+		if err := s.repo.DeleteEventVersion(ctx, userId, change.Id); err != nil {
+			return err
+		}
+		result.Deleted++
+		return publishDeleted(ctx, s.eventBus, change.Id)
+	}
+	// Changes made by Klokku itself come back with the version it stored
+	if change.IsAllDay() || known == change.Etag {
+		return nil
+	}
+	event, err := fromApiEvent(change)
+	if err != nil {
+		log.Warnf("ignoring Google event %s: %v", change.Id, err)
+		return nil
+	}
+	if err := s.weekLockChecker.CheckEvent(ctx, event); err != nil {
+		if !errors.Is(err, weekly_plan.ErrWeekLocked) {
+			return err
+		}
+		log.Debugf("not importing Google event %s of a locked week", change.Id)
+		return s.repo.StoreEventVersion(ctx, userId, change.Id, change.Etag)
+	}
+
+	etag := change.Etag
+	if event.Metadata.BudgetItemId == 0 {
+		for _, rule := range rules {
+			if !rule.Matches(event.Summary) {
+				continue
+			}
+			event.Metadata.BudgetItemId = rule.BudgetItemId
+			updated, err := s.assign(ctx, calendarId, event)
+			if err != nil {
+				return err
+			}
+			etag = updated.Etag
+			result.Assigned++
+			break
+		}
+	}
+	if err := s.repo.StoreEventVersion(ctx, userId, change.Id, etag); err != nil {
+		return err
+	}
+	if known == "" {
+		result.Created++
+		return publishCreated(ctx, s.eventBus, event)
+	}
+	result.Updated++
+	return publishUpdated(ctx, s.eventBus, event)
+}
+
+// assign writes the metadata of the event, with the budget item of the matching rule, back to Google.
+func (s *Syncer) assign(ctx context.Context, calendarId string, event calendar.Event) (ApiEvent, error) {
+	metadata, err := json.Marshal(event.Metadata)
+	if err != nil {
+		return ApiEvent{}, fmt.Errorf("failed to marshal event metadata: %w", err)
+	}
+	return s.client.UpdateEvent(ctx, calendarId, ApiEvent{
+		Id:                 event.UID,
+		ExtendedProperties: &ApiExtendedProperties{Private: map[string]string{metadataProperty: string(metadata)}},
+	})
+}
+
+func generateToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate channel token: %w", err)
+	}
+	return hex.EncodeToString(token), nil
+}
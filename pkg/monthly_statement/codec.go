@@ -0,0 +1,146 @@
+package monthly_statement
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/klokku/klokku/pkg/user"
+)
+
+func encodeStatement(w io.Writer, format Format, statement Statement, formatter user.Formatter) error {
+	switch format {
+	case FormatCSV:
+		return encodeCSV(w, statement, formatter)
+	case FormatPDF:
+		return encodePDF(w, statement, formatter)
+	}
+	return fmt.Errorf("unsupported format: %s", format)
+}
+
+// lastDay is the last date of the period, its end is the start of the next period.
+func lastDay(statement Statement) time.Time {
+	return statement.To.AddDate(0, 0, -1)
+}
+
+// formatHours formats the duration as decimal hours with a dot, so the column can be processed by other tools.
+func formatHours(d time.Duration) string {
+	return strconv.FormatFloat(d.Round(time.Second).Hours(), 'f', 2, 64)
+}
+
+// formatCents formats an amount in cents with a dot, e.g. 350.00, like the hours.
+func formatCents(cents int) string {
+	return strconv.FormatFloat(float64(cents)/100, 'f', 2, 64)
+}
+
+// formatRate formats the hourly rate of a line, empty when the line has no single rate.
+func formatRate(rate int, format func(cents int) string) string {
+	if rate == 0 {
+		return ""
+	}
+	return format(rate)
+}
+
+// encodeCSV writes a row per line of the statement followed by the total.
+func encodeCSV(w io.Writer, statement Statement, formatter user.Formatter) error {
+	writer := csv.NewWriter(w)
+	rows := [][]string{{string(statement.GroupBy), "events", "duration", "hours", "rate", "amount"}}
+	for _, line := range statement.Lines {
+		rows = append(rows, []string{line.Name, strconv.Itoa(line.Events), formatter.Duration(line.Duration),
+			formatHours(line.Duration), formatRate(line.Rate, formatCents), formatCents(line.Amount)})
+	}
+	rows = append(rows, []string{"Total", "", formatter.Duration(statement.Total), formatHours(statement.Total), "",
+		formatCents(statement.Amount)})
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// The DejaVu fonts cover the scripts of most languages, so the names are written as they are. They are free fonts,
+// see https://dejavu-fonts.github.io/License.html.
+var (
+	//go:embed fonts/DejaVuSansCondensed.ttf
+	regularFont []byte
+	//go:embed fonts/DejaVuSansCondensed-Bold.ttf
+	boldFont []byte
+)
+
+// Layout of the PDF pages, in millimeters of an A4 page.
+const (
+	pdfMargin     = 15
+	pdfLineHeight = 7
+	pdfFont       = "DejaVu"
+)
+
+// pdfColumns are the widths of the columns of the statement table, they fill the width between the margins.
+var pdfColumns = []float64{80, 18, 22, 20, 20, 20}
+
+// encodePDF writes the statement as a table, the header row is repeated on each page.
+func encodePDF(w io.Writer, statement Statement, formatter user.Formatter) error {
+	title := fmt.Sprintf("Statement %s - %s", statement.From.Format(time.DateOnly), lastDay(statement).Format(time.DateOnly))
+	formatAmount := func(cents int) string {
+		return formatter.Decimal(float64(cents)/100, 2)
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(pdfFont, "", regularFont)
+	pdf.AddUTF8FontFromBytes(pdfFont, "B", boldFont)
+	pdf.SetTitle(title, true)
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfMargin)
+	pdf.SetHeaderFunc(func() {
+		if pdf.PageNo() == 1 {
+			pdf.SetFont(pdfFont, "B", 16)
+			pdf.CellFormat(0, 10, title, "", 1, "L", false, 0, "")
+			pdf.Ln(4)
+		}
+		pdfRow(pdf, "B", "B", groupByTitle(statement.GroupBy), "Events", "Duration", "Hours", "Rate", "Amount")
+	})
+	pdf.AddPage()
+	for _, line := range statement.Lines {
+		pdfRow(pdf, "", "", line.Name, strconv.Itoa(line.Events), formatter.Duration(line.Duration),
+			formatter.Decimal(line.Duration.Round(time.Second).Hours(), 2), formatRate(line.Rate, formatAmount),
+			formatAmount(line.Amount))
+	}
+	pdfRow(pdf, "B", "T", "Total", "", formatter.Duration(statement.Total),
+		formatter.Decimal(statement.Total.Round(time.Second).Hours(), 2), "", formatAmount(statement.Amount))
+	return pdf.Output(w)
+}
+
+// pdfRow writes a row of the table, the first column is left aligned and shortened to fit, the others are numbers.
+func pdfRow(pdf *fpdf.Fpdf, style string, border string, values ...string) {
+	pdf.SetFont(pdfFont, style, 10)
+	for i, value := range values {
+		align := "R"
+		if i == 0 {
+			align = "L"
+			value = fitText(pdf, value, pdfColumns[i]-2*pdf.GetCellMargin())
+		}
+		pdf.CellFormat(pdfColumns[i], pdfLineHeight, value, border, 0, align, false, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+// fitText shortens the text with an ellipsis when it is wider than the width.
+func fitText(pdf *fpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+func groupByTitle(groupBy GroupBy) string {
+	if groupBy == GroupByCategory {
+		return "Category"
+	}
+	return "Budget item"
+}
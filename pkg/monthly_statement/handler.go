@@ -0,0 +1,99 @@
+package monthly_statement
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// ExportStatement godoc
+// @Summary Export a monthly statement
+// @Description Export the time tracked in a month per budget item or category, with the number of events, the duration,
+// @Description the decimal hours, the hourly rate and the billed amount of each line and the total, as CSV or PDF.
+// @Description Rates and amounts are in the user's currency, the rate is empty for a category whose items have
+// @Description different rates. The period starts on startDay of the month, at the start of the user's day, and ends
+// @Description where the next month's period starts.
+// @Description Durations are rounded according to the user's rounding settings. Sandbox events are not included.
+// @Tags Export
+// @Produce text/csv
+// @Produce application/pdf
+// @Param month query string true "Month of the statement, e.g. 2025-03"
+// @Param startDay query int false "Day of the month the period starts on, 1-28" default(1)
+// @Param groupBy query string false "What a line of the statement is" Enums(budgetItem, category) default(budgetItem)
+// @Param format query string false "File format" Enums(csv, pdf) default(csv)
+// @Success 200 {string} string "Statement"
+// @Failure 400 {object} rest.ErrorResponse
+// @Failure 403 {string} string "User not found"
+// @Router /api/export/statement [get]
+// @Security XUserId
+func (h *Handler) ExportStatement(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	month, err := time.Parse("2006-01", query.Get("month"))
+	if err != nil {
+		rest.WriteBadRequest(w, rest.InvalidField("month", "Invalid month", "'month' must be in the YYYY-MM format"))
+		return
+	}
+	request := Request{Year: month.Year(), Month: month.Month(), StartDay: 1, GroupBy: GroupByBudgetItem}
+	if value := query.Get("startDay"); value != "" {
+		startDay, err := strconv.Atoi(value)
+		if err != nil || startDay < 1 || startDay > MaxStartDay {
+			rest.WriteBadRequest(w, rest.InvalidField("startDay", "Invalid start day",
+				fmt.Sprintf("'startDay' must be between 1 and %d", MaxStartDay)))
+			return
+		}
+		request.StartDay = startDay
+	}
+	if value := query.Get("groupBy"); value != "" {
+		request.GroupBy = GroupBy(value)
+		if !request.GroupBy.IsValid() {
+			rest.WriteBadRequest(w, rest.InvalidField("groupBy", "Invalid group by",
+				"'groupBy' must be one of: budgetItem, category"))
+			return
+		}
+	}
+	format := FormatCSV
+	if value := query.Get("format"); value != "" {
+		format = Format(value)
+		if !format.IsValid() {
+			rest.WriteBadRequest(w, rest.InvalidField("format", "Invalid format", "'format' must be one of: csv, pdf"))
+			return
+		}
+	}
+	currentUser, err := user.CurrentUser(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	statement, err := h.service.BuildStatement(r.Context(), request)
+	if err != nil {
+		if errors.Is(err, ErrInvalidRequest) {
+			rest.WriteBadRequest(w, rest.InvalidField("month", "Invalid statement request", err.Error()))
+			return
+		}
+		log.Errorf("Failed to build monthly statement: %v", err)
+		http.Error(w, "Failed to build monthly statement", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"klokku-statement-%s-%s.%s\"",
+		statement.From.Format(time.DateOnly), lastDay(statement).Format(time.DateOnly), format))
+	if err := encodeStatement(w, format, statement, user.NewFormatter(currentUser.Settings)); err != nil {
+		log.Errorf("failed to export monthly statement: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package monthly_statement
+
+import (
+	"time"
+)
+
+// MaxStartDay is the last day of a month a statement period can start on, so every month has it.
+const MaxStartDay = 28
+
+type Format string
+
+const (
+	FormatCSV Format = "csv"
+	FormatPDF Format = "pdf"
+)
+
+func (f Format) IsValid() bool {
+	return f == FormatCSV || f == FormatPDF
+}
+
+func (f Format) ContentType() string {
+	if f == FormatPDF {
+		return "application/pdf"
+	}
+	return "text/csv"
+}
+
+// GroupBy tells what a line of the statement is.
+type GroupBy string
+
+const (
+	GroupByBudgetItem GroupBy = "budgetItem"
+	// GroupByCategory sums up the budget items of a category, items without a category are grouped together.
+	GroupByCategory GroupBy = "category"
+)
+
+func (g GroupBy) IsValid() bool {
+	return g == GroupByBudgetItem || g == GroupByCategory
+}
+
+// Request selects the statement of a month. The period starts on StartDay of the month, at the start of the user's
+// day, and ends where the period of the next month starts, e.g. from March 15th to April 15th.
+type Request struct {
+	Year     int
+	Month    time.Month
+	StartDay int
+	GroupBy  GroupBy
+}
+
+// Line is the time tracked on a budget item or a category in the period.
+type Line struct {
+	Name   string
+	Events int
+	// Duration is the sum of the durations of the events, each rounded according to the user's rounding settings.
+	// The parts of an event, e.g. split at midnight, are rounded as a whole.
+	Duration time.Duration
+	// Rate is the hourly rate of the line's budget items in cents, 0 when they are not billed or, for a category,
+	// when its items have different rates.
+	Rate int
+	// Amount is the billed amount in cents, the duration of each budget item times its hourly rate.
+	Amount int
+}
+
+// Statement of the tracked time of a period, the lines are sorted by duration, the longest first.
+type Statement struct {
+	From    time.Time
+	To      time.Time
+	GroupBy GroupBy
+	Lines   []Line
+	Total   time.Duration
+	// Amount is the billed amount of all lines in cents.
+	Amount int
+}
@@ -0,0 +1,254 @@
+package monthly_statement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+)
+
+var ErrInvalidRequest = errors.New("invalid statement request")
+
+// uncategorized is the line of the budget items without a category.
+const uncategorized = "Uncategorized"
+
+type Service interface {
+	// BuildStatement sums up the time tracked in the period per budget item or category. Sandbox events are not
+	// included and events crossing the period boundaries count only with their part inside the period.
+	BuildStatement(ctx context.Context, request Request) (Statement, error)
+}
+
+type calendarReader interface {
+	GetEventsIncludingArchive(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error)
+}
+
+type weeklyPlanReader interface {
+	GetPlansForRange(ctx context.Context, from time.Time, to time.Time) ([]weekly_plan.WeeklyPlan, error)
+}
+
+type budgetPlanReader interface {
+	GetPlan(ctx context.Context, planId int) (budget_plan.BudgetPlan, error)
+}
+
+type ServiceImpl struct {
+	calendar   calendarReader
+	weeklyPlan weeklyPlanReader
+	budgetPlan budgetPlanReader
+}
+
+func NewService(calendar calendarReader, weeklyPlan weeklyPlanReader, budgetPlan budgetPlanReader) *ServiceImpl {
+	return &ServiceImpl{calendar: calendar, weeklyPlan: weeklyPlan, budgetPlan: budgetPlan}
+}
+
+func (s *ServiceImpl) BuildStatement(ctx context.Context, request Request) (Statement, error) {
+	if request.Month < time.January || request.Month > time.December {
+		return Statement{}, fmt.Errorf("%w: month must be between 1 and 12", ErrInvalidRequest)
+	}
+	if request.StartDay < 1 || request.StartDay > MaxStartDay {
+		return Statement{}, fmt.Errorf("%w: start day must be between 1 and %d", ErrInvalidRequest, MaxStartDay)
+	}
+	if !request.GroupBy.IsValid() {
+		return Statement{}, fmt.Errorf("%w: group by must be one of: budgetItem, category", ErrInvalidRequest)
+	}
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return Statement{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	location, err := time.LoadLocation(currentUser.Settings.Timezone)
+	if err != nil {
+		return Statement{}, fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	startHour := currentUser.Settings.DayStartHour
+	statement := Statement{
+		From:    time.Date(request.Year, request.Month, request.StartDay, startHour, 0, 0, 0, location),
+		To:      time.Date(request.Year, request.Month+1, request.StartDay, startHour, 0, 0, 0, location),
+		GroupBy: request.GroupBy,
+	}
+
+	events, err := s.calendar.GetEventsIncludingArchive(ctx, statement.From, statement.To)
+	if err != nil {
+		return Statement{}, fmt.Errorf("failed to get events: %w", err)
+	}
+	events = calendar.WithoutSandbox(events)
+	items, err := s.periodItems(ctx, statement)
+	if err != nil {
+		return Statement{}, err
+	}
+
+	var parts []part
+	partsByEvent := make(map[string][]int)
+	for _, event := range events {
+		start, end := clip(event, statement.From, statement.To)
+		if !end.After(start) {
+			continue
+		}
+		week := weekly_plan.WeekNumberFromDate(event.StartTime, currentUser.Settings.WeekFirstDay)
+		uid := event.LogicalUID()
+		if uid == "" {
+			uid = fmt.Sprintf("#%d", len(parts))
+		}
+		partsByEvent[uid] = append(partsByEvent[uid], len(parts))
+		parts = append(parts, part{eventUid: uid, item: items.item(week, event), duration: end.Sub(start)})
+	}
+	// The parts of an event, e.g. split at midnight, are rounded as a whole
+	for _, indexes := range partsByEvent {
+		durations := make([]time.Duration, 0, len(indexes))
+		for _, i := range indexes {
+			durations = append(durations, parts[i].duration)
+		}
+		for j, duration := range currentUser.Settings.Rounding.ApplyToParts(durations) {
+			parts[indexes[j]].duration = duration
+		}
+	}
+
+	lines := make(map[lineKey]*lineBuilder)
+	var order []lineKey
+	for _, p := range parts {
+		key := p.item.line
+		line, ok := lines[key]
+		if !ok {
+			line = &lineBuilder{events: make(map[string]bool), byRate: make(map[int]time.Duration)}
+			lines[key] = line
+			order = append(order, key)
+		}
+		// A renamed item takes the name of the last week it has events in
+		line.name = p.item.name
+		// An event counts once on each line it has a part on
+		line.events[p.eventUid] = true
+		line.byRate[p.item.rate] += p.duration
+	}
+	statement.Lines = make([]Line, 0, len(lines))
+	for _, key := range order {
+		line := lines[key].build()
+		statement.Lines = append(statement.Lines, line)
+		statement.Total += line.Duration
+		statement.Amount += line.Amount
+	}
+	sort.SliceStable(statement.Lines, func(i, j int) bool {
+		if statement.Lines[i].Duration != statement.Lines[j].Duration {
+			return statement.Lines[i].Duration > statement.Lines[j].Duration
+		}
+		return statement.Lines[i].Name < statement.Lines[j].Name
+	})
+	return statement, nil
+}
+
+func clip(event calendar.Event, from time.Time, to time.Time) (time.Time, time.Time) {
+	start, end := event.StartTime, event.EndTime
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(to) {
+		end = to
+	}
+	return start, end
+}
+
+// part is the part of an event inside the period.
+type part struct {
+	eventUid string
+	item     lineItem
+	duration time.Duration
+}
+
+// lineKey identifies a line by the id of its budget item or category, 0 for the items without a category. Events
+// of budget items no longer in their week's plan have no name to take, their lines are keyed by the summary too.
+type lineKey struct {
+	id      int
+	summary string
+}
+
+// lineItem is the budget item of an event as it was in the event's week.
+type lineItem struct {
+	line lineKey
+	name string
+	rate int
+}
+
+// lineBuilder sums up the parts of the events of a line.
+type lineBuilder struct {
+	name   string
+	events map[string]bool
+	byRate map[int]time.Duration
+}
+
+// build returns the line, its amount is the sum of the amounts of each of its rates, rounded to whole cents.
+func (b *lineBuilder) build() Line {
+	line := Line{Name: b.name, Events: len(b.events)}
+	for rate, duration := range b.byRate {
+		line.Duration += duration
+		line.Amount += int(math.Round(float64(rate) * duration.Hours()))
+		if len(b.byRate) == 1 {
+			line.Rate = rate
+		}
+	}
+	return line
+}
+
+type itemKey struct {
+	week         weekly_plan.WeekNumber
+	budgetItemId int
+}
+
+// items maps the budget items of the weeks of the period to their lines.
+type items struct {
+	byItem  map[itemKey]lineItem
+	groupBy GroupBy
+}
+
+func (i items) item(week weekly_plan.WeekNumber, event calendar.Event) lineItem {
+	if item, ok := i.byItem[itemKey{week, event.Metadata.BudgetItemId}]; ok {
+		return item
+	}
+	if i.groupBy == GroupByCategory {
+		return lineItem{name: uncategorized}
+	}
+	// The item is no longer in the week's plan, the summary is the best name left
+	return lineItem{line: lineKey{id: event.Metadata.BudgetItemId, summary: event.Summary}, name: event.Summary}
+}
+
+// periodItems returns the budget items as they were in the weeks of the period, with the names and rates they have
+// in the budget plans the weeks were planned with. Grouped by category, the lines are the items' categories.
+func (s *ServiceImpl) periodItems(ctx context.Context, statement Statement) (items, error) {
+	result := items{byItem: make(map[itemKey]lineItem), groupBy: statement.GroupBy}
+	plans, err := s.weeklyPlan.GetPlansForRange(ctx, statement.From, statement.To)
+	if err != nil {
+		if errors.Is(err, weekly_plan.ErrNoCurrentPlan) {
+			return result, nil
+		}
+		return items{}, fmt.Errorf("failed to get weekly plans: %w", err)
+	}
+	budgetPlans := make(map[int]budget_plan.BudgetPlan)
+	for _, plan := range plans {
+		budgetPlan, ok := budgetPlans[plan.BudgetPlanId]
+		if !ok && plan.BudgetPlanId != 0 {
+			budgetPlan, err = s.budgetPlan.GetPlan(ctx, plan.BudgetPlanId)
+			if err != nil && !errors.Is(err, budget_plan.ErrPlanNotFound) {
+				return items{}, fmt.Errorf("failed to get budget plan: %w", err)
+			}
+			budgetPlans[plan.BudgetPlanId] = budgetPlan
+		}
+		for _, weeklyItem := range plan.Items {
+			item := lineItem{line: lineKey{id: weeklyItem.BudgetItemId}, name: weeklyItem.Name}
+			if budgetItem, ok := budgetPlan.FindItem(weeklyItem.BudgetItemId); ok {
+				item.rate = budgetItem.HourlyRate
+			}
+			if statement.GroupBy == GroupByCategory {
+				item.line, item.name = lineKey{}, uncategorized
+				categoryId := budgetPlan.ItemCategoryId(weeklyItem.BudgetItemId)
+				if category, ok := budgetPlan.FindCategory(categoryId); ok {
+					item.line, item.name = lineKey{id: categoryId}, category.Name
+				}
+			}
+			result.byItem[itemKey{plan.WeekNumber, weeklyItem.BudgetItemId}] = item
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,227 @@
+package monthly_statement
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var location, _ = time.LoadLocation("Europe/Warsaw")
+
+type weeklyPlanReaderStub struct {
+	plans []weekly_plan.WeeklyPlan
+}
+
+func (s *weeklyPlanReaderStub) GetPlansForRange(_ context.Context, _ time.Time, _ time.Time) ([]weekly_plan.WeeklyPlan, error) {
+	return s.plans, nil
+}
+
+type budgetPlanReaderStub map[int]budget_plan.BudgetPlan
+
+func (s budgetPlanReaderStub) GetPlan(_ context.Context, planId int) (budget_plan.BudgetPlan, error) {
+	plan, ok := s[planId]
+	if !ok {
+		return budget_plan.BudgetPlan{}, budget_plan.ErrPlanNotFound
+	}
+	return plan, nil
+}
+
+func setup(t *testing.T) (*ServiceImpl, context.Context) {
+	t.Helper()
+	ctx := user.WithUser(context.Background(), user.User{
+		Id:       1,
+		Settings: user.Settings{Timezone: "Europe/Warsaw", WeekFirstDay: time.Monday},
+	})
+	cal := calendar.NewStubCalendar()
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2025, month, day, hour, minute, 0, 0, location)
+	}
+	events := []calendar.Event{
+		// only the part after the midnight starting March is in the statement
+		{Summary: "Work", StartTime: at(time.February, 28, 23, 0), EndTime: at(time.March, 1, 1, 0),
+			Metadata: calendar.EventMetadata{BudgetItemId: 1}},
+		{Summary: "Work", StartTime: at(time.March, 10, 9, 0), EndTime: at(time.March, 10, 11, 30),
+			Metadata: calendar.EventMetadata{BudgetItemId: 1}},
+		{Summary: "Gym", StartTime: at(time.March, 11, 18, 0), EndTime: at(time.March, 11, 19, 0),
+			Metadata: calendar.EventMetadata{BudgetItemId: 2}},
+		{Summary: "Gym", StartTime: at(time.March, 31, 23, 0), EndTime: at(time.April, 1, 0, 30),
+			Metadata: calendar.EventMetadata{BudgetItemId: 2}},
+		{Summary: "Sandbox", StartTime: at(time.March, 12, 9, 0), EndTime: at(time.March, 12, 10, 0),
+			Metadata: calendar.EventMetadata{BudgetItemId: 1, Sandbox: true}},
+	}
+	for _, event := range events {
+		_, err := cal.AddEvent(ctx, event)
+		require.NoError(t, err)
+	}
+	weeklyPlan := &weeklyPlanReaderStub{}
+	for week := at(time.February, 24, 0, 0); week.Before(at(time.April, 21, 0, 0)); week = week.AddDate(0, 0, 7) {
+		weeklyPlan.plans = append(weeklyPlan.plans, weekly_plan.WeeklyPlan{
+			BudgetPlanId: 7,
+			WeekNumber:   weekly_plan.WeekNumberFromDate(week, time.Monday),
+			Items: []weekly_plan.WeeklyPlanItem{
+				{BudgetItemId: 1, Name: "Client work"},
+				{BudgetItemId: 2, Name: "Gym"},
+			},
+		})
+	}
+	budgetPlans := budgetPlanReaderStub{7: {
+		Id:         7,
+		Items:      []budget_plan.BudgetItem{{Id: 1, CategoryId: 3, HourlyRate: 10000}, {Id: 2}},
+		Categories: []budget_plan.Category{{Id: 3, Name: "Clients"}},
+	}}
+	return NewService(cal, weeklyPlan, budgetPlans), ctx
+}
+
+func TestServiceImpl_BuildStatement_PerBudgetItem(t *testing.T) {
+	// given
+	service, ctx := setup(t)
+
+	// when
+	statement, err := service.BuildStatement(ctx, Request{Year: 2025, Month: time.March, StartDay: 1, GroupBy: GroupByBudgetItem})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, location), statement.From)
+	assert.Equal(t, time.Date(2025, 4, 1, 0, 0, 0, 0, location), statement.To)
+	assert.Equal(t, []Line{
+		{Name: "Client work", Events: 2, Duration: 3*time.Hour + 30*time.Minute, Rate: 10000, Amount: 35000},
+		{Name: "Gym", Events: 2, Duration: 2 * time.Hour},
+	}, statement.Lines)
+	assert.Equal(t, 5*time.Hour+30*time.Minute, statement.Total)
+	assert.Equal(t, 35000, statement.Amount)
+}
+
+func TestServiceImpl_BuildStatement_PerCategory(t *testing.T) {
+	// given
+	service, ctx := setup(t)
+
+	// when
+	statement, err := service.BuildStatement(ctx, Request{Year: 2025, Month: time.March, StartDay: 1, GroupBy: GroupByCategory})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, []Line{
+		{Name: "Clients", Events: 2, Duration: 3*time.Hour + 30*time.Minute, Rate: 10000, Amount: 35000},
+		{Name: "Uncategorized", Events: 2, Duration: 2 * time.Hour},
+	}, statement.Lines)
+}
+
+func TestServiceImpl_BuildStatement_PeriodBoundariesAndRounding(t *testing.T) {
+	// given
+	service, _ := setup(t)
+	ctx := user.WithUser(context.Background(), user.User{
+		Id: 1,
+		Settings: user.Settings{Timezone: "Europe/Warsaw", WeekFirstDay: time.Monday,
+			Rounding: user.Rounding{Increment: time.Hour, Mode: user.RoundUp}},
+	})
+
+	// when
+	statement, err := service.BuildStatement(ctx, Request{Year: 2025, Month: time.March, StartDay: 15, GroupBy: GroupByBudgetItem})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 3, 15, 0, 0, 0, 0, location), statement.From)
+	assert.Equal(t, time.Date(2025, 4, 15, 0, 0, 0, 0, location), statement.To)
+	// the event split at midnight counts as one event, its 1:30 is rounded up to 2 hours as a whole
+	assert.Equal(t, []Line{{Name: "Gym", Events: 1, Duration: 2 * time.Hour}}, statement.Lines)
+}
+
+func TestServiceImpl_BuildStatement_LinesByItemId(t *testing.T) {
+	// given
+	ctx := user.WithUser(context.Background(), user.User{
+		Id: 1,
+		Settings: user.Settings{Timezone: "Europe/Warsaw", WeekFirstDay: time.Monday,
+			Rounding: user.Rounding{Increment: time.Hour, Mode: user.RoundUp}},
+	})
+	cal := calendar.NewStubCalendar()
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2025, time.March, day, hour, minute, 0, 0, location)
+	}
+	events := []calendar.Event{
+		// split at midnight into two parts of 30 minutes, rounded up to an hour as a whole
+		{Summary: "Support", StartTime: at(3, 23, 30), EndTime: at(4, 0, 30),
+			Metadata: calendar.EventMetadata{BudgetItemId: 1}},
+		{Summary: "Support", StartTime: at(5, 9, 0), EndTime: at(5, 9, 15),
+			Metadata: calendar.EventMetadata{BudgetItemId: 2}},
+	}
+	for _, event := range events {
+		_, err := cal.AddEvent(ctx, event)
+		require.NoError(t, err)
+	}
+	weeklyPlan := &weeklyPlanReaderStub{plans: []weekly_plan.WeeklyPlan{{
+		BudgetPlanId: 7,
+		WeekNumber:   weekly_plan.WeekNumberFromDate(at(3, 0, 0), time.Monday),
+		// items of two clients with the same name
+		Items: []weekly_plan.WeeklyPlanItem{{BudgetItemId: 1, Name: "Support"}, {BudgetItemId: 2, Name: "Support"}},
+	}}}
+	budgetPlans := budgetPlanReaderStub{7: {
+		Id:    7,
+		Items: []budget_plan.BudgetItem{{Id: 1, HourlyRate: 5000}, {Id: 2, HourlyRate: 8000}},
+	}}
+	service := NewService(cal, weeklyPlan, budgetPlans)
+
+	// when
+	statement, err := service.BuildStatement(ctx, Request{Year: 2025, Month: time.March, StartDay: 1, GroupBy: GroupByBudgetItem})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, []Line{
+		{Name: "Support", Events: 1, Duration: time.Hour, Rate: 5000, Amount: 5000},
+		{Name: "Support", Events: 1, Duration: time.Hour, Rate: 8000, Amount: 8000},
+	}, statement.Lines)
+	assert.Equal(t, 2*time.Hour, statement.Total)
+	assert.Equal(t, 13000, statement.Amount)
+}
+
+func TestServiceImpl_BuildStatement_InvalidRequest(t *testing.T) {
+	service, ctx := setup(t)
+
+	_, err := service.BuildStatement(ctx, Request{Year: 2025, Month: time.March, StartDay: 29, GroupBy: GroupByBudgetItem})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+	_, err = service.BuildStatement(ctx, Request{Year: 2025, Month: time.March, StartDay: 1, GroupBy: "tag"})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+}
+
+func TestEncodeStatement(t *testing.T) {
+	statement := Statement{
+		From:    time.Date(2025, 3, 1, 0, 0, 0, 0, location),
+		To:      time.Date(2025, 4, 1, 0, 0, 0, 0, location),
+		GroupBy: GroupByBudgetItem,
+		Lines: []Line{
+			{Name: "Zażółć (gęślą)", Events: 3, Duration: 90 * time.Minute, Rate: 8000, Amount: 12000},
+			{Name: "Gym", Events: 1, Duration: time.Hour},
+		},
+		Total:  150 * time.Minute,
+		Amount: 12000,
+	}
+	formatter := user.NewFormatter(user.Settings{})
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, encodeStatement(&buf, FormatCSV, statement, formatter))
+		assert.Equal(t, "budgetItem,events,duration,hours,rate,amount\n"+
+			"Zażółć (gęślą),3,1:30,1.50,80.00,120.00\n"+
+			"Gym,1,1:00,1.00,,0.00\n"+
+			"Total,,2:30,2.50,,120.00\n", buf.String())
+	})
+
+	t.Run("pdf", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, encodeStatement(&buf, FormatPDF, statement, formatter))
+		document := buf.String()
+		assert.True(t, strings.HasPrefix(document, "%PDF-"))
+		assert.True(t, strings.HasSuffix(strings.TrimSpace(document), "%%EOF"))
+		// the UTF-8 font is embedded, so names in any language are written as they are
+		assert.Contains(t, document, "/FontFile2")
+		assert.Contains(t, document, "/BaseFont /utf8dejavu")
+	})
+}
@@ -0,0 +1,141 @@
+package mqtt_bridge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/klokku/klokku/internal/rest"
+	log "github.com/sirupsen/logrus"
+)
+
+type SettingsDTO struct {
+	Enabled bool `json:"enabled"`
+	// Topic is the user's level of the MQTT topics, 1 to 64 lowercase letters, digits, '-' or '_'
+	Topic string `json:"topic"`
+	// StateTopic and CommandTopic are the full topics for the topic, empty until it is set
+	StateTopic   string `json:"stateTopic,omitempty"`
+	CommandTopic string `json:"commandTopic,omitempty"`
+	// CommandSecret must be sent as "secret" with every command, it is ignored when updating the settings
+	CommandSecret string `json:"commandSecret,omitempty"`
+	// Available tells whether the instance has an MQTT broker configured, the bridge can be enabled only then
+	Available bool `json:"available"`
+}
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// GetSettings godoc
+// @Summary Get MQTT bridge settings
+// @Tags MqttBridge
+// @Produce json
+// @Success 200 {object} SettingsDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/mqtt/settings [get]
+// @Security XUserId
+func (h *Handler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	settings, err := h.service.GetSettings(r.Context())
+	if err != nil {
+		log.Errorf("Failed to get MQTT bridge settings: %v", err)
+		http.Error(w, "Failed to get MQTT bridge settings", http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(h.settingsToDTO(settings)); err != nil {
+		log.Errorf("Failed to encode MQTT bridge settings: %v", err)
+		http.Error(w, "Failed to encode MQTT bridge settings", http.StatusInternalServerError)
+	}
+}
+
+// UpdateSettings godoc
+// @Summary Update MQTT bridge settings
+// @Description Publish the tracking state to <prefix>/<topic>/state and accept commands on <prefix>/<topic>/command,
+// @Description e.g. {"action":"start","budgetItemId":3,"secret":"<commandSecret>"}, {"action":"stop","secret":"..."}
+// @Description or {"action":"switch_back","secret":"..."}
+// @Tags MqttBridge
+// @Accept json
+// @Produce json
+// @Param settings body SettingsDTO true "Settings"
+// @Success 200 {object} SettingsDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid settings"
+// @Failure 403 {string} string "User not found"
+// @Failure 409 {object} rest.ErrorResponse "Topic taken or no MQTT broker configured"
+// @Router /api/mqtt/settings [put]
+// @Security XUserId
+func (h *Handler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	var settingsDTO SettingsDTO
+	if err := rest.DecodeJSON(r, &settingsDTO); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	updated, err := h.service.UpdateSettings(r.Context(), Settings{
+		Enabled: settingsDTO.Enabled,
+		Topic:   settingsDTO.Topic,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidSettings):
+			rest.WriteBadRequest(w, rest.InvalidField("topic", "Invalid MQTT bridge settings", err.Error()))
+		case errors.Is(err, ErrTopicTaken):
+			rest.WriteError(w, http.StatusConflict, rest.ErrorResponse{Error: "Topic taken", Details: err.Error()})
+		case errors.Is(err, ErrNotConfigured):
+			rest.WriteError(w, http.StatusConflict, rest.ErrorResponse{Error: "MQTT not available", Details: err.Error()})
+		default:
+			log.Errorf("Failed to update MQTT bridge settings: %v", err)
+			http.Error(w, "Failed to update MQTT bridge settings", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.settingsToDTO(updated)); err != nil {
+		log.Errorf("Failed to encode MQTT bridge settings: %v", err)
+		http.Error(w, "Failed to encode MQTT bridge settings", http.StatusInternalServerError)
+	}
+}
+
+// RotateSecret godoc
+// @Summary Replace the MQTT command secret
+// @Description Commands with the previous secret are rejected from then on
+// @Tags MqttBridge
+// @Produce json
+// @Success 200 {object} SettingsDTO
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {object} rest.ErrorResponse "MQTT bridge not configured"
+// @Router /api/mqtt/settings/secret [post]
+// @Security XUserId
+func (h *Handler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	updated, err := h.service.RotateCommandSecret(r.Context())
+	if err != nil {
+		if errors.Is(err, ErrSettingsNotFound) {
+			rest.WriteNotFound(w, err)
+			return
+		}
+		rest.WriteInternalError(w, fmt.Errorf("failed to rotate MQTT command secret: %w", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.settingsToDTO(updated)); err != nil {
+		log.Errorf("Failed to encode MQTT bridge settings: %v", err)
+	}
+}
+
+func (h *Handler) settingsToDTO(settings Settings) SettingsDTO {
+	dto := SettingsDTO{
+		Enabled:       settings.Enabled,
+		Topic:         settings.Topic,
+		CommandSecret: settings.CommandSecret,
+		Available:     h.service.Available(),
+	}
+	if settings.Topic != "" {
+		dto.StateTopic = h.service.StateTopic(settings.Topic)
+		dto.CommandTopic = h.service.CommandTopic(settings.Topic)
+	}
+	return dto
+}
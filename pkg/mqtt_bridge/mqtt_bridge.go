@@ -0,0 +1,52 @@
+package mqtt_bridge
+
+import (
+	"regexp"
+	"time"
+)
+
+// topicPattern keeps the user's topic a single level without the MQTT wildcards.
+var topicPattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+// Settings of publishing the user's tracking state to the MQTT broker.
+type Settings struct {
+	UserId  int
+	Enabled bool
+	// Topic is the user's level of the state and command topics, e.g. klokku/<topic>/state.
+	Topic string
+	// CommandSecret must be sent with every command, so only those given it can control the user's tracking. It is
+	// generated when the settings are first saved.
+	CommandSecret string
+}
+
+// State is published, retained, to the state topic of the user whenever tracking starts or stops and every minute
+// while the bridge is enabled.
+type State struct {
+	Tracking     bool       `json:"tracking"`
+	BudgetItemId int        `json:"budgetItemId,omitempty"`
+	Name         string     `json:"name,omitempty"`
+	StartTime    *time.Time `json:"startTime,omitempty"`
+	// Remaining is the time left of the tracked item's weekly budget in seconds, negative when it is exceeded.
+	Remaining int `json:"remaining"`
+	// WeekRemaining is the time left of the whole weekly plan in seconds.
+	WeekRemaining int       `json:"weekRemaining"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+type CommandAction string
+
+const (
+	CommandStart      CommandAction = "start"
+	CommandStop       CommandAction = "stop"
+	CommandSwitchBack CommandAction = "switch_back"
+)
+
+// Command is published to the command topic of a user to control tracking, e.g.
+// {"action":"start","budgetItemId":3,"secret":"..."}.
+type Command struct {
+	Action CommandAction `json:"action"`
+	// Secret is the CommandSecret of the user, commands without it are rejected.
+	Secret string `json:"secret"`
+	// BudgetItemId is the item to start tracking, only used by the start action.
+	BudgetItemId int `json:"budgetItemId"`
+}
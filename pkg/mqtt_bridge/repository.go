@@ -0,0 +1,110 @@
+package mqtt_bridge
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrSettingsNotFound = errors.New("MQTT bridge settings not found")
+
+type Repository interface {
+	// GetSettings returns ErrSettingsNotFound when the user has not configured the bridge.
+	GetSettings(ctx context.Context, userId int) (Settings, error)
+	// SaveSettings generates the command secret when it is empty and returns ErrTopicTaken when another user has the
+	// topic.
+	SaveSettings(ctx context.Context, settings Settings) (Settings, error)
+	// FindByTopic returns ErrSettingsNotFound when no user has the topic.
+	FindByTopic(ctx context.Context, topic string) (Settings, error)
+	ListEnabled(ctx context.Context) ([]Settings, error)
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+func (r *RepositoryImpl) GetSettings(ctx context.Context, userId int) (Settings, error) {
+	query := `SELECT user_id, enabled, topic, command_secret FROM mqtt_bridge_settings WHERE user_id = $1`
+	return r.getSettings(ctx, query, userId)
+}
+
+func (r *RepositoryImpl) FindByTopic(ctx context.Context, topic string) (Settings, error) {
+	query := `SELECT user_id, enabled, topic, command_secret FROM mqtt_bridge_settings WHERE topic = $1`
+	return r.getSettings(ctx, query, topic)
+}
+
+func (r *RepositoryImpl) getSettings(ctx context.Context, query string, arg any) (Settings, error) {
+	var settings Settings
+	if err := r.db.QueryRow(ctx, query, arg).Scan(&settings.UserId, &settings.Enabled, &settings.Topic,
+		&settings.CommandSecret); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Settings{}, ErrSettingsNotFound
+		}
+		return Settings{}, fmt.Errorf("failed to get MQTT bridge settings: %w", err)
+	}
+	return settings, nil
+}
+
+func (r *RepositoryImpl) SaveSettings(ctx context.Context, settings Settings) (Settings, error) {
+	if settings.CommandSecret == "" {
+		secret, err := generateToken()
+		if err != nil {
+			return Settings{}, fmt.Errorf("failed to generate MQTT command secret: %w", err)
+		}
+		settings.CommandSecret = secret
+	}
+	query := `INSERT INTO mqtt_bridge_settings (user_id, enabled, topic, command_secret) VALUES ($1, $2, $3, $4)
+			  ON CONFLICT (user_id) DO UPDATE SET enabled = EXCLUDED.enabled, topic = EXCLUDED.topic,
+			  command_secret = EXCLUDED.command_secret
+			  RETURNING user_id, enabled, topic, command_secret`
+	var saved Settings
+	if err := r.db.QueryRow(ctx, query, settings.UserId, settings.Enabled, settings.Topic, settings.CommandSecret).
+		Scan(&saved.UserId, &saved.Enabled, &saved.Topic, &saved.CommandSecret); err != nil {
+		// The topic was taken by another user since the service checked it
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return Settings{}, ErrTopicTaken
+		}
+		return Settings{}, fmt.Errorf("failed to save MQTT bridge settings: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *RepositoryImpl) ListEnabled(ctx context.Context) ([]Settings, error) {
+	rows, err := r.db.Query(ctx, `SELECT user_id, enabled, topic, command_secret FROM mqtt_bridge_settings WHERE enabled ORDER BY user_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MQTT bridge settings: %w", err)
+	}
+	defer rows.Close()
+
+	settings := make([]Settings, 0)
+	for rows.Next() {
+		var s Settings
+		if err := rows.Scan(&s.UserId, &s.Enabled, &s.Topic, &s.CommandSecret); err != nil {
+			return nil, fmt.Errorf("failed to scan MQTT bridge settings: %w", err)
+		}
+		settings = append(settings, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list MQTT bridge settings: %w", err)
+	}
+	return settings, nil
+}
+
+func generateToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	_, err := rand.Read(tokenBytes)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", tokenBytes), nil
+}
@@ -0,0 +1,69 @@
+package mqtt_bridge
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+type RepositoryStub struct {
+	mu       sync.RWMutex
+	settings map[int]Settings
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{settings: make(map[int]Settings)}
+}
+
+func (r *RepositoryStub) GetSettings(ctx context.Context, userId int) (Settings, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	settings, ok := r.settings[userId]
+	if !ok {
+		return Settings{}, ErrSettingsNotFound
+	}
+	return settings, nil
+}
+
+func (r *RepositoryStub) SaveSettings(ctx context.Context, settings Settings) (Settings, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, other := range r.settings {
+		if other.Topic == settings.Topic && other.UserId != settings.UserId {
+			return Settings{}, ErrTopicTaken
+		}
+	}
+	if settings.CommandSecret == "" {
+		secret, err := generateToken()
+		if err != nil {
+			return Settings{}, err
+		}
+		settings.CommandSecret = secret
+	}
+	r.settings[settings.UserId] = settings
+	return settings, nil
+}
+
+func (r *RepositoryStub) FindByTopic(ctx context.Context, topic string) (Settings, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, settings := range r.settings {
+		if settings.Topic == topic {
+			return settings, nil
+		}
+	}
+	return Settings{}, ErrSettingsNotFound
+}
+
+func (r *RepositoryStub) ListEnabled(ctx context.Context) ([]Settings, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	enabled := make([]Settings, 0)
+	for _, settings := range r.settings {
+		if settings.Enabled {
+			enabled = append(enabled, settings)
+		}
+	}
+	slices.SortFunc(enabled, func(a, b Settings) int { return a.UserId - b.UserId })
+	return enabled, nil
+}
@@ -0,0 +1,468 @@
+package mqtt_bridge
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/mqtt"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/current_event"
+	"github.com/klokku/klokku/pkg/stats"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrInvalidSettings = errors.New("invalid MQTT bridge settings")
+	ErrTopicTaken      = errors.New("MQTT topic is used by another user")
+	ErrNotConfigured   = errors.New("no MQTT broker configured")
+	ErrInvalidCommand  = errors.New("invalid MQTT command")
+)
+
+type Service interface {
+	GetSettings(ctx context.Context) (Settings, error)
+	// UpdateSettings saves the settings and publishes the state of the user right away when the bridge is enabled.
+	UpdateSettings(ctx context.Context, settings Settings) (Settings, error)
+	// RotateCommandSecret replaces the command secret of the user, the previous one is rejected from then on. It
+	// returns ErrSettingsNotFound when the user has not configured the bridge.
+	RotateCommandSecret(ctx context.Context) (Settings, error)
+	// PublishStates publishes the state of every user with the bridge enabled, so the remaining time stays current
+	// while tracking.
+	PublishStates(ctx context.Context) error
+	// Available tells whether a broker is configured, the bridge cannot be enabled without one.
+	Available() bool
+	StateTopic(topic string) string
+	CommandTopic(topic string) string
+}
+
+// Publisher is the connection to the MQTT broker.
+type Publisher interface {
+	Publish(topic string, payload []byte, retain bool) error
+	Subscribe(filter string, handler mqtt.Handler) error
+	OnConnect(f func())
+}
+
+type eventTracker interface {
+	FindCurrentEvent(ctx context.Context) (current_event.CurrentEvent, error)
+	StartNewEvent(ctx context.Context, event current_event.CurrentEvent) (current_event.CurrentEvent, error)
+	StopCurrentEvent(ctx context.Context) (current_event.CurrentEvent, error)
+	SwitchBack(ctx context.Context) (current_event.CurrentEvent, error)
+}
+
+type budgetItemProvider interface {
+	GetItem(ctx context.Context, id int) (budget_plan.BudgetItem, error)
+}
+
+type usersProvider interface {
+	GetUser(ctx context.Context, id int) (user.User, error)
+}
+
+type weeklyStatsProvider interface {
+	GetWeeklyStats(ctx context.Context, weekTime time.Time, includeSandbox bool) (stats.WeeklyStatsSummary, error)
+}
+
+type ServiceImpl struct {
+	repo        Repository
+	publisher   Publisher
+	events      eventTracker
+	budgetItems budgetItemProvider
+	users       usersProvider
+	stats       weeklyStatsProvider
+	clock       utils.Clock
+	cfg         config.Mqtt
+	// pending tracks the states published and commands run in the background
+	pending sync.WaitGroup
+}
+
+// NewService creates the bridge publishing to the publisher, which is nil when no broker is configured. The bridge
+// can then not be enabled.
+func NewService(
+	repo Repository,
+	publisher Publisher,
+	events eventTracker,
+	budgetItems budgetItemProvider,
+	users usersProvider,
+	stats weeklyStatsProvider,
+	eventBus *event_bus.EventBus,
+	cfg config.Mqtt,
+) *ServiceImpl {
+	service := &ServiceImpl{
+		repo:        repo,
+		publisher:   publisher,
+		events:      events,
+		budgetItems: budgetItems,
+		users:       users,
+		stats:       stats,
+		clock:       &utils.SystemClock{},
+		cfg:         cfg,
+	}
+	if publisher == nil {
+		return service
+	}
+	service.subscribe(eventBus)
+	eventBus.OnClose(service.flush)
+	if err := publisher.Subscribe(commandFilter(cfg), service.handleCommand); err != nil {
+		log.Errorf("failed to subscribe to MQTT commands: %v", err)
+	}
+	publisher.OnConnect(func() {
+		if err := publisher.Publish(StatusTopic(cfg.TopicPrefix), []byte(StatusOnline), true); err != nil {
+			log.Errorf("failed to publish MQTT availability: %v", err)
+		}
+		service.inBackground(context.Background(), service.PublishStates)
+	})
+	return service
+}
+
+const (
+	StatusOnline  = "online"
+	StatusOffline = "offline"
+)
+
+// commandFilter subscribes to the command topics of all users. With a shared group, the broker delivers each command
+// to one of the instances of Klokku only, so it is not run once per instance.
+func commandFilter(cfg config.Mqtt) string {
+	filter := cfg.TopicPrefix + "/+/command"
+	if cfg.SharedGroup == "" {
+		return filter
+	}
+	return "$share/" + cfg.SharedGroup + "/" + filter
+}
+
+// StatusTopic is where the availability of Klokku is published, StatusOffline is its will.
+func StatusTopic(prefix string) string {
+	return prefix + "/status"
+}
+
+func (s *ServiceImpl) Available() bool {
+	return s.publisher != nil
+}
+
+func (s *ServiceImpl) StateTopic(topic string) string {
+	return fmt.Sprintf("%s/%s/state", s.cfg.TopicPrefix, topic)
+}
+
+func (s *ServiceImpl) CommandTopic(topic string) string {
+	return fmt.Sprintf("%s/%s/command", s.cfg.TopicPrefix, topic)
+}
+
+func (s *ServiceImpl) discoveryTopic(topic string) string {
+	return fmt.Sprintf("%s/sensor/klokku_%s/config", s.cfg.DiscoveryPrefix, topic)
+}
+
+func (s *ServiceImpl) subscribe(eventBus *event_bus.EventBus) {
+	if eventBus == nil {
+		return
+	}
+	event_bus.SubscribeTyped[event_bus.CurrentEventStarted](
+		eventBus,
+		"current_event.started",
+		func(e event_bus.EventT[event_bus.CurrentEventStarted]) error {
+			s.inBackground(e.Context(), s.publishCurrentUserState)
+			return nil
+		},
+	)
+	event_bus.SubscribeTyped[event_bus.CurrentEventStopped](
+		eventBus,
+		"current_event.stopped",
+		func(e event_bus.EventT[event_bus.CurrentEventStopped]) error {
+			s.inBackground(e.Context(), s.publishCurrentUserState)
+			return nil
+		},
+	)
+}
+
+// inBackground runs f without holding up the caller, e.g. the request starting an event or the MQTT connection.
+func (s *ServiceImpl) inBackground(ctx context.Context, f func(ctx context.Context) error) {
+	ctx = context.WithoutCancel(ctx)
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		if err := f(ctx); err != nil {
+			log.Errorf("MQTT bridge: %v", err)
+		}
+	}()
+}
+
+// flush waits for the work done in the background.
+func (s *ServiceImpl) flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("MQTT bridge still publishing: %w", ctx.Err())
+	}
+}
+
+func (s *ServiceImpl) GetSettings(ctx context.Context) (Settings, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Settings{}, fmt.Errorf("failed to get current user id: %w", err)
+	}
+	settings, err := s.repo.GetSettings(ctx, userId)
+	if errors.Is(err, ErrSettingsNotFound) {
+		return Settings{UserId: userId}, nil
+	}
+	return settings, err
+}
+
+func (s *ServiceImpl) UpdateSettings(ctx context.Context, settings Settings) (Settings, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Settings{}, fmt.Errorf("failed to get current user id: %w", err)
+	}
+	settings.UserId = userId
+	if settings.Enabled && s.publisher == nil {
+		return Settings{}, ErrNotConfigured
+	}
+	if !topicPattern.MatchString(settings.Topic) {
+		return Settings{}, fmt.Errorf("%w: topic must be 1 to 64 lowercase letters, digits, '-' or '_'", ErrInvalidSettings)
+	}
+	owner, err := s.repo.FindByTopic(ctx, settings.Topic)
+	if err == nil && owner.UserId != userId {
+		return Settings{}, ErrTopicTaken
+	}
+	if err != nil && !errors.Is(err, ErrSettingsNotFound) {
+		return Settings{}, err
+	}
+	previous, err := s.GetSettings(ctx)
+	if err != nil {
+		return Settings{}, err
+	}
+	settings.CommandSecret = previous.CommandSecret
+
+	saved, err := s.repo.SaveSettings(ctx, settings)
+	if err != nil {
+		return Settings{}, err
+	}
+	if previous.Enabled && (!saved.Enabled || previous.Topic != saved.Topic) {
+		s.clear(previous.Topic)
+	}
+	if saved.Enabled {
+		s.publishDiscovery(saved.Topic)
+		if err := s.publishState(ctx, saved.Topic); err != nil {
+			log.Errorf("failed to publish MQTT state of user %d: %v", userId, err)
+		}
+	}
+	return saved, nil
+}
+
+func (s *ServiceImpl) RotateCommandSecret(ctx context.Context) (Settings, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Settings{}, fmt.Errorf("failed to get current user id: %w", err)
+	}
+	settings, err := s.repo.GetSettings(ctx, userId)
+	if err != nil {
+		return Settings{}, err
+	}
+	settings.CommandSecret = ""
+	return s.repo.SaveSettings(ctx, settings)
+}
+
+func (s *ServiceImpl) PublishStates(ctx context.Context) error {
+	if s.publisher == nil {
+		return nil
+	}
+	enabled, err := s.repo.ListEnabled(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, settings := range enabled {
+		u, err := s.users.GetUser(ctx, settings.UserId)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("user %d: %w", settings.UserId, err))
+			continue
+		}
+		if u.Disabled {
+			continue
+		}
+		if err := s.publishState(user.WithUser(ctx, u), settings.Topic); err != nil {
+			if errors.Is(err, mqtt.ErrNotConnected) {
+				return err
+			}
+			errs = append(errs, fmt.Errorf("user %d: %w", settings.UserId, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *ServiceImpl) publishCurrentUserState(ctx context.Context) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user id: %w", err)
+	}
+	settings, err := s.repo.GetSettings(ctx, userId)
+	if errors.Is(err, ErrSettingsNotFound) || (err == nil && !settings.Enabled) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.publishState(ctx, settings.Topic)
+}
+
+func (s *ServiceImpl) publishState(ctx context.Context, topic string) error {
+	state, err := s.currentState(ctx)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode MQTT state: %w", err)
+	}
+	return s.publisher.Publish(s.StateTopic(topic), payload, true)
+}
+
+// currentState is the event tracked by the current user with the time left of its budget and of the week.
+func (s *ServiceImpl) currentState(ctx context.Context) (State, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return State{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	location, err := time.LoadLocation(currentUser.Settings.Timezone)
+	if err != nil {
+		return State{}, fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	now := s.clock.Now()
+	event, err := s.events.FindCurrentEvent(ctx)
+	if err != nil {
+		return State{}, fmt.Errorf("failed to get current event: %w", err)
+	}
+	summary, err := s.stats.GetWeeklyStats(ctx, now.In(location), false)
+	if err != nil {
+		return State{}, fmt.Errorf("failed to get weekly stats: %w", err)
+	}
+
+	state := State{WeekRemaining: int(summary.TotalRemaining.Seconds()), UpdatedAt: now}
+	if event.Id == 0 {
+		return state, nil
+	}
+	startTime := event.StartTime
+	state.Tracking = true
+	state.BudgetItemId = event.PlanItem.BudgetItemId
+	state.Name = event.PlanItem.Name
+	state.StartTime = &startTime
+	for _, item := range summary.PerPlanItem {
+		if item.PlanItem.BudgetItemId == event.PlanItem.BudgetItemId {
+			state.Remaining = int(item.Remaining.Seconds())
+			break
+		}
+	}
+	return state, nil
+}
+
+// publishDiscovery announces the state as a sensor to Home Assistant, with the tracked item as its value.
+func (s *ServiceImpl) publishDiscovery(topic string) {
+	if s.cfg.DiscoveryPrefix == "" {
+		return
+	}
+	discovery := map[string]any{
+		"name":                  "Klokku " + topic,
+		"unique_id":             "klokku_" + topic,
+		"icon":                  "mdi:timer-outline",
+		"state_topic":           s.StateTopic(topic),
+		"value_template":        "{{ value_json.name if value_json.tracking else 'Idle' }}",
+		"json_attributes_topic": s.StateTopic(topic),
+		"availability_topic":    StatusTopic(s.cfg.TopicPrefix),
+	}
+	payload, _ := json.Marshal(discovery)
+	if err := s.publisher.Publish(s.discoveryTopic(topic), payload, true); err != nil {
+		log.Errorf("failed to publish MQTT discovery of topic %s: %v", topic, err)
+	}
+}
+
+// clear removes the retained state and discovery of a topic no longer used.
+func (s *ServiceImpl) clear(topic string) {
+	topics := []string{s.StateTopic(topic)}
+	if s.cfg.DiscoveryPrefix != "" {
+		topics = append(topics, s.discoveryTopic(topic))
+	}
+	for _, t := range topics {
+		if err := s.publisher.Publish(t, nil, true); err != nil {
+			log.Errorf("failed to clear MQTT topic %s: %v", t, err)
+		}
+	}
+}
+
+func (s *ServiceImpl) handleCommand(topic string, payload []byte) {
+	userTopic := strings.TrimSuffix(strings.TrimPrefix(topic, s.cfg.TopicPrefix+"/"), "/command")
+	s.inBackground(context.Background(), func(ctx context.Context) error {
+		return s.runCommand(ctx, userTopic, payload)
+	})
+}
+
+// runCommand controls the tracking of the user publishing to the command topic.
+func (s *ServiceImpl) runCommand(ctx context.Context, topic string, payload []byte) error {
+	settings, err := s.repo.FindByTopic(ctx, topic)
+	if errors.Is(err, ErrSettingsNotFound) || (err == nil && !settings.Enabled) {
+		log.Debugf("Ignoring MQTT command to unknown topic %s", topic)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var command Command
+	if err := json.Unmarshal(payload, &command); err != nil {
+		return fmt.Errorf("%w on %s: %v", ErrInvalidCommand, s.CommandTopic(topic), err)
+	}
+	if subtle.ConstantTimeCompare([]byte(command.Secret), []byte(settings.CommandSecret)) != 1 {
+		return fmt.Errorf("%w on %s: wrong secret", ErrInvalidCommand, s.CommandTopic(topic))
+	}
+	u, err := s.users.GetUser(ctx, settings.UserId)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if u.Disabled {
+		return user.ErrUserDisabled
+	}
+	ctx = user.WithUser(ctx, u)
+
+	switch command.Action {
+	case CommandStart:
+		budgetItem, err := s.budgetItems.GetItem(ctx, command.BudgetItemId)
+		if err != nil {
+			return fmt.Errorf("failed to get budget item: %w", err)
+		}
+		_, err = s.events.StartNewEvent(ctx, current_event.CurrentEvent{
+			StartTime: s.clock.Now(),
+			PlanItem: current_event.PlanItem{
+				BudgetItemId:   budgetItem.Id,
+				Name:           budgetItem.Name,
+				WeeklyDuration: budgetItem.WeeklyDuration,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start event: %w", err)
+		}
+	case CommandStop:
+		if _, err := s.events.StopCurrentEvent(ctx); err != nil {
+			if errors.Is(err, current_event.ErrNoCurrentEvent) {
+				return nil
+			}
+			return fmt.Errorf("failed to stop event: %w", err)
+		}
+	case CommandSwitchBack:
+		if _, err := s.events.SwitchBack(ctx); err != nil {
+			return fmt.Errorf("failed to switch back: %w", err)
+		}
+	default:
+		return fmt.Errorf("%w on %s: unknown action %q", ErrInvalidCommand, s.CommandTopic(topic), command.Action)
+	}
+	log.Infof("MQTT command %s run for user %d", command.Action, u.Id)
+	return nil
+}
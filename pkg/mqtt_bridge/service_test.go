@@ -0,0 +1,420 @@
+package mqtt_bridge
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/mqtt"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/current_event"
+	"github.com/klokku/klokku/pkg/stats"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2025, 6, 9, 9, 0, 0, 0, time.UTC)
+
+var testUser = user.User{
+	Id:       1,
+	Uid:      "user-1",
+	Username: "test-user-1",
+	Settings: user.Settings{Timezone: "UTC", WeekFirstDay: time.Monday},
+}
+
+var otherUser = user.User{
+	Id:       2,
+	Uid:      "user-2",
+	Username: "test-user-2",
+	Settings: user.Settings{Timezone: "UTC", WeekFirstDay: time.Monday},
+}
+
+type message struct {
+	Payload string
+	Retain  bool
+}
+
+// publisherStub records the last message of each topic.
+type publisherStub struct {
+	mu       sync.Mutex
+	messages map[string]message
+	filter   string
+	handler  mqtt.Handler
+}
+
+func (p *publisherStub) Publish(topic string, payload []byte, retain bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages[topic] = message{Payload: string(payload), Retain: retain}
+	return nil
+}
+
+func (p *publisherStub) Subscribe(filter string, handler mqtt.Handler) error {
+	p.filter = filter
+	p.handler = handler
+	return nil
+}
+
+func (p *publisherStub) OnConnect(f func()) {}
+
+func (p *publisherStub) message(topic string) (message, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m, ok := p.messages[topic]
+	return m, ok
+}
+
+type eventsStub struct {
+	mu      sync.Mutex
+	current current_event.CurrentEvent
+	stopped bool
+}
+
+func (e *eventsStub) FindCurrentEvent(ctx context.Context) (current_event.CurrentEvent, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.current, nil
+}
+
+func (e *eventsStub) StartNewEvent(ctx context.Context, event current_event.CurrentEvent) (current_event.CurrentEvent, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	event.Id = 1
+	e.current = event
+	return event, nil
+}
+
+func (e *eventsStub) StopCurrentEvent(ctx context.Context) (current_event.CurrentEvent, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.current.Id == 0 {
+		return current_event.CurrentEvent{}, current_event.ErrNoCurrentEvent
+	}
+	stopped := e.current
+	e.current = current_event.CurrentEvent{}
+	e.stopped = true
+	return stopped, nil
+}
+
+func (e *eventsStub) SwitchBack(ctx context.Context) (current_event.CurrentEvent, error) {
+	return current_event.CurrentEvent{}, current_event.ErrNoPreviousEvent
+}
+
+type budgetItemsStub map[int]budget_plan.BudgetItem
+
+func (b budgetItemsStub) GetItem(ctx context.Context, id int) (budget_plan.BudgetItem, error) {
+	return b[id], nil
+}
+
+type usersStub map[int]user.User
+
+func (u usersStub) GetUser(ctx context.Context, id int) (user.User, error) {
+	return u[id], nil
+}
+
+type statsStub struct {
+	summary stats.WeeklyStatsSummary
+}
+
+func (s *statsStub) GetWeeklyStats(ctx context.Context, weekTime time.Time, includeSandbox bool) (stats.WeeklyStatsSummary, error) {
+	return s.summary, nil
+}
+
+type testEnv struct {
+	service   *ServiceImpl
+	repo      *RepositoryStub
+	publisher *publisherStub
+	events    *eventsStub
+	eventBus  *event_bus.EventBus
+	ctx       context.Context
+}
+
+var testConfig = config.Mqtt{TopicPrefix: "klokku", DiscoveryPrefix: "homeassistant", SharedGroup: "klokku"}
+
+func setupServiceTest(t *testing.T) testEnv {
+	t.Helper()
+	repo := NewRepositoryStub()
+	publisher := &publisherStub{messages: make(map[string]message)}
+	events := &eventsStub{}
+	eventBus := event_bus.NewEventBus()
+	budgetItems := budgetItemsStub{10: {Id: 10, Name: "Reading", WeeklyDuration: 2 * time.Hour}}
+	statsProvider := &statsStub{summary: stats.WeeklyStatsSummary{
+		PerPlanItem: []stats.PlanItemStats{
+			{PlanItem: stats.PlanItem{BudgetItemId: 10, Name: "Reading"}, Remaining: 90 * time.Minute},
+		},
+		TotalRemaining: 10 * time.Hour,
+	}}
+	service := NewService(repo, publisher, events, budgetItems, usersStub{testUser.Id: testUser, otherUser.Id: otherUser},
+		statsProvider, eventBus, testConfig)
+	service.clock = &utils.MockClock{FixedNow: now}
+	return testEnv{
+		service:   service,
+		repo:      repo,
+		publisher: publisher,
+		events:    events,
+		eventBus:  eventBus,
+		ctx:       user.WithUser(context.Background(), testUser),
+	}
+}
+
+func (env testEnv) state(t *testing.T, topic string) State {
+	t.Helper()
+	m, ok := env.publisher.message("klokku/" + topic + "/state")
+	require.True(t, ok, "no state published to %s", topic)
+	assert.True(t, m.Retain)
+	var state State
+	require.NoError(t, json.Unmarshal([]byte(m.Payload), &state))
+	return state
+}
+
+func TestUpdateSettings_PublishesDiscoveryAndState(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+
+	// when
+	saved, err := env.service.UpdateSettings(env.ctx, Settings{Enabled: true, Topic: "jane"})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, Settings{UserId: testUser.Id, Enabled: true, Topic: "jane", CommandSecret: saved.CommandSecret}, saved)
+	assert.Len(t, saved.CommandSecret, 64)
+	assert.Equal(t, State{WeekRemaining: 36000, UpdatedAt: now}, env.state(t, "jane"))
+	discovery, ok := env.publisher.message("homeassistant/sensor/klokku_jane/config")
+	require.True(t, ok)
+	assert.Contains(t, discovery.Payload, `"state_topic":"klokku/jane/state"`)
+	assert.Equal(t, "$share/klokku/klokku/+/command", env.publisher.filter)
+}
+
+func TestUpdateSettings_KeepsCommandSecret(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	first, err := env.service.UpdateSettings(env.ctx, Settings{Enabled: true, Topic: "jane"})
+	require.NoError(t, err)
+
+	// when
+	second, err := env.service.UpdateSettings(env.ctx, Settings{Enabled: true, Topic: "janet"})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, first.CommandSecret, second.CommandSecret)
+}
+
+func TestRotateCommandSecret(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	_, err := env.service.RotateCommandSecret(env.ctx)
+	assert.ErrorIs(t, err, ErrSettingsNotFound)
+	saved, err := env.service.UpdateSettings(env.ctx, Settings{Enabled: true, Topic: "jane"})
+	require.NoError(t, err)
+
+	// when
+	rotated, err := env.service.RotateCommandSecret(env.ctx)
+
+	// then
+	require.NoError(t, err)
+	assert.NotEqual(t, saved.CommandSecret, rotated.CommandSecret)
+	assert.Equal(t, "jane", rotated.Topic)
+	assert.True(t, rotated.Enabled)
+}
+
+// racingRepository misses the topic taken by another user in the check, as when both save it at the same time.
+type racingRepository struct {
+	*RepositoryStub
+}
+
+func (r racingRepository) FindByTopic(ctx context.Context, topic string) (Settings, error) {
+	return Settings{}, ErrSettingsNotFound
+}
+
+func TestUpdateSettings_TopicTakenMeanwhile(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	_, err := env.repo.SaveSettings(env.ctx, Settings{UserId: otherUser.Id, Enabled: true, Topic: "john"})
+	require.NoError(t, err)
+	env.service.repo = racingRepository{env.repo}
+
+	// when
+	_, err = env.service.UpdateSettings(env.ctx, Settings{Enabled: true, Topic: "john"})
+
+	// then
+	assert.ErrorIs(t, err, ErrTopicTaken)
+}
+
+func TestUpdateSettings_Validation(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	_, err := env.repo.SaveSettings(env.ctx, Settings{UserId: otherUser.Id, Enabled: true, Topic: "john"})
+	require.NoError(t, err)
+
+	for name, tt := range map[string]struct {
+		topic string
+		err   error
+	}{
+		"empty topic":    {topic: "", err: ErrInvalidSettings},
+		"wildcard topic": {topic: "jane/#", err: ErrInvalidSettings},
+		"uppercase":      {topic: "Jane", err: ErrInvalidSettings},
+		"taken topic":    {topic: "john", err: ErrTopicTaken},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// when
+			_, err := env.service.UpdateSettings(env.ctx, Settings{Enabled: true, Topic: tt.topic})
+
+			// then
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestUpdateSettings_NotConfigured(t *testing.T) {
+	// given
+	service := NewService(NewRepositoryStub(), nil, &eventsStub{}, budgetItemsStub{}, usersStub{}, &statsStub{},
+		event_bus.NewEventBus(), testConfig)
+
+	// when
+	_, err := service.UpdateSettings(user.WithUser(context.Background(), testUser), Settings{Enabled: true, Topic: "jane"})
+
+	// then
+	assert.ErrorIs(t, err, ErrNotConfigured)
+	assert.False(t, service.Available())
+}
+
+func TestUpdateSettings_DisablingClearsRetainedMessages(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	_, err := env.service.UpdateSettings(env.ctx, Settings{Enabled: true, Topic: "jane"})
+	require.NoError(t, err)
+
+	// when
+	_, err = env.service.UpdateSettings(env.ctx, Settings{Enabled: false, Topic: "jane"})
+
+	// then
+	require.NoError(t, err)
+	state, _ := env.publisher.message("klokku/jane/state")
+	assert.Equal(t, message{Payload: "", Retain: true}, state)
+	discovery, _ := env.publisher.message("homeassistant/sensor/klokku_jane/config")
+	assert.Equal(t, message{Payload: "", Retain: true}, discovery)
+}
+
+func TestEventStarted_PublishesState(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	_, err := env.service.UpdateSettings(env.ctx, Settings{Enabled: true, Topic: "jane"})
+	require.NoError(t, err)
+	env.events.current = current_event.CurrentEvent{
+		Id:        1,
+		PlanItem:  current_event.PlanItem{BudgetItemId: 10, Name: "Reading"},
+		StartTime: now.Add(-time.Hour),
+	}
+
+	// when
+	err = env.eventBus.Publish(event_bus.NewEvent(env.ctx, "current_event.started", event_bus.CurrentEventStarted{
+		BudgetItemId: 10,
+		Name:         "Reading",
+		StartTime:    now.Add(-time.Hour),
+	}))
+	require.NoError(t, err)
+	env.service.pending.Wait()
+
+	// then
+	startTime := now.Add(-time.Hour)
+	assert.Equal(t, State{
+		Tracking:      true,
+		BudgetItemId:  10,
+		Name:          "Reading",
+		StartTime:     &startTime,
+		Remaining:     5400,
+		WeekRemaining: 36000,
+		UpdatedAt:     now,
+	}, env.state(t, "jane"))
+}
+
+func TestCommand_StartsAndStopsTracking(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	saved, err := env.service.UpdateSettings(env.ctx, Settings{Enabled: true, Topic: "jane"})
+	require.NoError(t, err)
+
+	// when
+	env.publisher.handler("klokku/jane/command",
+		[]byte(`{"action":"start","budgetItemId":10,"secret":"`+saved.CommandSecret+`"}`))
+	env.service.pending.Wait()
+
+	// then
+	assert.Equal(t, current_event.CurrentEvent{
+		Id:        1,
+		PlanItem:  current_event.PlanItem{BudgetItemId: 10, Name: "Reading", WeeklyDuration: 2 * time.Hour},
+		StartTime: now,
+	}, env.events.current)
+
+	// when
+	env.publisher.handler("klokku/jane/command", []byte(`{"action":"stop","secret":"`+saved.CommandSecret+`"}`))
+	env.service.pending.Wait()
+
+	// then
+	assert.True(t, env.events.stopped)
+	assert.Zero(t, env.events.current.Id)
+}
+
+func TestCommand_RejectsWrongSecret(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	_, err := env.service.UpdateSettings(env.ctx, Settings{Enabled: true, Topic: "jane"})
+	require.NoError(t, err)
+
+	for name, payload := range map[string]string{
+		"no secret":    `{"action":"start","budgetItemId":10}`,
+		"wrong secret": `{"action":"start","budgetItemId":10,"secret":"guess"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			// when
+			err := env.service.runCommand(env.ctx, "jane", []byte(payload))
+
+			// then
+			assert.ErrorIs(t, err, ErrInvalidCommand)
+			assert.Zero(t, env.events.current.Id)
+		})
+	}
+}
+
+func TestCommand_IgnoresUnknownAndDisabledTopics(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	_, err := env.service.UpdateSettings(env.ctx, Settings{Enabled: false, Topic: "jane"})
+	require.NoError(t, err)
+
+	// when
+	env.publisher.handler("klokku/jane/command", []byte(`{"action":"start","budgetItemId":10}`))
+	env.publisher.handler("klokku/john/command", []byte(`{"action":"start","budgetItemId":10}`))
+	env.service.pending.Wait()
+
+	// then
+	assert.Zero(t, env.events.current.Id)
+}
+
+func TestPublishStates_SkipsDisabledUsers(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	disabled := otherUser
+	disabled.Disabled = true
+	env.service.users = usersStub{testUser.Id: testUser, otherUser.Id: disabled}
+	_, err := env.repo.SaveSettings(env.ctx, Settings{UserId: testUser.Id, Enabled: true, Topic: "jane"})
+	require.NoError(t, err)
+	_, err = env.repo.SaveSettings(env.ctx, Settings{UserId: otherUser.Id, Enabled: true, Topic: "john"})
+	require.NoError(t, err)
+
+	// when
+	err = env.service.PublishStates(context.Background())
+
+	// then
+	require.NoError(t, err)
+	_, published := env.publisher.message("klokku/jane/state")
+	assert.True(t, published)
+	_, published = env.publisher.message("klokku/john/state")
+	assert.False(t, published)
+}
@@ -0,0 +1,169 @@
+package oidc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	maxErrorLength = 500
+	// clockSkew is how much the clock of the provider may differ when checking the expiry of the ID token
+	clockSkew = time.Minute
+)
+
+var ErrInvalidIdToken = errors.New("invalid ID token")
+
+// Client talks to the OpenID Connect provider using the authorization code flow with PKCE.
+type Client interface {
+	// AuthCodeURL returns the URL of the provider's login page.
+	AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error)
+	// Exchange exchanges the authorization code for the ID token and returns its claims, after checking they
+	// were issued by the provider for Klokku and did not expire. The nonce is left to the caller.
+	Exchange(ctx context.Context, code, codeVerifier string) (Claims, error)
+}
+
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+}
+
+type ClientImpl struct {
+	issuer       string
+	clientId     string
+	clientSecret string
+	redirectUrl  string
+	httpClient   *http.Client
+	now          func() time.Time
+
+	// The provider is discovered on the first login, so Klokku starts when the provider is unavailable
+	mu       sync.Mutex
+	metadata *providerMetadata
+}
+
+func NewClient(issuer, clientId, clientSecret, redirectUrl string, httpClient *http.Client) *ClientImpl {
+	return &ClientImpl{
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		clientId:     clientId,
+		clientSecret: clientSecret,
+		redirectUrl:  redirectUrl,
+		httpClient:   httpClient,
+		now:          time.Now,
+	}
+}
+
+func (c *ClientImpl) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	config, _, err := c.oauthConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	return config.AuthCodeURL(state, oauth2.SetAuthURLParam("nonce", nonce), oauth2.S256ChallengeOption(codeVerifier)), nil
+}
+
+func (c *ClientImpl) Exchange(ctx context.Context, code, codeVerifier string) (Claims, error) {
+	config, metadata, err := c.oauthConfig(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+	token, err := config.Exchange(context.WithValue(ctx, oauth2.HTTPClient, c.httpClient), code,
+		oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return Claims{}, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+	idToken, ok := token.Extra("id_token").(string)
+	if !ok || idToken == "" {
+		return Claims{}, fmt.Errorf("%w: missing in token response", ErrInvalidIdToken)
+	}
+
+	// The ID token comes straight from the token endpoint over TLS, which authenticates the provider in place
+	// of the token signature (OpenID Connect Core 3.1.3.7)
+	claims, err := parseClaims(idToken)
+	if err != nil {
+		return Claims{}, err
+	}
+	switch {
+	case claims.Issuer != metadata.Issuer:
+		return Claims{}, fmt.Errorf("%w: issued by %s", ErrInvalidIdToken, claims.Issuer)
+	case !claims.Audience.contains(c.clientId):
+		return Claims{}, fmt.Errorf("%w: not issued for this client", ErrInvalidIdToken)
+	case claims.Subject == "":
+		return Claims{}, fmt.Errorf("%w: missing subject", ErrInvalidIdToken)
+	case c.now().After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)):
+		return Claims{}, fmt.Errorf("%w: expired", ErrInvalidIdToken)
+	}
+	return claims, nil
+}
+
+func parseClaims(idToken string) (Claims, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return Claims{}, fmt.Errorf("%w: malformed", ErrInvalidIdToken)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: malformed payload", ErrInvalidIdToken)
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Claims{}, fmt.Errorf("%w: malformed claims", ErrInvalidIdToken)
+	}
+	return claims, nil
+}
+
+func (c *ClientImpl) oauthConfig(ctx context.Context) (*oauth2.Config, *providerMetadata, error) {
+	metadata, err := c.discover(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &oauth2.Config{
+		ClientID:     c.clientId,
+		ClientSecret: c.clientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  metadata.AuthorizationEndpoint,
+			TokenURL: metadata.TokenEndpoint,
+		},
+		RedirectURL: c.redirectUrl,
+		Scopes:      []string{"openid", "profile", "email"},
+	}, metadata, nil
+}
+
+func (c *ClientImpl) discover(ctx context.Context) (*providerMetadata, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.metadata != nil {
+		return c.metadata, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OpenID Connect provider: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		response, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+		return nil, fmt.Errorf("failed to discover OpenID Connect provider, status %d: %s", resp.StatusCode, string(response))
+	}
+	var metadata providerMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode OpenID Connect provider metadata: %w", err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != c.issuer {
+		return nil, fmt.Errorf("OpenID Connect provider reports issuer %s instead of %s", metadata.Issuer, c.issuer)
+	}
+	c.metadata = &metadata
+	return c.metadata, nil
+}
@@ -0,0 +1,216 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/pkg/audit"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+)
+
+type LoginRedirectDTO struct {
+	RedirectUrl string `json:"redirectUrl"`
+}
+
+type IdentityDTO struct {
+	Id        int       `json:"id"`
+	Issuer    string    `json:"issuer"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Handler struct {
+	service Service
+	audit   audit.Recorder
+}
+
+func NewHandler(service Service, audit audit.Recorder) *Handler {
+	return &Handler{
+		service: service,
+		audit:   audit,
+	}
+}
+
+// Login godoc
+// @Summary Start single sign-on
+// @Description Start signing in with the configured OpenID Connect provider. Users signing in for the first time
+// @Description get a new Klokku user unless auto provisioning is disabled.
+// @Tags OIDC
+// @Produce json
+// @Param finalUrl query string false "Klokku URL to redirect to after signing in"
+// @Success 200 {object} LoginRedirectDTO "URL of the provider's login page"
+// @Failure 400 {object} rest.ErrorResponse "Final URL outside of Klokku"
+// @Failure 404 {string} string "Single sign-on is not configured"
+// @Router /api/auth/oidc/login [get]
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	redirectUrl, err := h.service.StartLogin(r.Context(), r.URL.Query().Get("finalUrl"))
+	h.writeRedirect(w, redirectUrl, err)
+}
+
+// Link godoc
+// @Summary Link single sign-on identity
+// @Description Start signing in with the configured OpenID Connect provider to link the identity to the current user,
+// @Description who can sign in with it afterward.
+// @Tags OIDC
+// @Produce json
+// @Param finalUrl query string false "Klokku URL to redirect to after linking"
+// @Success 200 {object} LoginRedirectDTO "URL of the provider's login page"
+// @Failure 400 {object} rest.ErrorResponse "Final URL outside of Klokku"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Single sign-on is not configured"
+// @Router /api/auth/oidc/link [get]
+// @Security XUserId
+func (h *Handler) Link(w http.ResponseWriter, r *http.Request) {
+	redirectUrl, err := h.service.StartLink(r.Context(), r.URL.Query().Get("finalUrl"))
+	h.writeRedirect(w, redirectUrl, err)
+}
+
+func (h *Handler) writeRedirect(w http.ResponseWriter, redirectUrl string, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotConfigured):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ErrInvalidFinalUrl):
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(rest.ErrorResponse{Error: err.Error()})
+		default:
+			log.Errorf("Failed to start single sign-on: %v", err)
+			http.Error(w, "Failed to start single sign-on", http.StatusInternalServerError)
+		}
+		return
+	}
+	if err := json.NewEncoder(w).Encode(LoginRedirectDTO{RedirectUrl: redirectUrl}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// Callback godoc
+// @Summary Single sign-on callback
+// @Description Handle the callback of the OpenID Connect provider. After signing in the session token to be sent in
+// @Description the X-Session-Token header is passed in the sessionToken parameter of the finalUrl fragment.
+// @Tags OIDC
+// @Param code query string true "Authorization code"
+// @Param state query string true "State parameter"
+// @Success 302 "Redirect to finalUrl with success=true/false"
+// @Failure 400 {string} string "Unknown or expired login"
+// @Router /api/auth/oidc/callback [get]
+func (h *Handler) Callback(w http.ResponseWriter, r *http.Request) {
+	result, err := h.service.FinishLogin(r.Context(), r.FormValue("state"), r.FormValue("code"))
+	h.recordLogin(r, result, err)
+	if err != nil {
+		if result.FinalUrl == "" {
+			log.Warnf("Failed to finish single sign-on: %v", err)
+			http.Error(w, "Unknown or expired login", http.StatusBadRequest)
+			return
+		}
+		log.Errorf("Failed to finish single sign-on: %v", err)
+		http.Redirect(w, r, withQuery(result.FinalUrl, "success", "false"), http.StatusFound)
+		return
+	}
+
+	finalUrl := withQuery(result.FinalUrl, "success", "true")
+	if result.Session != nil {
+		// The fragment is not sent to the server, so the token stays out of access logs
+		finalUrl += "#" + url.Values{"sessionToken": {result.Session.Token}}.Encode()
+	}
+	http.Redirect(w, r, finalUrl, http.StatusFound)
+}
+
+// recordLogin records signing in to the audit log, linking an identity to the current user is not a login.
+func (h *Handler) recordLogin(r *http.Request, result LoginResult, err error) {
+	if err != nil {
+		details := map[string]string{"method": "oidc", "reason": err.Error()}
+		if result.User.Uid != "" {
+			details["userUid"] = result.User.Uid
+		}
+		h.audit.Record(r.Context(), audit.ActionLoginFailed, "oidc", details)
+		return
+	}
+	if result.Session != nil {
+		h.audit.Record(user.WithUser(r.Context(), result.User), audit.ActionLogin, "oidc", map[string]string{"method": "oidc"})
+	}
+}
+
+func withQuery(rawUrl, key, value string) string {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	query := parsed.Query()
+	query.Set(key, value)
+	parsed.RawQuery = query.Encode()
+	parsed.Fragment = ""
+	return parsed.String()
+}
+
+// ListIdentities godoc
+// @Summary List linked identities
+// @Description Get the single sign-on identities the current user can sign in with
+// @Tags OIDC
+// @Produce json
+// @Success 200 {array} IdentityDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/user/current/identities [get]
+// @Security XUserId
+func (h *Handler) ListIdentities(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	identities, err := h.service.GetIdentities(r.Context())
+	if err != nil {
+		log.Errorf("Failed to get identities: %v", err)
+		http.Error(w, "Failed to get identities", http.StatusInternalServerError)
+		return
+	}
+	dtos := make([]IdentityDTO, 0, len(identities))
+	for _, identity := range identities {
+		dtos = append(dtos, IdentityDTO{
+			Id:        identity.Id,
+			Issuer:    identity.Issuer,
+			Email:     identity.Email,
+			CreatedAt: identity.CreatedAt,
+		})
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteIdentity godoc
+// @Summary Unlink identity
+// @Description Unlink a single sign-on identity from the current user
+// @Tags OIDC
+// @Param id path int true "Identity ID"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Invalid identity ID"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Identity not found"
+// @Router /api/user/current/identities/{id} [delete]
+// @Security XUserId
+func (h *Handler) DeleteIdentity(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid identity ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Unlink(r.Context(), id); err != nil {
+		if errors.Is(err, ErrIdentityNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to unlink identity: %v", err)
+		http.Error(w, "Failed to unlink identity", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
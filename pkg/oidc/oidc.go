@@ -0,0 +1,70 @@
+// Package oidc signs users in with an OpenID Connect provider such as Google, Authentik or Keycloak. Identities
+// of the provider are linked to Klokku users, users signing in for the first time can be created automatically.
+package oidc
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Identity is a user of the OpenID Connect provider linked to a Klokku user.
+type Identity struct {
+	Id        int
+	UserId    int
+	Issuer    string
+	Subject   string
+	Email     string
+	CreatedAt time.Time
+}
+
+// LoginState is kept between redirecting the user to the provider and the callback.
+type LoginState struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+	FinalUrl     string
+	// LinkUserId is set when a signed in user links the identity to their account
+	LinkUserId *int
+	ExpiresAt  time.Time
+}
+
+// Claims are the claims of the ID token used by Klokku.
+type Claims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	ExpiresAt         int64    `json:"exp"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     bool     `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+	// Groups is sent by providers such as Authentik or Keycloak when configured to.
+	Groups []string `json:"groups"`
+}
+
+// audience is a single string or an array of strings in the ID token.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+func (a audience) contains(clientId string) bool {
+	for _, aud := range a {
+		if aud == clientId {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrIdentityNotFound = errors.New("identity not found")
+var ErrIdentityLinked = errors.New("identity is linked to another user")
+
+type Repository interface {
+	CreateLoginState(ctx context.Context, state LoginState) error
+	// TakeLoginState deletes the login state and returns it, nil when there is none so every login finishes once.
+	TakeLoginState(ctx context.Context, state string) (*LoginState, error)
+	DeleteExpiredLoginStates(ctx context.Context, before time.Time) (int, error)
+	// GetIdentity returns nil when the identity is not linked to any user.
+	GetIdentity(ctx context.Context, issuer, subject string) (*Identity, error)
+	// CreateIdentity returns ErrIdentityLinked when the identity is already linked to a user.
+	CreateIdentity(ctx context.Context, identity Identity) (Identity, error)
+	GetUserIdentities(ctx context.Context, userId int) ([]Identity, error)
+	DeleteIdentity(ctx context.Context, userId, id int) error
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+func (r *RepositoryImpl) CreateLoginState(ctx context.Context, state LoginState) error {
+	query := `INSERT INTO oidc_login_state (state, nonce, code_verifier, final_url, link_user_id, expires_at)
+			  VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := r.db.Exec(ctx, query, state.State, state.Nonce, state.CodeVerifier, state.FinalUrl, state.LinkUserId,
+		state.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to store login state: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) TakeLoginState(ctx context.Context, state string) (*LoginState, error) {
+	query := `DELETE FROM oidc_login_state WHERE state = $1
+			  RETURNING state, nonce, code_verifier, final_url, link_user_id, expires_at`
+	var loginState LoginState
+	err := r.db.QueryRow(ctx, query, state).Scan(&loginState.State, &loginState.Nonce, &loginState.CodeVerifier,
+		&loginState.FinalUrl, &loginState.LinkUserId, &loginState.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get login state: %w", err)
+	}
+	return &loginState, nil
+}
+
+func (r *RepositoryImpl) DeleteExpiredLoginStates(ctx context.Context, before time.Time) (int, error) {
+	tag, err := r.db.Exec(ctx, "DELETE FROM oidc_login_state WHERE expires_at <= $1", before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired login states: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+const identityColumns = `id, user_id, issuer, subject, email, created_at`
+
+func scanIdentity(row pgx.Row) (Identity, error) {
+	var identity Identity
+	err := row.Scan(&identity.Id, &identity.UserId, &identity.Issuer, &identity.Subject, &identity.Email,
+		&identity.CreatedAt)
+	return identity, err
+}
+
+func (r *RepositoryImpl) GetIdentity(ctx context.Context, issuer, subject string) (*Identity, error) {
+	identity, err := scanIdentity(r.db.QueryRow(ctx,
+		`SELECT `+identityColumns+` FROM user_identity WHERE issuer = $1 AND subject = $2`, issuer, subject))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+	return &identity, nil
+}
+
+func (r *RepositoryImpl) CreateIdentity(ctx context.Context, identity Identity) (Identity, error) {
+	query := `INSERT INTO user_identity (user_id, issuer, subject, email) VALUES ($1, $2, $3, $4)
+			  ON CONFLICT (issuer, subject) DO NOTHING
+			  RETURNING ` + identityColumns
+	created, err := scanIdentity(r.db.QueryRow(ctx, query, identity.UserId, identity.Issuer, identity.Subject,
+		identity.Email))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Identity{}, ErrIdentityLinked
+		}
+		return Identity{}, fmt.Errorf("failed to create identity: %w", err)
+	}
+	return created, nil
+}
+
+func (r *RepositoryImpl) GetUserIdentities(ctx context.Context, userId int) ([]Identity, error) {
+	rows, err := r.db.Query(ctx,
+		`SELECT `+identityColumns+` FROM user_identity WHERE user_id = $1 ORDER BY created_at, id`, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identities: %w", err)
+	}
+	defer rows.Close()
+
+	identities := make([]Identity, 0)
+	for rows.Next() {
+		identity, err := scanIdentity(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan identity: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+func (r *RepositoryImpl) DeleteIdentity(ctx context.Context, userId, id int) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM user_identity WHERE user_id = $1 AND id = $2", userId, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete identity: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
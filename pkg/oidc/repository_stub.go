@@ -0,0 +1,110 @@
+package oidc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type RepositoryStub struct {
+	mu         sync.Mutex
+	states     map[string]LoginState
+	identities []Identity
+	nextId     int
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{states: make(map[string]LoginState)}
+}
+
+func (r *RepositoryStub) CreateLoginState(ctx context.Context, state LoginState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states[state.State] = state
+	return nil
+}
+
+func (r *RepositoryStub) TakeLoginState(ctx context.Context, state string) (*LoginState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	loginState, ok := r.states[state]
+	if !ok {
+		return nil, nil
+	}
+	delete(r.states, state)
+	return &loginState, nil
+}
+
+func (r *RepositoryStub) DeleteExpiredLoginStates(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	deleted := 0
+	for key, state := range r.states {
+		if !state.ExpiresAt.After(before) {
+			delete(r.states, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (r *RepositoryStub) GetIdentity(ctx context.Context, issuer, subject string) (*Identity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, identity := range r.identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			return &identity, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *RepositoryStub) CreateIdentity(ctx context.Context, identity Identity) (Identity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.identities {
+		if existing.Issuer == identity.Issuer && existing.Subject == identity.Subject {
+			return Identity{}, ErrIdentityLinked
+		}
+	}
+	r.nextId++
+	identity.Id = r.nextId
+	identity.CreatedAt = time.Now()
+	r.identities = append(r.identities, identity)
+	return identity, nil
+}
+
+func (r *RepositoryStub) GetUserIdentities(ctx context.Context, userId int) ([]Identity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	identities := make([]Identity, 0)
+	for _, identity := range r.identities {
+		if identity.UserId == userId {
+			identities = append(identities, identity)
+		}
+	}
+	return identities, nil
+}
+
+func (r *RepositoryStub) DeleteIdentity(ctx context.Context, userId, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, identity := range r.identities {
+		if identity.UserId == userId && identity.Id == id {
+			r.identities = append(r.identities[:i], r.identities[i+1:]...)
+			return nil
+		}
+	}
+	return ErrIdentityNotFound
+}
+
+// LoginStates returns the pending logins
+func (r *RepositoryStub) LoginStates() []LoginState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	states := make([]LoginState, 0, len(r.states))
+	for _, state := range r.states {
+		states = append(states, state)
+	}
+	return states
+}
@@ -0,0 +1,387 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/user_switch"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+// loginTtl is how long the user has to sign in with the provider after starting the login
+const loginTtl = 10 * time.Minute
+
+const maxUsernameAttempts = 100
+
+var ErrNotConfigured = errors.New("single sign-on is not configured")
+var ErrInvalidFinalUrl = errors.New("final URL must point to Klokku")
+var ErrLoginNotFound = errors.New("login not found or expired")
+var ErrUserNotProvisioned = errors.New("identity is not linked to any user")
+
+type UserProvider interface {
+	GetUser(ctx context.Context, id int) (user.User, error)
+	CreateUser(ctx context.Context, user user.User) (user.User, error)
+	IsUsernameAvailable(ctx context.Context, username string) (bool, error)
+}
+
+type SessionProvider interface {
+	StartSession(ctx context.Context, userId int, ttl time.Duration) (user_switch.Session, error)
+}
+
+// LoginResult is the outcome of a finished login. Session is set when the user signed in, not when they linked
+// the identity to their account.
+type LoginResult struct {
+	FinalUrl string
+	User     user.User
+	Session  *user_switch.Session
+}
+
+type Service interface {
+	Enabled() bool
+	// StartLogin starts signing in with the provider and returns the URL of its login page. The user is sent to
+	// finalUrl, a URL of Klokku, once the login finishes.
+	StartLogin(ctx context.Context, finalUrl string) (string, error)
+	// StartLink is StartLogin for the current user, linking the identity they sign in with to their account.
+	StartLink(ctx context.Context, finalUrl string) (string, error)
+	// FinishLogin handles the callback of the provider. On sign in the user of the identity gets a session,
+	// identities not linked to any user get a new user when auto provisioning is enabled.
+	FinishLogin(ctx context.Context, state, code string) (LoginResult, error)
+	GetIdentities(ctx context.Context) ([]Identity, error)
+	Unlink(ctx context.Context, id int) error
+}
+
+type ServiceImpl struct {
+	repo          Repository
+	client        Client
+	users         UserProvider
+	sessions      SessionProvider
+	clock         utils.Clock
+	host          *url.URL
+	autoProvision bool
+	// allowedEmails, allowedDomains and allowedGroups restrict auto provisioning, lower cased
+	allowedEmails  []string
+	allowedDomains []string
+	allowedGroups  []string
+	sessionTtl     time.Duration
+}
+
+func NewService(repo Repository, client Client, users UserProvider, sessions SessionProvider, cfg config.Application) *ServiceImpl {
+	host, err := url.Parse(cfg.Host)
+	if err != nil {
+		log.Errorf("invalid host %s, single sign-on only redirects to relative URLs: %v", cfg.Host, err)
+		host = &url.URL{}
+	}
+	service := &ServiceImpl{
+		repo:           repo,
+		client:         client,
+		users:          users,
+		sessions:       sessions,
+		clock:          &utils.SystemClock{},
+		host:           host,
+		autoProvision:  cfg.Oidc.AutoProvision,
+		allowedEmails:  normalizeList(cfg.Oidc.AllowedEmails),
+		allowedDomains: normalizeList(cfg.Oidc.AllowedDomains),
+		allowedGroups:  normalizeList(cfg.Oidc.AllowedGroups),
+		sessionTtl:     time.Duration(cfg.Oidc.SessionTtlHours) * time.Hour,
+	}
+	if service.autoProvision && cfg.Oidc.Issuer != "" && !service.restrictsProvisioning() {
+		log.Warnf("Auto provisioning is enabled without an allow list, anyone with a verified email at %s can "+
+			"create a Klokku user", cfg.Oidc.Issuer)
+	}
+	return service
+}
+
+func normalizeList(values []string) []string {
+	normalized := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			normalized = append(normalized, v)
+		}
+	}
+	return normalized
+}
+
+func (s *ServiceImpl) Enabled() bool {
+	return s.client != nil
+}
+
+func (s *ServiceImpl) StartLogin(ctx context.Context, finalUrl string) (string, error) {
+	return s.start(ctx, finalUrl, nil)
+}
+
+func (s *ServiceImpl) StartLink(ctx context.Context, finalUrl string) (string, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.start(ctx, finalUrl, &userId)
+}
+
+func (s *ServiceImpl) start(ctx context.Context, finalUrl string, linkUserId *int) (string, error) {
+	if !s.Enabled() {
+		return "", ErrNotConfigured
+	}
+	// The session token is handed to the final URL, so it must not leave Klokku
+	finalUrl, err := s.resolveFinalUrl(finalUrl)
+	if err != nil {
+		return "", err
+	}
+
+	now := s.clock.Now()
+	if deleted, err := s.repo.DeleteExpiredLoginStates(ctx, now); err != nil {
+		log.Errorf("failed to delete expired login states: %v", err)
+	} else if deleted > 0 {
+		log.Debugf("deleted %d expired login states", deleted)
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	loginState := LoginState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		FinalUrl:     finalUrl,
+		LinkUserId:   linkUserId,
+		ExpiresAt:    now.Add(loginTtl),
+	}
+	if err := s.repo.CreateLoginState(ctx, loginState); err != nil {
+		return "", err
+	}
+	return s.client.AuthCodeURL(ctx, loginState.State, loginState.Nonce, loginState.CodeVerifier)
+}
+
+// resolveFinalUrl makes the URL absolute and checks it points to the Klokku host.
+func (s *ServiceImpl) resolveFinalUrl(finalUrl string) (string, error) {
+	if finalUrl == "" {
+		finalUrl = "/"
+	}
+	parsed, err := url.Parse(finalUrl)
+	if err != nil {
+		return "", ErrInvalidFinalUrl
+	}
+	if !parsed.IsAbs() && parsed.Host == "" && strings.HasPrefix(parsed.Path, "/") {
+		return s.host.ResolveReference(parsed).String(), nil
+	}
+	if parsed.Scheme != s.host.Scheme || parsed.Host != s.host.Host {
+		return "", ErrInvalidFinalUrl
+	}
+	return parsed.String(), nil
+}
+
+func (s *ServiceImpl) FinishLogin(ctx context.Context, state, code string) (LoginResult, error) {
+	if !s.Enabled() {
+		return LoginResult{}, ErrNotConfigured
+	}
+	loginState, err := s.repo.TakeLoginState(ctx, state)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	if loginState == nil || !s.clock.Now().Before(loginState.ExpiresAt) {
+		return LoginResult{}, ErrLoginNotFound
+	}
+	result := LoginResult{FinalUrl: loginState.FinalUrl}
+
+	claims, err := s.client.Exchange(ctx, code, loginState.CodeVerifier)
+	if err != nil {
+		return result, err
+	}
+	if claims.Nonce != loginState.Nonce {
+		return result, fmt.Errorf("%w: nonce does not match", ErrInvalidIdToken)
+	}
+
+	if loginState.LinkUserId != nil {
+		result.User, err = s.link(ctx, *loginState.LinkUserId, claims)
+		return result, err
+	}
+
+	result.User, err = s.identityUser(ctx, claims)
+	if err != nil {
+		return result, err
+	}
+	if result.User.Disabled {
+		return result, user.ErrUserDisabled
+	}
+	session, err := s.sessions.StartSession(ctx, result.User.Id, s.sessionTtl)
+	if err != nil {
+		return result, err
+	}
+	result.Session = &session
+	log.Infof("user %s signed in with %s", result.User.Uid, claims.Issuer)
+	return result, nil
+}
+
+func (s *ServiceImpl) link(ctx context.Context, userId int, claims Claims) (user.User, error) {
+	u, err := s.users.GetUser(ctx, userId)
+	if err != nil {
+		return user.User{}, err
+	}
+	existing, err := s.repo.GetIdentity(ctx, claims.Issuer, claims.Subject)
+	if err != nil {
+		return user.User{}, err
+	}
+	if existing != nil {
+		if existing.UserId == userId {
+			return u, nil
+		}
+		return user.User{}, ErrIdentityLinked
+	}
+	if _, err := s.repo.CreateIdentity(ctx, identityFromClaims(userId, claims)); err != nil {
+		return user.User{}, err
+	}
+	log.Infof("user %s linked identity of %s", u.Uid, claims.Issuer)
+	return u, nil
+}
+
+// identityUser returns the user linked to the identity, provisioning a new one on the first login.
+func (s *ServiceImpl) identityUser(ctx context.Context, claims Claims) (user.User, error) {
+	identity, err := s.repo.GetIdentity(ctx, claims.Issuer, claims.Subject)
+	if err != nil {
+		return user.User{}, err
+	}
+	if identity != nil {
+		return s.users.GetUser(ctx, identity.UserId)
+	}
+	if err := s.checkProvisioning(claims); err != nil {
+		return user.User{}, err
+	}
+
+	username, err := s.availableUsername(ctx, claims)
+	if err != nil {
+		return user.User{}, err
+	}
+	displayName := strings.TrimSpace(claims.Name)
+	if displayName == "" {
+		displayName = username
+	}
+	created, err := s.users.CreateUser(ctx, user.User{
+		Uid:         uuid.NewString(),
+		Username:    username,
+		DisplayName: displayName,
+		Settings: user.Settings{
+			Timezone:            "UTC",
+			WeekFirstDay:        time.Monday,
+			EventCalendarType:   user.KlokkuCalendar,
+			ShortEventThreshold: user.DefaultShortEventThreshold,
+			ShortEventHandling:  user.ShortEventMergeNext,
+			RenamePropagation:   user.DefaultRenamePropagation,
+		},
+	})
+	if err != nil {
+		return user.User{}, fmt.Errorf("failed to provision user: %w", err)
+	}
+	if _, err := s.repo.CreateIdentity(ctx, identityFromClaims(created.Id, claims)); err != nil {
+		return user.User{}, err
+	}
+	log.Infof("provisioned user %s for identity of %s", created.Uid, claims.Issuer)
+	return created, nil
+}
+
+// checkProvisioning returns ErrUserNotProvisioned unless a user may be provisioned for the identity.
+func (s *ServiceImpl) checkProvisioning(claims Claims) error {
+	if !s.autoProvision {
+		return ErrUserNotProvisioned
+	}
+	if claims.Email == "" || !claims.EmailVerified {
+		return fmt.Errorf("%w: email is not verified", ErrUserNotProvisioned)
+	}
+	if !s.restrictsProvisioning() {
+		return nil
+	}
+	email := strings.ToLower(claims.Email)
+	_, domain, _ := strings.Cut(email, "@")
+	if slices.Contains(s.allowedEmails, email) || slices.Contains(s.allowedDomains, domain) {
+		return nil
+	}
+	for _, group := range claims.Groups {
+		if slices.Contains(s.allowedGroups, strings.ToLower(group)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s is not allowed to sign up", ErrUserNotProvisioned, claims.Email)
+}
+
+func (s *ServiceImpl) restrictsProvisioning() bool {
+	return len(s.allowedEmails) > 0 || len(s.allowedDomains) > 0 || len(s.allowedGroups) > 0
+}
+
+// availableUsername derives the username from the preferred username or the email, adding a number when taken.
+func (s *ServiceImpl) availableUsername(ctx context.Context, claims Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = sanitizeUsername(base)
+	for attempt := 1; attempt <= maxUsernameAttempts; attempt++ {
+		username := base
+		if attempt > 1 {
+			username = fmt.Sprintf("%s-%d", base, attempt)
+		}
+		available, err := s.users.IsUsernameAvailable(ctx, username)
+		if err != nil {
+			return "", err
+		}
+		if available {
+			return username, nil
+		}
+	}
+	return "", fmt.Errorf("no available username for %s", base)
+}
+
+func sanitizeUsername(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '.' || r == '_' || r == '-' {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return "user"
+	}
+	return b.String()
+}
+
+func identityFromClaims(userId int, claims Claims) Identity {
+	return Identity{UserId: userId, Issuer: claims.Issuer, Subject: claims.Subject, Email: claims.Email}
+}
+
+func (s *ServiceImpl) GetIdentities(ctx context.Context) ([]Identity, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.GetUserIdentities(ctx, userId)
+}
+
+func (s *ServiceImpl) Unlink(ctx context.Context, id int) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.DeleteIdentity(ctx, userId, id)
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
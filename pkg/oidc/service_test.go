@@ -0,0 +1,356 @@
+package oidc
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/user_switch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testIssuer = "https://auth.example.com"
+
+var testNow = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+type clientStub struct {
+	claims Claims
+}
+
+func (c *clientStub) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	return testIssuer + "/authorize?" + url.Values{"state": {state}}.Encode(), nil
+}
+
+func (c *clientStub) Exchange(ctx context.Context, code, codeVerifier string) (Claims, error) {
+	return c.claims, nil
+}
+
+type serviceTest struct {
+	service  *ServiceImpl
+	repo     *RepositoryStub
+	client   *clientStub
+	users    user.Service
+	sessions *user_switch.ServiceImpl
+	clock    *utils.MockClock
+}
+
+func setupServiceTest(t *testing.T, autoProvision bool) serviceTest {
+	return setupServiceTestWithConfig(t, config.Oidc{AutoProvision: autoProvision})
+}
+
+func setupServiceTestWithConfig(t *testing.T, oidcConfig config.Oidc) serviceTest {
+	oidcConfig.Issuer = testIssuer
+	oidcConfig.SessionTtlHours = 24
+	repo := NewRepositoryStub()
+	client := &clientStub{}
+	users := user.NewUserService(user.NewStubUserRepository(), event_bus.NewEventBus())
+	sessions := user_switch.NewService(user_switch.NewRepositoryStub(), users, config.UserSwitch{})
+	service := NewService(repo, client, users, sessions, config.Application{
+		Host: "https://klokku.example.com",
+		Oidc: oidcConfig,
+	})
+	clock := &utils.MockClock{FixedNow: testNow}
+	service.clock = clock
+	return serviceTest{service: service, repo: repo, client: client, users: users, sessions: sessions, clock: clock}
+}
+
+// login goes through the provider's login page, signing in as the subject
+func (s serviceTest) login(t *testing.T, ctx context.Context, subject string, start func(context.Context, string) (string, error)) (LoginResult, error) {
+	_, err := start(ctx, "/settings")
+	require.NoError(t, err)
+	states := s.repo.LoginStates()
+	require.Len(t, states, 1)
+	s.client.claims.Issuer = testIssuer
+	s.client.claims.Subject = subject
+	s.client.claims.Nonce = states[0].Nonce
+	return s.service.FinishLogin(ctx, states[0].State, "code")
+}
+
+func TestServiceImpl_StartLogin(t *testing.T) {
+	t.Run("should resolve relative final URL against host", func(t *testing.T) {
+		// given
+		test := setupServiceTest(t, true)
+
+		// when
+		redirectUrl, err := test.service.StartLogin(context.Background(), "/settings?tab=account")
+
+		// then
+		require.NoError(t, err)
+		assert.Contains(t, redirectUrl, testIssuer+"/authorize")
+		states := test.repo.LoginStates()
+		require.Len(t, states, 1)
+		assert.Equal(t, "https://klokku.example.com/settings?tab=account", states[0].FinalUrl)
+		assert.Equal(t, testNow.Add(loginTtl), states[0].ExpiresAt)
+		assert.Nil(t, states[0].LinkUserId)
+	})
+
+	t.Run("should reject final URL outside of Klokku", func(t *testing.T) {
+		for _, finalUrl := range []string{"https://evil.example.com/", "//evil.example.com/", "http://klokku.example.com/", "settings"} {
+			// given
+			test := setupServiceTest(t, true)
+
+			// when
+			_, err := test.service.StartLogin(context.Background(), finalUrl)
+
+			// then
+			assert.ErrorIs(t, err, ErrInvalidFinalUrl, finalUrl)
+			assert.Empty(t, test.repo.LoginStates())
+		}
+	})
+
+	t.Run("should fail when not configured", func(t *testing.T) {
+		// given
+		service := NewService(NewRepositoryStub(), nil, nil, nil, config.Application{})
+
+		// when
+		_, err := service.StartLogin(context.Background(), "/")
+
+		// then
+		assert.False(t, service.Enabled())
+		assert.ErrorIs(t, err, ErrNotConfigured)
+	})
+}
+
+func TestServiceImpl_FinishLogin(t *testing.T) {
+	t.Run("should provision user on first login and start session", func(t *testing.T) {
+		// given
+		test := setupServiceTest(t, true)
+		test.client.claims = Claims{PreferredUsername: "Jane.Doe", Name: "Jane Doe", Email: "jane@example.com", EmailVerified: true}
+
+		// when
+		result, err := test.login(t, context.Background(), "subject-1", test.service.StartLogin)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "https://klokku.example.com/settings", result.FinalUrl)
+		assert.Equal(t, "jane.doe", result.User.Username)
+		assert.Equal(t, "Jane Doe", result.User.DisplayName)
+		assert.Equal(t, user.KlokkuCalendar, result.User.Settings.EventCalendarType)
+		require.NotNil(t, result.Session)
+		assert.Equal(t, result.User.Id, result.Session.UserId)
+		assert.WithinDuration(t, result.Session.CreatedAt.Add(24*time.Hour), result.Session.ExpiresAt, time.Second)
+		identities, err := test.repo.GetUserIdentities(context.Background(), result.User.Id)
+		require.NoError(t, err)
+		require.Len(t, identities, 1)
+		assert.Equal(t, "subject-1", identities[0].Subject)
+		assert.Equal(t, "jane@example.com", identities[0].Email)
+	})
+
+	t.Run("should sign in the linked user on next login", func(t *testing.T) {
+		// given
+		test := setupServiceTest(t, true)
+		test.client.claims = Claims{Email: "jane@example.com", EmailVerified: true}
+		first, err := test.login(t, context.Background(), "subject-1", test.service.StartLogin)
+		require.NoError(t, err)
+
+		// when
+		second, err := test.login(t, context.Background(), "subject-1", test.service.StartLogin)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, first.User.Id, second.User.Id)
+		assert.NotEqual(t, first.Session.Token, second.Session.Token)
+	})
+
+	t.Run("should number username when taken", func(t *testing.T) {
+		// given
+		test := setupServiceTest(t, true)
+		_, err := test.users.CreateUser(context.Background(), user.User{Uid: "uid-1", Username: "jane", DisplayName: "Jane"})
+		require.NoError(t, err)
+		test.client.claims = Claims{Email: "jane@example.com", EmailVerified: true}
+
+		// when
+		result, err := test.login(t, context.Background(), "subject-1", test.service.StartLogin)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "jane-2", result.User.Username)
+		assert.Equal(t, "jane-2", result.User.DisplayName)
+	})
+
+	t.Run("should not provision user when disabled", func(t *testing.T) {
+		// given
+		test := setupServiceTest(t, false)
+
+		// when
+		result, err := test.login(t, context.Background(), "subject-1", test.service.StartLogin)
+
+		// then
+		assert.ErrorIs(t, err, ErrUserNotProvisioned)
+		assert.Equal(t, "https://klokku.example.com/settings", result.FinalUrl)
+		assert.Nil(t, result.Session)
+	})
+
+	t.Run("should not provision user with unverified email", func(t *testing.T) {
+		// given
+		test := setupServiceTest(t, true)
+		test.client.claims = Claims{Email: "jane@example.com"}
+
+		// when
+		result, err := test.login(t, context.Background(), "subject-1", test.service.StartLogin)
+
+		// then
+		assert.ErrorIs(t, err, ErrUserNotProvisioned)
+		assert.Nil(t, result.Session)
+	})
+
+	t.Run("should provision only identities on the allow lists", func(t *testing.T) {
+		cases := []struct {
+			name    string
+			claims  Claims
+			allowed bool
+		}{
+			{"allowed email", Claims{Email: "Jane@Other.com"}, true},
+			{"allowed domain", Claims{Email: "john@example.com"}, true},
+			{"allowed group", Claims{Email: "joe@other.com", Groups: []string{"staff", "Klokku-Users"}}, true},
+			{"not allowed", Claims{Email: "joe@other.com", Groups: []string{"staff"}}, false},
+			{"subdomain", Claims{Email: "joe@mail.example.com"}, false},
+		}
+		for _, c := range cases {
+			// given
+			test := setupServiceTestWithConfig(t, config.Oidc{
+				AutoProvision:  true,
+				AllowedEmails:  []string{"jane@other.com"},
+				AllowedDomains: []string{" Example.com"},
+				AllowedGroups:  []string{"klokku-users"},
+			})
+			test.client.claims = c.claims
+			test.client.claims.EmailVerified = true
+
+			// when
+			result, err := test.login(t, context.Background(), "subject-1", test.service.StartLogin)
+
+			// then
+			if c.allowed {
+				require.NoError(t, err, c.name)
+				assert.NotNil(t, result.Session, c.name)
+			} else {
+				assert.ErrorIs(t, err, ErrUserNotProvisioned, c.name)
+				assert.Nil(t, result.Session, c.name)
+			}
+		}
+	})
+
+	t.Run("should reject ID token with different nonce", func(t *testing.T) {
+		// given
+		test := setupServiceTest(t, true)
+		_, err := test.service.StartLogin(context.Background(), "/")
+		require.NoError(t, err)
+		state := test.repo.LoginStates()[0]
+		test.client.claims = Claims{Issuer: testIssuer, Subject: "subject-1", Nonce: "other-nonce"}
+
+		// when
+		result, err := test.service.FinishLogin(context.Background(), state.State, "code")
+
+		// then
+		assert.ErrorIs(t, err, ErrInvalidIdToken)
+		assert.Nil(t, result.Session)
+	})
+
+	t.Run("should reject expired login", func(t *testing.T) {
+		// given
+		test := setupServiceTest(t, true)
+		_, err := test.service.StartLogin(context.Background(), "/")
+		require.NoError(t, err)
+		state := test.repo.LoginStates()[0]
+		test.clock.SetNow(testNow.Add(loginTtl))
+
+		// when
+		result, err := test.service.FinishLogin(context.Background(), state.State, "code")
+
+		// then
+		assert.ErrorIs(t, err, ErrLoginNotFound)
+		assert.Empty(t, result.FinalUrl)
+	})
+
+	t.Run("should finish login only once", func(t *testing.T) {
+		// given
+		test := setupServiceTest(t, true)
+		_, err := test.service.StartLogin(context.Background(), "/")
+		require.NoError(t, err)
+		state := test.repo.LoginStates()[0]
+		test.client.claims = Claims{Issuer: testIssuer, Subject: "subject-1", Nonce: state.Nonce,
+			Email: "jane@example.com", EmailVerified: true}
+		_, err = test.service.FinishLogin(context.Background(), state.State, "code")
+		require.NoError(t, err)
+
+		// when
+		_, err = test.service.FinishLogin(context.Background(), state.State, "code")
+
+		// then
+		assert.ErrorIs(t, err, ErrLoginNotFound)
+	})
+}
+
+func TestServiceImpl_Link(t *testing.T) {
+	t.Run("should link identity to current user", func(t *testing.T) {
+		// given
+		test := setupServiceTest(t, true)
+		current, err := test.users.CreateUser(context.Background(), user.User{Uid: "uid-1", Username: "jane", DisplayName: "Jane"})
+		require.NoError(t, err)
+		ctx := user.WithUser(context.Background(), current)
+
+		// when
+		result, err := test.login(t, ctx, "subject-1", test.service.StartLink)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, current.Id, result.User.Id)
+		assert.Nil(t, result.Session)
+		identities, err := test.service.GetIdentities(ctx)
+		require.NoError(t, err)
+		require.Len(t, identities, 1)
+		assert.Equal(t, "subject-1", identities[0].Subject)
+
+		signedIn, err := test.login(t, context.Background(), "subject-1", test.service.StartLogin)
+		require.NoError(t, err)
+		assert.Equal(t, current.Id, signedIn.User.Id)
+	})
+
+	t.Run("should not link identity of another user", func(t *testing.T) {
+		// given
+		test := setupServiceTest(t, true)
+		test.client.claims = Claims{Email: "john@example.com", EmailVerified: true}
+		other, err := test.login(t, context.Background(), "subject-1", test.service.StartLogin)
+		require.NoError(t, err)
+		current, err := test.users.CreateUser(context.Background(), user.User{Uid: "uid-1", Username: "jane", DisplayName: "Jane"})
+		require.NoError(t, err)
+
+		// when
+		_, err = test.login(t, user.WithUser(context.Background(), current), "subject-1", test.service.StartLink)
+
+		// then
+		assert.ErrorIs(t, err, ErrIdentityLinked)
+		identities, err := test.repo.GetUserIdentities(context.Background(), other.User.Id)
+		require.NoError(t, err)
+		assert.Len(t, identities, 1)
+	})
+}
+
+func TestServiceImpl_Unlink(t *testing.T) {
+	// given
+	test := setupServiceTest(t, true)
+	test.client.claims = Claims{Email: "jane@example.com", EmailVerified: true}
+	result, err := test.login(t, context.Background(), "subject-1", test.service.StartLogin)
+	require.NoError(t, err)
+	ctx := user.WithUser(context.Background(), result.User)
+	identities, err := test.service.GetIdentities(ctx)
+	require.NoError(t, err)
+	require.Len(t, identities, 1)
+
+	// when
+	err = test.service.Unlink(ctx, identities[0].Id)
+
+	// then
+	require.NoError(t, err)
+	identities, err = test.service.GetIdentities(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, identities)
+	assert.ErrorIs(t, test.service.Unlink(ctx, 999), ErrIdentityNotFound)
+}
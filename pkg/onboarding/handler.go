@@ -0,0 +1,130 @@
+package onboarding
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/klokku/klokku/internal/rest"
+	log "github.com/sirupsen/logrus"
+)
+
+type OnboardingDTO struct {
+	Steps []StepDTO `json:"steps"`
+	// CurrentStep is the step the user should be guided to, empty when onboarding is completed
+	CurrentStep Step `json:"currentStep,omitempty" enums:"create_plan,add_items,connect_calendar,first_tracked_event"`
+	Completed   bool `json:"completed"`
+	Dismissed   bool `json:"dismissed"`
+}
+
+type StepDTO struct {
+	Step      Step       `json:"step" enums:"create_plan,add_items,connect_calendar,first_tracked_event"`
+	Status    StepStatus `json:"status" enums:"pending,completed,skipped"`
+	Skippable bool       `json:"skippable"`
+}
+
+type UpdateOnboardingDTO struct {
+	// SkippedSteps replaces the list of skipped steps when present
+	SkippedSteps *[]Step `json:"skippedSteps,omitempty"`
+	Dismissed    *bool   `json:"dismissed,omitempty"`
+}
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// GetOnboarding godoc
+// @Summary Get onboarding progress
+// @Description Get the onboarding progress of the current user. Steps are completed based on the user's data:
+// @Description a budget plan exists, the current plan has items, an external calendar is connected
+// @Description and at least one event was tracked.
+// @Tags Onboarding
+// @Produce json
+// @Success 200 {object} OnboardingDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/user/current/onboarding [get]
+// @Security XUserId
+func (h *Handler) GetOnboarding(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	onboarding, err := h.service.GetOnboarding(r.Context())
+	if err != nil {
+		log.Errorf("Failed to get onboarding: %v", err)
+		http.Error(w, "Failed to get onboarding", http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(onboardingToDTO(onboarding)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// UpdateOnboarding godoc
+// @Summary Update onboarding preferences
+// @Description Skip optional onboarding steps or dismiss the onboarding. Only the fields present in the request are changed.
+// @Tags Onboarding
+// @Accept json
+// @Produce json
+// @Param onboarding body UpdateOnboardingDTO true "Onboarding preferences"
+// @Success 200 {object} OnboardingDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Router /api/user/current/onboarding [patch]
+// @Security XUserId
+func (h *Handler) UpdateOnboarding(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var updateDTO UpdateOnboardingDTO
+	if err := json.NewDecoder(r.Body).Decode(&updateDTO); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+			Error: "Invalid request body format",
+		})
+		return
+	}
+
+	onboarding, err := h.service.UpdatePreferences(r.Context(), PreferencesUpdate{
+		SkippedSteps: updateDTO.SkippedSteps,
+		Dismissed:    updateDTO.Dismissed,
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvalidStep) || errors.Is(err, ErrStepNotSkippable) {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+				Error:   "Invalid skipped steps",
+				Details: err.Error(),
+			})
+			return
+		}
+		log.Errorf("Failed to update onboarding: %v", err)
+		http.Error(w, "Failed to update onboarding", http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(onboardingToDTO(onboarding)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func onboardingToDTO(onboarding Onboarding) OnboardingDTO {
+	steps := make([]StepDTO, 0, len(onboarding.Steps))
+	for _, state := range onboarding.Steps {
+		steps = append(steps, StepDTO{
+			Step:      state.Step,
+			Status:    state.Status,
+			Skippable: state.Step.IsSkippable(),
+		})
+	}
+	return OnboardingDTO{
+		Steps:       steps,
+		CurrentStep: onboarding.CurrentStep,
+		Completed:   onboarding.Completed,
+		Dismissed:   onboarding.Dismissed,
+	}
+}
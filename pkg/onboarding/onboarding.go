@@ -0,0 +1,63 @@
+package onboarding
+
+// Step is a single step of the onboarding workflow. Steps are completed based on the user's data,
+// not by the client, so the progress always reflects what the user actually did.
+type Step string
+
+const (
+	StepCreatePlan        Step = "create_plan"
+	StepAddItems          Step = "add_items"
+	StepConnectCalendar   Step = "connect_calendar"
+	StepFirstTrackedEvent Step = "first_tracked_event"
+)
+
+// Steps lists the onboarding steps in the order the user is guided through them.
+var Steps = []Step{StepCreatePlan, StepAddItems, StepConnectCalendar, StepFirstTrackedEvent}
+
+func (s Step) IsValid() bool {
+	for _, step := range Steps {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSkippable reports whether the user may skip the step. Connecting an external calendar is optional,
+// because the built-in Klokku calendar works without it.
+func (s Step) IsSkippable() bool {
+	return s == StepConnectCalendar
+}
+
+type StepStatus string
+
+const (
+	StepPending   StepStatus = "pending"
+	StepCompleted StepStatus = "completed"
+	StepSkipped   StepStatus = "skipped"
+)
+
+type StepState struct {
+	Step   Step
+	Status StepStatus
+}
+
+// Preferences are the onboarding choices made by the user, stored per user.
+type Preferences struct {
+	SkippedSteps []Step
+	Dismissed    bool
+}
+
+type Onboarding struct {
+	Steps []StepState
+	// CurrentStep is the first pending step, empty when all steps are completed or skipped.
+	CurrentStep Step
+	Completed   bool
+	Dismissed   bool
+}
+
+// PreferencesUpdate holds the changes requested by the user. Nil fields are left unchanged.
+type PreferencesUpdate struct {
+	SkippedSteps *[]Step
+	Dismissed    *bool
+}
@@ -0,0 +1,57 @@
+package onboarding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository interface {
+	// GetPreferences returns the stored onboarding preferences of the user, or empty preferences when none are stored.
+	GetPreferences(ctx context.Context, userId int) (Preferences, error)
+	StorePreferences(ctx context.Context, userId int, preferences Preferences) error
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+func (r *RepositoryImpl) GetPreferences(ctx context.Context, userId int) (Preferences, error) {
+	query := `SELECT skipped_steps, dismissed FROM user_onboarding WHERE user_id = $1`
+	var skippedSteps []string
+	var preferences Preferences
+	err := r.db.QueryRow(ctx, query, userId).Scan(&skippedSteps, &preferences.Dismissed)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Preferences{}, nil
+		}
+		return Preferences{}, fmt.Errorf("failed to get onboarding preferences: %w", err)
+	}
+	for _, step := range skippedSteps {
+		preferences.SkippedSteps = append(preferences.SkippedSteps, Step(step))
+	}
+	return preferences, nil
+}
+
+func (r *RepositoryImpl) StorePreferences(ctx context.Context, userId int, preferences Preferences) error {
+	skippedSteps := make([]string, 0, len(preferences.SkippedSteps))
+	for _, step := range preferences.SkippedSteps {
+		skippedSteps = append(skippedSteps, string(step))
+	}
+	query := `INSERT INTO user_onboarding (user_id, skipped_steps, dismissed) VALUES ($1, $2, $3)
+			  ON CONFLICT (user_id) DO UPDATE SET skipped_steps = EXCLUDED.skipped_steps,
+			                                      dismissed = EXCLUDED.dismissed,
+			                                      updated_at = NOW()`
+	_, err := r.db.Exec(ctx, query, userId, skippedSteps, preferences.Dismissed)
+	if err != nil {
+		return fmt.Errorf("failed to store onboarding preferences: %w", err)
+	}
+	return nil
+}
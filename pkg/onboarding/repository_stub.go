@@ -0,0 +1,28 @@
+package onboarding
+
+import (
+	"context"
+	"sync"
+)
+
+type RepositoryStub struct {
+	mu          sync.RWMutex
+	preferences map[int]Preferences // userId -> preferences
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{preferences: make(map[int]Preferences)}
+}
+
+func (r *RepositoryStub) GetPreferences(ctx context.Context, userId int) (Preferences, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.preferences[userId], nil
+}
+
+func (r *RepositoryStub) StorePreferences(ctx context.Context, userId int, preferences Preferences) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.preferences[userId] = preferences
+	return nil
+}
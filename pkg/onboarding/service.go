@@ -0,0 +1,188 @@
+package onboarding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/current_event"
+	"github.com/klokku/klokku/pkg/user"
+)
+
+var ErrInvalidStep = errors.New("invalid onboarding step")
+var ErrStepNotSkippable = errors.New("onboarding step cannot be skipped")
+
+type Service interface {
+	// GetOnboarding returns the onboarding progress of the current user, evaluated against the user's data.
+	GetOnboarding(ctx context.Context) (Onboarding, error)
+	UpdatePreferences(ctx context.Context, update PreferencesUpdate) (Onboarding, error)
+}
+
+type budgetPlanReader interface {
+	ListPlans(ctx context.Context) ([]budget_plan.BudgetPlan, error)
+	GetCurrentPlan(ctx context.Context) (budget_plan.BudgetPlan, error)
+}
+
+type calendarEventsReader interface {
+	GetLastEvents(ctx context.Context, limit int) ([]calendar.Event, error)
+}
+
+type currentEventProvider interface {
+	FindCurrentEvent(ctx context.Context) (current_event.CurrentEvent, error)
+}
+
+type ServiceImpl struct {
+	repo                 Repository
+	budgetPlanReader     budgetPlanReader
+	calendar             calendarEventsReader
+	currentEventProvider currentEventProvider
+}
+
+func NewService(
+	repo Repository,
+	budgetPlanReader budgetPlanReader,
+	calendar calendarEventsReader,
+	currentEventProvider currentEventProvider,
+) *ServiceImpl {
+	return &ServiceImpl{
+		repo:                 repo,
+		budgetPlanReader:     budgetPlanReader,
+		calendar:             calendar,
+		currentEventProvider: currentEventProvider,
+	}
+}
+
+func (s *ServiceImpl) GetOnboarding(ctx context.Context) (Onboarding, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Onboarding{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	preferences, err := s.repo.GetPreferences(ctx, userId)
+	if err != nil {
+		return Onboarding{}, err
+	}
+	return s.evaluate(ctx, preferences)
+}
+
+func (s *ServiceImpl) UpdatePreferences(ctx context.Context, update PreferencesUpdate) (Onboarding, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Onboarding{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	preferences, err := s.repo.GetPreferences(ctx, userId)
+	if err != nil {
+		return Onboarding{}, err
+	}
+
+	if update.SkippedSteps != nil {
+		skippedSteps := make([]Step, 0, len(*update.SkippedSteps))
+		for _, step := range *update.SkippedSteps {
+			if !step.IsValid() {
+				return Onboarding{}, fmt.Errorf("%w: %s", ErrInvalidStep, step)
+			}
+			if !step.IsSkippable() {
+				return Onboarding{}, fmt.Errorf("%w: %s", ErrStepNotSkippable, step)
+			}
+			if !containsStep(skippedSteps, step) {
+				skippedSteps = append(skippedSteps, step)
+			}
+		}
+		preferences.SkippedSteps = skippedSteps
+	}
+	if update.Dismissed != nil {
+		preferences.Dismissed = *update.Dismissed
+	}
+
+	if err := s.repo.StorePreferences(ctx, userId, preferences); err != nil {
+		return Onboarding{}, err
+	}
+	return s.evaluate(ctx, preferences)
+}
+
+func (s *ServiceImpl) evaluate(ctx context.Context, preferences Preferences) (Onboarding, error) {
+	onboarding := Onboarding{
+		Steps:     make([]StepState, 0, len(Steps)),
+		Completed: true,
+		Dismissed: preferences.Dismissed,
+	}
+	for _, step := range Steps {
+		completed, err := s.isCompleted(ctx, step)
+		if err != nil {
+			return Onboarding{}, fmt.Errorf("failed to check onboarding step %s: %w", step, err)
+		}
+		status := StepPending
+		if completed {
+			status = StepCompleted
+		} else if containsStep(preferences.SkippedSteps, step) {
+			status = StepSkipped
+		}
+		if status == StepPending && onboarding.CurrentStep == "" {
+			onboarding.CurrentStep = step
+			onboarding.Completed = false
+		}
+		onboarding.Steps = append(onboarding.Steps, StepState{Step: step, Status: status})
+	}
+	return onboarding, nil
+}
+
+func (s *ServiceImpl) isCompleted(ctx context.Context, step Step) (bool, error) {
+	switch step {
+	case StepCreatePlan:
+		plans, err := s.budgetPlanReader.ListPlans(ctx)
+		if err != nil {
+			return false, err
+		}
+		return len(plans) > 0, nil
+	case StepAddItems:
+		plan, err := s.budgetPlanReader.GetCurrentPlan(ctx)
+		if err != nil {
+			if errors.Is(err, budget_plan.ErrPlanNotFound) {
+				return false, nil
+			}
+			return false, err
+		}
+		return len(plan.Items) > 0, nil
+	case StepConnectCalendar:
+		currentUser, err := user.CurrentUser(ctx)
+		if err != nil {
+			return false, fmt.Errorf("failed to get current user: %w", err)
+		}
+		return isCalendarConnected(currentUser.Settings), nil
+	case StepFirstTrackedEvent:
+		currentEvent, err := s.currentEventProvider.FindCurrentEvent(ctx)
+		if err != nil {
+			return false, err
+		}
+		if currentEvent.Id != 0 {
+			return true, nil
+		}
+		events, err := s.calendar.GetLastEvents(ctx, 1)
+		if err != nil {
+			return false, err
+		}
+		return len(events) > 0, nil
+	}
+	return false, fmt.Errorf("%w: %s", ErrInvalidStep, step)
+}
+
+// isCalendarConnected reports whether the user keeps the events in an external calendar and has chosen it.
+func isCalendarConnected(settings user.Settings) bool {
+	switch settings.EventCalendarType {
+	case user.GoogleCalendar:
+		return settings.GoogleCalendar.CalendarId != ""
+	case user.OutlookCalendar:
+		return settings.OutlookCalendar.CalendarId != ""
+	}
+	return false
+}
+
+func containsStep(steps []Step, step Step) bool {
+	for _, s := range steps {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
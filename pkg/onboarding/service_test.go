@@ -0,0 +1,226 @@
+package onboarding
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/current_event"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type budgetPlanReaderStub struct {
+	plans []budget_plan.BudgetPlan
+}
+
+func (s *budgetPlanReaderStub) ListPlans(ctx context.Context) ([]budget_plan.BudgetPlan, error) {
+	return s.plans, nil
+}
+
+func (s *budgetPlanReaderStub) GetCurrentPlan(ctx context.Context) (budget_plan.BudgetPlan, error) {
+	for _, plan := range s.plans {
+		if plan.IsCurrent {
+			return plan, nil
+		}
+	}
+	return budget_plan.BudgetPlan{}, budget_plan.ErrPlanNotFound
+}
+
+type currentEventProviderStub struct {
+	event current_event.CurrentEvent
+}
+
+func (s *currentEventProviderStub) FindCurrentEvent(ctx context.Context) (current_event.CurrentEvent, error) {
+	return s.event, nil
+}
+
+type testEnv struct {
+	service      *ServiceImpl
+	plans        *budgetPlanReaderStub
+	calendar     *calendar.StubCalendar
+	currentEvent *currentEventProviderStub
+	ctx          context.Context
+}
+
+func setupServiceTest(t *testing.T) testEnv {
+	t.Helper()
+	env := testEnv{
+		plans:        &budgetPlanReaderStub{},
+		calendar:     calendar.NewStubCalendar(),
+		currentEvent: &currentEventProviderStub{},
+		ctx: user.WithUser(context.Background(), user.User{
+			Id:       1,
+			Uid:      "user-1",
+			Username: "test-user-1",
+			Settings: user.Settings{Timezone: "Europe/Warsaw", EventCalendarType: user.KlokkuCalendar},
+		}),
+	}
+	env.service = NewService(NewRepositoryStub(), env.plans, env.calendar, env.currentEvent)
+	return env
+}
+
+func statuses(onboarding Onboarding) map[Step]StepStatus {
+	result := make(map[Step]StepStatus)
+	for _, state := range onboarding.Steps {
+		result[state.Step] = state.Status
+	}
+	return result
+}
+
+func TestServiceImpl_GetOnboarding(t *testing.T) {
+	t.Run("should start with creating a plan for a new user", func(t *testing.T) {
+		env := setupServiceTest(t)
+
+		onboarding, err := env.service.GetOnboarding(env.ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, StepCreatePlan, onboarding.CurrentStep)
+		assert.False(t, onboarding.Completed)
+		require.Len(t, onboarding.Steps, len(Steps))
+		for _, state := range onboarding.Steps {
+			assert.Equal(t, StepPending, state.Status)
+		}
+	})
+
+	t.Run("should complete steps based on user data", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.plans.plans = []budget_plan.BudgetPlan{{Id: 1, IsCurrent: true, Items: []budget_plan.BudgetItem{{Id: 1}}}}
+
+		onboarding, err := env.service.GetOnboarding(env.ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, StepConnectCalendar, onboarding.CurrentStep)
+		assert.Equal(t, StepCompleted, statuses(onboarding)[StepCreatePlan])
+		assert.Equal(t, StepCompleted, statuses(onboarding)[StepAddItems])
+	})
+
+	t.Run("should keep adding items pending when the current plan is empty", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.plans.plans = []budget_plan.BudgetPlan{{Id: 1, IsCurrent: true}}
+
+		onboarding, err := env.service.GetOnboarding(env.ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, StepAddItems, onboarding.CurrentStep)
+	})
+
+	t.Run("should complete connecting calendar when google calendar is configured", func(t *testing.T) {
+		env := setupServiceTest(t)
+		ctx := user.WithUser(env.ctx, user.User{Id: 1, Settings: user.Settings{
+			Timezone:          "Europe/Warsaw",
+			EventCalendarType: user.GoogleCalendar,
+			GoogleCalendar:    user.GoogleCalendarSettings{CalendarId: "calendar-1"},
+		}})
+
+		onboarding, err := env.service.GetOnboarding(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, StepCompleted, statuses(onboarding)[StepConnectCalendar])
+	})
+
+	t.Run("should complete connecting calendar when outlook calendar is configured", func(t *testing.T) {
+		env := setupServiceTest(t)
+		ctx := user.WithUser(env.ctx, user.User{Id: 1, Settings: user.Settings{
+			Timezone:          "Europe/Warsaw",
+			EventCalendarType: user.OutlookCalendar,
+			OutlookCalendar:   user.OutlookCalendarSettings{CalendarId: "calendar-1"},
+		}})
+
+		onboarding, err := env.service.GetOnboarding(ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, StepCompleted, statuses(onboarding)[StepConnectCalendar])
+	})
+
+	t.Run("should complete first tracked event when there is a running event", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.currentEvent.event = current_event.CurrentEvent{Id: 5, StartTime: time.Now()}
+
+		onboarding, err := env.service.GetOnboarding(env.ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, StepCompleted, statuses(onboarding)[StepFirstTrackedEvent])
+	})
+
+	t.Run("should complete first tracked event when there is a calendar event", func(t *testing.T) {
+		env := setupServiceTest(t)
+		start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+		_, err := env.calendar.AddEvent(env.ctx, calendar.Event{StartTime: start, EndTime: start.Add(time.Hour)})
+		require.NoError(t, err)
+
+		onboarding, err := env.service.GetOnboarding(env.ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, StepCompleted, statuses(onboarding)[StepFirstTrackedEvent])
+	})
+
+	t.Run("should return error when context has no user", func(t *testing.T) {
+		env := setupServiceTest(t)
+
+		_, err := env.service.GetOnboarding(context.Background())
+
+		assert.Error(t, err)
+	})
+}
+
+func TestServiceImpl_UpdatePreferences(t *testing.T) {
+	t.Run("should skip connecting calendar and move to the next step", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.plans.plans = []budget_plan.BudgetPlan{{Id: 1, IsCurrent: true, Items: []budget_plan.BudgetItem{{Id: 1}}}}
+
+		onboarding, err := env.service.UpdatePreferences(env.ctx, PreferencesUpdate{SkippedSteps: &[]Step{StepConnectCalendar}})
+
+		require.NoError(t, err)
+		assert.Equal(t, StepSkipped, statuses(onboarding)[StepConnectCalendar])
+		assert.Equal(t, StepFirstTrackedEvent, onboarding.CurrentStep)
+
+		reloaded, err := env.service.GetOnboarding(env.ctx)
+		require.NoError(t, err)
+		assert.Equal(t, onboarding, reloaded)
+	})
+
+	t.Run("should be completed when all steps are completed or skipped", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.plans.plans = []budget_plan.BudgetPlan{{Id: 1, IsCurrent: true, Items: []budget_plan.BudgetItem{{Id: 1}}}}
+		env.currentEvent.event = current_event.CurrentEvent{Id: 5}
+
+		onboarding, err := env.service.UpdatePreferences(env.ctx, PreferencesUpdate{SkippedSteps: &[]Step{StepConnectCalendar}})
+
+		require.NoError(t, err)
+		assert.True(t, onboarding.Completed)
+		assert.Empty(t, onboarding.CurrentStep)
+	})
+
+	t.Run("should reject skipping a step that is not skippable", func(t *testing.T) {
+		env := setupServiceTest(t)
+
+		_, err := env.service.UpdatePreferences(env.ctx, PreferencesUpdate{SkippedSteps: &[]Step{StepCreatePlan}})
+
+		assert.ErrorIs(t, err, ErrStepNotSkippable)
+	})
+
+	t.Run("should reject unknown step", func(t *testing.T) {
+		env := setupServiceTest(t)
+
+		_, err := env.service.UpdatePreferences(env.ctx, PreferencesUpdate{SkippedSteps: &[]Step{"unknown"}})
+
+		assert.ErrorIs(t, err, ErrInvalidStep)
+	})
+
+	t.Run("should dismiss onboarding without changing skipped steps", func(t *testing.T) {
+		env := setupServiceTest(t)
+		_, err := env.service.UpdatePreferences(env.ctx, PreferencesUpdate{SkippedSteps: &[]Step{StepConnectCalendar}})
+		require.NoError(t, err)
+		dismissed := true
+
+		onboarding, err := env.service.UpdatePreferences(env.ctx, PreferencesUpdate{Dismissed: &dismissed})
+
+		require.NoError(t, err)
+		assert.True(t, onboarding.Dismissed)
+		assert.Equal(t, StepSkipped, statuses(onboarding)[StepConnectCalendar])
+	})
+}
@@ -0,0 +1,213 @@
+package outlook_calendar
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/pkg/credentials"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+// scopes lets Klokku read and write the user's calendars and refresh the token without the user.
+var scopes = []string{"offline_access", "Calendars.ReadWrite"}
+
+type outlookAuthRedirect struct {
+	RedirectUrl string `json:"redirectUrl"`
+}
+
+type OutlookAuth struct {
+	userService user.Service
+	oauthConfig *oauth2.Config
+	// httpClient sends the requests to Microsoft, the OAuth client authorizes them on top of it
+	httpClient  *http.Client
+	credentials credentials.Service
+}
+
+func NewOutlookAuth(userService user.Service, cfg config.Application, httpClient *http.Client,
+	credentialsService credentials.Service) *OutlookAuth {
+	oauthConfig := &oauth2.Config{
+		ClientID:     cfg.Microsoft.ClientId,
+		ClientSecret: cfg.Microsoft.ClientSecret,
+		Endpoint:     endpoints.AzureAD(cfg.Microsoft.Tenant),
+		RedirectURL:  cfg.Host + "/api/integrations/outlook/auth/callback",
+		Scopes:       scopes,
+	}
+
+	return &OutlookAuth{
+		userService: userService,
+		oauthConfig: oauthConfig,
+		httpClient:  httpClient,
+		credentials: credentialsService,
+	}
+}
+
+// OAuthLogin godoc
+// @Summary Initiate Microsoft OAuth login
+// @Description Start the OAuth flow to connect a Microsoft 365 / Outlook calendar
+// @Tags Outlook
+// @Produce json
+// @Param finalUrl query string false "URL to redirect to after authentication"
+// @Success 200 {object} object{redirectUrl=string} "OAuth redirect URL"
+// @Failure 403 {string} string "User not found"
+// @Router /api/integrations/outlook/auth/login [get]
+// @Security XUserId
+func (a *OutlookAuth) OAuthLogin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	currentUser, err := a.userService.GetCurrentUser(r.Context())
+	if err != nil {
+		log.Error("unable to retrieve current user: ", err)
+		http.Error(w, "unable to retrieve current user", http.StatusInternalServerError)
+		return
+	}
+
+	stateNonce := uuid.New().String()
+	finalUrl := r.URL.Query().Get("finalUrl")
+
+	// The token is kept until the new one arrives, so the calendar keeps working if the user abandons the flow
+	err = a.credentials.StartAuthentication(r.Context(), credentials.Outlook, currentUser.Id, stateNonce)
+	if err != nil {
+		log.Errorf("failed to store Outlook auth nonce for user %d: %v", currentUser.Id, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
+			Error: "Failed to handle Outlook authentication",
+		})
+		if encodeErr != nil {
+			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Tracef("Redirecting to Microsoft auth URL with nonce: %s", stateNonce)
+	u := a.oauthConfig.AuthCodeURL(finalUrl+"|"+stateNonce, oauth2.SetAuthURLParam("prompt", "select_account"))
+
+	w.WriteHeader(http.StatusOK)
+	encodeErr := json.NewEncoder(w).Encode(outlookAuthRedirect{
+		RedirectUrl: u,
+	})
+	if encodeErr != nil {
+		http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
+	}
+}
+
+// OAuthCallback godoc
+// @Summary Microsoft OAuth callback
+// @Description Handle the OAuth callback from Microsoft
+// @Tags Outlook
+// @Param code query string true "Authorization code"
+// @Param state query string true "State parameter"
+// @Success 302 "Redirect to finalUrl with success=true/false"
+// @Router /api/integrations/outlook/auth/callback [get]
+func (a *OutlookAuth) OAuthCallback(w http.ResponseWriter, r *http.Request) {
+	code := r.FormValue("code")
+	state := r.FormValue("state")
+
+	parts := strings.SplitN(state, "|", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		http.Error(w, "Invalid state", http.StatusBadRequest)
+		return
+	}
+	finalUrl := parts[0]
+	nonce := parts[1]
+
+	if code == "" {
+		log.Warnf("Outlook authorization was not granted: %s", r.FormValue("error_description"))
+		http.Redirect(w, r, finalUrl+"?success=false", http.StatusFound)
+		return
+	}
+
+	token, err := a.oauthConfig.Exchange(context.WithValue(r.Context(), oauth2.HTTPClient, a.httpClient), code)
+	if err != nil {
+		log.Errorf("unable to exchange code for token: %v", err)
+		http.Redirect(w, r, finalUrl+"?success=false", http.StatusFound)
+		return
+	}
+
+	_, err = a.credentials.StoreTokenByNonce(r.Context(), credentials.Outlook, nonce, token)
+	if err != nil {
+		log.Errorf("unable to store Outlook auth token for nonce %s: %v", nonce, err)
+		http.Redirect(w, r, finalUrl+"?success=false", http.StatusFound)
+		return
+	}
+	log.Debug("Successfully stored Outlook auth token for nonce: ", nonce)
+	http.Redirect(w, r, finalUrl+"?success=true", http.StatusFound)
+}
+
+// IsAuthenticated godoc
+// @Summary Check Outlook authentication status
+// @Description Check if the current user has connected a Microsoft account
+// @Tags Outlook
+// @Produce json
+// @Success 200 {string} string "true"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 "Not authenticated"
+// @Router /api/integrations/outlook/auth [get]
+// @Security XUserId
+func (a *OutlookAuth) IsAuthenticated(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userId, err := user.CurrentId(r.Context())
+	if err != nil {
+		log.Error("unable to retrieve current user: ", err)
+		http.Error(w, "unable to retrieve current user", http.StatusInternalServerError)
+		return
+	}
+	token, err := a.credentials.GetToken(r.Context(), credentials.Outlook, userId)
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if token == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("true"))
+}
+
+// Disconnect godoc
+// @Summary Disconnect Outlook
+// @Description Forget the Microsoft token of the current user. Events stay in the Outlook calendar; switch the
+// @Description event calendar back to klokku to keep tracking.
+// @Tags Outlook
+// @Success 204 "No Content"
+// @Failure 403 {string} string "User not found"
+// @Router /api/integrations/outlook/auth [delete]
+// @Security XUserId
+func (a *OutlookAuth) Disconnect(w http.ResponseWriter, r *http.Request) {
+	userId, err := user.CurrentId(r.Context())
+	if err != nil {
+		log.Error("unable to retrieve current user: ", err)
+		http.Error(w, "unable to retrieve current user", http.StatusInternalServerError)
+		return
+	}
+	if err := a.credentials.Revoke(r.Context(), credentials.Outlook, userId); err != nil {
+		log.Errorf("failed to delete Outlook auth of user %d: %v", userId, err)
+		http.Error(w, "Failed to disconnect Outlook", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Client returns an HTTP client authorized for Microsoft Graph on behalf of the user, nil when the user has not
+// connected a Microsoft account.
+func (a *OutlookAuth) Client(ctx context.Context, userId int) (*http.Client, error) {
+	token, err := a.credentials.GetToken(ctx, credentials.Outlook, userId)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, nil
+	}
+	// Microsoft rotates the refresh token on every refresh, the credentials store keeps the latest one
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, a.httpClient)
+	return oauth2.NewClient(ctx, a.credentials.TokenSource(ctx, credentials.Outlook, userId, a.oauthConfig, token)), nil
+}
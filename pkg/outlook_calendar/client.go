@@ -0,0 +1,241 @@
+package outlook_calendar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/klokku/klokku/pkg/user"
+)
+
+var (
+	ErrUnauthenticated = errors.New("outlook account not connected")
+	ErrEventNotFound   = errors.New("outlook event not found")
+)
+
+const (
+	graphBaseUrl = "https://graph.microsoft.com/v1.0"
+	// metadataPropertyId identifies the extended property holding the calendar.EventMetadata JSON of an event.
+	metadataPropertyId  = "String {6f1b0c0e-3f0a-4c7e-9d2a-6b1f4c1e8a57} Name KlokkuMetadata"
+	graphDateTimeLayout = "2006-01-02T15:04:05.9999999"
+	eventsPageSize      = 500
+	maxErrorLength      = 500
+)
+
+// Client calls Microsoft Graph on behalf of the current user.
+type Client interface {
+	ListCalendars(ctx context.Context) ([]Calendar, error)
+	// ListEvents returns the events of the calendar overlapping the period. An empty calendar id is the default one.
+	ListEvents(ctx context.Context, calendarId string, from time.Time, to time.Time) ([]GraphEvent, error)
+	// ListLastEvents returns up to limit events starting before the time, the latest first.
+	ListLastEvents(ctx context.Context, calendarId string, before time.Time, limit int) ([]GraphEvent, error)
+	GetEvent(ctx context.Context, eventId string) (GraphEvent, error)
+	CreateEvent(ctx context.Context, calendarId string, event GraphEvent) (GraphEvent, error)
+	UpdateEvent(ctx context.Context, event GraphEvent) (GraphEvent, error)
+	DeleteEvent(ctx context.Context, eventId string) error
+}
+
+type httpClientProvider interface {
+	Client(ctx context.Context, userId int) (*http.Client, error)
+}
+
+type GraphDateTime struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+type GraphBody struct {
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+type GraphExtendedProperty struct {
+	Id    string `json:"id"`
+	Value string `json:"value"`
+}
+
+type GraphEvent struct {
+	Id                            string                  `json:"id,omitempty"`
+	Subject                       string                  `json:"subject"`
+	Body                          *GraphBody              `json:"body,omitempty"`
+	Start                         GraphDateTime           `json:"start"`
+	End                           GraphDateTime           `json:"end"`
+	IsAllDay                      bool                    `json:"isAllDay,omitempty"`
+	SingleValueExtendedProperties []GraphExtendedProperty `json:"singleValueExtendedProperties,omitempty"`
+}
+
+type graphCalendar struct {
+	Id                string `json:"id"`
+	Name              string `json:"name"`
+	IsDefaultCalendar bool   `json:"isDefaultCalendar"`
+	CanEdit           bool   `json:"canEdit"`
+}
+
+type graphPage[T any] struct {
+	Value    []T    `json:"value"`
+	NextLink string `json:"@odata.nextLink"`
+}
+
+type ClientImpl struct {
+	auth    httpClientProvider
+	baseUrl string
+}
+
+func NewClient(auth *OutlookAuth) *ClientImpl {
+	return &ClientImpl{auth: auth, baseUrl: graphBaseUrl}
+}
+
+func (c *ClientImpl) ListCalendars(ctx context.Context) ([]Calendar, error) {
+	graphCalendars, err := listAll[graphCalendar](ctx, c, c.baseUrl+"/me/calendars?$select=id,name,isDefaultCalendar,canEdit")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Outlook calendars: %w", err)
+	}
+	calendars := make([]Calendar, 0, len(graphCalendars))
+	for _, gc := range graphCalendars {
+		calendars = append(calendars, Calendar{Id: gc.Id, Name: gc.Name, IsDefault: gc.IsDefaultCalendar, CanEdit: gc.CanEdit})
+	}
+	return calendars, nil
+}
+
+func (c *ClientImpl) ListEvents(ctx context.Context, calendarId string, from time.Time, to time.Time) ([]GraphEvent, error) {
+	query := url.Values{}
+	query.Set("startDateTime", from.UTC().Format(time.RFC3339))
+	query.Set("endDateTime", to.UTC().Format(time.RFC3339))
+	query.Set("$top", strconv.Itoa(eventsPageSize))
+	query.Set("$expand", expandMetadata())
+	events, err := listAll[GraphEvent](ctx, c, c.calendarUrl(calendarId)+"/calendarView?"+query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Outlook events: %w", err)
+	}
+	return events, nil
+}
+
+func (c *ClientImpl) ListLastEvents(ctx context.Context, calendarId string, before time.Time, limit int) ([]GraphEvent, error) {
+	query := url.Values{}
+	query.Set("$filter", fmt.Sprintf("start/dateTime le '%s'", before.UTC().Format(graphDateTimeLayout)))
+	query.Set("$orderby", "start/dateTime desc")
+	query.Set("$top", strconv.Itoa(limit))
+	query.Set("$expand", expandMetadata())
+	var page graphPage[GraphEvent]
+	if err := c.call(ctx, http.MethodGet, c.calendarUrl(calendarId)+"/events?"+query.Encode(), nil, &page); err != nil {
+		return nil, fmt.Errorf("failed to list last Outlook events: %w", err)
+	}
+	return page.Value, nil
+}
+
+func (c *ClientImpl) GetEvent(ctx context.Context, eventId string) (GraphEvent, error) {
+	var event GraphEvent
+	eventUrl := c.baseUrl + "/me/events/" + url.PathEscape(eventId) + "?$expand=" + url.QueryEscape(expandMetadata())
+	if err := c.call(ctx, http.MethodGet, eventUrl, nil, &event); err != nil {
+		return GraphEvent{}, fmt.Errorf("failed to get Outlook event: %w", err)
+	}
+	return event, nil
+}
+
+func (c *ClientImpl) CreateEvent(ctx context.Context, calendarId string, event GraphEvent) (GraphEvent, error) {
+	var created GraphEvent
+	if err := c.call(ctx, http.MethodPost, c.calendarUrl(calendarId)+"/events", event, &created); err != nil {
+		return GraphEvent{}, fmt.Errorf("failed to create Outlook event: %w", err)
+	}
+	return created, nil
+}
+
+func (c *ClientImpl) UpdateEvent(ctx context.Context, event GraphEvent) (GraphEvent, error) {
+	var updated GraphEvent
+	eventUrl := c.baseUrl + "/me/events/" + url.PathEscape(event.Id)
+	if err := c.call(ctx, http.MethodPatch, eventUrl, event, &updated); err != nil {
+		return GraphEvent{}, fmt.Errorf("failed to update Outlook event: %w", err)
+	}
+	return updated, nil
+}
+
+func (c *ClientImpl) DeleteEvent(ctx context.Context, eventId string) error {
+	if err := c.call(ctx, http.MethodDelete, c.baseUrl+"/me/events/"+url.PathEscape(eventId), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete Outlook event: %w", err)
+	}
+	return nil
+}
+
+func (c *ClientImpl) calendarUrl(calendarId string) string {
+	if calendarId == "" {
+		return c.baseUrl + "/me/calendar"
+	}
+	return c.baseUrl + "/me/calendars/" + url.PathEscape(calendarId)
+}
+
+func expandMetadata() string {
+	return fmt.Sprintf("singleValueExtendedProperties($filter=id eq '%s')", metadataPropertyId)
+}
+
+// listAll follows the @odata.nextLink of the pages.
+func listAll[T any](ctx context.Context, c *ClientImpl, pageUrl string) ([]T, error) {
+	result := make([]T, 0)
+	for pageUrl != "" {
+		var page graphPage[T]
+		if err := c.call(ctx, http.MethodGet, pageUrl, nil, &page); err != nil {
+			return nil, err
+		}
+		result = append(result, page.Value...)
+		pageUrl = page.NextLink
+	}
+	return result, nil
+}
+
+// call sends the request with the body encoded as JSON and decodes the response into result, when not nil.
+func (c *ClientImpl) call(ctx context.Context, method string, requestUrl string, body any, result any) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	client, err := c.auth.Client(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		return ErrUnauthenticated
+	}
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, requestUrl, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	// Times in responses are in UTC instead of the time zone of each event
+	req.Header.Set("Prefer", `outlook.timezone="UTC"`)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthenticated
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrEventNotFound
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		response, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(response))
+	}
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,58 @@
+package outlook_calendar
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type CalendarDTO struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	IsDefault bool   `json:"isDefault"`
+	CanEdit   bool   `json:"canEdit"`
+}
+
+type Handler struct {
+	client Client
+}
+
+func NewHandler(client Client) *Handler {
+	return &Handler{client: client}
+}
+
+// ListCalendars godoc
+// @Summary List Outlook calendars
+// @Description List the calendars of the connected Microsoft account. The id of the chosen one goes to
+// @Description settings.outlookCalendar.calendarId of the user, with eventCalendarType set to outlook.
+// @Tags Outlook
+// @Produce json
+// @Success 200 {array} CalendarDTO
+// @Failure 401 {string} string "Outlook not connected"
+// @Failure 403 {string} string "User not found"
+// @Router /api/integrations/outlook/calendars [get]
+// @Security XUserId
+func (h *Handler) ListCalendars(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	calendars, err := h.client.ListCalendars(r.Context())
+	if err != nil {
+		if errors.Is(err, ErrUnauthenticated) {
+			http.Error(w, "Outlook not connected", http.StatusUnauthorized)
+			return
+		}
+		log.Errorf("Failed to list Outlook calendars: %v", err)
+		http.Error(w, "Failed to list Outlook calendars", http.StatusInternalServerError)
+		return
+	}
+	dtos := make([]CalendarDTO, 0, len(calendars))
+	for _, c := range calendars {
+		dtos = append(dtos, CalendarDTO{Id: c.Id, Name: c.Name, IsDefault: c.IsDefault, CanEdit: c.CanEdit})
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		log.Errorf("Failed to encode Outlook calendars: %v", err)
+		http.Error(w, "Failed to encode Outlook calendars", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,282 @@
+package outlook_calendar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrInvalidEvent = errors.New("invalid event")
+
+// Calendar is one of the user's Outlook calendars events can be stored in.
+type Calendar struct {
+	Id        string
+	Name      string
+	IsDefault bool
+	CanEdit   bool
+}
+
+// OutlookCalendar implements calendar.Calendar on top of the user's Outlook calendar. Outlook is the only store of
+// the events, so changes made in Outlook show up in Klokku and the other way round. Klokku's event metadata is kept
+// in an extended property of the Outlook event; events created in Outlook have no budget item. Events in locked
+// weeks cannot be changed through Klokku, the same as in the Klokku calendar.
+type OutlookCalendar struct {
+	client          Client
+	eventBus        *event_bus.EventBus
+	weekLockChecker calendar.WeekLockCheckerFunc
+	clock           utils.Clock
+}
+
+// NewOutlookCalendar creates the Outlook calendar. A nil weekLockChecker disables week locks.
+func NewOutlookCalendar(client Client, eventBus *event_bus.EventBus, weekLockChecker calendar.WeekLockCheckerFunc) *OutlookCalendar {
+	return &OutlookCalendar{client: client, eventBus: eventBus, weekLockChecker: weekLockChecker, clock: &utils.SystemClock{}}
+}
+
+func (c *OutlookCalendar) calendarId(ctx context.Context) (string, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get current user: %w", err)
+	}
+	return currentUser.Settings.OutlookCalendar.CalendarId, nil
+}
+
+func (c *OutlookCalendar) AddEvent(ctx context.Context, event calendar.Event) ([]calendar.Event, error) {
+	if err := validateEvent(event); err != nil {
+		return nil, err
+	}
+	if err := c.weekLockChecker.CheckEvent(ctx, event); err != nil {
+		return nil, err
+	}
+	calendarId, err := c.calendarId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	graphEvent, err := toGraphEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	created, err := c.client.CreateEvent(ctx, calendarId, graphEvent)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := fromGraphEvent(created)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.eventBus != nil {
+		err = c.eventBus.Publish(event_bus.NewEvent(ctx, "calendar.event.created", event_bus.CalendarEventCreated{
+			UID:          stored.UID,
+			Summary:      stored.Summary,
+			StartTime:    stored.StartTime,
+			EndTime:      stored.EndTime,
+			BudgetItemId: stored.Metadata.BudgetItemId,
+			TaskId:       stored.Metadata.TaskId,
+			Sandbox:      stored.Metadata.Sandbox,
+		}))
+		if err != nil {
+			return nil, fmt.Errorf("failed to publish event creation: %w", err)
+		}
+	}
+	return []calendar.Event{stored}, nil
+}
+
+func (c *OutlookCalendar) GetEvents(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error) {
+	calendarId, err := c.calendarId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	graphEvents, err := c.client.ListEvents(ctx, calendarId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return fromGraphEvents(graphEvents), nil
+}
+
+// GetEventsIncludingArchive is GetEvents, Outlook events are never archived by Klokku.
+func (c *OutlookCalendar) GetEventsIncludingArchive(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error) {
+	return c.GetEvents(ctx, from, to)
+}
+
+func (c *OutlookCalendar) ModifyEvent(ctx context.Context, event calendar.Event) ([]calendar.Event, error) {
+	if err := validateEvent(event); err != nil {
+		return nil, err
+	}
+	if err := c.checkStoredEventNotLocked(ctx, event.UID); err != nil {
+		return nil, err
+	}
+	if err := c.weekLockChecker.CheckEvent(ctx, event); err != nil {
+		return nil, err
+	}
+	graphEvent, err := toGraphEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := c.client.UpdateEvent(ctx, graphEvent)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := fromGraphEvent(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.eventBus != nil {
+		err = c.eventBus.Publish(event_bus.NewEvent(ctx, "calendar.event.updated", event_bus.CalendarEventUpdated{
+			UID:          stored.UID,
+			Summary:      stored.Summary,
+			StartTime:    stored.StartTime,
+			EndTime:      stored.EndTime,
+			BudgetItemId: stored.Metadata.BudgetItemId,
+			TaskId:       stored.Metadata.TaskId,
+			Sandbox:      stored.Metadata.Sandbox,
+		}))
+		if err != nil {
+			return nil, fmt.Errorf("failed to publish event update: %w", err)
+		}
+	}
+	return []calendar.Event{stored}, nil
+}
+
+func (c *OutlookCalendar) GetLastEvents(ctx context.Context, limit int) ([]calendar.Event, error) {
+	calendarId, err := c.calendarId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	graphEvents, err := c.client.ListLastEvents(ctx, calendarId, c.clock.Now(), limit)
+	if err != nil {
+		return nil, err
+	}
+	return fromGraphEvents(graphEvents), nil
+}
+
+func (c *OutlookCalendar) DeleteEvent(ctx context.Context, eventUid string) error {
+	if err := c.checkStoredEventNotLocked(ctx, eventUid); err != nil {
+		return err
+	}
+	if err := c.client.DeleteEvent(ctx, eventUid); err != nil {
+		return err
+	}
+	if c.eventBus != nil {
+		err := c.eventBus.Publish(event_bus.NewEvent(ctx, "calendar.event.deleted", event_bus.CalendarEventDeleted{UID: eventUid}))
+		if err != nil {
+			return fmt.Errorf("failed to publish event deletion: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkStoredEventNotLocked checks the event as currently stored in Outlook, so it cannot be moved out of a locked
+// week. Unknown events are left to the caller to report.
+func (c *OutlookCalendar) checkStoredEventNotLocked(ctx context.Context, eventUid string) error {
+	if c.weekLockChecker == nil {
+		return nil
+	}
+	graphEvent, err := c.client.GetEvent(ctx, eventUid)
+	if err != nil {
+		if errors.Is(err, ErrEventNotFound) {
+			return nil
+		}
+		return err
+	}
+	stored, err := fromGraphEvent(graphEvent)
+	if err != nil {
+		return err
+	}
+	return c.weekLockChecker.CheckEvent(ctx, stored)
+}
+
+func validateEvent(event calendar.Event) error {
+	if strings.TrimSpace(event.Summary) == "" {
+		return fmt.Errorf("%w: summary is required", ErrInvalidEvent)
+	}
+	if !event.EndTime.After(event.StartTime) {
+		return fmt.Errorf("%w: end time must be after start time", ErrInvalidEvent)
+	}
+	return nil
+}
+
+func toGraphEvent(event calendar.Event) (GraphEvent, error) {
+	metadata, err := json.Marshal(event.Metadata)
+	if err != nil {
+		return GraphEvent{}, fmt.Errorf("failed to marshal event metadata: %w", err)
+	}
+	return GraphEvent{
+		Id:      event.UID,
+		Subject: event.Summary,
+		Body:    &GraphBody{ContentType: "text", Content: event.Metadata.Notes},
+		Start:   GraphDateTime{DateTime: event.StartTime.UTC().Format(graphDateTimeLayout), TimeZone: "UTC"},
+		End:     GraphDateTime{DateTime: event.EndTime.UTC().Format(graphDateTimeLayout), TimeZone: "UTC"},
+		SingleValueExtendedProperties: []GraphExtendedProperty{
+			{Id: metadataPropertyId, Value: string(metadata)},
+		},
+	}, nil
+}
+
+func fromGraphEvent(graphEvent GraphEvent) (calendar.Event, error) {
+	start, err := parseGraphDateTime(graphEvent.Start)
+	if err != nil {
+		return calendar.Event{}, err
+	}
+	end, err := parseGraphDateTime(graphEvent.End)
+	if err != nil {
+		return calendar.Event{}, err
+	}
+	event := calendar.Event{
+		UID:       graphEvent.Id,
+		Summary:   graphEvent.Subject,
+		StartTime: start,
+		EndTime:   end,
+	}
+	for _, property := range graphEvent.SingleValueExtendedProperties {
+		if !strings.EqualFold(property.Id, metadataPropertyId) {
+			continue
+		}
+		if err := json.Unmarshal([]byte(property.Value), &event.Metadata); err != nil {
+			log.Warnf("ignoring invalid metadata of Outlook event %s: %v", graphEvent.Id, err)
+		}
+	}
+	return event, nil
+}
+
+// fromGraphEvents skips all-day events, they are not time tracked in Outlook.
+func fromGraphEvents(graphEvents []GraphEvent) []calendar.Event {
+	events := make([]calendar.Event, 0, len(graphEvents))
+	for _, graphEvent := range graphEvents {
+		if graphEvent.IsAllDay {
+			continue
+		}
+		event, err := fromGraphEvent(graphEvent)
+		if err != nil {
+			log.Warnf("ignoring Outlook event %s: %v", graphEvent.Id, err)
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func parseGraphDateTime(dateTime GraphDateTime) (time.Time, error) {
+	location := time.UTC
+	if dateTime.TimeZone != "" && dateTime.TimeZone != "UTC" {
+		loaded, err := time.LoadLocation(dateTime.TimeZone)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unsupported time zone %q: %w", dateTime.TimeZone, err)
+		}
+		location = loaded
+	}
+	parsed, err := time.ParseInLocation(graphDateTimeLayout, dateTime.DateTime, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date time %q: %w", dateTime.DateTime, err)
+	}
+	return parsed.UTC(), nil
+}
@@ -0,0 +1,290 @@
+package outlook_calendar
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+
+// fakeGraph keeps events in memory and serves the subset of Microsoft Graph the client uses.
+type fakeGraph struct {
+	mu       sync.Mutex
+	events   map[string]GraphEvent
+	nextId   int
+	requests []string
+}
+
+func (g *fakeGraph) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.requests = append(g.requests, r.Method+" "+r.URL.Path)
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/me/calendars":
+		writeJSON(w, map[string]any{
+			"value":           []graphCalendar{{Id: "cal-1", Name: "Calendar", IsDefaultCalendar: true, CanEdit: true}},
+			"@odata.nextLink": "http://" + r.Host + "/me/calendars/page2",
+		})
+	case r.Method == http.MethodGet && r.URL.Path == "/me/calendars/page2":
+		writeJSON(w, map[string]any{"value": []graphCalendar{{Id: "cal-2", Name: "Work"}}})
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/events"):
+		var event GraphEvent
+		_ = json.NewDecoder(r.Body).Decode(&event)
+		g.nextId++
+		event.Id = "evt-" + strconv.Itoa(g.nextId)
+		g.events[event.Id] = event
+		writeJSON(w, event)
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/calendarView"):
+		events := make([]GraphEvent, 0)
+		for _, event := range g.events {
+			events = append(events, event)
+		}
+		writeJSON(w, map[string]any{"value": events})
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/me/events/"):
+		event, ok := g.events[strings.TrimPrefix(r.URL.Path, "/me/events/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJSON(w, event)
+	case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, "/me/events/"):
+		id := strings.TrimPrefix(r.URL.Path, "/me/events/")
+		if _, ok := g.events[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var event GraphEvent
+		_ = json.NewDecoder(r.Body).Decode(&event)
+		event.Id = id
+		g.events[id] = event
+		writeJSON(w, event)
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/me/events/"):
+		delete(g.events, strings.TrimPrefix(r.URL.Path, "/me/events/"))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// tokenAuth authorizes the requests of connected users with a static token.
+type tokenAuth struct {
+	connected map[int]bool
+}
+
+type bearerTransport struct{}
+
+func (bearerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer test-token")
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func (a tokenAuth) Client(ctx context.Context, userId int) (*http.Client, error) {
+	if !a.connected[userId] {
+		return nil, nil
+	}
+	return &http.Client{Transport: bearerTransport{}}, nil
+}
+
+type testEnv struct {
+	calendar *OutlookCalendar
+	client   *ClientImpl
+	graph    *fakeGraph
+	eventBus *event_bus.EventBus
+	ctx      context.Context
+}
+
+func setupTest(t *testing.T, calendarId string) testEnv {
+	t.Helper()
+	graph := &fakeGraph{events: make(map[string]GraphEvent)}
+	server := httptest.NewServer(graph)
+	t.Cleanup(server.Close)
+	client := &ClientImpl{auth: tokenAuth{connected: map[int]bool{1: true}}, baseUrl: server.URL}
+	eventBus := event_bus.NewEventBus()
+	outlookCalendar := NewOutlookCalendar(client, eventBus, nil)
+	outlookCalendar.clock = &utils.MockClock{FixedNow: now}
+	testUser := user.User{Id: 1, Uid: "user-1", Username: "test-user-1", Settings: user.Settings{
+		EventCalendarType: user.OutlookCalendar,
+		OutlookCalendar:   user.OutlookCalendarSettings{CalendarId: calendarId},
+	}}
+	return testEnv{
+		calendar: outlookCalendar,
+		client:   client,
+		graph:    graph,
+		eventBus: eventBus,
+		ctx:      user.WithUser(context.Background(), testUser),
+	}
+}
+
+func TestAddEvent_StoresMetadataAndPublishesCreation(t *testing.T) {
+	env := setupTest(t, "cal-1")
+	var published []event_bus.CalendarEventCreated
+	event_bus.SubscribeTyped[event_bus.CalendarEventCreated](env.eventBus, "calendar.event.created",
+		func(e event_bus.EventT[event_bus.CalendarEventCreated]) error {
+			published = append(published, e.Data)
+			return nil
+		})
+
+	stored, err := env.calendar.AddEvent(env.ctx, calendar.Event{
+		Summary:   "Reading",
+		StartTime: now.Add(-time.Hour),
+		EndTime:   now,
+		Metadata:  calendar.EventMetadata{BudgetItemId: 10, Notes: "chapter 3"},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, stored, 1)
+	assert.Equal(t, "evt-1", stored[0].UID)
+	assert.Equal(t, now.Add(-time.Hour), stored[0].StartTime)
+	assert.Equal(t, now, stored[0].EndTime)
+	assert.Equal(t, calendar.EventMetadata{BudgetItemId: 10, Notes: "chapter 3"}, stored[0].Metadata)
+	assert.Equal(t, []string{"POST /me/calendars/cal-1/events"}, env.graph.requests)
+	assert.Equal(t, "chapter 3", env.graph.events["evt-1"].Body.Content)
+	require.Len(t, published, 1)
+	assert.Equal(t, 10, published[0].BudgetItemId)
+}
+
+func TestGetEvents_ReadsEventsCreatedInOutlook(t *testing.T) {
+	env := setupTest(t, "")
+	env.graph.events["outlook-1"] = GraphEvent{
+		Id:      "outlook-1",
+		Subject: "Team meeting",
+		Start:   GraphDateTime{DateTime: "2025-06-10T09:00:00.0000000", TimeZone: "UTC"},
+		End:     GraphDateTime{DateTime: "2025-06-10T10:30:00.0000000", TimeZone: "UTC"},
+	}
+	env.graph.events["outlook-2"] = GraphEvent{
+		Id:       "outlook-2",
+		Subject:  "Holiday",
+		IsAllDay: true,
+		Start:    GraphDateTime{DateTime: "2025-06-10T00:00:00.0000000", TimeZone: "UTC"},
+		End:      GraphDateTime{DateTime: "2025-06-11T00:00:00.0000000", TimeZone: "UTC"},
+	}
+
+	events, err := env.calendar.GetEvents(env.ctx, now.Add(-24*time.Hour), now)
+	require.NoError(t, err)
+
+	assert.Equal(t, []calendar.Event{{
+		UID:       "outlook-1",
+		Summary:   "Team meeting",
+		StartTime: time.Date(2025, 6, 10, 9, 0, 0, 0, time.UTC),
+		EndTime:   time.Date(2025, 6, 10, 10, 30, 0, 0, time.UTC),
+	}}, events)
+	assert.Equal(t, []string{"GET /me/calendar/calendarView"}, env.graph.requests)
+}
+
+func TestModifyAndDeleteEvent(t *testing.T) {
+	env := setupTest(t, "cal-1")
+	stored, err := env.calendar.AddEvent(env.ctx, calendar.Event{
+		Summary:   "Reading",
+		StartTime: now.Add(-time.Hour),
+		EndTime:   now,
+	})
+	require.NoError(t, err)
+
+	modified := stored[0]
+	modified.EndTime = now.Add(30 * time.Minute)
+	modified.Metadata.BudgetItemId = 11
+	updated, err := env.calendar.ModifyEvent(env.ctx, modified)
+	require.NoError(t, err)
+	assert.Equal(t, []calendar.Event{modified}, updated)
+
+	require.NoError(t, env.calendar.DeleteEvent(env.ctx, modified.UID))
+	assert.Empty(t, env.graph.events)
+
+	_, err = env.calendar.ModifyEvent(env.ctx, modified)
+	assert.ErrorIs(t, err, ErrEventNotFound)
+}
+
+func TestLockedWeeks(t *testing.T) {
+	env := setupTest(t, "cal-1")
+	// The week before now is locked
+	lockedUntil := now.Add(-7 * 24 * time.Hour)
+	env.calendar.weekLockChecker = func(ctx context.Context, date time.Time) (bool, error) {
+		return date.Before(lockedUntil), nil
+	}
+	locked := GraphEvent{
+		Id:      "outlook-1",
+		Subject: "Reading",
+		Start:   GraphDateTime{DateTime: "2025-06-02T09:00:00.0000000", TimeZone: "UTC"},
+		End:     GraphDateTime{DateTime: "2025-06-02T10:00:00.0000000", TimeZone: "UTC"},
+	}
+	env.graph.events[locked.Id] = locked
+
+	_, addErr := env.calendar.AddEvent(env.ctx, calendar.Event{
+		Summary:   "Reading",
+		StartTime: lockedUntil.Add(-time.Hour),
+		EndTime:   lockedUntil,
+	})
+	// Moving the event out of the locked week is not allowed either
+	_, modifyErr := env.calendar.ModifyEvent(env.ctx, calendar.Event{
+		UID:       locked.Id,
+		Summary:   "Reading",
+		StartTime: now.Add(-time.Hour),
+		EndTime:   now,
+	})
+	deleteErr := env.calendar.DeleteEvent(env.ctx, locked.Id)
+
+	assert.ErrorIs(t, addErr, weekly_plan.ErrWeekLocked)
+	assert.ErrorIs(t, modifyErr, weekly_plan.ErrWeekLocked)
+	assert.ErrorIs(t, deleteErr, weekly_plan.ErrWeekLocked)
+	assert.Equal(t, map[string]GraphEvent{locked.Id: locked}, env.graph.events)
+
+	stored, err := env.calendar.AddEvent(env.ctx, calendar.Event{Summary: "Reading", StartTime: now.Add(-time.Hour), EndTime: now})
+	require.NoError(t, err)
+	require.NoError(t, env.calendar.DeleteEvent(env.ctx, stored[0].UID))
+}
+
+func TestAddEvent_Validation(t *testing.T) {
+	env := setupTest(t, "")
+
+	_, err := env.calendar.AddEvent(env.ctx, calendar.Event{Summary: "Reading", StartTime: now, EndTime: now})
+
+	assert.ErrorIs(t, err, ErrInvalidEvent)
+	assert.Empty(t, env.graph.requests)
+}
+
+func TestListCalendars_FollowsNextLinks(t *testing.T) {
+	env := setupTest(t, "")
+
+	calendars, err := env.client.ListCalendars(env.ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Calendar{
+		{Id: "cal-1", Name: "Calendar", IsDefault: true, CanEdit: true},
+		{Id: "cal-2", Name: "Work"},
+	}, calendars)
+}
+
+func TestClient_NotConnected(t *testing.T) {
+	env := setupTest(t, "")
+	ctx := user.WithUser(context.Background(), user.User{Id: 2})
+
+	_, err := env.client.ListCalendars(ctx)
+
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	assert.Empty(t, env.graph.requests)
+}
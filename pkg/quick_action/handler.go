@@ -0,0 +1,221 @@
+package quick_action
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/internal/rest"
+	log "github.com/sirupsen/logrus"
+)
+
+type CreateDeviceDTO struct {
+	Name string `json:"name"`
+}
+
+type DeviceDTO struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+	// Url the device calls, with /next, /stop or /status appended. It works without any other authentication.
+	Url        string     `json:"url"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// StatusDTO is kept small for devices with little memory.
+type StatusDTO struct {
+	Tracking bool   `json:"tracking"`
+	Name     string `json:"name,omitempty"`
+	Color    string `json:"color,omitempty"`
+	// Elapsed is how long the current event runs in seconds
+	Elapsed int `json:"elapsed,omitempty"`
+	// Remaining is the time left of the item's weekly budget in seconds, negative when it is exceeded
+	Remaining int `json:"remaining,omitempty"`
+}
+
+type Handler struct {
+	host    string
+	service Service
+}
+
+func NewHandler(host string, service Service) *Handler {
+	return &Handler{host: host, service: service}
+}
+
+// CreateDevice godoc
+// @Summary Register a quick action device
+// @Description Register a device, e.g. a single hardware button or a Stream Deck, that controls tracking with its
+// @Description own URL instead of the user header.
+// @Tags QuickAction
+// @Accept json
+// @Produce json
+// @Param device body CreateDeviceDTO true "Device"
+// @Success 201 {object} DeviceDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid device"
+// @Failure 403 {string} string "User not found"
+// @Failure 409 {object} rest.ErrorResponse "Too many devices"
+// @Router /api/quick-devices [post]
+// @Security XUserId
+func (h *Handler) CreateDevice(w http.ResponseWriter, r *http.Request) {
+	var body CreateDeviceDTO
+	if err := rest.DecodeJSON(r, &body); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+
+	device, err := h.service.CreateDevice(r.Context(), body.Name)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidDevice):
+			rest.WriteBadRequest(w, rest.InvalidField("name", "Invalid device", err.Error()))
+		case errors.Is(err, ErrTooManyDevices):
+			rest.WriteError(w, http.StatusConflict, rest.ErrorResponse{Error: "Too many devices", Details: err.Error()})
+		default:
+			log.Errorf("Failed to create quick action device: %v", err)
+			http.Error(w, "Failed to create quick action device", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(h.deviceToDTO(device)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// ListDevices godoc
+// @Summary List quick action devices
+// @Tags QuickAction
+// @Produce json
+// @Success 200 {array} DeviceDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/quick-devices [get]
+// @Security XUserId
+func (h *Handler) ListDevices(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	devices, err := h.service.GetDevices(r.Context())
+	if err != nil {
+		log.Errorf("Failed to list quick action devices: %v", err)
+		http.Error(w, "Failed to list quick action devices", http.StatusInternalServerError)
+		return
+	}
+	dtos := make([]DeviceDTO, 0, len(devices))
+	for _, device := range devices {
+		dtos = append(dtos, h.deviceToDTO(device))
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// DeleteDevice godoc
+// @Summary Remove a quick action device
+// @Description Delete the device, its URL stops working
+// @Tags QuickAction
+// @Param id path int true "Device ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} rest.ErrorResponse "Invalid device ID"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Device not found"
+// @Router /api/quick-devices/{id} [delete]
+// @Security XUserId
+func (h *Handler) DeleteDevice(w http.ResponseWriter, r *http.Request) {
+	id, err := rest.PathInt(r, "id")
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	if err := h.service.DeleteDevice(r.Context(), id); err != nil {
+		if errors.Is(err, ErrDeviceNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to delete quick action device: %v", err)
+		http.Error(w, "Failed to delete quick action device", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Next godoc
+// @Summary Track the next item
+// @Description Start tracking the weekly plan item after the tracked one, the first item when nothing is tracked
+// @Description or the last item is, so a single button cycles through the plan.
+// @Tags QuickAction
+// @Produce json
+// @Param token path string true "Device token"
+// @Success 200 {object} StatusDTO
+// @Failure 404 {string} string "Invalid device token"
+// @Failure 409 {string} string "The weekly plan has no items"
+// @Router /api/quick/{token}/next [post]
+func (h *Handler) Next(w http.ResponseWriter, r *http.Request) {
+	status, err := h.service.Next(r.Context(), mux.Vars(r)["token"])
+	h.writeStatus(w, status, err)
+}
+
+// Stop godoc
+// @Summary Stop tracking
+// @Description Stop tracking, nothing happens when nothing is tracked
+// @Tags QuickAction
+// @Produce json
+// @Param token path string true "Device token"
+// @Success 200 {object} StatusDTO
+// @Failure 404 {string} string "Invalid device token"
+// @Router /api/quick/{token}/stop [post]
+func (h *Handler) Stop(w http.ResponseWriter, r *http.Request) {
+	status, err := h.service.Stop(r.Context(), mux.Vars(r)["token"])
+	h.writeStatus(w, status, err)
+}
+
+// Status godoc
+// @Summary Get tracking status
+// @Description The tracked item with the time it runs and the time left of its weekly budget
+// @Tags QuickAction
+// @Produce json
+// @Param token path string true "Device token"
+// @Success 200 {object} StatusDTO
+// @Failure 404 {string} string "Invalid device token"
+// @Router /api/quick/{token}/status [get]
+func (h *Handler) Status(w http.ResponseWriter, r *http.Request) {
+	status, err := h.service.Status(r.Context(), mux.Vars(r)["token"])
+	h.writeStatus(w, status, err)
+}
+
+func (h *Handler) writeStatus(w http.ResponseWriter, status Status, err error) {
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrDeviceNotFound):
+			http.Error(w, "Invalid device token", http.StatusNotFound)
+		case errors.Is(err, ErrNoPlanItems):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Errorf("Quick action failed: %v", err)
+			http.Error(w, "Quick action failed", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(StatusDTO{
+		Tracking:  status.Tracking,
+		Name:      status.Name,
+		Color:     status.Color,
+		Elapsed:   int(status.Elapsed.Seconds()),
+		Remaining: int(status.Remaining.Seconds()),
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) deviceToDTO(device Device) DeviceDTO {
+	return DeviceDTO{
+		Id:         device.Id,
+		Name:       device.Name,
+		Url:        h.host + "/api/quick/" + device.Token,
+		CreatedAt:  device.CreatedAt,
+		LastUsedAt: device.LastUsedAt,
+	}
+}
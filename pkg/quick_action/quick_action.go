@@ -0,0 +1,36 @@
+package quick_action
+
+import (
+	"time"
+)
+
+const (
+	MaxDevices       = 20
+	MaxDeviceNameLen = 100
+	// lastUsedPrecision is how often the use of a device is recorded at most, a device polling its status would
+	// otherwise write on every call.
+	lastUsedPrecision = time.Minute
+)
+
+// Device controls the tracking of its user with the token instead of the user header, e.g. a hardware button
+// cycling through the weekly plan items.
+type Device struct {
+	Id        int
+	UserId    int
+	Name      string
+	Token     string
+	CreatedAt time.Time
+	// LastUsedAt is nil until the device first called Klokku
+	LastUsedAt *time.Time
+}
+
+// Status is what the device shows of the tracking of its user.
+type Status struct {
+	Tracking bool
+	Name     string
+	Color    string
+	// Elapsed is how long the current event runs
+	Elapsed time.Duration
+	// Remaining is the time left of the tracked item's weekly budget, negative when it is exceeded
+	Remaining time.Duration
+}
@@ -0,0 +1,114 @@
+package quick_action
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrDeviceNotFound = errors.New("quick action device not found")
+
+type Repository interface {
+	// CreateDevice stores the device with a new token.
+	CreateDevice(ctx context.Context, device Device) (Device, error)
+	GetDevices(ctx context.Context, userId int) ([]Device, error)
+	// GetDeviceByToken returns ErrDeviceNotFound also for devices of disabled users.
+	GetDeviceByToken(ctx context.Context, token string) (Device, error)
+	MarkUsed(ctx context.Context, id int, usedAt time.Time) error
+	DeleteDevice(ctx context.Context, userId, id int) error
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+const deviceColumns = "id, user_id, name, token, created_at, last_used_at"
+
+func scanDevice(row pgx.Row) (Device, error) {
+	var device Device
+	err := row.Scan(&device.Id, &device.UserId, &device.Name, &device.Token, &device.CreatedAt, &device.LastUsedAt)
+	return device, err
+}
+
+func (r *RepositoryImpl) CreateDevice(ctx context.Context, device Device) (Device, error) {
+	token, err := generateToken()
+	if err != nil {
+		return Device{}, fmt.Errorf("failed to generate token: %w", err)
+	}
+	query := `INSERT INTO quick_action_device (user_id, name, token) VALUES ($1, $2, $3)
+			  RETURNING ` + deviceColumns
+	created, err := scanDevice(r.db.QueryRow(ctx, query, device.UserId, device.Name, token))
+	if err != nil {
+		return Device{}, fmt.Errorf("failed to create quick action device: %w", err)
+	}
+	return created, nil
+}
+
+func (r *RepositoryImpl) GetDevices(ctx context.Context, userId int) ([]Device, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+deviceColumns+` FROM quick_action_device WHERE user_id = $1 ORDER BY id`,
+		userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quick action devices: %w", err)
+	}
+	defer rows.Close()
+
+	devices := make([]Device, 0)
+	for rows.Next() {
+		device, err := scanDevice(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan quick action device: %w", err)
+		}
+		devices = append(devices, device)
+	}
+	return devices, rows.Err()
+}
+
+func (r *RepositoryImpl) GetDeviceByToken(ctx context.Context, token string) (Device, error) {
+	query := `SELECT ` + deviceColumns + ` FROM quick_action_device
+		WHERE token = $1 AND user_id IN (SELECT id FROM users WHERE NOT disabled)`
+	device, err := scanDevice(r.db.QueryRow(ctx, query, token))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Device{}, ErrDeviceNotFound
+		}
+		return Device{}, fmt.Errorf("failed to get quick action device: %w", err)
+	}
+	return device, nil
+}
+
+func (r *RepositoryImpl) MarkUsed(ctx context.Context, id int, usedAt time.Time) error {
+	_, err := r.db.Exec(ctx, "UPDATE quick_action_device SET last_used_at = $1 WHERE id = $2", usedAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark quick action device as used: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) DeleteDevice(ctx context.Context, userId, id int) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM quick_action_device WHERE user_id = $1 AND id = $2", userId, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete quick action device: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+func generateToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	_, err := rand.Read(tokenBytes)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", tokenBytes), nil
+}
@@ -0,0 +1,76 @@
+package quick_action
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type RepositoryStub struct {
+	mu      sync.Mutex
+	nextId  int
+	devices []Device
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{}
+}
+
+func (r *RepositoryStub) CreateDevice(ctx context.Context, device Device) (Device, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextId++
+	device.Id = r.nextId
+	device.Token = fmt.Sprintf("token-%d", r.nextId)
+	device.CreatedAt = time.Now()
+	r.devices = append(r.devices, device)
+	return device, nil
+}
+
+func (r *RepositoryStub) GetDevices(ctx context.Context, userId int) ([]Device, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	devices := make([]Device, 0)
+	for _, device := range r.devices {
+		if device.UserId == userId {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+func (r *RepositoryStub) GetDeviceByToken(ctx context.Context, token string) (Device, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, device := range r.devices {
+		if device.Token == token {
+			return device, nil
+		}
+	}
+	return Device{}, ErrDeviceNotFound
+}
+
+func (r *RepositoryStub) MarkUsed(ctx context.Context, id int, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, device := range r.devices {
+		if device.Id == id {
+			r.devices[i].LastUsedAt = &usedAt
+			return nil
+		}
+	}
+	return ErrDeviceNotFound
+}
+
+func (r *RepositoryStub) DeleteDevice(ctx context.Context, userId, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, device := range r.devices {
+		if device.UserId == userId && device.Id == id {
+			r.devices = append(r.devices[:i], r.devices[i+1:]...)
+			return nil
+		}
+	}
+	return ErrDeviceNotFound
+}
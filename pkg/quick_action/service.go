@@ -0,0 +1,256 @@
+package quick_action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/current_event"
+	"github.com/klokku/klokku/pkg/stats"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrInvalidDevice  = errors.New("invalid quick action device")
+	ErrTooManyDevices = fmt.Errorf("at most %d quick action devices are allowed", MaxDevices)
+	ErrNoPlanItems    = errors.New("the weekly plan has no items")
+)
+
+type Service interface {
+	CreateDevice(ctx context.Context, name string) (Device, error)
+	GetDevices(ctx context.Context) ([]Device, error)
+	DeleteDevice(ctx context.Context, id int) error
+	// Next starts tracking the weekly plan item after the tracked one, the first item when none is tracked or the
+	// last one is, for the user of the device.
+	Next(ctx context.Context, token string) (Status, error)
+	// Stop stops tracking for the user of the device, nothing happens when nothing is tracked.
+	Stop(ctx context.Context, token string) (Status, error)
+	Status(ctx context.Context, token string) (Status, error)
+}
+
+type eventTracker interface {
+	FindCurrentEvent(ctx context.Context) (current_event.CurrentEvent, error)
+	StartNewEvent(ctx context.Context, event current_event.CurrentEvent) (current_event.CurrentEvent, error)
+	StopCurrentEvent(ctx context.Context) (current_event.CurrentEvent, error)
+}
+
+type weeklyPlanItemsReader interface {
+	GetItemsForWeek(ctx context.Context, date time.Time) ([]weekly_plan.WeeklyPlanItem, error)
+}
+
+type usersProvider interface {
+	GetUser(ctx context.Context, id int) (user.User, error)
+}
+
+type weeklyStatsProvider interface {
+	GetWeeklyStats(ctx context.Context, weekTime time.Time, includeSandbox bool) (stats.WeeklyStatsSummary, error)
+}
+
+type ServiceImpl struct {
+	repo       Repository
+	events     eventTracker
+	weeklyPlan weeklyPlanItemsReader
+	users      usersProvider
+	stats      weeklyStatsProvider
+	clock      utils.Clock
+}
+
+func NewService(
+	repo Repository,
+	events eventTracker,
+	weeklyPlan weeklyPlanItemsReader,
+	users usersProvider,
+	stats weeklyStatsProvider,
+) *ServiceImpl {
+	return &ServiceImpl{
+		repo:       repo,
+		events:     events,
+		weeklyPlan: weeklyPlan,
+		users:      users,
+		stats:      stats,
+		clock:      &utils.SystemClock{},
+	}
+}
+
+func (s *ServiceImpl) CreateDevice(ctx context.Context, name string) (Device, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Device{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > MaxDeviceNameLen {
+		return Device{}, fmt.Errorf("%w: name must have 1 to %d characters", ErrInvalidDevice, MaxDeviceNameLen)
+	}
+	devices, err := s.repo.GetDevices(ctx, userId)
+	if err != nil {
+		return Device{}, err
+	}
+	if len(devices) >= MaxDevices {
+		return Device{}, ErrTooManyDevices
+	}
+	return s.repo.CreateDevice(ctx, Device{UserId: userId, Name: name})
+}
+
+func (s *ServiceImpl) GetDevices(ctx context.Context) ([]Device, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.GetDevices(ctx, userId)
+}
+
+func (s *ServiceImpl) DeleteDevice(ctx context.Context, id int) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.DeleteDevice(ctx, userId, id)
+}
+
+func (s *ServiceImpl) Next(ctx context.Context, token string) (Status, error) {
+	ctx, err := s.deviceContext(ctx, token)
+	if err != nil {
+		return Status{}, err
+	}
+	items, err := s.weekItems(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+	if len(items) == 0 {
+		return Status{}, ErrNoPlanItems
+	}
+	current, err := s.events.FindCurrentEvent(ctx)
+	if err != nil {
+		return Status{}, fmt.Errorf("failed to get current event: %w", err)
+	}
+	next := 0
+	if current.Id != 0 {
+		tracked := slices.IndexFunc(items, func(item weekly_plan.WeeklyPlanItem) bool {
+			return item.BudgetItemId == current.PlanItem.BudgetItemId
+		})
+		next = (tracked + 1) % len(items)
+	}
+	item := items[next]
+	_, err = s.events.StartNewEvent(ctx, current_event.CurrentEvent{
+		StartTime: s.clock.Now(),
+		PlanItem: current_event.PlanItem{
+			BudgetItemId:   item.BudgetItemId,
+			Name:           item.Name,
+			WeeklyDuration: item.WeeklyDuration,
+		},
+	})
+	if err != nil {
+		return Status{}, fmt.Errorf("failed to start event: %w", err)
+	}
+	return s.status(ctx, items)
+}
+
+func (s *ServiceImpl) Stop(ctx context.Context, token string) (Status, error) {
+	ctx, err := s.deviceContext(ctx, token)
+	if err != nil {
+		return Status{}, err
+	}
+	if _, err := s.events.StopCurrentEvent(ctx); err != nil && !errors.Is(err, current_event.ErrNoCurrentEvent) {
+		return Status{}, fmt.Errorf("failed to stop event: %w", err)
+	}
+	return Status{}, nil
+}
+
+func (s *ServiceImpl) Status(ctx context.Context, token string) (Status, error) {
+	ctx, err := s.deviceContext(ctx, token)
+	if err != nil {
+		return Status{}, err
+	}
+	items, err := s.weekItems(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+	return s.status(ctx, items)
+}
+
+// deviceContext returns the context of the device's user and records the use of the device.
+func (s *ServiceImpl) deviceContext(ctx context.Context, token string) (context.Context, error) {
+	device, err := s.repo.GetDeviceByToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	deviceUser, err := s.users.GetUser(ctx, device.UserId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	now := s.clock.Now()
+	if device.LastUsedAt == nil || now.Sub(*device.LastUsedAt) >= lastUsedPrecision {
+		if err := s.repo.MarkUsed(ctx, device.Id, now); err != nil {
+			log.Warnf("Quick action device %d used, but not recorded: %v", device.Id, err)
+		}
+	}
+	return user.WithUser(ctx, deviceUser), nil
+}
+
+// weekItems returns the items of the current week of the user, in the order of the plan.
+func (s *ServiceImpl) weekItems(ctx context.Context) ([]weekly_plan.WeeklyPlanItem, error) {
+	now, err := s.userNow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.weeklyPlan.GetItemsForWeek(ctx, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get weekly plan items: %w", err)
+	}
+	slices.SortStableFunc(items, func(a, b weekly_plan.WeeklyPlanItem) int { return a.Position - b.Position })
+	return items, nil
+}
+
+func (s *ServiceImpl) userNow(ctx context.Context) (time.Time, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	location, err := time.LoadLocation(currentUser.Settings.Timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	return s.clock.Now().In(location), nil
+}
+
+func (s *ServiceImpl) status(ctx context.Context, items []weekly_plan.WeeklyPlanItem) (Status, error) {
+	current, err := s.events.FindCurrentEvent(ctx)
+	if err != nil {
+		return Status{}, fmt.Errorf("failed to get current event: %w", err)
+	}
+	if current.Id == 0 {
+		return Status{}, nil
+	}
+	now, err := s.userNow(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+	status := Status{
+		Tracking: true,
+		Name:     current.PlanItem.Name,
+		Elapsed:  now.Sub(current.StartTime),
+	}
+	for _, item := range items {
+		if item.BudgetItemId == current.PlanItem.BudgetItemId {
+			status.Color = item.Color
+			break
+		}
+	}
+	summary, err := s.stats.GetWeeklyStats(ctx, now, false)
+	if err != nil {
+		return Status{}, fmt.Errorf("failed to get weekly stats: %w", err)
+	}
+	for _, item := range summary.PerPlanItem {
+		if item.PlanItem.BudgetItemId == current.PlanItem.BudgetItemId {
+			status.Remaining = item.Remaining
+			break
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,285 @@
+package quick_action
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/current_event"
+	"github.com/klokku/klokku/pkg/stats"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2025, 6, 9, 9, 0, 0, 0, time.UTC)
+
+var testUser = user.User{
+	Id:       1,
+	Uid:      "user-1",
+	Username: "test-user-1",
+	Settings: user.Settings{Timezone: "UTC", WeekFirstDay: time.Monday},
+}
+
+type eventsStub struct {
+	current current_event.CurrentEvent
+	nextId  int
+}
+
+func (e *eventsStub) FindCurrentEvent(ctx context.Context) (current_event.CurrentEvent, error) {
+	return e.current, nil
+}
+
+func (e *eventsStub) StartNewEvent(ctx context.Context, event current_event.CurrentEvent) (current_event.CurrentEvent, error) {
+	e.nextId++
+	event.Id = e.nextId
+	e.current = event
+	return event, nil
+}
+
+func (e *eventsStub) StopCurrentEvent(ctx context.Context) (current_event.CurrentEvent, error) {
+	if e.current.Id == 0 {
+		return current_event.CurrentEvent{}, current_event.ErrNoCurrentEvent
+	}
+	stopped := e.current
+	e.current = current_event.CurrentEvent{}
+	return stopped, nil
+}
+
+type weeklyPlanStub struct {
+	items []weekly_plan.WeeklyPlanItem
+}
+
+func (w *weeklyPlanStub) GetItemsForWeek(ctx context.Context, date time.Time) ([]weekly_plan.WeeklyPlanItem, error) {
+	return append([]weekly_plan.WeeklyPlanItem(nil), w.items...), nil
+}
+
+type usersStub map[int]user.User
+
+func (u usersStub) GetUser(ctx context.Context, id int) (user.User, error) {
+	return u[id], nil
+}
+
+type statsStub struct {
+	summary stats.WeeklyStatsSummary
+}
+
+func (s *statsStub) GetWeeklyStats(ctx context.Context, weekTime time.Time, includeSandbox bool) (stats.WeeklyStatsSummary, error) {
+	return s.summary, nil
+}
+
+type testEnv struct {
+	service    *ServiceImpl
+	repo       *RepositoryStub
+	events     *eventsStub
+	weeklyPlan *weeklyPlanStub
+	clock      *utils.MockClock
+	ctx        context.Context
+}
+
+func setupServiceTest(t *testing.T) testEnv {
+	t.Helper()
+	repo := NewRepositoryStub()
+	events := &eventsStub{}
+	weeklyPlan := &weeklyPlanStub{items: []weekly_plan.WeeklyPlanItem{
+		{Id: 3, BudgetItemId: 12, Name: "Exercise", Color: "#00ff00", Position: 200},
+		{Id: 1, BudgetItemId: 10, Name: "Reading", Color: "#ff0000", Position: 0, WeeklyDuration: 2 * time.Hour},
+		{Id: 2, BudgetItemId: 11, Name: "Work", Color: "#0000ff", Position: 100},
+	}}
+	statsProvider := &statsStub{summary: stats.WeeklyStatsSummary{
+		PerPlanItem: []stats.PlanItemStats{
+			{PlanItem: stats.PlanItem{BudgetItemId: 10, Name: "Reading"}, Remaining: 90 * time.Minute},
+		},
+	}}
+	service := NewService(repo, events, weeklyPlan, usersStub{testUser.Id: testUser}, statsProvider)
+	clock := &utils.MockClock{FixedNow: now}
+	service.clock = clock
+	return testEnv{
+		service:    service,
+		repo:       repo,
+		events:     events,
+		weeklyPlan: weeklyPlan,
+		clock:      clock,
+		ctx:        user.WithUser(context.Background(), testUser),
+	}
+}
+
+func (env testEnv) createDevice(t *testing.T) Device {
+	t.Helper()
+	device, err := env.service.CreateDevice(env.ctx, "Desk button")
+	require.NoError(t, err)
+	return device
+}
+
+func TestCreateDevice_Validation(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+
+	// when
+	_, emptyErr := env.service.CreateDevice(env.ctx, "  ")
+	_, longErr := env.service.CreateDevice(env.ctx, strings.Repeat("a", MaxDeviceNameLen+1))
+
+	// then
+	assert.ErrorIs(t, emptyErr, ErrInvalidDevice)
+	assert.ErrorIs(t, longErr, ErrInvalidDevice)
+}
+
+func TestCreateDevice_Limit(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	for range MaxDevices {
+		env.createDevice(t)
+	}
+
+	// when
+	_, err := env.service.CreateDevice(env.ctx, "One too many")
+
+	// then
+	assert.ErrorIs(t, err, ErrTooManyDevices)
+}
+
+func TestNext_CyclesThroughWeeklyPlanItems(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	device := env.createDevice(t)
+
+	// when
+	var tracked []string
+	for range 4 {
+		status, err := env.service.Next(context.Background(), device.Token)
+		require.NoError(t, err)
+		tracked = append(tracked, status.Name)
+	}
+
+	// then
+	assert.Equal(t, []string{"Reading", "Work", "Exercise", "Reading"}, tracked)
+}
+
+func TestNext_ReturnsStatusOfStartedItem(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	device := env.createDevice(t)
+
+	// when
+	status, err := env.service.Next(context.Background(), device.Token)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, Status{Tracking: true, Name: "Reading", Color: "#ff0000", Remaining: 90 * time.Minute}, status)
+	assert.Equal(t, current_event.PlanItem{BudgetItemId: 10, Name: "Reading", WeeklyDuration: 2 * time.Hour},
+		env.events.current.PlanItem)
+	devices, err := env.service.GetDevices(env.ctx)
+	require.NoError(t, err)
+	require.NotNil(t, devices[0].LastUsedAt)
+	assert.Equal(t, now, *devices[0].LastUsedAt)
+}
+
+func TestStatus_RecordsUseOncePerMinute(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	device := env.createDevice(t)
+	_, err := env.service.Status(context.Background(), device.Token)
+	require.NoError(t, err)
+
+	// The cases run in order, each after the previous call
+	for _, tt := range []struct {
+		name     string
+		calledAt time.Time
+		lastUsed time.Time
+	}{
+		{name: "within a minute", calledAt: now.Add(59 * time.Second), lastUsed: now},
+		{name: "a minute later", calledAt: now.Add(time.Minute), lastUsed: now.Add(time.Minute)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// when
+			env.clock.SetNow(tt.calledAt)
+			_, err := env.service.Status(context.Background(), device.Token)
+
+			// then
+			require.NoError(t, err)
+			devices, err := env.service.GetDevices(env.ctx)
+			require.NoError(t, err)
+			require.NotNil(t, devices[0].LastUsedAt)
+			assert.Equal(t, tt.lastUsed, *devices[0].LastUsedAt)
+		})
+	}
+}
+
+func TestNext_StartsFirstItemWhenTrackedItemIsNotInPlan(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	device := env.createDevice(t)
+	env.events.current = current_event.CurrentEvent{Id: 7, PlanItem: current_event.PlanItem{BudgetItemId: 99, Name: "Other"}}
+
+	// when
+	status, err := env.service.Next(context.Background(), device.Token)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "Reading", status.Name)
+}
+
+func TestNext_EmptyPlan(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	device := env.createDevice(t)
+	env.weeklyPlan.items = nil
+
+	// when
+	_, err := env.service.Next(context.Background(), device.Token)
+
+	// then
+	assert.ErrorIs(t, err, ErrNoPlanItems)
+}
+
+func TestStatus_ReportsElapsedTime(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	device := env.createDevice(t)
+	_, err := env.service.Next(context.Background(), device.Token)
+	require.NoError(t, err)
+	env.clock.SetNow(now.Add(25 * time.Minute))
+
+	// when
+	status, err := env.service.Status(context.Background(), device.Token)
+
+	// then
+	require.NoError(t, err)
+	assert.True(t, status.Tracking)
+	assert.Equal(t, 25*time.Minute, status.Elapsed)
+}
+
+func TestStop_IsIdempotent(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	device := env.createDevice(t)
+	_, err := env.service.Next(context.Background(), device.Token)
+	require.NoError(t, err)
+
+	// when
+	first, firstErr := env.service.Stop(context.Background(), device.Token)
+	second, secondErr := env.service.Stop(context.Background(), device.Token)
+
+	// then
+	require.NoError(t, firstErr)
+	require.NoError(t, secondErr)
+	assert.False(t, first.Tracking)
+	assert.False(t, second.Tracking)
+	assert.Zero(t, env.events.current.Id)
+}
+
+func TestQuickActions_InvalidToken(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	device := env.createDevice(t)
+	require.NoError(t, env.service.DeleteDevice(env.ctx, device.Id))
+
+	// when
+	_, err := env.service.Status(context.Background(), device.Token)
+
+	// then
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+}
@@ -0,0 +1,132 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/pkg/calendar"
+	log "github.com/sirupsen/logrus"
+)
+
+type WeekDTO struct {
+	StartDate time.Time `json:"startDate"`
+	EndDate   time.Time `json:"endDate"`
+	// Events are the generated sandbox events, all of them have the sandbox flag set
+	Events []calendar.EventDTO `json:"events"`
+}
+
+type CleanupDTO struct {
+	DeletedEvents int `json:"deletedEvents"`
+}
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// GenerateWeek godoc
+// @Summary Generate a sandbox week
+// @Description Fill a week with generated demo events based on the current budget plan, so the calendar and reports
+// @Description can be explored safely. Sandbox events are excluded from stats, reports and exports by default.
+// @Description The week must not contain any events yet. Defaults to the previous week.
+// @Tags Sandbox
+// @Produce json
+// @Param date query string false "Any date of the week in RFC3339 format"
+// @Success 201 {object} WeekDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Failure 409 {object} rest.ErrorResponse "The week already contains events"
+// @Router /api/sandbox/week [post]
+// @Security XUserId
+func (h *Handler) GenerateWeek(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	weekDate := time.Now().AddDate(0, 0, -7)
+	if dateString := r.URL.Query().Get("date"); dateString != "" {
+		var err error
+		weekDate, err = time.Parse(time.RFC3339, dateString)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+				Error:   "Invalid date format",
+				Details: "date must be in RFC3339 format",
+			})
+			return
+		}
+	}
+
+	week, err := h.service.GenerateWeek(r.Context(), weekDate)
+	if err != nil {
+		if errors.Is(err, ErrNoPlanItems) {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+				Error:   "Cannot generate sandbox week",
+				Details: err.Error(),
+			})
+			return
+		}
+		if errors.Is(err, ErrWeekNotEmpty) {
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+				Error:   "Cannot generate sandbox week",
+				Details: err.Error(),
+			})
+			return
+		}
+		log.Errorf("Failed to generate sandbox week: %v", err)
+		http.Error(w, "Failed to generate sandbox week", http.StatusInternalServerError)
+		return
+	}
+
+	events := make([]calendar.EventDTO, 0, len(week.Events))
+	for _, e := range week.Events {
+		events = append(events, calendar.EventDTO{
+			UID:          e.UID,
+			Summary:      e.Summary,
+			StartTime:    e.StartTime,
+			EndTime:      e.EndTime,
+			BudgetItemId: e.Metadata.BudgetItemId,
+			Sandbox:      e.Metadata.Sandbox,
+		})
+	}
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(WeekDTO{
+		StartDate: week.StartDate,
+		EndDate:   week.EndDate,
+		Events:    events,
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// Cleanup godoc
+// @Summary Remove sandbox data
+// @Description Remove all sandbox events of the current user
+// @Tags Sandbox
+// @Produce json
+// @Success 200 {object} CleanupDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/sandbox [delete]
+// @Security XUserId
+func (h *Handler) Cleanup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	deleted, err := h.service.Cleanup(r.Context())
+	if err != nil {
+		log.Errorf("Failed to clean up sandbox: %v", err)
+		http.Error(w, "Failed to clean up sandbox", http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(CleanupDTO{DeletedEvents: deleted}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,14 @@
+package sandbox
+
+import (
+	"time"
+
+	"github.com/klokku/klokku/pkg/calendar"
+)
+
+// Week is a demo week filled with generated sandbox events.
+type Week struct {
+	StartDate time.Time
+	EndDate   time.Time
+	Events    []calendar.Event
+}
@@ -0,0 +1,157 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+)
+
+var ErrNoPlanItems = errors.New("current budget plan with items is required to generate a sandbox week")
+var ErrWeekNotEmpty = errors.New("the week already contains events")
+
+// dayStartHour is the hour of the day the generated events start at.
+const dayStartHour = 8
+
+type Service interface {
+	// GenerateWeek fills the week containing weekTime with sandbox events based on the current budget plan.
+	// The week must not contain any events yet.
+	GenerateWeek(ctx context.Context, weekTime time.Time) (Week, error)
+	// Cleanup removes all sandbox events of the current user and returns the number of removed events.
+	Cleanup(ctx context.Context) (int, error)
+}
+
+type budgetPlanReader interface {
+	GetCurrentPlan(ctx context.Context) (budget_plan.BudgetPlan, error)
+}
+
+type sandboxCalendar interface {
+	GetEvents(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error)
+	AddSandboxEvents(ctx context.Context, events []calendar.Event) ([]calendar.Event, error)
+	DeleteSandboxEvents(ctx context.Context) (int, error)
+}
+
+type ServiceImpl struct {
+	budgetPlanReader budgetPlanReader
+	calendar         sandboxCalendar
+}
+
+func NewService(budgetPlanReader budgetPlanReader, calendar sandboxCalendar) *ServiceImpl {
+	return &ServiceImpl{
+		budgetPlanReader: budgetPlanReader,
+		calendar:         calendar,
+	}
+}
+
+func (s *ServiceImpl) GenerateWeek(ctx context.Context, weekTime time.Time) (Week, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return Week{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	dayBoundary, err := utils.NewDayBoundaryForTimezone(currentUser.Settings.Timezone)
+	if err != nil {
+		return Week{}, err
+	}
+
+	plan, err := s.budgetPlanReader.GetCurrentPlan(ctx)
+	if err != nil {
+		if errors.Is(err, budget_plan.ErrPlanNotFound) {
+			return Week{}, ErrNoPlanItems
+		}
+		return Week{}, fmt.Errorf("failed to get current plan: %w", err)
+	}
+	if len(plan.Items) == 0 {
+		return Week{}, ErrNoPlanItems
+	}
+
+	weekStart, weekEnd := weekTimeRange(weekTime.In(dayBoundary.Location()), currentUser.Settings.WeekFirstDay)
+	existingEvents, err := s.calendar.GetEvents(ctx, weekStart, weekEnd)
+	if err != nil {
+		return Week{}, fmt.Errorf("failed to get events: %w", err)
+	}
+	if len(existingEvents) > 0 {
+		return Week{}, ErrWeekNotEmpty
+	}
+
+	events, err := s.calendar.AddSandboxEvents(ctx, generateEvents(plan.Items, weekStart, dayBoundary))
+	if err != nil {
+		return Week{}, fmt.Errorf("failed to store sandbox events: %w", err)
+	}
+	return Week{
+		StartDate: weekStart,
+		EndDate:   weekEnd,
+		Events:    events,
+	}, nil
+}
+
+func (s *ServiceImpl) Cleanup(ctx context.Context) (int, error) {
+	deleted, err := s.calendar.DeleteSandboxEvents(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sandbox events: %w", err)
+	}
+	return deleted, nil
+}
+
+// generateEvents lays out the planned time of every item day by day, one event after another starting
+// at dayStartHour. Events that would not fit into the day are shortened to end at the end of the day.
+func generateEvents(items []budget_plan.BudgetItem, weekStart time.Time, dayBoundary utils.DayBoundary) []calendar.Event {
+	events := make([]calendar.Event, 0, len(items)*7)
+	for dayIndex := 0; dayIndex < 7; dayIndex++ {
+		day := time.Date(weekStart.Year(), weekStart.Month(), weekStart.Day()+dayIndex, dayStartHour, 0, 0, 0, dayBoundary.Location())
+		endOfDay := dayBoundary.EndOfDay(day)
+		cursor := day
+		for _, item := range items {
+			duration := plannedDuration(item, dayIndex, day.Weekday())
+			if duration <= 0 {
+				continue
+			}
+			if !cursor.Before(endOfDay) {
+				break
+			}
+			end := cursor.Add(duration)
+			if end.After(endOfDay) {
+				end = endOfDay
+			}
+			events = append(events, calendar.Event{
+				Summary:   item.Name,
+				StartTime: cursor,
+				EndTime:   end,
+				Metadata:  calendar.EventMetadata{BudgetItemId: item.Id},
+			})
+			cursor = end
+		}
+	}
+	return events
+}
+
+// plannedDuration returns the time planned for the item on the given day of the week. Daily durations are used
+// when configured, otherwise the weekly duration is spread evenly over the first WeeklyOccurrences days.
+func plannedDuration(item budget_plan.BudgetItem, dayIndex int, weekday time.Weekday) time.Duration {
+	if len(item.DailyDurations) > 0 {
+		return item.DailyDurations[weekday].Truncate(time.Minute)
+	}
+	occurrences := item.WeeklyOccurrences
+	if occurrences <= 0 || occurrences > 7 {
+		occurrences = 7
+	}
+	if dayIndex >= occurrences {
+		return 0
+	}
+	return (item.WeeklyDuration / time.Duration(occurrences)).Truncate(time.Minute)
+}
+
+func weekTimeRange(date time.Time, weekStartDay time.Weekday) (time.Time, time.Time) {
+	if weekStartDay < time.Sunday || weekStartDay > time.Saturday {
+		weekStartDay = time.Monday
+	}
+	date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	delta := (int(date.Weekday()) - int(weekStartDay) + 7) % 7
+	weekStart := date.AddDate(0, 0, -delta)
+	weekEnd := weekStart.AddDate(0, 0, 7).Add(-time.Nanosecond)
+	return weekStart, weekEnd
+}
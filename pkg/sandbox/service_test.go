@@ -0,0 +1,164 @@
+package sandbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type budgetPlanReaderStub struct {
+	plan *budget_plan.BudgetPlan
+}
+
+func (s *budgetPlanReaderStub) GetCurrentPlan(ctx context.Context) (budget_plan.BudgetPlan, error) {
+	if s.plan == nil {
+		return budget_plan.BudgetPlan{}, budget_plan.ErrPlanNotFound
+	}
+	return *s.plan, nil
+}
+
+type testEnv struct {
+	service  *ServiceImpl
+	plans    *budgetPlanReaderStub
+	calendar *calendar.Service
+	location *time.Location
+	ctx      context.Context
+}
+
+func setupServiceTest(t *testing.T) testEnv {
+	t.Helper()
+	location, err := time.LoadLocation("Europe/Warsaw")
+	require.NoError(t, err)
+	planItemsProvider := func(ctx context.Context, date time.Time) ([]weekly_plan.WeeklyPlanItem, error) {
+		return nil, nil
+	}
+	calendarService := calendar.NewService(calendar.NewRepositoryStub(), event_bus.NewEventBus(), planItemsProvider, nil)
+	plans := &budgetPlanReaderStub{}
+	return testEnv{
+		service:  NewService(plans, calendarService),
+		plans:    plans,
+		calendar: calendarService,
+		location: location,
+		ctx: user.WithUser(context.Background(), user.User{
+			Id:       1,
+			Uid:      "user-1",
+			Username: "test-user-1",
+			Settings: user.Settings{Timezone: "Europe/Warsaw", WeekFirstDay: time.Monday},
+		}),
+	}
+}
+
+func TestService_GenerateWeek(t *testing.T) {
+	env := setupServiceTest(t)
+	env.plans.plan = &budget_plan.BudgetPlan{
+		Id: 1,
+		Items: []budget_plan.BudgetItem{
+			{Id: 10, Name: "Work", WeeklyDuration: 40 * time.Hour, WeeklyOccurrences: 5},
+			{Id: 11, Name: "Gym", DailyDurations: map[time.Weekday]time.Duration{
+				time.Tuesday:  time.Hour,
+				time.Saturday: 2 * time.Hour,
+			}},
+		},
+	}
+	wednesday := time.Date(2024, time.March, 6, 12, 0, 0, 0, env.location)
+
+	week, err := env.service.GenerateWeek(env.ctx, wednesday)
+
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, time.March, 4, 0, 0, 0, 0, env.location), week.StartDate)
+	require.Len(t, week.Events, 7) // 5 work days, gym on Tuesday and Saturday
+	for _, e := range week.Events {
+		assert.True(t, e.Metadata.Sandbox)
+	}
+	assert.Equal(t, "Work", week.Events[0].Summary)
+	assert.Equal(t, time.Date(2024, time.March, 4, 8, 0, 0, 0, env.location), week.Events[0].StartTime)
+	assert.Equal(t, time.Date(2024, time.March, 4, 16, 0, 0, 0, env.location), week.Events[0].EndTime)
+	assert.Equal(t, "Gym", week.Events[2].Summary)
+	assert.Equal(t, time.Date(2024, time.March, 5, 16, 0, 0, 0, env.location), week.Events[2].StartTime)
+	assert.Equal(t, time.Date(2024, time.March, 5, 17, 0, 0, 0, env.location), week.Events[2].EndTime)
+	assert.Equal(t, 11, week.Events[6].Metadata.BudgetItemId)
+	assert.Equal(t, time.Date(2024, time.March, 9, 8, 0, 0, 0, env.location), week.Events[6].StartTime)
+
+	stored, err := env.calendar.GetEvents(env.ctx, week.StartDate, week.EndDate)
+	require.NoError(t, err)
+	assert.Len(t, stored, 7)
+}
+
+func TestService_GenerateWeek_ShortensEventsToEndOfDay(t *testing.T) {
+	env := setupServiceTest(t)
+	env.plans.plan = &budget_plan.BudgetPlan{
+		Id: 1,
+		Items: []budget_plan.BudgetItem{
+			{Id: 10, Name: "Marathon", DailyDurations: map[time.Weekday]time.Duration{time.Monday: 20 * time.Hour}},
+			{Id: 11, Name: "Reading", DailyDurations: map[time.Weekday]time.Duration{time.Monday: time.Hour}},
+		},
+	}
+
+	week, err := env.service.GenerateWeek(env.ctx, time.Date(2024, time.March, 4, 0, 0, 0, 0, env.location))
+
+	require.NoError(t, err)
+	require.Len(t, week.Events, 1)
+	assert.Equal(t, time.Date(2024, time.March, 5, 0, 0, 0, 0, env.location).Add(-time.Nanosecond), week.Events[0].EndTime)
+}
+
+func TestService_GenerateWeek_WeekNotEmpty(t *testing.T) {
+	env := setupServiceTest(t)
+	env.plans.plan = &budget_plan.BudgetPlan{
+		Id:    1,
+		Items: []budget_plan.BudgetItem{{Id: 10, Name: "Work", WeeklyDuration: 10 * time.Hour}},
+	}
+	monday := time.Date(2024, time.March, 4, 0, 0, 0, 0, env.location)
+	_, err := env.service.GenerateWeek(env.ctx, monday)
+	require.NoError(t, err)
+
+	_, err = env.service.GenerateWeek(env.ctx, monday)
+
+	assert.ErrorIs(t, err, ErrWeekNotEmpty)
+}
+
+func TestService_GenerateWeek_NoPlanItems(t *testing.T) {
+	env := setupServiceTest(t)
+	monday := time.Date(2024, time.March, 4, 0, 0, 0, 0, env.location)
+
+	_, err := env.service.GenerateWeek(env.ctx, monday)
+	assert.ErrorIs(t, err, ErrNoPlanItems)
+
+	env.plans.plan = &budget_plan.BudgetPlan{Id: 1}
+	_, err = env.service.GenerateWeek(env.ctx, monday)
+	assert.ErrorIs(t, err, ErrNoPlanItems)
+}
+
+func TestService_Cleanup(t *testing.T) {
+	env := setupServiceTest(t)
+	env.plans.plan = &budget_plan.BudgetPlan{
+		Id:    1,
+		Items: []budget_plan.BudgetItem{{Id: 10, Name: "Work", WeeklyDuration: 7 * time.Hour}},
+	}
+	week, err := env.service.GenerateWeek(env.ctx, time.Date(2024, time.March, 4, 0, 0, 0, 0, env.location))
+	require.NoError(t, err)
+	realEventStart := time.Date(2024, time.March, 11, 9, 0, 0, 0, env.location)
+	_, err = env.calendar.AddEvent(env.ctx, calendar.Event{
+		Summary:   "Work",
+		StartTime: realEventStart,
+		EndTime:   realEventStart.Add(time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 10},
+	})
+	require.NoError(t, err)
+
+	deleted, err := env.service.Cleanup(env.ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, 7, deleted)
+	remaining, err := env.calendar.GetEvents(env.ctx, week.StartDate, realEventStart.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, remaining, 1)
+	assert.False(t, remaining[0].Metadata.Sandbox)
+}
@@ -0,0 +1,192 @@
+package share_link
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/pkg/stats"
+	log "github.com/sirupsen/logrus"
+)
+
+type CreateShareLinkDTO struct {
+	// Date is any day of the shared week in RFC3339 format
+	Date time.Time `json:"date"`
+	// ExpiresAt is omitted for links valid until revoked
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type ShareLinkDTO struct {
+	Id int `json:"id"`
+	// Url of the read-only view, it works without any authentication
+	Url       string     `json:"url"`
+	Date      time.Time  `json:"date"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type SharedWeekDTO struct {
+	DisplayName string                       `json:"displayName"`
+	Stats       *stats.WeeklyStatsSummaryDTO `json:"stats"`
+}
+
+type Handler struct {
+	host    string
+	service Service
+}
+
+func NewHandler(host string, service Service) *Handler {
+	return &Handler{host: host, service: service}
+}
+
+// CreateLink godoc
+// @Summary Create a share link
+// @Description Create a public link to a read-only view of the plan and stats of a week, e.g. for a coach.
+// @Description Anyone with the link can see the week until it expires or is revoked.
+// @Tags ShareLink
+// @Accept json
+// @Produce json
+// @Param link body CreateShareLinkDTO true "Shared week"
+// @Success 201 {object} ShareLinkDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Router /api/share-links [post]
+// @Security XUserId
+func (h *Handler) CreateLink(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var body CreateShareLinkDTO
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	if body.Date.IsZero() {
+		writeBadRequest(w, "Invalid date", "date is required")
+		return
+	}
+
+	link, err := h.service.CreateLink(r.Context(), body.Date, body.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, ErrInvalidExpiry) {
+			writeBadRequest(w, "Invalid expiry", err.Error())
+			return
+		}
+		log.Errorf("Failed to create share link: %v", err)
+		http.Error(w, "Failed to create share link", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(h.linkToDTO(link)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// ListLinks godoc
+// @Summary List share links
+// @Description List the share links of the current user, newest first
+// @Tags ShareLink
+// @Produce json
+// @Success 200 {array} ShareLinkDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/share-links [get]
+// @Security XUserId
+func (h *Handler) ListLinks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	links, err := h.service.GetLinks(r.Context())
+	if err != nil {
+		log.Errorf("Failed to list share links: %v", err)
+		http.Error(w, "Failed to list share links", http.StatusInternalServerError)
+		return
+	}
+	dtos := make([]ShareLinkDTO, 0, len(links))
+	for _, link := range links {
+		dtos = append(dtos, h.linkToDTO(link))
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// RevokeLink godoc
+// @Summary Revoke a share link
+// @Description Delete the share link, the week is no longer visible through it
+// @Tags ShareLink
+// @Param id path int true "Share link ID"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Invalid share link ID"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Share link not found"
+// @Router /api/share-links/{id} [delete]
+// @Security XUserId
+func (h *Handler) RevokeLink(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid share link ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.RevokeLink(r.Context(), id); err != nil {
+		if errors.Is(err, ErrLinkNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to revoke share link: %v", err)
+		http.Error(w, "Failed to revoke share link", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSharedWeek godoc
+// @Summary View a shared week
+// @Description Read-only view of the plan and stats of the week shared by the link. Sandbox events are excluded.
+// @Tags ShareLink
+// @Produce json
+// @Param token path string true "Share link token"
+// @Success 200 {object} SharedWeekDTO
+// @Failure 404 {string} string "Share link not found or expired"
+// @Router /api/share/{token} [get]
+func (h *Handler) GetSharedWeek(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	week, err := h.service.GetSharedWeek(r.Context(), mux.Vars(r)["token"])
+	if err != nil {
+		if errors.Is(err, ErrLinkNotFound) || errors.Is(err, stats.ErrNoStatsFound) {
+			http.Error(w, "Share link not found or expired", http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to get shared week: %v", err)
+		http.Error(w, "Failed to get shared week", http.StatusInternalServerError)
+		return
+	}
+	// Shared weeks keep changing while tracked, and links can be revoked at any time
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(SharedWeekDTO{
+		DisplayName: week.DisplayName,
+		Stats:       stats.StatsSummaryToDTO(&week.Stats),
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) linkToDTO(link ShareLink) ShareLinkDTO {
+	return ShareLinkDTO{
+		Id:        link.Id,
+		Url:       h.host + "/api/share/" + link.Token,
+		Date:      link.WeekDate,
+		CreatedAt: link.CreatedAt,
+		ExpiresAt: link.ExpiresAt,
+	}
+}
+
+func writeBadRequest(w http.ResponseWriter, errMsg, details string) {
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+		Error:   errMsg,
+		Details: details,
+	})
+}
@@ -0,0 +1,101 @@
+package share_link
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrLinkNotFound = errors.New("share link not found")
+
+type Repository interface {
+	// CreateLink stores the link with a new token.
+	CreateLink(ctx context.Context, link ShareLink) (ShareLink, error)
+	GetLinks(ctx context.Context, userId int) ([]ShareLink, error)
+	GetLinkByToken(ctx context.Context, token string) (ShareLink, error)
+	DeleteLink(ctx context.Context, userId, id int) error
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+const linkColumns = "id, user_id, token, week_date, created_at, expires_at"
+
+func scanLink(row pgx.Row) (ShareLink, error) {
+	var link ShareLink
+	err := row.Scan(&link.Id, &link.UserId, &link.Token, &link.WeekDate, &link.CreatedAt, &link.ExpiresAt)
+	return link, err
+}
+
+func (r *RepositoryImpl) CreateLink(ctx context.Context, link ShareLink) (ShareLink, error) {
+	token, err := generateToken()
+	if err != nil {
+		return ShareLink{}, fmt.Errorf("failed to generate token: %w", err)
+	}
+	query := `INSERT INTO share_link (user_id, token, week_date, expires_at) VALUES ($1, $2, $3, $4)
+			  RETURNING ` + linkColumns
+	created, err := scanLink(r.db.QueryRow(ctx, query, link.UserId, token, link.WeekDate, link.ExpiresAt))
+	if err != nil {
+		return ShareLink{}, fmt.Errorf("failed to create share link: %w", err)
+	}
+	return created, nil
+}
+
+func (r *RepositoryImpl) GetLinks(ctx context.Context, userId int) ([]ShareLink, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+linkColumns+` FROM share_link WHERE user_id = $1 ORDER BY created_at DESC, id DESC`,
+		userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get share links: %w", err)
+	}
+	defer rows.Close()
+
+	links := make([]ShareLink, 0)
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan share link: %w", err)
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+func (r *RepositoryImpl) GetLinkByToken(ctx context.Context, token string) (ShareLink, error) {
+	link, err := scanLink(r.db.QueryRow(ctx, `SELECT `+linkColumns+` FROM share_link WHERE token = $1`, token))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ShareLink{}, ErrLinkNotFound
+		}
+		return ShareLink{}, fmt.Errorf("failed to get share link: %w", err)
+	}
+	return link, nil
+}
+
+func (r *RepositoryImpl) DeleteLink(ctx context.Context, userId, id int) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM share_link WHERE user_id = $1 AND id = $2", userId, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete share link: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrLinkNotFound
+	}
+	return nil
+}
+
+func generateToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	_, err := rand.Read(tokenBytes)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", tokenBytes), nil
+}
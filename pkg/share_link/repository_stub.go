@@ -0,0 +1,64 @@
+package share_link
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type RepositoryStub struct {
+	mu     sync.Mutex
+	nextId int
+	links  []ShareLink
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{}
+}
+
+func (r *RepositoryStub) CreateLink(ctx context.Context, link ShareLink) (ShareLink, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextId++
+	link.Id = r.nextId
+	link.Token = fmt.Sprintf("token-%d", r.nextId)
+	link.CreatedAt = time.Now()
+	r.links = append(r.links, link)
+	return link, nil
+}
+
+func (r *RepositoryStub) GetLinks(ctx context.Context, userId int) ([]ShareLink, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	links := make([]ShareLink, 0)
+	for i := len(r.links) - 1; i >= 0; i-- {
+		if r.links[i].UserId == userId {
+			links = append(links, r.links[i])
+		}
+	}
+	return links, nil
+}
+
+func (r *RepositoryStub) GetLinkByToken(ctx context.Context, token string) (ShareLink, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, link := range r.links {
+		if link.Token == token {
+			return link, nil
+		}
+	}
+	return ShareLink{}, ErrLinkNotFound
+}
+
+func (r *RepositoryStub) DeleteLink(ctx context.Context, userId, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, link := range r.links {
+		if link.UserId == userId && link.Id == id {
+			r.links = append(r.links[:i], r.links[i+1:]...)
+			return nil
+		}
+	}
+	return ErrLinkNotFound
+}
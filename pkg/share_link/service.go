@@ -0,0 +1,99 @@
+package share_link
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/stats"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrInvalidExpiry = errors.New("share link must expire in the future")
+
+type UserProvider interface {
+	GetUser(ctx context.Context, id int) (user.User, error)
+}
+
+type StatsProvider interface {
+	GetWeeklyStats(ctx context.Context, weekTime time.Time, includeSandbox bool) (stats.WeeklyStatsSummary, error)
+}
+
+type Service interface {
+	// CreateLink creates a link to the week containing weekDate, valid until expiresAt or until revoked when nil.
+	CreateLink(ctx context.Context, weekDate time.Time, expiresAt *time.Time) (ShareLink, error)
+	GetLinks(ctx context.Context) ([]ShareLink, error)
+	RevokeLink(ctx context.Context, id int) error
+	// GetSharedWeek returns the week of the link, ErrLinkNotFound when the link does not exist or expired.
+	GetSharedWeek(ctx context.Context, token string) (SharedWeek, error)
+}
+
+type ServiceImpl struct {
+	repo  Repository
+	users UserProvider
+	stats StatsProvider
+	clock utils.Clock
+}
+
+func NewService(repo Repository, users UserProvider, stats StatsProvider) *ServiceImpl {
+	return &ServiceImpl{
+		repo:  repo,
+		users: users,
+		stats: stats,
+		clock: &utils.SystemClock{},
+	}
+}
+
+func (s *ServiceImpl) CreateLink(ctx context.Context, weekDate time.Time, expiresAt *time.Time) (ShareLink, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return ShareLink{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	if expiresAt != nil && !expiresAt.After(s.clock.Now()) {
+		return ShareLink{}, ErrInvalidExpiry
+	}
+	return s.repo.CreateLink(ctx, ShareLink{UserId: userId, WeekDate: weekDate, ExpiresAt: expiresAt})
+}
+
+func (s *ServiceImpl) GetLinks(ctx context.Context) ([]ShareLink, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.GetLinks(ctx, userId)
+}
+
+func (s *ServiceImpl) RevokeLink(ctx context.Context, id int) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.DeleteLink(ctx, userId, id)
+}
+
+func (s *ServiceImpl) GetSharedWeek(ctx context.Context, token string) (SharedWeek, error) {
+	link, err := s.repo.GetLinkByToken(ctx, token)
+	if err != nil {
+		return SharedWeek{}, err
+	}
+	if link.IsExpired(s.clock.Now()) {
+		log.Debugf("share link %d expired", link.Id)
+		return SharedWeek{}, ErrLinkNotFound
+	}
+	u, err := s.users.GetUser(ctx, link.UserId)
+	if err != nil {
+		return SharedWeek{}, fmt.Errorf("failed to get owner of share link %d: %w", link.Id, err)
+	}
+	if u.Disabled {
+		log.Debugf("owner of share link %d is disabled", link.Id)
+		return SharedWeek{}, ErrLinkNotFound
+	}
+	weekStats, err := s.stats.GetWeeklyStats(user.WithUser(ctx, u), link.WeekDate, false)
+	if err != nil {
+		return SharedWeek{}, err
+	}
+	return SharedWeek{DisplayName: u.DisplayName, Stats: weekStats}, nil
+}
@@ -0,0 +1,125 @@
+package share_link
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/stats"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testNow = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+type statsStub struct {
+	userId         int
+	weekTime       time.Time
+	includeSandbox bool
+}
+
+func (s *statsStub) GetWeeklyStats(ctx context.Context, weekTime time.Time, includeSandbox bool) (stats.WeeklyStatsSummary, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return stats.WeeklyStatsSummary{}, err
+	}
+	s.userId = userId
+	s.weekTime = weekTime
+	s.includeSandbox = includeSandbox
+	return stats.WeeklyStatsSummary{TotalTime: 3 * time.Hour}, nil
+}
+
+func setupServiceTest(t *testing.T) (*ServiceImpl, *statsStub, context.Context) {
+	users := user.NewUserService(user.NewStubUserRepository(), event_bus.NewEventBus())
+	owner, err := users.CreateUser(context.Background(), user.User{Uid: "uid-1", Username: "jane", DisplayName: "Jane"})
+	require.NoError(t, err)
+	statsProvider := &statsStub{}
+	service := NewService(NewRepositoryStub(), users, statsProvider)
+	service.clock = &utils.MockClock{FixedNow: testNow}
+	return service, statsProvider, user.WithUser(context.Background(), owner)
+}
+
+func TestServiceImpl_GetSharedWeek(t *testing.T) {
+	t.Run("should show stats of the owner without sandbox events", func(t *testing.T) {
+		// given
+		service, statsProvider, ctx := setupServiceTest(t)
+		link, err := service.CreateLink(ctx, testNow, nil)
+		require.NoError(t, err)
+
+		// when
+		week, err := service.GetSharedWeek(context.Background(), link.Token)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "Jane", week.DisplayName)
+		assert.Equal(t, 3*time.Hour, week.Stats.TotalTime)
+		ownerId, _ := user.CurrentId(ctx)
+		assert.Equal(t, ownerId, statsProvider.userId)
+		assert.Equal(t, testNow, statsProvider.weekTime)
+		assert.False(t, statsProvider.includeSandbox)
+	})
+
+	t.Run("should not show week of expired link", func(t *testing.T) {
+		// given
+		service, _, ctx := setupServiceTest(t)
+		expiresAt := testNow.Add(time.Hour)
+		link, err := service.CreateLink(ctx, testNow, &expiresAt)
+		require.NoError(t, err)
+		service.clock = &utils.MockClock{FixedNow: expiresAt}
+
+		// when
+		_, err = service.GetSharedWeek(context.Background(), link.Token)
+
+		// then
+		assert.ErrorIs(t, err, ErrLinkNotFound)
+	})
+
+	t.Run("should not show week of revoked link", func(t *testing.T) {
+		// given
+		service, _, ctx := setupServiceTest(t)
+		link, err := service.CreateLink(ctx, testNow, nil)
+		require.NoError(t, err)
+
+		// when
+		err = service.RevokeLink(ctx, link.Id)
+
+		// then
+		require.NoError(t, err)
+		_, err = service.GetSharedWeek(context.Background(), link.Token)
+		assert.ErrorIs(t, err, ErrLinkNotFound)
+		links, err := service.GetLinks(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, links)
+	})
+}
+
+func TestServiceImpl_CreateLink(t *testing.T) {
+	t.Run("should reject expiry in the past", func(t *testing.T) {
+		// given
+		service, _, ctx := setupServiceTest(t)
+		expiresAt := testNow.Add(-time.Minute)
+
+		// when
+		_, err := service.CreateLink(ctx, testNow, &expiresAt)
+
+		// then
+		assert.ErrorIs(t, err, ErrInvalidExpiry)
+	})
+
+	t.Run("should not revoke link of another user", func(t *testing.T) {
+		// given
+		service, _, ctx := setupServiceTest(t)
+		link, err := service.CreateLink(ctx, testNow, nil)
+		require.NoError(t, err)
+		other := user.WithUser(context.Background(), user.User{Id: 999})
+
+		// when
+		err = service.RevokeLink(other, link.Id)
+
+		// then
+		assert.ErrorIs(t, err, ErrLinkNotFound)
+	})
+}
@@ -0,0 +1,29 @@
+package share_link
+
+import (
+	"time"
+
+	"github.com/klokku/klokku/pkg/stats"
+)
+
+// ShareLink gives anyone with its token a read-only view of the plan and stats of a week of the user.
+type ShareLink struct {
+	Id     int
+	UserId int
+	Token  string
+	// WeekDate is any time within the shared week
+	WeekDate  time.Time
+	CreatedAt time.Time
+	// ExpiresAt is nil for links valid until revoked
+	ExpiresAt *time.Time
+}
+
+func (l ShareLink) IsExpired(now time.Time) bool {
+	return l.ExpiresAt != nil && !now.Before(*l.ExpiresAt)
+}
+
+// SharedWeek is what the holder of a share link sees.
+type SharedWeek struct {
+	DisplayName string
+	Stats       stats.WeeklyStatsSummary
+}
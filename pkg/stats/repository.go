@@ -0,0 +1,84 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository interface {
+	// GetWeeklyTrend fills in the planned and tracked time of the budget item in each of the weeks. Events are
+	// aggregated in the database, including archived events and excluding sandbox events.
+	GetWeeklyTrend(ctx context.Context, userId int, budgetItemId int, weeks []TrendWeek) ([]TrendWeek, error)
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+func (r *RepositoryImpl) GetWeeklyTrend(ctx context.Context, userId int, budgetItemId int, weeks []TrendWeek) ([]TrendWeek, error) {
+	if len(weeks) == 0 {
+		return []TrendWeek{}, nil
+	}
+	starts := make([]time.Time, 0, len(weeks))
+	ends := make([]time.Time, 0, len(weeks))
+	weekNumbers := make([]string, 0, len(weeks))
+	for _, week := range weeks {
+		starts = append(starts, week.StartDate)
+		ends = append(ends, week.EndDate)
+		weekNumbers = append(weekNumbers, week.Week.String())
+	}
+
+	query := `WITH weeks AS (SELECT *
+			                 FROM unnest($3::timestamptz[], $4::timestamptz[], $5::text[]) AS w(week_start, week_end, week_number)),
+			       events AS (SELECT start_time, end_time
+			                  FROM calendar_event
+			                  WHERE user_id = $1 AND budget_item_id = $2 AND NOT sandbox AND start_time < $6 AND end_time > $7
+			                  UNION ALL
+			                  SELECT start_time, end_time
+			                  FROM calendar_event_archive
+			                  WHERE user_id = $1 AND budget_item_id = $2 AND NOT sandbox AND start_time < $6 AND end_time > $7)
+			  SELECT w.week_start,
+			         COALESCE((SELECT SUM(i.weekly_duration_sec)
+			                   FROM weekly_plan_item i
+			                   WHERE i.user_id = $1 AND i.budget_item_id = $2 AND i.week_number = w.week_number), 0)::BIGINT,
+			         COALESCE((SELECT SUM(EXTRACT(EPOCH FROM LEAST(e.end_time, w.week_end) - GREATEST(e.start_time, w.week_start)))
+			                   FROM events e
+			                   WHERE e.start_time < w.week_end AND e.end_time > w.week_start), 0)::BIGINT
+			  FROM weeks w
+			  ORDER BY w.week_start`
+	rows, err := r.db.Query(ctx, query, userId, budgetItemId, starts, ends, weekNumbers, ends[len(ends)-1], starts[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to query weekly trend: %w", err)
+	}
+	defer rows.Close()
+
+	plannedByStart := make(map[int64]int64, len(weeks))
+	trackedByStart := make(map[int64]int64, len(weeks))
+	for rows.Next() {
+		var weekStart time.Time
+		var plannedSec, trackedSec int64
+		if err := rows.Scan(&weekStart, &plannedSec, &trackedSec); err != nil {
+			return nil, fmt.Errorf("failed to scan weekly trend: %w", err)
+		}
+		plannedByStart[weekStart.Unix()] = plannedSec
+		trackedByStart[weekStart.Unix()] = trackedSec
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read weekly trend: %w", err)
+	}
+
+	result := make([]TrendWeek, 0, len(weeks))
+	for _, week := range weeks {
+		week.Planned = time.Duration(plannedByStart[week.StartDate.Unix()]) * time.Second
+		week.Tracked = time.Duration(trackedByStart[week.StartDate.Unix()]) * time.Second
+		result = append(result, week)
+	}
+	return result, nil
+}
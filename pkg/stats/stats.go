@@ -2,12 +2,17 @@ package stats
 
 import (
 	"time"
+
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/weekly_plan"
 )
 
 type DailyStats struct {
 	Date             time.Time
 	StatsPerPlanItem []PlanItemStats
 	TotalTime        time.Duration
+	// Absent is set for days of a planned absence, nothing is planned for them
+	Absent bool
 }
 
 type PlanItem struct {
@@ -21,15 +26,41 @@ type PlanItem struct {
 	WeeklyItemDuration time.Duration
 	BudgetItemDuration time.Duration
 	WeeklyOccurrences  int
-	Notes              string
+	DailyDurations     map[time.Weekday]time.Duration
+	CategoryId         int
+	// ParentBudgetItemId is set for sub-items. Stats of an item with sub-items include the sub-items' time.
+	ParentBudgetItemId int
+	// Unit tells whether the item is planned in time or in sessions (WeeklyOccurrences sessions a week).
+	Unit  budget_plan.ItemUnit
+	Notes string
+	// AdHoc is set for items added to the week only, they have no budget item.
+	AdHoc bool
 }
 
 type PlanItemStats struct {
 	PlanItem  PlanItem
 	Duration  time.Duration
 	Remaining time.Duration
-	StartDate time.Time
-	EndDate   time.Time
+	// Sessions is the number of sessions tracked for the item. Events directly continuing each other are
+	// counted as one session.
+	Sessions int
+	// DailyTarget is the time planned for the item on the given day. It is set only in daily stats
+	// and only when the item has a daily duration configured for that weekday.
+	DailyTarget time.Duration
+	StartDate   time.Time
+	EndDate     time.Time
+}
+
+// Percentage is the tracked share of the planned time (or of the planned sessions for items planned in sessions).
+// It is 0 when nothing was planned.
+func (s PlanItemStats) Percentage() float64 {
+	if s.PlanItem.Unit == budget_plan.UnitSessions {
+		if s.PlanItem.WeeklyOccurrences == 0 {
+			return 0
+		}
+		return float64(s.Sessions) / float64(s.PlanItem.WeeklyOccurrences) * 100
+	}
+	return percentage(s.Duration, s.PlanItem.WeeklyItemDuration)
 }
 
 type PlanItemHistoryStats struct {
@@ -38,12 +69,156 @@ type PlanItemHistoryStats struct {
 	StatsPerWeek []PlanItemStats
 }
 
+// CategoryStats sums up the stats of all plan items in a category. Uncategorized items are summed up
+// under CategoryId 0.
+type CategoryStats struct {
+	CategoryId int
+	Name       string
+	Color      string
+	Planned    time.Duration
+	Duration   time.Duration
+	Remaining  time.Duration
+}
+
+// WeeklyCapacity compares the time planned for a week with the time available in it.
+type WeeklyCapacity struct {
+	StartDate time.Time
+	EndDate   time.Time
+	Planned   time.Duration
+	// Available is the awake time of the week, the whole week without the sleep time and the absent days.
+	Available time.Duration
+	// Busy is the time blocked by external calendars.
+	Busy time.Duration
+	// Slack is the available time left after the planned and busy time. It is negative for an overbooked week.
+	Slack time.Duration
+}
+
 type WeeklyStatsSummary struct {
-	StartDate      time.Time
-	EndDate        time.Time
-	PerDay         []DailyStats
-	PerPlanItem    []PlanItemStats
+	StartDate   time.Time
+	EndDate     time.Time
+	PerDay      []DailyStats
+	PerPlanItem []PlanItemStats
+	// PerCategory is set only when the plan has categories.
+	PerCategory    []CategoryStats
 	TotalPlanned   time.Duration
 	TotalTime      time.Duration
 	TotalRemaining time.Duration
+	// AbsentDays is the number of days of the week the user is absent on, the planned time is reduced by them.
+	AbsentDays int
+	// PerLocation is set only when some of the time was tracked with a location.
+	PerLocation []LocationStats
+}
+
+// TotalPercentage is the tracked share of the total planned time, 0 when nothing was planned.
+func (s WeeklyStatsSummary) TotalPercentage() float64 {
+	return percentage(s.TotalTime, s.TotalPlanned)
+}
+
+func percentage(tracked time.Duration, planned time.Duration) float64 {
+	if planned <= 0 {
+		return 0
+	}
+	return float64(tracked) / float64(planned) * 100
+}
+
+// LocationStats is the time tracked at a location. Time tracked without a location has an empty Location.
+type LocationStats struct {
+	Location string
+	Duration time.Duration
+}
+
+// BaselineKind tells which week the weekly stats are compared against.
+type BaselineKind string
+
+const (
+	// BaselineBestWeek is the week with the highest plan completion among the weeks before the compared one.
+	BaselineBestWeek BaselineKind = "best"
+	// BaselineWeek is the week containing a given date.
+	BaselineWeek BaselineKind = "week"
+	// BaselineAverage is the average of the weeks before the compared one.
+	BaselineAverage BaselineKind = "average"
+)
+
+type Baseline struct {
+	Kind BaselineKind
+	// Date is any day of the baseline week. Used only by BaselineWeek.
+	Date time.Time
+	// Weeks is the number of weeks before the compared week looked at by BaselineBestWeek and BaselineAverage.
+	Weeks int
+}
+
+// BaselineComparison compares the time tracked in a week with the time tracked in the baseline.
+type BaselineComparison struct {
+	Kind BaselineKind
+	// StartDate and EndDate span the baseline week, or all the averaged weeks.
+	StartDate time.Time
+	EndDate   time.Time
+	// Weeks is the number of weeks with stats the baseline was calculated from.
+	Weeks       int
+	PerPlanItem []PlanItemComparison
+	TotalTime   time.Duration
+	// BaselineTotalTime is the time tracked in the baseline. Difference is positive when more time was tracked
+	// than in the baseline.
+	BaselineTotalTime time.Duration
+	Difference        time.Duration
+}
+
+type PlanItemComparison struct {
+	BudgetItemId     int
+	Name             string
+	Duration         time.Duration
+	BaselineDuration time.Duration
+	Difference       time.Duration
+}
+
+// TrendWeek is a point of the long-term trend of a budget item.
+type TrendWeek struct {
+	Week      weekly_plan.WeekNumber
+	StartDate time.Time
+	// EndDate is exclusive, it is the start of the next week.
+	EndDate time.Time
+	// Planned is the time planned in the week's plan, 0 for weeks that were never planned.
+	Planned time.Duration
+	Tracked time.Duration
+}
+
+type Trend struct {
+	BudgetItemId int
+	// Weeks are ordered from the oldest to the current week.
+	Weeks []TrendWeek
+}
+
+// FocusStats tells how contiguous the tracked time is. A block is time tracked for one item without interruption,
+// events directly continuing each other form one block.
+type FocusStats struct {
+	Date time.Time
+	// Blocks is the number of blocks started on the day (or in the week).
+	Blocks int
+	// Switches is the number of times a block followed a block of another item on the same day.
+	Switches     int
+	TotalTime    time.Duration
+	LongestBlock time.Duration
+	// DeepWorkTime is the time tracked in blocks of at least DeepWorkBlockDuration.
+	DeepWorkTime time.Duration
+}
+
+// AverageBlock is the average length of the blocks, 0 when nothing was tracked.
+func (f FocusStats) AverageBlock() time.Duration {
+	if f.Blocks == 0 {
+		return 0
+	}
+	return f.TotalTime / time.Duration(f.Blocks)
+}
+
+// Score is the share of the tracked time spent in deep work blocks, 0 when nothing was tracked.
+func (f FocusStats) Score() float64 {
+	return percentage(f.DeepWorkTime, f.TotalTime)
+}
+
+type WeeklyFocusStats struct {
+	StartDate time.Time
+	EndDate   time.Time
+	PerDay    []FocusStats
+	// Total sums up the days of the week, its Date is the first day of the week.
+	Total FocusStats
 }
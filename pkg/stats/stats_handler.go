@@ -1,19 +1,26 @@
 package stats
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
 	"github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	log "github.com/sirupsen/logrus"
 )
 
 type DailyStatsDTO struct {
 	Date        time.Time          `json:"date"`
 	PerPlanItem []PlanItemStatsDTO `json:"perPlanItem"`
 	TotalTime   int                `json:"totalTime"`
+	// Absent is set for days of a planned absence, nothing is planned for them
+	Absent bool `json:"absent,omitempty"`
 }
 
 type PlanItemDTO struct {
@@ -27,15 +34,40 @@ type PlanItemDTO struct {
 	WeeklyItemDuration int    `json:"weeklyItemDuration"`
 	BudgetItemDuration int    `json:"budgetItemDuration"`
 	WeeklyOccurrences  int    `json:"weeklyOccurrences"`
-	Notes              string `json:"notes"`
+	// DailyDurations maps lowercase weekday names (e.g. "monday") to the daily target in seconds.
+	DailyDurations map[string]int `json:"dailyDurations,omitempty"`
+	CategoryId     int            `json:"categoryId,omitempty"`
+	// ParentBudgetItemId is set for sub-items, the duration of their parent item includes their time.
+	ParentBudgetItemId int `json:"parentBudgetItemId,omitempty"`
+	// Unit is "sessions" for items planned in sessions, weeklyOccurrences is then the number of planned sessions.
+	Unit  string `json:"unit,omitempty" enums:"duration,sessions"`
+	Notes string `json:"notes"`
+	// AdHoc is set for items added to the week only, their budgetItemId is negative and budgetItemDuration is 0.
+	AdHoc bool `json:"adHoc,omitempty"`
+}
+
+type CategoryStatsDTO struct {
+	// CategoryId is 0 for the group of uncategorized items
+	CategoryId int    `json:"categoryId"`
+	Name       string `json:"name"`
+	Color      string `json:"color,omitempty"`
+	Planned    int    `json:"planned"`
+	Duration   int    `json:"duration"`
+	Remaining  int    `json:"remaining"`
 }
 
 type PlanItemStatsDTO struct {
 	PlanItem  PlanItemDTO `json:"planItem"`
 	Duration  int         `json:"duration"`
 	Remaining int         `json:"remaining"`
-	StartDate time.Time   `json:"startDate"`
-	EndDate   time.Time   `json:"endDate"`
+	// Sessions is the number of tracked sessions, events directly continuing each other count as one.
+	Sessions int `json:"sessions"`
+	// Percentage is the tracked share of the planned time (or sessions), rounded to one decimal place
+	Percentage float64 `json:"percentage"`
+	// DailyTarget is set only for per-day stats of items with a daily duration for that weekday.
+	DailyTarget int       `json:"dailyTarget,omitempty"`
+	StartDate   time.Time `json:"startDate"`
+	EndDate     time.Time `json:"endDate"`
 }
 
 type WeeklyStatsSummaryDTO struct {
@@ -43,9 +75,80 @@ type WeeklyStatsSummaryDTO struct {
 	EndDate        time.Time          `json:"endDate"`
 	PerDay         []DailyStatsDTO    `json:"perDay"`
 	PerPlanItem    []PlanItemStatsDTO `json:"perPlanItem"`
+	PerCategory    []CategoryStatsDTO `json:"perCategory,omitempty"`
 	TotalPlanned   int                `json:"totalPlanned"`
 	TotalTime      int                `json:"totalTime"`
 	TotalRemaining int                `json:"totalRemaining"`
+	// TotalPercentage is the tracked share of the total planned time, rounded to one decimal place
+	TotalPercentage float64 `json:"totalPercentage"`
+	// AbsentDays is the number of days of the week the user is absent on, totalPlanned is already reduced by them
+	AbsentDays int `json:"absentDays,omitempty"`
+	// PerLocation breaks the tracked time down by location, the longest first. It is set only when some of the
+	// time was tracked with a location.
+	PerLocation []LocationStatsDTO `json:"perLocation,omitempty"`
+	// Baseline is set only when a baseline was requested
+	Baseline *BaselineComparisonDTO `json:"baseline,omitempty"`
+	// ReviewStatus tells whether the week was reviewed, see /api/week-reviews. It is omitted when it can't be read.
+	ReviewStatus string `json:"reviewStatus,omitempty" enums:"not_started,in_progress,reviewed"`
+}
+
+type LocationStatsDTO struct {
+	// Location is empty for the time tracked without a location
+	Location string `json:"location"`
+	Duration int    `json:"duration"`
+}
+
+type BaselineComparisonDTO struct {
+	Kind string `json:"kind" enums:"best,week,average"`
+	// StartDate and EndDate span the baseline week, or all the averaged weeks
+	StartDate time.Time `json:"startDate"`
+	EndDate   time.Time `json:"endDate"`
+	// Weeks is the number of weeks with stats the baseline was calculated from
+	Weeks             int                     `json:"weeks"`
+	PerPlanItem       []PlanItemComparisonDTO `json:"perPlanItem"`
+	TotalTime         int                     `json:"totalTime"`
+	BaselineTotalTime int                     `json:"baselineTotalTime"`
+	// Difference is positive when more time was tracked than in the baseline
+	Difference int `json:"difference"`
+}
+
+type PlanItemComparisonDTO struct {
+	BudgetItemId     int    `json:"budgetItemId"`
+	Name             string `json:"name"`
+	Duration         int    `json:"duration"`
+	BaselineDuration int    `json:"baselineDuration"`
+	Difference       int    `json:"difference"`
+}
+
+type WeeklyCapacityDTO struct {
+	StartDate time.Time `json:"startDate"`
+	EndDate   time.Time `json:"endDate"`
+	Planned   int       `json:"planned"`
+	Available int       `json:"available"`
+	Busy      int       `json:"busy"`
+	// Slack is negative when the week is overbooked
+	Slack    int  `json:"slack"`
+	Feasible bool `json:"feasible"`
+}
+
+type FocusStatsDTO struct {
+	Date     time.Time `json:"date"`
+	Blocks   int       `json:"blocks"`
+	Switches int       `json:"switches"`
+	// TotalTime, AverageBlock, LongestBlock and DeepWorkTime are in seconds
+	TotalTime    int `json:"totalTime"`
+	AverageBlock int `json:"averageBlock"`
+	LongestBlock int `json:"longestBlock"`
+	DeepWorkTime int `json:"deepWorkTime"`
+	// Score is the percentage of the tracked time spent in deep work blocks
+	Score float64 `json:"score"`
+}
+
+type WeeklyFocusStatsDTO struct {
+	StartDate time.Time       `json:"startDate"`
+	EndDate   time.Time       `json:"endDate"`
+	PerDay    []FocusStatsDTO `json:"perDay"`
+	Total     FocusStatsDTO   `json:"total"`
 }
 
 type PlanItemHistoryStatsDTO struct {
@@ -54,12 +157,33 @@ type PlanItemHistoryStatsDTO struct {
 	StatsPerWeek []PlanItemStatsDTO `json:"statsPerWeek"`
 }
 
+type TrendWeekDTO struct {
+	// Week is the ISO week, e.g. "2025-W03"
+	Week      string    `json:"week"`
+	StartDate time.Time `json:"startDate"`
+	// EndDate is exclusive, it is the start of the next week
+	EndDate time.Time `json:"endDate"`
+	Planned int       `json:"planned"`
+	Tracked int       `json:"tracked"`
+}
+
+type TrendDTO struct {
+	BudgetItemId int            `json:"budgetItemId"`
+	Weeks        []TrendWeekDTO `json:"weeks"`
+}
+
+// weekReviewStatusReader is implemented by the week_review service, which itself depends on the stats.
+type weekReviewStatusReader interface {
+	ReviewStatus(ctx context.Context, weekDate time.Time) (string, error)
+}
+
 type StatsHandler struct {
 	statsService StatsService
+	reviews      weekReviewStatusReader
 }
 
-func NewStatsHandler(statsService StatsService) *StatsHandler {
-	return &StatsHandler{statsService}
+func NewStatsHandler(statsService StatsService, reviews weekReviewStatusReader) *StatsHandler {
+	return &StatsHandler{statsService, reviews}
 }
 
 // GetWeeklyStats godoc
@@ -68,9 +192,14 @@ func NewStatsHandler(statsService StatsService) *StatsHandler {
 // @Tags Stats
 // @Produce json
 // @Param date query string true "Date in RFC3339 format (can be any day of the week)"
+// @Param includeSandbox query bool false "Include sandbox (demo) events in the stats" default(false)
+// @Param baseline query string false "Compare the week against the best of the previous weeks, the week of baselineDate or the average of the previous weeks" Enums(best, week, average)
+// @Param baselineDate query string false "Any day of the baseline week in RFC3339 format, required for the week baseline"
+// @Param baselineWeeks query int false "Number of previous weeks looked at by the best and average baselines" default(8)
 // @Success 200 {object} WeeklyStatsSummaryDTO
-// @Failure 400 {object} rest.ErrorResponse "Invalid date format"
+// @Failure 400 {object} rest.ErrorResponse "Invalid date format or baseline"
 // @Failure 403 {string} string "User not found"
+// @Failure 404 {object} rest.ErrorResponse "No stats found for the week, or nothing tracked in the baseline"
 // @Router /api/stats/weekly [get]
 // @Security XUserId
 func (handler *StatsHandler) GetWeeklyStats(w http.ResponseWriter, r *http.Request) {
@@ -88,7 +217,8 @@ func (handler *StatsHandler) GetWeeklyStats(w http.ResponseWriter, r *http.Reque
 		}
 		return
 	}
-	stats, err := handler.statsService.GetWeeklyStats(r.Context(), weekDate)
+	includeSandbox := r.URL.Query().Get("includeSandbox") == "true"
+	stats, err := handler.statsService.GetWeeklyStats(r.Context(), weekDate, includeSandbox)
 	if err != nil {
 		if errors.Is(err, ErrNoStatsFound) {
 			http.Error(w, err.Error(), http.StatusNotFound)
@@ -98,15 +228,60 @@ func (handler *StatsHandler) GetWeeklyStats(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	statsSummaryDTO := StatsSummaryToDTO(&stats)
+	if r.URL.Query().Get("baseline") != "" {
+		baseline, err := parseBaseline(r.URL.Query())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
+				Error:   "Invalid baseline",
+				Details: err.Error(),
+			})
+			if encodeErr != nil {
+				http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		comparison, err := handler.statsService.CompareWithBaseline(r.Context(), stats, baseline, includeSandbox)
+		if err != nil {
+			if errors.Is(err, ErrInvalidBaseline) {
+				w.WriteHeader(http.StatusBadRequest)
+				encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
+					Error:   "Invalid baseline",
+					Details: err.Error(),
+				})
+				if encodeErr != nil {
+					http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
+				}
+				return
+			}
+			if errors.Is(err, ErrNoBaselineFound) {
+				rest.WriteError(w, http.StatusNotFound, rest.ErrorResponse{
+					Error:   "No baseline",
+					Details: "nothing was tracked in the baseline weeks",
+				})
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		statsSummaryDTO.Baseline = baselineComparisonToDTO(comparison)
+	}
+	// The stats are served without the review status when it can't be read
+	reviewStatus, err := handler.reviews.ReviewStatus(r.Context(), weekDate)
+	if err != nil {
+		log.Warnf("Failed to get week review status: %v", err)
+	}
+	statsSummaryDTO.ReviewStatus = reviewStatus
+
 	w.Header().Set("Content-Type", "application/json")
-	statsSummaryDTO := statsSummaryToDTO(&stats)
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(statsSummaryDTO); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
-func statsSummaryToDTO(stats *WeeklyStatsSummary) *WeeklyStatsSummaryDTO {
+func StatsSummaryToDTO(stats *WeeklyStatsSummary) *WeeklyStatsSummaryDTO {
 	budgetStats := make([]PlanItemStatsDTO, 0, len(stats.PerPlanItem))
 	for _, planItemStats := range stats.PerPlanItem {
 		budgetStatsDTO := planItemStatsToDTO(planItemStats)
@@ -115,35 +290,113 @@ func statsSummaryToDTO(stats *WeeklyStatsSummary) *WeeklyStatsSummaryDTO {
 
 	days := make([]DailyStatsDTO, 0, len(stats.PerDay))
 	for _, day := range stats.PerDay {
-		dailyStatsDTO := DailyStatsDTO{
-			Date:      day.Date,
-			TotalTime: int(day.TotalTime.Seconds()),
+		days = append(days, dailyStatsToDTO(day))
+	}
+
+	var categories []CategoryStatsDTO
+	for _, categoryStats := range stats.PerCategory {
+		categories = append(categories, CategoryStatsDTO{
+			CategoryId: categoryStats.CategoryId,
+			Name:       categoryStats.Name,
+			Color:      categoryStats.Color,
+			Planned:    int(categoryStats.Planned.Seconds()),
+			Duration:   int(categoryStats.Duration.Seconds()),
+			Remaining:  int(categoryStats.Remaining.Seconds()),
+		})
+	}
+
+	var locations []LocationStatsDTO
+	for _, locationStats := range stats.PerLocation {
+		locations = append(locations, LocationStatsDTO{
+			Location: locationStats.Location,
+			Duration: int(locationStats.Duration.Seconds()),
+		})
+	}
+
+	return &WeeklyStatsSummaryDTO{
+		StartDate:       stats.StartDate,
+		EndDate:         stats.EndDate,
+		PerDay:          days,
+		PerPlanItem:     budgetStats,
+		PerCategory:     categories,
+		TotalPlanned:    int(stats.TotalPlanned.Seconds()),
+		TotalTime:       int(stats.TotalTime.Seconds()),
+		TotalRemaining:  int(stats.TotalRemaining.Seconds()),
+		TotalPercentage: roundPercentage(stats.TotalPercentage()),
+		AbsentDays:      stats.AbsentDays,
+		PerLocation:     locations,
+	}
+}
+
+func roundPercentage(percentage float64) float64 {
+	return math.Round(percentage*10) / 10
+}
+
+func dailyStatsToDTO(day DailyStats) DailyStatsDTO {
+	dailyStatsDTO := DailyStatsDTO{
+		Date:      day.Date,
+		TotalTime: int(day.TotalTime.Seconds()),
+		Absent:    day.Absent,
+	}
+	for _, dayItemStats := range day.StatsPerPlanItem {
+		budgetStatsDTO := planItemStatsToDTO(dayItemStats)
+		dailyStatsDTO.PerPlanItem = append(dailyStatsDTO.PerPlanItem, budgetStatsDTO)
+	}
+	return dailyStatsDTO
+}
+
+func parseBaseline(query url.Values) (Baseline, error) {
+	baseline := Baseline{Kind: BaselineKind(query.Get("baseline"))}
+	if dateString := query.Get("baselineDate"); dateString != "" {
+		date, err := time.Parse(time.RFC3339, dateString)
+		if err != nil {
+			return Baseline{}, errors.New("baselineDate must be in RFC3339 format")
 		}
-		for _, dayItemStats := range day.StatsPerPlanItem {
-			budgetStatsDTO := planItemStatsToDTO(dayItemStats)
-			dailyStatsDTO.PerPlanItem = append(dailyStatsDTO.PerPlanItem, budgetStatsDTO)
+		baseline.Date = date
+	}
+	if weeksString := query.Get("baselineWeeks"); weeksString != "" {
+		weeks, err := strconv.Atoi(weeksString)
+		if err != nil {
+			return Baseline{}, errors.New("baselineWeeks must be an integer")
 		}
-		days = append(days, dailyStatsDTO)
+		baseline.Weeks = weeks
 	}
+	return baseline, nil
+}
 
-	return &WeeklyStatsSummaryDTO{
-		StartDate:      stats.StartDate,
-		EndDate:        stats.EndDate,
-		PerDay:         days,
-		PerPlanItem:    budgetStats,
-		TotalPlanned:   int(stats.TotalPlanned.Seconds()),
-		TotalTime:      int(stats.TotalTime.Seconds()),
-		TotalRemaining: int(stats.TotalRemaining.Seconds()),
+func baselineComparisonToDTO(comparison BaselineComparison) *BaselineComparisonDTO {
+	items := make([]PlanItemComparisonDTO, 0, len(comparison.PerPlanItem))
+	for _, item := range comparison.PerPlanItem {
+		items = append(items, PlanItemComparisonDTO{
+			BudgetItemId:     item.BudgetItemId,
+			Name:             item.Name,
+			Duration:         int(item.Duration.Seconds()),
+			BaselineDuration: int(item.BaselineDuration.Seconds()),
+			Difference:       int(item.Difference.Seconds()),
+		})
+	}
+	return &BaselineComparisonDTO{
+		Kind:              string(comparison.Kind),
+		StartDate:         comparison.StartDate,
+		EndDate:           comparison.EndDate,
+		Weeks:             comparison.Weeks,
+		PerPlanItem:       items,
+		TotalTime:         int(comparison.TotalTime.Seconds()),
+		BaselineTotalTime: int(comparison.BaselineTotalTime.Seconds()),
+		Difference:        int(comparison.Difference.Seconds()),
 	}
 }
 
 func planItemStatsToDTO(itemStats PlanItemStats) PlanItemStatsDTO {
 	return PlanItemStatsDTO{
-		PlanItem:  planItemToDTO(itemStats.PlanItem),
-		Duration:  int(itemStats.Duration.Seconds()),
-		Remaining: int(itemStats.Remaining.Seconds()),
-		StartDate: itemStats.StartDate,
-		EndDate:   itemStats.EndDate,
+		PlanItem:    planItemToDTO(itemStats.PlanItem),
+		Duration:    int(itemStats.Duration.Seconds()),
+		Remaining:   int(itemStats.Remaining.Seconds()),
+		Sessions:    itemStats.Sessions,
+		Percentage:  roundPercentage(itemStats.Percentage()),
+		DailyTarget: int(itemStats.DailyTarget.Seconds()),
+		StartDate:   itemStats.StartDate,
+		EndDate:     itemStats.EndDate,
 	}
 }
 
@@ -159,7 +412,53 @@ func planItemToDTO(planItem PlanItem) PlanItemDTO {
 		WeeklyItemDuration: int(planItem.WeeklyItemDuration.Seconds()),
 		BudgetItemDuration: int(planItem.BudgetItemDuration.Seconds()),
 		WeeklyOccurrences:  planItem.WeeklyOccurrences,
+		DailyDurations:     budget_plan.DailyDurationsToDTO(planItem.DailyDurations),
+		CategoryId:         planItem.CategoryId,
+		ParentBudgetItemId: planItem.ParentBudgetItemId,
+		Unit:               string(planItem.Unit),
 		Notes:              planItem.Notes,
+		AdHoc:              planItem.AdHoc,
+	}
+}
+
+// GetDailyStats godoc
+// @Summary Get daily statistics
+// @Description Retrieve the time tracked per plan item on a single day, in the user's timezone.
+// @Description Daily targets are set for items with a daily duration for that weekday.
+// @Tags Stats
+// @Produce json
+// @Param date query string true "Date in RFC3339 format (any time of the day)"
+// @Param includeSandbox query bool false "Include sandbox (demo) events in the stats" default(false)
+// @Success 200 {object} DailyStatsDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid date format"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "No stats found for the day"
+// @Router /api/stats/day [get]
+// @Security XUserId
+func (handler *StatsHandler) GetDailyStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	date, err := time.Parse(time.RFC3339, r.URL.Query().Get("date"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+			Error:   "Invalid date format",
+			Details: "date must be in RFC3339 format",
+		})
+		return
+	}
+	includeSandbox := r.URL.Query().Get("includeSandbox") == "true"
+	stats, err := handler.statsService.GetDailyStats(r.Context(), date, includeSandbox)
+	if err != nil {
+		if errors.Is(err, ErrNoStatsFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(dailyStatsToDTO(stats)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -241,6 +540,115 @@ func (handler *StatsHandler) GetPlanItemByWeekHistoryStats(w http.ResponseWriter
 	}
 }
 
+// GetWeeklyCapacity godoc
+// @Summary Get weekly capacity
+// @Description Compare the time planned for a week with the awake time available in it, the week without the user's
+// @Description daily sleep and the absent days. Durations are in seconds.
+// @Tags Stats
+// @Produce json
+// @Param weekDate query string true "Date in RFC3339 format (can be any day of the week)"
+// @Success 200 {object} WeeklyCapacityDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid date format"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "No current plan"
+// @Router /api/stats/capacity [get]
+// @Security XUserId
+func (handler *StatsHandler) GetWeeklyCapacity(w http.ResponseWriter, r *http.Request) {
+	weekDate, err := time.Parse(time.RFC3339, r.URL.Query().Get("weekDate"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
+			Error:   "Invalid 'weekDate' format",
+			Details: "date must be in RFC3339 format",
+		})
+		if encodeErr != nil {
+			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
+			return
+		}
+		return
+	}
+
+	capacity, err := handler.statsService.GetWeeklyCapacity(r.Context(), weekDate)
+	if err != nil {
+		if errors.Is(err, ErrNoStatsFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(WeeklyCapacityDTO{
+		StartDate: capacity.StartDate,
+		EndDate:   capacity.EndDate,
+		Planned:   int(capacity.Planned.Seconds()),
+		Available: int(capacity.Available.Seconds()),
+		Busy:      int(capacity.Busy.Seconds()),
+		Slack:     int(capacity.Slack.Seconds()),
+		Feasible:  capacity.Slack >= 0,
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// GetWeeklyFocus godoc
+// @Summary Get weekly focus statistics
+// @Description Measure how contiguous the time tracked in a week is, per day (in the user's timezone) and for the
+// @Description whole week. A block is time tracked for one item without interruption, a switch is a block following
+// @Description a block of another item on the same day. Blocks of at least an hour count as deep work. Durations are
+// @Description in seconds.
+// @Tags Stats
+// @Produce json
+// @Param date query string true "Date in RFC3339 format (can be any day of the week)"
+// @Param includeSandbox query bool false "Include sandbox (demo) events in the stats" default(false)
+// @Success 200 {object} WeeklyFocusStatsDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid date format"
+// @Failure 403 {string} string "User not found"
+// @Router /api/stats/focus [get]
+// @Security XUserId
+func (handler *StatsHandler) GetWeeklyFocus(w http.ResponseWriter, r *http.Request) {
+	date, err := time.Parse(time.RFC3339, r.URL.Query().Get("date"))
+	if err != nil {
+		rest.WriteBadRequest(w, rest.InvalidField("date", "Invalid date format", "date must be in RFC3339 format"))
+		return
+	}
+	includeSandbox := r.URL.Query().Get("includeSandbox") == "true"
+	focus, err := handler.statsService.GetWeeklyFocus(r.Context(), date, includeSandbox)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	perDay := make([]FocusStatsDTO, 0, len(focus.PerDay))
+	for _, day := range focus.PerDay {
+		perDay = append(perDay, focusStatsToDTO(day))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(WeeklyFocusStatsDTO{
+		StartDate: focus.StartDate,
+		EndDate:   focus.EndDate,
+		PerDay:    perDay,
+		Total:     focusStatsToDTO(focus.Total),
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func focusStatsToDTO(focus FocusStats) FocusStatsDTO {
+	return FocusStatsDTO{
+		Date:         focus.Date,
+		Blocks:       focus.Blocks,
+		Switches:     focus.Switches,
+		TotalTime:    int(focus.TotalTime.Seconds()),
+		AverageBlock: int(focus.AverageBlock().Seconds()),
+		LongestBlock: int(focus.LongestBlock.Seconds()),
+		DeepWorkTime: int(focus.DeepWorkTime.Seconds()),
+		Score:        roundPercentage(focus.Score()),
+	}
+}
+
 func planItemHistoryStatsToDTO(stats PlanItemHistoryStats) PlanItemHistoryStatsDTO {
 
 	statsPerWeek := make([]PlanItemStatsDTO, 0, len(stats.StatsPerWeek))
@@ -254,3 +662,69 @@ func planItemHistoryStatsToDTO(stats PlanItemHistoryStats) PlanItemHistoryStatsD
 		StatsPerWeek: statsPerWeek,
 	}
 }
+
+// GetTrend godoc
+// @Summary Get the long-term trend of a budget item
+// @Description Planned vs. tracked time of a budget item in each of the last weeks, including the current one,
+// @Description oldest first. Durations are in seconds. Weeks that were never planned have 0 planned time.
+// @Tags Stats
+// @Produce json
+// @Param budgetItemId query int true "Budget Item ID"
+// @Param weeks query int false "Number of weeks, 1-104" default(12)
+// @Success 200 {object} TrendDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid parameters"
+// @Failure 403 {string} string "User not found"
+// @Router /api/stats/trends [get]
+// @Security XUserId
+func (handler *StatsHandler) GetTrend(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	budgetItemId, err := strconv.Atoi(r.URL.Query().Get("budgetItemId"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+			Error:   "Invalid 'budgetItemId' format",
+			Details: "budgetItemId must be an integer",
+		})
+		return
+	}
+	weeks := DefaultTrendWeeks
+	if weeksStr := r.URL.Query().Get("weeks"); weeksStr != "" {
+		weeks, err = strconv.Atoi(weeksStr)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+				Error:   "Invalid 'weeks' format",
+				Details: "weeks must be an integer",
+			})
+			return
+		}
+	}
+
+	trend, err := handler.statsService.GetTrend(r.Context(), budgetItemId, weeks)
+	if err != nil {
+		if errors.Is(err, ErrInvalidTrendWeeks) {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+				Error:   "Invalid 'weeks' value",
+				Details: err.Error(),
+			})
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	dto := TrendDTO{BudgetItemId: trend.BudgetItemId, Weeks: make([]TrendWeekDTO, 0, len(trend.Weeks))}
+	for _, week := range trend.Weeks {
+		dto.Weeks = append(dto.Weeks, TrendWeekDTO{
+			Week:      week.Week.String(),
+			StartDate: week.StartDate,
+			EndDate:   week.EndDate,
+			Planned:   int(week.Planned.Seconds()),
+			Tracked:   int(week.Tracked.Seconds()),
+		})
+	}
+	if err := json.NewEncoder(w).Encode(dto); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
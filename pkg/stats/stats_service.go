@@ -1,9 +1,12 @@
 package stats
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/klokku/klokku/internal/utils"
@@ -17,22 +20,56 @@ import (
 
 var ErrPlanItemNotFound = fmt.Errorf("plan item not found")
 var ErrNoStatsFound = fmt.Errorf("no stats found")
+var ErrInvalidBaseline = fmt.Errorf("invalid baseline")
+var ErrNoBaselineFound = fmt.Errorf("no stats found for the baseline")
+var ErrInvalidTrendWeeks = fmt.Errorf("trend weeks must be between 1 and %d", MaxTrendWeeks)
+
+const (
+	// DefaultBaselineWeeks is the number of previous weeks looked at when the baseline does not set it.
+	DefaultBaselineWeeks = 8
+	MaxBaselineWeeks     = 52
+	// DefaultTrendWeeks is the number of weeks in a trend when the request does not set it.
+	DefaultTrendWeeks = 12
+	MaxTrendWeeks     = 104
+)
+
+// DeepWorkBlockDuration is the shortest block of uninterrupted time counted as deep work.
+const DeepWorkBlockDuration = time.Hour
 
 type StatsService interface {
-	GetWeeklyStats(ctx context.Context, weekTime time.Time) (WeeklyStatsSummary, error)
+	// GetWeeklyStats calculates stats for the week containing weekTime. Sandbox events are skipped unless includeSandbox is set.
+	GetWeeklyStats(ctx context.Context, weekTime time.Time, includeSandbox bool) (WeeklyStatsSummary, error)
+	// GetDailyStats calculates stats for the day containing date in the user's timezone.
+	GetDailyStats(ctx context.Context, date time.Time, includeSandbox bool) (DailyStats, error)
 	GetPlanItemByWeekHistoryStats(
 		ctx context.Context,
 		from time.Time,
 		to time.Time,
 		budgetItemId int,
 	) (PlanItemHistoryStats, error)
+	// CompareWithBaseline compares the time tracked in weekStats with the time tracked in the baseline.
+	CompareWithBaseline(
+		ctx context.Context,
+		weekStats WeeklyStatsSummary,
+		baseline Baseline,
+		includeSandbox bool,
+	) (BaselineComparison, error)
+	// GetWeeklyCapacity compares the time planned for the week containing weekTime with the awake time of that week.
+	GetWeeklyCapacity(ctx context.Context, weekTime time.Time) (WeeklyCapacity, error)
+	// GetTrend returns the planned and tracked time of the budget item in each of the last weeks, including the current one.
+	GetTrend(ctx context.Context, budgetItemId int, weeks int) (Trend, error)
+	// GetWeeklyFocus measures how fragmented the time tracked in the week containing weekTime is, per day (in the
+	// user's timezone) and for the whole week. Only finished events are taken into account.
+	GetWeeklyFocus(ctx context.Context, weekTime time.Time, includeSandbox bool) (WeeklyFocusStats, error)
 }
 
 type StatsServiceImpl struct {
+	repo                 Repository
 	currentEventProvider currentEventProvider
 	weeklyPlanService    weeklyPlanItemsReader
 	budgetPlanService    budgetPlanReader
 	calendar             calendarEventsReader
+	absences             absenceReader
 	clock                utils.Clock
 }
 
@@ -53,22 +90,30 @@ type calendarEventsReader interface {
 	GetEvents(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error)
 }
 
+type absenceReader interface {
+	AbsentDays(ctx context.Context, from time.Time, to time.Time) ([]time.Time, error)
+}
+
 func NewService(
+	repo Repository,
 	currentEventProvider currentEventProvider,
 	weeklyPlanService weeklyPlanItemsReader,
 	budgetPlanService budgetPlanReader,
 	calendar calendarEventsReader,
+	absences absenceReader,
 ) StatsService {
 	return &StatsServiceImpl{
+		repo:                 repo,
 		currentEventProvider: currentEventProvider,
 		weeklyPlanService:    weeklyPlanService,
 		budgetPlanService:    budgetPlanService,
 		calendar:             calendar,
+		absences:             absences,
 		clock:                &utils.SystemClock{},
 	}
 }
 
-func (s *StatsServiceImpl) GetWeeklyStats(ctx context.Context, weekTime time.Time) (WeeklyStatsSummary, error) {
+func (s *StatsServiceImpl) GetWeeklyStats(ctx context.Context, weekTime time.Time, includeSandbox bool) (WeeklyStatsSummary, error) {
 	currentUser, err := user.CurrentUser(ctx)
 	if err != nil {
 		return WeeklyStatsSummary{}, err
@@ -76,6 +121,9 @@ func (s *StatsServiceImpl) GetWeeklyStats(ctx context.Context, weekTime time.Tim
 
 	// Currently supports only weekly stats. `weekTime` is used to find out which week.
 	from, to := weekTimeRange(weekTime, currentUser.Settings.WeekFirstDay)
+	// The week starts at the user's day start hour, the early hours of its first date belong to the previous week
+	eventsFrom := time.Date(from.Year(), from.Month(), from.Day(), currentUser.Settings.DayStartHour, 0, 0, 0, from.Location())
+	eventsTo := eventsFrom.AddDate(0, 0, 7).Add(-time.Nanosecond)
 
 	weeklyItems, err := s.weeklyPlanService.GetItemsForWeek(ctx, from)
 	if err != nil {
@@ -109,12 +157,17 @@ func (s *StatsServiceImpl) GetWeeklyStats(ctx context.Context, weekTime time.Tim
 
 	totalPlanned := time.Duration(0)
 	for _, item := range planItems {
+		// Items with sub-items hold the sum of their sub-items' durations
+		if budgetPlan.HasChildren(item.BudgetItemId) {
+			continue
+		}
 		totalPlanned += item.WeeklyItemDuration
 	}
 
 	currentEventBudgetItemId := 0
+	currentEventLocation := ""
 	currentEventTime := time.Duration(0)
-	if s.clock.Now().After(from) && s.clock.Now().Before(to) {
+	if s.clock.Now().After(eventsFrom) && s.clock.Now().Before(eventsTo) {
 		log.Debugf("Calculating stats for current week. Taking into account current event if any.")
 		currentEvent, err := s.currentEventProvider.FindCurrentEvent(ctx)
 		if err != nil {
@@ -122,59 +175,79 @@ func (s *StatsServiceImpl) GetWeeklyStats(ctx context.Context, weekTime time.Tim
 		}
 		if currentEvent.Id != 0 { // current event exists
 			currentEventBudgetItemId = currentEvent.PlanItem.BudgetItemId
+			currentEventLocation = currentEvent.Location
 			currentEventTime = s.clock.Now().Sub(currentEvent.StartTime)
 		}
 	}
 
-	calendarEvents, err := s.calendar.GetEvents(ctx, from, to)
+	calendarEvents, err := s.calendar.GetEvents(ctx, eventsFrom, eventsTo)
 	if err != nil {
 		return WeeklyStatsSummary{}, err
 	}
+	if !includeSandbox {
+		calendarEvents = calendar.WithoutSandbox(calendarEvents)
+	}
 	userTimezone, err := time.LoadLocation(currentUser.Settings.Timezone)
 	if err != nil {
 		return WeeklyStatsSummary{}, fmt.Errorf("failed to load user timezone: %w", err)
 	}
-	eventsDurationPerDay := s.eventsDurationPerDay(calendarEvents, userTimezone)
+	absentDays, err := s.absentDays(ctx, from, to)
+	if err != nil {
+		return WeeklyStatsSummary{}, err
+	}
+	// Time before the user's day start hour counts into the previous day
+	dayBoundary := utils.NewDayBoundary(userTimezone).WithStartHour(currentUser.Settings.DayStartHour)
+	eventsDurationPerDay := s.eventsDurationPerDay(calendarEvents, dayBoundary)
 	eventsDurationPerBudget := s.eventsDurationPerBudget(calendarEvents)
+	sessionsPerDay := eventsSessionsPerDay(calendarEvents, dayBoundary)
+	sessionsPerBudget := eventsSessionsPerBudget(calendarEvents)
+	today := dayKey(dayBoundary, s.clock.Now())
 
 	statsByDate := make([]DailyStats, 0, len(eventsDurationPerDay))
 	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
-		isToday := sameDays(s.clock.Now(), date, userTimezone)
+		// Normalize loop date to user timezone midnight for map lookup
+		lookupDate := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, userTimezone)
+		isToday := lookupDate.Equal(today)
 		todayCurrentEventTime := time.Duration(0)
 		if isToday {
 			todayCurrentEventTime = currentEventTime
 		}
-
-		// Normalize loop date to user timezone midnight for map lookup
-		lookupDate := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, userTimezone)
 		dateBudgetDuration := eventsDurationPerDay[lookupDate]
 
 		budgetsStats := prepareStatsByBudget(
 			planItems,
 			dateBudgetDuration,
+			sessionsPerDay[lookupDate],
 			currentEventBudgetItemId,
 			todayCurrentEventTime,
 			date,
 			date.AddDate(0, 0, 1),
 		)
+		weekday := date.In(userTimezone).Weekday()
 		dateTotalTime := time.Duration(0)
-		for _, budgetStat := range budgetsStats {
+		for i, budgetStat := range budgetsStats {
+			budgetsStats[i].DailyTarget = budgetStat.PlanItem.DailyDurations[weekday]
 			dateTotalTime += budgetStat.Duration
 		}
+		rollUpSubItemStats(budgetsStats)
 
-		dailyStats := DailyStats{date, budgetsStats, dateTotalTime}
+		dailyStats := DailyStats{date, budgetsStats, dateTotalTime, absentDays[civilDate(date)]}
 		statsByDate = append(statsByDate, dailyStats)
 	}
 
 	statsByBudget := prepareStatsByBudget(
 		planItems,
 		eventsDurationPerBudget,
+		sessionsPerBudget,
 		currentEventBudgetItemId,
 		currentEventTime,
 		from,
 		to,
 	)
 
+	statsByCategory := prepareStatsByCategory(statsByBudget, budgetPlan.Categories)
+	rollUpSubItemStats(statsByBudget)
+
 	totalTime := time.Duration(0)
 	for _, budgetDuration := range eventsDurationPerBudget {
 		totalTime += budgetDuration
@@ -186,27 +259,65 @@ func (s *StatsServiceImpl) GetWeeklyStats(ctx context.Context, weekTime time.Tim
 		EndDate:        to,
 		PerDay:         statsByDate,
 		PerPlanItem:    statsByBudget,
+		PerCategory:    statsByCategory,
 		TotalPlanned:   totalPlanned,
 		TotalTime:      totalTime,
 		TotalRemaining: totalPlanned - totalTime,
+		AbsentDays:     len(absentDays),
+		PerLocation:    prepareStatsByLocation(calendarEvents, currentEventLocation, currentEventTime),
 	}, nil
 }
 
-func (s *StatsServiceImpl) eventsDurationPerDay(events []calendar.Event, userTimezone *time.Location) map[time.Time]map[int]time.Duration {
+func (s *StatsServiceImpl) GetDailyStats(ctx context.Context, date time.Time, includeSandbox bool) (DailyStats, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return DailyStats{}, err
+	}
+	userTimezone, err := time.LoadLocation(currentUser.Settings.Timezone)
+	if err != nil {
+		return DailyStats{}, fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	date = date.In(userTimezone)
+
+	// Daily stats are a part of the weekly stats, the day's plan items and targets come from the week's plan
+	weekStats, err := s.GetWeeklyStats(ctx, date, includeSandbox)
+	if err != nil {
+		return DailyStats{}, err
+	}
+	for _, day := range weekStats.PerDay {
+		if sameDays(day.Date, date, userTimezone) {
+			return day, nil
+		}
+	}
+	return DailyStats{Date: utils.NewDayBoundary(userTimezone).StartOfDay(date)}, nil
+}
+
+// eventsDurationPerDay sums up the events per user's day, keyed by the midnight of the day's date.
+func (s *StatsServiceImpl) eventsDurationPerDay(events []calendar.Event, dayBoundary utils.DayBoundary) map[time.Time]map[int]time.Duration {
 	eventsByDate := make(map[time.Time]map[int]time.Duration)
 	for _, e := range events {
-		// Use user timezone midnight for the map key to avoid location pointer mismatches
-		t := e.StartTime.In(userTimezone)
-		date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, userTimezone)
-
-		if eventsByDate[date] == nil {
-			eventsByDate[date] = make(map[int]time.Duration)
+		if !e.EndTime.After(e.StartTime) {
+			continue
+		}
+		// Events kept whole across the start of a day are distributed between the days they span
+		for _, part := range dayBoundary.Split(e.StartTime, e.EndTime) {
+			date := dayKey(dayBoundary, part.Start)
+			if eventsByDate[date] == nil {
+				eventsByDate[date] = make(map[int]time.Duration)
+			}
+			eventsByDate[date][e.Metadata.BudgetItemId] += part.End.Sub(part.Start)
 		}
-		eventsByDate[date][e.Metadata.BudgetItemId] += duration(e)
 	}
 	return eventsByDate
 }
 
+// dayKey returns the midnight of the date of the user's day containing t, so days are looked up by their date
+// whatever hour they start at.
+func dayKey(dayBoundary utils.DayBoundary, t time.Time) time.Time {
+	year, month, day := dayBoundary.Date(t)
+	return time.Date(year, month, day, 0, 0, 0, 0, dayBoundary.Location())
+}
+
 func (s *StatsServiceImpl) eventsDurationPerBudget(events []calendar.Event) map[int]time.Duration {
 	eventsByBudget := make(map[int]time.Duration)
 	for _, e := range events {
@@ -215,13 +326,73 @@ func (s *StatsServiceImpl) eventsDurationPerBudget(events []calendar.Event) map[
 	return eventsByBudget
 }
 
+// eventsSessionsPerBudget counts the sessions tracked for each budget item.
+func eventsSessionsPerBudget(events []calendar.Event) map[int]int {
+	sessionsByBudget := make(map[int]int)
+	for _, e := range sessionStarts(events) {
+		sessionsByBudget[e.Metadata.BudgetItemId]++
+	}
+	return sessionsByBudget
+}
+
+// eventsSessionsPerDay counts the sessions tracked for each budget item by the user's day they started on.
+func eventsSessionsPerDay(events []calendar.Event, dayBoundary utils.DayBoundary) map[time.Time]map[int]int {
+	sessionsByDate := make(map[time.Time]map[int]int)
+	for _, e := range sessionStarts(events) {
+		date := dayKey(dayBoundary, e.StartTime)
+		if sessionsByDate[date] == nil {
+			sessionsByDate[date] = make(map[int]int)
+		}
+		sessionsByDate[date][e.Metadata.BudgetItemId]++
+	}
+	return sessionsByDate
+}
+
+// sessionContinuationGap is the longest gap between events still treated as one session. Events split at
+// midnight end one nanosecond before the next day starts.
+const sessionContinuationGap = time.Second
+
+// sessionStarts returns events that start a new session. An event directly continuing the previous event of
+// the same budget item, or a part of the same logical event (e.g. one split at midnight), belongs to the same session.
+// Events that do not end after they start are no sessions.
+func sessionStarts(events []calendar.Event) []calendar.Event {
+	sorted := slices.DeleteFunc(slices.Clone(events), func(e calendar.Event) bool {
+		return !e.EndTime.After(e.StartTime)
+	})
+	slices.SortFunc(sorted, func(a, b calendar.Event) int {
+		return a.StartTime.Compare(b.StartTime)
+	})
+	lastByBudget := make(map[int]calendar.Event)
+	starts := make([]calendar.Event, 0, len(sorted))
+	for _, e := range sorted {
+		last, ok := lastByBudget[e.Metadata.BudgetItemId]
+		if !ok || !continues(e, last.LogicalUID(), last.EndTime) {
+			starts = append(starts, e)
+		}
+		lastByBudget[e.Metadata.BudgetItemId] = e
+	}
+	return starts
+}
+
+// continues reports whether the event continues the previous event of the same budget item, which ended at
+// previousEnd. Parts of the same logical event always continue each other, even when one of them was shortened.
+func continues(e calendar.Event, previousLogicalUid string, previousEnd time.Time) bool {
+	if e.ParentUID != "" && e.ParentUID == previousLogicalUid {
+		return true
+	}
+	gap := e.StartTime.Sub(previousEnd)
+	return gap >= 0 && gap <= sessionContinuationGap
+}
+
+// duration of the event, an event ending before it starts takes no time rather than a negative one.
 func duration(event calendar.Event) time.Duration {
-	return event.EndTime.Sub(event.StartTime)
+	return max(event.EndTime.Sub(event.StartTime), 0)
 }
 
 func prepareStatsByBudget(
 	planItems []PlanItem,
 	durationByBudgetItemId map[int]time.Duration,
+	sessionsByBudgetItemId map[int]int,
 	currentEventBudgetItemId int,
 	currentEventTime time.Duration,
 	startDate time.Time,
@@ -232,14 +403,17 @@ func prepareStatsByBudget(
 	for _, item := range planItems {
 		budgetDuration := durationByBudgetItemId[item.BudgetItemId]
 		budgetCurrentEventTime := time.Duration(0)
-		if item.BudgetItemId == currentEventBudgetItemId {
+		sessions := sessionsByBudgetItemId[item.BudgetItemId]
+		if item.BudgetItemId == currentEventBudgetItemId && currentEventTime > 0 {
 			budgetCurrentEventTime = currentEventTime
+			sessions++
 		}
 
 		budgetStats := PlanItemStats{
 			PlanItem:  item,
 			Duration:  budgetDuration + budgetCurrentEventTime,
 			Remaining: calculateRemainingDuration(&item, budgetDuration) - budgetCurrentEventTime,
+			Sessions:  sessions,
 			StartDate: startDate,
 			EndDate:   endDate,
 		}
@@ -248,6 +422,99 @@ func prepareStatsByBudget(
 	return statsByBudget
 }
 
+// prepareStatsByLocation sums up the tracked time by location, the longest first. Locations differing only in case
+// or white space, e.g. "Office" and "office ", are the same, named as they were first tracked. It returns nil when no
+// time was tracked with a location.
+func prepareStatsByLocation(events []calendar.Event, currentEventLocation string, currentEventTime time.Duration) []LocationStats {
+	var statsByLocation []LocationStats
+	indexByKey := make(map[string]int)
+	add := func(location string, d time.Duration) {
+		location = strings.Join(strings.Fields(location), " ")
+		key := strings.ToLower(location)
+		i, ok := indexByKey[key]
+		if !ok {
+			i = len(statsByLocation)
+			indexByKey[key] = i
+			statsByLocation = append(statsByLocation, LocationStats{Location: location})
+		}
+		statsByLocation[i].Duration += d
+	}
+	for _, e := range events {
+		add(e.Metadata.Location, duration(e))
+	}
+	if currentEventTime > 0 {
+		add(currentEventLocation, currentEventTime)
+	}
+	_, unlocated := indexByKey[""]
+	if len(statsByLocation) == 0 || (unlocated && len(statsByLocation) == 1) {
+		return nil
+	}
+	slices.SortFunc(statsByLocation, func(a, b LocationStats) int {
+		if a.Duration != b.Duration {
+			return cmp.Compare(b.Duration, a.Duration)
+		}
+		return cmp.Compare(a.Location, b.Location)
+	})
+	return statsByLocation
+}
+
+// rollUpSubItemStats adds the time of sub-items to the stats of their parent items.
+func rollUpSubItemStats(itemStats []PlanItemStats) {
+	indexByBudgetItemId := make(map[int]int, len(itemStats))
+	for i, stats := range itemStats {
+		indexByBudgetItemId[stats.PlanItem.BudgetItemId] = i
+	}
+	for _, stats := range itemStats {
+		parentIdx, ok := indexByBudgetItemId[stats.PlanItem.ParentBudgetItemId]
+		if stats.PlanItem.ParentBudgetItemId == 0 || !ok {
+			continue
+		}
+		itemStats[parentIdx].Duration += stats.Duration
+		itemStats[parentIdx].Remaining -= stats.Duration
+		itemStats[parentIdx].Sessions += stats.Sessions
+	}
+}
+
+// prepareStatsByCategory rolls up plan item stats by category, in category order, with uncategorized items last.
+// It expects stats before sub-items are rolled up into their parents.
+func prepareStatsByCategory(itemStats []PlanItemStats, categories []budget_plan.Category) []CategoryStats {
+	if len(categories) == 0 {
+		return nil
+	}
+	parentIds := make(map[int]bool)
+	for _, stats := range itemStats {
+		parentIds[stats.PlanItem.ParentBudgetItemId] = true
+	}
+	delete(parentIds, 0)
+	statsByCategory := make([]CategoryStats, 0, len(categories)+1)
+	indexByCategoryId := make(map[int]int, len(categories))
+	for _, category := range categories {
+		indexByCategoryId[category.Id] = len(statsByCategory)
+		statsByCategory = append(statsByCategory, CategoryStats{
+			CategoryId: category.Id,
+			Name:       category.Name,
+			Color:      category.Color,
+		})
+	}
+	for _, stats := range itemStats {
+		idx, ok := indexByCategoryId[stats.PlanItem.CategoryId]
+		if !ok {
+			idx = len(statsByCategory)
+			indexByCategoryId[stats.PlanItem.CategoryId] = idx
+			statsByCategory = append(statsByCategory, CategoryStats{})
+		}
+		planned := stats.PlanItem.WeeklyItemDuration
+		if parentIds[stats.PlanItem.BudgetItemId] {
+			// planned time of an item with sub-items is already counted by the sub-items
+			planned = 0
+		}
+		statsByCategory[idx].Planned += planned
+		statsByCategory[idx].Duration += stats.Duration
+		statsByCategory[idx].Remaining += planned - stats.Duration
+	}
+	return statsByCategory
+}
+
 func calculateRemainingDuration(
 	planItem *PlanItem,
 	duration time.Duration,
@@ -255,6 +522,24 @@ func calculateRemainingDuration(
 	return planItem.WeeklyItemDuration - duration
 }
 
+// absentDays returns the days from from to to the user is absent on, keyed by civilDate.
+func (s *StatsServiceImpl) absentDays(ctx context.Context, from time.Time, to time.Time) (map[time.Time]bool, error) {
+	days, err := s.absences.AbsentDays(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absences: %w", err)
+	}
+	absent := make(map[time.Time]bool, len(days))
+	for _, day := range days {
+		absent[day] = true
+	}
+	return absent, nil
+}
+
+// civilDate returns the day of date at midnight UTC, the way absent days are given.
+func civilDate(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+}
+
 func sameDays(date1, date2 time.Time, loc *time.Location) bool {
 	year1, month1, day1 := date1.In(loc).Date()
 	year2, month2, day2 := date2.In(loc).Date()
@@ -312,9 +597,12 @@ func (s *StatsServiceImpl) GetPlanItemByWeekHistoryStats(
 		if weeklyItem.BudgetItemId == 0 {
 			return PlanItemHistoryStats{}, ErrPlanItemNotFound
 		}
-		budgetItem, err := s.budgetPlanService.GetItem(ctx, weeklyItem.BudgetItemId)
-		if err != nil {
-			return PlanItemHistoryStats{}, err
+		var budgetItem budget_plan.BudgetItem
+		if !weeklyItem.IsAdHoc() {
+			budgetItem, err = s.budgetPlanService.GetItem(ctx, weeklyItem.BudgetItemId)
+			if err != nil {
+				return PlanItemHistoryStats{}, err
+			}
 		}
 
 		planItem := combinePlanItemData(weeklyItem, budgetItem)
@@ -324,6 +612,7 @@ func (s *StatsServiceImpl) GetPlanItemByWeekHistoryStats(
 		if err != nil {
 			return PlanItemHistoryStats{}, err
 		}
+		calendarEvents = calendar.WithoutSandbox(calendarEvents)
 
 		eventsDurationPerBudget := s.eventsDurationPerBudget(calendarEvents)
 
@@ -331,6 +620,7 @@ func (s *StatsServiceImpl) GetPlanItemByWeekHistoryStats(
 			PlanItem:  planItem,
 			Duration:  eventsDurationPerBudget[budgetItemId],
 			Remaining: weeklyItem.WeeklyDuration - eventsDurationPerBudget[budgetItemId],
+			Sessions:  eventsSessionsPerBudget(calendarEvents)[budgetItemId],
 			StartDate: startDate,
 			EndDate:   endDate,
 		})
@@ -343,10 +633,294 @@ func (s *StatsServiceImpl) GetPlanItemByWeekHistoryStats(
 	}, nil
 }
 
+func (s *StatsServiceImpl) CompareWithBaseline(
+	ctx context.Context,
+	weekStats WeeklyStatsSummary,
+	baseline Baseline,
+	includeSandbox bool,
+) (BaselineComparison, error) {
+	weeks := baseline.Weeks
+	if weeks == 0 {
+		weeks = DefaultBaselineWeeks
+	}
+	if weeks < 1 || weeks > MaxBaselineWeeks {
+		return BaselineComparison{}, fmt.Errorf("%w: weeks must be between 1 and %d", ErrInvalidBaseline, MaxBaselineWeeks)
+	}
+
+	var baselineWeeks []WeeklyStatsSummary
+	switch baseline.Kind {
+	case BaselineWeek:
+		if baseline.Date.IsZero() {
+			return BaselineComparison{}, fmt.Errorf("%w: date is required", ErrInvalidBaseline)
+		}
+		baselineWeek, err := s.baselineWeekStats(ctx, baseline.Date, includeSandbox)
+		if err != nil {
+			return BaselineComparison{}, err
+		}
+		if hasTrackedTime(baselineWeek) {
+			baselineWeeks = append(baselineWeeks, baselineWeek)
+		}
+	case BaselineBestWeek, BaselineAverage:
+		for i := 1; i <= weeks; i++ {
+			previousWeek, err := s.baselineWeekStats(ctx, weekStats.StartDate.AddDate(0, 0, -7*i), includeSandbox)
+			if err != nil {
+				return BaselineComparison{}, err
+			}
+			if hasTrackedTime(previousWeek) {
+				baselineWeeks = append(baselineWeeks, previousWeek)
+			}
+		}
+		if baseline.Kind == BaselineBestWeek && len(baselineWeeks) > 0 {
+			best := baselineWeeks[0]
+			for _, previousWeek := range baselineWeeks[1:] {
+				if planCompletion(previousWeek) > planCompletion(best) {
+					best = previousWeek
+				}
+			}
+			baselineWeeks = []WeeklyStatsSummary{best}
+		}
+	default:
+		return BaselineComparison{}, fmt.Errorf("%w: unknown kind %q", ErrInvalidBaseline, baseline.Kind)
+	}
+	if len(baselineWeeks) == 0 {
+		return BaselineComparison{}, ErrNoBaselineFound
+	}
+
+	// Weeks are collected from the most recent one
+	comparison := BaselineComparison{
+		Kind:      baseline.Kind,
+		StartDate: baselineWeeks[len(baselineWeeks)-1].StartDate,
+		EndDate:   baselineWeeks[0].EndDate,
+		Weeks:     len(baselineWeeks),
+		TotalTime: weekStats.TotalTime,
+	}
+	baselineDurations := make(map[int]time.Duration)
+	for _, baselineWeek := range baselineWeeks {
+		comparison.BaselineTotalTime += baselineWeek.TotalTime
+		for _, itemStats := range baselineWeek.PerPlanItem {
+			baselineDurations[itemStats.PlanItem.BudgetItemId] += itemStats.Duration
+		}
+	}
+	comparison.BaselineTotalTime /= time.Duration(len(baselineWeeks))
+	comparison.Difference = comparison.TotalTime - comparison.BaselineTotalTime
+
+	comparison.PerPlanItem = make([]PlanItemComparison, 0, len(weekStats.PerPlanItem))
+	for _, itemStats := range weekStats.PerPlanItem {
+		baselineDuration := baselineDurations[itemStats.PlanItem.BudgetItemId] / time.Duration(len(baselineWeeks))
+		comparison.PerPlanItem = append(comparison.PerPlanItem, PlanItemComparison{
+			BudgetItemId:     itemStats.PlanItem.BudgetItemId,
+			Name:             itemStats.PlanItem.Name,
+			Duration:         itemStats.Duration,
+			BaselineDuration: baselineDuration,
+			Difference:       itemStats.Duration - baselineDuration,
+		})
+	}
+	return comparison, nil
+}
+
+// baselineWeekStats returns the stats of the week containing weekTime. Weeks without a plan have no stats.
+func (s *StatsServiceImpl) baselineWeekStats(ctx context.Context, weekTime time.Time, includeSandbox bool) (WeeklyStatsSummary, error) {
+	weekStats, err := s.GetWeeklyStats(ctx, weekTime, includeSandbox)
+	if err != nil && !errors.Is(err, ErrNoStatsFound) {
+		return WeeklyStatsSummary{}, err
+	}
+	return weekStats, nil
+}
+
+// hasTrackedTime reports whether anything was tracked in the week. Weeks without a stored plan get the items of the
+// current budget plan, so a week nobody tracked in still has items, all of them at zero.
+func hasTrackedTime(weekStats WeeklyStatsSummary) bool {
+	return weekStats.TotalTime > 0
+}
+
+// planCompletion is the time tracked within the planned durations of the week's items, time tracked over an item's
+// plan does not count. Items with sub-items are skipped as their sub-items are counted.
+func planCompletion(weekStats WeeklyStatsSummary) time.Duration {
+	parents := make(map[int]bool)
+	for _, itemStats := range weekStats.PerPlanItem {
+		if itemStats.PlanItem.ParentBudgetItemId != 0 {
+			parents[itemStats.PlanItem.ParentBudgetItemId] = true
+		}
+	}
+	completion := time.Duration(0)
+	for _, itemStats := range weekStats.PerPlanItem {
+		if parents[itemStats.PlanItem.BudgetItemId] {
+			continue
+		}
+		completion += min(itemStats.Duration, itemStats.PlanItem.WeeklyItemDuration)
+	}
+	return completion
+}
+
+func (s *StatsServiceImpl) GetWeeklyCapacity(ctx context.Context, weekTime time.Time) (WeeklyCapacity, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return WeeklyCapacity{}, err
+	}
+	userTimezone, err := time.LoadLocation(currentUser.Settings.Timezone)
+	if err != nil {
+		return WeeklyCapacity{}, fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	from, to := weekTimeRange(weekTime.In(userTimezone), currentUser.Settings.WeekFirstDay)
+
+	weeklyItems, err := s.weeklyPlanService.GetItemsForWeek(ctx, from)
+	if err != nil {
+		if errors.Is(err, weekly_plan.ErrNoCurrentPlan) {
+			return WeeklyCapacity{}, ErrNoStatsFound
+		}
+		return WeeklyCapacity{}, err
+	}
+	planned := time.Duration(0)
+	if len(weeklyItems) > 0 {
+		budgetPlan, err := s.budgetPlanService.GetPlan(ctx, weeklyItems[0].BudgetPlanId)
+		if err != nil {
+			return WeeklyCapacity{}, err
+		}
+		for _, item := range weeklyItems {
+			// Items with sub-items hold the sum of their sub-items' durations
+			if budgetPlan.HasChildren(item.BudgetItemId) {
+				continue
+			}
+			planned += item.WeeklyDuration
+		}
+	}
+
+	absentDays, err := s.absentDays(ctx, from, to)
+	if err != nil {
+		return WeeklyCapacity{}, err
+	}
+	dailySleep := currentUser.Settings.DailySleep
+	if dailySleep <= 0 {
+		dailySleep = user.DefaultDailySleep
+	}
+	available := time.Duration(0)
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		if absentDays[civilDate(date)] {
+			continue
+		}
+		// Days of DST changes are an hour shorter or longer
+		available += date.AddDate(0, 0, 1).Sub(date) - dailySleep
+	}
+	// Busy calendars cannot be imported yet, so nothing blocks the awake time
+	busy := time.Duration(0)
+
+	return WeeklyCapacity{
+		StartDate: from,
+		EndDate:   to,
+		Planned:   planned,
+		Available: available,
+		Busy:      busy,
+		Slack:     available - busy - planned,
+	}, nil
+}
+
+func (s *StatsServiceImpl) GetWeeklyFocus(ctx context.Context, weekTime time.Time, includeSandbox bool) (WeeklyFocusStats, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return WeeklyFocusStats{}, err
+	}
+	userTimezone, err := time.LoadLocation(currentUser.Settings.Timezone)
+	if err != nil {
+		return WeeklyFocusStats{}, fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	from, to := weekTimeRange(weekTime.In(userTimezone), currentUser.Settings.WeekFirstDay)
+
+	calendarEvents, err := s.calendar.GetEvents(ctx, from, to)
+	if err != nil {
+		return WeeklyFocusStats{}, err
+	}
+	if !includeSandbox {
+		calendarEvents = calendar.WithoutSandbox(calendarEvents)
+	}
+	// Blocks started before the user's day start hour count into the previous day
+	dayBoundary := utils.NewDayBoundary(userTimezone).WithStartHour(currentUser.Settings.DayStartHour)
+	blocksByDate := make(map[time.Time][]focusBlock)
+	for _, block := range focusBlocks(calendarEvents) {
+		date := dayKey(dayBoundary, block.start)
+		blocksByDate[date] = append(blocksByDate[date], block)
+	}
+
+	total := FocusStats{Date: from}
+	perDay := make([]FocusStats, 0, 7)
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		day := dayFocus(date, blocksByDate[date])
+		total.Blocks += day.Blocks
+		total.Switches += day.Switches
+		total.TotalTime += day.TotalTime
+		total.DeepWorkTime += day.DeepWorkTime
+		total.LongestBlock = max(total.LongestBlock, day.LongestBlock)
+		perDay = append(perDay, day)
+	}
+	return WeeklyFocusStats{
+		StartDate: from,
+		EndDate:   to,
+		PerDay:    perDay,
+		Total:     total,
+	}, nil
+}
+
+// focusBlock is time tracked for one budget item without interruption.
+type focusBlock struct {
+	budgetItemId int
+	// logicalUid of the last event of the block
+	logicalUid string
+	start      time.Time
+	end        time.Time
+	// duration is the sum of the durations of the events, the parts of a logical event may have gaps between them
+	duration time.Duration
+}
+
+// focusBlocks merges the events into blocks, in the order they started. An event directly continuing the previous
+// event of the same budget item, or a part of the same logical event (e.g. one split at midnight), extends its block.
+// The block takes the time of its events only, not of the gaps between them.
+func focusBlocks(events []calendar.Event) []focusBlock {
+	sorted := slices.Clone(events)
+	slices.SortFunc(sorted, func(a, b calendar.Event) int {
+		return a.StartTime.Compare(b.StartTime)
+	})
+	blocks := make([]focusBlock, 0, len(sorted))
+	for _, e := range sorted {
+		if len(blocks) > 0 {
+			last := &blocks[len(blocks)-1]
+			if last.budgetItemId == e.Metadata.BudgetItemId && continues(e, last.logicalUid, last.end) {
+				last.end = e.EndTime
+				last.duration += duration(e)
+				last.logicalUid = e.LogicalUID()
+				continue
+			}
+		}
+		blocks = append(blocks, focusBlock{
+			budgetItemId: e.Metadata.BudgetItemId,
+			logicalUid:   e.LogicalUID(),
+			start:        e.StartTime,
+			end:          e.EndTime,
+			duration:     duration(e),
+		})
+	}
+	return blocks
+}
+
+// dayFocus sums up the blocks started on the day. A block is counted whole on the day it started.
+func dayFocus(date time.Time, blocks []focusBlock) FocusStats {
+	day := FocusStats{Date: date, Blocks: len(blocks)}
+	for i, block := range blocks {
+		length := block.duration
+		if i > 0 && blocks[i-1].budgetItemId != block.budgetItemId {
+			day.Switches++
+		}
+		day.TotalTime += length
+		day.LongestBlock = max(day.LongestBlock, length)
+		if length >= DeepWorkBlockDuration {
+			day.DeepWorkTime += length
+		}
+	}
+	return day
+}
+
 func combinePlanItemData(weeklyItem weekly_plan.WeeklyPlanItem, budgetItem budget_plan.BudgetItem) PlanItem {
 	return PlanItem{
-		BudgetPlanId:       budgetItem.PlanId,
-		BudgetItemId:       budgetItem.Id,
+		BudgetPlanId:       weeklyItem.BudgetPlanId,
+		BudgetItemId:       weeklyItem.BudgetItemId,
 		WeeklyItemId:       weeklyItem.Id,
 		Name:               weeklyItem.Name,
 		Icon:               weeklyItem.Icon,
@@ -355,6 +929,41 @@ func combinePlanItemData(weeklyItem weekly_plan.WeeklyPlanItem, budgetItem budge
 		WeeklyItemDuration: weeklyItem.WeeklyDuration,
 		BudgetItemDuration: budgetItem.WeeklyDuration,
 		WeeklyOccurrences:  weeklyItem.WeeklyOccurrences,
+		DailyDurations:     weeklyItem.DailyDurations,
+		CategoryId:         budgetItem.CategoryId,
+		ParentBudgetItemId: budgetItem.ParentId,
+		Unit:               budgetItem.Unit,
 		Notes:              weeklyItem.Notes,
+		AdHoc:              weeklyItem.IsAdHoc(),
+	}
+}
+
+func (s *StatsServiceImpl) GetTrend(ctx context.Context, budgetItemId int, weeks int) (Trend, error) {
+	if weeks < 1 || weeks > MaxTrendWeeks {
+		return Trend{}, ErrInvalidTrendWeeks
+	}
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return Trend{}, err
+	}
+	userTimezone, err := time.LoadLocation(currentUser.Settings.Timezone)
+	if err != nil {
+		return Trend{}, fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	currentWeekStart, _ := weekTimeRange(s.clock.Now().In(userTimezone), currentUser.Settings.WeekFirstDay)
+
+	trendWeeks := make([]TrendWeek, 0, weeks)
+	for i := weeks - 1; i >= 0; i-- {
+		startDate := currentWeekStart.AddDate(0, 0, -7*i)
+		trendWeeks = append(trendWeeks, TrendWeek{
+			Week:      weekly_plan.WeekNumberFromDate(startDate, currentUser.Settings.WeekFirstDay),
+			StartDate: startDate,
+			EndDate:   startDate.AddDate(0, 0, 7),
+		})
+	}
+	trendWeeks, err = s.repo.GetWeeklyTrend(ctx, currentUser.Id, budgetItemId, trendWeeks)
+	if err != nil {
+		return Trend{}, err
 	}
+	return Trend{BudgetItemId: budgetItemId, Weeks: trendWeeks}, nil
 }
@@ -15,6 +15,7 @@ import (
 	"github.com/klokku/klokku/pkg/user"
 	"github.com/klokku/klokku/pkg/weekly_plan"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var location, _ = time.LoadLocation("Europe/Warsaw")
@@ -23,13 +24,17 @@ var clock = &utils.MockClock{FixedNow: time.Date(2023, time.January, 1, 0, 0, 0,
 var weeklyPlanService = newWeeklyPlanItemsReaderStub()
 var budgetPlanService = newBudgetPlanReaderStub()
 var currentEventStub = newCurrentEventProviderStub()
+var statsRepo = newRepositoryStub()
+var absenceStub = newAbsenceReaderStub()
 
 func setup(t *testing.T) (StatsService, context.Context, func()) {
 	service := &StatsServiceImpl{
+		repo:                 statsRepo,
 		currentEventProvider: currentEventStub,
 		weeklyPlanService:    weeklyPlanService,
 		budgetPlanService:    budgetPlanService,
 		calendar:             calendarStub,
+		absences:             absenceStub,
 		clock:                clock,
 	}
 	ctx := user.WithUser(context.Background(), user.User{
@@ -51,6 +56,8 @@ func setup(t *testing.T) (StatsService, context.Context, func()) {
 		weeklyPlanService.reset()
 		budgetPlanService.reset()
 		currentEventStub.reset()
+		statsRepo.reset()
+		absenceStub.reset()
 		calendarStub.Cleanup()
 	}
 }
@@ -122,7 +129,7 @@ func TestStatsServiceImpl_GetStats(t *testing.T) {
 	})
 
 	// when
-	stats, _ := statsService.GetWeeklyStats(ctx, startTime)
+	stats, _ := statsService.GetWeeklyStats(ctx, startTime, false)
 
 	// then
 	assert.NotNil(t, stats)
@@ -146,6 +153,9 @@ func TestStatsServiceImpl_GetStats(t *testing.T) {
 	assert.Equal(t, time.Duration(150)*time.Minute, b1.Duration)
 	b2 := findBudgetByName(stats.PerPlanItem, "BudgetItem 2")
 	assert.Equal(t, time.Duration(105)*time.Minute, b2.Duration)
+	assert.InDelta(t, 125.0, b1.Percentage(), 0.001)
+	assert.InDelta(t, 87.5, b2.Percentage(), 0.001)
+	assert.InDelta(t, 106.25, stats.TotalPercentage(), 0.001)
 }
 
 func TestStatsServiceImpl_GetStats_WithCurrentEvent(t *testing.T) {
@@ -195,7 +205,7 @@ func TestStatsServiceImpl_GetStats_WithCurrentEvent(t *testing.T) {
 	})
 
 	// when
-	stats, _ := statsService.GetWeeklyStats(ctx, startTime)
+	stats, _ := statsService.GetWeeklyStats(ctx, startTime, false)
 
 	// then
 	// check on a budget list
@@ -211,6 +221,522 @@ func TestStatsServiceImpl_GetStats_WithCurrentEvent(t *testing.T) {
 	assert.Equal(t, time.Duration(30)*time.Minute, stats.TotalRemaining)
 }
 
+func TestStatsServiceImpl_GetStats_WithDailyDurations(t *testing.T) {
+	statsService, ctx, teardown := setup(t)
+	defer teardown()
+
+	// given
+	startTime := time.Date(2023, time.February, 6, 0, 0, 0, 0, location) // Monday
+	weeklyPlanService.setItems([]weekly_plan.WeeklyPlanItem{{
+		BudgetPlanId:   1,
+		Id:             101,
+		BudgetItemId:   1,
+		Name:           "Work",
+		WeeklyDuration: 40 * time.Hour,
+		DailyDurations: map[time.Weekday]time.Duration{
+			time.Monday:    8 * time.Hour,
+			time.Tuesday:   8 * time.Hour,
+			time.Wednesday: 8 * time.Hour,
+			time.Thursday:  8 * time.Hour,
+			time.Friday:    8 * time.Hour,
+		},
+	}})
+	budgetPlanService.addPlan(budget_plan.BudgetPlan{
+		Id:    1,
+		Items: []budget_plan.BudgetItem{{Id: 1, PlanId: 1, Name: "Work", WeeklyDuration: 40 * time.Hour}},
+	})
+
+	// when
+	stats, err := statsService.GetWeeklyStats(ctx, startTime, false)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 7, len(stats.PerDay))
+	for i, day := range stats.PerDay[:5] {
+		assert.Equal(t, 8*time.Hour, findBudgetByName(day.StatsPerPlanItem, "Work").DailyTarget, "day %d", i)
+	}
+	assert.Equal(t, time.Duration(0), findBudgetByName(stats.PerDay[5].StatsPerPlanItem, "Work").DailyTarget)
+	assert.Equal(t, time.Duration(0), findBudgetByName(stats.PerDay[6].StatsPerPlanItem, "Work").DailyTarget)
+	assert.Equal(t, time.Duration(0), findBudgetByName(stats.PerPlanItem, "Work").DailyTarget)
+	assert.Len(t, stats.PerPlanItem[0].PlanItem.DailyDurations, 5)
+}
+
+func TestStatsServiceImpl_GetStats_WithCrossMidnightEvent(t *testing.T) {
+	statsService, ctx, teardown := setup(t)
+	defer teardown()
+
+	// given
+	currentUser, _ := user.CurrentUser(ctx)
+	currentUser.Settings.KeepCrossMidnightEvents = true
+	ctx = user.WithUser(ctx, currentUser)
+	startTime := time.Date(2023, time.March, 6, 0, 0, 0, 0, location) // Monday
+	weeklyPlanService.setItems([]weekly_plan.WeeklyPlanItem{
+		{BudgetPlanId: 1, Id: 101, BudgetItemId: 1, Name: "Sleep", WeeklyDuration: 56 * time.Hour},
+	})
+	budgetPlanService.addPlan(budget_plan.BudgetPlan{
+		Id:    1,
+		Items: []budget_plan.BudgetItem{{Id: 1, PlanId: 1, Name: "Sleep", WeeklyDuration: 56 * time.Hour}},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{ // 22:00 - 06:00 kept as a single event
+		Summary:   "Sleep",
+		StartTime: startTime.Add(22 * time.Hour),
+		EndTime:   startTime.Add(30 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1},
+	})
+
+	// when
+	stats, err := statsService.GetWeeklyStats(ctx, startTime, false)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Hour, findBudgetByName(stats.PerDay[0].StatsPerPlanItem, "Sleep").Duration)
+	assert.Equal(t, 6*time.Hour, findBudgetByName(stats.PerDay[1].StatsPerPlanItem, "Sleep").Duration)
+	assert.Equal(t, 8*time.Hour, stats.TotalTime)
+}
+
+func TestStatsServiceImpl_GetStats_WithDayStartHour(t *testing.T) {
+	statsService, ctx, teardown := setup(t)
+	defer teardown()
+
+	// given
+	currentUser, _ := user.CurrentUser(ctx)
+	currentUser.Settings.KeepCrossMidnightEvents = true
+	currentUser.Settings.DayStartHour = 4
+	ctx = user.WithUser(ctx, currentUser)
+	startTime := time.Date(2023, time.March, 6, 0, 0, 0, 0, location) // Monday
+	weeklyPlanService.setItems([]weekly_plan.WeeklyPlanItem{
+		{BudgetPlanId: 1, Id: 101, BudgetItemId: 1, Name: "Reading", WeeklyDuration: 10 * time.Hour},
+	})
+	budgetPlanService.addPlan(budget_plan.BudgetPlan{
+		Id:    1,
+		Items: []budget_plan.BudgetItem{{Id: 1, PlanId: 1, Name: "Reading", WeeklyDuration: 10 * time.Hour}},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{ // Monday 23:00 - Tuesday 05:00, the day ends at 04:00
+		Summary:   "Reading",
+		StartTime: startTime.Add(23 * time.Hour),
+		EndTime:   startTime.Add(29 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1},
+	})
+
+	// when
+	stats, err := statsService.GetWeeklyStats(ctx, startTime, false)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Hour, findBudgetByName(stats.PerDay[0].StatsPerPlanItem, "Reading").Duration)
+	assert.Equal(t, time.Hour, findBudgetByName(stats.PerDay[1].StatsPerPlanItem, "Reading").Duration)
+	assert.Equal(t, 6*time.Hour, stats.TotalTime)
+}
+
+func TestStatsServiceImpl_GetStats_WithDayStartHourAtWeekBoundary(t *testing.T) {
+	statsService, ctx, teardown := setup(t)
+	defer teardown()
+
+	// given
+	currentUser, _ := user.CurrentUser(ctx)
+	currentUser.Settings.DayStartHour = 4
+	ctx = user.WithUser(ctx, currentUser)
+	startTime := time.Date(2023, time.March, 6, 0, 0, 0, 0, location) // Monday
+	weeklyPlanService.setItems([]weekly_plan.WeeklyPlanItem{
+		{BudgetPlanId: 1, Id: 101, BudgetItemId: 1, Name: "Reading", WeeklyDuration: 10 * time.Hour},
+	})
+	budgetPlanService.addPlan(budget_plan.BudgetPlan{
+		Id:    1,
+		Items: []budget_plan.BudgetItem{{Id: 1, PlanId: 1, Name: "Reading", WeeklyDuration: 10 * time.Hour}},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{ // Monday 02:00, the previous week's Sunday
+		Summary:   "Reading",
+		StartTime: startTime.Add(2 * time.Hour),
+		EndTime:   startTime.Add(3 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{ // next Monday 02:00, this week's Sunday
+		Summary:   "Reading",
+		StartTime: startTime.AddDate(0, 0, 7).Add(2 * time.Hour),
+		EndTime:   startTime.AddDate(0, 0, 7).Add(4 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1},
+	})
+
+	// when
+	stats, err := statsService.GetWeeklyStats(ctx, startTime, false)
+
+	// then
+	assert.NoError(t, err)
+	assert.Len(t, stats.PerDay, 7)
+	assert.Equal(t, time.Duration(0), stats.PerDay[0].TotalTime)
+	assert.Equal(t, 2*time.Hour, stats.PerDay[6].TotalTime)
+	assert.Equal(t, 2*time.Hour, findBudgetByName(stats.PerPlanItem, "Reading").Duration)
+	assert.Equal(t, 2*time.Hour, stats.TotalTime)
+}
+
+func TestStatsServiceImpl_GetStats_Sessions(t *testing.T) {
+	statsService, ctx, teardown := setup(t)
+	defer teardown()
+
+	// given
+	startTime := time.Date(2023, time.March, 6, 0, 0, 0, 0, location) // Monday
+	weeklyPlanService.setItems([]weekly_plan.WeeklyPlanItem{
+		{BudgetPlanId: 1, Id: 101, BudgetItemId: 1, Name: "Gym", WeeklyOccurrences: 3},
+	})
+	budgetPlanService.addPlan(budget_plan.BudgetPlan{
+		Id: 1,
+		Items: []budget_plan.BudgetItem{
+			{Id: 1, PlanId: 1, Name: "Gym", WeeklyOccurrences: 3, Unit: budget_plan.UnitSessions},
+		},
+	})
+	addEvent := func(start, end time.Duration) {
+		calendarStub.AddEvent(ctx, calendar.Event{
+			Summary:   "Gym",
+			StartTime: startTime.Add(start),
+			EndTime:   startTime.Add(end),
+			Metadata:  calendar.EventMetadata{BudgetItemId: 1},
+		})
+	}
+	addEvent(18*time.Hour, 19*time.Hour) // Monday
+	addEvent(23*time.Hour, 24*time.Hour) // Monday, split at midnight
+	addEvent(24*time.Hour, 25*time.Hour) // Tuesday, continues the Monday session
+	addEvent(66*time.Hour, 67*time.Hour) // Wednesday
+
+	// when
+	stats, err := statsService.GetWeeklyStats(ctx, startTime, false)
+
+	// then
+	require.NoError(t, err)
+	gym := findBudgetByName(stats.PerPlanItem, "Gym")
+	assert.Equal(t, budget_plan.UnitSessions, gym.PlanItem.Unit)
+	assert.Equal(t, 3, gym.Sessions)
+	assert.InDelta(t, 100.0, gym.Percentage(), 0.001)
+	assert.Equal(t, 2, findBudgetByName(stats.PerDay[0].StatsPerPlanItem, "Gym").Sessions)
+	assert.Equal(t, 0, findBudgetByName(stats.PerDay[1].StatsPerPlanItem, "Gym").Sessions)
+	assert.Equal(t, 1, findBudgetByName(stats.PerDay[2].StatsPerPlanItem, "Gym").Sessions)
+}
+
+func TestSessionStarts_SkipsEventsEndingBeforeTheyStart(t *testing.T) {
+	// given
+	start := time.Date(2023, time.March, 6, 18, 0, 0, 0, location)
+	negative := calendar.Event{UID: "negative", StartTime: start, EndTime: start.Add(-time.Hour),
+		Metadata: calendar.EventMetadata{BudgetItemId: 1}}
+	valid := calendar.Event{UID: "valid", StartTime: start.Add(2 * time.Hour), EndTime: start.Add(3 * time.Hour),
+		Metadata: calendar.EventMetadata{BudgetItemId: 1}}
+
+	// when
+	starts := sessionStarts([]calendar.Event{negative, valid})
+
+	// then
+	require.Len(t, starts, 1)
+	assert.Equal(t, "valid", starts[0].UID)
+	assert.Equal(t, time.Duration(0), duration(negative))
+}
+
+func TestStatsServiceImpl_GetStats_LinkedEventParts(t *testing.T) {
+	statsService, ctx, teardown := setup(t)
+	defer teardown()
+
+	// given
+	startTime := time.Date(2023, time.March, 6, 0, 0, 0, 0, location) // Monday
+	weeklyPlanService.setItems([]weekly_plan.WeeklyPlanItem{
+		{BudgetPlanId: 1, Id: 101, BudgetItemId: 1, Name: "Night shift", WeeklyOccurrences: 2},
+	})
+	budgetPlanService.addPlan(budget_plan.BudgetPlan{
+		Id: 1,
+		Items: []budget_plan.BudgetItem{
+			{Id: 1, PlanId: 1, Name: "Night shift", WeeklyOccurrences: 2, Unit: budget_plan.UnitSessions},
+		},
+	})
+	firstParts, err := calendarStub.AddEvent(ctx, calendar.Event{ // Monday, shortened after it was split
+		Summary:   "Night shift",
+		StartTime: startTime.Add(22 * time.Hour),
+		EndTime:   startTime.Add(23*time.Hour + 30*time.Minute),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1},
+	})
+	require.NoError(t, err)
+	_, err = calendarStub.AddEvent(ctx, calendar.Event{ // Tuesday, the part after midnight
+		ParentUID: firstParts[0].UID,
+		Summary:   "Night shift",
+		StartTime: startTime.Add(24 * time.Hour),
+		EndTime:   startTime.Add(30 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1},
+	})
+	require.NoError(t, err)
+
+	// when
+	stats, err := statsService.GetWeeklyStats(ctx, startTime, false)
+	focus, focusErr := statsService.GetWeeklyFocus(ctx, startTime, false)
+
+	// then
+	require.NoError(t, err)
+	require.NoError(t, focusErr)
+	assert.Equal(t, 1, findBudgetByName(stats.PerPlanItem, "Night shift").Sessions)
+	assert.Equal(t, 1, focus.Total.Blocks)
+	assert.Equal(t, 7*time.Hour+30*time.Minute, focus.Total.LongestBlock, "the gap between the parts is not tracked")
+}
+
+func TestStatsServiceImpl_GetStats_AdHocItem(t *testing.T) {
+	statsService, ctx, teardown := setup(t)
+	defer teardown()
+
+	// given
+	startTime := time.Date(2023, time.April, 3, 0, 0, 0, 0, location) // Monday
+	weeklyPlanService.setItems([]weekly_plan.WeeklyPlanItem{
+		{BudgetPlanId: 1, Id: 101, BudgetItemId: 1, Name: "Reading", WeeklyDuration: 5 * time.Hour},
+		{BudgetPlanId: 1, Id: 102, BudgetItemId: -1, Name: "Tax return", WeeklyDuration: 3 * time.Hour},
+	})
+	budgetPlanService.addPlan(budget_plan.BudgetPlan{
+		Id:    1,
+		Items: []budget_plan.BudgetItem{{Id: 1, PlanId: 1, Name: "Reading", WeeklyDuration: 5 * time.Hour}},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{
+		Summary:   "Tax return",
+		StartTime: startTime.Add(8 * time.Hour),
+		EndTime:   startTime.Add(10 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: -1},
+	})
+
+	// when
+	stats, err := statsService.GetWeeklyStats(ctx, startTime, false)
+
+	// then
+	require.NoError(t, err)
+	taxReturn := findBudgetByName(stats.PerPlanItem, "Tax return")
+	assert.True(t, taxReturn.PlanItem.AdHoc)
+	assert.Equal(t, -1, taxReturn.PlanItem.BudgetItemId)
+	assert.Equal(t, 2*time.Hour, taxReturn.Duration)
+	assert.Equal(t, time.Hour, taxReturn.Remaining)
+	assert.Equal(t, 8*time.Hour, stats.TotalPlanned)
+}
+
+func TestStatsServiceImpl_GetStats_SandboxEvents(t *testing.T) {
+	statsService, ctx, teardown := setup(t)
+	defer teardown()
+
+	// given
+	startTime := time.Date(2023, time.April, 3, 0, 0, 0, 0, location) // Monday
+	weeklyPlanService.setItems([]weekly_plan.WeeklyPlanItem{
+		{BudgetPlanId: 1, Id: 101, BudgetItemId: 1, Name: "Reading", WeeklyDuration: 5 * time.Hour},
+	})
+	budgetPlanService.addPlan(budget_plan.BudgetPlan{
+		Id:    1,
+		Items: []budget_plan.BudgetItem{{Id: 1, PlanId: 1, Name: "Reading", WeeklyDuration: 5 * time.Hour}},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{
+		Summary:   "Reading",
+		StartTime: startTime.Add(8 * time.Hour),
+		EndTime:   startTime.Add(9 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{
+		Summary:   "Reading",
+		StartTime: startTime.Add(10 * time.Hour),
+		EndTime:   startTime.Add(12 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1, Sandbox: true},
+	})
+
+	// when
+	stats, err := statsService.GetWeeklyStats(ctx, startTime, false)
+	statsWithSandbox, errWithSandbox := statsService.GetWeeklyStats(ctx, startTime, true)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 1*time.Hour, stats.TotalTime)
+	assert.Equal(t, 1*time.Hour, findBudgetByName(stats.PerPlanItem, "Reading").Duration)
+	assert.NoError(t, errWithSandbox)
+	assert.Equal(t, 3*time.Hour, statsWithSandbox.TotalTime)
+	assert.Equal(t, 3*time.Hour, findBudgetByName(statsWithSandbox.PerPlanItem, "Reading").Duration)
+}
+
+func TestStatsServiceImpl_GetStats_PerCategory(t *testing.T) {
+	statsService, ctx, teardown := setup(t)
+	defer teardown()
+
+	// given
+	startTime := time.Date(2023, time.May, 1, 0, 0, 0, 0, location) // Monday
+	weeklyPlanService.setItems([]weekly_plan.WeeklyPlanItem{
+		{BudgetPlanId: 1, Id: 101, BudgetItemId: 1, Name: "Coding", WeeklyDuration: 10 * time.Hour},
+		{BudgetPlanId: 1, Id: 102, BudgetItemId: 2, Name: "Meetings", WeeklyDuration: 2 * time.Hour},
+		{BudgetPlanId: 1, Id: 103, BudgetItemId: 3, Name: "Reading", WeeklyDuration: 3 * time.Hour},
+	})
+	budgetPlanService.addPlan(budget_plan.BudgetPlan{
+		Id:         1,
+		Categories: []budget_plan.Category{{Id: 7, PlanId: 1, Name: "Work", Color: "#0000FF"}},
+		Items: []budget_plan.BudgetItem{
+			{Id: 1, PlanId: 1, Name: "Coding", WeeklyDuration: 10 * time.Hour, CategoryId: 7},
+			{Id: 2, PlanId: 1, Name: "Meetings", WeeklyDuration: 2 * time.Hour, CategoryId: 7},
+			{Id: 3, PlanId: 1, Name: "Reading", WeeklyDuration: 3 * time.Hour},
+		},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{
+		Summary:   "Coding",
+		StartTime: startTime.Add(8 * time.Hour),
+		EndTime:   startTime.Add(12 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{
+		Summary:   "Meetings",
+		StartTime: startTime.Add(13 * time.Hour),
+		EndTime:   startTime.Add(14 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 2},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{
+		Summary:   "Reading",
+		StartTime: startTime.Add(20 * time.Hour),
+		EndTime:   startTime.Add(21 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 3},
+	})
+
+	// when
+	stats, err := statsService.GetWeeklyStats(ctx, startTime, false)
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, stats.PerCategory, 2)
+	assert.Equal(t, CategoryStats{
+		CategoryId: 7,
+		Name:       "Work",
+		Color:      "#0000FF",
+		Planned:    12 * time.Hour,
+		Duration:   5 * time.Hour,
+		Remaining:  7 * time.Hour,
+	}, stats.PerCategory[0])
+	assert.Equal(t, 0, stats.PerCategory[1].CategoryId)
+	assert.Equal(t, 3*time.Hour, stats.PerCategory[1].Planned)
+	assert.Equal(t, 1*time.Hour, stats.PerCategory[1].Duration)
+	assert.Equal(t, 2*time.Hour, stats.PerCategory[1].Remaining)
+}
+
+func TestStatsServiceImpl_GetStats_PerLocation(t *testing.T) {
+	statsService, ctx, teardown := setup(t)
+	defer teardown()
+
+	// given
+	startTime := time.Date(2023, time.March, 6, 0, 0, 0, 0, location) // Monday
+	weeklyPlanService.setItems([]weekly_plan.WeeklyPlanItem{
+		{BudgetPlanId: 1, Id: 101, BudgetItemId: 1, Name: "Coding", WeeklyDuration: 10 * time.Hour},
+	})
+	budgetPlanService.addPlan(budget_plan.BudgetPlan{
+		Id:    1,
+		Items: []budget_plan.BudgetItem{{Id: 1, PlanId: 1, Name: "Coding", WeeklyDuration: 10 * time.Hour}},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{
+		Summary:   "Coding",
+		StartTime: startTime.Add(8 * time.Hour),
+		EndTime:   startTime.Add(10 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1, Location: "home"},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{
+		Summary:   "Coding",
+		StartTime: startTime.Add(32 * time.Hour),
+		EndTime:   startTime.Add(34 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1, Location: "Office"},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{
+		Summary:   "Coding",
+		StartTime: startTime.Add(40 * time.Hour),
+		EndTime:   startTime.Add(41 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1, Location: " office "},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{
+		Summary:   "Coding",
+		StartTime: startTime.Add(56 * time.Hour),
+		EndTime:   startTime.Add(57 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1},
+	})
+
+	// when
+	stats, err := statsService.GetWeeklyStats(ctx, startTime, false)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, []LocationStats{
+		{Location: "Office", Duration: 3 * time.Hour},
+		{Location: "home", Duration: 2 * time.Hour},
+		{Location: "", Duration: 1 * time.Hour},
+	}, stats.PerLocation)
+}
+
+func TestStatsServiceImpl_GetStats_PerLocation_NoLocations(t *testing.T) {
+	statsService, ctx, teardown := setup(t)
+	defer teardown()
+
+	// given
+	startTime := time.Date(2023, time.March, 6, 0, 0, 0, 0, location) // Monday
+	weeklyPlanService.setItems([]weekly_plan.WeeklyPlanItem{
+		{BudgetPlanId: 1, Id: 101, BudgetItemId: 1, Name: "Coding", WeeklyDuration: 10 * time.Hour},
+	})
+	budgetPlanService.addPlan(budget_plan.BudgetPlan{
+		Id:    1,
+		Items: []budget_plan.BudgetItem{{Id: 1, PlanId: 1, Name: "Coding", WeeklyDuration: 10 * time.Hour}},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{
+		Summary:   "Coding",
+		StartTime: startTime.Add(8 * time.Hour),
+		EndTime:   startTime.Add(10 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1},
+	})
+
+	// when
+	stats, err := statsService.GetWeeklyStats(ctx, startTime, false)
+
+	// then
+	require.NoError(t, err)
+	assert.Nil(t, stats.PerLocation)
+}
+
+func TestStatsServiceImpl_GetStats_SubItems(t *testing.T) {
+	statsService, ctx, teardown := setup(t)
+	defer teardown()
+
+	// given
+	startTime := time.Date(2023, time.June, 5, 0, 0, 0, 0, location) // Monday
+	weeklyPlanService.setItems([]weekly_plan.WeeklyPlanItem{
+		{BudgetPlanId: 1, Id: 101, BudgetItemId: 1, Name: "Work", WeeklyDuration: 12 * time.Hour},
+		{BudgetPlanId: 1, Id: 102, BudgetItemId: 2, Name: "Coding", WeeklyDuration: 10 * time.Hour},
+		{BudgetPlanId: 1, Id: 103, BudgetItemId: 3, Name: "Meetings", WeeklyDuration: 2 * time.Hour},
+	})
+	budgetPlanService.addPlan(budget_plan.BudgetPlan{
+		Id: 1,
+		Items: []budget_plan.BudgetItem{
+			{Id: 1, PlanId: 1, Name: "Work", WeeklyDuration: 12 * time.Hour},
+			{Id: 2, PlanId: 1, Name: "Coding", WeeklyDuration: 10 * time.Hour, ParentId: 1},
+			{Id: 3, PlanId: 1, Name: "Meetings", WeeklyDuration: 2 * time.Hour, ParentId: 1},
+		},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{
+		Summary:   "Coding",
+		StartTime: startTime.Add(8 * time.Hour),
+		EndTime:   startTime.Add(12 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 2},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{
+		Summary:   "Meetings",
+		StartTime: startTime.Add(13 * time.Hour),
+		EndTime:   startTime.Add(14 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 3},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{
+		Summary:   "Work",
+		StartTime: startTime.Add(15 * time.Hour),
+		EndTime:   startTime.Add(16 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1},
+	})
+
+	// when
+	stats, err := statsService.GetWeeklyStats(ctx, startTime, false)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, 12*time.Hour, stats.TotalPlanned)
+	assert.Equal(t, 6*time.Hour, stats.TotalTime)
+	work := findBudgetByName(stats.PerPlanItem, "Work")
+	assert.Equal(t, 6*time.Hour, work.Duration)
+	assert.Equal(t, 6*time.Hour, work.Remaining)
+	coding := findBudgetByName(stats.PerPlanItem, "Coding")
+	assert.Equal(t, 1, coding.PlanItem.ParentBudgetItemId)
+	assert.Equal(t, 4*time.Hour, coding.Duration)
+	monday := stats.PerDay[0]
+	assert.Equal(t, 6*time.Hour, monday.TotalTime)
+	assert.Equal(t, 6*time.Hour, findBudgetByName(monday.StatsPerPlanItem, "Work").Duration)
+}
+
 func findBudgetByName(budgets []PlanItemStats, budgetName string) *PlanItemStats {
 	for _, b := range budgets {
 		if b.PlanItem.Name == budgetName {
@@ -220,6 +746,253 @@ func findBudgetByName(budgets []PlanItemStats, budgetName string) *PlanItemStats
 	return nil
 }
 
+func TestStatsServiceImpl_GetWeeklyStats_AbsentDays(t *testing.T) {
+	statsService, ctx, teardown := setup(t)
+	defer teardown()
+
+	// given
+	weeklyPlanService.setItems([]weekly_plan.WeeklyPlanItem{
+		{BudgetPlanId: 1, Id: 101, BudgetItemId: 1, Name: "Work", WeeklyDuration: 24 * time.Hour},
+	})
+	budgetPlanService.addPlan(budget_plan.BudgetPlan{Id: 1, Items: []budget_plan.BudgetItem{{Id: 1, PlanId: 1}}})
+	absenceStub.days = []time.Time{
+		time.Date(2023, time.January, 11, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, time.January, 12, 0, 0, 0, 0, time.UTC),
+		// The next week is not counted
+		time.Date(2023, time.January, 16, 0, 0, 0, 0, time.UTC),
+	}
+
+	// when
+	stats, err := statsService.GetWeeklyStats(ctx, time.Date(2023, time.January, 9, 0, 0, 0, 0, location), false)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.AbsentDays)
+	var absent []int
+	for _, day := range stats.PerDay {
+		if day.Absent {
+			absent = append(absent, day.Date.Day())
+		}
+	}
+	assert.Equal(t, []int{11, 12}, absent)
+}
+
+func TestStatsServiceImpl_GetWeeklyCapacity(t *testing.T) {
+	t.Run("compares planned time with awake time", func(t *testing.T) {
+		statsService, ctx, teardown := setup(t)
+		defer teardown()
+
+		// given
+		weeklyPlanService.setItems([]weekly_plan.WeeklyPlanItem{
+			{BudgetPlanId: 1, Id: 101, BudgetItemId: 1, WeeklyDuration: 40 * time.Hour},
+			{BudgetPlanId: 1, Id: 102, BudgetItemId: 2, WeeklyDuration: 10 * time.Hour},
+			{BudgetPlanId: 1, Id: 103, BudgetItemId: 3, WeeklyDuration: 6 * time.Hour},
+		})
+		budgetPlanService.addPlan(budget_plan.BudgetPlan{
+			Id: 1,
+			Items: []budget_plan.BudgetItem{
+				{Id: 1, PlanId: 1},
+				{Id: 2, PlanId: 1},
+				{Id: 3, PlanId: 1, ParentId: 2},
+			},
+		})
+
+		// when
+		capacity, err := statsService.GetWeeklyCapacity(ctx, time.Date(2023, time.January, 4, 12, 0, 0, 0, location))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2023, time.January, 2, 0, 0, 0, 0, location), capacity.StartDate)
+		assert.Equal(t, 46*time.Hour, capacity.Planned, "parent items hold the sum of their sub-items")
+		assert.Equal(t, 7*16*time.Hour, capacity.Available)
+		assert.Equal(t, time.Duration(0), capacity.Busy)
+		assert.Equal(t, 66*time.Hour, capacity.Slack)
+	})
+
+	t.Run("counts the real length of a DST change week", func(t *testing.T) {
+		statsService, ctx, teardown := setup(t)
+		defer teardown()
+
+		// when
+		capacity, err := statsService.GetWeeklyCapacity(ctx, time.Date(2023, time.March, 26, 12, 0, 0, 0, location))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 7*16*time.Hour-time.Hour, capacity.Available)
+		assert.Equal(t, capacity.Available, capacity.Slack)
+	})
+
+	t.Run("excludes absent days from the awake time", func(t *testing.T) {
+		statsService, ctx, teardown := setup(t)
+		defer teardown()
+
+		// given
+		absenceStub.days = []time.Time{
+			time.Date(2023, time.January, 5, 0, 0, 0, 0, time.UTC),
+			time.Date(2023, time.January, 6, 0, 0, 0, 0, time.UTC),
+		}
+
+		// when
+		capacity, err := statsService.GetWeeklyCapacity(ctx, time.Date(2023, time.January, 4, 12, 0, 0, 0, location))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 5*16*time.Hour, capacity.Available)
+	})
+
+	t.Run("uses the user's daily sleep", func(t *testing.T) {
+		statsService, ctx, teardown := setup(t)
+		defer teardown()
+
+		// given
+		currentUser, _ := user.CurrentUser(ctx)
+		currentUser.Settings.DailySleep = 6 * time.Hour
+		ctx = user.WithUser(ctx, currentUser)
+
+		// when
+		capacity, err := statsService.GetWeeklyCapacity(ctx, time.Date(2023, time.January, 4, 12, 0, 0, 0, location))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 7*18*time.Hour, capacity.Available)
+	})
+
+	t.Run("returns negative slack for an overbooked week", func(t *testing.T) {
+		statsService, ctx, teardown := setup(t)
+		defer teardown()
+
+		// given
+		weeklyPlanService.setItems([]weekly_plan.WeeklyPlanItem{
+			{BudgetPlanId: 1, Id: 101, BudgetItemId: 1, WeeklyDuration: 120 * time.Hour},
+		})
+		budgetPlanService.addPlan(budget_plan.BudgetPlan{Id: 1, Items: []budget_plan.BudgetItem{{Id: 1, PlanId: 1}}})
+
+		// when
+		capacity, err := statsService.GetWeeklyCapacity(ctx, time.Date(2023, time.January, 4, 12, 0, 0, 0, location))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, -8*time.Hour, capacity.Slack)
+	})
+}
+
+func TestStatsServiceImpl_CompareWithBaseline(t *testing.T) {
+	givenWeeks := func(t *testing.T) (StatsService, context.Context, WeeklyStatsSummary, func()) {
+		statsService, ctx, teardown := setup(t)
+		weeklyPlanService.setItems([]weekly_plan.WeeklyPlanItem{
+			{Id: 101, BudgetPlanId: 1, BudgetItemId: 1, Name: "BudgetItem 1", WeeklyDuration: 5 * time.Hour},
+			{Id: 102, BudgetPlanId: 1, BudgetItemId: 2, Name: "BudgetItem 2", WeeklyDuration: 2 * time.Hour, Position: 1},
+		})
+		budgetPlanService.addPlan(budget_plan.BudgetPlan{
+			Id: 1,
+			Items: []budget_plan.BudgetItem{
+				{Id: 1, PlanId: 1, Name: "BudgetItem 1", WeeklyDuration: 5 * time.Hour},
+				{Id: 2, PlanId: 1, Name: "BudgetItem 2", WeeklyDuration: 2 * time.Hour},
+			},
+		})
+		trackWeek := func(weekStart time.Time, item1 time.Duration, item2 time.Duration) {
+			addCalendarEvents(ctx, 1, weekStart, weekStart.AddDate(0, 0, 1), 1, item1)
+			if item2 > 0 {
+				addCalendarEvents(ctx, 1, weekStart.AddDate(0, 0, 1), weekStart.AddDate(0, 0, 2), 2, item2)
+			}
+		}
+		trackWeek(time.Date(2025, 9, 8, 0, 0, 0, 0, location), 8*time.Hour, 0)            // 5h of the plan completed
+		trackWeek(time.Date(2025, 9, 15, 0, 0, 0, 0, location), 4*time.Hour, 2*time.Hour) // 6h of the plan completed
+		trackWeek(time.Date(2025, 9, 22, 0, 0, 0, 0, location), 3*time.Hour, time.Hour)   // 4h of the plan completed
+		trackWeek(time.Date(2025, 9, 29, 0, 0, 0, 0, location), 5*time.Hour, time.Hour)
+
+		weekStats, err := statsService.GetWeeklyStats(ctx, time.Date(2025, 10, 1, 12, 0, 0, 0, location), false)
+		require.NoError(t, err)
+		return statsService, ctx, weekStats, teardown
+	}
+
+	t.Run("should compare with the best of the previous weeks", func(t *testing.T) {
+		statsService, ctx, weekStats, teardown := givenWeeks(t)
+		defer teardown()
+
+		comparison, err := statsService.CompareWithBaseline(ctx, weekStats, Baseline{Kind: BaselineBestWeek, Weeks: 3}, false)
+
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2025, 9, 15, 0, 0, 0, 0, location), comparison.StartDate)
+		assert.Equal(t, 1, comparison.Weeks)
+		assert.Equal(t, 6*time.Hour, comparison.TotalTime)
+		assert.Equal(t, 6*time.Hour, comparison.BaselineTotalTime)
+		assert.Equal(t, time.Duration(0), comparison.Difference)
+		require.Len(t, comparison.PerPlanItem, 2)
+		assert.Equal(t, 4*time.Hour, comparison.PerPlanItem[0].BaselineDuration)
+		assert.Equal(t, time.Hour, comparison.PerPlanItem[0].Difference)
+		assert.Equal(t, 2*time.Hour, comparison.PerPlanItem[1].BaselineDuration)
+		assert.Equal(t, -time.Hour, comparison.PerPlanItem[1].Difference)
+	})
+
+	t.Run("should compare with the average of the previous weeks", func(t *testing.T) {
+		statsService, ctx, weekStats, teardown := givenWeeks(t)
+		defer teardown()
+
+		comparison, err := statsService.CompareWithBaseline(ctx, weekStats, Baseline{Kind: BaselineAverage, Weeks: 3}, false)
+
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2025, 9, 8, 0, 0, 0, 0, location), comparison.StartDate)
+		assert.Equal(t, time.Date(2025, 9, 28, 23, 59, 59, 999999999, location), comparison.EndDate)
+		assert.Equal(t, 3, comparison.Weeks)
+		assert.Equal(t, 6*time.Hour, comparison.BaselineTotalTime)
+		require.Len(t, comparison.PerPlanItem, 2)
+		assert.Equal(t, 5*time.Hour, comparison.PerPlanItem[0].BaselineDuration)
+		assert.Equal(t, time.Duration(0), comparison.PerPlanItem[0].Difference)
+		assert.Equal(t, time.Hour, comparison.PerPlanItem[1].BaselineDuration)
+	})
+
+	t.Run("should compare with the week of the given date", func(t *testing.T) {
+		statsService, ctx, weekStats, teardown := givenWeeks(t)
+		defer teardown()
+
+		baseline := Baseline{Kind: BaselineWeek, Date: time.Date(2025, 9, 24, 12, 0, 0, 0, location)}
+		comparison, err := statsService.CompareWithBaseline(ctx, weekStats, baseline, false)
+
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2025, 9, 22, 0, 0, 0, 0, location), comparison.StartDate)
+		assert.Equal(t, 4*time.Hour, comparison.BaselineTotalTime)
+		assert.Equal(t, 2*time.Hour, comparison.Difference)
+		assert.Equal(t, 2*time.Hour, comparison.PerPlanItem[0].Difference)
+	})
+
+	t.Run("should leave weeks without tracked time out of the average", func(t *testing.T) {
+		statsService, ctx, weekStats, teardown := givenWeeks(t)
+		defer teardown()
+
+		// 4 weeks back nothing was tracked, but the week still has the items of the plan
+		comparison, err := statsService.CompareWithBaseline(ctx, weekStats, Baseline{Kind: BaselineAverage, Weeks: 6}, false)
+
+		require.NoError(t, err)
+		assert.Equal(t, 3, comparison.Weeks)
+		assert.Equal(t, 6*time.Hour, comparison.BaselineTotalTime)
+	})
+
+	t.Run("should report no baseline when nothing was tracked in the baseline week", func(t *testing.T) {
+		statsService, ctx, weekStats, teardown := givenWeeks(t)
+		defer teardown()
+
+		baseline := Baseline{Kind: BaselineWeek, Date: time.Date(2025, 9, 3, 12, 0, 0, 0, location)}
+		_, err := statsService.CompareWithBaseline(ctx, weekStats, baseline, false)
+
+		assert.ErrorIs(t, err, ErrNoBaselineFound)
+	})
+
+	t.Run("should reject invalid baselines", func(t *testing.T) {
+		statsService, ctx, weekStats, teardown := givenWeeks(t)
+		defer teardown()
+
+		for _, baseline := range []Baseline{
+			{Kind: "worst"},
+			{Kind: BaselineWeek},
+			{Kind: BaselineAverage, Weeks: MaxBaselineWeeks + 1},
+		} {
+			_, err := statsService.CompareWithBaseline(ctx, weekStats, baseline, false)
+			assert.ErrorIs(t, err, ErrInvalidBaseline, baseline)
+		}
+	})
+}
+
 func Test_weekTimeRange(t *testing.T) {
 	type args struct {
 		date         time.Time
@@ -398,3 +1171,191 @@ func addCalendarEvents(ctx context.Context, numberOfEvents int, startTime time.T
 		}
 	}
 }
+
+func TestStatsServiceImpl_GetTrend(t *testing.T) {
+	statsService, ctx, teardown := setup(t)
+	defer teardown()
+	originalNow := clock.Now()
+	defer clock.SetNow(originalNow)
+
+	// given
+	clock.SetNow(time.Date(2023, time.March, 15, 10, 0, 0, 0, location)) // Wednesday
+	currentWeek := time.Date(2023, time.March, 13, 0, 0, 0, 0, location)
+	previousWeek := time.Date(2023, time.March, 6, 0, 0, 0, 0, location)
+	statsRepo.planned[previousWeek.Unix()] = 2 * time.Hour
+	statsRepo.tracked[previousWeek.Unix()] = 90 * time.Minute
+	statsRepo.tracked[currentWeek.Unix()] = 30 * time.Minute
+
+	// when
+	trend, err := statsService.GetTrend(ctx, 1, 3)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, 1, trend.BudgetItemId)
+	require.Len(t, trend.Weeks, 3)
+	assert.True(t, time.Date(2023, time.February, 27, 0, 0, 0, 0, location).Equal(trend.Weeks[0].StartDate))
+	assert.True(t, previousWeek.Equal(trend.Weeks[0].EndDate))
+	assert.Equal(t, weekly_plan.WeekNumber{Year: 2023, Week: 11}, trend.Weeks[2].Week)
+	assert.Equal(t, 2*time.Hour, trend.Weeks[1].Planned)
+	assert.Equal(t, 90*time.Minute, trend.Weeks[1].Tracked)
+	assert.Equal(t, time.Duration(0), trend.Weeks[2].Planned)
+	assert.Equal(t, 30*time.Minute, trend.Weeks[2].Tracked)
+
+	_, err = statsService.GetTrend(ctx, 1, MaxTrendWeeks+1)
+	assert.ErrorIs(t, err, ErrInvalidTrendWeeks)
+}
+
+func TestStatsServiceImpl_GetDailyStats(t *testing.T) {
+	statsService, ctx, teardown := setup(t)
+	defer teardown()
+
+	// given
+	tuesday := time.Date(2023, time.February, 7, 0, 0, 0, 0, location)
+	weeklyPlanService.setItems([]weekly_plan.WeeklyPlanItem{{
+		BudgetPlanId:   1,
+		Id:             101,
+		BudgetItemId:   1,
+		Name:           "Work",
+		WeeklyDuration: 40 * time.Hour,
+		DailyDurations: map[time.Weekday]time.Duration{time.Tuesday: 8 * time.Hour},
+	}})
+	budgetPlanService.addPlan(budget_plan.BudgetPlan{
+		Id:    1,
+		Items: []budget_plan.BudgetItem{{Id: 1, PlanId: 1, Name: "Work", WeeklyDuration: 40 * time.Hour}},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{ // Monday, 23:00 - 23:30 in the user's timezone
+		Summary:   "Work",
+		StartTime: tuesday.Add(-time.Hour),
+		EndTime:   tuesday.Add(-30 * time.Minute),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1},
+	})
+	calendarStub.AddEvent(ctx, calendar.Event{ // Tuesday
+		Summary:   "Work",
+		StartTime: tuesday.Add(9 * time.Hour),
+		EndTime:   tuesday.Add(11 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1},
+	})
+
+	// when
+	// 23:30 UTC on Monday is already Tuesday in Warsaw
+	stats, err := statsService.GetDailyStats(ctx, tuesday.Add(30*time.Minute).UTC(), false)
+
+	// then
+	require.NoError(t, err)
+	assert.True(t, tuesday.Equal(stats.Date))
+	assert.Equal(t, 2*time.Hour, stats.TotalTime)
+	work := findBudgetByName(stats.StatsPerPlanItem, "Work")
+	assert.Equal(t, 2*time.Hour, work.Duration)
+	assert.Equal(t, 8*time.Hour, work.DailyTarget)
+}
+
+func TestStatsServiceImpl_GetWeeklyFocus(t *testing.T) {
+	statsService, ctx, teardown := setup(t)
+	defer teardown()
+
+	// given
+	monday := time.Date(2023, time.March, 13, 0, 0, 0, 0, location)
+	addEvent := func(budgetItemId int, start, end time.Duration) {
+		calendarStub.AddEvent(ctx, calendar.Event{
+			Summary:   "Event",
+			StartTime: monday.Add(start),
+			EndTime:   monday.Add(end),
+			Metadata:  calendar.EventMetadata{BudgetItemId: budgetItemId},
+		})
+	}
+	addEvent(1, 9*time.Hour, 10*time.Hour)                 // Monday, deep work
+	addEvent(1, 10*time.Hour, 10*time.Hour+30*time.Minute) // continues the block
+	addEvent(2, 11*time.Hour, 11*time.Hour+15*time.Minute) // switch
+	addEvent(1, 12*time.Hour, 12*time.Hour+15*time.Minute) // switch
+	addEvent(1, 12*time.Hour+30*time.Minute, 13*time.Hour) // same item after a break, not a switch
+	addEvent(3, 23*time.Hour, 24*time.Hour)                // Monday, split at midnight
+	addEvent(3, 24*time.Hour, 25*time.Hour)                // Tuesday, continues the Monday block
+
+	// when
+	focus, err := statsService.GetWeeklyFocus(ctx, monday.Add(50*time.Hour), false)
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, focus.PerDay, 7)
+	assert.True(t, monday.Equal(focus.StartDate))
+	mondayFocus := focus.PerDay[0]
+	assert.Equal(t, 5, mondayFocus.Blocks)
+	assert.Equal(t, 3, mondayFocus.Switches)
+	assert.Equal(t, 4*time.Hour+30*time.Minute, mondayFocus.TotalTime)
+	assert.Equal(t, 2*time.Hour, mondayFocus.LongestBlock)
+	assert.Equal(t, 3*time.Hour+30*time.Minute, mondayFocus.DeepWorkTime)
+	assert.Equal(t, FocusStats{Date: monday.AddDate(0, 0, 1)}, focus.PerDay[1])
+	assert.Equal(t, 5, focus.Total.Blocks)
+	assert.Equal(t, 3, focus.Total.Switches)
+	assert.Equal(t, 54*time.Minute, focus.Total.AverageBlock())
+	assert.InDelta(t, 77.778, focus.Total.Score(), 0.001)
+}
+
+func Test_focusBlocks_PartsWithGapTakeOnlyTheirTime(t *testing.T) {
+	// given
+	start := time.Date(2023, time.March, 13, 22, 0, 0, 0, location)
+	firstPart := calendar.Event{ // shortened by a sticky edit, so it ends before the next part starts
+		UID:       "first",
+		StartTime: start,
+		EndTime:   start.Add(time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1},
+	}
+	secondPart := calendar.Event{
+		UID:       "second",
+		ParentUID: "first",
+		StartTime: start.Add(3 * time.Hour),
+		EndTime:   start.Add(4 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1},
+	}
+
+	// when
+	blocks := focusBlocks([]calendar.Event{secondPart, firstPart})
+
+	// then
+	require.Len(t, blocks, 1)
+	assert.Equal(t, 2*time.Hour, blocks[0].duration)
+	assert.True(t, start.Equal(blocks[0].start))
+}
+
+func TestStatsServiceImpl_GetWeeklyFocus_WithDayStartHour(t *testing.T) {
+	statsService, ctx, teardown := setup(t)
+	defer teardown()
+
+	// given
+	currentUser, _ := user.CurrentUser(ctx)
+	currentUser.Settings.DayStartHour = 4
+	ctx = user.WithUser(ctx, currentUser)
+	monday := time.Date(2023, time.March, 13, 0, 0, 0, 0, location)
+	calendarStub.AddEvent(ctx, calendar.Event{ // Tuesday 02:00 - 03:00, before the day starts at 04:00
+		Summary:   "Event",
+		StartTime: monday.Add(26 * time.Hour),
+		EndTime:   monday.Add(27 * time.Hour),
+		Metadata:  calendar.EventMetadata{BudgetItemId: 1},
+	})
+
+	// when
+	focus, err := statsService.GetWeeklyFocus(ctx, monday, false)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, 1, focus.PerDay[0].Blocks)
+	assert.Equal(t, time.Hour, focus.PerDay[0].TotalTime)
+	assert.Zero(t, focus.PerDay[1].Blocks)
+}
+
+func TestStatsServiceImpl_GetWeeklyFocus_NothingTracked(t *testing.T) {
+	statsService, ctx, teardown := setup(t)
+	defer teardown()
+
+	// given
+	monday := time.Date(2023, time.March, 13, 0, 0, 0, 0, location)
+
+	// when
+	focus, err := statsService.GetWeeklyFocus(ctx, monday, false)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, 0, focus.Total.Blocks)
+	assert.Zero(t, focus.Total.AverageBlock())
+	assert.Zero(t, focus.Total.Score())
+}
@@ -102,3 +102,53 @@ func (s *budgetPlanReaderStub) GetItem(ctx context.Context, budgetItemId int) (b
 	}
 	return budget_plan.BudgetItem{}, errors.New("budget item not found")
 }
+
+type repositoryStub struct {
+	// tracked and planned are keyed by the week start Unix time
+	tracked map[int64]time.Duration
+	planned map[int64]time.Duration
+}
+
+func newRepositoryStub() *repositoryStub {
+	return &repositoryStub{
+		tracked: make(map[int64]time.Duration),
+		planned: make(map[int64]time.Duration),
+	}
+}
+
+func (r *repositoryStub) GetWeeklyTrend(ctx context.Context, userId int, budgetItemId int, weeks []TrendWeek) ([]TrendWeek, error) {
+	result := make([]TrendWeek, 0, len(weeks))
+	for _, week := range weeks {
+		week.Planned = r.planned[week.StartDate.Unix()]
+		week.Tracked = r.tracked[week.StartDate.Unix()]
+		result = append(result, week)
+	}
+	return result, nil
+}
+
+func (r *repositoryStub) reset() {
+	r.tracked = make(map[int64]time.Duration)
+	r.planned = make(map[int64]time.Duration)
+}
+
+type absenceReaderStub struct {
+	days []time.Time
+}
+
+func newAbsenceReaderStub() *absenceReaderStub {
+	return &absenceReaderStub{}
+}
+
+func (s *absenceReaderStub) AbsentDays(ctx context.Context, from time.Time, to time.Time) ([]time.Time, error) {
+	var days []time.Time
+	for _, day := range s.days {
+		if !day.Before(civilDate(from)) && !day.After(civilDate(to)) {
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+func (s *absenceReaderStub) reset() {
+	s.days = nil
+}
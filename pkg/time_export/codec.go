@@ -0,0 +1,161 @@
+package time_export
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func encodeReport(w io.Writer, format Format, report Report) error {
+	switch format {
+	case FormatCSV:
+		return encodeCSV(w, report)
+	case FormatXLSX:
+		return encodeXLSX(w, report)
+	}
+	return fmt.Errorf("unsupported format: %s", format)
+}
+
+func encodeCSV(w io.Writer, report Report) error {
+	writer := csv.NewWriter(w)
+	header := make([]string, 0, len(report.Columns))
+	for _, column := range report.Columns {
+		header = append(header, string(column))
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, row := range report.Rows {
+		record := make([]string, len(row))
+		for i, value := range row {
+			record[i] = value
+			if !numericColumns[report.Columns[i]] {
+				record[i] = escapeFormula(value)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// escapeFormula prefixes values a spreadsheet would run as a formula with a single quote, so a summary or note
+// like "=HYPERLINK(...)" is shown as text when the CSV is opened.
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// The parts of a minimal XLSX (Office Open XML) workbook with a single sheet. The sheet itself is written
+// row by row, with inline strings, so no shared strings table is needed.
+var xlsxParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Tracked time" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+func encodeXLSX(w io.Writer, report Report) error {
+	archive := zip.NewWriter(w)
+	for _, part := range xlsxParts {
+		partWriter, err := archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(partWriter, part.content); err != nil {
+			return err
+		}
+	}
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	if err := writeSheet(sheet, report); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+func writeSheet(w io.Writer, report Report) error {
+	if _, err := io.WriteString(w, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return err
+	}
+	header := make([]string, 0, len(report.Columns))
+	for _, column := range report.Columns {
+		header = append(header, string(column))
+	}
+	if err := writeSheetRow(w, 1, header, nil); err != nil {
+		return err
+	}
+	for i, row := range report.Rows {
+		if err := writeSheetRow(w, i+2, row, report.Columns); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, `</sheetData></worksheet>`)
+	return err
+}
+
+// writeSheetRow writes the values as inline strings, or as numbers for numeric columns.
+func writeSheetRow(w io.Writer, rowNumber int, values []string, columns []Column) error {
+	if _, err := fmt.Fprintf(w, `<row r="%d">`, rowNumber); err != nil {
+		return err
+	}
+	for i, value := range values {
+		ref := cellReference(i, rowNumber)
+		if columns != nil && numericColumns[columns[i]] {
+			if _, err := strconv.ParseFloat(value, 64); err == nil {
+				if _, err := fmt.Fprintf(w, `<c r="%s"><v>%s</v></c>`, ref, value); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if _, err := fmt.Fprintf(w, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref); err != nil {
+			return err
+		}
+		if err := xml.EscapeText(w, []byte(value)); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, `</t></is></c>`); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, `</row>`)
+	return err
+}
+
+// cellReference returns the A1 style reference of the cell, e.g. "AB12".
+func cellReference(column int, row int) string {
+	name := ""
+	for column++; column > 0; column = (column - 1) / 26 {
+		name = string(rune('A'+(column-1)%26)) + name
+	}
+	return name + strconv.Itoa(row)
+}
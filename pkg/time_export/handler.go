@@ -0,0 +1,99 @@
+package time_export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/klokku/klokku/internal/rest"
+	log "github.com/sirupsen/logrus"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// ExportTime godoc
+// @Summary Export tracked time
+// @Description Export the calendar events of a period, or the time tracked per day and budget item, as CSV or XLSX.
+// @Description Dates and times are in the user's timezone, durations in the H:MM format and hours as decimal numbers.
+// @Description Durations and hours are rounded according to the user's rounding settings, an event split at midnight
+// @Description as a whole. Start and end times are not rounded.
+// @Description Columns of the events mode: date, start, end, duration, hours, budgetItem, summary, notes, taskId.
+// @Description Columns of the daily mode: date, budgetItem, duration, hours.
+// @Tags Export
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param from query string true "Start of the period in RFC3339 format"
+// @Param to query string true "End of the period in RFC3339 format, at most 366 days after from"
+// @Param format query string false "File format" Enums(csv, xlsx) default(csv)
+// @Param mode query string false "Row per event or per day and budget item" Enums(events, daily) default(events)
+// @Param columns query string false "Comma separated columns, in the order of the file"
+// @Success 200 {string} string "Exported time"
+// @Failure 400 {object} rest.ErrorResponse
+// @Failure 403 {string} string "User not found"
+// @Router /api/export/time [get]
+// @Security XUserId
+func (h *Handler) ExportTime(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, err := time.Parse(time.RFC3339, query.Get("from"))
+	if err != nil {
+		writeBadRequest(w, "Invalid from (date) format", "'from' must be in RFC3339 format")
+		return
+	}
+	to, err := time.Parse(time.RFC3339, query.Get("to"))
+	if err != nil {
+		writeBadRequest(w, "Invalid to (date) format", "'to' must be in RFC3339 format")
+		return
+	}
+	format := FormatCSV
+	if value := query.Get("format"); value != "" {
+		format = Format(value)
+		if !format.IsValid() {
+			writeBadRequest(w, "Invalid format", "format must be one of: csv, xlsx")
+			return
+		}
+	}
+	request := Request{From: from, To: to, Mode: ModeEvents}
+	if value := query.Get("mode"); value != "" {
+		request.Mode = Mode(value)
+	}
+	if value := query.Get("columns"); value != "" {
+		for _, column := range strings.Split(value, ",") {
+			request.Columns = append(request.Columns, Column(strings.TrimSpace(column)))
+		}
+	}
+
+	report, err := h.service.BuildReport(r.Context(), request)
+	if err != nil {
+		if errors.Is(err, ErrInvalidRange) || errors.Is(err, ErrInvalidMode) || errors.Is(err, ErrInvalidColumn) {
+			writeBadRequest(w, "Invalid export request", err.Error())
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"klokku-%s-%s.%s\"",
+		from.Format(time.DateOnly), to.Format(time.DateOnly), format))
+	if err := encodeReport(w, format, report); err != nil {
+		log.Errorf("failed to export tracked time: %v", err)
+	}
+}
+
+func writeBadRequest(w http.ResponseWriter, errMsg, details string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+		Error:   errMsg,
+		Details: details,
+	})
+}
@@ -0,0 +1,265 @@
+package time_export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+)
+
+var ErrInvalidRange = fmt.Errorf("from must be before to and the range cannot be longer than %d days", int(MaxRange.Hours()/24))
+var ErrInvalidMode = errors.New("mode must be one of: events, daily")
+var ErrInvalidColumn = errors.New("invalid column")
+
+type Service interface {
+	// BuildReport returns the tracked time of the period as a table. Sandbox events are not exported.
+	BuildReport(ctx context.Context, request Request) (Report, error)
+}
+
+type calendarReader interface {
+	GetEventsIncludingArchive(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error)
+}
+
+type weeklyPlanReader interface {
+	GetPlansForRange(ctx context.Context, from time.Time, to time.Time) ([]weekly_plan.WeeklyPlan, error)
+}
+
+type ServiceImpl struct {
+	calendar   calendarReader
+	weeklyPlan weeklyPlanReader
+}
+
+func NewService(calendar calendarReader, weeklyPlan weeklyPlanReader) Service {
+	return &ServiceImpl{calendar: calendar, weeklyPlan: weeklyPlan}
+}
+
+func (s *ServiceImpl) BuildReport(ctx context.Context, request Request) (Report, error) {
+	if !request.From.Before(request.To) || request.To.Sub(request.From) > MaxRange {
+		return Report{}, ErrInvalidRange
+	}
+	columns, err := resolveColumns(request.Mode, request.Columns)
+	if err != nil {
+		return Report{}, err
+	}
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	dayBoundary, err := utils.NewDayBoundaryForTimezone(currentUser.Settings.Timezone)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	dayBoundary = dayBoundary.WithStartHour(currentUser.Settings.DayStartHour)
+	formatter := user.NewFormatter(currentUser.Settings)
+	rounding := currentUser.Settings.Rounding
+
+	events, err := s.calendar.GetEventsIncludingArchive(ctx, request.From, request.To)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to get events: %w", err)
+	}
+	events = calendar.WithoutSandbox(events)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].StartTime.Before(events[j].StartTime)
+	})
+	itemNames, err := s.budgetItemNames(ctx, request.From, request.To, currentUser.Settings.WeekFirstDay)
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{Columns: columns, Rows: make([][]string, 0, len(events))}
+	switch request.Mode {
+	case ModeEvents:
+		logicalUids := make([]string, 0, len(events))
+		durations := make([]time.Duration, 0, len(events))
+		for _, event := range events {
+			logicalUids = append(logicalUids, event.LogicalUID())
+			durations = append(durations, event.EndTime.Sub(event.StartTime))
+		}
+		durations = roundedDurations(rounding, logicalUids, durations)
+		for i, event := range events {
+			report.Rows = append(report.Rows, eventRow(columns, event, durations[i], dayBoundary, formatter, itemNames,
+				currentUser.Settings.WeekFirstDay))
+		}
+	case ModeDaily:
+		report.Rows = dailyRows(columns, events, dayBoundary, formatter, rounding, itemNames, currentUser.Settings.WeekFirstDay)
+	}
+	return report, nil
+}
+
+func resolveColumns(mode Mode, requested []Column) ([]Column, error) {
+	available, ok := Columns[mode]
+	if !ok {
+		return nil, ErrInvalidMode
+	}
+	if len(requested) == 0 {
+		return DefaultColumns[mode], nil
+	}
+	for _, column := range requested {
+		found := false
+		for _, availableColumn := range available {
+			found = found || column == availableColumn
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s is not available in the %s mode", ErrInvalidColumn, column, mode)
+		}
+	}
+	return requested, nil
+}
+
+type itemKey struct {
+	week         weekly_plan.WeekNumber
+	budgetItemId int
+}
+
+// budgetItemNames returns the names of the budget items as they were in the weeks of the period.
+func (s *ServiceImpl) budgetItemNames(ctx context.Context, from time.Time, to time.Time, weekFirstDay time.Weekday) (map[itemKey]string, error) {
+	plans, err := s.weeklyPlan.GetPlansForRange(ctx, from, to)
+	if err != nil {
+		if errors.Is(err, weekly_plan.ErrNoCurrentPlan) {
+			return map[itemKey]string{}, nil
+		}
+		return nil, fmt.Errorf("failed to get weekly plans: %w", err)
+	}
+	names := make(map[itemKey]string)
+	for _, plan := range plans {
+		for _, item := range plan.Items {
+			names[itemKey{plan.WeekNumber, item.BudgetItemId}] = item.Name
+		}
+	}
+	return names, nil
+}
+
+func itemName(event calendar.Event, itemNames map[itemKey]string, weekFirstDay time.Weekday) string {
+	week := weekly_plan.WeekNumberFromDate(event.StartTime, weekFirstDay)
+	if name, ok := itemNames[itemKey{week, event.Metadata.BudgetItemId}]; ok {
+		return name
+	}
+	// The item is no longer in the week's plan, the summary is the best name left
+	return event.Summary
+}
+
+// roundedDurations rounds the durations according to the user's settings, the durations of the parts of the same
+// logical event, e.g. of an event split at midnight, as a whole. logicalUids[i] is the logical event of durations[i].
+func roundedDurations(rounding user.Rounding, logicalUids []string, durations []time.Duration) []time.Duration {
+	partsByEvent := make(map[string][]int)
+	order := make([]string, 0)
+	for i, logicalUid := range logicalUids {
+		if _, ok := partsByEvent[logicalUid]; !ok {
+			order = append(order, logicalUid)
+		}
+		partsByEvent[logicalUid] = append(partsByEvent[logicalUid], i)
+	}
+	rounded := make([]time.Duration, len(durations))
+	for _, logicalUid := range order {
+		parts := partsByEvent[logicalUid]
+		partDurations := make([]time.Duration, 0, len(parts))
+		for _, i := range parts {
+			partDurations = append(partDurations, durations[i])
+		}
+		for j, duration := range rounding.ApplyToParts(partDurations) {
+			rounded[parts[j]] = duration
+		}
+	}
+	return rounded
+}
+
+// eventRow writes the event as a row. The date is the date of the user's day the event starts in, which can differ
+// from the calendar date when the user's day does not start at midnight. The duration is the rounded one, the start
+// and the end are not rounded.
+func eventRow(columns []Column, event calendar.Event, duration time.Duration, dayBoundary utils.DayBoundary,
+	formatter user.Formatter, itemNames map[itemKey]string, weekFirstDay time.Weekday) []string {
+	location := dayBoundary.Location()
+	row := make([]string, 0, len(columns))
+	for _, column := range columns {
+		var value string
+		switch column {
+		case ColumnDate:
+			value = dayBoundary.StartOfDay(event.StartTime).Format(time.DateOnly)
+		case ColumnStart:
+			value = event.StartTime.In(location).Format("15:04")
+		case ColumnEnd:
+			value = event.EndTime.In(location).Format("15:04")
+		case ColumnDuration:
+			value = formatter.Duration(duration)
+		case ColumnHours:
+			value = formatHours(duration)
+		case ColumnBudgetItem:
+			value = itemName(event, itemNames, weekFirstDay)
+		case ColumnSummary:
+			value = event.Summary
+		case ColumnNotes:
+			value = event.Metadata.Notes
+		case ColumnTaskId:
+			value = event.Metadata.TaskId
+		}
+		row = append(row, value)
+	}
+	return row
+}
+
+// dailyRows sums up the events per day and budget item. Events crossing the start of a day are split between the days.
+// The parts of an event are rounded as a whole according to the user's settings before they are summed up, the
+// difference made by rounding goes to the last day.
+func dailyRows(columns []Column, events []calendar.Event, dayBoundary utils.DayBoundary, formatter user.Formatter,
+	rounding user.Rounding, itemNames map[itemKey]string, weekFirstDay time.Weekday) [][]string {
+	type dayItem struct {
+		day  time.Time
+		name string
+	}
+	var keys []dayItem
+	var logicalUids []string
+	var partDurations []time.Duration
+	for _, event := range events {
+		name := itemName(event, itemNames, weekFirstDay)
+		for _, part := range dayBoundary.Split(event.StartTime, event.EndTime) {
+			keys = append(keys, dayItem{dayBoundary.StartOfDay(part.Start), name})
+			logicalUids = append(logicalUids, event.LogicalUID())
+			partDurations = append(partDurations, part.End.Sub(part.Start))
+		}
+	}
+	durations := make(map[dayItem]time.Duration)
+	order := make([]dayItem, 0)
+	for i, duration := range roundedDurations(rounding, logicalUids, partDurations) {
+		if _, ok := durations[keys[i]]; !ok {
+			order = append(order, keys[i])
+		}
+		durations[keys[i]] += duration
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return order[i].day.Before(order[j].day)
+	})
+
+	rows := make([][]string, 0, len(order))
+	for _, key := range order {
+		row := make([]string, 0, len(columns))
+		for _, column := range columns {
+			var value string
+			switch column {
+			case ColumnDate:
+				value = key.day.Format(time.DateOnly)
+			case ColumnBudgetItem:
+				value = key.name
+			case ColumnDuration:
+				value = formatter.Duration(durations[key])
+			case ColumnHours:
+				value = formatHours(durations[key])
+			}
+			row = append(row, value)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// formatHours formats the duration as decimal hours with a dot, regardless of the user's locale, so the column stays
+// machine-readable and is written as a number into XLSX files.
+func formatHours(d time.Duration) string {
+	return strconv.FormatFloat(d.Round(time.Second).Hours(), 'f', 2, 64)
+}
@@ -0,0 +1,227 @@
+package time_export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var location, _ = time.LoadLocation("Europe/Warsaw")
+
+type weeklyPlanReaderStub struct {
+	plans []weekly_plan.WeeklyPlan
+}
+
+func (s *weeklyPlanReaderStub) GetPlansForRange(_ context.Context, _ time.Time, _ time.Time) ([]weekly_plan.WeeklyPlan, error) {
+	return s.plans, nil
+}
+
+func setup(t *testing.T) (Service, context.Context, time.Time) {
+	t.Helper()
+	monday := time.Date(2025, 3, 10, 0, 0, 0, 0, location)
+	ctx := user.WithUser(context.Background(), user.User{
+		Id:       1,
+		Settings: user.Settings{Timezone: "Europe/Warsaw", WeekFirstDay: time.Monday},
+	})
+	cal := calendar.NewStubCalendar()
+	events := []calendar.Event{
+		{Summary: "Old name", StartTime: monday.Add(9 * time.Hour), EndTime: monday.Add(10*time.Hour + 30*time.Minute),
+			Metadata: calendar.EventMetadata{BudgetItemId: 1, Notes: "standup, \"planning\""}},
+		{Summary: "Gym", StartTime: monday.Add(23 * time.Hour), EndTime: monday.Add(25 * time.Hour),
+			Metadata: calendar.EventMetadata{BudgetItemId: 2}},
+		{Summary: "Work", StartTime: monday.Add(11 * time.Hour), EndTime: monday.Add(12 * time.Hour),
+			Metadata: calendar.EventMetadata{BudgetItemId: 1, TaskId: "abc"}},
+		{Summary: "Sandbox", StartTime: monday.Add(14 * time.Hour), EndTime: monday.Add(15 * time.Hour),
+			Metadata: calendar.EventMetadata{BudgetItemId: 1, Sandbox: true}},
+	}
+	for _, event := range events {
+		_, err := cal.AddEvent(ctx, event)
+		require.NoError(t, err)
+	}
+	weeklyPlan := &weeklyPlanReaderStub{plans: []weekly_plan.WeeklyPlan{{
+		WeekNumber: weekly_plan.WeekNumberFromDate(monday, time.Monday),
+		Items: []weekly_plan.WeeklyPlanItem{
+			{BudgetItemId: 1, Name: "Work"},
+		},
+	}}}
+	return NewService(cal, weeklyPlan), ctx, monday
+}
+
+func TestServiceImpl_BuildReport_Events(t *testing.T) {
+	service, ctx, monday := setup(t)
+
+	report, err := service.BuildReport(ctx, Request{
+		From:    monday,
+		To:      monday.AddDate(0, 0, 7),
+		Mode:    ModeEvents,
+		Columns: []Column{ColumnDate, ColumnStart, ColumnHours, ColumnBudgetItem, ColumnNotes, ColumnTaskId},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"2025-03-10", "09:00", "1.50", "Work", "standup, \"planning\"", ""},
+		{"2025-03-10", "11:00", "1.00", "Work", "", "abc"},
+		// the item is not in the week's plan, the summary is used; the event was split at midnight when stored
+		{"2025-03-10", "23:00", "1.00", "Gym", "", ""},
+		{"2025-03-11", "00:00", "1.00", "Gym", "", ""},
+	}, report.Rows)
+}
+
+func TestServiceImpl_BuildReport_Daily(t *testing.T) {
+	service, ctx, monday := setup(t)
+
+	report, err := service.BuildReport(ctx, Request{From: monday, To: monday.AddDate(0, 0, 7), Mode: ModeDaily})
+
+	require.NoError(t, err)
+	assert.Equal(t, DefaultColumns[ModeDaily], report.Columns)
+	assert.Equal(t, [][]string{
+		{"2025-03-10", "Work", "2:30"},
+		{"2025-03-10", "Gym", "1:00"},
+		{"2025-03-11", "Gym", "1:00"},
+	}, report.Rows)
+}
+
+func TestServiceImpl_BuildReport_UserFormatting(t *testing.T) {
+	service, _, monday := setup(t)
+	ctx := user.WithUser(context.Background(), user.User{
+		Id: 1,
+		Settings: user.Settings{Timezone: "Europe/Warsaw", WeekFirstDay: time.Monday, Locale: "pl-PL",
+			DurationFormat: user.DurationDecimalHours, DayStartHour: 4},
+	})
+
+	report, err := service.BuildReport(ctx, Request{From: monday, To: monday.AddDate(0, 0, 7), Mode: ModeDaily,
+		Columns: []Column{ColumnDate, ColumnBudgetItem, ColumnDuration, ColumnHours}})
+
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"2025-03-10", "Work", "2,50", "2.50"},
+		// the night before 4:00 counts into Monday
+		{"2025-03-10", "Gym", "2,00", "2.00"},
+	}, report.Rows)
+}
+
+func TestServiceImpl_BuildReport_Rounding(t *testing.T) {
+	service, _, monday := setup(t)
+	ctx := user.WithUser(context.Background(), user.User{
+		Id: 1,
+		Settings: user.Settings{Timezone: "Europe/Warsaw", WeekFirstDay: time.Monday,
+			Rounding: user.Rounding{Increment: time.Hour, Mode: user.RoundUp}},
+	})
+
+	events, err := service.BuildReport(ctx, Request{From: monday, To: monday.AddDate(0, 0, 7), Mode: ModeEvents,
+		Columns: []Column{ColumnStart, ColumnEnd, ColumnDuration, ColumnBudgetItem}})
+	require.NoError(t, err)
+	daily, err := service.BuildReport(ctx, Request{From: monday, To: monday.AddDate(0, 0, 7), Mode: ModeDaily})
+	require.NoError(t, err)
+
+	// the start and the end are the stored times, only the duration is rounded
+	assert.Equal(t, []string{"09:00", "10:30", "2:00", "Work"}, events.Rows[0])
+	assert.Equal(t, []string{"2025-03-10", "Work", "3:00"}, daily.Rows[0])
+}
+
+type eventsReaderStub []calendar.Event
+
+func (s eventsReaderStub) GetEventsIncludingArchive(_ context.Context, _ time.Time, _ time.Time) ([]calendar.Event, error) {
+	return s, nil
+}
+
+func TestServiceImpl_BuildReport_RoundsPartsOfEventAsWhole(t *testing.T) {
+	monday := time.Date(2025, 3, 10, 0, 0, 0, 0, location)
+	ctx := user.WithUser(context.Background(), user.User{
+		Id: 1,
+		Settings: user.Settings{Timezone: "Europe/Warsaw", WeekFirstDay: time.Monday,
+			Rounding: user.Rounding{Increment: 15 * time.Minute, Mode: user.RoundUp}},
+	})
+	tuesday := monday.AddDate(0, 0, 1)
+	service := NewService(eventsReaderStub{ // 20 minutes split at midnight
+		{UID: "first", Summary: "Work", StartTime: tuesday.Add(-10 * time.Minute), EndTime: tuesday.Add(-time.Nanosecond),
+			Metadata: calendar.EventMetadata{BudgetItemId: 1}},
+		{UID: "second", ParentUID: "first", Summary: "Work", StartTime: tuesday, EndTime: tuesday.Add(10 * time.Minute),
+			Metadata: calendar.EventMetadata{BudgetItemId: 1}},
+	}, &weeklyPlanReaderStub{})
+
+	events, err := service.BuildReport(ctx, Request{From: monday, To: monday.AddDate(0, 0, 7), Mode: ModeEvents,
+		Columns: []Column{ColumnDate, ColumnDuration}})
+	require.NoError(t, err)
+	daily, err := service.BuildReport(ctx, Request{From: monday, To: monday.AddDate(0, 0, 7), Mode: ModeDaily})
+	require.NoError(t, err)
+
+	// the whole event is rounded up to 30 minutes once, not each of its parts
+	assert.Equal(t, [][]string{{"2025-03-10", "0:10"}, {"2025-03-11", "0:20"}}, events.Rows)
+	assert.Equal(t, [][]string{{"2025-03-10", "Work", "0:10"}, {"2025-03-11", "Work", "0:20"}}, daily.Rows)
+}
+
+func TestServiceImpl_BuildReport_Validation(t *testing.T) {
+	service, ctx, monday := setup(t)
+
+	_, err := service.BuildReport(ctx, Request{From: monday, To: monday.AddDate(2, 0, 0), Mode: ModeEvents})
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	_, err = service.BuildReport(ctx, Request{From: monday, To: monday.AddDate(0, 0, 7), Mode: "weekly"})
+	assert.ErrorIs(t, err, ErrInvalidMode)
+	_, err = service.BuildReport(ctx, Request{From: monday, To: monday.AddDate(0, 0, 7), Mode: ModeDaily,
+		Columns: []Column{ColumnSummary}})
+	assert.ErrorIs(t, err, ErrInvalidColumn)
+}
+
+func TestEncodeReport(t *testing.T) {
+	report := Report{
+		Columns: []Column{ColumnBudgetItem, ColumnHours},
+		Rows:    [][]string{{"R&D <core>", "1.50"}},
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, encodeReport(&buf, FormatCSV, report))
+		assert.Equal(t, "budgetItem,hours\nR&D <core>,1.50\n", buf.String())
+	})
+
+	t.Run("csv escapes formulas", func(t *testing.T) {
+		var buf bytes.Buffer
+		formulas := Report{
+			Columns: []Column{ColumnSummary, ColumnNotes, ColumnHours},
+			Rows: [][]string{
+				{"=HYPERLINK(\"http://x\")", "+1", "-0.50"},
+				{"@SUM(A1)", "-note", "1.00"},
+			},
+		}
+		require.NoError(t, encodeReport(&buf, FormatCSV, formulas))
+		assert.Equal(t, "summary,notes,hours\n\"'=HYPERLINK(\"\"http://x\"\")\",'+1,-0.50\n'@SUM(A1),'-note,1.00\n", buf.String())
+	})
+
+	t.Run("xlsx", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, encodeReport(&buf, FormatXLSX, report))
+		archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		var sheet string
+		for _, file := range archive.File {
+			if file.Name == "xl/worksheets/sheet1.xml" {
+				reader, err := file.Open()
+				require.NoError(t, err)
+				content, err := io.ReadAll(reader)
+				require.NoError(t, err)
+				sheet = string(content)
+			}
+		}
+		assert.Len(t, archive.File, 5)
+		assert.True(t, strings.Contains(sheet, `<c r="A2" t="inlineStr"><is><t xml:space="preserve">R&amp;D &lt;core&gt;</t></is></c>`))
+		assert.True(t, strings.Contains(sheet, `<c r="B2"><v>1.50</v></c>`))
+	})
+
+	t.Run("cell references", func(t *testing.T) {
+		assert.Equal(t, "A1", cellReference(0, 1))
+		assert.Equal(t, "Z3", cellReference(25, 3))
+		assert.Equal(t, "AA3", cellReference(26, 3))
+		assert.Equal(t, "AB10", cellReference(27, 10))
+	})
+}
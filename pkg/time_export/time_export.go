@@ -0,0 +1,81 @@
+package time_export
+
+import (
+	"time"
+)
+
+// MaxRange limits the period of a single export.
+const MaxRange = 366 * 24 * time.Hour
+
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+func (f Format) IsValid() bool {
+	return f == FormatCSV || f == FormatXLSX
+}
+
+func (f Format) ContentType() string {
+	if f == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv"
+}
+
+// Mode tells what a row of the export is.
+type Mode string
+
+const (
+	// ModeEvents exports a row per calendar event.
+	ModeEvents Mode = "events"
+	// ModeDaily exports a row per day and budget item with the time tracked on the day.
+	ModeDaily Mode = "daily"
+)
+
+type Column string
+
+const (
+	ColumnDate  Column = "date"
+	ColumnStart Column = "start"
+	ColumnEnd   Column = "end"
+	// ColumnDuration is written in the user's duration format and locale
+	ColumnDuration Column = "duration"
+	// ColumnHours is written as decimal hours with a dot, so it can be processed by other tools
+	ColumnHours      Column = "hours"
+	ColumnBudgetItem Column = "budgetItem"
+	ColumnSummary    Column = "summary"
+	ColumnNotes      Column = "notes"
+	ColumnTaskId     Column = "taskId"
+)
+
+// numeric columns are written as numbers to spreadsheets.
+var numericColumns = map[Column]bool{ColumnHours: true}
+
+// Columns lists the columns available in the mode, DefaultColumns the ones exported when none are requested.
+var (
+	Columns = map[Mode][]Column{
+		ModeEvents: {ColumnDate, ColumnStart, ColumnEnd, ColumnDuration, ColumnHours, ColumnBudgetItem, ColumnSummary,
+			ColumnNotes, ColumnTaskId},
+		ModeDaily: {ColumnDate, ColumnBudgetItem, ColumnDuration, ColumnHours},
+	}
+	DefaultColumns = map[Mode][]Column{
+		ModeEvents: {ColumnDate, ColumnStart, ColumnEnd, ColumnDuration, ColumnBudgetItem, ColumnSummary, ColumnNotes},
+		ModeDaily:  {ColumnDate, ColumnBudgetItem, ColumnDuration},
+	}
+)
+
+type Request struct {
+	From    time.Time
+	To      time.Time
+	Mode    Mode
+	Columns []Column
+}
+
+// Report is the exported table. Values are formatted in the user's timezone.
+type Report struct {
+	Columns []Column
+	Rows    [][]string
+}
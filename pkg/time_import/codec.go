@@ -0,0 +1,156 @@
+package time_import
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrInvalidFile = errors.New("invalid import file")
+
+// MaxEntries limits the number of entries of a single file.
+const MaxEntries = 10000
+
+// fileColumns tells where the values of an entry are in the CSV of a format. Columns are named in lowercase.
+type fileColumns struct {
+	// activity columns, the first non-empty one is the activity
+	activity []string
+	// description columns, the first non-empty one is the description
+	description []string
+	// start and end columns, joined with a space before parsing them with one of the layouts
+	start   []string
+	end     []string
+	layouts []string
+}
+
+var formatColumns = map[Format]fileColumns{
+	FormatToggl: {
+		activity:    []string{"project", "description"},
+		description: []string{"description"},
+		start:       []string{"start date", "start time"},
+		end:         []string{"end date", "end time"},
+		layouts:     []string{"2006-01-02 15:04:05", "2006-01-02 15:04"},
+	},
+	FormatClockify: {
+		activity:    []string{"project", "description"},
+		description: []string{"description"},
+		start:       []string{"start date", "start time"},
+		end:         []string{"end date", "end time"},
+		layouts: []string{"01/02/2006 03:04:05 PM", "01/02/2006 03:04 PM", "01/02/2006 15:04:05", "01/02/2006 15:04",
+			"2006-01-02 15:04:05", "2006-01-02 15:04"},
+	},
+	FormatATracker: {
+		activity:    []string{"task name"},
+		description: []string{"note", "task description"},
+		start:       []string{"start time"},
+		end:         []string{"end time"},
+		layouts: []string{"2006-01-02 15:04", "2006-01-02 15:04:05", "Jan 2, 2006 at 3:04 PM", "Jan 2, 2006 3:04 PM",
+			"Jan 2, 2006 at 15:04", "Jan 2, 2006 15:04"},
+	},
+}
+
+// decodeEntries reads the entries of the file, times are in the location. Errors of single rows are returned as
+// RowErrors, the error is returned only when the file cannot be read at all.
+func decodeEntries(r io.Reader, format Format, location *time.Location) ([]Entry, []RowError, error) {
+	if format == FormatGoogleTakeout {
+		return decodeCalendar(r, location)
+	}
+	fileColumns, ok := formatColumns[format]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported format: %s", format)
+	}
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("%w: missing header", ErrInvalidFile)
+	}
+	if len(records)-1 > MaxEntries {
+		return nil, nil, fmt.Errorf("%w: at most %d entries can be imported at once", ErrInvalidFile, MaxEntries)
+	}
+
+	columns := make(map[string]int)
+	for idx, column := range records[0] {
+		if idx == 0 {
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		columns[strings.ToLower(strings.TrimSpace(column))] = idx
+	}
+	for _, column := range slices.Concat(fileColumns.activity[:1], fileColumns.start, fileColumns.end) {
+		if _, ok := columns[column]; !ok {
+			return nil, nil, fmt.Errorf("%w: missing %q column, is it a %s export?", ErrInvalidFile, column, format)
+		}
+	}
+
+	var entries []Entry
+	var rowErrors []RowError
+	for idx, record := range records[1:] {
+		rowNumber := idx + 1
+		value := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		entry, err := parseEntry(fileColumns, value, location)
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Row: rowNumber, Message: err.Error()})
+			continue
+		}
+		entry.Row = rowNumber
+		entries = append(entries, entry)
+	}
+	return entries, rowErrors, nil
+}
+
+func parseEntry(fileColumns fileColumns, value func(column string) string, location *time.Location) (Entry, error) {
+	entry := Entry{
+		Activity:    firstValue(fileColumns.activity, value),
+		Description: firstValue(fileColumns.description, value),
+	}
+	if entry.Activity == "" {
+		return Entry{}, fmt.Errorf("missing %s", fileColumns.activity[0])
+	}
+	var err error
+	if entry.Start, err = parseTime(fileColumns.start, fileColumns.layouts, value, location); err != nil {
+		return Entry{}, err
+	}
+	if entry.End, err = parseTime(fileColumns.end, fileColumns.layouts, value, location); err != nil {
+		return Entry{}, err
+	}
+	if !entry.End.After(entry.Start) {
+		return Entry{}, errors.New("the end is not after the start")
+	}
+	return entry, nil
+}
+
+func firstValue(columns []string, value func(column string) string) string {
+	for _, column := range columns {
+		if v := value(column); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func parseTime(columns []string, layouts []string, value func(column string) string, location *time.Location) (time.Time, error) {
+	parts := make([]string, 0, len(columns))
+	for _, column := range columns {
+		parts = append(parts, value(column))
+	}
+	text := strings.Join(parts, " ")
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, text, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid %s %q", strings.Join(columns, " and "), text)
+}
@@ -0,0 +1,211 @@
+package time_import
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/klokku/klokku/internal/rest"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxRequestSize limits the size of a request with the imported file.
+const maxRequestSize = 5 << 20
+
+type FileDTO struct {
+	Format string `json:"format" enums:"toggl,clockify,atracker,google_takeout"`
+	// Content of the CSV file exported from the time tracker, or of the .ics file of a Google Takeout export
+	Content string `json:"content"`
+}
+
+type ImportRequestDTO struct {
+	Format   string       `json:"format" enums:"toggl,clockify,atracker,google_takeout"`
+	Content  string       `json:"content"`
+	Mappings []MappingDTO `json:"mappings"`
+}
+
+type MappingDTO struct {
+	Activity string `json:"activity"`
+	// BudgetItemId of 0 skips the entries of the activity
+	BudgetItemId int `json:"budgetItemId"`
+}
+
+type ActivityDTO struct {
+	Name    string `json:"name"`
+	Entries int    `json:"entries"`
+	// Duration of the entries in seconds
+	Duration int `json:"duration"`
+	// BudgetItemId is the suggested budget item, omitted when there is no suggestion
+	BudgetItemId int `json:"budgetItemId,omitempty"`
+}
+
+type RowErrorDTO struct {
+	// Row is the 1-based row number in the file (not counting the CSV header), or the number of the event of a Google
+	// Takeout calendar, 0 for errors of the whole file.
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+type PreviewDTO struct {
+	From       *time.Time    `json:"from,omitempty"`
+	To         *time.Time    `json:"to,omitempty"`
+	Activities []ActivityDTO `json:"activities"`
+	Errors     []RowErrorDTO `json:"errors"`
+}
+
+type ImportResultDTO struct {
+	Created  int           `json:"created"`
+	Skipped  int           `json:"skipped"`
+	Rejected []RowErrorDTO `json:"rejected"`
+	Errors   []RowErrorDTO `json:"errors"`
+}
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// PreviewImport godoc
+// @Summary Preview an import from another time tracker
+// @Description Read a CSV file exported from Toggl Track (detailed report), Clockify (detailed report) or ATracker,
+// @Description or an .ics file of the Calendar folder of a Google Takeout export, and list its activities - projects,
+// @Description tasks for ATracker or event summaries for Google Takeout - with a suggested budget item for each.
+// @Description All-day and cancelled calendar events are left out, recurring ones are read for their first occurrence.
+// @Description Times without a time zone are read in the user's timezone. Nothing is imported.
+// @Tags Import
+// @Accept json
+// @Produce json
+// @Param file body FileDTO true "Exported file"
+// @Success 200 {object} PreviewDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid file"
+// @Failure 403 {string} string "User not found"
+// @Router /api/import/time/preview [post]
+// @Security XUserId
+func (h *Handler) PreviewImport(w http.ResponseWriter, r *http.Request) {
+	var file FileDTO
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	if err := rest.DecodeJSON(r, &file); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	format, err := parseFormat(file.Format)
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+
+	preview, err := h.service.Preview(r.Context(), strings.NewReader(file.Content), format)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(previewToDTO(preview)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Import godoc
+// @Summary Import time from another time tracker
+// @Description Create calendar events of the entries of a file exported from Toggl Track, Clockify, ATracker or
+// @Description Google Takeout whose activity is mapped to a budget item. Entries of unmapped activities and entries
+// @Description imported before are skipped. The whole file is validated first and nothing is imported when any row is invalid.
+// @Tags Import
+// @Accept json
+// @Produce json
+// @Param request body ImportRequestDTO true "Exported file with the mappings of its activities"
+// @Success 200 {object} ImportResultDTO
+// @Failure 400 {object} ImportResultDTO "Invalid rows, nothing was imported"
+// @Failure 403 {string} string "User not found"
+// @Router /api/import/time [post]
+// @Security XUserId
+func (h *Handler) Import(w http.ResponseWriter, r *http.Request) {
+	var request ImportRequestDTO
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	if err := rest.DecodeJSON(r, &request); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	format, err := parseFormat(request.Format)
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	mappings := make([]Mapping, 0, len(request.Mappings))
+	for _, mapping := range request.Mappings {
+		mappings = append(mappings, Mapping{Activity: mapping.Activity, BudgetItemId: mapping.BudgetItemId})
+	}
+
+	result, err := h.service.Import(r.Context(), strings.NewReader(request.Content), format, mappings)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if result.HasErrors() {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if err := json.NewEncoder(w).Encode(importResultToDTO(result)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func parseFormat(value string) (Format, error) {
+	format := Format(value)
+	if !format.IsValid() {
+		return "", rest.InvalidField("format", "Invalid format", "'format' must be one of: toggl, clockify, atracker, google_takeout")
+	}
+	return format, nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidFile):
+		rest.WriteBadRequest(w, rest.InvalidField("content", "Invalid file", err.Error()))
+	case errors.Is(err, ErrInvalidMapping):
+		rest.WriteBadRequest(w, rest.InvalidField("mappings", "Invalid mapping", err.Error()))
+	default:
+		log.Errorf("Time import failed: %v", err)
+		http.Error(w, "Time import failed", http.StatusInternalServerError)
+	}
+}
+
+func rowErrorsToDTO(rowErrors []RowError) []RowErrorDTO {
+	result := make([]RowErrorDTO, 0, len(rowErrors))
+	for _, rowErr := range rowErrors {
+		result = append(result, RowErrorDTO{Row: rowErr.Row, Message: rowErr.Message})
+	}
+	return result
+}
+
+func previewToDTO(preview Preview) PreviewDTO {
+	activities := make([]ActivityDTO, 0, len(preview.Activities))
+	for _, activity := range preview.Activities {
+		activities = append(activities, ActivityDTO{
+			Name:         activity.Name,
+			Entries:      activity.Entries,
+			Duration:     int(activity.Duration.Seconds()),
+			BudgetItemId: activity.BudgetItemId,
+		})
+	}
+	dto := PreviewDTO{Activities: activities, Errors: rowErrorsToDTO(preview.Errors)}
+	if !preview.From.IsZero() {
+		dto.From = &preview.From
+		dto.To = &preview.To
+	}
+	return dto
+}
+
+func importResultToDTO(result ImportResult) ImportResultDTO {
+	return ImportResultDTO{
+		Created:  result.Created,
+		Skipped:  result.Skipped,
+		Rejected: rowErrorsToDTO(result.Rejected),
+		Errors:   rowErrorsToDTO(result.Errors),
+	}
+}
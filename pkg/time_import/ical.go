@@ -0,0 +1,196 @@
+package time_import
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// untitledActivity is the activity of the calendar events without a summary, named as Google Calendar shows them.
+const untitledActivity = "(No title)"
+
+// icalProperty is a content line of an iCalendar file, e.g. DTSTART;TZID=Europe/Warsaw:20250310T090000.
+type icalProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// decodeCalendar reads the events of an iCalendar file, e.g. one of the .ics files of the Calendar folder of a
+// Google Takeout export. The summary of an event is its activity. All-day and cancelled events are not time entries
+// and are left out, and only the first occurrence of a recurring event is read. Times without a time zone are in
+// the location. Row numbers count the events of the file.
+func decodeCalendar(r io.Reader, location *time.Location) ([]Entry, []RowError, error) {
+	lines, err := unfoldLines(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	if len(lines) == 0 || !strings.EqualFold(strings.TrimPrefix(lines[0], "\ufeff"), "BEGIN:VCALENDAR") {
+		return nil, nil, fmt.Errorf("%w: missing BEGIN:VCALENDAR, is it a %s export?", ErrInvalidFile, FormatGoogleTakeout)
+	}
+
+	var entries []Entry
+	var rowErrors []RowError
+	var components []string
+	var event []icalProperty
+	rowNumber := 0
+	for _, line := range lines {
+		property := parseProperty(line)
+		switch property.name {
+		case "BEGIN":
+			components = append(components, strings.ToUpper(property.value))
+			if strings.EqualFold(property.value, "VEVENT") {
+				event = nil
+			}
+			continue
+		case "END":
+			if len(components) > 0 {
+				components = components[:len(components)-1]
+			}
+			if !strings.EqualFold(property.value, "VEVENT") {
+				continue
+			}
+			rowNumber++
+			if rowNumber > MaxEntries {
+				return nil, nil, fmt.Errorf("%w: at most %d entries can be imported at once", ErrInvalidFile, MaxEntries)
+			}
+			entry, ok, err := parseEvent(event, location)
+			if err != nil {
+				rowErrors = append(rowErrors, RowError{Row: rowNumber, Message: err.Error()})
+				continue
+			}
+			if ok {
+				entry.Row = rowNumber
+				entries = append(entries, entry)
+			}
+			continue
+		}
+		// Properties of the components of an event, e.g. of its alarms, are not the event's
+		if len(components) > 0 && components[len(components)-1] == "VEVENT" {
+			event = append(event, property)
+		}
+	}
+	return entries, rowErrors, nil
+}
+
+// unfoldLines returns the content lines of the file, joining the lines folded by starting them with a space or a tab.
+func unfoldLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRequestSize)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseProperty splits the content line into its name, parameters and value. Parameter values may be quoted.
+func parseProperty(line string) icalProperty {
+	inQuotes := false
+	separator := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if r == ':' && !inQuotes {
+			separator = i
+			break
+		}
+	}
+	if separator < 0 {
+		return icalProperty{name: strings.ToUpper(line)}
+	}
+	parts := strings.Split(line[:separator], ";")
+	property := icalProperty{name: strings.ToUpper(parts[0]), params: make(map[string]string), value: line[separator+1:]}
+	for _, param := range parts[1:] {
+		if key, value, ok := strings.Cut(param, "="); ok {
+			property.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+		}
+	}
+	return property
+}
+
+// parseEvent returns the time entry of the event, ok is false for the events that are not time entries.
+func parseEvent(properties []icalProperty, location *time.Location) (entry Entry, ok bool, err error) {
+	var start, end *icalProperty
+	for i, property := range properties {
+		switch property.name {
+		case "SUMMARY":
+			entry.Activity = strings.TrimSpace(unescapeText(property.value))
+		case "DESCRIPTION":
+			entry.Description = strings.TrimSpace(unescapeText(property.value))
+		case "DTSTART":
+			start = &properties[i]
+		case "DTEND":
+			end = &properties[i]
+		case "STATUS":
+			if strings.EqualFold(property.value, "CANCELLED") {
+				return Entry{}, false, nil
+			}
+		}
+	}
+	if start == nil {
+		return Entry{}, false, errors.New("missing DTSTART")
+	}
+	if isDate(*start) {
+		return Entry{}, false, nil
+	}
+	if end == nil {
+		return Entry{}, false, errors.New("missing DTEND")
+	}
+	if entry.Activity == "" {
+		entry.Activity = untitledActivity
+	}
+	if entry.Start, err = parseDateTime(*start, location); err != nil {
+		return Entry{}, false, err
+	}
+	if entry.End, err = parseDateTime(*end, location); err != nil {
+		return Entry{}, false, err
+	}
+	if !entry.End.After(entry.Start) {
+		return Entry{}, false, errors.New("the end is not after the start")
+	}
+	return entry, true, nil
+}
+
+// isDate tells whether the property is a date without a time, which all-day events start with.
+func isDate(property icalProperty) bool {
+	return strings.EqualFold(property.params["VALUE"], "DATE") || len(property.value) == len("20060102")
+}
+
+// parseDateTime reads a UTC time, e.g. 20250310T090000Z, or a local one in the time zone of its TZID parameter, or
+// in the location when it has none. The time is returned in the location, like the times of the other formats.
+func parseDateTime(property icalProperty, location *time.Location) (time.Time, error) {
+	if strings.HasSuffix(property.value, "Z") {
+		if t, err := time.Parse("20060102T150405Z", property.value); err == nil {
+			return t.In(location), nil
+		}
+		return time.Time{}, fmt.Errorf("invalid %s %q", property.name, property.value)
+	}
+	timeLocation := location
+	if tzid, ok := property.params["TZID"]; ok {
+		var err error
+		if timeLocation, err = time.LoadLocation(tzid); err != nil {
+			return time.Time{}, fmt.Errorf("unknown time zone %q of %s", tzid, property.name)
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", property.value, timeLocation)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q", property.name, property.value)
+	}
+	return t.In(location), nil
+}
+
+// unescapeText reverts the escaping of the text values, e.g. \n for a new line and \, for a comma.
+func unescapeText(value string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
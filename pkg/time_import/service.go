@@ -0,0 +1,229 @@
+package time_import
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/event_classifier"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrInvalidMapping = errors.New("invalid mapping")
+
+// importedFromAttribute is the event attribute naming the format the event was imported from.
+const importedFromAttribute = "importedFrom"
+
+type Service interface {
+	// Preview reads the file and suggests a budget item for each of its activities. Nothing is imported.
+	Preview(ctx context.Context, file io.Reader, format Format) (Preview, error)
+	// Import creates calendar events of the entries whose activity is mapped to a budget item. Entries of other
+	// activities, and entries already imported before, are skipped. Nothing is imported when any row is invalid.
+	Import(ctx context.Context, file io.Reader, format Format, mappings []Mapping) (ImportResult, error)
+}
+
+type eventClassifier interface {
+	ClassifyAll(ctx context.Context, entries []event_classifier.Entry) ([][]event_classifier.Suggestion, error)
+}
+
+// eventCalendar is the calendar the entries are imported to, calendar_provider.CalendarProvider implements it.
+type eventCalendar interface {
+	AddEvents(ctx context.Context, events []calendar.Event) ([]error, error)
+	GetEvents(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error)
+}
+
+type budgetItemsReader interface {
+	GetItem(ctx context.Context, id int) (budget_plan.BudgetItem, error)
+}
+
+type ServiceImpl struct {
+	calendar    eventCalendar
+	budgetItems budgetItemsReader
+	classifier  eventClassifier
+}
+
+func NewService(calendar eventCalendar, budgetItems budgetItemsReader, classifier eventClassifier) *ServiceImpl {
+	return &ServiceImpl{
+		calendar:    calendar,
+		budgetItems: budgetItems,
+		classifier:  classifier,
+	}
+}
+
+func (s *ServiceImpl) Preview(ctx context.Context, file io.Reader, format Format) (Preview, error) {
+	entries, rowErrors, err := s.decode(ctx, file, format)
+	if err != nil {
+		return Preview{}, err
+	}
+	preview := Preview{Errors: rowErrors}
+	activitiesByName := make(map[string]*Activity)
+	for _, entry := range entries {
+		if preview.From.IsZero() || entry.Start.Before(preview.From) {
+			preview.From = entry.Start
+		}
+		if entry.End.After(preview.To) {
+			preview.To = entry.End
+		}
+		activity, ok := activitiesByName[entry.Activity]
+		if !ok {
+			activity = &Activity{Name: entry.Activity}
+			activitiesByName[entry.Activity] = activity
+		}
+		activity.Entries++
+		activity.Duration += entry.End.Sub(entry.Start)
+	}
+	classified := make([]event_classifier.Entry, 0, len(activitiesByName))
+	for _, activity := range activitiesByName {
+		preview.Activities = append(preview.Activities, *activity)
+		classified = append(classified, event_classifier.Entry{Summary: activity.Name})
+	}
+	suggestions, err := s.classifier.ClassifyAll(ctx, classified)
+	if err != nil {
+		return Preview{}, fmt.Errorf("failed to suggest budget items: %w", err)
+	}
+	for i := range preview.Activities {
+		if len(suggestions[i]) > 0 {
+			preview.Activities[i].BudgetItemId = suggestions[i][0].BudgetItemId
+		}
+	}
+	slices.SortFunc(preview.Activities, func(a, b Activity) int {
+		if a.Duration != b.Duration {
+			return cmp.Compare(b.Duration, a.Duration)
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return preview, nil
+}
+
+func (s *ServiceImpl) Import(ctx context.Context, file io.Reader, format Format, mappings []Mapping) (ImportResult, error) {
+	budgetItemIds, err := s.resolveMappings(ctx, mappings)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	entries, rowErrors, err := s.decode(ctx, file, format)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	if len(rowErrors) > 0 {
+		return ImportResult{Errors: rowErrors}, nil
+	}
+	if len(entries) == 0 {
+		return ImportResult{}, nil
+	}
+
+	imported, err := s.importedStarts(ctx, entries)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	var result ImportResult
+	var imports []Entry
+	var events []calendar.Event
+	for _, entry := range entries {
+		budgetItemId := budgetItemIds[entry.Activity]
+		key := importKey{budgetItemId, entry.Start.Unix()}
+		if budgetItemId == 0 || imported[key] {
+			result.Skipped++
+			continue
+		}
+		imported[key] = true
+		imports = append(imports, entry)
+		events = append(events, calendar.Event{
+			Summary:   entry.Activity,
+			StartTime: entry.Start,
+			EndTime:   entry.End,
+			Metadata: calendar.EventMetadata{
+				BudgetItemId: budgetItemId,
+				Notes:        entry.Description,
+				Attributes:   map[string]string{importedFromAttribute: string(format)},
+			},
+		})
+	}
+	if len(events) == 0 {
+		return result, nil
+	}
+	eventErrs, err := s.calendar.AddEvents(ctx, events)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("failed to add imported events: %w", err)
+	}
+	for i, eventErr := range eventErrs {
+		if eventErr != nil {
+			log.Debugf("Imported entry of row %d rejected: %v", imports[i].Row, eventErr)
+			result.Rejected = append(result.Rejected, RowError{Row: imports[i].Row, Message: eventErr.Error()})
+			continue
+		}
+		result.Created++
+	}
+	return result, nil
+}
+
+// decode reads the entries of the file in the timezone of the current user.
+func (s *ServiceImpl) decode(ctx context.Context, file io.Reader, format Format) ([]Entry, []RowError, error) {
+	if !format.IsValid() {
+		return nil, nil, fmt.Errorf("%w: format must be one of: toggl, clockify, atracker, google_takeout", ErrInvalidFile)
+	}
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	location, err := time.LoadLocation(currentUser.Settings.Timezone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	return decodeEntries(file, format, location)
+}
+
+// resolveMappings returns the budget item id of each mapped activity, checking the budget items exist.
+func (s *ServiceImpl) resolveMappings(ctx context.Context, mappings []Mapping) (map[string]int, error) {
+	budgetItemIds := make(map[string]int, len(mappings))
+	for _, mapping := range mappings {
+		if _, ok := budgetItemIds[mapping.Activity]; ok {
+			return nil, fmt.Errorf("%w: activity %q is mapped more than once", ErrInvalidMapping, mapping.Activity)
+		}
+		if mapping.BudgetItemId != 0 {
+			if _, err := s.budgetItems.GetItem(ctx, mapping.BudgetItemId); err != nil {
+				if errors.Is(err, budget_plan.ErrBudgetPlanItemNotFound) {
+					return nil, fmt.Errorf("%w: budget item %d not found", ErrInvalidMapping, mapping.BudgetItemId)
+				}
+				return nil, fmt.Errorf("failed to get budget item: %w", err)
+			}
+		}
+		budgetItemIds[mapping.Activity] = mapping.BudgetItemId
+	}
+	return budgetItemIds, nil
+}
+
+// importKey identifies an imported entry by its budget item and start, in seconds since the epoch.
+type importKey struct {
+	budgetItemId int
+	start        int64
+}
+
+// importedStarts returns the events already stored in the period of the entries, so an imported file can be
+// imported again without duplicating its entries.
+func (s *ServiceImpl) importedStarts(ctx context.Context, entries []Entry) (map[importKey]bool, error) {
+	from, to := entries[0].Start, entries[0].End
+	for _, entry := range entries {
+		if entry.Start.Before(from) {
+			from = entry.Start
+		}
+		if entry.End.After(to) {
+			to = entry.End
+		}
+	}
+	events, err := s.calendar.GetEvents(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get calendar events: %w", err)
+	}
+	imported := make(map[importKey]bool, len(events))
+	for _, event := range events {
+		imported[importKey{event.Metadata.BudgetItemId, event.StartTime.Unix()}] = true
+	}
+	return imported, nil
+}
@@ -0,0 +1,230 @@
+package time_import
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/event_classifier"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testUser = user.User{
+	Id:       1,
+	Uid:      "user-1",
+	Username: "test-user-1",
+	Settings: user.Settings{Timezone: "Europe/Warsaw", WeekFirstDay: time.Monday},
+}
+
+var location, _ = time.LoadLocation("Europe/Warsaw")
+
+type budgetItemsStub map[int]budget_plan.BudgetItem
+
+func (b budgetItemsStub) GetItem(ctx context.Context, id int) (budget_plan.BudgetItem, error) {
+	item, ok := b[id]
+	if !ok {
+		return budget_plan.BudgetItem{}, budget_plan.ErrBudgetPlanItemNotFound
+	}
+	return item, nil
+}
+
+// classifierStub suggests the budget item of the activity by its name.
+type classifierStub map[string]int
+
+func (c classifierStub) ClassifyAll(ctx context.Context, entries []event_classifier.Entry) ([][]event_classifier.Suggestion, error) {
+	result := make([][]event_classifier.Suggestion, len(entries))
+	for i, entry := range entries {
+		if budgetItemId, ok := c[entry.Summary]; ok {
+			result[i] = []event_classifier.Suggestion{{BudgetItemId: budgetItemId, Confidence: 1}}
+		}
+	}
+	return result, nil
+}
+
+func setupServiceTest(t *testing.T) (*ServiceImpl, *calendar.StubCalendar, context.Context) {
+	t.Helper()
+	calendarStub := calendar.NewStubCalendar()
+	budgetItems := budgetItemsStub{
+		10: {Id: 10, Name: "Work"},
+		11: {Id: 11, Name: "Reading"},
+	}
+	service := NewService(calendarStub, budgetItems, classifierStub{"Klokku": 10})
+	return service, calendarStub, user.WithUser(context.Background(), testUser)
+}
+
+const togglFile = "\ufeffUser,Email,Client,Project,Task,Description,Billable,Start date,Start time,End date,End time,Duration,Tags\n" +
+	"Jane,jane@example.com,,Klokku,,Import feature,No,2025-03-10,09:00:00,2025-03-10,11:30:00,02:30:00,\n" +
+	"Jane,jane@example.com,,Books,,The Hobbit,No,2025-03-10,20:00:00,2025-03-10,21:00:00,01:00:00,\n" +
+	"Jane,jane@example.com,,,,Email,No,2025-03-11,08:00:00,2025-03-11,08:15:00,00:15:00,\n" +
+	"Jane,jane@example.com,,Klokku,,Review,No,2025-03-11,09:00:00,2025-03-11,10:00:00,01:00:00,\n"
+
+func TestPreview_GroupsEntriesByActivity(t *testing.T) {
+	// given
+	service, _, ctx := setupServiceTest(t)
+
+	// when
+	preview, err := service.Preview(ctx, strings.NewReader(togglFile), FormatToggl)
+
+	// then
+	require.NoError(t, err)
+	assert.Empty(t, preview.Errors)
+	assert.Equal(t, time.Date(2025, 3, 10, 9, 0, 0, 0, location), preview.From)
+	assert.Equal(t, time.Date(2025, 3, 11, 10, 0, 0, 0, location), preview.To)
+	assert.Equal(t, []Activity{
+		{Name: "Klokku", Entries: 2, Duration: 3*time.Hour + 30*time.Minute, BudgetItemId: 10},
+		{Name: "Books", Entries: 1, Duration: time.Hour},
+		{Name: "Email", Entries: 1, Duration: 15 * time.Minute},
+	}, preview.Activities)
+}
+
+func TestImport_CreatesEventsOfMappedActivities(t *testing.T) {
+	// given
+	service, calendarStub, ctx := setupServiceTest(t)
+	mappings := []Mapping{{Activity: "Klokku", BudgetItemId: 10}, {Activity: "Books", BudgetItemId: 11}}
+
+	// when
+	result, err := service.Import(ctx, strings.NewReader(togglFile), FormatToggl, mappings)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, ImportResult{Created: 3, Skipped: 1}, result)
+	events, err := calendarStub.GetEvents(ctx, time.Date(2025, 3, 10, 0, 0, 0, 0, location),
+		time.Date(2025, 3, 12, 0, 0, 0, 0, location))
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, time.Date(2025, 3, 10, 9, 0, 0, 0, location), events[0].StartTime)
+	assert.Equal(t, time.Date(2025, 3, 10, 11, 30, 0, 0, location), events[0].EndTime)
+	assert.Equal(t, calendar.EventMetadata{
+		BudgetItemId: 10,
+		Notes:        "Import feature",
+		Attributes:   map[string]string{"importedFrom": "toggl"},
+	}, events[0].Metadata)
+	assert.Equal(t, 11, events[1].Metadata.BudgetItemId)
+}
+
+func TestImport_SkipsEntriesImportedBefore(t *testing.T) {
+	// given
+	service, _, ctx := setupServiceTest(t)
+	mappings := []Mapping{{Activity: "Klokku", BudgetItemId: 10}}
+	_, err := service.Import(ctx, strings.NewReader(togglFile), FormatToggl, mappings)
+	require.NoError(t, err)
+
+	// when
+	result, err := service.Import(ctx, strings.NewReader(togglFile), FormatToggl, mappings)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, ImportResult{Created: 0, Skipped: 4}, result)
+}
+
+func TestImport_NothingImportedWithInvalidRows(t *testing.T) {
+	// given
+	service, calendarStub, ctx := setupServiceTest(t)
+	file := "Project,Client,Description,Task,User,Start Date,Start Time,End Date,End Time\n" +
+		"Klokku,,Import,,Jane,03/10/2025,09:00:00 AM,03/10/2025,11:00:00 AM\n" +
+		"Klokku,,Import,,Jane,03/10/2025,soon,03/10/2025,11:00:00 AM\n" +
+		"Klokku,,Import,,Jane,03/10/2025,01:00:00 PM,03/10/2025,12:00:00 PM\n"
+
+	// when
+	result, err := service.Import(ctx, strings.NewReader(file), FormatClockify,
+		[]Mapping{{Activity: "Klokku", BudgetItemId: 10}})
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, []RowError{
+		{Row: 2, Message: `invalid start date and start time "03/10/2025 soon"`},
+		{Row: 3, Message: "the end is not after the start"},
+	}, result.Errors)
+	assert.Zero(t, result.Created)
+	events, err := calendarStub.GetEvents(ctx, time.Date(2025, 3, 10, 0, 0, 0, 0, location),
+		time.Date(2025, 3, 11, 0, 0, 0, 0, location))
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestImport_InvalidMapping(t *testing.T) {
+	// given
+	service, _, ctx := setupServiceTest(t)
+
+	// when
+	_, unknownErr := service.Import(ctx, strings.NewReader(togglFile), FormatToggl,
+		[]Mapping{{Activity: "Klokku", BudgetItemId: 99}})
+	_, duplicateErr := service.Import(ctx, strings.NewReader(togglFile), FormatToggl,
+		[]Mapping{{Activity: "Klokku", BudgetItemId: 10}, {Activity: "Klokku", BudgetItemId: 11}})
+
+	// then
+	assert.ErrorIs(t, unknownErr, ErrInvalidMapping)
+	assert.ErrorIs(t, duplicateErr, ErrInvalidMapping)
+}
+
+// googleTakeoutFile has an all-day and a cancelled event, which are left out, and a folded, escaped event with an
+// alarm.
+const googleTakeoutFile = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20250310\r\nDTEND;VALUE=DATE:20250311\r\nSUMMARY:Birthday\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nDTSTART:20250310T060000Z\r\nDTEND:20250310T070000Z\r\nSUMMARY:Gym\r\nSTATUS:CANCELLED\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nDTSTART;TZID=Europe/Warsaw:20250310T090000\r\nDTEND:20250310T090000Z\r\n" +
+	"SUMMARY:Dentist\\, Dr. Smith\r\nDESCRIPTION:Bring the card\\n\r\n and the referral\r\n" +
+	"BEGIN:VALARM\r\nACTION:DISPLAY\r\nDESCRIPTION:Reminder\r\nEND:VALARM\r\nEND:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func Test_decodeEntries(t *testing.T) {
+	for name, tt := range map[string]struct {
+		format Format
+		file   string
+		entry  Entry
+	}{
+		"clockify with 24-hour times": {
+			format: FormatClockify,
+			file:   "Project,Description,Start Date,Start Time,End Date,End Time\nKlokku,Stats,03/10/2025,21:00,03/10/2025,22:15",
+			entry: Entry{Row: 1, Activity: "Klokku", Description: "Stats",
+				Start: time.Date(2025, 3, 10, 21, 0, 0, 0, location), End: time.Date(2025, 3, 10, 22, 15, 0, 0, location)},
+		},
+		"atracker": {
+			format: FormatATracker,
+			file: "Task name,Task description,Start time,End time,Duration,Duration in hours,Note,Tag\n" +
+				`Running,,"Mar 10, 2025 at 6:30 AM","Mar 10, 2025 at 7:15 AM",00:45,0.75,Park,`,
+			entry: Entry{Row: 1, Activity: "Running", Description: "Park",
+				Start: time.Date(2025, 3, 10, 6, 30, 0, 0, location), End: time.Date(2025, 3, 10, 7, 15, 0, 0, location)},
+		},
+		"google takeout": {
+			format: FormatGoogleTakeout,
+			file:   googleTakeoutFile,
+			entry: Entry{Row: 3, Activity: "Dentist, Dr. Smith", Description: "Bring the card\nand the referral",
+				Start: time.Date(2025, 3, 10, 9, 0, 0, 0, location), End: time.Date(2025, 3, 10, 10, 0, 0, 0, location)},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// when
+			entries, rowErrors, err := decodeEntries(strings.NewReader(tt.file), tt.format, location)
+
+			// then
+			require.NoError(t, err)
+			assert.Empty(t, rowErrors)
+			assert.Equal(t, []Entry{tt.entry}, entries)
+		})
+	}
+}
+
+func Test_decodeEntries_WrongFormat(t *testing.T) {
+	// when
+	_, _, err := decodeEntries(strings.NewReader(togglFile), FormatATracker, location)
+
+	// then
+	assert.ErrorIs(t, err, ErrInvalidFile)
+	assert.ErrorContains(t, err, `missing "task name" column`)
+}
+
+func Test_decodeEntries_NotACalendar(t *testing.T) {
+	// when
+	_, _, err := decodeEntries(strings.NewReader(togglFile), FormatGoogleTakeout, location)
+
+	// then
+	assert.ErrorIs(t, err, ErrInvalidFile)
+	assert.ErrorContains(t, err, "missing BEGIN:VCALENDAR")
+}
@@ -0,0 +1,80 @@
+package time_import
+
+import (
+	"time"
+)
+
+// Format is the time tracker the file was exported from.
+type Format string
+
+const (
+	// FormatToggl is the detailed report CSV of Toggl Track.
+	FormatToggl Format = "toggl"
+	// FormatClockify is the detailed report CSV of Clockify.
+	FormatClockify Format = "clockify"
+	// FormatATracker is the CSV export of ATracker.
+	FormatATracker Format = "atracker"
+	// FormatGoogleTakeout is an iCalendar (.ics) file of the Calendar folder of a Google Takeout export.
+	FormatGoogleTakeout Format = "google_takeout"
+)
+
+func (f Format) IsValid() bool {
+	return f == FormatToggl || f == FormatClockify || f == FormatATracker || f == FormatGoogleTakeout
+}
+
+// Entry is a time entry of the file. Its Activity (the project, the task for ATracker, or the event summary for
+// Google Takeout) is what gets mapped to a budget item.
+type Entry struct {
+	// Row is the 1-based row number in the file, not counting the header. For Google Takeout it is the number of the
+	// event in the file.
+	Row         int
+	Activity    string
+	Description string
+	Start       time.Time
+	End         time.Time
+}
+
+// Activity groups the entries of the file with the same activity.
+type Activity struct {
+	Name     string
+	Entries  int
+	Duration time.Duration
+	// BudgetItemId is the budget item suggested for the activity, 0 when there is no suggestion.
+	BudgetItemId int
+}
+
+// RowError describes why a row cannot be imported. Row 0 refers to the whole file.
+type RowError struct {
+	Row     int
+	Message string
+}
+
+// Preview shows what the file contains so its activities can be mapped to budget items before importing it.
+type Preview struct {
+	// From and To is the period of the entries, zero when the file has none.
+	From time.Time
+	To   time.Time
+	// Activities are sorted by duration, the longest first.
+	Activities []Activity
+	Errors     []RowError
+}
+
+// Mapping assigns the entries of an activity to a budget item.
+type Mapping struct {
+	Activity     string
+	BudgetItemId int
+}
+
+// ImportResult tells what happened to the entries of the file. When the file has invalid rows, nothing is imported.
+type ImportResult struct {
+	Created int
+	// Skipped entries have no mapping, or were already imported.
+	Skipped int
+	// Rejected entries were refused by the calendar, e.g. in a locked week.
+	Rejected []RowError
+	Errors   []RowError
+}
+
+func (r ImportResult) HasErrors() bool {
+	return len(r.Errors) > 0
+}
@@ -0,0 +1,152 @@
+package toggl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var ErrUnauthenticated = errors.New("toggl API token rejected")
+
+const (
+	togglBaseUrl   = "https://api.track.toggl.com/api/v9"
+	maxErrorLength = 500
+)
+
+// Client calls the Toggl Track API v9 with the user's API token.
+type Client interface {
+	// GetDefaultWorkspaceId checks the token and returns the default workspace of its account.
+	GetDefaultWorkspaceId(ctx context.Context, apiToken string) (int64, error)
+	ListProjects(ctx context.Context, apiToken string, workspaceId int64) ([]Project, error)
+	// ListTimeEntries returns the entries started in the period.
+	ListTimeEntries(ctx context.Context, apiToken string, from time.Time, to time.Time) ([]TimeEntry, error)
+	// ListModifiedTimeEntries returns the entries modified since the time, including the deleted ones.
+	ListModifiedTimeEntries(ctx context.Context, apiToken string, since time.Time) ([]TimeEntry, error)
+}
+
+type togglMe struct {
+	DefaultWorkspaceId int64 `json:"default_workspace_id"`
+}
+
+type togglProject struct {
+	Id     int64  `json:"id"`
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+}
+
+type togglTimeEntry struct {
+	Id              int64      `json:"id"`
+	WorkspaceId     int64      `json:"workspace_id"`
+	ProjectId       *int64     `json:"project_id"`
+	Description     string     `json:"description"`
+	Tags            []string   `json:"tags"`
+	Start           time.Time  `json:"start"`
+	Stop            *time.Time `json:"stop"`
+	At              time.Time  `json:"at"`
+	ServerDeletedAt *time.Time `json:"server_deleted_at"`
+}
+
+type ClientImpl struct {
+	httpClient *http.Client
+	baseUrl    string
+}
+
+func NewClient(httpClient *http.Client) *ClientImpl {
+	return &ClientImpl{httpClient: httpClient, baseUrl: togglBaseUrl}
+}
+
+func (c *ClientImpl) GetDefaultWorkspaceId(ctx context.Context, apiToken string) (int64, error) {
+	var me togglMe
+	if err := c.call(ctx, apiToken, c.baseUrl+"/me", &me); err != nil {
+		return 0, fmt.Errorf("failed to get Toggl account: %w", err)
+	}
+	return me.DefaultWorkspaceId, nil
+}
+
+func (c *ClientImpl) ListProjects(ctx context.Context, apiToken string, workspaceId int64) ([]Project, error) {
+	var togglProjects []togglProject
+	projectsUrl := fmt.Sprintf("%s/workspaces/%d/projects", c.baseUrl, workspaceId)
+	if err := c.call(ctx, apiToken, projectsUrl, &togglProjects); err != nil {
+		return nil, fmt.Errorf("failed to list Toggl projects: %w", err)
+	}
+	projects := make([]Project, 0, len(togglProjects))
+	for _, p := range togglProjects {
+		projects = append(projects, Project{Id: p.Id, Name: p.Name, Active: p.Active})
+	}
+	return projects, nil
+}
+
+func (c *ClientImpl) ListTimeEntries(ctx context.Context, apiToken string, from time.Time, to time.Time) ([]TimeEntry, error) {
+	query := url.Values{}
+	query.Set("start_date", from.UTC().Format(time.RFC3339))
+	query.Set("end_date", to.UTC().Format(time.RFC3339))
+	entries, err := c.listTimeEntries(ctx, apiToken, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Toggl time entries: %w", err)
+	}
+	return entries, nil
+}
+
+func (c *ClientImpl) ListModifiedTimeEntries(ctx context.Context, apiToken string, since time.Time) ([]TimeEntry, error) {
+	query := url.Values{}
+	query.Set("since", strconv.FormatInt(since.Unix(), 10))
+	entries, err := c.listTimeEntries(ctx, apiToken, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list modified Toggl time entries: %w", err)
+	}
+	return entries, nil
+}
+
+func (c *ClientImpl) listTimeEntries(ctx context.Context, apiToken string, query url.Values) ([]TimeEntry, error) {
+	var togglEntries []togglTimeEntry
+	if err := c.call(ctx, apiToken, c.baseUrl+"/me/time_entries?"+query.Encode(), &togglEntries); err != nil {
+		return nil, err
+	}
+	entries := make([]TimeEntry, 0, len(togglEntries))
+	for _, e := range togglEntries {
+		entry := TimeEntry{
+			Id:          e.Id,
+			WorkspaceId: e.WorkspaceId,
+			Description: e.Description,
+			Tags:        e.Tags,
+			Start:       e.Start,
+			Stop:        e.Stop,
+			UpdatedAt:   e.At,
+			DeletedAt:   e.ServerDeletedAt,
+		}
+		if e.ProjectId != nil {
+			entry.ProjectId = *e.ProjectId
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// call sends a GET request authenticated with the API token and decodes the JSON response into result.
+func (c *ClientImpl) call(ctx context.Context, apiToken string, requestUrl string, result any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(apiToken, "api_token")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return ErrUnauthenticated
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		response, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(response))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
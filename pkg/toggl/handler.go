@@ -0,0 +1,325 @@
+package toggl
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/klokku/klokku/internal/rest"
+	log "github.com/sirupsen/logrus"
+)
+
+type ConfigurationDTO struct {
+	// ApiToken is write only, leave it empty to keep the stored one
+	ApiToken string `json:"apiToken,omitempty"`
+	// WorkspaceId defaults to the default workspace of the account
+	WorkspaceId int64 `json:"workspaceId"`
+	// Enabled turns the periodic sync on, defaults to true
+	Enabled     *bool      `json:"enabled,omitempty"`
+	SyncedUntil *time.Time `json:"syncedUntil,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+}
+
+type MappingDTO struct {
+	// TogglProjectId of 0 matches any project
+	TogglProjectId int64 `json:"togglProjectId"`
+	// TogglTag, when empty, matches any tag
+	TogglTag     string `json:"togglTag"`
+	BudgetItemId int    `json:"budgetItemId"`
+}
+
+type ProjectDTO struct {
+	Id     int64  `json:"id"`
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+}
+
+type ImportRequestDTO struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type ImportResultDTO struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Deleted int `json:"deleted"`
+	Skipped int `json:"skipped"`
+}
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// GetConfiguration godoc
+// @Summary Get Toggl configuration
+// @Description Get the Toggl Track connection of the current user with the state of the sync
+// @Tags Toggl
+// @Produce json
+// @Success 200 {object} ConfigurationDTO
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Toggl not connected"
+// @Router /api/integrations/toggl [get]
+// @Security XUserId
+func (h *Handler) GetConfiguration(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	config, err := h.service.GetConfiguration(r.Context())
+	if err != nil {
+		h.handleError(w, err, "Failed to get Toggl configuration")
+		return
+	}
+	if err := json.NewEncoder(w).Encode(configurationToDTO(config)); err != nil {
+		log.Errorf("Failed to encode Toggl configuration: %v", err)
+		http.Error(w, "Failed to encode Toggl configuration", http.StatusInternalServerError)
+	}
+}
+
+// StoreConfiguration godoc
+// @Summary Connect Toggl
+// @Description Connect the Toggl Track account of the API token (found in the Toggl profile settings) or update
+// @Description the connection. Time entries modified after connecting are synced every 15 minutes while enabled,
+// @Description older ones are imported with /api/integrations/toggl/import.
+// @Tags Toggl
+// @Accept json
+// @Produce json
+// @Param configuration body ConfigurationDTO true "Configuration"
+// @Success 200 {object} ConfigurationDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid configuration or API token"
+// @Failure 403 {string} string "User not found"
+// @Router /api/integrations/toggl [put]
+// @Security XUserId
+func (h *Handler) StoreConfiguration(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var configDTO ConfigurationDTO
+	if err := json.NewDecoder(r.Body).Decode(&configDTO); err != nil {
+		writeBadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	config, err := h.service.StoreConfiguration(r.Context(), Configuration{
+		ApiToken:    configDTO.ApiToken,
+		WorkspaceId: configDTO.WorkspaceId,
+		Enabled:     configDTO.Enabled == nil || *configDTO.Enabled,
+	})
+	if err != nil {
+		h.handleError(w, err, "Failed to store Toggl configuration")
+		return
+	}
+	if err := json.NewEncoder(w).Encode(configurationToDTO(config)); err != nil {
+		log.Errorf("Failed to encode Toggl configuration: %v", err)
+		http.Error(w, "Failed to encode Toggl configuration", http.StatusInternalServerError)
+	}
+}
+
+// DeleteConfiguration godoc
+// @Summary Disconnect Toggl
+// @Description Remove the Toggl connection and mappings. Events imported before are kept.
+// @Tags Toggl
+// @Success 204 "No Content"
+// @Failure 403 {string} string "User not found"
+// @Router /api/integrations/toggl [delete]
+// @Security XUserId
+func (h *Handler) DeleteConfiguration(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.DeleteConfiguration(r.Context()); err != nil {
+		log.Errorf("Failed to delete Toggl configuration: %v", err)
+		http.Error(w, "Failed to delete Toggl configuration", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListProjects godoc
+// @Summary List Toggl projects
+// @Description List the projects of the connected Toggl workspace, to be used in mappings
+// @Tags Toggl
+// @Produce json
+// @Success 200 {array} ProjectDTO
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Toggl not connected"
+// @Failure 502 {object} rest.ErrorResponse "Toggl rejected the request"
+// @Router /api/integrations/toggl/projects [get]
+// @Security XUserId
+func (h *Handler) ListProjects(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	projects, err := h.service.ListProjects(r.Context())
+	if err != nil {
+		h.handleError(w, err, "Failed to list Toggl projects")
+		return
+	}
+	dtos := make([]ProjectDTO, 0, len(projects))
+	for _, p := range projects {
+		dtos = append(dtos, ProjectDTO{Id: p.Id, Name: p.Name, Active: p.Active})
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		log.Errorf("Failed to encode Toggl projects: %v", err)
+		http.Error(w, "Failed to encode Toggl projects", http.StatusInternalServerError)
+	}
+}
+
+// GetMappings godoc
+// @Summary Get Toggl mappings
+// @Description Get the mappings of Toggl projects and tags to budget items, in the order they are matched
+// @Tags Toggl
+// @Produce json
+// @Success 200 {array} MappingDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/integrations/toggl/mappings [get]
+// @Security XUserId
+func (h *Handler) GetMappings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	mappings, err := h.service.GetMappings(r.Context())
+	if err != nil {
+		h.handleError(w, err, "Failed to get Toggl mappings")
+		return
+	}
+	dtos := make([]MappingDTO, 0, len(mappings))
+	for _, m := range mappings {
+		dtos = append(dtos, MappingDTO{TogglProjectId: m.TogglProjectId, TogglTag: m.TogglTag, BudgetItemId: m.BudgetItemId})
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		log.Errorf("Failed to encode Toggl mappings: %v", err)
+		http.Error(w, "Failed to encode Toggl mappings", http.StatusInternalServerError)
+	}
+}
+
+// StoreMappings godoc
+// @Summary Store Toggl mappings
+// @Description Replace the mappings of Toggl projects and tags to budget items. A time entry is imported as
+// @Description an event of the first matching mapping's budget item, entries matching none are not imported.
+// @Tags Toggl
+// @Accept json
+// @Param mappings body []MappingDTO true "Mappings"
+// @Success 204 "No Content"
+// @Failure 400 {object} rest.ErrorResponse "Invalid mappings"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Toggl not connected"
+// @Router /api/integrations/toggl/mappings [put]
+// @Security XUserId
+func (h *Handler) StoreMappings(w http.ResponseWriter, r *http.Request) {
+	var dtos []MappingDTO
+	if err := json.NewDecoder(r.Body).Decode(&dtos); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		writeBadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	mappings := make([]Mapping, 0, len(dtos))
+	for _, dto := range dtos {
+		mappings = append(mappings, Mapping{TogglProjectId: dto.TogglProjectId, TogglTag: dto.TogglTag, BudgetItemId: dto.BudgetItemId})
+	}
+	if err := h.service.StoreMappings(r.Context(), mappings); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		h.handleError(w, err, "Failed to store Toggl mappings")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Import godoc
+// @Summary Import Toggl time entries
+// @Description Import the time entries started in the period, at most a year long, as calendar events.
+// @Description Entries imported before are updated when they changed in Toggl.
+// @Tags Toggl
+// @Accept json
+// @Produce json
+// @Param period body ImportRequestDTO true "Period"
+// @Success 200 {object} ImportResultDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid period"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Toggl not connected"
+// @Failure 502 {object} rest.ErrorResponse "Toggl rejected the request"
+// @Router /api/integrations/toggl/import [post]
+// @Security XUserId
+func (h *Handler) Import(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request ImportRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeBadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	result, err := h.service.Import(r.Context(), request.From, request.To)
+	if err != nil {
+		h.handleError(w, err, "Failed to import Toggl time entries")
+		return
+	}
+	if err := json.NewEncoder(w).Encode(importResultToDTO(result)); err != nil {
+		log.Errorf("Failed to encode Toggl import result: %v", err)
+	}
+}
+
+// Sync godoc
+// @Summary Sync Toggl time entries
+// @Description Import the time entries modified since the last sync now, instead of waiting for the periodic sync
+// @Tags Toggl
+// @Produce json
+// @Success 200 {object} ImportResultDTO
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Toggl not connected"
+// @Failure 502 {object} rest.ErrorResponse "Toggl rejected the request"
+// @Router /api/integrations/toggl/sync [post]
+// @Security XUserId
+func (h *Handler) Sync(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	result, err := h.service.Sync(r.Context())
+	if err != nil {
+		h.handleError(w, err, "Failed to sync Toggl time entries")
+		return
+	}
+	if err := json.NewEncoder(w).Encode(importResultToDTO(result)); err != nil {
+		log.Errorf("Failed to encode Toggl sync result: %v", err)
+	}
+}
+
+func (h *Handler) handleError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, ErrNotConnected):
+		http.Error(w, "Toggl not connected", http.StatusNotFound)
+	case errors.Is(err, ErrInvalidConfiguration):
+		writeBadRequest(w, "Invalid Toggl configuration", err.Error())
+	case errors.Is(err, ErrInvalidPeriod):
+		writeBadRequest(w, "Invalid import period", err.Error())
+	case errors.Is(err, ErrUnauthenticated):
+		w.WriteHeader(http.StatusBadGateway)
+		_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+			Error:   "Toggl rejected the request",
+			Details: err.Error(),
+		})
+	default:
+		log.Errorf("%s: %v", message, err)
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
+
+func configurationToDTO(config Configuration) ConfigurationDTO {
+	return ConfigurationDTO{
+		WorkspaceId: config.WorkspaceId,
+		Enabled:     &config.Enabled,
+		SyncedUntil: config.SyncedUntil,
+		LastError:   config.LastError,
+	}
+}
+
+func importResultToDTO(result ImportResult) ImportResultDTO {
+	return ImportResultDTO{
+		Created: result.Created,
+		Updated: result.Updated,
+		Deleted: result.Deleted,
+		Skipped: result.Skipped,
+	}
+}
+
+func writeBadRequest(w http.ResponseWriter, errMsg, details string) {
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+		Error:   errMsg,
+		Details: details,
+	})
+}
@@ -0,0 +1,198 @@
+package toggl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository interface {
+	// GetConfiguration returns nil when the user has not connected Toggl.
+	GetConfiguration(ctx context.Context, userId int) (*Configuration, error)
+	// StoreConfiguration creates or updates the configuration, keeping the sync cursor.
+	StoreConfiguration(ctx context.Context, config Configuration) error
+	// DeleteConfiguration removes the configuration with its mappings. Imported events are kept.
+	DeleteConfiguration(ctx context.Context, userId int) error
+	// ListEnabledConfigurations returns the configurations of all users with sync enabled.
+	ListEnabledConfigurations(ctx context.Context) ([]Configuration, error)
+	UpdateSyncCursor(ctx context.Context, userId int, syncedUntil time.Time, lastError string) error
+	GetMappings(ctx context.Context, userId int) ([]Mapping, error)
+	// StoreMappings replaces the user's mappings.
+	StoreMappings(ctx context.Context, userId int, mappings []Mapping) error
+	DeleteBudgetItemMappings(ctx context.Context, userId int, budgetItemId int) error
+	// GetImportedEntry returns nil when the time entry has not been imported.
+	GetImportedEntry(ctx context.Context, userId int, togglEntryId int64) (*ImportedEntry, error)
+	StoreImportedEntry(ctx context.Context, userId int, entry ImportedEntry) error
+	DeleteImportedEntry(ctx context.Context, userId int, togglEntryId int64) error
+}
+
+const configurationColumns = `user_id, workspace_id, enabled, synced_until, last_error`
+
+func scanConfiguration(row pgx.Row) (Configuration, error) {
+	var config Configuration
+	err := row.Scan(&config.UserId, &config.WorkspaceId, &config.Enabled, &config.SyncedUntil,
+		&config.LastError)
+	return config, err
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+func (r *RepositoryImpl) GetConfiguration(ctx context.Context, userId int) (*Configuration, error) {
+	query := `SELECT ` + configurationColumns + ` FROM toggl_config WHERE user_id = $1`
+	config, err := scanConfiguration(r.db.QueryRow(ctx, query, userId))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get Toggl configuration: %w", err)
+	}
+	return &config, nil
+}
+
+func (r *RepositoryImpl) StoreConfiguration(ctx context.Context, config Configuration) error {
+	query := `INSERT INTO toggl_config (user_id, workspace_id, enabled)
+			  VALUES ($1, $2, $3)
+			  ON CONFLICT (user_id) DO UPDATE SET
+				  workspace_id = EXCLUDED.workspace_id,
+				  enabled = EXCLUDED.enabled`
+	_, err := r.db.Exec(ctx, query, config.UserId, config.WorkspaceId, config.Enabled)
+	if err != nil {
+		return fmt.Errorf("failed to store Toggl configuration: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) DeleteConfiguration(ctx context.Context, userId int) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM toggl_config WHERE user_id = $1`, userId)
+	if err != nil {
+		return fmt.Errorf("failed to delete Toggl configuration: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) ListEnabledConfigurations(ctx context.Context) ([]Configuration, error) {
+	query := `SELECT ` + configurationColumns + ` FROM toggl_config WHERE enabled ORDER BY user_id`
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Toggl configurations: %w", err)
+	}
+	defer rows.Close()
+
+	configs := make([]Configuration, 0)
+	for rows.Next() {
+		config, err := scanConfiguration(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan Toggl configuration: %w", err)
+		}
+		configs = append(configs, config)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list Toggl configurations: %w", err)
+	}
+	return configs, nil
+}
+
+func (r *RepositoryImpl) UpdateSyncCursor(ctx context.Context, userId int, syncedUntil time.Time, lastError string) error {
+	query := `UPDATE toggl_config SET synced_until = $1, last_error = $2 WHERE user_id = $3`
+	_, err := r.db.Exec(ctx, query, syncedUntil, lastError, userId)
+	if err != nil {
+		return fmt.Errorf("failed to update Toggl sync cursor: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) GetMappings(ctx context.Context, userId int) ([]Mapping, error) {
+	query := `SELECT toggl_project_id, toggl_tag, budget_item_id, position
+			  FROM toggl_mapping WHERE user_id = $1 ORDER BY position`
+	rows, err := r.db.Query(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Toggl mappings: %w", err)
+	}
+	defer rows.Close()
+
+	mappings := make([]Mapping, 0)
+	for rows.Next() {
+		var m Mapping
+		if err := rows.Scan(&m.TogglProjectId, &m.TogglTag, &m.BudgetItemId, &m.Position); err != nil {
+			return nil, fmt.Errorf("failed to scan Toggl mapping: %w", err)
+		}
+		mappings = append(mappings, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get Toggl mappings: %w", err)
+	}
+	return mappings, nil
+}
+
+func (r *RepositoryImpl) StoreMappings(ctx context.Context, userId int, mappings []Mapping) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, `DELETE FROM toggl_mapping WHERE user_id = $1`, userId); err != nil {
+		return fmt.Errorf("failed to delete Toggl mappings: %w", err)
+	}
+	for _, m := range mappings {
+		query := `INSERT INTO toggl_mapping (user_id, toggl_project_id, toggl_tag, budget_item_id, position)
+				  VALUES ($1, $2, $3, $4, $5)`
+		if _, err := tx.Exec(ctx, query, userId, m.TogglProjectId, m.TogglTag, m.BudgetItemId, m.Position); err != nil {
+			return fmt.Errorf("failed to insert Toggl mapping: %w", err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *RepositoryImpl) DeleteBudgetItemMappings(ctx context.Context, userId int, budgetItemId int) error {
+	query := `DELETE FROM toggl_mapping WHERE user_id = $1 AND budget_item_id = $2`
+	if _, err := r.db.Exec(ctx, query, userId, budgetItemId); err != nil {
+		return fmt.Errorf("failed to delete Toggl mappings of budget item %d: %w", budgetItemId, err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) GetImportedEntry(ctx context.Context, userId int, togglEntryId int64) (*ImportedEntry, error) {
+	query := `SELECT toggl_entry_id, event_uids, toggl_updated_at
+			  FROM toggl_imported_entry WHERE user_id = $1 AND toggl_entry_id = $2`
+	var entry ImportedEntry
+	err := r.db.QueryRow(ctx, query, userId, togglEntryId).Scan(&entry.TogglEntryId, &entry.EventUids,
+		&entry.TogglUpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get imported Toggl entry: %w", err)
+	}
+	return &entry, nil
+}
+
+func (r *RepositoryImpl) StoreImportedEntry(ctx context.Context, userId int, entry ImportedEntry) error {
+	query := `INSERT INTO toggl_imported_entry (user_id, toggl_entry_id, event_uids, toggl_updated_at)
+			  VALUES ($1, $2, $3, $4)
+			  ON CONFLICT (user_id, toggl_entry_id) DO UPDATE SET
+				  event_uids = EXCLUDED.event_uids,
+				  toggl_updated_at = EXCLUDED.toggl_updated_at`
+	if _, err := r.db.Exec(ctx, query, userId, entry.TogglEntryId, entry.EventUids, entry.TogglUpdatedAt); err != nil {
+		return fmt.Errorf("failed to store imported Toggl entry: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) DeleteImportedEntry(ctx context.Context, userId int, togglEntryId int64) error {
+	query := `DELETE FROM toggl_imported_entry WHERE user_id = $1 AND toggl_entry_id = $2`
+	if _, err := r.db.Exec(ctx, query, userId, togglEntryId); err != nil {
+		return fmt.Errorf("failed to delete imported Toggl entry: %w", err)
+	}
+	return nil
+}
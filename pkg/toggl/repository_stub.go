@@ -0,0 +1,134 @@
+package toggl
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+type RepositoryStub struct {
+	mu       sync.RWMutex
+	configs  map[int]Configuration
+	mappings map[int][]Mapping
+	imported map[int]map[int64]ImportedEntry
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{
+		configs:  make(map[int]Configuration),
+		mappings: make(map[int][]Mapping),
+		imported: make(map[int]map[int64]ImportedEntry),
+	}
+}
+
+func (r *RepositoryStub) GetConfiguration(ctx context.Context, userId int) (*Configuration, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	config, ok := r.configs[userId]
+	if !ok {
+		return nil, nil
+	}
+	return &config, nil
+}
+
+// StoreConfiguration drops the API token like the database, it is kept in the credentials store
+func (r *RepositoryStub) StoreConfiguration(ctx context.Context, config Configuration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	config.ApiToken = ""
+	if existing, ok := r.configs[config.UserId]; ok {
+		config.SyncedUntil = existing.SyncedUntil
+		config.LastError = existing.LastError
+	} else {
+		config.SyncedUntil = nil
+		config.LastError = ""
+	}
+	r.configs[config.UserId] = config
+	return nil
+}
+
+func (r *RepositoryStub) DeleteConfiguration(ctx context.Context, userId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.configs, userId)
+	delete(r.mappings, userId)
+	return nil
+}
+
+func (r *RepositoryStub) ListEnabledConfigurations(ctx context.Context) ([]Configuration, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	configs := make([]Configuration, 0)
+	for _, config := range r.configs {
+		if config.Enabled {
+			configs = append(configs, config)
+		}
+	}
+	slices.SortFunc(configs, func(a, b Configuration) int { return a.UserId - b.UserId })
+	return configs, nil
+}
+
+func (r *RepositoryStub) UpdateSyncCursor(ctx context.Context, userId int, syncedUntil time.Time, lastError string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	config, ok := r.configs[userId]
+	if !ok {
+		return nil
+	}
+	config.SyncedUntil = &syncedUntil
+	config.LastError = lastError
+	r.configs[userId] = config
+	return nil
+}
+
+func (r *RepositoryStub) GetMappings(ctx context.Context, userId int) ([]Mapping, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append(make([]Mapping, 0), r.mappings[userId]...), nil
+}
+
+func (r *RepositoryStub) StoreMappings(ctx context.Context, userId int, mappings []Mapping) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := append([]Mapping(nil), mappings...)
+	slices.SortFunc(stored, func(a, b Mapping) int { return a.Position - b.Position })
+	r.mappings[userId] = stored
+	return nil
+}
+
+func (r *RepositoryStub) DeleteBudgetItemMappings(ctx context.Context, userId int, budgetItemId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mappings[userId] = slices.DeleteFunc(r.mappings[userId], func(m Mapping) bool {
+		return m.BudgetItemId == budgetItemId
+	})
+	return nil
+}
+
+func (r *RepositoryStub) GetImportedEntry(ctx context.Context, userId int, togglEntryId int64) (*ImportedEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entry, ok := r.imported[userId][togglEntryId]
+	if !ok {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func (r *RepositoryStub) StoreImportedEntry(ctx context.Context, userId int, entry ImportedEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.imported[userId] == nil {
+		r.imported[userId] = make(map[int64]ImportedEntry)
+	}
+	r.imported[userId][entry.TogglEntryId] = entry
+	return nil
+}
+
+func (r *RepositoryStub) DeleteImportedEntry(ctx context.Context, userId int, togglEntryId int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.imported[userId], togglEntryId)
+	return nil
+}
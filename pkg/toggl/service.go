@@ -0,0 +1,390 @@
+package toggl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/credentials"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrNotConnected         = errors.New("toggl not connected")
+	ErrInvalidConfiguration = errors.New("invalid toggl configuration")
+	ErrInvalidPeriod        = errors.New("invalid import period")
+)
+
+const (
+	// maxSyncLookback is how far back Toggl returns modified time entries.
+	maxSyncLookback = 90 * 24 * time.Hour
+	maxImportPeriod = 366 * 24 * time.Hour
+)
+
+type Service interface {
+	// GetConfiguration returns ErrNotConnected when the user has not connected Toggl.
+	GetConfiguration(ctx context.Context) (Configuration, error)
+	// StoreConfiguration checks the API token with Toggl. An empty token keeps the stored one and an empty
+	// workspace selects the default workspace of the account.
+	StoreConfiguration(ctx context.Context, config Configuration) (Configuration, error)
+	DeleteConfiguration(ctx context.Context) error
+	ListProjects(ctx context.Context) ([]Project, error)
+	GetMappings(ctx context.Context) ([]Mapping, error)
+	StoreMappings(ctx context.Context, mappings []Mapping) error
+	// Import imports the time entries started in the period, e.g. the history before connecting Toggl.
+	// Entries imported before are updated when they changed in Toggl.
+	Import(ctx context.Context, from time.Time, to time.Time) (ImportResult, error)
+	// Sync imports the time entries modified since the sync cursor of the current user. The first sync only
+	// sets the cursor, earlier entries are imported with Import.
+	Sync(ctx context.Context) (ImportResult, error)
+	// SyncAll syncs the users with sync enabled.
+	SyncAll(ctx context.Context, now time.Time) error
+}
+
+type usersProvider interface {
+	GetUser(ctx context.Context, id int) (user.User, error)
+}
+
+type budgetItemReader interface {
+	GetItem(ctx context.Context, id int) (budget_plan.BudgetItem, error)
+}
+
+type ServiceImpl struct {
+	repo        Repository
+	client      Client
+	calendar    calendar.Calendar
+	users       usersProvider
+	budgetItems budgetItemReader
+	// credentials keeps the API token, encrypted like the tokens of the other integrations
+	credentials credentials.Service
+	clock       utils.Clock
+}
+
+func NewService(repo Repository, client Client, calendar calendar.Calendar, users usersProvider,
+	budgetItems budgetItemReader, credentialsService credentials.Service, eventBus *event_bus.EventBus) *ServiceImpl {
+	service := &ServiceImpl{
+		repo:        repo,
+		client:      client,
+		calendar:    calendar,
+		users:       users,
+		budgetItems: budgetItems,
+		credentials: credentialsService,
+		clock:       &utils.SystemClock{},
+	}
+	service.subscribe(eventBus)
+	return service
+}
+
+// subscribe removes the mappings of deleted budget items, so no entry is imported to an item that no longer exists.
+func (s *ServiceImpl) subscribe(eventBus *event_bus.EventBus) {
+	if eventBus == nil {
+		return
+	}
+	event_bus.SubscribeTyped[event_bus.BudgetPlanItemDeleted](
+		eventBus,
+		"budget_plan.item.deleted",
+		func(e event_bus.EventT[event_bus.BudgetPlanItemDeleted]) error {
+			userId, err := user.CurrentId(e.Context())
+			if err != nil {
+				return fmt.Errorf("failed to get current user: %w", err)
+			}
+			return s.repo.DeleteBudgetItemMappings(e.Context(), userId, e.Data.Id)
+		},
+	)
+}
+
+func (s *ServiceImpl) GetConfiguration(ctx context.Context) (Configuration, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Configuration{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.getConfiguration(ctx, userId)
+}
+
+func (s *ServiceImpl) getConfiguration(ctx context.Context, userId int) (Configuration, error) {
+	config, err := s.repo.GetConfiguration(ctx, userId)
+	if err != nil {
+		return Configuration{}, err
+	}
+	if config == nil {
+		return Configuration{}, ErrNotConnected
+	}
+	return s.withToken(ctx, *config)
+}
+
+// withToken adds the API token to the configuration. A configuration without a token, e.g. after the user revoked
+// all tokens, is not connected.
+func (s *ServiceImpl) withToken(ctx context.Context, config Configuration) (Configuration, error) {
+	token, err := s.credentials.GetToken(ctx, credentials.Toggl, config.UserId)
+	if err != nil {
+		return Configuration{}, err
+	}
+	if token == nil {
+		return Configuration{}, ErrNotConnected
+	}
+	config.ApiToken = token.AccessToken
+	return config, nil
+}
+
+func (s *ServiceImpl) StoreConfiguration(ctx context.Context, config Configuration) (Configuration, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Configuration{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	if config.ApiToken == "" {
+		existing, err := s.getConfiguration(ctx, userId)
+		if errors.Is(err, ErrNotConnected) {
+			return Configuration{}, fmt.Errorf("%w: API token is required", ErrInvalidConfiguration)
+		}
+		if err != nil {
+			return Configuration{}, err
+		}
+		config.ApiToken = existing.ApiToken
+	}
+	defaultWorkspaceId, err := s.client.GetDefaultWorkspaceId(ctx, config.ApiToken)
+	if err != nil {
+		if errors.Is(err, ErrUnauthenticated) {
+			return Configuration{}, fmt.Errorf("%w: API token rejected by Toggl", ErrInvalidConfiguration)
+		}
+		return Configuration{}, err
+	}
+	if config.WorkspaceId == 0 {
+		config.WorkspaceId = defaultWorkspaceId
+	}
+	config.UserId = userId
+	err = s.credentials.StoreToken(ctx, credentials.Toggl, userId, &oauth2.Token{AccessToken: config.ApiToken})
+	if err != nil {
+		return Configuration{}, err
+	}
+	if err := s.repo.StoreConfiguration(ctx, config); err != nil {
+		return Configuration{}, err
+	}
+	return s.getConfiguration(ctx, userId)
+}
+
+func (s *ServiceImpl) DeleteConfiguration(ctx context.Context) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	if err := s.repo.DeleteConfiguration(ctx, userId); err != nil {
+		return err
+	}
+	return s.credentials.Revoke(ctx, credentials.Toggl, userId)
+}
+
+func (s *ServiceImpl) ListProjects(ctx context.Context) ([]Project, error) {
+	config, err := s.GetConfiguration(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.client.ListProjects(ctx, config.ApiToken, config.WorkspaceId)
+}
+
+func (s *ServiceImpl) GetMappings(ctx context.Context) ([]Mapping, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.GetMappings(ctx, userId)
+}
+
+func (s *ServiceImpl) StoreMappings(ctx context.Context, mappings []Mapping) error {
+	config, err := s.GetConfiguration(ctx)
+	if err != nil {
+		return err
+	}
+	for i, m := range mappings {
+		if m.BudgetItemId <= 0 {
+			return fmt.Errorf("%w: mapping %d has no budget item", ErrInvalidConfiguration, i)
+		}
+		if _, err := s.budgetItems.GetItem(ctx, m.BudgetItemId); err != nil {
+			if errors.Is(err, budget_plan.ErrBudgetPlanItemNotFound) {
+				return fmt.Errorf("%w: budget item %d of mapping %d not found", ErrInvalidConfiguration, m.BudgetItemId, i)
+			}
+			return err
+		}
+		mappings[i].Position = i
+	}
+	return s.repo.StoreMappings(ctx, config.UserId, mappings)
+}
+
+func (s *ServiceImpl) Import(ctx context.Context, from time.Time, to time.Time) (ImportResult, error) {
+	if !from.Before(to) || to.Sub(from) > maxImportPeriod {
+		return ImportResult{}, fmt.Errorf("%w: from must be before to and the period at most a year long", ErrInvalidPeriod)
+	}
+	config, err := s.GetConfiguration(ctx)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	entries, err := s.client.ListTimeEntries(ctx, config.ApiToken, from, to)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	return s.importEntries(ctx, config, entries)
+}
+
+func (s *ServiceImpl) Sync(ctx context.Context) (ImportResult, error) {
+	config, err := s.GetConfiguration(ctx)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	return s.sync(ctx, config, s.clock.Now())
+}
+
+func (s *ServiceImpl) SyncAll(ctx context.Context, now time.Time) error {
+	configs, err := s.repo.ListEnabledConfigurations(ctx)
+	if err != nil {
+		return err
+	}
+	for _, config := range configs {
+		u, err := s.users.GetUser(ctx, config.UserId)
+		if err != nil {
+			log.Errorf("failed to get user %d for Toggl sync: %v", config.UserId, err)
+			continue
+		}
+		if u.Disabled {
+			continue
+		}
+		connected, err := s.withToken(ctx, config)
+		if errors.Is(err, ErrNotConnected) {
+			continue
+		}
+		if err != nil {
+			log.Errorf("failed to get Toggl token of user %d: %v", config.UserId, err)
+			continue
+		}
+		result, err := s.sync(user.WithUser(ctx, u), connected, now)
+		if err != nil {
+			log.Warnf("Toggl sync of user %d failed: %v", config.UserId, err)
+			continue
+		}
+		log.Debugf("Toggl sync of user %d: %+v", config.UserId, result)
+	}
+	return nil
+}
+
+// sync moves the cursor to now, which is taken before fetching, so entries modified meanwhile are fetched again
+// by the next sync. Unchanged entries are skipped then.
+func (s *ServiceImpl) sync(ctx context.Context, config Configuration, now time.Time) (ImportResult, error) {
+	if config.SyncedUntil == nil {
+		return ImportResult{}, s.repo.UpdateSyncCursor(ctx, config.UserId, now, "")
+	}
+	since := *config.SyncedUntil
+	if now.Sub(since) > maxSyncLookback {
+		since = now.Add(-maxSyncLookback)
+	}
+	entries, err := s.client.ListModifiedTimeEntries(ctx, config.ApiToken, since)
+	if err != nil {
+		if cursorErr := s.repo.UpdateSyncCursor(ctx, config.UserId, *config.SyncedUntil, err.Error()); cursorErr != nil {
+			log.Errorf("failed to store Toggl sync error of user %d: %v", config.UserId, cursorErr)
+		}
+		return ImportResult{}, err
+	}
+	result, err := s.importEntries(ctx, config, entries)
+	if err != nil {
+		return result, err
+	}
+	return result, s.repo.UpdateSyncCursor(ctx, config.UserId, now, "")
+}
+
+// importEntries creates, replaces or deletes the calendar events of the time entries. Entries rejected
+// by the calendar, e.g. in a locked week, are skipped so they do not block the others.
+func (s *ServiceImpl) importEntries(ctx context.Context, config Configuration, entries []TimeEntry) (ImportResult, error) {
+	mappings, err := s.repo.GetMappings(ctx, config.UserId)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	var result ImportResult
+	for _, entry := range entries {
+		if entry.WorkspaceId != config.WorkspaceId {
+			result.Skipped++
+			continue
+		}
+		imported, err := s.repo.GetImportedEntry(ctx, config.UserId, entry.Id)
+		if err != nil {
+			return result, err
+		}
+		if imported != nil && !entry.UpdatedAt.After(imported.TogglUpdatedAt) {
+			continue
+		}
+		budgetItemId := budgetItemIdOf(mappings, entry)
+		if entry.DeletedAt != nil || budgetItemId == 0 {
+			if imported == nil {
+				result.Skipped++
+				continue
+			}
+			if err := s.removeImported(ctx, config.UserId, *imported); err != nil {
+				return result, err
+			}
+			result.Deleted++
+			continue
+		}
+		if entry.Stop == nil {
+			result.Skipped++
+			continue
+		}
+
+		// The new events are added before the old ones are deleted, so a rejected change keeps the entry imported
+		created, err := s.calendar.AddEvent(ctx, calendar.Event{
+			Summary:   entry.Description,
+			StartTime: entry.Start,
+			EndTime:   *entry.Stop,
+			Metadata:  calendar.EventMetadata{BudgetItemId: budgetItemId, Notes: entry.Description},
+		})
+		if err != nil {
+			log.Warnf("Toggl time entry %d of user %d not imported: %v", entry.Id, config.UserId, err)
+			result.Skipped++
+			continue
+		}
+		eventUids := make([]string, 0, len(created))
+		for _, e := range created {
+			eventUids = append(eventUids, e.UID)
+		}
+		err = s.repo.StoreImportedEntry(ctx, config.UserId, ImportedEntry{
+			TogglEntryId:   entry.Id,
+			EventUids:      eventUids,
+			TogglUpdatedAt: entry.UpdatedAt,
+		})
+		if err != nil {
+			return result, err
+		}
+		if imported != nil {
+			s.deleteEvents(ctx, *imported)
+			result.Updated++
+		} else {
+			result.Created++
+		}
+	}
+	return result, nil
+}
+
+// removeImported deletes the events of the imported entry. Events already deleted in Klokku are ignored.
+func (s *ServiceImpl) removeImported(ctx context.Context, userId int, imported ImportedEntry) error {
+	s.deleteEvents(ctx, imported)
+	return s.repo.DeleteImportedEntry(ctx, userId, imported.TogglEntryId)
+}
+
+func (s *ServiceImpl) deleteEvents(ctx context.Context, imported ImportedEntry) {
+	for _, uid := range imported.EventUids {
+		if err := s.calendar.DeleteEvent(ctx, uid); err != nil {
+			log.Warnf("failed to delete event %s imported from Toggl time entry %d: %v", uid, imported.TogglEntryId, err)
+		}
+	}
+}
+
+func budgetItemIdOf(mappings []Mapping, entry TimeEntry) int {
+	for _, m := range mappings {
+		if m.Matches(entry) {
+			return m.BudgetItemId
+		}
+	}
+	return 0
+}
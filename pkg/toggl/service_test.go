@@ -0,0 +1,471 @@
+package toggl
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/credentials"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	workspaceId    = int64(100)
+	readingProject = int64(1)
+	workProject    = int64(2)
+)
+
+var now = time.Date(2025, 6, 9, 12, 0, 0, 0, time.UTC)
+
+var testUser = user.User{
+	Id:       1,
+	Uid:      "user-1",
+	Username: "test-user-1",
+	Settings: user.Settings{Timezone: "UTC", WeekFirstDay: time.Monday},
+}
+
+type clientStub struct {
+	entries     []TimeEntry
+	rejectToken bool
+	since       []time.Time
+}
+
+func (c *clientStub) GetDefaultWorkspaceId(ctx context.Context, apiToken string) (int64, error) {
+	if c.rejectToken {
+		return 0, ErrUnauthenticated
+	}
+	return workspaceId, nil
+}
+
+func (c *clientStub) ListProjects(ctx context.Context, apiToken string, workspaceId int64) ([]Project, error) {
+	return []Project{{Id: readingProject, Name: "Reading", Active: true}, {Id: workProject, Name: "Work", Active: true}}, nil
+}
+
+func (c *clientStub) ListTimeEntries(ctx context.Context, apiToken string, from time.Time, to time.Time) ([]TimeEntry, error) {
+	entries := make([]TimeEntry, 0)
+	for _, e := range c.entries {
+		if !e.Start.Before(from) && e.Start.Before(to) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func (c *clientStub) ListModifiedTimeEntries(ctx context.Context, apiToken string, since time.Time) ([]TimeEntry, error) {
+	if c.rejectToken {
+		return nil, ErrUnauthenticated
+	}
+	c.since = append(c.since, since)
+	entries := make([]TimeEntry, 0)
+	for _, e := range c.entries {
+		if !e.UpdatedAt.Before(since) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// calendarStub stores the events and rejects the ones starting at rejectStart.
+type calendarStub struct {
+	events      map[string]calendar.Event
+	rejectStart time.Time
+	nextUid     int
+}
+
+func (c *calendarStub) AddEvent(ctx context.Context, event calendar.Event) ([]calendar.Event, error) {
+	if event.StartTime.Equal(c.rejectStart) {
+		return nil, errors.New("week is locked")
+	}
+	c.nextUid++
+	event.UID = fmt.Sprintf("event-%d", c.nextUid)
+	c.events[event.UID] = event
+	return []calendar.Event{event}, nil
+}
+
+func (c *calendarStub) GetEvents(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error) {
+	return nil, nil
+}
+
+func (c *calendarStub) GetEventsIncludingArchive(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error) {
+	return nil, nil
+}
+
+func (c *calendarStub) ModifyEvent(ctx context.Context, event calendar.Event) ([]calendar.Event, error) {
+	c.events[event.UID] = event
+	return []calendar.Event{event}, nil
+}
+
+func (c *calendarStub) GetLastEvents(ctx context.Context, limit int) ([]calendar.Event, error) {
+	return nil, nil
+}
+
+func (c *calendarStub) DeleteEvent(ctx context.Context, eventUid string) error {
+	delete(c.events, eventUid)
+	return nil
+}
+
+type usersStub map[int]user.User
+
+func (u usersStub) GetUser(ctx context.Context, id int) (user.User, error) {
+	return u[id], nil
+}
+
+// budgetItemsStub knows the budget items of the test user.
+type budgetItemsStub map[int]bool
+
+func (s budgetItemsStub) GetItem(ctx context.Context, id int) (budget_plan.BudgetItem, error) {
+	if !s[id] {
+		return budget_plan.BudgetItem{}, budget_plan.ErrBudgetPlanItemNotFound
+	}
+	return budget_plan.BudgetItem{Id: id}, nil
+}
+
+type testEnv struct {
+	service     *ServiceImpl
+	repo        *RepositoryStub
+	credentials *credentials.ServiceImpl
+	client      *clientStub
+	calendar    *calendarStub
+	eventBus    *event_bus.EventBus
+	ctx         context.Context
+}
+
+func setupServiceTest(t *testing.T) testEnv {
+	t.Helper()
+	repo := NewRepositoryStub()
+	client := &clientStub{}
+	cal := &calendarStub{events: make(map[string]calendar.Event)}
+	eventBus := event_bus.NewEventBus()
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	cipher, err := credentials.NewCipher(base64.StdEncoding.EncodeToString(key))
+	require.NoError(t, err)
+	credentialsService := credentials.NewService(credentials.NewRepositoryStub(), cipher, eventBus)
+	service := NewService(repo, client, cal, usersStub{testUser.Id: testUser},
+		budgetItemsStub{10: true, 11: true, 12: true}, credentialsService, eventBus)
+	service.clock = &utils.MockClock{FixedNow: now}
+	return testEnv{
+		service:     service,
+		repo:        repo,
+		credentials: credentialsService,
+		client:      client,
+		calendar:    cal,
+		eventBus:    eventBus,
+		ctx:         user.WithUser(context.Background(), testUser),
+	}
+}
+
+func (env testEnv) connect(t *testing.T) {
+	t.Helper()
+	_, err := env.service.StoreConfiguration(env.ctx, Configuration{ApiToken: "token", Enabled: true})
+	require.NoError(t, err)
+	err = env.service.StoreMappings(env.ctx, []Mapping{
+		{TogglProjectId: workProject, TogglTag: "meeting", BudgetItemId: 12},
+		{TogglProjectId: workProject, BudgetItemId: 11},
+		{TogglProjectId: readingProject, BudgetItemId: 10},
+	})
+	require.NoError(t, err)
+}
+
+func entry(id int64, projectId int64, start time.Time, duration time.Duration, tags ...string) TimeEntry {
+	stop := start.Add(duration)
+	return TimeEntry{
+		Id:          id,
+		WorkspaceId: workspaceId,
+		ProjectId:   projectId,
+		Description: fmt.Sprintf("entry %d", id),
+		Tags:        tags,
+		Start:       start,
+		Stop:        &stop,
+		UpdatedAt:   stop,
+	}
+}
+
+func (env testEnv) eventOf(t *testing.T, togglEntryId int64) calendar.Event {
+	t.Helper()
+	imported, err := env.repo.GetImportedEntry(env.ctx, testUser.Id, togglEntryId)
+	require.NoError(t, err)
+	require.NotNil(t, imported)
+	require.Len(t, imported.EventUids, 1)
+	event, ok := env.calendar.events[imported.EventUids[0]]
+	require.True(t, ok)
+	return event
+}
+
+func TestStoreConfiguration(t *testing.T) {
+	t.Run("should select the default workspace", func(t *testing.T) {
+		env := setupServiceTest(t)
+
+		config, err := env.service.StoreConfiguration(env.ctx, Configuration{ApiToken: "token", Enabled: true})
+
+		require.NoError(t, err)
+		assert.Equal(t, workspaceId, config.WorkspaceId)
+		assert.Equal(t, testUser.Id, config.UserId)
+	})
+
+	t.Run("should keep the stored token when none is given", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.connect(t)
+
+		config, err := env.service.StoreConfiguration(env.ctx, Configuration{Enabled: false})
+
+		require.NoError(t, err)
+		assert.Equal(t, "token", config.ApiToken)
+		assert.False(t, config.Enabled)
+	})
+
+	t.Run("should reject a missing or invalid token", func(t *testing.T) {
+		env := setupServiceTest(t)
+
+		_, err := env.service.StoreConfiguration(env.ctx, Configuration{})
+		assert.ErrorIs(t, err, ErrInvalidConfiguration)
+
+		env.client.rejectToken = true
+		_, err = env.service.StoreConfiguration(env.ctx, Configuration{ApiToken: "invalid"})
+		assert.ErrorIs(t, err, ErrInvalidConfiguration)
+	})
+
+	t.Run("should keep the token in the credentials store", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.connect(t)
+
+		stored, err := env.repo.GetConfiguration(env.ctx, testUser.Id)
+		require.NoError(t, err)
+		assert.Empty(t, stored.ApiToken)
+		token, err := env.credentials.GetToken(env.ctx, credentials.Toggl, testUser.Id)
+		require.NoError(t, err)
+		assert.Equal(t, "token", token.AccessToken)
+	})
+}
+
+func TestDeleteConfiguration(t *testing.T) {
+	t.Run("should revoke the token", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.connect(t)
+
+		err := env.service.DeleteConfiguration(env.ctx)
+
+		require.NoError(t, err)
+		token, err := env.credentials.GetToken(env.ctx, credentials.Toggl, testUser.Id)
+		require.NoError(t, err)
+		assert.Nil(t, token)
+		_, err = env.service.GetConfiguration(env.ctx)
+		assert.ErrorIs(t, err, ErrNotConnected)
+	})
+
+	t.Run("should not be connected once all tokens are revoked", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.connect(t)
+
+		err := env.credentials.RevokeAll(env.ctx)
+
+		require.NoError(t, err)
+		_, err = env.service.GetConfiguration(env.ctx)
+		assert.ErrorIs(t, err, ErrNotConnected)
+	})
+}
+
+func TestStoreMappings_Validation(t *testing.T) {
+	env := setupServiceTest(t)
+
+	err := env.service.StoreMappings(env.ctx, []Mapping{{TogglProjectId: workProject}})
+	assert.ErrorIs(t, err, ErrNotConnected)
+
+	env.connect(t)
+	err = env.service.StoreMappings(env.ctx, []Mapping{{TogglProjectId: workProject}})
+	assert.ErrorIs(t, err, ErrInvalidConfiguration)
+
+	// Budget item of another user
+	err = env.service.StoreMappings(env.ctx, []Mapping{{TogglProjectId: workProject, BudgetItemId: 99}})
+	assert.ErrorIs(t, err, ErrInvalidConfiguration)
+	mappings, err := env.service.GetMappings(env.ctx)
+	require.NoError(t, err)
+	assert.Len(t, mappings, 3)
+}
+
+func TestImport(t *testing.T) {
+	day := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+
+	t.Run("should create events of the mapped budget items", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.connect(t)
+		env.client.entries = []TimeEntry{
+			entry(1, readingProject, day.Add(8*time.Hour), time.Hour),
+			entry(2, workProject, day.Add(9*time.Hour), 2*time.Hour),
+			entry(3, workProject, day.Add(11*time.Hour), time.Hour, "meeting"),
+			entry(4, 99, day.Add(12*time.Hour), time.Hour),
+		}
+
+		result, err := env.service.Import(env.ctx, day, day.AddDate(0, 0, 1))
+
+		require.NoError(t, err)
+		assert.Equal(t, ImportResult{Created: 3, Skipped: 1}, result)
+		assert.Equal(t, 10, env.eventOf(t, 1).Metadata.BudgetItemId)
+		assert.Equal(t, 11, env.eventOf(t, 2).Metadata.BudgetItemId)
+		assert.Equal(t, 12, env.eventOf(t, 3).Metadata.BudgetItemId)
+		assert.Equal(t, "entry 3", env.eventOf(t, 3).Metadata.Notes)
+		assert.True(t, env.eventOf(t, 2).EndTime.Equal(day.Add(11*time.Hour)))
+	})
+
+	t.Run("should not duplicate entries imported twice", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.connect(t)
+		env.client.entries = []TimeEntry{entry(1, readingProject, day.Add(8*time.Hour), time.Hour)}
+
+		_, err := env.service.Import(env.ctx, day, day.AddDate(0, 0, 1))
+		require.NoError(t, err)
+		result, err := env.service.Import(env.ctx, day, day.AddDate(0, 0, 1))
+
+		require.NoError(t, err)
+		assert.Equal(t, ImportResult{}, result)
+		assert.Len(t, env.calendar.events, 1)
+	})
+
+	t.Run("should skip running entries, other workspaces and entries rejected by the calendar", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.connect(t)
+		running := entry(1, readingProject, day.Add(8*time.Hour), time.Hour)
+		running.Stop = nil
+		otherWorkspace := entry(2, readingProject, day.Add(9*time.Hour), time.Hour)
+		otherWorkspace.WorkspaceId = 200
+		env.calendar.rejectStart = day.Add(10 * time.Hour)
+		env.client.entries = []TimeEntry{
+			running,
+			otherWorkspace,
+			entry(3, readingProject, day.Add(10*time.Hour), time.Hour),
+			entry(4, readingProject, day.Add(11*time.Hour), time.Hour),
+		}
+
+		result, err := env.service.Import(env.ctx, day, day.AddDate(0, 0, 1))
+
+		require.NoError(t, err)
+		assert.Equal(t, ImportResult{Created: 1, Skipped: 3}, result)
+		assert.Len(t, env.calendar.events, 1)
+	})
+
+	t.Run("should reject invalid periods", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.connect(t)
+
+		_, err := env.service.Import(env.ctx, day, day)
+		assert.ErrorIs(t, err, ErrInvalidPeriod)
+		_, err = env.service.Import(env.ctx, day, day.AddDate(2, 0, 0))
+		assert.ErrorIs(t, err, ErrInvalidPeriod)
+	})
+}
+
+func TestSync(t *testing.T) {
+	t.Run("should only set the cursor on the first sync", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.connect(t)
+		env.client.entries = []TimeEntry{entry(1, readingProject, now.Add(-2*time.Hour), time.Hour)}
+
+		result, err := env.service.Sync(env.ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, ImportResult{}, result)
+		assert.Empty(t, env.calendar.events)
+		config, err := env.service.GetConfiguration(env.ctx)
+		require.NoError(t, err)
+		require.NotNil(t, config.SyncedUntil)
+		assert.True(t, config.SyncedUntil.Equal(now))
+	})
+
+	t.Run("should import, update and delete entries modified since the cursor", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.connect(t)
+		start := now.Add(-4 * time.Hour)
+		require.NoError(t, env.repo.UpdateSyncCursor(env.ctx, testUser.Id, start, ""))
+		kept := entry(1, readingProject, start, time.Hour)
+		modified := entry(2, readingProject, start.Add(time.Hour), time.Hour)
+		deleted := entry(3, readingProject, start.Add(2*time.Hour), time.Hour)
+		env.client.entries = []TimeEntry{kept, modified, deleted}
+		_, err := env.service.Sync(env.ctx)
+		require.NoError(t, err)
+		require.Len(t, env.calendar.events, 3)
+
+		later := now.Add(time.Hour)
+		env.service.clock = &utils.MockClock{FixedNow: later}
+		modified.ProjectId = workProject
+		modified.UpdatedAt = now.Add(10 * time.Minute)
+		deleted.DeletedAt = &modified.UpdatedAt
+		deleted.UpdatedAt = modified.UpdatedAt
+		env.client.entries = []TimeEntry{kept, modified, deleted}
+
+		result, err := env.service.Sync(env.ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, ImportResult{Updated: 1, Deleted: 1}, result)
+		assert.Equal(t, []time.Time{start, now}, env.client.since)
+		assert.Len(t, env.calendar.events, 2)
+		assert.Equal(t, 11, env.eventOf(t, 2).Metadata.BudgetItemId)
+		imported, err := env.repo.GetImportedEntry(env.ctx, testUser.Id, 3)
+		require.NoError(t, err)
+		assert.Nil(t, imported)
+	})
+
+	t.Run("should keep the imported events when the calendar rejects a change", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.connect(t)
+		start := now.Add(-4 * time.Hour)
+		require.NoError(t, env.repo.UpdateSyncCursor(env.ctx, testUser.Id, start, ""))
+		modified := entry(1, readingProject, start, time.Hour)
+		env.client.entries = []TimeEntry{modified}
+		_, err := env.service.Sync(env.ctx)
+		require.NoError(t, err)
+		original := env.eventOf(t, 1)
+
+		env.service.clock = &utils.MockClock{FixedNow: now.Add(time.Hour)}
+		modified.Start = start.Add(-time.Hour)
+		modified.UpdatedAt = now.Add(10 * time.Minute)
+		env.calendar.rejectStart = modified.Start
+		env.client.entries = []TimeEntry{modified}
+
+		result, err := env.service.Sync(env.ctx)
+
+		require.NoError(t, err)
+		assert.Equal(t, ImportResult{Skipped: 1}, result)
+		assert.Equal(t, original, env.eventOf(t, 1))
+	})
+
+	t.Run("should keep the cursor and store the error when Toggl fails", func(t *testing.T) {
+		env := setupServiceTest(t)
+		env.connect(t)
+		cursor := now.Add(-time.Hour)
+		require.NoError(t, env.repo.UpdateSyncCursor(env.ctx, testUser.Id, cursor, ""))
+		env.client.rejectToken = true
+
+		err := env.service.SyncAll(context.Background(), now)
+
+		require.NoError(t, err)
+		config, err := env.service.GetConfiguration(env.ctx)
+		require.NoError(t, err)
+		assert.True(t, config.SyncedUntil.Equal(cursor))
+		assert.NotEmpty(t, config.LastError)
+	})
+}
+
+func TestBudgetItemDeletedRemovesMappings(t *testing.T) {
+	env := setupServiceTest(t)
+	env.connect(t)
+
+	err := env.eventBus.Publish(event_bus.NewEvent(env.ctx, "budget_plan.item.deleted", event_bus.BudgetPlanItemDeleted{Id: 11, PlanId: 1}))
+	require.NoError(t, err)
+
+	mappings, err := env.service.GetMappings(env.ctx)
+	require.NoError(t, err)
+	require.Len(t, mappings, 2)
+	assert.Equal(t, 12, mappings[0].BudgetItemId)
+	assert.Equal(t, 10, mappings[1].BudgetItemId)
+}
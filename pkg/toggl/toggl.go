@@ -0,0 +1,73 @@
+package toggl
+
+import (
+	"slices"
+	"time"
+)
+
+// Configuration connects a user's Toggl Track account. Time entries of the workspace are imported as calendar
+// events of the budget items they are mapped to.
+type Configuration struct {
+	UserId int
+	// ApiToken is kept in the credentials store, not with the configuration.
+	ApiToken    string
+	WorkspaceId int64
+	// Enabled turns the periodic sync on, historical imports work regardless.
+	Enabled bool
+	// SyncedUntil is the sync cursor, time entries modified after it are imported by the next sync.
+	SyncedUntil *time.Time
+	// LastError of the last sync, empty when it succeeded.
+	LastError string
+}
+
+// Mapping assigns time entries to a budget item. An empty project or tag matches any, the first matching
+// mapping by position wins.
+type Mapping struct {
+	TogglProjectId int64
+	TogglTag       string
+	BudgetItemId   int
+	Position       int
+}
+
+func (m Mapping) Matches(entry TimeEntry) bool {
+	if m.TogglProjectId != 0 && m.TogglProjectId != entry.ProjectId {
+		return false
+	}
+	return m.TogglTag == "" || slices.Contains(entry.Tags, m.TogglTag)
+}
+
+// ImportedEntry links a Toggl time entry to the calendar events created from it. An entry can result
+// in several events when it crosses midnight.
+type ImportedEntry struct {
+	TogglEntryId int64
+	EventUids    []string
+	// TogglUpdatedAt is the modification time of the entry when it was imported.
+	TogglUpdatedAt time.Time
+}
+
+type Project struct {
+	Id     int64
+	Name   string
+	Active bool
+}
+
+type TimeEntry struct {
+	Id          int64
+	WorkspaceId int64
+	ProjectId   int64
+	Description string
+	Tags        []string
+	Start       time.Time
+	// Stop is nil for the running entry.
+	Stop      *time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time
+}
+
+type ImportResult struct {
+	Created int
+	Updated int
+	Deleted int
+	// Skipped entries are running, unmapped, from another workspace or rejected by the calendar.
+	Skipped int
+}
@@ -0,0 +1,71 @@
+package user
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// DefaultLocale is used for users who have not chosen a locale.
+const DefaultLocale = "en-US"
+
+// DurationFormat defines how durations are written in exports, reports and emails.
+type DurationFormat string
+
+const (
+	// DurationHoursMinutes - hours and minutes, e.g. 1:30
+	DurationHoursMinutes DurationFormat = "hh_mm"
+	// DurationDecimalHours - hours as a decimal number in the user's locale, e.g. 1.50 or 1,50
+	DurationDecimalHours DurationFormat = "decimal"
+)
+
+func (f DurationFormat) IsValid() bool {
+	return f == DurationHoursMinutes || f == DurationDecimalHours
+}
+
+// IsValidLocale reports whether the locale is a well-formed BCP 47 language tag, e.g. "en-US" or "pl".
+func IsValidLocale(locale string) bool {
+	_, err := language.Parse(locale)
+	return err == nil
+}
+
+// Formatter writes numbers and durations according to the user's locale and duration format settings.
+type Formatter struct {
+	printer        *message.Printer
+	durationFormat DurationFormat
+}
+
+func NewFormatter(settings Settings) Formatter {
+	tag, err := language.Parse(settings.Locale)
+	if err != nil {
+		tag = language.MustParse(DefaultLocale)
+	}
+	durationFormat := settings.DurationFormat
+	if !durationFormat.IsValid() {
+		durationFormat = DurationHoursMinutes
+	}
+	return Formatter{printer: message.NewPrinter(tag), durationFormat: durationFormat}
+}
+
+// Duration formats the duration in the user's duration format, e.g. "1:30" or "1.50".
+// Durations are rounded to seconds first, as parts of events split at midnight end a nanosecond before it.
+func (f Formatter) Duration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if f.durationFormat == DurationDecimalHours {
+		return f.Decimal(d.Hours(), 2)
+	}
+	return fmt.Sprintf("%d:%02d", int(d/time.Hour), int((d%time.Hour)/time.Minute))
+}
+
+// Decimal formats the number with the given number of decimal places and the separators of the user's locale.
+func (f Formatter) Decimal(value float64, scale int) string {
+	return f.printer.Sprint(number.Decimal(value, number.Scale(scale)))
+}
+
+// DecimalHours reports whether durations should be written as decimal hours.
+func (f Formatter) DecimalHours() bool {
+	return f.durationFormat == DurationDecimalHours
+}
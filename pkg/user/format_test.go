@@ -0,0 +1,32 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatter_Duration(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		want     string
+	}{
+		{"defaults to hours and minutes", Settings{}, "1:30"},
+		{"decimal hours in English", Settings{Locale: "en-US", DurationFormat: DurationDecimalHours}, "1.50"},
+		{"decimal hours in Polish", Settings{Locale: "pl-PL", DurationFormat: DurationDecimalHours}, "1,50"},
+		{"invalid locale falls back to English", Settings{Locale: "not a locale", DurationFormat: DurationDecimalHours}, "1.50"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NewFormatter(tt.settings).Duration(90*time.Minute-time.Nanosecond))
+		})
+	}
+}
+
+func TestIsValidLocale(t *testing.T) {
+	assert.True(t, IsValidLocale("pl-PL"))
+	assert.True(t, IsValidLocale("en"))
+	assert.False(t, IsValidLocale("not a locale"))
+}
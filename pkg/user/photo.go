@@ -0,0 +1,212 @@
+package user
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+var ErrInvalidPhoto = errors.New("invalid photo")
+
+type PhotoSize string
+
+const (
+	PhotoSizeFull      PhotoSize = "full"
+	PhotoSizeThumbnail PhotoSize = "thumbnail"
+)
+
+func (s PhotoSize) IsValid() bool {
+	return s == PhotoSizeFull || s == PhotoSizeThumbnail
+}
+
+// maxPhotoDimensions holds the longest side of each stored variant in pixels.
+var maxPhotoDimensions = map[PhotoSize]int{
+	PhotoSizeFull:      1024,
+	PhotoSizeThumbnail: 128,
+}
+
+// maxSourcePixels protects the server from decoding huge images, a 3MB upload can declare any dimensions.
+const maxSourcePixels = 40_000_000
+
+const photoJpegQuality = 85
+
+var allowedPhotoTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// processPhoto validates the uploaded image and re-encodes it as JPEG in all the standard sizes. Re-encoding drops
+// EXIF and any other metadata, so the EXIF orientation is applied to the pixels first.
+func processPhoto(raw []byte) (map[PhotoSize][]byte, error) {
+	contentType := http.DetectContentType(raw)
+	if !allowedPhotoTypes[contentType] {
+		return nil, fmt.Errorf("%w: unsupported content type %s", ErrInvalidPhoto, contentType)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPhoto, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxSourcePixels {
+		return nil, fmt.Errorf("%w: unsupported dimensions %dx%d", ErrInvalidPhoto, config.Width, config.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPhoto, err)
+	}
+	if contentType == "image/jpeg" {
+		img = applyOrientation(img, exifOrientation(raw))
+	}
+
+	variants := make(map[PhotoSize][]byte, len(maxPhotoDimensions))
+	for size, maxDimension := range maxPhotoDimensions {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, fitInto(img, maxDimension), &jpeg.Options{Quality: photoJpegQuality}); err != nil {
+			return nil, fmt.Errorf("failed to encode %s photo: %w", size, err)
+		}
+		variants[size] = buf.Bytes()
+	}
+	return variants, nil
+}
+
+// fitInto scales the image down, keeping its aspect ratio, so its longest side is at most maxDimension.
+// Each target pixel is the average of the source pixels it covers.
+func fitInto(src image.Image, maxDimension int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxDimension && height <= maxDimension {
+		return src
+	}
+	targetWidth, targetHeight := maxDimension, maxDimension
+	if width > height {
+		targetHeight = max(1, height*maxDimension/width)
+	} else {
+		targetWidth = max(1, width*maxDimension/height)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+	for y := 0; y < targetHeight; y++ {
+		y0 := bounds.Min.Y + y*height/targetHeight
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*height/targetHeight)
+		for x := 0; x < targetWidth; x++ {
+			x0 := bounds.Min.X + x*width/targetWidth
+			x1 := max(x0+1, bounds.Min.X+(x+1)*width/targetWidth)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// exifOrientation returns the orientation tag (1-8) of a JPEG, 1 when it has none.
+func exifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	offset := 2
+	for offset+4 <= len(data) {
+		if data[offset] != 0xFF {
+			return 1
+		}
+		marker := data[offset+1]
+		segmentLength := int(binary.BigEndian.Uint16(data[offset+2:]))
+		if marker == 0xDA || segmentLength < 2 || offset+2+segmentLength > len(data) {
+			// Start of scan, no more metadata segments
+			return 1
+		}
+		segment := data[offset+4 : offset+2+segmentLength]
+		if marker == 0xE1 && len(segment) >= 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+		offset += 2 + segmentLength
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifdOffset := int(order.Uint32(tiff[4:]))
+	if ifdOffset+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifdOffset:]))
+	for i := 0; i < entries; i++ {
+		entry := ifdOffset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// applyOrientation transforms the image so it is displayed upright without the EXIF orientation tag.
+func applyOrientation(src image.Image, orientation int) image.Image {
+	if orientation <= 1 {
+		return src
+	}
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	// Orientations 5-8 swap the width and height
+	dstWidth, dstHeight := width, height
+	if orientation >= 5 {
+		dstWidth, dstHeight = height, width
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = width-1-x, y
+			case 3:
+				dx, dy = width-1-x, height-1-y
+			case 4:
+				dx, dy = x, height-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = height-1-y, x
+			case 7:
+				dx, dy = height-1-y, width-1-x
+			case 8:
+				dx, dy = y, width-1-x
+			}
+			dst.Set(dx, dy, src.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return dst
+}
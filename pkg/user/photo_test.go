@@ -0,0 +1,121 @@
+package user
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePng(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// withExifOrientation inserts an EXIF segment with the orientation tag right after the JPEG start of image marker.
+func withExifOrientation(jpegBytes []byte, orientation byte) []byte {
+	tiff := []byte{
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, // big endian header, IFD0 at offset 8
+		0x00, 0x01, // one entry
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, orientation, 0x00, 0x00, // orientation, SHORT
+		0x00, 0x00, 0x00, 0x00, // no next IFD
+	}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	length := len(payload) + 2
+	segment := append([]byte{0xFF, 0xE1, byte(length >> 8), byte(length)}, payload...)
+	result := append([]byte{}, jpegBytes[:2]...)
+	result = append(result, segment...)
+	return append(result, jpegBytes[2:]...)
+}
+
+func decodeSize(t *testing.T, data []byte) (int, int) {
+	t.Helper()
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	return config.Width, config.Height
+}
+
+func TestProcessPhoto(t *testing.T) {
+	t.Run("should resize to standard sizes keeping aspect ratio", func(t *testing.T) {
+		// given
+		raw := encodePng(t, 2048, 1024)
+
+		// when
+		variants, err := processPhoto(raw)
+
+		// then
+		require.NoError(t, err)
+		width, height := decodeSize(t, variants[PhotoSizeFull])
+		assert.Equal(t, 1024, width)
+		assert.Equal(t, 512, height)
+		width, height = decodeSize(t, variants[PhotoSizeThumbnail])
+		assert.Equal(t, 128, width)
+		assert.Equal(t, 64, height)
+	})
+
+	t.Run("should not enlarge small photo", func(t *testing.T) {
+		// given
+		raw := encodePng(t, 100, 80)
+
+		// when
+		variants, err := processPhoto(raw)
+
+		// then
+		require.NoError(t, err)
+		width, height := decodeSize(t, variants[PhotoSizeFull])
+		assert.Equal(t, 100, width)
+		assert.Equal(t, 80, height)
+	})
+
+	t.Run("should apply and strip EXIF orientation", func(t *testing.T) {
+		// given
+		img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+		var buf bytes.Buffer
+		require.NoError(t, jpeg.Encode(&buf, img, nil))
+		raw := withExifOrientation(buf.Bytes(), 6)
+		require.Equal(t, 6, exifOrientation(raw))
+
+		// when
+		variants, err := processPhoto(raw)
+
+		// then
+		require.NoError(t, err)
+		width, height := decodeSize(t, variants[PhotoSizeFull])
+		assert.Equal(t, 20, width)
+		assert.Equal(t, 40, height)
+		assert.Equal(t, 1, exifOrientation(variants[PhotoSizeFull]))
+	})
+
+	t.Run("should reject data that is not an image", func(t *testing.T) {
+		// when
+		_, err := processPhoto([]byte("<html><body>not an image</body></html>"))
+
+		// then
+		assert.ErrorIs(t, err, ErrInvalidPhoto)
+	})
+
+	t.Run("should reject truncated image", func(t *testing.T) {
+		// given
+		raw := encodePng(t, 50, 50)
+
+		// when
+		_, err := processPhoto(raw[:len(raw)/2])
+
+		// then
+		assert.ErrorIs(t, err, ErrInvalidPhoto)
+	})
+}
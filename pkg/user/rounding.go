@@ -0,0 +1,83 @@
+package user
+
+import (
+	"time"
+)
+
+// RoundingMode defines which way tracked durations are rounded to the rounding increment.
+type RoundingMode string
+
+const (
+	RoundUp      RoundingMode = "up"
+	RoundNearest RoundingMode = "nearest"
+	RoundDown    RoundingMode = "down"
+)
+
+// MaxRoundingIncrement and MaxMinimumBlock limit the rounding settings.
+const (
+	MaxRoundingIncrement = time.Hour
+	MaxMinimumBlock      = 8 * time.Hour
+)
+
+func (m RoundingMode) IsValid() bool {
+	return m == RoundUp || m == RoundNearest || m == RoundDown
+}
+
+// Rounding defines how tracked durations are rounded when an event is finished and in exported reports.
+// It never changes the stored times of events.
+type Rounding struct {
+	// Increment the durations are rounded to, e.g. 15 minutes. 0 disables rounding.
+	Increment time.Duration
+	Mode      RoundingMode
+	// MinimumBlock is the shortest rounded duration, e.g. 30 minutes billed for a 5 minute call. 0 means no minimum.
+	MinimumBlock time.Duration
+}
+
+func (r Rounding) IsEnabled() bool {
+	return r.Increment > 0 || r.MinimumBlock > 0
+}
+
+// Apply returns the rounded duration. Durations are rounded to seconds first, as parts of events split at midnight
+// end a nanosecond before it.
+func (r Rounding) Apply(d time.Duration) time.Duration {
+	d = d.Round(time.Second)
+	if d <= 0 {
+		return d
+	}
+	if r.Increment > 0 {
+		switch r.Mode {
+		case RoundUp:
+			if remainder := d % r.Increment; remainder != 0 {
+				d += r.Increment - remainder
+			}
+		case RoundDown:
+			d -= d % r.Increment
+		default:
+			d = d.Round(r.Increment)
+		}
+	}
+	if d < r.MinimumBlock {
+		d = r.MinimumBlock
+	}
+	return d
+}
+
+// ApplyToParts rounds the parts of one event, e.g. the ones split at midnight, as a whole: their total is rounded once,
+// so an event split in two is not rounded up twice, nor does it get the minimum block twice. The rounded durations
+// of the parts are returned at their index, the difference made by rounding goes to the last parts.
+func (r Rounding) ApplyToParts(parts []time.Duration) []time.Duration {
+	rounded := make([]time.Duration, len(parts))
+	var total time.Duration
+	for i, part := range parts {
+		rounded[i] = max(part.Round(time.Second), 0)
+		total += rounded[i]
+	}
+	difference := r.Apply(total) - total
+	for i := len(rounded) - 1; i >= 0 && difference != 0; i-- {
+		// Rounding down takes from the last parts, not making any of them negative
+		change := max(difference, -rounded[i])
+		rounded[i] += change
+		difference -= change
+	}
+	return rounded
+}
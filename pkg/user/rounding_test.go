@@ -0,0 +1,54 @@
+package user
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRounding_Apply(t *testing.T) {
+	tests := []struct {
+		name     string
+		rounding Rounding
+		duration time.Duration
+		want     time.Duration
+	}{
+		{"disabled", Rounding{}, 17 * time.Minute, 17 * time.Minute},
+		{"up", Rounding{Increment: 15 * time.Minute, Mode: RoundUp}, 16 * time.Minute, 30 * time.Minute},
+		{"up keeps exact multiples", Rounding{Increment: 15 * time.Minute, Mode: RoundUp}, 30*time.Minute - time.Nanosecond, 30 * time.Minute},
+		{"nearest", Rounding{Increment: 5 * time.Minute, Mode: RoundNearest}, 12 * time.Minute, 10 * time.Minute},
+		{"down", Rounding{Increment: 15 * time.Minute, Mode: RoundDown}, 29 * time.Minute, 15 * time.Minute},
+		{"minimum block", Rounding{Increment: 5 * time.Minute, Mode: RoundDown, MinimumBlock: 30 * time.Minute}, 3 * time.Minute, 30 * time.Minute},
+		{"minimum block without increment", Rounding{MinimumBlock: 15 * time.Minute}, 40 * time.Minute, 40 * time.Minute},
+		{"zero stays zero", Rounding{Increment: 15 * time.Minute, Mode: RoundUp, MinimumBlock: 15 * time.Minute}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.rounding.Apply(tt.duration))
+		})
+	}
+}
+
+func TestRounding_ApplyToParts(t *testing.T) {
+	tests := []struct {
+		name     string
+		rounding Rounding
+		parts    []time.Duration
+		want     []time.Duration
+	}{
+		{"rounded up once", Rounding{Increment: 15 * time.Minute, Mode: RoundUp},
+			[]time.Duration{20 * time.Minute, 20*time.Minute - time.Nanosecond}, []time.Duration{20 * time.Minute, 25 * time.Minute}},
+		{"minimum block once", Rounding{MinimumBlock: 30 * time.Minute},
+			[]time.Duration{5 * time.Minute, 10 * time.Minute}, []time.Duration{5 * time.Minute, 25 * time.Minute}},
+		{"rounded down from the last parts", Rounding{Increment: 30 * time.Minute, Mode: RoundDown},
+			[]time.Duration{40 * time.Minute, 10 * time.Minute}, []time.Duration{30 * time.Minute, 0}},
+		{"single part", Rounding{Increment: 15 * time.Minute, Mode: RoundUp},
+			[]time.Duration{16 * time.Minute}, []time.Duration{30 * time.Minute}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.rounding.ApplyToParts(tt.parts))
+		})
+	}
+}
@@ -71,3 +71,13 @@ func (s *StubUserRepository) IsUsernameAvailable(ctx context.Context, username s
 	}
 	return true, nil
 }
+
+func (s *StubUserRepository) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	user, ok := s.data[id]
+	if !ok {
+		return errors.New("user not found")
+	}
+	user.Disabled = disabled
+	s.data[id] = user
+	return nil
+}
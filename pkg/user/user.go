@@ -9,6 +9,8 @@ type User struct {
 	DisplayName string
 	PhotoUrl    string
 	Settings    Settings
+	// Disabled users keep their data, but cannot sign in and are skipped by background jobs
+	Disabled bool
 }
 
 type EventCalendarType string
@@ -16,16 +18,95 @@ type EventCalendarType string
 const (
 	KlokkuCalendar EventCalendarType = "klokku"
 	GoogleCalendar EventCalendarType = "google"
+	// OutlookCalendar keeps the events in a Microsoft 365 / Outlook calendar, accessed through Microsoft Graph.
+	OutlookCalendar EventCalendarType = "outlook"
 )
 
+// ShortEventHandling defines what happens with an event shorter than the user's threshold
+// when it is finished and IgnoreShortEvents is enabled.
+type ShortEventHandling string
+
+const (
+	// ShortEventMergeNext - the short event time is counted into the next event, which starts at the short event start.
+	ShortEventMergeNext ShortEventHandling = "merge_next"
+	// ShortEventMergePrevious - the short event time is counted into the previous adjacent calendar event.
+	ShortEventMergePrevious ShortEventHandling = "merge_previous"
+	// ShortEventDiscard - the short event time is not counted at all.
+	ShortEventDiscard ShortEventHandling = "discard"
+)
+
+const DefaultShortEventThreshold = time.Minute
+
+const (
+	DefaultDailySleep = 8 * time.Hour
+	MaxDailySleep     = 16 * time.Hour
+)
+
+func (h ShortEventHandling) IsValid() bool {
+	switch h {
+	case ShortEventMergeNext, ShortEventMergePrevious, ShortEventDiscard:
+		return true
+	}
+	return false
+}
+
 type Settings struct {
 	Timezone          string
 	WeekFirstDay      time.Weekday
 	EventCalendarType EventCalendarType
 	GoogleCalendar    GoogleCalendarSettings
+	OutlookCalendar   OutlookCalendarSettings
 	IgnoreShortEvents bool
+	// ShortEventThreshold - events shorter than this are handled according to ShortEventHandling
+	ShortEventThreshold time.Duration
+	ShortEventHandling  ShortEventHandling
+	StrictCalendar      bool
+	DiscardIdleTime     bool
+	// KeepCrossMidnightEvents - events spanning midnight are stored whole instead of being split per day
+	KeepCrossMidnightEvents bool
+	// EventSummaryTemplate - template of calendar event summaries, e.g. "{icon} {name}". Empty means just the name.
+	EventSummaryTemplate string
+	// RenamePropagation - whether renaming a budget item rewrites past weekly plans and calendar events
+	RenamePropagation RenamePropagation
+	// WeeklyDigest - the weekly summary email, sent only to users who opted in
+	WeeklyDigest WeeklyDigestSettings
+	// Locale - BCP 47 language tag used to format numbers in exports, reports and emails, e.g. "pl-PL"
+	Locale string
+	// DurationFormat - how durations are written in exports, reports and emails
+	DurationFormat DurationFormat
+	// DayStartHour - hour (0-23) at which the user's day starts, time before it counts into the previous day
+	DayStartHour int
+	// DefaultBudgetItemId - budget item tracked when an event is started without choosing one, 0 when not set
+	DefaultBudgetItemId int
+	// Rounding - how tracked durations are rounded when an event is finished and in exports
+	Rounding Rounding
+	// DailySleep - time asleep every day, which is not available for planned work
+	DailySleep time.Duration
+}
+
+type WeeklyDigestSettings struct {
+	Enabled bool
+	// Email is the address the digest is sent to
+	Email string
+}
+
+// RenamePropagation defines what happens to the past when a budget item is renamed (or its icon or color change).
+// Current and future weeks and events always follow the budget item.
+type RenamePropagation struct {
+	// RewritePastWeeklyPlans - items of past weekly plans are updated, otherwise they stay as they were.
+	RewritePastWeeklyPlans bool
+	// RewritePastEvents - summaries of past calendar events are re-rendered, otherwise they are preserved.
+	RewritePastEvents bool
 }
 
+// DefaultRenamePropagation rewrites the past, which is how renaming worked before it was configurable.
+var DefaultRenamePropagation = RenamePropagation{RewritePastWeeklyPlans: true, RewritePastEvents: true}
+
 type GoogleCalendarSettings struct {
 	CalendarId string
 }
+
+type OutlookCalendarSettings struct {
+	// CalendarId is the Microsoft Graph id of the selected calendar, empty means the user's default calendar.
+	CalendarId string
+}
@@ -0,0 +1,89 @@
+package user
+
+import (
+	"sync"
+	"time"
+
+	"github.com/klokku/klokku/internal/utils"
+)
+
+// defaultCacheTTL is short on purpose - the cache only has to absorb the repeated lookups of a burst of
+// requests, changes made by other instances become visible after at most this long.
+const defaultCacheTTL = 30 * time.Second
+
+type cachedUser struct {
+	user      User
+	expiresAt time.Time
+}
+
+// userCache keeps recently loaded users keyed by user id, with a secondary index by uid used by the auth middleware.
+type userCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	clock    utils.Clock
+	byId     map[int]cachedUser
+	idsByUid map[string]int
+}
+
+func newUserCache(ttl time.Duration, clock utils.Clock) *userCache {
+	return &userCache{
+		ttl:      ttl,
+		clock:    clock,
+		byId:     make(map[int]cachedUser),
+		idsByUid: make(map[string]int),
+	}
+}
+
+func (c *userCache) get(id int) (User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.getLocked(id)
+}
+
+func (c *userCache) getByUid(uid string) (User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id, ok := c.idsByUid[uid]
+	if !ok {
+		return User{}, false
+	}
+	return c.getLocked(id)
+}
+
+func (c *userCache) getLocked(id int) (User, bool) {
+	entry, ok := c.byId[id]
+	if !ok {
+		return User{}, false
+	}
+	if !c.clock.Now().Before(entry.expiresAt) {
+		c.removeLocked(id)
+		return User{}, false
+	}
+	return entry.user, true
+}
+
+func (c *userCache) put(user User) {
+	if user.Id == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(user.Id)
+	c.byId[user.Id] = cachedUser{user: user, expiresAt: c.clock.Now().Add(c.ttl)}
+	if user.Uid != "" {
+		c.idsByUid[user.Uid] = user.Id
+	}
+}
+
+func (c *userCache) invalidate(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(id)
+}
+
+func (c *userCache) removeLocked(id int) {
+	if entry, ok := c.byId[id]; ok {
+		delete(c.idsByUid, entry.user.Uid)
+		delete(c.byId, id)
+	}
+}
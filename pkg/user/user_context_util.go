@@ -35,3 +35,26 @@ func CurrentUser(ctx context.Context) (User, error) {
 func WithUser(ctx context.Context, user User) context.Context {
 	return context.WithValue(ctx, UserKey, user)
 }
+
+// EventUserContext carries the current user to the durable subscribers of the event bus, which handle events in
+// the background.
+type EventUserContext struct {
+	users Service
+}
+
+func NewEventUserContext(users Service) *EventUserContext {
+	return &EventUserContext{users: users}
+}
+
+func (c *EventUserContext) UserId(ctx context.Context) (int, bool) {
+	id, err := CurrentId(ctx)
+	return id, err == nil
+}
+
+func (c *EventUserContext) WithUser(ctx context.Context, userId int) (context.Context, error) {
+	user, err := c.users.GetUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return WithUser(ctx, user), nil
+}
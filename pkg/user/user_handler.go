@@ -1,10 +1,13 @@
 package user
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"net/mail"
 	"strings"
 	"time"
 
@@ -19,6 +22,12 @@ type UserDTO struct {
 	DisplayName string      `json:"displayName"`
 	PhotoUrl    string      `json:"photoUrl"`
 	Settings    SettingsDTO `json:"settings"`
+	// Disabled is read-only, it is changed by an admin through the user status endpoint
+	Disabled bool `json:"disabled"`
+}
+
+type UserStatusDTO struct {
+	Disabled bool `json:"disabled"`
 }
 
 type SettingsDTO struct {
@@ -26,13 +35,65 @@ type SettingsDTO struct {
 	WeekStartDay      string                    `json:"weekStartDay"`
 	EventCalendarType EventCalendarType         `json:"eventCalendarType"`
 	GoogleCalendar    GoogleCalendarSettingsDTO `json:"googleCalendar"`
-	IgnoreShortEvents bool                      `json:"ignoreShortEvents"`
+	// OutlookCalendar is used when EventCalendarType is outlook
+	OutlookCalendar   OutlookCalendarSettingsDTO `json:"outlookCalendar"`
+	IgnoreShortEvents bool                       `json:"ignoreShortEvents"`
+	// ShortEventThreshold in seconds
+	ShortEventThreshold int                `json:"shortEventThreshold"`
+	ShortEventHandling  ShortEventHandling `json:"shortEventHandling" enums:"merge_next,merge_previous,discard"`
+	StrictCalendar      bool               `json:"strictCalendar"`
+	DiscardIdleTime     bool               `json:"discardIdleTime"`
+	// KeepCrossMidnightEvents disables splitting of events spanning midnight into per-day events
+	KeepCrossMidnightEvents bool `json:"keepCrossMidnightEvents"`
+	// EventSummaryTemplate is the template of calendar event summaries, e.g. "{icon} {name}" or "{name} – {note}".
+	// Empty means just the budget item name.
+	EventSummaryTemplate string `json:"eventSummaryTemplate"`
+	// RenamePropagation defines whether renaming a budget item rewrites the past. Both options default to true.
+	RenamePropagation *RenamePropagationDTO `json:"renamePropagation,omitempty"`
+	// WeeklyDigest is the opt-in to the weekly summary email
+	WeeklyDigest WeeklyDigestSettingsDTO `json:"weeklyDigest"`
+	// Locale is a BCP 47 language tag, e.g. "pl-PL", used to format numbers in exports, reports and emails.
+	// Empty means en-US.
+	Locale string `json:"locale"`
+	// DurationFormat of exports, reports and emails, hh_mm by default
+	DurationFormat DurationFormat `json:"durationFormat" enums:"hh_mm,decimal"`
+	// DayStartHour is the hour (0-23) at which the user's day starts, e.g. 4 counts the night into the previous day
+	DayStartHour int `json:"dayStartHour"`
+	// DefaultBudgetItemId is tracked by /api/event/current/start-default, 0 when not set
+	DefaultBudgetItemId int `json:"defaultBudgetItemId"`
+	// Rounding of tracked durations when an event is finished and in exports, the stored times are not rounded
+	Rounding RoundingDTO `json:"rounding"`
+	// DailySleep in seconds is the time asleep every day, not available for planned work. 0 means 8 hours.
+	DailySleep int `json:"dailySleep"`
+}
+
+type RoundingDTO struct {
+	// Increment in seconds the durations are rounded to, e.g. 900 for 15 minutes, 0 disables rounding
+	Increment int          `json:"increment"`
+	Mode      RoundingMode `json:"mode" enums:"up,nearest,down"`
+	// MinimumBlock in seconds is the shortest rounded duration, 0 for none
+	MinimumBlock int `json:"minimumBlock"`
+}
+
+type WeeklyDigestSettingsDTO struct {
+	Enabled bool   `json:"enabled"`
+	Email   string `json:"email"`
+}
+
+type RenamePropagationDTO struct {
+	RewritePastWeeklyPlans bool `json:"rewritePastWeeklyPlans"`
+	RewritePastEvents      bool `json:"rewritePastEvents"`
 }
 
 type GoogleCalendarSettingsDTO struct {
 	CalendarId string `json:"calendarId"`
 }
 
+type OutlookCalendarSettingsDTO struct {
+	// CalendarId of one of the calendars listed by /api/integrations/outlook/calendars, empty for the default one
+	CalendarId string `json:"calendarId"`
+}
+
 type Handler struct {
 	userService Service
 }
@@ -59,60 +120,33 @@ func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	log.Debug("Creating user")
 
 	var user UserDTO
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
-			Error: "Invalid request body format",
-		})
-		if encodeErr != nil {
-			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
-		}
+	if err := rest.DecodeJSON(r, &user); err != nil {
+		rest.WriteBadRequest(w, err)
 		return
 	}
 	log.Tracef("Creating new user: %+v", user)
 
-	if len(user.Username) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
-			Error: "Username is required",
-		})
-		if encodeErr != nil {
-			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
-		}
-		return
-	}
-
-	if len(user.DisplayName) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
-			Error: "Display name is required",
-		})
-		if encodeErr != nil {
-			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
-		}
+	var v rest.Validator
+	validateNames(&v, user)
+	if err := v.Err(); err != nil {
+		rest.WriteBadRequest(w, err)
 		return
 	}
 
 	createdUser, err := h.userService.CreateUser(r.Context(), dtoToUser(user))
 	if err != nil {
 		if errors.Is(err, ErrUserDataInvalid) {
-			w.WriteHeader(http.StatusBadRequest)
-			encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
-				Error: "Invalid user data",
-			})
-			if encodeErr != nil {
-				http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
-			}
+			rest.WriteError(w, http.StatusBadRequest, rest.ErrorResponse{Error: "Invalid user data"})
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 	log.Tracef("Created user: %+v", createdUser)
 
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(userToDTO(&createdUser)); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 }
@@ -124,7 +158,7 @@ func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Success 200 {object} UserDTO
 // @Failure 403 {string} string "User not found"
-// @Failure 404 {string} string "User Not Found"
+// @Failure 404 {object} rest.ErrorResponse "User Not Found"
 // @Router /api/user/current [get]
 // @Security XUserId
 func (h *Handler) CurrentUser(w http.ResponseWriter, r *http.Request) {
@@ -137,13 +171,13 @@ func (h *Handler) CurrentUser(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusNotFound)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(userToDTO(&currentUser)); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 }
@@ -165,50 +199,34 @@ func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	log.Trace("Updating user")
 
 	var user UserDTO
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
-			Error: "Invalid request body format",
-		})
-		if encodeErr != nil {
-			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
-		}
+	if err := rest.DecodeJSON(r, &user); err != nil {
+		rest.WriteBadRequest(w, err)
 		return
 	}
 
 	log.Debug("Updating user: ", user)
-	if len(user.Username) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
-			Error: "Username is required",
-		})
-		if encodeErr != nil {
-			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
-		}
-		return
-	}
-
-	if len(user.DisplayName) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
-			Error: "Display name is required",
-		})
-		if encodeErr != nil {
-			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
-		}
+	var v rest.Validator
+	validateNames(&v, user)
+	validateSettings(&v, user.Settings)
+	if err := v.Err(); err != nil {
+		rest.WriteBadRequest(w, err)
 		return
 	}
 
 	updatedUser, err := h.userService.UpdateUser(r.Context(), dtoToUser(user))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, ErrUserDataInvalid) {
+			rest.WriteBadRequest(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
 		return
 	}
 	log.Debug("Updated user: ", updatedUser)
 
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(userToDTO(&updatedUser)); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 	}
 }
 
@@ -228,25 +246,21 @@ func (h *Handler) IsUsernameAvailable(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	username := vars["username"]
 	log.Debug("Checking availability of username: ", username)
-	if len(username) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
-			Error: "Username is required",
-		})
-		if encodeErr != nil {
-			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
-		}
+	var v rest.Validator
+	v.Check(len(username) > 0, "username", "Username is required", "'username' must not be empty")
+	if err := v.Err(); err != nil {
+		rest.WriteBadRequest(w, err)
 		return
 	}
 
 	isAvailable, err := h.userService.IsUsernameAvailable(r.Context(), username)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(map[string]bool{"available": isAvailable}); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 	}
 }
 
@@ -264,7 +278,7 @@ func (h *Handler) GetAvailableUsers(w http.ResponseWriter, r *http.Request) {
 
 	users, err := h.userService.GetAllUsers(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 
@@ -275,19 +289,20 @@ func (h *Handler) GetAvailableUsers(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(usersDTO); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 	}
 }
 
 // DeleteUser godoc
 // @Summary Delete a user
-// @Description Delete a user by UID
+// @Description Delete a user by UID, requires an admin user
 // @Tags User
 // @Param userUid path string true "User UID"
 // @Success 204 "No Content"
-// @Failure 400 {string} string "Bad Request"
-// @Failure 403 {string} string "User not found"
+// @Failure 400 {object} rest.ErrorResponse "Bad Request"
+// @Failure 403 {string} string "User not found or not an admin"
 // @Router /api/user/{userUid} [delete]
+// @Security XUserId
 func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	log.Trace("Deleting user")
@@ -296,13 +311,13 @@ func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	userUid := vars["userUid"]
 	user, err := h.userService.GetUserByUid(r.Context(), userUid)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		rest.WriteBadRequest(w, err)
 		return
 	}
 	log.Debug("Deleting user with id: ", user.Id)
 	err = h.userService.DeleteUser(r.Context(), user.Id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
@@ -310,7 +325,8 @@ func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 
 // UploadPhoto godoc
 // @Summary Upload user photo
-// @Description Upload a profile photo for the current user (max 3MB)
+// @Description Upload a profile photo for the current user (max 3MB). JPEG, PNG and GIF images are accepted.
+// @Description The photo is stored without metadata as JPEG in full size (up to 1024px) and as a 128px thumbnail.
 // @Tags User
 // @Accept multipart/form-data
 // @Param photo formData file true "User photo"
@@ -329,21 +345,16 @@ func (h *Handler) UploadPhoto(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseMultipartForm(3 << 20)
 	if err != nil {
 		log.Debugf("File is too large: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
-		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
+		rest.WriteError(w, http.StatusBadRequest, rest.ErrorResponse{
 			Error:   "Image is too large",
 			Details: "Maximum size is 3MB. Please try again with a smaller image.",
 		})
-		if encodeErr != nil {
-			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
-			return
-		}
 		return
 	}
 
 	file, header, err := r.FormFile("photo")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		rest.WriteBadRequest(w, err)
 		return
 	}
 	defer file.Close()
@@ -353,13 +364,17 @@ func (h *Handler) UploadPhoto(w http.ResponseWriter, r *http.Request) {
 
 	fileBytes, err := io.ReadAll(file)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		rest.WriteBadRequest(w, err)
 		return
 	}
 
 	err = h.userService.StoreUserPhoto(r.Context(), fileBytes)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, ErrInvalidPhoto) {
+			rest.WriteError(w, http.StatusBadRequest, rest.ErrorResponse{Error: "Image is invalid", Details: err.Error()})
+			return
+		}
+		rest.WriteInternalError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -367,51 +382,67 @@ func (h *Handler) UploadPhoto(w http.ResponseWriter, r *http.Request) {
 
 // GetPhoto godoc
 // @Summary Get user photo
-// @Description Retrieve a user's profile photo. If userUid is provided, gets that user's photo, otherwise gets current user's photo
+// @Description Retrieve a user's profile photo. If userUid is provided, gets that user's photo, otherwise gets current user's photo.
+// @Description The response has an ETag, a request with a matching If-None-Match header gets 304 Not Modified.
 // @Tags User
 // @Produce image/jpeg
 // @Param userUid path string false "User UID (optional)"
+// @Param size query string false "Photo size, full by default" Enums(full, thumbnail)
 // @Success 200 {file} image/jpeg
-// @Failure 400 {string} string "Bad Request"
+// @Success 304 "Not Modified"
+// @Failure 400 {object} rest.ErrorResponse "Bad Request"
 // @Failure 403 {string} string "User not found"
+// @Failure 404 {object} rest.ErrorResponse "User has no photo"
 // @Router /api/user/current/photo [get]
 // @Router /api/user/{userUid}/photo [get]
 func (h *Handler) GetPhoto(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "image/jpeg")
 	log.Trace("Getting user photo")
 
+	size := PhotoSizeFull
+	if value := r.URL.Query().Get("size"); value != "" {
+		size = PhotoSize(value)
+		if !size.IsValid() {
+			rest.WriteBadRequest(w, rest.InvalidField("size", "Invalid photo size", "'size' must be full or thumbnail"))
+			return
+		}
+	}
+
+	var photo []byte
+	var err error
 	vars := mux.Vars(r)
 	userUid := vars["userUid"]
 	if userUid != "" {
 		user, err := h.userService.GetUserByUid(r.Context(), userUid)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		photo, err := h.userService.GetUserPhoto(r.Context(), user.Id)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		w.WriteHeader(http.StatusOK)
-		_, err = w.Write(photo)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			rest.WriteBadRequest(w, err)
 			return
 		}
+		photo, err = h.userService.GetUserPhoto(r.Context(), user.Id, size)
+	} else {
+		photo, err = h.userService.GetCurrentUserPhoto(r.Context(), size)
+	}
+	if err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+	if photo == nil {
+		rest.WriteError(w, http.StatusNotFound, rest.ErrorResponse{Error: "User has no photo"})
 		return
 	}
 
-	photo, err := h.userService.GetCurrentUserPhoto(r.Context())
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	etag := fmt.Sprintf(`"%x"`, sha256.Sum256(photo))
+	w.Header().Set("ETag", etag)
+	// The photo can change at any time, so clients keep it but revalidate with the ETag
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
+	// Photos uploaded before processing was introduced are stored as uploaded
+	w.Header().Set("Content-Type", http.DetectContentType(photo))
 	w.WriteHeader(http.StatusOK)
-	_, err = w.Write(photo)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	if _, err = w.Write(photo); err != nil {
+		log.Errorf("failed to write photo: %v", err)
 	}
 }
 
@@ -429,12 +460,95 @@ func (h *Handler) DeletePhoto(w http.ResponseWriter, r *http.Request) {
 
 	err := h.userService.DeleteUserPhoto(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// SetUserStatus godoc
+// @Summary Disable or enable a user
+// @Description Disable a user without deleting their data, or enable them again, requires an admin user.
+// @Description Disabled users cannot sign in, their sessions and tokens stop working and background jobs skip them.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param userUid path string true "User UID"
+// @Param status body UserStatusDTO true "User status"
+// @Success 200 {object} UserDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found or not an admin"
+// @Failure 404 {object} rest.ErrorResponse "User not found"
+// @Router /api/user/{userUid}/status [put]
+// @Security XUserId
+func (h *Handler) SetUserStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var body UserStatusDTO
+	if err := rest.DecodeJSON(r, &body); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	target, err := h.userService.GetUserByUid(r.Context(), mux.Vars(r)["userUid"])
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			rest.WriteNotFound(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
+		return
+	}
+	if currentId, err := CurrentId(r.Context()); err == nil && currentId == target.Id && body.Disabled {
+		rest.WriteError(w, http.StatusBadRequest, rest.ErrorResponse{Error: "Invalid user", Details: "admins cannot disable themselves"})
+		return
+	}
+
+	updated, err := h.userService.SetUserDisabled(r.Context(), target.Id, body.Disabled)
+	if err != nil {
+		rest.WriteInternalError(w, fmt.Errorf("failed to change status of user %s: %w", target.Uid, err))
+		return
+	}
+	if err := json.NewEncoder(w).Encode(userToDTO(&updated)); err != nil {
+		rest.WriteInternalError(w, err)
+	}
+}
+
+func validateNames(v *rest.Validator, user UserDTO) {
+	v.Check(len(user.Username) > 0, "username", "Username is required", "'username' must not be empty")
+	v.Check(len(user.DisplayName) > 0, "displayName", "Display name is required", "'displayName' must not be empty")
+}
+
+func validateSettings(v *rest.Validator, settings SettingsDTO) {
+	v.Check(settings.ShortEventHandling == "" || settings.ShortEventHandling.IsValid(), "settings.shortEventHandling",
+		"Invalid short event handling", "Short event handling must be one of: merge_next, merge_previous, discard")
+	v.Check(settings.EventSummaryTemplate == "" || strings.Contains(settings.EventSummaryTemplate, "{name}"),
+		"settings.eventSummaryTemplate", "Invalid event summary template",
+		"Event summary template must contain the {name} placeholder")
+	v.Check(settings.Locale == "" || IsValidLocale(settings.Locale), "settings.locale", "Invalid locale",
+		"Locale must be a BCP 47 language tag, e.g. en-US or pl-PL")
+	v.Check(settings.DurationFormat == "" || settings.DurationFormat.IsValid(), "settings.durationFormat",
+		"Invalid duration format", "Duration format must be one of: hh_mm, decimal")
+	v.Check(settings.DayStartHour >= 0 && settings.DayStartHour <= 23, "settings.dayStartHour",
+		"Invalid day start hour", "Day start hour must be between 0 and 23")
+	v.Check(settings.DefaultBudgetItemId >= 0, "settings.defaultBudgetItemId", "Invalid default budget item",
+		"Default budget item id must be a budget item id or 0 for none")
+	v.Check(settings.Rounding.Increment >= 0 && settings.Rounding.Increment <= int(MaxRoundingIncrement.Seconds()),
+		"settings.rounding.increment", "Invalid rounding increment",
+		fmt.Sprintf("Rounding increment must be between 0 and %d seconds", int(MaxRoundingIncrement.Seconds())))
+	v.Check(settings.Rounding.Mode == "" || settings.Rounding.Mode.IsValid(), "settings.rounding.mode",
+		"Invalid rounding mode", "Rounding mode must be one of: up, nearest, down")
+	v.Check(settings.Rounding.MinimumBlock >= 0 && settings.Rounding.MinimumBlock <= int(MaxMinimumBlock.Seconds()),
+		"settings.rounding.minimumBlock", "Invalid minimum block",
+		fmt.Sprintf("Minimum block must be between 0 and %d seconds", int(MaxMinimumBlock.Seconds())))
+	v.Check(settings.DailySleep >= 0 && settings.DailySleep <= int(MaxDailySleep.Seconds()), "settings.dailySleep",
+		"Invalid daily sleep", fmt.Sprintf("Daily sleep must be between 0 and %d seconds", int(MaxDailySleep.Seconds())))
+	if settings.WeeklyDigest.Enabled {
+		_, err := mail.ParseAddress(strings.TrimSpace(settings.WeeklyDigest.Email))
+		v.Check(err == nil, "settings.weeklyDigest.email", "Invalid weekly digest email",
+			"A valid email address is required to enable the weekly digest")
+	}
+}
+
 func userToDTO(user *User) UserDTO {
 	return UserDTO{
 		Uid:         user.Uid,
@@ -442,6 +556,7 @@ func userToDTO(user *User) UserDTO {
 		DisplayName: user.DisplayName,
 		PhotoUrl:    user.PhotoUrl,
 		Settings:    settingsToDTO(user.Settings),
+		Disabled:    user.Disabled,
 	}
 }
 
@@ -453,7 +568,34 @@ func settingsToDTO(settings Settings) SettingsDTO {
 		GoogleCalendar: GoogleCalendarSettingsDTO{
 			CalendarId: settings.GoogleCalendar.CalendarId,
 		},
-		IgnoreShortEvents: settings.IgnoreShortEvents,
+		OutlookCalendar: OutlookCalendarSettingsDTO{
+			CalendarId: settings.OutlookCalendar.CalendarId,
+		},
+		IgnoreShortEvents:       settings.IgnoreShortEvents,
+		ShortEventThreshold:     int(settings.ShortEventThreshold.Seconds()),
+		ShortEventHandling:      settings.ShortEventHandling,
+		StrictCalendar:          settings.StrictCalendar,
+		DiscardIdleTime:         settings.DiscardIdleTime,
+		KeepCrossMidnightEvents: settings.KeepCrossMidnightEvents,
+		EventSummaryTemplate:    settings.EventSummaryTemplate,
+		RenamePropagation: &RenamePropagationDTO{
+			RewritePastWeeklyPlans: settings.RenamePropagation.RewritePastWeeklyPlans,
+			RewritePastEvents:      settings.RenamePropagation.RewritePastEvents,
+		},
+		WeeklyDigest: WeeklyDigestSettingsDTO{
+			Enabled: settings.WeeklyDigest.Enabled,
+			Email:   settings.WeeklyDigest.Email,
+		},
+		Locale:              settings.Locale,
+		DurationFormat:      settings.DurationFormat,
+		DayStartHour:        settings.DayStartHour,
+		DefaultBudgetItemId: settings.DefaultBudgetItemId,
+		Rounding: RoundingDTO{
+			Increment:    int(settings.Rounding.Increment.Seconds()),
+			Mode:         settings.Rounding.Mode,
+			MinimumBlock: int(settings.Rounding.MinimumBlock.Seconds()),
+		},
+		DailySleep: int(settings.DailySleep.Seconds()),
 	}
 }
 
@@ -467,6 +609,31 @@ func dtoToUser(userDTO UserDTO) User {
 }
 
 func dtoToSettings(settingsDTO SettingsDTO) Settings {
+	if settingsDTO.ShortEventThreshold <= 0 {
+		settingsDTO.ShortEventThreshold = int(DefaultShortEventThreshold.Seconds())
+	}
+	if settingsDTO.ShortEventHandling == "" {
+		settingsDTO.ShortEventHandling = ShortEventMergeNext
+	}
+	if settingsDTO.Locale == "" {
+		settingsDTO.Locale = DefaultLocale
+	}
+	if settingsDTO.DurationFormat == "" {
+		settingsDTO.DurationFormat = DurationHoursMinutes
+	}
+	if settingsDTO.Rounding.Mode == "" {
+		settingsDTO.Rounding.Mode = RoundNearest
+	}
+	if settingsDTO.DailySleep <= 0 {
+		settingsDTO.DailySleep = int(DefaultDailySleep.Seconds())
+	}
+	renamePropagation := DefaultRenamePropagation
+	if settingsDTO.RenamePropagation != nil {
+		renamePropagation = RenamePropagation{
+			RewritePastWeeklyPlans: settingsDTO.RenamePropagation.RewritePastWeeklyPlans,
+			RewritePastEvents:      settingsDTO.RenamePropagation.RewritePastEvents,
+		}
+	}
 	return Settings{
 		Timezone:          settingsDTO.Timezone,
 		WeekFirstDay:      stringToWeekday(settingsDTO.WeekStartDay),
@@ -474,7 +641,31 @@ func dtoToSettings(settingsDTO SettingsDTO) Settings {
 		GoogleCalendar: GoogleCalendarSettings{
 			CalendarId: settingsDTO.GoogleCalendar.CalendarId,
 		},
-		IgnoreShortEvents: settingsDTO.IgnoreShortEvents,
+		OutlookCalendar: OutlookCalendarSettings{
+			CalendarId: strings.TrimSpace(settingsDTO.OutlookCalendar.CalendarId),
+		},
+		IgnoreShortEvents:       settingsDTO.IgnoreShortEvents,
+		ShortEventThreshold:     time.Duration(settingsDTO.ShortEventThreshold) * time.Second,
+		ShortEventHandling:      settingsDTO.ShortEventHandling,
+		StrictCalendar:          settingsDTO.StrictCalendar,
+		DiscardIdleTime:         settingsDTO.DiscardIdleTime,
+		KeepCrossMidnightEvents: settingsDTO.KeepCrossMidnightEvents,
+		EventSummaryTemplate:    strings.TrimSpace(settingsDTO.EventSummaryTemplate),
+		RenamePropagation:       renamePropagation,
+		WeeklyDigest: WeeklyDigestSettings{
+			Enabled: settingsDTO.WeeklyDigest.Enabled,
+			Email:   strings.TrimSpace(settingsDTO.WeeklyDigest.Email),
+		},
+		Locale:              settingsDTO.Locale,
+		DurationFormat:      settingsDTO.DurationFormat,
+		DayStartHour:        settingsDTO.DayStartHour,
+		DefaultBudgetItemId: settingsDTO.DefaultBudgetItemId,
+		Rounding: Rounding{
+			Increment:    time.Duration(settingsDTO.Rounding.Increment) * time.Second,
+			Mode:         settingsDTO.Rounding.Mode,
+			MinimumBlock: time.Duration(settingsDTO.Rounding.MinimumBlock) * time.Second,
+		},
+		DailySleep: time.Duration(settingsDTO.DailySleep) * time.Second,
 	}
 }
 
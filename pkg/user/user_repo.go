@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	log "github.com/sirupsen/logrus"
@@ -18,6 +20,7 @@ type Repo interface {
 	DeleteUser(ctx context.Context, id int) error
 	GetAllUsers(ctx context.Context) ([]User, error)
 	IsUsernameAvailable(ctx context.Context, username string) (bool, error)
+	SetDisabled(ctx context.Context, id int, disabled bool) error
 }
 
 type UserRepoImpl struct {
@@ -34,7 +37,8 @@ func (u *UserRepoImpl) CreateUser(ctx context.Context, user User) (int, error) {
 		eventCalendarType = KlokkuCalendar
 	}
 	query := `INSERT INTO users (uid, username, display_name, photo_url, timezone, week_first_day, event_calendar_type, 
-				event_calendar_google_calendar_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+				event_calendar_google_calendar_id, event_calendar_outlook_calendar_id)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
 	var id int
 	err := u.db.QueryRow(ctx, query,
 		user.Uid,
@@ -45,6 +49,7 @@ func (u *UserRepoImpl) CreateUser(ctx context.Context, user User) (int, error) {
 		user.Settings.WeekFirstDay,
 		eventCalendarType,
 		user.Settings.GoogleCalendar.CalendarId,
+		user.Settings.OutlookCalendar.CalendarId,
 	).Scan(&id)
 	if err != nil {
 		log.Errorf("failed to create user: %v", err)
@@ -55,9 +60,18 @@ func (u *UserRepoImpl) CreateUser(ctx context.Context, user User) (int, error) {
 
 func (u *UserRepoImpl) GetUser(ctx context.Context, id int) (User, error) {
 	query := `SELECT id, uid, username, display_name, photo_url, timezone, week_first_day, event_calendar_type,
-				event_calendar_google_calendar_id, ignore_short_events FROM users WHERE id = $1`
+				event_calendar_google_calendar_id, event_calendar_outlook_calendar_id, ignore_short_events, strict_calendar, discard_idle_time,
+				short_event_threshold, short_event_handling, keep_cross_midnight_events, event_summary_template,
+				rename_rewrites_past_weekly_plans, rename_rewrites_past_events, weekly_digest_enabled,
+				weekly_digest_email, locale, duration_format, day_start_hour,
+				default_budget_item_id, rounding_increment, rounding_mode, rounding_minimum_block,
+				daily_sleep, disabled FROM users WHERE id = $1`
 	var user User
 	var googleCalendarId sql.NullString
+	var outlookCalendarId sql.NullString
+	var shortEventThreshold int
+	var defaultBudgetItemId sql.NullInt32
+	var roundingIncrement, roundingMinimumBlock, dailySleep int
 	err := u.db.QueryRow(ctx, query, id).
 		Scan(
 			&user.Id,
@@ -69,7 +83,27 @@ func (u *UserRepoImpl) GetUser(ctx context.Context, id int) (User, error) {
 			&user.Settings.WeekFirstDay,
 			&user.Settings.EventCalendarType,
 			&googleCalendarId,
+			&outlookCalendarId,
 			&user.Settings.IgnoreShortEvents,
+			&user.Settings.StrictCalendar,
+			&user.Settings.DiscardIdleTime,
+			&shortEventThreshold,
+			&user.Settings.ShortEventHandling,
+			&user.Settings.KeepCrossMidnightEvents,
+			&user.Settings.EventSummaryTemplate,
+			&user.Settings.RenamePropagation.RewritePastWeeklyPlans,
+			&user.Settings.RenamePropagation.RewritePastEvents,
+			&user.Settings.WeeklyDigest.Enabled,
+			&user.Settings.WeeklyDigest.Email,
+			&user.Settings.Locale,
+			&user.Settings.DurationFormat,
+			&user.Settings.DayStartHour,
+			&defaultBudgetItemId,
+			&roundingIncrement,
+			&user.Settings.Rounding.Mode,
+			&roundingMinimumBlock,
+			&dailySleep,
+			&user.Disabled,
 		)
 	if errors.Is(err, sql.ErrNoRows) {
 		log.Errorf("user with id %d not found: %v", id, err)
@@ -81,15 +115,32 @@ func (u *UserRepoImpl) GetUser(ctx context.Context, id int) (User, error) {
 	if googleCalendarId.Valid {
 		user.Settings.GoogleCalendar.CalendarId = googleCalendarId.String
 	}
+	if outlookCalendarId.Valid {
+		user.Settings.OutlookCalendar.CalendarId = outlookCalendarId.String
+	}
+	user.Settings.ShortEventThreshold = time.Duration(shortEventThreshold) * time.Second
+	user.Settings.DefaultBudgetItemId = int(defaultBudgetItemId.Int32)
+	user.Settings.Rounding.Increment = time.Duration(roundingIncrement) * time.Second
+	user.Settings.Rounding.MinimumBlock = time.Duration(roundingMinimumBlock) * time.Second
+	user.Settings.DailySleep = time.Duration(dailySleep) * time.Second
 	return user, nil
 }
 
 func (u *UserRepoImpl) GetUserByUid(ctx context.Context, uid string) (User, error) {
 	query := `SELECT id, uid, username, display_name, photo_url, timezone, week_first_day, event_calendar_type,
-				event_calendar_google_calendar_id, ignore_short_events FROM users WHERE uid = $1`
+				event_calendar_google_calendar_id, event_calendar_outlook_calendar_id, ignore_short_events, strict_calendar, discard_idle_time,
+				short_event_threshold, short_event_handling, keep_cross_midnight_events, event_summary_template,
+				rename_rewrites_past_weekly_plans, rename_rewrites_past_events, weekly_digest_enabled,
+				weekly_digest_email, locale, duration_format, day_start_hour,
+				default_budget_item_id, rounding_increment, rounding_mode, rounding_minimum_block,
+				daily_sleep, disabled FROM users WHERE uid = $1`
 
 	var user User
 	var googleCalendarId sql.NullString
+	var outlookCalendarId sql.NullString
+	var shortEventThreshold int
+	var defaultBudgetItemId sql.NullInt32
+	var roundingIncrement, roundingMinimumBlock, dailySleep int
 	err := u.db.QueryRow(ctx, query, uid).
 		Scan(
 			&user.Id,
@@ -101,7 +152,27 @@ func (u *UserRepoImpl) GetUserByUid(ctx context.Context, uid string) (User, erro
 			&user.Settings.WeekFirstDay,
 			&user.Settings.EventCalendarType,
 			&googleCalendarId,
+			&outlookCalendarId,
 			&user.Settings.IgnoreShortEvents,
+			&user.Settings.StrictCalendar,
+			&user.Settings.DiscardIdleTime,
+			&shortEventThreshold,
+			&user.Settings.ShortEventHandling,
+			&user.Settings.KeepCrossMidnightEvents,
+			&user.Settings.EventSummaryTemplate,
+			&user.Settings.RenamePropagation.RewritePastWeeklyPlans,
+			&user.Settings.RenamePropagation.RewritePastEvents,
+			&user.Settings.WeeklyDigest.Enabled,
+			&user.Settings.WeeklyDigest.Email,
+			&user.Settings.Locale,
+			&user.Settings.DurationFormat,
+			&user.Settings.DayStartHour,
+			&defaultBudgetItemId,
+			&roundingIncrement,
+			&user.Settings.Rounding.Mode,
+			&roundingMinimumBlock,
+			&dailySleep,
+			&user.Disabled,
 		)
 	if errors.Is(err, sql.ErrNoRows) {
 		log.Infof("user with uid %s not found: %v", uid, err)
@@ -113,12 +184,27 @@ func (u *UserRepoImpl) GetUserByUid(ctx context.Context, uid string) (User, erro
 	if googleCalendarId.Valid {
 		user.Settings.GoogleCalendar.CalendarId = googleCalendarId.String
 	}
+	if outlookCalendarId.Valid {
+		user.Settings.OutlookCalendar.CalendarId = outlookCalendarId.String
+	}
+	user.Settings.ShortEventThreshold = time.Duration(shortEventThreshold) * time.Second
+	user.Settings.DefaultBudgetItemId = int(defaultBudgetItemId.Int32)
+	user.Settings.Rounding.Increment = time.Duration(roundingIncrement) * time.Second
+	user.Settings.Rounding.MinimumBlock = time.Duration(roundingMinimumBlock) * time.Second
+	user.Settings.DailySleep = time.Duration(dailySleep) * time.Second
 	return user, nil
 }
 
 func (u *UserRepoImpl) UpdateUser(ctx context.Context, userId int, user User) (User, error) {
 	query := `UPDATE users SET display_name = $1, timezone = $2, week_first_day = $3, event_calendar_type = $4, 
-				event_calendar_google_calendar_id = $5, ignore_short_events = $6 WHERE id = $7`
+				event_calendar_google_calendar_id = $5, ignore_short_events = $6, strict_calendar = $7,
+				discard_idle_time = $8, short_event_threshold = $9, short_event_handling = $10,
+				keep_cross_midnight_events = $11, event_summary_template = $12,
+				rename_rewrites_past_weekly_plans = $13, rename_rewrites_past_events = $14,
+				weekly_digest_enabled = $15, weekly_digest_email = $16,
+				event_calendar_outlook_calendar_id = $17, locale = $18, duration_format = $19,
+				day_start_hour = $20, default_budget_item_id = $21, rounding_increment = $22, rounding_mode = $23,
+				rounding_minimum_block = $24, daily_sleep = $25 WHERE id = $26`
 	result, err := u.db.Exec(ctx, query,
 		user.DisplayName,
 		user.Settings.Timezone,
@@ -126,6 +212,25 @@ func (u *UserRepoImpl) UpdateUser(ctx context.Context, userId int, user User) (U
 		user.Settings.EventCalendarType,
 		user.Settings.GoogleCalendar.CalendarId,
 		user.Settings.IgnoreShortEvents,
+		user.Settings.StrictCalendar,
+		user.Settings.DiscardIdleTime,
+		int(user.Settings.ShortEventThreshold.Seconds()),
+		user.Settings.ShortEventHandling,
+		user.Settings.KeepCrossMidnightEvents,
+		user.Settings.EventSummaryTemplate,
+		user.Settings.RenamePropagation.RewritePastWeeklyPlans,
+		user.Settings.RenamePropagation.RewritePastEvents,
+		user.Settings.WeeklyDigest.Enabled,
+		user.Settings.WeeklyDigest.Email,
+		user.Settings.OutlookCalendar.CalendarId,
+		user.Settings.Locale,
+		user.Settings.DurationFormat,
+		user.Settings.DayStartHour,
+		sql.NullInt32{Int32: int32(user.Settings.DefaultBudgetItemId), Valid: user.Settings.DefaultBudgetItemId != 0},
+		int(user.Settings.Rounding.Increment.Seconds()),
+		user.Settings.Rounding.Mode,
+		int(user.Settings.Rounding.MinimumBlock.Seconds()),
+		int(user.Settings.DailySleep.Seconds()),
 		userId,
 	)
 	if err != nil {
@@ -139,9 +244,48 @@ func (u *UserRepoImpl) UpdateUser(ctx context.Context, userId int, user User) (U
 	return user, nil
 }
 
+// userOwnedTables lists the tables holding the user's data without a foreign key cascading from users.
+// The order matters: current_event and budget_plan_current reference budget items and plans without a cascade.
+// Tables created later reference users with ON DELETE CASCADE and are cleaned up by the database.
+var userOwnedTables = []string{
+	"current_event",
+	"budget_plan_current",
+	"calendar_event_lineage",
+	"calendar_event",
+	"calendar_event_archive",
+	"weekly_plan_item",
+	"weekly_plan",
+	"event_schedule",
+	"webhooks",
+	"export_stream_change",
+	"export_stream",
+	"user_onboarding",
+	"budget_plan_activation",
+	"budget_plan_revision",
+	"budget_category",
+	"budget_item",
+	"budget_plan",
+	"clickup_tag_mapping",
+	"clickup_config",
+	"clickup_auth",
+	"google_calendar_auth",
+}
+
+// DeleteUser deletes the user together with all the data they own in a single transaction.
 func (u *UserRepoImpl) DeleteUser(ctx context.Context, id int) error {
-	query := `DELETE FROM users WHERE id = $1`
-	result, err := u.db.Exec(ctx, query, id)
+	tx, err := u.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	for _, table := range userOwnedTables {
+		if _, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE user_id = $1`, id); err != nil {
+			return fmt.Errorf("failed to delete %s of user %d: %w", table, id, err)
+		}
+	}
+
+	result, err := tx.Exec(ctx, `DELETE FROM users WHERE id = $1`, id)
 	if err != nil {
 		return err
 	}
@@ -150,12 +294,17 @@ func (u *UserRepoImpl) DeleteUser(ctx context.Context, id int) error {
 		log.Info("no rows affected of deleting user")
 		return errors.New("User with id " + strconv.Itoa(id) + " not found")
 	}
-	return nil
+	return tx.Commit(ctx)
 }
 
 func (u *UserRepoImpl) GetAllUsers(ctx context.Context) ([]User, error) {
 	query := `SELECT id, uid, username, display_name, photo_url, timezone, week_first_day, event_calendar_type, 
-		        event_calendar_google_calendar_id, ignore_short_events FROM users`
+		        event_calendar_google_calendar_id, event_calendar_outlook_calendar_id, ignore_short_events, strict_calendar, discard_idle_time,
+				short_event_threshold, short_event_handling, keep_cross_midnight_events, event_summary_template,
+				rename_rewrites_past_weekly_plans, rename_rewrites_past_events, weekly_digest_enabled,
+				weekly_digest_email, locale, duration_format, day_start_hour,
+				default_budget_item_id, rounding_increment, rounding_mode, rounding_minimum_block,
+				daily_sleep, disabled FROM users`
 	rows, err := u.db.Query(ctx, query)
 	if err != nil {
 		log.Errorf("failed to get users: %v", err)
@@ -166,8 +315,19 @@ func (u *UserRepoImpl) GetAllUsers(ctx context.Context) ([]User, error) {
 	for rows.Next() {
 		var user User
 		var googleCalendarId sql.NullString
+		var outlookCalendarId sql.NullString
+		var shortEventThreshold int
+		var defaultBudgetItemId sql.NullInt32
+		var roundingIncrement, roundingMinimumBlock, dailySleep int
 		err := rows.Scan(&user.Id, &user.Uid, &user.Username, &user.DisplayName, &user.PhotoUrl, &user.Settings.Timezone,
-			&user.Settings.WeekFirstDay, &user.Settings.EventCalendarType, &googleCalendarId, &user.Settings.IgnoreShortEvents)
+			&user.Settings.WeekFirstDay, &user.Settings.EventCalendarType, &googleCalendarId, &outlookCalendarId,
+			&user.Settings.IgnoreShortEvents,
+			&user.Settings.StrictCalendar, &user.Settings.DiscardIdleTime, &shortEventThreshold, &user.Settings.ShortEventHandling,
+			&user.Settings.KeepCrossMidnightEvents, &user.Settings.EventSummaryTemplate,
+			&user.Settings.RenamePropagation.RewritePastWeeklyPlans, &user.Settings.RenamePropagation.RewritePastEvents,
+			&user.Settings.WeeklyDigest.Enabled, &user.Settings.WeeklyDigest.Email,
+			&user.Settings.Locale, &user.Settings.DurationFormat, &user.Settings.DayStartHour, &defaultBudgetItemId,
+			&roundingIncrement, &user.Settings.Rounding.Mode, &roundingMinimumBlock, &dailySleep, &user.Disabled)
 		if err != nil {
 			log.Errorf("failed to scan user: %v", err)
 			return nil, err
@@ -175,6 +335,14 @@ func (u *UserRepoImpl) GetAllUsers(ctx context.Context) ([]User, error) {
 		if googleCalendarId.Valid {
 			user.Settings.GoogleCalendar.CalendarId = googleCalendarId.String
 		}
+		if outlookCalendarId.Valid {
+			user.Settings.OutlookCalendar.CalendarId = outlookCalendarId.String
+		}
+		user.Settings.ShortEventThreshold = time.Duration(shortEventThreshold) * time.Second
+		user.Settings.DefaultBudgetItemId = int(defaultBudgetItemId.Int32)
+		user.Settings.Rounding.Increment = time.Duration(roundingIncrement) * time.Second
+		user.Settings.Rounding.MinimumBlock = time.Duration(roundingMinimumBlock) * time.Second
+		user.Settings.DailySleep = time.Duration(dailySleep) * time.Second
 		users = append(users, user)
 		if err := rows.Err(); err != nil {
 			log.Errorf("error iterating over rows: %v", err)
@@ -194,3 +362,14 @@ func (u *UserRepoImpl) IsUsernameAvailable(ctx context.Context, username string)
 	}
 	return count == 0, nil
 }
+
+func (u *UserRepoImpl) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	result, err := u.db.Exec(ctx, `UPDATE users SET disabled = $1 WHERE id = $2`, disabled, id)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.New("User with id " + strconv.Itoa(id) + " not found")
+	}
+	return nil
+}
@@ -8,11 +8,14 @@ import (
 	"os"
 	"strconv"
 
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
 	log "github.com/sirupsen/logrus"
 )
 
 var ErrUserNotFound = errors.New("user not found")
 var ErrUserDataInvalid = errors.New("user data invalid")
+var ErrUserDisabled = errors.New("user is disabled")
 
 const storagePath = "storage/user_photos/"
 
@@ -23,10 +26,16 @@ type Service interface {
 	UpdateUser(ctx context.Context, user User) (User, error)
 	GetUserByUid(ctx context.Context, uid string) (User, error)
 	DeleteUser(ctx context.Context, id int) error
+	// SetUserDisabled disables or re-enables the user. Disabled users keep all their data, but cannot sign in
+	// and are skipped by background jobs.
+	SetUserDisabled(ctx context.Context, id int, disabled bool) (User, error)
 	GetAllUsers(ctx context.Context) ([]User, error)
+	// StoreUserPhoto validates the uploaded image and stores it in all the photo sizes, ErrInvalidPhoto when it is not
+	// a supported image.
 	StoreUserPhoto(ctx context.Context, photo []byte) error
-	GetUserPhoto(ctx context.Context, id int) ([]byte, error)
-	GetCurrentUserPhoto(ctx context.Context) ([]byte, error)
+	// GetUserPhoto returns nil when the user has no photo.
+	GetUserPhoto(ctx context.Context, id int, size PhotoSize) ([]byte, error)
+	GetCurrentUserPhoto(ctx context.Context, size PhotoSize) ([]byte, error)
 	DeleteUserPhoto(ctx context.Context) error
 	IsUsernameAvailable(ctx context.Context, username string) (bool, error)
 }
@@ -36,14 +45,37 @@ type Provider interface {
 }
 
 type UserServiceImpl struct {
-	repo Repo
+	repo     Repo
+	eventBus *event_bus.EventBus
+	cache    *userCache
+	// onDelete runs before the user is deleted, while their data can still be read
+	onDelete []func(ctx context.Context, userId int) error
+	// onUpdate validates the references of the updated user to data of other modules
+	onUpdate []func(ctx context.Context, user User) error
 }
 
-func NewUserService(repo Repo) *UserServiceImpl {
-	return &UserServiceImpl{repo: repo}
+func NewUserService(repo Repo, eventBus *event_bus.EventBus) *UserServiceImpl {
+	return &UserServiceImpl{repo: repo, eventBus: eventBus, cache: newUserCache(defaultCacheTTL, utils.SystemClock{})}
 }
 
+// OnDelete registers a function run before a user is deleted, e.g. to revoke the user's third-party tokens.
+// The user is not deleted when it fails.
+func (u *UserServiceImpl) OnDelete(fn func(ctx context.Context, userId int) error) {
+	u.onDelete = append(u.onDelete, fn)
+}
+
+// OnUpdate registers a function validating the user before the current user is updated, e.g. that the default
+// budget item belongs to them. The user is not updated when it fails, ErrUserDataInvalid marks invalid data.
+func (u *UserServiceImpl) OnUpdate(fn func(ctx context.Context, user User) error) {
+	u.onUpdate = append(u.onUpdate, fn)
+}
+
+// GetCurrentUser returns the user put into the context by the auth middleware, so it does not hit the repository
+// more than once per request.
 func (u *UserServiceImpl) GetCurrentUser(ctx context.Context) (User, error) {
+	if currentUser, err := CurrentUser(ctx); err == nil {
+		return currentUser, nil
+	}
 	userId, err := CurrentId(ctx)
 	if err != nil {
 		return User{}, fmt.Errorf("failed to get current user: %w", err)
@@ -77,6 +109,9 @@ func (u *UserServiceImpl) validateUser(ctx context.Context, user User) bool {
 }
 
 func (u *UserServiceImpl) GetUser(ctx context.Context, id int) (User, error) {
+	if user, ok := u.cache.get(id); ok {
+		return user, nil
+	}
 	user, err := u.repo.GetUser(ctx, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -84,10 +119,14 @@ func (u *UserServiceImpl) GetUser(ctx context.Context, id int) (User, error) {
 		}
 		return User{}, err
 	}
+	u.cache.put(user)
 	return user, nil
 }
 
 func (u *UserServiceImpl) GetUserByUid(ctx context.Context, uid string) (User, error) {
+	if user, ok := u.cache.getByUid(uid); ok {
+		return user, nil
+	}
 	user, err := u.repo.GetUserByUid(ctx, uid)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -95,6 +134,7 @@ func (u *UserServiceImpl) GetUserByUid(ctx context.Context, uid string) (User, e
 		}
 		return User{}, err
 	}
+	u.cache.put(user)
 	return user, nil
 }
 
@@ -103,11 +143,50 @@ func (u *UserServiceImpl) UpdateUser(ctx context.Context, user User) (User, erro
 	if err != nil {
 		return User{}, fmt.Errorf("failed to get current user: %w", err)
 	}
-	return u.repo.UpdateUser(ctx, userId, user)
+	for _, fn := range u.onUpdate {
+		if err := fn(ctx, user); err != nil {
+			return User{}, err
+		}
+	}
+	updated, err := u.repo.UpdateUser(ctx, userId, user)
+	u.cache.invalidate(userId)
+	return updated, err
 }
 
+// DeleteUser runs the OnDelete functions, deletes the user with all their data and photo, then publishes
+// user.deleted.
 func (u *UserServiceImpl) DeleteUser(ctx context.Context, id int) error {
-	return u.repo.DeleteUser(ctx, id)
+	deleted, err := u.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, fn := range u.onDelete {
+		if err := fn(ctx, id); err != nil {
+			return fmt.Errorf("failed to prepare deletion of user %d: %w", id, err)
+		}
+	}
+	err = u.repo.DeleteUser(ctx, id)
+	u.cache.invalidate(id)
+	if err != nil {
+		return err
+	}
+	if err := removePhoto(id); err != nil {
+		log.Errorf("failed to delete photo of deleted user %d: %v", id, err)
+	}
+	err = u.eventBus.Publish(event_bus.NewEvent(ctx, "user.deleted", event_bus.UserDeleted{Id: id, Uid: deleted.Uid}))
+	if err != nil {
+		log.Errorf("failed to publish user.deleted event: %v", err)
+	}
+	return nil
+}
+
+func (u *UserServiceImpl) SetUserDisabled(ctx context.Context, id int, disabled bool) (User, error) {
+	err := u.repo.SetDisabled(ctx, id, disabled)
+	u.cache.invalidate(id)
+	if err != nil {
+		return User{}, err
+	}
+	return u.GetUser(ctx, id)
 }
 
 func (u *UserServiceImpl) GetAllUsers(ctx context.Context) ([]User, error) {
@@ -120,32 +199,42 @@ func (u *UserServiceImpl) StoreUserPhoto(ctx context.Context, photo []byte) erro
 		return fmt.Errorf("failed to get current user: %w", err)
 	}
 
-	err = os.MkdirAll(storagePath, 0755)
+	variants, err := processPhoto(photo)
 	if err != nil {
 		return err
 	}
-	err = os.WriteFile(storagePath+"/"+strconv.Itoa(userId)+".jpg", photo, 0644)
+	err = os.MkdirAll(storagePath, 0755)
 	if err != nil {
 		return err
 	}
+	for size, variant := range variants {
+		err = os.WriteFile(photoPath(userId, size), variant, 0644)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (u *UserServiceImpl) GetUserPhoto(_ context.Context, id int) ([]byte, error) {
-	expectedFile := storagePath + "/" + strconv.Itoa(id) + ".jpg"
+func (u *UserServiceImpl) GetUserPhoto(ctx context.Context, id int, size PhotoSize) ([]byte, error) {
+	expectedFile := photoPath(id, size)
 	if _, err := os.Stat(expectedFile); os.IsNotExist(err) {
+		if size != PhotoSizeFull {
+			// Photos uploaded before the sizes were introduced only have the original file
+			return u.GetUserPhoto(ctx, id, PhotoSizeFull)
+		}
 		return nil, nil
 	}
 	return os.ReadFile(expectedFile)
 }
 
-func (u *UserServiceImpl) GetCurrentUserPhoto(ctx context.Context) ([]byte, error) {
+func (u *UserServiceImpl) GetCurrentUserPhoto(ctx context.Context, size PhotoSize) ([]byte, error) {
 	userId, err := CurrentId(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get current user: %w", err)
 	}
 
-	return u.GetUserPhoto(ctx, userId)
+	return u.GetUserPhoto(ctx, userId, size)
 }
 
 func (u *UserServiceImpl) DeleteUserPhoto(ctx context.Context) error {
@@ -154,11 +243,28 @@ func (u *UserServiceImpl) DeleteUserPhoto(ctx context.Context) error {
 		return fmt.Errorf("failed to get current user: %w", err)
 	}
 
-	expectedFile := storagePath + "/" + strconv.Itoa(userId) + ".jpg"
-	if _, err := os.Stat(expectedFile); os.IsNotExist(err) {
-		return nil
+	return removePhoto(userId)
+}
+
+func removePhoto(userId int) error {
+	for size := range maxPhotoDimensions {
+		expectedFile := photoPath(userId, size)
+		if _, err := os.Stat(expectedFile); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Remove(expectedFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// photoPath keeps the full size photo at the path used before the sizes were introduced.
+func photoPath(userId int, size PhotoSize) string {
+	if size == PhotoSizeFull {
+		return storagePath + "/" + strconv.Itoa(userId) + ".jpg"
 	}
-	return os.Remove(expectedFile)
+	return storagePath + "/" + strconv.Itoa(userId) + "_" + string(size) + ".jpg"
 }
 
 func (u *UserServiceImpl) IsUsernameAvailable(ctx context.Context, username string) (bool, error) {
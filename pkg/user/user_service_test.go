@@ -0,0 +1,236 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingRepo struct {
+	*StubUserRepository
+	getCalls int
+}
+
+func (r *countingRepo) GetUser(ctx context.Context, id int) (User, error) {
+	r.getCalls++
+	return r.StubUserRepository.GetUser(ctx, id)
+}
+
+func (r *countingRepo) GetUserByUid(ctx context.Context, uid string) (User, error) {
+	r.getCalls++
+	return r.StubUserRepository.GetUserByUid(ctx, uid)
+}
+
+func setupService(t *testing.T) (*UserServiceImpl, *countingRepo, *utils.MockClock, User) {
+	t.Helper()
+	repo := &countingRepo{StubUserRepository: NewStubUserRepository()}
+	clock := &utils.MockClock{FixedNow: time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)}
+	service := &UserServiceImpl{repo: repo, eventBus: event_bus.NewEventBus(), cache: newUserCache(defaultCacheTTL, clock)}
+	created := User{Uid: "uid-1", Username: "john", DisplayName: "John"}
+	id, err := repo.CreateUser(context.Background(), created)
+	require.NoError(t, err)
+	created.Id = id
+	return service, repo, clock, created
+}
+
+func TestUserServiceImpl_GetCurrentUser_UsesContextUser(t *testing.T) {
+	service, repo, _, u := setupService(t)
+	ctx := WithUser(context.Background(), u)
+
+	current, err := service.GetCurrentUser(ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, u, current)
+	assert.Equal(t, 0, repo.getCalls)
+}
+
+func TestUserServiceImpl_GetUser_Cache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("repeated lookups hit the repository once", func(t *testing.T) {
+		service, repo, _, u := setupService(t)
+
+		byUid, err := service.GetUserByUid(ctx, u.Uid)
+		require.NoError(t, err)
+		byId, err := service.GetUser(ctx, u.Id)
+		require.NoError(t, err)
+		_, err = service.GetUserByUid(ctx, u.Uid)
+		require.NoError(t, err)
+
+		assert.Equal(t, u, byUid)
+		assert.Equal(t, u, byId)
+		assert.Equal(t, 1, repo.getCalls)
+	})
+
+	t.Run("entries expire after ttl", func(t *testing.T) {
+		service, repo, clock, u := setupService(t)
+
+		_, err := service.GetUser(ctx, u.Id)
+		require.NoError(t, err)
+		clock.SetNow(clock.Now().Add(defaultCacheTTL))
+		_, err = service.GetUser(ctx, u.Id)
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, repo.getCalls)
+	})
+
+	t.Run("update invalidates the cached user", func(t *testing.T) {
+		service, _, _, u := setupService(t)
+
+		_, err := service.GetUser(ctx, u.Id)
+		require.NoError(t, err)
+		changed := u
+		changed.DisplayName = "Johnny"
+		changed.Settings.Timezone = "Europe/Warsaw"
+		_, err = service.UpdateUser(WithUser(ctx, u), changed)
+		require.NoError(t, err)
+
+		fetched, err := service.GetUserByUid(ctx, u.Uid)
+		require.NoError(t, err)
+		assert.Equal(t, "Johnny", fetched.DisplayName)
+		assert.Equal(t, "Europe/Warsaw", fetched.Settings.Timezone)
+	})
+
+	t.Run("delete invalidates the cached user", func(t *testing.T) {
+		service, _, _, u := setupService(t)
+
+		_, err := service.GetUser(ctx, u.Id)
+		require.NoError(t, err)
+		require.NoError(t, service.DeleteUser(ctx, u.Id))
+
+		_, err = service.GetUser(ctx, u.Id)
+		assert.Error(t, err)
+	})
+}
+
+func TestUserServiceImpl_DeleteUser(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("publishes user.deleted with the deleted user", func(t *testing.T) {
+		service, _, _, u := setupService(t)
+		var published []event_bus.UserDeleted
+		event_bus.SubscribeTyped[event_bus.UserDeleted](service.eventBus, "user.deleted",
+			func(e event_bus.EventT[event_bus.UserDeleted]) error {
+				published = append(published, e.Data)
+				return nil
+			})
+
+		err := service.DeleteUser(ctx, u.Id)
+
+		require.NoError(t, err)
+		assert.Equal(t, []event_bus.UserDeleted{{Id: u.Id, Uid: u.Uid}}, published)
+	})
+
+	t.Run("runs delete functions before deleting the user", func(t *testing.T) {
+		service, repo, _, u := setupService(t)
+		var existed []bool
+		service.OnDelete(func(ctx context.Context, userId int) error {
+			_, err := repo.StubUserRepository.GetUser(ctx, userId)
+			existed = append(existed, err == nil)
+			return nil
+		})
+
+		err := service.DeleteUser(ctx, u.Id)
+
+		require.NoError(t, err)
+		assert.Equal(t, []bool{true}, existed)
+	})
+
+	t.Run("keeps the user when a delete function fails", func(t *testing.T) {
+		service, repo, _, u := setupService(t)
+		service.OnDelete(func(ctx context.Context, userId int) error {
+			return errors.New("token store unavailable")
+		})
+
+		err := service.DeleteUser(ctx, u.Id)
+
+		assert.Error(t, err)
+		_, err = repo.StubUserRepository.GetUser(ctx, u.Id)
+		assert.NoError(t, err)
+	})
+
+	t.Run("does not publish for unknown user", func(t *testing.T) {
+		service, _, _, u := setupService(t)
+		published := 0
+		event_bus.SubscribeTyped[event_bus.UserDeleted](service.eventBus, "user.deleted",
+			func(e event_bus.EventT[event_bus.UserDeleted]) error {
+				published++
+				return nil
+			})
+
+		err := service.DeleteUser(ctx, u.Id+1)
+
+		assert.Error(t, err)
+		assert.Equal(t, 0, published)
+	})
+}
+
+func TestUserServiceImpl_UpdateUser(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("keeps the user when an update function fails", func(t *testing.T) {
+		service, repo, _, u := setupService(t)
+		service.OnUpdate(func(ctx context.Context, user User) error {
+			return fmt.Errorf("%w: default budget item %d not found", ErrUserDataInvalid, user.Settings.DefaultBudgetItemId)
+		})
+		changed := u
+		changed.Settings.DefaultBudgetItemId = 42
+
+		_, err := service.UpdateUser(WithUser(ctx, u), changed)
+
+		assert.ErrorIs(t, err, ErrUserDataInvalid)
+		stored, err := repo.StubUserRepository.GetUser(ctx, u.Id)
+		require.NoError(t, err)
+		assert.Zero(t, stored.Settings.DefaultBudgetItemId)
+	})
+}
+
+func TestUserServiceImpl_SetUserDisabled(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("disables and enables the user keeping the cache fresh", func(t *testing.T) {
+		service, _, _, u := setupService(t)
+		_, err := service.GetUser(ctx, u.Id)
+		require.NoError(t, err)
+
+		disabled, err := service.SetUserDisabled(ctx, u.Id, true)
+
+		require.NoError(t, err)
+		assert.True(t, disabled.Disabled)
+		cached, err := service.GetUserByUid(ctx, u.Uid)
+		require.NoError(t, err)
+		assert.True(t, cached.Disabled)
+
+		enabled, err := service.SetUserDisabled(ctx, u.Id, false)
+
+		require.NoError(t, err)
+		assert.False(t, enabled.Disabled)
+	})
+
+	t.Run("keeps the data of disabled user", func(t *testing.T) {
+		service, _, _, u := setupService(t)
+
+		_, err := service.SetUserDisabled(ctx, u.Id, true)
+
+		require.NoError(t, err)
+		stored, err := service.GetUser(ctx, u.Id)
+		require.NoError(t, err)
+		assert.Equal(t, u.Username, stored.Username)
+		assert.Equal(t, u.DisplayName, stored.DisplayName)
+	})
+
+	t.Run("fails for unknown user", func(t *testing.T) {
+		service, _, _, u := setupService(t)
+
+		_, err := service.SetUserDisabled(ctx, u.Id+1, true)
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,229 @@
+package user_switch
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/pkg/audit"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+)
+
+// SessionHeader carries the token of a switch session instead of the X-User-Id header.
+const SessionHeader = "X-Session-Token"
+
+type PinRequestDTO struct {
+	// Pin of 4 to 8 digits
+	Pin string `json:"pin"`
+}
+
+type PinDTO struct {
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type SwitchRequestDTO struct {
+	UserUid string `json:"userUid"`
+	Pin     string `json:"pin"`
+}
+
+type SessionDTO struct {
+	// Token to be sent in the X-Session-Token header instead of X-User-Id
+	Token       string    `json:"token"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	UserUid     string    `json:"userUid"`
+	DisplayName string    `json:"displayName"`
+}
+
+type Handler struct {
+	service Service
+	audit   audit.Recorder
+}
+
+func NewHandler(service Service, audit audit.Recorder) *Handler {
+	return &Handler{
+		service: service,
+		audit:   audit,
+	}
+}
+
+// SetPin godoc
+// @Summary Set user switch PIN
+// @Description Set the PIN household members enter to switch to the current user on a shared device.
+// @Description The PIN expires after the configured time and has to be set again.
+// @Tags UserSwitch
+// @Accept json
+// @Produce json
+// @Param request body PinRequestDTO true "PIN"
+// @Success 200 {object} PinDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid PIN"
+// @Failure 403 {string} string "User not found"
+// @Router /api/user/current/pin [put]
+// @Security XUserId
+func (h *Handler) SetPin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var body PinRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(rest.ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		return
+	}
+
+	pin, err := h.service.SetPin(r.Context(), body.Pin)
+	if err != nil {
+		if errors.Is(err, ErrInvalidPin) {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(rest.ErrorResponse{Error: err.Error()})
+			return
+		}
+		log.Errorf("Failed to set user switch pin: %v", err)
+		http.Error(w, "Failed to set pin", http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(PinDTO{ExpiresAt: pin.ExpiresAt}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetPin godoc
+// @Summary Get user switch PIN status
+// @Description Get when the current user's PIN expires. The PIN itself is never returned.
+// @Tags UserSwitch
+// @Produce json
+// @Success 200 {object} PinDTO
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "No valid PIN set"
+// @Router /api/user/current/pin [get]
+// @Security XUserId
+func (h *Handler) GetPin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	pin, err := h.service.GetPin(r.Context())
+	if err != nil {
+		if errors.Is(err, ErrPinNotSet) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to get user switch pin: %v", err)
+		http.Error(w, "Failed to get pin", http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(PinDTO{ExpiresAt: pin.ExpiresAt}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeletePin godoc
+// @Summary Delete user switch PIN
+// @Description Remove the current user's PIN, nobody can switch to the user until a new one is set.
+// @Description Sessions already issued stay valid until they expire.
+// @Tags UserSwitch
+// @Success 204 "No Content"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "No PIN set"
+// @Router /api/user/current/pin [delete]
+// @Security XUserId
+func (h *Handler) DeletePin(w http.ResponseWriter, r *http.Request) {
+	err := h.service.DeletePin(r.Context())
+	if err != nil {
+		if errors.Is(err, ErrPinNotSet) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to delete user switch pin: %v", err)
+		http.Error(w, "Failed to delete pin", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Switch godoc
+// @Summary Switch user
+// @Description Switch to a user on a shared device with the user's PIN. The returned session token is sent
+// @Description in the X-Session-Token header instead of X-User-Id until the session expires.
+// @Description Sessions only grant access to the user's own data, admin endpoints are rejected.
+// @Tags UserSwitch
+// @Accept json
+// @Produce json
+// @Param request body SwitchRequestDTO true "User and PIN"
+// @Success 201 {object} SessionDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 401 {object} rest.ErrorResponse "Wrong PIN or no valid PIN set"
+// @Failure 403 {string} string "User is disabled"
+// @Failure 404 {string} string "User not found"
+// @Router /api/user/switch [post]
+func (h *Handler) Switch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var body SwitchRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.UserUid == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(rest.ErrorResponse{Error: "Invalid request body", Details: "userUid and pin are required"})
+		return
+	}
+
+	session, sessionUser, err := h.service.Switch(r.Context(), body.UserUid, body.Pin)
+	if err != nil {
+		h.audit.Record(r.Context(), audit.ActionLoginFailed, "user switch", map[string]string{
+			"method":  "pin",
+			"userUid": body.UserUid,
+			"reason":  err.Error(),
+		})
+		if errors.Is(err, user.ErrUserNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, user.ErrUserDisabled) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, ErrWrongPin) || errors.Is(err, ErrPinNotSet) {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(rest.ErrorResponse{Error: err.Error()})
+			return
+		}
+		log.Errorf("Failed to switch user: %v", err)
+		http.Error(w, "Failed to switch user", http.StatusInternalServerError)
+		return
+	}
+	h.audit.Record(user.WithUser(r.Context(), sessionUser), audit.ActionLogin, "user switch", map[string]string{"method": "pin"})
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(SessionDTO{
+		Token:       session.Token,
+		ExpiresAt:   session.ExpiresAt,
+		UserUid:     sessionUser.Uid,
+		DisplayName: sessionUser.DisplayName,
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// EndSession godoc
+// @Summary End switch session
+// @Description End the session the request is authenticated with, e.g. when handing the shared device over.
+// @Tags UserSwitch
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Request is not authenticated with a session"
+// @Failure 403 {string} string "User not found"
+// @Router /api/user/switch [delete]
+// @Security XSessionToken
+func (h *Handler) EndSession(w http.ResponseWriter, r *http.Request) {
+	session, ok := CurrentSession(r.Context())
+	if !ok {
+		http.Error(w, "request is not authenticated with a session", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.EndSession(r.Context(), session.Token); err != nil && !errors.Is(err, ErrSessionNotFound) {
+		log.Errorf("Failed to end user switch session: %v", err)
+		http.Error(w, "Failed to end session", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
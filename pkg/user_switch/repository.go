@@ -0,0 +1,153 @@
+package user_switch
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrPinNotFound = errors.New("pin not found")
+var ErrSessionNotFound = errors.New("session not found")
+
+type Repository interface {
+	// SetPin stores the PIN of the user, replacing the previous one and its failed attempts.
+	SetPin(ctx context.Context, pin Pin) error
+	GetPin(ctx context.Context, userId int) (Pin, error)
+	DeletePin(ctx context.Context, userId int) error
+	// RecordFailedAttempt increments the failed attempts of the user's PIN and returns their new count.
+	RecordFailedAttempt(ctx context.Context, userId int) (int, error)
+	ResetFailedAttempts(ctx context.Context, userId int) error
+	// CreateSession issues a session with a new random token.
+	CreateSession(ctx context.Context, userId int, expiresAt time.Time) (Session, error)
+	GetSession(ctx context.Context, token string) (Session, error)
+	DeleteSession(ctx context.Context, token string) error
+	// DeleteExpiredSessions removes sessions that expired before the given time and returns their count.
+	DeleteExpiredSessions(ctx context.Context, before time.Time) (int, error)
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+func (r *RepositoryImpl) SetPin(ctx context.Context, pin Pin) error {
+	query := `INSERT INTO user_switch_pin (user_id, pin_hash, pin_salt, failed_attempts, expires_at)
+			  VALUES ($1, $2, $3, 0, $4)
+			  ON CONFLICT (user_id) DO UPDATE
+			  SET pin_hash = EXCLUDED.pin_hash, pin_salt = EXCLUDED.pin_salt, failed_attempts = 0, expires_at = EXCLUDED.expires_at`
+	_, err := r.db.Exec(ctx, query, pin.UserId, pin.Hash, pin.Salt, pin.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to store pin: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) GetPin(ctx context.Context, userId int) (Pin, error) {
+	query := `SELECT user_id, pin_hash, pin_salt, failed_attempts, expires_at FROM user_switch_pin WHERE user_id = $1`
+	var pin Pin
+	err := r.db.QueryRow(ctx, query, userId).Scan(&pin.UserId, &pin.Hash, &pin.Salt, &pin.FailedAttempts, &pin.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Pin{}, ErrPinNotFound
+		}
+		return Pin{}, fmt.Errorf("failed to get pin: %w", err)
+	}
+	return pin, nil
+}
+
+func (r *RepositoryImpl) DeletePin(ctx context.Context, userId int) error {
+	result, err := r.db.Exec(ctx, `DELETE FROM user_switch_pin WHERE user_id = $1`, userId)
+	if err != nil {
+		return fmt.Errorf("failed to delete pin: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrPinNotFound
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) RecordFailedAttempt(ctx context.Context, userId int) (int, error) {
+	query := `UPDATE user_switch_pin SET failed_attempts = failed_attempts + 1 WHERE user_id = $1 RETURNING failed_attempts`
+	var attempts int
+	err := r.db.QueryRow(ctx, query, userId).Scan(&attempts)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrPinNotFound
+		}
+		return 0, fmt.Errorf("failed to record failed pin attempt: %w", err)
+	}
+	return attempts, nil
+}
+
+func (r *RepositoryImpl) ResetFailedAttempts(ctx context.Context, userId int) error {
+	_, err := r.db.Exec(ctx, `UPDATE user_switch_pin SET failed_attempts = 0 WHERE user_id = $1`, userId)
+	if err != nil {
+		return fmt.Errorf("failed to reset failed pin attempts: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) CreateSession(ctx context.Context, userId int, expiresAt time.Time) (Session, error) {
+	token, err := generateToken()
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to generate token: %w", err)
+	}
+	query := `INSERT INTO user_switch_session (token, user_id, expires_at) VALUES ($1, $2, $3)
+			  RETURNING token, user_id, created_at, expires_at`
+	var session Session
+	err = r.db.QueryRow(ctx, query, token, userId, expiresAt).
+		Scan(&session.Token, &session.UserId, &session.CreatedAt, &session.ExpiresAt)
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to store session: %w", err)
+	}
+	return session, nil
+}
+
+func (r *RepositoryImpl) GetSession(ctx context.Context, token string) (Session, error) {
+	query := `SELECT token, user_id, created_at, expires_at FROM user_switch_session WHERE token = $1`
+	var session Session
+	err := r.db.QueryRow(ctx, query, token).Scan(&session.Token, &session.UserId, &session.CreatedAt, &session.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Session{}, ErrSessionNotFound
+		}
+		return Session{}, fmt.Errorf("failed to get session: %w", err)
+	}
+	return session, nil
+}
+
+func (r *RepositoryImpl) DeleteSession(ctx context.Context, token string) error {
+	result, err := r.db.Exec(ctx, `DELETE FROM user_switch_session WHERE token = $1`, token)
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) DeleteExpiredSessions(ctx context.Context, before time.Time) (int, error) {
+	result, err := r.db.Exec(ctx, `DELETE FROM user_switch_session WHERE expires_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+func generateToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	_, err := rand.Read(tokenBytes)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", tokenBytes), nil
+}
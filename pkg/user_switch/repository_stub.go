@@ -0,0 +1,128 @@
+package user_switch
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type RepositoryStub struct {
+	mu        sync.RWMutex
+	pins      map[int]Pin        // userId -> pin
+	sessions  map[string]Session // token -> session
+	nextToken int
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{
+		pins:     make(map[int]Pin),
+		sessions: make(map[string]Session),
+	}
+}
+
+func (r *RepositoryStub) SetPin(ctx context.Context, pin Pin) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pin.FailedAttempts = 0
+	r.pins[pin.UserId] = pin
+	return nil
+}
+
+func (r *RepositoryStub) GetPin(ctx context.Context, userId int) (Pin, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	pin, exists := r.pins[userId]
+	if !exists {
+		return Pin{}, ErrPinNotFound
+	}
+	return pin, nil
+}
+
+func (r *RepositoryStub) DeletePin(ctx context.Context, userId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.pins[userId]; !exists {
+		return ErrPinNotFound
+	}
+	delete(r.pins, userId)
+	return nil
+}
+
+func (r *RepositoryStub) RecordFailedAttempt(ctx context.Context, userId int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pin, exists := r.pins[userId]
+	if !exists {
+		return 0, ErrPinNotFound
+	}
+	pin.FailedAttempts++
+	r.pins[userId] = pin
+	return pin.FailedAttempts, nil
+}
+
+func (r *RepositoryStub) ResetFailedAttempts(ctx context.Context, userId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if pin, exists := r.pins[userId]; exists {
+		pin.FailedAttempts = 0
+		r.pins[userId] = pin
+	}
+	return nil
+}
+
+func (r *RepositoryStub) CreateSession(ctx context.Context, userId int, expiresAt time.Time) (Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextToken++
+	session := Session{
+		Token:     fmt.Sprintf("session-%d", r.nextToken),
+		UserId:    userId,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+	r.sessions[session.Token] = session
+	return session, nil
+}
+
+func (r *RepositoryStub) GetSession(ctx context.Context, token string) (Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, exists := r.sessions[token]
+	if !exists {
+		return Session{}, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+func (r *RepositoryStub) DeleteSession(ctx context.Context, token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.sessions[token]; !exists {
+		return ErrSessionNotFound
+	}
+	delete(r.sessions, token)
+	return nil
+}
+
+func (r *RepositoryStub) DeleteExpiredSessions(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := 0
+	for token, session := range r.sessions {
+		if session.ExpiresAt.Before(before) {
+			delete(r.sessions, token)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,234 @@
+package user_switch
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	minPinLength = 4
+	maxPinLength = 8
+	// MaxFailedAttempts is the number of wrong PINs after which the PIN is removed and has to be set again.
+	MaxFailedAttempts = 5
+
+	pinHashIterations = 100_000
+	pinHashLength     = 32
+	pinSaltLength     = 16
+)
+
+var ErrInvalidPin = fmt.Errorf("pin must be %d to %d digits", minPinLength, maxPinLength)
+var ErrPinNotSet = errors.New("user has no valid pin set")
+var ErrWrongPin = errors.New("wrong pin")
+
+type UserProvider interface {
+	GetUser(ctx context.Context, id int) (user.User, error)
+	GetUserByUid(ctx context.Context, uid string) (user.User, error)
+}
+
+type Service interface {
+	// SetPin sets the PIN other household members use to switch to the current user. It expires after the
+	// configured time.
+	SetPin(ctx context.Context, pin string) (Pin, error)
+	// GetPin returns the current user's PIN, or ErrPinNotSet when there is no PIN or it has expired.
+	GetPin(ctx context.Context) (Pin, error)
+	DeletePin(ctx context.Context) error
+	// Switch checks the PIN of the user with the given uid and issues a short-lived session for them.
+	// After MaxFailedAttempts wrong PINs the user's PIN is removed.
+	Switch(ctx context.Context, userUid string, pin string) (Session, user.User, error)
+	// Authenticate returns the session with the given token and its user, or ErrSessionNotFound when
+	// there is no such session or it has expired, and user.ErrUserDisabled when the user has been disabled.
+	Authenticate(ctx context.Context, token string) (Session, user.User, error)
+	EndSession(ctx context.Context, token string) error
+	// StartSession issues a session for a user authenticated by other means, e.g. single sign-on.
+	StartSession(ctx context.Context, userId int, ttl time.Duration) (Session, error)
+}
+
+type ServiceImpl struct {
+	repo       Repository
+	users      UserProvider
+	clock      utils.Clock
+	pinTtl     time.Duration
+	sessionTtl time.Duration
+}
+
+func NewService(repo Repository, users UserProvider, cfg config.UserSwitch) *ServiceImpl {
+	return &ServiceImpl{
+		repo:       repo,
+		users:      users,
+		clock:      &utils.SystemClock{},
+		pinTtl:     time.Duration(cfg.PinTtlHours) * time.Hour,
+		sessionTtl: time.Duration(cfg.SessionTtlMinutes) * time.Minute,
+	}
+}
+
+func (s *ServiceImpl) SetPin(ctx context.Context, pin string) (Pin, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Pin{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	if !isValidPin(pin) {
+		return Pin{}, ErrInvalidPin
+	}
+
+	salt := make([]byte, pinSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return Pin{}, fmt.Errorf("failed to generate pin salt: %w", err)
+	}
+	hash, err := hashPin(pin, salt)
+	if err != nil {
+		return Pin{}, err
+	}
+	stored := Pin{
+		UserId:    userId,
+		Hash:      hash,
+		Salt:      salt,
+		ExpiresAt: s.clock.Now().Add(s.pinTtl),
+	}
+	if err := s.repo.SetPin(ctx, stored); err != nil {
+		return Pin{}, err
+	}
+	return stored, nil
+}
+
+func (s *ServiceImpl) GetPin(ctx context.Context) (Pin, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Pin{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.validPin(ctx, userId)
+}
+
+func (s *ServiceImpl) DeletePin(ctx context.Context) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	if err := s.repo.DeletePin(ctx, userId); err != nil {
+		if errors.Is(err, ErrPinNotFound) {
+			return ErrPinNotSet
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *ServiceImpl) Switch(ctx context.Context, userUid string, pin string) (Session, user.User, error) {
+	target, err := s.users.GetUserByUid(ctx, userUid)
+	if err != nil {
+		return Session{}, user.User{}, err
+	}
+	if target.Disabled {
+		return Session{}, user.User{}, user.ErrUserDisabled
+	}
+	stored, err := s.validPin(ctx, target.Id)
+	if err != nil {
+		return Session{}, user.User{}, err
+	}
+
+	hash, err := hashPin(pin, stored.Salt)
+	if err != nil {
+		return Session{}, user.User{}, err
+	}
+	if subtle.ConstantTimeCompare(hash, stored.Hash) != 1 {
+		attempts, err := s.repo.RecordFailedAttempt(ctx, target.Id)
+		if err != nil {
+			return Session{}, user.User{}, err
+		}
+		if attempts >= MaxFailedAttempts {
+			log.Warnf("too many wrong pins for user %s, removing the pin", target.Uid)
+			if err := s.repo.DeletePin(ctx, target.Id); err != nil && !errors.Is(err, ErrPinNotFound) {
+				return Session{}, user.User{}, err
+			}
+		}
+		return Session{}, user.User{}, ErrWrongPin
+	}
+	if stored.FailedAttempts > 0 {
+		if err := s.repo.ResetFailedAttempts(ctx, target.Id); err != nil {
+			return Session{}, user.User{}, err
+		}
+	}
+
+	session, err := s.StartSession(ctx, target.Id, s.sessionTtl)
+	if err != nil {
+		return Session{}, user.User{}, err
+	}
+	return session, target, nil
+}
+
+func (s *ServiceImpl) StartSession(ctx context.Context, userId int, ttl time.Duration) (Session, error) {
+	now := s.clock.Now()
+	if deleted, err := s.repo.DeleteExpiredSessions(ctx, now); err != nil {
+		log.Errorf("failed to delete expired user switch sessions: %v", err)
+	} else if deleted > 0 {
+		log.Debugf("deleted %d expired user switch sessions", deleted)
+	}
+	return s.repo.CreateSession(ctx, userId, now.Add(ttl))
+}
+
+func (s *ServiceImpl) Authenticate(ctx context.Context, token string) (Session, user.User, error) {
+	session, err := s.repo.GetSession(ctx, token)
+	if err != nil {
+		return Session{}, user.User{}, err
+	}
+	if !s.clock.Now().Before(session.ExpiresAt) {
+		return Session{}, user.User{}, ErrSessionNotFound
+	}
+	sessionUser, err := s.users.GetUser(ctx, session.UserId)
+	if err != nil {
+		return Session{}, user.User{}, err
+	}
+	if sessionUser.Disabled {
+		return Session{}, user.User{}, user.ErrUserDisabled
+	}
+	return session, sessionUser, nil
+}
+
+func (s *ServiceImpl) EndSession(ctx context.Context, token string) error {
+	return s.repo.DeleteSession(ctx, token)
+}
+
+// validPin returns the user's PIN unless it is missing or expired.
+func (s *ServiceImpl) validPin(ctx context.Context, userId int) (Pin, error) {
+	pin, err := s.repo.GetPin(ctx, userId)
+	if err != nil {
+		if errors.Is(err, ErrPinNotFound) {
+			return Pin{}, ErrPinNotSet
+		}
+		return Pin{}, err
+	}
+	if !s.clock.Now().Before(pin.ExpiresAt) {
+		return Pin{}, ErrPinNotSet
+	}
+	return pin, nil
+}
+
+func isValidPin(pin string) bool {
+	if len(pin) < minPinLength || len(pin) > maxPinLength {
+		return false
+	}
+	for _, c := range pin {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func hashPin(pin string, salt []byte) ([]byte, error) {
+	hash, err := pbkdf2.Key(sha256.New, pin, salt, pinHashIterations, pinHashLength)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash pin: %w", err)
+	}
+	return hash, nil
+}
@@ -0,0 +1,197 @@
+package user_switch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type userProviderStub struct {
+	users []user.User
+}
+
+func (s *userProviderStub) GetUser(ctx context.Context, id int) (user.User, error) {
+	for _, u := range s.users {
+		if u.Id == id {
+			return u, nil
+		}
+	}
+	return user.User{}, user.ErrUserNotFound
+}
+
+func (s *userProviderStub) GetUserByUid(ctx context.Context, uid string) (user.User, error) {
+	for _, u := range s.users {
+		if u.Uid == uid {
+			return u, nil
+		}
+	}
+	return user.User{}, user.ErrUserNotFound
+}
+
+var parent = user.User{Id: 1, Uid: "parent-uid", Username: "parent", DisplayName: "Parent"}
+var child = user.User{Id: 2, Uid: "child-uid", Username: "child", DisplayName: "Child"}
+
+func setupServiceTest(t *testing.T) (*ServiceImpl, *RepositoryStub, *utils.MockClock) {
+	t.Helper()
+	repo := NewRepositoryStub()
+	service := NewService(repo, &userProviderStub{users: []user.User{parent, child}}, config.UserSwitch{
+		PinTtlHours:       24,
+		SessionTtlMinutes: 30,
+	})
+	clock := &utils.MockClock{FixedNow: time.Date(2026, 3, 2, 18, 0, 0, 0, time.UTC)}
+	service.clock = clock
+	return service, repo, clock
+}
+
+func TestServiceImpl_SetPin(t *testing.T) {
+	t.Run("should store a hashed pin expiring after the configured time", func(t *testing.T) {
+		service, repo, clock := setupServiceTest(t)
+
+		pin, err := service.SetPin(user.WithUser(context.Background(), child), "1234")
+
+		require.NoError(t, err)
+		assert.Equal(t, clock.Now().Add(24*time.Hour), pin.ExpiresAt)
+		stored, err := repo.GetPin(context.Background(), child.Id)
+		require.NoError(t, err)
+		assert.NotEqual(t, []byte("1234"), stored.Hash)
+		assert.Len(t, stored.Salt, pinSaltLength)
+	})
+
+	t.Run("should reject pins that are not 4 to 8 digits", func(t *testing.T) {
+		service, _, _ := setupServiceTest(t)
+		ctx := user.WithUser(context.Background(), child)
+
+		for _, pin := range []string{"", "123", "123456789", "12a4"} {
+			_, err := service.SetPin(ctx, pin)
+			assert.ErrorIs(t, err, ErrInvalidPin, pin)
+		}
+	})
+
+	t.Run("should report an expired pin as not set", func(t *testing.T) {
+		service, _, clock := setupServiceTest(t)
+		ctx := user.WithUser(context.Background(), child)
+		_, err := service.SetPin(ctx, "1234")
+		require.NoError(t, err)
+
+		clock.SetNow(clock.Now().Add(24 * time.Hour))
+		_, err = service.GetPin(ctx)
+
+		assert.ErrorIs(t, err, ErrPinNotSet)
+	})
+}
+
+func TestServiceImpl_Switch(t *testing.T) {
+	t.Run("should issue a session for the correct pin", func(t *testing.T) {
+		service, _, clock := setupServiceTest(t)
+		_, err := service.SetPin(user.WithUser(context.Background(), child), "1234")
+		require.NoError(t, err)
+
+		session, switchedTo, err := service.Switch(context.Background(), child.Uid, "1234")
+
+		require.NoError(t, err)
+		assert.Equal(t, child.Uid, switchedTo.Uid)
+		assert.Equal(t, clock.Now().Add(30*time.Minute), session.ExpiresAt)
+		authenticated, sessionUser, err := service.Authenticate(context.Background(), session.Token)
+		require.NoError(t, err)
+		assert.Equal(t, session.Token, authenticated.Token)
+		assert.Equal(t, child.Id, sessionUser.Id)
+	})
+
+	t.Run("should reject a wrong pin and remove the pin after too many attempts", func(t *testing.T) {
+		service, repo, _ := setupServiceTest(t)
+		_, err := service.SetPin(user.WithUser(context.Background(), child), "1234")
+		require.NoError(t, err)
+
+		for i := 0; i < MaxFailedAttempts-1; i++ {
+			_, _, err = service.Switch(context.Background(), child.Uid, "4321")
+			assert.ErrorIs(t, err, ErrWrongPin)
+		}
+		stored, err := repo.GetPin(context.Background(), child.Id)
+		require.NoError(t, err)
+		assert.Equal(t, MaxFailedAttempts-1, stored.FailedAttempts)
+
+		_, _, err = service.Switch(context.Background(), child.Uid, "4321")
+		assert.ErrorIs(t, err, ErrWrongPin)
+		_, _, err = service.Switch(context.Background(), child.Uid, "1234")
+		assert.ErrorIs(t, err, ErrPinNotSet)
+	})
+
+	t.Run("should reset failed attempts after the correct pin", func(t *testing.T) {
+		service, repo, _ := setupServiceTest(t)
+		_, err := service.SetPin(user.WithUser(context.Background(), child), "1234")
+		require.NoError(t, err)
+		_, _, err = service.Switch(context.Background(), child.Uid, "0000")
+		require.ErrorIs(t, err, ErrWrongPin)
+
+		_, _, err = service.Switch(context.Background(), child.Uid, "1234")
+
+		require.NoError(t, err)
+		stored, err := repo.GetPin(context.Background(), child.Id)
+		require.NoError(t, err)
+		assert.Equal(t, 0, stored.FailedAttempts)
+	})
+
+	t.Run("should reject users without a pin", func(t *testing.T) {
+		service, _, _ := setupServiceTest(t)
+
+		_, _, err := service.Switch(context.Background(), parent.Uid, "1234")
+		assert.ErrorIs(t, err, ErrPinNotSet)
+
+		_, _, err = service.Switch(context.Background(), "unknown-uid", "1234")
+		assert.ErrorIs(t, err, user.ErrUserNotFound)
+	})
+
+	t.Run("should reject disabled users", func(t *testing.T) {
+		service, _, _ := setupServiceTest(t)
+		_, err := service.SetPin(user.WithUser(context.Background(), child), "1234")
+		require.NoError(t, err)
+		disabledChild := child
+		disabledChild.Disabled = true
+		service.users = &userProviderStub{users: []user.User{parent, disabledChild}}
+
+		_, _, err = service.Switch(context.Background(), child.Uid, "1234")
+
+		assert.ErrorIs(t, err, user.ErrUserDisabled)
+	})
+}
+
+func TestServiceImpl_Authenticate(t *testing.T) {
+	t.Run("should reject expired and ended sessions", func(t *testing.T) {
+		service, _, clock := setupServiceTest(t)
+		_, err := service.SetPin(user.WithUser(context.Background(), child), "1234")
+		require.NoError(t, err)
+		expiring, _, err := service.Switch(context.Background(), child.Uid, "1234")
+		require.NoError(t, err)
+		ended, _, err := service.Switch(context.Background(), child.Uid, "1234")
+		require.NoError(t, err)
+
+		require.NoError(t, service.EndSession(context.Background(), ended.Token))
+		_, _, err = service.Authenticate(context.Background(), ended.Token)
+		assert.ErrorIs(t, err, ErrSessionNotFound)
+
+		clock.SetNow(clock.Now().Add(30 * time.Minute))
+		_, _, err = service.Authenticate(context.Background(), expiring.Token)
+		assert.ErrorIs(t, err, ErrSessionNotFound)
+	})
+
+	t.Run("should reject sessions of disabled users", func(t *testing.T) {
+		service, _, _ := setupServiceTest(t)
+		_, err := service.SetPin(user.WithUser(context.Background(), child), "1234")
+		require.NoError(t, err)
+		session, _, err := service.Switch(context.Background(), child.Uid, "1234")
+		require.NoError(t, err)
+		disabledChild := child
+		disabledChild.Disabled = true
+		service.users = &userProviderStub{users: []user.User{parent, disabledChild}}
+
+		_, _, err = service.Authenticate(context.Background(), session.Token)
+
+		assert.ErrorIs(t, err, user.ErrUserDisabled)
+	})
+}
@@ -0,0 +1,39 @@
+package user_switch
+
+import (
+	"context"
+	"time"
+)
+
+// Pin lets other members of a household switch to the user on a shared device.
+type Pin struct {
+	UserId int
+	Hash   []byte
+	Salt   []byte
+	// FailedAttempts counts wrong PINs entered since the PIN was set or last used
+	FailedAttempts int
+	ExpiresAt      time.Time
+}
+
+// Session is issued by switching to a user and is used instead of the X-User-Id header.
+type Session struct {
+	Token     string
+	UserId    int
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type contextKey string
+
+const sessionKey contextKey = "userSwitchSession"
+
+// WithSession marks the context as authenticated by a switch session.
+func WithSession(ctx context.Context, session Session) context.Context {
+	return context.WithValue(ctx, sessionKey, session)
+}
+
+// CurrentSession returns the switch session the request is authenticated with, if any.
+func CurrentSession(ctx context.Context) (Session, bool) {
+	session, ok := ctx.Value(sessionKey).(Session)
+	return session, ok
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/pkg/user"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -18,6 +19,8 @@ type WebhookDTO struct {
 	Token      string          `json:"token"`
 	WebhookUrl string          `json:"webhookUrl"`
 	Data       json.RawMessage `json:"data" swaggertype:"object"`
+	// PreviousTokenExpiresAt is set while the token replaced by the last rotation is still accepted
+	PreviousTokenExpiresAt *time.Time `json:"previousTokenExpiresAt,omitempty"`
 }
 type Handler struct {
 	appHost string
@@ -38,10 +41,12 @@ func NewHandler(appHost string, service Service) *Handler {
 // @Accept json
 // @Produce json
 // @Param token path string true "Webhook Token"
+// @Description When the previous token of a rotated webhook is used, the Sunset header tells when it stops working.
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "Webhook owner is disabled"
 // @Failure 404 {string} string "Invalid webhook token"
-// @Router /webhook/{token} [post]
+// @Router /api/webhook/{token} [post]
 func (h *Handler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	log.Debug("Webhook request received")
@@ -55,12 +60,16 @@ func (h *Handler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Execute webhook
-	err := h.service.Execute(r.Context(), token)
+	webhook, err := h.service.Execute(r.Context(), token)
 	if err != nil {
 		if errors.Is(err, ErrWebhookNotFound) {
 			http.Error(w, "Invalid webhook token", http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, user.ErrUserDisabled) {
+			http.Error(w, "Webhook owner is disabled", http.StatusForbidden)
+			return
+		}
 		log.Errorf("Failed to execute webhook: %v", err)
 		http.Error(w, "Failed to execute webhook", http.StatusInternalServerError)
 		return
@@ -71,6 +80,12 @@ func (h *Handler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 		"message":   "Webhook executed successfully",
 		"timestamp": time.Now().Format(time.RFC3339),
 	}
+	// Automations still using the previous token are told when it stops working
+	if token != webhook.Token && webhook.PreviousTokenExpiresAt != nil {
+		w.Header().Set("Sunset", webhook.PreviousTokenExpiresAt.UTC().Format(http.TimeFormat))
+		response["warning"] = fmt.Sprintf("This webhook token was rotated and stops working at %s, use the new webhook URL",
+			webhook.PreviousTokenExpiresAt.Format(time.RFC3339))
+	}
 
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
@@ -199,12 +214,71 @@ func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// RotateWebhookToken godoc
+// @Summary Rotate the token of a webhook
+// @Description Issue a new token for a webhook. The current token keeps working for the overlap window,
+// @Description so automations can switch to the new webhook URL without a hard cutover.
+// @Tags Webhook
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param overlapHours query int false "Hours the current token stays valid (0-720), defaults to the server setting"
+// @Success 200 {object} WebhookDTO
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Webhook not found"
+// @Router /api/webhook/{id}/rotate [post]
+// @Security XUserId
+func (h *Handler) RotateWebhookToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	webhookId, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+	var overlap *time.Duration
+	if overlapHours := r.URL.Query().Get("overlapHours"); overlapHours != "" {
+		hours, err := strconv.Atoi(overlapHours)
+		if err != nil {
+			http.Error(w, "Invalid overlapHours parameter", http.StatusBadRequest)
+			return
+		}
+		duration := time.Duration(hours) * time.Hour
+		overlap = &duration
+	}
+
+	webhook, err := h.service.RotateToken(r.Context(), webhookId, overlap)
+	if err != nil {
+		if errors.Is(err, ErrInvalidRotationOverlap) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, ErrWebhookNotFound) {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to rotate webhook token: %v", err)
+		http.Error(w, "Failed to rotate webhook token", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(h.webhookToDTO(webhook)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
 func (h *Handler) webhookToDTO(webhook Webhook) WebhookDTO {
-	return WebhookDTO{
+	dto := WebhookDTO{
 		Id:         webhook.Id,
 		Type:       webhook.Type,
 		Token:      webhook.Token,
 		WebhookUrl: fmt.Sprintf("%s/api/webhook/%s", h.appHost, webhook.Token),
 		Data:       webhook.Data,
 	}
+	if webhook.PreviousTokenValid(time.Now()) {
+		dto.PreviousTokenExpiresAt = webhook.PreviousTokenExpiresAt
+	}
+	return dto
 }
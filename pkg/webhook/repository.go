@@ -6,7 +6,9 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -14,12 +16,24 @@ var ErrWebhookNotFound = errors.New("webhook not found")
 
 type Repository interface {
 	Create(ctx context.Context, webhook Webhook) (Webhook, error)
+	// GetByToken returns the webhook with the given token, or with the given previous token while it is still valid.
 	GetByToken(ctx context.Context, token string) (Webhook, error)
 	GetByUserIdAndType(ctx context.Context, userId int, webhookType WebhookType) ([]Webhook, error)
-	RotateToken(ctx context.Context, webhookId int, userId int) (string, error)
+	// RotateToken issues a new token and keeps the current one valid as the previous token until previousTokenExpiresAt.
+	RotateToken(ctx context.Context, webhookId int, userId int, previousTokenExpiresAt time.Time) (Webhook, error)
 	Delete(ctx context.Context, webhookId int, userId int) error
 }
 
+const webhookColumns = `id, type, token, user_id, data, created_at, updated_at,
+	          COALESCE(previous_token, ''), previous_token_expires_at`
+
+func scanWebhook(row pgx.Row) (Webhook, error) {
+	var webhook Webhook
+	err := row.Scan(&webhook.Id, &webhook.Type, &webhook.Token, &webhook.UserId, &webhook.Data, &webhook.CreatedAt,
+		&webhook.UpdatedAt, &webhook.PreviousToken, &webhook.PreviousTokenExpiresAt)
+	return webhook, err
+}
+
 type RepositoryImpl struct {
 	db *pgxpool.Pool
 }
@@ -37,12 +51,9 @@ func (r *RepositoryImpl) Create(ctx context.Context, webhook Webhook) (Webhook,
 
 	query := `INSERT INTO webhooks (type, token, user_id, data)
 	          VALUES ($1, $2, $3, $4)
-	          RETURNING id, type, token, user_id, data, created_at, updated_at`
-
-	var result Webhook
-	err = r.db.QueryRow(ctx, query, webhook.Type, token, webhook.UserId, webhook.Data).
-		Scan(&result.Id, &result.Type, &result.Token, &result.UserId, &result.Data, &result.CreatedAt, &result.UpdatedAt)
+	          RETURNING ` + webhookColumns
 
+	result, err := scanWebhook(r.db.QueryRow(ctx, query, webhook.Type, token, webhook.UserId, webhook.Data))
 	if err != nil {
 		return Webhook{}, fmt.Errorf("failed to create webhook: %w", err)
 	}
@@ -51,14 +62,11 @@ func (r *RepositoryImpl) Create(ctx context.Context, webhook Webhook) (Webhook,
 }
 
 func (r *RepositoryImpl) GetByToken(ctx context.Context, token string) (Webhook, error) {
-	query := `SELECT id, type, token, user_id, data, created_at, updated_at
+	query := `SELECT ` + webhookColumns + `
 	          FROM webhooks
-	          WHERE token = $1`
-
-	var webhook Webhook
-	err := r.db.QueryRow(ctx, query, token).
-		Scan(&webhook.Id, &webhook.Type, &webhook.Token, &webhook.UserId, &webhook.Data, &webhook.CreatedAt, &webhook.UpdatedAt)
+	          WHERE token = $1 OR (previous_token = $1 AND previous_token_expires_at > NOW())`
 
+	webhook, err := scanWebhook(r.db.QueryRow(ctx, query, token))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return Webhook{}, ErrWebhookNotFound
@@ -70,7 +78,7 @@ func (r *RepositoryImpl) GetByToken(ctx context.Context, token string) (Webhook,
 }
 
 func (r *RepositoryImpl) GetByUserIdAndType(ctx context.Context, userId int, webhookType WebhookType) ([]Webhook, error) {
-	query := `SELECT id, type, token, user_id, data, created_at, updated_at
+	query := `SELECT ` + webhookColumns + `
 	          FROM webhooks
 	          WHERE user_id = $1 AND type = $2
 	          ORDER BY created_at DESC`
@@ -83,8 +91,7 @@ func (r *RepositoryImpl) GetByUserIdAndType(ctx context.Context, userId int, web
 
 	var webhooks []Webhook
 	for rows.Next() {
-		var webhook Webhook
-		err := rows.Scan(&webhook.Id, &webhook.Type, &webhook.Token, &webhook.UserId, &webhook.Data, &webhook.CreatedAt, &webhook.UpdatedAt)
+		webhook, err := scanWebhook(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan webhook: %w", err)
 		}
@@ -98,28 +105,28 @@ func (r *RepositoryImpl) GetByUserIdAndType(ctx context.Context, userId int, web
 	return webhooks, nil
 }
 
-func (r *RepositoryImpl) RotateToken(ctx context.Context, webhookId int, userId int) (string, error) {
+func (r *RepositoryImpl) RotateToken(ctx context.Context, webhookId int, userId int, previousTokenExpiresAt time.Time) (Webhook, error) {
 	// Generate a new secure random token
 	token, err := generateToken()
 	if err != nil {
-		return "", fmt.Errorf("failed to generate token: %w", err)
+		return Webhook{}, fmt.Errorf("failed to generate token: %w", err)
 	}
 
+	// A token replaced by an earlier rotation stops working now, only the current one is kept
 	query := `UPDATE webhooks
-	          SET token = $1, updated_at = NOW()
-	          WHERE id = $2 AND user_id = $3
-	          RETURNING token`
+	          SET previous_token = token, previous_token_expires_at = $2, token = $1, updated_at = NOW()
+	          WHERE id = $3 AND user_id = $4
+	          RETURNING ` + webhookColumns
 
-	var newToken string
-	err = r.db.QueryRow(ctx, query, token, webhookId, userId).Scan(&newToken)
+	webhook, err := scanWebhook(r.db.QueryRow(ctx, query, token, previousTokenExpiresAt, webhookId, userId))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", ErrWebhookNotFound
+			return Webhook{}, ErrWebhookNotFound
 		}
-		return "", fmt.Errorf("failed to rotate token: %w", err)
+		return Webhook{}, fmt.Errorf("failed to rotate token: %w", err)
 	}
 
-	return newToken, nil
+	return webhook, nil
 }
 
 func (r *RepositoryImpl) Delete(ctx context.Context, webhookId int, userId int) error {
@@ -60,6 +60,11 @@ func (r *RepositoryStub) GetByToken(ctx context.Context, token string) (Webhook,
 
 	id, exists := r.tokens[token]
 	if !exists {
+		for _, webhook := range r.webhooks {
+			if webhook.PreviousToken == token && webhook.PreviousTokenValid(time.Now()) {
+				return webhook, nil
+			}
+		}
 		return Webhook{}, ErrWebhookNotFound
 	}
 
@@ -94,31 +99,33 @@ func (r *RepositoryStub) GetByUserIdAndType(ctx context.Context, userId int, web
 	return result, nil
 }
 
-func (r *RepositoryStub) RotateToken(ctx context.Context, webhookId int, userId int) (string, error) {
+func (r *RepositoryStub) RotateToken(ctx context.Context, webhookId int, userId int, previousTokenExpiresAt time.Time) (Webhook, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	webhook, exists := r.webhooks[webhookId]
 	if !exists || r.userIds[webhookId] != userId {
-		return "", ErrWebhookNotFound
+		return Webhook{}, ErrWebhookNotFound
 	}
 
 	// Generate new token
 	newToken, err := r.generateToken()
 	if err != nil {
-		return "", err
+		return Webhook{}, err
 	}
 
-	// Remove old token mapping
+	// Remove old token mapping, the old token is found as the previous token until it expires
 	delete(r.tokens, webhook.Token)
 
 	// Update webhook with new token
+	webhook.PreviousToken = webhook.Token
+	webhook.PreviousTokenExpiresAt = &previousTokenExpiresAt
 	webhook.Token = newToken
 	webhook.UpdatedAt = time.Now()
 	r.webhooks[webhookId] = webhook
 	r.tokens[newToken] = webhookId
 
-	return newToken, nil
+	return webhook, nil
 }
 
 func (r *RepositoryStub) Delete(ctx context.Context, webhookId int, userId int) error {
@@ -271,20 +271,20 @@ func TestRepositoryImpl_RotateToken(t *testing.T) {
 		originalToken := created.Token
 
 		// when
-		newToken, err := repo.RotateToken(ctx, created.Id, userId)
+		rotated, err := repo.RotateToken(ctx, created.Id, userId, time.Now())
 
 		// then
 		require.NoError(t, err)
-		require.NotEmpty(t, newToken)
-		require.NotEqual(t, originalToken, newToken)
-		require.Equal(t, 64, len(newToken))
+		require.NotEmpty(t, rotated.Token)
+		require.NotEqual(t, originalToken, rotated.Token)
+		require.Equal(t, 64, len(rotated.Token))
 
 		// Verify old token no longer works
 		_, err = repo.GetByToken(ctx, originalToken)
 		require.ErrorIs(t, err, ErrWebhookNotFound)
 
 		// Verify new token works
-		retrieved, err := repo.GetByToken(ctx, newToken)
+		retrieved, err := repo.GetByToken(ctx, rotated.Token)
 		require.NoError(t, err)
 		require.Equal(t, created.Id, retrieved.Id)
 	})
@@ -295,7 +295,7 @@ func TestRepositoryImpl_RotateToken(t *testing.T) {
 		nonExistentId := 99999
 
 		// when
-		_, err := repo.RotateToken(ctx, nonExistentId, userId)
+		_, err := repo.RotateToken(ctx, nonExistentId, userId, time.Now())
 
 		// then
 		require.ErrorIs(t, err, ErrWebhookNotFound)
@@ -316,7 +316,7 @@ func TestRepositoryImpl_RotateToken(t *testing.T) {
 
 		// when
 		differentUserId := userId + 1
-		_, err = repo.RotateToken(ctx, created.Id, differentUserId)
+		_, err = repo.RotateToken(ctx, created.Id, differentUserId, time.Now())
 
 		// then
 		require.ErrorIs(t, err, ErrWebhookNotFound)
@@ -339,14 +339,42 @@ func TestRepositoryImpl_RotateToken(t *testing.T) {
 		time.Sleep(100 * time.Millisecond) // Ensure different timestamp
 
 		// when
-		newToken, err := repo.RotateToken(ctx, created.Id, userId)
+		rotated, err := repo.RotateToken(ctx, created.Id, userId, time.Now())
 		require.NoError(t, err)
 
 		// then
-		retrieved, err := repo.GetByToken(ctx, newToken)
+		retrieved, err := repo.GetByToken(ctx, rotated.Token)
 		require.NoError(t, err)
 		require.True(t, retrieved.UpdatedAt.After(originalUpdatedAt))
 	})
+
+	t.Run("should accept the previous token until it expires", func(t *testing.T) {
+		// given
+		ctx, repo, userId := setupTestRepository(t)
+		created, err := repo.Create(ctx, Webhook{
+			Type:   TypeStartCurrentEvent,
+			UserId: userId,
+			Data:   json.RawMessage(`{"budgetItemId": 42}`),
+		})
+		require.NoError(t, err)
+
+		// when
+		rotated, err := repo.RotateToken(ctx, created.Id, userId, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+
+		// then
+		require.Equal(t, created.Token, rotated.PreviousToken)
+		require.NotNil(t, rotated.PreviousTokenExpiresAt)
+		retrieved, err := repo.GetByToken(ctx, created.Token)
+		require.NoError(t, err)
+		require.Equal(t, rotated.Token, retrieved.Token)
+
+		// a second rotation replaces the previous token
+		_, err = repo.RotateToken(ctx, created.Id, userId, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		_, err = repo.GetByToken(ctx, created.Token)
+		require.ErrorIs(t, err, ErrWebhookNotFound)
+	})
 }
 
 func TestRepositoryImpl_Delete(t *testing.T) {
@@ -3,15 +3,23 @@ package webhook
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/internal/utils"
 	"github.com/klokku/klokku/pkg/budget_plan"
 	"github.com/klokku/klokku/pkg/current_event"
 	"github.com/klokku/klokku/pkg/user"
 	log "github.com/sirupsen/logrus"
 )
 
+// MaxRotationOverlap is the longest time the previous token can stay valid after rotation.
+const MaxRotationOverlap = 30 * 24 * time.Hour
+
+var ErrInvalidRotationOverlap = errors.New("rotation overlap must be between 0 and 30 days")
+
 type EventStarter interface {
 	StartNewEvent(ctx context.Context, event current_event.CurrentEvent) (current_event.CurrentEvent, error)
 }
@@ -27,9 +35,13 @@ type UserProvider interface {
 type Service interface {
 	Create(ctx context.Context, webhookType WebhookType, data interface{}) (Webhook, error)
 	GetByUserIdAndType(ctx context.Context, webhookType WebhookType) ([]Webhook, error)
-	RotateToken(ctx context.Context, webhookId int) (string, error)
+	// RotateToken issues a new token for the webhook. The current token stays valid for the overlap,
+	// or for the configured default overlap when it is nil.
+	RotateToken(ctx context.Context, webhookId int, overlap *time.Duration) (Webhook, error)
 	Delete(ctx context.Context, webhookId int) error
-	Execute(ctx context.Context, token string) error
+	// Execute runs the webhook with the given token and returns it. The token may be the previous token
+	// of a rotated webhook while its overlap lasts.
+	Execute(ctx context.Context, token string) (Webhook, error)
 }
 
 type ServiceImpl struct {
@@ -37,14 +49,25 @@ type ServiceImpl struct {
 	eventStarter  EventStarter
 	budgetService BudgetItemProvider
 	userService   UserProvider
+	clock         utils.Clock
+	// rotationOverlap is how long the previous token stays valid when the rotation does not say otherwise.
+	rotationOverlap time.Duration
 }
 
-func NewService(repo Repository, eventStarter EventStarter, budgetService BudgetItemProvider, userService UserProvider) Service {
+func NewService(
+	repo Repository,
+	eventStarter EventStarter,
+	budgetService BudgetItemProvider,
+	userService UserProvider,
+	cfg config.Webhook,
+) Service {
 	return &ServiceImpl{
-		repo:          repo,
-		eventStarter:  eventStarter,
-		budgetService: budgetService,
-		userService:   userService,
+		repo:            repo,
+		eventStarter:    eventStarter,
+		budgetService:   budgetService,
+		userService:     userService,
+		clock:           &utils.SystemClock{},
+		rotationOverlap: time.Duration(cfg.RotationOverlapHours) * time.Hour,
 	}
 }
 
@@ -83,13 +106,21 @@ func (s *ServiceImpl) GetByUserIdAndType(ctx context.Context, webhookType Webhoo
 	return s.repo.GetByUserIdAndType(ctx, userId, webhookType)
 }
 
-func (s *ServiceImpl) RotateToken(ctx context.Context, webhookId int) (string, error) {
+func (s *ServiceImpl) RotateToken(ctx context.Context, webhookId int, overlap *time.Duration) (Webhook, error) {
 	userId, err := user.CurrentId(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to get current user: %w", err)
+		return Webhook{}, fmt.Errorf("failed to get current user: %w", err)
 	}
 
-	return s.repo.RotateToken(ctx, webhookId, userId)
+	rotationOverlap := s.rotationOverlap
+	if overlap != nil {
+		rotationOverlap = *overlap
+	}
+	if rotationOverlap < 0 || rotationOverlap > MaxRotationOverlap {
+		return Webhook{}, ErrInvalidRotationOverlap
+	}
+
+	return s.repo.RotateToken(ctx, webhookId, userId, s.clock.Now().Add(rotationOverlap))
 }
 
 func (s *ServiceImpl) Delete(ctx context.Context, webhookId int) error {
@@ -101,17 +132,24 @@ func (s *ServiceImpl) Delete(ctx context.Context, webhookId int) error {
 	return s.repo.Delete(ctx, webhookId, userId)
 }
 
-func (s *ServiceImpl) Execute(ctx context.Context, token string) error {
+func (s *ServiceImpl) Execute(ctx context.Context, token string) (Webhook, error) {
 	// Get webhook by token (no user context required)
 	webhook, err := s.repo.GetByToken(ctx, token)
 	if err != nil {
-		return err
+		return Webhook{}, err
+	}
+	if token != webhook.Token {
+		log.Infof("Webhook %d executed with its previous token, valid until %s", webhook.Id,
+			webhook.PreviousTokenExpiresAt.Format(time.RFC3339))
 	}
 
 	// Get user to create proper context for service calls
 	userObj, err := s.userService.GetUser(ctx, webhook.UserId)
 	if err != nil {
-		return fmt.Errorf("failed to get user: %w", err)
+		return Webhook{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	if userObj.Disabled {
+		return Webhook{}, user.ErrUserDisabled
 	}
 
 	// Create context with user
@@ -120,9 +158,9 @@ func (s *ServiceImpl) Execute(ctx context.Context, token string) error {
 	// Execute based on webhook type
 	switch webhook.Type {
 	case TypeStartCurrentEvent:
-		return s.executeStartCurrentEvent(userCtx, webhook)
+		return webhook, s.executeStartCurrentEvent(userCtx, webhook)
 	default:
-		return fmt.Errorf("unsupported webhook type: %s", webhook.Type)
+		return Webhook{}, fmt.Errorf("unsupported webhook type: %s", webhook.Type)
 	}
 }
 
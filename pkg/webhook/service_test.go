@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/klokku/klokku/internal/config"
 	"github.com/klokku/klokku/pkg/budget_plan"
 	"github.com/klokku/klokku/pkg/user"
 	"github.com/stretchr/testify/assert"
@@ -35,7 +36,7 @@ var userProviderStub = NewUserProviderStub()
 var service Service
 
 func setup(t *testing.T) func() {
-	service = NewService(repoStub, eventStarterStub, budgetProviderStub, userProviderStub)
+	service = NewService(repoStub, eventStarterStub, budgetProviderStub, userProviderStub, config.Webhook{RotationOverlapHours: 24})
 	return func() {
 		t.Log("Teardown after test")
 		repoStub.Reset()
@@ -134,6 +135,8 @@ func TestServiceImpl_GetByUserIdAndType(t *testing.T) {
 }
 
 func TestServiceImpl_RotateToken(t *testing.T) {
+	noOverlap := time.Duration(0)
+
 	t.Run("should rotate token", func(t *testing.T) {
 		teardown := setup(t)
 		defer teardown()
@@ -144,24 +147,83 @@ func TestServiceImpl_RotateToken(t *testing.T) {
 		originalToken := webhook.Token
 
 		// when
-		newToken, err := service.RotateToken(ctx, webhook.Id)
+		rotated, err := service.RotateToken(ctx, webhook.Id, &noOverlap)
 
 		// then
 		require.NoError(t, err)
-		assert.NotEmpty(t, newToken)
-		assert.NotEqual(t, originalToken, newToken)
-		assert.Len(t, newToken, 64) // 32 bytes = 64 hex chars
+		assert.NotEmpty(t, rotated.Token)
+		assert.NotEqual(t, originalToken, rotated.Token)
+		assert.Len(t, rotated.Token, 64) // 32 bytes = 64 hex chars
 
 		// Verify old token doesn't work
 		_, err = repoStub.GetByToken(ctx, originalToken)
 		assert.ErrorIs(t, err, ErrWebhookNotFound)
 
 		// Verify new token works
-		retrieved, err := repoStub.GetByToken(ctx, newToken)
+		retrieved, err := repoStub.GetByToken(ctx, rotated.Token)
 		require.NoError(t, err)
 		assert.Equal(t, webhook.Id, retrieved.Id)
 	})
 
+	t.Run("should keep the old token valid for the default overlap", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		webhook, err := service.Create(ctx, TypeStartCurrentEvent, StartCurrentEventData{BudgetItemId: 42})
+		require.NoError(t, err)
+
+		// when
+		rotated, err := service.RotateToken(ctx, webhook.Id, nil)
+
+		// then
+		require.NoError(t, err)
+		require.NotNil(t, rotated.PreviousTokenExpiresAt)
+		assert.WithinDuration(t, time.Now().Add(24*time.Hour), *rotated.PreviousTokenExpiresAt, time.Minute)
+		assert.Equal(t, webhook.Token, rotated.PreviousToken)
+		retrieved, err := repoStub.GetByToken(ctx, webhook.Token)
+		require.NoError(t, err)
+		assert.Equal(t, rotated.Token, retrieved.Token)
+	})
+
+	t.Run("should execute the webhook with the old token during the overlap", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		webhook, err := service.Create(ctx, TypeStartCurrentEvent, StartCurrentEventData{BudgetItemId: 42})
+		require.NoError(t, err)
+		budgetProviderStub.SetItem(42, budget_plan.BudgetItem{Id: 42, Name: "Work"})
+		userProviderStub.SetUser(10, user.User{Id: 10})
+		overlap := time.Hour
+		rotated, err := service.RotateToken(ctx, webhook.Id, &overlap)
+		require.NoError(t, err)
+
+		// when
+		executed, err := service.Execute(context.Background(), webhook.Token)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, rotated.Token, executed.Token)
+		assert.Len(t, eventStarterStub.GetStartedEvents(), 1)
+	})
+
+	t.Run("should reject an overlap longer than the maximum", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		webhook, err := service.Create(ctx, TypeStartCurrentEvent, StartCurrentEventData{BudgetItemId: 42})
+		require.NoError(t, err)
+		overlap := MaxRotationOverlap + time.Hour
+
+		// when
+		_, err = service.RotateToken(ctx, webhook.Id, &overlap)
+
+		// then
+		require.ErrorIs(t, err, ErrInvalidRotationOverlap)
+		retrieved, err := repoStub.GetByToken(ctx, webhook.Token)
+		require.NoError(t, err)
+		assert.Empty(t, retrieved.PreviousToken)
+	})
+
 	t.Run("should return error when user not in context", func(t *testing.T) {
 		teardown := setup(t)
 		defer teardown()
@@ -169,7 +231,7 @@ func TestServiceImpl_RotateToken(t *testing.T) {
 		emptyCtx := context.Background()
 
 		// when
-		_, err := service.RotateToken(emptyCtx, 1)
+		_, err := service.RotateToken(emptyCtx, 1, nil)
 
 		// then
 		require.Error(t, err)
@@ -240,7 +302,7 @@ func TestServiceImpl_Execute(t *testing.T) {
 		budgetProviderStub.SetItem(budgetItemId, budgetItem)
 
 		// when
-		err = service.Execute(context.Background(), webhook.Token)
+		_, err = service.Execute(context.Background(), webhook.Token)
 
 		// then
 		require.NoError(t, err)
@@ -258,7 +320,7 @@ func TestServiceImpl_Execute(t *testing.T) {
 		defer teardown()
 
 		// when
-		err := service.Execute(context.Background(), "invalid-token")
+		_, err := service.Execute(context.Background(), "invalid-token")
 
 		// then
 		require.ErrorIs(t, err, ErrWebhookNotFound)
@@ -282,7 +344,7 @@ func TestServiceImpl_Execute(t *testing.T) {
 		userProviderStub.SetUser(10, testUser)
 
 		// when
-		err = service.Execute(context.Background(), created.Token)
+		_, err = service.Execute(context.Background(), created.Token)
 
 		// then
 		require.Error(t, err)
@@ -305,7 +367,7 @@ func TestServiceImpl_Execute(t *testing.T) {
 		// Don't setup user stub - user not found
 
 		// when
-		err = service.Execute(context.Background(), created.Token)
+		_, err = service.Execute(context.Background(), created.Token)
 
 		// then
 		require.Error(t, err)
@@ -325,7 +387,7 @@ func TestServiceImpl_Execute(t *testing.T) {
 		userProviderStub.SetUser(10, testUser)
 
 		// when
-		err = service.Execute(context.Background(), webhook.Token)
+		_, err = service.Execute(context.Background(), webhook.Token)
 
 		// then
 		require.Error(t, err)
@@ -350,7 +412,7 @@ func TestServiceImpl_Execute(t *testing.T) {
 		userProviderStub.SetUser(10, testUser)
 
 		// when
-		err = service.Execute(context.Background(), created.Token)
+		_, err = service.Execute(context.Background(), created.Token)
 
 		// then
 		require.Error(t, err)
@@ -21,6 +21,15 @@ type Webhook struct {
 	Data      json.RawMessage
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	// PreviousToken is the token replaced by the last rotation. It is accepted until PreviousTokenExpiresAt,
+	// so automations can switch to the new token without a hard cutover.
+	PreviousToken          string
+	PreviousTokenExpiresAt *time.Time
+}
+
+// PreviousTokenValid reports whether the token replaced by the last rotation is still accepted at the given time.
+func (w Webhook) PreviousTokenValid(now time.Time) bool {
+	return w.PreviousToken != "" && w.PreviousTokenExpiresAt != nil && now.Before(*w.PreviousTokenExpiresAt)
 }
 
 // StartCurrentEventData is the data structure for START_CURRENT_EVENT webhook type
@@ -0,0 +1,284 @@
+package webhook_subscription
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/internal/rest"
+	log "github.com/sirupsen/logrus"
+)
+
+type SubscriptionDTO struct {
+	Id  int    `json:"id"`
+	Url string `json:"url"`
+	// Secret signs the deliveries. It is returned only when the subscription is created; when empty on create
+	// it is generated, when empty on update the current one is kept.
+	Secret     string   `json:"secret,omitempty"`
+	EventTypes []string `json:"eventTypes"`
+	// Enabled defaults to true
+	Enabled   *bool     `json:"enabled,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type DeliveryDTO struct {
+	Id             int64           `json:"id"`
+	EventType      string          `json:"eventType"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	Status         string          `json:"status" enums:"pending,succeeded,failed"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"nextAttemptAt,omitempty"`
+	ResponseStatus int             `json:"responseStatus,omitempty"`
+	LastError      string          `json:"lastError,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt"`
+}
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// ListSubscriptions godoc
+// @Summary List webhook subscriptions
+// @Description List the URLs the current user's events are delivered to
+// @Tags WebhookSubscription
+// @Produce json
+// @Success 200 {array} SubscriptionDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/webhook-subscriptions [get]
+// @Security XUserId
+func (h *Handler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	subscriptions, err := h.service.ListSubscriptions(r.Context())
+	if err != nil {
+		log.Errorf("Failed to list webhook subscriptions: %v", err)
+		http.Error(w, "Failed to list webhook subscriptions", http.StatusInternalServerError)
+		return
+	}
+	dtos := make([]SubscriptionDTO, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		dtos = append(dtos, subscriptionToDTO(subscription, false))
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		log.Errorf("Failed to encode webhook subscriptions: %v", err)
+		http.Error(w, "Failed to encode webhook subscriptions", http.StatusInternalServerError)
+	}
+}
+
+// CreateSubscription godoc
+// @Summary Create a webhook subscription
+// @Description Register a URL the events of the current user are POSTed to. Supported event types are
+// @Description calendar.event.created, budget_plan.item.updated, current_event.started and current_event.stopped,
+// @Description no event types subscribe to all of them. Each request carries the X-Klokku-Signature header
+// @Description "t=<unix time>,v1=<hex HMAC-SHA256 of '<unix time>.<body>' keyed with the secret>".
+// @Description Failed deliveries are retried up to 5 times with growing delays.
+// @Tags WebhookSubscription
+// @Accept json
+// @Produce json
+// @Param subscription body SubscriptionDTO true "Subscription"
+// @Success 201 {object} SubscriptionDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid subscription"
+// @Failure 403 {string} string "User not found"
+// @Router /api/webhook-subscriptions [post]
+// @Security XUserId
+func (h *Handler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var subscriptionDTO SubscriptionDTO
+	if err := json.NewDecoder(r.Body).Decode(&subscriptionDTO); err != nil {
+		writeBadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	created, err := h.service.CreateSubscription(r.Context(), dtoToSubscription(subscriptionDTO))
+	if err != nil {
+		if errors.Is(err, ErrInvalidSubscription) {
+			writeBadRequest(w, "Invalid webhook subscription", err.Error())
+			return
+		}
+		log.Errorf("Failed to create webhook subscription: %v", err)
+		http.Error(w, "Failed to create webhook subscription", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(subscriptionToDTO(created, true)); err != nil {
+		log.Errorf("Failed to encode webhook subscription: %v", err)
+	}
+}
+
+// UpdateSubscription godoc
+// @Summary Update a webhook subscription
+// @Description Change the URL, event types or enabled state of a subscription, or rotate its secret.
+// @Description Pending deliveries of a disabled subscription fail on their next attempt.
+// @Tags WebhookSubscription
+// @Accept json
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Param subscription body SubscriptionDTO true "Subscription"
+// @Success 200 {object} SubscriptionDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid subscription"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Subscription not found"
+// @Router /api/webhook-subscriptions/{id} [put]
+// @Security XUserId
+func (h *Handler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid subscription ID", http.StatusBadRequest)
+		return
+	}
+	var subscriptionDTO SubscriptionDTO
+	if err := json.NewDecoder(r.Body).Decode(&subscriptionDTO); err != nil {
+		writeBadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	subscription := dtoToSubscription(subscriptionDTO)
+	subscription.Id = id
+
+	updated, err := h.service.UpdateSubscription(r.Context(), subscription)
+	if err != nil {
+		if errors.Is(err, ErrInvalidSubscription) {
+			writeBadRequest(w, "Invalid webhook subscription", err.Error())
+			return
+		}
+		if errors.Is(err, ErrSubscriptionNotFound) {
+			http.Error(w, "Subscription not found", http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to update webhook subscription: %v", err)
+		http.Error(w, "Failed to update webhook subscription", http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(subscriptionToDTO(updated, false)); err != nil {
+		log.Errorf("Failed to encode webhook subscription: %v", err)
+		http.Error(w, "Failed to encode webhook subscription", http.StatusInternalServerError)
+	}
+}
+
+// DeleteSubscription godoc
+// @Summary Delete a webhook subscription
+// @Description Delete the subscription together with its delivery log
+// @Tags WebhookSubscription
+// @Param id path int true "Subscription ID"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Subscription not found"
+// @Router /api/webhook-subscriptions/{id} [delete]
+// @Security XUserId
+func (h *Handler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid subscription ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.DeleteSubscription(r.Context(), id); err != nil {
+		if errors.Is(err, ErrSubscriptionNotFound) {
+			http.Error(w, "Subscription not found", http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to delete webhook subscription: %v", err)
+		http.Error(w, "Failed to delete webhook subscription", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries godoc
+// @Summary List webhook deliveries
+// @Description The delivery log of the subscription, the latest 100 deliveries, newest first
+// @Tags WebhookSubscription
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Success 200 {array} DeliveryDTO
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Subscription not found"
+// @Router /api/webhook-subscriptions/{id}/deliveries [get]
+// @Security XUserId
+func (h *Handler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid subscription ID", http.StatusBadRequest)
+		return
+	}
+	deliveries, err := h.service.ListDeliveries(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrSubscriptionNotFound) {
+			http.Error(w, "Subscription not found", http.StatusNotFound)
+			return
+		}
+		log.Errorf("Failed to list webhook deliveries: %v", err)
+		http.Error(w, "Failed to list webhook deliveries", http.StatusInternalServerError)
+		return
+	}
+	dtos := make([]DeliveryDTO, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		dtos = append(dtos, DeliveryDTO{
+			Id:             delivery.Id,
+			EventType:      string(delivery.EventType),
+			Payload:        delivery.Payload,
+			Status:         string(delivery.Status),
+			Attempts:       delivery.Attempts,
+			NextAttemptAt:  delivery.NextAttemptAt,
+			ResponseStatus: delivery.ResponseStatus,
+			LastError:      delivery.LastError,
+			CreatedAt:      delivery.CreatedAt,
+			UpdatedAt:      delivery.UpdatedAt,
+		})
+	}
+	if err := json.NewEncoder(w).Encode(dtos); err != nil {
+		log.Errorf("Failed to encode webhook deliveries: %v", err)
+		http.Error(w, "Failed to encode webhook deliveries", http.StatusInternalServerError)
+	}
+}
+
+func subscriptionToDTO(subscription Subscription, withSecret bool) SubscriptionDTO {
+	dto := SubscriptionDTO{
+		Id:         subscription.Id,
+		Url:        subscription.Url,
+		EventTypes: make([]string, 0, len(subscription.EventTypes)),
+		Enabled:    &subscription.Enabled,
+		CreatedAt:  subscription.CreatedAt,
+	}
+	for _, eventType := range subscription.EventTypes {
+		dto.EventTypes = append(dto.EventTypes, string(eventType))
+	}
+	if withSecret {
+		dto.Secret = subscription.Secret
+	}
+	return dto
+}
+
+func dtoToSubscription(dto SubscriptionDTO) Subscription {
+	subscription := Subscription{
+		Id:      dto.Id,
+		Url:     dto.Url,
+		Secret:  dto.Secret,
+		Enabled: dto.Enabled == nil || *dto.Enabled,
+	}
+	for _, eventType := range dto.EventTypes {
+		subscription.EventTypes = append(subscription.EventTypes, EventType(eventType))
+	}
+	return subscription
+}
+
+func writeBadRequest(w http.ResponseWriter, errMsg, details string) {
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+		Error:   errMsg,
+		Details: details,
+	})
+}
@@ -0,0 +1,252 @@
+package webhook_subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+
+type Repository interface {
+	ListSubscriptions(ctx context.Context, userId int) ([]Subscription, error)
+	GetSubscription(ctx context.Context, userId int, id int) (Subscription, error)
+	CreateSubscription(ctx context.Context, subscription Subscription) (Subscription, error)
+	UpdateSubscription(ctx context.Context, subscription Subscription) (Subscription, error)
+	// DeleteSubscription deletes the subscription with its deliveries, it returns false when it does not exist.
+	DeleteSubscription(ctx context.Context, userId int, id int) (bool, error)
+
+	CreateDelivery(ctx context.Context, delivery Delivery) (Delivery, error)
+	// ClaimDueDeliveries returns up to limit pending deliveries due at now, with their subscriptions, and moves
+	// their next attempt by lease so that concurrent dispatchers do not send them twice.
+	ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]DueDelivery, error)
+	// UpdateDelivery stores the outcome of a delivery attempt.
+	UpdateDelivery(ctx context.Context, delivery Delivery) error
+	// ListDeliveries returns the latest deliveries of the subscription, the newest first.
+	ListDeliveries(ctx context.Context, subscriptionId int, limit int) ([]Delivery, error)
+	// DeleteFinishedDeliveriesBefore deletes the succeeded and failed deliveries last updated before the given
+	// time and returns how many were deleted.
+	DeleteFinishedDeliveriesBefore(ctx context.Context, before time.Time) (int, error)
+}
+
+// DueDelivery is a pending delivery together with the subscription it is sent to.
+type DueDelivery struct {
+	Delivery     Delivery
+	Subscription Subscription
+}
+
+const subscriptionColumns = `s.id, s.user_id, s.url, s.secret, s.event_types, s.enabled, s.created_at`
+
+func scanSubscription(row pgx.Row, extra ...any) (Subscription, error) {
+	var subscription Subscription
+	var eventTypes []string
+	dest := append([]any{&subscription.Id, &subscription.UserId, &subscription.Url, &subscription.Secret,
+		&eventTypes, &subscription.Enabled, &subscription.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return Subscription{}, err
+	}
+	for _, eventType := range eventTypes {
+		subscription.EventTypes = append(subscription.EventTypes, EventType(eventType))
+	}
+	return subscription, nil
+}
+
+const deliveryColumns = `d.id, d.subscription_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at,
+	COALESCE(d.response_status, 0), d.last_error, d.created_at, d.updated_at`
+
+func deliveryDest(delivery *Delivery) []any {
+	return []any{&delivery.Id, &delivery.SubscriptionId, &delivery.EventType, &delivery.Payload, &delivery.Status,
+		&delivery.Attempts, &delivery.NextAttemptAt, &delivery.ResponseStatus, &delivery.LastError,
+		&delivery.CreatedAt, &delivery.UpdatedAt}
+}
+
+func eventTypesToStrings(eventTypes []EventType) []string {
+	result := make([]string, 0, len(eventTypes))
+	for _, eventType := range eventTypes {
+		result = append(result, string(eventType))
+	}
+	return result
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+func (r *RepositoryImpl) ListSubscriptions(ctx context.Context, userId int) ([]Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM webhook_subscription s WHERE s.user_id = $1 ORDER BY s.id`
+	rows, err := r.db.Query(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subscriptions := make([]Subscription, 0)
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook subscription: %w", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+	return subscriptions, nil
+}
+
+func (r *RepositoryImpl) GetSubscription(ctx context.Context, userId int, id int) (Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM webhook_subscription s WHERE s.id = $1 AND s.user_id = $2`
+	subscription, err := scanSubscription(r.db.QueryRow(ctx, query, id, userId))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Subscription{}, ErrSubscriptionNotFound
+		}
+		return Subscription{}, fmt.Errorf("failed to get webhook subscription: %w", err)
+	}
+	return subscription, nil
+}
+
+func (r *RepositoryImpl) CreateSubscription(ctx context.Context, subscription Subscription) (Subscription, error) {
+	query := `INSERT INTO webhook_subscription AS s (user_id, url, secret, event_types, enabled)
+			  VALUES ($1, $2, $3, $4, $5)
+			  RETURNING ` + subscriptionColumns
+	created, err := scanSubscription(r.db.QueryRow(ctx, query, subscription.UserId, subscription.Url,
+		subscription.Secret, eventTypesToStrings(subscription.EventTypes), subscription.Enabled))
+	if err != nil {
+		return Subscription{}, fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+	return created, nil
+}
+
+func (r *RepositoryImpl) UpdateSubscription(ctx context.Context, subscription Subscription) (Subscription, error) {
+	query := `UPDATE webhook_subscription AS s SET url = $1, secret = $2, event_types = $3, enabled = $4
+			  WHERE s.id = $5 AND s.user_id = $6
+			  RETURNING ` + subscriptionColumns
+	updated, err := scanSubscription(r.db.QueryRow(ctx, query, subscription.Url, subscription.Secret,
+		eventTypesToStrings(subscription.EventTypes), subscription.Enabled, subscription.Id, subscription.UserId))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Subscription{}, ErrSubscriptionNotFound
+		}
+		return Subscription{}, fmt.Errorf("failed to update webhook subscription: %w", err)
+	}
+	return updated, nil
+}
+
+func (r *RepositoryImpl) DeleteSubscription(ctx context.Context, userId int, id int) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM webhook_subscription WHERE id = $1 AND user_id = $2`, id, userId)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *RepositoryImpl) CreateDelivery(ctx context.Context, delivery Delivery) (Delivery, error) {
+	query := `INSERT INTO webhook_delivery AS d (subscription_id, event_type, payload, status, next_attempt_at)
+			  VALUES ($1, $2, $3, $4, $5)
+			  RETURNING ` + deliveryColumns
+	var created Delivery
+	err := r.db.QueryRow(ctx, query, delivery.SubscriptionId, delivery.EventType, delivery.Payload, delivery.Status,
+		delivery.NextAttemptAt).Scan(deliveryDest(&created)...)
+	if err != nil {
+		return Delivery{}, fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	return created, nil
+}
+
+func (r *RepositoryImpl) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration,
+	limit int) ([]DueDelivery, error) {
+	query := `WITH due AS (
+				SELECT id FROM webhook_delivery
+				WHERE status = 'pending' AND next_attempt_at <= $1
+				  AND subscription_id IN (
+					SELECT s.id FROM webhook_subscription s JOIN users u ON u.id = s.user_id WHERE NOT u.disabled
+				  )
+				ORDER BY next_attempt_at, id
+				LIMIT $3
+				FOR UPDATE SKIP LOCKED
+			  ), claimed AS (
+				UPDATE webhook_delivery d SET next_attempt_at = $2, updated_at = NOW()
+				FROM due
+				WHERE d.id = due.id
+				RETURNING d.*
+			  )
+			  SELECT ` + subscriptionColumns + `, ` + deliveryColumns + `
+			  FROM claimed d
+			  JOIN webhook_subscription s ON s.id = d.subscription_id
+			  ORDER BY d.id`
+	rows, err := r.db.Query(ctx, query, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim due webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	due := make([]DueDelivery, 0)
+	for rows.Next() {
+		var delivery Delivery
+		subscription, err := scanSubscription(rows, deliveryDest(&delivery)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		due = append(due, DueDelivery{Delivery: delivery, Subscription: subscription})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim due webhook deliveries: %w", err)
+	}
+	return due, nil
+}
+
+func (r *RepositoryImpl) UpdateDelivery(ctx context.Context, delivery Delivery) error {
+	query := `UPDATE webhook_delivery
+			  SET status = $1, attempts = $2, next_attempt_at = $3, response_status = NULLIF($4, 0), last_error = $5,
+			      updated_at = NOW()
+			  WHERE id = $6`
+	_, err := r.db.Exec(ctx, query, delivery.Status, delivery.Attempts, delivery.NextAttemptAt,
+		delivery.ResponseStatus, delivery.LastError, delivery.Id)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) ListDeliveries(ctx context.Context, subscriptionId int, limit int) ([]Delivery, error) {
+	query := `SELECT ` + deliveryColumns + `
+			  FROM webhook_delivery d
+			  WHERE d.subscription_id = $1
+			  ORDER BY d.id DESC
+			  LIMIT $2`
+	rows, err := r.db.Query(ctx, query, subscriptionId, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]Delivery, 0)
+	for rows.Next() {
+		var delivery Delivery
+		if err := rows.Scan(deliveryDest(&delivery)...); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (r *RepositoryImpl) DeleteFinishedDeliveriesBefore(ctx context.Context, before time.Time) (int, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM webhook_delivery WHERE status <> 'pending' AND updated_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old webhook deliveries: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
@@ -0,0 +1,166 @@
+package webhook_subscription
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+type RepositoryStub struct {
+	mu                 sync.RWMutex
+	subscriptions      map[int]Subscription
+	deliveries         map[int64]Delivery
+	nextSubscriptionId int
+	nextDeliveryId     int64
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{
+		subscriptions:      make(map[int]Subscription),
+		deliveries:         make(map[int64]Delivery),
+		nextSubscriptionId: 1,
+		nextDeliveryId:     1,
+	}
+}
+
+func (r *RepositoryStub) ListSubscriptions(ctx context.Context, userId int) ([]Subscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	subscriptions := make([]Subscription, 0)
+	for _, subscription := range r.subscriptions {
+		if subscription.UserId == userId {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	slices.SortFunc(subscriptions, func(a, b Subscription) int { return a.Id - b.Id })
+	return subscriptions, nil
+}
+
+func (r *RepositoryStub) GetSubscription(ctx context.Context, userId int, id int) (Subscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	subscription, ok := r.subscriptions[id]
+	if !ok || subscription.UserId != userId {
+		return Subscription{}, ErrSubscriptionNotFound
+	}
+	return subscription, nil
+}
+
+func (r *RepositoryStub) CreateSubscription(ctx context.Context, subscription Subscription) (Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	subscription.Id = r.nextSubscriptionId
+	subscription.CreatedAt = time.Now()
+	r.nextSubscriptionId++
+	r.subscriptions[subscription.Id] = subscription
+	return subscription, nil
+}
+
+func (r *RepositoryStub) UpdateSubscription(ctx context.Context, subscription Subscription) (Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing, ok := r.subscriptions[subscription.Id]
+	if !ok || existing.UserId != subscription.UserId {
+		return Subscription{}, ErrSubscriptionNotFound
+	}
+	subscription.CreatedAt = existing.CreatedAt
+	r.subscriptions[subscription.Id] = subscription
+	return subscription, nil
+}
+
+func (r *RepositoryStub) DeleteSubscription(ctx context.Context, userId int, id int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	subscription, ok := r.subscriptions[id]
+	if !ok || subscription.UserId != userId {
+		return false, nil
+	}
+	delete(r.subscriptions, id)
+	for deliveryId, delivery := range r.deliveries {
+		if delivery.SubscriptionId == id {
+			delete(r.deliveries, deliveryId)
+		}
+	}
+	return true, nil
+}
+
+func (r *RepositoryStub) CreateDelivery(ctx context.Context, delivery Delivery) (Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delivery.Id = r.nextDeliveryId
+	delivery.CreatedAt = time.Now()
+	delivery.UpdatedAt = delivery.CreatedAt
+	r.nextDeliveryId++
+	r.deliveries[delivery.Id] = delivery
+	return delivery, nil
+}
+
+func (r *RepositoryStub) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration,
+	limit int) ([]DueDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	due := make([]DueDelivery, 0)
+	for _, delivery := range r.deliveries {
+		if delivery.Status != DeliveryPending || delivery.NextAttemptAt == nil || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		due = append(due, DueDelivery{Delivery: delivery, Subscription: r.subscriptions[delivery.SubscriptionId]})
+	}
+	slices.SortFunc(due, func(a, b DueDelivery) int { return int(a.Delivery.Id - b.Delivery.Id) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	leaseUntil := now.Add(lease)
+	for i := range due {
+		due[i].Delivery.NextAttemptAt = &leaseUntil
+		r.deliveries[due[i].Delivery.Id] = due[i].Delivery
+	}
+	return due, nil
+}
+
+func (r *RepositoryStub) UpdateDelivery(ctx context.Context, delivery Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing, ok := r.deliveries[delivery.Id]
+	if !ok {
+		return nil
+	}
+	existing.Status = delivery.Status
+	existing.Attempts = delivery.Attempts
+	existing.NextAttemptAt = delivery.NextAttemptAt
+	existing.ResponseStatus = delivery.ResponseStatus
+	existing.LastError = delivery.LastError
+	existing.UpdatedAt = time.Now()
+	r.deliveries[delivery.Id] = existing
+	return nil
+}
+
+func (r *RepositoryStub) ListDeliveries(ctx context.Context, subscriptionId int, limit int) ([]Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	deliveries := make([]Delivery, 0)
+	for _, delivery := range r.deliveries {
+		if delivery.SubscriptionId == subscriptionId {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	slices.SortFunc(deliveries, func(a, b Delivery) int { return int(b.Id - a.Id) })
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (r *RepositoryStub) DeleteFinishedDeliveriesBefore(ctx context.Context, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	deleted := 0
+	for id, delivery := range r.deliveries {
+		if delivery.Status != DeliveryPending && delivery.UpdatedAt.Before(before) {
+			delete(r.deliveries, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
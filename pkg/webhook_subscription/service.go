@@ -0,0 +1,384 @@
+package webhook_subscription
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrInvalidSubscription = errors.New("invalid webhook subscription")
+
+const (
+	SignatureHeader = "X-Klokku-Signature"
+	EventTypeHeader = "X-Klokku-Event"
+	DeliveryHeader  = "X-Klokku-Delivery"
+
+	// MaxDeliveriesListed limits the delivery log returned for a subscription.
+	MaxDeliveriesListed = 100
+	deliveriesPerRun    = 100
+	// deliveryLease is how long claimed deliveries are not handed out again, it has to outlast a whole run
+	deliveryLease = 20 * time.Minute
+	// deliveryRetention is how long succeeded and failed deliveries stay in the delivery log
+	deliveryRetention = 30 * 24 * time.Hour
+	maxErrorLength    = 500
+)
+
+// retryDelays are the waits after each failed attempt. A delivery fails for good after len(retryDelays)+1 attempts.
+var retryDelays = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 6 * time.Hour}
+
+type Service interface {
+	ListSubscriptions(ctx context.Context) ([]Subscription, error)
+	// CreateSubscription registers the URL of the current user. A secret is generated when none is given.
+	CreateSubscription(ctx context.Context, subscription Subscription) (Subscription, error)
+	// UpdateSubscription changes the URL, event types and enabled state. The secret is kept when none is given.
+	UpdateSubscription(ctx context.Context, subscription Subscription) (Subscription, error)
+	DeleteSubscription(ctx context.Context, id int) error
+	// ListDeliveries returns the delivery log of the subscription, the newest first.
+	ListDeliveries(ctx context.Context, subscriptionId int) ([]Delivery, error)
+	// DeliverDue attempts all pending deliveries that are due at now and returns how many were attempted.
+	DeliverDue(ctx context.Context, now time.Time) (int, error)
+	// DeleteOldDeliveries deletes the finished deliveries older than the retention and returns how many were deleted.
+	DeleteOldDeliveries(ctx context.Context, now time.Time) (int, error)
+}
+
+type ServiceImpl struct {
+	repo       Repository
+	httpClient *http.Client
+	clock      utils.Clock
+}
+
+// NewService creates the service delivering with the httpClient, which has to refuse non-public addresses, see
+// outbound.Registry.PublicClient.
+func NewService(repo Repository, httpClient *http.Client, eventBus *event_bus.EventBus) (*ServiceImpl, error) {
+	service := &ServiceImpl{
+		repo:       repo,
+		httpClient: httpClient,
+		clock:      &utils.SystemClock{},
+	}
+	if err := service.subscribe(eventBus); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+func (s *ServiceImpl) subscribe(eventBus *event_bus.EventBus) error {
+	if eventBus == nil {
+		return nil
+	}
+	if err := event_bus.SubscribeDurable[event_bus.CalendarEventCreated](
+		eventBus,
+		"webhook_subscription",
+		event_bus.EventType(EventCalendarEventCreated),
+		func(e event_bus.EventT[event_bus.CalendarEventCreated]) error {
+			if e.Data.Sandbox {
+				return nil
+			}
+			return s.enqueue(e.Context(), EventCalendarEventCreated, e.Timestamp, CalendarEventData{
+				UID:          e.Data.UID,
+				Summary:      e.Data.Summary,
+				StartTime:    e.Data.StartTime,
+				EndTime:      e.Data.EndTime,
+				BudgetItemId: e.Data.BudgetItemId,
+			})
+		},
+	); err != nil {
+		return err
+	}
+	if err := event_bus.SubscribeDurable[event_bus.BudgetPlanItemUpdated](
+		eventBus,
+		"webhook_subscription",
+		event_bus.EventType(EventBudgetItemUpdated),
+		func(e event_bus.EventT[event_bus.BudgetPlanItemUpdated]) error {
+			return s.enqueue(e.Context(), EventBudgetItemUpdated, e.Timestamp, BudgetItemData{
+				Id:                e.Data.Id,
+				PlanId:            e.Data.PlanId,
+				Name:              e.Data.Name,
+				WeeklyDuration:    int(e.Data.WeeklyDuration.Seconds()),
+				WeeklyOccurrences: e.Data.WeeklyOccurrences,
+				Icon:              e.Data.Icon,
+				Color:             e.Data.Color,
+			})
+		},
+	); err != nil {
+		return err
+	}
+	if err := event_bus.SubscribeDurable[event_bus.CurrentEventStarted](
+		eventBus,
+		"webhook_subscription",
+		event_bus.EventType(EventCurrentEventStarted),
+		func(e event_bus.EventT[event_bus.CurrentEventStarted]) error {
+			return s.enqueue(e.Context(), EventCurrentEventStarted, e.Timestamp, CurrentEventData{
+				BudgetItemId: e.Data.BudgetItemId,
+				Name:         e.Data.Name,
+				StartTime:    e.Data.StartTime,
+			})
+		},
+	); err != nil {
+		return err
+	}
+	if err := event_bus.SubscribeDurable[event_bus.CurrentEventStopped](
+		eventBus,
+		"webhook_subscription",
+		event_bus.EventType(EventCurrentEventStopped),
+		func(e event_bus.EventT[event_bus.CurrentEventStopped]) error {
+			return s.enqueue(e.Context(), EventCurrentEventStopped, e.Timestamp, CurrentEventData{
+				BudgetItemId:    e.Data.BudgetItemId,
+				Name:            e.Data.Name,
+				StartTime:       e.Data.StartTime,
+				EndTime:         &e.Data.EndTime,
+				RoundedDuration: int(e.Data.RoundedDuration.Seconds()),
+			})
+		},
+	); err != nil {
+		return err
+	}
+	return event_bus.SubscribeDurable[event_bus.BudgetAlertTriggered](
+		eventBus,
+		"webhook_subscription",
+		event_bus.EventType(EventBudgetAlertTriggered),
+		func(e event_bus.EventT[event_bus.BudgetAlertTriggered]) error {
+			return s.enqueue(e.Context(), EventBudgetAlertTriggered, e.Timestamp, BudgetAlertData{
+				BudgetItemId: e.Data.BudgetItemId,
+				Name:         e.Data.Name,
+				Week:         e.Data.Week,
+				Threshold:    e.Data.Threshold,
+				Percentage:   e.Data.Percentage,
+				Tracked:      int(e.Data.Tracked.Seconds()),
+				Planned:      int(e.Data.Planned.Seconds()),
+			})
+		},
+	)
+}
+
+// enqueue records a pending delivery for every subscription of the current user accepting the event type.
+func (s *ServiceImpl) enqueue(ctx context.Context, eventType EventType, occurredAt time.Time, data any) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	subscriptions, err := s.repo.ListSubscriptions(ctx, userId)
+	if err != nil {
+		return err
+	}
+	for _, subscription := range subscriptions {
+		if !subscription.Accepts(eventType) {
+			continue
+		}
+		eventId, err := generateSecret(16)
+		if err != nil {
+			return err
+		}
+		payload, err := json.Marshal(Payload{Id: eventId, Type: eventType, OccurredAt: occurredAt, Data: data})
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s payload: %w", eventType, err)
+		}
+		now := s.clock.Now()
+		_, err = s.repo.CreateDelivery(ctx, Delivery{
+			SubscriptionId: subscription.Id,
+			EventType:      eventType,
+			Payload:        payload,
+			Status:         DeliveryPending,
+			NextAttemptAt:  &now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ServiceImpl) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.ListSubscriptions(ctx, userId)
+}
+
+func (s *ServiceImpl) CreateSubscription(ctx context.Context, subscription Subscription) (Subscription, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Subscription{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	if err := validate(&subscription); err != nil {
+		return Subscription{}, err
+	}
+	if subscription.Secret == "" {
+		if subscription.Secret, err = generateSecret(32); err != nil {
+			return Subscription{}, err
+		}
+	}
+	subscription.UserId = userId
+	return s.repo.CreateSubscription(ctx, subscription)
+}
+
+func (s *ServiceImpl) UpdateSubscription(ctx context.Context, subscription Subscription) (Subscription, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Subscription{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	existing, err := s.repo.GetSubscription(ctx, userId, subscription.Id)
+	if err != nil {
+		return Subscription{}, err
+	}
+	if err := validate(&subscription); err != nil {
+		return Subscription{}, err
+	}
+	if subscription.Secret == "" {
+		subscription.Secret = existing.Secret
+	}
+	subscription.UserId = userId
+	return s.repo.UpdateSubscription(ctx, subscription)
+}
+
+func (s *ServiceImpl) DeleteSubscription(ctx context.Context, id int) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	deleted, err := s.repo.DeleteSubscription(ctx, userId, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+func (s *ServiceImpl) ListDeliveries(ctx context.Context, subscriptionId int) ([]Delivery, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	// Checks the subscription belongs to the user
+	if _, err := s.repo.GetSubscription(ctx, userId, subscriptionId); err != nil {
+		return nil, err
+	}
+	return s.repo.ListDeliveries(ctx, subscriptionId, MaxDeliveriesListed)
+}
+
+func validate(subscription *Subscription) error {
+	subscription.Url = strings.TrimSpace(subscription.Url)
+	subscription.Secret = strings.TrimSpace(subscription.Secret)
+	parsed, err := url.Parse(subscription.Url)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidSubscription)
+	}
+	for _, eventType := range subscription.EventTypes {
+		if !slices.Contains(EventTypes, eventType) {
+			return fmt.Errorf("%w: unknown event type %q", ErrInvalidSubscription, eventType)
+		}
+	}
+	return nil
+}
+
+func (s *ServiceImpl) DeliverDue(ctx context.Context, now time.Time) (int, error) {
+	due, err := s.repo.ClaimDueDeliveries(ctx, now, deliveryLease, deliveriesPerRun)
+	if err != nil {
+		return 0, err
+	}
+	for _, d := range due {
+		delivery := s.attempt(ctx, d.Subscription, d.Delivery, now)
+		if err := s.repo.UpdateDelivery(ctx, delivery); err != nil {
+			return 0, err
+		}
+	}
+	return len(due), nil
+}
+
+// attempt sends the delivery once and returns it with the outcome and the next attempt scheduled.
+func (s *ServiceImpl) attempt(ctx context.Context, subscription Subscription, delivery Delivery, now time.Time) Delivery {
+	delivery.Attempts++
+	delivery.ResponseStatus, delivery.LastError = s.send(ctx, subscription, delivery, now)
+
+	switch {
+	case delivery.LastError == "":
+		delivery.Status = DeliverySucceeded
+		delivery.NextAttemptAt = nil
+	case delivery.Attempts > len(retryDelays) || !subscription.Enabled:
+		log.Infof("webhook delivery %d to subscription %d failed after %d attempts: %s",
+			delivery.Id, subscription.Id, delivery.Attempts, delivery.LastError)
+		delivery.Status = DeliveryFailed
+		delivery.NextAttemptAt = nil
+	default:
+		next := now.Add(retryDelays[delivery.Attempts-1])
+		delivery.NextAttemptAt = &next
+	}
+	return delivery
+}
+
+// send POSTs the payload and returns the response status and an error message, empty on a 2xx response.
+func (s *ServiceImpl) send(ctx context.Context, subscription Subscription, delivery Delivery, now time.Time) (int, string) {
+	if !subscription.Enabled {
+		return 0, "subscription disabled"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, utils.Truncate(err.Error(), maxErrorLength)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Klokku-Webhook")
+	req.Header.Set(EventTypeHeader, string(delivery.EventType))
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.Id, 10))
+	req.Header.Set(SignatureHeader, Sign(subscription.Secret, now.Unix(), delivery.Payload))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, utils.Truncate(err.Error(), maxErrorLength)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Sprintf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, ""
+}
+
+func (s *ServiceImpl) DeleteOldDeliveries(ctx context.Context, now time.Time) (int, error) {
+	deleted, err := s.repo.DeleteFinishedDeliveriesBefore(ctx, now.Add(-deliveryRetention))
+	if err != nil {
+		return 0, err
+	}
+	if deleted > 0 {
+		log.Infof("Deleted %d webhook deliveries", deleted)
+	}
+	return deleted, nil
+}
+
+// Sign returns the signature header value of the body sent at the given Unix time: "t=<timestamp>,v1=<signature>",
+// where the signature is the hex encoded HMAC-SHA256 of "<timestamp>.<body>" keyed with the subscription secret.
+// Receivers should recompute it and reject old timestamps to prevent replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+func generateSecret(size int) (string, error) {
+	secret := make([]byte, size)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return hex.EncodeToString(secret), nil
+}
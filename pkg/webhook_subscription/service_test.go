@@ -0,0 +1,340 @@
+package webhook_subscription
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver records the requests and responds with the queued statuses, 200 once the queue is empty.
+type receiver struct {
+	mu       sync.Mutex
+	requests []receivedRequest
+	statuses []int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, receivedRequest{header: r.Header.Clone(), body: body})
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+type testEnv struct {
+	service  *ServiceImpl
+	repo     *RepositoryStub
+	eventBus *event_bus.EventBus
+	clock    *utils.MockClock
+	receiver *receiver
+	server   *httptest.Server
+	ctx      context.Context
+}
+
+func setupServiceTest(t *testing.T) testEnv {
+	t.Helper()
+	repo := NewRepositoryStub()
+	eventBus := event_bus.NewEventBus()
+	clock := &utils.MockClock{FixedNow: now}
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	t.Cleanup(server.Close)
+	// The test server listens on a local address, which the public client refuses
+	service, err := NewService(repo, server.Client(), eventBus)
+	require.NoError(t, err)
+	service.clock = clock
+	return testEnv{
+		service:  service,
+		repo:     repo,
+		eventBus: eventBus,
+		clock:    clock,
+		receiver: rc,
+		server:   server,
+		ctx:      user.WithUser(context.Background(), user.User{Id: 1, Uid: "user-1", Username: "test-user-1"}),
+	}
+}
+
+func (env testEnv) publishStarted(t *testing.T) {
+	t.Helper()
+	err := env.eventBus.Publish(event_bus.NewEvent(env.ctx, "current_event.started", event_bus.CurrentEventStarted{
+		BudgetItemId: 10,
+		Name:         "Exercise",
+		StartTime:    now,
+	}))
+	require.NoError(t, err)
+}
+
+func TestService_CreateSubscription(t *testing.T) {
+	env := setupServiceTest(t)
+
+	t.Run("generates a secret", func(t *testing.T) {
+		created, err := env.service.CreateSubscription(env.ctx, Subscription{Url: " https://example.com/hook ", Enabled: true})
+		require.NoError(t, err)
+
+		assert.Equal(t, "https://example.com/hook", created.Url)
+		assert.Len(t, created.Secret, 64)
+		assert.Equal(t, 1, created.UserId)
+	})
+
+	t.Run("rejects invalid subscriptions", func(t *testing.T) {
+		_, err := env.service.CreateSubscription(env.ctx, Subscription{Url: "ftp://example.com"})
+		assert.ErrorIs(t, err, ErrInvalidSubscription)
+
+		_, err = env.service.CreateSubscription(env.ctx, Subscription{Url: "/relative"})
+		assert.ErrorIs(t, err, ErrInvalidSubscription)
+
+		_, err = env.service.CreateSubscription(env.ctx, Subscription{
+			Url:        "https://example.com/hook",
+			EventTypes: []EventType{"calendar.event.deleted"},
+		})
+		assert.ErrorIs(t, err, ErrInvalidSubscription)
+	})
+
+	t.Run("keeps the secret on update without one", func(t *testing.T) {
+		created, err := env.service.CreateSubscription(env.ctx, Subscription{Url: "https://example.com/a", Secret: "s3cret", Enabled: true})
+		require.NoError(t, err)
+
+		updated, err := env.service.UpdateSubscription(env.ctx, Subscription{Id: created.Id, Url: "https://example.com/b"})
+		require.NoError(t, err)
+		assert.Equal(t, "s3cret", updated.Secret)
+		assert.Equal(t, "https://example.com/b", updated.Url)
+		assert.False(t, updated.Enabled)
+	})
+}
+
+func TestService_Deliveries(t *testing.T) {
+	t.Run("delivers signed events of subscribed types", func(t *testing.T) {
+		env := setupServiceTest(t)
+		subscription, err := env.service.CreateSubscription(env.ctx, Subscription{
+			Url:        env.server.URL,
+			Secret:     "s3cret",
+			EventTypes: []EventType{EventCurrentEventStarted},
+			Enabled:    true,
+		})
+		require.NoError(t, err)
+
+		env.publishStarted(t)
+		err = env.eventBus.Publish(event_bus.NewEvent(env.ctx, "current_event.stopped", event_bus.CurrentEventStopped{
+			BudgetItemId: 10,
+			StartTime:    now.Add(-time.Hour),
+			EndTime:      now,
+		}))
+		require.NoError(t, err)
+
+		attempted, err := env.service.DeliverDue(env.ctx, now)
+		require.NoError(t, err)
+		assert.Equal(t, 1, attempted)
+
+		require.Len(t, env.receiver.requests, 1)
+		request := env.receiver.requests[0]
+		assert.Equal(t, "current_event.started", request.header.Get(EventTypeHeader))
+		assert.Equal(t, Sign("s3cret", now.Unix(), request.body), request.header.Get(SignatureHeader))
+		var payload struct {
+			Type string           `json:"type"`
+			Data CurrentEventData `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(request.body, &payload))
+		assert.Equal(t, "current_event.started", payload.Type)
+		assert.Equal(t, "Exercise", payload.Data.Name)
+		assert.Nil(t, payload.Data.EndTime)
+
+		deliveries, err := env.service.ListDeliveries(env.ctx, subscription.Id)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, DeliverySucceeded, deliveries[0].Status)
+		assert.Equal(t, 1, deliveries[0].Attempts)
+		assert.Equal(t, http.StatusOK, deliveries[0].ResponseStatus)
+	})
+
+	t.Run("delivers triggered budget alerts", func(t *testing.T) {
+		env := setupServiceTest(t)
+		_, err := env.service.CreateSubscription(env.ctx, Subscription{
+			Url:        env.server.URL,
+			EventTypes: []EventType{EventBudgetAlertTriggered},
+			Enabled:    true,
+		})
+		require.NoError(t, err)
+
+		err = env.eventBus.Publish(event_bus.NewEvent(env.ctx, "budget_alert.triggered", event_bus.BudgetAlertTriggered{
+			BudgetItemId: 10,
+			Name:         "Exercise",
+			Week:         "2025-W24",
+			Threshold:    100,
+			Percentage:   104,
+			Tracked:      125 * time.Minute,
+			Planned:      2 * time.Hour,
+		}))
+		require.NoError(t, err)
+		_, err = env.service.DeliverDue(env.ctx, now)
+		require.NoError(t, err)
+
+		require.Len(t, env.receiver.requests, 1)
+		var payload struct {
+			Data BudgetAlertData `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(env.receiver.requests[0].body, &payload))
+		assert.Equal(t, BudgetAlertData{
+			BudgetItemId: 10,
+			Name:         "Exercise",
+			Week:         "2025-W24",
+			Threshold:    100,
+			Percentage:   104,
+			Tracked:      7500,
+			Planned:      7200,
+		}, payload.Data)
+	})
+
+	t.Run("retries failed deliveries with growing delays", func(t *testing.T) {
+		env := setupServiceTest(t)
+		subscription, err := env.service.CreateSubscription(env.ctx, Subscription{Url: env.server.URL, Enabled: true})
+		require.NoError(t, err)
+		env.receiver.statuses = []int{http.StatusInternalServerError, http.StatusBadGateway}
+
+		env.publishStarted(t)
+		_, err = env.service.DeliverDue(env.ctx, now)
+		require.NoError(t, err)
+
+		deliveries, err := env.service.ListDeliveries(env.ctx, subscription.Id)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, DeliveryPending, deliveries[0].Status)
+		assert.Equal(t, http.StatusInternalServerError, deliveries[0].ResponseStatus)
+		assert.Equal(t, now.Add(time.Minute), *deliveries[0].NextAttemptAt)
+
+		// Not due yet
+		attempted, err := env.service.DeliverDue(env.ctx, now.Add(30*time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, 0, attempted)
+
+		_, err = env.service.DeliverDue(env.ctx, now.Add(time.Minute))
+		require.NoError(t, err)
+		deliveries, err = env.service.ListDeliveries(env.ctx, subscription.Id)
+		require.NoError(t, err)
+		assert.Equal(t, now.Add(6*time.Minute), *deliveries[0].NextAttemptAt)
+
+		_, err = env.service.DeliverDue(env.ctx, now.Add(6*time.Minute))
+		require.NoError(t, err)
+		deliveries, err = env.service.ListDeliveries(env.ctx, subscription.Id)
+		require.NoError(t, err)
+		assert.Equal(t, DeliverySucceeded, deliveries[0].Status)
+		assert.Equal(t, 3, deliveries[0].Attempts)
+		assert.Len(t, env.receiver.requests, 3)
+	})
+
+	t.Run("fails after the last retry", func(t *testing.T) {
+		env := setupServiceTest(t)
+		subscription, err := env.service.CreateSubscription(env.ctx, Subscription{Url: env.server.URL, Enabled: true})
+		require.NoError(t, err)
+		for range len(retryDelays) + 1 {
+			env.receiver.statuses = append(env.receiver.statuses, http.StatusInternalServerError)
+		}
+
+		env.publishStarted(t)
+		at := now
+		for range len(retryDelays) + 1 {
+			attempted, err := env.service.DeliverDue(env.ctx, at)
+			require.NoError(t, err)
+			require.Equal(t, 1, attempted)
+			at = at.Add(24 * time.Hour)
+		}
+
+		deliveries, err := env.service.ListDeliveries(env.ctx, subscription.Id)
+		require.NoError(t, err)
+		assert.Equal(t, DeliveryFailed, deliveries[0].Status)
+		assert.Nil(t, deliveries[0].NextAttemptAt)
+		attempted, err := env.service.DeliverDue(env.ctx, at)
+		require.NoError(t, err)
+		assert.Equal(t, 0, attempted)
+	})
+
+	t.Run("skips disabled subscriptions and other users", func(t *testing.T) {
+		env := setupServiceTest(t)
+		_, err := env.service.CreateSubscription(env.ctx, Subscription{Url: env.server.URL, Enabled: false})
+		require.NoError(t, err)
+		otherCtx := user.WithUser(context.Background(), user.User{Id: 2, Uid: "user-2"})
+		_, err = env.service.CreateSubscription(otherCtx, Subscription{Url: env.server.URL, Enabled: true})
+		require.NoError(t, err)
+
+		env.publishStarted(t)
+		attempted, err := env.service.DeliverDue(env.ctx, now)
+		require.NoError(t, err)
+		assert.Equal(t, 0, attempted)
+	})
+
+	t.Run("does not hand out claimed deliveries again", func(t *testing.T) {
+		env := setupServiceTest(t)
+		_, err := env.service.CreateSubscription(env.ctx, Subscription{Url: env.server.URL, Enabled: true})
+		require.NoError(t, err)
+		env.publishStarted(t)
+
+		claimed, err := env.repo.ClaimDueDeliveries(env.ctx, now, deliveryLease, deliveriesPerRun)
+		require.NoError(t, err)
+		require.Len(t, claimed, 1)
+
+		attempted, err := env.service.DeliverDue(env.ctx, now)
+		require.NoError(t, err)
+		assert.Equal(t, 0, attempted)
+		assert.Empty(t, env.receiver.requests)
+
+		// A claim left behind by a crashed dispatcher expires
+		attempted, err = env.service.DeliverDue(env.ctx, now.Add(deliveryLease))
+		require.NoError(t, err)
+		assert.Equal(t, 1, attempted)
+	})
+
+	t.Run("does not list deliveries of other users", func(t *testing.T) {
+		env := setupServiceTest(t)
+		subscription, err := env.service.CreateSubscription(env.ctx, Subscription{Url: env.server.URL, Enabled: true})
+		require.NoError(t, err)
+
+		otherCtx := user.WithUser(context.Background(), user.User{Id: 2, Uid: "user-2"})
+		_, err = env.service.ListDeliveries(otherCtx, subscription.Id)
+		assert.ErrorIs(t, err, ErrSubscriptionNotFound)
+	})
+}
+
+func TestService_DeleteOldDeliveries(t *testing.T) {
+	env := setupServiceTest(t)
+	subscription, err := env.service.CreateSubscription(env.ctx, Subscription{Url: env.server.URL, Enabled: true})
+	require.NoError(t, err)
+	env.receiver.statuses = []int{http.StatusOK, http.StatusInternalServerError}
+	env.publishStarted(t)
+	env.publishStarted(t)
+	_, err = env.service.DeliverDue(env.ctx, now)
+	require.NoError(t, err)
+
+	// The stub stamps updates with the wall clock
+	deleted, err := env.service.DeleteOldDeliveries(env.ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+
+	deleted, err = env.service.DeleteOldDeliveries(env.ctx, time.Now().Add(deliveryRetention+time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	deliveries, err := env.service.ListDeliveries(env.ctx, subscription.Id)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, DeliveryPending, deliveries[0].Status)
+}
@@ -0,0 +1,123 @@
+package webhook_subscription
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+type EventType string
+
+const (
+	// EventCalendarEventCreated - data is CalendarEventData
+	EventCalendarEventCreated EventType = "calendar.event.created"
+	// EventBudgetItemUpdated - data is BudgetItemData
+	EventBudgetItemUpdated EventType = "budget_plan.item.updated"
+	// EventCurrentEventStarted - data is CurrentEventData without the end
+	EventCurrentEventStarted EventType = "current_event.started"
+	// EventCurrentEventStopped - data is CurrentEventData
+	EventCurrentEventStopped EventType = "current_event.stopped"
+	// EventBudgetAlertTriggered - data is BudgetAlertData
+	EventBudgetAlertTriggered EventType = "budget_alert.triggered"
+)
+
+var EventTypes = []EventType{
+	EventCalendarEventCreated,
+	EventBudgetItemUpdated,
+	EventCurrentEventStarted,
+	EventCurrentEventStopped,
+	EventBudgetAlertTriggered,
+}
+
+// Subscription delivers the user's events of the subscribed types to the URL, signed with the secret.
+type Subscription struct {
+	Id     int
+	UserId int
+	Url    string
+	Secret string
+	// EventTypes filters the delivered events, empty means all of them.
+	EventTypes []EventType
+	Enabled    bool
+	CreatedAt  time.Time
+}
+
+func (s Subscription) Accepts(eventType EventType) bool {
+	return s.Enabled && (len(s.EventTypes) == 0 || slices.Contains(s.EventTypes, eventType))
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryFailed - all attempts failed, the delivery is not retried anymore.
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// Delivery is a single event sent, or to be sent, to a subscription. It is kept as the delivery log.
+type Delivery struct {
+	Id             int64
+	SubscriptionId int
+	EventType      EventType
+	// Payload is the request body, see Payload
+	Payload  json.RawMessage
+	Status   DeliveryStatus
+	Attempts int
+	// NextAttemptAt is set while the delivery is pending.
+	NextAttemptAt *time.Time
+	// ResponseStatus is the HTTP status of the last attempt, 0 when no response was received.
+	ResponseStatus int
+	LastError      string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// Payload is the documented schema of the request body of a delivery.
+type Payload struct {
+	// Id identifies the event, it is the same across delivery retries.
+	Id         string    `json:"id"`
+	Type       EventType `json:"type"`
+	OccurredAt time.Time `json:"occurredAt"`
+	Data       any       `json:"data"`
+}
+
+type CalendarEventData struct {
+	UID          string    `json:"uid"`
+	Summary      string    `json:"summary"`
+	StartTime    time.Time `json:"start"`
+	EndTime      time.Time `json:"end"`
+	BudgetItemId int       `json:"budgetItemId"`
+}
+
+type BudgetItemData struct {
+	Id     int    `json:"id"`
+	PlanId int    `json:"planId"`
+	Name   string `json:"name"`
+	// WeeklyDuration in seconds
+	WeeklyDuration    int    `json:"weeklyDuration"`
+	WeeklyOccurrences int    `json:"weeklyOccurrences"`
+	Icon              string `json:"icon"`
+	Color             string `json:"color"`
+}
+
+type CurrentEventData struct {
+	BudgetItemId int        `json:"budgetItemId"`
+	Name         string     `json:"name"`
+	StartTime    time.Time  `json:"start"`
+	EndTime      *time.Time `json:"end,omitempty"`
+	// RoundedDuration in seconds, set for stopped events of users with rounding enabled
+	RoundedDuration int `json:"roundedDuration,omitempty"`
+}
+
+type BudgetAlertData struct {
+	BudgetItemId int    `json:"budgetItemId"`
+	Name         string `json:"name"`
+	// Week is the ISO 8601 week, e.g. "2025-W03"
+	Week string `json:"week"`
+	// Threshold is the crossed percentage of the planned time
+	Threshold  int     `json:"threshold"`
+	Percentage float64 `json:"percentage"`
+	// Tracked and Planned in seconds
+	Tracked int `json:"tracked"`
+	Planned int `json:"planned"`
+}
@@ -0,0 +1,215 @@
+package week_review
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	log "github.com/sirupsen/logrus"
+)
+
+type ReviewDTO struct {
+	// Week is the ISO week, e.g. "2025-W03"
+	Week        string     `json:"week"`
+	Status      string     `json:"status" enums:"in_progress,reviewed"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	// Deviations of the items of the week, the largest first
+	Deviations []DeviationDTO `json:"deviations"`
+}
+
+type DeviationDTO struct {
+	BudgetItemId int    `json:"budgetItemId"`
+	Name         string `json:"name"`
+	Color        string `json:"color"`
+	Planned      int    `json:"planned"`
+	Tracked      int    `json:"tracked"`
+	// Difference is positive when more time was tracked than planned
+	Difference int    `json:"difference"`
+	Note       string `json:"note,omitempty"`
+}
+
+type ItemNoteDTO struct {
+	Note string `json:"note"`
+}
+
+var weekPattern = regexp.MustCompile(`^\d{4}-W\d{2}$`)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// StartReview godoc
+// @Summary Start a week review
+// @Description Start reviewing the deviations of a past week from its plan, the review of the week is returned
+// @Description when it was already started.
+// @Tags WeekReview
+// @Produce json
+// @Param week query string false "ISO 8601 week, e.g. 2025-W03, last week when omitted"
+// @Success 200 {object} ReviewDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid week"
+// @Failure 403 {string} string "User not found"
+// @Failure 409 {string} string "The week is not over"
+// @Router /api/week-reviews [post]
+// @Security XUserId
+func (h *Handler) StartReview(w http.ResponseWriter, r *http.Request) {
+	week, err := h.service.LastWeek(r.Context())
+	if err != nil {
+		h.writeReview(w, Review{}, err)
+		return
+	}
+	if weekString := r.URL.Query().Get("week"); weekString != "" {
+		week, err = parseWeek(weekString)
+		if err != nil {
+			rest.WriteBadRequest(w, err)
+			return
+		}
+	}
+	review, err := h.service.StartReview(r.Context(), week)
+	h.writeReview(w, review, err)
+}
+
+// GetReview godoc
+// @Summary Get a week review
+// @Description The review of the week with the planned and tracked time of its items
+// @Tags WeekReview
+// @Produce json
+// @Param week path string true "ISO 8601 week, e.g. 2025-W03"
+// @Success 200 {object} ReviewDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid week"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Week review not found"
+// @Router /api/week-reviews/{week} [get]
+// @Security XUserId
+func (h *Handler) GetReview(w http.ResponseWriter, r *http.Request) {
+	week, err := parseWeek(mux.Vars(r)["week"])
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	review, err := h.service.GetReview(r.Context(), week)
+	h.writeReview(w, review, err)
+}
+
+// SetItemNote godoc
+// @Summary Set the note about an item
+// @Description Store the retrospective note about the item of the reviewed week, an empty note removes it
+// @Tags WeekReview
+// @Accept json
+// @Produce json
+// @Param week path string true "ISO 8601 week, e.g. 2025-W03"
+// @Param budgetItemId path int true "Budget item ID, negative for ad hoc items"
+// @Param note body ItemNoteDTO true "Note"
+// @Success 200 {object} ReviewDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid week or note"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Week review not found or item not planned in the week"
+// @Failure 409 {string} string "The week is already reviewed"
+// @Router /api/week-reviews/{week}/items/{budgetItemId} [put]
+// @Security XUserId
+func (h *Handler) SetItemNote(w http.ResponseWriter, r *http.Request) {
+	week, err := parseWeek(mux.Vars(r)["week"])
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	budgetItemId, err := rest.PathInt(r, "budgetItemId")
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	var body ItemNoteDTO
+	if err := rest.DecodeJSON(r, &body); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	review, err := h.service.SetItemNote(r.Context(), week, budgetItemId, body.Note)
+	h.writeReview(w, review, err)
+}
+
+// CompleteReview godoc
+// @Summary Mark a week as reviewed
+// @Description Complete the review, its notes can't be changed afterwards
+// @Tags WeekReview
+// @Produce json
+// @Param week path string true "ISO 8601 week, e.g. 2025-W03"
+// @Success 200 {object} ReviewDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid week"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Week review not found"
+// @Failure 409 {string} string "The week is already reviewed"
+// @Router /api/week-reviews/{week}/complete [post]
+// @Security XUserId
+func (h *Handler) CompleteReview(w http.ResponseWriter, r *http.Request) {
+	week, err := parseWeek(mux.Vars(r)["week"])
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	review, err := h.service.Complete(r.Context(), week)
+	h.writeReview(w, review, err)
+}
+
+func (h *Handler) writeReview(w http.ResponseWriter, review Review, err error) {
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrReviewNotFound), errors.Is(err, ErrItemNotInWeek):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ErrWeekNotOver), errors.Is(err, ErrReviewCompleted):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, ErrInvalidNote):
+			rest.WriteBadRequest(w, rest.InvalidField("note", "Invalid note", err.Error()))
+		default:
+			log.Errorf("Week review failed: %v", err)
+			http.Error(w, "Week review failed", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reviewToDTO(review)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func parseWeek(weekString string) (weekly_plan.WeekNumber, error) {
+	invalid := rest.InvalidField("week", "Invalid week", "'week' must be an ISO 8601 week, e.g. 2025-W03")
+	if !weekPattern.MatchString(weekString) {
+		return weekly_plan.WeekNumber{}, invalid
+	}
+	week, err := weekly_plan.WeekNumberFromString(weekString)
+	if err != nil || week.Week < 1 || week.Week > 53 {
+		return weekly_plan.WeekNumber{}, invalid
+	}
+	return week, nil
+}
+
+func reviewToDTO(review Review) ReviewDTO {
+	deviations := make([]DeviationDTO, 0, len(review.Deviations))
+	for _, deviation := range review.Deviations {
+		deviations = append(deviations, DeviationDTO{
+			BudgetItemId: deviation.BudgetItemId,
+			Name:         deviation.Name,
+			Color:        deviation.Color,
+			Planned:      int(deviation.Planned.Seconds()),
+			Tracked:      int(deviation.Tracked.Seconds()),
+			Difference:   int(deviation.Difference.Seconds()),
+			Note:         deviation.Note,
+		})
+	}
+	return ReviewDTO{
+		Week:        review.Week.String(),
+		Status:      string(review.Status),
+		CreatedAt:   review.CreatedAt,
+		CompletedAt: review.CompletedAt,
+		Deviations:  deviations,
+	}
+}
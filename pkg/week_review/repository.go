@@ -0,0 +1,121 @@
+package week_review
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+)
+
+var ErrReviewNotFound = errors.New("week review not found")
+
+type Repository interface {
+	// CreateReview stores the review, or returns the existing review of the week.
+	CreateReview(ctx context.Context, review Review) (Review, error)
+	// GetReview returns the review of the week with its item notes.
+	GetReview(ctx context.Context, userId int, week weekly_plan.WeekNumber) (Review, error)
+	// SetItemNote stores the note about the item, an empty note removes it.
+	SetItemNote(ctx context.Context, reviewId, budgetItemId int, note string) error
+	// CompleteReview marks the review as reviewed, it returns ErrReviewCompleted when it already is.
+	CompleteReview(ctx context.Context, reviewId int, completedAt time.Time) error
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+const reviewColumns = "id, user_id, week_number, status, created_at, completed_at"
+
+func scanReview(row pgx.Row) (Review, error) {
+	var review Review
+	var week string
+	err := row.Scan(&review.Id, &review.UserId, &week, &review.Status, &review.CreatedAt, &review.CompletedAt)
+	if err != nil {
+		return Review{}, err
+	}
+	review.Week, err = weekly_plan.WeekNumberFromString(week)
+	return review, err
+}
+
+func (r *RepositoryImpl) CreateReview(ctx context.Context, review Review) (Review, error) {
+	query := `INSERT INTO week_review (user_id, week_number, status) VALUES ($1, $2, $3)
+			  ON CONFLICT (user_id, week_number) DO NOTHING`
+	_, err := r.db.Exec(ctx, query, review.UserId, review.Week.String(), StatusInProgress)
+	if err != nil {
+		return Review{}, fmt.Errorf("failed to create week review: %w", err)
+	}
+	return r.GetReview(ctx, review.UserId, review.Week)
+}
+
+func (r *RepositoryImpl) GetReview(ctx context.Context, userId int, week weekly_plan.WeekNumber) (Review, error) {
+	query := `SELECT ` + reviewColumns + ` FROM week_review WHERE user_id = $1 AND week_number = $2`
+	review, err := scanReview(r.db.QueryRow(ctx, query, userId, week.String()))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Review{}, ErrReviewNotFound
+		}
+		return Review{}, fmt.Errorf("failed to get week review: %w", err)
+	}
+
+	rows, err := r.db.Query(ctx, "SELECT budget_item_id, note FROM week_review_item_note WHERE review_id = $1",
+		review.Id)
+	if err != nil {
+		return Review{}, fmt.Errorf("failed to get week review notes: %w", err)
+	}
+	defer rows.Close()
+
+	review.ItemNotes = make(map[int]string)
+	for rows.Next() {
+		var budgetItemId int
+		var note string
+		if err := rows.Scan(&budgetItemId, &note); err != nil {
+			return Review{}, fmt.Errorf("failed to scan week review note: %w", err)
+		}
+		review.ItemNotes[budgetItemId] = note
+	}
+	return review, rows.Err()
+}
+
+func (r *RepositoryImpl) SetItemNote(ctx context.Context, reviewId, budgetItemId int, note string) error {
+	var err error
+	if note == "" {
+		_, err = r.db.Exec(ctx, "DELETE FROM week_review_item_note WHERE review_id = $1 AND budget_item_id = $2",
+			reviewId, budgetItemId)
+	} else {
+		_, err = r.db.Exec(ctx, `INSERT INTO week_review_item_note (review_id, budget_item_id, note) VALUES ($1, $2, $3)
+			ON CONFLICT (review_id, budget_item_id) DO UPDATE SET note = EXCLUDED.note`, reviewId, budgetItemId, note)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store week review note: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) CompleteReview(ctx context.Context, reviewId int, completedAt time.Time) error {
+	// The status is checked in the update, so of two concurrent requests only one completes the review
+	tag, err := r.db.Exec(ctx, "UPDATE week_review SET status = $1, completed_at = $2 WHERE id = $3 AND status <> $1",
+		StatusReviewed, completedAt, reviewId)
+	if err != nil {
+		return fmt.Errorf("failed to complete week review: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		var exists bool
+		err := r.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM week_review WHERE id = $1)", reviewId).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to complete week review: %w", err)
+		}
+		if exists {
+			return ErrReviewCompleted
+		}
+		return ErrReviewNotFound
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package week_review
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/klokku/klokku/pkg/weekly_plan"
+)
+
+type RepositoryStub struct {
+	mu      sync.Mutex
+	nextId  int
+	reviews []Review
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{}
+}
+
+func (r *RepositoryStub) CreateReview(ctx context.Context, review Review) (Review, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.reviews {
+		if existing.UserId == review.UserId && existing.Week.Equal(review.Week) {
+			return copyReview(existing), nil
+		}
+	}
+	r.nextId++
+	review.Id = r.nextId
+	review.Status = StatusInProgress
+	review.ItemNotes = make(map[int]string)
+	review.CreatedAt = time.Now()
+	r.reviews = append(r.reviews, review)
+	return copyReview(review), nil
+}
+
+func (r *RepositoryStub) GetReview(ctx context.Context, userId int, week weekly_plan.WeekNumber) (Review, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, review := range r.reviews {
+		if review.UserId == userId && review.Week.Equal(week) {
+			return copyReview(review), nil
+		}
+	}
+	return Review{}, ErrReviewNotFound
+}
+
+func (r *RepositoryStub) SetItemNote(ctx context.Context, reviewId, budgetItemId int, note string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, review := range r.reviews {
+		if review.Id == reviewId {
+			if note == "" {
+				delete(review.ItemNotes, budgetItemId)
+			} else {
+				review.ItemNotes[budgetItemId] = note
+			}
+			return nil
+		}
+	}
+	return ErrReviewNotFound
+}
+
+func (r *RepositoryStub) CompleteReview(ctx context.Context, reviewId int, completedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, review := range r.reviews {
+		if review.Id == reviewId {
+			if review.Status == StatusReviewed {
+				return ErrReviewCompleted
+			}
+			r.reviews[i].Status = StatusReviewed
+			r.reviews[i].CompletedAt = &completedAt
+			return nil
+		}
+	}
+	return ErrReviewNotFound
+}
+
+func copyReview(review Review) Review {
+	review.ItemNotes = maps.Clone(review.ItemNotes)
+	return review
+}
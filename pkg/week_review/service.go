@@ -0,0 +1,198 @@
+package week_review
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/stats"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+)
+
+var (
+	ErrWeekNotOver     = errors.New("only past weeks can be reviewed")
+	ErrReviewCompleted = errors.New("the week is already reviewed")
+	ErrInvalidNote     = errors.New("invalid week review note")
+	ErrItemNotInWeek   = errors.New("the budget item is not planned in the reviewed week")
+)
+
+type Service interface {
+	// StartReview returns the review of the week, it is started when there is none yet.
+	StartReview(ctx context.Context, week weekly_plan.WeekNumber) (Review, error)
+	GetReview(ctx context.Context, week weekly_plan.WeekNumber) (Review, error)
+	// SetItemNote stores the note about the item of the reviewed week, an empty note removes it. It returns
+	// ErrItemNotInWeek when the item is not planned in the week.
+	SetItemNote(ctx context.Context, week weekly_plan.WeekNumber, budgetItemId int, note string) (Review, error)
+	// Complete marks the week as reviewed, the notes can't be changed afterwards.
+	Complete(ctx context.Context, week weekly_plan.WeekNumber) (Review, error)
+	// LastWeek returns the week before the current week of the user.
+	LastWeek(ctx context.Context) (weekly_plan.WeekNumber, error)
+	// ReviewStatus returns the status of the review of the week containing weekDate.
+	ReviewStatus(ctx context.Context, weekDate time.Time) (string, error)
+}
+
+type weeklyStatsProvider interface {
+	GetWeeklyStats(ctx context.Context, weekTime time.Time, includeSandbox bool) (stats.WeeklyStatsSummary, error)
+}
+
+type ServiceImpl struct {
+	repo  Repository
+	stats weeklyStatsProvider
+	clock utils.Clock
+}
+
+func NewService(repo Repository, stats weeklyStatsProvider) *ServiceImpl {
+	return &ServiceImpl{
+		repo:  repo,
+		stats: stats,
+		clock: &utils.SystemClock{},
+	}
+}
+
+func (s *ServiceImpl) StartReview(ctx context.Context, week weekly_plan.WeekNumber) (Review, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return Review{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	lastWeek, err := s.LastWeek(ctx)
+	if err != nil {
+		return Review{}, err
+	}
+	if week.After(lastWeek) {
+		return Review{}, ErrWeekNotOver
+	}
+	review, err := s.repo.CreateReview(ctx, Review{UserId: currentUser.Id, Week: week})
+	if err != nil {
+		return Review{}, err
+	}
+	return s.withDeviations(ctx, review)
+}
+
+func (s *ServiceImpl) GetReview(ctx context.Context, week weekly_plan.WeekNumber) (Review, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Review{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	review, err := s.repo.GetReview(ctx, userId, week)
+	if err != nil {
+		return Review{}, err
+	}
+	return s.withDeviations(ctx, review)
+}
+
+func (s *ServiceImpl) SetItemNote(ctx context.Context, week weekly_plan.WeekNumber, budgetItemId int, note string) (Review, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Review{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	note = strings.TrimSpace(note)
+	if len(note) > MaxNoteLen {
+		return Review{}, fmt.Errorf("%w: note must have at most %d characters", ErrInvalidNote, MaxNoteLen)
+	}
+	review, err := s.repo.GetReview(ctx, userId, week)
+	if err != nil {
+		return Review{}, err
+	}
+	if review.Status == StatusReviewed {
+		return Review{}, ErrReviewCompleted
+	}
+	// A note is removed even when its item is no longer planned
+	if note != "" {
+		review, err = s.withDeviations(ctx, review)
+		if err != nil {
+			return Review{}, err
+		}
+		if !slices.ContainsFunc(review.Deviations, func(d Deviation) bool { return d.BudgetItemId == budgetItemId }) {
+			return Review{}, ErrItemNotInWeek
+		}
+	}
+	if err := s.repo.SetItemNote(ctx, review.Id, budgetItemId, note); err != nil {
+		return Review{}, err
+	}
+	return s.GetReview(ctx, week)
+}
+
+func (s *ServiceImpl) Complete(ctx context.Context, week weekly_plan.WeekNumber) (Review, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Review{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	review, err := s.repo.GetReview(ctx, userId, week)
+	if err != nil {
+		return Review{}, err
+	}
+	if err := s.repo.CompleteReview(ctx, review.Id, s.clock.Now()); err != nil {
+		return Review{}, err
+	}
+	return s.GetReview(ctx, week)
+}
+
+func (s *ServiceImpl) LastWeek(ctx context.Context) (weekly_plan.WeekNumber, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return weekly_plan.WeekNumber{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	location, err := time.LoadLocation(currentUser.Settings.Timezone)
+	if err != nil {
+		return weekly_plan.WeekNumber{}, fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	weekAgo := s.clock.Now().In(location).AddDate(0, 0, -7)
+	return weekly_plan.WeekNumberFromDate(weekAgo, currentUser.Settings.WeekFirstDay), nil
+}
+
+func (s *ServiceImpl) ReviewStatus(ctx context.Context, weekDate time.Time) (string, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get current user: %w", err)
+	}
+	week := weekly_plan.WeekNumberFromDate(weekDate, currentUser.Settings.WeekFirstDay)
+	review, err := s.repo.GetReview(ctx, currentUser.Id, week)
+	if err != nil {
+		if errors.Is(err, ErrReviewNotFound) {
+			return string(StatusNotStarted), nil
+		}
+		return "", err
+	}
+	return string(review.Status), nil
+}
+
+// withDeviations adds the deviations of the items of the reviewed week, the largest first.
+func (s *ServiceImpl) withDeviations(ctx context.Context, review Review) (Review, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return Review{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	location, err := time.LoadLocation(currentUser.Settings.Timezone)
+	if err != nil {
+		return Review{}, fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	firstDay := review.Week.FirstDay(currentUser.Settings.WeekFirstDay)
+	weekTime := time.Date(firstDay.Year(), firstDay.Month(), firstDay.Day(), 0, 0, 0, 0, location)
+	summary, err := s.stats.GetWeeklyStats(ctx, weekTime, false)
+	if err != nil && !errors.Is(err, stats.ErrNoStatsFound) {
+		return Review{}, fmt.Errorf("failed to get weekly stats: %w", err)
+	}
+
+	review.Deviations = make([]Deviation, 0, len(summary.PerPlanItem))
+	for _, item := range summary.PerPlanItem {
+		review.Deviations = append(review.Deviations, Deviation{
+			BudgetItemId: item.PlanItem.BudgetItemId,
+			Name:         item.PlanItem.Name,
+			Color:        item.PlanItem.Color,
+			Planned:      item.PlanItem.WeeklyItemDuration,
+			Tracked:      item.Duration,
+			Difference:   item.Duration - item.PlanItem.WeeklyItemDuration,
+			Note:         review.ItemNotes[item.PlanItem.BudgetItemId],
+		})
+	}
+	slices.SortStableFunc(review.Deviations, func(a, b Deviation) int {
+		return cmp.Compare(b.Difference.Abs(), a.Difference.Abs())
+	})
+	return review, nil
+}
@@ -0,0 +1,189 @@
+package week_review
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/utils"
+	"github.com/klokku/klokku/pkg/stats"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// now is on Wednesday of 2025-W24, last week is 2025-W23
+var now = time.Date(2025, 6, 11, 9, 0, 0, 0, time.UTC)
+
+var lastWeek = weekly_plan.WeekNumber{Year: 2025, Week: 23}
+
+var testUser = user.User{
+	Id:       1,
+	Uid:      "user-1",
+	Username: "test-user-1",
+	Settings: user.Settings{Timezone: "UTC", WeekFirstDay: time.Monday},
+}
+
+type statsStub struct {
+	summary  stats.WeeklyStatsSummary
+	weekTime time.Time
+}
+
+func (s *statsStub) GetWeeklyStats(ctx context.Context, weekTime time.Time, includeSandbox bool) (stats.WeeklyStatsSummary, error) {
+	s.weekTime = weekTime
+	return s.summary, nil
+}
+
+type testEnv struct {
+	service *ServiceImpl
+	stats   *statsStub
+	ctx     context.Context
+}
+
+func setupServiceTest(t *testing.T) testEnv {
+	t.Helper()
+	statsProvider := &statsStub{summary: stats.WeeklyStatsSummary{
+		PerPlanItem: []stats.PlanItemStats{
+			{PlanItem: stats.PlanItem{BudgetItemId: 10, Name: "Reading", WeeklyItemDuration: 2 * time.Hour}, Duration: 90 * time.Minute},
+			{PlanItem: stats.PlanItem{BudgetItemId: 11, Name: "Work", WeeklyItemDuration: 40 * time.Hour}, Duration: 45 * time.Hour},
+			{PlanItem: stats.PlanItem{BudgetItemId: 12, Name: "Exercise", WeeklyItemDuration: 3 * time.Hour}, Duration: 3 * time.Hour},
+		},
+	}}
+	service := NewService(NewRepositoryStub(), statsProvider)
+	service.clock = &utils.MockClock{FixedNow: now}
+	return testEnv{
+		service: service,
+		stats:   statsProvider,
+		ctx:     user.WithUser(context.Background(), testUser),
+	}
+}
+
+func TestStartReview_ReturnsDeviationsLargestFirst(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+
+	// when
+	review, err := env.service.StartReview(env.ctx, lastWeek)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, StatusInProgress, review.Status)
+	assert.Equal(t, time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), env.stats.weekTime)
+	assert.Equal(t, []Deviation{
+		{BudgetItemId: 11, Name: "Work", Planned: 40 * time.Hour, Tracked: 45 * time.Hour, Difference: 5 * time.Hour},
+		{BudgetItemId: 10, Name: "Reading", Planned: 2 * time.Hour, Tracked: 90 * time.Minute, Difference: -30 * time.Minute},
+		{BudgetItemId: 12, Name: "Exercise", Planned: 3 * time.Hour, Tracked: 3 * time.Hour},
+	}, review.Deviations)
+}
+
+func TestStartReview_ReturnsStartedReview(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	started, err := env.service.StartReview(env.ctx, lastWeek)
+	require.NoError(t, err)
+	_, err = env.service.SetItemNote(env.ctx, lastWeek, 11, "Release week")
+	require.NoError(t, err)
+
+	// when
+	review, err := env.service.StartReview(env.ctx, lastWeek)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, started.Id, review.Id)
+	assert.Equal(t, map[int]string{11: "Release week"}, review.ItemNotes)
+}
+
+func TestStartReview_RejectsWeeksNotOver(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+
+	// when
+	_, err := env.service.StartReview(env.ctx, weekly_plan.WeekNumber{Year: 2025, Week: 24})
+
+	// then
+	assert.ErrorIs(t, err, ErrWeekNotOver)
+}
+
+func TestSetItemNote(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	_, err := env.service.StartReview(env.ctx, lastWeek)
+	require.NoError(t, err)
+
+	// when
+	review, err := env.service.SetItemNote(env.ctx, lastWeek, 10, "  Too tired in the evenings ")
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "Too tired in the evenings", review.Deviations[1].Note)
+
+	// when
+	review, err = env.service.SetItemNote(env.ctx, lastWeek, 10, "")
+
+	// then
+	require.NoError(t, err)
+	assert.Empty(t, review.ItemNotes)
+}
+
+func TestSetItemNote_Validation(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	_, err := env.service.StartReview(env.ctx, lastWeek)
+	require.NoError(t, err)
+
+	// when
+	_, tooLongErr := env.service.SetItemNote(env.ctx, lastWeek, 10, strings.Repeat("a", MaxNoteLen+1))
+	_, notStartedErr := env.service.SetItemNote(env.ctx, weekly_plan.WeekNumber{Year: 2025, Week: 22}, 10, "Note")
+	_, notPlannedErr := env.service.SetItemNote(env.ctx, lastWeek, 99, "Note")
+
+	// then
+	assert.ErrorIs(t, tooLongErr, ErrInvalidNote)
+	assert.ErrorIs(t, notStartedErr, ErrReviewNotFound)
+	assert.ErrorIs(t, notPlannedErr, ErrItemNotInWeek)
+}
+
+func TestComplete_LocksNotes(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	_, err := env.service.StartReview(env.ctx, lastWeek)
+	require.NoError(t, err)
+
+	// when
+	review, err := env.service.Complete(env.ctx, lastWeek)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, StatusReviewed, review.Status)
+	require.NotNil(t, review.CompletedAt)
+	assert.Equal(t, now, *review.CompletedAt)
+	_, err = env.service.SetItemNote(env.ctx, lastWeek, 10, "Too late")
+	assert.ErrorIs(t, err, ErrReviewCompleted)
+	_, err = env.service.Complete(env.ctx, lastWeek)
+	assert.ErrorIs(t, err, ErrReviewCompleted)
+}
+
+func TestReviewStatus(t *testing.T) {
+	// given
+	env := setupServiceTest(t)
+	_, err := env.service.StartReview(env.ctx, lastWeek)
+	require.NoError(t, err)
+	_, err = env.service.StartReview(env.ctx, weekly_plan.WeekNumber{Year: 2025, Week: 22})
+	require.NoError(t, err)
+	_, err = env.service.Complete(env.ctx, weekly_plan.WeekNumber{Year: 2025, Week: 22})
+	require.NoError(t, err)
+
+	// when
+	inProgress, err1 := env.service.ReviewStatus(env.ctx, time.Date(2025, 6, 8, 20, 0, 0, 0, time.UTC))
+	reviewed, err2 := env.service.ReviewStatus(env.ctx, time.Date(2025, 5, 26, 0, 0, 0, 0, time.UTC))
+	notStarted, err3 := env.service.ReviewStatus(env.ctx, now)
+
+	// then
+	require.NoError(t, err1)
+	require.NoError(t, err2)
+	require.NoError(t, err3)
+	assert.Equal(t, "in_progress", inProgress)
+	assert.Equal(t, "reviewed", reviewed)
+	assert.Equal(t, "not_started", notStarted)
+}
@@ -0,0 +1,45 @@
+package week_review
+
+import (
+	"time"
+
+	"github.com/klokku/klokku/pkg/weekly_plan"
+)
+
+const MaxNoteLen = 2000
+
+type Status string
+
+const (
+	// StatusNotStarted is reported for weeks without a review, it is never stored
+	StatusNotStarted Status = "not_started"
+	StatusInProgress Status = "in_progress"
+	StatusReviewed   Status = "reviewed"
+)
+
+// Review is the retrospective of a past week.
+type Review struct {
+	Id     int
+	UserId int
+	Week   weekly_plan.WeekNumber
+	Status Status
+	// ItemNotes maps budget item ids to the notes about the item
+	ItemNotes map[int]string
+	CreatedAt time.Time
+	// CompletedAt is nil until the week is marked as reviewed
+	CompletedAt *time.Time
+	// Deviations are calculated from the stats of the week, they are not stored
+	Deviations []Deviation
+}
+
+// Deviation is how the time tracked for a weekly plan item differs from the planned time.
+type Deviation struct {
+	BudgetItemId int
+	Name         string
+	Color        string
+	Planned      time.Duration
+	Tracked      time.Duration
+	// Difference is positive when more time was tracked than planned
+	Difference time.Duration
+	Note       string
+}
@@ -0,0 +1,59 @@
+package weekly_digest
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klokku/klokku/internal/config"
+)
+
+// Notifier delivers a plain text message to an email address.
+type Notifier interface {
+	Send(ctx context.Context, to string, subject string, body string) error
+}
+
+// SmtpNotifier sends emails through the configured SMTP server.
+type SmtpNotifier struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSmtpNotifier returns nil when no SMTP host is configured.
+func NewSmtpNotifier(cfg config.Smtp) Notifier {
+	if cfg.Host == "" {
+		return nil
+	}
+	var auth smtp.Auth
+	if cfg.User != "" {
+		auth = smtp.PlainAuth("", cfg.User, cfg.Pass, cfg.Host)
+	}
+	return &SmtpNotifier{
+		addr: cfg.Host + ":" + strconv.Itoa(cfg.Port),
+		auth: auth,
+		from: cfg.From,
+	}
+}
+
+func (n *SmtpNotifier) Send(ctx context.Context, to string, subject string, body string) error {
+	var msg strings.Builder
+	msg.WriteString("From: " + n.from + "\r\n")
+	msg.WriteString("To: " + to + "\r\n")
+	msg.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	if err := smtp.SendMail(n.addr, n.auth, n.from, []string{to}, []byte(msg.String())); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package weekly_digest
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/klokku/klokku/pkg/user"
+)
+
+// digestTemplate is parsed with placeholder number functions, renderBody replaces them with the ones formatting
+// according to the user's settings.
+var digestTemplate = template.Must(template.New("digest").Funcs(template.FuncMap{
+	"duration": func(d time.Duration) string { return "" },
+	"date":     func(t time.Time) string { return t.Format("Jan 2") },
+	"lastDay":  func(t time.Time) string { return t.AddDate(0, 0, -1).Format("Jan 2") },
+	"percent":  func(p float64) string { return "" },
+}).Parse(`Hi {{.DisplayName}},
+
+here is how your week {{.Week}} ({{date .StartDate}} - {{lastDay .EndDate}}) went.
+
+Plan vs. actual:
+{{- range .Items}}
+  {{.Name}}: {{duration .Tracked}} of {{duration .Planned}}{{if .Planned}} ({{percent .Percentage}}){{end}}
+{{- else}}
+  Nothing was planned.
+{{- end}}
+  Total: {{duration .TotalTracked}} of {{duration .TotalPlanned}}
+
+Plan of week {{.NextWeek}}:
+{{- range .Upcoming}}
+  {{.Name}}: {{duration .Planned}}
+{{- else}}
+  Nothing planned yet.
+{{- end}}
+
+You receive this email because the weekly digest is enabled in your Klokku settings.
+`))
+
+func renderSubject(digest Digest) string {
+	return fmt.Sprintf("Your Klokku week %s", digest.Week)
+}
+
+func renderBody(digest Digest, formatter user.Formatter) (string, error) {
+	tmpl, err := digestTemplate.Clone()
+	if err != nil {
+		return "", fmt.Errorf("failed to render weekly digest: %w", err)
+	}
+	tmpl.Funcs(template.FuncMap{
+		"duration": func(d time.Duration) string { return formatDuration(d, formatter) },
+		"percent":  func(p float64) string { return formatter.Decimal(p, 0) + "%" },
+	})
+	var body strings.Builder
+	if err := tmpl.Execute(&body, digest); err != nil {
+		return "", fmt.Errorf("failed to render weekly digest: %w", err)
+	}
+	return body.String(), nil
+}
+
+// formatDuration formats the duration as hours and minutes, e.g. "5h 30m", or as decimal hours in the user's locale,
+// e.g. "5.5h", when the user prefers them.
+func formatDuration(d time.Duration, formatter user.Formatter) string {
+	d = d.Round(time.Minute)
+	if formatter.DecimalHours() {
+		return formatter.Decimal(d.Hours(), 1) + "h"
+	}
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	if hours == 0 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	if minutes == 0 {
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
@@ -0,0 +1,41 @@
+package weekly_digest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+)
+
+type Repository interface {
+	// WasSent reports whether the digest of the week was already sent to the user.
+	WasSent(ctx context.Context, userId int, week weekly_plan.WeekNumber) (bool, error)
+	MarkSent(ctx context.Context, userId int, week weekly_plan.WeekNumber) error
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+func (r *RepositoryImpl) WasSent(ctx context.Context, userId int, week weekly_plan.WeekNumber) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM weekly_digest_sent WHERE user_id = $1 AND week_number = $2)`
+	var sent bool
+	if err := r.db.QueryRow(ctx, query, userId, week.String()).Scan(&sent); err != nil {
+		return false, fmt.Errorf("failed to check weekly digest: %w", err)
+	}
+	return sent, nil
+}
+
+func (r *RepositoryImpl) MarkSent(ctx context.Context, userId int, week weekly_plan.WeekNumber) error {
+	query := `INSERT INTO weekly_digest_sent (user_id, week_number) VALUES ($1, $2)
+			  ON CONFLICT (user_id, week_number) DO NOTHING`
+	if _, err := r.db.Exec(ctx, query, userId, week.String()); err != nil {
+		return fmt.Errorf("failed to mark weekly digest as sent: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package weekly_digest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/klokku/klokku/pkg/weekly_plan"
+)
+
+type RepositoryStub struct {
+	mu   sync.RWMutex
+	sent map[int]map[string]bool // userId -> week -> sent
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{sent: make(map[int]map[string]bool)}
+}
+
+func (r *RepositoryStub) WasSent(ctx context.Context, userId int, week weekly_plan.WeekNumber) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sent[userId][week.String()], nil
+}
+
+func (r *RepositoryStub) MarkSent(ctx context.Context, userId int, week weekly_plan.WeekNumber) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sent[userId] == nil {
+		r.sent[userId] = make(map[string]bool)
+	}
+	r.sent[userId][week.String()] = true
+	return nil
+}
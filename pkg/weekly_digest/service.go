@@ -0,0 +1,179 @@
+package weekly_digest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/pkg/stats"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	log "github.com/sirupsen/logrus"
+)
+
+type Service interface {
+	// BuildDigest builds the digest of the current user for the last week finished before now.
+	BuildDigest(ctx context.Context, now time.Time) (Digest, error)
+	// SendDueDigests sends the digest to every opted-in user whose digest is due at now and was not sent yet.
+	SendDueDigests(ctx context.Context, now time.Time) error
+}
+
+type usersProvider interface {
+	GetAllUsers(ctx context.Context) ([]user.User, error)
+}
+
+type weeklyStatsProvider interface {
+	GetWeeklyStats(ctx context.Context, weekTime time.Time, includeSandbox bool) (stats.WeeklyStatsSummary, error)
+}
+
+type weeklyPlanItemsReader interface {
+	GetItemsForWeek(ctx context.Context, date time.Time) ([]weekly_plan.WeeklyPlanItem, error)
+}
+
+type ServiceImpl struct {
+	repo       Repository
+	users      usersProvider
+	stats      weeklyStatsProvider
+	weeklyPlan weeklyPlanItemsReader
+	// notifier is nil when emails cannot be sent
+	notifier Notifier
+	weekday  time.Weekday
+	hour     int
+}
+
+func NewService(
+	repo Repository,
+	users usersProvider,
+	stats weeklyStatsProvider,
+	weeklyPlan weeklyPlanItemsReader,
+	notifier Notifier,
+	cfg config.Digest,
+) *ServiceImpl {
+	weekday, ok := parseWeekday(cfg.Weekday)
+	if !ok {
+		log.Warnf("invalid weekly digest weekday %q, using monday", cfg.Weekday)
+	}
+	return &ServiceImpl{
+		repo:       repo,
+		users:      users,
+		stats:      stats,
+		weeklyPlan: weeklyPlan,
+		notifier:   notifier,
+		weekday:    weekday,
+		hour:       min(max(cfg.Hour, 0), 23),
+	}
+}
+
+func (s *ServiceImpl) BuildDigest(ctx context.Context, now time.Time) (Digest, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return Digest{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	location, err := time.LoadLocation(currentUser.Settings.Timezone)
+	if err != nil {
+		return Digest{}, fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	localNow := now.In(location)
+	weekDate := localNow.AddDate(0, 0, -7)
+
+	summary, err := s.stats.GetWeeklyStats(ctx, weekDate, false)
+	if err != nil {
+		return Digest{}, fmt.Errorf("failed to get weekly stats: %w", err)
+	}
+	digest := Digest{
+		DisplayName:  currentUser.DisplayName,
+		Week:         weekly_plan.WeekNumberFromDate(weekDate, currentUser.Settings.WeekFirstDay),
+		StartDate:    summary.StartDate,
+		EndDate:      summary.EndDate,
+		Items:        make([]ItemSummary, 0, len(summary.PerPlanItem)),
+		TotalPlanned: summary.TotalPlanned,
+		TotalTracked: summary.TotalTime,
+		NextWeek:     weekly_plan.WeekNumberFromDate(localNow, currentUser.Settings.WeekFirstDay),
+		Upcoming:     make([]UpcomingItem, 0),
+	}
+	if digest.DisplayName == "" {
+		digest.DisplayName = currentUser.Username
+	}
+	for _, item := range summary.PerPlanItem {
+		// Time of sub-items is already included in their parents
+		if item.PlanItem.ParentBudgetItemId != 0 {
+			continue
+		}
+		digest.Items = append(digest.Items, ItemSummary{
+			Name:       item.PlanItem.Name,
+			Planned:    item.PlanItem.WeeklyItemDuration,
+			Tracked:    item.Duration,
+			Percentage: item.Percentage(),
+		})
+	}
+
+	upcoming, err := s.weeklyPlan.GetItemsForWeek(ctx, localNow)
+	if err != nil {
+		return Digest{}, fmt.Errorf("failed to get the plan of week %s: %w", digest.NextWeek, err)
+	}
+	for _, item := range upcoming {
+		digest.Upcoming = append(digest.Upcoming, UpcomingItem{Name: item.Name, Planned: item.WeeklyDuration})
+	}
+	return digest, nil
+}
+
+func (s *ServiceImpl) SendDueDigests(ctx context.Context, now time.Time) error {
+	if s.notifier == nil {
+		return nil
+	}
+	users, err := s.users.GetAllUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get users: %w", err)
+	}
+	for _, u := range users {
+		if u.Disabled || !u.Settings.WeeklyDigest.Enabled || u.Settings.WeeklyDigest.Email == "" {
+			continue
+		}
+		if err := s.sendIfDue(user.WithUser(ctx, u), u, now); err != nil {
+			log.Errorf("failed to send weekly digest to user %d: %v", u.Id, err)
+		}
+	}
+	return nil
+}
+
+func (s *ServiceImpl) sendIfDue(ctx context.Context, u user.User, now time.Time) error {
+	location, err := time.LoadLocation(u.Settings.Timezone)
+	if err != nil {
+		return fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	localNow := now.In(location)
+	// Due from the configured hour until the end of the day, so a restart does not skip the week
+	if localNow.Weekday() != s.weekday || localNow.Hour() < s.hour {
+		return nil
+	}
+	week := weekly_plan.WeekNumberFromDate(localNow.AddDate(0, 0, -7), u.Settings.WeekFirstDay)
+	sent, err := s.repo.WasSent(ctx, u.Id, week)
+	if err != nil || sent {
+		return err
+	}
+
+	digest, err := s.BuildDigest(ctx, now)
+	if err != nil {
+		return err
+	}
+	body, err := renderBody(digest, user.NewFormatter(u.Settings))
+	if err != nil {
+		return err
+	}
+	if err := s.notifier.Send(ctx, u.Settings.WeeklyDigest.Email, renderSubject(digest), body); err != nil {
+		return err
+	}
+	log.Debugf("weekly digest of week %s sent to user %d", week, u.Id)
+	return s.repo.MarkSent(ctx, u.Id, week)
+}
+
+func parseWeekday(day string) (time.Weekday, bool) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(weekday.String(), strings.TrimSpace(day)) {
+			return weekday, true
+		}
+	}
+	return time.Monday, false
+}
@@ -0,0 +1,192 @@
+package weekly_digest
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/pkg/stats"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/klokku/klokku/pkg/weekly_plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type usersProviderStub struct {
+	users []user.User
+}
+
+func (s *usersProviderStub) GetAllUsers(ctx context.Context) ([]user.User, error) {
+	return s.users, nil
+}
+
+type weeklyStatsProviderStub struct {
+	requested []time.Time
+}
+
+func (s *weeklyStatsProviderStub) GetWeeklyStats(ctx context.Context, weekTime time.Time, includeSandbox bool) (stats.WeeklyStatsSummary, error) {
+	s.requested = append(s.requested, weekTime)
+	startDate := time.Date(2025, 6, 2, 0, 0, 0, 0, weekTime.Location())
+	return stats.WeeklyStatsSummary{
+		StartDate: startDate,
+		EndDate:   startDate.AddDate(0, 0, 7),
+		PerPlanItem: []stats.PlanItemStats{
+			{PlanItem: stats.PlanItem{BudgetItemId: 1, Name: "Exercise", WeeklyItemDuration: 5 * time.Hour}, Duration: 4 * time.Hour},
+			{PlanItem: stats.PlanItem{BudgetItemId: 2, Name: "Running", WeeklyItemDuration: 2 * time.Hour, ParentBudgetItemId: 1}, Duration: time.Hour},
+			{PlanItem: stats.PlanItem{BudgetItemId: 3, Name: "Reading", WeeklyItemDuration: 3 * time.Hour}, Duration: 90 * time.Minute},
+		},
+		TotalPlanned: 8 * time.Hour,
+		TotalTime:    5*time.Hour + 30*time.Minute,
+	}, nil
+}
+
+type weeklyPlanItemsReaderStub struct{}
+
+func (s *weeklyPlanItemsReaderStub) GetItemsForWeek(ctx context.Context, date time.Time) ([]weekly_plan.WeeklyPlanItem, error) {
+	return []weekly_plan.WeeklyPlanItem{
+		{BudgetItemId: 1, Name: "Exercise", WeeklyDuration: 6 * time.Hour},
+		{BudgetItemId: 3, Name: "Reading", WeeklyDuration: 3 * time.Hour},
+	}, nil
+}
+
+type sentEmail struct {
+	to      string
+	subject string
+	body    string
+}
+
+type notifierStub struct {
+	sent []sentEmail
+}
+
+func (n *notifierStub) Send(ctx context.Context, to string, subject string, body string) error {
+	n.sent = append(n.sent, sentEmail{to: to, subject: subject, body: body})
+	return nil
+}
+
+func digestUser(id int, enabled bool) user.User {
+	return user.User{
+		Id:          id,
+		Uid:         "user-" + strconv.Itoa(id),
+		Username:    "test-user",
+		DisplayName: "Test User",
+		Settings: user.Settings{
+			Timezone:     "Europe/Warsaw",
+			WeekFirstDay: time.Monday,
+			WeeklyDigest: user.WeeklyDigestSettings{Enabled: enabled, Email: "user@example.com"},
+		},
+	}
+}
+
+func setupServiceTest(t *testing.T, users ...user.User) (*ServiceImpl, *notifierStub, *weeklyStatsProviderStub) {
+	t.Helper()
+	notifier := &notifierStub{}
+	statsProvider := &weeklyStatsProviderStub{}
+	service := NewService(
+		NewRepositoryStub(),
+		&usersProviderStub{users: users},
+		statsProvider,
+		&weeklyPlanItemsReaderStub{},
+		notifier,
+		config.Digest{Weekday: "monday", Hour: 8},
+	)
+	return service, notifier, statsProvider
+}
+
+func TestService_BuildDigest(t *testing.T) {
+	service, _, statsProvider := setupServiceTest(t)
+	ctx := user.WithUser(context.Background(), digestUser(1, true))
+	// Monday 2025-06-09 09:00 in Warsaw
+	now := time.Date(2025, 6, 9, 7, 0, 0, 0, time.UTC)
+
+	digest, err := service.BuildDigest(ctx, now)
+	require.NoError(t, err)
+
+	require.Len(t, statsProvider.requested, 1)
+	assert.Equal(t, time.Date(2025, 6, 2, 7, 0, 0, 0, time.UTC).Unix(), statsProvider.requested[0].Unix())
+	assert.Equal(t, "2025-W23", digest.Week.String())
+	assert.Equal(t, "2025-W24", digest.NextWeek.String())
+	assert.Equal(t, []ItemSummary{
+		{Name: "Exercise", Planned: 5 * time.Hour, Tracked: 4 * time.Hour, Percentage: 80},
+		{Name: "Reading", Planned: 3 * time.Hour, Tracked: 90 * time.Minute, Percentage: 50},
+	}, digest.Items)
+	assert.Equal(t, []UpcomingItem{{Name: "Exercise", Planned: 6 * time.Hour}, {Name: "Reading", Planned: 3 * time.Hour}}, digest.Upcoming)
+
+	body, err := renderBody(digest, user.NewFormatter(user.Settings{}))
+	require.NoError(t, err)
+	assert.Contains(t, body, "Hi Test User,")
+	assert.Contains(t, body, "Reading: 1h 30m of 3h (50%)")
+	assert.Contains(t, body, "Total: 5h 30m of 8h")
+	assert.Contains(t, body, "Plan of week 2025-W24:\n  Exercise: 6h")
+}
+
+func TestRenderBody_DecimalHoursInUserLocale(t *testing.T) {
+	digest := Digest{
+		DisplayName:  "Test User",
+		Items:        []ItemSummary{{Name: "Reading", Planned: 3 * time.Hour, Tracked: 90 * time.Minute, Percentage: 50}},
+		TotalPlanned: 3 * time.Hour,
+		TotalTracked: 90 * time.Minute,
+	}
+	formatter := user.NewFormatter(user.Settings{Locale: "de-DE", DurationFormat: user.DurationDecimalHours})
+
+	body, err := renderBody(digest, formatter)
+
+	require.NoError(t, err)
+	assert.Contains(t, body, "Reading: 1,5h of 3,0h (50%)")
+}
+
+func TestService_SendDueDigests(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("sends the digest once on the configured day and hour", func(t *testing.T) {
+		service, notifier, _ := setupServiceTest(t, digestUser(1, true))
+
+		// Monday 07:59 in Warsaw
+		require.NoError(t, service.SendDueDigests(ctx, time.Date(2025, 6, 9, 5, 59, 0, 0, time.UTC)))
+		assert.Empty(t, notifier.sent)
+
+		// Monday 08:00 in Warsaw
+		require.NoError(t, service.SendDueDigests(ctx, time.Date(2025, 6, 9, 6, 0, 0, 0, time.UTC)))
+		require.Len(t, notifier.sent, 1)
+		assert.Equal(t, "user@example.com", notifier.sent[0].to)
+		assert.Equal(t, "Your Klokku week 2025-W23", notifier.sent[0].subject)
+
+		require.NoError(t, service.SendDueDigests(ctx, time.Date(2025, 6, 9, 15, 0, 0, 0, time.UTC)))
+		assert.Len(t, notifier.sent, 1)
+
+		// Tuesday
+		require.NoError(t, service.SendDueDigests(ctx, time.Date(2025, 6, 10, 6, 0, 0, 0, time.UTC)))
+		assert.Len(t, notifier.sent, 1)
+
+		// Next Monday
+		require.NoError(t, service.SendDueDigests(ctx, time.Date(2025, 6, 16, 6, 0, 0, 0, time.UTC)))
+		require.Len(t, notifier.sent, 2)
+		assert.Equal(t, "Your Klokku week 2025-W24", notifier.sent[1].subject)
+	})
+
+	t.Run("skips users who did not opt in", func(t *testing.T) {
+		service, notifier, _ := setupServiceTest(t, digestUser(1, false))
+
+		require.NoError(t, service.SendDueDigests(ctx, time.Date(2025, 6, 9, 6, 0, 0, 0, time.UTC)))
+		assert.Empty(t, notifier.sent)
+	})
+
+	t.Run("skips disabled users", func(t *testing.T) {
+		disabled := digestUser(1, true)
+		disabled.Disabled = true
+		service, notifier, _ := setupServiceTest(t, disabled)
+
+		require.NoError(t, service.SendDueDigests(ctx, time.Date(2025, 6, 9, 6, 0, 0, 0, time.UTC)))
+		assert.Empty(t, notifier.sent)
+	})
+
+	t.Run("does nothing without a notifier", func(t *testing.T) {
+		service, _, statsProvider := setupServiceTest(t, digestUser(1, true))
+		service.notifier = nil
+
+		require.NoError(t, service.SendDueDigests(ctx, time.Date(2025, 6, 9, 6, 0, 0, 0, time.UTC)))
+		assert.Empty(t, statsProvider.requested)
+	})
+}
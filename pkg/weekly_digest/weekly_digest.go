@@ -0,0 +1,37 @@
+package weekly_digest
+
+import (
+	"time"
+
+	"github.com/klokku/klokku/pkg/weekly_plan"
+)
+
+// Digest is the weekly summary emailed to a user: plan vs. actual of the last finished week and the plan of the
+// week that follows it.
+type Digest struct {
+	DisplayName string
+	// Week is the summarized week, the last one finished when the digest is built.
+	Week      weekly_plan.WeekNumber
+	StartDate time.Time
+	// EndDate is exclusive, it is the start of NextWeek.
+	EndDate      time.Time
+	Items        []ItemSummary
+	TotalPlanned time.Duration
+	TotalTracked time.Duration
+	NextWeek     weekly_plan.WeekNumber
+	// Upcoming is the plan of NextWeek.
+	Upcoming []UpcomingItem
+}
+
+type ItemSummary struct {
+	Name    string
+	Planned time.Duration
+	Tracked time.Duration
+	// Percentage is the tracked share of the planned time, 0 when nothing was planned.
+	Percentage float64
+}
+
+type UpcomingItem struct {
+	Name    string
+	Planned time.Duration
+}
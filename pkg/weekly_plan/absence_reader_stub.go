@@ -0,0 +1,44 @@
+package weekly_plan
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// AbsenceReaderStub is a test stub implementation of AbsenceReader
+type AbsenceReaderStub struct {
+	mu   sync.RWMutex
+	days []time.Time
+}
+
+func NewAbsenceReaderStub() *AbsenceReaderStub {
+	return &AbsenceReaderStub{}
+}
+
+func (s *AbsenceReaderStub) AbsentDays(ctx context.Context, from time.Time, to time.Time) ([]time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var days []time.Time
+	for _, day := range s.days {
+		if !day.Before(from) && !day.After(to) {
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+// AddAbsentDays marks the days from the first to the last (inclusive), at midnight UTC, as absent.
+func (s *AbsenceReaderStub) AddAbsentDays(first time.Time, last time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		s.days = append(s.days, day)
+	}
+}
+
+func (s *AbsenceReaderStub) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.days = nil
+}
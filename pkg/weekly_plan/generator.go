@@ -0,0 +1,66 @@
+package weekly_plan
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/klokku/klokku/internal/config"
+	"github.com/klokku/klokku/internal/event_bus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Generator creates the plans of upcoming weeks in advance, so integrations and notifications can rely on them
+// existing before the user changes the week or tracks the first event in it. Like lazily created weeks, generated
+// weeks are based on the budget plan current at the time of the generation, upcoming weeks the user did not change
+// are generated again when items are added to the budget plan or another plan becomes current.
+type Generator struct {
+	service Service
+	// weeksAhead is how many weeks after the current one are generated, 0 disables the generation.
+	weeksAhead int
+}
+
+func NewGenerator(service Service, cfg config.WeeklyPlan) *Generator {
+	return &Generator{
+		service:    service,
+		weeksAhead: cfg.GenerateWeeksAhead,
+	}
+}
+
+// Enabled reports whether upcoming weeks are generated at all.
+func (g *Generator) Enabled() bool {
+	return g.weeksAhead > 0
+}
+
+// GenerateUpcomingWeeks generates the upcoming weeks of the user in the context, it does nothing when the user has
+// no current budget plan.
+func (g *Generator) GenerateUpcomingWeeks(ctx context.Context, now time.Time) error {
+	if !g.Enabled() {
+		return nil
+	}
+	generated, err := g.service.GenerateUpcomingWeeks(ctx, now, g.weeksAhead)
+	if err != nil && !errors.Is(err, ErrNoCurrentPlan) {
+		return err
+	}
+	if len(generated) > 0 {
+		log.Debugf("Generated %d upcoming weekly plans", len(generated))
+	}
+	return nil
+}
+
+// SubscribeToBudgetPlanChanges generates the upcoming weeks of the user again when an item is added to a budget plan
+// or a plan becomes current, so the weeks do not wait for the next daily run to follow the change.
+func (g *Generator) SubscribeToBudgetPlanChanges(eventBus *event_bus.EventBus) {
+	if !g.Enabled() {
+		return
+	}
+	for _, eventType := range []event_bus.EventType{"budget_plan.item.created", "budget_plan.plan.updated"} {
+		eventBus.Subscribe(eventType, func(e event_bus.Event) error {
+			if err := g.GenerateUpcomingWeeks(e.Context(), time.Now()); err != nil {
+				log.Errorf("failed to generate upcoming weekly plans on %s: %v", e.Type, err)
+				return err
+			}
+			return nil
+		})
+	}
+}
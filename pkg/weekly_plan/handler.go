@@ -1,32 +1,108 @@
 package weekly_plan
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
 	rest "github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/pkg/budget_plan"
 )
 
 type WeeklyPlanDTO struct {
-	BudgetPlanId int                 `json:"budgetPlanId"`
-	IsOffWeek    bool                `json:"isOffWeek"`
-	Items        []WeeklyPlanItemDTO `json:"items"`
+	BudgetPlanId int  `json:"budgetPlanId"`
+	IsOffWeek    bool `json:"isOffWeek"`
+	// LockedAt is set for a locked week, changes to its plan and events are rejected until it is unlocked
+	LockedAt *time.Time `json:"lockedAt,omitempty"`
+	// AbsentDays are the lowercase names of the weekdays (e.g. "monday") of a planned absence, the items'
+	// effectiveWeeklyDuration is reduced by them
+	AbsentDays []string            `json:"absentDays,omitempty"`
+	Items      []WeeklyPlanItemDTO `json:"items"`
+	// Categories rolls up the items by budget plan categories, omitted when the plan has no categories
+	Categories []CategoryTotalDTO `json:"categories,omitempty"`
+}
+
+type WeeklyPlanWeekDTO struct {
+	WeekNumber string `json:"weekNumber"`
+	WeeklyPlanDTO
+}
+
+type CategoryTotalDTO struct {
+	// CategoryId is 0 for the group of uncategorized items
+	CategoryId     int    `json:"categoryId"`
+	Name           string `json:"name"`
+	Color          string `json:"color,omitempty"`
+	WeeklyDuration int    `json:"weeklyDuration"`
+	BudgetItemIds  []int  `json:"budgetItemIds"`
 }
 
 type WeeklyPlanItemDTO struct {
-	Id                int    `json:"id"`
+	Id int `json:"id"`
+	// BudgetItemId is negative for ad-hoc items, use it to reference the item in calendar events
 	BudgetItemId      int    `json:"budgetItemId"`
+	Name              string `json:"name"`
+	WeeklyDuration    int    `json:"weeklyDuration"`
+	WeeklyOccurrences int    `json:"weeklyOccurrences"`
+	// DailyDurations maps lowercase weekday names (e.g. "monday") to the daily target in seconds.
+	DailyDurations map[string]int `json:"dailyDurations,omitempty"`
+	Icon           string         `json:"icon,omitempty"`
+	Color          string         `json:"color,omitempty"`
+	Notes          string         `json:"notes"`
+	Position       int            `json:"position"`
+	// CarriedOver is the time (in seconds) rolled over from the previous week and included in weeklyDuration,
+	// negative when the previous week was overspent. Omitted when no rollover was applied.
+	CarriedOver *int `json:"carriedOver,omitempty"`
+	// AdHoc is set for items added to the week only, without a budget plan item
+	AdHoc bool `json:"adHoc,omitempty"`
+	// DailyDurationsCustomized is set when dailyDurations were edited for this week, they then sum up to weeklyDuration
+	DailyDurationsCustomized bool `json:"dailyDurationsCustomized,omitempty"`
+	// EffectiveWeeklyDuration is the time (in seconds) planned for the days of the week the user is present on, i.e.
+	// weeklyDuration without absenceReduction. The stats compare the tracked time with it.
+	EffectiveWeeklyDuration int `json:"effectiveWeeklyDuration"`
+	// AbsenceReduction is the time (in seconds) taken off weeklyDuration for the absent days of the week
+	AbsenceReduction int `json:"absenceReduction,omitempty"`
+}
+
+type ItemDailyDurationsDTO struct {
+	BudgetItemId int `json:"budgetItemId"`
+	// DailyDurations maps lowercase weekday names (e.g. "monday") to seconds and must sum up to the weekly duration.
+	// Empty daily durations restore the distribution of the budget item.
+	DailyDurations map[string]int `json:"dailyDurations"`
+}
+
+type AdHocItemDTO struct {
 	Name              string `json:"name"`
 	WeeklyDuration    int    `json:"weeklyDuration"`
 	WeeklyOccurrences int    `json:"weeklyOccurrences"`
 	Icon              string `json:"icon,omitempty"`
 	Color             string `json:"color,omitempty"`
 	Notes             string `json:"notes"`
-	Position          int    `json:"position"`
+}
+
+type WeekPreviewDTO struct {
+	WeekNumber   string              `json:"weekNumber"`
+	BudgetPlanId int                 `json:"budgetPlanId"`
+	IsGenerated  bool                `json:"isGenerated"`
+	Items        []PreviewItemDTO    `json:"items"`
+	RemovedItems []WeeklyPlanItemDTO `json:"removedItems"`
+}
+
+type PreviewItemDTO struct {
+	WeeklyPlanItemDTO
+	Change                 PreviewChange `json:"change"`
+	PreviousWeeklyDuration int           `json:"previousWeeklyDuration"`
+}
+
+type CopyWeekRequestDTO struct {
+	// SourceDate and TargetDate are any day of the weeks, in RFC3339 or YYYY-MM-DD format
+	SourceDate string `json:"sourceDate"`
+	TargetDate string `json:"targetDate"`
+	// Overwrite replaces target items the user already customized, otherwise they are skipped
+	Overwrite bool `json:"overwrite"`
 }
 
 type Handler struct {
@@ -41,50 +117,92 @@ func NewHandler(service Service) *Handler {
 
 // GetPlan godoc
 // @Summary Get weekly plan items
-// @Description Retrieve all items for a specific week
+// @Description Retrieve all items for a specific week.
+// @Description The response has an ETag, a request with a matching If-None-Match header gets 304 Not Modified.
 // @Tags WeeklyPlan
 // @Produce json
 // @Param date query string true "Date in RFC3339 format (can be any day of the week)"
 // @Success 200 {object} WeeklyPlanDTO
+// @Success 304 "Not Modified"
 // @Failure 400 {object} rest.ErrorResponse "Invalid date format"
 // @Failure 403 {string} string "User not found"
 // @Router /api/weeklyplan [get]
 // @Security XUserId
 func (h *Handler) GetPlan(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
 	// Can be any day of the given week
-	weekDateString := r.URL.Query().Get("date")
-	weekDate, err := time.Parse(time.RFC3339, weekDateString)
+	weekDate, err := queryWeekDate(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
-			Error:   "Incorrect date format",
-			Details: "Date must be in RFC3339 format",
-		})
-		if encodeErr != nil {
-			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
-			return
-		}
+		rest.WriteBadRequest(w, err)
+		return
 	}
 	plan, err := h.service.GetPlanForWeek(r.Context(), weekDate)
 	if err != nil {
 		if errors.Is(err, ErrNoCurrentPlan) {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			rest.WriteNotFound(w, err)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 
-	itemsDTO := make([]WeeklyPlanItemDTO, 0, len(plan.Items))
-	for _, item := range plan.Items {
-		itemsDTO = append(itemsDTO, WeeklyPlanItemToDTO(item))
+	planDTO, err := h.weeklyPlanToDTO(r.Context(), plan)
+	if err != nil {
+		rest.WriteInternalError(w, err)
+		return
 	}
-	if err := json.NewEncoder(w).Encode(WeeklyPlanDTO{plan.BudgetPlanId, plan.IsOffWeek, itemsDTO}); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	rest.WriteJSONWithETag(w, r, planDTO)
+}
+
+// GetPlanRange godoc
+// @Summary Get weekly plans of a date range
+// @Description Retrieve the plans of all weeks between two dates in one call, grouped by week.
+// @Description The response has an ETag, a request with a matching If-None-Match header gets 304 Not Modified.
+// @Tags WeeklyPlan
+// @Produce json
+// @Param from query string true "First date of the range in RFC3339 or YYYY-MM-DD format (can be any day of the week)"
+// @Param to query string true "Last date of the range in RFC3339 or YYYY-MM-DD format (can be any day of the week)"
+// @Success 200 {array} WeeklyPlanWeekDTO
+// @Success 304 "Not Modified"
+// @Failure 400 {object} rest.ErrorResponse "Invalid date format or range"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {object} rest.ErrorResponse "No current budget plan"
+// @Router /api/weeklyplan/range [get]
+// @Security XUserId
+func (h *Handler) GetPlanRange(w http.ResponseWriter, r *http.Request) {
+	var v rest.Validator
+	from, fromErr := parseWeekDate(r.URL.Query().Get("from"))
+	v.Check(fromErr == nil, "from", "Incorrect date format", "'from' must be in RFC3339 or YYYY-MM-DD format")
+	to, toErr := parseWeekDate(r.URL.Query().Get("to"))
+	v.Check(toErr == nil, "to", "Incorrect date format", "'to' must be in RFC3339 or YYYY-MM-DD format")
+	if err := v.Err(); err != nil {
+		rest.WriteBadRequest(w, err)
 		return
 	}
+
+	plans, err := h.service.GetPlansForRange(r.Context(), from, to)
+	if err != nil {
+		if errors.Is(err, ErrInvalidRange) || errors.Is(err, ErrRangeTooLong) {
+			rest.WriteError(w, http.StatusBadRequest, rest.ErrorResponse{Error: err.Error()})
+			return
+		}
+		if errors.Is(err, ErrNoCurrentPlan) {
+			rest.WriteNotFound(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
+		return
+	}
+
+	weeksDTO := make([]WeeklyPlanWeekDTO, 0, len(plans))
+	for _, plan := range plans {
+		planDTO, err := h.weeklyPlanToDTO(r.Context(), plan)
+		if err != nil {
+			rest.WriteInternalError(w, err)
+			return
+		}
+		weeksDTO = append(weeksDTO, WeeklyPlanWeekDTO{WeekNumber: plan.WeekNumber.String(), WeeklyPlanDTO: planDTO})
+	}
+	rest.WriteJSONWithETag(w, r, weeksDTO)
 }
 
 // UpdateItem godoc
@@ -98,25 +216,18 @@ func (h *Handler) GetPlan(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} WeeklyPlanItemDTO
 // @Failure 400 {object} rest.ErrorResponse "Invalid request"
 // @Failure 403 {string} string "User not found"
-// @Failure 404 {string} string "Item Not Found"
+// @Failure 404 {object} rest.ErrorResponse "Item Not Found"
+// @Failure 409 {object} rest.ErrorResponse "Week is locked"
 // @Router /api/weeklyplan/item [put]
 // @Security XUserId
 func (h *Handler) UpdateItem(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	// Can be any day of the given week
-	weekDateString := r.URL.Query().Get("date")
-	weekDate, err := time.Parse(time.RFC3339, weekDateString)
+	weekDate, err := queryWeekDate(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
-			Error:   "Incorrect date format",
-			Details: "Date must be in RFC3339 format",
-		})
-		if encodeErr != nil {
-			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
-			return
-		}
+		rest.WriteBadRequest(w, err)
+		return
 	}
 
 	var updateItemDTO struct {
@@ -126,42 +237,163 @@ func (h *Handler) UpdateItem(w http.ResponseWriter, r *http.Request) {
 		Notes        string `json:"notes"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&updateItemDTO); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
-			Error: "Invalid request body format",
-		})
-		if encodeErr != nil {
-			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
-			return
-		}
+	if err := rest.DecodeJSON(r, &updateItemDTO); err != nil {
+		rest.WriteBadRequest(w, err)
 		return
 	}
 	if updateItemDTO.Id == 0 && updateItemDTO.BudgetItemId == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
-			Error: "Id or budgetItemId must be provided",
-		})
-		if encodeErr != nil {
-			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
-			return
-		}
+		rest.WriteBadRequest(w, rest.InvalidField("budgetItemId", "Id or budgetItemId must be provided",
+			"'id' or 'budgetItemId' must be provided"))
+		return
 	}
 
 	duration := time.Duration(updateItemDTO.Duration) * time.Second
 
 	updatedItem, err := h.service.UpdateItem(r.Context(), weekDate, updateItemDTO.Id, updateItemDTO.BudgetItemId, duration, updateItemDTO.Notes)
 	if err != nil {
+		if errors.Is(err, ErrWeekLocked) {
+			rest.WriteConflict(w, err)
+			return
+		}
 		if errors.Is(err, ErrWeeklyPlanItemNotFound) {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			rest.WriteNotFound(w, err)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
 		return
 	}
 
 	if err := json.NewEncoder(w).Encode(WeeklyPlanItemToDTO(updatedItem)); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
+		return
+	}
+}
+
+// MoveItem godoc
+// @Summary Set position of a weekly plan item
+// @Description Move a weekly plan item right after another item of the week, or to the top when precedingBudgetItemId is 0.
+// @Description Only the given week is reordered, the budget plan keeps its order.
+// @Tags WeeklyPlan
+// @Accept json
+// @Produce json
+// @Param date query string true "Date in RFC3339 format (can be any day of the week)"
+// @Param position body object{budgetItemId=int,precedingBudgetItemId=int} true "Position details"
+// @Success 200 {object} WeeklyPlanDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {object} rest.ErrorResponse "Item Not Found"
+// @Failure 409 {object} rest.ErrorResponse "Week is locked"
+// @Router /api/weeklyplan/item/position [put]
+// @Security XUserId
+func (h *Handler) MoveItem(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	weekDate, err := queryWeekDate(r)
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+
+	var body struct {
+		BudgetItemId          int `json:"budgetItemId"`
+		PrecedingBudgetItemId int `json:"precedingBudgetItemId"`
+	}
+	if err := rest.DecodeJSON(r, &body); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	if body.BudgetItemId == 0 {
+		rest.WriteBadRequest(w, rest.InvalidField("budgetItemId", "Invalid request body format",
+			"'budgetItemId' must be provided"))
+		return
+	}
+
+	plan, err := h.service.MoveItemAfter(r.Context(), weekDate, body.BudgetItemId, body.PrecedingBudgetItemId)
+	if err != nil {
+		if errors.Is(err, ErrWeekLocked) {
+			rest.WriteConflict(w, err)
+			return
+		}
+		if errors.Is(err, ErrWeeklyItemNotFound) || errors.Is(err, ErrNoCurrentPlan) {
+			rest.WriteNotFound(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
+		return
+	}
+
+	planDTO, err := h.weeklyPlanToDTO(r.Context(), plan)
+	if err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(planDTO); err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+}
+
+// SetItemDailyDurations godoc
+// @Summary Set daily durations of a weekly plan item
+// @Description Distribute a weekly plan item across weekdays for the given week only. The daily durations must sum up
+// @Description to the item's weekly duration. Changing the weekly duration later drops the distribution.
+// @Tags WeeklyPlan
+// @Accept json
+// @Produce json
+// @Param date query string true "Date in RFC3339 format (can be any day of the week)"
+// @Param dailyDurations body ItemDailyDurationsDTO true "Daily durations"
+// @Success 200 {object} WeeklyPlanItemDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {object} rest.ErrorResponse "Item Not Found"
+// @Failure 409 {object} rest.ErrorResponse "Week is locked"
+// @Router /api/weeklyplan/item/daily-durations [put]
+// @Security XUserId
+func (h *Handler) SetItemDailyDurations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	weekDate, err := queryWeekDate(r)
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+
+	var body ItemDailyDurationsDTO
+	if err := rest.DecodeJSON(r, &body); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	if body.BudgetItemId == 0 {
+		rest.WriteBadRequest(w, rest.InvalidField("budgetItemId", "Invalid request body format",
+			"'budgetItemId' must be provided"))
+		return
+	}
+	if err := budget_plan.ValidateDailyDurationsDTO(body.DailyDurations); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+
+	item, err := h.service.SetItemDailyDurations(r.Context(), weekDate, body.BudgetItemId,
+		budget_plan.DTOToDailyDurations(body.DailyDurations))
+	if err != nil {
+		if errors.Is(err, ErrInvalidDailyDurations) {
+			rest.WriteError(w, http.StatusBadRequest, rest.ErrorResponse{Error: err.Error()})
+			return
+		}
+		if errors.Is(err, ErrWeekLocked) {
+			rest.WriteConflict(w, err)
+			return
+		}
+		if errors.Is(err, ErrWeeklyItemNotFound) || errors.Is(err, ErrBudgetItemNotFound) || errors.Is(err, ErrNoCurrentPlan) {
+			rest.WriteNotFound(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(WeeklyPlanItemToDTO(item)); err != nil {
+		rest.WriteInternalError(w, err)
 		return
 	}
 }
@@ -173,38 +405,134 @@ func (h *Handler) UpdateItem(w http.ResponseWriter, r *http.Request) {
 // @Produce json
 // @Param itemId path int true "Weekly Plan Item ID"
 // @Success 200 {object} WeeklyPlanItemDTO
-// @Failure 400 {object} rest.ErrorResponse "Invalid itemId"
+// @Failure 400 {object} rest.ErrorResponse "Invalid itemId or an ad-hoc item"
 // @Failure 403 {string} string "User not found"
+// @Failure 409 {object} rest.ErrorResponse "Week is locked"
 // @Router /api/weeklyplan/item/{itemId} [delete]
 // @Security XUserId
 func (h *Handler) ResetItem(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	vars := mux.Vars(r)
-	itemIdString := vars["itemId"]
-	itemId, err := strconv.ParseInt(itemIdString, 10, 64)
+	itemId, err := rest.PathInt(r, "itemId")
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
-			Error:   "Invalid itemId format",
-			Details: "Parameter itemId must be a number",
-		})
-		if encodeErr != nil {
-			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	itemAfterReset, err := h.service.ResetWeekItemToBudgetPlanItem(r.Context(), itemId)
+	if err != nil {
+		if errors.Is(err, ErrWeekLocked) {
+			rest.WriteConflict(w, err)
 			return
 		}
+		if errors.Is(err, ErrAdHocItemReset) {
+			rest.WriteBadRequest(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
 		return
 	}
-	itemAfterReset, err := h.service.ResetWeekItemToBudgetPlanItem(r.Context(), int(itemId))
+	if err := json.NewEncoder(w).Encode(WeeklyPlanItemToDTO(itemAfterReset)); err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+}
+
+// AddAdHocItem godoc
+// @Summary Add an ad-hoc item to a week
+// @Description Add a one-off item to the given week only, without adding it to the budget plan.
+// @Description Calendar events of the week reference it by its (negative) budgetItemId.
+// @Tags WeeklyPlan
+// @Accept json
+// @Produce json
+// @Param date query string true "Date in RFC3339 format (can be any day of the week)"
+// @Param item body AdHocItemDTO true "Ad-hoc item"
+// @Success 201 {object} WeeklyPlanItemDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {object} rest.ErrorResponse "No current budget plan"
+// @Failure 409 {object} rest.ErrorResponse "Week is locked"
+// @Router /api/weeklyplan/ad-hoc-item [post]
+// @Security XUserId
+func (h *Handler) AddAdHocItem(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	weekDate, err := queryWeekDate(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteBadRequest(w, err)
 		return
 	}
-	if err := json.NewEncoder(w).Encode(WeeklyPlanItemToDTO(itemAfterReset)); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+	var body AdHocItemDTO
+	if err := rest.DecodeJSON(r, &body); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+
+	created, err := h.service.AddAdHocItem(r.Context(), weekDate, WeeklyPlanItem{
+		Name:              body.Name,
+		WeeklyDuration:    time.Duration(body.WeeklyDuration) * time.Second,
+		WeeklyOccurrences: body.WeeklyOccurrences,
+		Icon:              body.Icon,
+		Color:             body.Color,
+		Notes:             body.Notes,
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvalidAdHocItem) {
+			rest.WriteError(w, http.StatusBadRequest, rest.ErrorResponse{Error: err.Error()})
+			return
+		}
+		if errors.Is(err, ErrWeekLocked) {
+			rest.WriteConflict(w, err)
+			return
+		}
+		if errors.Is(err, ErrNoCurrentPlan) {
+			rest.WriteNotFound(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(WeeklyPlanItemToDTO(created)); err != nil {
+		rest.WriteInternalError(w, err)
 		return
 	}
 }
 
+// DeleteAdHocItem godoc
+// @Summary Delete an ad-hoc item
+// @Description Remove an ad-hoc item from its week. Items created from the budget plan cannot be deleted.
+// @Tags WeeklyPlan
+// @Param itemId path int true "Weekly Plan Item ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} rest.ErrorResponse "Not an ad-hoc item"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {object} rest.ErrorResponse "Item Not Found"
+// @Failure 409 {object} rest.ErrorResponse "Week is locked"
+// @Router /api/weeklyplan/ad-hoc-item/{itemId} [delete]
+// @Security XUserId
+func (h *Handler) DeleteAdHocItem(w http.ResponseWriter, r *http.Request) {
+	itemId, err := rest.PathInt(r, "itemId")
+	if err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	if err := h.service.DeleteAdHocItem(r.Context(), itemId); err != nil {
+		switch {
+		case errors.Is(err, ErrNotAdHocItem):
+			rest.WriteBadRequest(w, err)
+		case errors.Is(err, ErrWeeklyItemNotFound):
+			rest.WriteNotFound(w, err)
+		case errors.Is(err, ErrWeekLocked):
+			rest.WriteConflict(w, err)
+		default:
+			rest.WriteInternalError(w, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // ResetWeek godoc
 // @Summary Reset entire week
 // @Description Reset all weekly plan items to budget plan values for a specific week
@@ -214,37 +542,37 @@ func (h *Handler) ResetItem(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} WeeklyPlanDTO
 // @Failure 400 {object} rest.ErrorResponse "Invalid date format"
 // @Failure 403 {string} string "User not found"
+// @Failure 409 {object} rest.ErrorResponse "Week is locked"
 // @Router /api/weeklyplan [delete]
 // @Security XUserId
 func (h *Handler) ResetWeek(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	// Can be any day of the given week
-	weekDateString := r.URL.Query().Get("date")
-	weekDate, err := time.Parse(time.RFC3339, weekDateString)
+	weekDate, err := queryWeekDate(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		encodeErr := json.NewEncoder(w).Encode(rest.ErrorResponse{
-			Error:   "Incorrect date format",
-			Details: "Date must be in RFC3339 format",
-		})
-		if encodeErr != nil {
-			http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
-			return
-		}
+		rest.WriteBadRequest(w, err)
+		return
 	}
 	itemsAfterReset, err := h.service.ResetWeekItemsToBudgetPlan(r.Context(), weekDate)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, ErrWeekLocked) {
+			rest.WriteConflict(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
 		return
 	}
 	var budgetPlanId int
-	var itemsDTO []WeeklyPlanItemDTO
 	for _, item := range itemsAfterReset {
-		itemsDTO = append(itemsDTO, WeeklyPlanItemToDTO(item))
 		budgetPlanId = item.BudgetPlanId
 	}
-	if err := json.NewEncoder(w).Encode(WeeklyPlanDTO{budgetPlanId, false, itemsDTO}); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	planDTO, err := h.weeklyPlanToDTO(r.Context(), WeeklyPlan{BudgetPlanId: budgetPlanId, Items: itemsAfterReset})
+	if err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(planDTO); err != nil {
+		rest.WriteInternalError(w, err)
 		return
 	}
 }
@@ -260,63 +588,320 @@ func (h *Handler) ResetWeek(w http.ResponseWriter, r *http.Request) {
 // @Success 200 {object} WeeklyPlanDTO
 // @Failure 400 {object} rest.ErrorResponse "Invalid request"
 // @Failure 403 {string} string "User not found"
+// @Failure 409 {object} rest.ErrorResponse "Week is locked"
 // @Router /api/weeklyplan/off-week [put]
 // @Security XUserId
 func (h *Handler) SetOffWeek(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	weekDateString := r.URL.Query().Get("date")
-	weekDate, err := time.Parse(time.RFC3339, weekDateString)
+	weekDate, err := queryWeekDate(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
-			Error:   "Incorrect date format",
-			Details: "Date must be in RFC3339 format",
-		})
+		rest.WriteBadRequest(w, err)
 		return
 	}
 
 	var body struct {
 		IsOffWeek bool `json:"isOffWeek"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
-			Error: "Invalid request body format",
-		})
+	if err := rest.DecodeJSON(r, &body); err != nil {
+		rest.WriteBadRequest(w, err)
 		return
 	}
 
 	plan, err := h.service.SetOffWeek(r.Context(), weekDate, body.IsOffWeek)
 	if err != nil {
+		if errors.Is(err, ErrWeekLocked) {
+			rest.WriteConflict(w, err)
+			return
+		}
+		if errors.Is(err, ErrNoCurrentPlan) {
+			rest.WriteNotFound(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
+		return
+	}
+
+	planDTO, err := h.weeklyPlanToDTO(r.Context(), plan)
+	if err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(planDTO); err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+}
+
+// PreviewWeek godoc
+// @Summary Preview weekly plan generation
+// @Description Show the items that would be generated from the current budget plan for the given week,
+// @Description flagging differences from the previous week. Nothing is persisted.
+// @Tags WeeklyPlan
+// @Produce json
+// @Param weekDate path string true "Date in RFC3339 or YYYY-MM-DD format (can be any day of the week)"
+// @Success 200 {object} WeekPreviewDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid date format"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {object} rest.ErrorResponse "No current plan"
+// @Router /api/weeklyplan/{weekDate}/preview [get]
+// @Security XUserId
+func (h *Handler) PreviewWeek(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	weekDate, err := parseWeekDate(mux.Vars(r)["weekDate"])
+	if err != nil {
+		rest.WriteBadRequest(w, rest.InvalidField("weekDate", "Incorrect date format",
+			"'weekDate' must be in RFC3339 or YYYY-MM-DD format"))
+		return
+	}
+
+	preview, err := h.service.PreviewWeek(r.Context(), weekDate)
+	if err != nil {
+		if errors.Is(err, ErrNoCurrentPlan) {
+			rest.WriteNotFound(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(weekPreviewToDTO(preview)); err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+}
+
+// CopyWeek godoc
+// @Summary Copy weekly plan from another week
+// @Description Copy weekly durations and notes of the source week's items into the matching items of the target week.
+// @Description Target items already customized are skipped unless overwrite is set.
+// @Tags WeeklyPlan
+// @Accept json
+// @Produce json
+// @Param body body CopyWeekRequestDTO true "Source and target week"
+// @Success 200 {object} WeeklyPlanDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid request"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {object} rest.ErrorResponse "No current plan or nothing to copy"
+// @Failure 409 {object} rest.ErrorResponse "Week is locked"
+// @Router /api/weeklyplan/copy [post]
+// @Security XUserId
+func (h *Handler) CopyWeek(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var body CopyWeekRequestDTO
+	if err := rest.DecodeJSON(r, &body); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+	var v rest.Validator
+	sourceDate, sourceErr := parseWeekDate(body.SourceDate)
+	v.Check(sourceErr == nil, "sourceDate", "Incorrect date format",
+		"'sourceDate' must be in RFC3339 or YYYY-MM-DD format")
+	targetDate, targetErr := parseWeekDate(body.TargetDate)
+	v.Check(targetErr == nil, "targetDate", "Incorrect date format",
+		"'targetDate' must be in RFC3339 or YYYY-MM-DD format")
+	if err := v.Err(); err != nil {
+		rest.WriteBadRequest(w, err)
+		return
+	}
+
+	plan, err := h.service.CopyWeek(r.Context(), sourceDate, targetDate, body.Overwrite)
+	if err != nil {
+		if errors.Is(err, ErrCopyToSameWeek) {
+			rest.WriteError(w, http.StatusBadRequest, rest.ErrorResponse{Error: err.Error()})
+			return
+		}
+		if errors.Is(err, ErrNoCurrentPlan) || errors.Is(err, ErrNothingToCopy) {
+			rest.WriteNotFound(w, err)
+			return
+		}
+		if errors.Is(err, ErrWeekLocked) {
+			rest.WriteConflict(w, err)
+			return
+		}
+		rest.WriteInternalError(w, err)
+		return
+	}
+
+	planDTO, err := h.weeklyPlanToDTO(r.Context(), plan)
+	if err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+	if err := json.NewEncoder(w).Encode(planDTO); err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+}
+
+// LockWeek godoc
+// @Summary Lock a past week
+// @Description Make a past week's plan and calendar events read-only, so reports of the week do not change.
+// @Description The week stays locked until it is explicitly unlocked.
+// @Tags WeeklyPlan
+// @Produce json
+// @Param date query string true "Date in RFC3339 or YYYY-MM-DD format (can be any day of the week)"
+// @Success 200 {object} WeeklyPlanDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid date format or the week is not in the past"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {object} rest.ErrorResponse "No current plan"
+// @Router /api/weeklyplan/lock [put]
+// @Security XUserId
+func (h *Handler) LockWeek(w http.ResponseWriter, r *http.Request) {
+	h.setWeekLock(w, r, h.service.LockWeek)
+}
+
+// UnlockWeek godoc
+// @Summary Unlock a week
+// @Description Allow changes to a previously locked week's plan and calendar events again
+// @Tags WeeklyPlan
+// @Produce json
+// @Param date query string true "Date in RFC3339 or YYYY-MM-DD format (can be any day of the week)"
+// @Success 200 {object} WeeklyPlanDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid date format"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {object} rest.ErrorResponse "No current plan"
+// @Router /api/weeklyplan/lock [delete]
+// @Security XUserId
+func (h *Handler) UnlockWeek(w http.ResponseWriter, r *http.Request) {
+	h.setWeekLock(w, r, h.service.UnlockWeek)
+}
+
+func (h *Handler) setWeekLock(w http.ResponseWriter, r *http.Request, setLock func(ctx context.Context, weekDate time.Time) (WeeklyPlan, error)) {
+	w.Header().Set("Content-Type", "application/json")
+
+	weekDate, err := parseWeekDate(r.URL.Query().Get("date"))
+	if err != nil {
+		rest.WriteBadRequest(w, rest.InvalidField("date", "Incorrect date format",
+			"Date must be in RFC3339 or YYYY-MM-DD format"))
+		return
+	}
+
+	plan, err := setLock(r.Context(), weekDate)
+	if err != nil {
+		if errors.Is(err, ErrWeekNotPast) {
+			rest.WriteError(w, http.StatusBadRequest, rest.ErrorResponse{Error: err.Error()})
+			return
+		}
 		if errors.Is(err, ErrNoCurrentPlan) {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			rest.WriteNotFound(w, err)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		rest.WriteInternalError(w, err)
+		return
+	}
+
+	planDTO, err := h.weeklyPlanToDTO(r.Context(), plan)
+	if err != nil {
+		rest.WriteInternalError(w, err)
 		return
 	}
+	if err := json.NewEncoder(w).Encode(planDTO); err != nil {
+		rest.WriteInternalError(w, err)
+		return
+	}
+}
+
+// queryWeekDate parses the date query parameter, any day of the week in RFC3339 format.
+func queryWeekDate(r *http.Request) (time.Time, error) {
+	weekDate, err := time.Parse(time.RFC3339, r.URL.Query().Get("date"))
+	if err != nil {
+		return time.Time{}, rest.InvalidField("date", "Incorrect date format", "Date must be in RFC3339 format")
+	}
+	return weekDate, nil
+}
+
+func parseWeekDate(value string) (time.Time, error) {
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+func weekPreviewToDTO(preview WeekPreview) WeekPreviewDTO {
+	items := make([]PreviewItemDTO, 0, len(preview.Items))
+	for _, item := range preview.Items {
+		items = append(items, PreviewItemDTO{
+			WeeklyPlanItemDTO:      WeeklyPlanItemToDTO(item.Item),
+			Change:                 item.Change,
+			PreviousWeeklyDuration: int(item.PreviousWeeklyDuration.Seconds()),
+		})
+	}
+	removed := make([]WeeklyPlanItemDTO, 0, len(preview.RemovedItems))
+	for _, item := range preview.RemovedItems {
+		removed = append(removed, WeeklyPlanItemToDTO(item))
+	}
+	return WeekPreviewDTO{
+		WeekNumber:   preview.WeekNumber.String(),
+		BudgetPlanId: preview.BudgetPlanId,
+		IsGenerated:  preview.IsGenerated,
+		Items:        items,
+		RemovedItems: removed,
+	}
+}
+
+func (h *Handler) weeklyPlanToDTO(ctx context.Context, plan WeeklyPlan) (WeeklyPlanDTO, error) {
+	categoryTotals, err := h.service.CategoryTotals(ctx, plan)
+	if err != nil {
+		return WeeklyPlanDTO{}, err
+	}
 
 	itemsDTO := make([]WeeklyPlanItemDTO, 0, len(plan.Items))
 	for _, item := range plan.Items {
 		itemsDTO = append(itemsDTO, WeeklyPlanItemToDTO(item))
 	}
-	if err := json.NewEncoder(w).Encode(WeeklyPlanDTO{plan.BudgetPlanId, plan.IsOffWeek, itemsDTO}); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	var categoriesDTO []CategoryTotalDTO
+	for _, total := range categoryTotals {
+		categoriesDTO = append(categoriesDTO, CategoryTotalDTO{
+			CategoryId:     total.CategoryId,
+			Name:           total.Name,
+			Color:          total.Color,
+			WeeklyDuration: int(total.WeeklyDuration.Seconds()),
+			BudgetItemIds:  total.BudgetItemIds,
+		})
+	}
+	var absentDays []string
+	for _, weekday := range plan.AbsentDays {
+		absentDays = append(absentDays, strings.ToLower(weekday.String()))
 	}
+	return WeeklyPlanDTO{
+		BudgetPlanId: plan.BudgetPlanId,
+		IsOffWeek:    plan.IsOffWeek,
+		LockedAt:     plan.LockedAt,
+		AbsentDays:   absentDays,
+		Items:        itemsDTO,
+		Categories:   categoriesDTO,
+	}, nil
 }
 
 func WeeklyPlanItemToDTO(item WeeklyPlanItem) WeeklyPlanItemDTO {
+	var carriedOver *int
+	if item.CarriedOver != nil {
+		seconds := int(item.CarriedOver.Seconds())
+		carriedOver = &seconds
+	}
+	// The durations are those planned, they are reduced for the absences only in effectiveWeeklyDuration
+	planned := item
+	if item.Unreduced != nil {
+		planned = *item.Unreduced
+	}
 	return WeeklyPlanItemDTO{
-		Id:                item.Id,
-		BudgetItemId:      item.BudgetItemId,
-		Name:              item.Name,
-		WeeklyDuration:    int(item.WeeklyDuration.Seconds()),
-		WeeklyOccurrences: item.WeeklyOccurrences,
-		Icon:              item.Icon,
-		Color:             item.Color,
-		Notes:             item.Notes,
-		Position:          item.Position,
+		Id:                       item.Id,
+		BudgetItemId:             item.BudgetItemId,
+		Name:                     item.Name,
+		WeeklyDuration:           int(planned.WeeklyDuration.Seconds()),
+		WeeklyOccurrences:        planned.WeeklyOccurrences,
+		DailyDurations:           budget_plan.DailyDurationsToDTO(planned.DailyDurations),
+		Icon:                     item.Icon,
+		Color:                    item.Color,
+		Notes:                    item.Notes,
+		Position:                 item.Position,
+		CarriedOver:              carriedOver,
+		AdHoc:                    item.IsAdHoc(),
+		DailyDurationsCustomized: item.DailyDurationsCustomized,
+		EffectiveWeeklyDuration:  int(item.WeeklyDuration.Seconds()),
+		AbsenceReduction:         int(item.AbsenceReduction.Seconds()),
 	}
 }
@@ -8,85 +8,90 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
-	log "github.com/sirupsen/logrus"
+	"github.com/klokku/klokku/internal/database/dbtx"
+	"github.com/klokku/klokku/pkg/budget_plan"
 )
 
 var ErrWeeklyPlanItemNotFound = errors.New("weekly plan item not found")
 
 type Repository interface {
-	WithTransaction(ctx context.Context, fn func(repo Repository) error) error
+	WithTransaction(ctx context.Context, fn func(ctx context.Context, repo Repository) error) error
 	GetItemsForWeek(ctx context.Context, userId int, weekNumber WeekNumber) ([]WeeklyPlanItem, error)
+	// GetItemsForWeeks returns the stored items of the given weeks in one query, weeks without items are left out.
+	GetItemsForWeeks(ctx context.Context, userId int, weekNumbers []WeekNumber) (map[WeekNumber][]WeeklyPlanItem, error)
 	GetItem(ctx context.Context, userId int, id int) (WeeklyPlanItem, error)
-	// UpdateAllItemsByBudgetItemId updates name, icon, and color of all weekly plan items for a given budget item.
-	UpdateAllItemsByBudgetItemId(ctx context.Context, userId int, budgetItemId int, name string, icon string, color string) (int, error)
+	// UpdateAllItemsByBudgetItemId updates name, icon, color and daily durations of weekly plan items for a given budget item,
+	// in fromWeek and later weeks. A zero fromWeek updates items of all weeks. Customized daily durations are kept and
+	// items of locked weeks are left as they are.
+	UpdateAllItemsByBudgetItemId(
+		ctx context.Context,
+		userId int,
+		budgetItemId int,
+		fromWeek WeekNumber,
+		name string,
+		icon string,
+		color string,
+		dailyDurations map[time.Weekday]time.Duration,
+	) (int, error)
+	// UpdateItem sets the weekly duration and notes of the item. Customized daily durations no longer summing up to
+	// a changed weekly duration are dropped, as are those of SetCarriedOver.
 	UpdateItem(ctx context.Context, userId int, id int, weeklyDuration time.Duration, notes string) (WeeklyPlanItem, error)
+	// SetCarriedOver sets the weekly duration of the item together with the time carried over from the previous week.
+	SetCarriedOver(ctx context.Context, userId int, id int, weeklyDuration time.Duration, carriedOver time.Duration) (WeeklyPlanItem, error)
+	// UpdateItemDailyDurations sets the daily durations of the item and whether they were customized for the week.
+	UpdateItemDailyDurations(
+		ctx context.Context,
+		userId int,
+		id int,
+		dailyDurations map[time.Weekday]time.Duration,
+		customized bool,
+	) (WeeklyPlanItem, error)
+	// UpdateItemPosition sets the position of the item within its week.
+	UpdateItemPosition(ctx context.Context, userId int, id int, position int) (WeeklyPlanItem, error)
 	createItems(ctx context.Context, userId int, items []WeeklyPlanItem) ([]WeeklyPlanItem, error)
+	// NextAdHocBudgetItemId returns a new negative budget item id for an ad-hoc item.
+	NextAdHocBudgetItemId(ctx context.Context) (int, error)
+	DeleteItem(ctx context.Context, userId int, id int) (bool, error)
 	// DeleteWeekItems deletes all weekly plan items for a given week.
 	DeleteWeekItems(ctx context.Context, userId int, weekNumber WeekNumber) (int, error)
+	// DeleteItemsByBudgetItemId deletes weekly plan items of a given budget item in fromWeek and later weeks.
+	DeleteItemsByBudgetItemId(ctx context.Context, userId int, budgetItemId int, fromWeek WeekNumber) (int, error)
 	// GetWeeklyPlan returns the weekly_plan record for the given week, or nil if none exists.
 	GetWeeklyPlan(ctx context.Context, userId int, weekNumber WeekNumber) (*WeeklyPlan, error)
+	// GetWeeklyPlans returns the weekly_plan records of the given weeks, weeks without a record are left out.
+	GetWeeklyPlans(ctx context.Context, userId int, weekNumbers []WeekNumber) (map[WeekNumber]WeeklyPlan, error)
 	// CreateWeeklyPlan inserts a new weekly_plan record with is_off_week=false.
 	CreateWeeklyPlan(ctx context.Context, userId int, budgetPlanId int, weekNumber WeekNumber) (WeeklyPlan, error)
 	// SetOffWeek upserts the weekly_plan record and sets is_off_week.
 	SetOffWeek(ctx context.Context, userId int, budgetPlanId int, weekNumber WeekNumber, isOffWeek bool) (WeeklyPlan, error)
+	// SetLocked upserts the weekly_plan record and sets the time it was locked at, nil unlocks the week.
+	SetLocked(ctx context.Context, userId int, budgetPlanId int, weekNumber WeekNumber, lockedAt *time.Time) (WeeklyPlan, error)
 	// DeleteWeeklyPlan deletes the weekly_plan record for the given week (no-op if not found).
 	DeleteWeeklyPlan(ctx context.Context, userId int, weekNumber WeekNumber) error
 }
 
 type repositoryImpl struct {
 	db *pgxpool.Pool
-	tx pgx.Tx
 }
 
 func NewRepo(db *pgxpool.Pool) Repository {
 	return &repositoryImpl{db: db}
 }
 
-// getQueryer returns the appropriate database interface for queries (either tx or db)
-func (r *repositoryImpl) getQueryer() interface {
-	Exec(ctx context.Context, query string, args ...interface{}) (pgconn.CommandTag, error)
-	Query(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error)
-	QueryRow(ctx context.Context, query string, args ...interface{}) pgx.Row
-} {
-	if r.tx != nil {
-		return r.tx
-	}
-	return r.db
+// conn returns the transaction of ctx, if any, or the pool
+func (r *repositoryImpl) conn(ctx context.Context) dbtx.Querier {
+	return dbtx.From(ctx, r.db)
 }
 
-func (r *repositoryImpl) WithTransaction(ctx context.Context, fn func(repo Repository) error) error {
-	tx, err := r.db.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
-	}
-	defer func() {
-		// The Rollback will be a no-op if the transaction was already committed
-		if rbErr := tx.Rollback(ctx); rbErr != nil && !errors.Is(rbErr, pgx.ErrTxClosed) {
-			// Just log rollback errors
-			log.Errorf("rollback error: %v", rbErr)
-		}
-	}()
-
-	// Create a repository that uses the transaction
-	txRepo := &repositoryImpl{db: r.db, tx: tx}
-
-	if err := fn(txRepo); err != nil {
-		return err
-	}
-
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("commit transaction: %w", err)
-	}
-
-	return nil
+func (r *repositoryImpl) WithTransaction(ctx context.Context, fn func(ctx context.Context, repo Repository) error) error {
+	return dbtx.InTx(ctx, r.db, func(ctx context.Context) error {
+		return fn(ctx, r)
+	})
 }
 
-func (r *repositoryImpl) GetItemsForWeek(ctx context.Context, userId int, weekNumber WeekNumber) ([]WeeklyPlanItem, error) {
-
-	query := `SELECT 
-    			item.id,
+// itemColumns are the columns selected and returned by item queries, scanned by scanItem.
+const itemColumns = `item.id,
     			item.budget_item_id,
     			item.budget_plan_id,
     			item.week_number,
@@ -96,82 +101,89 @@ func (r *repositoryImpl) GetItemsForWeek(ctx context.Context, userId int, weekNu
     			item.icon,
     			item.color,
     			item.notes,
-    			item.position
+    			item.daily_durations_sec,
+    			item.position,
+    			item.carried_over_sec,
+    			item.daily_durations_customized`
+
+func (r *repositoryImpl) GetItemsForWeek(ctx context.Context, userId int, weekNumber WeekNumber) ([]WeeklyPlanItem, error) {
+
+	query := `SELECT ` + itemColumns + `
 			  FROM weekly_plan_item item 
 			  WHERE user_id = $1 AND week_number = $2 
 			  ORDER BY item.position`
-	rows, err := r.getQueryer().Query(ctx, query, userId, weekNumber.String())
+	rows, err := r.conn(ctx).Query(ctx, query, userId, weekNumber.String())
+	if err != nil {
+		return nil, err
+	}
+	return scanItems(rows)
+}
+
+func (r *repositoryImpl) GetItemsForWeeks(ctx context.Context, userId int, weekNumbers []WeekNumber) (map[WeekNumber][]WeeklyPlanItem, error) {
+	itemsByWeek := make(map[WeekNumber][]WeeklyPlanItem, len(weekNumbers))
+	if len(weekNumbers) == 0 {
+		return itemsByWeek, nil
+	}
+	placeholders, args := weekNumbersIn(userId, weekNumbers)
+	query := `SELECT ` + itemColumns + `
+			  FROM weekly_plan_item item
+			  WHERE user_id = $1 AND week_number IN (` + placeholders + `)
+			  ORDER BY item.week_number, item.position`
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	items, err := scanItems(rows)
 	if err != nil {
 		return nil, err
 	}
+	for _, item := range items {
+		itemsByWeek[item.WeekNumber] = append(itemsByWeek[item.WeekNumber], item)
+	}
+	return itemsByWeek, nil
+}
+
+// weekNumbersIn returns placeholders for the week numbers in an IN clause, with the user id as the first argument.
+func weekNumbersIn(userId int, weekNumbers []WeekNumber) (string, []any) {
+	var placeholders strings.Builder
+	args := make([]any, 0, len(weekNumbers)+1)
+	args = append(args, userId)
+	for i, weekNumber := range weekNumbers {
+		if i > 0 {
+			placeholders.WriteByte(',')
+		}
+		fmt.Fprintf(&placeholders, "$%d", i+2)
+		args = append(args, weekNumber.String())
+	}
+	return placeholders.String(), args
+}
+
+// scanItems reads and closes rows selecting itemColumns.
+func scanItems(rows pgx.Rows) ([]WeeklyPlanItem, error) {
 	defer rows.Close()
 
 	items := make([]WeeklyPlanItem, 0, 10)
-
 	for rows.Next() {
-		var itemWeekNumberString string
-		var weeklyDurationSec int
-		var item WeeklyPlanItem
-		if err := rows.Scan(
-			&item.Id,
-			&item.BudgetItemId,
-			&item.BudgetPlanId,
-			&itemWeekNumberString,
-			&item.Name,
-			&weeklyDurationSec,
-			&item.WeeklyOccurrences,
-			&item.Icon,
-			&item.Color,
-			&item.Notes,
-			&item.Position,
-		); err != nil {
-			return nil, err
-		}
-		item.WeeklyDuration = time.Duration(weeklyDurationSec) * time.Second
-		item.WeekNumber, err = WeekNumberFromString(itemWeekNumberString)
+		item, err := scanItem(rows)
 		if err != nil {
-			return nil, fmt.Errorf("could not parse week number: %w", err)
+			return nil, err
 		}
 		items = append(items, item)
 	}
-	return items, nil
-}
-
-func (r *repositoryImpl) UpdateAllItemsByBudgetItemId(
-	ctx context.Context,
-	userId int,
-	budgetItemId int,
-	name string,
-	icon string,
-	color string,
-) (int, error) {
-	query := `UPDATE weekly_plan_item SET name = $1, icon = $2, color = $3 WHERE user_id = $4 AND budget_item_id = $5`
-	result, err := r.getQueryer().Exec(ctx, query, name, icon, color, userId, budgetItemId)
-	if err != nil {
-		return 0, err
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
-	rowsAffected := result.RowsAffected()
-	return int(rowsAffected), nil
+	return items, nil
 }
 
-func (r *repositoryImpl) GetItem(ctx context.Context, userId int, id int) (WeeklyPlanItem, error) {
-	query := `SELECT
-    			item.id,
-    			item.budget_item_id,
-    			item.budget_plan_id,
-    			item.week_number,
-    			item.name,
-    			item.weekly_duration_sec,
-    			item.weekly_occurrences,
-    			item.icon,
-    			item.color,
-    			item.notes,
-    			item.position
- 			  FROM weekly_plan_item item WHERE item.user_id = $1 AND item.id = $2`
+// scanItem scans a row selecting itemColumns.
+func scanItem(row pgx.Row) (WeeklyPlanItem, error) {
 	var itemWeekNumberString string
 	var weeklyDurationSec int
+	var dailyDurationsSec []int32
+	var carriedOverSec *int
 	var item WeeklyPlanItem
-	err := r.getQueryer().QueryRow(ctx, query, userId, id).Scan(
+	if err := row.Scan(
 		&item.Id,
 		&item.BudgetItemId,
 		&item.BudgetPlanId,
@@ -182,12 +194,17 @@ func (r *repositoryImpl) GetItem(ctx context.Context, userId int, id int) (Weekl
 		&item.Icon,
 		&item.Color,
 		&item.Notes,
+		&dailyDurationsSec,
 		&item.Position,
-	)
-	if err != nil {
+		&carriedOverSec,
+		&item.DailyDurationsCustomized,
+	); err != nil {
 		return WeeklyPlanItem{}, err
 	}
 	item.WeeklyDuration = time.Duration(weeklyDurationSec) * time.Second
+	item.CarriedOver = durationFromSeconds(carriedOverSec)
+	item.DailyDurations = budget_plan.DailyDurationsFromSeconds(dailyDurationsSec)
+	var err error
 	item.WeekNumber, err = WeekNumberFromString(itemWeekNumberString)
 	if err != nil {
 		return WeeklyPlanItem{}, fmt.Errorf("could not parse week number: %w", err)
@@ -195,47 +212,90 @@ func (r *repositoryImpl) GetItem(ctx context.Context, userId int, id int) (Weekl
 	return item, nil
 }
 
+func (r *repositoryImpl) UpdateAllItemsByBudgetItemId(
+	ctx context.Context,
+	userId int,
+	budgetItemId int,
+	fromWeek WeekNumber,
+	name string,
+	icon string,
+	color string,
+	dailyDurations map[time.Weekday]time.Duration,
+) (int, error) {
+	// week_number is a zero-padded ISO week, so it can be compared as text
+	query := `UPDATE weekly_plan_item SET name = $1, icon = $2, color = $3,
+              daily_durations_sec = CASE WHEN daily_durations_customized THEN daily_durations_sec ELSE $4 END
+              WHERE user_id = $5 AND budget_item_id = $6 AND week_number >= $7
+              AND NOT EXISTS (SELECT 1 FROM weekly_plan wp WHERE wp.user_id = weekly_plan_item.user_id
+                              AND wp.week_number = weekly_plan_item.week_number AND wp.locked_at IS NOT NULL)`
+	result, err := r.conn(ctx).Exec(ctx, query, name, icon, color, budget_plan.DailyDurationsToSeconds(dailyDurations), userId, budgetItemId,
+		fromWeek.String())
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected := result.RowsAffected()
+	return int(rowsAffected), nil
+}
+
+func (r *repositoryImpl) GetItem(ctx context.Context, userId int, id int) (WeeklyPlanItem, error) {
+	query := `SELECT ` + itemColumns + `
+ 			  FROM weekly_plan_item item WHERE item.user_id = $1 AND item.id = $2`
+	return scanItem(r.conn(ctx).QueryRow(ctx, query, userId, id))
+}
+
 func (r *repositoryImpl) UpdateItem(ctx context.Context, userId int, id int, weeklyDuration time.Duration, notes string) (WeeklyPlanItem, error) {
 	query := `UPDATE weekly_plan_item item
-	 			SET weekly_duration_sec = $1, notes = $2
+	 			SET weekly_duration_sec = $1, notes = $2,
+	 			    daily_durations_sec = CASE WHEN daily_durations_customized AND weekly_duration_sec <> $1
+	 			        THEN NULL ELSE daily_durations_sec END,
+	 			    daily_durations_customized = daily_durations_customized AND weekly_duration_sec = $1
      			WHERE item.user_id = $3 AND item.id = $4
-     			RETURNING
-     			     item.id,
-    					 item.budget_item_id,
-     			         item.budget_plan_id,
-    					 item.week_number,
-    					 item.name,
-    					 item.weekly_duration_sec,
-    					 item.weekly_occurrences,
-    					 item.icon,
-    					 item.color,
-    					 item.notes `
-	var itemWeekNumberString string
-	var weeklyDurationSec int
-	var item WeeklyPlanItem
-	err := r.getQueryer().QueryRow(ctx, query, weeklyDuration.Seconds(), notes, userId, id).Scan(
-		&item.Id,
-		&item.BudgetItemId,
-		&item.BudgetPlanId,
-		&itemWeekNumberString,
-		&item.Name,
-		&weeklyDurationSec,
-		&item.WeeklyOccurrences,
-		&item.Icon,
-		&item.Color,
-		&item.Notes,
-	)
+     			RETURNING ` + itemColumns
+	return r.updateItem(ctx, query, weeklyDuration.Seconds(), notes, userId, id)
+}
+
+func (r *repositoryImpl) SetCarriedOver(ctx context.Context, userId int, id int, weeklyDuration time.Duration, carriedOver time.Duration) (WeeklyPlanItem, error) {
+	query := `UPDATE weekly_plan_item item
+	 			SET weekly_duration_sec = $1, carried_over_sec = $2,
+	 			    daily_durations_sec = CASE WHEN daily_durations_customized AND weekly_duration_sec <> $1
+	 			        THEN NULL ELSE daily_durations_sec END,
+	 			    daily_durations_customized = daily_durations_customized AND weekly_duration_sec = $1
+     			WHERE item.user_id = $3 AND item.id = $4
+     			RETURNING ` + itemColumns
+	return r.updateItem(ctx, query, weeklyDuration.Seconds(), int(carriedOver.Seconds()), userId, id)
+}
+
+func (r *repositoryImpl) UpdateItemDailyDurations(
+	ctx context.Context,
+	userId int,
+	id int,
+	dailyDurations map[time.Weekday]time.Duration,
+	customized bool,
+) (WeeklyPlanItem, error) {
+	query := `UPDATE weekly_plan_item item
+	 			SET daily_durations_sec = $1, daily_durations_customized = $2
+     			WHERE item.user_id = $3 AND item.id = $4
+     			RETURNING ` + itemColumns
+	return r.updateItem(ctx, query, budget_plan.DailyDurationsToSeconds(dailyDurations), customized, userId, id)
+}
+
+func (r *repositoryImpl) UpdateItemPosition(ctx context.Context, userId int, id int, position int) (WeeklyPlanItem, error) {
+	query := `UPDATE weekly_plan_item item
+	 			SET position = $1
+     			WHERE item.user_id = $2 AND item.id = $3
+     			RETURNING ` + itemColumns
+	return r.updateItem(ctx, query, position, userId, id)
+}
+
+// updateItem runs an UPDATE query returning itemColumns and scans the updated item.
+func (r *repositoryImpl) updateItem(ctx context.Context, query string, args ...any) (WeeklyPlanItem, error) {
+	item, err := scanItem(r.conn(ctx).QueryRow(ctx, query, args...))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return WeeklyPlanItem{}, ErrWeeklyPlanItemNotFound
 		}
 		return WeeklyPlanItem{}, fmt.Errorf("could not update item: %w", err)
 	}
-	item.WeeklyDuration = time.Duration(weeklyDurationSec) * time.Second
-	item.WeekNumber, err = WeekNumberFromString(itemWeekNumberString)
-	if err != nil {
-		return WeeklyPlanItem{}, fmt.Errorf("could not parse week number: %w", err)
-	}
 	return item, nil
 }
 
@@ -245,14 +305,14 @@ func (r *repositoryImpl) createItems(ctx context.Context, userId int, items []We
 	}
 
 	var valuesBuilder strings.Builder
-	args := make([]any, 0, len(items)*10)
+	args := make([]any, 0, len(items)*12)
 	placeholder := 1
 	for idx, item := range items {
 		if idx > 0 {
 			valuesBuilder.WriteByte(',')
 		}
 		valuesBuilder.WriteString("(")
-		for i := 0; i < 11; i++ {
+		for i := 0; i < 12; i++ {
 			if i > 0 {
 				valuesBuilder.WriteByte(',')
 			}
@@ -272,6 +332,7 @@ func (r *repositoryImpl) createItems(ctx context.Context, userId int, items []We
 			item.Icon,
 			item.Color,
 			item.Notes,
+			budget_plan.DailyDurationsToSeconds(item.DailyDurations),
 			item.Position,
 		)
 	}
@@ -287,6 +348,7 @@ func (r *repositoryImpl) createItems(ctx context.Context, userId int, items []We
                             icon,
                             color,
                             notes,
+                            daily_durations_sec,
                             position
                   ) VALUES %s RETURNING 
                             id,
@@ -299,9 +361,10 @@ func (r *repositoryImpl) createItems(ctx context.Context, userId int, items []We
                             icon,
                             color,
                             notes,
+                            daily_durations_sec,
                             position`, valuesBuilder.String())
 
-	rows, err := r.getQueryer().Query(ctx, query, args...)
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -311,6 +374,7 @@ func (r *repositoryImpl) createItems(ctx context.Context, userId int, items []We
 	for rows.Next() {
 		var weekNumberString string
 		var weeklyDurationSec int
+		var dailyDurationsSec []int32
 		var item WeeklyPlanItem
 		err := rows.Scan(
 			&item.Id,
@@ -323,12 +387,14 @@ func (r *repositoryImpl) createItems(ctx context.Context, userId int, items []We
 			&item.Icon,
 			&item.Color,
 			&item.Notes,
+			&dailyDurationsSec,
 			&item.Position,
 		)
 		if err != nil {
 			return nil, err
 		}
 		item.WeeklyDuration = time.Duration(weeklyDurationSec) * time.Second
+		item.DailyDurations = budget_plan.DailyDurationsFromSeconds(dailyDurationsSec)
 		item.WeekNumber, err = WeekNumberFromString(weekNumberString)
 		if err != nil {
 			return nil, fmt.Errorf("could not parse week number: %w", err)
@@ -342,9 +408,36 @@ func (r *repositoryImpl) createItems(ctx context.Context, userId int, items []We
 	return created, nil
 }
 
+func (r *repositoryImpl) NextAdHocBudgetItemId(ctx context.Context) (int, error) {
+	var id int
+	err := r.conn(ctx).QueryRow(ctx, `SELECT nextval('weekly_plan_ad_hoc_item_seq')`).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("could not get ad-hoc item id: %w", err)
+	}
+	return id, nil
+}
+
+func (r *repositoryImpl) DeleteItem(ctx context.Context, userId int, id int) (bool, error) {
+	query := `DELETE FROM weekly_plan_item WHERE user_id = $1 AND id = $2`
+	result, err := r.conn(ctx).Exec(ctx, query, userId, id)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
 func (r *repositoryImpl) DeleteWeekItems(ctx context.Context, userId int, weekNumber WeekNumber) (int, error) {
 	query := `DELETE FROM weekly_plan_item WHERE user_id = $1 AND week_number = $2`
-	result, err := r.getQueryer().Exec(ctx, query, userId, weekNumber.String())
+	result, err := r.conn(ctx).Exec(ctx, query, userId, weekNumber.String())
+	if err != nil {
+		return 0, err
+	}
+	return int(result.RowsAffected()), nil
+}
+
+func (r *repositoryImpl) DeleteItemsByBudgetItemId(ctx context.Context, userId int, budgetItemId int, fromWeek WeekNumber) (int, error) {
+	query := `DELETE FROM weekly_plan_item WHERE user_id = $1 AND budget_item_id = $2 AND week_number >= $3`
+	result, err := r.conn(ctx).Exec(ctx, query, userId, budgetItemId, fromWeek.String())
 	if err != nil {
 		return 0, err
 	}
@@ -352,49 +445,27 @@ func (r *repositoryImpl) DeleteWeekItems(ctx context.Context, userId int, weekNu
 }
 
 func (r *repositoryImpl) GetWeeklyPlan(ctx context.Context, userId int, weekNumber WeekNumber) (*WeeklyPlan, error) {
-	query := `SELECT id, budget_plan_id, week_number, is_off_week
+	query := `SELECT ` + weeklyPlanColumns + `
 	          FROM weekly_plan
 	          WHERE user_id = $1 AND week_number = $2`
-	var wp WeeklyPlan
-	var weekNumberString string
-	err := r.getQueryer().QueryRow(ctx, query, userId, weekNumber.String()).Scan(
-		&wp.Id,
-		&wp.BudgetPlanId,
-		&weekNumberString,
-		&wp.IsOffWeek,
-	)
+	wp, err := scanWeeklyPlan(r.conn(ctx).QueryRow(ctx, query, userId, weekNumber.String()))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("could not get weekly plan: %w", err)
 	}
-	wp.WeekNumber, err = WeekNumberFromString(weekNumberString)
-	if err != nil {
-		return nil, fmt.Errorf("could not parse week number: %w", err)
-	}
 	return &wp, nil
 }
 
 func (r *repositoryImpl) CreateWeeklyPlan(ctx context.Context, userId int, budgetPlanId int, weekNumber WeekNumber) (WeeklyPlan, error) {
 	query := `INSERT INTO weekly_plan (user_id, budget_plan_id, week_number, is_off_week)
 	          VALUES ($1, $2, $3, FALSE)
-	          RETURNING id, budget_plan_id, week_number, is_off_week`
-	var wp WeeklyPlan
-	var weekNumberString string
-	err := r.getQueryer().QueryRow(ctx, query, userId, budgetPlanId, weekNumber.String()).Scan(
-		&wp.Id,
-		&wp.BudgetPlanId,
-		&weekNumberString,
-		&wp.IsOffWeek,
-	)
+	          RETURNING ` + weeklyPlanColumns
+	wp, err := scanWeeklyPlan(r.conn(ctx).QueryRow(ctx, query, userId, budgetPlanId, weekNumber.String()))
 	if err != nil {
 		return WeeklyPlan{}, fmt.Errorf("could not create weekly plan: %w", err)
 	}
-	wp.WeekNumber, err = WeekNumberFromString(weekNumberString)
-	if err != nil {
-		return WeeklyPlan{}, fmt.Errorf("could not parse week number: %w", err)
-	}
 	return wp, nil
 }
 
@@ -402,17 +473,67 @@ func (r *repositoryImpl) SetOffWeek(ctx context.Context, userId int, budgetPlanI
 	query := `INSERT INTO weekly_plan (user_id, budget_plan_id, week_number, is_off_week)
 	          VALUES ($1, $2, $3, $4)
 	          ON CONFLICT (user_id, week_number) DO UPDATE SET is_off_week = EXCLUDED.is_off_week
-	          RETURNING id, budget_plan_id, week_number, is_off_week`
+	          RETURNING ` + weeklyPlanColumns
+	wp, err := scanWeeklyPlan(r.conn(ctx).QueryRow(ctx, query, userId, budgetPlanId, weekNumber.String(), isOffWeek))
+	if err != nil {
+		return WeeklyPlan{}, fmt.Errorf("could not set off week: %w", err)
+	}
+	return wp, nil
+}
+
+func (r *repositoryImpl) SetLocked(ctx context.Context, userId int, budgetPlanId int, weekNumber WeekNumber, lockedAt *time.Time) (WeeklyPlan, error) {
+	query := `INSERT INTO weekly_plan (user_id, budget_plan_id, week_number, is_off_week, locked_at)
+	          VALUES ($1, $2, $3, FALSE, $4)
+	          ON CONFLICT (user_id, week_number) DO UPDATE SET locked_at = EXCLUDED.locked_at
+	          RETURNING ` + weeklyPlanColumns
+	wp, err := scanWeeklyPlan(r.conn(ctx).QueryRow(ctx, query, userId, budgetPlanId, weekNumber.String(), lockedAt))
+	if err != nil {
+		return WeeklyPlan{}, fmt.Errorf("could not set week lock: %w", err)
+	}
+	return wp, nil
+}
+
+func (r *repositoryImpl) GetWeeklyPlans(ctx context.Context, userId int, weekNumbers []WeekNumber) (map[WeekNumber]WeeklyPlan, error) {
+	plans := make(map[WeekNumber]WeeklyPlan, len(weekNumbers))
+	if len(weekNumbers) == 0 {
+		return plans, nil
+	}
+	placeholders, args := weekNumbersIn(userId, weekNumbers)
+	query := `SELECT ` + weeklyPlanColumns + `
+	          FROM weekly_plan
+	          WHERE user_id = $1 AND week_number IN (` + placeholders + `)`
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not get weekly plans: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		wp, err := scanWeeklyPlan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not get weekly plans: %w", err)
+		}
+		plans[wp.WeekNumber] = wp
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get weekly plans: %w", err)
+	}
+	return plans, nil
+}
+
+const weeklyPlanColumns = `id, budget_plan_id, week_number, is_off_week, locked_at`
+
+func scanWeeklyPlan(row pgx.Row) (WeeklyPlan, error) {
 	var wp WeeklyPlan
 	var weekNumberString string
-	err := r.getQueryer().QueryRow(ctx, query, userId, budgetPlanId, weekNumber.String(), isOffWeek).Scan(
+	err := row.Scan(
 		&wp.Id,
 		&wp.BudgetPlanId,
 		&weekNumberString,
 		&wp.IsOffWeek,
+		&wp.LockedAt,
 	)
 	if err != nil {
-		return WeeklyPlan{}, fmt.Errorf("could not set off week: %w", err)
+		return WeeklyPlan{}, err
 	}
 	wp.WeekNumber, err = WeekNumberFromString(weekNumberString)
 	if err != nil {
@@ -423,6 +544,15 @@ func (r *repositoryImpl) SetOffWeek(ctx context.Context, userId int, budgetPlanI
 
 func (r *repositoryImpl) DeleteWeeklyPlan(ctx context.Context, userId int, weekNumber WeekNumber) error {
 	query := `DELETE FROM weekly_plan WHERE user_id = $1 AND week_number = $2`
-	_, err := r.getQueryer().Exec(ctx, query, userId, weekNumber.String())
+	_, err := r.conn(ctx).Exec(ctx, query, userId, weekNumber.String())
 	return err
 }
+
+// durationFromSeconds maps a nullable seconds column to an optional duration.
+func durationFromSeconds(seconds *int) *time.Duration {
+	if seconds == nil {
+		return nil
+	}
+	d := time.Duration(*seconds) * time.Second
+	return &d
+}
@@ -12,6 +12,7 @@ type RepositoryStub struct {
 	items          map[int]WeeklyPlanItem // id -> item
 	userIds        map[int]int            // id -> userId
 	nextId         int
+	nextAdHocId    int
 	weeklyPlans    map[string]WeeklyPlan // "userId:weekNumber" -> plan
 	nextPlanId     int
 	inTransaction  bool
@@ -23,6 +24,7 @@ func NewRepositoryStub() *RepositoryStub {
 		items:       make(map[int]WeeklyPlanItem),
 		userIds:     make(map[int]int),
 		nextId:      1,
+		nextAdHocId: -1,
 		weeklyPlans: make(map[string]WeeklyPlan),
 		nextPlanId:  1,
 	}
@@ -32,7 +34,7 @@ func weeklyPlanKey(userId int, weekNumber WeekNumber) string {
 	return fmt.Sprintf("%d:%s", userId, weekNumber.String())
 }
 
-func (r *RepositoryStub) WithTransaction(ctx context.Context, fn func(repo Repository) error) error {
+func (r *RepositoryStub) WithTransaction(ctx context.Context, fn func(ctx context.Context, repo Repository) error) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -58,7 +60,7 @@ func (r *RepositoryStub) WithTransaction(ctx context.Context, fn func(repo Repos
 	r.mu.Unlock()
 
 	// Execute the function
-	err := fn(r)
+	err := fn(ctx, r)
 
 	r.mu.Lock()
 	r.inTransaction = false
@@ -102,6 +104,20 @@ func (r *RepositoryStub) GetItemsForWeek(ctx context.Context, userId int, weekNu
 	return result, nil
 }
 
+func (r *RepositoryStub) GetItemsForWeeks(ctx context.Context, userId int, weekNumbers []WeekNumber) (map[WeekNumber][]WeeklyPlanItem, error) {
+	result := make(map[WeekNumber][]WeeklyPlanItem, len(weekNumbers))
+	for _, weekNumber := range weekNumbers {
+		items, err := r.GetItemsForWeek(ctx, userId, weekNumber)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) > 0 {
+			result[weekNumber] = items
+		}
+	}
+	return result, nil
+}
+
 func (r *RepositoryStub) GetItem(ctx context.Context, userId int, id int) (WeeklyPlanItem, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -118,19 +134,27 @@ func (r *RepositoryStub) UpdateAllItemsByBudgetItemId(
 	ctx context.Context,
 	userId int,
 	budgetItemId int,
+	fromWeek WeekNumber,
 	name string,
 	icon string,
 	color string,
+	dailyDurations map[time.Weekday]time.Duration,
 ) (int, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	count := 0
 	for id, item := range r.items {
-		if r.userIds[id] == userId && item.BudgetItemId == budgetItemId {
+		if r.userIds[id] == userId && item.BudgetItemId == budgetItemId && !item.WeekNumber.Before(fromWeek) {
+			if wp, ok := r.weeklyPlans[weeklyPlanKey(userId, item.WeekNumber)]; ok && wp.IsLocked() {
+				continue
+			}
 			item.Name = name
 			item.Icon = icon
 			item.Color = color
+			if !item.DailyDurationsCustomized {
+				item.DailyDurations = dailyDurations
+			}
 			r.items[id] = item
 			count++
 		}
@@ -154,6 +178,7 @@ func (r *RepositoryStub) UpdateItem(
 		return WeeklyPlanItem{}, ErrWeeklyPlanItemNotFound
 	}
 
+	dropStaleDailyDurations(&item, weeklyDuration)
 	item.WeeklyDuration = weeklyDuration
 	item.Notes = notes
 	r.items[id] = item
@@ -161,6 +186,74 @@ func (r *RepositoryStub) UpdateItem(
 	return item, nil
 }
 
+func (r *RepositoryStub) SetCarriedOver(
+	ctx context.Context,
+	userId int,
+	id int,
+	weeklyDuration time.Duration,
+	carriedOver time.Duration,
+) (WeeklyPlanItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, exists := r.items[id]
+	if !exists || r.userIds[id] != userId {
+		return WeeklyPlanItem{}, ErrWeeklyPlanItemNotFound
+	}
+
+	dropStaleDailyDurations(&item, weeklyDuration)
+	item.WeeklyDuration = weeklyDuration
+	item.CarriedOver = &carriedOver
+	r.items[id] = item
+
+	return item, nil
+}
+
+// dropStaleDailyDurations mirrors the repository dropping customized daily durations when the weekly duration changes.
+func dropStaleDailyDurations(item *WeeklyPlanItem, weeklyDuration time.Duration) {
+	if item.DailyDurationsCustomized && item.WeeklyDuration != weeklyDuration {
+		item.DailyDurations = nil
+		item.DailyDurationsCustomized = false
+	}
+}
+
+func (r *RepositoryStub) UpdateItemDailyDurations(
+	ctx context.Context,
+	userId int,
+	id int,
+	dailyDurations map[time.Weekday]time.Duration,
+	customized bool,
+) (WeeklyPlanItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, exists := r.items[id]
+	if !exists || r.userIds[id] != userId {
+		return WeeklyPlanItem{}, ErrWeeklyPlanItemNotFound
+	}
+
+	item.DailyDurations = dailyDurations
+	item.DailyDurationsCustomized = customized
+	r.items[id] = item
+
+	return item, nil
+}
+
+func (r *RepositoryStub) UpdateItemPosition(ctx context.Context, userId int, id int, position int) (WeeklyPlanItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, exists := r.items[id]
+	if !exists || r.userIds[id] != userId {
+		return WeeklyPlanItem{}, ErrWeeklyPlanItemNotFound
+	}
+
+	item.Position = position
+	r.items[id] = item
+
+	return item, nil
+}
+
 func (r *RepositoryStub) createItems(ctx context.Context, userId int, items []WeeklyPlanItem) ([]WeeklyPlanItem, error) {
 	if len(items) == 0 {
 		return nil, nil
@@ -181,6 +274,27 @@ func (r *RepositoryStub) createItems(ctx context.Context, userId int, items []We
 	return created, nil
 }
 
+func (r *RepositoryStub) NextAdHocBudgetItemId(ctx context.Context) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := r.nextAdHocId
+	r.nextAdHocId--
+	return id, nil
+}
+
+func (r *RepositoryStub) DeleteItem(ctx context.Context, userId int, id int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.items[id]; !exists || r.userIds[id] != userId {
+		return false, nil
+	}
+	delete(r.items, id)
+	delete(r.userIds, id)
+	return true, nil
+}
+
 func (r *RepositoryStub) DeleteWeekItems(ctx context.Context, userId int, weekNumber WeekNumber) (int, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -197,6 +311,22 @@ func (r *RepositoryStub) DeleteWeekItems(ctx context.Context, userId int, weekNu
 	return count, nil
 }
 
+func (r *RepositoryStub) DeleteItemsByBudgetItemId(ctx context.Context, userId int, budgetItemId int, fromWeek WeekNumber) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := 0
+	for id, item := range r.items {
+		if r.userIds[id] == userId && item.BudgetItemId == budgetItemId && !item.WeekNumber.Before(fromWeek) {
+			delete(r.items, id)
+			delete(r.userIds, id)
+			count++
+		}
+	}
+
+	return count, nil
+}
+
 // Helper method to set transaction error (for testing transaction rollback)
 func (r *RepositoryStub) SetTransactionError(err error) {
 	r.mu.Lock()
@@ -227,6 +357,19 @@ func (r *RepositoryStub) GetWeeklyPlan(ctx context.Context, userId int, weekNumb
 	return nil, nil
 }
 
+func (r *RepositoryStub) GetWeeklyPlans(ctx context.Context, userId int, weekNumbers []WeekNumber) (map[WeekNumber]WeeklyPlan, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make(map[WeekNumber]WeeklyPlan, len(weekNumbers))
+	for _, weekNumber := range weekNumbers {
+		if plan, ok := r.weeklyPlans[weeklyPlanKey(userId, weekNumber)]; ok {
+			result[weekNumber] = plan
+		}
+	}
+	return result, nil
+}
+
 func (r *RepositoryStub) CreateWeeklyPlan(ctx context.Context, userId int, budgetPlanId int, weekNumber WeekNumber) (WeeklyPlan, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -262,6 +405,25 @@ func (r *RepositoryStub) SetOffWeek(ctx context.Context, userId int, budgetPlanI
 	return wp, nil
 }
 
+func (r *RepositoryStub) SetLocked(ctx context.Context, userId int, budgetPlanId int, weekNumber WeekNumber, lockedAt *time.Time) (WeeklyPlan, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := weeklyPlanKey(userId, weekNumber)
+	wp, ok := r.weeklyPlans[key]
+	if !ok {
+		wp = WeeklyPlan{
+			Id:           r.nextPlanId,
+			BudgetPlanId: budgetPlanId,
+			WeekNumber:   weekNumber,
+		}
+		r.nextPlanId++
+	}
+	wp.LockedAt = lockedAt
+	r.weeklyPlans[key] = wp
+	return wp, nil
+}
+
 func (r *RepositoryStub) DeleteWeeklyPlan(ctx context.Context, userId int, weekNumber WeekNumber) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -279,6 +441,7 @@ func (r *RepositoryStub) Reset() {
 	r.items = make(map[int]WeeklyPlanItem)
 	r.userIds = make(map[int]int)
 	r.nextId = 1
+	r.nextAdHocId = -1
 	r.weeklyPlans = make(map[string]WeeklyPlan)
 	r.nextPlanId = 1
 	r.inTransaction = false
@@ -228,6 +228,33 @@ func TestRepositoryImpl_GetItemsForWeek(t *testing.T) {
 	})
 }
 
+func TestRepositoryImpl_GetItemsForWeeks(t *testing.T) {
+	// given
+	ctx, repo, userId := setupTestRepository(t)
+	firstWeek := WeekNumber{Year: 2025, Week: 10}
+	secondWeek := WeekNumber{Year: 2025, Week: 11}
+	otherWeek := WeekNumber{Year: 2025, Week: 12}
+	_, err := repo.createItems(ctx, userId, []WeeklyPlanItem{
+		weeklyItem(WeeklyPlanItem{BudgetItemId: 1, WeekNumber: firstWeek, Position: 200}),
+		weeklyItem(WeeklyPlanItem{BudgetItemId: 2, WeekNumber: firstWeek, Position: 100}),
+		weeklyItem(WeeklyPlanItem{BudgetItemId: 1, WeekNumber: secondWeek, Position: 100}),
+		weeklyItem(WeeklyPlanItem{BudgetItemId: 1, WeekNumber: otherWeek, Position: 100}),
+	})
+	require.NoError(t, err)
+
+	// when
+	itemsByWeek, err := repo.GetItemsForWeeks(ctx, userId, []WeekNumber{firstWeek, secondWeek, {Year: 2025, Week: 20}})
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, itemsByWeek, 2)
+	require.Len(t, itemsByWeek[firstWeek], 2)
+	require.Equal(t, 2, itemsByWeek[firstWeek][0].BudgetItemId)
+	require.Equal(t, 1, itemsByWeek[firstWeek][1].BudgetItemId)
+	require.Len(t, itemsByWeek[secondWeek], 1)
+	require.Equal(t, secondWeek, itemsByWeek[secondWeek][0].WeekNumber)
+}
+
 func TestRepositoryImpl_UpdateAllItemsByBudgetItemId(t *testing.T) {
 	t.Run("should update name, icon, and color for all items with the same budget item id", func(t *testing.T) {
 		// given
@@ -262,7 +289,7 @@ func TestRepositoryImpl_UpdateAllItemsByBudgetItemId(t *testing.T) {
 		newName := "Updated Name"
 		newIcon := "updated-icon"
 		newColor := "updated-color"
-		updatedCount, err := repo.UpdateAllItemsByBudgetItemId(ctx, userId, budgetItemId, newName, newIcon, newColor)
+		updatedCount, err := repo.UpdateAllItemsByBudgetItemId(ctx, userId, budgetItemId, WeekNumber{}, newName, newIcon, newColor, nil)
 
 		// then
 		require.NoError(t, err)
@@ -291,7 +318,7 @@ func TestRepositoryImpl_UpdateAllItemsByBudgetItemId(t *testing.T) {
 		nonExistentBudgetItemId := 99999
 
 		// when
-		updatedCount, err := repo.UpdateAllItemsByBudgetItemId(ctx, userId, nonExistentBudgetItemId, "name", "icon", "color")
+		updatedCount, err := repo.UpdateAllItemsByBudgetItemId(ctx, userId, nonExistentBudgetItemId, WeekNumber{}, "name", "icon", "color", nil)
 
 		// then
 		require.NoError(t, err)
@@ -312,7 +339,7 @@ func TestRepositoryImpl_UpdateAllItemsByBudgetItemId(t *testing.T) {
 
 		// when - try to update with different user id
 		differentUserId := userId + 1
-		updatedCount, err := repo.UpdateAllItemsByBudgetItemId(ctx, differentUserId, budgetItemId, "new name", "new icon", "new color")
+		updatedCount, err := repo.UpdateAllItemsByBudgetItemId(ctx, differentUserId, budgetItemId, WeekNumber{}, "new name", "new icon", "new color", nil)
 
 		// then
 		require.NoError(t, err)
@@ -325,6 +352,33 @@ func TestRepositoryImpl_UpdateAllItemsByBudgetItemId(t *testing.T) {
 	})
 }
 
+func TestRepositoryImpl_DeleteItemsByBudgetItemId(t *testing.T) {
+	t.Run("should delete items of the budget item from the given week on", func(t *testing.T) {
+		// given
+		ctx, repo, userId := setupTestRepository(t)
+		budgetItemId := 42
+		createdItems, err := repo.createItems(ctx, userId, []WeeklyPlanItem{
+			weeklyItem(WeeklyPlanItem{BudgetItemId: budgetItemId, WeekNumber: WeekNumber{Year: 2025, Week: 2}}),
+			weeklyItem(WeeklyPlanItem{BudgetItemId: budgetItemId, WeekNumber: WeekNumber{Year: 2025, Week: 10}}),
+			weeklyItem(WeeklyPlanItem{BudgetItemId: 99, WeekNumber: WeekNumber{Year: 2025, Week: 10}}),
+		})
+		require.NoError(t, err)
+
+		// when
+		deletedCount, err := repo.DeleteItemsByBudgetItemId(ctx, userId, budgetItemId, WeekNumber{Year: 2025, Week: 5})
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, 1, deletedCount)
+		_, err = repo.GetItem(ctx, userId, createdItems[0].Id)
+		require.NoError(t, err)
+		_, err = repo.GetItem(ctx, userId, createdItems[1].Id)
+		require.ErrorIs(t, err, ErrWeeklyPlanItemNotFound)
+		_, err = repo.GetItem(ctx, userId, createdItems[2].Id)
+		require.NoError(t, err)
+	})
+}
+
 func TestRepositoryImpl_WithTransaction(t *testing.T) {
 	t.Run("should commit transaction on success", func(t *testing.T) {
 		// given
@@ -332,7 +386,7 @@ func TestRepositoryImpl_WithTransaction(t *testing.T) {
 		item := weeklyItem(WeeklyPlanItem{BudgetItemId: 1})
 
 		// when
-		err := repo.WithTransaction(ctx, func(txRepo Repository) error {
+		err := repo.WithTransaction(ctx, func(ctx context.Context, txRepo Repository) error {
 			_, err := txRepo.createItems(ctx, userId, []WeeklyPlanItem{item})
 			return err
 		})
@@ -352,7 +406,7 @@ func TestRepositoryImpl_WithTransaction(t *testing.T) {
 		item := weeklyItem(WeeklyPlanItem{BudgetItemId: 1})
 
 		// when
-		err := repo.WithTransaction(ctx, func(txRepo Repository) error {
+		err := repo.WithTransaction(ctx, func(ctx context.Context, txRepo Repository) error {
 			_, err := txRepo.createItems(ctx, userId, []WeeklyPlanItem{item})
 			if err != nil {
 				return err
@@ -381,7 +435,7 @@ func TestRepositoryImpl_WithTransaction(t *testing.T) {
 		var firstItemId int
 
 		// when
-		err := repo.WithTransaction(ctx, func(txRepo Repository) error {
+		err := repo.WithTransaction(ctx, func(ctx context.Context, txRepo Repository) error {
 			// Create items
 			created, err := txRepo.createItems(ctx, userId, []WeeklyPlanItem{item1, item2})
 			if err != nil {
@@ -412,6 +466,36 @@ func TestRepositoryImpl_WithTransaction(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, "Updated in transaction", updatedItem.Notes)
 	})
+
+	t.Run("should roll back only the nested transaction on error", func(t *testing.T) {
+		// given
+		ctx, repo, userId := setupTestRepository(t)
+		currentWeek := WeekNumberFromDate(time.Now(), time.Monday)
+		item1 := weeklyItem(WeeklyPlanItem{BudgetItemId: 1, WeekNumber: currentWeek})
+		item2 := weeklyItem(WeeklyPlanItem{BudgetItemId: 2, WeekNumber: currentWeek})
+
+		// when
+		err := repo.WithTransaction(ctx, func(ctx context.Context, txRepo Repository) error {
+			if _, err := txRepo.createItems(ctx, userId, []WeeklyPlanItem{item1}); err != nil {
+				return err
+			}
+			nestedErr := txRepo.WithTransaction(ctx, func(ctx context.Context, nestedRepo Repository) error {
+				if _, err := nestedRepo.createItems(ctx, userId, []WeeklyPlanItem{item2}); err != nil {
+					return err
+				}
+				return errors.New("intentional error to trigger rollback")
+			})
+			require.Error(t, nestedErr)
+			return nil
+		})
+
+		// then
+		require.NoError(t, err)
+		items, err := repo.GetItemsForWeek(ctx, userId, currentWeek)
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		require.Equal(t, 1, items[0].BudgetItemId)
+	})
 }
 
 func TestRepositoryImpl_UpdateItem(t *testing.T) {
@@ -558,3 +642,107 @@ func assertWeeklyPlanItemsEqual(t *testing.T, expected WeeklyPlanItem, actual We
 	require.Equal(t, expected.Notes, actual.Notes)
 	require.Equal(t, expected.Position, actual.Position)
 }
+
+func TestRepositoryImpl_SetCarriedOver(t *testing.T) {
+	// given
+	ctx, repo, userId := setupTestRepository(t)
+	createdItems, err := repo.createItems(ctx, userId, []WeeklyPlanItem{weeklyItem(WeeklyPlanItem{BudgetItemId: 1})})
+	require.NoError(t, err)
+	require.Nil(t, createdItems[0].CarriedOver)
+
+	// when
+	updated, err := repo.SetCarriedOver(ctx, userId, createdItems[0].Id, 2*time.Hour, -30*time.Minute)
+
+	// then
+	require.NoError(t, err)
+	require.Equal(t, 2*time.Hour, updated.WeeklyDuration)
+	require.NotNil(t, updated.CarriedOver)
+	require.Equal(t, -30*time.Minute, *updated.CarriedOver)
+	stored, err := repo.GetItem(ctx, userId, createdItems[0].Id)
+	require.NoError(t, err)
+	require.NotNil(t, stored.CarriedOver)
+	require.Equal(t, -30*time.Minute, *stored.CarriedOver)
+}
+
+func TestRepositoryImpl_UpdateItemDailyDurations(t *testing.T) {
+	// given
+	ctx, repo, userId := setupTestRepository(t)
+	createdItems, err := repo.createItems(ctx, userId, []WeeklyPlanItem{
+		weeklyItem(WeeklyPlanItem{BudgetItemId: 1, WeeklyDuration: 3 * time.Hour}),
+	})
+	require.NoError(t, err)
+	days := map[time.Weekday]time.Duration{time.Monday: time.Hour, time.Friday: 2 * time.Hour}
+
+	// when
+	updated, err := repo.UpdateItemDailyDurations(ctx, userId, createdItems[0].Id, days, true)
+	require.NoError(t, err)
+	_, err = repo.UpdateAllItemsByBudgetItemId(ctx, userId, 1, WeekNumber{}, "Renamed", "", "",
+		map[time.Weekday]time.Duration{time.Sunday: time.Hour})
+	require.NoError(t, err)
+
+	// then
+	require.True(t, updated.DailyDurationsCustomized)
+	stored, err := repo.GetItem(ctx, userId, createdItems[0].Id)
+	require.NoError(t, err)
+	require.Equal(t, "Renamed", stored.Name)
+	require.Equal(t, days, stored.DailyDurations)
+
+	// when the weekly duration changes
+	updated, err = repo.UpdateItem(ctx, userId, createdItems[0].Id, 4*time.Hour, "")
+
+	// then
+	require.NoError(t, err)
+	require.False(t, updated.DailyDurationsCustomized)
+	require.Empty(t, updated.DailyDurations)
+}
+
+func TestRepositoryImpl_SetLocked(t *testing.T) {
+	// given
+	ctx, repo, userId := setupTestRepository(t)
+	week := WeekNumber{Year: 2025, Week: 3}
+	lockedAt := time.Date(2025, 1, 20, 8, 0, 0, 0, time.UTC)
+
+	// when
+	locked, err := repo.SetLocked(ctx, userId, 1, week, &lockedAt)
+
+	// then
+	require.NoError(t, err)
+	require.NotNil(t, locked.LockedAt)
+	require.True(t, lockedAt.Equal(*locked.LockedAt))
+	stored, err := repo.GetWeeklyPlan(ctx, userId, week)
+	require.NoError(t, err)
+	require.True(t, stored.IsLocked())
+
+	// when
+	unlocked, err := repo.SetLocked(ctx, userId, 1, week, nil)
+
+	// then
+	require.NoError(t, err)
+	require.False(t, unlocked.IsLocked())
+	require.Equal(t, locked.Id, unlocked.Id)
+}
+
+func TestRepositoryImpl_AdHocItem(t *testing.T) {
+	// given
+	ctx, repo, userId := setupTestRepository(t)
+	firstId, err := repo.NextAdHocBudgetItemId(ctx)
+	require.NoError(t, err)
+	secondId, err := repo.NextAdHocBudgetItemId(ctx)
+	require.NoError(t, err)
+
+	// then
+	require.Less(t, firstId, 0)
+	require.Less(t, secondId, firstId)
+
+	// when
+	createdItems, err := repo.createItems(ctx, userId, []WeeklyPlanItem{weeklyItem(WeeklyPlanItem{BudgetItemId: firstId})})
+	require.NoError(t, err)
+	require.True(t, createdItems[0].IsAdHoc())
+	deleted, err := repo.DeleteItem(ctx, userId, createdItems[0].Id)
+
+	// then
+	require.NoError(t, err)
+	require.True(t, deleted)
+	_, err = repo.GetItem(ctx, userId, createdItems[0].Id)
+	require.Error(t, err)
+}
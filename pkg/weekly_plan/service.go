@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/klokku/klokku/internal/event_bus"
@@ -16,15 +19,65 @@ var ErrNoCurrentPlan = fmt.Errorf("no current plan")
 var ErrBudgetItemNotFound = fmt.Errorf("budget item not found")
 var ErrWeeklyItemAlreadyExists = fmt.Errorf("weekly items already exist for week")
 var ErrWeeklyItemNotFound = fmt.Errorf("weekly item not found")
+var ErrNothingToCopy = fmt.Errorf("source week has no customized items")
+var ErrCopyToSameWeek = fmt.Errorf("source and target week are the same")
+var ErrWeekLocked = fmt.Errorf("week is locked")
+var ErrWeekNotPast = fmt.Errorf("only past weeks can be locked")
+var ErrInvalidAdHocItem = fmt.Errorf("ad-hoc item requires a name and a non-negative weekly duration")
+var ErrNotAdHocItem = fmt.Errorf("item is not an ad-hoc item")
+var ErrAdHocItemReset = fmt.Errorf("ad-hoc item has no budget plan item to reset to")
+var ErrInvalidDailyDurations = fmt.Errorf("daily durations must be between 0 and 24 hours and sum up to the weekly duration")
+var ErrInvalidRange = fmt.Errorf("range end is before its start")
+var ErrRangeTooLong = fmt.Errorf("range spans more than %d weeks", MaxRangeWeeks)
+
+// MaxRangeWeeks is the maximum number of weeks returned by GetPlansForRange.
+const MaxRangeWeeks = 26
 
 type Service interface {
 	GetItemsForWeek(ctx context.Context, date time.Time) ([]WeeklyPlanItem, error)
 	GetPlanForWeek(ctx context.Context, date time.Time) (WeeklyPlan, error)
+	// GetPlansForRange returns the plans of all weeks from the week containing from to the week containing to,
+	// in week order. Stored weeks are read with a single query.
+	GetPlansForRange(ctx context.Context, from time.Time, to time.Time) ([]WeeklyPlan, error)
 	UpdateItem(ctx context.Context, weekDate time.Time, id int, budgetItemId int, weeklyDuration time.Duration, notes string) (WeeklyPlanItem, error)
+	// MoveItemAfter moves the week's item of the budget item right after the item of precedingBudgetItemId, or to the
+	// top when precedingBudgetItemId is 0. Only the given week is reordered, the budget plan keeps its order.
+	MoveItemAfter(ctx context.Context, weekDate time.Time, budgetItemId int, precedingBudgetItemId int) (WeeklyPlan, error)
+	// SetItemDailyDurations distributes the week's item of the budget item across weekdays. The daily durations must
+	// sum up to the item's weekly duration and are kept when the budget item changes. Empty daily durations restore
+	// the distribution of the budget item.
+	SetItemDailyDurations(ctx context.Context, weekDate time.Time, budgetItemId int, dailyDurations map[time.Weekday]time.Duration) (WeeklyPlanItem, error)
+	// AddAdHocItem adds a one-off item, not backed by a budget item, at the end of the given week.
+	AddAdHocItem(ctx context.Context, weekDate time.Time, item WeeklyPlanItem) (WeeklyPlanItem, error)
+	// DeleteAdHocItem removes an ad-hoc item from its week. Items created from the budget plan cannot be deleted.
+	DeleteAdHocItem(ctx context.Context, id int) error
 	// ResetWeekItemToBudgetPlanItem resets the specified weekly plan item to the value of the budget plan item it was created from.
 	ResetWeekItemToBudgetPlanItem(ctx context.Context, id int) (WeeklyPlanItem, error)
 	ResetWeekItemsToBudgetPlan(ctx context.Context, weekDate time.Time) ([]WeeklyPlanItem, error)
 	SetOffWeek(ctx context.Context, weekDate time.Time, isOffWeek bool) (WeeklyPlan, error)
+	// PreviewWeek shows the items that would be generated from the current budget plan for the given week,
+	// compared with the previous week. Nothing is persisted.
+	PreviewWeek(ctx context.Context, weekDate time.Time) (WeekPreview, error)
+	// CategoryTotals rolls up the plan's items by the categories of the budget plan the week is based on.
+	// It returns nil when the budget plan has no categories.
+	CategoryTotals(ctx context.Context, plan WeeklyPlan) ([]CategoryTotal, error)
+	// CopyWeek copies weekly durations and notes of the source week's items into the matching items of the target
+	// week. Target items already customized by the user are kept unless overwrite is set.
+	CopyWeek(ctx context.Context, sourceWeekDate time.Time, targetWeekDate time.Time, overwrite bool) (WeeklyPlan, error)
+	// ApplyCarryOver adds the carried time (by budget item id) to the weekly durations of the week's items and
+	// records it on the items. Items that already have a carry-over applied are left unchanged.
+	ApplyCarryOver(ctx context.Context, weekDate time.Time, carried map[int]time.Duration) ([]WeeklyPlanItem, error)
+	// LockWeek makes a past week's plan and events read-only. The week's items are persisted, so later budget
+	// plan changes do not alter it either.
+	LockWeek(ctx context.Context, weekDate time.Time) (WeeklyPlan, error)
+	UnlockWeek(ctx context.Context, weekDate time.Time) (WeeklyPlan, error)
+	// IsWeekLocked reports whether the week containing the date is locked.
+	IsWeekLocked(ctx context.Context, date time.Time) (bool, error)
+	// GenerateUpcomingWeeks creates the items of the current week and the weeksAhead weeks after it from the current
+	// budget plan, for the weeks that do not have them yet. Weeks that have not started and that the user did not change
+	// are generated again when they no longer match the current budget plan. It publishes weekly_plan.week.generated
+	// for every generated week and returns the generated weeks.
+	GenerateUpcomingWeeks(ctx context.Context, now time.Time, weeksAhead int) ([]WeekNumber, error)
 }
 
 type BudgetPlanReader interface {
@@ -33,14 +86,20 @@ type BudgetPlanReader interface {
 	GetItem(ctx context.Context, id int) (budget_plan.BudgetItem, error)
 }
 
+// AbsenceReader returns the days, at midnight UTC, from from to to (inclusive) the user is absent on.
+type AbsenceReader interface {
+	AbsentDays(ctx context.Context, from time.Time, to time.Time) ([]time.Time, error)
+}
+
 type ServiceImpl struct {
 	repo     Repository
 	bpReader BudgetPlanReader
+	absences AbsenceReader
 	eventBus *event_bus.EventBus
 }
 
-func NewService(repo Repository, bpReader BudgetPlanReader, eventBus *event_bus.EventBus) Service {
-	service := &ServiceImpl{repo, bpReader, eventBus}
+func NewService(repo Repository, bpReader BudgetPlanReader, absences AbsenceReader, eventBus *event_bus.EventBus) Service {
+	service := &ServiceImpl{repo, bpReader, absences, eventBus}
 	event_bus.SubscribeTyped[event_bus.BudgetPlanItemUpdated](
 		eventBus,
 		"budget_plan.item.updated",
@@ -56,6 +115,19 @@ func NewService(repo Repository, bpReader BudgetPlanReader, eventBus *event_bus.
 			return nil
 		},
 	)
+	event_bus.SubscribeTyped[event_bus.BudgetPlanItemDeleted](
+		eventBus,
+		"budget_plan.item.deleted",
+		func(e event_bus.EventT[event_bus.BudgetPlanItemDeleted]) error {
+			countDeleted, err := service.handleBudgetPlanItemDeleted(e.Context(), e.Data)
+			if err != nil {
+				log.Errorf("failed to handle budget plan item deletion: %v", err)
+				return err
+			}
+			log.Debugf("deleted weekly plan items: %d", countDeleted)
+			return nil
+		},
+	)
 	event_bus.SubscribeTyped[event_bus.CalendarEventCreated](
 		eventBus,
 		"calendar.event.updated",
@@ -100,18 +172,14 @@ func (s *ServiceImpl) GetPlanForWeek(ctx context.Context, date time.Time) (Weekl
 		return WeeklyPlan{}, fmt.Errorf("failed to get weekly plan items: %w", err)
 	}
 
+	firstDay := weekNumber.FirstDay(currentUser.Settings.WeekFirstDay)
+	absentDays, err := s.absences.AbsentDays(ctx, firstDay, firstDay.AddDate(0, 0, 6))
+	if err != nil {
+		return WeeklyPlan{}, fmt.Errorf("failed to get absences: %w", err)
+	}
+
 	if len(items) > 0 {
-		result := WeeklyPlan{
-			WeekNumber:   weekNumber,
-			BudgetPlanId: items[0].BudgetPlanId, // fallback for weeks without a weekly_plan record
-		}
-		if wp != nil {
-			result.Id = wp.Id
-			result.BudgetPlanId = wp.BudgetPlanId
-			result.IsOffWeek = wp.IsOffWeek
-		}
-		result.Items = items
-		return result, nil
+		return withAbsences(storedWeeklyPlan(weekNumber, wp, items), absentDays, currentUser.Settings.WeekFirstDay), nil
 	}
 
 	// No items in DB — synthesize from current budget plan
@@ -122,16 +190,181 @@ func (s *ServiceImpl) GetPlanForWeek(ctx context.Context, date time.Time) (Weekl
 		}
 		return WeeklyPlan{}, err
 	}
-	synthesized := make([]WeeklyPlanItem, 0, len(currentPlan.Items))
-	for _, bpItem := range currentPlan.Items {
+	plan := synthesizedWeeklyPlan(currentPlan, weekNumber, currentUser.Settings.WeekFirstDay)
+	return withAbsences(plan, absentDays, currentUser.Settings.WeekFirstDay), nil
+}
+
+func (s *ServiceImpl) GetPlansForRange(ctx context.Context, from time.Time, to time.Time) ([]WeeklyPlan, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	if to.Before(from) {
+		return nil, ErrInvalidRange
+	}
+
+	weekFirstDay := currentUser.Settings.WeekFirstDay
+	lastWeek := WeekNumberFromDate(to, weekFirstDay)
+	var weekNumbers []WeekNumber
+	for date := from; ; date = date.AddDate(0, 0, 7) {
+		weekNumber := WeekNumberFromDate(date, weekFirstDay)
+		if len(weekNumbers) > 0 && weekNumbers[len(weekNumbers)-1] == lastWeek {
+			break
+		}
+		if len(weekNumbers) == MaxRangeWeeks {
+			return nil, ErrRangeTooLong
+		}
+		weekNumbers = append(weekNumbers, weekNumber)
+	}
+
+	weeklyPlans, err := s.repo.GetWeeklyPlans(ctx, currentUser.Id, weekNumbers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get weekly plans: %w", err)
+	}
+	itemsByWeek, err := s.repo.GetItemsForWeeks(ctx, currentUser.Id, weekNumbers)
+	if err != nil {
+		log.Errorf("failed to get weekly plan items for weeks %s - %s: %v", weekNumbers[0], lastWeek, err)
+		return nil, fmt.Errorf("failed to get weekly plan items: %w", err)
+	}
+
+	firstDay := weekNumbers[0].FirstDay(weekFirstDay)
+	absentDays, err := s.absences.AbsentDays(ctx, firstDay, lastWeek.FirstDay(weekFirstDay).AddDate(0, 0, 6))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absences: %w", err)
+	}
+
+	var currentPlan *budget_plan.BudgetPlan
+	plans := make([]WeeklyPlan, 0, len(weekNumbers))
+	for _, weekNumber := range weekNumbers {
+		if items := itemsByWeek[weekNumber]; len(items) > 0 {
+			var wp *WeeklyPlan
+			if stored, ok := weeklyPlans[weekNumber]; ok {
+				wp = &stored
+			}
+			plans = append(plans, withAbsences(storedWeeklyPlan(weekNumber, wp, items), absentDays, weekFirstDay))
+			continue
+		}
+		if currentPlan == nil {
+			plan, err := s.bpReader.GetCurrentPlan(ctx)
+			if err != nil {
+				if errors.Is(err, budget_plan.ErrPlanNotFound) {
+					return nil, ErrNoCurrentPlan
+				}
+				return nil, err
+			}
+			currentPlan = &plan
+		}
+		plans = append(plans, withAbsences(synthesizedWeeklyPlan(*currentPlan, weekNumber, weekFirstDay), absentDays, weekFirstDay))
+	}
+	return plans, nil
+}
+
+// storedWeeklyPlan builds the plan of a week from its persisted items and weekly_plan record (if any).
+func storedWeeklyPlan(weekNumber WeekNumber, wp *WeeklyPlan, items []WeeklyPlanItem) WeeklyPlan {
+	result := WeeklyPlan{
+		WeekNumber:   weekNumber,
+		BudgetPlanId: items[0].BudgetPlanId, // fallback for weeks without a weekly_plan record
+	}
+	if wp != nil {
+		result.Id = wp.Id
+		result.BudgetPlanId = wp.BudgetPlanId
+		result.IsOffWeek = wp.IsOffWeek
+		result.LockedAt = wp.LockedAt
+	}
+	result.Items = items
+	return result
+}
+
+// withAbsences reduces the items of the plan by the absent days falling into its week: by the item's daily durations
+// of those days, or by their share of the weekly duration for items without daily durations. Items are not planned
+// on more days than the user is present on. The items keep a copy of themselves before the reduction.
+func withAbsences(plan WeeklyPlan, absentDays []time.Time, weekFirstDay time.Weekday) WeeklyPlan {
+	for _, day := range absentDays {
+		if WeekNumberFromDate(day, weekFirstDay).Equal(plan.WeekNumber) {
+			plan.AbsentDays = append(plan.AbsentDays, day.Weekday())
+		}
+	}
+	if len(plan.AbsentDays) == 0 {
+		return plan
+	}
+
+	presentDays := 7 - len(plan.AbsentDays)
+	items := make([]WeeklyPlanItem, 0, len(plan.Items))
+	for _, item := range plan.Items {
+		unreduced := item
+		item.Unreduced = &unreduced
+		if len(item.DailyDurations) > 0 {
+			dailyDurations := maps.Clone(item.DailyDurations)
+			for _, weekday := range plan.AbsentDays {
+				item.AbsenceReduction += dailyDurations[weekday]
+				delete(dailyDurations, weekday)
+			}
+			item.DailyDurations = dailyDurations
+		} else {
+			item.AbsenceReduction = (item.WeeklyDuration * time.Duration(len(plan.AbsentDays)) / 7).Round(time.Second)
+		}
+		item.WeeklyDuration -= item.AbsenceReduction
+		item.WeeklyOccurrences = min(item.WeeklyOccurrences, presentDays)
+		items = append(items, item)
+	}
+	plan.Items = items
+	return plan
+}
+
+// synthesizedWeeklyPlan builds the plan of a week without persisted items from the budget plan.
+func synthesizedWeeklyPlan(budgetPlan budget_plan.BudgetPlan, weekNumber WeekNumber, weekFirstDay time.Weekday) WeeklyPlan {
+	activeItems := activeBudgetItems(budgetPlan.Items, weekNumber, weekFirstDay)
+	synthesized := make([]WeeklyPlanItem, 0, len(activeItems))
+	for _, bpItem := range activeItems {
 		synthesized = append(synthesized, budgetPlanItemToWeekPlanItem(bpItem, weekNumber))
 	}
 	return WeeklyPlan{
 		WeekNumber:   weekNumber,
-		BudgetPlanId: currentPlan.Id,
+		BudgetPlanId: budgetPlan.Id,
 		IsOffWeek:    false,
 		Items:        synthesized,
-	}, nil
+	}
+}
+
+func (s *ServiceImpl) CategoryTotals(ctx context.Context, plan WeeklyPlan) ([]CategoryTotal, error) {
+	budgetPlan, err := s.bpReader.GetPlan(ctx, plan.BudgetPlanId)
+	if err != nil {
+		if errors.Is(err, budget_plan.ErrPlanNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get budget plan: %w", err)
+	}
+	if len(budgetPlan.Categories) == 0 {
+		return nil, nil
+	}
+
+	totals := make([]CategoryTotal, 0, len(budgetPlan.Categories)+1)
+	indexByCategoryId := make(map[int]int, len(budgetPlan.Categories))
+	for _, category := range budgetPlan.Categories {
+		indexByCategoryId[category.Id] = len(totals)
+		totals = append(totals, CategoryTotal{
+			CategoryId:    category.Id,
+			Name:          category.Name,
+			Color:         category.Color,
+			BudgetItemIds: []int{},
+		})
+	}
+	for _, item := range plan.Items {
+		// Items with sub-items hold the sum of their sub-items' durations, counting them would double the time
+		if budgetPlan.HasChildren(item.BudgetItemId) {
+			continue
+		}
+		categoryId := budgetPlan.ItemCategoryId(item.BudgetItemId)
+		idx, ok := indexByCategoryId[categoryId]
+		if !ok {
+			idx = len(totals)
+			indexByCategoryId[categoryId] = idx
+			totals = append(totals, CategoryTotal{BudgetItemIds: []int{}})
+		}
+		totals[idx].WeeklyDuration += item.WeeklyDuration
+		totals[idx].BudgetItemIds = append(totals[idx].BudgetItemIds, item.BudgetItemId)
+	}
+	return totals, nil
 }
 
 func (s *ServiceImpl) SetOffWeek(ctx context.Context, weekDate time.Time, isOffWeek bool) (WeeklyPlan, error) {
@@ -141,6 +374,9 @@ func (s *ServiceImpl) SetOffWeek(ctx context.Context, weekDate time.Time, isOffW
 	}
 
 	weekNumber := WeekNumberFromDate(weekDate, currentUser.Settings.WeekFirstDay)
+	if err := s.checkNotLocked(ctx, currentUser.Id, weekNumber); err != nil {
+		return WeeklyPlan{}, err
+	}
 
 	// Ensure items exist so we have a budgetPlanId to use
 	existingItems, err := s.repo.GetItemsForWeek(ctx, currentUser.Id, weekNumber)
@@ -157,8 +393,8 @@ func (s *ServiceImpl) SetOffWeek(ctx context.Context, weekDate time.Time, isOffW
 			}
 			return WeeklyPlan{}, err
 		}
-		err = s.repo.WithTransaction(ctx, func(repo Repository) error {
-			transactionalService := ServiceImpl{repo, s.bpReader, nil}
+		err = s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
+			transactionalService := ServiceImpl{repo, s.bpReader, s.absences, nil}
 			_, err = transactionalService.createItemsFromBudgetPlan(ctx, currentPlan.Id, weekNumber)
 			return err
 		})
@@ -183,6 +419,327 @@ func (s *ServiceImpl) SetOffWeek(ctx context.Context, weekDate time.Time, isOffW
 	return wp, nil
 }
 
+func (s *ServiceImpl) PreviewWeek(ctx context.Context, weekDate time.Time) (WeekPreview, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return WeekPreview{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	weekNumber := WeekNumberFromDate(weekDate, currentUser.Settings.WeekFirstDay)
+
+	currentPlan, err := s.bpReader.GetCurrentPlan(ctx)
+	if err != nil {
+		if errors.Is(err, budget_plan.ErrPlanNotFound) {
+			return WeekPreview{}, ErrNoCurrentPlan
+		}
+		return WeekPreview{}, err
+	}
+
+	existingItems, err := s.repo.GetItemsForWeek(ctx, currentUser.Id, weekNumber)
+	if err != nil {
+		return WeekPreview{}, fmt.Errorf("failed to get weekly plan items: %w", err)
+	}
+
+	// A previous week without persisted items follows the budget plan, its items are the synthesized ones
+	previousPlan, err := s.GetPlanForWeek(ctx, weekDate.AddDate(0, 0, -7))
+	if err != nil {
+		return WeekPreview{}, fmt.Errorf("failed to get previous week plan: %w", err)
+	}
+	previousItems := make([]WeeklyPlanItem, 0, len(previousPlan.Items))
+	previousByBudgetItem := make(map[int]WeeklyPlanItem, len(previousPlan.Items))
+	for _, item := range previousPlan.Items {
+		// Absences reduce the durations of their week only, the planned durations are compared
+		if item.Unreduced != nil {
+			item = *item.Unreduced
+		}
+		previousItems = append(previousItems, item)
+		previousByBudgetItem[item.BudgetItemId] = item
+	}
+
+	preview := WeekPreview{
+		WeekNumber:   weekNumber,
+		BudgetPlanId: currentPlan.Id,
+		IsGenerated:  len(existingItems) > 0,
+		Items:        make([]PreviewItem, 0, len(currentPlan.Items)),
+		RemovedItems: make([]WeeklyPlanItem, 0),
+	}
+	for _, bpItem := range activeBudgetItems(currentPlan.Items, weekNumber, currentUser.Settings.WeekFirstDay) {
+		item := budgetPlanItemToWeekPlanItem(bpItem, weekNumber)
+		previewItem := PreviewItem{Item: item, Change: PreviewAdded}
+		if previous, ok := previousByBudgetItem[bpItem.Id]; ok {
+			previewItem.PreviousWeeklyDuration = previous.WeeklyDuration
+			previewItem.Change = PreviewUnchanged
+			if previous.WeeklyDuration != item.WeeklyDuration {
+				previewItem.Change = PreviewDurationChanged
+			}
+			delete(previousByBudgetItem, bpItem.Id)
+		}
+		preview.Items = append(preview.Items, previewItem)
+	}
+	for _, previous := range previousItems {
+		// Ad-hoc items belong to their week only, they are not removed from the plan
+		if previous.IsAdHoc() {
+			continue
+		}
+		if _, removed := previousByBudgetItem[previous.BudgetItemId]; removed {
+			preview.RemovedItems = append(preview.RemovedItems, previous)
+		}
+	}
+	return preview, nil
+}
+
+func (s *ServiceImpl) CopyWeek(ctx context.Context, sourceWeekDate time.Time, targetWeekDate time.Time, overwrite bool) (WeeklyPlan, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return WeeklyPlan{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	sourceWeek := WeekNumberFromDate(sourceWeekDate, currentUser.Settings.WeekFirstDay)
+	targetWeek := WeekNumberFromDate(targetWeekDate, currentUser.Settings.WeekFirstDay)
+	if sourceWeek.Equal(targetWeek) {
+		return WeeklyPlan{}, ErrCopyToSameWeek
+	}
+	if err := s.checkNotLocked(ctx, currentUser.Id, targetWeek); err != nil {
+		return WeeklyPlan{}, err
+	}
+
+	// A week without persisted items follows the budget plan, there is nothing customized to copy
+	sourceItems, err := s.repo.GetItemsForWeek(ctx, currentUser.Id, sourceWeek)
+	if err != nil {
+		return WeeklyPlan{}, fmt.Errorf("failed to get source week items: %w", err)
+	}
+	if len(sourceItems) == 0 {
+		return WeeklyPlan{}, ErrNothingToCopy
+	}
+	sourceByBudgetItem := make(map[int]WeeklyPlanItem, len(sourceItems))
+	for _, item := range sourceItems {
+		sourceByBudgetItem[item.BudgetItemId] = item
+	}
+
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
+		transactionalService := ServiceImpl{repo, s.bpReader, s.absences, nil}
+		targetItems, err := repo.GetItemsForWeek(ctx, currentUser.Id, targetWeek)
+		if err != nil {
+			return err
+		}
+		if len(targetItems) == 0 {
+			currentPlan, err := s.bpReader.GetCurrentPlan(ctx)
+			if err != nil {
+				if errors.Is(err, budget_plan.ErrPlanNotFound) {
+					return ErrNoCurrentPlan
+				}
+				return err
+			}
+			targetItems, err = transactionalService.createItemsFromBudgetPlan(ctx, currentPlan.Id, targetWeek)
+			if err != nil {
+				return err
+			}
+		}
+		if len(targetItems) == 0 {
+			return nil
+		}
+		budgetPlan, err := s.bpReader.GetPlan(ctx, targetItems[0].BudgetPlanId)
+		if err != nil {
+			return fmt.Errorf("failed to get budget plan: %w", err)
+		}
+
+		for _, item := range targetItems {
+			source, ok := sourceByBudgetItem[item.BudgetItemId]
+			if !ok {
+				continue
+			}
+			isParent := budgetPlan.HasChildren(item.BudgetItemId)
+			if !overwrite && isCustomized(item, budgetPlan, isParent) {
+				continue
+			}
+			// Durations of items with sub-items are the sum of their sub-items, they are rolled up below
+			weeklyDuration := source.WeeklyDuration
+			if isParent {
+				weeklyDuration = item.WeeklyDuration
+			}
+			updatedItem, err := repo.UpdateItem(ctx, currentUser.Id, item.Id, weeklyDuration, source.Notes)
+			if err != nil {
+				return err
+			}
+			if _, err := transactionalService.rollUpWeekParent(ctx, repo, currentUser.Id, updatedItem); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrNoCurrentPlan) {
+			return WeeklyPlan{}, ErrNoCurrentPlan
+		}
+		return WeeklyPlan{}, fmt.Errorf("failed to copy weekly plan: %w", err)
+	}
+
+	return s.GetPlanForWeek(ctx, targetWeekDate)
+}
+
+func (s *ServiceImpl) ApplyCarryOver(ctx context.Context, weekDate time.Time, carried map[int]time.Duration) ([]WeeklyPlanItem, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	week := WeekNumberFromDate(weekDate, currentUser.Settings.WeekFirstDay)
+	if err := s.checkNotLocked(ctx, currentUser.Id, week); err != nil {
+		return nil, err
+	}
+
+	var applied []WeeklyPlanItem
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
+		transactionalService := ServiceImpl{repo, s.bpReader, s.absences, nil}
+		items, err := repo.GetItemsForWeek(ctx, currentUser.Id, week)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			currentPlan, err := s.bpReader.GetCurrentPlan(ctx)
+			if err != nil {
+				if errors.Is(err, budget_plan.ErrPlanNotFound) {
+					return ErrNoCurrentPlan
+				}
+				return err
+			}
+			items, err = transactionalService.createItemsFromBudgetPlan(ctx, currentPlan.Id, week)
+			if err != nil {
+				return err
+			}
+		}
+		for _, item := range items {
+			carry, ok := carried[item.BudgetItemId]
+			if !ok || item.CarriedOver != nil {
+				continue
+			}
+			weeklyDuration := max(item.WeeklyDuration+carry, 0)
+			updatedItem, err := repo.SetCarriedOver(ctx, currentUser.Id, item.Id, weeklyDuration, carry)
+			if err != nil {
+				return err
+			}
+			if _, err := transactionalService.rollUpWeekParent(ctx, repo, currentUser.Id, updatedItem); err != nil {
+				return err
+			}
+			applied = append(applied, updatedItem)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrNoCurrentPlan) {
+			return nil, ErrNoCurrentPlan
+		}
+		return nil, fmt.Errorf("failed to apply carry-over: %w", err)
+	}
+	return applied, nil
+}
+
+func (s *ServiceImpl) LockWeek(ctx context.Context, weekDate time.Time) (WeeklyPlan, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return WeeklyPlan{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	week := WeekNumberFromDate(weekDate, currentUser.Settings.WeekFirstDay)
+	currentWeek := WeekNumberFromDate(time.Now(), currentUser.Settings.WeekFirstDay)
+	if !week.Before(currentWeek) {
+		return WeeklyPlan{}, ErrWeekNotPast
+	}
+
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
+		transactionalService := ServiceImpl{repo, s.bpReader, s.absences, nil}
+		items, err := repo.GetItemsForWeek(ctx, currentUser.Id, week)
+		if err != nil {
+			return err
+		}
+		// Persist the items, a week without them would follow the current budget plan
+		if len(items) == 0 {
+			currentPlan, err := s.bpReader.GetCurrentPlan(ctx)
+			if err != nil {
+				if errors.Is(err, budget_plan.ErrPlanNotFound) {
+					return ErrNoCurrentPlan
+				}
+				return err
+			}
+			items, err = transactionalService.createItemsFromBudgetPlan(ctx, currentPlan.Id, week)
+			if err != nil {
+				return err
+			}
+		}
+		if len(items) == 0 {
+			return ErrNoCurrentPlan
+		}
+		wp, err := repo.GetWeeklyPlan(ctx, currentUser.Id, week)
+		if err != nil {
+			return err
+		}
+		if wp != nil && wp.IsLocked() {
+			return nil
+		}
+		lockedAt := time.Now()
+		_, err = repo.SetLocked(ctx, currentUser.Id, items[0].BudgetPlanId, week, &lockedAt)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, ErrNoCurrentPlan) {
+			return WeeklyPlan{}, ErrNoCurrentPlan
+		}
+		return WeeklyPlan{}, fmt.Errorf("failed to lock week: %w", err)
+	}
+	return s.GetPlanForWeek(ctx, weekDate)
+}
+
+func (s *ServiceImpl) UnlockWeek(ctx context.Context, weekDate time.Time) (WeeklyPlan, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return WeeklyPlan{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	week := WeekNumberFromDate(weekDate, currentUser.Settings.WeekFirstDay)
+	wp, err := s.repo.GetWeeklyPlan(ctx, currentUser.Id, week)
+	if err != nil {
+		return WeeklyPlan{}, fmt.Errorf("failed to get weekly plan: %w", err)
+	}
+	if wp != nil && wp.IsLocked() {
+		if _, err := s.repo.SetLocked(ctx, currentUser.Id, wp.BudgetPlanId, week, nil); err != nil {
+			return WeeklyPlan{}, fmt.Errorf("failed to unlock week: %w", err)
+		}
+	}
+	return s.GetPlanForWeek(ctx, weekDate)
+}
+
+func (s *ServiceImpl) IsWeekLocked(ctx context.Context, date time.Time) (bool, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get current user: %w", err)
+	}
+	wp, err := s.repo.GetWeeklyPlan(ctx, currentUser.Id, WeekNumberFromDate(date, currentUser.Settings.WeekFirstDay))
+	if err != nil {
+		return false, fmt.Errorf("failed to get weekly plan: %w", err)
+	}
+	return wp != nil && wp.IsLocked(), nil
+}
+
+// checkNotLocked returns ErrWeekLocked when the user locked the week.
+func (s *ServiceImpl) checkNotLocked(ctx context.Context, userId int, week WeekNumber) error {
+	wp, err := s.repo.GetWeeklyPlan(ctx, userId, week)
+	if err != nil {
+		return fmt.Errorf("failed to get weekly plan: %w", err)
+	}
+	if wp != nil && wp.IsLocked() {
+		return fmt.Errorf("%w: %s", ErrWeekLocked, week)
+	}
+	return nil
+}
+
+// isCustomized reports whether the user changed the weekly item compared with the budget plan item it was created from.
+// Durations of items with sub-items are derived, so only their notes count.
+func isCustomized(item WeeklyPlanItem, budgetPlan budget_plan.BudgetPlan, isParent bool) bool {
+	if item.Notes != "" {
+		return true
+	}
+	if isParent {
+		return false
+	}
+	budgetItem, found := budgetPlan.FindItem(item.BudgetItemId)
+	return found && budgetItem.WeeklyDuration != item.WeeklyDuration
+}
+
 func (s *ServiceImpl) UpdateItem(
 	ctx context.Context,
 	weekDate time.Time,
@@ -197,10 +754,24 @@ func (s *ServiceImpl) UpdateItem(
 	}
 	// Update existing item (weekly item already exists)
 	if id != 0 {
-		return s.repo.UpdateItem(ctx, currentUser.Id, id, weeklyDuration, notes)
+		item, err := s.repo.GetItem(ctx, currentUser.Id, id)
+		if err != nil {
+			return WeeklyPlanItem{}, err
+		}
+		if err := s.checkNotLocked(ctx, currentUser.Id, item.WeekNumber); err != nil {
+			return WeeklyPlanItem{}, err
+		}
+		updatedItem, err := s.repo.UpdateItem(ctx, currentUser.Id, id, weeklyDuration, notes)
+		if err != nil {
+			return WeeklyPlanItem{}, err
+		}
+		return s.rollUpWeekParent(ctx, s.repo, currentUser.Id, updatedItem)
 	}
 
 	week := WeekNumberFromDate(weekDate, currentUser.Settings.WeekFirstDay)
+	if err := s.checkNotLocked(ctx, currentUser.Id, week); err != nil {
+		return WeeklyPlanItem{}, err
+	}
 
 	// Weekly items do not exist yet, create them
 	budgetItem, err := s.bpReader.GetItem(ctx, budgetItemId)
@@ -218,8 +789,8 @@ func (s *ServiceImpl) UpdateItem(
 	}
 
 	var updatedItem WeeklyPlanItem
-	err = s.repo.WithTransaction(ctx, func(repo Repository) error {
-		transactionalService := ServiceImpl{repo, s.bpReader, nil}
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
+		transactionalService := ServiceImpl{repo, s.bpReader, s.absences, nil}
 		items, err := transactionalService.createItemsFromBudgetPlan(ctx, budgetItem.PlanId, week)
 		if err != nil {
 			return err
@@ -230,6 +801,10 @@ func (s *ServiceImpl) UpdateItem(
 				if err != nil {
 					return err
 				}
+				updatedItem, err = s.rollUpWeekParent(ctx, repo, currentUser.Id, updatedItem)
+				if err != nil {
+					return err
+				}
 				break
 			}
 		}
@@ -241,12 +816,444 @@ func (s *ServiceImpl) UpdateItem(
 	return updatedItem, nil
 }
 
+func (s *ServiceImpl) MoveItemAfter(ctx context.Context, weekDate time.Time, budgetItemId int, precedingBudgetItemId int) (WeeklyPlan, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return WeeklyPlan{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	week := WeekNumberFromDate(weekDate, currentUser.Settings.WeekFirstDay)
+	if err := s.checkNotLocked(ctx, currentUser.Id, week); err != nil {
+		return WeeklyPlan{}, err
+	}
+
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
+		items, _, err := s.ensureWeekItems(ctx, repo, currentUser.Id, week)
+		if err != nil {
+			return err
+		}
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].Position < items[j].Position
+		})
+
+		itemIdx := findWeekItem(budgetItemId, items)
+		if itemIdx == -1 {
+			return ErrWeeklyItemNotFound
+		}
+		itemToMove := items[itemIdx]
+		others := append(append([]WeeklyPlanItem{}, items[:itemIdx]...), items[itemIdx+1:]...)
+		newIdx := 0
+		if precedingBudgetItemId != 0 {
+			precedingIdx := findWeekItem(precedingBudgetItemId, others)
+			if precedingIdx == -1 {
+				return ErrWeeklyItemNotFound
+			}
+			newIdx = precedingIdx + 1
+		}
+
+		prevPos, nextPos := 0, -1
+		if newIdx > 0 {
+			prevPos = others[newIdx-1].Position
+		}
+		if newIdx < len(others) {
+			nextPos = others[newIdx].Position
+		}
+		if nextPos == -1 {
+			_, err = repo.UpdateItemPosition(ctx, currentUser.Id, itemToMove.Id, prevPos+100)
+			return err
+		}
+		if nextPos-prevPos > 1 {
+			_, err = repo.UpdateItemPosition(ctx, currentUser.Id, itemToMove.Id, prevPos+(nextPos-prevPos)/2)
+			return err
+		}
+		// no space between the previous and next item - reorder all items of the week
+		reordered := append(append(append([]WeeklyPlanItem{}, others[:newIdx]...), itemToMove), others[newIdx:]...)
+		for i, item := range reordered {
+			position := (i + 1) * 100
+			if item.Position == position {
+				continue
+			}
+			if _, err := repo.UpdateItemPosition(ctx, currentUser.Id, item.Id, position); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return WeeklyPlan{}, fmt.Errorf("failed to move weekly plan item: %w", err)
+	}
+	return s.GetPlanForWeek(ctx, weekDate)
+}
+
+func (s *ServiceImpl) SetItemDailyDurations(
+	ctx context.Context,
+	weekDate time.Time,
+	budgetItemId int,
+	dailyDurations map[time.Weekday]time.Duration,
+) (WeeklyPlanItem, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return WeeklyPlanItem{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	week := WeekNumberFromDate(weekDate, currentUser.Settings.WeekFirstDay)
+	if err := s.checkNotLocked(ctx, currentUser.Id, week); err != nil {
+		return WeeklyPlanItem{}, err
+	}
+
+	var updatedItem WeeklyPlanItem
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
+		items, _, err := s.ensureWeekItems(ctx, repo, currentUser.Id, week)
+		if err != nil {
+			return err
+		}
+		itemIdx := findWeekItem(budgetItemId, items)
+		if itemIdx == -1 {
+			return ErrWeeklyItemNotFound
+		}
+		item := items[itemIdx]
+
+		if len(dailyDurations) == 0 {
+			var budgetDailyDurations map[time.Weekday]time.Duration
+			if !item.IsAdHoc() {
+				budgetItem, err := s.bpReader.GetItem(ctx, item.BudgetItemId)
+				if err != nil {
+					return ErrBudgetItemNotFound
+				}
+				budgetDailyDurations = budgetItem.DailyDurations
+			}
+			updatedItem, err = repo.UpdateItemDailyDurations(ctx, currentUser.Id, item.Id, budgetDailyDurations, false)
+			return err
+		}
+
+		if err := validateDailyDurations(dailyDurations, item.WeeklyDuration); err != nil {
+			return err
+		}
+		updatedItem, err = repo.UpdateItemDailyDurations(ctx, currentUser.Id, item.Id, dailyDurations, true)
+		return err
+	})
+	if err != nil {
+		return WeeklyPlanItem{}, fmt.Errorf("failed to set daily durations: %w", err)
+	}
+	return updatedItem, nil
+}
+
+// validateDailyDurations checks that each day fits into a day and that the days sum up to the weekly duration.
+func validateDailyDurations(dailyDurations map[time.Weekday]time.Duration, weeklyDuration time.Duration) error {
+	total := time.Duration(0)
+	for weekday, duration := range dailyDurations {
+		if weekday < time.Sunday || weekday > time.Saturday || duration < 0 || duration > 24*time.Hour {
+			return ErrInvalidDailyDurations
+		}
+		total += duration
+	}
+	if total != weeklyDuration {
+		return fmt.Errorf("%w: days sum up to %s instead of %s", ErrInvalidDailyDurations, total, weeklyDuration)
+	}
+	return nil
+}
+
+func (s *ServiceImpl) GenerateUpcomingWeeks(ctx context.Context, now time.Time, weeksAhead int) ([]WeekNumber, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	location, err := time.LoadLocation(currentUser.Settings.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user timezone: %w", err)
+	}
+	weekFirstDay := currentUser.Settings.WeekFirstDay
+	currentWeek := WeekNumberFromDate(now.In(location), weekFirstDay)
+
+	var generated []WeekNumber
+	for i := 0; i <= weeksAhead; i++ {
+		week := WeekNumberFromDate(currentWeek.FirstDay(weekFirstDay).AddDate(0, 0, 7*i), weekFirstDay)
+		var items []WeeklyPlanItem
+		var budgetPlanId int
+		err := s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
+			weeklyPlan, err := repo.GetWeeklyPlan(ctx, currentUser.Id, week)
+			if err != nil {
+				return err
+			}
+			existing, err := repo.GetItemsForWeek(ctx, currentUser.Id, week)
+			if err != nil {
+				return err
+			}
+			if weeklyPlan != nil || len(existing) > 0 {
+				// Weeks that have not started follow the current budget plan until the user changes them
+				if !week.After(currentWeek) {
+					return nil
+				}
+				outdated, err := s.isOutdatedGeneratedWeek(ctx, weeklyPlan, existing, week, weekFirstDay)
+				if err != nil || !outdated {
+					return err
+				}
+				if _, err := repo.DeleteWeekItems(ctx, currentUser.Id, week); err != nil {
+					return err
+				}
+				if err := repo.DeleteWeeklyPlan(ctx, currentUser.Id, week); err != nil {
+					return err
+				}
+			}
+			items, budgetPlanId, err = s.ensureWeekItems(ctx, repo, currentUser.Id, week)
+			return err
+		})
+		if err != nil {
+			return generated, err
+		}
+		if budgetPlanId == 0 {
+			continue
+		}
+		generated = append(generated, week)
+		log.Debugf("Generated %d weekly plan items of week %s for user %d", len(items), week, currentUser.Id)
+		if s.eventBus != nil {
+			err = s.eventBus.Publish(event_bus.NewEvent(ctx, "weekly_plan.week.generated", event_bus.WeeklyPlanWeekGenerated{
+				BudgetPlanId: budgetPlanId,
+				Week:         week.String(),
+				StartDate:    week.FirstDay(weekFirstDay),
+				ItemCount:    len(items),
+			}))
+			if err != nil {
+				log.Errorf("failed to publish weekly_plan.week.generated event: %v", err)
+			}
+		}
+	}
+	return generated, nil
+}
+
+// isOutdatedGeneratedWeek reports whether the week's items are still as they were created from their budget plan, but
+// no longer match the current budget plan, e.g. because an item was added to it or another plan became current.
+// Weeks the user changed in any way are never outdated.
+func (s *ServiceImpl) isOutdatedGeneratedWeek(
+	ctx context.Context,
+	weeklyPlan *WeeklyPlan,
+	items []WeeklyPlanItem,
+	week WeekNumber,
+	weekFirstDay time.Weekday,
+) (bool, error) {
+	if len(items) == 0 || (weeklyPlan != nil && (weeklyPlan.IsOffWeek || weeklyPlan.IsLocked())) {
+		return false, nil
+	}
+	weekPlan, err := s.bpReader.GetPlan(ctx, items[0].BudgetPlanId)
+	if err != nil && !errors.Is(err, budget_plan.ErrPlanNotFound) {
+		return false, fmt.Errorf("failed to get budget plan: %w", err)
+	}
+	for _, item := range items {
+		if item.IsAdHoc() || item.CarriedOver != nil || item.DailyDurationsCustomized ||
+			isCustomized(item, weekPlan, weekPlan.HasChildren(item.BudgetItemId)) {
+			return false, nil
+		}
+	}
+	currentPlan, err := s.bpReader.GetCurrentPlan(ctx)
+	if err != nil {
+		if errors.Is(err, budget_plan.ErrPlanNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if currentPlan.Id != weekPlan.Id {
+		return true, nil
+	}
+	active := activeBudgetItems(currentPlan.Items, week, weekFirstDay)
+	if len(active) != len(items) {
+		return true, nil
+	}
+	weekItems := make(map[int]bool, len(items))
+	for _, item := range items {
+		weekItems[item.BudgetItemId] = true
+	}
+	for _, budgetItem := range active {
+		if !weekItems[budgetItem.Id] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ensureWeekItems returns the week's items, creating them from the current budget plan when the week has none yet,
+// together with the id of the budget plan the week is based on.
+func (s *ServiceImpl) ensureWeekItems(ctx context.Context, repo Repository, userId int, week WeekNumber) ([]WeeklyPlanItem, int, error) {
+	items, err := repo.GetItemsForWeek(ctx, userId, week)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(items) > 0 {
+		return items, items[0].BudgetPlanId, nil
+	}
+	currentPlan, err := s.bpReader.GetCurrentPlan(ctx)
+	if err != nil {
+		if errors.Is(err, budget_plan.ErrPlanNotFound) {
+			return nil, 0, ErrNoCurrentPlan
+		}
+		return nil, 0, err
+	}
+	transactionalService := ServiceImpl{repo, s.bpReader, s.absences, nil}
+	items, err = transactionalService.createItemsFromBudgetPlan(ctx, currentPlan.Id, week)
+	if err != nil {
+		return nil, 0, err
+	}
+	return items, currentPlan.Id, nil
+}
+
+func (s *ServiceImpl) AddAdHocItem(ctx context.Context, weekDate time.Time, item WeeklyPlanItem) (WeeklyPlanItem, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return WeeklyPlanItem{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	if strings.TrimSpace(item.Name) == "" || item.WeeklyDuration < 0 || item.WeeklyOccurrences < 0 {
+		return WeeklyPlanItem{}, ErrInvalidAdHocItem
+	}
+	week := WeekNumberFromDate(weekDate, currentUser.Settings.WeekFirstDay)
+	if err := s.checkNotLocked(ctx, currentUser.Id, week); err != nil {
+		return WeeklyPlanItem{}, err
+	}
+
+	var created WeeklyPlanItem
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
+		items, budgetPlanId, err := s.ensureWeekItems(ctx, repo, currentUser.Id, week)
+		if err != nil {
+			return err
+		}
+		position := 0
+		for _, weekItem := range items {
+			position = max(position, weekItem.Position)
+		}
+		budgetItemId, err := repo.NextAdHocBudgetItemId(ctx)
+		if err != nil {
+			return err
+		}
+		createdItems, err := repo.createItems(ctx, currentUser.Id, []WeeklyPlanItem{{
+			BudgetItemId:      budgetItemId,
+			BudgetPlanId:      budgetPlanId,
+			WeekNumber:        week,
+			Name:              item.Name,
+			WeeklyDuration:    item.WeeklyDuration,
+			WeeklyOccurrences: item.WeeklyOccurrences,
+			Icon:              item.Icon,
+			Color:             item.Color,
+			Notes:             item.Notes,
+			Position:          position + 100,
+		}})
+		if err != nil {
+			return err
+		}
+		created = createdItems[0]
+		return nil
+	})
+	if err != nil {
+		return WeeklyPlanItem{}, fmt.Errorf("failed to add ad-hoc item: %w", err)
+	}
+	s.publishWeekChanged(ctx, week)
+	return created, nil
+}
+
+func (s *ServiceImpl) DeleteAdHocItem(ctx context.Context, id int) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	item, err := s.repo.GetItem(ctx, userId, id)
+	if err != nil {
+		log.Errorf("failed to get weekly plan item: %v", err)
+		return ErrWeeklyItemNotFound
+	}
+	if !item.IsAdHoc() {
+		return ErrNotAdHocItem
+	}
+	if err := s.checkNotLocked(ctx, userId, item.WeekNumber); err != nil {
+		return err
+	}
+	deleted, err := s.repo.DeleteItem(ctx, userId, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrWeeklyItemNotFound
+	}
+	s.publishWeekChanged(ctx, item.WeekNumber)
+	return nil
+}
+
+// publishWeekChanged lets other modules drop what they know about the week's items.
+func (s *ServiceImpl) publishWeekChanged(ctx context.Context, week WeekNumber) {
+	if s.eventBus == nil {
+		return
+	}
+	err := s.eventBus.Publish(event_bus.NewEvent(ctx, "weekly_plan.week.changed", event_bus.WeeklyPlanWeekChanged{Week: week.String()}))
+	if err != nil {
+		log.Errorf("failed to publish weekly_plan.week.changed event: %v", err)
+	}
+}
+
+func findWeekItem(budgetItemId int, items []WeeklyPlanItem) int {
+	for i, item := range items {
+		if item.BudgetItemId == budgetItemId {
+			return i
+		}
+	}
+	return -1
+}
+
+// rollUpWeekParent sets the weekly duration of the parent of the item (or of the item itself, when it has sub-items)
+// to the sum of its sub-items' durations in the item's week. It returns the item as stored after the roll-up.
+func (s *ServiceImpl) rollUpWeekParent(ctx context.Context, repo Repository, userId int, item WeeklyPlanItem) (WeeklyPlanItem, error) {
+	budgetPlan, err := s.bpReader.GetPlan(ctx, item.BudgetPlanId)
+	if err != nil {
+		if errors.Is(err, budget_plan.ErrPlanNotFound) {
+			return item, nil
+		}
+		return WeeklyPlanItem{}, fmt.Errorf("failed to get budget plan: %w", err)
+	}
+	budgetItem, found := budgetPlan.FindItem(item.BudgetItemId)
+	if !found {
+		return item, nil
+	}
+	parentBudgetItemId := budgetItem.ParentId
+	if budgetPlan.HasChildren(budgetItem.Id) {
+		parentBudgetItemId = budgetItem.Id
+	}
+	if parentBudgetItemId == 0 {
+		return item, nil
+	}
+
+	weekItems, err := repo.GetItemsForWeek(ctx, userId, item.WeekNumber)
+	if err != nil {
+		return WeeklyPlanItem{}, err
+	}
+	childIds := make(map[int]bool)
+	for _, child := range budgetPlan.Children(parentBudgetItemId) {
+		childIds[child.Id] = true
+	}
+	var parent *WeeklyPlanItem
+	total := time.Duration(0)
+	for i, weekItem := range weekItems {
+		if weekItem.BudgetItemId == parentBudgetItemId {
+			parent = &weekItems[i]
+		}
+		if childIds[weekItem.BudgetItemId] {
+			total += weekItem.WeeklyDuration
+		}
+	}
+	if parent == nil {
+		return item, nil
+	}
+	if parent.WeeklyDuration != total {
+		updatedParent, err := repo.UpdateItem(ctx, userId, parent.Id, total, parent.Notes)
+		if err != nil {
+			return WeeklyPlanItem{}, fmt.Errorf("failed to roll up weekly plan item: %w", err)
+		}
+		parent = &updatedParent
+	}
+	if parent.Id == item.Id {
+		return *parent, nil
+	}
+	return item, nil
+}
+
 // createItemsFromBudgetPlan generates weekly plan items from the specified budget plan for a given week and persists them in the repository.
 // This is done in two cases:
 // 1. When a user updates any weekly item for the week that did not have the WeeklyItems yet
 // 2. When a first calendar event is created for the given week
 func (s *ServiceImpl) createItemsFromBudgetPlan(ctx context.Context, budgetPlanId int, week WeekNumber) ([]WeeklyPlanItem, error) {
-	userId, err := user.CurrentId(ctx)
+	currentUser, err := user.CurrentUser(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get current user: %w", err)
 	}
@@ -255,9 +1262,10 @@ func (s *ServiceImpl) createItemsFromBudgetPlan(ctx context.Context, budgetPlanI
 		return nil, fmt.Errorf("failed to get budget plan: %w", err)
 	}
 	var items []WeeklyPlanItem
-	for _, bpItem := range plan.Items {
+	for _, bpItem := range activeBudgetItems(plan.Items, week, currentUser.Settings.WeekFirstDay) {
 		items = append(items, budgetPlanItemToWeekPlanItem(bpItem, week))
 	}
+	userId := currentUser.Id
 	createdItems, err := s.repo.createItems(ctx, userId, items)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create weekly plan items: %w", err)
@@ -280,6 +1288,12 @@ func (s *ServiceImpl) ResetWeekItemToBudgetPlanItem(ctx context.Context, id int)
 		log.Errorf("failed to get weekly plan item: %v", err)
 		return WeeklyPlanItem{}, ErrWeeklyItemNotFound
 	}
+	if item.IsAdHoc() {
+		return WeeklyPlanItem{}, ErrAdHocItemReset
+	}
+	if err := s.checkNotLocked(ctx, userId, item.WeekNumber); err != nil {
+		return WeeklyPlanItem{}, err
+	}
 
 	budgetItem, err := s.bpReader.GetItem(ctx, item.BudgetItemId)
 	if err != nil {
@@ -295,6 +1309,13 @@ func (s *ServiceImpl) ResetWeekItemToBudgetPlanItem(ctx context.Context, id int)
 		log.Errorf("failed to reset weekly plan item: %v", err)
 		return WeeklyPlanItem{}, err
 	}
+	if updatedItem.DailyDurationsCustomized || !maps.Equal(updatedItem.DailyDurations, budgetItem.DailyDurations) {
+		updatedItem, err = s.repo.UpdateItemDailyDurations(ctx, userId, item.Id, budgetItem.DailyDurations, false)
+		if err != nil {
+			log.Errorf("failed to reset daily durations of weekly plan item: %v", err)
+			return WeeklyPlanItem{}, err
+		}
+	}
 
 	return updatedItem, nil
 }
@@ -306,10 +1327,13 @@ func (s *ServiceImpl) ResetWeekItemsToBudgetPlan(ctx context.Context, weekDate t
 	}
 
 	week := WeekNumberFromDate(weekDate, currentUser.Settings.WeekFirstDay)
+	if err := s.checkNotLocked(ctx, currentUser.Id, week); err != nil {
+		return nil, err
+	}
 	currentWeek := WeekNumberFromDate(time.Now(), currentUser.Settings.WeekFirstDay)
 	// For future weeks simply delete all weekly plan items and the weekly plan record
 	if week.After(currentWeek) {
-		err = s.repo.WithTransaction(ctx, func(repo Repository) error {
+		err = s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
 			if _, err := repo.DeleteWeekItems(ctx, currentUser.Id, week); err != nil {
 				return fmt.Errorf("failed to delete weekly plan items: %w", err)
 			}
@@ -318,6 +1342,7 @@ func (s *ServiceImpl) ResetWeekItemsToBudgetPlan(ctx context.Context, weekDate t
 		if err != nil {
 			return nil, fmt.Errorf("failed to reset weekly plan: %w", err)
 		}
+		s.publishWeekChanged(ctx, week)
 		items, err := s.GetItemsForWeek(ctx, weekDate)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get weekly plan items: %w", err)
@@ -326,14 +1351,18 @@ func (s *ServiceImpl) ResetWeekItemsToBudgetPlan(ctx context.Context, weekDate t
 	}
 
 	// For past and current weeks only restore the items' WeeklyDuration and remove notes,
-	// and delete the weekly plan record (clears IsOffWeek)
+	// and delete the weekly plan record (clears IsOffWeek). Ad-hoc items are kept as they are, events may use them.
 	items, err := s.GetItemsForWeek(ctx, weekDate)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get weekly plan items before reset: %w", err)
 	}
 	var resetItems []WeeklyPlanItem
-	err = s.repo.WithTransaction(ctx, func(repo Repository) error {
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
 		for _, item := range items {
+			if item.IsAdHoc() {
+				resetItems = append(resetItems, item)
+				continue
+			}
 			budgetItem, err := s.bpReader.GetItem(ctx, item.BudgetItemId)
 			if err != nil {
 				log.Errorf("failed to get budget plan item: %v", err)
@@ -343,6 +1372,12 @@ func (s *ServiceImpl) ResetWeekItemsToBudgetPlan(ctx context.Context, weekDate t
 			if err != nil {
 				return err
 			}
+			if updatedItem.Position != budgetItem.Position {
+				updatedItem, err = repo.UpdateItemPosition(ctx, currentUser.Id, item.Id, budgetItem.Position)
+				if err != nil {
+					return err
+				}
+			}
 			resetItems = append(resetItems, updatedItem)
 		}
 		return repo.DeleteWeeklyPlan(ctx, currentUser.Id, week)
@@ -355,11 +1390,59 @@ func (s *ServiceImpl) ResetWeekItemsToBudgetPlan(ctx context.Context, weekDate t
 }
 
 func (s *ServiceImpl) handleBudgetPlanItemUpdated(ctx context.Context, budgetItem event_bus.BudgetPlanItemUpdated) (int, error) {
-	userId, err := user.CurrentId(ctx)
+	currentUser, err := user.CurrentUser(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get current user: %w", err)
 	}
-	return s.repo.UpdateAllItemsByBudgetItemId(ctx, userId, budgetItem.Id, budgetItem.Name, budgetItem.Icon, budgetItem.Color)
+	// Past weeks keep the item as it was, unless the user wants the past rewritten
+	fromWeek := WeekNumber{}
+	if !currentUser.Settings.RenamePropagation.RewritePastWeeklyPlans {
+		fromWeek = WeekNumberFromDate(time.Now(), currentUser.Settings.WeekFirstDay)
+	}
+	return s.repo.UpdateAllItemsByBudgetItemId(ctx, currentUser.Id, budgetItem.Id, fromWeek, budgetItem.Name, budgetItem.Icon,
+		budgetItem.Color, budgetItem.DailyDurations)
+}
+
+// handleBudgetPlanItemDeleted removes the deleted budget item from the current and upcoming weeks.
+// Past weeks keep their items as the record of what was planned.
+func (s *ServiceImpl) handleBudgetPlanItemDeleted(ctx context.Context, budgetItem event_bus.BudgetPlanItemDeleted) (int, error) {
+	currentUser, err := user.CurrentUser(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get current user: %w", err)
+	}
+	fromWeek := WeekNumberFromDate(time.Now(), currentUser.Settings.WeekFirstDay)
+	return s.repo.DeleteItemsByBudgetItemId(ctx, currentUser.Id, budgetItem.Id, fromWeek)
+}
+
+// activeBudgetItems returns the budget plan items active in the given week, i.e. whose StartDate-EndDate window
+// overlaps the week. Items with sub-items get the sum of their active sub-items' durations.
+func activeBudgetItems(items []budget_plan.BudgetItem, week WeekNumber, weekStartDay time.Weekday) []budget_plan.BudgetItem {
+	firstDay := week.FirstDay(weekStartDay)
+	lastDay := firstDay.AddDate(0, 0, 6)
+
+	parentIds := make(map[int]bool)
+	activeChildrenDuration := make(map[int]time.Duration)
+	for _, item := range items {
+		if item.ParentId == 0 {
+			continue
+		}
+		parentIds[item.ParentId] = true
+		if item.IsActiveBetween(firstDay, lastDay) {
+			activeChildrenDuration[item.ParentId] += item.WeeklyDuration
+		}
+	}
+
+	active := make([]budget_plan.BudgetItem, 0, len(items))
+	for _, item := range items {
+		if !item.IsActiveBetween(firstDay, lastDay) {
+			continue
+		}
+		if parentIds[item.Id] {
+			item.WeeklyDuration = activeChildrenDuration[item.Id]
+		}
+		active = append(active, item)
+	}
+	return active
 }
 
 func budgetPlanItemToWeekPlanItem(bpItem budget_plan.BudgetItem, weekNumber WeekNumber) WeeklyPlanItem {
@@ -370,6 +1453,7 @@ func budgetPlanItemToWeekPlanItem(bpItem budget_plan.BudgetItem, weekNumber Week
 		Name:              bpItem.Name,
 		WeeklyDuration:    bpItem.WeeklyDuration,
 		WeeklyOccurrences: bpItem.WeeklyOccurrences,
+		DailyDurations:    bpItem.DailyDurations,
 		Icon:              bpItem.Icon,
 		Color:             bpItem.Color,
 		Notes:             "",
@@ -384,8 +1468,8 @@ func (s *ServiceImpl) handleCalendarEventChanged(ctx context.Context, event even
 	}
 
 	week := WeekNumberFromDate(event.StartTime, currentUser.Settings.WeekFirstDay)
-	err = s.repo.WithTransaction(ctx, func(repo Repository) error {
-		transactionalService := ServiceImpl{repo, s.bpReader, s.eventBus}
+	err = s.repo.WithTransaction(ctx, func(ctx context.Context, repo Repository) error {
+		transactionalService := ServiceImpl{repo, s.bpReader, s.absences, s.eventBus}
 		weeklyPlanItems, err := repo.GetItemsForWeek(ctx, currentUser.Id, week)
 		if err != nil {
 			return err
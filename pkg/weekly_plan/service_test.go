@@ -2,6 +2,7 @@ package weekly_plan
 
 import (
 	"context"
+	"slices"
 	"testing"
 	"time"
 
@@ -24,21 +25,25 @@ var ctx = context.WithValue(context.Background(), user.UserKey, user.User{
 		WeekFirstDay:      time.Monday,
 		EventCalendarType: user.KlokkuCalendar,
 		GoogleCalendar:    user.GoogleCalendarSettings{},
+		RenamePropagation: user.DefaultRenamePropagation,
 	},
 })
 
 var repoStub = NewRepositoryStub()
 var bpReaderStub = NewBudgetPlanReaderStub()
-var eventBus = event_bus.NewEventBus()
+var absenceStub = NewAbsenceReaderStub()
+var eventBus *event_bus.EventBus
 
 var service Service
 
 func setup(t *testing.T) func() {
-	service = NewService(repoStub, bpReaderStub, eventBus)
+	eventBus = event_bus.NewEventBus()
+	service = NewService(repoStub, bpReaderStub, absenceStub, eventBus)
 	return func() {
 		t.Log("Teardown after test")
 		repoStub.Reset()
 		bpReaderStub.Reset()
+		absenceStub.Reset()
 	}
 }
 
@@ -549,6 +554,35 @@ func TestServiceImpl_createItemsFromBudgetPlan(t *testing.T) {
 		assert.Equal(t, 1, items[1].Position)
 	})
 
+	t.Run("skips items outside of their date window", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// 2025-W03 is from Monday 13th to Sunday 19th of January
+		weekNumber := WeekNumber{Year: 2025, Week: 3}
+		date := func(day int) *time.Time {
+			d := time.Date(2025, time.January, day, 0, 0, 0, 0, time.UTC)
+			return &d
+		}
+		bpReaderStub.SetPlan(budget_plan.BudgetPlan{
+			Id: 1,
+			Items: []budget_plan.BudgetItem{
+				{Id: 101, PlanId: 1, Name: "Study", WeeklyDuration: 10 * time.Hour},
+				{Id: 102, PlanId: 1, Name: "Course", WeeklyDuration: 4 * time.Hour, ParentId: 101, StartDate: date(19)},
+				{Id: 103, PlanId: 1, Name: "Finished course", WeeklyDuration: 6 * time.Hour, ParentId: 101, EndDate: date(12)},
+				{Id: 104, PlanId: 1, Name: "Future course", WeeklyDuration: 2 * time.Hour, StartDate: date(20)},
+			},
+		})
+
+		items, err := service.(*ServiceImpl).createItemsFromBudgetPlan(ctx, 1, weekNumber)
+
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+		assert.Equal(t, 101, items[0].BudgetItemId)
+		assert.Equal(t, 4*time.Hour, items[0].WeeklyDuration)
+		assert.Equal(t, 102, items[1].BudgetItemId)
+	})
+
 	t.Run("returns error when budget plan not found", func(t *testing.T) {
 		teardown := setup(t)
 		defer teardown()
@@ -609,10 +643,11 @@ func TestServiceImpl_handleBudgetPlanItemUpdated(t *testing.T) {
 
 		// Simulate budget plan item update
 		updatedBudgetItem := event_bus.BudgetPlanItemUpdated{
-			Id:    101,
-			Name:  "Updated Work",
-			Icon:  "💼",
-			Color: "#FF5733",
+			Id:             101,
+			Name:           "Updated Work",
+			Icon:           "💼",
+			Color:          "#FF5733",
+			DailyDurations: map[time.Weekday]time.Duration{time.Monday: 8 * time.Hour},
 		}
 
 		err = eventBus.Publish(event_bus.NewEvent(ctx, "budget_plan.item.updated", updatedBudgetItem))
@@ -627,6 +662,7 @@ func TestServiceImpl_handleBudgetPlanItemUpdated(t *testing.T) {
 		assert.Equal(t, "Updated Work", workItemWeek1.Name, "Name should be changed")
 		assert.Equal(t, "💼", workItemWeek1.Icon, "Icon should be changed")
 		assert.Equal(t, "#FF5733", workItemWeek1.Color, "Color should be changed")
+		assert.Equal(t, updatedBudgetItem.DailyDurations, workItemWeek1.DailyDurations, "Daily durations should be changed")
 		assert.Equal(t, "custom note 1", workItemWeek1.Notes, "Note should not be changed")
 		assert.Equal(t, 35*time.Hour, workItemWeek1.WeeklyDuration, "Weekly duration should not be changed")
 
@@ -649,6 +685,86 @@ func TestServiceImpl_handleBudgetPlanItemUpdated(t *testing.T) {
 		}
 	})
 
+	t.Run("keeps past weeks when the user does not rewrite the past", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		pastWeek := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+		nextWeek := time.Now().AddDate(0, 0, 7)
+		bpReaderStub.SetCurrentPlan(budget_plan.BudgetPlan{
+			Id:        1,
+			Name:      "My Plan",
+			IsCurrent: true,
+			Items: []budget_plan.BudgetItem{
+				{Id: 101, PlanId: 1, Name: "Old Work", WeeklyDuration: 40 * time.Hour, Icon: "📝", Color: "#000000"},
+			},
+		})
+		_, err := service.UpdateItem(ctx, pastWeek, 0, 101, 35*time.Hour, "")
+		require.NoError(t, err)
+		_, err = service.UpdateItem(ctx, nextWeek, 0, 101, 35*time.Hour, "")
+		require.NoError(t, err)
+
+		currentUser, _ := user.CurrentUser(ctx)
+		currentUser.Settings.RenamePropagation.RewritePastWeeklyPlans = false
+		keepPastCtx := user.WithUser(ctx, currentUser)
+
+		count, err := service.(*ServiceImpl).handleBudgetPlanItemUpdated(keepPastCtx, event_bus.BudgetPlanItemUpdated{
+			Id:    101,
+			Name:  "Updated Work",
+			Icon:  "💼",
+			Color: "#FF5733",
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		pastItems, err := service.GetItemsForWeek(ctx, pastWeek)
+		require.NoError(t, err)
+		require.Len(t, pastItems, 1)
+		assert.Equal(t, "Old Work", pastItems[0].Name)
+		nextItems, err := service.GetItemsForWeek(ctx, nextWeek)
+		require.NoError(t, err)
+		require.Len(t, nextItems, 1)
+		assert.Equal(t, "Updated Work", nextItems[0].Name)
+	})
+
+	t.Run("keeps items of locked weeks", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		lockedWeek := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+		openWeek := time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC)
+		bpReaderStub.SetCurrentPlan(budget_plan.BudgetPlan{
+			Id:        1,
+			Name:      "My Plan",
+			IsCurrent: true,
+			Items: []budget_plan.BudgetItem{
+				{Id: 101, PlanId: 1, Name: "Old Work", WeeklyDuration: 40 * time.Hour, Icon: "📝", Color: "#000000"},
+			},
+		})
+		_, err := service.UpdateItem(ctx, openWeek, 0, 101, 35*time.Hour, "")
+		require.NoError(t, err)
+		_, err = service.LockWeek(ctx, lockedWeek)
+		require.NoError(t, err)
+
+		count, err := service.(*ServiceImpl).handleBudgetPlanItemUpdated(ctx, event_bus.BudgetPlanItemUpdated{
+			Id:    101,
+			Name:  "Updated Work",
+			Icon:  "💼",
+			Color: "#FF5733",
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		lockedItems, err := service.GetItemsForWeek(ctx, lockedWeek)
+		require.NoError(t, err)
+		require.Len(t, lockedItems, 1)
+		assert.Equal(t, "Old Work", lockedItems[0].Name)
+		openItems, err := service.GetItemsForWeek(ctx, openWeek)
+		require.NoError(t, err)
+		require.Len(t, openItems, 1)
+		assert.Equal(t, "Updated Work", openItems[0].Name)
+	})
+
 	t.Run("returns 0 when no items match", func(t *testing.T) {
 		teardown := setup(t)
 		defer teardown()
@@ -667,6 +783,40 @@ func TestServiceImpl_handleBudgetPlanItemUpdated(t *testing.T) {
 	})
 }
 
+func TestServiceImpl_handleBudgetPlanItemDeleted(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+
+	pastWeek := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	nextWeek := time.Now().AddDate(0, 0, 7)
+	bpReaderStub.SetCurrentPlan(budget_plan.BudgetPlan{
+		Id:        1,
+		Name:      "My Plan",
+		IsCurrent: true,
+		Items: []budget_plan.BudgetItem{
+			{Id: 101, PlanId: 1, Name: "Work", WeeklyDuration: 40 * time.Hour},
+			{Id: 102, PlanId: 1, Name: "Exercise", WeeklyDuration: 5 * time.Hour},
+		},
+	})
+	_, err := service.UpdateItem(ctx, pastWeek, 0, 101, 35*time.Hour, "")
+	require.NoError(t, err)
+	_, err = service.UpdateItem(ctx, nextWeek, 0, 101, 35*time.Hour, "")
+	require.NoError(t, err)
+
+	// when
+	err = eventBus.Publish(event_bus.NewEvent(ctx, "budget_plan.item.deleted", event_bus.BudgetPlanItemDeleted{Id: 101, PlanId: 1}))
+
+	// then
+	require.NoError(t, err)
+	pastItems, err := service.GetItemsForWeek(ctx, pastWeek)
+	require.NoError(t, err)
+	assert.Len(t, pastItems, 2, "past weeks keep the deleted item")
+	nextItems, err := service.GetItemsForWeek(ctx, nextWeek)
+	require.NoError(t, err)
+	require.Len(t, nextItems, 1)
+	assert.Equal(t, 102, nextItems[0].BudgetItemId)
+}
+
 func TestServiceImpl_SetOffWeek(t *testing.T) {
 	t.Run("marks a week as off and returns plan with flag set", func(t *testing.T) {
 		teardown := setup(t)
@@ -835,3 +985,938 @@ func TestServiceImpl_handleCalendarEventChanged(t *testing.T) {
 		assert.Equal(t, item2.Id, items[1].BudgetItemId)
 	})
 }
+
+func TestServiceImpl_PreviewWeek(t *testing.T) {
+	previousWeekDate := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	weekDate := previousWeekDate.AddDate(0, 0, 7)
+	previousPlan := budget_plan.BudgetPlan{
+		Id:        1,
+		Name:      "Previous plan",
+		IsCurrent: true,
+		Items: []budget_plan.BudgetItem{
+			{Id: 101, PlanId: 1, Name: "Work", WeeklyDuration: 40 * time.Hour, Position: 100},
+			{Id: 102, PlanId: 1, Name: "Reading", WeeklyDuration: 3 * time.Hour, Position: 200},
+			{Id: 103, PlanId: 1, Name: "Gaming", WeeklyDuration: 5 * time.Hour, Position: 300},
+		},
+	}
+
+	t.Run("flags differences from the previous week without persisting", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		bpReaderStub.SetCurrentPlan(previousPlan)
+		_, err := service.UpdateItem(ctx, previousWeekDate, 0, 101, 40*time.Hour, "")
+		require.NoError(t, err)
+		bpReaderStub.SetCurrentPlan(budget_plan.BudgetPlan{
+			Id:        2,
+			Name:      "New plan",
+			IsCurrent: true,
+			Items: []budget_plan.BudgetItem{
+				{Id: 101, PlanId: 2, Name: "Work", WeeklyDuration: 35 * time.Hour, Position: 100},
+				{Id: 102, PlanId: 2, Name: "Reading", WeeklyDuration: 3 * time.Hour, Position: 200},
+				{Id: 104, PlanId: 2, Name: "Running", WeeklyDuration: 2 * time.Hour, Position: 300},
+			},
+		})
+
+		// when
+		preview, err := service.PreviewWeek(ctx, weekDate)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 2, preview.BudgetPlanId)
+		assert.Equal(t, WeekNumber{Year: 2025, Week: 4}, preview.WeekNumber)
+		assert.False(t, preview.IsGenerated)
+		require.Len(t, preview.Items, 3)
+		assert.Equal(t, PreviewDurationChanged, preview.Items[0].Change)
+		assert.Equal(t, 40*time.Hour, preview.Items[0].PreviousWeeklyDuration)
+		assert.Equal(t, 35*time.Hour, preview.Items[0].Item.WeeklyDuration)
+		assert.Equal(t, PreviewUnchanged, preview.Items[1].Change)
+		assert.Equal(t, PreviewAdded, preview.Items[2].Change)
+		require.Len(t, preview.RemovedItems, 1)
+		assert.Equal(t, 103, preview.RemovedItems[0].BudgetItemId)
+
+		items, err := repoStub.GetItemsForWeek(ctx, 10, preview.WeekNumber)
+		require.NoError(t, err)
+		assert.Empty(t, items)
+	})
+
+	t.Run("marks week that already has items as generated", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		bpReaderStub.SetCurrentPlan(previousPlan)
+		_, err := service.UpdateItem(ctx, weekDate, 0, 101, 40*time.Hour, "")
+		require.NoError(t, err)
+
+		// when
+		preview, err := service.PreviewWeek(ctx, weekDate)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, preview.IsGenerated)
+		// the previous week was not generated, it follows the same plan
+		for _, item := range preview.Items {
+			assert.Equal(t, PreviewUnchanged, item.Change)
+		}
+	})
+
+	t.Run("compares with the budget plan when the previous week was not generated", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		bpReaderStub.SetCurrentPlan(budget_plan.BudgetPlan{
+			Id:        2,
+			Name:      "New plan",
+			IsCurrent: true,
+			Items: []budget_plan.BudgetItem{
+				{Id: 101, PlanId: 2, Name: "Work", WeeklyDuration: 35 * time.Hour, Position: 100},
+				{Id: 104, PlanId: 2, Name: "Running", WeeklyDuration: 2 * time.Hour, Position: 200,
+					StartDate: &weekDate},
+			},
+		})
+
+		// when
+		preview, err := service.PreviewWeek(ctx, weekDate)
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, preview.IsGenerated)
+		require.Len(t, preview.Items, 2)
+		assert.Equal(t, PreviewUnchanged, preview.Items[0].Change)
+		assert.Equal(t, 35*time.Hour, preview.Items[0].PreviousWeeklyDuration)
+		// the item starts this week, it was not in the previous week's plan
+		assert.Equal(t, PreviewAdded, preview.Items[1].Change)
+		assert.Empty(t, preview.RemovedItems)
+	})
+
+	t.Run("returns error when there is no current plan", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		_, err := service.PreviewWeek(ctx, weekDate)
+
+		assert.ErrorIs(t, err, ErrNoCurrentPlan)
+	})
+}
+
+func TestServiceImpl_CategoryTotals(t *testing.T) {
+	t.Run("rolls up items by category with uncategorized items last", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		bpReaderStub.SetCurrentPlan(budget_plan.BudgetPlan{
+			Id:        1,
+			IsCurrent: true,
+			Categories: []budget_plan.Category{
+				{Id: 11, PlanId: 1, Name: "Work", Color: "#0000FF"},
+				{Id: 12, PlanId: 1, Name: "Health"},
+			},
+			Items: []budget_plan.BudgetItem{
+				{Id: 101, PlanId: 1, Name: "Coding", WeeklyDuration: 30 * time.Hour, CategoryId: 11},
+				{Id: 102, PlanId: 1, Name: "Meetings", WeeklyDuration: 5 * time.Hour, CategoryId: 11},
+				{Id: 103, PlanId: 1, Name: "Reading", WeeklyDuration: 3 * time.Hour},
+			},
+		})
+		plan, err := service.GetPlanForWeek(ctx, time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+
+		// when
+		totals, err := service.CategoryTotals(ctx, plan)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, totals, 3)
+		assert.Equal(t, CategoryTotal{
+			CategoryId:     11,
+			Name:           "Work",
+			Color:          "#0000FF",
+			WeeklyDuration: 35 * time.Hour,
+			BudgetItemIds:  []int{101, 102},
+		}, totals[0])
+		assert.Equal(t, 12, totals[1].CategoryId)
+		assert.Equal(t, time.Duration(0), totals[1].WeeklyDuration)
+		assert.Empty(t, totals[1].BudgetItemIds)
+		assert.Equal(t, 0, totals[2].CategoryId)
+		assert.Equal(t, 3*time.Hour, totals[2].WeeklyDuration)
+		assert.Equal(t, []int{103}, totals[2].BudgetItemIds)
+	})
+
+	t.Run("returns nil when the plan has no categories", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		bpReaderStub.SetCurrentPlan(budget_plan.BudgetPlan{
+			Id:        1,
+			IsCurrent: true,
+			Items:     []budget_plan.BudgetItem{{Id: 101, PlanId: 1, Name: "Reading", WeeklyDuration: 3 * time.Hour}},
+		})
+		plan, err := service.GetPlanForWeek(ctx, time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+
+		// when
+		totals, err := service.CategoryTotals(ctx, plan)
+
+		// then
+		require.NoError(t, err)
+		assert.Nil(t, totals)
+	})
+}
+
+func TestServiceImpl_UpdateItem_SubItems(t *testing.T) {
+	t.Run("rolls up sub-item durations into the parent weekly item", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		// given
+		weekDate := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+		plan := budget_plan.BudgetPlan{
+			Id:        1,
+			IsCurrent: true,
+			Items: []budget_plan.BudgetItem{
+				{Id: 101, PlanId: 1, Name: "Work", WeeklyDuration: 25 * time.Hour},
+				{Id: 102, PlanId: 1, Name: "Meetings", WeeklyDuration: 5 * time.Hour, ParentId: 101},
+				{Id: 103, PlanId: 1, Name: "Coding", WeeklyDuration: 20 * time.Hour, ParentId: 101},
+			},
+		}
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+		bpReaderStub.SetItem(plan.Items[2])
+
+		// when
+		coding, err := service.UpdateItem(ctx, weekDate, 0, 103, 10*time.Hour, "")
+		require.NoError(t, err)
+
+		// then
+		assert.Equal(t, 10*time.Hour, coding.WeeklyDuration)
+		items, err := service.GetItemsForWeek(ctx, weekDate)
+		require.NoError(t, err)
+		assert.Equal(t, 15*time.Hour, findWeeklyItem(items, 101).WeeklyDuration)
+
+		// when
+		work, err := service.UpdateItem(ctx, weekDate, findWeeklyItem(items, 101).Id, 101, 40*time.Hour, "Focus")
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 15*time.Hour, work.WeeklyDuration)
+		assert.Equal(t, "Focus", work.Notes)
+	})
+}
+
+func findWeeklyItem(items []WeeklyPlanItem, budgetItemId int) WeeklyPlanItem {
+	for _, item := range items {
+		if item.BudgetItemId == budgetItemId {
+			return item
+		}
+	}
+	return WeeklyPlanItem{}
+}
+
+func TestServiceImpl_CopyWeek(t *testing.T) {
+	sourceDate := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	targetDate := time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC)
+	plan := budget_plan.BudgetPlan{
+		Id:        1,
+		Name:      "My Plan",
+		IsCurrent: true,
+		Items: []budget_plan.BudgetItem{
+			{Id: 101, PlanId: 1, Name: "Work", WeeklyDuration: 40 * time.Hour, WeeklyOccurrences: 5, Position: 0},
+			{Id: 102, PlanId: 1, Name: "Exercise", WeeklyDuration: 5 * time.Hour, WeeklyOccurrences: 3, Position: 1},
+		},
+	}
+	customizeSourceWeek := func(t *testing.T) {
+		_, err := service.UpdateItem(ctx, sourceDate, 0, 101, 30*time.Hour, "focus")
+		require.NoError(t, err)
+		items, err := service.GetItemsForWeek(ctx, sourceDate)
+		require.NoError(t, err)
+		_, err = service.UpdateItem(ctx, sourceDate, items[1].Id, 102, 3*time.Hour, "")
+		require.NoError(t, err)
+	}
+
+	t.Run("copies durations and notes into a week without items", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+		customizeSourceWeek(t)
+
+		copied, err := service.CopyWeek(ctx, sourceDate, targetDate, false)
+
+		require.NoError(t, err)
+		assert.Equal(t, WeekNumber{Year: 2025, Week: 4}, copied.WeekNumber)
+		require.Len(t, copied.Items, 2)
+		assert.NotZero(t, copied.Items[0].Id)
+		assert.Equal(t, 30*time.Hour, copied.Items[0].WeeklyDuration)
+		assert.Equal(t, "focus", copied.Items[0].Notes)
+		assert.Equal(t, 3*time.Hour, copied.Items[1].WeeklyDuration)
+	})
+
+	t.Run("skips customized target items unless overwrite is set", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+		customizeSourceWeek(t)
+		_, err := service.UpdateItem(ctx, targetDate, 0, 101, 35*time.Hour, "own")
+		require.NoError(t, err)
+
+		copied, err := service.CopyWeek(ctx, sourceDate, targetDate, false)
+		require.NoError(t, err)
+		require.Len(t, copied.Items, 2)
+		assert.Equal(t, 35*time.Hour, copied.Items[0].WeeklyDuration)
+		assert.Equal(t, "own", copied.Items[0].Notes)
+		assert.Equal(t, 3*time.Hour, copied.Items[1].WeeklyDuration)
+
+		copied, err = service.CopyWeek(ctx, sourceDate, targetDate, true)
+		require.NoError(t, err)
+		assert.Equal(t, 30*time.Hour, copied.Items[0].WeeklyDuration)
+		assert.Equal(t, "focus", copied.Items[0].Notes)
+	})
+
+	t.Run("fails when the source week has no items", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+
+		_, err := service.CopyWeek(ctx, sourceDate, targetDate, false)
+
+		assert.ErrorIs(t, err, ErrNothingToCopy)
+		items, err := repoStub.GetItemsForWeek(ctx, 10, WeekNumber{Year: 2025, Week: 4})
+		require.NoError(t, err)
+		assert.Empty(t, items)
+	})
+
+	t.Run("fails when the source and target are the same week", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		_, err := service.CopyWeek(ctx, sourceDate, sourceDate.AddDate(0, 0, 3), false)
+
+		assert.ErrorIs(t, err, ErrCopyToSameWeek)
+	})
+}
+
+func TestServiceImpl_ApplyCarryOver(t *testing.T) {
+	weekDate := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	plan := budget_plan.BudgetPlan{
+		Id:        1,
+		Name:      "My Plan",
+		IsCurrent: true,
+		Items: []budget_plan.BudgetItem{
+			{Id: 101, PlanId: 1, Name: "Reading", WeeklyDuration: 5 * time.Hour, Rollover: true, Position: 0},
+			{Id: 102, PlanId: 1, Name: "Work", WeeklyDuration: 40 * time.Hour, Position: 1},
+		},
+	}
+
+	t.Run("creates the week and applies the carry-over once", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+
+		applied, err := service.ApplyCarryOver(ctx, weekDate, map[int]time.Duration{101: 2 * time.Hour})
+		require.NoError(t, err)
+		require.Len(t, applied, 1)
+
+		applied, err = service.ApplyCarryOver(ctx, weekDate, map[int]time.Duration{101: 3 * time.Hour})
+		require.NoError(t, err)
+		assert.Empty(t, applied)
+
+		items, err := repoStub.GetItemsForWeek(ctx, 10, WeekNumberFromDate(weekDate, time.Monday))
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+		assert.Equal(t, 7*time.Hour, items[0].WeeklyDuration)
+		require.NotNil(t, items[0].CarriedOver)
+		assert.Equal(t, 2*time.Hour, *items[0].CarriedOver)
+		assert.Equal(t, 40*time.Hour, items[1].WeeklyDuration)
+		assert.Nil(t, items[1].CarriedOver)
+	})
+
+	t.Run("does not reduce the duration below zero", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+
+		applied, err := service.ApplyCarryOver(ctx, weekDate, map[int]time.Duration{101: -6 * time.Hour})
+
+		require.NoError(t, err)
+		require.Len(t, applied, 1)
+		assert.Equal(t, time.Duration(0), applied[0].WeeklyDuration)
+		assert.Equal(t, -6*time.Hour, *applied[0].CarriedOver)
+	})
+}
+
+func TestServiceImpl_LockWeek(t *testing.T) {
+	pastDate := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	plan := budget_plan.BudgetPlan{
+		Id:        1,
+		Name:      "My Plan",
+		IsCurrent: true,
+		Items: []budget_plan.BudgetItem{
+			{Id: 101, PlanId: 1, Name: "Work", WeeklyDuration: 40 * time.Hour, WeeklyOccurrences: 5, Position: 0},
+		},
+	}
+
+	t.Run("persists the week's items and rejects changes until unlocked", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+
+		locked, err := service.LockWeek(ctx, pastDate)
+		require.NoError(t, err)
+		assert.True(t, locked.IsLocked())
+		require.Len(t, locked.Items, 1)
+		assert.NotZero(t, locked.Items[0].Id)
+		isLocked, err := service.IsWeekLocked(ctx, pastDate.AddDate(0, 0, 3))
+		require.NoError(t, err)
+		assert.True(t, isLocked)
+
+		_, updateErr := service.UpdateItem(ctx, pastDate, locked.Items[0].Id, 101, time.Hour, "")
+		_, resetItemErr := service.ResetWeekItemToBudgetPlanItem(ctx, locked.Items[0].Id)
+		_, resetWeekErr := service.ResetWeekItemsToBudgetPlan(ctx, pastDate)
+		_, offWeekErr := service.SetOffWeek(ctx, pastDate, true)
+		assert.ErrorIs(t, updateErr, ErrWeekLocked)
+		assert.ErrorIs(t, resetItemErr, ErrWeekLocked)
+		assert.ErrorIs(t, resetWeekErr, ErrWeekLocked)
+		assert.ErrorIs(t, offWeekErr, ErrWeekLocked)
+
+		unlocked, err := service.UnlockWeek(ctx, pastDate)
+		require.NoError(t, err)
+		assert.False(t, unlocked.IsLocked())
+		updated, err := service.UpdateItem(ctx, pastDate, locked.Items[0].Id, 101, time.Hour, "")
+		require.NoError(t, err)
+		assert.Equal(t, time.Hour, updated.WeeklyDuration)
+	})
+
+	t.Run("rejects locking the current week", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+
+		_, err := service.LockWeek(ctx, time.Now())
+
+		assert.ErrorIs(t, err, ErrWeekNotPast)
+	})
+}
+
+func TestServiceImpl_MoveItemAfter(t *testing.T) {
+	weekDate := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	plan := budget_plan.BudgetPlan{
+		Id:        1,
+		Name:      "My Plan",
+		IsCurrent: true,
+		Items: []budget_plan.BudgetItem{
+			{Id: 101, PlanId: 1, Name: "Work", WeeklyDuration: 40 * time.Hour, WeeklyOccurrences: 5, Position: 100},
+			{Id: 102, PlanId: 1, Name: "Sport", WeeklyDuration: 5 * time.Hour, WeeklyOccurrences: 3, Position: 200},
+			{Id: 103, PlanId: 1, Name: "Reading", WeeklyDuration: 3 * time.Hour, WeeklyOccurrences: 7, Position: 300},
+		},
+	}
+	budgetItemIds := func(items []WeeklyPlanItem) []int {
+		ids := make([]int, 0, len(items))
+		for _, item := range items {
+			ids = append(ids, item.BudgetItemId)
+		}
+		return ids
+	}
+
+	t.Run("reorders only the given week", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+
+		moved, err := service.MoveItemAfter(ctx, weekDate, 103, 101)
+		require.NoError(t, err)
+		assert.Equal(t, []int{101, 103, 102}, budgetItemIds(moved.Items))
+
+		moved, err = service.MoveItemAfter(ctx, weekDate, 102, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []int{102, 101, 103}, budgetItemIds(moved.Items))
+
+		nextWeek, err := service.GetItemsForWeek(ctx, weekDate.AddDate(0, 0, 7))
+		require.NoError(t, err)
+		assert.Equal(t, []int{101, 102, 103}, budgetItemIds(nextWeek))
+	})
+
+	t.Run("renumbers the week when there is no space between items", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		crowded := plan
+		crowded.Items = []budget_plan.BudgetItem{plan.Items[0], plan.Items[1], plan.Items[2]}
+		crowded.Items[0].Position = 1
+		crowded.Items[1].Position = 2
+		crowded.Items[2].Position = 3
+		bpReaderStub.SetCurrentPlan(crowded)
+		bpReaderStub.SetPlan(crowded)
+
+		moved, err := service.MoveItemAfter(ctx, weekDate, 103, 101)
+		require.NoError(t, err)
+
+		assert.Equal(t, []int{101, 103, 102}, budgetItemIds(moved.Items))
+		assert.Equal(t, 200, moved.Items[1].Position)
+	})
+
+	t.Run("returns not found for an item outside of the week", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+
+		_, err := service.MoveItemAfter(ctx, weekDate, 101, 999)
+
+		assert.ErrorIs(t, err, ErrWeeklyItemNotFound)
+	})
+}
+
+func TestServiceImpl_AdHocItem(t *testing.T) {
+	weekDate := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	plan := budget_plan.BudgetPlan{
+		Id:        1,
+		Name:      "My Plan",
+		IsCurrent: true,
+		Items: []budget_plan.BudgetItem{
+			{Id: 101, PlanId: 1, Name: "Work", WeeklyDuration: 40 * time.Hour, WeeklyOccurrences: 5, Position: 100},
+		},
+	}
+
+	t.Run("adds the item to the given week only", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+
+		created, err := service.AddAdHocItem(ctx, weekDate, WeeklyPlanItem{Name: "Tax return", WeeklyDuration: 3 * time.Hour})
+		require.NoError(t, err)
+
+		assert.True(t, created.IsAdHoc())
+		assert.Equal(t, 1, created.BudgetPlanId)
+		assert.Equal(t, 200, created.Position)
+		items, err := service.GetItemsForWeek(ctx, weekDate)
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+		assert.Equal(t, 101, items[0].BudgetItemId)
+		assert.Equal(t, "Tax return", items[1].Name)
+		nextWeek, err := service.GetItemsForWeek(ctx, weekDate.AddDate(0, 0, 7))
+		require.NoError(t, err)
+		assert.Len(t, nextWeek, 1)
+	})
+
+	t.Run("rejects an item without name", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+
+		_, err := service.AddAdHocItem(ctx, weekDate, WeeklyPlanItem{Name: " ", WeeklyDuration: time.Hour})
+
+		assert.ErrorIs(t, err, ErrInvalidAdHocItem)
+	})
+
+	t.Run("is kept by a week reset and can be deleted", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+		created, err := service.AddAdHocItem(ctx, weekDate, WeeklyPlanItem{Name: "Tax return", WeeklyDuration: 3 * time.Hour})
+		require.NoError(t, err)
+
+		resetItems, err := service.ResetWeekItemsToBudgetPlan(ctx, weekDate)
+		require.NoError(t, err)
+		assert.Len(t, resetItems, 2)
+		_, resetItemErr := service.ResetWeekItemToBudgetPlanItem(ctx, created.Id)
+		assert.ErrorIs(t, resetItemErr, ErrAdHocItemReset)
+
+		assert.ErrorIs(t, service.DeleteAdHocItem(ctx, resetItems[0].Id), ErrNotAdHocItem)
+		require.NoError(t, service.DeleteAdHocItem(ctx, created.Id))
+		items, err := service.GetItemsForWeek(ctx, weekDate)
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		assert.Equal(t, 101, items[0].BudgetItemId)
+	})
+}
+
+func TestServiceImpl_GetPlansForRange(t *testing.T) {
+	weekDate := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	plan := budget_plan.BudgetPlan{
+		Id:        1,
+		Name:      "My Plan",
+		IsCurrent: true,
+		Items: []budget_plan.BudgetItem{
+			{Id: 101, PlanId: 1, Name: "Work", WeeklyDuration: 40 * time.Hour, WeeklyOccurrences: 5, Position: 100},
+			{Id: 102, PlanId: 1, Name: "Sport", WeeklyDuration: 5 * time.Hour, WeeklyOccurrences: 3, Position: 200},
+		},
+	}
+
+	t.Run("returns stored and synthesized weeks in order", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+		_, err := service.UpdateItem(ctx, weekDate.AddDate(0, 0, 7), 0, 101, 30*time.Hour, "Short week")
+		require.NoError(t, err)
+
+		// Wednesday to Tuesday spans four weeks
+		plans, err := service.GetPlansForRange(ctx, weekDate.AddDate(0, 0, 2), weekDate.AddDate(0, 0, 22))
+		require.NoError(t, err)
+
+		require.Len(t, plans, 4)
+		for i, weekPlan := range plans {
+			assert.Equal(t, WeekNumberFromDate(weekDate.AddDate(0, 0, 7*i), time.Monday), weekPlan.WeekNumber)
+			assert.Equal(t, 1, weekPlan.BudgetPlanId)
+			require.Len(t, weekPlan.Items, 2)
+		}
+		assert.Equal(t, 40*time.Hour, plans[0].Items[0].WeeklyDuration)
+		assert.Equal(t, 30*time.Hour, plans[1].Items[0].WeeklyDuration)
+		assert.Equal(t, "Short week", plans[1].Items[0].Notes)
+		assert.Equal(t, 40*time.Hour, plans[2].Items[0].WeeklyDuration)
+	})
+
+	t.Run("returns a single week for dates of the same week", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+
+		plans, err := service.GetPlansForRange(ctx, weekDate, weekDate.AddDate(0, 0, 6))
+		require.NoError(t, err)
+
+		require.Len(t, plans, 1)
+		assert.Equal(t, WeekNumberFromDate(weekDate, time.Monday), plans[0].WeekNumber)
+	})
+
+	t.Run("rejects invalid ranges", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+
+		_, err := service.GetPlansForRange(ctx, weekDate, weekDate.AddDate(0, 0, -1))
+		assert.ErrorIs(t, err, ErrInvalidRange)
+
+		_, err = service.GetPlansForRange(ctx, weekDate, weekDate.AddDate(0, 0, 7*MaxRangeWeeks))
+		assert.ErrorIs(t, err, ErrRangeTooLong)
+
+		plans, err := service.GetPlansForRange(ctx, weekDate, weekDate.AddDate(0, 0, 7*(MaxRangeWeeks-1)))
+		require.NoError(t, err)
+		assert.Len(t, plans, MaxRangeWeeks)
+	})
+
+	t.Run("returns no current plan when a week has to be synthesized", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		_, err := service.GetPlansForRange(ctx, weekDate, weekDate.AddDate(0, 0, 7))
+		assert.ErrorIs(t, err, ErrNoCurrentPlan)
+	})
+}
+
+func TestServiceImpl_Absences(t *testing.T) {
+	weekDate := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	workdays := map[time.Weekday]time.Duration{
+		time.Monday: 8 * time.Hour, time.Tuesday: 8 * time.Hour, time.Wednesday: 8 * time.Hour,
+		time.Thursday: 8 * time.Hour, time.Friday: 8 * time.Hour,
+	}
+	plan := budget_plan.BudgetPlan{
+		Id:        1,
+		Name:      "My Plan",
+		IsCurrent: true,
+		Items: []budget_plan.BudgetItem{
+			{Id: 101, PlanId: 1, Name: "Work", WeeklyDuration: 40 * time.Hour, WeeklyOccurrences: 5, DailyDurations: workdays, Position: 100},
+			{Id: 102, PlanId: 1, Name: "Sport", WeeklyDuration: 7 * time.Hour, WeeklyOccurrences: 7, Position: 200},
+		},
+	}
+
+	t.Run("reduces the items by the absent days", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		absenceStub.AddAbsentDays(time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC))
+
+		weekPlan, err := service.GetPlanForWeek(ctx, weekDate)
+		require.NoError(t, err)
+
+		assert.Equal(t, []time.Weekday{time.Wednesday, time.Thursday}, weekPlan.AbsentDays)
+		work, sport := weekPlan.Items[0], weekPlan.Items[1]
+		assert.Equal(t, 24*time.Hour, work.WeeklyDuration)
+		assert.Equal(t, 16*time.Hour, work.AbsenceReduction)
+		assert.NotContains(t, work.DailyDurations, time.Wednesday)
+		assert.Equal(t, 8*time.Hour, work.DailyDurations[time.Friday])
+		assert.Equal(t, 5*time.Hour, sport.WeeklyDuration)
+		assert.Equal(t, 2*time.Hour, sport.AbsenceReduction)
+		assert.Equal(t, 5, sport.WeeklyOccurrences)
+		assert.Equal(t, 8*time.Hour, plan.Items[0].DailyDurations[time.Wednesday], "the budget plan is not changed")
+	})
+
+	t.Run("returns the planned durations besides the effective one", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		absenceStub.AddAbsentDays(time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC))
+
+		weekPlan, err := service.GetPlanForWeek(ctx, weekDate)
+		require.NoError(t, err)
+		work := WeeklyPlanItemToDTO(weekPlan.Items[0])
+		sport := WeeklyPlanItemToDTO(weekPlan.Items[1])
+
+		assert.Equal(t, int((40 * time.Hour).Seconds()), work.WeeklyDuration)
+		assert.Equal(t, int((24 * time.Hour).Seconds()), work.EffectiveWeeklyDuration)
+		assert.Equal(t, int((16 * time.Hour).Seconds()), work.AbsenceReduction)
+		assert.Equal(t, int((8 * time.Hour).Seconds()), work.DailyDurations["wednesday"])
+		assert.Equal(t, 5, work.WeeklyOccurrences)
+		assert.Equal(t, int((7 * time.Hour).Seconds()), sport.WeeklyDuration)
+		assert.Equal(t, int((5 * time.Hour).Seconds()), sport.EffectiveWeeklyDuration)
+		assert.Equal(t, 7, sport.WeeklyOccurrences)
+	})
+
+	t.Run("plans nothing for a week of absence", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		_, err := service.UpdateItem(ctx, weekDate, 0, 102, 10*time.Hour, "")
+		require.NoError(t, err)
+		absenceStub.AddAbsentDays(time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC))
+
+		weekPlan, err := service.GetPlanForWeek(ctx, weekDate)
+		require.NoError(t, err)
+
+		assert.Len(t, weekPlan.AbsentDays, 7)
+		for _, item := range weekPlan.Items {
+			assert.Zero(t, item.WeeklyDuration)
+			assert.Zero(t, item.WeeklyOccurrences)
+		}
+		assert.Equal(t, 10*time.Hour, weekPlan.Items[1].AbsenceReduction)
+	})
+
+	t.Run("reduces only the weeks of the absence in a range", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		absenceStub.AddAbsentDays(time.Date(2025, 1, 24, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 24, 0, 0, 0, 0, time.UTC))
+
+		plans, err := service.GetPlansForRange(ctx, weekDate, weekDate.AddDate(0, 0, 7))
+		require.NoError(t, err)
+
+		require.Len(t, plans, 2)
+		assert.Empty(t, plans[0].AbsentDays)
+		assert.Equal(t, 40*time.Hour, plans[0].Items[0].WeeklyDuration)
+		assert.Equal(t, []time.Weekday{time.Friday}, plans[1].AbsentDays)
+		assert.Equal(t, 32*time.Hour, plans[1].Items[0].WeeklyDuration)
+	})
+}
+
+func TestServiceImpl_SetItemDailyDurations(t *testing.T) {
+	weekDate := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	budgetDays := map[time.Weekday]time.Duration{time.Monday: 2 * time.Hour}
+	plan := budget_plan.BudgetPlan{
+		Id:        1,
+		Name:      "My Plan",
+		IsCurrent: true,
+		Items: []budget_plan.BudgetItem{
+			{Id: 101, PlanId: 1, Name: "Sport", WeeklyDuration: 5 * time.Hour, WeeklyOccurrences: 3, Position: 100,
+				DailyDurations: budgetDays},
+		},
+	}
+	weekDays := map[time.Weekday]time.Duration{
+		time.Monday:    2 * time.Hour,
+		time.Wednesday: 90 * time.Minute,
+		time.Saturday:  90 * time.Minute,
+	}
+
+	t.Run("sets daily durations summing up to the weekly duration", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+
+		item, err := service.SetItemDailyDurations(ctx, weekDate, 101, weekDays)
+
+		require.NoError(t, err)
+		assert.Equal(t, weekDays, item.DailyDurations)
+		assert.True(t, item.DailyDurationsCustomized)
+		nextWeek, err := service.GetItemsForWeek(ctx, weekDate.AddDate(0, 0, 7))
+		require.NoError(t, err)
+		assert.Equal(t, budgetDays, nextWeek[0].DailyDurations)
+	})
+
+	t.Run("rejects daily durations not summing up to the weekly duration", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+
+		_, err := service.SetItemDailyDurations(ctx, weekDate, 101, map[time.Weekday]time.Duration{time.Monday: 4 * time.Hour})
+		assert.ErrorIs(t, err, ErrInvalidDailyDurations)
+
+		_, err = service.SetItemDailyDurations(ctx, weekDate, 101, map[time.Weekday]time.Duration{
+			time.Monday:  -1 * time.Hour,
+			time.Tuesday: 6 * time.Hour,
+		})
+		assert.ErrorIs(t, err, ErrInvalidDailyDurations)
+	})
+
+	t.Run("keeps customized daily durations on budget item updates", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+		_, err := service.SetItemDailyDurations(ctx, weekDate, 101, weekDays)
+		require.NoError(t, err)
+
+		_, err = service.(*ServiceImpl).handleBudgetPlanItemUpdated(ctx, event_bus.BudgetPlanItemUpdated{
+			Id:             101,
+			Name:           "Gym",
+			DailyDurations: map[time.Weekday]time.Duration{time.Friday: time.Hour},
+		})
+		require.NoError(t, err)
+
+		items, err := service.GetItemsForWeek(ctx, weekDate)
+		require.NoError(t, err)
+		assert.Equal(t, "Gym", items[0].Name)
+		assert.Equal(t, weekDays, items[0].DailyDurations)
+	})
+
+	t.Run("drops customized daily durations when the weekly duration changes", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+		item, err := service.SetItemDailyDurations(ctx, weekDate, 101, weekDays)
+		require.NoError(t, err)
+
+		updated, err := service.UpdateItem(ctx, weekDate, item.Id, 101, 5*time.Hour, "same duration")
+		require.NoError(t, err)
+		assert.True(t, updated.DailyDurationsCustomized)
+
+		updated, err = service.UpdateItem(ctx, weekDate, item.Id, 101, 6*time.Hour, "longer")
+		require.NoError(t, err)
+		assert.False(t, updated.DailyDurationsCustomized)
+		assert.Empty(t, updated.DailyDurations)
+	})
+
+	t.Run("restores the budget item daily durations", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+		item, err := service.SetItemDailyDurations(ctx, weekDate, 101, weekDays)
+		require.NoError(t, err)
+
+		restored, err := service.SetItemDailyDurations(ctx, weekDate, 101, nil)
+		require.NoError(t, err)
+		assert.False(t, restored.DailyDurationsCustomized)
+		assert.Equal(t, budgetDays, restored.DailyDurations)
+
+		_, err = service.SetItemDailyDurations(ctx, weekDate, 101, weekDays)
+		require.NoError(t, err)
+		reset, err := service.ResetWeekItemToBudgetPlanItem(ctx, item.Id)
+		require.NoError(t, err)
+		assert.False(t, reset.DailyDurationsCustomized)
+		assert.Equal(t, budgetDays, reset.DailyDurations)
+	})
+}
+
+func TestServiceImpl_GenerateUpcomingWeeks(t *testing.T) {
+	plan := budget_plan.BudgetPlan{
+		Id:        1,
+		Name:      "My Plan",
+		IsCurrent: true,
+		Items: []budget_plan.BudgetItem{
+			{Id: 101, PlanId: 1, Name: "Work", WeeklyDuration: 40 * time.Hour, Position: 0},
+			{Id: 102, PlanId: 1, Name: "Exercise", WeeklyDuration: 5 * time.Hour, Position: 1},
+		},
+	}
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	t.Run("should generate the weeks without items and publish an event for each", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+		_, err := service.UpdateItem(ctx, time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC), 0, 101, 30*time.Hour, "")
+		require.NoError(t, err)
+		var published []event_bus.WeeklyPlanWeekGenerated
+		unsubscribe := event_bus.SubscribeTyped[event_bus.WeeklyPlanWeekGenerated](eventBus, "weekly_plan.week.generated",
+			func(e event_bus.EventT[event_bus.WeeklyPlanWeekGenerated]) error {
+				published = append(published, e.Data)
+				return nil
+			})
+		defer unsubscribe()
+
+		generated, err := service.GenerateUpcomingWeeks(ctx, now, 2)
+
+		require.NoError(t, err)
+		assert.Equal(t, []WeekNumber{{Year: 2025, Week: 3}, {Year: 2025, Week: 5}}, generated)
+		require.Len(t, published, 2)
+		assert.Equal(t, "2025-W05", published[1].Week)
+		assert.Equal(t, 2, published[1].ItemCount)
+		assert.Equal(t, 1, published[1].BudgetPlanId)
+		items, err := service.GetItemsForWeek(ctx, time.Date(2025, 1, 27, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+		assert.NotZero(t, items[0].Id)
+		// The customized week is left as it was
+		items, err = service.GetItemsForWeek(ctx, time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		assert.Equal(t, 30*time.Hour, items[0].WeeklyDuration)
+	})
+
+	t.Run("should not generate already generated weeks again", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+		_, err := service.GenerateUpcomingWeeks(ctx, now, 1)
+		require.NoError(t, err)
+
+		generated, err := service.GenerateUpcomingWeeks(ctx, now, 1)
+
+		require.NoError(t, err)
+		assert.Empty(t, generated)
+	})
+
+	t.Run("should generate again upcoming weeks no longer matching the current plan", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+		bpReaderStub.SetCurrentPlan(plan)
+		bpReaderStub.SetPlan(plan)
+		_, err := service.GenerateUpcomingWeeks(ctx, now, 2)
+		require.NoError(t, err)
+		changedWeek := time.Date(2025, 1, 27, 0, 0, 0, 0, time.UTC)
+		changedItems, err := service.GetItemsForWeek(ctx, changedWeek)
+		require.NoError(t, err)
+		_, err = service.UpdateItem(ctx, changedWeek, changedItems[0].Id, 101, 30*time.Hour, "")
+		require.NoError(t, err)
+		extended := plan
+		extended.Items = append(slices.Clone(plan.Items),
+			budget_plan.BudgetItem{Id: 103, PlanId: 1, Name: "Reading", WeeklyDuration: 3 * time.Hour, Position: 2})
+		bpReaderStub.SetCurrentPlan(extended)
+		bpReaderStub.SetPlan(extended)
+
+		generated, err := service.GenerateUpcomingWeeks(ctx, now, 2)
+
+		require.NoError(t, err)
+		// The current week has started and the week after the next one was changed by the user
+		assert.Equal(t, []WeekNumber{{Year: 2025, Week: 4}}, generated)
+		items, err := service.GetItemsForWeek(ctx, time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		assert.Len(t, items, 3)
+		items, err = service.GetItemsForWeek(ctx, changedWeek)
+		require.NoError(t, err)
+		assert.Len(t, items, 2)
+	})
+
+	t.Run("should fail without a current plan", func(t *testing.T) {
+		teardown := setup(t)
+		defer teardown()
+
+		_, err := service.GenerateUpcomingWeeks(ctx, now, 1)
+
+		assert.ErrorIs(t, err, ErrNoCurrentPlan)
+	})
+}
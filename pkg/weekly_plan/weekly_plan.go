@@ -13,11 +13,21 @@ type WeeklyPlan struct {
 	BudgetPlanId int
 	WeekNumber   WeekNumber
 	IsOffWeek    bool
-	Items        []WeeklyPlanItem
+	// LockedAt is set when the user locked the week, its plan and events are then read-only.
+	LockedAt *time.Time
+	// AbsentDays are the weekdays the user is absent on, the items' durations are already reduced by them
+	AbsentDays []time.Weekday
+	Items      []WeeklyPlanItem
+}
+
+// IsLocked reports whether changes to the week's plan and events are rejected.
+func (p WeeklyPlan) IsLocked() bool {
+	return p.LockedAt != nil
 }
 
 type WeeklyPlanItem struct {
-	Id           int
+	Id int
+	// BudgetItemId is negative for ad-hoc items added to a single week, which have no budget item.
 	BudgetItemId int
 	BudgetPlanId int
 	WeekNumber   WeekNumber
@@ -25,11 +35,38 @@ type WeeklyPlanItem struct {
 	// WeeklyDuration represents the total time allocated weekly for a budget, specified as a duration.
 	WeeklyDuration time.Duration // updatable - independent copy
 	// WeeklyOccurrences represents the number of days in a week that a budget is expected to be used.
-	WeeklyOccurrences int    // immutable - created and never updated
-	Icon              string // copy - as long as BudgetItem exist, updated with value from there
-	Color             string // copy - as long as BudgetItem exist, updated with value from there
-	Notes             string // updatable - independent - does not exist on BudgetItem
-	Position          int    // copy - as long as BudgetItem exist, updated with value from there
+	WeeklyOccurrences int // immutable - created and never updated
+	// DailyDurations optionally distributes the weekly duration across weekdays.
+	DailyDurations map[time.Weekday]time.Duration // copy - as long as BudgetItem exist and the week does not customize it
+	Icon           string                         // copy - as long as BudgetItem exist, updated with value from there
+	Color          string                         // copy - as long as BudgetItem exist, updated with value from there
+	Notes          string                         // updatable - independent - does not exist on BudgetItem
+	Position       int                            // copy - updatable per week, restored by a week reset
+	// CarriedOver records the time rolled over from the previous week and already included in WeeklyDuration
+	// (negative when the previous week was overspent). Nil when no rollover was applied to the item.
+	CarriedOver *time.Duration
+	// DailyDurationsCustomized is set when the week's daily durations were edited, they then always sum up to
+	// WeeklyDuration and are no longer updated from the BudgetItem.
+	DailyDurationsCustomized bool
+	// AbsenceReduction is the time taken off WeeklyDuration for the absent days of the week, it is not stored.
+	AbsenceReduction time.Duration
+	// Unreduced is the item before the absent days of the week reduced it, nil when the week has none.
+	Unreduced *WeeklyPlanItem
+}
+
+// IsAdHoc reports whether the item was added to the week only and is not backed by a budget item.
+func (i WeeklyPlanItem) IsAdHoc() bool {
+	return i.BudgetItemId < 0
+}
+
+// CategoryTotal is the time planned in a week for all items of a budget plan category.
+// Uncategorized items are summed up under CategoryId 0.
+type CategoryTotal struct {
+	CategoryId     int
+	Name           string
+	Color          string
+	WeeklyDuration time.Duration
+	BudgetItemIds  []int
 }
 
 type WeekNumber struct {
@@ -90,7 +127,45 @@ func (w WeekNumber) After(other WeekNumber) bool {
 	return w.Week > other.Week
 }
 
+// FirstDay returns the date (midnight UTC) the week starts on, given the user's week start day.
+// It is the inverse of WeekNumberFromDate.
+func (w WeekNumber) FirstDay(weekStartDay time.Weekday) time.Time {
+	if weekStartDay < time.Sunday || weekStartDay > time.Saturday {
+		weekStartDay = time.Monday
+	}
+	// January 4th is always in the first ISO week
+	jan4 := time.Date(w.Year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	isoMonday := jan4.AddDate(0, 0, -((int(jan4.Weekday())+6)%7)+(w.Week-1)*7)
+	return isoMonday.AddDate(0, 0, (int(weekStartDay)-int(time.Monday)+7)%7)
+}
+
 // String returns the ISO week format ISO 8601 e.g. "2025-W03"
 func (w WeekNumber) String() string {
 	return fmt.Sprintf("%04d-W%02d", w.Year, w.Week)
 }
+
+// PreviewChange describes how a previewed weekly plan item differs from the previous week.
+type PreviewChange string
+
+const (
+	PreviewUnchanged       PreviewChange = "unchanged"
+	PreviewAdded           PreviewChange = "added"
+	PreviewDurationChanged PreviewChange = "duration_changed"
+)
+
+// WeekPreview is a non-persisted weekly plan generated from the current budget plan.
+type WeekPreview struct {
+	WeekNumber   WeekNumber
+	BudgetPlanId int
+	// IsGenerated is true when the week already has persisted items, so the preview will not be applied.
+	IsGenerated bool
+	Items       []PreviewItem
+	// RemovedItems are items of the previous week that are not in the current budget plan anymore.
+	RemovedItems []WeeklyPlanItem
+}
+
+type PreviewItem struct {
+	Item                   WeeklyPlanItem
+	Change                 PreviewChange
+	PreviousWeeklyDuration time.Duration
+}
@@ -130,3 +130,31 @@ func TestWeekNumberFromDate(t *testing.T) {
 		})
 	}
 }
+
+func TestWeekNumberFirstDay(t *testing.T) {
+	tests := []struct {
+		name         string
+		week         WeekNumber
+		weekStartDay time.Weekday
+		expect       time.Time
+	}{
+		{"monday start", WeekNumber{Year: 2025, Week: 3}, time.Monday, time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)},
+		{"sunday start", WeekNumber{Year: 2025, Week: 3}, time.Sunday, time.Date(2025, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{"saturday start", WeekNumber{Year: 2025, Week: 3}, time.Saturday, time.Date(2025, 1, 18, 0, 0, 0, 0, time.UTC)},
+		{"first week starting in previous year", WeekNumber{Year: 2025, Week: 1}, time.Monday, time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC)},
+		{"53rd week", WeekNumber{Year: 2020, Week: 53}, time.Monday, time.Date(2020, 12, 28, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.week.FirstDay(tt.weekStartDay)
+			if !got.Equal(tt.expect) {
+				t.Fatalf("FirstDay(%v) = %v, want %v", tt.weekStartDay, got, tt.expect)
+			}
+			if back := WeekNumberFromDate(got, tt.weekStartDay); back != tt.week {
+				t.Fatalf("WeekNumberFromDate(%v) = %v, want %v", got, back, tt.week)
+			}
+		})
+	}
+}
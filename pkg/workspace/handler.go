@@ -0,0 +1,813 @@
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/klokku/klokku/internal/rest"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+)
+
+type WorkspaceDTO struct {
+	Id        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Role of the current user
+	Role string `json:"role" enums:"owner,editor,viewer"`
+}
+
+type WorkspaceRequestDTO struct {
+	Name string `json:"name"`
+}
+
+type MemberDTO struct {
+	UserUid     string    `json:"userUid"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"displayName"`
+	Role        string    `json:"role" enums:"owner,editor,viewer"`
+	JoinedAt    time.Time `json:"joinedAt"`
+}
+
+type MemberRequestDTO struct {
+	UserUid string `json:"userUid"`
+	Role    string `json:"role" enums:"owner,editor,viewer"`
+}
+
+type InvitationDTO struct {
+	Id            int       `json:"id"`
+	WorkspaceId   int       `json:"workspaceId"`
+	WorkspaceName string    `json:"workspaceName"`
+	Role          string    `json:"role" enums:"owner,editor,viewer"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type RoleRequestDTO struct {
+	Role string `json:"role" enums:"owner,editor,viewer"`
+}
+
+type SharePlanRequestDTO struct {
+	PlanId int `json:"planId"`
+}
+
+type SharedPlanDTO struct {
+	Plan          budget_plan.BudgetPlanDTO `json:"plan"`
+	OwnerUid      string                    `json:"ownerUid"`
+	OwnerUsername string                    `json:"ownerUsername"`
+	SharedAt      time.Time                 `json:"sharedAt"`
+}
+
+type MemberEventDTO struct {
+	UID          string    `json:"uid"`
+	Summary      string    `json:"summary"`
+	StartTime    time.Time `json:"start"`
+	EndTime      time.Time `json:"end"`
+	BudgetItemId int       `json:"budgetItemId"`
+}
+
+type MemberCalendarDTO struct {
+	UserUid     string           `json:"userUid"`
+	DisplayName string           `json:"displayName"`
+	Events      []MemberEventDTO `json:"events"`
+}
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// ListWorkspaces godoc
+// @Summary List workspaces
+// @Description List the workspaces the current user is a member of
+// @Tags Workspace
+// @Produce json
+// @Success 200 {array} WorkspaceDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/workspaces [get]
+// @Security XUserId
+func (h *Handler) ListWorkspaces(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	workspaces, err := h.service.GetWorkspaces(r.Context())
+	if err != nil {
+		log.Errorf("Failed to list workspaces: %v", err)
+		http.Error(w, "Failed to list workspaces", http.StatusInternalServerError)
+		return
+	}
+	dtos := make([]WorkspaceDTO, 0, len(workspaces))
+	for _, workspace := range workspaces {
+		dtos = append(dtos, workspaceToDTO(workspace))
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+// CreateWorkspace godoc
+// @Summary Create a workspace
+// @Description Create a workspace (e.g. a household) to share budget plans and calendars with other users.
+// @Description The current user becomes its owner.
+// @Tags Workspace
+// @Accept json
+// @Produce json
+// @Param workspace body WorkspaceRequestDTO true "Workspace"
+// @Success 201 {object} WorkspaceDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid workspace"
+// @Failure 403 {string} string "User not found"
+// @Router /api/workspaces [post]
+// @Security XUserId
+func (h *Handler) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var body WorkspaceRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	workspace, err := h.service.CreateWorkspace(r.Context(), body.Name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, workspaceToDTO(workspace))
+}
+
+// GetWorkspace godoc
+// @Summary Get a workspace
+// @Tags Workspace
+// @Produce json
+// @Param workspaceId path int true "Workspace ID"
+// @Success 200 {object} WorkspaceDTO
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Workspace not found"
+// @Router /api/workspaces/{workspaceId} [get]
+// @Security XUserId
+func (h *Handler) GetWorkspace(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	workspaceId, err := strconv.Atoi(mux.Vars(r)["workspaceId"])
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+	workspace, err := h.service.GetWorkspace(r.Context(), workspaceId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, workspaceToDTO(workspace))
+}
+
+// RenameWorkspace godoc
+// @Summary Rename a workspace
+// @Description Rename the workspace, allowed to its owners
+// @Tags Workspace
+// @Accept json
+// @Produce json
+// @Param workspaceId path int true "Workspace ID"
+// @Param workspace body WorkspaceRequestDTO true "Workspace"
+// @Success 200 {object} WorkspaceDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid workspace"
+// @Failure 403 {string} string "User not found or not an owner"
+// @Failure 404 {string} string "Workspace not found"
+// @Router /api/workspaces/{workspaceId} [put]
+// @Security XUserId
+func (h *Handler) RenameWorkspace(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	workspaceId, err := strconv.Atoi(mux.Vars(r)["workspaceId"])
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+	var body WorkspaceRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	workspace, err := h.service.RenameWorkspace(r.Context(), workspaceId, body.Name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, workspaceToDTO(workspace))
+}
+
+// DeleteWorkspace godoc
+// @Summary Delete a workspace
+// @Description Delete the workspace, allowed to its owners. Shared budget plans stay with the members who own them.
+// @Tags Workspace
+// @Param workspaceId path int true "Workspace ID"
+// @Success 204 "No Content"
+// @Failure 403 {string} string "User not found or not an owner"
+// @Failure 404 {string} string "Workspace not found"
+// @Router /api/workspaces/{workspaceId} [delete]
+// @Security XUserId
+func (h *Handler) DeleteWorkspace(w http.ResponseWriter, r *http.Request) {
+	workspaceId, err := strconv.Atoi(mux.Vars(r)["workspaceId"])
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.DeleteWorkspace(r.Context(), workspaceId); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListMembers godoc
+// @Summary List workspace members
+// @Tags Workspace
+// @Produce json
+// @Param workspaceId path int true "Workspace ID"
+// @Success 200 {array} MemberDTO
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Workspace not found"
+// @Router /api/workspaces/{workspaceId}/members [get]
+// @Security XUserId
+func (h *Handler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	workspaceId, err := strconv.Atoi(mux.Vars(r)["workspaceId"])
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+	members, err := h.service.GetMembers(r.Context(), workspaceId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	dtos := make([]MemberDTO, 0, len(members))
+	for _, member := range members {
+		dtos = append(dtos, memberToDTO(member))
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+// InviteMember godoc
+// @Summary Invite a user to the workspace
+// @Description Invite a user to join the workspace with the given role, allowed to its owners. The user becomes
+// @Description a member once they accept the invitation. Inviting a user again changes the role of the invitation.
+// @Tags Workspace
+// @Accept json
+// @Produce json
+// @Param workspaceId path int true "Workspace ID"
+// @Param member body MemberRequestDTO true "Invited user"
+// @Success 201 {object} InvitationDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid role"
+// @Failure 403 {string} string "User not found or not an owner"
+// @Failure 404 {string} string "Workspace or user not found"
+// @Failure 409 {string} string "User is already a member"
+// @Router /api/workspaces/{workspaceId}/invitations [post]
+// @Security XUserId
+func (h *Handler) InviteMember(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	workspaceId, err := strconv.Atoi(mux.Vars(r)["workspaceId"])
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+	var body MemberRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	invitation, err := h.service.InviteMember(r.Context(), workspaceId, body.UserUid, Role(body.Role))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, invitationToDTO(invitation))
+}
+
+// ListInvitations godoc
+// @Summary List workspace invitations
+// @Description List the pending workspace invitations of the current user
+// @Tags Workspace
+// @Produce json
+// @Success 200 {array} InvitationDTO
+// @Failure 403 {string} string "User not found"
+// @Router /api/workspaces/invitations [get]
+// @Security XUserId
+func (h *Handler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	invitations, err := h.service.GetInvitations(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	dtos := make([]InvitationDTO, 0, len(invitations))
+	for _, invitation := range invitations {
+		dtos = append(dtos, invitationToDTO(invitation))
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+// AcceptInvitation godoc
+// @Summary Accept a workspace invitation
+// @Description Join the workspace of the invitation with its role
+// @Tags Workspace
+// @Produce json
+// @Param invitationId path int true "Invitation ID"
+// @Success 200 {object} WorkspaceDTO
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Invitation not found"
+// @Failure 409 {string} string "User is already a member"
+// @Router /api/workspaces/invitations/{invitationId}/accept [post]
+// @Security XUserId
+func (h *Handler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	invitationId, err := strconv.Atoi(mux.Vars(r)["invitationId"])
+	if err != nil {
+		writeBadRequest(w, "Invalid invitation ID", err.Error())
+		return
+	}
+	workspace, err := h.service.AcceptInvitation(r.Context(), invitationId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, workspaceToDTO(workspace))
+}
+
+// DeclineInvitation godoc
+// @Summary Decline a workspace invitation
+// @Description Decline the invitation, the workspace owners can invite the user again
+// @Tags Workspace
+// @Param invitationId path int true "Invitation ID"
+// @Success 204 "No Content"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Invitation not found"
+// @Router /api/workspaces/invitations/{invitationId} [delete]
+// @Security XUserId
+func (h *Handler) DeclineInvitation(w http.ResponseWriter, r *http.Request) {
+	invitationId, err := strconv.Atoi(mux.Vars(r)["invitationId"])
+	if err != nil {
+		writeBadRequest(w, "Invalid invitation ID", err.Error())
+		return
+	}
+	if err := h.service.DeclineInvitation(r.Context(), invitationId); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateMemberRole godoc
+// @Summary Change the role of a workspace member
+// @Description Change the role of a member, allowed to owners. The last owner cannot give up the role.
+// @Tags Workspace
+// @Accept json
+// @Param workspaceId path int true "Workspace ID"
+// @Param userUid path string true "User UID"
+// @Param role body RoleRequestDTO true "Role"
+// @Success 204 "No Content"
+// @Failure 400 {object} rest.ErrorResponse "Invalid role"
+// @Failure 403 {string} string "User not found or not an owner"
+// @Failure 404 {string} string "Workspace or member not found"
+// @Failure 409 {string} string "Last owner"
+// @Router /api/workspaces/{workspaceId}/members/{userUid} [put]
+// @Security XUserId
+func (h *Handler) UpdateMemberRole(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	workspaceId, err := strconv.Atoi(vars["workspaceId"])
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+	var body RoleRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeBadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	if err := h.service.UpdateMemberRole(r.Context(), workspaceId, vars["userUid"], Role(body.Role)); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveMember godoc
+// @Summary Remove a workspace member
+// @Description Remove a member from the workspace, owners remove anyone and every member can remove themselves.
+// @Description Plans the member shared are no longer shared with the workspace.
+// @Tags Workspace
+// @Param workspaceId path int true "Workspace ID"
+// @Param userUid path string true "User UID"
+// @Success 204 "No Content"
+// @Failure 403 {string} string "User not found or not an owner"
+// @Failure 404 {string} string "Workspace or member not found"
+// @Failure 409 {string} string "Last owner"
+// @Router /api/workspaces/{workspaceId}/members/{userUid} [delete]
+// @Security XUserId
+func (h *Handler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	workspaceId, err := strconv.Atoi(vars["workspaceId"])
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.RemoveMember(r.Context(), workspaceId, vars["userUid"]); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListSharedPlans godoc
+// @Summary List shared budget plans
+// @Description List the budget plans members shared with the workspace
+// @Tags Workspace
+// @Produce json
+// @Param workspaceId path int true "Workspace ID"
+// @Success 200 {array} SharedPlanDTO
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Workspace not found"
+// @Router /api/workspaces/{workspaceId}/budgetplans [get]
+// @Security XUserId
+func (h *Handler) ListSharedPlans(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	workspaceId, err := strconv.Atoi(mux.Vars(r)["workspaceId"])
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+	plans, err := h.service.GetSharedPlans(r.Context(), workspaceId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	dtos := make([]SharedPlanDTO, 0, len(plans))
+	for _, plan := range plans {
+		dtos = append(dtos, sharedPlanToDTO(plan))
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+// SharePlan godoc
+// @Summary Share a budget plan
+// @Description Share a budget plan of the current user with the workspace, allowed to owners and editors.
+// @Description Editors of the workspace can change items of the plan.
+// @Tags Workspace
+// @Accept json
+// @Param workspaceId path int true "Workspace ID"
+// @Param plan body SharePlanRequestDTO true "Plan"
+// @Success 204 "No Content"
+// @Failure 403 {string} string "User not found or a viewer"
+// @Failure 404 {string} string "Workspace or plan not found"
+// @Router /api/workspaces/{workspaceId}/budgetplans [post]
+// @Security XUserId
+func (h *Handler) SharePlan(w http.ResponseWriter, r *http.Request) {
+	workspaceId, err := strconv.Atoi(mux.Vars(r)["workspaceId"])
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+	var body SharePlanRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		writeBadRequest(w, "Invalid request body", err.Error())
+		return
+	}
+	if err := h.service.SharePlan(r.Context(), workspaceId, body.PlanId); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSharedPlan godoc
+// @Summary Get a shared budget plan
+// @Tags Workspace
+// @Produce json
+// @Param workspaceId path int true "Workspace ID"
+// @Param planId path int true "Budget Plan ID"
+// @Success 200 {object} SharedPlanDTO
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Workspace not found or plan not shared"
+// @Router /api/workspaces/{workspaceId}/budgetplans/{planId} [get]
+// @Security XUserId
+func (h *Handler) GetSharedPlan(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	workspaceId, planId, err := workspacePlanIds(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	plan, err := h.service.GetSharedPlan(r.Context(), workspaceId, planId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sharedPlanToDTO(plan))
+}
+
+// UnsharePlan godoc
+// @Summary Stop sharing a budget plan
+// @Description Stop sharing the plan with the workspace, allowed to the member who shared it and to owners
+// @Tags Workspace
+// @Param workspaceId path int true "Workspace ID"
+// @Param planId path int true "Budget Plan ID"
+// @Success 204 "No Content"
+// @Failure 403 {string} string "User not found or not allowed"
+// @Failure 404 {string} string "Workspace not found or plan not shared"
+// @Router /api/workspaces/{workspaceId}/budgetplans/{planId} [delete]
+// @Security XUserId
+func (h *Handler) UnsharePlan(w http.ResponseWriter, r *http.Request) {
+	workspaceId, planId, err := workspacePlanIds(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.service.UnsharePlan(r.Context(), workspaceId, planId); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateSharedItem godoc
+// @Summary Add an item to a shared budget plan
+// @Description Add a budget item to a plan shared with the workspace, allowed to owners and editors
+// @Tags Workspace
+// @Accept json
+// @Produce json
+// @Param workspaceId path int true "Workspace ID"
+// @Param planId path int true "Budget Plan ID"
+// @Param item body budget_plan.ItemDTO true "Budget Item"
+// @Success 201 {object} budget_plan.ItemDTO
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "User not found or a viewer"
+// @Failure 404 {string} string "Workspace not found or plan not shared"
+// @Router /api/workspaces/{workspaceId}/budgetplans/{planId}/item [post]
+// @Security XUserId
+func (h *Handler) CreateSharedItem(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	workspaceId, planId, err := workspacePlanIds(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var itemDTO budget_plan.ItemDTO
+	if err := json.NewDecoder(r.Body).Decode(&itemDTO); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := budget_plan.ValidateDailyDurationsDTO(itemDTO.DailyDurations); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	item, err := h.service.CreateSharedItem(r.Context(), workspaceId, budget_plan.DTOToItem(planId, itemDTO))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, budget_plan.ItemToDTO(item))
+}
+
+// UpdateSharedItem godoc
+// @Summary Update an item of a shared budget plan
+// @Description Update a budget item of a plan shared with the workspace, allowed to owners and editors
+// @Tags Workspace
+// @Accept json
+// @Produce json
+// @Param workspaceId path int true "Workspace ID"
+// @Param planId path int true "Budget Plan ID"
+// @Param itemId path int true "Budget Item ID"
+// @Param item body budget_plan.ItemDTO true "Budget Item"
+// @Success 200 {object} budget_plan.ItemDTO
+// @Failure 400 {string} string "Bad Request"
+// @Failure 403 {string} string "User not found or a viewer"
+// @Failure 404 {string} string "Workspace, plan or item not found"
+// @Router /api/workspaces/{workspaceId}/budgetplans/{planId}/item/{itemId} [put]
+// @Security XUserId
+func (h *Handler) UpdateSharedItem(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	workspaceId, planId, err := workspacePlanIds(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	itemId, err := strconv.Atoi(mux.Vars(r)["itemId"])
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+	var itemDTO budget_plan.ItemDTO
+	if err := json.NewDecoder(r.Body).Decode(&itemDTO); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if itemDTO.ID == 0 || itemDTO.ID != itemId {
+		http.Error(w, "Invalid item id in request body", http.StatusBadRequest)
+		return
+	}
+	if err := budget_plan.ValidateDailyDurationsDTO(itemDTO.DailyDurations); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	item, err := h.service.UpdateSharedItem(r.Context(), workspaceId, budget_plan.DTOToItem(planId, itemDTO))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, budget_plan.ItemToDTO(item))
+}
+
+// DeleteSharedItem godoc
+// @Summary Delete an item of a shared budget plan
+// @Description Remove a budget item from a plan shared with the workspace, allowed to owners and editors
+// @Tags Workspace
+// @Param workspaceId path int true "Workspace ID"
+// @Param planId path int true "Budget Plan ID"
+// @Param itemId path int true "Budget Item ID"
+// @Success 204 "No Content"
+// @Failure 403 {string} string "User not found or a viewer"
+// @Failure 404 {string} string "Workspace, plan or item not found"
+// @Failure 409 {string} string "Item is tracked by the current event"
+// @Router /api/workspaces/{workspaceId}/budgetplans/{planId}/item/{itemId} [delete]
+// @Security XUserId
+func (h *Handler) DeleteSharedItem(w http.ResponseWriter, r *http.Request) {
+	workspaceId, planId, err := workspacePlanIds(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	itemId, err := strconv.Atoi(mux.Vars(r)["itemId"])
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.DeleteSharedItem(r.Context(), workspaceId, planId, itemId); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetCalendar godoc
+// @Summary Get the workspace calendar
+// @Description Get the calendar events of every workspace member in the given time range, at most 31 days long
+// @Tags Workspace
+// @Produce json
+// @Param workspaceId path int true "Workspace ID"
+// @Param from query string true "Start date in RFC3339 format"
+// @Param to query string true "End date in RFC3339 format"
+// @Success 200 {array} MemberCalendarDTO
+// @Failure 400 {object} rest.ErrorResponse "Invalid time range"
+// @Failure 403 {string} string "User not found"
+// @Failure 404 {string} string "Workspace not found"
+// @Router /api/workspaces/{workspaceId}/calendar [get]
+// @Security XUserId
+func (h *Handler) GetCalendar(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	workspaceId, err := strconv.Atoi(mux.Vars(r)["workspaceId"])
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+	from, err := time.Parse(time.RFC3339, r.URL.Query().Get("from"))
+	if err != nil {
+		writeBadRequest(w, "Invalid 'from' date format", err.Error())
+		return
+	}
+	to, err := time.Parse(time.RFC3339, r.URL.Query().Get("to"))
+	if err != nil {
+		writeBadRequest(w, "Invalid 'to' date format", err.Error())
+		return
+	}
+	if !to.After(from) {
+		writeBadRequest(w, "Invalid time range", "'to' must be after 'from'")
+		return
+	}
+
+	calendars, err := h.service.GetCalendar(r.Context(), workspaceId, from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	dtos := make([]MemberCalendarDTO, 0, len(calendars))
+	for _, memberEvents := range calendars {
+		events := make([]MemberEventDTO, 0, len(memberEvents.Events))
+		for _, event := range memberEvents.Events {
+			events = append(events, MemberEventDTO{
+				UID:          event.UID,
+				Summary:      event.Summary,
+				StartTime:    event.StartTime,
+				EndTime:      event.EndTime,
+				BudgetItemId: event.Metadata.BudgetItemId,
+			})
+		}
+		dtos = append(dtos, MemberCalendarDTO{
+			UserUid:     memberEvents.Member.Uid,
+			DisplayName: memberEvents.Member.DisplayName,
+			Events:      events,
+		})
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+func workspacePlanIds(r *http.Request) (int, int, error) {
+	vars := mux.Vars(r)
+	workspaceId, err := strconv.Atoi(vars["workspaceId"])
+	if err != nil {
+		return 0, 0, err
+	}
+	planId, err := strconv.Atoi(vars["planId"])
+	if err != nil {
+		return 0, 0, err
+	}
+	return workspaceId, planId, nil
+}
+
+func workspaceToDTO(workspace Workspace) WorkspaceDTO {
+	return WorkspaceDTO{
+		Id:        workspace.Id,
+		Name:      workspace.Name,
+		CreatedAt: workspace.CreatedAt,
+		Role:      string(workspace.Role),
+	}
+}
+
+func memberToDTO(member MemberUser) MemberDTO {
+	return MemberDTO{
+		UserUid:     member.User.Uid,
+		Username:    member.User.Username,
+		DisplayName: member.User.DisplayName,
+		Role:        string(member.Role),
+		JoinedAt:    member.JoinedAt,
+	}
+}
+
+func invitationToDTO(invitation Invitation) InvitationDTO {
+	return InvitationDTO{
+		Id:            invitation.Id,
+		WorkspaceId:   invitation.WorkspaceId,
+		WorkspaceName: invitation.WorkspaceName,
+		Role:          string(invitation.Role),
+		CreatedAt:     invitation.CreatedAt,
+	}
+}
+
+func sharedPlanToDTO(plan SharedPlan) SharedPlanDTO {
+	return SharedPlanDTO{
+		Plan:          budget_plan.PlanToDTO(plan.Plan),
+		OwnerUid:      plan.Owner.Uid,
+		OwnerUsername: plan.Owner.Username,
+		SharedAt:      plan.SharedAt,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidName), errors.Is(err, ErrInvalidRole), errors.Is(err, ErrInvalidRange):
+		writeBadRequest(w, err.Error(), "")
+	case errors.Is(err, budget_plan.ErrInvalidDailyDuration), errors.Is(err, budget_plan.ErrCategoryNotFound),
+		errors.Is(err, budget_plan.ErrInvalidParentItem), errors.Is(err, budget_plan.ErrInvalidItemDates),
+		errors.Is(err, budget_plan.ErrInvalidItemUnit), errors.Is(err, budget_plan.ErrInvalidItemColor):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrWorkspaceNotFound), errors.Is(err, ErrMemberNotFound), errors.Is(err, ErrPlanNotShared),
+		errors.Is(err, ErrInvitationNotFound),
+		errors.Is(err, user.ErrUserNotFound), errors.Is(err, budget_plan.ErrPlanNotFound),
+		errors.Is(err, budget_plan.ErrBudgetPlanItemNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrAlreadyMember), errors.Is(err, ErrLastOwner), errors.Is(err, budget_plan.ErrItemTracked):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Errorf("Workspace request failed: %v", err)
+		http.Error(w, "Workspace request failed", http.StatusInternalServerError)
+	}
+}
+
+func writeBadRequest(w http.ResponseWriter, errMsg, details string) {
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(rest.ErrorResponse{
+		Error:   errMsg,
+		Details: details,
+	})
+}
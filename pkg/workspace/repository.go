@@ -0,0 +1,332 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrWorkspaceNotFound = errors.New("workspace not found")
+var ErrMemberNotFound = errors.New("member not found")
+var ErrAlreadyMember = errors.New("user is already a member of the workspace")
+var ErrPlanNotShared = errors.New("plan is not shared with the workspace")
+var ErrInvitationNotFound = errors.New("invitation not found")
+
+type Repository interface {
+	// CreateWorkspace creates the workspace with the user as its owner.
+	CreateWorkspace(ctx context.Context, name string, ownerId int) (Workspace, error)
+	// GetWorkspaces returns the workspaces the user is a member of, with the user's role.
+	GetWorkspaces(ctx context.Context, userId int) ([]Workspace, error)
+	// GetWorkspace returns ErrWorkspaceNotFound when the user is not a member of the workspace.
+	GetWorkspace(ctx context.Context, userId, workspaceId int) (Workspace, error)
+	RenameWorkspace(ctx context.Context, workspaceId int, name string) error
+	DeleteWorkspace(ctx context.Context, workspaceId int) error
+	GetMembers(ctx context.Context, workspaceId int) ([]Member, error)
+	// CreateInvitation invites the user, replacing the role of a pending invitation. It returns ErrAlreadyMember
+	// when the user is a member already.
+	CreateInvitation(ctx context.Context, invitation Invitation) (Invitation, error)
+	// GetUserInvitations returns the pending invitations of the user.
+	GetUserInvitations(ctx context.Context, userId int) ([]Invitation, error)
+	// AcceptInvitation makes the user a member and removes the invitation. It returns ErrInvitationNotFound
+	// when the user has no such invitation.
+	AcceptInvitation(ctx context.Context, userId, invitationId int) (Member, error)
+	// DeleteInvitation returns ErrInvitationNotFound when the user has no such invitation.
+	DeleteInvitation(ctx context.Context, userId, invitationId int) error
+	UpdateMemberRole(ctx context.Context, workspaceId, userId int, role Role) error
+	// RemoveMember removes the member together with the plans they shared with the workspace.
+	RemoveMember(ctx context.Context, workspaceId, userId int) error
+	// SharePlan does nothing when the plan is shared already.
+	SharePlan(ctx context.Context, ref SharedPlanRef) error
+	UnsharePlan(ctx context.Context, workspaceId, planId int) error
+	GetSharedPlans(ctx context.Context, workspaceId int) ([]SharedPlanRef, error)
+	// GetSharedPlan returns ErrPlanNotShared when the plan is not shared with the workspace.
+	GetSharedPlan(ctx context.Context, workspaceId, planId int) (SharedPlanRef, error)
+}
+
+type RepositoryImpl struct {
+	db *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *RepositoryImpl {
+	return &RepositoryImpl{db: db}
+}
+
+func (r *RepositoryImpl) CreateWorkspace(ctx context.Context, name string, ownerId int) (Workspace, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return Workspace{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	workspace := Workspace{Name: name, Role: RoleOwner}
+	err = tx.QueryRow(ctx, "INSERT INTO workspace (name) VALUES ($1) RETURNING id, created_at", name).
+		Scan(&workspace.Id, &workspace.CreatedAt)
+	if err != nil {
+		return Workspace{}, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	_, err = tx.Exec(ctx, "INSERT INTO workspace_member (workspace_id, user_id, role) VALUES ($1, $2, $3)",
+		workspace.Id, ownerId, RoleOwner)
+	if err != nil {
+		return Workspace{}, fmt.Errorf("failed to add workspace owner: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Workspace{}, err
+	}
+	return workspace, nil
+}
+
+func (r *RepositoryImpl) GetWorkspaces(ctx context.Context, userId int) ([]Workspace, error) {
+	query := `SELECT w.id, w.name, w.created_at, m.role
+			  FROM workspace w JOIN workspace_member m ON m.workspace_id = w.id
+			  WHERE m.user_id = $1 ORDER BY w.name, w.id`
+	rows, err := r.db.Query(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspaces: %w", err)
+	}
+	defer rows.Close()
+
+	workspaces := make([]Workspace, 0)
+	for rows.Next() {
+		var workspace Workspace
+		if err := rows.Scan(&workspace.Id, &workspace.Name, &workspace.CreatedAt, &workspace.Role); err != nil {
+			return nil, fmt.Errorf("failed to scan workspace: %w", err)
+		}
+		workspaces = append(workspaces, workspace)
+	}
+	return workspaces, rows.Err()
+}
+
+func (r *RepositoryImpl) GetWorkspace(ctx context.Context, userId, workspaceId int) (Workspace, error) {
+	query := `SELECT w.id, w.name, w.created_at, m.role
+			  FROM workspace w JOIN workspace_member m ON m.workspace_id = w.id
+			  WHERE m.user_id = $1 AND w.id = $2`
+	var workspace Workspace
+	err := r.db.QueryRow(ctx, query, userId, workspaceId).
+		Scan(&workspace.Id, &workspace.Name, &workspace.CreatedAt, &workspace.Role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Workspace{}, ErrWorkspaceNotFound
+		}
+		return Workspace{}, fmt.Errorf("failed to get workspace: %w", err)
+	}
+	return workspace, nil
+}
+
+func (r *RepositoryImpl) RenameWorkspace(ctx context.Context, workspaceId int, name string) error {
+	tag, err := r.db.Exec(ctx, "UPDATE workspace SET name = $1 WHERE id = $2", name, workspaceId)
+	if err != nil {
+		return fmt.Errorf("failed to rename workspace: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWorkspaceNotFound
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) DeleteWorkspace(ctx context.Context, workspaceId int) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM workspace WHERE id = $1", workspaceId)
+	if err != nil {
+		return fmt.Errorf("failed to delete workspace: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWorkspaceNotFound
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) GetMembers(ctx context.Context, workspaceId int) ([]Member, error) {
+	query := `SELECT workspace_id, user_id, role, joined_at FROM workspace_member
+			  WHERE workspace_id = $1 ORDER BY joined_at, user_id`
+	rows, err := r.db.Query(ctx, query, workspaceId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspace members: %w", err)
+	}
+	defer rows.Close()
+
+	members := make([]Member, 0)
+	for rows.Next() {
+		var member Member
+		if err := rows.Scan(&member.WorkspaceId, &member.UserId, &member.Role, &member.JoinedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan workspace member: %w", err)
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+func (r *RepositoryImpl) CreateInvitation(ctx context.Context, invitation Invitation) (Invitation, error) {
+	query := `INSERT INTO workspace_invitation (workspace_id, user_id, role, invited_by)
+			  SELECT $1, $2, $3, $4
+			  WHERE NOT EXISTS (SELECT 1 FROM workspace_member WHERE workspace_id = $1 AND user_id = $2)
+			  ON CONFLICT (workspace_id, user_id) DO UPDATE SET
+				  role = EXCLUDED.role,
+				  invited_by = EXCLUDED.invited_by,
+				  created_at = NOW()
+			  RETURNING id, created_at`
+	err := r.db.QueryRow(ctx, query, invitation.WorkspaceId, invitation.UserId, invitation.Role, invitation.InvitedBy).
+		Scan(&invitation.Id, &invitation.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Invitation{}, ErrAlreadyMember
+		}
+		return Invitation{}, fmt.Errorf("failed to create workspace invitation: %w", err)
+	}
+	return invitation, nil
+}
+
+func (r *RepositoryImpl) GetUserInvitations(ctx context.Context, userId int) ([]Invitation, error) {
+	query := `SELECT i.id, i.workspace_id, w.name, i.user_id, i.role, COALESCE(i.invited_by, 0), i.created_at
+			  FROM workspace_invitation i JOIN workspace w ON w.id = i.workspace_id
+			  WHERE i.user_id = $1 ORDER BY i.created_at, i.id`
+	rows, err := r.db.Query(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspace invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := make([]Invitation, 0)
+	for rows.Next() {
+		var i Invitation
+		err := rows.Scan(&i.Id, &i.WorkspaceId, &i.WorkspaceName, &i.UserId, &i.Role, &i.InvitedBy, &i.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan workspace invitation: %w", err)
+		}
+		invitations = append(invitations, i)
+	}
+	return invitations, rows.Err()
+}
+
+func (r *RepositoryImpl) AcceptInvitation(ctx context.Context, userId, invitationId int) (Member, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return Member{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	member := Member{UserId: userId}
+	err = tx.QueryRow(ctx, "DELETE FROM workspace_invitation WHERE id = $1 AND user_id = $2 RETURNING workspace_id, role",
+		invitationId, userId).Scan(&member.WorkspaceId, &member.Role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Member{}, ErrInvitationNotFound
+		}
+		return Member{}, fmt.Errorf("failed to accept workspace invitation: %w", err)
+	}
+	query := `INSERT INTO workspace_member (workspace_id, user_id, role) VALUES ($1, $2, $3)
+			  ON CONFLICT (workspace_id, user_id) DO NOTHING
+			  RETURNING joined_at`
+	err = tx.QueryRow(ctx, query, member.WorkspaceId, member.UserId, member.Role).Scan(&member.JoinedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Member{}, ErrAlreadyMember
+		}
+		return Member{}, fmt.Errorf("failed to add workspace member: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Member{}, err
+	}
+	return member, nil
+}
+
+func (r *RepositoryImpl) DeleteInvitation(ctx context.Context, userId, invitationId int) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM workspace_invitation WHERE id = $1 AND user_id = $2", invitationId, userId)
+	if err != nil {
+		return fmt.Errorf("failed to delete workspace invitation: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInvitationNotFound
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) UpdateMemberRole(ctx context.Context, workspaceId, userId int, role Role) error {
+	tag, err := r.db.Exec(ctx, "UPDATE workspace_member SET role = $1 WHERE workspace_id = $2 AND user_id = $3",
+		role, workspaceId, userId)
+	if err != nil {
+		return fmt.Errorf("failed to update workspace member: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMemberNotFound
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) RemoveMember(ctx context.Context, workspaceId, userId int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	tag, err := tx.Exec(ctx, "DELETE FROM workspace_member WHERE workspace_id = $1 AND user_id = $2", workspaceId, userId)
+	if err != nil {
+		return fmt.Errorf("failed to remove workspace member: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMemberNotFound
+	}
+	_, err = tx.Exec(ctx, "DELETE FROM workspace_budget_plan WHERE workspace_id = $1 AND user_id = $2", workspaceId, userId)
+	if err != nil {
+		return fmt.Errorf("failed to unshare plans of workspace member: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *RepositoryImpl) SharePlan(ctx context.Context, ref SharedPlanRef) error {
+	query := `INSERT INTO workspace_budget_plan (workspace_id, budget_plan_id, user_id) VALUES ($1, $2, $3)
+			  ON CONFLICT (workspace_id, budget_plan_id) DO NOTHING`
+	_, err := r.db.Exec(ctx, query, ref.WorkspaceId, ref.PlanId, ref.OwnerId)
+	if err != nil {
+		return fmt.Errorf("failed to share plan: %w", err)
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) UnsharePlan(ctx context.Context, workspaceId, planId int) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM workspace_budget_plan WHERE workspace_id = $1 AND budget_plan_id = $2",
+		workspaceId, planId)
+	if err != nil {
+		return fmt.Errorf("failed to unshare plan: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPlanNotShared
+	}
+	return nil
+}
+
+func (r *RepositoryImpl) GetSharedPlans(ctx context.Context, workspaceId int) ([]SharedPlanRef, error) {
+	query := `SELECT workspace_id, budget_plan_id, user_id, shared_at FROM workspace_budget_plan
+			  WHERE workspace_id = $1 ORDER BY shared_at, budget_plan_id`
+	rows, err := r.db.Query(ctx, query, workspaceId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shared plans: %w", err)
+	}
+	defer rows.Close()
+
+	refs := make([]SharedPlanRef, 0)
+	for rows.Next() {
+		var ref SharedPlanRef
+		if err := rows.Scan(&ref.WorkspaceId, &ref.PlanId, &ref.OwnerId, &ref.SharedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan shared plan: %w", err)
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+func (r *RepositoryImpl) GetSharedPlan(ctx context.Context, workspaceId, planId int) (SharedPlanRef, error) {
+	query := `SELECT workspace_id, budget_plan_id, user_id, shared_at FROM workspace_budget_plan
+			  WHERE workspace_id = $1 AND budget_plan_id = $2`
+	var ref SharedPlanRef
+	err := r.db.QueryRow(ctx, query, workspaceId, planId).Scan(&ref.WorkspaceId, &ref.PlanId, &ref.OwnerId, &ref.SharedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return SharedPlanRef{}, ErrPlanNotShared
+		}
+		return SharedPlanRef{}, fmt.Errorf("failed to get shared plan: %w", err)
+	}
+	return ref, nil
+}
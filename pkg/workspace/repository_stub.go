@@ -0,0 +1,261 @@
+package workspace
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type RepositoryStub struct {
+	mu               sync.Mutex
+	nextId           int
+	nextInvitationId int
+	workspaces       map[int]Workspace
+	members          []Member
+	invitations      []Invitation
+	plans            []SharedPlanRef
+}
+
+func NewRepositoryStub() *RepositoryStub {
+	return &RepositoryStub{workspaces: make(map[int]Workspace)}
+}
+
+func (r *RepositoryStub) CreateWorkspace(ctx context.Context, name string, ownerId int) (Workspace, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextId++
+	workspace := Workspace{Id: r.nextId, Name: name, CreatedAt: time.Now()}
+	r.workspaces[workspace.Id] = workspace
+	r.members = append(r.members, Member{WorkspaceId: workspace.Id, UserId: ownerId, Role: RoleOwner, JoinedAt: time.Now()})
+	workspace.Role = RoleOwner
+	return workspace, nil
+}
+
+func (r *RepositoryStub) GetWorkspaces(ctx context.Context, userId int) ([]Workspace, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	workspaces := make([]Workspace, 0)
+	for _, member := range r.members {
+		if member.UserId == userId {
+			workspace := r.workspaces[member.WorkspaceId]
+			workspace.Role = member.Role
+			workspaces = append(workspaces, workspace)
+		}
+	}
+	sort.Slice(workspaces, func(i, j int) bool { return workspaces[i].Id < workspaces[j].Id })
+	return workspaces, nil
+}
+
+func (r *RepositoryStub) GetWorkspace(ctx context.Context, userId, workspaceId int) (Workspace, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, member := range r.members {
+		if member.WorkspaceId == workspaceId && member.UserId == userId {
+			workspace := r.workspaces[workspaceId]
+			workspace.Role = member.Role
+			return workspace, nil
+		}
+	}
+	return Workspace{}, ErrWorkspaceNotFound
+}
+
+func (r *RepositoryStub) RenameWorkspace(ctx context.Context, workspaceId int, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	workspace, ok := r.workspaces[workspaceId]
+	if !ok {
+		return ErrWorkspaceNotFound
+	}
+	workspace.Name = name
+	r.workspaces[workspaceId] = workspace
+	return nil
+}
+
+func (r *RepositoryStub) DeleteWorkspace(ctx context.Context, workspaceId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.workspaces[workspaceId]; !ok {
+		return ErrWorkspaceNotFound
+	}
+	delete(r.workspaces, workspaceId)
+	members := make([]Member, 0)
+	for _, member := range r.members {
+		if member.WorkspaceId != workspaceId {
+			members = append(members, member)
+		}
+	}
+	r.members = members
+	invitations := make([]Invitation, 0)
+	for _, invitation := range r.invitations {
+		if invitation.WorkspaceId != workspaceId {
+			invitations = append(invitations, invitation)
+		}
+	}
+	r.invitations = invitations
+	plans := make([]SharedPlanRef, 0)
+	for _, plan := range r.plans {
+		if plan.WorkspaceId != workspaceId {
+			plans = append(plans, plan)
+		}
+	}
+	r.plans = plans
+	return nil
+}
+
+func (r *RepositoryStub) GetMembers(ctx context.Context, workspaceId int) ([]Member, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	members := make([]Member, 0)
+	for _, member := range r.members {
+		if member.WorkspaceId == workspaceId {
+			members = append(members, member)
+		}
+	}
+	return members, nil
+}
+
+func (r *RepositoryStub) CreateInvitation(ctx context.Context, invitation Invitation) (Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, member := range r.members {
+		if member.WorkspaceId == invitation.WorkspaceId && member.UserId == invitation.UserId {
+			return Invitation{}, ErrAlreadyMember
+		}
+	}
+	invitation.CreatedAt = time.Now()
+	for i, existing := range r.invitations {
+		if existing.WorkspaceId == invitation.WorkspaceId && existing.UserId == invitation.UserId {
+			invitation.Id = existing.Id
+			r.invitations[i] = invitation
+			return invitation, nil
+		}
+	}
+	r.nextInvitationId++
+	invitation.Id = r.nextInvitationId
+	r.invitations = append(r.invitations, invitation)
+	return invitation, nil
+}
+
+func (r *RepositoryStub) GetUserInvitations(ctx context.Context, userId int) ([]Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	invitations := make([]Invitation, 0)
+	for _, invitation := range r.invitations {
+		if invitation.UserId == userId {
+			invitation.WorkspaceName = r.workspaces[invitation.WorkspaceId].Name
+			invitations = append(invitations, invitation)
+		}
+	}
+	return invitations, nil
+}
+
+func (r *RepositoryStub) AcceptInvitation(ctx context.Context, userId, invitationId int) (Member, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, invitation := range r.invitations {
+		if invitation.Id == invitationId && invitation.UserId == userId {
+			r.invitations = append(r.invitations[:i], r.invitations[i+1:]...)
+			for _, existing := range r.members {
+				if existing.WorkspaceId == invitation.WorkspaceId && existing.UserId == userId {
+					return Member{}, ErrAlreadyMember
+				}
+			}
+			member := Member{WorkspaceId: invitation.WorkspaceId, UserId: userId, Role: invitation.Role, JoinedAt: time.Now()}
+			r.members = append(r.members, member)
+			return member, nil
+		}
+	}
+	return Member{}, ErrInvitationNotFound
+}
+
+func (r *RepositoryStub) DeleteInvitation(ctx context.Context, userId, invitationId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, invitation := range r.invitations {
+		if invitation.Id == invitationId && invitation.UserId == userId {
+			r.invitations = append(r.invitations[:i], r.invitations[i+1:]...)
+			return nil
+		}
+	}
+	return ErrInvitationNotFound
+}
+
+func (r *RepositoryStub) UpdateMemberRole(ctx context.Context, workspaceId, userId int, role Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, member := range r.members {
+		if member.WorkspaceId == workspaceId && member.UserId == userId {
+			r.members[i].Role = role
+			return nil
+		}
+	}
+	return ErrMemberNotFound
+}
+
+func (r *RepositoryStub) RemoveMember(ctx context.Context, workspaceId, userId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, member := range r.members {
+		if member.WorkspaceId == workspaceId && member.UserId == userId {
+			r.members = append(r.members[:i], r.members[i+1:]...)
+			plans := make([]SharedPlanRef, 0)
+			for _, plan := range r.plans {
+				if plan.WorkspaceId != workspaceId || plan.OwnerId != userId {
+					plans = append(plans, plan)
+				}
+			}
+			r.plans = plans
+			return nil
+		}
+	}
+	return ErrMemberNotFound
+}
+
+func (r *RepositoryStub) SharePlan(ctx context.Context, ref SharedPlanRef) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, plan := range r.plans {
+		if plan.WorkspaceId == ref.WorkspaceId && plan.PlanId == ref.PlanId {
+			return nil
+		}
+	}
+	ref.SharedAt = time.Now()
+	r.plans = append(r.plans, ref)
+	return nil
+}
+
+func (r *RepositoryStub) UnsharePlan(ctx context.Context, workspaceId, planId int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, plan := range r.plans {
+		if plan.WorkspaceId == workspaceId && plan.PlanId == planId {
+			r.plans = append(r.plans[:i], r.plans[i+1:]...)
+			return nil
+		}
+	}
+	return ErrPlanNotShared
+}
+
+func (r *RepositoryStub) GetSharedPlans(ctx context.Context, workspaceId int) ([]SharedPlanRef, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	plans := make([]SharedPlanRef, 0)
+	for _, plan := range r.plans {
+		if plan.WorkspaceId == workspaceId {
+			plans = append(plans, plan)
+		}
+	}
+	return plans, nil
+}
+
+func (r *RepositoryStub) GetSharedPlan(ctx context.Context, workspaceId, planId int) (SharedPlanRef, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, plan := range r.plans {
+		if plan.WorkspaceId == workspaceId && plan.PlanId == planId {
+			return plan, nil
+		}
+	}
+	return SharedPlanRef{}, ErrPlanNotShared
+}
@@ -0,0 +1,420 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrInvalidName = errors.New("workspace name cannot be empty")
+var ErrInvalidRole = errors.New("role must be owner, editor or viewer")
+var ErrForbidden = errors.New("workspace role does not allow this operation")
+var ErrLastOwner = errors.New("workspace must keep at least one owner")
+var ErrInvalidRange = errors.New("calendar range is too long")
+
+// maxCalendarRange is the longest range of the workspace calendar, every member's calendar is read for it.
+const maxCalendarRange = 31 * 24 * time.Hour
+
+type UserProvider interface {
+	GetUser(ctx context.Context, id int) (user.User, error)
+	GetUserByUid(ctx context.Context, uid string) (user.User, error)
+}
+
+type EventsProvider interface {
+	GetEvents(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error)
+}
+
+// Service manages workspaces and gives their members access to the budget plans and calendars of each other.
+// Shared data stays owned by the member, it is read and changed on their behalf.
+type Service interface {
+	CreateWorkspace(ctx context.Context, name string) (Workspace, error)
+	GetWorkspaces(ctx context.Context) ([]Workspace, error)
+	GetWorkspace(ctx context.Context, workspaceId int) (Workspace, error)
+	RenameWorkspace(ctx context.Context, workspaceId int, name string) (Workspace, error)
+	DeleteWorkspace(ctx context.Context, workspaceId int) error
+	GetMembers(ctx context.Context, workspaceId int) ([]MemberUser, error)
+	// InviteMember invites a user to join the workspace with the role, allowed to owners. The user becomes a member
+	// once they accept the invitation.
+	InviteMember(ctx context.Context, workspaceId int, userUid string, role Role) (Invitation, error)
+	// GetInvitations returns the pending invitations of the current user.
+	GetInvitations(ctx context.Context) ([]Invitation, error)
+	AcceptInvitation(ctx context.Context, invitationId int) (Workspace, error)
+	DeclineInvitation(ctx context.Context, invitationId int) error
+	UpdateMemberRole(ctx context.Context, workspaceId int, userUid string, role Role) error
+	// RemoveMember removes a member, owners remove anyone and every member can leave the workspace.
+	RemoveMember(ctx context.Context, workspaceId int, userUid string) error
+	// SharePlan shares a budget plan of the current user with the workspace.
+	SharePlan(ctx context.Context, workspaceId, planId int) error
+	// UnsharePlan stops sharing the plan, allowed to the member who shared it and to owners.
+	UnsharePlan(ctx context.Context, workspaceId, planId int) error
+	GetSharedPlans(ctx context.Context, workspaceId int) ([]SharedPlan, error)
+	GetSharedPlan(ctx context.Context, workspaceId, planId int) (SharedPlan, error)
+	CreateSharedItem(ctx context.Context, workspaceId int, item budget_plan.BudgetItem) (budget_plan.BudgetItem, error)
+	UpdateSharedItem(ctx context.Context, workspaceId int, item budget_plan.BudgetItem) (budget_plan.BudgetItem, error)
+	DeleteSharedItem(ctx context.Context, workspaceId, planId, itemId int) error
+	// GetCalendar returns the events of every member in the given time range, at most maxCalendarRange long.
+	GetCalendar(ctx context.Context, workspaceId int, from, to time.Time) ([]MemberEvents, error)
+}
+
+type ServiceImpl struct {
+	repo        Repository
+	users       UserProvider
+	budgetPlans budget_plan.Service
+	events      EventsProvider
+}
+
+func NewService(repo Repository, users UserProvider, budgetPlans budget_plan.Service, events EventsProvider) *ServiceImpl {
+	return &ServiceImpl{
+		repo:        repo,
+		users:       users,
+		budgetPlans: budgetPlans,
+		events:      events,
+	}
+}
+
+func (s *ServiceImpl) CreateWorkspace(ctx context.Context, name string) (Workspace, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Workspace{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Workspace{}, ErrInvalidName
+	}
+	return s.repo.CreateWorkspace(ctx, name, userId)
+}
+
+func (s *ServiceImpl) GetWorkspaces(ctx context.Context) ([]Workspace, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.GetWorkspaces(ctx, userId)
+}
+
+func (s *ServiceImpl) GetWorkspace(ctx context.Context, workspaceId int) (Workspace, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Workspace{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.GetWorkspace(ctx, userId, workspaceId)
+}
+
+// authorize returns the workspace when the current user is a member allowed to do the operation.
+func (s *ServiceImpl) authorize(ctx context.Context, workspaceId int, allowed func(Role) bool) (Workspace, int, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Workspace{}, 0, fmt.Errorf("failed to get current user: %w", err)
+	}
+	workspace, err := s.repo.GetWorkspace(ctx, userId, workspaceId)
+	if err != nil {
+		return Workspace{}, 0, err
+	}
+	if allowed != nil && !allowed(workspace.Role) {
+		return Workspace{}, 0, ErrForbidden
+	}
+	return workspace, userId, nil
+}
+
+func isOwner(role Role) bool {
+	return role == RoleOwner
+}
+
+func (s *ServiceImpl) RenameWorkspace(ctx context.Context, workspaceId int, name string) (Workspace, error) {
+	workspace, _, err := s.authorize(ctx, workspaceId, isOwner)
+	if err != nil {
+		return Workspace{}, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Workspace{}, ErrInvalidName
+	}
+	if err := s.repo.RenameWorkspace(ctx, workspaceId, name); err != nil {
+		return Workspace{}, err
+	}
+	workspace.Name = name
+	return workspace, nil
+}
+
+func (s *ServiceImpl) DeleteWorkspace(ctx context.Context, workspaceId int) error {
+	if _, _, err := s.authorize(ctx, workspaceId, isOwner); err != nil {
+		return err
+	}
+	return s.repo.DeleteWorkspace(ctx, workspaceId)
+}
+
+func (s *ServiceImpl) GetMembers(ctx context.Context, workspaceId int) ([]MemberUser, error) {
+	if _, _, err := s.authorize(ctx, workspaceId, nil); err != nil {
+		return nil, err
+	}
+	members, err := s.repo.GetMembers(ctx, workspaceId)
+	if err != nil {
+		return nil, err
+	}
+	memberUsers := make([]MemberUser, 0, len(members))
+	for _, member := range members {
+		u, err := s.users.GetUser(ctx, member.UserId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get workspace member %d: %w", member.UserId, err)
+		}
+		memberUsers = append(memberUsers, MemberUser{Member: member, User: u})
+	}
+	return memberUsers, nil
+}
+
+func (s *ServiceImpl) InviteMember(ctx context.Context, workspaceId int, userUid string, role Role) (Invitation, error) {
+	if !role.IsValid() {
+		return Invitation{}, ErrInvalidRole
+	}
+	_, userId, err := s.authorize(ctx, workspaceId, isOwner)
+	if err != nil {
+		return Invitation{}, err
+	}
+	u, err := s.users.GetUserByUid(ctx, userUid)
+	if err != nil {
+		return Invitation{}, err
+	}
+	return s.repo.CreateInvitation(ctx, Invitation{WorkspaceId: workspaceId, UserId: u.Id, Role: role, InvitedBy: userId})
+}
+
+func (s *ServiceImpl) GetInvitations(ctx context.Context) ([]Invitation, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.GetUserInvitations(ctx, userId)
+}
+
+func (s *ServiceImpl) AcceptInvitation(ctx context.Context, invitationId int) (Workspace, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return Workspace{}, fmt.Errorf("failed to get current user: %w", err)
+	}
+	member, err := s.repo.AcceptInvitation(ctx, userId, invitationId)
+	if err != nil {
+		return Workspace{}, err
+	}
+	return s.repo.GetWorkspace(ctx, userId, member.WorkspaceId)
+}
+
+func (s *ServiceImpl) DeclineInvitation(ctx context.Context, invitationId int) error {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+	return s.repo.DeleteInvitation(ctx, userId, invitationId)
+}
+
+func (s *ServiceImpl) UpdateMemberRole(ctx context.Context, workspaceId int, userUid string, role Role) error {
+	if !role.IsValid() {
+		return ErrInvalidRole
+	}
+	if _, _, err := s.authorize(ctx, workspaceId, isOwner); err != nil {
+		return err
+	}
+	member, err := s.findMember(ctx, workspaceId, userUid)
+	if err != nil {
+		return err
+	}
+	if member.Role == RoleOwner && role != RoleOwner {
+		if err := s.checkOtherOwner(ctx, workspaceId, member.UserId); err != nil {
+			return err
+		}
+	}
+	return s.repo.UpdateMemberRole(ctx, workspaceId, member.UserId, role)
+}
+
+func (s *ServiceImpl) RemoveMember(ctx context.Context, workspaceId int, userUid string) error {
+	workspace, userId, err := s.authorize(ctx, workspaceId, nil)
+	if err != nil {
+		return err
+	}
+	member, err := s.findMember(ctx, workspaceId, userUid)
+	if err != nil {
+		return err
+	}
+	if member.UserId != userId && workspace.Role != RoleOwner {
+		return ErrForbidden
+	}
+	if member.Role == RoleOwner {
+		if err := s.checkOtherOwner(ctx, workspaceId, member.UserId); err != nil {
+			return err
+		}
+	}
+	return s.repo.RemoveMember(ctx, workspaceId, member.UserId)
+}
+
+func (s *ServiceImpl) findMember(ctx context.Context, workspaceId int, userUid string) (Member, error) {
+	u, err := s.users.GetUserByUid(ctx, userUid)
+	if err != nil {
+		return Member{}, err
+	}
+	members, err := s.repo.GetMembers(ctx, workspaceId)
+	if err != nil {
+		return Member{}, err
+	}
+	for _, member := range members {
+		if member.UserId == u.Id {
+			return member, nil
+		}
+	}
+	return Member{}, ErrMemberNotFound
+}
+
+// checkOtherOwner returns ErrLastOwner when the user is the only owner of the workspace.
+func (s *ServiceImpl) checkOtherOwner(ctx context.Context, workspaceId, userId int) error {
+	members, err := s.repo.GetMembers(ctx, workspaceId)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		if member.Role == RoleOwner && member.UserId != userId {
+			return nil
+		}
+	}
+	return ErrLastOwner
+}
+
+func (s *ServiceImpl) SharePlan(ctx context.Context, workspaceId, planId int) error {
+	_, userId, err := s.authorize(ctx, workspaceId, Role.CanEdit)
+	if err != nil {
+		return err
+	}
+	// Only own plans can be shared
+	if _, err := s.budgetPlans.GetPlan(ctx, planId); err != nil {
+		return err
+	}
+	return s.repo.SharePlan(ctx, SharedPlanRef{WorkspaceId: workspaceId, PlanId: planId, OwnerId: userId})
+}
+
+func (s *ServiceImpl) UnsharePlan(ctx context.Context, workspaceId, planId int) error {
+	workspace, userId, err := s.authorize(ctx, workspaceId, nil)
+	if err != nil {
+		return err
+	}
+	ref, err := s.repo.GetSharedPlan(ctx, workspaceId, planId)
+	if err != nil {
+		return err
+	}
+	if ref.OwnerId != userId && workspace.Role != RoleOwner {
+		return ErrForbidden
+	}
+	return s.repo.UnsharePlan(ctx, workspaceId, planId)
+}
+
+func (s *ServiceImpl) GetSharedPlans(ctx context.Context, workspaceId int) ([]SharedPlan, error) {
+	if _, _, err := s.authorize(ctx, workspaceId, nil); err != nil {
+		return nil, err
+	}
+	refs, err := s.repo.GetSharedPlans(ctx, workspaceId)
+	if err != nil {
+		return nil, err
+	}
+	plans := make([]SharedPlan, 0, len(refs))
+	for _, ref := range refs {
+		plan, err := s.loadSharedPlan(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+func (s *ServiceImpl) GetSharedPlan(ctx context.Context, workspaceId, planId int) (SharedPlan, error) {
+	if _, _, err := s.authorize(ctx, workspaceId, nil); err != nil {
+		return SharedPlan{}, err
+	}
+	ref, err := s.repo.GetSharedPlan(ctx, workspaceId, planId)
+	if err != nil {
+		return SharedPlan{}, err
+	}
+	return s.loadSharedPlan(ctx, ref)
+}
+
+func (s *ServiceImpl) loadSharedPlan(ctx context.Context, ref SharedPlanRef) (SharedPlan, error) {
+	owner, err := s.users.GetUser(ctx, ref.OwnerId)
+	if err != nil {
+		return SharedPlan{}, fmt.Errorf("failed to get owner of shared plan %d: %w", ref.PlanId, err)
+	}
+	plan, err := s.budgetPlans.GetPlan(user.WithUser(ctx, owner), ref.PlanId)
+	if err != nil {
+		return SharedPlan{}, err
+	}
+	return SharedPlan{Plan: plan, Owner: owner, SharedAt: ref.SharedAt}, nil
+}
+
+// ownerContext authorizes an edit of the shared plan and returns the context of the plan owner to do it in.
+func (s *ServiceImpl) ownerContext(ctx context.Context, workspaceId, planId int) (context.Context, budget_plan.BudgetPlan, error) {
+	if _, _, err := s.authorize(ctx, workspaceId, Role.CanEdit); err != nil {
+		return nil, budget_plan.BudgetPlan{}, err
+	}
+	ref, err := s.repo.GetSharedPlan(ctx, workspaceId, planId)
+	if err != nil {
+		return nil, budget_plan.BudgetPlan{}, err
+	}
+	shared, err := s.loadSharedPlan(ctx, ref)
+	if err != nil {
+		return nil, budget_plan.BudgetPlan{}, err
+	}
+	return user.WithUser(ctx, shared.Owner), shared.Plan, nil
+}
+
+func (s *ServiceImpl) CreateSharedItem(ctx context.Context, workspaceId int, item budget_plan.BudgetItem) (budget_plan.BudgetItem, error) {
+	ownerCtx, _, err := s.ownerContext(ctx, workspaceId, item.PlanId)
+	if err != nil {
+		return budget_plan.BudgetItem{}, err
+	}
+	return s.budgetPlans.CreateItem(ownerCtx, item)
+}
+
+func (s *ServiceImpl) UpdateSharedItem(ctx context.Context, workspaceId int, item budget_plan.BudgetItem) (budget_plan.BudgetItem, error) {
+	ownerCtx, plan, err := s.ownerContext(ctx, workspaceId, item.PlanId)
+	if err != nil {
+		return budget_plan.BudgetItem{}, err
+	}
+	if _, ok := plan.FindItem(item.Id); !ok {
+		return budget_plan.BudgetItem{}, budget_plan.ErrBudgetPlanItemNotFound
+	}
+	return s.budgetPlans.UpdateItem(ownerCtx, item)
+}
+
+func (s *ServiceImpl) DeleteSharedItem(ctx context.Context, workspaceId, planId, itemId int) error {
+	ownerCtx, plan, err := s.ownerContext(ctx, workspaceId, planId)
+	if err != nil {
+		return err
+	}
+	if _, ok := plan.FindItem(itemId); !ok {
+		return budget_plan.ErrBudgetPlanItemNotFound
+	}
+	_, err = s.budgetPlans.DeleteItem(ownerCtx, itemId)
+	return err
+}
+
+func (s *ServiceImpl) GetCalendar(ctx context.Context, workspaceId int, from, to time.Time) ([]MemberEvents, error) {
+	if to.Sub(from) > maxCalendarRange {
+		return nil, ErrInvalidRange
+	}
+	members, err := s.GetMembers(ctx, workspaceId)
+	if err != nil {
+		return nil, err
+	}
+	calendars := make([]MemberEvents, 0, len(members))
+	for _, member := range members {
+		events, err := s.events.GetEvents(user.WithUser(ctx, member.User), from, to)
+		if err != nil {
+			// A member's external calendar being unavailable should not hide the others
+			log.Warnf("failed to get events of workspace %d member %d: %v", workspaceId, member.UserId, err)
+			events = []calendar.Event{}
+		}
+		calendars = append(calendars, MemberEvents{Member: member.User, Events: events})
+	}
+	return calendars, nil
+}
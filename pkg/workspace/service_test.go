@@ -0,0 +1,359 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/klokku/klokku/internal/event_bus"
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type eventsStub struct {
+	events map[int][]calendar.Event
+	failed map[int]bool
+}
+
+func (e *eventsStub) GetEvents(ctx context.Context, from time.Time, to time.Time) ([]calendar.Event, error) {
+	userId, err := user.CurrentId(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if e.failed[userId] {
+		return nil, errors.New("calendar unavailable")
+	}
+	return e.events[userId], nil
+}
+
+type serviceTest struct {
+	service     *ServiceImpl
+	budgetPlans budget_plan.Service
+	events      *eventsStub
+	owner       context.Context
+	editor      context.Context
+	viewer      context.Context
+	ownerUser   user.User
+	editorUser  user.User
+	viewerUser  user.User
+}
+
+// setupServiceTest creates a workspace of an owner, an editor and a viewer
+func setupServiceTest(t *testing.T) (serviceTest, Workspace) {
+	users := user.NewUserService(user.NewStubUserRepository(), event_bus.NewEventBus())
+	budgetPlans := budget_plan.NewBudgetPlanService(budget_plan.NewStubBudgetRepo(), event_bus.NewEventBus())
+	events := &eventsStub{events: map[int][]calendar.Event{}, failed: map[int]bool{}}
+	service := NewService(NewRepositoryStub(), users, budgetPlans, events)
+
+	createUser := func(name string) user.User {
+		u, err := users.CreateUser(context.Background(), user.User{Uid: "uid-" + name, Username: name, DisplayName: name})
+		require.NoError(t, err)
+		return u
+	}
+	test := serviceTest{
+		service:     service,
+		budgetPlans: budgetPlans,
+		events:      events,
+		ownerUser:   createUser("owner"),
+		editorUser:  createUser("editor"),
+		viewerUser:  createUser("viewer"),
+	}
+	test.owner = user.WithUser(context.Background(), test.ownerUser)
+	test.editor = user.WithUser(context.Background(), test.editorUser)
+	test.viewer = user.WithUser(context.Background(), test.viewerUser)
+
+	workspace, err := service.CreateWorkspace(test.owner, " Family ")
+	require.NoError(t, err)
+	test.join(t, workspace.Id, test.editor, test.editorUser, RoleEditor)
+	test.join(t, workspace.Id, test.viewer, test.viewerUser, RoleViewer)
+	return test, workspace
+}
+
+// join invites the user to the workspace and accepts the invitation
+func (s serviceTest) join(t *testing.T, workspaceId int, ctx context.Context, u user.User, role Role) {
+	invitation, err := s.service.InviteMember(s.owner, workspaceId, u.Uid, role)
+	require.NoError(t, err)
+	_, err = s.service.AcceptInvitation(ctx, invitation.Id)
+	require.NoError(t, err)
+}
+
+func TestServiceImpl_CreateWorkspace(t *testing.T) {
+	// given
+	test, workspace := setupServiceTest(t)
+
+	// when
+	workspaces, err := test.service.GetWorkspaces(test.viewer)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "Family", workspace.Name)
+	assert.Equal(t, RoleOwner, workspace.Role)
+	require.Len(t, workspaces, 1)
+	assert.Equal(t, workspace.Id, workspaces[0].Id)
+	assert.Equal(t, RoleViewer, workspaces[0].Role)
+
+	_, err = test.service.CreateWorkspace(test.owner, "  ")
+	assert.ErrorIs(t, err, ErrInvalidName)
+}
+
+func TestServiceImpl_Members(t *testing.T) {
+	t.Run("should list members with user data", func(t *testing.T) {
+		// given
+		test, workspace := setupServiceTest(t)
+
+		// when
+		members, err := test.service.GetMembers(test.viewer, workspace.Id)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, members, 3)
+		assert.Equal(t, "owner", members[0].User.Username)
+		assert.Equal(t, RoleOwner, members[0].Role)
+		assert.Equal(t, RoleEditor, members[1].Role)
+		assert.Equal(t, RoleViewer, members[2].Role)
+	})
+
+	t.Run("should hide workspace from non members", func(t *testing.T) {
+		// given
+		test, workspace := setupServiceTest(t)
+		outsider := user.WithUser(context.Background(), user.User{Id: 999})
+
+		// when
+		_, err := test.service.GetMembers(outsider, workspace.Id)
+
+		// then
+		assert.ErrorIs(t, err, ErrWorkspaceNotFound)
+	})
+
+	t.Run("should allow only owners to manage members", func(t *testing.T) {
+		// given
+		test, workspace := setupServiceTest(t)
+
+		// when
+		err := test.service.UpdateMemberRole(test.editor, workspace.Id, test.viewerUser.Uid, RoleEditor)
+
+		// then
+		assert.ErrorIs(t, err, ErrForbidden)
+		assert.ErrorIs(t, test.service.RemoveMember(test.editor, workspace.Id, test.viewerUser.Uid), ErrForbidden)
+		_, err = test.service.InviteMember(test.editor, workspace.Id, test.viewerUser.Uid, RoleEditor)
+		assert.ErrorIs(t, err, ErrForbidden)
+		_, err = test.service.InviteMember(test.owner, workspace.Id, test.viewerUser.Uid, RoleEditor)
+		assert.ErrorIs(t, err, ErrAlreadyMember)
+		_, err = test.service.InviteMember(test.owner, workspace.Id, test.viewerUser.Uid, "admin")
+		assert.ErrorIs(t, err, ErrInvalidRole)
+	})
+
+	t.Run("should let member leave", func(t *testing.T) {
+		// given
+		test, workspace := setupServiceTest(t)
+
+		// when
+		err := test.service.RemoveMember(test.viewer, workspace.Id, test.viewerUser.Uid)
+
+		// then
+		require.NoError(t, err)
+		workspaces, err := test.service.GetWorkspaces(test.viewer)
+		require.NoError(t, err)
+		assert.Empty(t, workspaces)
+	})
+
+	t.Run("should keep last owner", func(t *testing.T) {
+		// given
+		test, workspace := setupServiceTest(t)
+
+		// when
+		err := test.service.UpdateMemberRole(test.owner, workspace.Id, test.ownerUser.Uid, RoleEditor)
+
+		// then
+		assert.ErrorIs(t, err, ErrLastOwner)
+		assert.ErrorIs(t, test.service.RemoveMember(test.owner, workspace.Id, test.ownerUser.Uid), ErrLastOwner)
+
+		require.NoError(t, test.service.UpdateMemberRole(test.owner, workspace.Id, test.editorUser.Uid, RoleOwner))
+		assert.NoError(t, test.service.RemoveMember(test.owner, workspace.Id, test.ownerUser.Uid))
+	})
+}
+
+func TestServiceImpl_Invitations(t *testing.T) {
+	t.Run("should add member only once the invitation is accepted", func(t *testing.T) {
+		// given
+		test, _ := setupServiceTest(t)
+		workspace, err := test.service.CreateWorkspace(test.owner, "Work")
+		require.NoError(t, err)
+
+		// when
+		invitation, err := test.service.InviteMember(test.owner, workspace.Id, test.editorUser.Uid, RoleEditor)
+
+		// then
+		require.NoError(t, err)
+		_, err = test.service.GetWorkspace(test.editor, workspace.Id)
+		assert.ErrorIs(t, err, ErrWorkspaceNotFound)
+		invitations, err := test.service.GetInvitations(test.editor)
+		require.NoError(t, err)
+		require.Len(t, invitations, 1)
+		assert.Equal(t, "Work", invitations[0].WorkspaceName)
+		assert.Equal(t, RoleEditor, invitations[0].Role)
+
+		joined, err := test.service.AcceptInvitation(test.editor, invitation.Id)
+		require.NoError(t, err)
+		assert.Equal(t, workspace.Id, joined.Id)
+		assert.Equal(t, RoleEditor, joined.Role)
+		invitations, err = test.service.GetInvitations(test.editor)
+		require.NoError(t, err)
+		assert.Empty(t, invitations)
+	})
+
+	t.Run("should not let another user accept the invitation", func(t *testing.T) {
+		// given
+		test, _ := setupServiceTest(t)
+		workspace, err := test.service.CreateWorkspace(test.owner, "Work")
+		require.NoError(t, err)
+		invitation, err := test.service.InviteMember(test.owner, workspace.Id, test.editorUser.Uid, RoleEditor)
+		require.NoError(t, err)
+
+		// when
+		_, err = test.service.AcceptInvitation(test.viewer, invitation.Id)
+
+		// then
+		assert.ErrorIs(t, err, ErrInvitationNotFound)
+		_, err = test.service.GetWorkspace(test.viewer, workspace.Id)
+		assert.ErrorIs(t, err, ErrWorkspaceNotFound)
+	})
+
+	t.Run("should not join after declining", func(t *testing.T) {
+		// given
+		test, _ := setupServiceTest(t)
+		workspace, err := test.service.CreateWorkspace(test.owner, "Work")
+		require.NoError(t, err)
+		invitation, err := test.service.InviteMember(test.owner, workspace.Id, test.editorUser.Uid, RoleViewer)
+		require.NoError(t, err)
+
+		// when
+		err = test.service.DeclineInvitation(test.editor, invitation.Id)
+
+		// then
+		require.NoError(t, err)
+		_, err = test.service.AcceptInvitation(test.editor, invitation.Id)
+		assert.ErrorIs(t, err, ErrInvitationNotFound)
+	})
+}
+
+func TestServiceImpl_SharedPlans(t *testing.T) {
+	t.Run("should show shared plan to members", func(t *testing.T) {
+		// given
+		test, workspace := setupServiceTest(t)
+		plan, err := test.budgetPlans.CreatePlan(test.editor, budget_plan.BudgetPlan{Name: "Chores"})
+		require.NoError(t, err)
+
+		// when
+		err = test.service.SharePlan(test.editor, workspace.Id, plan.Id)
+
+		// then
+		require.NoError(t, err)
+		plans, err := test.service.GetSharedPlans(test.viewer, workspace.Id)
+		require.NoError(t, err)
+		require.Len(t, plans, 1)
+		assert.Equal(t, "Chores", plans[0].Plan.Name)
+		assert.Equal(t, test.editorUser.Id, plans[0].Owner.Id)
+	})
+
+	t.Run("should not let viewer share or edit plans", func(t *testing.T) {
+		// given
+		test, workspace := setupServiceTest(t)
+		plan, err := test.budgetPlans.CreatePlan(test.owner, budget_plan.BudgetPlan{Name: "Chores"})
+		require.NoError(t, err)
+		require.NoError(t, test.service.SharePlan(test.owner, workspace.Id, plan.Id))
+
+		// when
+		_, err = test.service.CreateSharedItem(test.viewer, workspace.Id,
+			budget_plan.BudgetItem{PlanId: plan.Id, Name: "Dishes", WeeklyDuration: time.Hour})
+
+		// then
+		assert.ErrorIs(t, err, ErrForbidden)
+		assert.ErrorIs(t, test.service.SharePlan(test.viewer, workspace.Id, plan.Id), ErrForbidden)
+		assert.ErrorIs(t, test.service.UnsharePlan(test.viewer, workspace.Id, plan.Id), ErrForbidden)
+	})
+
+	t.Run("should let editor change items of shared plan", func(t *testing.T) {
+		// given
+		test, workspace := setupServiceTest(t)
+		plan, err := test.budgetPlans.CreatePlan(test.owner, budget_plan.BudgetPlan{Name: "Chores"})
+		require.NoError(t, err)
+		require.NoError(t, test.service.SharePlan(test.owner, workspace.Id, plan.Id))
+
+		// when
+		item, err := test.service.CreateSharedItem(test.editor, workspace.Id,
+			budget_plan.BudgetItem{PlanId: plan.Id, Name: "Dishes", WeeklyDuration: time.Hour})
+		require.NoError(t, err)
+		item.WeeklyDuration = 2 * time.Hour
+		_, err = test.service.UpdateSharedItem(test.editor, workspace.Id, item)
+
+		// then
+		require.NoError(t, err)
+		ownPlan, err := test.budgetPlans.GetPlan(test.owner, plan.Id)
+		require.NoError(t, err)
+		require.Len(t, ownPlan.Items, 1)
+		assert.Equal(t, 2*time.Hour, ownPlan.Items[0].WeeklyDuration)
+
+		require.NoError(t, test.service.DeleteSharedItem(test.editor, workspace.Id, plan.Id, item.Id))
+		assert.ErrorIs(t, test.service.DeleteSharedItem(test.editor, workspace.Id, plan.Id, item.Id),
+			budget_plan.ErrBudgetPlanItemNotFound)
+	})
+
+	t.Run("should not edit plans not shared with the workspace", func(t *testing.T) {
+		// given
+		test, workspace := setupServiceTest(t)
+		plan, err := test.budgetPlans.CreatePlan(test.owner, budget_plan.BudgetPlan{Name: "Private"})
+		require.NoError(t, err)
+
+		// when
+		_, err = test.service.CreateSharedItem(test.editor, workspace.Id,
+			budget_plan.BudgetItem{PlanId: plan.Id, Name: "Dishes", WeeklyDuration: time.Hour})
+
+		// then
+		assert.ErrorIs(t, err, ErrPlanNotShared)
+	})
+
+	t.Run("should unshare plans of removed member", func(t *testing.T) {
+		// given
+		test, workspace := setupServiceTest(t)
+		plan, err := test.budgetPlans.CreatePlan(test.editor, budget_plan.BudgetPlan{Name: "Chores"})
+		require.NoError(t, err)
+		require.NoError(t, test.service.SharePlan(test.editor, workspace.Id, plan.Id))
+
+		// when
+		err = test.service.RemoveMember(test.owner, workspace.Id, test.editorUser.Uid)
+
+		// then
+		require.NoError(t, err)
+		plans, err := test.service.GetSharedPlans(test.owner, workspace.Id)
+		require.NoError(t, err)
+		assert.Empty(t, plans)
+	})
+}
+
+func TestServiceImpl_GetCalendar(t *testing.T) {
+	// given
+	test, workspace := setupServiceTest(t)
+	start := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	test.events.events[test.ownerUser.Id] = []calendar.Event{{UID: "owner-1", StartTime: start, EndTime: start.Add(time.Hour)}}
+	test.events.events[test.editorUser.Id] = []calendar.Event{{UID: "editor-1", StartTime: start, EndTime: start.Add(time.Hour)}}
+	test.events.failed[test.viewerUser.Id] = true
+
+	// when
+	calendars, err := test.service.GetCalendar(test.viewer, workspace.Id, start.Add(-time.Hour), start.Add(24*time.Hour))
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, calendars, 3)
+	assert.Equal(t, test.ownerUser.Id, calendars[0].Member.Id)
+	assert.Equal(t, "owner-1", calendars[0].Events[0].UID)
+	assert.Equal(t, "editor-1", calendars[1].Events[0].UID)
+	assert.Empty(t, calendars[2].Events)
+
+	_, err = test.service.GetCalendar(test.viewer, workspace.Id, start, start.Add(maxCalendarRange+time.Hour))
+	assert.ErrorIs(t, err, ErrInvalidRange)
+}
@@ -0,0 +1,83 @@
+package workspace
+
+import (
+	"time"
+
+	"github.com/klokku/klokku/pkg/budget_plan"
+	"github.com/klokku/klokku/pkg/calendar"
+	"github.com/klokku/klokku/pkg/user"
+)
+
+// Role of a member in the workspace. Owners manage the workspace and its members, editors share their budget
+// plans and edit plans shared by others, viewers only see shared plans and calendars.
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+)
+
+func (r Role) IsValid() bool {
+	return r == RoleOwner || r == RoleEditor || r == RoleViewer
+}
+
+// CanEdit tells whether the role allows sharing and editing budget plans.
+func (r Role) CanEdit() bool {
+	return r == RoleOwner || r == RoleEditor
+}
+
+// Workspace is a group of users (e.g. a household) sharing budget plans and calendars.
+type Workspace struct {
+	Id        int
+	Name      string
+	CreatedAt time.Time
+	// Role of the current user
+	Role Role
+}
+
+type Member struct {
+	WorkspaceId int
+	UserId      int
+	Role        Role
+	JoinedAt    time.Time
+}
+
+// Invitation to join a workspace, the invited user becomes a member with the role once they accept it.
+type Invitation struct {
+	Id          int
+	WorkspaceId int
+	// WorkspaceName is shown to the invited user, who cannot see the workspace before joining
+	WorkspaceName string
+	UserId        int
+	Role          Role
+	InvitedBy     int
+	CreatedAt     time.Time
+}
+
+// MemberUser is a member together with the user data shown to other members.
+type MemberUser struct {
+	Member
+	User user.User
+}
+
+// SharedPlanRef links a budget plan to the workspace it was shared with by its owner.
+type SharedPlanRef struct {
+	WorkspaceId int
+	PlanId      int
+	OwnerId     int
+	SharedAt    time.Time
+}
+
+// SharedPlan is a budget plan shared with the workspace.
+type SharedPlan struct {
+	Plan     budget_plan.BudgetPlan
+	Owner    user.User
+	SharedAt time.Time
+}
+
+// MemberEvents are the calendar events of a member.
+type MemberEvents struct {
+	Member user.User
+	Events []calendar.Event
+}
@@ -0,0 +1,67 @@
+//go:build sdksmoke
+
+package klokku
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestSmoke runs the generated client against a running Klokku server at KLOKKU_SDK_TEST_URL.
+func TestSmoke(t *testing.T) {
+	serverUrl := os.Getenv("KLOKKU_SDK_TEST_URL")
+	if serverUrl == "" {
+		t.Skip("KLOKKU_SDK_TEST_URL is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	uid := randomUid(t)
+
+	cfg := NewConfiguration()
+	cfg.Servers = ServerConfigurations{{URL: serverUrl}}
+	cfg.AddDefaultHeader("X-User-Id", uid)
+	client := NewAPIClient(cfg)
+
+	created, resp, err := client.UserAPI.ApiUserPost(ctx).User(UserUserDTO{
+		Uid:         PtrString(uid),
+		Username:    PtrString("sdk-smoke-" + uid[:8]),
+		DisplayName: PtrString("SDK Smoke Test"),
+	}).Execute()
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if resp.StatusCode != http.StatusCreated || created.GetUid() != uid {
+		t.Fatalf("create user: unexpected response %d %+v", resp.StatusCode, created)
+	}
+
+	plan, _, err := client.BudgetPlanAPI.ApiBudgetplanPost(ctx).Plan(BudgetPlanBudgetPlanDTO{
+		Name: PtrString("SDK smoke plan"),
+	}).Execute()
+	if err != nil {
+		t.Fatalf("create budget plan: %v", err)
+	}
+	if plan.GetId() == 0 {
+		t.Fatalf("create budget plan: no id in %+v", plan)
+	}
+
+	plans, _, err := client.BudgetPlanAPI.ApiBudgetplanGet(ctx).Execute()
+	if err != nil {
+		t.Fatalf("list budget plans: %v", err)
+	}
+	if len(plans) != 1 || plans[0].GetName() != "SDK smoke plan" {
+		t.Fatalf("list budget plans: unexpected plans %+v", plans)
+	}
+}
+
+func randomUid(t *testing.T) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,26 @@
+// Runs the generated client against a running Klokku server at KLOKKU_SDK_TEST_URL.
+// Run with `node --test` after building the package.
+import { test } from "node:test";
+import assert from "node:assert/strict";
+import { randomUUID } from "node:crypto";
+
+import { BudgetPlanApi, Configuration, UserApi } from "./dist/index.js";
+
+const serverUrl = process.env.KLOKKU_SDK_TEST_URL;
+
+test("smoke", { skip: !serverUrl && "KLOKKU_SDK_TEST_URL is not set" }, async () => {
+  const uid = randomUUID();
+  const config = new Configuration({ basePath: serverUrl, headers: { "X-User-Id": uid } });
+
+  const created = await new UserApi(config).apiUserPost({
+    user: { uid, username: `sdk-smoke-${uid.slice(0, 8)}`, displayName: "SDK Smoke Test" },
+  });
+  assert.equal(created.uid, uid);
+
+  const budgetPlanApi = new BudgetPlanApi(config);
+  const plan = await budgetPlanApi.apiBudgetplanPost({ plan: { name: "SDK smoke plan" } });
+  assert.ok(plan.id);
+
+  const plans = await budgetPlanApi.apiBudgetplanGet();
+  assert.deepEqual(plans.map((p) => p.name), ["SDK smoke plan"]);
+});